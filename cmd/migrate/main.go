@@ -0,0 +1,99 @@
+// Command migrate applies or rolls back the versioned database migrations.
+//
+//	migrate up       apply pending migrations (the server also does this on start)
+//	migrate down     roll back the latest migration
+//	migrate version  show the applied version
+//	migrate force N  record version N as applied without running anything,
+//	                 to baseline an existing database or clear a failed one
+//	migrate dedupe-phones [-dry-run]
+//	                 normalise shop phone numbers, merging shops that
+//	                 share one (run once after upgrading)
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/config"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/database"
+)
+
+func main() {
+	dryRun := len(os.Args) == 3 && os.Args[1] == "dedupe-phones" && os.Args[2] == "-dry-run"
+	force := len(os.Args) == 3 && os.Args[1] == "force"
+	if len(os.Args) != 2 && !dryRun && !force {
+		fmt.Fprintln(os.Stderr, "usage: migrate up|down|version|force N|dedupe-phones [-dry-run]")
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if err := database.Connect(cfg); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	switch os.Args[1] {
+	case "up":
+		err = database.Migrate()
+	case "down":
+		err = database.MigrateDown()
+	case "version":
+		var version, latest uint
+		var dirty bool
+		version, latest, dirty, err = database.MigrationVersion()
+		if err == nil {
+			fmt.Printf("version %04d of %04d (dirty: %v)\n", version, latest, dirty)
+		}
+	case "force":
+		var version uint64
+		version, err = strconv.ParseUint(os.Args[2], 10, 32)
+		if err == nil {
+			err = database.ForceMigrationVersion(uint(version))
+		}
+		if err == nil {
+			fmt.Printf("version %04d recorded\n", version)
+		}
+	case "dedupe-phones":
+		err = dedupePhones(dryRun)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+}
+
+// dedupePhones normalises shop phone numbers and reports each change
+func dedupePhones(dryRun bool) error {
+	merges, err := database.DedupeShopPhones(dryRun)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, m := range merges {
+		switch {
+		case m.Err != nil:
+			failed++
+			fmt.Printf("❌ %s: shop %d, merging %v failed: %v\n", m.Phone, m.KeptID, m.Merged, m.Err)
+		case len(m.Merged) > 0:
+			fmt.Printf("🔀 %s: kept shop %d, merged %v\n", m.Phone, m.KeptID, m.Merged)
+		default:
+			fmt.Printf("📞 %s: normalised shop %d\n", m.Phone, m.KeptID)
+		}
+	}
+	if dryRun {
+		fmt.Printf("%d numbers to normalise (dry run, nothing changed)\n", len(merges))
+		return nil
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d numbers could not be merged", failed, len(merges))
+	}
+	fmt.Printf("✅ %d numbers normalised\n", len(merges))
+	return nil
+}
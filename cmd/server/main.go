@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/C9b3rD3vi1/DukaPOS/internal/config"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/database"
@@ -15,7 +18,6 @@ import (
 	auditloghandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/auditlog"
 	billinghandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/billing"
 	currencyhandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/currency"
-	docshandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/docs"
 	emailhandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/email"
 	exporthandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/export"
 	jobscheduler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/jobscheduler"
@@ -26,28 +28,44 @@ import (
 	qrhandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/qr"
 	smshandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/sms"
 	staffhandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/staff"
+	stripehandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/stripe"
 	supplierhandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/supplier"
 	twofactorhandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/twofactor"
 	ussdhandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/ussd"
 	webhookhandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/webhook"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/metrics"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/middleware"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/routes"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
 	ai "github.com/C9b3rD3vi1/DukaPOS/internal/services/ai"
 	apiservice "github.com/C9b3rD3vi1/DukaPOS/internal/services/api"
+	billingservice "github.com/C9b3rD3vi1/DukaPOS/internal/services/billing"
 	cacheservice "github.com/C9b3rD3vi1/DukaPOS/internal/services/cache"
 	currencyservice "github.com/C9b3rD3vi1/DukaPOS/internal/services/currency"
 	email "github.com/C9b3rD3vi1/DukaPOS/internal/services/email"
 	encryption "github.com/C9b3rD3vi1/DukaPOS/internal/services/encryption"
+	exportservice "github.com/C9b3rD3vi1/DukaPOS/internal/services/export"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/features"
+	jobsservice "github.com/C9b3rD3vi1/DukaPOS/internal/services/jobs"
+	loyaltyservice "github.com/C9b3rD3vi1/DukaPOS/internal/services/loyalty"
+	mediaservice "github.com/C9b3rD3vi1/DukaPOS/internal/services/media"
 	mpesaservice "github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
+	otpservice "github.com/C9b3rD3vi1/DukaPOS/internal/services/otp"
+	outboxservice "github.com/C9b3rD3vi1/DukaPOS/internal/services/outbox"
 	printerservice "github.com/C9b3rD3vi1/DukaPOS/internal/services/printer"
 	qrservice "github.com/C9b3rD3vi1/DukaPOS/internal/services/qr"
 	smsservice "github.com/C9b3rD3vi1/DukaPOS/internal/services/sms"
+	storageservice "github.com/C9b3rD3vi1/DukaPOS/internal/services/storage"
+	stripeservice "github.com/C9b3rD3vi1/DukaPOS/internal/services/stripe"
+	supplierservice "github.com/C9b3rD3vi1/DukaPOS/internal/services/supplier"
 	twofactorservice "github.com/C9b3rD3vi1/DukaPOS/internal/services/twofactor"
 	ussdservice "github.com/C9b3rD3vi1/DukaPOS/internal/services/ussd"
 	webhookservice "github.com/C9b3rD3vi1/DukaPOS/internal/services/webhook"
 	websocket "github.com/C9b3rD3vi1/DukaPOS/internal/services/websocket"
+	zreportservice "github.com/C9b3rD3vi1/DukaPOS/internal/services/zreport"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/compress"
 	"github.com/gofiber/fiber/v2/middleware/logger"
@@ -95,6 +113,15 @@ func main() {
 	// Get database instance
 	db := database.GetDB()
 
+	// Prometheus metrics (query timings, sales and shop gauges)
+	if cfg.MetricsEnabled {
+		if err := metrics.Init(db); err != nil {
+			log.Printf("⚠️ Failed to initialize metrics: %v", err)
+		} else {
+			log.Println("✅ Prometheus metrics enabled at /metrics")
+		}
+	}
+
 	// Initialize webhook service
 	webhookservice.Init(db, 3, 5)
 	log.Println("✅ Webhook service initialized")
@@ -104,6 +131,10 @@ func main() {
 	productRepo := repository.NewProductRepository(db)
 	saleRepo := repository.NewSaleRepository(db)
 	summaryRepo := repository.NewDailySummaryRepository(db)
+	snapshotRepo := repository.NewInventorySnapshotRepository(db)
+	closingStockRepo := repository.NewClosingStockRepository(db)
+	zreports := zreportservice.New(repository.NewDayCloseRepository(db))
+	expenseRepo := repository.NewExpenseRepository(db)
 	auditRepo := repository.NewAuditLogRepository(db)
 	staffRepo := repository.NewStaffRepository(db)
 	apiKeyRepo := repository.NewAPIKeyRepository(db)
@@ -112,52 +143,105 @@ func main() {
 	accountRepo := repository.NewAccountRepository(db)
 	supplierRepo := repository.NewSupplierRepository(db)
 	orderRepo := repository.NewOrderRepository(db)
+	categoryRepo := repository.NewCategoryRepository(db)
+	bundleRepo := repository.NewBundleRepository(db)
+	priceHistoryRepo := repository.NewPriceHistoryRepository(db)
+	stockMovementRepo := repository.NewStockMovementRepository(db)
+	supplierProductRepo := repository.NewSupplierProductRepository(db)
 
 	// ========== Initialize Services ==========
 	authService := services.NewAuthService(shopRepo, cfg)
 	authService.SetAccountRepo(accountRepo)
 	cmdHandler := services.NewCommandHandler(db, shopRepo, productRepo, saleRepo, summaryRepo, auditRepo)
+	cmdHandler.SetCategoryRepo(categoryRepo)
+	cmdHandler.SetPriceHistoryRepo(priceHistoryRepo)
+	cmdHandler.SetBundleRepo(bundleRepo)
+	menuSessions := services.NewMenuSessionService()
+	cmdHandler.SetMenuSessions(menuSessions)
+	confirmations := services.NewConfirmationService()
+	cmdHandler.SetConfirmations(confirmations)
+	crossSells := services.NewCrossSellService(saleRepo, productRepo)
+	cmdHandler.SetCrossSells(crossSells)
+
+	// Runtime feature flags, defaulting to FEATURE_*_ENABLED. Every feature
+	// is set up so an admin can switch it on without a restart; requests
+	// and commands for a feature that is off are turned away.
+	featureFlags := features.New(repository.NewFeatureFlagRepository(db), map[string]bool{
+		models.FeatureFlagMpesa:         cfg.FeatureMpesaEnabled,
+		models.FeatureFlagAnalytics:     cfg.FeatureAnalyticsEnabled,
+		models.FeatureFlagWebDashboard:  cfg.FeatureWebDashboardEnabled,
+		models.FeatureFlagMultipleShops: cfg.FeatureMultipleShopsEnabled,
+		models.FeatureFlagStaffAccounts: cfg.FeatureStaffAccountsEnabled,
+	})
+	middleware.SetFeatureFlags(featureFlags)
+	cmdHandler.SetFeatureFlags(featureFlags)
 
-	// Set account repo for multi-shop support
-	if cfg.FeatureMultipleShopsEnabled {
-		cmdHandler.SetAccountRepo(accountRepo)
-	}
-
-	// Set staff repo for staff commands
-	if cfg.FeatureStaffAccountsEnabled {
-		cmdHandler.SetStaffRepo(staffRepo)
-	}
-
-	// Set supplier repo for supplier commands (Pro feature)
-	if cfg.FeatureStaffAccountsEnabled {
-		cmdHandler.SetSupplierRepo(supplierRepo, orderRepo)
-	}
-
-	// Set customer repo for loyalty commands (Business feature)
-	if cfg.FeatureAnalyticsEnabled {
-		cmdHandler.SetCustomerRepo(customerRepo)
-	}
+	// Multi-shop, staff, supplier (Pro) and loyalty (Business) commands
+	cmdHandler.SetAccountRepo(accountRepo)
+	cmdHandler.SetStaffRepo(staffRepo)
+	cmdHandler.SetSupplierRepo(supplierRepo, orderRepo)
+	cmdHandler.SetCustomerRepo(customerRepo)
+	cmdHandler.SetZReportService(zreports)
+	cmdHandler.SetExpenseRepo(expenseRepo)
 
 	// Initialize M-Pesa repositories
 	mpesaPaymentRepo := repository.NewMpesaPaymentRepository(db)
 	mpesaTransactionRepo := repository.NewMpesaTransactionRepository(db)
 
-	// M-Pesa Service (if enabled)
+	// M-Pesa Service
+	// Plan payments issue invoices, with PDFs kept on local disk
+	invoiceRepo := repository.NewInvoiceRepository(db)
+	billingSvc := billingservice.NewService(db, invoiceRepo, storageservice.NewLocalStore(cfg.InvoiceStorageDir))
+
 	var mpesaSvc *mpesaservice.Service
-	if cfg.FeatureMpesaEnabled {
-		if cfg.MPesaConsumerKey != "" && cfg.MPesaShortcode != "" {
-			mpesaSvc = mpesaservice.New(&mpesaservice.Config{
-				ConsumerKey:    cfg.MPesaConsumerKey,
-				ConsumerSecret: cfg.MPesaConsumerSecret,
-				Shortcode:      cfg.MPesaShortcode,
-				Passkey:        cfg.MPesaPasskey,
-				CallbackURL:    cfg.MPesaCallbackURL,
-				Environment:    cfg.MPesaEnvironment,
-			}, mpesaPaymentRepo, mpesaTransactionRepo)
-			log.Println("✅ M-Pesa service initialized")
-		} else {
-			log.Println("⚠️ M-Pesa enabled but not configured (missing credentials)")
+	mpesaSvc = mpesaservice.New(&mpesaservice.Config{
+		ConsumerKey:        cfg.MPesaConsumerKey,
+		ConsumerSecret:     cfg.MPesaConsumerSecret,
+		Shortcode:          cfg.MPesaShortcode,
+		Passkey:            cfg.MPesaPasskey,
+		CallbackURL:        cfg.MPesaCallbackURL,
+		CallbackToken:      cfg.MPesaCallbackToken,
+		Environment:        cfg.MPesaEnvironment,
+		MockCallbackDelay:  time.Duration(cfg.MPesaMockDelaySecs) * time.Second,
+		B2CResultURL:       cfg.MPesaB2CResultURL,
+		B2CTimeoutURL:      cfg.MPesaB2CTimeoutURL,
+		B2CDailyLimit:      float64(cfg.MPesaB2CDailyLimit),
+		StatusResultURL:    cfg.MPesaStatusResultURL,
+		ReversalResultURL:  cfg.MPesaReversalResultURL,
+		C2BValidationURL:   cfg.MPesaC2BValidationURL,
+		C2BConfirmationURL: cfg.MPesaC2BConfirmationURL,
+		C2BResponseType:    cfg.MPesaC2BResponseType,
+	}, mpesaPaymentRepo, mpesaTransactionRepo)
+	mpesaSvc.SetB2CRepos(repository.NewB2CPayoutRepository(db), auditRepo)
+	mpesaSvc.SetOrderRepo(orderRepo)
+	mpesaSvc.SetCurrencyConverter(currencyservice.NewService(db, cfg))
+	mpesaSvc.SetBusinessRepos(saleRepo, productRepo, shopRepo)
+	mpesaSvc.SetPlanPaymentHandler(billingSvc)
+	mpesaSvc.SetPendingSaleRepo(repository.NewPendingSaleRepository(db))
+	mpesaSvc.SetPaymentLinkRepo(repository.NewPaymentLinkRepository(db), cfg.PaymentLinkBaseURL)
+
+	// Shops may bring their own paybill/till; credentials are stored encrypted
+	if encryptSvc != nil {
+		mpesaSvc.SetCredentialStore(repository.NewIntegrationCredentialRepository(db), encryptSvc)
+	}
+
+	if mpesaSvc.IsMock() {
+		log.Println("🧪 M-Pesa running in mock mode: STK pushes are paid automatically, no money moves")
+	}
+	if mpesaSvc.IsConfigured() {
+		log.Println("✅ M-Pesa service initialized")
+
+		if cfg.MPesaC2BRegisterOnStartup {
+			go func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				defer cancel()
+				if err := mpesaSvc.RegisterC2BURLs(ctx, 0); err != nil {
+					log.Printf("⚠️ Failed to register M-Pesa C2B URLs: %v", err)
+				}
+			}()
 		}
+	} else {
+		log.Println("⚠️ M-Pesa platform shortcode not configured (only shops with their own credentials can take payments)")
 	}
 
 	// Set M-Pesa service for WhatsApp payments
@@ -166,13 +250,23 @@ func main() {
 	}
 
 	// SMS Service (Africa Talking)
+	receiptLinker := qrservice.NewReceiptLinker(cfg.ReceiptBaseURL, cfg.JWTSecret)
+	smsMessageRepo := repository.NewSmsMessageRepository(db)
+	smsCampaignRepo := repository.NewSmsCampaignRepository(db)
 	var smsSvc *smsservice.Service
 	if cfg.AfricaTalkingAPIKey != "" && cfg.AfricaTalkingUsername != "" {
 		smsSvc = smsservice.New(&smsservice.Config{
 			APIKey:   cfg.AfricaTalkingAPIKey,
 			Username: cfg.AfricaTalkingUsername,
 			BaseURL:  "https://api.africastalking.com",
+			From:     cfg.AfricaTalkingShortCode,
 		})
+		smsSvc.SetMessageRepo(smsMessageRepo)
+		smsSvc.SetReceiptLinker(receiptLinker)
+		smsSvc.SetCampaignRepo(smsCampaignRepo)
+		if mpesaSvc != nil {
+			mpesaSvc.SetReceiptSender(smsSvc)
+		}
 		log.Println("✅ SMS service (Africa Talking) initialized")
 	} else {
 		log.Println("⚠️ Africa Talking SMS not configured")
@@ -186,31 +280,63 @@ func main() {
 			FromEmail: cfg.SendGridFromEmail,
 			FromName:  cfg.SendGridFromName,
 		})
+		emailTemplates := email.NewTemplates(repository.NewEmailTemplateRepository(db))
+		if err := emailTemplates.SeedDefaults(); err != nil {
+			log.Printf("⚠️ Failed to seed email templates: %v", err)
+		}
+		emailSvc.SetTemplates(emailTemplates)
 		log.Println("✅ Email service (SendGrid) initialized")
 	} else {
 		log.Println("⚠️ SendGrid email not configured")
 	}
 
-	// API Service (if enabled)
-	var apiSvc *apiservice.Service
-	if cfg.FeatureAnalyticsEnabled {
-		apiSvc = apiservice.New(apiKeyRepo)
-		log.Println("✅ API service initialized")
+	// One-time codes for login and password reset: SMS to phones, email otherwise
+	otpSvc := otpservice.NewOTPService(db, cfg)
+	if smsSvc != nil {
+		otpSvc.SetSMSSender(func(phone, message string) error {
+			_, err := smsSvc.Send(0, models.SmsPurposeOTP, phone, message)
+			return err
+		})
 	}
-
-	// USSD Service (if multiple shops enabled)
-	var ussdSvc *ussdservice.Service
-	var ussdHandler *ussdhandler.Handler
-	if cfg.FeatureMultipleShopsEnabled {
-		ussdSvc = ussdservice.New()
-		ussdSvc.SetRepositories(shopRepo, productRepo, saleRepo, summaryRepo)
-		ussdHandler = ussdhandler.New(ussdSvc)
-		log.Println("✅ USSD service initialized")
+	if emailSvc != nil {
+		otpSvc.SetEmailSender(func(to, subject, body string) error {
+			return emailSvc.SendEmail(&email.Email{To: to, Subject: subject, Body: body})
+		})
+		otpSvc.SetPasswordResetMailer(func(to, code string) error {
+			return emailSvc.SendTemplate(nil, to, models.EmailTemplatePasswordReset, map[string]string{
+				"code":            code,
+				"expires_minutes": fmt.Sprint(otpservice.OTPExpiryMinutes),
+			})
+		})
 	}
+	authService.SetOTPService(otpSvc)
+
+	// API Service
+	apiSvc := apiservice.New(apiKeyRepo)
+	log.Println("✅ API service initialized")
+
+	// USSD Service (served while multiple shops are enabled)
+	ussdSvc := ussdservice.New()
+	ussdSvc.SetRepositories(shopRepo, productRepo, saleRepo, summaryRepo)
+	ussdHandler := ussdhandler.New(ussdSvc)
+	log.Println("✅ USSD service initialized")
 
 	// Printer Service
-	printerSvc := printerservice.New(&printerservice.PrinterConfig{})
-	log.Println("✅ Printer service initialized")
+	printerSvc := printerservice.New(&printerservice.PrinterConfig{
+		Type:       cfg.PrinterType,
+		Connection: cfg.PrinterConnection,
+		Host:       cfg.PrinterHost,
+		Port:       cfg.PrinterPort,
+		Device:     cfg.PrinterDevice,
+		Width:      cfg.PrinterWidth,
+		OpenDrawer: cfg.PrinterOpenDrawer,
+		Timeout:    time.Duration(cfg.PrinterTimeoutSeconds) * time.Second,
+	})
+	if printerSvc.Configured() {
+		log.Printf("✅ Printer service initialized (%s printer at %s)", cfg.PrinterType, printerSvc.Address())
+	} else {
+		log.Println("✅ Printer service initialized")
+	}
 
 	// Cache Service (Redis)
 	var cacheSvc *cacheservice.CacheService
@@ -226,23 +352,109 @@ func main() {
 			log.Printf("⚠️ Redis connection failed: %v (using in-memory fallback)", err)
 		} else {
 			log.Println("✅ Cache service (Redis) initialized")
+			if mpesaSvc != nil {
+				mpesaSvc.SetCache(cacheSvc)
+			}
+			if ussdSvc != nil {
+				ussdSvc.SetSessionStore(cacheSvc)
+			}
 		}
 	}
 
 	// ========== Initialize Handlers ==========
 	whatsappHandler := handlers.NewWhatsAppHandler(cmdHandler, cfg)
+	if cacheSvc != nil {
+		whatsappHandler.SetMessageDeduper(cacheSvc)
+		menuSessions.SetStore(cacheSvc)
+		confirmations.SetStore(cacheSvc)
+		crossSells.SetStore(cacheSvc)
+	}
+	if smsSvc == nil {
+		otpSvc.SetWhatsAppSender(whatsappHandler.SendWhatsAppMessage)
+	}
+	// QR codes and receipts go out as WhatsApp media, which Twilio fetches
+	// from a public URL
+	var mediaHost *mediaservice.Host
+	if cfg.WebhookBaseURL != "" {
+		mediaHost = mediaservice.NewHost(cfg.MediaDir, strings.TrimRight(cfg.WebhookBaseURL, "/")+"/media", mediaservice.DefaultTTL)
+		cmdHandler.SetMediaSender(mediaHost, whatsappHandler.SendWhatsAppMedia)
+		cmdHandler.SetReceiptLinker(receiptLinker)
+		log.Println("✅ WhatsApp media sending initialized")
+	}
 	authHandler := handlers.NewAuthHandler(authService)
+	authHandler.SetLoginEvents(repository.NewLoginEventRepository(db), cfg.LoginCountryHeader, emailSvc)
 	shopHandler := handlers.NewShopHandlerWithAccount(shopRepo, productRepo, saleRepo, accountRepo)
+	productImages := storageservice.NewLocalStore(cfg.StaticDir)
 	productHandler := handlers.NewProductHandler(productRepo)
+	productHandler.SetCategoryRepo(categoryRepo)
+	productHandler.SetBundleRepo(bundleRepo)
+	productHandler.SetPriceHistoryRepo(priceHistoryRepo)
+	productHandler.SetImageStore(productImages, "/static/")
+	productHandler.SetStockMovementRepo(stockMovementRepo)
+	productHandler.SetCrossSells(crossSells)
 	saleHandler := handlers.NewSaleHandler(saleRepo, productRepo)
+	saleHandler.SetBundleRepo(bundleRepo)
+	saleHandler.SetShopRepo(shopRepo)
 	reportHandler := handlers.NewReportHandlerWithCache(saleRepo, productRepo, summaryRepo, cacheSvc)
+	reportHandler.SetSnapshotRepo(snapshotRepo)
+	reportHandler.SetClosingStockRepo(closingStockRepo)
+	reportHandler.SetZReportService(zreports)
+	reportHandler.SetExpenseRepo(expenseRepo)
 	staffHandler := staffhandler.New(staffRepo, shopRepo)
 	webhookHandler := webhookhandler.New(webhookRepo)
+	webhookHandler.SetDeliveries(repository.NewWebhookDeliveryRepository(db), webhookservice.GetManager())
 
 	// Export Handler
 	exportHandler := exporthandler.NewExportHandler(productRepo, saleRepo, summaryRepo)
+	exportHandler.SetReconciliationService(mpesaservice.NewReconciliationService(mpesaPaymentRepo, mpesaTransactionRepo, saleRepo))
+	exportHandler.SetShopRepo(shopRepo)
+	exportHandler.SetClosingStockRepo(closingStockRepo)
+	exportHandler.SetImageStore(productImages, "/static/")
+	exportHandler.SetMaxRange(cfg.ExportMaxRangeDays)
+	exportHandler.SetCustomerRepos(customerRepo, repository.NewLoyaltyTransactionRepository(db))
+	exportHandler.SetMpesaRepos(mpesaPaymentRepo, mpesaTransactionRepo)
+	exportHandler.SetSupplierRepos(supplierRepo, orderRepo)
 	log.Println("✅ Export handler initialized")
 
+	// Product and report exports run on background workers when Redis is
+	// available; otherwise they stay synchronous
+	var jobQueue *jobsservice.JobQueue
+	var jobHandler *handlers.JobHandler
+	if cacheSvc != nil {
+		jobQueue = jobsservice.NewJobQueue(cacheSvc, cfg.JobWorkers)
+		jobQueue.SetNotifier(func(job *jobsservice.Job) {
+			// Export files report through their own status and messages
+			if job.Type != exportservice.JobType {
+				websocket.NotifyJobStatus(job.ShopID, job.ID, job.Type, job.Status)
+			}
+		})
+		exportHandler.SetJobQueue(jobQueue)
+		jobHandler = handlers.NewJobHandler(jobQueue)
+	}
+
+	// Exports of large datasets are streamed to files by the queue's
+	// workers and downloaded through signed links. Without Redis the queue
+	// is in-process, which only suits a single server.
+	var exportJobs *exportservice.JobService
+	exportQueue := jobQueue
+	if cfg.ExportSigningSecret == "" {
+		log.Println("⚠️ EXPORT_SIGNING_SECRET not set - background exports disabled")
+	} else {
+		if exportQueue == nil {
+			exportQueue = jobsservice.NewJobQueue(jobsservice.NewMemoryStore(), cfg.JobWorkers)
+		}
+		exportJobs = exportservice.NewJobService(exportservice.JobConfig{
+			Store:   storageservice.NewLocalStore(cfg.ExportJobDir),
+			BaseURL: cfg.WebhookBaseURL,
+			Secret:  cfg.ExportSigningSecret,
+			TTL:     time.Duration(cfg.ExportJobTTLHours) * time.Hour,
+		}, exportQueue, repository.NewExportJobRepository(db), shopRepo, saleRepo, productRepo)
+		exportHandler.SetExportJobs(exportJobs)
+	}
+	if exportQueue != nil {
+		exportQueue.Start()
+	}
+
 	// QR Handler
 	var qrHandler *qrhandler.QRHandler
 	if mpesaSvc != nil {
@@ -256,6 +468,9 @@ func main() {
 	var smsHandler *smshandler.Handler
 	if smsSvc != nil {
 		smsHandler = smshandler.New(smsSvc)
+		smsHandler.SetMessageRepo(smsMessageRepo)
+		smsHandler.SetDeliveryReportToken(cfg.AfricaTalkingDLRToken)
+		smsHandler.SetCampaignRepos(smsCampaignRepo, customerRepo)
 		log.Println("✅ SMS handler initialized")
 	}
 
@@ -266,18 +481,161 @@ func main() {
 		log.Println("✅ M-Pesa handler initialized")
 	}
 
+	// Stripe card payments, an alternative to M-Pesa for online orders
+	var stripeHandler *stripehandler.Handler
+	if cfg.StripeSecretKey != "" {
+		stripeSvc := stripeservice.New(&stripeservice.Config{
+			SecretKey:     cfg.StripeSecretKey,
+			WebhookSecret: cfg.StripeWebhookSecret,
+		}, repository.NewStripePaymentRepository(db), productRepo)
+		stripeHandler = stripehandler.New(stripeSvc)
+		log.Println("✅ Stripe handler initialized")
+	}
+
+	// ========== Initialize Outbox ==========
+	// Notifications are queued and sent by workers with retries, so a slow
+	// or failing provider doesn't hold up or lose them
+	outbox := outboxservice.New(repository.NewOutboxRepository(db), outboxservice.DefaultWorkers)
+	outbox.SetSender(models.OutboxChannelWhatsApp, func(m *models.OutboxMessage) error {
+		msgType := m.Subject
+		if msgType == "" {
+			msgType = models.MessageTypeNotification
+		}
+		return whatsappHandler.SendWhatsAppMessageAs(m.Recipient, msgType, m.Body)
+	})
+	if smsSvc != nil {
+		outbox.SetSender(models.OutboxChannelSMS, func(m *models.OutboxMessage) error {
+			purpose := models.SmsPurpose(m.Subject)
+			if purpose == "" {
+				purpose = models.SmsPurposeManual
+			}
+			_, err := smsSvc.Send(m.ShopID, purpose, m.Recipient, m.Body)
+			return err
+		})
+		// Receipts, low stock alerts and USSD reports go through the outbox
+		// too; one-time codes are sent straight away and never stored
+		smsSvc.SetOutbox(outbox.SendShopSMS)
+		ussdSvc.SetSMSSender(func(shopID uint, phone, message string) error {
+			return outbox.SendShopSMS(shopID, models.SmsPurposeReport, phone, message)
+		})
+	}
+	if emailSvc != nil {
+		outbox.SetSender(models.OutboxChannelEmail, func(m *models.OutboxMessage) error {
+			return emailSvc.SendEmail(&email.Email{To: m.Recipient, Subject: m.Subject, Body: m.Body})
+		})
+	}
+	if mpesaSvc != nil {
+		// STK pushes WhatsApp commands made while Daraja was rate limiting
+		// them are sent again by the workers, not waited out in the webhook
+		outbox.SetSender(models.OutboxChannelMpesa, cmdHandler.SendQueuedSTKPush)
+		cmdHandler.SetSTKPushQueue(outbox.QueueSTKPush, outbox.SendWhatsApp)
+	}
+	outbox.Start()
+	var sendExportEmail func(to, subject, body string) error
+	if emailSvc != nil {
+		sendExportEmail = outbox.SendEmail
+	}
+	if exportJobs != nil {
+		exportJobs.SetMessageSenders(outbox.SendWhatsApp, sendExportEmail)
+	}
+
+	// Twilio status callbacks update each sent message; admins hear about
+	// shops whose messages keep failing
+	outboundMessageRepo := repository.NewOutboundMessageRepository(db)
+	whatsappHandler.SetDeliveryTracking(outboundMessageRepo, shopRepo, func(shop *models.Shop, failures int) {
+		log.Printf("🚨 Last %d WhatsApp messages to shop %s (%s) failed", failures, shop.Name, shop.Phone)
+		if emailSvc == nil {
+			return
+		}
+		admins, err := accountRepo.GetAdmins()
+		if err != nil {
+			log.Printf("❌ Failed to load admins for delivery alert: %v", err)
+			return
+		}
+		subject := fmt.Sprintf("WhatsApp messages to %s are failing", shop.Name)
+		body := fmt.Sprintf("The last %d WhatsApp messages to shop %s (%s, ID %d) were not delivered.\n\n"+
+			"The number may be wrong or the owner may have opted out. See /api/v1/admin/messages?status=failed.",
+			failures, shop.Name, shop.Phone, shop.ID)
+		for _, admin := range admins {
+			if admin.Email != "" {
+				_ = outbox.SendEmail(admin.Email, subject, body)
+			}
+		}
+	})
+
 	// ========== Initialize Scheduler ==========
-	routes.RegisterScheduledTasks(routes.SchedulerConfig{
+	schedulerConfig := routes.SchedulerConfig{
 		ShopRepo:     shopRepo,
 		SaleRepo:     saleRepo,
 		ProductRepo:  productRepo,
-		SendWhatsApp: whatsappHandler.SendWhatsAppMessage,
-	})
+		SendWhatsApp: outbox.SendWhatsApp,
+		SnapshotRepo: snapshotRepo,
+
+		ClosingStockRepo: closingStockRepo,
+		SendWhatsAppAs:   outbox.SendWhatsAppAs,
+		SummaryRepo:      summaryRepo,
+		ExpenseRepo:      expenseRepo,
+	}
+	if smsSvc != nil {
+		schedulerConfig.SendLowStockSMS = smsSvc.SendLowStockAlert
+	}
+	if mpesaSvc != nil {
+		schedulerConfig.ExpirePayments = mpesaSvc.ProcessExpiredPayments
+		schedulerConfig.PollPayments = mpesaSvc.PollPendingPayments
+	}
+	// Low stock drafts supplier orders for Pro shops (supplier feature)
+	autoOrders := supplierservice.NewAutoOrderService(supplierProductRepo, orderRepo,
+		ai.NewPredictionService(productRepo, saleRepo, summaryRepo))
+	schedulerConfig.CreateAutoOrders = func(shop *models.Shop, products []models.Product) ([]string, error) {
+		if !featureFlags.Enabled(models.FeatureFlagStaffAccounts) {
+			return nil, nil
+		}
+		return autoOrders.CreateDraftOrders(shop, products)
+	}
+	cmdHandler.SetSupplierSender(outbox.SendWhatsApp)
+	// Sales report PDFs emailed on each shop's schedule
+	var reportMailer *email.ReportMailer
+	if emailSvc != nil {
+		reportMailer = email.NewReportMailer(emailSvc, repository.NewReportEmailPreferenceRepository(db), shopRepo, saleRepo)
+		reportMailer.SetWhatsAppSender(outbox.SendWhatsApp)
+		schedulerConfig.EmailReports = reportMailer.SendScheduled
+	}
+	if exportJobs != nil {
+		schedulerConfig.CleanupExports = func() error {
+			n, err := exportJobs.Cleanup()
+			if n > 0 {
+				log.Printf("🧹 Removed %d expired export files", n)
+			}
+			return err
+		}
+	}
+	if mediaHost != nil {
+		schedulerConfig.CleanupMedia = func() error {
+			n, err := mediaHost.Cleanup()
+			if n > 0 {
+				log.Printf("🧹 Removed %d expired WhatsApp media files", n)
+			}
+			return err
+		}
+	}
+	// Birthday loyalty points for every shop that sets them, congratulated
+	// by SMS when it is configured
+	birthdayLoyalty := loyaltyservice.NewService(customerRepo, saleRepo, db)
+	if smsSvc != nil {
+		birthdayLoyalty.SetMessageSender(outbox.SendSMS)
+	} else {
+		birthdayLoyalty.SetMessageSender(outbox.SendWhatsApp)
+	}
+	schedulerConfig.BirthdayRewards = birthdayLoyalty.AwardBirthdayBonuses
+	routes.RegisterScheduledTasks(schedulerConfig)
 
 	// ========== Create Fiber App ==========
 	var emailHandler *emailhandler.Handler
 	if emailSvc != nil {
 		emailHandler = emailhandler.New(emailSvc)
+		emailHandler.SetReportMailer(reportMailer)
+		emailHandler.SetShopRepo(shopRepo)
+		emailHandler.SetEventWebhookToken(cfg.SendGridWebhookToken)
 		log.Println("✅ Email handler initialized")
 	}
 
@@ -288,27 +646,22 @@ func main() {
 	}
 
 	// AI Handler
-	var aiHandler *aihandler.Handler
-	if cfg.FeatureAnalyticsEnabled {
-		aiPredService := ai.NewPredictionService(productRepo, saleRepo, summaryRepo)
-		aiHandler = aihandler.New(aiPredService)
-		cmdHandler.SetPredictionService(aiPredService)
-		log.Println("✅ AI Predictions service initialized")
-	}
+	aiPredService := ai.NewPredictionService(productRepo, saleRepo, summaryRepo)
+	aiPredService.SetPriceHistoryRepo(priceHistoryRepo)
+	aiHandler := aihandler.New(aiPredService)
+	cmdHandler.SetPredictionService(aiPredService)
+	log.Println("✅ AI Predictions service initialized")
 
 	// ========== Create Fiber App ==========
+	// c.IP() is the connecting address unless it is a trusted proxy, so
+	// source checks on webhooks see the real client behind one
 	app := fiber.New(fiber.Config{
-		AppName:      "DukaPOS",
-		ServerHeader: "DukaPOS/1.0.0",
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			code := fiber.StatusInternalServerError
-			if e, ok := err.(*fiber.Error); ok {
-				code = e.Code
-			}
-			return c.Status(code).JSON(fiber.Map{
-				"error": err.Error(),
-			})
-		},
+		AppName:                 "DukaPOS",
+		ServerHeader:            "DukaPOS/1.0.0",
+		ErrorHandler:            utils.ErrorHandler,
+		ProxyHeader:             cfg.ProxyHeader,
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          cfg.GetTrustedProxies(),
 	})
 
 	// Middleware
@@ -318,6 +671,9 @@ func main() {
 		TimeFormat: "2006-01-02 15:04:05",
 	}))
 	app.Use(compress.New())
+	if cfg.MetricsEnabled {
+		app.Use(metrics.Middleware())
+	}
 
 	// CORS
 	if cfg.CORSEnabled {
@@ -327,136 +683,49 @@ func main() {
 	// Rate Limiter
 	app.Use(middleware.RateLimiter(60, 60))
 
+	// Prometheus scrape endpoint, restricted to METRICS_ALLOWED_CIDR
+	if cfg.MetricsEnabled {
+		app.Get("/metrics", middleware.IPAllowlist(cfg.GetMetricsAllowedCIDRs()), metrics.Handler())
+	}
+
 	// Serve static files
-	app.Static("/static", "./static")
+	app.Static("/static", cfg.StaticDir)
 
-	// Serve React frontend (PWA)
+	// Web dashboard API and payment link pages; the React frontend (PWA)
+	// is served by routes.RegisterServerRoutes
 	webHandler := handlers.NewWebHandler(shopRepo, productRepo, saleRepo)
-
-	if cfg.FeatureWebDashboardEnabled {
-		// Serve the React frontend built with Vite
-		app.Static("/", "./dukapos-frontend/dist")
-
-		// Initialize web handler for API fallback
-		webHandler := handlers.NewWebHandler(shopRepo, productRepo, saleRepo)
-		webHandler.SetAdditionalRepos(summaryRepo, customerRepo, staffRepo)
-
-		// Legacy template routes (for backward compatibility)
-		web := app.Group("")
-
-		// Landing page
-		web.Get("/", func(c *fiber.Ctx) error {
-			return c.SendFile("./dukapos-frontend/dist/index.html")
-		})
-
-		// Login page
-		web.Get("/login", func(c *fiber.Ctx) error {
-			return c.SendFile("./dukapos-frontend/dist/index.html")
-		})
-
-		// Register page
-		web.Get("/register", func(c *fiber.Ctx) error {
-			return c.SendFile("./dukapos-frontend/dist/index.html")
-		})
-
-		// Dashboard (React app handles routing)
-		web.Get("/dashboard/*", func(c *fiber.Ctx) error {
-			return c.SendFile("./dukapos-frontend/dist/index.html")
-		})
-
-		// Admin routes
-		web.Get("/admin/*", func(c *fiber.Ctx) error {
-			return c.SendFile("./dukapos-frontend/dist/index.html")
-		})
-
-		log.Println("✅ React frontend enabled at /")
-	}
-
-	// Dashboard API routes - use JWT auth like protected routes
-	webAPI := app.Group("/api/v1")
-	webAPI.Use(middleware.JWT(authService))
-	webAPI.Get("/shop/dashboard-json/:shop_id", webHandler.DashboardJSON)
-	webAPI.Get("/shop/dashboard/:shop_id", webHandler.Dashboard)
-	webAPI.Get("/products/categories", productHandler.ListCategories)
-	webAPI.Post("/products/bulk", productHandler.BulkCreateProducts)
-	webAPI.Post("/products", webHandler.APIProductCreate)
-	webAPI.Get("/products", productHandler.ListProducts)
-	webAPI.Get("/products/:id", productHandler.GetProduct)
-	webAPI.Put("/products/:id", webHandler.APIProductUpdate)
-	webAPI.Delete("/products/:id", webHandler.APIProductDelete)
-	webAPI.Get("/sales/:shop_id", webHandler.APISales)
-	webAPI.Post("/sales", webHandler.APISaleCreate)
-	webAPI.Get("/reports/:shop_id", webHandler.APIReports)
-
-	// ========== API Routes ==========
-	api := app.Group("/api")
-
-	// Health check
-	api.Get("/health", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{
-			"status":  "healthy",
-			"service": "DukaPOS",
-			"version": "1.0.0",
-		})
-	})
-
-	// API Documentation
-	docsHandler := docshandler.New()
-	docsHandler.RegisterRoutes(app)
-
-	// API Info
-	api.Get("/", func(c *fiber.Ctx) error {
-		features := []string{"inventory", "sales"}
-		if cfg.FeatureMpesaEnabled && mpesaSvc != nil {
-			features = append(features, "mpesa")
-		}
-		if cfg.FeatureStaffAccountsEnabled {
-			features = append(features, "staff")
-		}
-		if cfg.FeatureAnalyticsEnabled {
-			features = append(features, "api", "webhooks")
-		}
-		if cfg.FeatureMultipleShopsEnabled {
-			features = append(features, "multi-shop")
-		}
-
-		return c.JSON(fiber.Map{
-			"name":        "DukaPOS API",
-			"version":     "1.0.0",
-			"description": "REST API for Kenyan Duka POS",
-			"base_url":    "/api/v1",
-			"channels":    []string{"WhatsApp", "USSD", "REST"},
-			"features":    features,
-		})
-	})
-
-	// Auth routes (public)
-	auth := api.Group("/auth")
-	auth.Post("/register", authHandler.Register)
-	auth.Post("/login", authHandler.Login)
-	auth.Post("/otp/send", authHandler.SendOTP)
-	auth.Post("/otp/verify", authHandler.VerifyOTP)
+	webHandler.SetPaymentLinks(mpesaSvc)
 
 	// ========== Create additional handlers for routes ==========
 	adminHandler := handlers.NewAdminHandler()
+	adminHandler.SetFeatureFlags(featureFlags)
 	billingHandler := billinghandler.NewHandler(db, cfg)
+	billingHandler.SetBilling(billingSvc, mpesaSvc, invoiceRepo)
 	planHandler := middleware.NewPlanInfoHandler()
 	customerHandler := handlers.NewCustomerHandler(customerRepo, shopRepo)
 	var loyaltyHandler *loyaltyhandler.Handler
 	var supplierHandler *supplierhandler.Handler
 	var printerHandler *printerhandler.Handler
 
-	if cfg.FeatureMultipleShopsEnabled {
-		loyaltyHandler = loyaltyhandler.NewHandler(customerRepo, saleRepo, db)
-		supplierHandler = supplierhandler.New(supplierRepo, orderRepo, productRepo)
-	}
+	loyaltyHandler = loyaltyhandler.NewHandler(customerRepo, saleRepo, db)
+	supplierHandler = supplierhandler.New(supplierRepo, orderRepo, productRepo)
+	supplierHandler.SetProductLinkRepo(supplierProductRepo)
+	supplierHandler.SetOrderSenders(outbox.SendWhatsApp, emailSvc)
+	supplierHandler.SetAuditRepo(auditRepo)
 
 	if printerSvc != nil {
 		printerHandler = printerhandler.New(printerSvc)
+		printerHandler.SetShopRepo(shopRepo)
+		printerHandler.SetSaleRepo(saleRepo)
+		printerHandler.SetPrinterSettingRepo(repository.NewPrinterSettingRepository(db))
+		printerHandler.SetLocalPrinters(cfg.PrinterAllowLocal)
+		printerHandler.SetImageStore(productImages, "/static/")
+		printerHandler.SetReceiptLinker(receiptLinker)
+		printerHandler.SetZReportService(zreports)
 	}
 
 	// Protected routes
-	protected := api.Group("/v1")
+	protected := app.Group("/api/v1")
 	protected.Use(middleware.JWT(authService))
 
 	// ========== Initialize Additional Handlers ==========
@@ -473,6 +742,13 @@ func main() {
 	scheduledReportHandler := handlers.NewScheduledReportHandler(db)
 	log.Println("✅ Scheduled Report handler initialized")
 
+	// Receipt Handler (PDF receipts and the public digital receipt page)
+	receiptHandler := handlers.NewReceiptHandler(saleRepo, shopRepo, receiptLinker)
+	var mediaHandler *handlers.MediaHandler
+	if mediaHost != nil {
+		mediaHandler = handlers.NewMediaHandler(mediaHost)
+	}
+
 	// Staff Role Handler
 	staffRoleHandler := handlers.NewStaffRoleHandler(db)
 	log.Println("✅ Staff Role handler initialized")
@@ -490,47 +766,45 @@ func main() {
 	pushHandler := pushhandler.NewPushNotificationHandler(db)
 	log.Println("✅ Push Notification handler initialized")
 
-	// Plan routes
-	api.Get("/plans", planHandler.GetAllPlans)
-
 	// ========== Register All Routes ==========
-	routes.RegisterAllRoutes(routes.RouteConfig{
-		App:                         app,
-		AuthService:                 authService,
-		AuthHandler:                 authHandler,
-		ShopHandler:                 shopHandler,
-		ProductHandler:              productHandler,
-		SaleHandler:                 saleHandler,
-		ReportHandler:               reportHandler,
-		ExportHandler:               exportHandler,
-		StaffHandler:                staffHandler,
-		WebhookHandler:              webhookHandler,
-		CustomerHandler:             loyaltyHandler,
-		CustHandler:                 customerHandler,
-		SupplierHandler:             supplierHandler,
-		MpesaHandler:                mpesaHandler,
-		SMSHandler:                  smsHandler,
-		EmailHandler:                emailHandler,
-		AIHandler:                   aiHandler,
-		PrinterHandler:              printerHandler,
-		QRHandler:                   qrHandler,
-		BillingHandler:              billingHandler,
-		AdminHandler:                adminHandler,
-		APIKeyHandler:               apiKeyHandler,
-		WebHandler:                  webHandler,
-		PlanInfoHandler:             planHandler,
-		CurrencyHandler:             currencyHandler,
-		WhiteLabelHandler:           whitelabelHandler,
-		ScheduledReportHandler:      scheduledReportHandler,
-		StaffRoleHandler:            staffRoleHandler,
-		FeatureStaffAccountsEnabled: cfg.FeatureStaffAccountsEnabled,
-		FeatureMpesaEnabled:         cfg.FeatureMpesaEnabled,
-		FeatureAnalyticsEnabled:     cfg.FeatureAnalyticsEnabled,
-		FeatureMultipleShopsEnabled: cfg.FeatureMultipleShopsEnabled,
-		FeatureWebDashboardEnabled:  cfg.FeatureWebDashboardEnabled,
-		CustomerRepo:                customerRepo,
-		SaleRepo:                    saleRepo,
-		DB:                          db,
+	routes.RegisterServerRoutes(routes.RouteConfig{
+		App:                    app,
+		AuthService:            authService,
+		AuthHandler:            authHandler,
+		ShopHandler:            shopHandler,
+		ProductHandler:         productHandler,
+		SaleHandler:            saleHandler,
+		ReportHandler:          reportHandler,
+		ExportHandler:          exportHandler,
+		StaffHandler:           staffHandler,
+		WebhookHandler:         webhookHandler,
+		CustomerHandler:        loyaltyHandler,
+		CustHandler:            customerHandler,
+		SupplierHandler:        supplierHandler,
+		MpesaHandler:           mpesaHandler,
+		StripeHandler:          stripeHandler,
+		SMSHandler:             smsHandler,
+		EmailHandler:           emailHandler,
+		AIHandler:              aiHandler,
+		PrinterHandler:         printerHandler,
+		QRHandler:              qrHandler,
+		BillingHandler:         billingHandler,
+		AdminHandler:           adminHandler,
+		APIKeyHandler:          apiKeyHandler,
+		WebHandler:             webHandler,
+		PlanInfoHandler:        planHandler,
+		CurrencyHandler:        currencyHandler,
+		ExpenseHandler:         handlers.NewExpenseHandler(expenseRepo),
+		WhiteLabelHandler:      whitelabelHandler,
+		ScheduledReportHandler: scheduledReportHandler,
+		ReceiptHandler:         receiptHandler,
+		MediaHandler:           mediaHandler,
+		MessageHandler:         handlers.NewMessageHandler(outboundMessageRepo),
+		JobHandler:             jobHandler,
+		StaffRoleHandler:       staffRoleHandler,
+		CustomerRepo:           customerRepo,
+		SaleRepo:               saleRepo,
+		DB:                     db,
 	})
 
 	// ========== Register Additional Handlers ==========
@@ -567,28 +841,83 @@ func main() {
 	webhook := app.Group("/webhook")
 
 	// Twilio WhatsApp
-	webhook.Post("/twilio", whatsappHandler.HandleWebhook)
-	webhook.Post("/twilio/status", whatsappHandler.HandleStatusCallback)
+	twilioAuth := middleware.TwilioSignature(cfg.TwilioAuthToken, cfg.WebhookBaseURL, cfg.IsProduction())
+	webhook.Post("/twilio", twilioAuth, whatsappHandler.HandleWebhook)
+	webhook.Post("/twilio/status", twilioAuth, whatsappHandler.HandleStatusCallback)
 	webhook.Get("/twilio/verify", whatsappHandler.WebhookVerification)
 
 	// M-Pesa Callbacks
 	if mpesaHandler != nil {
-		webhook.Post("/mpesa/stk", mpesaHandler.STKCallback)
-		webhook.Post("/mpesa/b2c", mpesaHandler.B2CCallback)
-		webhook.Post("/mpesa/balance", mpesaHandler.BalanceCallback)
+		mpesaAuth := middleware.MpesaCallbackAuth(cfg.GetMpesaCallbackIPs(), cfg.MPesaCallbackToken)
+		webhook.Post("/mpesa/stk", mpesaAuth, mpesaHandler.STKCallback)
+		webhook.Post("/mpesa/b2c", mpesaAuth, mpesaHandler.B2CCallback)
+		webhook.Post("/mpesa/b2c/timeout", mpesaAuth, mpesaHandler.B2CTimeoutCallback)
+		webhook.Post("/mpesa/status", mpesaAuth, mpesaHandler.StatusResultCallback)
+		webhook.Post("/mpesa/reversal", mpesaAuth, mpesaHandler.ReversalResultCallback)
+		webhook.Post("/mpesa/balance", mpesaAuth, mpesaHandler.BalanceCallback)
+		webhook.Post("/mpesa/c2b/validation", mpesaAuth, mpesaHandler.C2BValidation)
+		webhook.Post("/mpesa/c2b/confirmation", mpesaAuth, mpesaHandler.C2BConfirmation)
+	}
+
+	// Stripe events, authenticated by the Stripe-Signature header
+	if stripeHandler != nil {
+		webhook.Post("/stripe", stripeHandler.Webhook)
+	}
+
+	// Africa's Talking SMS delivery reports
+	if smsHandler != nil && cfg.AfricaTalkingDLRToken != "" {
+		webhook.Post("/sms/delivery", smsHandler.DeliveryReport)
+	} else if smsHandler != nil {
+		log.Println("⚠️ AFRICA_TALKING_DLR_TOKEN not set - SMS delivery reports disabled")
+	}
+
+	// SendGrid delivery events (bounces turn report emails off)
+	if emailHandler != nil && cfg.SendGridWebhookToken != "" {
+		webhook.Post("/sendgrid/events", emailHandler.EventWebhook)
+	} else if emailHandler != nil {
+		log.Println("⚠️ SENDGRID_WEBHOOK_TOKEN not set - SendGrid event webhook disabled")
 	}
 
 	// ========== USSD Routes ==========
 	if ussdHandler != nil {
-		ussdRoutes := app.Group("/api/v1/ussd")
+		ussdRoutes := app.Group("/api/v1/ussd", middleware.USSDGatewayAuth(cfg.GetUSSDAllowedIPs(), cfg.USSDGatewayToken),
+			middleware.RequireFlag(models.FeatureFlagMultipleShops))
 		ussdRoutes.Post("/", ussdHandler.Handle)
 		ussdRoutes.Post("/africa", ussdHandler.HandleAfricaTalking)
+		if cfg.USSDGatewayFormat != "" {
+			fields, err := ussdhandler.ParseGatewayFields(cfg.USSDGatewayFields)
+			var gateway *ussdhandler.GenericAdapter
+			if err == nil {
+				gateway, err = ussdhandler.NewGenericAdapter(ussdhandler.GatewayConfig{
+					Format:       cfg.USSDGatewayFormat,
+					Fields:       fields,
+					Cumulative:   cfg.USSDGatewayCumulative,
+					Continue:     cfg.USSDGatewayContinue,
+					End:          cfg.USSDGatewayEnd,
+					ActionHeader: cfg.USSDGatewayActionHeader,
+				})
+			}
+			if err != nil {
+				log.Printf("⚠️ USSD gateway not enabled: %v", err)
+			} else {
+				ussdRoutes.Get("/gateway", ussdHandler.Serve(gateway))
+				ussdRoutes.Post("/gateway", ussdHandler.Serve(gateway))
+				log.Printf("✅ USSD gateway enabled (%s)", cfg.USSDGatewayFormat)
+			}
+		}
 		ussdRoutes.Post("/callback", ussdHandler.Callback)
 		log.Println("✅ USSD routes enabled")
 	}
 
 	// ========== WebSocket ==========
 	websocket.Init()
+	websocket.SetAuthenticator(func(token string) (uint, error) {
+		shop, err := authService.ValidateToken(token)
+		if err != nil {
+			return 0, err
+		}
+		return shop.ID, nil
+	})
 	app.Get("/ws", websocket.HandleWebSocket)
 	app.Get("/ws/*", websocket.HandleWebSocket)
 
@@ -600,12 +929,57 @@ func main() {
 	})
 
 	// ========== Graceful Shutdown ==========
+	shutdownDone := make(chan struct{})
 	go func() {
+		defer close(shutdownDone)
+
 		sigChan := make(chan os.Signal, 1)
 		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 		<-sigChan
 		log.Println("Shutting down gracefully...")
-		app.Shutdown()
+
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.GetShutdownTimeout())
+		defer cancel()
+
+		// Stop accepting connections and wait for in-flight requests
+		if err := app.ShutdownWithContext(ctx); err != nil {
+			log.Printf("⚠️ HTTP server shutdown: %v", err)
+		}
+
+		// Wait for running scheduled jobs
+		if err := routes.StopScheduledTasks(ctx); err != nil {
+			log.Printf("⚠️ Scheduler shutdown: %v", err)
+		}
+
+		// Finish the outbox batch being sent; the rest stays queued
+		if err := outbox.Shutdown(ctx); err != nil {
+			log.Printf("⚠️ Outbox shutdown: %v", err)
+		}
+
+		// Drain queued webhook deliveries
+		if err := webhookservice.Shutdown(ctx); err != nil {
+			log.Printf("⚠️ Webhook shutdown: %v", err)
+		}
+
+		// Finish running exports before Redis goes away; queued ones stay
+		// in Redis for the next start
+		if exportQueue != nil {
+			if err := exportQueue.Shutdown(ctx); err != nil {
+				log.Printf("⚠️ Job queue shutdown: %v", err)
+			}
+		}
+
+		if cacheSvc != nil {
+			if err := cacheSvc.Close(); err != nil {
+				log.Printf("⚠️ Redis close: %v", err)
+			}
+		}
+
+		if err := database.Close(); err != nil {
+			log.Printf("⚠️ Database close: %v", err)
+		}
+
+		log.Println("✅ Shutdown complete")
 	}()
 
 	// ========== Start Server ==========
@@ -618,17 +992,17 @@ func main() {
 	log.Println("📋 Features:")
 	log.Printf("   • Products: ✅")
 	log.Printf("   • Sales: ✅")
-	if cfg.FeatureStaffAccountsEnabled {
+	if featureFlags.Enabled(models.FeatureFlagStaffAccounts) {
 		log.Printf("   • Staff: ✅")
 	}
-	if mpesaSvc != nil {
+	if featureFlags.Enabled(models.FeatureFlagMpesa) {
 		log.Printf("   • M-Pesa: ✅")
 	}
-	if apiSvc != nil {
+	if featureFlags.Enabled(models.FeatureFlagAnalytics) {
 		log.Printf("   • API Keys: ✅")
 		log.Printf("   • Webhooks: ✅")
 	}
-	if cfg.FeatureMultipleShopsEnabled {
+	if featureFlags.Enabled(models.FeatureFlagMultipleShops) {
 		log.Printf("   • USSD: ✅")
 		log.Printf("   • Multi-shop: ✅")
 	}
@@ -636,4 +1010,6 @@ func main() {
 	if err := app.Listen(addr); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
+
+	<-shutdownDone
 }
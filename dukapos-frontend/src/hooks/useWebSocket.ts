@@ -6,6 +6,7 @@ export type WebSocketMessageType =
   | 'new_sale'
   | 'low_stock'
   | 'payment_received'
+  | 'payment_status'
   | 'order_update'
   | 'stock_sync'
   | 'pong'
@@ -16,10 +17,19 @@ export interface WebSocketMessage {
   timestamp: number
 }
 
+export interface PaymentStatusPayload {
+  payment_id: number
+  checkout_request_id: string
+  status: 'completed' | 'failed' | 'timeout'
+  amount: number
+  mpesa_receipt: string
+}
+
 export interface UseWebSocketOptions {
   onNewSale?: (data: { product: string; amount: number }) => void
   onLowStock?: (data: { product: string; current_stock: number }) => void
   onPaymentReceived?: (data: { amount: number; phone: string }) => void
+  onPaymentStatus?: (data: PaymentStatusPayload) => void
   onOrderUpdate?: (data: { order_id: number; status: string }) => void
   onStockSync?: (data: { product_id: number; quantity: number }) => void
   onConnect?: () => void
@@ -75,6 +85,9 @@ export function useWebSocket(options: UseWebSocketOptions = {}) {
             case 'payment_received':
               options.onPaymentReceived?.(message.payload as { amount: number; phone: string })
               break
+            case 'payment_status':
+              options.onPaymentStatus?.(message.payload as PaymentStatusPayload)
+              break
             case 'order_update':
               options.onOrderUpdate?.(message.payload as { order_id: number; status: string })
               break
@@ -13,14 +13,21 @@ import (
 // Config holds all application configuration
 type Config struct {
 	// Server
-	Port        string
-	Environment string
-	Debug       bool
+	Port                   string
+	Environment            string
+	Debug                  bool
+	ShutdownTimeoutSeconds int
+
+	// Reverse proxies in front of the server; the client IP is read from
+	// ProxyHeader only on requests from these addresses
+	TrustedProxies string
+	ProxyHeader    string
 
 	// Database
 	DBPath               string
 	DBMaxIdleConnections int
 	DBMaxOpenConnections int
+	DBConnMaxLifetime    int    // minutes
 	DBType               string // sqlite or postgres
 	DBHost               string
 	DBPort               int
@@ -29,11 +36,18 @@ type Config struct {
 	DBName               string
 	DBSSLMode            string
 
+	// Directory billing invoice PDFs are stored in
+	InvoiceStorageDir string
+
+	// Directory served under /static; product images are stored in it
+	StaticDir string
+
 	// Twilio
 	TwilioAccountSID       string
 	TwilioAuthToken        string
 	TwilioWhatsAppNumber   string
 	TwilioAuthTokenConfirm string
+	TwilioBaseURL          string // overrides the Twilio API host, e.g. for a mock server
 
 	// JWT
 	JWTSecret    string
@@ -44,8 +58,39 @@ type Config struct {
 	MPesaConsumerSecret string
 	MPesaShortcode      string
 	MPesaPasskey        string
-	MPesaEnvironment    string
+	MPesaEnvironment    string // sandbox, live, or mock to simulate payments
 	MPesaCallbackURL    string
+	MPesaCallbackToken  string
+	MPesaCallbackIPs    string
+	MPesaMockDelaySecs  int // how long mock STK pushes take to be paid
+
+	// M-Pesa B2C payouts, sent with each shop's own initiator
+	MPesaB2CResultURL  string
+	MPesaB2CTimeoutURL string
+	MPesaB2CDailyLimit int
+
+	// M-Pesa transaction status queries and reversals (also the shop's initiator)
+	MPesaStatusResultURL   string
+	MPesaReversalResultURL string
+
+	// M-Pesa paybill (C2B) payments
+	MPesaC2BValidationURL     string
+	MPesaC2BConfirmationURL   string
+	MPesaC2BResponseType      string
+	MPesaC2BRegisterOnStartup bool
+
+	// Public base URL external webhooks are delivered to (used for signature checks)
+	WebhookBaseURL string
+
+	// Public site serving digital receipts linked from receipt QR codes
+	ReceiptBaseURL string
+
+	// Public site serving /pay/{token}, the pages payment links open
+	PaymentLinkBaseURL string
+
+	// Directory for QR codes and receipts sent as WhatsApp media, served
+	// at WEBHOOK_BASE_URL/media
+	MediaDir string
 
 	// OpenAI
 	OpenAIAPIKey string
@@ -55,6 +100,18 @@ type Config struct {
 	RedisPassword string
 	RedisDB       int
 
+	// Workers running queued exports (needs Redis)
+	JobWorkers int
+
+	// Longest period, in days, exported within a request
+	ExportMaxRangeDays int
+
+	// Where background exports are written, how long they are kept and
+	// the secret their download links are signed with
+	ExportJobDir        string
+	ExportJobTTLHours   int
+	ExportSigningSecret string
+
 	// Rate Limiting
 	RateLimitEnabled       bool
 	RateLimitMaxRequests   int
@@ -64,9 +121,17 @@ type Config struct {
 	LogLevel string
 	LogFile  string
 
+	// Prometheus metrics
+	MetricsEnabled     bool
+	MetricsAllowedCIDR string
+
 	// Security
 	AllowedOrigins string
 	CORSEnabled    bool
+	// LoginCountryHeader is the request header the proxy in front puts the
+	// client's country code in, off by default; logins from a new country
+	// are emailed
+	LoginCountryHeader string
 
 	// Encryption
 	EncryptionKey string
@@ -75,9 +140,40 @@ type Config struct {
 	AfricaTalkingAPIKey    string
 	AfricaTalkingUsername  string
 	AfricaTalkingShortCode string
+	AfricaTalkingDLRToken  string // required on SMS delivery report callbacks when set
 	SendGridAPIKey         string
 	SendGridFromEmail      string
 	SendGridFromName       string
+	SendGridWebhookToken   string // required on SendGrid event webhooks when set
+
+	// USSD requests are only accepted with this ?token= and/or from these
+	// IPs/CIDRs; with neither every USSD request is rejected
+	USSDGatewayToken string
+	USSDAllowedIPs   string
+
+	// A second USSD gateway at /api/v1/ussd/gateway, off without a format;
+	// see ussdhandler.GatewayConfig
+	USSDGatewayFormat       string // form, json or xml
+	USSDGatewayFields       string // e.g. "phone=MSISDN,session=SESSIONID,input=INPUT"
+	USSDGatewayCumulative   bool   // input holds every answer joined by "*"
+	USSDGatewayContinue     string
+	USSDGatewayEnd          string
+	USSDGatewayActionHeader string
+
+	// Receipt printer; thermal printers take ESC/POS over TCP at host:port
+	PrinterType           string // thermal, cloud or pdf
+	PrinterConnection     string // network, usb or serial
+	PrinterHost           string
+	PrinterPort           int
+	PrinterDevice         string // USB or serial device, e.g. /dev/usb/lp0
+	PrinterWidth          int    // characters per line, 32 for 58mm paper and 48 for 80mm
+	PrinterOpenDrawer     bool   // kick the cash drawer on cash sales
+	PrinterTimeoutSeconds int
+	PrinterAllowLocal     bool // let shops save printers on the server's network or USB/serial devices
+
+	// Stripe card payments for online orders; off without a secret key
+	StripeSecretKey     string
+	StripeWebhookSecret string
 
 	// Feature Flags
 	FeatureMpesaEnabled         bool
@@ -95,14 +191,18 @@ func Load() (*Config, error) {
 
 	cfg := &Config{
 		// Server
-		Port:        getEnv("PORT", "8080"),
-		Environment: getEnv("ENVIRONMENT", "development"),
-		Debug:       getEnvAsBool("DEBUG", false),
+		Port:                   getEnv("PORT", "8080"),
+		Environment:            getEnv("ENVIRONMENT", "development"),
+		Debug:                  getEnvAsBool("DEBUG", false),
+		ShutdownTimeoutSeconds: getEnvAsInt("SHUTDOWN_TIMEOUT_SECONDS", 30),
+		TrustedProxies:         getEnv("TRUSTED_PROXIES", ""),
+		ProxyHeader:            getEnv("PROXY_HEADER", "X-Real-IP"),
 
 		// Database
 		DBPath:               getEnv("DB_PATH", "./dukapos.db"),
-		DBMaxIdleConnections: getEnvAsInt("DB_MAX_IDLE_CONNECTIONS", 10),
-		DBMaxOpenConnections: getEnvAsInt("DB_MAX_OPEN_CONNECTIONS", 100),
+		DBMaxIdleConnections: getEnvAsInt("DB_MAX_IDLE_CONNS", getEnvAsInt("DB_MAX_IDLE_CONNECTIONS", 5)),
+		DBMaxOpenConnections: getEnvAsInt("DB_MAX_OPEN_CONNS", getEnvAsInt("DB_MAX_OPEN_CONNECTIONS", 25)),
+		DBConnMaxLifetime:    getEnvAsInt("DB_CONN_MAX_LIFETIME_MINUTES", 30),
 		DBType:               getEnv("DB_TYPE", "sqlite"),
 		DBHost:               getEnv("DB_HOST", "localhost"),
 		DBPort:               getEnvAsInt("DB_PORT", 5432),
@@ -111,11 +211,15 @@ func Load() (*Config, error) {
 		DBName:               getEnv("DB_NAME", "dukapos"),
 		DBSSLMode:            getEnv("DB_SSL_MODE", "disable"),
 
+		InvoiceStorageDir: getEnv("INVOICE_STORAGE_DIR", "./data/invoices"),
+		StaticDir:         getEnv("STATIC_DIR", "./static"),
+
 		// Twilio
 		TwilioAccountSID:       getEnv("TWILIO_ACCOUNT_SID", ""),
 		TwilioAuthToken:        getEnv("TWILIO_AUTH_TOKEN", ""),
 		TwilioWhatsAppNumber:   getEnv("TWILIO_WHATSAPP_NUMBER", "whatsapp:+14155238886"),
 		TwilioAuthTokenConfirm: getEnv("TWILIO_AUTHENTICATION_TOKEN", ""),
+		TwilioBaseURL:          getEnv("TWILIO_BASE_URL", ""),
 
 		// JWT
 		JWTSecret:    getEnv("JWT_SECRET", "change-me-in-production"),
@@ -128,6 +232,27 @@ func Load() (*Config, error) {
 		MPesaPasskey:        getEnv("MPESA_PASSKEY", ""),
 		MPesaEnvironment:    getEnv("MPESA_ENVIRONMENT", "sandbox"),
 		MPesaCallbackURL:    getEnv("MPESA_CALLBACK_URL", ""),
+		MPesaCallbackToken:  getEnv("MPESA_CALLBACK_TOKEN", ""),
+		MPesaCallbackIPs:    getEnv("MPESA_CALLBACK_ALLOWED_IPS", "safaricom"),
+		MPesaMockDelaySecs:  getEnvAsInt("MPESA_MOCK_CALLBACK_SECONDS", 3),
+
+		MPesaB2CResultURL:      getEnv("MPESA_B2C_RESULT_URL", ""),
+		MPesaB2CTimeoutURL:     getEnv("MPESA_B2C_TIMEOUT_URL", ""),
+		MPesaB2CDailyLimit:     getEnvAsInt("MPESA_B2C_DAILY_LIMIT", 50000),
+		MPesaStatusResultURL:   getEnv("MPESA_STATUS_RESULT_URL", ""),
+		MPesaReversalResultURL: getEnv("MPESA_REVERSAL_RESULT_URL", ""),
+
+		MPesaC2BValidationURL:     getEnv("MPESA_C2B_VALIDATION_URL", ""),
+		MPesaC2BConfirmationURL:   getEnv("MPESA_C2B_CONFIRMATION_URL", ""),
+		MPesaC2BResponseType:      getEnv("MPESA_C2B_RESPONSE_TYPE", "Completed"),
+		MPesaC2BRegisterOnStartup: getEnvAsBool("MPESA_C2B_REGISTER_ON_STARTUP", false),
+
+		WebhookBaseURL: getEnv("WEBHOOK_BASE_URL", ""),
+		ReceiptBaseURL: getEnv("RECEIPT_BASE_URL", "https://receipt.dukapos.io"),
+
+		PaymentLinkBaseURL: getEnv("PAYMENT_LINK_BASE_URL", "https://pay.dukapos.io"),
+
+		MediaDir: getEnv("MEDIA_DIR", "./data/media"),
 
 		// OpenAI
 		OpenAIAPIKey: getEnv("OPENAI_API_KEY", ""),
@@ -137,6 +262,14 @@ func Load() (*Config, error) {
 		RedisPassword: getEnv("REDIS_PASSWORD", ""),
 		RedisDB:       getEnvAsInt("REDIS_DB", 0),
 
+		JobWorkers: getEnvAsInt("JOB_WORKERS", 2),
+
+		ExportMaxRangeDays: getEnvAsInt("EXPORT_MAX_RANGE_DAYS", 92),
+
+		ExportJobDir:        getEnv("EXPORT_JOB_DIR", "./data/exports"),
+		ExportJobTTLHours:   getEnvAsInt("EXPORT_JOB_TTL_HOURS", 24),
+		ExportSigningSecret: getEnv("EXPORT_SIGNING_SECRET", ""),
+
 		// Rate Limiting
 		RateLimitEnabled:       getEnvAsBool("RATE_LIMIT_ENABLED", true),
 		RateLimitMaxRequests:   getEnvAsInt("RATE_LIMIT_MAX_REQUESTS", 100),
@@ -146,9 +279,14 @@ func Load() (*Config, error) {
 		LogLevel: getEnv("LOG_LEVEL", "debug"),
 		LogFile:  getEnv("LOG_FILE", "./logs/dukapos.log"),
 
+		// Prometheus metrics
+		MetricsEnabled:     getEnvAsBool("METRICS_ENABLED", true),
+		MetricsAllowedCIDR: getEnv("METRICS_ALLOWED_CIDR", "127.0.0.1/32,::1/128"),
+
 		// Security
-		AllowedOrigins: getEnv("ALLOWED_ORIGINS", "*"),
-		CORSEnabled:    getEnvAsBool("CORS_ENABLED", true),
+		AllowedOrigins:     getEnv("ALLOWED_ORIGINS", "*"),
+		CORSEnabled:        getEnvAsBool("CORS_ENABLED", true),
+		LoginCountryHeader: getEnv("LOGIN_COUNTRY_HEADER", ""),
 
 		// Encryption (AES-256-GCM for sensitive data)
 		EncryptionKey: getEnv("ENCRYPTION_KEY", ""),
@@ -157,9 +295,33 @@ func Load() (*Config, error) {
 		AfricaTalkingAPIKey:    getEnv("AFRICA_TALKING_API_KEY", ""),
 		AfricaTalkingUsername:  getEnv("AFRICA_TALKING_USERNAME", "sandbox"),
 		AfricaTalkingShortCode: getEnv("AFRICA_TALKING_SHORT_CODE", ""),
+		AfricaTalkingDLRToken:  getEnv("AFRICA_TALKING_DLR_TOKEN", ""),
 		SendGridAPIKey:         getEnv("SENDGRID_API_KEY", ""),
 		SendGridFromEmail:      getEnv("SENDGRID_FROM_EMAIL", "noreply@dukapos.com"),
 		SendGridFromName:       getEnv("SENDGRID_FROM_NAME", "DukaPOS"),
+		SendGridWebhookToken:   getEnv("SENDGRID_WEBHOOK_TOKEN", ""),
+
+		USSDGatewayToken:        getEnv("USSD_GATEWAY_TOKEN", ""),
+		USSDAllowedIPs:          getEnv("USSD_ALLOWED_IPS", ""),
+		USSDGatewayFormat:       getEnv("USSD_GATEWAY_FORMAT", ""),
+		USSDGatewayFields:       getEnv("USSD_GATEWAY_FIELDS", ""),
+		USSDGatewayCumulative:   getEnvAsBool("USSD_GATEWAY_CUMULATIVE", false),
+		USSDGatewayContinue:     getEnv("USSD_GATEWAY_CONTINUE", ""),
+		USSDGatewayEnd:          getEnv("USSD_GATEWAY_END", ""),
+		USSDGatewayActionHeader: getEnv("USSD_GATEWAY_ACTION_HEADER", ""),
+
+		PrinterType:           getEnv("PRINTER_TYPE", "thermal"),
+		PrinterConnection:     getEnv("PRINTER_CONNECTION", "network"),
+		PrinterHost:           getEnv("PRINTER_HOST", ""),
+		PrinterPort:           getEnvAsInt("PRINTER_PORT", 9100),
+		PrinterDevice:         getEnv("PRINTER_DEVICE", ""),
+		PrinterWidth:          getEnvAsInt("PRINTER_WIDTH", 32),
+		PrinterOpenDrawer:     getEnvAsBool("PRINTER_OPEN_DRAWER", true),
+		PrinterTimeoutSeconds: getEnvAsInt("PRINTER_TIMEOUT_SECONDS", 5),
+		PrinterAllowLocal:     getEnvAsBool("PRINTER_ALLOW_LOCAL", false),
+
+		StripeSecretKey:     getEnv("STRIPE_SECRET_KEY", ""),
+		StripeWebhookSecret: getEnv("STRIPE_WEBHOOK_SECRET", ""),
 
 		// Feature Flags (enabled by default per FEATURES.md documentation)
 		FeatureMpesaEnabled:         getEnvAsBool("FEATURE_MPESA_ENABLED", true),
@@ -176,6 +338,12 @@ func Load() (*Config, error) {
 	if cfg.TwilioAuthToken == "" {
 		fmt.Println("Warning: TWILIO_AUTH_TOKEN not set")
 	}
+	// Mock M-Pesa pays every prompt without taking money, so it needs
+	// ENVIRONMENT set to development or test rather than the default
+	if cfg.MPesaEnvironment == "mock" && !mockPaymentsAllowed(os.Getenv("ENVIRONMENT")) {
+		return nil, fmt.Errorf("MPESA_ENVIRONMENT=mock needs ENVIRONMENT=development or ENVIRONMENT=test")
+	}
+
 	if cfg.JWTSecret == "change-me-in-production" {
 		fmt.Println("Warning: Using default JWT_SECRET - change in production!")
 	}
@@ -196,16 +364,67 @@ func (c *Config) GetAllowedOrigins() []string {
 	return strings.Split(c.AllowedOrigins, ",")
 }
 
+// GetMpesaCallbackIPs returns the allowed M-Pesa callback sources as a slice
+func (c *Config) GetMpesaCallbackIPs() []string {
+	if c.MPesaCallbackIPs == "" {
+		return nil
+	}
+	return strings.Split(c.MPesaCallbackIPs, ",")
+}
+
+// GetTrustedProxies returns the trusted proxy addresses as a slice
+func (c *Config) GetTrustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(c.TrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// GetUSSDAllowedIPs returns the USSD gateway's allowed sources as a slice
+func (c *Config) GetUSSDAllowedIPs() []string {
+	if c.USSDAllowedIPs == "" {
+		return nil
+	}
+	return strings.Split(c.USSDAllowedIPs, ",")
+}
+
+// GetMetricsAllowedCIDRs returns the networks allowed to scrape /metrics
+func (c *Config) GetMetricsAllowedCIDRs() []string {
+	if c.MetricsAllowedCIDR == "" {
+		return nil
+	}
+	return strings.Split(c.MetricsAllowedCIDR, ",")
+}
+
 // GetJWTDuration returns the JWT expiry duration
 func (c *Config) GetJWTDuration() time.Duration {
 	return time.Duration(c.JWTExpiryHrs) * time.Hour
 }
 
+// GetShutdownTimeout returns how long graceful shutdown may take before giving up
+func (c *Config) GetShutdownTimeout() time.Duration {
+	return time.Duration(c.ShutdownTimeoutSeconds) * time.Second
+}
+
+// GetDBConnMaxLifetime returns how long a pooled database connection may be reused
+func (c *Config) GetDBConnMaxLifetime() time.Duration {
+	return time.Duration(c.DBConnMaxLifetime) * time.Minute
+}
+
 // IsProduction returns true if running in production
 func (c *Config) IsProduction() bool {
 	return c.Environment == "production"
 }
 
+// mockPaymentsAllowed reports whether an explicitly set ENVIRONMENT allows
+// mock M-Pesa
+func mockPaymentsAllowed(environment string) bool {
+	return environment == "development" || environment == "test"
+}
+
 // IsDevelopment returns true if running in development
 func (c *Config) IsDevelopment() bool {
 	return c.Environment == "development"
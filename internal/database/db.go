@@ -1,12 +1,14 @@
 package database
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"time"
 
 	"github.com/C9b3rD3vi1/DukaPOS/internal/config"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/database/migrations"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/postgres"
@@ -62,53 +64,101 @@ func Connect(cfg *config.Config) error {
 		return fmt.Errorf("failed to get database connection: %w", err)
 	}
 
-	sqlDB.SetMaxIdleConns(cfg.DBMaxIdleConnections)
 	sqlDB.SetMaxOpenConns(cfg.DBMaxOpenConnections)
-	sqlDB.SetConnMaxLifetime(time.Hour)
+	sqlDB.SetMaxIdleConns(cfg.DBMaxIdleConnections)
+	sqlDB.SetConnMaxLifetime(cfg.GetDBConnMaxLifetime())
+	log.Printf("📦 Connection pool: max open %d, max idle %d, max lifetime %s",
+		cfg.DBMaxOpenConnections, cfg.DBMaxIdleConnections, cfg.GetDBConnMaxLifetime())
 
 	log.Println("✅ Database connected successfully")
 	return nil
 }
 
+// Migrate applies pending versioned migrations from
+// internal/database/migrations. A database created by the old AutoMigrate
+// startup is refused until its version is recorded.
 func Migrate() error {
 	log.Println("🔄 Running database migrations...")
 
-	migrator := DB.Migrator()
-
-	modelsToMigrate := []interface{}{
-		&models.Account{},
-		&models.Shop{},
-		&models.Product{},
-		&models.Sale{},
-		&models.DailySummary{},
-		&models.Staff{},
-		&models.Customer{},
-		&models.Supplier{},
-		&models.Order{},
-		&models.OrderItem{},
-		&models.AuditLog{},
-		&models.Webhook{},
-		&models.APIKey{},
-		&models.LoyaltyTransaction{},
+	runner, err := newMigrationRunner()
+	if err != nil {
+		return err
+	}
+	if err := checkUnversionedSchema(runner); err != nil {
+		return err
 	}
 
-	for _, model := range modelsToMigrate {
-		if !migrator.HasTable(model) {
-			if err := DB.AutoMigrate(model); err != nil {
-				log.Printf("⚠️ Failed to migrate %v: %v", model, err)
-				continue
-			}
-		} else {
-			if err := DB.AutoMigrate(model); err != nil {
-				log.Printf("⚠️ Failed to migrate %v: %v", model, err)
-			}
-		}
+	applied, err := runner.Up()
+	for _, m := range applied {
+		log.Printf("✅ Applied migration %04d_%s", m.Version, m.Name)
+	}
+	if err != nil {
+		return err
 	}
 
-	log.Println("✅ Database migrations completed")
+	version, _, _ := runner.Version()
+	log.Printf("✅ Database migrations completed (version %04d)", version)
+	return nil
+}
+
+// MigrateDown rolls back the latest applied migration
+func MigrateDown() error {
+	runner, err := newMigrationRunner()
+	if err != nil {
+		return err
+	}
+	m, err := runner.Down()
+	if err != nil {
+		return err
+	}
+	log.Printf("↩️ Rolled back migration %04d_%s", m.Version, m.Name)
 	return nil
 }
 
+// MigrationVersion returns the applied and newest migration versions, and
+// whether the applied one failed part way
+func MigrationVersion() (version, latest uint, dirty bool, err error) {
+	runner, err := newMigrationRunner()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	version, dirty, err = runner.Version()
+	return version, runner.Latest(), dirty, err
+}
+
+func newMigrationRunner() (*migrations.Runner, error) {
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %w", err)
+	}
+	return migrations.New(sqlDB, DB.Dialector.Name())
+}
+
+// ErrUnversionedSchema is returned for a database that has tables but no
+// migration history, as the old AutoMigrate startup left them
+var ErrUnversionedSchema = errors.New("database has tables but no migration history")
+
+// checkUnversionedSchema refuses to run migrations over a schema they did
+// not build. Once such a database matches a migration version, recording
+// it with "go run ./cmd/migrate force N" lets the later ones run.
+func checkUnversionedSchema(runner *migrations.Runner) error {
+	version, dirty, err := runner.Version()
+	if err != nil || version > 0 || dirty || !DB.Migrator().HasTable(&models.Shop{}) {
+		return err
+	}
+	return fmt.Errorf("%w: bring it to a migration's schema and record that version with the migrate force command", ErrUnversionedSchema)
+}
+
+// ForceMigrationVersion records version as applied without running it,
+// for baselining an existing database or clearing a failed migration
+func ForceMigrationVersion(version uint) error {
+	runner, err := newMigrationRunner()
+	if err != nil {
+		return err
+	}
+	return runner.Force(version)
+}
+
 func Seed() error {
 	log.Println("🌱 Checking for seed data...")
 
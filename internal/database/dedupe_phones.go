@@ -0,0 +1,100 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
+	"gorm.io/gorm"
+)
+
+// PhoneMerge is one shop phone number DedupeShopPhones normalised. Merged
+// lists shops with the same number whose data moved to the kept shop.
+type PhoneMerge struct {
+	Phone  string
+	KeptID uint
+	Merged []uint
+	Err    error // the merge was rolled back
+}
+
+// DedupeShopPhones stores every shop's phone number normalised. Shops
+// whose numbers normalise to the same one were created by messages in
+// different formats; the oldest is kept and the others' rows in every
+// table with a shop_id are moved to it before they are deleted. Each
+// number is merged in its own transaction, so a merge that fails, e.g. on
+// a unique index, is reported without stopping the rest. With dryRun
+// nothing is changed.
+func DedupeShopPhones(dryRun bool) ([]PhoneMerge, error) {
+	var shops []models.Shop
+	if err := DB.Select("id", "phone").Order("id ASC").Find(&shops).Error; err != nil {
+		return nil, fmt.Errorf("failed to load shops: %w", err)
+	}
+
+	groups := make(map[string][]models.Shop)
+	for _, shop := range shops {
+		phone := utils.NormalizePhone(shop.Phone)
+		groups[phone] = append(groups[phone], shop)
+	}
+	phones := make([]string, 0, len(groups))
+	for phone, group := range groups {
+		if len(group) > 1 || group[0].Phone != phone {
+			phones = append(phones, phone)
+		}
+	}
+	sort.Strings(phones)
+
+	tables, err := shopTables()
+	if err != nil {
+		return nil, err
+	}
+
+	merges := make([]PhoneMerge, 0, len(phones))
+	for _, phone := range phones {
+		group := groups[phone]
+		merge := PhoneMerge{Phone: phone, KeptID: group[0].ID}
+		for _, shop := range group[1:] {
+			merge.Merged = append(merge.Merged, shop.ID)
+		}
+		if !dryRun {
+			merge.Err = DB.Transaction(func(tx *gorm.DB) error {
+				return mergeShops(tx, tables, merge)
+			})
+		}
+		merges = append(merges, merge)
+	}
+	return merges, nil
+}
+
+// mergeShops moves the merged shops' rows to the kept shop, deletes the
+// merged shops and gives the kept one the normalised number
+func mergeShops(tx *gorm.DB, tables []string, merge PhoneMerge) error {
+	if len(merge.Merged) > 0 {
+		for _, table := range tables {
+			err := tx.Table(table).Where("shop_id IN ?", merge.Merged).Update("shop_id", merge.KeptID).Error
+			if err != nil {
+				return fmt.Errorf("failed to move %s: %w", table, err)
+			}
+		}
+		// Deleted for good so the unique phone index frees the number
+		if err := tx.Unscoped().Delete(&models.Shop{}, merge.Merged).Error; err != nil {
+			return fmt.Errorf("failed to delete merged shops: %w", err)
+		}
+	}
+	return tx.Model(&models.Shop{ID: merge.KeptID}).Update("phone", merge.Phone).Error
+}
+
+// shopTables returns the tables with a shop_id column
+func shopTables() ([]string, error) {
+	all, err := DB.Migrator().GetTables()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	var tables []string
+	for _, table := range all {
+		if DB.Migrator().HasColumn(table, "shop_id") {
+			tables = append(tables, table)
+		}
+	}
+	return tables, nil
+}
@@ -0,0 +1,204 @@
+// Package migrations applies the versioned SQL schema migrations embedded
+// from postgres/ and sqlite/ with golang-migrate.
+//
+// Files are NNNN_description.up.sql with a matching
+// NNNN_description.down.sql, and the applied version is kept in the
+// schema_migrations table (version, dirty), so they can also be run with
+// the migrate CLI.
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+var (
+	ErrDirty        = errors.New("database is dirty: a migration failed part way, fix it by hand and force the version")
+	ErrNoMigrations = errors.New("no migrations to roll back")
+)
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is one version with its up and down SQL
+type Migration struct {
+	Version uint
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Runner applies migrations for one database dialect through golang-migrate
+type Runner struct {
+	m          *migrate.Migrate
+	migrations []Migration
+}
+
+// New loads the migrations for dialect ("postgres" or "sqlite"). The
+// runner borrows db and never closes it.
+func New(db *sql.DB, dialect string) (*Runner, error) {
+	loaded, err := Load(dialect)
+	if err != nil {
+		return nil, err
+	}
+	source, err := iofs.New(files, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	var driver database.Driver
+	switch dialect {
+	case "postgres":
+		driver, err = pgx.WithInstance(db, &pgx.Config{})
+	case "sqlite":
+		driver, err = sqlite3.WithInstance(db, &sqlite3.Config{})
+	default:
+		return nil, fmt.Errorf("no migration driver for %s", dialect)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open migration driver: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, dialect, driver)
+	if err != nil {
+		return nil, err
+	}
+	return &Runner{m: m, migrations: loaded}, nil
+}
+
+// Load reads the embedded migrations for dialect in version order
+func Load(dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for %s: %w", dialect, err)
+	}
+
+	byVersion := make(map[uint]*Migration)
+	for _, entry := range entries {
+		m := fileName.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		version, _ := strconv.ParseUint(m[1], 10, 64)
+		content, err := files.ReadFile(path.Join(dialect, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[uint(version)]
+		if !ok {
+			migration = &Migration{Version: uint(version), Name: m[2]}
+			byVersion[uint(version)] = migration
+		}
+		if m[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Latest returns the newest migration version
+func (r *Runner) Latest() uint {
+	if len(r.migrations) == 0 {
+		return 0
+	}
+	return r.migrations[len(r.migrations)-1].Version
+}
+
+// Version returns the applied version, 0 when none has been applied
+func (r *Runner) Version() (uint, bool, error) {
+	version, dirty, err := r.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+// Force records version as applied without running anything, for
+// baselining an existing database or clearing a dirty one
+func (r *Runner) Force(version uint) error {
+	if version == 0 {
+		return r.m.Force(database.NilVersion)
+	}
+	return r.m.Force(int(version))
+}
+
+// Up applies every pending migration in order and returns those applied
+func (r *Runner) Up() ([]Migration, error) {
+	from, _, err := r.Version()
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.m.Up()
+	if errors.Is(err, migrate.ErrNoChange) {
+		err = nil
+	}
+	to, _, verr := r.Version()
+	if verr != nil {
+		return nil, verr
+	}
+
+	var applied []Migration
+	for _, m := range r.migrations {
+		if m.Version > from && m.Version <= to && (err == nil || m.Version < to) {
+			applied = append(applied, m)
+		}
+	}
+	return applied, r.wrap(err)
+}
+
+// Down rolls back the latest applied migration and returns it
+func (r *Runner) Down() (*Migration, error) {
+	current, _, err := r.Version()
+	if err != nil {
+		return nil, err
+	}
+	if current == 0 {
+		return nil, ErrNoMigrations
+	}
+	if err := r.m.Steps(-1); err != nil {
+		return nil, r.wrap(err)
+	}
+	for _, m := range r.migrations {
+		if m.Version == current {
+			return &m, nil
+		}
+	}
+	return nil, fmt.Errorf("applied version %d has no migration file", current)
+}
+
+// wrap turns golang-migrate's dirty error into ErrDirty
+func (r *Runner) wrap(err error) error {
+	var dirty migrate.ErrDirty
+	if errors.As(err, &dirty) {
+		return fmt.Errorf("%w (version %d)", ErrDirty, dirty.Version)
+	}
+	return err
+}
@@ -0,0 +1,39 @@
+DROP TABLE IF EXISTS "email_templates";
+DROP TABLE IF EXISTS "outbound_messages";
+DROP TABLE IF EXISTS "closing_stock_snapshots";
+DROP TABLE IF EXISTS "stripe_payments";
+DROP TABLE IF EXISTS "outbox_messages";
+DROP TABLE IF EXISTS "report_email_preferences";
+DROP TABLE IF EXISTS "otp_codes";
+DROP TABLE IF EXISTS "supplier_products";
+DROP TABLE IF EXISTS "sms_campaigns";
+DROP TABLE IF EXISTS "sms_messages";
+DROP TABLE IF EXISTS "stock_movements";
+DROP TABLE IF EXISTS "payment_links";
+DROP TABLE IF EXISTS "inventory_snapshots";
+DROP TABLE IF EXISTS "pending_sale_items";
+DROP TABLE IF EXISTS "pending_sales";
+DROP TABLE IF EXISTS "invoices";
+DROP TABLE IF EXISTS "mpesa_payment_attempts";
+DROP TABLE IF EXISTS "mpesa_transactions";
+DROP TABLE IF EXISTS "mpesa_payments";
+DROP TABLE IF EXISTS "mpesa_b2c_payouts";
+DROP TABLE IF EXISTS "integration_credentials";
+DROP TABLE IF EXISTS "loyalty_transactions";
+DROP TABLE IF EXISTS "api_keys";
+DROP TABLE IF EXISTS "webhook_deliveries";
+DROP TABLE IF EXISTS "webhooks";
+DROP TABLE IF EXISTS "audit_logs";
+DROP TABLE IF EXISTS "order_items";
+DROP TABLE IF EXISTS "orders";
+DROP TABLE IF EXISTS "suppliers";
+DROP TABLE IF EXISTS "daily_summaries";
+DROP TABLE IF EXISTS "sales";
+DROP TABLE IF EXISTS "customers";
+DROP TABLE IF EXISTS "staffs";
+DROP TABLE IF EXISTS "price_histories";
+DROP TABLE IF EXISTS "product_bundles";
+DROP TABLE IF EXISTS "categories";
+DROP TABLE IF EXISTS "products";
+DROP TABLE IF EXISTS "shops";
+DROP TABLE IF EXISTS "accounts";
@@ -0,0 +1,804 @@
+-- Schema as of the switch to versioned migrations. Databases created
+-- before then are baselined at this version instead of running it.
+
+CREATE TABLE "accounts" (
+    "id" bigserial,
+    "email" varchar(100) NOT NULL,
+    "password_hash" varchar(255) NOT NULL,
+    "name" varchar(100) NOT NULL,
+    "phone" varchar(20) NOT NULL,
+    "is_active" boolean DEFAULT true,
+    "is_verified" boolean DEFAULT false,
+    "is_admin" boolean DEFAULT false,
+    "plan" varchar(20) DEFAULT 'free',
+    "failed_login_attempts" bigint DEFAULT 0,
+    "locked_until" timestamptz,
+    "last_failed_login" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_accounts_deleted_at" ON "accounts" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_accounts_phone" ON "accounts" ("phone");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_accounts_email" ON "accounts" ("email");
+
+CREATE TABLE "shops" (
+    "id" bigserial,
+    "account_id" bigint NOT NULL,
+    "name" varchar(255) NOT NULL,
+    "phone" varchar(20) NOT NULL,
+    "owner_name" varchar(100),
+    "address" varchar(255),
+    "plan" varchar(20) DEFAULT 'free',
+    "mpesa_shortcode" varchar(20),
+    "mpesa_partner_id" varchar(50),
+    "is_active" boolean DEFAULT true,
+    "language" varchar(5) DEFAULT 'en',
+    "feature_phone" boolean DEFAULT false,
+    "rounding" varchar(20) DEFAULT 'none',
+    "auto_deactivate_zero_stock" boolean DEFAULT false,
+    "sms_receipts" boolean DEFAULT false,
+    "low_stock_channel" varchar(10),
+    "birthday_bonus_points" bigint DEFAULT 0,
+    "email" varchar(100),
+    "password_hash" varchar(255),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "brand_name" varchar(100),
+    "brand_logo" varchar(255),
+    "brand_primary_color" varchar(7),
+    "brand_secondary_color" varchar(7),
+    "brand_accent_color" varchar(7),
+    "brand_font" varchar(50),
+    "custom_domain" varchar(255),
+    "custom_subdomain" varchar(50),
+    "invoice_footer" varchar(500),
+    "receipt_header" varchar(255),
+    "receipt_footer" varchar(500),
+    "business_hours" text,
+    "closed_message" varchar(500),
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_accounts_shops" FOREIGN KEY ("account_id") REFERENCES "accounts"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_shops_deleted_at" ON "shops" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_shops_phone" ON "shops" ("phone");
+CREATE INDEX IF NOT EXISTS "idx_shops_account_id" ON "shops" ("account_id");
+
+CREATE TABLE "products" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "name" varchar(100) NOT NULL,
+    "category" varchar(50),
+    "unit" varchar(20) DEFAULT 'pcs',
+    "cost_price" decimal(12,2) DEFAULT 0,
+    "selling_price" decimal(12,2) NOT NULL,
+    "currency" varchar(3) DEFAULT 'KES',
+    "alt_currency" varchar(3),
+    "alt_price" decimal(12,2),
+    "current_stock" bigint DEFAULT 0,
+    "low_stock_threshold" bigint DEFAULT 10,
+    "barcode" varchar(50),
+    "image_url" varchar(255),
+    "is_active" boolean DEFAULT true,
+    "is_bundle" boolean DEFAULT false,
+    "auto_deactivated" boolean DEFAULT false,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "purchase_unit" varchar(20),
+    "units_per_purchase" bigint DEFAULT 1,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_shops_products" FOREIGN KEY ("shop_id") REFERENCES "shops"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_products_deleted_at" ON "products" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_products_name" ON "products" ("name");
+CREATE INDEX IF NOT EXISTS "idx_products_shop_id" ON "products" ("shop_id");
+
+CREATE TABLE "categories" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "name" varchar(50) NOT NULL,
+    "parent_category_id" bigint,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_categories_deleted_at" ON "categories" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_categories_parent_category_id" ON "categories" ("parent_category_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_category_shop_name" ON "categories" ("shop_id","name");
+
+CREATE TABLE "product_bundles" (
+    "id" bigserial,
+    "bundle_product_id" bigint NOT NULL,
+    "component_product_id" bigint NOT NULL,
+    "quantity" bigint NOT NULL DEFAULT 1,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_product_bundles_component" FOREIGN KEY ("component_product_id") REFERENCES "products"("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_bundle_component" ON "product_bundles" ("bundle_product_id","component_product_id");
+
+CREATE TABLE "price_histories" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "product_id" bigint NOT NULL,
+    "old_price" decimal(12,2) NOT NULL,
+    "new_price" decimal(12,2) NOT NULL,
+    "source" varchar(20) NOT NULL,
+    "created_at" timestamptz,
+    "old_cost_price" decimal(12,2) NOT NULL DEFAULT 0,
+    "new_cost_price" decimal(12,2) NOT NULL DEFAULT 0,
+    "changed_by_type" varchar(20),
+    "changed_by_id" bigint,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_price_histories_created_at" ON "price_histories" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_price_histories_product_id" ON "price_histories" ("product_id");
+CREATE INDEX IF NOT EXISTS "idx_price_histories_shop_id" ON "price_histories" ("shop_id");
+
+CREATE TABLE "staffs" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "name" varchar(100) NOT NULL,
+    "phone" varchar(20) NOT NULL,
+    "role" varchar(50) DEFAULT 'staff',
+    "pin" varchar(255),
+    "is_active" boolean DEFAULT true,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_shops_staff" FOREIGN KEY ("shop_id") REFERENCES "shops"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_staffs_deleted_at" ON "staffs" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_staffs_shop_id" ON "staffs" ("shop_id");
+
+CREATE TABLE "customers" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "name" varchar(100) NOT NULL,
+    "phone" varchar(20),
+    "email" varchar(100),
+    "address" varchar(255),
+    "date_of_birth" timestamptz,
+    "loyalty_points" bigint DEFAULT 0,
+    "points_earned" bigint DEFAULT 0,
+    "points_redeemed" bigint DEFAULT 0,
+    "total_spent" decimal DEFAULT 0,
+    "tier" varchar(20) DEFAULT 'bronze',
+    "total_purchases" bigint DEFAULT 0,
+    "last_purchase_at" timestamptz,
+    "referral_code" varchar(20),
+    "referred_by" bigint,
+    "notes" varchar(500),
+    "is_active" boolean DEFAULT true,
+    "email_verified" boolean DEFAULT false,
+    "phone_verified" boolean DEFAULT false,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "sms_opt_out" boolean DEFAULT false,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_customers_shop" FOREIGN KEY ("shop_id") REFERENCES "shops"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_customers_shop_id" ON "customers" ("shop_id");
+CREATE INDEX IF NOT EXISTS "idx_customers_deleted_at" ON "customers" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_customers_referral_code" ON "customers" ("referral_code");
+CREATE INDEX IF NOT EXISTS "idx_customers_phone" ON "customers" ("phone");
+
+CREATE TABLE "sales" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "product_id" bigint NOT NULL,
+    "customer_id" bigint,
+    "quantity" bigint NOT NULL,
+    "unit_price" decimal(12,2) NOT NULL,
+    "total_amount" decimal(12,2) NOT NULL,
+    "cost_amount" decimal(12,2) DEFAULT 0,
+    "profit" decimal(12,2) DEFAULT 0,
+    "payment_method" varchar(20) DEFAULT 'cash',
+    "rounding_adjustment" decimal(12,2) DEFAULT 0,
+    "mpesa_receipt" varchar(50),
+    "mpesa_phone" varchar(20),
+    "pending_sale_id" bigint,
+    "staff_id" bigint,
+    "notes" varchar(255),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_sales_customer" FOREIGN KEY ("customer_id") REFERENCES "customers"("id"),
+    CONSTRAINT "fk_products_sales" FOREIGN KEY ("product_id") REFERENCES "products"("id"),
+    CONSTRAINT "fk_shops_sales" FOREIGN KEY ("shop_id") REFERENCES "shops"("id"),
+    CONSTRAINT "fk_sales_staff" FOREIGN KEY ("staff_id") REFERENCES "staffs"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_sales_shop_id" ON "sales" ("shop_id");
+CREATE INDEX IF NOT EXISTS "idx_sales_deleted_at" ON "sales" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_sales_pending_sale_id" ON "sales" ("pending_sale_id");
+CREATE INDEX IF NOT EXISTS "idx_sales_customer_id" ON "sales" ("customer_id");
+CREATE INDEX IF NOT EXISTS "idx_sales_product_id" ON "sales" ("product_id");
+
+CREATE TABLE "daily_summaries" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "date" date NOT NULL,
+    "total_sales" decimal(12,2) DEFAULT 0,
+    "total_transactions" bigint DEFAULT 0,
+    "total_profit" decimal(12,2) DEFAULT 0,
+    "total_cost" decimal(12,2) DEFAULT 0,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_daily_summaries_shop" FOREIGN KEY ("shop_id") REFERENCES "shops"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_daily_summaries_date" ON "daily_summaries" ("date");
+CREATE INDEX IF NOT EXISTS "idx_daily_summaries_shop_id" ON "daily_summaries" ("shop_id");
+
+CREATE TABLE "suppliers" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "name" varchar(100) NOT NULL,
+    "phone" varchar(20),
+    "email" varchar(100),
+    "address" varchar(255),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_suppliers_shop" FOREIGN KEY ("shop_id") REFERENCES "shops"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_suppliers_deleted_at" ON "suppliers" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_suppliers_shop_id" ON "suppliers" ("shop_id");
+
+CREATE TABLE "orders" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "supplier_id" bigint NOT NULL,
+    "status" varchar(20) DEFAULT 'pending',
+    "total_amount" decimal(12,2),
+    "notes" varchar(255),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "payment_status" varchar(20) DEFAULT 'unpaid',
+    "b2_c_payout_id" bigint,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_orders_supplier" FOREIGN KEY ("supplier_id") REFERENCES "suppliers"("id"),
+    CONSTRAINT "fk_orders_shop" FOREIGN KEY ("shop_id") REFERENCES "shops"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_orders_b2_c_payout_id" ON "orders" ("b2_c_payout_id");
+CREATE INDEX IF NOT EXISTS "idx_orders_deleted_at" ON "orders" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_orders_supplier_id" ON "orders" ("supplier_id");
+CREATE INDEX IF NOT EXISTS "idx_orders_shop_id" ON "orders" ("shop_id");
+
+CREATE TABLE "order_items" (
+    "id" bigserial,
+    "order_id" bigint NOT NULL,
+    "product_id" bigint NOT NULL,
+    "quantity" bigint NOT NULL,
+    "unit_cost" decimal(12,2),
+    "total_cost" decimal(12,2),
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_order_items_product" FOREIGN KEY ("product_id") REFERENCES "products"("id"),
+    CONSTRAINT "fk_orders_items" FOREIGN KEY ("order_id") REFERENCES "orders"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_order_items_product_id" ON "order_items" ("product_id");
+CREATE INDEX IF NOT EXISTS "idx_order_items_order_id" ON "order_items" ("order_id");
+
+CREATE TABLE "audit_logs" (
+    "id" bigserial,
+    "shop_id" bigint,
+    "user_type" varchar(20),
+    "user_id" bigint,
+    "action" varchar(50) NOT NULL,
+    "entity_type" varchar(50),
+    "entity_id" bigint,
+    "details" text,
+    "ip_address" varchar(45),
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_user_id" ON "audit_logs" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_shop_id" ON "audit_logs" ("shop_id");
+
+CREATE TABLE "webhooks" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "name" varchar(100) NOT NULL,
+    "url" varchar(255) NOT NULL,
+    "events" varchar(255),
+    "secret" varchar(255),
+    "is_active" boolean DEFAULT true,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_webhooks_shop" FOREIGN KEY ("shop_id") REFERENCES "shops"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_webhooks_shop_id" ON "webhooks" ("shop_id");
+
+CREATE TABLE "webhook_deliveries" (
+    "id" bigserial,
+    "webhook_id" bigint,
+    "event_id" bigint,
+    "event" varchar(50),
+    "webhook_url" varchar(500),
+    "status" varchar(20) DEFAULT 'pending',
+    "http_status" bigint,
+    "response_body" varchar(1000),
+    "error" varchar(500),
+    "attempt" bigint DEFAULT 0,
+    "delivered_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_event_id" ON "webhook_deliveries" ("event_id");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_webhook_id" ON "webhook_deliveries" ("webhook_id");
+
+CREATE TABLE "api_keys" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "name" varchar(100) NOT NULL,
+    "key" varchar(50) NOT NULL,
+    "secret_hash" varchar(255) NOT NULL,
+    "permissions" varchar(255),
+    "rate_limit" bigint DEFAULT 60,
+    "is_active" boolean DEFAULT true,
+    "last_used_at" timestamptz,
+    "expires_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_api_keys_shop" FOREIGN KEY ("shop_id") REFERENCES "shops"("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_api_keys_key" ON "api_keys" ("key");
+CREATE INDEX IF NOT EXISTS "idx_api_keys_shop_id" ON "api_keys" ("shop_id");
+
+CREATE TABLE "loyalty_transactions" (
+    "id" bigserial,
+    "customer_id" bigint NOT NULL,
+    "shop_id" bigint NOT NULL,
+    "sale_id" bigint,
+    "type" varchar(20) NOT NULL,
+    "points" bigint NOT NULL,
+    "points_before" bigint,
+    "points_after" bigint,
+    "amount" decimal(12,2),
+    "description" varchar(255),
+    "reference" varchar(50),
+    "expires_at" timestamptz,
+    "redeemed_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_loyalty_transactions_shop" FOREIGN KEY ("shop_id") REFERENCES "shops"("id"),
+    CONSTRAINT "fk_customers_transactions" FOREIGN KEY ("customer_id") REFERENCES "customers"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_loyalty_transactions_reference" ON "loyalty_transactions" ("reference");
+CREATE INDEX IF NOT EXISTS "idx_loyalty_transactions_sale_id" ON "loyalty_transactions" ("sale_id");
+CREATE INDEX IF NOT EXISTS "idx_loyalty_transactions_shop_id" ON "loyalty_transactions" ("shop_id");
+CREATE INDEX IF NOT EXISTS "idx_loyalty_transactions_customer_id" ON "loyalty_transactions" ("customer_id");
+
+CREATE TABLE "integration_credentials" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "provider" varchar(30) NOT NULL,
+    "identifier" varchar(50),
+    "secrets" text,
+    "is_active" boolean DEFAULT true,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_integration_credentials_identifier" ON "integration_credentials" ("identifier");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_integration_shop_provider" ON "integration_credentials" ("shop_id","provider");
+CREATE INDEX IF NOT EXISTS "idx_integration_credentials_deleted_at" ON "integration_credentials" ("deleted_at");
+
+CREATE TABLE "mpesa_b2c_payouts" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "phone" varchar(20),
+    "amount" decimal(12,2) NOT NULL,
+    "command_id" varchar(30),
+    "remarks" varchar(100),
+    "occasion" varchar(100),
+    "conversation_id" varchar(100),
+    "originator_conversation_id" varchar(100),
+    "status" varchar(20) DEFAULT 'pending',
+    "result_code" bigint,
+    "result_desc" varchar(255),
+    "receipt_number" varchar(50),
+    "receiver_name" varchar(100),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "completed_at" timestamptz,
+    "currency" varchar(3),
+    "foreign_amount" decimal(12,2),
+    "exchange_rate" decimal(12,4),
+    "order_id" bigint,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_mpesa_b2c_payouts_order_id" ON "mpesa_b2c_payouts" ("order_id");
+CREATE INDEX IF NOT EXISTS "idx_mpesa_b2c_payouts_status" ON "mpesa_b2c_payouts" ("status");
+CREATE INDEX IF NOT EXISTS "idx_mpesa_b2c_payouts_originator_conversation_id" ON "mpesa_b2c_payouts" ("originator_conversation_id");
+CREATE INDEX IF NOT EXISTS "idx_mpesa_b2c_payouts_conversation_id" ON "mpesa_b2c_payouts" ("conversation_id");
+CREATE INDEX IF NOT EXISTS "idx_mpesa_b2c_payouts_phone" ON "mpesa_b2c_payouts" ("phone");
+CREATE INDEX IF NOT EXISTS "idx_mpesa_b2c_payouts_shop_id" ON "mpesa_b2c_payouts" ("shop_id");
+
+CREATE TABLE "mpesa_payments" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "product_id" bigint,
+    "amount" decimal(12,2) NOT NULL,
+    "phone" varchar(20),
+    "account_reference" varchar(50),
+    "description" varchar(255),
+    "merchant_request_id" varchar(100),
+    "checkout_request_id" varchar(100),
+    "mpesa_receipt" varchar(50),
+    "mpesa_transaction_id" varchar(50),
+    "status" varchar(20) DEFAULT 'pending',
+    "failure_reason" varchar(255),
+    "retry_count" bigint DEFAULT 0,
+    "last_attempt_at" timestamptz,
+    "sale_id" bigint,
+    "pending_sale_id" bigint,
+    "payment_link_id" bigint,
+    "plan" varchar(20),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "completed_at" timestamptz,
+    "expires_at" timestamptz,
+    "deleted_at" timestamptz,
+    "status_checks" bigint DEFAULT 0,
+    "last_status_check_at" timestamptz,
+    "amount_paid" decimal(12,2),
+    "payer_phone" varchar(20),
+    "amount_mismatch" varchar(20),
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_mpesa_payments_shop" FOREIGN KEY ("shop_id") REFERENCES "shops"("id"),
+    CONSTRAINT "fk_mpesa_payments_product" FOREIGN KEY ("product_id") REFERENCES "products"("id"),
+    CONSTRAINT "fk_mpesa_payments_sale" FOREIGN KEY ("sale_id") REFERENCES "sales"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_mpesa_payments_product_id" ON "mpesa_payments" ("product_id");
+CREATE INDEX IF NOT EXISTS "idx_mpesa_payments_shop_id" ON "mpesa_payments" ("shop_id");
+CREATE INDEX IF NOT EXISTS "idx_mpesa_payments_deleted_at" ON "mpesa_payments" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_mpesa_payments_sale_id" ON "mpesa_payments" ("sale_id");
+CREATE INDEX IF NOT EXISTS "idx_mpesa_payments_phone" ON "mpesa_payments" ("phone");
+CREATE INDEX IF NOT EXISTS "idx_mpesa_payments_amount_mismatch" ON "mpesa_payments" ("amount_mismatch");
+CREATE INDEX IF NOT EXISTS "idx_mpesa_payments_payment_link_id" ON "mpesa_payments" ("payment_link_id");
+CREATE INDEX IF NOT EXISTS "idx_mpesa_payments_pending_sale_id" ON "mpesa_payments" ("pending_sale_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_mpesa_payments_checkout_request_id" ON "mpesa_payments" ("checkout_request_id");
+
+CREATE TABLE "mpesa_transactions" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "type" varchar(20) NOT NULL,
+    "amount" decimal(12,2) NOT NULL,
+    "phone" varchar(20),
+    "transaction_id" varchar(50),
+    "receipt_number" varchar(50),
+    "transaction_time" timestamptz,
+    "status" varchar(20),
+    "created_at" timestamptz,
+    "account_reference" varchar(50),
+    "customer_name" varchar(100),
+    "sale_id" bigint,
+    "status_conversation_id" varchar(50),
+    "status_requested_at" timestamptz,
+    "status_result" varchar(30),
+    "status_result_desc" varchar(255),
+    "status_checked_at" timestamptz,
+    "reversal_conversation_id" varchar(50),
+    "reversal_status" varchar(20),
+    "reversal_reason" varchar(255),
+    "reversal_receipt" varchar(50),
+    "reversal_result_desc" varchar(255),
+    "reversed_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_mpesa_transactions_shop" FOREIGN KEY ("shop_id") REFERENCES "shops"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_mpesa_transactions_reversal_conversation_id" ON "mpesa_transactions" ("reversal_conversation_id");
+CREATE INDEX IF NOT EXISTS "idx_mpesa_transactions_status_conversation_id" ON "mpesa_transactions" ("status_conversation_id");
+CREATE INDEX IF NOT EXISTS "idx_mpesa_transactions_sale_id" ON "mpesa_transactions" ("sale_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_mpesa_transactions_transaction_id" ON "mpesa_transactions" ("transaction_id");
+CREATE INDEX IF NOT EXISTS "idx_mpesa_transactions_shop_id" ON "mpesa_transactions" ("shop_id");
+
+CREATE TABLE "mpesa_payment_attempts" (
+    "id" bigserial,
+    "payment_id" bigint NOT NULL,
+    "attempt" bigint NOT NULL,
+    "merchant_request_id" varchar(100),
+    "checkout_request_id" varchar(100),
+    "status" varchar(20),
+    "failure_reason" varchar(255),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_mpesa_payments_attempts" FOREIGN KEY ("payment_id") REFERENCES "mpesa_payments"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_mpesa_payment_attempts_payment_id" ON "mpesa_payment_attempts" ("payment_id");
+CREATE INDEX IF NOT EXISTS "idx_mpesa_payment_attempts_checkout_request_id" ON "mpesa_payment_attempts" ("checkout_request_id");
+
+CREATE TABLE "invoices" (
+    "id" bigserial,
+    "account_id" bigint NOT NULL,
+    "shop_id" bigint,
+    "payment_id" bigint,
+    "number" varchar(30),
+    "amount" decimal(12,2) NOT NULL,
+    "currency" varchar(3) DEFAULT 'KES',
+    "plan" varchar(20) NOT NULL,
+    "period_start" timestamptz,
+    "period_end" timestamptz,
+    "paid_at" timestamptz,
+    "mpesa_receipt" varchar(50),
+    "pdf_url" varchar(255),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_invoices_number" ON "invoices" ("number");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_invoices_payment_id" ON "invoices" ("payment_id");
+CREATE INDEX IF NOT EXISTS "idx_invoices_shop_id" ON "invoices" ("shop_id");
+CREATE INDEX IF NOT EXISTS "idx_invoices_account_id" ON "invoices" ("account_id");
+
+CREATE TABLE "pending_sales" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "total_amount" decimal(12,2) NOT NULL,
+    "status" varchar(20) DEFAULT 'pending',
+    "payment_id" bigint,
+    "mpesa_receipt" varchar(50),
+    "notes" varchar(255),
+    "paid_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_pending_sales_payment_id" ON "pending_sales" ("payment_id");
+CREATE INDEX IF NOT EXISTS "idx_pending_sales_status" ON "pending_sales" ("status");
+CREATE INDEX IF NOT EXISTS "idx_pending_sales_shop_id" ON "pending_sales" ("shop_id");
+
+CREATE TABLE "pending_sale_items" (
+    "id" bigserial,
+    "pending_sale_id" bigint NOT NULL,
+    "product_id" bigint NOT NULL,
+    "quantity" bigint NOT NULL,
+    "unit_price" decimal(12,2) NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_pending_sale_items_product" FOREIGN KEY ("product_id") REFERENCES "products"("id"),
+    CONSTRAINT "fk_pending_sales_items" FOREIGN KEY ("pending_sale_id") REFERENCES "pending_sales"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_pending_sale_items_pending_sale_id" ON "pending_sale_items" ("pending_sale_id");
+
+CREATE TABLE "inventory_snapshots" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "date" date NOT NULL,
+    "value" decimal(14,2) DEFAULT 0,
+    "cost_value" decimal(14,2) DEFAULT 0,
+    "units" bigint DEFAULT 0,
+    "products" bigint DEFAULT 0,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_inventory_snapshot_day" ON "inventory_snapshots" ("shop_id","date");
+
+CREATE TABLE "payment_links" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "token" varchar(32) NOT NULL,
+    "amount" decimal(12,2) DEFAULT 0,
+    "description" varchar(255),
+    "status" varchar(20) DEFAULT 'active',
+    "expires_at" timestamptz,
+    "payment_id" bigint,
+    "phone" varchar(20),
+    "mpesa_receipt" varchar(50),
+    "paid_amount" decimal(12,2) DEFAULT 0,
+    "paid_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_payment_links_payment_id" ON "payment_links" ("payment_id");
+CREATE INDEX IF NOT EXISTS "idx_payment_links_status" ON "payment_links" ("status");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_payment_links_token" ON "payment_links" ("token");
+CREATE INDEX IF NOT EXISTS "idx_payment_links_shop_id" ON "payment_links" ("shop_id");
+
+CREATE TABLE "stock_movements" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "product_id" bigint NOT NULL,
+    "type" varchar(20) NOT NULL,
+    "quantity" bigint NOT NULL,
+    "balance_after" bigint,
+    "reference_id" bigint,
+    "note" varchar(255),
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_stock_movements_reference_id" ON "stock_movements" ("reference_id");
+CREATE INDEX IF NOT EXISTS "idx_stock_movements_type" ON "stock_movements" ("type");
+CREATE INDEX IF NOT EXISTS "idx_stock_movement_product" ON "stock_movements" ("product_id","created_at");
+CREATE INDEX IF NOT EXISTS "idx_stock_movements_shop_id" ON "stock_movements" ("shop_id");
+
+CREATE TABLE "sms_messages" (
+    "id" bigserial,
+    "shop_id" bigint,
+    "campaign_id" bigint,
+    "recipient" varchar(20),
+    "body" text,
+    "purpose" varchar(20),
+    "status" varchar(20),
+    "provider_id" varchar(100),
+    "cost" decimal(10,4) DEFAULT 0,
+    "currency" varchar(3),
+    "failure_reason" varchar(255),
+    "delivered_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_sms_messages_recipient" ON "sms_messages" ("recipient");
+CREATE INDEX IF NOT EXISTS "idx_sms_messages_campaign_id" ON "sms_messages" ("campaign_id");
+CREATE INDEX IF NOT EXISTS "idx_sms_messages_shop_id" ON "sms_messages" ("shop_id");
+CREATE INDEX IF NOT EXISTS "idx_sms_messages_created_at" ON "sms_messages" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_sms_messages_provider_id" ON "sms_messages" ("provider_id");
+CREATE INDEX IF NOT EXISTS "idx_sms_messages_status" ON "sms_messages" ("status");
+
+CREATE TABLE "sms_campaigns" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "template" text NOT NULL,
+    "status" varchar(20) DEFAULT 'sending',
+    "tier" varchar(20),
+    "min_points" bigint,
+    "last_purchase_before" timestamptz,
+    "last_purchase_after" timestamptz,
+    "recipients" bigint,
+    "opted_out" bigint,
+    "completed_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_sms_campaigns_shop_id" ON "sms_campaigns" ("shop_id");
+
+CREATE TABLE "supplier_products" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "supplier_id" bigint NOT NULL,
+    "product_id" bigint NOT NULL,
+    "unit_cost" decimal(12,2) DEFAULT 0,
+    "min_order_qty" bigint DEFAULT 1,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_supplier_products_product" FOREIGN KEY ("product_id") REFERENCES "products"("id"),
+    CONSTRAINT "fk_supplier_products_supplier" FOREIGN KEY ("supplier_id") REFERENCES "suppliers"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_supplier_products_product_id" ON "supplier_products" ("product_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_supplier_product" ON "supplier_products" ("supplier_id","product_id");
+CREATE INDEX IF NOT EXISTS "idx_supplier_products_shop_id" ON "supplier_products" ("shop_id");
+
+CREATE TABLE "otp_codes" (
+    "id" bigserial,
+    "destination" varchar(100) NOT NULL,
+    "purpose" varchar(30) NOT NULL,
+    "channel" varchar(10),
+    "code_hash" varchar(64) NOT NULL,
+    "attempts" bigint DEFAULT 0,
+    "expires_at" timestamptz,
+    "used_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_otp_destination" ON "otp_codes" ("destination","purpose");
+
+CREATE TABLE "report_email_preferences" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "enabled" boolean DEFAULT false,
+    "frequency" varchar(10) DEFAULT 'daily',
+    "recipients" text,
+    "last_sent_at" timestamptz,
+    "disabled_reason" varchar(255),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_report_email_preferences_shop_id" ON "report_email_preferences" ("shop_id");
+
+CREATE TABLE "outbox_messages" (
+    "id" bigserial,
+    "channel" varchar(20) NOT NULL,
+    "recipient" varchar(255) NOT NULL,
+    "subject" varchar(255),
+    "body" text,
+    "status" varchar(20) DEFAULT 'pending',
+    "attempts" bigint DEFAULT 0,
+    "max_attempts" bigint DEFAULT 5,
+    "next_attempt_at" timestamptz,
+    "last_error" varchar(500),
+    "sent_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_outbox_messages_next_attempt_at" ON "outbox_messages" ("next_attempt_at");
+CREATE INDEX IF NOT EXISTS "idx_outbox_messages_status" ON "outbox_messages" ("status");
+CREATE INDEX IF NOT EXISTS "idx_outbox_messages_channel" ON "outbox_messages" ("channel");
+
+CREATE TABLE "stripe_payments" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "payment_intent_id" varchar(100) NOT NULL,
+    "product_id" bigint NOT NULL,
+    "quantity" bigint NOT NULL,
+    "amount" decimal(12,2) NOT NULL,
+    "currency" varchar(3) NOT NULL,
+    "description" varchar(255),
+    "status" varchar(20) DEFAULT 'pending',
+    "failure_reason" varchar(255),
+    "sale_id" bigint,
+    "completed_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_stripe_payments_status" ON "stripe_payments" ("status");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_stripe_payments_payment_intent_id" ON "stripe_payments" ("payment_intent_id");
+CREATE INDEX IF NOT EXISTS "idx_stripe_payments_shop_id" ON "stripe_payments" ("shop_id");
+
+CREATE TABLE "closing_stock_snapshots" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "product_id" bigint NOT NULL,
+    "product_name" varchar(255),
+    "closing_stock" bigint NOT NULL,
+    "cost_price" decimal(12,2) DEFAULT 0,
+    "selling_price" decimal(12,2) DEFAULT 0,
+    "snapshot_date" date NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_closing_stock_product_day" ON "closing_stock_snapshots" ("shop_id","product_id","snapshot_date");
+
+CREATE TABLE "outbound_messages" (
+    "id" bigserial,
+    "sid" varchar(64) NOT NULL,
+    "shop_id" bigint,
+    "to" varchar(30) NOT NULL,
+    "type" varchar(30),
+    "status" varchar(20) DEFAULT 'queued',
+    "error_code" varchar(10),
+    "error_message" varchar(255),
+    "delivered_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_outbound_messages_created_at" ON "outbound_messages" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_outbound_messages_status" ON "outbound_messages" ("status");
+CREATE INDEX IF NOT EXISTS "idx_outbound_messages_type" ON "outbound_messages" ("type");
+CREATE INDEX IF NOT EXISTS "idx_outbound_messages_shop_id" ON "outbound_messages" ("shop_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_outbound_messages_s_id" ON "outbound_messages" ("sid");
+
+CREATE TABLE "email_templates" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "key" varchar(50) NOT NULL,
+    "subject" varchar(255) NOT NULL,
+    "html" text NOT NULL,
+    "text" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_email_template_shop_key" ON "email_templates" ("shop_id","key");
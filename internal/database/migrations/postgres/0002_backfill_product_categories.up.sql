@@ -0,0 +1,10 @@
+-- Products used to carry their category only as a name; give every name a
+-- category row
+INSERT INTO "categories" ("shop_id", "name", "created_at", "updated_at")
+SELECT DISTINCT p."shop_id", p."category", CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+FROM "products" p
+WHERE p."category" <> '' AND p."deleted_at" IS NULL
+  AND NOT EXISTS (
+    SELECT 1 FROM "categories" c
+    WHERE c."shop_id" = p."shop_id" AND c."name" = p."category"
+  );
@@ -0,0 +1,3 @@
+ALTER TABLE "shops" DROP COLUMN "ussd_pin_locked_until";
+ALTER TABLE "shops" DROP COLUMN "ussd_pin_failures";
+ALTER TABLE "shops" DROP COLUMN "ussd_pin_hash";
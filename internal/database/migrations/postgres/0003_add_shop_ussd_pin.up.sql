@@ -0,0 +1,3 @@
+ALTER TABLE "shops" ADD COLUMN "ussd_pin_hash" varchar(255);
+ALTER TABLE "shops" ADD COLUMN "ussd_pin_failures" bigint DEFAULT 0;
+ALTER TABLE "shops" ADD COLUMN "ussd_pin_locked_until" timestamptz;
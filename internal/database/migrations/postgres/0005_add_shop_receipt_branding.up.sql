@@ -0,0 +1,3 @@
+ALTER TABLE "shops" ADD COLUMN "receipt_logo" varchar(255);
+ALTER TABLE "shops" ADD COLUMN "receipt_contact" varchar(255);
+ALTER TABLE "shops" ADD COLUMN "vat_number" varchar(30);
@@ -0,0 +1,10 @@
+CREATE TABLE "product_aliases" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "product_id" bigint NOT NULL,
+    "alias" varchar(100) NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_product_alias" ON "product_aliases" ("shop_id","alias");
+CREATE INDEX IF NOT EXISTS "idx_product_aliases_product_id" ON "product_aliases" ("product_id");
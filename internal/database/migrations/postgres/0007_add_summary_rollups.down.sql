@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "monthly_summaries";
+DROP TABLE IF EXISTS "weekly_summaries";
@@ -0,0 +1,27 @@
+CREATE TABLE "weekly_summaries" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "week_start" date NOT NULL,
+    "total_sales" decimal(12,2) DEFAULT 0,
+    "total_transactions" bigint DEFAULT 0,
+    "total_profit" decimal(12,2) DEFAULT 0,
+    "total_cost" decimal(12,2) DEFAULT 0,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_weekly_summary" ON "weekly_summaries" ("shop_id","week_start");
+
+CREATE TABLE "monthly_summaries" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "month" date NOT NULL,
+    "total_sales" decimal(12,2) DEFAULT 0,
+    "total_transactions" bigint DEFAULT 0,
+    "total_profit" decimal(12,2) DEFAULT 0,
+    "total_cost" decimal(12,2) DEFAULT 0,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_monthly_summary" ON "monthly_summaries" ("shop_id","month");
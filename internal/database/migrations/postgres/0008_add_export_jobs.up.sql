@@ -0,0 +1,25 @@
+CREATE TABLE "export_jobs" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "type" varchar(20) NOT NULL,
+    "format" varchar(10) NOT NULL,
+    "period_start" timestamptz,
+    "period_end" timestamptz,
+    "payment_method" varchar(20),
+    "product_id" bigint,
+    "category" varchar(100),
+    "status" varchar(20) DEFAULT 'queued',
+    "error" varchar(500),
+    "rows" bigint DEFAULT 0,
+    "size" bigint DEFAULT 0,
+    "filename" varchar(150),
+    "file_path" varchar(255),
+    "finished_at" timestamptz,
+    "expires_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_export_jobs_shop_id" ON "export_jobs" ("shop_id");
+CREATE INDEX IF NOT EXISTS "idx_export_jobs_status" ON "export_jobs" ("status");
+CREATE INDEX IF NOT EXISTS "idx_export_jobs_expires_at" ON "export_jobs" ("expires_at");
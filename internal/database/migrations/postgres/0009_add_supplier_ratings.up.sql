@@ -0,0 +1,13 @@
+CREATE TABLE "supplier_ratings" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "supplier_id" bigint NOT NULL,
+    "order_id" bigint NOT NULL,
+    "rating" bigint NOT NULL,
+    "notes" text,
+    "rated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_supplier_ratings_shop_id" ON "supplier_ratings" ("shop_id");
+CREATE INDEX IF NOT EXISTS "idx_supplier_ratings_supplier_id" ON "supplier_ratings" ("supplier_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_supplier_ratings_order_id" ON "supplier_ratings" ("order_id");
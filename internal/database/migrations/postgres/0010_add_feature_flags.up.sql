@@ -0,0 +1,6 @@
+CREATE TABLE "feature_flags" (
+    "name" varchar(50),
+    "enabled" boolean NOT NULL,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("name")
+);
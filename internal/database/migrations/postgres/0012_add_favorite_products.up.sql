@@ -0,0 +1,8 @@
+CREATE TABLE "favorite_products" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "product_id" bigint NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_favorite_product" ON "favorite_products" ("shop_id","product_id");
@@ -0,0 +1,15 @@
+CREATE TABLE "printer_settings" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "type" varchar(20) DEFAULT 'thermal',
+    "connection" varchar(20) DEFAULT 'network',
+    "host" varchar(255),
+    "port" bigint,
+    "device" varchar(255),
+    "paper_width" bigint DEFAULT 58,
+    "open_drawer" boolean DEFAULT false,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_printer_settings_shop_id" ON "printer_settings" ("shop_id");
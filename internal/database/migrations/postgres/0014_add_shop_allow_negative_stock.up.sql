@@ -0,0 +1 @@
+ALTER TABLE "shops" ADD COLUMN "allow_negative_stock" boolean DEFAULT false;
@@ -0,0 +1,3 @@
+ALTER TABLE "shops" DROP COLUMN "receipt_currency";
+ALTER TABLE "shops" DROP COLUMN "receipt_show_qr";
+ALTER TABLE "shops" DROP COLUMN "tax_pin";
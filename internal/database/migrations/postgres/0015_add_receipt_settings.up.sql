@@ -0,0 +1,3 @@
+ALTER TABLE "shops" ADD COLUMN "tax_pin" varchar(20);
+ALTER TABLE "shops" ADD COLUMN "receipt_show_qr" boolean DEFAULT false;
+ALTER TABLE "shops" ADD COLUMN "receipt_currency" varchar(10);
@@ -0,0 +1,23 @@
+CREATE TABLE "day_closes" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "number" bigint NOT NULL,
+    "business_date" date NOT NULL,
+    "period_start" timestamptz NOT NULL,
+    "period_end" timestamptz NOT NULL,
+    "transactions" bigint DEFAULT 0,
+    "cash_sales" decimal(12,2) DEFAULT 0,
+    "mpesa_sales" decimal(12,2) DEFAULT 0,
+    "other_sales" decimal(12,2) DEFAULT 0,
+    "gross_sales" decimal(12,2) DEFAULT 0,
+    "refunds" decimal(12,2) DEFAULT 0,
+    "refund_count" bigint DEFAULT 0,
+    "opening_float" decimal(12,2) DEFAULT 0,
+    "expected_cash" decimal(12,2) DEFAULT 0,
+    "counted_cash" decimal(12,2),
+    "by_staff" text,
+    "closed_by" varchar(100),
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_day_closes_shop_id" ON "day_closes" ("shop_id");
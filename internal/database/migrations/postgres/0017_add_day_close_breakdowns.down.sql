@@ -0,0 +1,2 @@
+ALTER TABLE "day_closes" DROP COLUMN "by_category";
+ALTER TABLE "day_closes" DROP COLUMN "discounts";
@@ -0,0 +1,33 @@
+CREATE TABLE "expenses" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "amount" decimal(12,2) NOT NULL,
+    "category" varchar(50) NOT NULL,
+    "note" varchar(255),
+    "spent_at" timestamptz NOT NULL,
+    "recurring_expense_id" bigint,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_expenses_shop_id" ON "expenses" ("shop_id");
+CREATE INDEX IF NOT EXISTS "idx_expenses_spent_at" ON "expenses" ("spent_at");
+CREATE INDEX IF NOT EXISTS "idx_expenses_recurring_expense_id" ON "expenses" ("recurring_expense_id");
+CREATE INDEX IF NOT EXISTS "idx_expenses_deleted_at" ON "expenses" ("deleted_at");
+
+CREATE TABLE "recurring_expenses" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "amount" decimal(12,2) NOT NULL,
+    "category" varchar(50) NOT NULL,
+    "note" varchar(255),
+    "frequency" varchar(10) NOT NULL,
+    "next_due_at" timestamptz NOT NULL,
+    "is_active" boolean DEFAULT true,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_recurring_expenses_shop_id" ON "recurring_expenses" ("shop_id");
+CREATE INDEX IF NOT EXISTS "idx_recurring_expenses_next_due_at" ON "recurring_expenses" ("next_due_at");
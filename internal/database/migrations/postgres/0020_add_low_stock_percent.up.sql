@@ -0,0 +1,2 @@
+ALTER TABLE "products" ADD COLUMN "low_stock_percent" bigint DEFAULT 0;
+ALTER TABLE "products" ADD COLUMN "max_stock" bigint DEFAULT 0;
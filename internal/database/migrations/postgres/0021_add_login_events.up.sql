@@ -0,0 +1,15 @@
+CREATE TABLE "login_events" (
+    "id" bigserial,
+    "account_id" bigint,
+    "shop_id" bigint NOT NULL,
+    "ip_address" varchar(45),
+    "user_agent" varchar(255),
+    "country" varchar(2),
+    "method" varchar(20) NOT NULL,
+    "success" boolean NOT NULL DEFAULT false,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_login_events_account_id" ON "login_events" ("account_id");
+CREATE INDEX IF NOT EXISTS "idx_login_events_shop_id" ON "login_events" ("shop_id");
+CREATE INDEX IF NOT EXISTS "idx_login_events_created_at" ON "login_events" ("created_at");
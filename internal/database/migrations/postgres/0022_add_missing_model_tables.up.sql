@@ -0,0 +1,108 @@
+-- Tables for models the push, loyalty reward, scheduled report, staff role
+-- and white-label handlers use, which no migration created.
+
+CREATE TABLE "devices" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "shop_id" bigint,
+    "device_token" varchar(255) NOT NULL,
+    "platform" varchar(20) NOT NULL,
+    "is_active" boolean DEFAULT true,
+    "last_active_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_devices_user" FOREIGN KEY ("user_id") REFERENCES "accounts"("id"),
+    CONSTRAINT "fk_devices_shop" FOREIGN KEY ("shop_id") REFERENCES "shops"("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_devices_device_token" ON "devices" ("device_token");
+CREATE INDEX IF NOT EXISTS "idx_devices_shop_id" ON "devices" ("shop_id");
+CREATE INDEX IF NOT EXISTS "idx_devices_user_id" ON "devices" ("user_id");
+
+CREATE TABLE "loyalty_rewards" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "name" varchar(100) NOT NULL,
+    "description" varchar(255),
+    "points_cost" bigint NOT NULL,
+    "discount_type" varchar(20),
+    "discount_value" decimal,
+    "min_tier" varchar(20) DEFAULT 'bronze',
+    "max_redeem" bigint DEFAULT 0,
+    "redeemed_count" bigint DEFAULT 0,
+    "valid_from" timestamptz,
+    "valid_until" timestamptz,
+    "is_active" boolean DEFAULT true,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_loyalty_rewards_shop_id" ON "loyalty_rewards" ("shop_id");
+
+CREATE TABLE "loyalty_redemptions" (
+    "id" bigserial,
+    "customer_id" bigint NOT NULL,
+    "shop_id" bigint NOT NULL,
+    "reward_id" bigint NOT NULL,
+    "sale_id" bigint,
+    "points_used" bigint NOT NULL,
+    "discount_amount" decimal(12,2),
+    "status" varchar(20) DEFAULT 'pending',
+    "redeemed_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_loyalty_redemptions_customer_id" ON "loyalty_redemptions" ("customer_id");
+CREATE INDEX IF NOT EXISTS "idx_loyalty_redemptions_shop_id" ON "loyalty_redemptions" ("shop_id");
+CREATE INDEX IF NOT EXISTS "idx_loyalty_redemptions_reward_id" ON "loyalty_redemptions" ("reward_id");
+CREATE INDEX IF NOT EXISTS "idx_loyalty_redemptions_sale_id" ON "loyalty_redemptions" ("sale_id");
+
+CREATE TABLE "scheduled_reports" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "type" varchar(20) NOT NULL,
+    "frequency" varchar(20) NOT NULL,
+    "time" varchar(5) NOT NULL,
+    "enabled" boolean DEFAULT true,
+    "recipients" text,
+    "last_sent" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_scheduled_reports_deleted_at" ON "scheduled_reports" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_scheduled_reports_shop_id" ON "scheduled_reports" ("shop_id");
+
+CREATE TABLE "staff_roles" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "name" varchar(50) NOT NULL,
+    "permissions" text,
+    "description" varchar(200),
+    "is_default" boolean DEFAULT false,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_staff_roles_deleted_at" ON "staff_roles" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_staff_roles_shop_id" ON "staff_roles" ("shop_id");
+
+CREATE TABLE "white_label_configs" (
+    "id" bigserial,
+    "shop_id" bigint NOT NULL,
+    "brand_name" varchar(100),
+    "brand_color" varchar(7),
+    "logo_url" varchar(255),
+    "custom_css" text,
+    "custom_domain" varchar(255),
+    "whats_app_number" varchar(20),
+    "enabled" boolean DEFAULT false,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_white_label_configs_deleted_at" ON "white_label_configs" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_white_label_configs_shop_id" ON "white_label_configs" ("shop_id");
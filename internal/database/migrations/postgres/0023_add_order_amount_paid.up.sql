@@ -0,0 +1 @@
+ALTER TABLE "orders" ADD COLUMN "amount_paid" decimal(12,2) DEFAULT 0;
@@ -0,0 +1 @@
+-- The account numbers overwritten by the up migration are still in account_reference
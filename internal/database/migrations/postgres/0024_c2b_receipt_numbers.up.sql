@@ -0,0 +1,2 @@
+-- Paybill payments stored the account number as the receipt
+UPDATE "mpesa_transactions" SET "receipt_number" = "transaction_id" WHERE "type" = 'c2b';
@@ -0,0 +1 @@
+ALTER TABLE "shops" ADD COLUMN "tax_pin" varchar(20);
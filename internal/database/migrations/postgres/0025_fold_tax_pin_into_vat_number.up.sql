@@ -0,0 +1,2 @@
+UPDATE "shops" SET "vat_number" = "tax_pin" WHERE COALESCE("vat_number", '') = '' AND COALESCE("tax_pin", '') <> '';
+ALTER TABLE "shops" DROP COLUMN "tax_pin";
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS "idx_day_closes_shop_number";
+CREATE INDEX IF NOT EXISTS "idx_day_closes_shop_id" ON "day_closes" ("shop_id");
+ALTER TABLE "day_closes" DROP COLUMN "cash_expenses";
+ALTER TABLE "day_closes" DROP COLUMN "expenses";
+ALTER TABLE "recurring_expenses" DROP COLUMN "payment_method";
+ALTER TABLE "expenses" DROP COLUMN "payment_method";
@@ -0,0 +1,13 @@
+-- Categories used to be registered with an inactive placeholder product
+-- named __category_<name>; they are category rows now, so the placeholders
+-- are removed
+INSERT INTO "categories" ("shop_id", "name", "created_at", "updated_at")
+SELECT DISTINCT p."shop_id", p."category", CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+FROM "products" p
+WHERE substr(p."name", 1, 11) = '__category_' AND p."category" <> '' AND p."deleted_at" IS NULL
+  AND NOT EXISTS (
+    SELECT 1 FROM "categories" c
+    WHERE c."shop_id" = p."shop_id" AND c."name" = p."category"
+  );
+UPDATE "products" SET "deleted_at" = CURRENT_TIMESTAMP
+WHERE substr("name", 1, 11) = '__category_' AND "is_active" = false AND "deleted_at" IS NULL;
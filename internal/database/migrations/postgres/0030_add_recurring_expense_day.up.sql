@@ -0,0 +1,4 @@
+ALTER TABLE "recurring_expenses" ADD COLUMN "day_of_month" bigint DEFAULT 0;
+-- Monthly expenses keep the day they are next due on
+UPDATE "recurring_expenses" SET "day_of_month" = EXTRACT(DAY FROM "next_due_at")
+WHERE "frequency" = 'monthly';
@@ -0,0 +1,765 @@
+-- Schema as of the switch to versioned migrations. Databases created
+-- before then are baselined at this version instead of running it.
+
+CREATE TABLE `accounts` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `email` text NOT NULL,
+    `password_hash` text NOT NULL,
+    `name` text NOT NULL,
+    `phone` text NOT NULL,
+    `is_active` numeric DEFAULT true,
+    `is_verified` numeric DEFAULT false,
+    `is_admin` numeric DEFAULT false,
+    `plan` text DEFAULT 'free',
+    `failed_login_attempts` integer DEFAULT 0,
+    `locked_until` datetime,
+    `last_failed_login` datetime,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime
+);
+CREATE INDEX `idx_accounts_deleted_at` ON `accounts`(`deleted_at`);
+CREATE UNIQUE INDEX `idx_accounts_phone` ON `accounts`(`phone`);
+CREATE UNIQUE INDEX `idx_accounts_email` ON `accounts`(`email`);
+
+CREATE TABLE `shops` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `account_id` integer NOT NULL,
+    `name` text NOT NULL,
+    `phone` text NOT NULL,
+    `owner_name` text,
+    `address` text,
+    `plan` text DEFAULT 'free',
+    `mpesa_shortcode` text,
+    `mpesa_partner_id` text,
+    `is_active` numeric DEFAULT true,
+    `language` text DEFAULT 'en',
+    `feature_phone` numeric DEFAULT false,
+    `rounding` text DEFAULT 'none',
+    `auto_deactivate_zero_stock` numeric DEFAULT false,
+    `sms_receipts` numeric DEFAULT false,
+    `low_stock_channel` text,
+    `birthday_bonus_points` integer DEFAULT 0,
+    `email` text,
+    `password_hash` text,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    `brand_name` text,
+    `brand_logo` text,
+    `brand_primary_color` text,
+    `brand_secondary_color` text,
+    `brand_accent_color` text,
+    `brand_font` text,
+    `custom_domain` text,
+    `custom_subdomain` text,
+    `invoice_footer` text,
+    `receipt_header` text,
+    `receipt_footer` text,
+    `business_hours` text,
+    `closed_message` text,
+    CONSTRAINT `fk_accounts_shops` FOREIGN KEY (`account_id`) REFERENCES `accounts`(`id`)
+);
+CREATE INDEX `idx_shops_deleted_at` ON `shops`(`deleted_at`);
+CREATE UNIQUE INDEX `idx_shops_phone` ON `shops`(`phone`);
+CREATE INDEX `idx_shops_account_id` ON `shops`(`account_id`);
+
+CREATE TABLE `products` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `name` text NOT NULL,
+    `category` text,
+    `unit` text DEFAULT 'pcs',
+    `cost_price` decimal(12,2) DEFAULT 0,
+    `selling_price` decimal(12,2) NOT NULL,
+    `currency` text DEFAULT 'KES',
+    `alt_currency` text,
+    `alt_price` decimal(12,2),
+    `current_stock` integer DEFAULT 0,
+    `low_stock_threshold` integer DEFAULT 10,
+    `barcode` text,
+    `image_url` text,
+    `is_active` numeric DEFAULT true,
+    `is_bundle` numeric DEFAULT false,
+    `auto_deactivated` numeric DEFAULT false,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    `purchase_unit` text,
+    `units_per_purchase` integer DEFAULT 1,
+    CONSTRAINT `fk_shops_products` FOREIGN KEY (`shop_id`) REFERENCES `shops`(`id`)
+);
+CREATE INDEX `idx_products_deleted_at` ON `products`(`deleted_at`);
+CREATE INDEX `idx_products_name` ON `products`(`name`);
+CREATE INDEX `idx_products_shop_id` ON `products`(`shop_id`);
+
+CREATE TABLE `categories` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `name` text NOT NULL,
+    `parent_category_id` integer,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime
+);
+CREATE INDEX `idx_categories_deleted_at` ON `categories`(`deleted_at`);
+CREATE INDEX `idx_categories_parent_category_id` ON `categories`(`parent_category_id`);
+CREATE UNIQUE INDEX `idx_category_shop_name` ON `categories`(`shop_id`,`name`);
+
+CREATE TABLE `product_bundles` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `bundle_product_id` integer NOT NULL,
+    `component_product_id` integer NOT NULL,
+    `quantity` integer NOT NULL DEFAULT 1,
+    `created_at` datetime,
+    `updated_at` datetime,
+    CONSTRAINT `fk_product_bundles_component` FOREIGN KEY (`component_product_id`) REFERENCES `products`(`id`)
+);
+CREATE UNIQUE INDEX `idx_bundle_component` ON `product_bundles`(`bundle_product_id`,`component_product_id`);
+
+CREATE TABLE `price_histories` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `product_id` integer NOT NULL,
+    `old_price` decimal(12,2) NOT NULL,
+    `new_price` decimal(12,2) NOT NULL,
+    `source` text NOT NULL,
+    `created_at` datetime,
+    `old_cost_price` decimal(12,2) NOT NULL DEFAULT 0,
+    `new_cost_price` decimal(12,2) NOT NULL DEFAULT 0,
+    `changed_by_type` text,
+    `changed_by_id` integer
+);
+CREATE INDEX `idx_price_histories_created_at` ON `price_histories`(`created_at`);
+CREATE INDEX `idx_price_histories_product_id` ON `price_histories`(`product_id`);
+CREATE INDEX `idx_price_histories_shop_id` ON `price_histories`(`shop_id`);
+
+CREATE TABLE `staffs` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `name` text NOT NULL,
+    `phone` text NOT NULL,
+    `role` text DEFAULT 'staff',
+    `pin` text,
+    `is_active` numeric DEFAULT true,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    CONSTRAINT `fk_shops_staff` FOREIGN KEY (`shop_id`) REFERENCES `shops`(`id`)
+);
+CREATE INDEX `idx_staffs_deleted_at` ON `staffs`(`deleted_at`);
+CREATE INDEX `idx_staffs_shop_id` ON `staffs`(`shop_id`);
+
+CREATE TABLE `customers` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `name` text NOT NULL,
+    `phone` text,
+    `email` text,
+    `address` text,
+    `date_of_birth` datetime,
+    `loyalty_points` integer DEFAULT 0,
+    `points_earned` integer DEFAULT 0,
+    `points_redeemed` integer DEFAULT 0,
+    `total_spent` real DEFAULT 0,
+    `tier` text DEFAULT 'bronze',
+    `total_purchases` integer DEFAULT 0,
+    `last_purchase_at` datetime,
+    `referral_code` text,
+    `referred_by` integer,
+    `notes` text,
+    `is_active` numeric DEFAULT true,
+    `email_verified` numeric DEFAULT false,
+    `phone_verified` numeric DEFAULT false,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    `sms_opt_out` numeric DEFAULT false,
+    CONSTRAINT `fk_customers_shop` FOREIGN KEY (`shop_id`) REFERENCES `shops`(`id`)
+);
+CREATE INDEX `idx_customers_deleted_at` ON `customers`(`deleted_at`);
+CREATE UNIQUE INDEX `idx_customers_referral_code` ON `customers`(`referral_code`);
+CREATE INDEX `idx_customers_phone` ON `customers`(`phone`);
+CREATE INDEX `idx_customers_shop_id` ON `customers`(`shop_id`);
+
+CREATE TABLE `sales` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `product_id` integer NOT NULL,
+    `customer_id` integer,
+    `quantity` integer NOT NULL,
+    `unit_price` decimal(12,2) NOT NULL,
+    `total_amount` decimal(12,2) NOT NULL,
+    `cost_amount` decimal(12,2) DEFAULT 0,
+    `profit` decimal(12,2) DEFAULT 0,
+    `payment_method` text DEFAULT 'cash',
+    `rounding_adjustment` decimal(12,2) DEFAULT 0,
+    `mpesa_receipt` text,
+    `mpesa_phone` text,
+    `pending_sale_id` integer,
+    `staff_id` integer,
+    `notes` text,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    CONSTRAINT `fk_shops_sales` FOREIGN KEY (`shop_id`) REFERENCES `shops`(`id`),
+    CONSTRAINT `fk_sales_staff` FOREIGN KEY (`staff_id`) REFERENCES `staffs`(`id`),
+    CONSTRAINT `fk_sales_customer` FOREIGN KEY (`customer_id`) REFERENCES `customers`(`id`),
+    CONSTRAINT `fk_products_sales` FOREIGN KEY (`product_id`) REFERENCES `products`(`id`)
+);
+CREATE INDEX `idx_sales_shop_id` ON `sales`(`shop_id`);
+CREATE INDEX `idx_sales_deleted_at` ON `sales`(`deleted_at`);
+CREATE INDEX `idx_sales_pending_sale_id` ON `sales`(`pending_sale_id`);
+CREATE INDEX `idx_sales_customer_id` ON `sales`(`customer_id`);
+CREATE INDEX `idx_sales_product_id` ON `sales`(`product_id`);
+
+CREATE TABLE `daily_summaries` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `date` date NOT NULL,
+    `total_sales` decimal(12,2) DEFAULT 0,
+    `total_transactions` integer DEFAULT 0,
+    `total_profit` decimal(12,2) DEFAULT 0,
+    `total_cost` decimal(12,2) DEFAULT 0,
+    `created_at` datetime,
+    `updated_at` datetime,
+    CONSTRAINT `fk_daily_summaries_shop` FOREIGN KEY (`shop_id`) REFERENCES `shops`(`id`)
+);
+CREATE INDEX `idx_daily_summaries_date` ON `daily_summaries`(`date`);
+CREATE INDEX `idx_daily_summaries_shop_id` ON `daily_summaries`(`shop_id`);
+
+CREATE TABLE `suppliers` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `name` text NOT NULL,
+    `phone` text,
+    `email` text,
+    `address` text,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    CONSTRAINT `fk_suppliers_shop` FOREIGN KEY (`shop_id`) REFERENCES `shops`(`id`)
+);
+CREATE INDEX `idx_suppliers_deleted_at` ON `suppliers`(`deleted_at`);
+CREATE INDEX `idx_suppliers_shop_id` ON `suppliers`(`shop_id`);
+
+CREATE TABLE `orders` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `supplier_id` integer NOT NULL,
+    `status` text DEFAULT 'pending',
+    `total_amount` decimal(12,2),
+    `notes` text,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    `payment_status` text DEFAULT 'unpaid',
+    `b2_c_payout_id` integer,
+    CONSTRAINT `fk_orders_supplier` FOREIGN KEY (`supplier_id`) REFERENCES `suppliers`(`id`),
+    CONSTRAINT `fk_orders_shop` FOREIGN KEY (`shop_id`) REFERENCES `shops`(`id`)
+);
+CREATE INDEX `idx_orders_supplier_id` ON `orders`(`supplier_id`);
+CREATE INDEX `idx_orders_shop_id` ON `orders`(`shop_id`);
+CREATE INDEX `idx_orders_b2_c_payout_id` ON `orders`(`b2_c_payout_id`);
+CREATE INDEX `idx_orders_deleted_at` ON `orders`(`deleted_at`);
+
+CREATE TABLE `order_items` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `order_id` integer NOT NULL,
+    `product_id` integer NOT NULL,
+    `quantity` integer NOT NULL,
+    `unit_cost` decimal(12,2),
+    `total_cost` decimal(12,2),
+    CONSTRAINT `fk_orders_items` FOREIGN KEY (`order_id`) REFERENCES `orders`(`id`),
+    CONSTRAINT `fk_order_items_product` FOREIGN KEY (`product_id`) REFERENCES `products`(`id`)
+);
+CREATE INDEX `idx_order_items_product_id` ON `order_items`(`product_id`);
+CREATE INDEX `idx_order_items_order_id` ON `order_items`(`order_id`);
+
+CREATE TABLE `audit_logs` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer,
+    `user_type` text,
+    `user_id` integer,
+    `action` text NOT NULL,
+    `entity_type` text,
+    `entity_id` integer,
+    `details` text,
+    `ip_address` text,
+    `created_at` datetime
+);
+CREATE INDEX `idx_audit_logs_user_id` ON `audit_logs`(`user_id`);
+CREATE INDEX `idx_audit_logs_shop_id` ON `audit_logs`(`shop_id`);
+
+CREATE TABLE `webhooks` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `name` text NOT NULL,
+    `url` text NOT NULL,
+    `events` text,
+    `secret` text,
+    `is_active` numeric DEFAULT true,
+    `created_at` datetime,
+    `updated_at` datetime,
+    CONSTRAINT `fk_webhooks_shop` FOREIGN KEY (`shop_id`) REFERENCES `shops`(`id`)
+);
+CREATE INDEX `idx_webhooks_shop_id` ON `webhooks`(`shop_id`);
+
+CREATE TABLE `webhook_deliveries` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `webhook_id` integer,
+    `event_id` integer,
+    `event` text,
+    `webhook_url` text,
+    `status` text DEFAULT 'pending',
+    `http_status` integer,
+    `response_body` text,
+    `error` text,
+    `attempt` integer DEFAULT 0,
+    `delivered_at` datetime,
+    `created_at` datetime
+);
+CREATE INDEX `idx_webhook_deliveries_event_id` ON `webhook_deliveries`(`event_id`);
+CREATE INDEX `idx_webhook_deliveries_webhook_id` ON `webhook_deliveries`(`webhook_id`);
+
+CREATE TABLE `api_keys` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `name` text NOT NULL,
+    `key` text NOT NULL,
+    `secret_hash` text NOT NULL,
+    `permissions` text,
+    `rate_limit` integer DEFAULT 60,
+    `is_active` numeric DEFAULT true,
+    `last_used_at` datetime,
+    `expires_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime,
+    CONSTRAINT `fk_api_keys_shop` FOREIGN KEY (`shop_id`) REFERENCES `shops`(`id`)
+);
+CREATE UNIQUE INDEX `idx_api_keys_key` ON `api_keys`(`key`);
+CREATE INDEX `idx_api_keys_shop_id` ON `api_keys`(`shop_id`);
+
+CREATE TABLE `loyalty_transactions` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `customer_id` integer NOT NULL,
+    `shop_id` integer NOT NULL,
+    `sale_id` integer,
+    `type` text NOT NULL,
+    `points` integer NOT NULL,
+    `points_before` integer,
+    `points_after` integer,
+    `amount` decimal(12,2),
+    `description` text,
+    `reference` text,
+    `expires_at` datetime,
+    `redeemed_at` datetime,
+    `created_at` datetime,
+    CONSTRAINT `fk_loyalty_transactions_shop` FOREIGN KEY (`shop_id`) REFERENCES `shops`(`id`),
+    CONSTRAINT `fk_customers_transactions` FOREIGN KEY (`customer_id`) REFERENCES `customers`(`id`)
+);
+CREATE INDEX `idx_loyalty_transactions_reference` ON `loyalty_transactions`(`reference`);
+CREATE INDEX `idx_loyalty_transactions_sale_id` ON `loyalty_transactions`(`sale_id`);
+CREATE INDEX `idx_loyalty_transactions_shop_id` ON `loyalty_transactions`(`shop_id`);
+CREATE INDEX `idx_loyalty_transactions_customer_id` ON `loyalty_transactions`(`customer_id`);
+
+CREATE TABLE `integration_credentials` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `provider` text NOT NULL,
+    `identifier` text,
+    `secrets` text,
+    `is_active` numeric DEFAULT true,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime
+);
+CREATE UNIQUE INDEX `idx_integration_shop_provider` ON `integration_credentials`(`shop_id`,`provider`);
+CREATE INDEX `idx_integration_credentials_deleted_at` ON `integration_credentials`(`deleted_at`);
+CREATE INDEX `idx_integration_credentials_identifier` ON `integration_credentials`(`identifier`);
+
+CREATE TABLE `mpesa_b2c_payouts` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `phone` text,
+    `amount` decimal(12,2) NOT NULL,
+    `command_id` text,
+    `remarks` text,
+    `occasion` text,
+    `conversation_id` text,
+    `originator_conversation_id` text,
+    `status` text DEFAULT 'pending',
+    `result_code` integer,
+    `result_desc` text,
+    `receipt_number` text,
+    `receiver_name` text,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `completed_at` datetime,
+    `currency` text,
+    `foreign_amount` decimal(12,2),
+    `exchange_rate` decimal(12,4),
+    `order_id` integer
+);
+CREATE INDEX `idx_mpesa_b2c_payouts_order_id` ON `mpesa_b2c_payouts`(`order_id`);
+CREATE INDEX `idx_mpesa_b2c_payouts_status` ON `mpesa_b2c_payouts`(`status`);
+CREATE INDEX `idx_mpesa_b2c_payouts_originator_conversation_id` ON `mpesa_b2c_payouts`(`originator_conversation_id`);
+CREATE INDEX `idx_mpesa_b2c_payouts_conversation_id` ON `mpesa_b2c_payouts`(`conversation_id`);
+CREATE INDEX `idx_mpesa_b2c_payouts_phone` ON `mpesa_b2c_payouts`(`phone`);
+CREATE INDEX `idx_mpesa_b2c_payouts_shop_id` ON `mpesa_b2c_payouts`(`shop_id`);
+
+CREATE TABLE `mpesa_payments` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `product_id` integer,
+    `amount` decimal(12,2) NOT NULL,
+    `phone` text,
+    `account_reference` text,
+    `description` text,
+    `merchant_request_id` text,
+    `checkout_request_id` text,
+    `mpesa_receipt` text,
+    `mpesa_transaction_id` text,
+    `status` text DEFAULT 'pending',
+    `failure_reason` text,
+    `retry_count` integer DEFAULT 0,
+    `last_attempt_at` datetime,
+    `sale_id` integer,
+    `pending_sale_id` integer,
+    `payment_link_id` integer,
+    `plan` text,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `completed_at` datetime,
+    `expires_at` datetime,
+    `deleted_at` datetime,
+    `status_checks` integer DEFAULT 0,
+    `last_status_check_at` datetime,
+    `amount_paid` decimal(12,2),
+    `payer_phone` text,
+    `amount_mismatch` text,
+    CONSTRAINT `fk_mpesa_payments_shop` FOREIGN KEY (`shop_id`) REFERENCES `shops`(`id`),
+    CONSTRAINT `fk_mpesa_payments_product` FOREIGN KEY (`product_id`) REFERENCES `products`(`id`),
+    CONSTRAINT `fk_mpesa_payments_sale` FOREIGN KEY (`sale_id`) REFERENCES `sales`(`id`)
+);
+CREATE INDEX `idx_mpesa_payments_amount_mismatch` ON `mpesa_payments`(`amount_mismatch`);
+CREATE INDEX `idx_mpesa_payments_deleted_at` ON `mpesa_payments`(`deleted_at`);
+CREATE INDEX `idx_mpesa_payments_payment_link_id` ON `mpesa_payments`(`payment_link_id`);
+CREATE UNIQUE INDEX `idx_mpesa_payments_checkout_request_id` ON `mpesa_payments`(`checkout_request_id`);
+CREATE INDEX `idx_mpesa_payments_product_id` ON `mpesa_payments`(`product_id`);
+CREATE INDEX `idx_mpesa_payments_shop_id` ON `mpesa_payments`(`shop_id`);
+CREATE INDEX `idx_mpesa_payments_pending_sale_id` ON `mpesa_payments`(`pending_sale_id`);
+CREATE INDEX `idx_mpesa_payments_sale_id` ON `mpesa_payments`(`sale_id`);
+CREATE INDEX `idx_mpesa_payments_phone` ON `mpesa_payments`(`phone`);
+
+CREATE TABLE `mpesa_transactions` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `type` text NOT NULL,
+    `amount` decimal(12,2) NOT NULL,
+    `phone` text,
+    `transaction_id` text,
+    `receipt_number` text,
+    `transaction_time` datetime,
+    `status` text,
+    `created_at` datetime,
+    `account_reference` text,
+    `customer_name` text,
+    `sale_id` integer,
+    `status_conversation_id` text,
+    `status_requested_at` datetime,
+    `status_result` text,
+    `status_result_desc` text,
+    `status_checked_at` datetime,
+    `reversal_conversation_id` text,
+    `reversal_status` text,
+    `reversal_reason` text,
+    `reversal_receipt` text,
+    `reversal_result_desc` text,
+    `reversed_at` datetime,
+    CONSTRAINT `fk_mpesa_transactions_shop` FOREIGN KEY (`shop_id`) REFERENCES `shops`(`id`)
+);
+CREATE INDEX `idx_mpesa_transactions_sale_id` ON `mpesa_transactions`(`sale_id`);
+CREATE UNIQUE INDEX `idx_mpesa_transactions_transaction_id` ON `mpesa_transactions`(`transaction_id`);
+CREATE INDEX `idx_mpesa_transactions_shop_id` ON `mpesa_transactions`(`shop_id`);
+CREATE INDEX `idx_mpesa_transactions_reversal_conversation_id` ON `mpesa_transactions`(`reversal_conversation_id`);
+CREATE INDEX `idx_mpesa_transactions_status_conversation_id` ON `mpesa_transactions`(`status_conversation_id`);
+
+CREATE TABLE `mpesa_payment_attempts` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `payment_id` integer NOT NULL,
+    `attempt` integer NOT NULL,
+    `merchant_request_id` text,
+    `checkout_request_id` text,
+    `status` text,
+    `failure_reason` text,
+    `created_at` datetime,
+    `updated_at` datetime,
+    CONSTRAINT `fk_mpesa_payments_attempts` FOREIGN KEY (`payment_id`) REFERENCES `mpesa_payments`(`id`)
+);
+CREATE INDEX `idx_mpesa_payment_attempts_checkout_request_id` ON `mpesa_payment_attempts`(`checkout_request_id`);
+CREATE INDEX `idx_mpesa_payment_attempts_payment_id` ON `mpesa_payment_attempts`(`payment_id`);
+
+CREATE TABLE `invoices` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `account_id` integer NOT NULL,
+    `shop_id` integer,
+    `payment_id` integer,
+    `number` text,
+    `amount` decimal(12,2) NOT NULL,
+    `currency` text DEFAULT 'KES',
+    `plan` text NOT NULL,
+    `period_start` datetime,
+    `period_end` datetime,
+    `paid_at` datetime,
+    `mpesa_receipt` text,
+    `pdf_url` text,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE INDEX `idx_invoices_number` ON `invoices`(`number`);
+CREATE UNIQUE INDEX `idx_invoices_payment_id` ON `invoices`(`payment_id`);
+CREATE INDEX `idx_invoices_shop_id` ON `invoices`(`shop_id`);
+CREATE INDEX `idx_invoices_account_id` ON `invoices`(`account_id`);
+
+CREATE TABLE `pending_sales` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `total_amount` decimal(12,2) NOT NULL,
+    `status` text DEFAULT 'pending',
+    `payment_id` integer,
+    `mpesa_receipt` text,
+    `notes` text,
+    `paid_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE INDEX `idx_pending_sales_shop_id` ON `pending_sales`(`shop_id`);
+CREATE INDEX `idx_pending_sales_payment_id` ON `pending_sales`(`payment_id`);
+CREATE INDEX `idx_pending_sales_status` ON `pending_sales`(`status`);
+
+CREATE TABLE `pending_sale_items` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `pending_sale_id` integer NOT NULL,
+    `product_id` integer NOT NULL,
+    `quantity` integer NOT NULL,
+    `unit_price` decimal(12,2) NOT NULL,
+    CONSTRAINT `fk_pending_sale_items_product` FOREIGN KEY (`product_id`) REFERENCES `products`(`id`),
+    CONSTRAINT `fk_pending_sales_items` FOREIGN KEY (`pending_sale_id`) REFERENCES `pending_sales`(`id`)
+);
+CREATE INDEX `idx_pending_sale_items_pending_sale_id` ON `pending_sale_items`(`pending_sale_id`);
+
+CREATE TABLE `inventory_snapshots` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `date` date NOT NULL,
+    `value` decimal(14,2) DEFAULT 0,
+    `cost_value` decimal(14,2) DEFAULT 0,
+    `units` integer DEFAULT 0,
+    `products` integer DEFAULT 0,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE UNIQUE INDEX `idx_inventory_snapshot_day` ON `inventory_snapshots`(`shop_id`,`date`);
+
+CREATE TABLE `payment_links` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `token` text NOT NULL,
+    `amount` decimal(12,2) DEFAULT 0,
+    `description` text,
+    `status` text DEFAULT 'active',
+    `expires_at` datetime,
+    `payment_id` integer,
+    `phone` text,
+    `mpesa_receipt` text,
+    `paid_amount` decimal(12,2) DEFAULT 0,
+    `paid_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE UNIQUE INDEX `idx_payment_links_token` ON `payment_links`(`token`);
+CREATE INDEX `idx_payment_links_shop_id` ON `payment_links`(`shop_id`);
+CREATE INDEX `idx_payment_links_payment_id` ON `payment_links`(`payment_id`);
+CREATE INDEX `idx_payment_links_status` ON `payment_links`(`status`);
+
+CREATE TABLE `stock_movements` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `product_id` integer NOT NULL,
+    `type` text NOT NULL,
+    `quantity` integer NOT NULL,
+    `balance_after` integer,
+    `reference_id` integer,
+    `note` text,
+    `created_at` datetime
+);
+CREATE INDEX `idx_stock_movements_reference_id` ON `stock_movements`(`reference_id`);
+CREATE INDEX `idx_stock_movements_type` ON `stock_movements`(`type`);
+CREATE INDEX `idx_stock_movement_product` ON `stock_movements`(`product_id`,`created_at`);
+CREATE INDEX `idx_stock_movements_shop_id` ON `stock_movements`(`shop_id`);
+
+CREATE TABLE `sms_messages` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer,
+    `campaign_id` integer,
+    `recipient` text,
+    `body` text,
+    `purpose` text,
+    `status` text,
+    `provider_id` text,
+    `cost` decimal(10,4) DEFAULT 0,
+    `currency` text,
+    `failure_reason` text,
+    `delivered_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE INDEX `idx_sms_messages_created_at` ON `sms_messages`(`created_at`);
+CREATE INDEX `idx_sms_messages_provider_id` ON `sms_messages`(`provider_id`);
+CREATE INDEX `idx_sms_messages_status` ON `sms_messages`(`status`);
+CREATE INDEX `idx_sms_messages_recipient` ON `sms_messages`(`recipient`);
+CREATE INDEX `idx_sms_messages_campaign_id` ON `sms_messages`(`campaign_id`);
+CREATE INDEX `idx_sms_messages_shop_id` ON `sms_messages`(`shop_id`);
+
+CREATE TABLE `sms_campaigns` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `template` text NOT NULL,
+    `status` text DEFAULT 'sending',
+    `tier` text,
+    `min_points` integer,
+    `last_purchase_before` datetime,
+    `last_purchase_after` datetime,
+    `recipients` integer,
+    `opted_out` integer,
+    `completed_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE INDEX `idx_sms_campaigns_shop_id` ON `sms_campaigns`(`shop_id`);
+
+CREATE TABLE `supplier_products` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `supplier_id` integer NOT NULL,
+    `product_id` integer NOT NULL,
+    `unit_cost` decimal(12,2) DEFAULT 0,
+    `min_order_qty` integer DEFAULT 1,
+    `created_at` datetime,
+    `updated_at` datetime,
+    CONSTRAINT `fk_supplier_products_supplier` FOREIGN KEY (`supplier_id`) REFERENCES `suppliers`(`id`),
+    CONSTRAINT `fk_supplier_products_product` FOREIGN KEY (`product_id`) REFERENCES `products`(`id`)
+);
+CREATE INDEX `idx_supplier_products_shop_id` ON `supplier_products`(`shop_id`);
+CREATE INDEX `idx_supplier_products_product_id` ON `supplier_products`(`product_id`);
+CREATE UNIQUE INDEX `idx_supplier_product` ON `supplier_products`(`supplier_id`,`product_id`);
+
+CREATE TABLE `otp_codes` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `destination` text NOT NULL,
+    `purpose` text NOT NULL,
+    `channel` text,
+    `code_hash` text NOT NULL,
+    `attempts` integer DEFAULT 0,
+    `expires_at` datetime,
+    `used_at` datetime,
+    `created_at` datetime
+);
+CREATE INDEX `idx_otp_destination` ON `otp_codes`(`destination`,`purpose`);
+
+CREATE TABLE `report_email_preferences` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `enabled` numeric DEFAULT false,
+    `frequency` text DEFAULT 'daily',
+    `recipients` text,
+    `last_sent_at` datetime,
+    `disabled_reason` text,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE UNIQUE INDEX `idx_report_email_preferences_shop_id` ON `report_email_preferences`(`shop_id`);
+
+CREATE TABLE `outbox_messages` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `channel` text NOT NULL,
+    `recipient` text NOT NULL,
+    `subject` text,
+    `body` text,
+    `status` text DEFAULT 'pending',
+    `attempts` integer DEFAULT 0,
+    `max_attempts` integer DEFAULT 5,
+    `next_attempt_at` datetime,
+    `last_error` text,
+    `sent_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE INDEX `idx_outbox_messages_next_attempt_at` ON `outbox_messages`(`next_attempt_at`);
+CREATE INDEX `idx_outbox_messages_status` ON `outbox_messages`(`status`);
+CREATE INDEX `idx_outbox_messages_channel` ON `outbox_messages`(`channel`);
+
+CREATE TABLE `stripe_payments` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `payment_intent_id` text NOT NULL,
+    `product_id` integer NOT NULL,
+    `quantity` integer NOT NULL,
+    `amount` decimal(12,2) NOT NULL,
+    `currency` text NOT NULL,
+    `description` text,
+    `status` text DEFAULT 'pending',
+    `failure_reason` text,
+    `sale_id` integer,
+    `completed_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE INDEX `idx_stripe_payments_status` ON `stripe_payments`(`status`);
+CREATE UNIQUE INDEX `idx_stripe_payments_payment_intent_id` ON `stripe_payments`(`payment_intent_id`);
+CREATE INDEX `idx_stripe_payments_shop_id` ON `stripe_payments`(`shop_id`);
+
+CREATE TABLE `closing_stock_snapshots` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `product_id` integer NOT NULL,
+    `product_name` text,
+    `closing_stock` integer NOT NULL,
+    `cost_price` decimal(12,2) DEFAULT 0,
+    `selling_price` decimal(12,2) DEFAULT 0,
+    `snapshot_date` date NOT NULL,
+    `created_at` datetime
+);
+CREATE UNIQUE INDEX `idx_closing_stock_product_day` ON `closing_stock_snapshots`(`shop_id`,`product_id`,`snapshot_date`);
+
+CREATE TABLE `outbound_messages` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `sid` text NOT NULL,
+    `shop_id` integer,
+    `to` text NOT NULL,
+    `type` text,
+    `status` text DEFAULT 'queued',
+    `error_code` text,
+    `error_message` text,
+    `delivered_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE INDEX `idx_outbound_messages_created_at` ON `outbound_messages`(`created_at`);
+CREATE INDEX `idx_outbound_messages_status` ON `outbound_messages`(`status`);
+CREATE INDEX `idx_outbound_messages_type` ON `outbound_messages`(`type`);
+CREATE INDEX `idx_outbound_messages_shop_id` ON `outbound_messages`(`shop_id`);
+CREATE UNIQUE INDEX `idx_outbound_messages_s_id` ON `outbound_messages`(`sid`);
+
+CREATE TABLE `email_templates` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `key` text NOT NULL,
+    `subject` text NOT NULL,
+    `html` text NOT NULL,
+    `text` text,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE UNIQUE INDEX `idx_email_template_shop_key` ON `email_templates`(`shop_id`,`key`);
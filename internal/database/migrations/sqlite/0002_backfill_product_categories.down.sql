@@ -0,0 +1 @@
+-- Categories may have been edited since; they are kept
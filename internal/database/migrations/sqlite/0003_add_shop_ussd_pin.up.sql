@@ -0,0 +1,3 @@
+ALTER TABLE `shops` ADD COLUMN `ussd_pin_hash` text;
+ALTER TABLE `shops` ADD COLUMN `ussd_pin_failures` integer DEFAULT 0;
+ALTER TABLE `shops` ADD COLUMN `ussd_pin_locked_until` datetime;
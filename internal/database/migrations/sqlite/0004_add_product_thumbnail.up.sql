@@ -0,0 +1 @@
+ALTER TABLE `products` ADD COLUMN `thumbnail_url` text;
@@ -0,0 +1,3 @@
+ALTER TABLE `shops` DROP COLUMN `vat_number`;
+ALTER TABLE `shops` DROP COLUMN `receipt_contact`;
+ALTER TABLE `shops` DROP COLUMN `receipt_logo`;
@@ -0,0 +1,3 @@
+ALTER TABLE `shops` ADD COLUMN `receipt_logo` text;
+ALTER TABLE `shops` ADD COLUMN `receipt_contact` text;
+ALTER TABLE `shops` ADD COLUMN `vat_number` text;
@@ -0,0 +1,9 @@
+CREATE TABLE `product_aliases` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `product_id` integer NOT NULL,
+    `alias` text NOT NULL,
+    `created_at` datetime
+);
+CREATE UNIQUE INDEX `idx_product_alias` ON `product_aliases`(`shop_id`,`alias`);
+CREATE INDEX `idx_product_aliases_product_id` ON `product_aliases`(`product_id`);
@@ -0,0 +1,25 @@
+CREATE TABLE `weekly_summaries` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `week_start` date NOT NULL,
+    `total_sales` decimal(12,2) DEFAULT 0,
+    `total_transactions` integer DEFAULT 0,
+    `total_profit` decimal(12,2) DEFAULT 0,
+    `total_cost` decimal(12,2) DEFAULT 0,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE UNIQUE INDEX `idx_weekly_summary` ON `weekly_summaries`(`shop_id`,`week_start`);
+
+CREATE TABLE `monthly_summaries` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `month` date NOT NULL,
+    `total_sales` decimal(12,2) DEFAULT 0,
+    `total_transactions` integer DEFAULT 0,
+    `total_profit` decimal(12,2) DEFAULT 0,
+    `total_cost` decimal(12,2) DEFAULT 0,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE UNIQUE INDEX `idx_monthly_summary` ON `monthly_summaries`(`shop_id`,`month`);
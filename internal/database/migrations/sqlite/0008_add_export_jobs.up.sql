@@ -0,0 +1,24 @@
+CREATE TABLE `export_jobs` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `type` text NOT NULL,
+    `format` text NOT NULL,
+    `period_start` datetime,
+    `period_end` datetime,
+    `payment_method` text,
+    `product_id` integer,
+    `category` text,
+    `status` text DEFAULT 'queued',
+    `error` text,
+    `rows` integer DEFAULT 0,
+    `size` integer DEFAULT 0,
+    `filename` text,
+    `file_path` text,
+    `finished_at` datetime,
+    `expires_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE INDEX `idx_export_jobs_shop_id` ON `export_jobs`(`shop_id`);
+CREATE INDEX `idx_export_jobs_status` ON `export_jobs`(`status`);
+CREATE INDEX `idx_export_jobs_expires_at` ON `export_jobs`(`expires_at`);
@@ -0,0 +1,12 @@
+CREATE TABLE `supplier_ratings` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `supplier_id` integer NOT NULL,
+    `order_id` integer NOT NULL,
+    `rating` integer NOT NULL,
+    `notes` text,
+    `rated_at` datetime
+);
+CREATE INDEX `idx_supplier_ratings_shop_id` ON `supplier_ratings`(`shop_id`);
+CREATE INDEX `idx_supplier_ratings_supplier_id` ON `supplier_ratings`(`supplier_id`);
+CREATE UNIQUE INDEX `idx_supplier_ratings_order_id` ON `supplier_ratings`(`order_id`);
@@ -0,0 +1,5 @@
+CREATE TABLE `feature_flags` (
+    `name` varchar(50) PRIMARY KEY,
+    `enabled` numeric NOT NULL,
+    `updated_at` datetime
+);
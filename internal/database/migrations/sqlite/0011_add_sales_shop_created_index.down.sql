@@ -0,0 +1 @@
+DROP INDEX IF EXISTS `idx_sales_shop_created`;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS `idx_sales_shop_created` ON `sales`(`shop_id`,`created_at`);
@@ -0,0 +1,7 @@
+CREATE TABLE `favorite_products` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `product_id` integer NOT NULL,
+    `created_at` datetime
+);
+CREATE UNIQUE INDEX `idx_favorite_product` ON `favorite_products`(`shop_id`,`product_id`);
@@ -0,0 +1,14 @@
+CREATE TABLE `printer_settings` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `type` text DEFAULT 'thermal',
+    `connection` text DEFAULT 'network',
+    `host` text,
+    `port` integer,
+    `device` text,
+    `paper_width` integer DEFAULT 58,
+    `open_drawer` numeric DEFAULT false,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE UNIQUE INDEX `idx_printer_settings_shop_id` ON `printer_settings`(`shop_id`);
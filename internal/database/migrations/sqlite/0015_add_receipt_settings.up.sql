@@ -0,0 +1,3 @@
+ALTER TABLE `shops` ADD COLUMN `tax_pin` text;
+ALTER TABLE `shops` ADD COLUMN `receipt_show_qr` numeric DEFAULT false;
+ALTER TABLE `shops` ADD COLUMN `receipt_currency` text;
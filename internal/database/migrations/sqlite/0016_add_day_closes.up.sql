@@ -0,0 +1,22 @@
+CREATE TABLE `day_closes` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `number` integer NOT NULL,
+    `business_date` date NOT NULL,
+    `period_start` datetime NOT NULL,
+    `period_end` datetime NOT NULL,
+    `transactions` integer DEFAULT 0,
+    `cash_sales` decimal(12,2) DEFAULT 0,
+    `mpesa_sales` decimal(12,2) DEFAULT 0,
+    `other_sales` decimal(12,2) DEFAULT 0,
+    `gross_sales` decimal(12,2) DEFAULT 0,
+    `refunds` decimal(12,2) DEFAULT 0,
+    `refund_count` integer DEFAULT 0,
+    `opening_float` decimal(12,2) DEFAULT 0,
+    `expected_cash` decimal(12,2) DEFAULT 0,
+    `counted_cash` decimal(12,2),
+    `by_staff` text,
+    `closed_by` text,
+    `created_at` datetime
+);
+CREATE INDEX `idx_day_closes_shop_id` ON `day_closes`(`shop_id`);
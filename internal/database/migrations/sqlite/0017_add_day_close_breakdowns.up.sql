@@ -0,0 +1,2 @@
+ALTER TABLE `day_closes` ADD COLUMN `discounts` decimal(12,2) DEFAULT 0;
+ALTER TABLE `day_closes` ADD COLUMN `by_category` text;
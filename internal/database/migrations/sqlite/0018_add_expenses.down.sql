@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `recurring_expenses`;
+DROP TABLE IF EXISTS `expenses`;
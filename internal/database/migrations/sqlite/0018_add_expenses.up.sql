@@ -0,0 +1,31 @@
+CREATE TABLE `expenses` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `amount` decimal(12,2) NOT NULL,
+    `category` text NOT NULL,
+    `note` text,
+    `spent_at` datetime NOT NULL,
+    `recurring_expense_id` integer,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime
+);
+CREATE INDEX `idx_expenses_shop_id` ON `expenses`(`shop_id`);
+CREATE INDEX `idx_expenses_spent_at` ON `expenses`(`spent_at`);
+CREATE INDEX `idx_expenses_recurring_expense_id` ON `expenses`(`recurring_expense_id`);
+CREATE INDEX `idx_expenses_deleted_at` ON `expenses`(`deleted_at`);
+
+CREATE TABLE `recurring_expenses` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `amount` decimal(12,2) NOT NULL,
+    `category` text NOT NULL,
+    `note` text,
+    `frequency` text NOT NULL,
+    `next_due_at` datetime NOT NULL,
+    `is_active` numeric DEFAULT true,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE INDEX `idx_recurring_expenses_shop_id` ON `recurring_expenses`(`shop_id`);
+CREATE INDEX `idx_recurring_expenses_next_due_at` ON `recurring_expenses`(`next_due_at`);
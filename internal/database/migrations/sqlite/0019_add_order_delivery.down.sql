@@ -0,0 +1,2 @@
+ALTER TABLE `orders` DROP COLUMN `sent_at`;
+ALTER TABLE `orders` DROP COLUMN `expected_delivery`;
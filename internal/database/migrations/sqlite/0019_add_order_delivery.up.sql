@@ -0,0 +1,2 @@
+ALTER TABLE `orders` ADD COLUMN `expected_delivery` date;
+ALTER TABLE `orders` ADD COLUMN `sent_at` datetime;
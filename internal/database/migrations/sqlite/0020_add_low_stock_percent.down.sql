@@ -0,0 +1,2 @@
+ALTER TABLE `products` DROP COLUMN `max_stock`;
+ALTER TABLE `products` DROP COLUMN `low_stock_percent`;
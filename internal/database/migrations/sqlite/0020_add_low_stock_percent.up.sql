@@ -0,0 +1,2 @@
+ALTER TABLE `products` ADD COLUMN `low_stock_percent` integer DEFAULT 0;
+ALTER TABLE `products` ADD COLUMN `max_stock` integer DEFAULT 0;
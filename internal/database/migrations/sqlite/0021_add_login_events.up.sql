@@ -0,0 +1,14 @@
+CREATE TABLE `login_events` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `account_id` integer,
+    `shop_id` integer NOT NULL,
+    `ip_address` text,
+    `user_agent` text,
+    `country` text,
+    `method` text NOT NULL,
+    `success` numeric NOT NULL DEFAULT false,
+    `created_at` datetime
+);
+CREATE INDEX `idx_login_events_account_id` ON `login_events`(`account_id`);
+CREATE INDEX `idx_login_events_shop_id` ON `login_events`(`shop_id`);
+CREATE INDEX `idx_login_events_created_at` ON `login_events`(`created_at`);
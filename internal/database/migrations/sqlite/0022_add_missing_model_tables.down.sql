@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS `white_label_configs`;
+DROP TABLE IF EXISTS `staff_roles`;
+DROP TABLE IF EXISTS `scheduled_reports`;
+DROP TABLE IF EXISTS `loyalty_redemptions`;
+DROP TABLE IF EXISTS `loyalty_rewards`;
+DROP TABLE IF EXISTS `devices`;
@@ -0,0 +1,102 @@
+-- Tables for models the push, loyalty reward, scheduled report, staff role
+-- and white-label handlers use, which no migration created.
+
+CREATE TABLE `devices` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `user_id` integer NOT NULL,
+    `shop_id` integer,
+    `device_token` text NOT NULL,
+    `platform` text NOT NULL,
+    `is_active` numeric DEFAULT true,
+    `last_active_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime,
+    CONSTRAINT `fk_devices_user` FOREIGN KEY (`user_id`) REFERENCES `accounts`(`id`),
+    CONSTRAINT `fk_devices_shop` FOREIGN KEY (`shop_id`) REFERENCES `shops`(`id`)
+);
+CREATE UNIQUE INDEX `idx_devices_device_token` ON `devices`(`device_token`);
+CREATE INDEX `idx_devices_shop_id` ON `devices`(`shop_id`);
+CREATE INDEX `idx_devices_user_id` ON `devices`(`user_id`);
+
+CREATE TABLE `loyalty_rewards` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `name` text NOT NULL,
+    `description` text,
+    `points_cost` integer NOT NULL,
+    `discount_type` text,
+    `discount_value` real,
+    `min_tier` text DEFAULT 'bronze',
+    `max_redeem` integer DEFAULT 0,
+    `redeemed_count` integer DEFAULT 0,
+    `valid_from` datetime,
+    `valid_until` datetime,
+    `is_active` numeric DEFAULT true,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE INDEX `idx_loyalty_rewards_shop_id` ON `loyalty_rewards`(`shop_id`);
+
+CREATE TABLE `loyalty_redemptions` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `customer_id` integer NOT NULL,
+    `shop_id` integer NOT NULL,
+    `reward_id` integer NOT NULL,
+    `sale_id` integer,
+    `points_used` integer NOT NULL,
+    `discount_amount` decimal(12,2),
+    `status` text DEFAULT 'pending',
+    `redeemed_at` datetime,
+    `created_at` datetime
+);
+CREATE INDEX `idx_loyalty_redemptions_customer_id` ON `loyalty_redemptions`(`customer_id`);
+CREATE INDEX `idx_loyalty_redemptions_shop_id` ON `loyalty_redemptions`(`shop_id`);
+CREATE INDEX `idx_loyalty_redemptions_reward_id` ON `loyalty_redemptions`(`reward_id`);
+CREATE INDEX `idx_loyalty_redemptions_sale_id` ON `loyalty_redemptions`(`sale_id`);
+
+CREATE TABLE `scheduled_reports` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `type` text NOT NULL,
+    `frequency` text NOT NULL,
+    `time` text NOT NULL,
+    `enabled` numeric DEFAULT true,
+    `recipients` text,
+    `last_sent` datetime,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime
+);
+CREATE INDEX `idx_scheduled_reports_deleted_at` ON `scheduled_reports`(`deleted_at`);
+CREATE INDEX `idx_scheduled_reports_shop_id` ON `scheduled_reports`(`shop_id`);
+
+CREATE TABLE `staff_roles` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `name` text NOT NULL,
+    `permissions` text,
+    `description` text,
+    `is_default` numeric DEFAULT false,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime
+);
+CREATE INDEX `idx_staff_roles_deleted_at` ON `staff_roles`(`deleted_at`);
+CREATE INDEX `idx_staff_roles_shop_id` ON `staff_roles`(`shop_id`);
+
+CREATE TABLE `white_label_configs` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `shop_id` integer NOT NULL,
+    `brand_name` text,
+    `brand_color` text,
+    `logo_url` text,
+    `custom_css` text,
+    `custom_domain` text,
+    `whats_app_number` text,
+    `enabled` numeric DEFAULT false,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime
+);
+CREATE INDEX `idx_white_label_configs_deleted_at` ON `white_label_configs`(`deleted_at`);
+CREATE UNIQUE INDEX `idx_white_label_configs_shop_id` ON `white_label_configs`(`shop_id`);
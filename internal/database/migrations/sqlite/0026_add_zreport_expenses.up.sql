@@ -0,0 +1,6 @@
+ALTER TABLE `expenses` ADD COLUMN `payment_method` text DEFAULT 'cash';
+ALTER TABLE `recurring_expenses` ADD COLUMN `payment_method` text DEFAULT 'cash';
+ALTER TABLE `day_closes` ADD COLUMN `expenses` decimal(12,2) DEFAULT 0;
+ALTER TABLE `day_closes` ADD COLUMN `cash_expenses` decimal(12,2) DEFAULT 0;
+DROP INDEX IF EXISTS `idx_day_closes_shop_id`;
+CREATE UNIQUE INDEX `idx_day_closes_shop_number` ON `day_closes`(`shop_id`,`number`);
@@ -0,0 +1 @@
+ALTER TABLE `shops` ADD COLUMN `timezone` text;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS `idx_outbox_messages_shop_id`;
+ALTER TABLE `outbox_messages` DROP COLUMN `claimed_at`;
+ALTER TABLE `outbox_messages` DROP COLUMN `shop_id`;
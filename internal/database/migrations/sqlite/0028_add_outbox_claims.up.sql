@@ -0,0 +1,3 @@
+ALTER TABLE `outbox_messages` ADD COLUMN `shop_id` integer;
+ALTER TABLE `outbox_messages` ADD COLUMN `claimed_at` datetime;
+CREATE INDEX `idx_outbox_messages_shop_id` ON `outbox_messages`(`shop_id`);
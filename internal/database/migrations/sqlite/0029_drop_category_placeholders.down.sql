@@ -0,0 +1 @@
+-- The placeholder products are not restored
@@ -0,0 +1,4 @@
+ALTER TABLE `recurring_expenses` ADD COLUMN `day_of_month` integer DEFAULT 0;
+-- Monthly expenses keep the day they are next due on
+UPDATE `recurring_expenses` SET `day_of_month` = CAST(strftime('%d', `next_due_at`) AS integer)
+WHERE `frequency` = 'monthly';
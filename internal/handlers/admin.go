@@ -5,15 +5,24 @@ import (
 
 	"github.com/C9b3rD3vi1/DukaPOS/internal/database"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/features"
 	"github.com/gofiber/fiber/v2"
 )
 
-type AdminHandler struct{}
+type AdminHandler struct {
+	flags *features.Service
+}
 
 func NewAdminHandler() *AdminHandler {
 	return &AdminHandler{}
 }
 
+// SetFeatureFlags sets the flags admins can switch on and off
+func (h *AdminHandler) SetFeatureFlags(flags *features.Service) {
+	h.flags = flags
+}
+
 func (h *AdminHandler) requireAdmin(c *fiber.Ctx) error {
 	account, ok := c.Locals("account").(*models.Account)
 	if !ok || account == nil {
@@ -43,6 +52,7 @@ func (h *AdminHandler) Dashboard(c *fiber.Ctx) error {
 		BusinessAccounts int64
 		TodaySales       int64
 		TodayRevenue     float64
+		FailedMessages   int64 // WhatsApp messages not delivered today
 	}
 
 	now := time.Now()
@@ -58,6 +68,8 @@ func (h *AdminHandler) Dashboard(c *fiber.Ctx) error {
 	db.Model(&models.Sale{}).Select("COALESCE(SUM(total_amount), 0)").Scan(&stats.TotalRevenue)
 	db.Model(&models.Sale{}).Where("created_at >= ?", today).Count(&stats.TodaySales)
 	db.Model(&models.Sale{}).Where("created_at >= ?", today).Select("COALESCE(SUM(total_amount), 0)").Scan(&stats.TodayRevenue)
+	db.Model(&models.OutboundMessage{}).Where("created_at >= ? AND status IN ?", today,
+		[]string{models.MessageStatusFailed, models.MessageStatusUndelivered}).Count(&stats.FailedMessages)
 
 	return c.JSON(stats)
 }
@@ -228,6 +240,88 @@ func (h *AdminHandler) GetShops(c *fiber.Ctx) error {
 	})
 }
 
+// GetOutbox lists queued outbound messages, filtered by ?status= and
+// ?channel=, with a count per status
+// GET /api/v1/admin/outbox
+func (h *AdminHandler) GetOutbox(c *fiber.Ctx) error {
+	if err := h.requireAdmin(c); err != nil {
+		return err
+	}
+
+	repo := repository.NewOutboxRepository(database.GetDB())
+
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 50)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	messages, total, err := repo.List(c.Query("status"), c.Query("channel"), limit, (page-1)*limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to list outbox"})
+	}
+	counts, err := repo.CountByStatus()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to count outbox"})
+	}
+
+	return c.JSON(fiber.Map{
+		"messages": messages,
+		"counts":   counts,
+		"total":    total,
+		"page":     page,
+		"limit":    limit,
+		"pages":    (total + int64(limit) - 1) / int64(limit),
+	})
+}
+
+// GetMessages lists WhatsApp messages sent through Twilio across shops,
+// filtered by ?status= (failed also matches undelivered) and ?type=, with
+// the shops that had the most failures in the last 7 days
+// GET /api/v1/admin/messages
+func (h *AdminHandler) GetMessages(c *fiber.Ctx) error {
+	if err := h.requireAdmin(c); err != nil {
+		return err
+	}
+
+	repo := repository.NewOutboundMessageRepository(database.GetDB())
+
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 50)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	messages, total, err := repo.List(0, c.Query("status"), c.Query("type"), limit, (page-1)*limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to list messages"})
+	}
+	counts, err := repo.CountByStatus(0)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to count messages"})
+	}
+	failing, err := repo.FailingShops(time.Now().AddDate(0, 0, -7), 20)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to list failing shops"})
+	}
+
+	return c.JSON(fiber.Map{
+		"messages":      messages,
+		"counts":        counts,
+		"failing_shops": failing,
+		"total":         total,
+		"page":          page,
+		"limit":         limit,
+		"pages":         (total + int64(limit) - 1) / int64(limit),
+	})
+}
+
 func (h *AdminHandler) GetSystemStats(c *fiber.Ctx) error {
 	if err := h.requireAdmin(c); err != nil {
 		return err
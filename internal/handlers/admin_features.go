@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/features"
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetFeatures lists the feature flags with whether each is on
+func (h *AdminHandler) GetFeatures(c *fiber.Ctx) error {
+	if err := h.requireAdmin(c); err != nil {
+		return err
+	}
+	if h.flags == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Feature flags not configured"})
+	}
+
+	flags, err := h.flags.List()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load feature flags"})
+	}
+	return c.JSON(fiber.Map{"features": flags})
+}
+
+// UpdateFeature switches a feature flag on or off without a restart
+func (h *AdminHandler) UpdateFeature(c *fiber.Ctx) error {
+	if err := h.requireAdmin(c); err != nil {
+		return err
+	}
+	if h.flags == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Feature flags not configured"})
+	}
+
+	var input struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := c.BodyParser(&input); err != nil || input.Enabled == nil {
+		return c.Status(400).JSON(fiber.Map{"error": "enabled is required"})
+	}
+
+	flag, err := h.flags.Set(c.Params("name"), *input.Enabled)
+	if errors.Is(err, features.ErrUnknownFlag) {
+		return c.Status(404).JSON(fiber.Map{"error": "Unknown feature flag"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update feature flag"})
+	}
+	return c.JSON(flag)
+}
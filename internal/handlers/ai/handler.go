@@ -4,6 +4,7 @@ import (
 	"strconv"
 
 	aiservice "github.com/C9b3rD3vi1/DukaPOS/internal/services/ai"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -22,9 +23,7 @@ func (h *Handler) GetPredictions(c *fiber.Ctx) error {
 
 	predictions, err := h.predictionService.GetPredictions(shopID)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to generate predictions",
-		})
+		return utils.SendError(c, 500, utils.CodeInternal, "Failed to generate predictions")
 	}
 
 	return c.JSON(predictions)
@@ -35,9 +34,7 @@ func (h *Handler) GetRestockRecommendations(c *fiber.Ctx) error {
 
 	recommendations, err := h.predictionService.GetRestockRecommendations(shopID)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to get recommendations",
-		})
+		return utils.SendError(c, 500, utils.CodeInternal, "Failed to get recommendations")
 	}
 
 	return c.JSON(fiber.Map{
@@ -60,9 +57,7 @@ func (h *Handler) GetSalesAnalytics(c *fiber.Ctx) error {
 
 	analytics, err := h.predictionService.GetSalesAnalytics(shopID, days)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to get analytics",
-		})
+		return utils.SendError(c, 500, utils.CodeInternal, "Failed to get analytics")
 	}
 
 	return c.JSON(analytics)
@@ -73,9 +68,7 @@ func (h *Handler) GetInventoryValue(c *fiber.Ctx) error {
 
 	value, err := h.predictionService.GetInventoryValue(shopID)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to get inventory value",
-		})
+		return utils.SendError(c, 500, utils.CodeInternal, "Failed to get inventory value")
 	}
 
 	return c.JSON(fiber.Map{
@@ -94,15 +87,11 @@ func (h *Handler) GenerateForecast(c *fiber.Ctx) error {
 	}
 
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+		return utils.SendError(c, 400, utils.CodeInvalidRequest, "Invalid request body")
 	}
 
 	if req.ProductID == 0 {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Product ID is required",
-		})
+		return utils.SendError(c, 400, utils.CodeValidationError, "Product ID is required")
 	}
 
 	forecast, err := h.predictionService.GenerateForecast(
@@ -118,6 +107,7 @@ func (h *Handler) GenerateForecast(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error":   "Failed to generate forecast",
+			"code":    utils.CodeInternal,
 			"details": err.Error(),
 		})
 	}
@@ -125,14 +115,19 @@ func (h *Handler) GenerateForecast(c *fiber.Ctx) error {
 	return c.JSON(forecast)
 }
 
+// GetTrends groups products by sales trend and lists the price changes of
+// the last ?days=N (default 30)
 func (h *Handler) GetTrends(c *fiber.Ctx) error {
 	shopID := c.Locals("shop_id").(uint)
 
 	predictions, err := h.predictionService.GetPredictions(shopID)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to get trends",
-		})
+		return utils.SendError(c, 500, utils.CodeInternal, "Failed to get trends")
+	}
+
+	priceChanges, err := h.predictionService.GetPriceChanges(shopID, reportDays(c))
+	if err != nil {
+		return utils.SendError(c, 500, utils.CodeInternal, "Failed to get trends")
 	}
 
 	trendingUp := []string{}
@@ -154,5 +149,78 @@ func (h *Handler) GetTrends(c *fiber.Ctx) error {
 		"trending_up":   trendingUp,
 		"trending_down": trendingDown,
 		"stable":        stable,
+		"price_changes": priceChanges,
 	})
 }
+
+// GetDeadStock lists products with no sales in the last ?days=N (default 30)
+// GET /api/v1/ai/dead-stock/:shop_id
+func (h *Handler) GetDeadStock(c *fiber.Ctx) error {
+	shopID, err := h.paramShopID(c)
+	if err != nil {
+		return err
+	}
+
+	report, err := h.predictionService.GetDeadStock(shopID, reportDays(c))
+	if err != nil {
+		return utils.SendError(c, 500, utils.CodeInternal, "Failed to get dead stock")
+	}
+
+	return c.JSON(report)
+}
+
+// GetTurnover returns the inventory turnover ratio per product. The 30-day
+// slow/fast thresholds can be overridden with ?slow_below= and ?fast_above=.
+// GET /api/v1/ai/turnover/:shop_id
+func (h *Handler) GetTurnover(c *fiber.Ctx) error {
+	shopID, err := h.paramShopID(c)
+	if err != nil {
+		return err
+	}
+
+	thresholds := h.predictionService.TurnoverThresholds()
+	if v, err := strconv.ParseFloat(c.Query("slow_below"), 64); err == nil && v >= 0 {
+		thresholds.SlowBelow = v
+	}
+	if v, err := strconv.ParseFloat(c.Query("fast_above"), 64); err == nil && v >= 0 {
+		thresholds.FastAbove = v
+	}
+	if thresholds.FastAbove < thresholds.SlowBelow {
+		return utils.SendError(c, 400, utils.CodeValidationError, "fast_above must not be less than slow_below")
+	}
+
+	report, err := h.predictionService.GetInventoryTurnover(shopID, reportDays(c), thresholds)
+	if err != nil {
+		return utils.SendError(c, 500, utils.CodeInternal, "Failed to get inventory turnover")
+	}
+
+	return c.JSON(report)
+}
+
+// paramShopID returns the :shop_id route parameter, which must be the caller's
+// own shop. Errors are UserErrors for the global error handler to render.
+func (h *Handler) paramShopID(c *fiber.Ctx) (uint, error) {
+	shopID := c.Locals("shop_id").(uint)
+
+	paramID, err := c.ParamsInt("shop_id")
+	if err != nil || paramID <= 0 {
+		return 0, utils.NewUserError(utils.CodeInvalidRequest, "Invalid shop ID")
+	}
+	if uint(paramID) != shopID {
+		return 0, utils.NewUserError(utils.CodeForbidden, "Access denied")
+	}
+
+	return shopID, nil
+}
+
+// reportDays reads ?days=N, defaulting to 30 and capped at a year
+func reportDays(c *fiber.Ctx) int {
+	days, _ := strconv.Atoi(c.Query("days", "30"))
+	if days < 1 {
+		days = 30
+	}
+	if days > 365 {
+		days = 365
+	}
+	return days
+}
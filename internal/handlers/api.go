@@ -1,12 +1,21 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/services/cache"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/export"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/storage"
+	webhooksvc "github.com/C9b3rD3vi1/DukaPOS/internal/services/webhook"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/websocket"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/zreport"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -52,9 +61,7 @@ func (h *ShopHandler) GetProfile(c *fiber.Ctx) error {
 
 	shop, err := h.shopRepo.GetByID(shopID)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Shop not found",
-		})
+		return utils.SendError(c, fiber.StatusNotFound, utils.CodeShopNotFound, "Shop not found")
 	}
 
 	return c.JSON(shop)
@@ -63,31 +70,23 @@ func (h *ShopHandler) GetProfile(c *fiber.Ctx) error {
 // GetAccount returns the account with all shops
 func (h *ShopHandler) GetAccount(c *fiber.Ctx) error {
 	if h.accountRepo == nil {
-		return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{
-			"error": "Account feature not available",
-		})
+		return utils.SendError(c, fiber.StatusNotImplemented, utils.CodeServiceUnavailable, "Account feature not available")
 	}
 
 	shopID := c.Locals("shop_id").(uint)
 	shop, err := h.shopRepo.GetByID(shopID)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Shop not found",
-		})
+		return utils.SendError(c, fiber.StatusNotFound, utils.CodeShopNotFound, "Shop not found")
 	}
 
 	account, err := h.accountRepo.GetByID(shop.AccountID)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Account not found",
-		})
+		return utils.SendError(c, fiber.StatusNotFound, utils.CodeAccountNotFound, "Account not found")
 	}
 
 	shops, err := h.shopRepo.GetByAccountID(account.ID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get shops")
 	}
 
 	return c.JSON(fiber.Map{
@@ -99,24 +98,18 @@ func (h *ShopHandler) GetAccount(c *fiber.Ctx) error {
 // ListShops returns all shops for the current user's account
 func (h *ShopHandler) ListShops(c *fiber.Ctx) error {
 	if h.accountRepo == nil {
-		return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{
-			"error": "Account feature not available",
-		})
+		return utils.SendError(c, fiber.StatusNotImplemented, utils.CodeServiceUnavailable, "Account feature not available")
 	}
 
 	shopID := c.Locals("shop_id").(uint)
 	shop, err := h.shopRepo.GetByID(shopID)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Shop not found",
-		})
+		return utils.SendError(c, fiber.StatusNotFound, utils.CodeShopNotFound, "Shop not found")
 	}
 
 	shops, err := h.shopRepo.GetByAccountID(shop.AccountID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get shops")
 	}
 
 	return c.JSON(fiber.Map{"data": shops})
@@ -128,9 +121,7 @@ func (h *ShopHandler) UpdateProfile(c *fiber.Ctx) error {
 
 	shop, err := h.shopRepo.GetByID(shopID)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Shop not found",
-		})
+		return utils.SendError(c, fiber.StatusNotFound, utils.CodeShopNotFound, "Shop not found")
 	}
 
 	type UpdateRequest struct {
@@ -138,13 +129,24 @@ func (h *ShopHandler) UpdateProfile(c *fiber.Ctx) error {
 		OwnerName string `json:"owner_name"`
 		Address   string `json:"address"`
 		Email     string `json:"email"`
+		Rounding  string `json:"rounding"`
+
+		AutoDeactivateZeroStock *bool `json:"auto_deactivate_zero_stock"`
+		AllowNegativeStock      *bool `json:"allow_negative_stock"`
+		SMSReceipts             *bool `json:"sms_receipts"`
+
+		LowStockChannel string `json:"low_stock_channel"` // whatsapp or sms
+
+		BusinessHours *models.BusinessHours `json:"business_hours"` // {} clears
+		ClosedMessage *string               `json:"closed_message"`
+		Timezone      *string               `json:"timezone"` // "" for East Africa Time
+
+		BirthdayBonusPoints *int `json:"birthday_bonus_points"` // 0 turns birthday rewards off
 	}
 
 	var req UpdateRequest
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "Invalid request body")
 	}
 
 	if req.Name != "" {
@@ -159,16 +161,96 @@ func (h *ShopHandler) UpdateProfile(c *fiber.Ctx) error {
 	if req.Email != "" {
 		shop.Email = req.Email
 	}
+	if req.Rounding != "" {
+		policy, ok := models.ParseRoundingPolicy(req.Rounding)
+		if !ok {
+			return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "Rounding must be none, nearest_1 or nearest_5")
+		}
+		shop.Rounding = policy
+	}
+	if req.AutoDeactivateZeroStock != nil {
+		shop.AutoDeactivateZeroStock = *req.AutoDeactivateZeroStock
+	}
+	if req.AllowNegativeStock != nil {
+		shop.AllowNegativeStock = *req.AllowNegativeStock
+	}
+	if req.SMSReceipts != nil {
+		shop.SMSReceipts = *req.SMSReceipts
+	}
+	switch req.LowStockChannel {
+	case "":
+	case models.AlertChannelWhatsApp, models.AlertChannelSMS:
+		shop.LowStockChannel = req.LowStockChannel
+	default:
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "low_stock_channel must be whatsapp or sms")
+	}
+
+	if req.BusinessHours != nil {
+		if err := req.BusinessHours.Validate(); err != nil {
+			return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "business_hours: "+err.Error())
+		}
+		shop.BusinessHours = *req.BusinessHours
+		if len(shop.BusinessHours) == 0 {
+			shop.BusinessHours = nil
+		}
+	}
+	if req.ClosedMessage != nil {
+		if len(*req.ClosedMessage) > 500 {
+			return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "closed_message must be at most 500 characters")
+		}
+		shop.ClosedMessage = strings.TrimSpace(*req.ClosedMessage)
+	}
+	if req.Timezone != nil {
+		zone := strings.TrimSpace(*req.Timezone)
+		if zone != "" && !models.ValidTimezone(zone) {
+			return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "timezone must be a time zone such as Africa/Nairobi")
+		}
+		shop.Timezone = zone
+	}
+
+	if req.BirthdayBonusPoints != nil {
+		if *req.BirthdayBonusPoints < 0 || *req.BirthdayBonusPoints > 10000 {
+			return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "birthday_bonus_points must be between 0 and 10000")
+		}
+		shop.BirthdayBonusPoints = *req.BirthdayBonusPoints
+	}
 
 	if err := h.shopRepo.Update(shop); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to update profile",
-		})
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to update profile")
 	}
 
 	return c.JSON(shop)
 }
 
+// SetUSSDPin sets the PIN asked for on USSD and lifts any lockout
+func (h *ShopHandler) SetUSSDPin(c *fiber.Ctx) error {
+	shopID := c.Locals("shop_id").(uint)
+
+	shop, err := h.shopRepo.GetByID(shopID)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusNotFound, utils.CodeShopNotFound, "Shop not found")
+	}
+
+	var req struct {
+		Pin string `json:"pin"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "Invalid request body")
+	}
+	if err := shop.SetUSSDPin(req.Pin); err != nil {
+		if errors.Is(err, models.ErrInvalidUSSDPin) {
+			return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, fmt.Sprintf("pin must be %d digits", models.USSDPinLength))
+		}
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to set PIN")
+	}
+
+	if err := h.shopRepo.Update(shop); err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to set PIN")
+	}
+
+	return c.JSON(fiber.Map{"message": "USSD PIN updated"})
+}
+
 // GetDashboard returns dashboard statistics
 func (h *ShopHandler) GetDashboard(c *fiber.Ctx) error {
 	shopID := c.Locals("shop_id").(uint)
@@ -176,17 +258,13 @@ func (h *ShopHandler) GetDashboard(c *fiber.Ctx) error {
 	// Get product count
 	products, err := h.productRepo.GetByShopID(shopID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to get products",
-		})
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get products")
 	}
 
 	// Get today's sales
 	sales, err := h.saleRepo.GetTodaySales(shopID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to get sales",
-		})
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get sales")
 	}
 
 	// Calculate totals
@@ -221,7 +299,15 @@ func (h *ShopHandler) GetDashboard(c *fiber.Ctx) error {
 
 // ProductHandler handles product-related HTTP requests
 type ProductHandler struct {
-	productRepo *repository.ProductRepository
+	productRepo  *repository.ProductRepository
+	categoryRepo *repository.CategoryRepository
+	bundleRepo   *repository.BundleRepository
+	priceRepo    *repository.PriceHistoryRepository
+	movementRepo *repository.StockMovementRepository
+	crossSells   *services.CrossSellService
+
+	imageStore     storage.Store
+	imageURLPrefix string
 }
 
 // NewProductHandler creates a new product handler
@@ -229,15 +315,38 @@ func NewProductHandler(productRepo *repository.ProductRepository) *ProductHandle
 	return &ProductHandler{productRepo: productRepo}
 }
 
+// SetCategoryRepo sets the category repository for hierarchical categories
+func (h *ProductHandler) SetCategoryRepo(categoryRepo *repository.CategoryRepository) {
+	h.categoryRepo = categoryRepo
+}
+
+// SetBundleRepo sets the bundle repository for combo products
+func (h *ProductHandler) SetBundleRepo(bundleRepo *repository.BundleRepository) {
+	h.bundleRepo = bundleRepo
+}
+
+// SetPriceHistoryRepo sets the price history repository
+func (h *ProductHandler) SetPriceHistoryRepo(priceRepo *repository.PriceHistoryRepository) {
+	h.priceRepo = priceRepo
+}
+
+// SetStockMovementRepo sets the stock movement repository
+func (h *ProductHandler) SetStockMovementRepo(movementRepo *repository.StockMovementRepository) {
+	h.movementRepo = movementRepo
+}
+
+// SetCrossSells sets the service suggesting products bought together
+func (h *ProductHandler) SetCrossSells(crossSells *services.CrossSellService) {
+	h.crossSells = crossSells
+}
+
 // ListProducts returns all products for a shop
 func (h *ProductHandler) ListProducts(c *fiber.Ctx) error {
 	shopID := c.Locals("shop_id").(uint)
 
 	products, err := h.productRepo.GetByShopID(shopID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to get products",
-		})
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get products")
 	}
 
 	return c.JSON(products)
@@ -248,23 +357,17 @@ func (h *ProductHandler) GetProduct(c *fiber.Ctx) error {
 	shopID := c.Locals("shop_id").(uint)
 	productID, err := c.ParamsInt("id")
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid product ID",
-		})
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "Invalid product ID")
 	}
 
 	product, err := h.productRepo.GetByID(uint(productID))
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Product not found",
-		})
+		return utils.SendError(c, fiber.StatusNotFound, utils.CodeProductNotFound, "Product not found")
 	}
 
 	// Verify ownership
 	if product.ShopID != shopID {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": "Access denied",
-		})
+		return utils.SendError(c, fiber.StatusForbidden, utils.CodeForbidden, "Access denied")
 	}
 
 	return c.JSON(product)
@@ -283,25 +386,25 @@ func (h *ProductHandler) CreateProduct(c *fiber.Ctx) error {
 		CurrentStock      int     `json:"current_stock"`
 		LowStockThreshold int     `json:"low_stock_threshold"`
 		Barcode           string  `json:"barcode"`
+		PurchaseUnit      string  `json:"purchase_unit"`
+		UnitsPerPurchase  int     `json:"units_per_purchase"`
 	}
 
 	var req CreateRequest
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "Invalid request body")
 	}
 
 	// Validate required fields
 	if req.Name == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Product name is required",
-		})
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "Product name is required")
 	}
 	if req.SellingPrice <= 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Selling price must be greater than 0",
-		})
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "Selling price must be greater than 0")
+	}
+
+	if err := checkProductLimit(h.productRepo, shopID, shopPlan(c), 1); err != nil {
+		return err
 	}
 
 	product := &models.Product{
@@ -323,37 +426,56 @@ func (h *ProductHandler) CreateProduct(c *fiber.Ctx) error {
 	if product.LowStockThreshold == 0 {
 		product.LowStockThreshold = 10
 	}
+	if err := product.SetPurchaseUnit(req.PurchaseUnit, req.UnitsPerPurchase); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, err.Error())
+	}
 
 	if err := h.productRepo.Create(product); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to create product",
-		})
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to create product")
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(product)
 }
 
+// shopPlan returns the plan of the authenticated shop
+func shopPlan(c *fiber.Ctx) models.PlanType {
+	if shop, ok := c.Locals("shop").(*models.Shop); ok && shop != nil {
+		return shop.Plan
+	}
+	return models.PlanFree
+}
+
+// checkProductLimit returns a plan-limit error when n more products would
+// take the shop past its plan's product limit
+func checkProductLimit(productRepo *repository.ProductRepository, shopID uint, plan models.PlanType, n int) error {
+	count, err := productRepo.CountByShop(shopID)
+	if err != nil {
+		return err
+	}
+	limits := models.LimitsFor(plan)
+	if limits.CanAddProducts(count, n) {
+		return nil
+	}
+	return utils.NewUserError(utils.CodePlanLimitReached, fmt.Sprintf(
+		"Product limit reached: the %s plan allows %d products. Upgrade to %s to add more",
+		plan.Name(), limits.MaxProducts, models.NextPlan(plan).Name()))
+}
+
 // UpdateProduct updates a product
 func (h *ProductHandler) UpdateProduct(c *fiber.Ctx) error {
 	shopID := c.Locals("shop_id").(uint)
 	productID, err := c.ParamsInt("id")
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid product ID",
-		})
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "Invalid product ID")
 	}
 
 	product, err := h.productRepo.GetByID(uint(productID))
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Product not found",
-		})
+		return utils.SendError(c, fiber.StatusNotFound, utils.CodeProductNotFound, "Product not found")
 	}
 
 	if product.ShopID != shopID {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": "Access denied",
-		})
+		return utils.SendError(c, fiber.StatusForbidden, utils.CodeForbidden, "Access denied")
 	}
 
 	type UpdateRequest struct {
@@ -364,14 +486,16 @@ func (h *ProductHandler) UpdateProduct(c *fiber.Ctx) error {
 		SellingPrice      float64 `json:"selling_price"`
 		CurrentStock      *int    `json:"current_stock"`
 		LowStockThreshold int     `json:"low_stock_threshold"`
+		LowStockPercent   *int    `json:"low_stock_percent"` // 0 goes back to low_stock_threshold
+		MaxStock          *int    `json:"max_stock"`
 		Barcode           string  `json:"barcode"`
+		PurchaseUnit      *string `json:"purchase_unit"`
+		UnitsPerPurchase  int     `json:"units_per_purchase"`
 	}
 
 	var req UpdateRequest
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "Invalid request body")
 	}
 
 	if req.Name != "" {
@@ -395,14 +519,39 @@ func (h *ProductHandler) UpdateProduct(c *fiber.Ctx) error {
 	if req.LowStockThreshold > 0 {
 		product.LowStockThreshold = req.LowStockThreshold
 	}
+	if req.LowStockPercent != nil {
+		if *req.LowStockPercent < 0 || *req.LowStockPercent > 100 {
+			return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "low_stock_percent must be between 0 and 100")
+		}
+		product.LowStockPercent = *req.LowStockPercent
+	}
+	if req.MaxStock != nil {
+		if *req.MaxStock < 0 {
+			return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "max_stock must not be negative")
+		}
+		product.MaxStock = *req.MaxStock
+	}
+	if product.LowStockPercent > 0 && product.MaxStock == 0 {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "low_stock_percent needs a max_stock to be a percentage of")
+	}
 	if req.Barcode != "" {
 		product.Barcode = req.Barcode
 	}
+	if req.PurchaseUnit != nil || req.UnitsPerPurchase != 0 {
+		unit, factor := product.PurchaseUnit, product.UnitsPerPurchase
+		if req.PurchaseUnit != nil {
+			unit = *req.PurchaseUnit
+		}
+		if req.UnitsPerPurchase != 0 {
+			factor = req.UnitsPerPurchase
+		}
+		if err := product.SetPurchaseUnit(unit, factor); err != nil {
+			return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, err.Error())
+		}
+	}
 
-	if err := h.productRepo.Update(product); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to update product",
-		})
+	if err := h.productRepo.UpdateBy(product, models.PriceSourceAPI, priceChangedBy(c)); err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to update product")
 	}
 
 	return c.JSON(product)
@@ -413,28 +562,20 @@ func (h *ProductHandler) DeleteProduct(c *fiber.Ctx) error {
 	shopID := c.Locals("shop_id").(uint)
 	productID, err := c.ParamsInt("id")
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid product ID",
-		})
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "Invalid product ID")
 	}
 
 	product, err := h.productRepo.GetByID(uint(productID))
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Product not found",
-		})
+		return utils.SendError(c, fiber.StatusNotFound, utils.CodeProductNotFound, "Product not found")
 	}
 
 	if product.ShopID != shopID {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": "Access denied",
-		})
+		return utils.SendError(c, fiber.StatusForbidden, utils.CodeForbidden, "Access denied")
 	}
 
 	if err := h.productRepo.Delete(product.ID); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to delete product",
-		})
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to delete product")
 	}
 
 	return c.JSON(fiber.Map{
@@ -446,6 +587,8 @@ func (h *ProductHandler) DeleteProduct(c *fiber.Ctx) error {
 type SaleHandler struct {
 	saleRepo    *repository.SaleRepository
 	productRepo *repository.ProductRepository
+	bundleRepo  *repository.BundleRepository
+	shopRepo    *repository.ShopRepository
 }
 
 // NewSaleHandler creates a new sale handler
@@ -456,27 +599,40 @@ func NewSaleHandler(saleRepo *repository.SaleRepository, productRepo *repository
 	}
 }
 
+// SetBundleRepo sets the bundle repository so bundle sales expand into components
+func (h *SaleHandler) SetBundleRepo(bundleRepo *repository.BundleRepository) {
+	h.bundleRepo = bundleRepo
+}
+
+// SetShopRepo sets the shop repository used to look up the shop's rounding policy
+func (h *SaleHandler) SetShopRepo(shopRepo *repository.ShopRepository) {
+	h.shopRepo = shopRepo
+}
+
+// rounding returns the rounding policy for a sale paid by method
+func (h *SaleHandler) rounding(shopID uint, method models.PaymentMethod) models.RoundingPolicy {
+	var shop *models.Shop
+	if h.shopRepo != nil {
+		shop, _ = h.shopRepo.GetByID(shopID)
+	}
+	return shop.RoundingFor(method)
+}
+
 // GetSale returns a single sale by ID
 func (h *SaleHandler) GetSale(c *fiber.Ctx) error {
 	shopID := c.Locals("shop_id").(uint)
 	saleID, err := c.ParamsInt("id")
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid sale ID",
-		})
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "Invalid sale ID")
 	}
 
 	sale, err := h.saleRepo.GetByID(uint(saleID))
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Sale not found",
-		})
+		return utils.SendError(c, fiber.StatusNotFound, utils.CodeSaleNotFound, "Sale not found")
 	}
 
 	if sale.ShopID != shopID {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": "Access denied",
-		})
+		return utils.SendError(c, fiber.StatusForbidden, utils.CodeForbidden, "Access denied")
 	}
 
 	return c.JSON(sale)
@@ -489,9 +645,7 @@ func (h *SaleHandler) ListSales(c *fiber.Ctx) error {
 
 	sales, err := h.saleRepo.GetByShopID(shopID, limit)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to get sales",
-		})
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get sales")
 	}
 
 	return c.JSON(sales)
@@ -510,41 +664,43 @@ func (h *SaleHandler) CreateSale(c *fiber.Ctx) error {
 
 	var req CreateRequest
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "Invalid request body")
 	}
 
 	// Validate
 	if req.ProductID == 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Product ID is required",
-		})
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "Product ID is required")
 	}
 	if req.Quantity <= 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Quantity must be greater than 0",
-		})
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "Quantity must be greater than 0")
 	}
 
 	// Get product
 	product, err := h.productRepo.GetByID(req.ProductID)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Product not found",
-		})
+		return utils.SendError(c, fiber.StatusNotFound, utils.CodeProductNotFound, "Product not found")
 	}
 
 	if product.ShopID != shopID {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": "Access denied",
-		})
+		return utils.SendError(c, fiber.StatusForbidden, utils.CodeForbidden, "Access denied")
 	}
 
-	// Check stock
-	if product.CurrentStock < req.Quantity {
+	if product.IsBundle && h.bundleRepo != nil {
+		return h.createBundleSale(c, product, req.Quantity, req.UnitPrice, req.PaymentMethod)
+	}
+
+	var shop *models.Shop
+	if h.shopRepo != nil {
+		shop, _ = h.shopRepo.GetByID(shopID)
+	}
+
+	// Check stock; shops that allow negative stock sell past it with a
+	// warning
+	allowNegative := shop != nil && shop.AllowNegativeStock
+	if product.CurrentStock < req.Quantity && !allowNegative {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error":     "Insufficient stock",
+			"code":      utils.CodeInsufficientStock,
 			"available": product.CurrentStock,
 		})
 	}
@@ -576,17 +732,32 @@ func (h *SaleHandler) CreateSale(c *fiber.Ctx) error {
 		Profit:        profit,
 		PaymentMethod: paymentMethod,
 	}
+	sale.ApplyRounding(shop.RoundingFor(paymentMethod))
 
-	if err := h.saleRepo.Create(sale); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to create sale",
-		})
+	soldOut, err := h.saleRepo.RecordSale(sale)
+	if err != nil {
+		if errors.Is(err, repository.ErrInsufficientStock) {
+			return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInsufficientStock, "Insufficient stock")
+		}
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to create sale")
 	}
+	webhooksvc.TriggerProductDeactivated(soldOut, "out_of_stock")
 
-	// Update stock
-	h.productRepo.UpdateStock(product.ID, -req.Quantity)
+	remaining := product.CurrentStock - req.Quantity
+	websocket.NotifySaleCreated(sale, product, remaining)
+
+	resp := saleResponse{Sale: sale}
+	if remaining < 0 {
+		resp.Warning = fmt.Sprintf("Sold past the stock on record: %s is now at %d. Add the stock you have.", product.Name, remaining)
+	}
+	return c.Status(fiber.StatusCreated).JSON(resp)
+}
 
-	return c.Status(fiber.StatusCreated).JSON(sale)
+// saleResponse is a created sale, with a warning when it took stock below
+// zero
+type saleResponse struct {
+	*models.Sale
+	Warning string `json:"warning,omitempty"`
 }
 
 // ReportHandler handles report-related HTTP requests
@@ -595,6 +766,17 @@ type ReportHandler struct {
 	productRepo *repository.ProductRepository
 	summaryRepo *repository.DailySummaryRepository
 	cache       *cache.CacheService
+	// snapshotRepo backs the inventory value trend; nil disables it
+	snapshotRepo *repository.InventorySnapshotRepository
+
+	// closingStockRepo backs month-end snapshots; nil disables them
+	closingStockRepo *repository.ClosingStockRepository
+
+	// zreports backs end-of-day Z-reports; nil disables them
+	zreports *zreport.Service
+
+	// expenseRepo backs the net profit report; nil disables it
+	expenseRepo *repository.ExpenseRepository
 }
 
 // NewReportHandler creates a new report handler
@@ -626,6 +808,16 @@ func NewReportHandlerWithCache(
 	}
 }
 
+// SetSnapshotRepo sets the inventory snapshot repository
+func (h *ReportHandler) SetSnapshotRepo(repo *repository.InventorySnapshotRepository) {
+	h.snapshotRepo = repo
+}
+
+// SetClosingStockRepo sets the month-end closing stock repository
+func (h *ReportHandler) SetClosingStockRepo(repo *repository.ClosingStockRepository) {
+	h.closingStockRepo = repo
+}
+
 // GetDailyReport returns daily report
 func (h *ReportHandler) GetDailyReport(c *fiber.Ctx) error {
 	shopID := c.Locals("shop_id").(uint)
@@ -656,9 +848,7 @@ func (h *ReportHandler) GetDailyReport(c *fiber.Ctx) error {
 	// Get from database
 	sales, err := h.saleRepo.GetTodaySales(shopID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to get sales",
-		})
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get sales")
 	}
 
 	var totalSales, totalProfit, totalCost float64
@@ -748,9 +938,7 @@ func (h *ReportHandler) GetWeeklyReport(c *fiber.Ctx) error {
 
 	sales, err := h.saleRepo.GetByDateRange(shopID, start, end)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to get sales",
-		})
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get sales")
 	}
 
 	var totalSales, totalProfit, totalCost float64
@@ -765,6 +953,12 @@ func (h *ReportHandler) GetWeeklyReport(c *fiber.Ctx) error {
 
 	dailyAvg := totalSales / 7
 
+	comparison, err := h.compareWithPrevious(shopID, start.AddDate(0, 0, -7), start, "last week",
+		export.PeriodTotals{Sales: totalSales, Profit: totalProfit, Transactions: transactionCount})
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get sales")
+	}
+
 	return c.JSON(fiber.Map{
 		"type":         "weekly",
 		"start_date":   start.Format("2006-01-02"),
@@ -774,6 +968,7 @@ func (h *ReportHandler) GetWeeklyReport(c *fiber.Ctx) error {
 		"total_cost":   totalCost,
 		"transactions": transactionCount,
 		"daily_avg":    dailyAvg,
+		"comparison":   comparison,
 	})
 }
 
@@ -786,9 +981,7 @@ func (h *ReportHandler) GetMonthlyReport(c *fiber.Ctx) error {
 
 	sales, err := h.saleRepo.GetByDateRange(shopID, start, end)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to get sales",
-		})
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get sales")
 	}
 
 	var totalSales, totalProfit, totalCost float64
@@ -807,6 +1000,12 @@ func (h *ReportHandler) GetMonthlyReport(c *fiber.Ctx) error {
 	}
 	dailyAvg := totalSales / daysInRange
 
+	comparison, err := h.compareWithPrevious(shopID, start.AddDate(0, -1, 0), start, "last month",
+		export.PeriodTotals{Sales: totalSales, Profit: totalProfit, Transactions: transactionCount})
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get sales")
+	}
+
 	return c.JSON(fiber.Map{
 		"type":         "monthly",
 		"start_date":   start.Format("2006-01-02"),
@@ -816,9 +1015,27 @@ func (h *ReportHandler) GetMonthlyReport(c *fiber.Ctx) error {
 		"total_cost":   totalCost,
 		"transactions": transactionCount,
 		"daily_avg":    dailyAvg,
+		"comparison":   comparison,
 	})
 }
 
+// compareWithPrevious totals the shop's sales from prevStart to start, the
+// period before a report's, and compares the report's totals with them
+func (h *ReportHandler) compareWithPrevious(shopID uint, prevStart, start time.Time, period string, current export.PeriodTotals) (*export.Comparison, error) {
+	sales, err := h.saleRepo.GetByDateRange(shopID, prevStart, start)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous export.PeriodTotals
+	for _, sale := range sales {
+		previous.Sales += sale.TotalAmount
+		previous.Profit += sale.Profit
+		previous.Transactions++
+	}
+	return export.Compare(period, current, previous), nil
+}
+
 // GetAnalytics returns analytics data
 func (h *ReportHandler) GetAnalytics(c *fiber.Ctx) error {
 	shopID := c.Locals("shop_id").(uint)
@@ -829,9 +1046,7 @@ func (h *ReportHandler) GetAnalytics(c *fiber.Ctx) error {
 
 	sales, err := h.saleRepo.GetByDateRange(shopID, start, end)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to get sales",
-		})
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get sales")
 	}
 
 	// Group by day
@@ -873,6 +1088,111 @@ func (h *ReportHandler) GetAnalytics(c *fiber.Ctx) error {
 	})
 }
 
+// maxInventoryTrendDays caps the inventory value series length
+const maxInventoryTrendDays = 365
+
+// InventoryValuePoint is one day of the inventory value series. Value is
+// nil for days before snapshots started or when the scheduler missed a day.
+type InventoryValuePoint struct {
+	Date      string   `json:"date"`
+	Value     *float64 `json:"value"`
+	CostValue *float64 `json:"cost_value"`
+	Units     *int     `json:"units"`
+}
+
+// GetInventoryValueTrend returns the daily inventory value for the last N days
+func (h *ReportHandler) GetInventoryValueTrend(c *fiber.Ctx) error {
+	if h.snapshotRepo == nil {
+		return utils.SendError(c, fiber.StatusServiceUnavailable, utils.CodeInternal, "Inventory value history is not available")
+	}
+	shopID := c.Locals("shop_id").(uint)
+
+	days := c.QueryInt("days", 30)
+	if days < 1 || days > maxInventoryTrendDays {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError,
+			fmt.Sprintf("days must be between 1 and %d", maxInventoryTrendDays))
+	}
+
+	today := time.Now()
+	start := today.AddDate(0, 0, -(days - 1))
+
+	snapshots, err := h.snapshotRepo.GetRange(shopID, start, today)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get inventory history")
+	}
+	// Today's value is measured live rather than waiting for the next snapshot
+	current, err := h.snapshotRepo.Measure(shopID, today)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get inventory value")
+	}
+
+	byDate := make(map[string]models.InventorySnapshot, len(snapshots)+1)
+	for _, s := range snapshots {
+		byDate[s.Date.Format("2006-01-02")] = s
+	}
+	byDate[current.Date.Format("2006-01-02")] = *current
+
+	series := make([]InventoryValuePoint, 0, days)
+	for d := 0; d < days; d++ {
+		date := start.AddDate(0, 0, d).Format("2006-01-02")
+		point := InventoryValuePoint{Date: date}
+		if s, ok := byDate[date]; ok {
+			point.Value = &s.Value
+			point.CostValue = &s.CostValue
+			point.Units = &s.Units
+		}
+		series = append(series, point)
+	}
+
+	return c.JSON(fiber.Map{
+		"type":          "inventory_value",
+		"days":          days,
+		"start_date":    start.Format("2006-01-02"),
+		"end_date":      today.Format("2006-01-02"),
+		"current_value": current.Value,
+		"series":        series,
+	})
+}
+
+// GetMonthEndSnapshot returns the closing stock of every product at the
+// end of ?month=2024-11, valued at cost and selling price
+func (h *ReportHandler) GetMonthEndSnapshot(c *fiber.Ctx) error {
+	if h.closingStockRepo == nil {
+		return utils.SendError(c, fiber.StatusServiceUnavailable, utils.CodeInternal, "Month-end snapshots are not available")
+	}
+	shopID := c.Locals("shop_id").(uint)
+
+	month, err := time.ParseInLocation("2006-01", c.Query("month"), time.Local)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "month must look like 2024-11")
+	}
+
+	rows, err := h.closingStockRepo.GetMonth(shopID, month)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get month-end snapshot")
+	}
+	if len(rows) == 0 {
+		return utils.SendError(c, fiber.StatusNotFound, utils.CodeNotFound, "No snapshot for "+month.Format("January 2006"))
+	}
+
+	var units int
+	var costValue, value float64
+	for _, r := range rows {
+		units += r.ClosingStock
+		costValue += r.CostPrice * float64(r.ClosingStock)
+		value += r.SellingPrice * float64(r.ClosingStock)
+	}
+
+	return c.JSON(fiber.Map{
+		"month":         month.Format("2006-01"),
+		"snapshot_date": rows[0].SnapshotDate.Format("2006-01-02"),
+		"products":      rows,
+		"units":         units,
+		"cost_value":    costValue,
+		"value":         value,
+	})
+}
+
 // BulkCreateProducts creates multiple products at once
 func (h *ProductHandler) BulkCreateProducts(c *fiber.Ctx) error {
 	shopID := c.Locals("shop_id").(uint)
@@ -890,21 +1210,22 @@ func (h *ProductHandler) BulkCreateProducts(c *fiber.Ctx) error {
 
 	var products []BulkProduct
 	if err := c.BodyParser(&products); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "Invalid request body")
 	}
 
 	if len(products) == 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "No products provided",
-		})
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "No products provided")
 	}
 
 	if len(products) > 100 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Maximum 100 products per request",
-		})
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "Maximum 100 products per request")
+	}
+
+	plan := shopPlan(c)
+	limits := models.LimitsFor(plan)
+	count, err := h.productRepo.CountByShop(shopID)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to count products")
 	}
 
 	var created []models.Product
@@ -920,27 +1241,21 @@ func (h *ProductHandler) BulkCreateProducts(c *fiber.Ctx) error {
 			continue
 		}
 
-		unit := p.Unit
-		if unit == "" {
-			unit = "pcs"
-		}
-		threshold := p.LowStockThreshold
-		if threshold == 0 {
-			threshold = 10
+		if !limits.CanAddProducts(count+int64(len(created)), 1) {
+			errors = append(errors, fmt.Sprintf("Row %d: product limit reached for %s plan", i+1, plan.Name()))
+			continue
 		}
 
-		product := &models.Product{
-			ShopID:            shopID,
+		product := newBulkProduct(shopID, models.Product{
 			Name:              p.Name,
 			Category:          p.Category,
-			Unit:              unit,
+			Unit:              p.Unit,
 			CostPrice:         p.CostPrice,
 			SellingPrice:      p.SellingPrice,
 			CurrentStock:      p.CurrentStock,
-			LowStockThreshold: threshold,
+			LowStockThreshold: p.LowStockThreshold,
 			Barcode:           p.Barcode,
-			IsActive:          true,
-		}
+		})
 
 		if err := h.productRepo.Create(product); err != nil {
 			errors = append(errors, fmt.Sprintf("Row %d: %s", i+1, err.Error()))
@@ -957,15 +1272,38 @@ func (h *ProductHandler) BulkCreateProducts(c *fiber.Ctx) error {
 	})
 }
 
+// newBulkProduct is an active product of the shop from a bulk or imported
+// row, sold in pcs with a low stock alert at 10 unless the row says
+// otherwise
+func newBulkProduct(shopID uint, p models.Product) *models.Product {
+	product := &models.Product{
+		ShopID:            shopID,
+		Name:              p.Name,
+		Category:          p.Category,
+		Unit:              p.Unit,
+		CostPrice:         p.CostPrice,
+		SellingPrice:      p.SellingPrice,
+		CurrentStock:      p.CurrentStock,
+		LowStockThreshold: p.LowStockThreshold,
+		Barcode:           p.Barcode,
+		IsActive:          true,
+	}
+	if product.Unit == "" {
+		product.Unit = "pcs"
+	}
+	if product.LowStockThreshold == 0 {
+		product.LowStockThreshold = 10
+	}
+	return product
+}
+
 // ListCategories returns all unique categories
 func (h *ProductHandler) ListCategories(c *fiber.Ctx) error {
 	shopID := c.Locals("shop_id").(uint)
 
 	categories, err := h.productRepo.GetCategories(shopID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to get categories",
-		})
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get categories")
 	}
 
 	// Also get uncategorized count
@@ -984,125 +1322,168 @@ func (h *ProductHandler) ListCategories(c *fiber.Ctx) error {
 	})
 }
 
-// CreateCategory creates a new category (as string in products)
+// GetCategoryTree returns the shop's categories nested under their parents
+func (h *ProductHandler) GetCategoryTree(c *fiber.Ctx) error {
+	shopID := c.Locals("shop_id").(uint)
+
+	if h.categoryRepo == nil {
+		return utils.SendError(c, fiber.StatusServiceUnavailable, utils.CodeServiceUnavailable, "Category tree not available")
+	}
+
+	tree, err := h.categoryRepo.GetTree(shopID)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get categories")
+	}
+
+	return c.JSON(fiber.Map{
+		"categories": tree,
+	})
+}
+
+// CreateCategory creates a new category, optionally under a parent
 func (h *ProductHandler) CreateCategory(c *fiber.Ctx) error {
 	shopID := c.Locals("shop_id").(uint)
 
+	if h.categoryRepo == nil {
+		return utils.SendError(c, fiber.StatusServiceUnavailable, utils.CodeServiceUnavailable, "Categories not available")
+	}
+
 	type Request struct {
-		Name string `json:"name"`
+		Name     string `json:"name"`
+		ParentID *uint  `json:"parent_id"`
 	}
 
 	var req Request
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		return utils.SendError(c, 400, utils.CodeInvalidRequest, "Invalid request body")
 	}
 
+	req.Name = strings.TrimSpace(req.Name)
 	if req.Name == "" {
-		return c.Status(400).JSON(fiber.Map{"error": "Category name is required"})
+		return utils.SendError(c, 400, utils.CodeValidationError, "Category name is required")
 	}
 
-	// Check if category already exists
-	existing, _ := h.productRepo.GetCategories(shopID)
-	for _, cat := range existing {
-		if cat == req.Name {
-			return c.Status(400).JSON(fiber.Map{"error": "Category already exists"})
-		}
+	if _, err := h.categoryRepo.GetByName(shopID, req.Name); err == nil {
+		return utils.SendError(c, 400, utils.CodeDuplicateEntry, "Category already exists")
 	}
 
-	// Create a placeholder product with this category to "register" it
-	placeholder := &models.Product{
-		ShopID:   shopID,
-		Name:     "__category_" + req.Name,
-		Category: req.Name,
-		IsActive: false, // Inactive = not shown but category exists
+	if req.ParentID != nil {
+		parent, err := h.categoryRepo.GetByID(*req.ParentID)
+		if err != nil || parent.ShopID != shopID {
+			return utils.SendError(c, 400, utils.CodeNotFound, "Parent category not found")
+		}
 	}
 
-	if err := h.productRepo.Create(placeholder); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to create category"})
+	category := &models.Category{
+		ShopID:           shopID,
+		Name:             req.Name,
+		ParentCategoryID: req.ParentID,
+	}
+	if err := h.categoryRepo.Create(category); err != nil {
+		return utils.SendError(c, 500, utils.CodeInternal, "Failed to create category")
 	}
 
 	return c.Status(201).JSON(fiber.Map{
-		"message": "Category created",
-		"id":      placeholder.ID,
-		"name":    req.Name,
+		"message":   "Category created",
+		"id":        category.ID,
+		"name":      category.Name,
+		"parent_id": category.ParentCategoryID,
 	})
 }
 
-// UpdateCategory renames a category
+// UpdateCategory renames a category and its products' category, or moves it
+// under another parent. A parent_id of 0 makes it a top-level category.
 func (h *ProductHandler) UpdateCategory(c *fiber.Ctx) error {
 	shopID := c.Locals("shop_id").(uint)
 	categoryID, err := c.ParamsInt("id")
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid category ID"})
+		return utils.SendError(c, 400, utils.CodeInvalidRequest, "Invalid category ID")
+	}
+
+	if h.categoryRepo == nil {
+		return utils.SendError(c, fiber.StatusServiceUnavailable, utils.CodeServiceUnavailable, "Categories not available")
 	}
 
 	type Request struct {
-		Name string `json:"name"`
+		Name     string `json:"name"`
+		ParentID *uint  `json:"parent_id"`
 	}
 
 	var req Request
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		return utils.SendError(c, 400, utils.CodeInvalidRequest, "Invalid request body")
 	}
 
-	if req.Name == "" {
-		return c.Status(400).JSON(fiber.Map{"error": "Category name is required"})
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" && req.ParentID == nil {
+		return utils.SendError(c, 400, utils.CodeValidationError, "Category name or parent_id is required")
 	}
 
-	// Get the placeholder product
-	product, err := h.productRepo.GetByID(uint(categoryID))
-	if err != nil || product.ShopID != shopID {
-		return c.Status(404).JSON(fiber.Map{"error": "Category not found"})
+	category, err := h.categoryRepo.GetByID(uint(categoryID))
+	if err != nil || category.ShopID != shopID {
+		return utils.SendError(c, 404, utils.CodeNotFound, "Category not found")
 	}
 
-	oldName := product.Category
-	product.Category = req.Name
-	if err := h.productRepo.Update(product); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to update category"})
+	if req.ParentID != nil {
+		if *req.ParentID == 0 {
+			category.ParentCategoryID = nil
+		} else {
+			parent, err := h.categoryRepo.GetByID(*req.ParentID)
+			if err != nil || parent.ShopID != shopID {
+				return utils.SendError(c, 400, utils.CodeNotFound, "Parent category not found")
+			}
+			within, err := h.categoryRepo.IsWithin(parent.ID, category.ID)
+			if err != nil {
+				return utils.SendError(c, 500, utils.CodeInternal, "Failed to update category")
+			}
+			if within {
+				return utils.SendError(c, 400, utils.CodeValidationError, "A category cannot be moved under itself or its subcategories")
+			}
+			category.ParentCategoryID = &parent.ID
+		}
 	}
 
-	// Update all products with old category name
-	products, _ := h.productRepo.GetByShopID(shopID)
-	for i := range products {
-		if products[i].Category == oldName {
-			products[i].Category = req.Name
-			h.productRepo.Update(&products[i])
+	if req.Name != "" && req.Name != category.Name {
+		if _, err := h.categoryRepo.GetByName(shopID, req.Name); err == nil {
+			return utils.SendError(c, 400, utils.CodeDuplicateEntry, "Category already exists")
 		}
+		err = h.categoryRepo.Rename(category, req.Name)
+	} else {
+		err = h.categoryRepo.Update(category)
+	}
+	if err != nil {
+		return utils.SendError(c, 500, utils.CodeInternal, "Failed to update category")
 	}
 
 	return c.JSON(fiber.Map{
-		"message": "Category updated",
-		"name":    req.Name,
+		"message":   "Category updated",
+		"id":        category.ID,
+		"name":      category.Name,
+		"parent_id": category.ParentCategoryID,
 	})
 }
 
-// DeleteCategory deletes a category
+// DeleteCategory deletes a category. Its products become uncategorized and
+// its subcategories move up to its parent.
 func (h *ProductHandler) DeleteCategory(c *fiber.Ctx) error {
 	shopID := c.Locals("shop_id").(uint)
 	categoryID, err := c.ParamsInt("id")
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid category ID"})
+		return utils.SendError(c, 400, utils.CodeInvalidRequest, "Invalid category ID")
 	}
 
-	// Get the placeholder product
-	product, err := h.productRepo.GetByID(uint(categoryID))
-	if err != nil || product.ShopID != shopID {
-		return c.Status(404).JSON(fiber.Map{"error": "Category not found"})
+	if h.categoryRepo == nil {
+		return utils.SendError(c, fiber.StatusServiceUnavailable, utils.CodeServiceUnavailable, "Categories not available")
 	}
 
-	categoryName := product.Category
-
-	// Update all products with this category to uncategorized
-	products, _ := h.productRepo.GetByShopID(shopID)
-	for i := range products {
-		if products[i].Category == categoryName {
-			products[i].Category = ""
-			h.productRepo.Update(&products[i])
-		}
+	category, err := h.categoryRepo.GetByID(uint(categoryID))
+	if err != nil || category.ShopID != shopID {
+		return utils.SendError(c, 404, utils.CodeNotFound, "Category not found")
 	}
 
-	// Delete the placeholder product
-	h.productRepo.Delete(uint(categoryID))
+	if err := h.categoryRepo.Delete(category.ID); err != nil {
+		return utils.SendError(c, 500, utils.CodeInternal, "Failed to delete category")
+	}
 
 	return c.JSON(fiber.Map{
 		"message": "Category deleted",
@@ -1,14 +1,25 @@
 package handlers
 
 import (
+	"errors"
+
 	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/email"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/otp"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
 	"github.com/gofiber/fiber/v2"
 )
 
 // AuthHandler handles authentication HTTP requests
 type AuthHandler struct {
 	authService *services.AuthService
+
+	// loginEvents records logins; nil disables the audit trail
+	loginEvents   *repository.LoginEventRepository
+	countryHeader string
+	emailSvc      *email.Service
 }
 
 // NewAuthHandler creates a new auth handler
@@ -36,26 +47,18 @@ type LoginRequest struct {
 func (h *AuthHandler) Register(c *fiber.Ctx) error {
 	var req RegisterRequest
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "Invalid request body")
 	}
 
 	// Validate required fields
 	if req.Phone == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Phone number is required",
-		})
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "Phone number is required")
 	}
 	if req.Password == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Password is required",
-		})
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "Password is required")
 	}
 	if len(req.Password) < 6 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Password must be at least 6 characters",
-		})
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "Password must be at least 6 characters")
 	}
 
 	shop := &models.Shop{
@@ -75,21 +78,15 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 	err := h.authService.Register(shop, req.Password)
 	if err != nil {
 		if err == services.ErrShopExists {
-			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
-				"error": "Shop already exists with this phone or email",
-			})
+			return utils.SendError(c, fiber.StatusConflict, utils.CodeDuplicateEntry, "Shop already exists with this phone or email")
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to create shop",
-		})
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to create shop")
 	}
 
 	// Generate token
 	_, token, _, err := h.authService.Login(req.Phone, req.Password)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to generate token",
-		})
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to generate token")
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
@@ -103,21 +100,15 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	var req LoginRequest
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "Invalid request body")
 	}
 
 	// Validate required fields
 	if req.Phone == "" && req.Email == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Phone or email is required",
-		})
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "Phone or email is required")
 	}
 	if req.Password == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Password is required",
-		})
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "Password is required")
 	}
 
 	// Determine login identifier
@@ -128,15 +119,15 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 
 	shop, token, account, err := h.authService.Login(identifier, req.Password)
 	if err != nil {
+		if err == services.ErrInvalidCredentials || err == services.ErrAccountLocked {
+			h.recordLogin(c, h.authService.FindShop(identifier), nil, models.LoginMethodPassword, false)
+		}
 		if err == services.ErrInvalidCredentials {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Invalid phone/email or password",
-			})
+			return utils.SendError(c, fiber.StatusUnauthorized, utils.CodeInvalidCredentials, "Invalid phone/email or password")
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Login failed",
-		})
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Login failed")
 	}
+	h.recordLogin(c, shop, account, models.LoginMethodPassword, true)
 
 	return c.JSON(fiber.Map{
 		"shop":    shop,
@@ -165,33 +156,23 @@ func (h *AuthHandler) ChangePassword(c *fiber.Ctx) error {
 
 	var req ChangePasswordRequest
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "Invalid request body")
 	}
 
 	if req.OldPassword == "" || req.NewPassword == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Old and new passwords are required",
-		})
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "Old and new passwords are required")
 	}
 
 	if len(req.NewPassword) < 6 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "New password must be at least 6 characters",
-		})
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "New password must be at least 6 characters")
 	}
 
 	err := h.authService.ChangePassword(shopID, req.OldPassword, req.NewPassword)
 	if err != nil {
 		if err == services.ErrInvalidCredentials {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Current password is incorrect",
-			})
+			return utils.SendError(c, fiber.StatusUnauthorized, utils.CodeInvalidCredentials, "Current password is incorrect")
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to change password",
-		})
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to change password")
 	}
 
 	return c.JSON(fiber.Map{
@@ -201,58 +182,138 @@ func (h *AuthHandler) ChangePassword(c *fiber.Ctx) error {
 
 // OTPRequest represents an OTP request
 type OTPRequest struct {
-	Phone string `json:"phone"`
+	Phone   string `json:"phone"`
+	Email   string `json:"email"`
+	Purpose string `json:"purpose"` // login (default) or password_reset
 }
 
 // OTPVerifyRequest represents OTP verification
 type OTPVerifyRequest struct {
 	Phone string `json:"phone"`
+	Email string `json:"email"`
 	Code  string `json:"code"`
 }
 
-// SendOTP sends OTP to phone number
+// ResetPasswordRequest sets a new password with a password_reset code
+type ResetPasswordRequest struct {
+	Phone       string `json:"phone"`
+	Email       string `json:"email"`
+	Code        string `json:"code"`
+	NewPassword string `json:"new_password"`
+}
+
+// sendOTPError maps one-time code failures to responses
+func sendOTPError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidCredentials),
+		errors.Is(err, otp.ErrOTPNotFound),
+		errors.Is(err, otp.ErrOTPExpired),
+		errors.Is(err, otp.ErrOTPInvalid),
+		errors.Is(err, otp.ErrOTPTooManyAttempts):
+		return utils.SendError(c, fiber.StatusUnauthorized, utils.CodeInvalidCredentials, err.Error())
+	case errors.Is(err, otp.ErrOTPThrottled):
+		return utils.SendError(c, fiber.StatusTooManyRequests, utils.CodeRateLimited, err.Error())
+	case errors.Is(err, services.ErrOTPUnavailable), errors.Is(err, otp.ErrOTPNoChannel):
+		return utils.SendError(c, fiber.StatusServiceUnavailable, utils.CodeServiceUnavailable, "One-time codes are not available")
+	default:
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to process code")
+	}
+}
+
+// SendOTP sends a login or password reset code by SMS to a phone, or by email
 func (h *AuthHandler) SendOTP(c *fiber.Ctx) error {
 	var req OTPRequest
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "Invalid request body")
 	}
 
-	if req.Phone == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Phone number is required",
-		})
+	identifier := req.Phone
+	if identifier == "" {
+		identifier = req.Email
+	}
+	if identifier == "" {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "Phone or email is required")
+	}
+	if req.Purpose == "" {
+		req.Purpose = otp.PurposeLogin
 	}
 
-	// Use the auth service to send OTP
-	// For now, return success - the actual implementation would use OTP service
+	err := h.authService.SendOTP(identifier, req.Purpose)
+	switch {
+	case errors.Is(err, services.ErrInvalidOTPPurpose):
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, err.Error())
+	case err != nil && !errors.Is(err, services.ErrInvalidCredentials) && !errors.Is(err, otp.ErrOTPThrottled):
+		return sendOTPError(c, err)
+	}
+
+	// The same reply whether or not the shop exists, so this can't be used
+	// to find registered numbers. Only registered numbers are ever
+	// throttled, so a throttled request gets this reply too.
 	return c.JSON(fiber.Map{
-		"message": "OTP sent successfully",
-		"note":    "Configure OTP service for production",
+		"message":            "If the account exists, a code has been sent",
+		"expires_in_minutes": otp.OTPExpiryMinutes,
 	})
 }
 
-// VerifyOTP verifies OTP code
+// VerifyOTP logs in with a code from SendOTP
 func (h *AuthHandler) VerifyOTP(c *fiber.Ctx) error {
 	var req OTPVerifyRequest
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "Invalid request body")
+	}
+
+	identifier := req.Phone
+	if identifier == "" {
+		identifier = req.Email
+	}
+	if identifier == "" || req.Code == "" {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "Phone or email and code are required")
+	}
+
+	shop, token, err := h.authService.LoginWithOTP(identifier, req.Code)
+	if err != nil {
+		if errors.Is(err, otp.ErrOTPInvalid) || errors.Is(err, otp.ErrOTPExpired) ||
+			errors.Is(err, otp.ErrOTPNotFound) || errors.Is(err, otp.ErrOTPTooManyAttempts) {
+			h.recordLogin(c, h.authService.FindShop(identifier), nil, models.LoginMethodOTP, false)
+		}
+		return sendOTPError(c, err)
+	}
+	var account *models.Account
+	if shop.AccountID > 0 {
+		account, _ = h.authService.GetAccountByID(shop.AccountID)
+	}
+	h.recordLogin(c, shop, account, models.LoginMethodOTP, true)
+
+	return c.JSON(fiber.Map{
+		"shop":  shop,
+		"token": token,
+	})
+}
+
+// ResetPassword sets a new password with a password_reset code from SendOTP
+func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
+	var req ResetPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "Invalid request body")
+	}
+
+	identifier := req.Phone
+	if identifier == "" {
+		identifier = req.Email
+	}
+	if identifier == "" || req.Code == "" {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "Phone or email and code are required")
+	}
+	if len(req.NewPassword) < 6 {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "New password must be at least 6 characters")
 	}
 
-	if req.Phone == "" || req.Code == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Phone and code are required",
-		})
+	if err := h.authService.ResetPasswordWithOTP(identifier, req.Code, req.NewPassword); err != nil {
+		return sendOTPError(c, err)
 	}
 
-	// Use the auth service to verify OTP
-	// For now, return success - the actual implementation would use OTP service
 	return c.JSON(fiber.Map{
-		"message": "OTP verified successfully",
-		"note":    "Configure OTP service for production",
+		"message": "Password reset successfully",
 	})
 }
 
@@ -260,9 +321,7 @@ func (h *AuthHandler) VerifyOTP(c *fiber.Ctx) error {
 func (h *AuthHandler) GetTwoFactorStatus(c *fiber.Ctx) error {
 	userID := c.Locals("user_id")
 	if userID == nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Unauthorized",
-		})
+		return utils.SendError(c, fiber.StatusUnauthorized, utils.CodeUnauthorized, "Unauthorized")
 	}
 
 	// TODO: Check actual 2FA status from database
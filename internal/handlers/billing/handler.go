@@ -1,15 +1,25 @@
 package billing
 
 import (
+	"errors"
+	"fmt"
+	"strconv"
+
 	"github.com/C9b3rD3vi1/DukaPOS/internal/config"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	billingsvc "github.com/C9b3rD3vi1/DukaPOS/internal/services/billing"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 )
 
 type Handler struct {
-	db  *gorm.DB
-	cfg *config.Config
+	db          *gorm.DB
+	cfg         *config.Config
+	billing     *billingsvc.Service
+	mpesa       *mpesa.Service
+	invoiceRepo *repository.InvoiceRepository
 }
 
 func NewHandler(db *gorm.DB, cfg *config.Config) *Handler {
@@ -19,11 +29,41 @@ func NewHandler(db *gorm.DB, cfg *config.Config) *Handler {
 	}
 }
 
+// SetBilling enables paid upgrades over M-Pesa and invoices
+func (h *Handler) SetBilling(billing *billingsvc.Service, mpesaSvc *mpesa.Service, invoiceRepo *repository.InvoiceRepository) {
+	h.billing = billing
+	h.mpesa = mpesaSvc
+	h.invoiceRepo = invoiceRepo
+}
+
 func (h *Handler) RegisterRoutes(app fiber.Router) {
 	billing := app.Group("/billing")
 	billing.Get("/plans", h.GetPlans)
 	billing.Get("/current", h.GetCurrentPlan)
 	billing.Post("/upgrade", h.UpgradePlan)
+	billing.Get("/invoices", h.ListInvoices)
+	billing.Get("/invoices/:id/download", h.DownloadInvoice)
+}
+
+// currentAccountID returns the logged in account, or the account owning the shop
+// for shop tokens
+func currentAccountID(c *fiber.Ctx) uint {
+	if account, ok := c.Locals("account").(*models.Account); ok && account != nil {
+		return account.ID
+	}
+	if shop, ok := c.Locals("shop").(*models.Shop); ok && shop != nil {
+		return shop.AccountID
+	}
+	return 0
+}
+
+func findPlan(id string) (Plan, bool) {
+	for _, p := range plans {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return Plan{}, false
 }
 
 type Plan struct {
@@ -79,8 +119,8 @@ func (h *Handler) GetPlans(c *fiber.Ctx) error {
 }
 
 func (h *Handler) GetCurrentPlan(c *fiber.Ctx) error {
-	accountID := c.Locals("account_id")
-	if accountID == nil {
+	accountID := currentAccountID(c)
+	if accountID == 0 {
 		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
 	}
 
@@ -118,9 +158,13 @@ func (h *Handler) GetCurrentPlan(c *fiber.Ctx) error {
 	})
 }
 
+// UpgradePlan changes the account's plan. Moving to the free plan applies
+// at once; a paid plan sends an M-Pesa prompt to phone and applies when the
+// payment completes, issuing an invoice.
 func (h *Handler) UpgradePlan(c *fiber.Ctx) error {
 	type UpgradeRequest struct {
 		PlanID string `json:"plan_id"`
+		Phone  string `json:"phone"`
 	}
 
 	var req UpgradeRequest
@@ -128,19 +172,12 @@ func (h *Handler) UpgradePlan(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
 	}
 
-	accountID := c.Locals("account_id")
-	if accountID == nil {
+	accountID := currentAccountID(c)
+	if accountID == 0 {
 		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
 	}
 
-	validPlan := false
-	for _, p := range plans {
-		if p.ID == req.PlanID {
-			validPlan = true
-			break
-		}
-	}
-
+	plan, validPlan := findPlan(req.PlanID)
 	if !validPlan {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid plan_id"})
 	}
@@ -151,9 +188,18 @@ func (h *Handler) UpgradePlan(c *fiber.Ctx) error {
 	}
 
 	oldPlan := account.Plan
-	account.Plan = models.PlanType(req.PlanID)
+	if plan.Price > 0 {
+		return h.payForPlan(c, &account, plan, req.Phone)
+	}
 
-	if err := h.db.Save(&account).Error; err != nil {
+	newPlan := models.PlanType(req.PlanID)
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&account).Update("plan", newPlan).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Shop{}).Where("account_id = ?", account.ID).Update("plan", newPlan).Error
+	})
+	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "failed to upgrade plan"})
 	}
 
@@ -164,8 +210,123 @@ func (h *Handler) UpgradePlan(c *fiber.Ctx) error {
 	})
 }
 
-func (h *Handler) GetHistory(c *fiber.Ctx) error {
+// payForPlan sends the STK prompt for a paid plan, charged to the platform
+// shortcode from the account's current shop
+func (h *Handler) payForPlan(c *fiber.Ctx, account *models.Account, plan Plan, phone string) error {
+	if h.mpesa == nil || h.billing == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "M-Pesa payments are not available"})
+	}
+	if phone == "" {
+		phone = account.Phone
+	}
+
+	shop, ok := c.Locals("shop").(*models.Shop)
+	if !ok || shop == nil || shop.AccountID != account.ID {
+		return c.Status(400).JSON(fiber.Map{"error": "no shop selected"})
+	}
+
+	payment, result, err := h.mpesa.InitiateSTKPush(c.Context(), &mpesa.PaymentRequest{
+		Phone:            phone,
+		Amount:           plan.Price,
+		AccountReference: "PLAN",
+		Description:      fmt.Sprintf("DukaPOS %s plan", plan.Name),
+		ShopID:           shop.ID,
+		Plan:             models.PlanType(plan.ID),
+	})
+	if err != nil {
+		if errors.Is(err, mpesa.ErrMpesaNotConfigured) {
+			return c.Status(503).JSON(fiber.Map{"error": "M-Pesa payments are not available"})
+		}
+		if errors.Is(err, mpesa.ErrRateLimited) {
+			c.Set("Retry-After", strconv.Itoa(mpesa.RetryAfterSeconds(err)))
+			return c.Status(429).JSON(fiber.Map{"error": mpesa.RateLimitMessage})
+		}
+		if payment == nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(502).JSON(fiber.Map{"error": err.Error(), "payment_id": payment.ID})
+	}
+
+	response := fiber.Map{
+		"message":    "confirm the payment on your phone to upgrade",
+		"new_plan":   plan.ID,
+		"amount":     payment.Amount,
+		"payment_id": payment.ID,
+	}
+	if result != nil {
+		response["checkout_request_id"] = result.CheckoutRequestID
+	} else {
+		response["checkout_request_id"] = payment.CheckoutRequestID
+	}
+	return c.Status(202).JSON(response)
+}
+
+// ListInvoices lists the account's billing invoices, newest first
+func (h *Handler) ListInvoices(c *fiber.Ctx) error {
+	accountID := currentAccountID(c)
+	if accountID == 0 {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	if h.invoiceRepo == nil {
+		return c.JSON(fiber.Map{"data": []models.Invoice{}, "total": 0})
+	}
+
+	limit := c.QueryInt("limit", 20)
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	offset := c.QueryInt("offset", 0)
+	if offset < 0 {
+		offset = 0
+	}
+
+	invoices, total, err := h.invoiceRepo.GetByAccount(accountID, limit, offset)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to load invoices"})
+	}
+	for i := range invoices {
+		invoices[i].DownloadURL = fmt.Sprintf("/api/v1/billing/invoices/%d/download", invoices[i].ID)
+	}
+
 	return c.JSON(fiber.Map{
-		"data": []interface{}{},
+		"data":   invoices,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
 	})
 }
+
+// DownloadInvoice sends an invoice's PDF
+func (h *Handler) DownloadInvoice(c *fiber.Ctx) error {
+	accountID := currentAccountID(c)
+	if accountID == 0 {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	if h.invoiceRepo == nil || h.billing == nil {
+		return c.Status(404).JSON(fiber.Map{"error": "invoice not found"})
+	}
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid invoice id"})
+	}
+
+	invoice, err := h.invoiceRepo.GetByID(uint(id))
+	if err != nil || invoice.AccountID != accountID {
+		return c.Status(404).JSON(fiber.Map{"error": "invoice not found"})
+	}
+
+	data, err := h.billing.InvoicePDF(invoice)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to generate invoice"})
+	}
+
+	c.Set("Content-Type", "application/pdf")
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.pdf", invoice.Number))
+	return c.Send(data)
+}
+
+// GetHistory lists past plan payments, which are the account's invoices
+func (h *Handler) GetHistory(c *fiber.Ctx) error {
+	return h.ListInvoices(c)
+}
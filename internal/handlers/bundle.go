@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	webhooksvc "github.com/C9b3rD3vi1/DukaPOS/internal/services/webhook"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/websocket"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// BundleResponse is a bundle with what it costs and how many can be sold
+type BundleResponse struct {
+	ID           uint                   `json:"id"`
+	Name         string                 `json:"name"`
+	SellingPrice float64                `json:"selling_price"`
+	CostPrice    float64                `json:"cost_price"` // of the components
+	Profit       float64                `json:"profit"`
+	Available    int                    `json:"available"` // bundles the components' stock makes
+	Components   []models.ProductBundle `json:"components"`
+}
+
+// ListBundles lists the shop's bundles with their components
+// GET /api/v1/bundles
+func (h *ProductHandler) ListBundles(c *fiber.Ctx) error {
+	shopID := c.Locals("shop_id").(uint)
+
+	if h.bundleRepo == nil {
+		return utils.SendError(c, fiber.StatusServiceUnavailable, utils.CodeServiceUnavailable, "Bundles not available")
+	}
+
+	bundles, err := h.bundleRepo.GetBundles(shopID)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to list bundles")
+	}
+
+	response := make([]BundleResponse, 0, len(bundles))
+	for _, bundle := range bundles {
+		components, err := h.bundleRepo.GetComponents(bundle.ID)
+		if err != nil {
+			return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to list bundles")
+		}
+		cost := models.BundleCost(components)
+		response = append(response, BundleResponse{
+			ID:           bundle.ID,
+			Name:         bundle.Name,
+			SellingPrice: bundle.SellingPrice,
+			CostPrice:    cost,
+			Profit:       bundle.SellingPrice - cost,
+			Available:    models.BundleAvailable(components),
+			Components:   components,
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// GetBundle returns the components of a bundle product
+// GET /api/v1/products/:id/bundle
+func (h *ProductHandler) GetBundle(c *fiber.Ctx) error {
+	product, err := h.shopProduct(c)
+	if err != nil {
+		return err
+	}
+
+	if h.bundleRepo == nil {
+		return utils.SendError(c, fiber.StatusServiceUnavailable, utils.CodeServiceUnavailable, "Bundles not available")
+	}
+
+	components, err := h.bundleRepo.GetComponents(product.ID)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get bundle")
+	}
+
+	return c.JSON(fiber.Map{
+		"product_id": product.ID,
+		"name":       product.Name,
+		"is_bundle":  product.IsBundle,
+		"components": components,
+	})
+}
+
+// SetBundle sets the components of a bundle product. An empty list turns the
+// product back into a regular stocked product.
+// POST /api/v1/products/:id/bundle
+func (h *ProductHandler) SetBundle(c *fiber.Ctx) error {
+	product, err := h.shopProduct(c)
+	if err != nil {
+		return err
+	}
+
+	if h.bundleRepo == nil {
+		return utils.SendError(c, fiber.StatusServiceUnavailable, utils.CodeServiceUnavailable, "Bundles not available")
+	}
+
+	type ComponentRequest struct {
+		ProductID uint `json:"product_id"`
+		Quantity  int  `json:"quantity"`
+	}
+	var req struct {
+		Components []ComponentRequest `json:"components"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "Invalid request body")
+	}
+
+	seen := make(map[uint]bool)
+	components := make([]models.ProductBundle, 0, len(req.Components))
+	for _, comp := range req.Components {
+		if comp.Quantity <= 0 {
+			return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "Component quantity must be greater than 0")
+		}
+		if comp.ProductID == product.ID || seen[comp.ProductID] {
+			return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "A bundle can't contain itself or the same product twice")
+		}
+		seen[comp.ProductID] = true
+
+		component, err := h.productRepo.GetByID(comp.ProductID)
+		if err != nil || component.ShopID != product.ShopID {
+			return utils.SendError(c, fiber.StatusBadRequest, utils.CodeProductNotFound, fmt.Sprintf("Component product %d not found", comp.ProductID))
+		}
+		if component.IsBundle {
+			return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, fmt.Sprintf("%s is a bundle and can't be a component", component.Name))
+		}
+
+		components = append(components, models.ProductBundle{
+			ComponentProductID: component.ID,
+			Quantity:           comp.Quantity,
+		})
+	}
+
+	if err := h.bundleRepo.SetComponents(product.ID, components); err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to save bundle")
+	}
+
+	saved, _ := h.bundleRepo.GetComponents(product.ID)
+	return c.JSON(fiber.Map{
+		"product_id": product.ID,
+		"name":       product.Name,
+		"is_bundle":  len(components) > 0,
+		"components": saved,
+	})
+}
+
+// shopProduct loads the :id product and checks it belongs to the caller's shop.
+// Errors are UserErrors for the global error handler to render.
+func (h *ProductHandler) shopProduct(c *fiber.Ctx) (*models.Product, error) {
+	shopID := c.Locals("shop_id").(uint)
+	productID, err := c.ParamsInt("id")
+	if err != nil {
+		return nil, utils.NewUserError(utils.CodeInvalidRequest, "Invalid product ID")
+	}
+
+	product, err := h.productRepo.GetByID(uint(productID))
+	if err != nil {
+		return nil, utils.NewUserError(utils.CodeProductNotFound, "Product not found")
+	}
+
+	if product.ShopID != shopID {
+		return nil, utils.NewUserError(utils.CodeForbidden, "Access denied")
+	}
+
+	return product, nil
+}
+
+// createBundleSale sells a bundle by recording one sale per component
+func (h *SaleHandler) createBundleSale(c *fiber.Ctx, bundle *models.Product, quantity int, unitPrice float64, method string) error {
+	components, err := h.bundleRepo.GetComponents(bundle.ID)
+	if err != nil || len(components) == 0 {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "Bundle has no components")
+	}
+
+	if short, needed := models.BundleShortage(components, quantity); short != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":     fmt.Sprintf("Insufficient stock for %s", short.Component.Name),
+			"code":      utils.CodeInsufficientStock,
+			"available": short.Component.CurrentStock,
+			"required":  needed,
+		})
+	}
+
+	price := bundle.SellingPrice
+	if unitPrice > 0 {
+		price = unitPrice
+	}
+
+	paymentMethod := models.PaymentCash
+	if method == "mpesa" {
+		paymentMethod = models.PaymentMpesa
+	}
+
+	total, adjustment := h.rounding(bundle.ShopID, paymentMethod).Round(price * float64(quantity))
+	sales := models.BuildBundleSales(bundle, components, quantity, total, paymentMethod)
+	sales[len(sales)-1].RoundingAdjustment = adjustment
+
+	if err := h.bundleRepo.RecordSales(sales); err != nil {
+		if errors.Is(err, repository.ErrInsufficientStock) {
+			return utils.SendError(c, fiber.StatusConflict, utils.CodeConflict, "Stock changed while recording the sale, please retry")
+		}
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to create sale")
+	}
+	for i, sale := range sales {
+		soldOut, _ := h.productRepo.DeactivateIfOutOfStock(sale.ProductID)
+		webhooksvc.TriggerProductDeactivated(soldOut, "out_of_stock")
+		component := components[i].Component
+		websocket.NotifySaleCreated(sale, &component, component.CurrentStock-sale.Quantity)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"bundle_id":           bundle.ID,
+		"quantity":            quantity,
+		"total_amount":        total,
+		"rounding_adjustment": adjustment,
+		"sales":               sales,
+	})
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetCrossSells returns the products most often bought on the same days as
+// this one, most frequent first
+// GET /api/v1/products/:id/cross-sells?limit=5
+func (h *ProductHandler) GetCrossSells(c *fiber.Ctx) error {
+	product, err := h.shopProduct(c)
+	if err != nil {
+		return err
+	}
+
+	if h.crossSells == nil {
+		return utils.SendError(c, fiber.StatusServiceUnavailable, utils.CodeServiceUnavailable, "Cross-sells not available")
+	}
+
+	limit := c.QueryInt("limit", 5)
+	if limit < 1 || limit > 20 {
+		limit = 5
+	}
+
+	products, err := h.crossSells.Suggest(product.ShopID, product.ID, limit)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get cross-sells")
+	}
+
+	return c.JSON(fiber.Map{
+		"product_id": product.ID,
+		"name":       product.Name,
+		"data":       products,
+	})
+}
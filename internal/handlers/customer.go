@@ -160,9 +160,10 @@ func (h *CustomerHandler) Update(c *fiber.Ctx) error {
 	}
 
 	type Request struct {
-		Name  string `json:"name"`
-		Phone string `json:"phone"`
-		Email string `json:"email"`
+		Name      string `json:"name"`
+		Phone     string `json:"phone"`
+		Email     string `json:"email"`
+		SMSOptOut *bool  `json:"sms_opt_out"`
 	}
 
 	var req Request
@@ -181,6 +182,9 @@ func (h *CustomerHandler) Update(c *fiber.Ctx) error {
 	if req.Email != "" {
 		customer.Email = req.Email
 	}
+	if req.SMSOptOut != nil {
+		customer.SMSOptOut = *req.SMSOptOut
+	}
 
 	if err := h.customerRepo.Update(customer); err != nil {
 		return c.Status(500).JSON(fiber.Map{
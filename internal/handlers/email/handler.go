@@ -1,25 +1,53 @@
 package emailhandler
 
 import (
+	"errors"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/services/email"
 	"github.com/gofiber/fiber/v2"
 )
 
 type Handler struct {
 	emailSvc *email.Service
+	shopRepo *repository.ShopRepository
+
+	reportMailer *email.ReportMailer
+	eventToken   string
 }
 
 func New(emailSvc *email.Service) *Handler {
 	return &Handler{emailSvc: emailSvc}
 }
 
+// SetReportMailer enables report email settings, on-demand reports and
+// bounce handling
+func (h *Handler) SetReportMailer(mailer *email.ReportMailer) {
+	h.reportMailer = mailer
+}
+
+// SetShopRepo lets sends use the shop's branded templates
+func (h *Handler) SetShopRepo(shopRepo *repository.ShopRepository) {
+	h.shopRepo = shopRepo
+}
+
+// SetEventWebhookToken requires SendGrid event webhooks to carry ?token=token
+func (h *Handler) SetEventWebhookToken(token string) {
+	h.eventToken = token
+}
+
+// SendEmail sends an email, either written out in full or rendered from
+// one of the shop's templates with variables
 func (h *Handler) SendEmail(c *fiber.Ctx) error {
 	type SendRequest struct {
-		To      string `json:"to"`
-		ToName  string `json:"to_name"`
-		Subject string `json:"subject"`
-		Body    string `json:"body"`
-		HTML    string `json:"html"`
+		To        string            `json:"to"`
+		ToName    string            `json:"to_name"`
+		Subject   string            `json:"subject"`
+		Body      string            `json:"body"`
+		HTML      string            `json:"html"`
+		Template  string            `json:"template"`
+		Variables map[string]string `json:"variables"`
 	}
 
 	var req SendRequest
@@ -27,6 +55,13 @@ func (h *Handler) SendEmail(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
 	}
 
+	if req.Template != "" {
+		if req.To == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "to required"})
+		}
+		return h.sendTemplate(c, req.To, req.Template, req.Variables)
+	}
+
 	if req.To == "" || req.Subject == "" || req.Body == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "to, subject, and body required"})
 	}
@@ -45,6 +80,23 @@ func (h *Handler) SendEmail(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"success": true})
 }
 
+func (h *Handler) sendTemplate(c *fiber.Ctx, to, key string, vars map[string]string) error {
+	var shop *models.Shop
+	if shopID, ok := c.Locals("shop_id").(uint); ok && h.shopRepo != nil {
+		shop, _ = h.shopRepo.GetByID(shopID)
+	}
+
+	err := h.emailSvc.SendTemplate(shop, to, key, vars)
+	if errors.Is(err, email.ErrUnknownTemplate) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"success": true})
+}
+
 func (h *Handler) SendWelcomeEmail(c *fiber.Ctx) error {
 	type Request struct {
 		To       string `json:"to"`
@@ -76,4 +128,8 @@ func (h *Handler) RegisterRoutes(protected fiber.Router) {
 	emailRoutes := protected.Group("/email")
 	emailRoutes.Post("/send", h.SendEmail)
 	emailRoutes.Post("/welcome", h.SendWelcomeEmail)
+	emailRoutes.Get("/templates", h.ListTemplates)
+	emailRoutes.Get("/templates/:key", h.GetTemplate)
+	emailRoutes.Put("/templates/:key", h.UpdateTemplate)
+	emailRoutes.Delete("/templates/:key", h.ResetTemplate)
 }
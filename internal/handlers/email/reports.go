@@ -0,0 +1,126 @@
+package emailhandler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/email"
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetReportSettings returns the shop's report email settings
+func (h *Handler) GetReportSettings(c *fiber.Ctx) error {
+	if h.reportMailer == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "report emails not available"})
+	}
+	shopID := c.Locals("shop_id").(uint)
+
+	pref, err := h.reportMailer.GetPreference(shopID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to get report email settings"})
+	}
+	return c.JSON(fiber.Map{"data": pref})
+}
+
+// UpdateReportSettings sets whether, how often and to whom reports are emailed
+func (h *Handler) UpdateReportSettings(c *fiber.Ctx) error {
+	if h.reportMailer == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "report emails not available"})
+	}
+	shopID := c.Locals("shop_id").(uint)
+
+	type Request struct {
+		Enabled    *bool   `json:"enabled"`
+		Frequency  string  `json:"frequency"`  // daily or weekly
+		Recipients *string `json:"recipients"` // comma-separated; empty uses the shop's email
+	}
+
+	var req Request
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	pref, err := h.reportMailer.GetPreference(shopID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to get report email settings"})
+	}
+	if req.Enabled != nil {
+		pref.Enabled = *req.Enabled
+	}
+	if req.Frequency != "" {
+		pref.Frequency = req.Frequency
+	}
+	if req.Recipients != nil {
+		pref.Recipients = *req.Recipients
+	}
+
+	err = h.reportMailer.SavePreference(pref)
+	if errors.Is(err, email.ErrInvalidFrequency) || errors.Is(err, email.ErrInvalidRecipients) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to save report email settings"})
+	}
+	return c.JSON(fiber.Map{"data": pref})
+}
+
+// SendReport emails the report for the day, week or month so far right away
+func (h *Handler) SendReport(c *fiber.Ctx) error {
+	if h.reportMailer == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "report emails not available"})
+	}
+	shopID := c.Locals("shop_id").(uint)
+
+	type Request struct {
+		Period string `json:"period"` // day, week or month (default)
+	}
+
+	var req Request
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+		}
+	}
+
+	sentTo, err := h.reportMailer.SendNow(shopID, req.Period)
+	if errors.Is(err, email.ErrInvalidPeriod) || errors.Is(err, email.ErrNoRecipients) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "failed to email report: " + err.Error()})
+	}
+	return c.JSON(fiber.Map{"success": true, "sent_to": sentTo})
+}
+
+// EventWebhook receives SendGrid delivery events and turns off report
+// emails to addresses that bounce. Events are refused until a token is
+// configured.
+func (h *Handler) EventWebhook(c *fiber.Ctx) error {
+	if h.eventToken == "" || subtle.ConstantTimeCompare([]byte(c.Query("token")), []byte(h.eventToken)) != 1 {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Invalid callback token"})
+	}
+
+	var events []struct {
+		Email  string `json:"email"`
+		Event  string `json:"event"`
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&events); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	if h.reportMailer == nil {
+		return c.SendStatus(fiber.StatusOK)
+	}
+	for _, e := range events {
+		if e.Event != "bounce" && e.Event != "dropped" {
+			continue
+		}
+		if _, err := h.reportMailer.HandleBounce(e.Email, e.Reason); err != nil {
+			log.Printf("❌ Failed to handle bounce for %s: %v", e.Email, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to record bounce"})
+		}
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
@@ -0,0 +1,87 @@
+package emailhandler
+
+import (
+	"errors"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/email"
+	"github.com/gofiber/fiber/v2"
+)
+
+// templateResponse is a template as the shop's emails use it
+type templateResponse struct {
+	*models.EmailTemplate
+	Customized bool `json:"customized"`
+}
+
+// ListTemplates lists every email template, showing which the shop has
+// customised
+func (h *Handler) ListTemplates(c *fiber.Ctx) error {
+	shopID := c.Locals("shop_id").(uint)
+
+	templates := make([]templateResponse, 0, len(models.EmailTemplateKeys))
+	for _, key := range models.EmailTemplateKeys {
+		tmpl, customized, err := h.emailSvc.Templates().Get(shopID, key)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to get email templates"})
+		}
+		templates = append(templates, templateResponse{tmpl, customized})
+	}
+	return c.JSON(fiber.Map{"data": templates})
+}
+
+// GetTemplate returns the template the shop's emails use for :key
+func (h *Handler) GetTemplate(c *fiber.Ctx) error {
+	shopID := c.Locals("shop_id").(uint)
+
+	tmpl, customized, err := h.emailSvc.Templates().Get(shopID, c.Params("key"))
+	if errors.Is(err, email.ErrUnknownTemplate) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to get email template"})
+	}
+	return c.JSON(fiber.Map{"data": templateResponse{tmpl, customized}})
+}
+
+// UpdateTemplate saves the shop's own version of the :key template
+func (h *Handler) UpdateTemplate(c *fiber.Ctx) error {
+	shopID := c.Locals("shop_id").(uint)
+
+	type Request struct {
+		Subject string `json:"subject"`
+		HTML    string `json:"html"`
+		Text    string `json:"text"` // optional; derived from html when empty
+	}
+
+	var req Request
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	tmpl, err := h.emailSvc.Templates().Save(shopID, c.Params("key"), req.Subject, req.HTML, req.Text)
+	switch {
+	case errors.Is(err, email.ErrUnknownTemplate):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, email.ErrInvalidTemplate):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to save email template"})
+	}
+	return c.JSON(fiber.Map{"data": templateResponse{tmpl, true}})
+}
+
+// ResetTemplate removes the shop's own version of the :key template so
+// the default is used again
+func (h *Handler) ResetTemplate(c *fiber.Ctx) error {
+	shopID := c.Locals("shop_id").(uint)
+
+	err := h.emailSvc.Templates().Reset(shopID, c.Params("key"))
+	if errors.Is(err, email.ErrUnknownTemplate) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to reset email template"})
+	}
+	return h.GetTemplate(c)
+}
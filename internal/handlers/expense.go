@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ExpenseHandler handles shop expense HTTP requests
+type ExpenseHandler struct {
+	expenseRepo *repository.ExpenseRepository
+}
+
+// NewExpenseHandler creates a new expense handler
+func NewExpenseHandler(expenseRepo *repository.ExpenseRepository) *ExpenseHandler {
+	return &ExpenseHandler{expenseRepo: expenseRepo}
+}
+
+// CreateExpenseRequest is an expense to record. Repeat, when set, records
+// it again every day, week or month.
+type CreateExpenseRequest struct {
+	Amount        float64 `json:"amount"`
+	Category      string  `json:"category"`
+	Note          string  `json:"note"`
+	PaymentMethod string  `json:"payment_method"` // cash, mpesa, card or bank; cash when empty
+	SpentAt       string  `json:"spent_at"`       // YYYY-MM-DD; today when empty
+	Repeat        string  `json:"repeat"`         // daily, weekly or monthly
+}
+
+// List returns the shop's expenses with their total and totals by
+// category. from and to are inclusive dates (YYYY-MM-DD); the default is
+// this month.
+// GET /api/v1/expenses?from=&to=
+func (h *ExpenseHandler) List(c *fiber.Ctx) error {
+	shopID := c.Locals("shop_id").(uint)
+
+	start, end, err := reportRange(c)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, err.Error())
+	}
+
+	expenses, err := h.expenseRepo.List(shopID, start, end)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get expenses")
+	}
+	byCategory, err := h.expenseRepo.TotalsByCategory(shopID, start, end)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get expenses")
+	}
+
+	var total float64
+	for _, expense := range expenses {
+		total += expense.Amount
+	}
+	return c.JSON(fiber.Map{
+		"from":        start.Format("2006-01-02"),
+		"to":          end.AddDate(0, 0, -1).Format("2006-01-02"),
+		"data":        expenses,
+		"count":       len(expenses),
+		"total":       total,
+		"by_category": byCategory,
+	})
+}
+
+// Create records an expense, and with repeat, a recurring expense the
+// scheduler records again when it falls due
+// POST /api/v1/expenses
+func (h *ExpenseHandler) Create(c *fiber.Ctx) error {
+	shopID := c.Locals("shop_id").(uint)
+
+	var req CreateExpenseRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "Invalid request body")
+	}
+	req.Category = strings.ToLower(strings.TrimSpace(req.Category))
+	req.Repeat = strings.ToLower(strings.TrimSpace(req.Repeat))
+	method := models.PaymentMethod(strings.ToLower(strings.TrimSpace(req.PaymentMethod)))
+	if method == "" {
+		method = models.PaymentCash
+	}
+	if req.Amount <= 0 {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "amount must be more than 0")
+	}
+	if req.Category == "" || len(req.Category) > 50 {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "category is required (up to 50 characters)")
+	}
+	if len(req.Note) > 255 {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "note must be up to 255 characters")
+	}
+	switch method {
+	case models.PaymentCash, models.PaymentMpesa, models.PaymentCard, models.PaymentBank:
+	default:
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "payment_method must be cash, mpesa, card or bank")
+	}
+	if req.Repeat != "" && !models.ValidExpenseFrequency(req.Repeat) {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "repeat must be daily, weekly or monthly")
+	}
+
+	spentAt := time.Now()
+	if req.SpentAt != "" {
+		day, err := time.ParseInLocation("2006-01-02", req.SpentAt, time.Local)
+		if err != nil {
+			return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "spent_at must be a date (YYYY-MM-DD)")
+		}
+		spentAt = day
+	}
+
+	expense := &models.Expense{
+		ShopID:        shopID,
+		Amount:        req.Amount,
+		Category:      req.Category,
+		Note:          strings.TrimSpace(req.Note),
+		PaymentMethod: method,
+		SpentAt:       spentAt,
+	}
+	if req.Repeat == "" {
+		if err := h.expenseRepo.Create(expense); err != nil {
+			return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to record expense")
+		}
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{"expense": expense})
+	}
+
+	recurring, err := h.expenseRepo.CreateRecurring(expense, req.Repeat)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to record expense")
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"expense": expense, "recurring": recurring})
+}
+
+// Delete removes one of the shop's expenses
+// DELETE /api/v1/expenses/:id
+func (h *ExpenseHandler) Delete(c *fiber.Ctx) error {
+	shopID := c.Locals("shop_id").(uint)
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "Invalid expense ID")
+	}
+	if err := h.expenseRepo.Delete(shopID, uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.SendError(c, fiber.StatusNotFound, utils.CodeNotFound, "Expense not found")
+		}
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to delete expense")
+	}
+	return c.JSON(fiber.Map{"message": "Expense deleted"})
+}
+
+// ListRecurring returns the shop's active recurring expenses
+// GET /api/v1/expenses/recurring
+func (h *ExpenseHandler) ListRecurring(c *fiber.Ctx) error {
+	shopID := c.Locals("shop_id").(uint)
+
+	recurring, err := h.expenseRepo.ListRecurring(shopID)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get recurring expenses")
+	}
+	return c.JSON(fiber.Map{"data": recurring, "count": len(recurring)})
+}
+
+// StopRecurring stops one of the shop's recurring expenses; what it
+// already recorded is kept
+// DELETE /api/v1/expenses/recurring/:id
+func (h *ExpenseHandler) StopRecurring(c *fiber.Ctx) error {
+	shopID := c.Locals("shop_id").(uint)
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "Invalid recurring expense ID")
+	}
+	if err := h.expenseRepo.StopRecurring(shopID, uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.SendError(c, fiber.StatusNotFound, utils.CodeNotFound, "Recurring expense not found")
+		}
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to stop recurring expense")
+	}
+	return c.JSON(fiber.Map{"message": "Recurring expense stopped"})
+}
+
+// SetExpenseRepo enables the net profit report
+func (h *ReportHandler) SetExpenseRepo(repo *repository.ExpenseRepository) {
+	h.expenseRepo = repo
+}
+
+// GetNetProfit returns the shop's profit after both the cost of the goods
+// it sold and its expenses. from and to are inclusive dates (YYYY-MM-DD);
+// the default is this month.
+// GET /api/v1/reports/net-profit?from=&to=
+func (h *ReportHandler) GetNetProfit(c *fiber.Ctx) error {
+	shopID := c.Locals("shop_id").(uint)
+
+	if h.expenseRepo == nil {
+		return utils.SendError(c, fiber.StatusServiceUnavailable, utils.CodeServiceUnavailable, "Net profit report not available")
+	}
+	start, end, err := reportRange(c)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, err.Error())
+	}
+
+	sales, err := h.saleRepo.GetTotals(shopID, start, end)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get sales")
+	}
+	expenses, err := h.expenseRepo.TotalsByCategory(shopID, start, end)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get expenses")
+	}
+
+	var totalExpenses float64
+	for _, category := range expenses {
+		totalExpenses += category.Total
+	}
+	grossProfit := sales.Sales - sales.Cost
+	return c.JSON(fiber.Map{
+		"type":                 "net_profit",
+		"from":                 start.Format("2006-01-02"),
+		"to":                   end.AddDate(0, 0, -1).Format("2006-01-02"),
+		"total_sales":          sales.Sales,
+		"cost_of_goods":        sales.Cost,
+		"gross_profit":         grossProfit,
+		"total_expenses":       totalExpenses,
+		"expenses_by_category": expenses,
+		"net_profit":           grossProfit - totalExpenses,
+		"transactions":         sales.Transactions,
+	})
+}
+
+// reportRange returns the period from the from and to query dates, both
+// inclusive, as a start and an exclusive end. It defaults to this month.
+func reportRange(c *fiber.Ctx) (time.Time, time.Time, error) {
+	now := time.Now()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	end := start.AddDate(0, 1, 0)
+
+	var err error
+	if v := c.Query("from"); v != "" {
+		if start, err = time.ParseInLocation("2006-01-02", v, time.Local); err != nil {
+			return start, end, errors.New("from must be a date (YYYY-MM-DD)")
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if end, err = time.ParseInLocation("2006-01-02", v, time.Local); err != nil {
+			return start, end, errors.New("to must be a date (YYYY-MM-DD)")
+		}
+		end = end.AddDate(0, 0, 1)
+	}
+	if !end.After(start) {
+		return start, end, errors.New("to must not be before from")
+	}
+	return start, end, nil
+}
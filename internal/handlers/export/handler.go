@@ -1,13 +1,22 @@
 package handler
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"log"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/services/export"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/jobs"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/storage"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -15,8 +24,32 @@ type ExportHandler struct {
 	productRepo *repository.ProductRepository
 	saleRepo    *repository.SaleRepository
 	summaryRepo *repository.DailySummaryRepository
+	closingRepo *repository.ClosingStockRepository
+	reconciler  *mpesa.ReconciliationService
+	jobs        *jobs.JobQueue
+	exportJobs  *export.JobService
+	maxDays     int // longest period exported within a request
+
+	// catalog branding and product images
+	shopRepo       *repository.ShopRepository
+	imageStore     storage.Store
+	imageURLPrefix string
+
+	// record exports for accountants and moving off the platform
+	customerRepo     *repository.CustomerRepository
+	loyaltyRepo      *repository.LoyaltyTransactionRepository
+	mpesaPaymentRepo *repository.MpesaPaymentRepository
+	mpesaTxRepo      *repository.MpesaTransactionRepository
+	supplierRepo     *repository.SupplierRepository
+	orderRepo        *repository.OrderRepository
 }
 
+// Export job types
+const (
+	JobExportProducts = "export_products"
+	JobExportReport   = "export_report"
+)
+
 func NewExportHandler(
 	productRepo *repository.ProductRepository,
 	saleRepo *repository.SaleRepository,
@@ -26,85 +59,204 @@ func NewExportHandler(
 		productRepo: productRepo,
 		saleRepo:    saleRepo,
 		summaryRepo: summaryRepo,
+		maxDays:     DefaultMaxRangeDays,
 	}
 }
 
-func (h *ExportHandler) RegisterRoutes(protected fiber.Router) {
-	exportRoutes := protected.Group("/export")
-	exportRoutes.Get("/products", h.ExportProducts)
-	exportRoutes.Get("/sales", h.ExportSales)
-	exportRoutes.Get("/report", h.ExportReport)
-	exportRoutes.Get("/inventory", h.ExportInventory)
+// SetMaxRange sets the longest period, in days, exported within a request.
+// Queued exports are not limited.
+func (h *ExportHandler) SetMaxRange(days int) {
+	if days > 0 {
+		h.maxDays = days
+	}
 }
 
-type ExportQuery struct {
-	Format string `query:"format"`
-	From   string `query:"from"`
-	To     string `query:"to"`
+// SetReconciliationService enables the M-Pesa reconciliation export
+func (h *ExportHandler) SetReconciliationService(reconciler *mpesa.ReconciliationService) {
+	h.reconciler = reconciler
 }
 
-func (h *ExportHandler) ExportProducts(c *fiber.Ctx) error {
-	shopID := c.Locals("shop_id").(uint)
+// SetClosingStockRepo enables inventory valuations at a month's close
+func (h *ExportHandler) SetClosingStockRepo(closingRepo *repository.ClosingStockRepository) {
+	h.closingRepo = closingRepo
+}
+
+// SetShopRepo enables the customer catalog, which is branded with the
+// shop's name and colour
+func (h *ExportHandler) SetShopRepo(shopRepo *repository.ShopRepository) {
+	h.shopRepo = shopRepo
+}
+
+// SetImageStore lets the catalog show product thumbnails saved in store
+// under URLs starting with urlPrefix
+func (h *ExportHandler) SetImageStore(store storage.Store, urlPrefix string) {
+	h.imageStore = store
+	h.imageURLPrefix = urlPrefix
+}
+
+// SetJobQueue makes product and report exports run on the queue's workers
+// instead of the request goroutine
+func (h *ExportHandler) SetJobQueue(queue *jobs.JobQueue) {
+	h.jobs = queue
+	queue.Register(JobExportProducts, func(ctx context.Context, job *jobs.Job) (*jobs.Result, error) {
+		opts, err := queryFromParams(job.Params).options(1)
+		if err != nil {
+			return nil, err
+		}
+		return h.exportProducts(job.ShopID, opts)
+	})
+	queue.Register(JobExportReport, func(ctx context.Context, job *jobs.Job) (*jobs.Result, error) {
+		opts, err := queryFromParams(job.Params).options(reportDays)
+		if err != nil {
+			return nil, err
+		}
+		return h.exportReport(job.ShopID, opts)
+	})
+}
+
+// Default periods when no from date is given
+const (
+	reportDays    = 30
+	inventoryDays = 30
+)
+
+// parseQuery reads and resolves an export's query string
+func parseQuery(c *fiber.Ctx, defaultDays int) (ExportQuery, exportOptions, error) {
 	query := new(ExportQuery)
 	if err := c.QueryParser(query); err != nil {
-		query.Format = "csv"
+		return *query, exportOptions{}, errors.New("invalid export options")
 	}
+	opts, err := query.options(defaultDays)
+	return *query, opts, err
+}
 
-	format := export.FormatCSV
-	if query.Format == "json" {
-		format = export.FormatJSON
+// tooLong replies 400 when the period is too long to export within the
+// request
+func (h *ExportHandler) tooLong(c *fiber.Ctx, opts exportOptions) bool {
+	if opts.days() <= h.maxDays {
+		return false
 	}
+	c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error": fmt.Sprintf("Date range is %d days; exports are limited to %d days", opts.days(), h.maxDays),
+	})
+	return true
+}
 
-	products, err := h.productRepo.GetByShopID(shopID)
+// notRows replies 400 for JSON lines, which only the row-per-record sales
+// and product exports come in
+func notRows(c *fiber.Ctx, opts exportOptions) bool {
+	if opts.format != export.FormatJSONLines {
+		return false
+	}
+	c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error": "jsonl is only available for sales and product exports",
+	})
+	return true
+}
+
+// ExportProducts exports the shop's products, optionally only a category
+// or those added between from and to
+func (h *ExportHandler) ExportProducts(c *fiber.Ctx) error {
+	shopID := c.Locals("shop_id").(uint)
+	query, opts, err := parseQuery(c, 1)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch products",
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
 		})
 	}
 
-	exporter := &export.ProductExporter{}
-	data, err := exporter.Export(products, format)
+	if h.jobs != nil {
+		return h.enqueue(c, shopID, JobExportProducts, query.params())
+	}
+	if opts.ranged && h.tooLong(c, opts) {
+		return nil
+	}
+
+	result, err := h.exportProducts(shopID, opts)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to export products",
 		})
 	}
+	return sendResult(c, result)
+}
 
-	filename := fmt.Sprintf("products_%s.%s", time.Now().Format("20060102"), query.Format)
-	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
-	if query.Format == "json" {
-		c.Set("Content-Type", "application/json")
-	} else {
-		c.Set("Content-Type", "text/csv")
+func (h *ExportHandler) exportProducts(shopID uint, opts exportOptions) (*jobs.Result, error) {
+	products, err := h.productRepo.GetByShopID(shopID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %w", err)
+	}
+	products = filterProducts(products, opts.filter)
+	if opts.ranged {
+		added := products[:0]
+		for _, p := range products {
+			if !p.CreatedAt.Before(opts.from) && p.CreatedAt.Before(opts.to) {
+				added = append(added, p)
+			}
+		}
+		products = added
 	}
 
-	return c.Send(data)
+	exporter := &export.ProductExporter{}
+	data, err := exporter.Export(products, opts.format)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jobs.Result{
+		Filename:    h.filename(shopID, "products", opts, opts.ranged),
+		ContentType: contentType(opts.format),
+		Data:        data,
+	}, nil
 }
 
-func (h *ExportHandler) ExportSales(c *fiber.Ctx) error {
-	shopID := c.Locals("shop_id").(uint)
-	query := new(ExportQuery)
-	if err := c.QueryParser(query); err != nil {
-		query.Format = "csv"
+// ProductImportTemplate serves a product import file to fill in, with the
+// columns headed and three example rows
+// GET /api/v1/import/products/template?format=csv|excel
+func (h *ExportHandler) ProductImportTemplate(c *fiber.Ctx) error {
+	format, err := parseFormat(c.Query("format"))
+	if err == nil && format != export.FormatCSV && format != export.FormatExcel {
+		err = fmt.Errorf("unsupported format %q; use csv or excel", c.Query("format"))
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
 	}
 
-	format := export.FormatCSV
-	if query.Format == "json" {
-		format = export.FormatJSON
+	exporter := &export.ProductExporter{}
+	data, err := exporter.Template(format)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to build template",
+		})
 	}
 
-	var sales []models.Sale
-	var err error
+	return sendResult(c, &jobs.Result{
+		Filename:    "products_import_template." + extension(format),
+		ContentType: contentType(format),
+		Data:        data,
+	})
+}
 
-	if query.From != "" && query.To != "" {
-		from, _ := time.Parse("2006-01-02", query.From)
-		to, _ := time.Parse("2006-01-02", query.To)
-		to = to.Add(24 * time.Hour)
-		sales, err = h.saleRepo.GetByDateRange(shopID, from, to)
-	} else {
-		sales, err = h.saleRepo.GetTodaySales(shopID)
+// ExportSales exports the sales made from from to to, today by default,
+// filtered like the sales list
+func (h *ExportHandler) ExportSales(c *fiber.Ctx) error {
+	shopID := c.Locals("shop_id").(uint)
+	_, opts, err := parseQuery(c, 1)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if h.tooLong(c, opts) {
+		return nil
+	}
+	if streamed(opts.format) {
+		return h.streamSales(c, shopID, opts)
 	}
 
+	sales, err := h.saleRepo.GetFiltered(shopID, opts.from, opts.to, opts.filter)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch sales",
@@ -112,56 +264,99 @@ func (h *ExportHandler) ExportSales(c *fiber.Ctx) error {
 	}
 
 	exporter := &export.SalesExporter{}
-	data, err := exporter.Export(sales, format)
+	data, err := exporter.Export(sales, opts.format)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to export sales",
 		})
 	}
 
-	filename := fmt.Sprintf("sales_%s.%s", time.Now().Format("20060102"), query.Format)
-	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
-	if query.Format == "json" {
-		c.Set("Content-Type", "application/json")
-	} else {
-		c.Set("Content-Type", "text/csv")
-	}
+	return sendResult(c, &jobs.Result{
+		Filename:    h.filename(shopID, "sales", opts, true),
+		ContentType: contentType(opts.format),
+		Data:        data,
+	})
+}
 
-	return c.Send(data)
+// streamBatchSize is how many sales or records are read from the database at
+// a time while streaming an export
+const streamBatchSize = 500
+
+// streamed reports whether exports in format are written to the response
+// as they are read rather than built first
+func streamed(format export.Format) bool {
+	return format == export.FormatCSV || format == export.FormatJSONLines
 }
 
+// streamSales writes the sales to the response a batch at a time, newest
+// first like a buffered export, so memory stays flat however many there are. Once rows are
+// sent the status cannot change, so a failure part way is only logged.
+func (h *ExportHandler) streamSales(c *fiber.Ctx, shopID uint, opts exportOptions) error {
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", h.filename(shopID, "sales", opts, true)))
+	c.Set("Content-Type", contentType(opts.format))
+
+	saleRepo := h.saleRepo
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		_, err := export.StreamSales(w, opts.format, func(fn func([]models.Sale) error) error {
+			return saleRepo.EachFilteredNewest(shopID, opts.from, opts.to, opts.filter, streamBatchSize, func(batch []models.Sale) error {
+				if err := fn(batch); err != nil {
+					return err
+				}
+				return w.Flush()
+			})
+		})
+		if err != nil {
+			log.Printf("⚠️ Sales export for shop %d stopped: %v", shopID, err)
+		}
+	})
+	return nil
+}
+
+// ExportReport exports sales totals and top products for the period, the
+// last 30 days by default
 func (h *ExportHandler) ExportReport(c *fiber.Ctx) error {
 	shopID := c.Locals("shop_id").(uint)
-	query := new(ExportQuery)
-	if err := c.QueryParser(query); err != nil {
-		query.Format = "csv"
+	query, opts, err := parseQuery(c, reportDays)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
 	}
-
-	format := export.FormatCSV
-	if query.Format == "json" {
-		format = export.FormatJSON
+	if notRows(c, opts) {
+		return nil
 	}
 
-	reportDate := time.Now()
-	if query.From != "" {
-		reportDate, _ = time.Parse("2006-01-02", query.From)
+	if h.jobs != nil {
+		return h.enqueue(c, shopID, JobExportReport, query.params())
+	}
+	if h.tooLong(c, opts) {
+		return nil
 	}
 
-	summaries, err := h.summaryRepo.GetByDateRange(shopID, reportDate.AddDate(0, 0, -30), reportDate)
+	result, err := h.exportReport(shopID, opts)
 	if err != nil {
-		summaries = nil
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to export report",
+		})
 	}
+	return sendResult(c, result)
+}
 
-	sales, err := h.saleRepo.GetByDateRange(shopID, reportDate.AddDate(0, 0, -30), reportDate)
+func (h *ExportHandler) exportReport(shopID uint, opts exportOptions) (*jobs.Result, error) {
+	sales, err := h.saleRepo.GetFiltered(shopID, opts.from, opts.to, opts.filter)
 	if err != nil {
-		sales = nil
+		return nil, fmt.Errorf("failed to fetch sales: %w", err)
 	}
 
-	var totalSales, totalProfit float64
-	for _, s := range summaries {
-		totalSales += s.TotalSales
-		totalProfit += s.TotalProfit
+	totalSales, totalProfit := h.reportTotals(shopID, opts, sales)
+
+	// The period of the same length before, for the comparison
+	previous := opts.previous()
+	previousSales, err := h.saleRepo.GetFiltered(shopID, previous.from, previous.to, previous.filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch previous sales: %w", err)
 	}
+	previousTotal, previousProfit := h.reportTotals(shopID, previous, previousSales)
 
 	avgSale := 0.0
 	if len(sales) > 0 {
@@ -169,12 +364,18 @@ func (h *ExportHandler) ExportReport(c *fiber.Ctx) error {
 	}
 
 	report := export.DailyReportData{
-		Date:             reportDate.Format("2006-01-02"),
+		Date:             opts.period(),
 		TotalSales:       totalSales,
 		TotalProfit:      totalProfit,
 		TransactionCount: len(sales),
 		AverageSale:      avgSale,
 		TopProducts:      []export.ProductSale{},
+		Comparison: export.Compare(previous.period(),
+			export.PeriodTotals{Sales: totalSales, Profit: totalProfit, Transactions: len(sales)},
+			export.PeriodTotals{Sales: previousTotal, Profit: previousProfit, Transactions: len(previousSales)}),
+	}
+	if opts.days() > 1 {
+		report.Title = "Sales Report"
 	}
 
 	productSales := make(map[string]export.ProductSale)
@@ -195,18 +396,204 @@ func (h *ExportHandler) ExportReport(c *fiber.Ctx) error {
 	for _, ps := range productSales {
 		report.TopProducts = append(report.TopProducts, ps)
 	}
+	sort.Slice(report.TopProducts, func(i, j int) bool {
+		a, b := report.TopProducts[i], report.TopProducts[j]
+		if a.Revenue != b.Revenue {
+			return a.Revenue > b.Revenue
+		}
+		return a.Name < b.Name
+	})
 
 	exporter := &export.ReportExporter{}
-	data, err := exporter.ExportDaily(report, format)
+	data, err := exporter.ExportDaily(report, opts.format)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jobs.Result{
+		Filename:    h.filename(shopID, "report", opts, true),
+		ContentType: contentType(opts.format),
+		Data:        data,
+	}, nil
+}
+
+// reportTotals adds up the sales and profit of a report's period. Daily
+// summaries hold the totals of every sale, so a filtered report adds up
+// its own.
+func (h *ExportHandler) reportTotals(shopID uint, opts exportOptions, sales []models.Sale) (totalSales, totalProfit float64) {
+	if opts.filter == (repository.SaleFilter{}) {
+		summaries, err := h.summaryRepo.GetByDateRange(shopID, opts.from, opts.to.AddDate(0, 0, -1))
+		if err != nil {
+			summaries = nil
+		}
+		for _, s := range summaries {
+			totalSales += s.TotalSales
+			totalProfit += s.TotalProfit
+		}
+		return totalSales, totalProfit
+	}
+	for _, s := range sales {
+		totalSales += s.TotalAmount
+		totalProfit += s.Profit
+	}
+	return totalSales, totalProfit
+}
+
+// ExportInventory exports the stock valuation of the shop's products with
+// the units each sold in the period, the last 30 days by default. For a
+// month, the stock and prices are those recorded when the month closed.
+func (h *ExportHandler) ExportInventory(c *fiber.Ctx) error {
+	shopID := c.Locals("shop_id").(uint)
+	_, opts, err := parseQuery(c, inventoryDays)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if notRows(c, opts) {
+		return nil
+	}
+	if h.tooLong(c, opts) {
+		return nil
+	}
+
+	products, err := h.productRepo.GetByShopID(shopID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to export report",
+			"error": "Failed to fetch products",
 		})
 	}
+	if opts.month {
+		if h.closingRepo == nil {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"error": "Month-end closing stock is not available",
+			})
+		}
+		closing, err := h.closingRepo.GetMonth(shopID, opts.from)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch closing stock",
+			})
+		}
+		if len(closing) == 0 {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": fmt.Sprintf("%s has no closing stock yet", opts.from.Format("January 2006")),
+			})
+		}
+		products = closingProducts(closing, products)
+	}
+	products = filterProducts(products, opts.filter)
 
-	filename := fmt.Sprintf("report_%s.%s", reportDate.Format("20060102"), query.Format)
-	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	sales, err := h.saleRepo.GetFiltered(shopID, opts.from, opts.to, opts.filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch sales",
+		})
+	}
+	sold := make(map[uint]int)
+	for _, s := range sales {
+		sold[s.ProductID] += s.Quantity
+	}
+
+	exporter := &export.InventoryExporter{}
+	data, err := exporter.Export(export.NewInventoryData(products, sold, opts.period()), opts.format)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to export inventory",
+		})
+	}
+
+	return sendResult(c, &jobs.Result{
+		Filename:    h.filename(shopID, "inventory", opts, opts.ranged),
+		ContentType: contentType(opts.format),
+		Data:        data,
+	})
+}
+
+// closingProducts values the closing stock as products, with the category
+// of the shop's product where it still has it
+func closingProducts(closing []models.ClosingStockSnapshot, current []models.Product) []models.Product {
+	categories := make(map[uint]string, len(current))
+	for _, p := range current {
+		categories[p.ID] = p.Category
+	}
+	products := make([]models.Product, len(closing))
+	for i, row := range closing {
+		products[i] = models.Product{
+			ID:           row.ProductID,
+			ShopID:       row.ShopID,
+			Name:         row.ProductName,
+			Category:     categories[row.ProductID],
+			CurrentStock: row.ClosingStock,
+			CostPrice:    row.CostPrice,
+			SellingPrice: row.SellingPrice,
+		}
+	}
+	return products
+}
+
+// filterProducts keeps the products matching the filter's product and
+// category
+func filterProducts(products []models.Product, filter repository.SaleFilter) []models.Product {
+	kept := products[:0]
+	for _, p := range products {
+		if filter.ProductID != 0 && p.ID != filter.ProductID {
+			continue
+		}
+		if filter.Category != "" && !strings.EqualFold(p.Category, filter.Category) {
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept
+}
+
+// ExportMpesaReconciliation exports the M-Pesa reconciliation for a date
+// range as CSV (default) or JSON
+func (h *ExportHandler) ExportMpesaReconciliation(c *fiber.Ctx) error {
+	if h.reconciler == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Reconciliation is not available",
+		})
+	}
+
+	shopID := c.Locals("shop_id").(uint)
+	query := new(ExportQuery)
+	if err := c.QueryParser(query); err != nil {
+		query.Format = "csv"
+	}
+
+	from, to, err := mpesa.ParseReconciliationRange(query.From, query.To)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	report, err := h.reconciler.Reconcile(shopID, from, to)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to reconcile payments",
+		})
+	}
+
+	format := export.FormatCSV
 	if query.Format == "json" {
+		format = export.FormatJSON
+	}
+
+	exporter := &export.ReconciliationExporter{}
+	data, err := exporter.Export(report, format)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to export reconciliation",
+		})
+	}
+
+	filename := fmt.Sprintf("mpesa_reconciliation_%s_%s.%s",
+		from.Format("20060102"), to.AddDate(0, 0, -1).Format("20060102"), format)
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	if format == export.FormatJSON {
 		c.Set("Content-Type", "application/json")
 	} else {
 		c.Set("Content-Type", "text/csv")
@@ -215,13 +602,36 @@ func (h *ExportHandler) ExportReport(c *fiber.Ctx) error {
 	return c.Send(data)
 }
 
-func (h *ExportHandler) ExportInventory(c *fiber.Ctx) error {
+// CatalogQuery filters the customer catalog
+type CatalogQuery struct {
+	Category string `query:"category"`
+	InStock  bool   `query:"in_stock"`
+	Images   bool   `query:"images"`
+	Barcodes bool   `query:"barcodes"`
+}
+
+// ExportCatalog exports a branded price list PDF to share with customers
+func (h *ExportHandler) ExportCatalog(c *fiber.Ctx) error {
+	if h.shopRepo == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Catalog is not available",
+		})
+	}
+
 	shopID := c.Locals("shop_id").(uint)
-	query := new(ExportQuery)
+	query := new(CatalogQuery)
 	if err := c.QueryParser(query); err != nil {
-		query.Format = "csv"
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid catalog options",
+		})
 	}
 
+	shop, err := h.shopRepo.GetByID(shopID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Shop not found",
+		})
+	}
 	products, err := h.productRepo.GetByShopID(shopID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -229,62 +639,87 @@ func (h *ExportHandler) ExportInventory(c *fiber.Ctx) error {
 		})
 	}
 
-	type InventoryItem struct {
-		Name            string  `json:"name"`
-		Category        string  `json:"category"`
-		CurrentStock    int     `json:"current_stock"`
-		SellingPrice    float64 `json:"selling_price"`
-		CostPrice       float64 `json:"cost_price"`
-		StockValue      float64 `json:"stock_value"`
-		PotentialProfit float64 `json:"potential_profit"`
-	}
-
-	inventory := make([]InventoryItem, len(products))
-	totalValue := 0.0
-	totalCost := 0.0
-
-	for i, p := range products {
-		stockValue := p.SellingPrice * float64(p.CurrentStock)
-		costValue := p.CostPrice * float64(p.CurrentStock)
-		totalValue += stockValue
-		totalCost += costValue
-
-		inventory[i] = InventoryItem{
-			Name:            p.Name,
-			Category:        p.Category,
-			CurrentStock:    p.CurrentStock,
-			SellingPrice:    p.SellingPrice,
-			CostPrice:       p.CostPrice,
-			StockValue:      stockValue,
-			PotentialProfit: stockValue - costValue,
-		}
+	data := export.CatalogData{
+		Shop:     *shop,
+		Products: products,
+		Options: export.CatalogOptions{
+			Category:     strings.TrimSpace(query.Category),
+			InStockOnly:  query.InStock,
+			ShowBarcodes: query.Barcodes,
+		},
+	}
+	if query.Images {
+		data.Images = h.catalogImages(export.CatalogProducts(products, data.Options))
 	}
 
-	if query.Format == "json" {
-		return c.JSON(fiber.Map{
-			"inventory":         inventory,
-			"total_stock_value": totalValue,
-			"total_cost_value":  totalCost,
-			"potential_profit":  totalValue - totalCost,
-			"product_count":     len(products),
+	pdf, err := (&export.CatalogExporter{}).ExportPDF(data)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to export catalog",
 		})
 	}
 
-	var result string
-	result += "NAME,CATEGORY,STOCK,SELLING PRICE,COST PRICE,STOCK VALUE,POTENTIAL PROFIT\n"
-	for _, item := range inventory {
-		result += fmt.Sprintf("%s,%s,%d,%.2f,%.2f,%.2f,%.2f\n",
-			item.Name, item.Category, item.CurrentStock,
-			item.SellingPrice, item.CostPrice,
-			item.StockValue, item.PotentialProfit)
+	return sendResult(c, &jobs.Result{
+		Filename:    fmt.Sprintf("catalog_%s.pdf", time.Now().Format("20060102")),
+		ContentType: contentType(export.FormatPDF),
+		Data:        pdf,
+	})
+}
+
+// catalogImages loads the stored thumbnails of products; products whose
+// thumbnail is missing or hosted elsewhere are left out
+func (h *ExportHandler) catalogImages(products []models.Product) map[uint][]byte {
+	if h.imageStore == nil {
+		return nil
 	}
-	result += fmt.Sprintf("\nTOTAL,,,,,,%.2f,%.2f\n", totalValue, totalValue-totalCost)
+	images := make(map[uint][]byte)
+	for _, p := range products {
+		key, ok := strings.CutPrefix(p.ThumbnailURL, h.imageURLPrefix)
+		if p.ThumbnailURL == "" || !ok {
+			continue
+		}
+		if data, err := h.imageStore.Open(key); err == nil {
+			images[p.ID] = data
+		}
+	}
+	return images
+}
 
-	filename := fmt.Sprintf("inventory_%s.csv", time.Now().Format("20060102"))
-	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
-	c.Set("Content-Type", "text/csv")
+// enqueue queues an export and answers 202 with the job to poll
+func (h *ExportHandler) enqueue(c *fiber.Ctx, shopID uint, jobType string, params map[string]string) error {
+	job, err := h.jobs.Enqueue(shopID, jobType, params)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to queue export",
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"job_id":     job.ID,
+		"status":     job.Status,
+		"status_url": "/api/v1/jobs/" + job.ID,
+	})
+}
 
-	return c.Send([]byte(result))
+// sendResult sends an export as a file download
+func sendResult(c *fiber.Ctx, result *jobs.Result) error {
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", result.Filename))
+	c.Set("Content-Type", result.ContentType)
+	return c.Send(result.Data)
+}
+
+func contentType(format export.Format) string {
+	switch format {
+	case export.FormatJSON:
+		return "application/json"
+	case export.FormatJSONLines:
+		return "application/x-ndjson"
+	case export.FormatPDF:
+		return "application/pdf"
+	case export.FormatExcel:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv"
 }
 
 func parseUint(s string) uint {
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/export"
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetExportJobs enables background exports of large datasets
+func (h *ExportHandler) SetExportJobs(svc *export.JobService) {
+	h.exportJobs = svc
+}
+
+// ExportJobRequest asks for a background export. The period and filters
+// are those of the export endpoints; products are all exported unless a
+// period is given.
+type ExportJobRequest struct {
+	Type          string `json:"type"` // sales or products
+	Format        string `json:"format"`
+	From          string `json:"from"`
+	To            string `json:"to"`
+	PaymentMethod string `json:"payment_method"`
+	ProductID     uint   `json:"product_id"`
+	Category      string `json:"category"`
+}
+
+// CreateExportJob queues an export to be written in the background. The
+// shop is told by WhatsApp and email when it is ready.
+// POST /api/v1/export/jobs
+func (h *ExportHandler) CreateExportJob(c *fiber.Ctx) error {
+	if h.exportJobs == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Background exports are not available",
+		})
+	}
+	shopID := c.Locals("shop_id").(uint)
+
+	var req ExportJobRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	req.Type = strings.ToLower(strings.TrimSpace(req.Type))
+	query := ExportQuery{
+		Format:        req.Format,
+		From:          req.From,
+		To:            req.To,
+		PaymentMethod: req.PaymentMethod,
+		ProductID:     req.ProductID,
+		Category:      req.Category,
+	}
+	opts, err := query.options(1)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	job := &models.ExportJob{
+		ShopID:        shopID,
+		Type:          req.Type,
+		Format:        string(opts.format),
+		PaymentMethod: opts.filter.PaymentMethod,
+		ProductID:     opts.filter.ProductID,
+		Category:      opts.filter.Category,
+	}
+	dated := req.Type == models.ExportJobSales || opts.ranged
+	if dated {
+		job.PeriodStart, job.PeriodEnd = &opts.from, &opts.to
+	}
+	job.Filename = h.filename(shopID, req.Type, opts, dated)
+
+	if err := h.exportJobs.Submit(job); err != nil {
+		if job.ID == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to queue export",
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"job":        job,
+		"status_url": fmt.Sprintf("/api/v1/export/jobs/%d", job.ID),
+	})
+}
+
+// GetExportJob returns a background export's status, with a signed
+// download link once it is ready
+// GET /api/v1/export/jobs/:id
+func (h *ExportHandler) GetExportJob(c *fiber.Ctx) error {
+	if h.exportJobs == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Background exports are not available",
+		})
+	}
+	shopID := c.Locals("shop_id").(uint)
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid job ID",
+		})
+	}
+	job, err := h.exportJobs.ShopJob(shopID, uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Export job not found",
+		})
+	}
+
+	response := fiber.Map{"job": job}
+	if job.Status == models.ExportJobStatusDone {
+		response["download_url"] = h.exportJobs.DownloadURL(job)
+	}
+	return c.JSON(response)
+}
+
+// DownloadExport sends a finished background export. The link is signed,
+// so it works from a WhatsApp message or email without logging in.
+// GET /exports/:id?expires=...&sig=...
+func (h *ExportHandler) DownloadExport(c *fiber.Ctx) error {
+	if h.exportJobs == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Export not found",
+		})
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 || !h.exportJobs.VerifyDownload(uint(id), int64(c.QueryInt("expires")), c.Query("sig")) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Invalid or expired download link",
+		})
+	}
+	job, err := h.exportJobs.Job(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Export not found",
+		})
+	}
+
+	f, size, err := h.exportJobs.Open(job)
+	switch {
+	case errors.Is(err, export.ErrJobExpired):
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, export.ErrJobNotReady):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to open export",
+		})
+	}
+
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", job.Filename))
+	c.Set("Content-Type", contentType(export.Format(job.Format)))
+	return c.SendStream(f, int(size))
+}
@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/export"
+)
+
+// DefaultMaxRangeDays is the longest period exported within a request
+const DefaultMaxRangeDays = 92
+
+const (
+	dateLayout  = "2006-01-02"
+	monthLayout = "2006-01"
+)
+
+var (
+	errInvalidRange = errors.New("from and to must be dates (YYYY-MM-DD) with from no later than to")
+	errInvalidMonth = errors.New("month must be a month (YYYY-MM) and not given with from or to")
+)
+
+// ExportQuery is an export's format, period and the sales list filters.
+// From and to are inclusive dates; month is a whole calendar month instead.
+type ExportQuery struct {
+	Format        string `query:"format"` // csv, json, jsonl, xlsx or pdf
+	From          string `query:"from"`
+	To            string `query:"to"`
+	Month         string `query:"month"`
+	PaymentMethod string `query:"payment_method"`
+	ProductID     uint   `query:"product_id"`
+	Category      string `query:"category"`
+}
+
+// exportOptions is an ExportQuery checked and resolved
+type exportOptions struct {
+	format   export.Format
+	from, to time.Time // sales made in [from, to)
+	ranged   bool      // the caller chose the period
+	month    bool      // the period is the calendar month chosen
+	filter   repository.SaleFilter
+}
+
+// options resolves the query. Without from, the period is the defaultDays
+// days ending on to, or today.
+func (q ExportQuery) options(defaultDays int) (exportOptions, error) {
+	format, err := parseFormat(q.Format)
+	if err != nil {
+		return exportOptions{}, err
+	}
+
+	if q.Month != "" {
+		return q.monthOptions(format)
+	}
+
+	now := time.Now()
+	last := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if q.To != "" {
+		if last, err = time.ParseInLocation(dateLayout, q.To, time.Local); err != nil {
+			return exportOptions{}, errInvalidRange
+		}
+	}
+	first := last.AddDate(0, 0, 1-defaultDays)
+	if q.From != "" {
+		if first, err = time.ParseInLocation(dateLayout, q.From, time.Local); err != nil {
+			return exportOptions{}, errInvalidRange
+		}
+	}
+	if last.Before(first) {
+		return exportOptions{}, errInvalidRange
+	}
+
+	return exportOptions{
+		format: format,
+		from:   first,
+		to:     last.AddDate(0, 0, 1),
+		ranged: q.From != "" || q.To != "",
+		filter: q.filter(),
+	}, nil
+}
+
+// monthOptions resolves a query for a calendar month
+func (q ExportQuery) monthOptions(format export.Format) (exportOptions, error) {
+	if q.From != "" || q.To != "" {
+		return exportOptions{}, errInvalidMonth
+	}
+	first, err := time.ParseInLocation(monthLayout, q.Month, time.Local)
+	if err != nil {
+		return exportOptions{}, errInvalidMonth
+	}
+	return exportOptions{
+		format: format,
+		from:   first,
+		to:     first.AddDate(0, 1, 0),
+		ranged: true,
+		month:  true,
+		filter: q.filter(),
+	}, nil
+}
+
+// filter is the sales list filters of the query
+func (q ExportQuery) filter() repository.SaleFilter {
+	return repository.SaleFilter{
+		PaymentMethod: strings.ToLower(strings.TrimSpace(q.PaymentMethod)),
+		ProductID:     q.ProductID,
+		Category:      strings.TrimSpace(q.Category),
+	}
+}
+
+// days is the length of the period, counting both ends
+func (o exportOptions) days() int {
+	return int(o.to.Sub(o.from).Round(24*time.Hour).Hours() / 24)
+}
+
+// previous is the period of the same length just before this one
+func (o exportOptions) previous() exportOptions {
+	o.from, o.to = o.from.AddDate(0, 0, -o.days()), o.from
+	return o
+}
+
+// period describes the dates exported, e.g. "2026-01-01 to 2026-01-31"
+func (o exportOptions) period() string {
+	first, last := o.from.Format(dateLayout), o.to.AddDate(0, 0, -1).Format(dateLayout)
+	if first == last {
+		return first
+	}
+	return first + " to " + last
+}
+
+// params stores the query in a queued job
+func (q ExportQuery) params() map[string]string {
+	params := map[string]string{
+		"format":         q.Format,
+		"from":           q.From,
+		"to":             q.To,
+		"month":          q.Month,
+		"payment_method": q.PaymentMethod,
+		"category":       q.Category,
+	}
+	if q.ProductID != 0 {
+		params["product_id"] = strconv.FormatUint(uint64(q.ProductID), 10)
+	}
+	return params
+}
+
+// queryFromParams reads a query stored with params
+func queryFromParams(params map[string]string) ExportQuery {
+	return ExportQuery{
+		Format:        params["format"],
+		From:          params["from"],
+		To:            params["to"],
+		Month:         params["month"],
+		PaymentMethod: params["payment_method"],
+		ProductID:     parseUint(params["product_id"]),
+		Category:      params["category"],
+	}
+}
+
+// parseFormat maps the format query parameter, defaulting to CSV
+func parseFormat(name string) (export.Format, error) {
+	switch strings.ToLower(name) {
+	case "", "csv":
+		return export.FormatCSV, nil
+	case "json":
+		return export.FormatJSON, nil
+	case "jsonl", "ndjson":
+		return export.FormatJSONLines, nil
+	case "xlsx", "excel":
+		return export.FormatExcel, nil
+	case "pdf":
+		return export.FormatPDF, nil
+	}
+	return "", fmt.Errorf("unsupported format %q; use csv, json, jsonl, xlsx or pdf", name)
+}
+
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// filename names an export after the shop, what it holds and its period,
+// e.g. mama-mboga_sales_20260101-20260131.csv
+func (h *ExportHandler) filename(shopID uint, kind string, opts exportOptions, dated bool) string {
+	parts := []string{}
+	if h.shopRepo != nil {
+		if shop, err := h.shopRepo.GetByID(shopID); err == nil {
+			if slug := strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(shop.Name), "-"), "-"); slug != "" {
+				parts = append(parts, slug)
+			}
+		}
+	}
+	parts = append(parts, kind)
+	if dated {
+		first, last := opts.from.Format("20060102"), opts.to.AddDate(0, 0, -1).Format("20060102")
+		if first == last {
+			parts = append(parts, first)
+		} else {
+			parts = append(parts, first+"-"+last)
+		}
+	} else {
+		parts = append(parts, time.Now().Format("20060102"))
+	}
+	return strings.Join(parts, "_") + "." + extension(opts.format)
+}
+
+func extension(format export.Format) string {
+	if format == export.FormatExcel {
+		return "xlsx"
+	}
+	return string(format)
+}
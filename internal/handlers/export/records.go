@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/export"
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetCustomerRepos enables the customer and loyalty transaction exports
+func (h *ExportHandler) SetCustomerRepos(customerRepo *repository.CustomerRepository, loyaltyRepo *repository.LoyaltyTransactionRepository) {
+	h.customerRepo = customerRepo
+	h.loyaltyRepo = loyaltyRepo
+}
+
+// SetMpesaRepos enables the M-Pesa payment and transaction exports
+func (h *ExportHandler) SetMpesaRepos(paymentRepo *repository.MpesaPaymentRepository, txRepo *repository.MpesaTransactionRepository) {
+	h.mpesaPaymentRepo = paymentRepo
+	h.mpesaTxRepo = txRepo
+}
+
+// SetSupplierRepos enables the supplier and purchase order exports
+func (h *ExportHandler) SetSupplierRepos(supplierRepo *repository.SupplierRepository, orderRepo *repository.OrderRepository) {
+	h.supplierRepo = supplierRepo
+	h.orderRepo = orderRepo
+}
+
+// exportRecords answers a record export: kind names the file, and each
+// reads the shop's records in the period, calling write with a batch of
+// rows in columns order at a time. The rows are streamed to the response
+// as they are read, so without from or to the whole history is exported
+// and no range limit applies.
+func (h *ExportHandler) exportRecords(c *fiber.Ctx, kind string, available bool, columns []export.Column,
+	each func(shopID uint, start, end time.Time, write func(rows [][]interface{}) error) error) error {
+	if !available {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "This export is not available",
+		})
+	}
+
+	shopID := c.Locals("shop_id").(uint)
+	_, opts, err := parseQuery(c, 1)
+	if err == nil && opts.format == export.FormatPDF {
+		err = fmt.Errorf("pdf is not available for %s; use csv, json, jsonl or xlsx", kind)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var start, end time.Time
+	if opts.ranged {
+		start, end = opts.from, opts.to
+	}
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", h.filename(shopID, kind, opts, opts.ranged)))
+	c.Set("Content-Type", contentType(opts.format))
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		table, err := export.NewTableWriter(w, opts.format, columns)
+		if err == nil {
+			err = each(shopID, start, end, func(rows [][]interface{}) error {
+				for _, row := range rows {
+					if err := table.WriteRow(row); err != nil {
+						return err
+					}
+				}
+				return w.Flush()
+			})
+		}
+		if err == nil {
+			err = table.Close()
+		}
+		if err != nil {
+			log.Printf("⚠️ %s export for shop %d stopped: %v", kind, shopID, err)
+		}
+	})
+	return nil
+}
+
+// ExportCustomers exports the shop's loyalty customers with their points,
+// tier and spend
+func (h *ExportHandler) ExportCustomers(c *fiber.Ctx) error {
+	return h.exportRecords(c, "customers", h.customerRepo != nil, export.CustomerColumns,
+		func(shopID uint, _, _ time.Time, write func([][]interface{}) error) error {
+			return h.customerRepo.EachForExport(shopID, streamBatchSize, func(batch []models.Customer) error {
+				rows := make([][]interface{}, len(batch))
+				for i, customer := range batch {
+					rows[i] = export.CustomerRow(customer)
+				}
+				return write(rows)
+			})
+		})
+}
+
+// ExportLoyaltyTransactions exports points earned, redeemed and adjusted,
+// optionally only those from from to to
+func (h *ExportHandler) ExportLoyaltyTransactions(c *fiber.Ctx) error {
+	return h.exportRecords(c, "loyalty_transactions", h.loyaltyRepo != nil, export.LoyaltyTransactionColumns,
+		func(shopID uint, start, end time.Time, write func([][]interface{}) error) error {
+			return h.loyaltyRepo.EachForExport(shopID, start, end, streamBatchSize, func(batch []models.LoyaltyTransaction) error {
+				rows := make([][]interface{}, len(batch))
+				for i, transaction := range batch {
+					rows[i] = export.LoyaltyTransactionRow(transaction)
+				}
+				return write(rows)
+			})
+		})
+}
+
+// ExportMpesaPayments exports STK push payments whatever their status,
+// optionally only those started from from to to
+func (h *ExportHandler) ExportMpesaPayments(c *fiber.Ctx) error {
+	return h.exportRecords(c, "mpesa_payments", h.mpesaPaymentRepo != nil, export.MpesaPaymentColumns,
+		func(shopID uint, start, end time.Time, write func([][]interface{}) error) error {
+			return h.mpesaPaymentRepo.EachForExport(shopID, start, end, streamBatchSize, func(batch []models.MpesaPayment) error {
+				rows := make([][]interface{}, len(batch))
+				for i, payment := range batch {
+					rows[i] = export.MpesaPaymentRow(payment)
+				}
+				return write(rows)
+			})
+		})
+}
+
+// ExportMpesaTransactions exports STK, paybill and B2C transactions,
+// optionally only those made from from to to
+func (h *ExportHandler) ExportMpesaTransactions(c *fiber.Ctx) error {
+	return h.exportRecords(c, "mpesa_transactions", h.mpesaTxRepo != nil, export.MpesaTransactionColumns,
+		func(shopID uint, start, end time.Time, write func([][]interface{}) error) error {
+			return h.mpesaTxRepo.EachForExport(shopID, start, end, streamBatchSize, func(batch []models.MpesaTransaction) error {
+				rows := make([][]interface{}, len(batch))
+				for i, transaction := range batch {
+					rows[i] = export.MpesaTransactionRow(transaction)
+				}
+				return write(rows)
+			})
+		})
+}
+
+// ExportSuppliers exports the shop's suppliers
+func (h *ExportHandler) ExportSuppliers(c *fiber.Ctx) error {
+	return h.exportRecords(c, "suppliers", h.supplierRepo != nil, export.SupplierColumns,
+		func(shopID uint, _, _ time.Time, write func([][]interface{}) error) error {
+			suppliers, err := h.supplierRepo.GetByShopID(shopID)
+			if err != nil {
+				return err
+			}
+			rows := make([][]interface{}, len(suppliers))
+			for i, supplier := range suppliers {
+				rows[i] = export.SupplierRow(supplier)
+			}
+			return write(rows)
+		})
+}
+
+// ExportPurchaseOrders exports supplier orders a row per item, optionally
+// only those placed from from to to
+func (h *ExportHandler) ExportPurchaseOrders(c *fiber.Ctx) error {
+	return h.exportRecords(c, "purchase_orders", h.orderRepo != nil, export.PurchaseOrderColumns,
+		func(shopID uint, start, end time.Time, write func([][]interface{}) error) error {
+			return h.orderRepo.EachForExport(shopID, start, end, streamBatchSize, func(batch []models.Order) error {
+				var rows [][]interface{}
+				for _, order := range batch {
+					rows = append(rows, export.PurchaseOrderRows(order)...)
+				}
+				return write(rows)
+			})
+		})
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/jobs"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// JobHandler reports on background jobs such as queued exports
+type JobHandler struct {
+	queue *jobs.JobQueue
+}
+
+// NewJobHandler creates a new job handler
+func NewJobHandler(queue *jobs.JobQueue) *JobHandler {
+	return &JobHandler{queue: queue}
+}
+
+// GetJob returns a job's status, with a download link once it's done
+// GET /api/v1/jobs/:id
+func (h *JobHandler) GetJob(c *fiber.Ctx) error {
+	job, err := h.shopJob(c)
+	if job == nil {
+		return err
+	}
+
+	response := fiber.Map{"job": job}
+	if job.Status == jobs.StatusDone {
+		response["download_url"] = "/api/v1/jobs/" + job.ID + "/download"
+	}
+	return c.JSON(response)
+}
+
+// Download sends the file a finished job produced. Results are kept for
+// 10 minutes.
+// GET /api/v1/jobs/:id/download
+func (h *JobHandler) Download(c *fiber.Ctx) error {
+	job, err := h.shopJob(c)
+	if job == nil {
+		return err
+	}
+	if job.Status != jobs.StatusDone {
+		return utils.SendError(c, fiber.StatusConflict, utils.CodeConflict, "Job is "+job.Status)
+	}
+
+	result, err := h.queue.Result(job.ID)
+	if errors.Is(err, jobs.ErrNotFound) {
+		return utils.SendError(c, fiber.StatusNotFound, utils.CodeNotFound, "Job result has expired")
+	}
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get job result")
+	}
+
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", result.Filename))
+	c.Set("Content-Type", result.ContentType)
+	return c.Send(result.Data)
+}
+
+// shopJob loads the job in the URL, answering 404 for other shops' jobs.
+// It returns a nil job once an error response has been sent.
+func (h *JobHandler) shopJob(c *fiber.Ctx) (*jobs.Job, error) {
+	shopID, ok := c.Locals("shop_id").(uint)
+	if !ok || shopID == 0 {
+		return nil, utils.SendError(c, fiber.StatusUnauthorized, utils.CodeUnauthorized, "Unauthorized")
+	}
+
+	job, err := h.queue.Get(c.Params("id"))
+	if errors.Is(err, jobs.ErrNotFound) || (err == nil && job.ShopID != shopID) {
+		return nil, utils.SendError(c, fiber.StatusNotFound, utils.CodeNotFound, "Job not found")
+	}
+	if err != nil {
+		return nil, utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get job")
+	}
+	return job, nil
+}
@@ -1,6 +1,10 @@
 package handlers
 
 import (
+	"errors"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/middleware"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/services/job"
 	"github.com/gofiber/fiber/v2"
 )
@@ -20,6 +24,53 @@ func (h *JobSchedulerHandler) RegisterRoutes(app fiber.Router) {
 	jobs.Get("/list", h.ListJobs)
 }
 
+// RegisterAdminRoutes registers admin-only scheduler routes on the admin group
+func (h *JobSchedulerHandler) RegisterAdminRoutes(admin fiber.Router) {
+	admin.Get("/scheduler", middleware.RequireAdmin(), h.GetStatus)
+	admin.Post("/scheduler/:task/run", middleware.RequireAdmin(), h.RunTask)
+}
+
+// RunTask runs a scheduled task immediately and reports its outcome
+// POST /admin/scheduler/:task/run
+func (h *JobSchedulerHandler) RunTask(c *fiber.Ctx) error {
+	if h.scheduler == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Job scheduler not available",
+		})
+	}
+
+	task := c.Params("task")
+	start := time.Now()
+	err := h.scheduler.RunJob(task)
+	duration := time.Since(start)
+
+	switch {
+	case errors.Is(err, job.ErrJobNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+			"code":  "TASK_NOT_FOUND",
+		})
+	case errors.Is(err, job.ErrJobAlreadyRunning):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Task is already running",
+			"code":  "TASK_RUNNING",
+		})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"task":        task,
+			"status":      "failed",
+			"error":       err.Error(),
+			"duration_ms": duration.Milliseconds(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"task":        task,
+		"status":      "completed",
+		"duration_ms": duration.Milliseconds(),
+	})
+}
+
 func (h *JobSchedulerHandler) GetStatus(c *fiber.Ctx) error {
 	if h.scheduler == nil {
 		return c.JSON(fiber.Map{
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/email"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetLoginEvents records logins in the audit trail. countryHeader is the
+// request header the proxy puts the client's country code in, e.g.
+// CF-IPCountry; it is only read from trusted proxies. With emailSvc the
+// owner is emailed when a login comes from a country the account hasn't
+// logged in from before.
+func (h *AuthHandler) SetLoginEvents(repo *repository.LoginEventRepository, countryHeader string, emailSvc *email.Service) {
+	h.loginEvents = repo
+	h.countryHeader = countryHeader
+	h.emailSvc = emailSvc
+}
+
+// GetLoginEvents returns the latest logins to the shop's account across
+// all its shops, or to the shop when it has no account, newest first
+// GET /api/v1/account/security/logins?limit=20
+func (h *AuthHandler) GetLoginEvents(c *fiber.Ctx) error {
+	shop, ok := c.Locals("shop").(*models.Shop)
+	if !ok || shop == nil {
+		return utils.SendError(c, fiber.StatusUnauthorized, utils.CodeUnauthorized, "Unauthorized")
+	}
+	if h.loginEvents == nil {
+		return utils.SendError(c, fiber.StatusServiceUnavailable, utils.CodeServiceUnavailable, "Login history not available")
+	}
+
+	limit := c.QueryInt("limit", 20)
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	events, err := h.loginEvents.Recent(shop.AccountID, shop.ID, limit)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get login history")
+	}
+	return c.JSON(fiber.Map{"data": events, "count": len(events)})
+}
+
+// recordLogin adds a login attempt to the audit trail, and emails the owner
+// when a successful one comes from a new country. Failures here never fail
+// the login.
+func (h *AuthHandler) recordLogin(c *fiber.Ctx, shop *models.Shop, account *models.Account, method string, success bool) {
+	if h.loginEvents == nil || shop == nil {
+		return
+	}
+
+	event := &models.LoginEvent{
+		AccountID: shop.AccountID,
+		ShopID:    shop.ID,
+		IPAddress: c.IP(),
+		UserAgent: truncate(c.Get(fiber.HeaderUserAgent), 255),
+		Country:   h.loginCountry(c),
+		Method:    method,
+		Success:   success,
+	}
+
+	newCountry := false
+	if success && event.Country != "" {
+		known, err := h.loginEvents.KnownCountries(event.AccountID, event.ShopID)
+		if err == nil && len(known) > 0 {
+			newCountry = true
+			for _, country := range known {
+				if country == event.Country {
+					newCountry = false
+					break
+				}
+			}
+		}
+	}
+
+	if err := h.loginEvents.Create(event); err != nil {
+		log.Printf("⚠️ Failed to record login to shop %d: %v", shop.ID, err)
+		return
+	}
+	if newCountry {
+		// Emailed in the background so a slow mail provider doesn't hold up
+		// the login
+		go h.sendLoginAlert(shop, account, event)
+	}
+}
+
+// loginCountry is the two-letter country code the proxy gave the request,
+// or "" when it has none (Cloudflare sends XX for unknown). The header is
+// ignored unless the request came through a trusted proxy, since a client
+// could send any country itself.
+func (h *AuthHandler) loginCountry(c *fiber.Ctx) string {
+	if h.countryHeader == "" || !c.IsProxyTrusted() {
+		return ""
+	}
+	country := strings.ToUpper(strings.TrimSpace(c.Get(h.countryHeader)))
+	if len(country) != 2 || country == "XX" {
+		return ""
+	}
+	for _, r := range country {
+		if r < 'A' || r > 'Z' {
+			return ""
+		}
+	}
+	return country
+}
+
+// sendLoginAlert emails the account owner, or the shop, about a login from
+// a new country
+func (h *AuthHandler) sendLoginAlert(shop *models.Shop, account *models.Account, event *models.LoginEvent) {
+	if h.emailSvc == nil {
+		return
+	}
+	to := shop.Email
+	if account != nil && account.Email != "" {
+		to = account.Email
+	}
+	if to == "" {
+		return
+	}
+
+	vars := map[string]string{
+		"country":    event.Country,
+		"ip_address": event.IPAddress,
+		"user_agent": event.UserAgent,
+		"method":     event.Method,
+		"time":       event.CreatedAt.Format(time.RFC1123),
+	}
+	if err := h.emailSvc.SendTemplate(shop, to, models.EmailTemplateLoginAlert, vars); err != nil {
+		log.Printf("⚠️ Failed to email login alert for shop %d: %v", shop.ID, err)
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/media"
+	"github.com/gofiber/fiber/v2"
+)
+
+// MediaHandler serves the short-lived files sent as WhatsApp attachments
+type MediaHandler struct {
+	host *media.Host
+}
+
+// NewMediaHandler creates a new media handler
+func NewMediaHandler(host *media.Host) *MediaHandler {
+	return &MediaHandler{host: host}
+}
+
+// Serve returns a hosted file until it expires. Names are random, so the
+// URL is the only access check.
+// GET /media/:name
+func (h *MediaHandler) Serve(c *fiber.Ctx) error {
+	path, err := h.host.Path(c.Params("name"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).SendString("Not found")
+	}
+
+	c.Set("Cache-Control", "private, max-age=300")
+	return c.SendFile(path)
+}
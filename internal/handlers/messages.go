@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// MessageHandler lists the WhatsApp messages sent to a shop and whether
+// they were delivered
+type MessageHandler struct {
+	messageRepo *repository.OutboundMessageRepository
+}
+
+// NewMessageHandler creates a new message handler
+func NewMessageHandler(messageRepo *repository.OutboundMessageRepository) *MessageHandler {
+	return &MessageHandler{messageRepo: messageRepo}
+}
+
+// ListMessages returns the shop's sent messages, newest first, with a
+// count per status. status=failed also matches undelivered.
+// GET /api/v1/messages?status=&type=&limit=50&offset=0
+func (h *MessageHandler) ListMessages(c *fiber.Ctx) error {
+	shopID, ok := c.Locals("shop_id").(uint)
+	if !ok || shopID == 0 {
+		return utils.SendError(c, fiber.StatusUnauthorized, utils.CodeUnauthorized, "Unauthorized")
+	}
+
+	limit := c.QueryInt("limit", 50)
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+	offset := c.QueryInt("offset", 0)
+	if offset < 0 {
+		offset = 0
+	}
+
+	messages, total, err := h.messageRepo.List(shopID, c.Query("status"), c.Query("type"), limit, offset)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to list messages")
+	}
+	counts, err := h.messageRepo.CountByStatus(shopID)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to count messages")
+	}
+
+	return c.JSON(fiber.Map{
+		"messages": messages,
+		"counts":   counts,
+		"total":    total,
+		"limit":    limit,
+		"offset":   offset,
+	})
+}
@@ -0,0 +1,172 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// MaxBulkSTKEntries caps how many prompts one bulk request may hold
+	MaxBulkSTKEntries = 50
+	// BulkSTKDeadline is how long a bulk request may spend sending, so it
+	// answers inside a client's or proxy's timeout however slow Daraja is.
+	// Entries not sent by then are reported as not_sent.
+	BulkSTKDeadline = 40 * time.Second
+	// bulkSTKWorkers bounds how many pushes run at once so a bulk request
+	// doesn't trip Daraja's rate limit for the shop's other payments
+	bulkSTKWorkers = 5
+)
+
+// BulkSTKEntry is one payment prompt in a bulk STK request
+type BulkSTKEntry struct {
+	Phone     string  `json:"phone"`
+	Amount    float64 `json:"amount"`
+	Reference string  `json:"reference"`
+}
+
+// BulkSTKResult is how one entry went; entries keep their request order
+type BulkSTKResult struct {
+	Index             int     `json:"index"`
+	Phone             string  `json:"phone"`
+	Amount            float64 `json:"amount"`
+	Reference         string  `json:"reference"`
+	Status            string  `json:"status"`
+	PaymentID         uint    `json:"payment_id,omitempty"`
+	CheckoutRequestID string  `json:"checkout_request_id,omitempty"`
+	Error             string  `json:"error,omitempty"`
+}
+
+// SetBulkSTKDeadline changes how long a bulk request may spend sending
+func (h *Handler) SetBulkSTKDeadline(d time.Duration) {
+	h.bulkDeadline = d
+}
+
+// BulkSTKPush sends an STK prompt to each phone in the list, five at a
+// time, each recorded as its own payment. One entry failing doesn't stop
+// the rest. The whole request shares one deadline; entries it leaves
+// unsent can be sent again.
+func (h *Handler) BulkSTKPush(c *fiber.Ctx) error {
+	shopID := shopIDFromCtx(c)
+	if h.service == nil || !h.service.IsConfiguredForShop(shopID) {
+		return c.Status(503).JSON(fiber.Map{
+			"error": "M-Pesa service is not configured",
+		})
+	}
+
+	var entries []BulkSTKEntry
+	if err := c.BodyParser(&entries); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "invalid request body; send a list of {phone, amount, reference}",
+			"details": err.Error(),
+		})
+	}
+	if len(entries) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "at least one payment is required"})
+	}
+	if len(entries) > MaxBulkSTKEntries {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "too many payments; send at most 50 per request",
+			"max":   MaxBulkSTKEntries,
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.bulkDeadline)
+	defer cancel()
+
+	results := make([]BulkSTKResult, len(entries))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < bulkSTKWorkers && w < len(entries); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = h.bulkSTKPush(ctx, shopID, i, entries[i])
+			}
+		}()
+	}
+	for i := range entries {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	success, notSent := 0, 0
+	for _, r := range results {
+		switch r.Status {
+		case "success":
+			success++
+		case "not_sent":
+			notSent++
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"success":  success,
+		"failed":   len(results) - success - notSent,
+		"not_sent": notSent,
+		"results":  results,
+	})
+}
+
+// bulkSTKPush validates and sends one entry before the request's deadline,
+// giving each push at most 30s so a slow prompt doesn't eat into the
+// others'.
+func (h *Handler) bulkSTKPush(deadline context.Context, shopID uint, index int, entry BulkSTKEntry) BulkSTKResult {
+	result := BulkSTKResult{
+		Index:     index,
+		Phone:     entry.Phone,
+		Amount:    entry.Amount,
+		Reference: entry.Reference,
+		Status:    "failed",
+	}
+
+	switch {
+	case entry.Phone == "":
+		result.Error = "phone number is required"
+		return result
+	case entry.Amount <= 0:
+		result.Error = "amount must be greater than 0"
+		return result
+	case entry.Amount > 150000:
+		result.Error = "amount exceeds maximum allowed (150,000 KES)"
+		return result
+	}
+
+	if deadline.Err() != nil {
+		result.Status = "not_sent"
+		result.Error = "the request ran out of time before this payment was sent; send it again"
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(deadline, 30*time.Second)
+	defer cancel()
+
+	payment, _, err := h.service.InitiateSTKPush(ctx, &mpesa.PaymentRequest{
+		Phone:            entry.Phone,
+		Amount:           entry.Amount,
+		AccountReference: entry.Reference,
+		Description:      "DukaPOS Payment",
+		ShopID:           shopID,
+	})
+	if payment != nil {
+		result.PaymentID = payment.ID
+	}
+	switch {
+	case errors.Is(err, mpesa.ErrRateLimited):
+		result.Error = mpesa.RateLimitMessage
+	case err != nil && deadline.Err() != nil:
+		result.Error = "the request ran out of time while this payment was being sent; check its status before sending it again"
+	case err != nil:
+		result.Error = err.Error()
+	default:
+		result.Status = "success"
+		result.CheckoutRequestID = payment.CheckoutRequestID
+	}
+	return result
+}
@@ -2,11 +2,17 @@ package mpesa
 
 import (
 	"context"
+	"errors"
+	"log"
+	"math"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -17,6 +23,8 @@ type Handler struct {
 	saleRepo        *repository.SaleRepository
 	paymentRepo     *repository.MpesaPaymentRepository
 	transactionRepo *repository.MpesaTransactionRepository
+	reconciler      *mpesa.ReconciliationService
+	bulkDeadline    time.Duration
 }
 
 func New(
@@ -34,6 +42,8 @@ func New(
 		saleRepo:        saleRepo,
 		paymentRepo:     paymentRepo,
 		transactionRepo: transactionRepo,
+		reconciler:      mpesa.NewReconciliationService(paymentRepo, transactionRepo, saleRepo),
+		bulkDeadline:    BulkSTKDeadline,
 	}
 
 	if service != nil {
@@ -45,11 +55,12 @@ func New(
 }
 
 type STKPushRequest struct {
-	Phone       string  `json:"phone"`
-	Amount      float64 `json:"amount"`
-	AccountRef  string  `json:"account_ref"`
-	Description string  `json:"description"`
-	ProductID   *uint   `json:"product_id"`
+	Phone         string  `json:"phone"`
+	Amount        float64 `json:"amount"`
+	AccountRef    string  `json:"account_ref"`
+	Description   string  `json:"description"`
+	ProductID     *uint   `json:"product_id"`
+	PendingSaleID *uint   `json:"pending_sale_id"` // basket from POST /mpesa/pending-sales; amount defaults to its total
 }
 
 type STKPushResponse struct {
@@ -64,7 +75,7 @@ type STKPushResponse struct {
 }
 
 func (h *Handler) STKPush(c *fiber.Ctx) error {
-	if h.service == nil || !h.service.IsConfigured() {
+	if h.service == nil || !h.service.IsConfiguredForShop(shopIDFromCtx(c)) {
 		return c.Status(503).JSON(fiber.Map{
 			"error": "M-Pesa service is not configured. Please set MPESA_CONSUMER_KEY, MPESA_CONSUMER_SECRET, MPESA_SHORTCODE, and MPESA_PASSKEY environment variables.",
 		})
@@ -84,7 +95,7 @@ func (h *Handler) STKPush(c *fiber.Ctx) error {
 		})
 	}
 
-	if req.Amount <= 0 {
+	if req.Amount <= 0 && req.PendingSaleID == nil {
 		return c.Status(400).JSON(fiber.Map{
 			"error": "amount must be greater than 0",
 		})
@@ -104,14 +115,6 @@ func (h *Handler) STKPush(c *fiber.Ctx) error {
 		}
 	}
 
-	accountRef := req.AccountRef
-	if accountRef == "" {
-		shop, err := h.shopRepo.GetByID(shopID)
-		if err == nil {
-			accountRef = shop.Phone
-		}
-	}
-
 	description := req.Description
 	if description == "" {
 		description = "DukaPOS Payment"
@@ -123,13 +126,24 @@ func (h *Handler) STKPush(c *fiber.Ctx) error {
 	paymentReq := &mpesa.PaymentRequest{
 		Phone:            req.Phone,
 		Amount:           req.Amount,
-		AccountReference: accountRef,
+		AccountReference: req.AccountRef,
 		Description:      description,
 		ShopID:           shopID,
 		ProductID:        req.ProductID,
+		PendingSaleID:    req.PendingSaleID,
 	}
 
 	payment, stkResp, err := h.service.InitiateSTKPush(ctx, paymentReq)
+	switch {
+	case errors.Is(err, mpesa.ErrPendingSaleNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "pending sale not found"})
+	case errors.Is(err, repository.ErrPendingSaleClosed):
+		return c.Status(409).JSON(fiber.Map{"error": "pending sale was already paid or cancelled"})
+	case errors.Is(err, mpesa.ErrPendingSaleAmount), errors.Is(err, mpesa.ErrAccountReferenceTooLong):
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, mpesa.ErrRateLimited):
+		return rateLimited(c, err)
+	}
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error":   "failed to initiate payment",
@@ -145,7 +159,7 @@ func (h *Handler) STKPush(c *fiber.Ctx) error {
 		MerchantRequestID: payment.MerchantRequestID,
 		CheckoutRequestID: payment.CheckoutRequestID,
 		ExpiresIn:         300,
-		Amount:            req.Amount,
+		Amount:            payment.Amount,
 		Phone:             req.Phone,
 	}
 
@@ -157,7 +171,7 @@ func (h *Handler) STKPush(c *fiber.Ctx) error {
 }
 
 func (h *Handler) GetStatus(c *fiber.Ctx) error {
-	if h.service == nil || !h.service.IsConfigured() {
+	if h.service == nil || !h.service.IsConfiguredForShop(shopIDFromCtx(c)) {
 		return c.Status(503).JSON(fiber.Map{
 			"error": "M-Pesa service is not configured",
 		})
@@ -223,7 +237,7 @@ func (h *Handler) ListPayments(c *fiber.Ctx) error {
 }
 
 func (h *Handler) RetryPayment(c *fiber.Ctx) error {
-	if h.service == nil || !h.service.IsConfigured() {
+	if h.service == nil || !h.service.IsConfiguredForShop(shopIDFromCtx(c)) {
 		return c.Status(503).JSON(fiber.Map{
 			"error": "M-Pesa service is not configured",
 		})
@@ -239,19 +253,184 @@ func (h *Handler) RetryPayment(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(c.Context(), 30*time.Second)
 	defer cancel()
 
-	newPayment, err := h.service.RetryPayment(ctx, uint(paymentID))
-	if err != nil {
+	payment, err := h.service.RetryPayment(ctx, shopIDFromCtx(c), uint(paymentID))
+	var wait *mpesa.RetryWaitError
+	switch {
+	case errors.Is(err, mpesa.ErrPaymentNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "payment not found"})
+	case errors.Is(err, mpesa.ErrPaymentCompleted):
+		return c.Status(409).JSON(fiber.Map{
+			"error":         "the customer has already paid",
+			"mpesa_receipt": payment.MpesaReceipt,
+		})
+	case errors.Is(err, mpesa.ErrPaymentExpired):
+		return c.Status(410).JSON(fiber.Map{"error": "payment request expired, start a new payment"})
+	case errors.Is(err, mpesa.ErrPromptPending):
+		return c.Status(409).JSON(fiber.Map{
+			"error":      "the customer still has the last payment prompt, wait for it to be paid or to time out",
+			"payment_id": payment.ID,
+		})
+	case errors.Is(err, mpesa.ErrMaxRetriesExceeded):
+		return c.Status(409).JSON(fiber.Map{
+			"error":       "maximum retry attempts exceeded, start a new payment",
+			"retry_count": payment.RetryCount,
+		})
+	case errors.As(err, &wait):
+		seconds := int(math.Ceil(wait.Wait.Seconds()))
+		c.Set("Retry-After", strconv.Itoa(seconds))
+		return c.Status(429).JSON(fiber.Map{
+			"error":         err.Error(),
+			"retry_after":   seconds,
+			"next_retry_at": mpesa.NextRetryAt(payment),
+		})
+	case errors.Is(err, mpesa.ErrMpesaNotConfigured):
+		return c.Status(503).JSON(fiber.Map{"error": "M-Pesa service is not configured"})
+	case errors.Is(err, mpesa.ErrRateLimited):
+		return rateLimited(c, err)
+	case err != nil && payment == nil:
 		return c.Status(500).JSON(fiber.Map{
 			"error":   "failed to retry payment",
 			"details": err.Error(),
 		})
+	case err != nil:
+		return c.Status(502).JSON(fiber.Map{
+			"error":          "failed to retry payment",
+			"details":        err.Error(),
+			"payment_id":     payment.ID,
+			"retry_count":    payment.RetryCount,
+			"failure_reason": payment.FailureReason,
+		})
 	}
 
 	return c.JSON(fiber.Map{
 		"status":              "success",
 		"message":             "Payment retry initiated",
-		"new_payment_id":      newPayment.ID,
-		"checkout_request_id": newPayment.CheckoutRequestID,
+		"payment_id":          payment.ID,
+		"attempt":             payment.RetryCount + 1,
+		"retry_count":         payment.RetryCount,
+		"checkout_request_id": payment.CheckoutRequestID,
+		"next_retry_at":       mpesa.NextRetryAt(payment),
+	})
+}
+
+// SaveCredentials stores the shop's own paybill/till credentials
+// PUT /api/v1/mpesa/credentials
+func (h *Handler) SaveCredentials(c *fiber.Ctx) error {
+	if h.service == nil {
+		return c.Status(503).JSON(fiber.Map{
+			"error": "M-Pesa service not configured",
+		})
+	}
+
+	shopID := shopIDFromCtx(c)
+	if shopID == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "shop not found",
+		})
+	}
+
+	var creds mpesa.ShopCredentials
+	if err := c.BodyParser(&creds); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	if err := h.service.SaveShopCredentials(shopID, &creds); err != nil {
+		status := 400
+		if errors.Is(err, mpesa.ErrEncryptionRequired) {
+			status = 503
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":    "ok",
+		"shortcode": creds.Shortcode,
+	})
+}
+
+// GetCredentials shows which shortcode the shop's payments go to. Secrets
+// are never returned.
+// GET /api/v1/mpesa/credentials
+func (h *Handler) GetCredentials(c *fiber.Ctx) error {
+	if h.service == nil {
+		return c.Status(503).JSON(fiber.Map{
+			"error": "M-Pesa service not configured",
+		})
+	}
+
+	shopID := shopIDFromCtx(c)
+	if shopID == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "shop not found",
+		})
+	}
+
+	shortcode, own := h.service.ShopShortcode(shopID)
+	source := "platform"
+	if own {
+		source = "shop"
+	}
+	return c.JSON(fiber.Map{
+		"configured": shortcode != "",
+		"source":     source,
+		"shortcode":  shortcode,
+	})
+}
+
+// DeleteCredentials removes the shop's own credentials, falling back to the
+// platform shortcode
+// DELETE /api/v1/mpesa/credentials
+func (h *Handler) DeleteCredentials(c *fiber.Ctx) error {
+	if h.service == nil {
+		return c.Status(503).JSON(fiber.Map{
+			"error": "M-Pesa service not configured",
+		})
+	}
+
+	shopID := shopIDFromCtx(c)
+	if shopID == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "shop not found",
+		})
+	}
+
+	if err := h.service.DeleteShopCredentials(shopID); err != nil {
+		status := 500
+		if errors.Is(err, mpesa.ErrEncryptionRequired) {
+			status = 503
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	shortcode, _ := h.service.ShopShortcode(shopID)
+	return c.JSON(fiber.Map{
+		"status":    "ok",
+		"source":    "platform",
+		"shortcode": shortcode,
+	})
+}
+
+// shopIDFromCtx returns the authenticated shop, or 0 when there is none
+func shopIDFromCtx(c *fiber.Ctx) uint {
+	shopID, _ := c.Locals("shop_id").(uint)
+	return shopID
+}
+
+// rateLimited answers a request M-Pesa kept rate limiting after every
+// retry, telling the client when to try again
+func rateLimited(c *fiber.Ctx, err error) error {
+	seconds := mpesa.RetryAfterSeconds(err)
+	c.Set("Retry-After", strconv.Itoa(seconds))
+	return c.Status(429).JSON(fiber.Map{
+		"error":       mpesa.RateLimitMessage,
+		"retry_after": seconds,
+		"code":        "MPESA_RATE_LIMITED",
 	})
 }
 
@@ -265,6 +444,14 @@ func (h *Handler) STKCallback(c *fiber.Ctx) error {
 	body := c.Body()
 
 	payment, err := h.service.ProcessSTKCallback(body)
+	if errors.Is(err, mpesa.ErrDuplicateCallback) {
+		log.Printf("⚠️ Ignoring replayed STK callback for payment %d", payment.ID)
+		return c.JSON(fiber.Map{
+			"status":         "duplicate",
+			"payment_id":     payment.ID,
+			"payment_status": payment.Status,
+		})
+	}
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{
 			"error":   "failed to process callback",
@@ -281,7 +468,26 @@ func (h *Handler) STKCallback(c *fiber.Ctx) error {
 	})
 }
 
-func (h *Handler) C2BCallback(c *fiber.Ctx) error {
+// C2BValidation lets Daraja ask whether a paybill payment should be accepted
+func (h *Handler) C2BValidation(c *fiber.Ctx) error {
+	if h.service == nil {
+		return c.Status(503).JSON(fiber.Map{
+			"error": "M-Pesa service not configured",
+		})
+	}
+
+	var notification mpesa.C2BNotification
+	if err := c.BodyParser(&notification); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "invalid callback body",
+		})
+	}
+
+	return c.JSON(h.service.ValidateC2B(&notification))
+}
+
+// C2BConfirmation records a completed paybill payment
+func (h *Handler) C2BConfirmation(c *fiber.Ctx) error {
 	if h.service == nil || h.transactionRepo == nil {
 		return c.Status(503).JSON(fiber.Map{
 			"error": "M-Pesa service not configured",
@@ -295,23 +501,109 @@ func (h *Handler) C2BCallback(c *fiber.Ctx) error {
 		})
 	}
 
-	tx, err := h.service.HandleC2BNotification(&notification)
-	if err != nil {
+	if _, err := h.service.HandleC2BNotification(&notification); err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error": "failed to process C2B notification",
 		})
 	}
 
 	return c.JSON(fiber.Map{
-		"status":         "ok",
-		"transaction_id": tx.TransactionID,
-		"amount":         tx.Amount,
+		"ResultCode": 0,
+		"ResultDesc": "Accepted",
 	})
 }
 
+// RegisterC2BURLs registers the paybill callbacks for the shop's own shortcode
+func (h *Handler) RegisterC2BURLs(c *fiber.Ctx) error {
+	return h.registerC2BURLs(c, shopIDFromCtx(c))
+}
+
+// RegisterPlatformC2BURLs registers the paybill callbacks for the platform shortcode
+func (h *Handler) RegisterPlatformC2BURLs(c *fiber.Ctx) error {
+	return h.registerC2BURLs(c, 0)
+}
+
+func (h *Handler) registerC2BURLs(c *fiber.Ctx, shopID uint) error {
+	if h.service == nil {
+		return c.Status(503).JSON(fiber.Map{
+			"error": "M-Pesa service not configured",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 30*time.Second)
+	defer cancel()
+
+	if err := h.service.RegisterC2BURLs(ctx, shopID); err != nil {
+		switch {
+		case errors.Is(err, mpesa.ErrC2BNotConfigured), errors.Is(err, mpesa.ErrMpesaNotConfigured):
+			return c.Status(503).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case errors.Is(err, mpesa.ErrShopCredentialsRequired):
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Payments to the DukaPOS paybill need no registration. Save your own paybill credentials first.",
+				"code":  "CREDENTIALS_REQUIRED",
+			})
+		case errors.Is(err, mpesa.ErrRateLimited):
+			return rateLimited(c, err)
+		default:
+			return c.Status(502).JSON(fiber.Map{
+				"error":   "failed to register C2B URLs",
+				"details": err.Error(),
+			})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "registered",
+	})
+}
+
+// B2CCallback handles the Daraja B2C result callback
 func (h *Handler) B2CCallback(c *fiber.Ctx) error {
+	if h.service == nil {
+		return c.Status(503).JSON(fiber.Map{
+			"error": "M-Pesa service not configured",
+		})
+	}
+
+	payout, err := h.service.ProcessB2CResult(c.Body())
+	return h.b2cCallbackResponse(c, payout, err)
+}
+
+// B2CTimeoutCallback handles the Daraja B2C queue timeout callback
+func (h *Handler) B2CTimeoutCallback(c *fiber.Ctx) error {
+	if h.service == nil {
+		return c.Status(503).JSON(fiber.Map{
+			"error": "M-Pesa service not configured",
+		})
+	}
+
+	payout, err := h.service.ProcessB2CTimeout(c.Body())
+	return h.b2cCallbackResponse(c, payout, err)
+}
+
+func (h *Handler) b2cCallbackResponse(c *fiber.Ctx, payout *models.B2CPayout, err error) error {
+	if errors.Is(err, mpesa.ErrDuplicateCallback) {
+		log.Printf("⚠️ Ignoring replayed B2C callback for payout %d", payout.ID)
+		return c.JSON(fiber.Map{
+			"status":        "duplicate",
+			"payout_id":     payout.ID,
+			"payout_status": payout.Status,
+		})
+	}
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "failed to process callback",
+			"details": err.Error(),
+		})
+	}
+
 	return c.JSON(fiber.Map{
-		"status": "ok",
+		"status":         "ok",
+		"payout_id":      payout.ID,
+		"payout_status":  payout.Status,
+		"receipt_number": payout.ReceiptNumber,
 	})
 }
 
@@ -329,17 +621,31 @@ func (h *Handler) GetBalance(c *fiber.Ctx) error {
 }
 
 type B2CRequest struct {
-	Phone   string  `json:"phone"`
-	Amount  float64 `json:"amount"`
-	Remarks string  `json:"remarks"`
+	Phone     string  `json:"phone"`
+	Amount    float64 `json:"amount"`
+	CommandID string  `json:"command_id"`
+	Remarks   string  `json:"remarks"`
+	Occasion  string  `json:"occasion"`
+
+	// Currency and ExchangeRate (KES per unit) send a foreign amount as KES
+	Currency     string  `json:"currency"`
+	ExchangeRate float64 `json:"exchange_rate"`
 }
 
+// B2CSend pays out from the shop's shortcode to a customer's M-Pesa
+// POST /api/v1/mpesa/b2c
 func (h *Handler) B2CSend(c *fiber.Ctx) error {
-	if h.service == nil || !h.service.IsConfigured() {
+	shopID := shopIDFromCtx(c)
+	if h.service == nil {
 		return c.Status(503).JSON(fiber.Map{
 			"error": "M-Pesa service not configured",
 		})
 	}
+	if err := h.service.B2CConfigError(shopID); err != nil {
+		return c.Status(503).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 
 	var req B2CRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -359,11 +665,91 @@ func (h *Handler) B2CSend(c *fiber.Ctx) error {
 		})
 	}
 
+	ctx, cancel := context.WithTimeout(c.Context(), 30*time.Second)
+	defer cancel()
+
+	payout, err := h.service.InitiateB2C(ctx, &mpesa.B2CRequest{
+		ShopID:    shopID,
+		Phone:     req.Phone,
+		Amount:    req.Amount,
+		CommandID: req.CommandID,
+		Remarks:   req.Remarks,
+		Occasion:  req.Occasion,
+		Forex: mpesa.B2CForexRequest{
+			Currency:     req.Currency,
+			ExchangeRate: req.ExchangeRate,
+		},
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, mpesa.ErrB2CDailyLimit):
+			return c.Status(403).JSON(fiber.Map{
+				"error": err.Error(),
+				"code":  "B2C_DAILY_LIMIT",
+			})
+		case errors.Is(err, mpesa.ErrRateLimited):
+			return rateLimited(c, err)
+		case payout == nil:
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		default:
+			return c.Status(502).JSON(fiber.Map{
+				"error":     "failed to send payout",
+				"details":   err.Error(),
+				"code":      "B2C_FAILED",
+				"payout_id": payout.ID,
+			})
+		}
+	}
+
+	resp := fiber.Map{
+		"status":          "submitted",
+		"message":         "Payout sent to M-Pesa. The result will be confirmed shortly.",
+		"payout_id":       payout.ID,
+		"conversation_id": payout.ConversationID,
+		"phone":           payout.Phone,
+		"amount":          payout.Amount,
+	}
+	if payout.Currency != "" {
+		resp["currency"] = payout.Currency
+		resp["foreign_amount"] = payout.ForeignAmount
+		resp["exchange_rate"] = payout.ExchangeRate
+	}
+	return c.Status(202).JSON(resp)
+}
+
+// ListB2CPayouts lists the shop's payouts, with the exchange rate of any
+// sent in another currency
+// GET /api/v1/mpesa/b2c
+// GET /api/v1/mpesa/payouts
+func (h *Handler) ListB2CPayouts(c *fiber.Ctx) error {
+	if h.service == nil {
+		return c.Status(503).JSON(fiber.Map{
+			"error": "M-Pesa service not configured",
+		})
+	}
+
+	shopID := c.Locals("shop_id").(uint)
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	offset, _ := strconv.Atoi(c.Query("offset", "0"))
+
+	if limit > 100 {
+		limit = 100
+	}
+
+	payouts, total, err := h.service.GetB2CPayoutsByShop(shopID, limit, offset)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "failed to fetch payouts",
+		})
+	}
+
 	return c.JSON(fiber.Map{
-		"status":  "pending",
-		"message": "B2C payments require additional Safaricom API access",
-		"phone":   req.Phone,
-		"amount":  req.Amount,
+		"data":   payouts,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
 	})
 }
 
@@ -396,3 +782,186 @@ func (h *Handler) GetTransactions(c *fiber.Ctx) error {
 		"offset": offset,
 	})
 }
+
+// GetReconciliation matches M-Pesa payments to sales by receipt for a date
+// range, flagging money received with no sale and M-Pesa sales with no payment.
+// discrepancies=true leaves matched receipts out of the rows.
+// GET /api/v1/mpesa/reconciliation?from=YYYY-MM-DD&to=YYYY-MM-DD&discrepancies=true
+func (h *Handler) GetReconciliation(c *fiber.Ctx) error {
+	from, to, err := mpesa.ParseReconciliationRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	report, err := h.reconciler.Reconcile(shopIDFromCtx(c), from, to)
+	if err != nil {
+		log.Printf("❌ M-Pesa reconciliation failed: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "failed to reconcile payments",
+		})
+	}
+	if c.QueryBool("discrepancies") {
+		report.Discrepancies()
+	}
+
+	return c.JSON(report)
+}
+
+// GetTransactionStatus queries Daraja for the status of a transaction. The
+// result is stored on the transaction when M-Pesa posts it back.
+// GET /api/v1/mpesa/transactions/:id/status
+func (h *Handler) GetTransactionStatus(c *fiber.Ctx) error {
+	if h.service == nil {
+		return utils.SendError(c, 503, utils.CodeServiceUnavailable, "M-Pesa service not configured")
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return utils.SendError(c, 400, utils.CodeInvalidRequest, "invalid transaction ID")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 30*time.Second)
+	defer cancel()
+
+	tx, err := h.service.TransactionStatus(ctx, shopIDFromCtx(c), uint(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, mpesa.ErrStatusNotConfigured):
+			return utils.SendError(c, 503, utils.CodeServiceUnavailable, initiatorRequiredMessage("Transaction status checks", "MPESA_STATUS_RESULT_URL"))
+		case errors.Is(err, mpesa.ErrTransactionNotFound):
+			return utils.SendError(c, 404, utils.CodeNotFound, "transaction not found")
+		case errors.Is(err, mpesa.ErrRateLimited):
+			return rateLimited(c, err)
+		default:
+			return utils.SendError(c, 502, utils.CodeNetworkError, "failed to query transaction status: "+err.Error())
+		}
+	}
+
+	return c.Status(202).JSON(fiber.Map{
+		"status":      "submitted",
+		"message":     "Status query sent to M-Pesa. The result will be stored on the transaction shortly.",
+		"transaction": tx,
+	})
+}
+
+// initiatorRequiredMessage explains that a feature needs the shop's own
+// initiator, saved with its M-Pesa credentials
+func initiatorRequiredMessage(feature, resultURL string) string {
+	return feature + " need the shop's own M-Pesa credentials with initiator_name and security_credential, and " + resultURL + " on the server."
+}
+
+type ReversalRequest struct {
+	Confirm string `json:"confirm"` // must repeat the transaction's receipt code
+	Reason  string `json:"reason"`
+}
+
+// ReverseTransaction returns a mistaken payment to the customer
+// POST /api/v1/mpesa/transactions/:id/reverse
+func (h *Handler) ReverseTransaction(c *fiber.Ctx) error {
+	shopID := shopIDFromCtx(c)
+	if h.service == nil || !h.service.IsReversalConfiguredForShop(shopID) {
+		return utils.SendError(c, 503, utils.CodeServiceUnavailable, initiatorRequiredMessage("Reversals", "MPESA_REVERSAL_RESULT_URL"))
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return utils.SendError(c, 400, utils.CodeInvalidRequest, "invalid transaction ID")
+	}
+
+	var req ReversalRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, 400, utils.CodeInvalidRequest, "invalid request body")
+	}
+
+	tx, err := h.transactionRepo.GetByID(uint(id))
+	if err != nil || tx.ShopID != shopID {
+		return utils.SendError(c, 404, utils.CodeNotFound, "transaction not found")
+	}
+
+	// Reversals can't be undone, so the owner has to type the receipt code
+	if !strings.EqualFold(strings.TrimSpace(req.Confirm), tx.Receipt()) {
+		return utils.SendError(c, 400, utils.CodeValidationError, "confirm must repeat the receipt code of the transaction being reversed")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 30*time.Second)
+	defer cancel()
+
+	tx, err = h.service.Reversal(ctx, &mpesa.ReversalRequest{
+		ShopID:        shopID,
+		TransactionID: uint(id),
+		Reason:        req.Reason,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, mpesa.ErrReversalInProgress):
+			return utils.SendError(c, 409, utils.CodeConflict, err.Error())
+		case errors.Is(err, mpesa.ErrRateLimited):
+			return rateLimited(c, err)
+		case tx == nil:
+			return utils.SendError(c, 400, utils.CodeValidationError, err.Error())
+		default:
+			return utils.SendError(c, 502, utils.CodeNetworkError, "failed to request reversal: "+err.Error())
+		}
+	}
+
+	return c.Status(202).JSON(fiber.Map{
+		"status":      "submitted",
+		"message":     "Reversal sent to M-Pesa. The result will be confirmed shortly.",
+		"transaction": tx,
+	})
+}
+
+// StatusResultCallback handles the Daraja transaction status result. A
+// receipt paid to another shortcode is acknowledged but not recorded as a
+// payment.
+func (h *Handler) StatusResultCallback(c *fiber.Ctx) error {
+	if h.service == nil {
+		return utils.SendError(c, 503, utils.CodeServiceUnavailable, "M-Pesa service not configured")
+	}
+
+	tx, err := h.service.ProcessStatusResult(c.Body())
+	if errors.Is(err, mpesa.ErrReceiptNotForShop) {
+		log.Printf("⚠️ Rejected status result for transaction %d: %v", tx.ID, err)
+		return c.JSON(fiber.Map{
+			"status":         "rejected",
+			"transaction_id": tx.ID,
+		})
+	}
+	if err != nil {
+		return utils.SendError(c, 400, utils.CodeInvalidRequest, "failed to process callback: "+err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status":         "ok",
+		"transaction_id": tx.ID,
+		"status_result":  tx.StatusResult,
+	})
+}
+
+// ReversalResultCallback handles the Daraja reversal result
+func (h *Handler) ReversalResultCallback(c *fiber.Ctx) error {
+	if h.service == nil {
+		return utils.SendError(c, 503, utils.CodeServiceUnavailable, "M-Pesa service not configured")
+	}
+
+	tx, err := h.service.ProcessReversalResult(c.Body())
+	if errors.Is(err, mpesa.ErrDuplicateCallback) {
+		log.Printf("⚠️ Ignoring replayed reversal callback for transaction %d", tx.ID)
+		return c.JSON(fiber.Map{
+			"status":          "duplicate",
+			"transaction_id":  tx.ID,
+			"reversal_status": tx.ReversalStatus,
+		})
+	}
+	if err != nil {
+		return utils.SendError(c, 400, utils.CodeInvalidRequest, "failed to process callback: "+err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status":          "ok",
+		"transaction_id":  tx.ID,
+		"reversal_status": tx.ReversalStatus,
+	})
+}
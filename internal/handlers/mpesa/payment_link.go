@@ -0,0 +1,115 @@
+package mpesa
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
+	"github.com/gofiber/fiber/v2"
+)
+
+type CreatePaymentLinkRequest struct {
+	Amount         float64 `json:"amount"` // 0 lets the customer choose
+	Description    string  `json:"description"`
+	ExpiresInHours int     `json:"expires_in_hours"`
+}
+
+// PaymentLinkResponse is a payment link with the URL to share
+type PaymentLinkResponse struct {
+	*models.PaymentLink
+	URL string `json:"url"`
+}
+
+// CreatePaymentLink creates a link a customer can open to pay by STK push
+func (h *Handler) CreatePaymentLink(c *fiber.Ctx) error {
+	if h.service == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "M-Pesa service is not configured"})
+	}
+
+	var req CreatePaymentLinkRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
+	}
+	if req.ExpiresInHours < 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "expires_in_hours cannot be negative"})
+	}
+
+	ttl := time.Duration(req.ExpiresInHours) * time.Hour
+	link, err := h.service.CreatePaymentLink(shopIDFromCtx(c), req.Amount, req.Description, ttl)
+	if errors.Is(err, mpesa.ErrPaymentLinkAmount) {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to create payment link"})
+	}
+	return c.Status(201).JSON(PaymentLinkResponse{link, h.service.PaymentLinkURL(link)})
+}
+
+func (h *Handler) ListPaymentLinks(c *fiber.Ctx) error {
+	if h.service == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "M-Pesa service is not configured"})
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	offset, _ := strconv.Atoi(c.Query("offset", "0"))
+	if limit > 100 {
+		limit = 100
+	}
+
+	links, total, err := h.service.ListPaymentLinks(shopIDFromCtx(c), limit, offset)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to fetch payment links"})
+	}
+
+	data := make([]PaymentLinkResponse, len(links))
+	for i := range links {
+		data[i] = PaymentLinkResponse{&links[i], h.service.PaymentLinkURL(&links[i])}
+	}
+	return c.JSON(fiber.Map{
+		"data":   data,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+func (h *Handler) GetPaymentLink(c *fiber.Ctx) error {
+	if h.service == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "M-Pesa service is not configured"})
+	}
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid payment link ID"})
+	}
+
+	link, err := h.service.GetPaymentLink(shopIDFromCtx(c), uint(id))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "payment link not found"})
+	}
+	return c.JSON(PaymentLinkResponse{link, h.service.PaymentLinkURL(link)})
+}
+
+func (h *Handler) CancelPaymentLink(c *fiber.Ctx) error {
+	if h.service == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "M-Pesa service is not configured"})
+	}
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid payment link ID"})
+	}
+
+	err = h.service.CancelPaymentLink(shopIDFromCtx(c), uint(id))
+	switch {
+	case errors.Is(err, mpesa.ErrPaymentLinkNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "payment link not found"})
+	case errors.Is(err, mpesa.ErrPaymentLinkUnavailable):
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{"error": "failed to cancel payment link"})
+	}
+	return c.JSON(fiber.Map{"message": "payment link cancelled"})
+}
@@ -0,0 +1,114 @@
+package mpesa
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
+	"github.com/gofiber/fiber/v2"
+)
+
+type CreatePendingSaleRequest struct {
+	Items []mpesa.PendingSaleItemRequest `json:"items"`
+	Notes string                         `json:"notes"`
+}
+
+type AttributePaymentRequest struct {
+	PendingSaleID uint `json:"pending_sale_id"`
+}
+
+// CreatePendingSale prices a basket so an STK push can pay for exactly it
+func (h *Handler) CreatePendingSale(c *fiber.Ctx) error {
+	if h.service == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "M-Pesa service is not configured"})
+	}
+
+	var req CreatePendingSaleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
+	}
+
+	pending, err := h.service.CreatePendingSale(shopIDFromCtx(c), req.Items, req.Notes)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(201).JSON(pending)
+}
+
+func (h *Handler) GetPendingSale(c *fiber.Ctx) error {
+	if h.service == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "M-Pesa service is not configured"})
+	}
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid pending sale ID"})
+	}
+
+	pending, err := h.service.GetPendingSale(shopIDFromCtx(c), uint(id))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "pending sale not found"})
+	}
+	return c.JSON(pending)
+}
+
+func (h *Handler) CancelPendingSale(c *fiber.Ctx) error {
+	if h.service == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "M-Pesa service is not configured"})
+	}
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid pending sale ID"})
+	}
+
+	err = h.service.CancelPendingSale(shopIDFromCtx(c), uint(id))
+	switch {
+	case errors.Is(err, mpesa.ErrPendingSaleNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "pending sale not found"})
+	case errors.Is(err, repository.ErrPendingSaleClosed):
+		return c.Status(409).JSON(fiber.Map{"error": "pending sale was already paid or cancelled"})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{"error": "failed to cancel pending sale"})
+	}
+	return c.JSON(fiber.Map{"message": "pending sale cancelled"})
+}
+
+// AttributePayment records a completed payment that came in without a
+// basket against one of the shop's pending sales
+func (h *Handler) AttributePayment(c *fiber.Ctx) error {
+	if h.service == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "M-Pesa service is not configured"})
+	}
+
+	paymentID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid payment ID"})
+	}
+
+	var req AttributePaymentRequest
+	if err := c.BodyParser(&req); err != nil || req.PendingSaleID == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "pending_sale_id is required"})
+	}
+
+	sales, err := h.service.AttributePayment(shopIDFromCtx(c), uint(paymentID), req.PendingSaleID)
+	switch {
+	case errors.Is(err, mpesa.ErrPaymentNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "payment not found"})
+	case errors.Is(err, mpesa.ErrPendingSaleNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "pending sale not found"})
+	case errors.Is(err, mpesa.ErrPaymentNotCompleted), errors.Is(err, mpesa.ErrPaymentAlreadyLinked),
+		errors.Is(err, repository.ErrPendingSaleClosed), errors.Is(err, repository.ErrInsufficientStock):
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, mpesa.ErrPendingSaleAmount):
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{"error": "failed to record sale"})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "payment recorded against pending sale",
+		"sales":   sales,
+	})
+}
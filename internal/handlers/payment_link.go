@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
+	"github.com/gofiber/fiber/v2"
+)
+
+// paymentLinkPage is the page a payment link opens. It works without
+// JavaScript so it loads on any phone browser.
+var paymentLinkPage = template.Must(template.New("pay").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Pay {{.ShopName}}</title>
+<style>
+body{font-family:sans-serif;max-width:420px;margin:2em auto;padding:0 1em;color:#222}
+h1{font-size:1.3em}.amount{font-size:2em;font-weight:bold;margin:.3em 0}
+input,button{width:100%;box-sizing:border-box;padding:.8em;margin:.4em 0;font-size:1em}
+button{background:#2e7d32;color:#fff;border:0;border-radius:4px}
+.error{color:#c62828}.note{color:#555}
+</style>
+</head>
+<body>
+<h1>{{.ShopName}}</h1>
+{{if .Description}}<p>{{.Description}}</p>{{end}}
+{{if .Message}}<p class="note">{{.Message}}</p>{{end}}
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+{{if .Payable}}
+<form method="post">
+{{if .OpenAmount}}<label>Amount (KSh)<input name="amount" type="number" min="1" step="1" value="{{.Amount}}" required></label>
+{{else}}<p class="amount">KSh {{.Amount}}</p>{{end}}
+<label>M-Pesa phone number<input name="phone" type="tel" placeholder="07XX XXX XXX" value="{{.Phone}}" required></label>
+<button type="submit">Pay with M-Pesa</button>
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+type paymentLinkView struct {
+	ShopName    string
+	Description string
+	Amount      string
+	OpenAmount  bool
+	Payable     bool
+	Phone       string
+	Message     string
+	Error       string
+}
+
+// SetPaymentLinks enables the public payment link pages
+func (h *WebHandler) SetPaymentLinks(mpesaSvc *mpesa.Service) {
+	h.mpesaSvc = mpesaSvc
+}
+
+// PaymentLinkPage shows a payment link to the customer
+// GET /pay/:token
+func (h *WebHandler) PaymentLinkPage(c *fiber.Ctx) error {
+	link, view, err := h.loadPaymentLink(c.Params("token"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).SendString("Payment link not found")
+	}
+	if !link.IsPayable(time.Now()) {
+		view.Message = paymentLinkClosedMessage(link)
+	}
+	return renderPaymentLink(c, view)
+}
+
+// PayPaymentLink sends the STK push for a payment link to the phone number
+// the customer entered
+// POST /pay/:token
+func (h *WebHandler) PayPaymentLink(c *fiber.Ctx) error {
+	link, view, err := h.loadPaymentLink(c.Params("token"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).SendString("Payment link not found")
+	}
+
+	view.Phone = strings.TrimSpace(c.FormValue("phone"))
+	var amount float64
+	if link.IsOpenAmount() {
+		view.Amount = strings.TrimSpace(c.FormValue("amount"))
+		if amount, err = strconv.ParseFloat(view.Amount, 64); err != nil {
+			view.Error = "Enter the amount to pay."
+			return renderPaymentLink(c.Status(fiber.StatusBadRequest), view)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err = h.mpesaSvc.PayPaymentLink(ctx, link.Token, view.Phone, amount)
+	switch {
+	case err == nil:
+		view.Payable = false
+		view.Message = "Check your phone and enter your M-Pesa PIN to complete the payment."
+		return renderPaymentLink(c, view)
+	case errors.Is(err, mpesa.ErrPaymentLinkUnavailable):
+		view.Payable = false
+		view.Message = paymentLinkClosedMessage(link)
+		return renderPaymentLink(c.Status(fiber.StatusConflict), view)
+	case errors.Is(err, mpesa.ErrInvalidPhone):
+		view.Error = "Enter a valid M-Pesa number, e.g. 0712 345 678."
+		return renderPaymentLink(c.Status(fiber.StatusBadRequest), view)
+	case errors.Is(err, mpesa.ErrPaymentLinkAmount):
+		view.Error = "The amount must be between KSh 1 and KSh 150,000."
+		return renderPaymentLink(c.Status(fiber.StatusBadRequest), view)
+	case errors.Is(err, mpesa.ErrRateLimited):
+		view.Error = mpesa.RateLimitMessage + "."
+		c.Set("Retry-After", strconv.Itoa(mpesa.RetryAfterSeconds(err)))
+		return renderPaymentLink(c.Status(fiber.StatusTooManyRequests), view)
+	default:
+		view.Error = "We could not send the payment prompt. Please try again."
+		return renderPaymentLink(c.Status(fiber.StatusBadGateway), view)
+	}
+}
+
+// loadPaymentLink loads a link and the shop it pays
+func (h *WebHandler) loadPaymentLink(token string) (*models.PaymentLink, *paymentLinkView, error) {
+	if h.mpesaSvc == nil {
+		return nil, nil, mpesa.ErrPaymentLinkNotFound
+	}
+	link, err := h.mpesaSvc.GetPaymentLinkByToken(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	shop, err := h.shopRepo.GetByID(link.ShopID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	view := &paymentLinkView{
+		ShopName:    shop.Name,
+		Description: link.Description,
+		OpenAmount:  link.IsOpenAmount(),
+		Payable:     link.IsPayable(time.Now()),
+	}
+	if shop.BrandName != "" {
+		view.ShopName = shop.BrandName
+	}
+	if !link.IsOpenAmount() {
+		view.Amount = strconv.FormatFloat(link.Amount, 'f', 0, 64)
+	}
+	return link, view, nil
+}
+
+func paymentLinkClosedMessage(link *models.PaymentLink) string {
+	switch link.Status {
+	case models.PaymentLinkPaid:
+		return "This link has already been paid. Thank you!"
+	case models.PaymentLinkCancelled:
+		return "This payment link has been cancelled."
+	default:
+		return "This payment link has expired."
+	}
+}
+
+func renderPaymentLink(c *fiber.Ctx, view *paymentLinkView) error {
+	c.Type("html", "utf-8")
+	return paymentLinkPage.Execute(c, view)
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetPriceHistory returns a product's selling and cost price changes,
+// newest first. from and to are inclusive dates (YYYY-MM-DD).
+// GET /api/v1/products/:id/price-history?from=&to=&limit=50
+func (h *ProductHandler) GetPriceHistory(c *fiber.Ctx) error {
+	product, err := h.shopProduct(c)
+	if err != nil {
+		return err
+	}
+
+	if h.priceRepo == nil {
+		return utils.SendError(c, fiber.StatusServiceUnavailable, utils.CodeServiceUnavailable, "Price history not available")
+	}
+
+	limit := c.QueryInt("limit", 50)
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	var from, to time.Time
+	if v := c.Query("from"); v != "" {
+		if from, err = time.ParseInLocation("2006-01-02", v, time.Local); err != nil {
+			return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "from must be a date (YYYY-MM-DD)")
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if to, err = time.ParseInLocation("2006-01-02", v, time.Local); err != nil {
+			return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "to must be a date (YYYY-MM-DD)")
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+
+	history, err := h.priceRepo.GetByProductBetween(product.ID, from, to, limit)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get price history")
+	}
+
+	return c.JSON(fiber.Map{
+		"product_id":    product.ID,
+		"name":          product.Name,
+		"current_price": product.SellingPrice,
+		"cost_price":    product.CostPrice,
+		"history":       history,
+	})
+}
+
+// priceChangedBy identifies who is making a request's price changes
+func priceChangedBy(c *fiber.Ctx) models.PriceChangedBy {
+	if key, ok := c.Locals("api_key").(*models.APIKey); ok {
+		return models.PriceChangedBy{Type: "api_key", ID: key.ID}
+	}
+	shopID, _ := c.Locals("shop_id").(uint)
+	return models.ChangedByShop(shopID)
+}
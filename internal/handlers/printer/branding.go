@@ -0,0 +1,285 @@
+package printer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/images"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/printer"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/storage"
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetShopRepo puts each shop's branding on its receipts and enables the
+// branding endpoints
+func (h *Handler) SetShopRepo(repo *repository.ShopRepository) {
+	h.shopRepo = repo
+}
+
+// SetImageStore sets where receipt logos are saved and the URL prefix the
+// store is served under, e.g. a LocalStore on the static directory and
+// "/static/"
+func (h *Handler) SetImageStore(store storage.Store, urlPrefix string) {
+	h.logoStore = store
+	h.logoURLPrefix = urlPrefix
+}
+
+// BrandingRequest is a shop's receipt branding. It replaces the text
+// settings; the logo is kept unless a new "logo" form file is uploaded or
+// remove_logo is set.
+type BrandingRequest struct {
+	Header         string `json:"header" form:"header"`
+	Footer         string `json:"footer" form:"footer"`
+	Contact        string `json:"contact" form:"contact"`
+	VATNumber      string `json:"vat_number" form:"vat_number"`
+	CurrencySymbol string `json:"currency_symbol" form:"currency_symbol"`
+	ShowQR         bool   `json:"show_qr" form:"show_qr"`
+	RemoveLogo     bool   `json:"remove_logo" form:"remove_logo"`
+}
+
+// maxHeaderLines is how many header lines fit on a receipt before the
+// shop's details
+const maxHeaderLines = 5
+
+// taxPINPattern is a KRA PIN, which is also the VAT number: A for a person
+// or P for a company, nine digits and a check letter
+var taxPINPattern = regexp.MustCompile(`^[AP][0-9]{9}[A-Z]$`)
+
+// brandingLimits are the longest values the shop columns hold
+var brandingLimits = []struct {
+	field string
+	max   int
+}{
+	{"header", 255},
+	{"footer", 500},
+	{"contact", 255},
+	{"vat_number", 30},
+	{"currency_symbol", 10},
+}
+
+// GetBranding returns the shop's receipt branding
+// GET /api/v1/print/branding
+func (h *Handler) GetBranding(c *fiber.Ctx) error {
+	if h.shopRepo == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "receipt branding not available",
+		})
+	}
+	shop, err := h.shopRepo.GetByID(c.Locals("shop_id").(uint))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "shop not found",
+		})
+	}
+	return c.JSON(brandingResponse(shop))
+}
+
+// UpdateBranding sets the shop's receipt header, footer, contact line, VAT
+// number, currency symbol and QR code, and its logo from the "logo" form
+// file (JPEG, PNG or GIF, up to 2 MB)
+// PUT /api/v1/print/branding
+func (h *Handler) UpdateBranding(c *fiber.Ctx) error {
+	if h.shopRepo == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "receipt branding not available",
+		})
+	}
+	var req BrandingRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+	values := map[string]*string{
+		"header":          &req.Header,
+		"footer":          &req.Footer,
+		"contact":         &req.Contact,
+		"vat_number":      &req.VATNumber,
+		"currency_symbol": &req.CurrencySymbol,
+	}
+	for _, limit := range brandingLimits {
+		v := values[limit.field]
+		*v = strings.TrimSpace(*v)
+		if utf8.RuneCountInString(*v) > limit.max {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("%s must be at most %d characters", limit.field, limit.max),
+			})
+		}
+	}
+	header, err := headerLines(req.Header)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	req.VATNumber = strings.ToUpper(req.VATNumber)
+	if req.VATNumber != "" && !taxPINPattern.MatchString(req.VATNumber) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "vat_number must be a KRA PIN, e.g. P051234567X",
+		})
+	}
+
+	shop, err := h.shopRepo.GetByID(c.Locals("shop_id").(uint))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "shop not found",
+		})
+	}
+
+	oldLogo := shop.ReceiptLogo
+	newKey := ""
+	if header, err := c.FormFile("logo"); err == nil {
+		if h.logoStore == nil {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"error": "logo uploads not available",
+			})
+		}
+		if header.Size > images.MaxUploadSize {
+			return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+				"error": images.ErrTooLarge.Error(),
+			})
+		}
+		file, err := header.Open()
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid logo upload",
+			})
+		}
+		defer file.Close()
+		data, err := io.ReadAll(io.LimitReader(file, images.MaxUploadSize+1))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid logo upload",
+			})
+		}
+		ext, err := images.Validate(data)
+		if errors.Is(err, images.ErrTooLarge) || errors.Is(err, images.ErrTooManyPixels) {
+			return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		// Checked now so a logo that cannot print is refused, not skipped
+		if _, err := printer.RasterLogo(data); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "logo could not be read",
+			})
+		}
+
+		// A new name on every upload so cached copies of the old logo go stale
+		newKey = fmt.Sprintf("receipts/%d/logo-%d.%s", shop.ID, time.Now().UnixNano(), ext)
+		if _, err := h.logoStore.Save(newKey, data); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to save logo",
+			})
+		}
+		shop.ReceiptLogo = h.logoURLPrefix + newKey
+	} else if req.RemoveLogo {
+		shop.ReceiptLogo = ""
+	}
+
+	shop.ReceiptHeader = header
+	shop.ReceiptFooter = req.Footer
+	shop.ReceiptContact = req.Contact
+	shop.VATNumber = req.VATNumber
+	shop.ReceiptCurrency = req.CurrencySymbol
+	shop.ReceiptShowQR = req.ShowQR
+	if err := h.shopRepo.Update(shop); err != nil {
+		if newKey != "" {
+			h.logoStore.Delete(newKey)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to update receipt branding",
+		})
+	}
+	if shop.ReceiptLogo != oldLogo {
+		h.deleteLogo(oldLogo)
+	}
+
+	return c.JSON(brandingResponse(shop))
+}
+
+// headerLines drops the blank lines of a header, which may have up to
+// maxHeaderLines lines
+func headerLines(header string) (string, error) {
+	var lines []string
+	for _, line := range strings.Split(header, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > maxHeaderLines {
+		return "", fmt.Errorf("header must have at most %d lines", maxHeaderLines)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func brandingResponse(shop *models.Shop) fiber.Map {
+	currency := shop.ReceiptCurrency
+	if currency == "" {
+		currency = printer.DefaultCurrency
+	}
+	return fiber.Map{
+		"header":          shop.ReceiptHeader,
+		"footer":          shop.ReceiptFooter,
+		"contact":         shop.ReceiptContact,
+		"vat_number":      shop.VATNumber,
+		"currency_symbol": currency,
+		"show_qr":         shop.ReceiptShowQR,
+		"logo_url":        shop.ReceiptLogo,
+	}
+}
+
+// branding loads the shop's receipt branding, or none when the shop
+// cannot be found
+func (h *Handler) branding(c *fiber.Ctx) printer.Branding {
+	shopID, ok := c.Locals("shop_id").(uint)
+	if h.shopRepo == nil || !ok {
+		return printer.Branding{}
+	}
+	shop, err := h.shopRepo.GetByID(shopID)
+	if err != nil {
+		return printer.Branding{}
+	}
+
+	b := printer.Branding{
+		LogoURL:   shop.ReceiptLogo,
+		Header:    shop.ReceiptHeader,
+		Footer:    shop.ReceiptFooter,
+		Contact:   shop.ReceiptContact,
+		VATNumber: shop.VATNumber,
+		Currency:  shop.ReceiptCurrency,
+		ShowQR:    shop.ReceiptShowQR,
+	}
+	if key, ok := strings.CutPrefix(shop.ReceiptLogo, h.logoURLPrefix); ok && key != "" && h.logoStore != nil {
+		if data, err := h.logoStore.Open(key); err == nil {
+			b.Logo = data
+		} else {
+			log.Printf("⚠️ Failed to load receipt logo for shop %d: %v", shop.ID, err)
+		}
+	}
+	return b
+}
+
+// deleteLogo removes a replaced logo if it was one we stored
+func (h *Handler) deleteLogo(url string) {
+	key, ok := strings.CutPrefix(url, h.logoURLPrefix)
+	if url == "" || !ok || h.logoStore == nil {
+		return
+	}
+	if err := h.logoStore.Delete(key); err != nil {
+		log.Printf("⚠️ Failed to delete old receipt logo %s: %v", key, err)
+	}
+}
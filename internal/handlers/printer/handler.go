@@ -1,15 +1,28 @@
 package printer
 
 import (
+	"errors"
 	"time"
 
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/services/printer"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/qr"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/storage"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/zreport"
 	"github.com/gofiber/fiber/v2"
 )
 
 // Handler handles receipt/print HTTP requests
 type Handler struct {
-	service *printer.Service
+	service       *printer.Service
+	shopRepo      *repository.ShopRepository
+	saleRepo      *repository.SaleRepository
+	settingsRepo  *repository.PrinterSettingRepository
+	linker        *qr.ReceiptLinker
+	zreports      *zreport.Service
+	logoStore     storage.Store
+	logoURLPrefix string
+	localPrinters bool
 }
 
 // New creates a new printer handler
@@ -37,7 +50,8 @@ type ReceiptItem struct {
 	Total     float64 `json:"total"`
 }
 
-// PrintReceipt prints a receipt
+// PrintReceipt prints a receipt on the shop's printer: a recorded sale's
+// when sale_id is given, otherwise one for the items sent
 // POST /api/v1/print/receipt
 func (h *Handler) PrintReceipt(c *fiber.Ctx) error {
 	var req PrintRequest
@@ -47,6 +61,10 @@ func (h *Handler) PrintReceipt(c *fiber.Ctx) error {
 		})
 	}
 
+	if req.SaleID != 0 {
+		return h.printSale(c, req)
+	}
+
 	if req.ShopName == "" || len(req.Items) == 0 {
 		return c.Status(400).JSON(fiber.Map{
 			"error": "shop name and items are required",
@@ -74,10 +92,9 @@ func (h *Handler) PrintReceipt(c *fiber.Ctx) error {
 		PrintedAt:     time.Now(),
 	}
 
-	if err := h.service.Print(receipt); err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+	receipt.Branding = h.branding(c)
+	if err := h.printerFor(c).Print(receipt); err != nil {
+		return printError(c, err)
 	}
 
 	return c.JSON(fiber.Map{
@@ -129,7 +146,8 @@ func (h *Handler) GetTextReceipt(c *fiber.Ctx) error {
 		PrintedAt:     time.Now(),
 	}
 
-	text := h.service.FormatText(receipt)
+	receipt.Branding = h.branding(c)
+	text := h.printerFor(c).FormatText(receipt)
 
 	return c.JSON(fiber.Map{
 		"receipt_id": receipt.ID,
@@ -166,7 +184,8 @@ func (h *Handler) GetThermalReceipt(c *fiber.Ctx) error {
 		PrintedAt:     time.Now(),
 	}
 
-	thermal := h.service.FormatThermal(receipt)
+	receipt.Branding = h.branding(c)
+	thermal := h.printerFor(c).FormatThermal(receipt)
 
 	return c.JSON(fiber.Map{
 		"receipt_id": receipt.ID,
@@ -204,7 +223,8 @@ func (h *Handler) GetHTMLReceipt(c *fiber.Ctx) error {
 		PrintedAt:     time.Now(),
 	}
 
-	html := h.service.FormatHTML(receipt)
+	receipt.Branding = h.branding(c)
+	html := h.printerFor(c).FormatHTML(receipt)
 
 	return c.JSON(fiber.Map{
 		"receipt_id": receipt.ID,
@@ -229,7 +249,7 @@ func (h *Handler) PrintDailyReport(c *fiber.Ctx) error {
 		})
 	}
 
-	report := h.service.DailyReport(req.ShopName, req.TotalSales, req.TransactionCount, req.TopProducts)
+	report := h.printerFor(c).DailyReport(req.ShopName, req.TotalSales, req.TransactionCount, req.TopProducts)
 
 	return c.JSON(fiber.Map{
 		"report": report,
@@ -237,17 +257,31 @@ func (h *Handler) PrintDailyReport(c *fiber.Ctx) error {
 	})
 }
 
-// GetPrinters returns available printers
+// GetPrinters returns the configured printer and, for a network printer,
+// whether it answers
 // GET /api/v1/print/printers
 func (h *Handler) GetPrinters(c *fiber.Ctx) error {
-	printers := []fiber.Map{
-		{
-			"id":      "thermal_1",
-			"name":    "Thermal Printer 1",
-			"type":    "thermal",
-			"status":  "online",
+	service := h.printerFor(c)
+	config := service.Config()
+	printers := []fiber.Map{}
+	if service.Configured() {
+		p := fiber.Map{
+			"id":      config.Type + "_1",
+			"name":    "Receipt Printer",
+			"type":    config.Type,
+			"status":  "configured",
 			"default": true,
-		},
+		}
+		if config.Type == "thermal" {
+			p["connection"] = config.Connection
+			p["address"] = service.Address()
+			p["status"] = "online"
+			if err := service.Ping(); err != nil {
+				p["status"] = "offline"
+				p["error"] = err.Error()
+			}
+		}
+		printers = append(printers, p)
 	}
 
 	return c.JSON(fiber.Map{
@@ -255,31 +289,35 @@ func (h *Handler) GetPrinters(c *fiber.Ctx) error {
 	})
 }
 
-// TestPrinter tests printer connection
+// TestPrinter prints a test page with alignment marks on the shop's
+// printer
 // POST /api/v1/print/test
 func (h *Handler) TestPrinter(c *fiber.Ctx) error {
-	return c.JSON(fiber.Map{
-		"status":  "success",
-		"message": "Test receipt generated",
-	})
-}
+	service := h.printerFor(c)
+	if err := service.TestPrint(); err != nil {
+		return printError(c, err)
+	}
 
-// Configure updates printer configuration
-// PUT /api/v1/print/config
-func (h *Handler) Configure(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{
 		"status":  "success",
-		"message": "Printer configured",
+		"message": "Test page sent to printer at " + service.Address(),
 	})
 }
 
-// GetConfig returns current printer configuration
-// GET /api/v1/print/config
-func (h *Handler) GetConfig(c *fiber.Ctx) error {
-	return c.JSON(fiber.Map{
-		"config": fiber.Map{
-			"type": "thermal",
-			"width": 32,
-		},
+// printError replies with why a printer could not be reached
+func printError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, printer.ErrNoPrinter):
+		status = fiber.StatusServiceUnavailable
+	case errors.Is(err, printer.ErrPrinterNotAllowed):
+		status = fiber.StatusForbidden
+	case errors.Is(err, printer.ErrPrinterTimeout):
+		status = fiber.StatusGatewayTimeout
+	case errors.Is(err, printer.ErrPrinterUnreachable):
+		status = fiber.StatusBadGateway
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error": err.Error(),
 	})
 }
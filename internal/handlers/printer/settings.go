@@ -0,0 +1,265 @@
+package printer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/printer"
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetPrinterSettingRepo lets each shop save its own printer; shops that
+// haven't use the server's
+func (h *Handler) SetPrinterSettingRepo(repo *repository.PrinterSettingRepository) {
+	h.settingsRepo = repo
+}
+
+// SetLocalPrinters lets shops save printers on the server's own network or
+// attached to it over USB or serial. Only for a server run by a single
+// shop; on a shared server any shop could reach the others' devices and
+// internal services.
+func (h *Handler) SetLocalPrinters(allowed bool) {
+	h.localPrinters = allowed
+}
+
+// SetSaleRepo enables printing a recorded sale's receipt by its ID
+func (h *Handler) SetSaleRepo(repo *repository.SaleRepository) {
+	h.saleRepo = repo
+}
+
+// ConfigRequest is a shop's receipt printer
+type ConfigRequest struct {
+	Type       string `json:"type"`       // thermal (default), cloud or pdf
+	Connection string `json:"connection"` // network (default), usb or serial
+	Host       string `json:"host"`
+	Port       int    `json:"port"`
+	Device     string `json:"device"`
+	PaperWidth int    `json:"paper_width"` // 58 (default) or 80
+	OpenDrawer bool   `json:"open_drawer"`
+}
+
+// printerFor returns the service for the shop's own printer, or the
+// server's when the shop hasn't saved one
+func (h *Handler) printerFor(c *fiber.Ctx) *printer.Service {
+	shopID, ok := c.Locals("shop_id").(uint)
+	if h.settingsRepo == nil || !ok {
+		return h.service
+	}
+	setting, err := h.settingsRepo.GetByShop(shopID)
+	if err != nil {
+		return h.service
+	}
+	return h.service.With(printer.PrinterConfig{
+		Type:       setting.Type,
+		Connection: setting.Connection,
+		Host:       setting.Host,
+		Port:       setting.Port,
+		Device:     setting.Device,
+		PaperWidth: setting.PaperWidth,
+		OpenDrawer: setting.OpenDrawer,
+		PublicOnly: !h.localPrinters,
+	})
+}
+
+// Configure saves the shop's receipt printer: a thermal printer on the
+// network (host, port 9100 by default) or on a USB or serial device, its
+// paper width and whether to open the cash drawer on cash sales. Unless
+// local printers are allowed the host must be a public address.
+// PUT /api/v1/print/config
+func (h *Handler) Configure(c *fiber.Ctx) error {
+	if h.settingsRepo == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "printer settings not available",
+		})
+	}
+	var req ConfigRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	setting, err := configSetting(req, h.localPrinters)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	setting.ShopID = c.Locals("shop_id").(uint)
+	if err := h.settingsRepo.Save(setting); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to save printer settings",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Printer configured",
+		"config":  h.printerFor(c).Config(),
+	})
+}
+
+// configSetting checks a printer request and returns it as settings.
+// Without allowLocal only printers on public addresses are accepted.
+func configSetting(req ConfigRequest, allowLocal bool) (*models.PrinterSetting, error) {
+	setting := &models.PrinterSetting{
+		Type:       strings.ToLower(strings.TrimSpace(req.Type)),
+		Connection: strings.ToLower(strings.TrimSpace(req.Connection)),
+		Host:       strings.TrimSpace(req.Host),
+		Port:       req.Port,
+		Device:     strings.TrimSpace(req.Device),
+		PaperWidth: req.PaperWidth,
+		OpenDrawer: req.OpenDrawer,
+	}
+	if setting.Type == "" {
+		setting.Type = "thermal"
+	}
+	if setting.Connection == "" {
+		setting.Connection = printer.ConnectionNetwork
+	}
+	if setting.PaperWidth == 0 {
+		setting.PaperWidth = 58
+	}
+
+	switch setting.Type {
+	case "thermal", "cloud", "pdf":
+	default:
+		return nil, fmt.Errorf("type must be thermal, cloud or pdf")
+	}
+	if _, ok := printer.PaperWidths[setting.PaperWidth]; !ok {
+		return nil, fmt.Errorf("paper_width must be 58 or 80")
+	}
+	if setting.Port < 0 || setting.Port > 65535 {
+		return nil, fmt.Errorf("port must be between 1 and 65535")
+	}
+
+	switch setting.Connection {
+	case printer.ConnectionNetwork:
+		if setting.Type == "thermal" && setting.Host == "" {
+			return nil, fmt.Errorf("host is required for a network printer")
+		}
+		if setting.Host != "" && !allowLocal {
+			if err := printer.CheckPublicHost(setting.Host); err != nil {
+				return nil, fmt.Errorf("host must be a public address reachable from the server: %w", err)
+			}
+		}
+		setting.Device = ""
+	case printer.ConnectionUSB, printer.ConnectionSerial:
+		if !allowLocal {
+			return nil, fmt.Errorf("%s printers are not available on this server", setting.Connection)
+		}
+		if setting.Type != "thermal" {
+			return nil, fmt.Errorf("only thermal printers connect over %s", setting.Connection)
+		}
+		if !printer.ValidDevice(setting.Connection, setting.Device) {
+			return nil, fmt.Errorf("device must be a %s printer device, e.g. %s", setting.Connection, printer.ExampleDevice(setting.Connection))
+		}
+		setting.Host, setting.Port = "", 0
+	default:
+		return nil, fmt.Errorf("connection must be network, usb or serial")
+	}
+	return setting, nil
+}
+
+// GetConfig returns the shop's printer configuration and whether it is the
+// shop's own or the server's
+// GET /api/v1/print/config
+func (h *Handler) GetConfig(c *fiber.Ctx) error {
+	saved := false
+	if shopID, ok := c.Locals("shop_id").(uint); ok && h.settingsRepo != nil {
+		_, err := h.settingsRepo.GetByShop(shopID)
+		saved = err == nil
+	}
+	return c.JSON(fiber.Map{
+		"config": h.printerFor(c).Config(),
+		"saved":  saved,
+	})
+}
+
+// printSale prints a recorded sale's receipt, with every item when it was
+// paid for in a basket
+func (h *Handler) printSale(c *fiber.Ctx, req PrintRequest) error {
+	if h.saleRepo == nil || h.shopRepo == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "printing sales not available",
+		})
+	}
+	shopID := c.Locals("shop_id").(uint)
+	sale, err := h.saleRepo.GetByID(req.SaleID)
+	if err != nil || sale.ShopID != shopID {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "sale not found",
+		})
+	}
+	sales, err := h.saleRepo.GetBasket(sale)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to load sale",
+		})
+	}
+	shop, err := h.shopRepo.GetByID(shopID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "shop not found",
+		})
+	}
+
+	receipt := saleReceipt(shop, sale, sales, req.CashGiven)
+	receipt.Branding = h.branding(c)
+	if h.linker != nil {
+		receipt.VerifyURL = h.linker.URL(sale.ID)
+	}
+	if err := h.printerFor(c).Print(receipt); err != nil {
+		return printError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"status":     "success",
+		"receipt_id": receipt.ID,
+		"message":    "Receipt printed",
+	})
+}
+
+// saleReceipt converts a sale and the rest of its basket to a receipt
+func saleReceipt(shop *models.Shop, sale *models.Sale, sales []models.Sale, cashGiven float64) *printer.Receipt {
+	shopName := shop.Name
+	if shop.BrandName != "" {
+		shopName = shop.BrandName
+	}
+	payment := string(sale.PaymentMethod)
+	if sale.MpesaReceipt != "" {
+		payment += " " + sale.MpesaReceipt
+	}
+
+	receipt := &printer.Receipt{
+		ID:            fmt.Sprintf("%d", sale.ID),
+		ShopName:      shopName,
+		ShopPhone:     shop.Phone,
+		ShopAddress:   shop.Address,
+		PaymentMethod: payment,
+		PrintedAt:     sale.CreatedAt,
+	}
+	for _, s := range sales {
+		receipt.Items = append(receipt.Items, printer.ReceiptItem{
+			Name:      s.Product.Name,
+			Quantity:  s.Quantity,
+			UnitPrice: s.UnitPrice,
+			Total:     s.TotalAmount,
+		})
+		receipt.Total += s.TotalAmount
+		if s.Staff != nil && receipt.Cashier == "" {
+			receipt.Cashier = s.Staff.Name
+		}
+		if s.Customer != nil && receipt.CustomerName == "" {
+			receipt.CustomerName, receipt.CustomerPhone = s.Customer.Name, s.Customer.Phone
+		}
+	}
+	receipt.Subtotal = receipt.Total
+	if sale.PaymentMethod == models.PaymentCash && cashGiven > 0 {
+		receipt.CashGiven = cashGiven
+		receipt.Change = cashGiven - receipt.Total
+	}
+	return receipt
+}
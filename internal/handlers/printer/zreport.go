@@ -0,0 +1,109 @@
+package printer
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/zreport"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// SetZReportService enables printing end-of-day Z-reports
+func (h *Handler) SetZReportService(svc *zreport.Service) {
+	h.zreports = svc
+}
+
+// ZReportRequest picks the Z-report to print
+type ZReportRequest struct {
+	Date   string `json:"date"`   // YYYY-MM-DD, today when empty
+	Number int    `json:"number"` // a past close by its Z number, instead of a date
+	Format string `json:"format"` // thermal (default) prints it; text only returns it
+}
+
+// PrintZReport prints the Z-report for a business day on the shop's
+// printer: the day's close, or its sales so far when it was never closed,
+// so it works for shops that don't close their days. The text of the
+// report is returned either way.
+// POST /api/v1/print/zreport
+func (h *Handler) PrintZReport(c *fiber.Ctx) error {
+	if h.zreports == nil || h.shopRepo == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Z-reports not available",
+		})
+	}
+	var req ZReportRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "invalid request body",
+			})
+		}
+	}
+	if req.Format == "" {
+		req.Format = "thermal"
+	}
+	if req.Format != "thermal" && req.Format != "text" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "format must be thermal or text",
+		})
+	}
+
+	now := time.Now()
+	date := now
+	if req.Date != "" {
+		var err error
+		date, err = time.Parse("2006-01-02", req.Date)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "date must be YYYY-MM-DD",
+			})
+		}
+		if date.After(now) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "date cannot be in the future",
+			})
+		}
+	}
+
+	shop, err := h.shopRepo.GetByID(c.Locals("shop_id").(uint))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "shop not found",
+		})
+	}
+	var report *zreport.Report
+	if req.Number > 0 {
+		report, err = h.zreports.Get(shop, req.Number)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": fmt.Sprintf("Z-report #%d not found", req.Number),
+			})
+		}
+	} else {
+		report, err = h.zreports.ForDate(shop, date, now)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to build Z-report",
+		})
+	}
+
+	service := h.printerFor(c)
+	text := service.FormatZReport(report)
+	if req.Format == "thermal" {
+		if err := service.PrintZReport(report); err != nil {
+			return printError(c, err)
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"printed": req.Format == "thermal",
+		"date":    report.BusinessDate.Format("2006-01-02"),
+		"number":  report.Number,
+		"closed":  report.Closed,
+		"text":    text,
+	})
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// maxAliasLength is the longest alias the product_aliases column holds
+const maxAliasLength = 100
+
+// AddAlias gives a product another name for WhatsApp commands, e.g.
+// "cocacola" for "Coca-Cola 500ml"
+// POST /api/v1/products/:id/aliases
+func (h *ProductHandler) AddAlias(c *fiber.Ctx) error {
+	product, err := h.shopProduct(c)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		Alias string `json:"alias"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "Invalid request body")
+	}
+	alias := repository.NormalizeAlias(req.Alias)
+	if alias == "" || len(alias) > maxAliasLength {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "Alias must be 1 to 100 characters")
+	}
+	// Commands take the product name as one word
+	if strings.ContainsAny(alias, " \t") {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "Alias must be a single word")
+	}
+
+	if _, err := h.productRepo.AddAlias(product, alias); err != nil {
+		if errors.Is(err, repository.ErrAliasTaken) {
+			return utils.SendError(c, fiber.StatusConflict, utils.CodeDuplicateEntry, "Alias already names another product")
+		}
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to add alias")
+	}
+	return h.sendAliases(c, product.ID, fiber.StatusCreated)
+}
+
+// DeleteAlias removes one of a product's aliases
+// DELETE /api/v1/products/:id/aliases/:alias
+func (h *ProductHandler) DeleteAlias(c *fiber.Ctx) error {
+	product, err := h.shopProduct(c)
+	if err != nil {
+		return err
+	}
+
+	alias, err := url.PathUnescape(c.Params("alias"))
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "Invalid alias")
+	}
+	if err := h.productRepo.DeleteAlias(product.ID, alias); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.SendError(c, fiber.StatusNotFound, utils.CodeNotFound, "Alias not found")
+		}
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to delete alias")
+	}
+	return h.sendAliases(c, product.ID, fiber.StatusOK)
+}
+
+// sendAliases replies with the product's aliases after a change
+func (h *ProductHandler) sendAliases(c *fiber.Ctx, productID uint, status int) error {
+	aliases, err := h.productRepo.GetAliases(productID)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get aliases")
+	}
+	names := make([]string, 0, len(aliases))
+	for _, a := range aliases {
+		names = append(names, a.Alias)
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"product_id": productID,
+		"aliases":    names,
+	})
+}
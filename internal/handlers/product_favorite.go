@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// GetFavorites returns the products the shop sells by number over
+// WhatsApp, favorite #1 first. Until the shop pins any they are the
+// week's best sellers, and saved is false.
+// GET /api/v1/products/favorites
+func (h *ProductHandler) GetFavorites(c *fiber.Ctx) error {
+	return h.sendFavorites(c, fiber.StatusOK)
+}
+
+// AddFavorite pins a product for quick selling
+// POST /api/v1/products/favorites
+func (h *ProductHandler) AddFavorite(c *fiber.Ctx) error {
+	shopID := c.Locals("shop_id").(uint)
+
+	var req struct {
+		ProductID uint `json:"product_id"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.ProductID == 0 {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "product_id is required")
+	}
+	product, err := h.productRepo.GetByID(req.ProductID)
+	if err != nil || product.ShopID != shopID {
+		return utils.SendError(c, fiber.StatusNotFound, utils.CodeProductNotFound, "Product not found")
+	}
+
+	if err := h.productRepo.AddFavorite(product); err != nil {
+		if errors.Is(err, repository.ErrTooManyFavorites) {
+			return utils.SendError(c, fiber.StatusConflict, utils.CodeConflict,
+				fmt.Sprintf("A shop can have at most %d favorites", repository.MaxFavorites))
+		}
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to add favorite")
+	}
+	return h.sendFavorites(c, fiber.StatusCreated)
+}
+
+// RemoveFavorite unpins a product
+// DELETE /api/v1/products/favorites/:id
+func (h *ProductHandler) RemoveFavorite(c *fiber.Ctx) error {
+	product, err := h.shopProduct(c)
+	if err != nil {
+		return err
+	}
+	if err := h.productRepo.RemoveFavorite(product.ShopID, product.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.SendError(c, fiber.StatusNotFound, utils.CodeNotFound, "Product is not a favorite")
+		}
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to remove favorite")
+	}
+	return h.sendFavorites(c, fiber.StatusOK)
+}
+
+// sendFavorites replies with the shop's quick sell list
+func (h *ProductHandler) sendFavorites(c *fiber.Ctx, status int) error {
+	shopID := c.Locals("shop_id").(uint)
+	products, saved, err := h.productRepo.GetQuickSell(shopID)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get favorites")
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"saved":     saved,
+		"favorites": products,
+	})
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/images"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/storage"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetImageStore sets where product images are saved and the URL prefix the
+// store is served under, e.g. a LocalStore on the static directory and
+// "/static/"
+func (h *ProductHandler) SetImageStore(store storage.Store, urlPrefix string) {
+	h.imageStore = store
+	h.imageURLPrefix = urlPrefix
+}
+
+// UploadImage saves a product's image from the "image" form file, makes its
+// thumbnail and replaces any previous image
+// POST /api/v1/products/:id/image
+func (h *ProductHandler) UploadImage(c *fiber.Ctx) error {
+	product, err := h.shopProduct(c)
+	if err != nil {
+		return err
+	}
+
+	if h.imageStore == nil {
+		return utils.SendError(c, fiber.StatusServiceUnavailable, utils.CodeServiceUnavailable, "Image uploads not available")
+	}
+
+	header, err := c.FormFile("image")
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "Upload the image as the \"image\" form file")
+	}
+	if header.Size > images.MaxUploadSize {
+		return utils.SendError(c, fiber.StatusRequestEntityTooLarge, utils.CodeValidationError, images.ErrTooLarge.Error())
+	}
+	file, err := header.Open()
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "Invalid image upload")
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, images.MaxUploadSize+1))
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "Invalid image upload")
+	}
+
+	ext, err := images.Validate(data)
+	if errors.Is(err, images.ErrTooLarge) || errors.Is(err, images.ErrTooManyPixels) {
+		return utils.SendError(c, fiber.StatusRequestEntityTooLarge, utils.CodeValidationError, err.Error())
+	}
+	if err != nil {
+		return utils.SendError(c, fiber.StatusUnsupportedMediaType, utils.CodeValidationError, err.Error())
+	}
+	thumb, err := images.Thumbnail(data, images.ThumbnailSize)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "Image could not be read")
+	}
+
+	// A new name on every upload so cached copies of the old image go stale
+	base := fmt.Sprintf("products/%d/%d-%d", product.ShopID, product.ID, time.Now().UnixNano())
+	imageKey := base + "." + ext
+	thumbKey := base + "-thumb.jpg"
+	if _, err := h.imageStore.Save(imageKey, data); err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to save image")
+	}
+	if _, err := h.imageStore.Save(thumbKey, thumb); err != nil {
+		h.imageStore.Delete(imageKey)
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to save image")
+	}
+
+	oldImage, oldThumb := product.ImageURL, product.ThumbnailURL
+	product.ImageURL = h.imageURLPrefix + imageKey
+	product.ThumbnailURL = h.imageURLPrefix + thumbKey
+	if err := h.productRepo.Update(product); err != nil {
+		h.imageStore.Delete(imageKey)
+		h.imageStore.Delete(thumbKey)
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to update product")
+	}
+
+	h.deleteImage(oldImage)
+	h.deleteImage(oldThumb)
+
+	return c.JSON(product)
+}
+
+// deleteImage removes a replaced image if it was one we stored
+func (h *ProductHandler) deleteImage(url string) {
+	key, ok := strings.CutPrefix(url, h.imageURLPrefix)
+	if url == "" || !ok {
+		return
+	}
+	if err := h.imageStore.Delete(key); err != nil {
+		log.Printf("⚠️ Failed to delete old product image %s: %v", key, err)
+	}
+}
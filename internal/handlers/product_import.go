@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/export"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ImportTemplatePath is where the file layout ImportProducts reads is served
+const ImportTemplatePath = "/api/v1/import/products/template"
+
+// maxImportSize is the largest product import file accepted
+const maxImportSize = 5 << 20
+
+// ImportProducts adds and updates products from a "file" form file laid out
+// like the import template, CSV or Excel (.xlsx). Rows with an ID of 0 add
+// a product; rows with the ID of one of the shop's products update it.
+// Rows that can't be imported are listed with their row number.
+// POST /api/v1/import/products
+func (h *ProductHandler) ImportProducts(c *fiber.Ctx) error {
+	shopID := c.Locals("shop_id").(uint)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return importError(c, "Upload the products as the \"file\" form file")
+	}
+	if header.Size > maxImportSize {
+		return utils.SendError(c, fiber.StatusRequestEntityTooLarge, utils.CodeValidationError, "Import files are limited to 5 MB")
+	}
+	file, err := header.Open()
+	if err != nil {
+		return importError(c, "Invalid file upload")
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxImportSize))
+	if err != nil {
+		return importError(c, "Invalid file upload")
+	}
+
+	format := export.FormatCSV
+	if strings.EqualFold(filepath.Ext(header.Filename), ".xlsx") || c.Query("format") == "excel" {
+		format = export.FormatExcel
+	}
+	rows, err := export.ParseProducts(data, format)
+	if err != nil {
+		return importError(c, "Products could not be read: "+err.Error())
+	}
+
+	plan := shopPlan(c)
+	limits := models.LimitsFor(plan)
+	count, err := h.productRepo.CountByShop(shopID)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to count products")
+	}
+
+	created, updated := 0, 0
+	errors := []string{}
+	for _, row := range rows {
+		if row.Err != nil {
+			errors = append(errors, fmt.Sprintf("Row %d: %s", row.Row, row.Err))
+			continue
+		}
+		p := row.Product
+
+		if p.ID == 0 {
+			if !limits.CanAddProducts(count+int64(created), 1) {
+				errors = append(errors, fmt.Sprintf("Row %d: product limit reached for %s plan", row.Row, plan.Name()))
+				continue
+			}
+			product := newBulkProduct(shopID, p)
+			if err := h.productRepo.Create(product); err != nil {
+				errors = append(errors, fmt.Sprintf("Row %d: %s", row.Row, err.Error()))
+				continue
+			}
+			created++
+			continue
+		}
+
+		product, err := h.productRepo.GetByID(p.ID)
+		if err != nil || product.ShopID != shopID {
+			errors = append(errors, fmt.Sprintf("Row %d: product %d not found", row.Row, p.ID))
+			continue
+		}
+		product.Name = p.Name
+		product.Category = p.Category
+		if p.Unit != "" {
+			product.Unit = p.Unit
+		}
+		product.CostPrice = p.CostPrice
+		product.SellingPrice = p.SellingPrice
+		product.CurrentStock = p.CurrentStock
+		if p.LowStockThreshold > 0 {
+			product.LowStockThreshold = p.LowStockThreshold
+		}
+		product.Barcode = p.Barcode
+		if err := h.productRepo.UpdateBy(product, models.PriceSourceAPI, priceChangedBy(c)); err != nil {
+			errors = append(errors, fmt.Sprintf("Row %d: %s", row.Row, err.Error()))
+			continue
+		}
+		updated++
+	}
+
+	return c.JSON(fiber.Map{
+		"created": created,
+		"updated": updated,
+		"total":   len(rows),
+		"errors":  errors,
+	})
+}
+
+// importError replies 400 pointing at the import template
+func importError(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error":    message,
+		"code":     utils.CodeInvalidRequest,
+		"template": ImportTemplatePath,
+	})
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// SearchProducts returns the shop's products whose names contain q, for
+// autocomplete. sort=velocity (the default) puts the products that sell
+// most and most recently first; sort=name orders them alphabetically.
+// GET /api/v1/products/search?q=ch&sort=velocity&limit=20
+func (h *ProductHandler) SearchProducts(c *fiber.Ctx) error {
+	shopID := c.Locals("shop_id").(uint)
+
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "q is required")
+	}
+	sort := c.Query("sort", repository.SearchSortVelocity)
+	if sort != repository.SearchSortVelocity && sort != repository.SearchSortName {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, "sort must be velocity or name")
+	}
+	limit := c.QueryInt("limit", 20)
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	results, err := h.productRepo.Search(shopID, query, sort, limit)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to search products")
+	}
+
+	return c.JSON(fiber.Map{
+		"query": query,
+		"sort":  sort,
+		"data":  results,
+		"count": len(results),
+	})
+}
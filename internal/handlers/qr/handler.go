@@ -25,11 +25,12 @@ func (h *QRHandler) RegisterRoutes(app *fiber.App, protected fiber.Router) {
 }
 
 type GenerateQRRequest struct {
-	Amount      float64 `json:"amount"`
-	Reference   string  `json:"reference"`
-	Description string  `json:"description"`
-	ProductID   *uint   `json:"product_id"`
-	Phone       string  `json:"phone"`
+	Amount        float64 `json:"amount"`
+	Reference     string  `json:"reference"`
+	Description   string  `json:"description"`
+	ProductID     *uint   `json:"product_id"`
+	PendingSaleID *uint   `json:"pending_sale_id"`
+	Phone         string  `json:"phone"`
 }
 
 func (h *QRHandler) GenerateDynamicQR(c *fiber.Ctx) error {
@@ -55,12 +56,13 @@ func (h *QRHandler) GenerateDynamicQR(c *fiber.Ctx) error {
 	}
 
 	dtoReq := &qr.DynamicQRRequest{
-		ShopID:      shopID,
-		Amount:      req.Amount,
-		Reference:   req.Reference,
-		Description: req.Description,
-		ProductID:   req.ProductID,
-		Phone:       req.Phone,
+		ShopID:        shopID,
+		Amount:        req.Amount,
+		Reference:     req.Reference,
+		Description:   req.Description,
+		ProductID:     req.ProductID,
+		PendingSaleID: req.PendingSaleID,
+		Phone:         req.Phone,
 	}
 
 	resp, err := h.qrService.GenerateDynamicQR(c.Context(), dtoReq)
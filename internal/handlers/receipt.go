@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/export"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/printer"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/qr"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// receiptQRPixels is the size of the QR PNG embedded in PDF receipts
+const receiptQRPixels = 256
+
+// ReceiptHandler serves PDF receipts and the public digital receipt page
+// their QR codes link to
+type ReceiptHandler struct {
+	saleRepo *repository.SaleRepository
+	shopRepo *repository.ShopRepository
+	linker   *qr.ReceiptLinker
+	printer  *printer.Service
+}
+
+// NewReceiptHandler creates a new receipt handler
+func NewReceiptHandler(saleRepo *repository.SaleRepository, shopRepo *repository.ShopRepository, linker *qr.ReceiptLinker) *ReceiptHandler {
+	return &ReceiptHandler{
+		saleRepo: saleRepo,
+		shopRepo: shopRepo,
+		linker:   linker,
+		printer:  printer.New(nil),
+	}
+}
+
+// GetReceiptPDF returns a sale's receipt as a PDF with a QR code linking to
+// the digital receipt
+// GET /api/v1/sales/:id/receipt.pdf
+func (h *ReceiptHandler) GetReceiptPDF(c *fiber.Ctx) error {
+	shopID := c.Locals("shop_id").(uint)
+	saleID, err := c.ParamsInt("id")
+	if err != nil || saleID <= 0 {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "Invalid sale ID")
+	}
+
+	sale, err := h.saleRepo.GetByID(uint(saleID))
+	if err != nil || sale.ShopID != shopID {
+		return utils.SendError(c, fiber.StatusNotFound, utils.CodeSaleNotFound, "Sale not found")
+	}
+	shop, err := h.shopRepo.GetByID(shopID)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusNotFound, utils.CodeShopNotFound, "Shop not found")
+	}
+
+	url := h.linker.URL(sale.ID)
+	png, err := qr.GenerateQRImage(url, receiptQRPixels)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to generate receipt QR code")
+	}
+
+	data, err := (&export.ReceiptExporter{}).ExportPDF(export.ReceiptData{
+		Shop:   *shop,
+		Sale:   *sale,
+		URL:    url,
+		QRCode: png,
+	})
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to generate receipt")
+	}
+
+	c.Set("Content-Disposition", fmt.Sprintf("inline; filename=receipt_%d.pdf", sale.ID))
+	c.Set("Content-Type", "application/pdf")
+	return c.Send(data)
+}
+
+// PublicReceipt shows the digital receipt a receipt QR code links to. It
+// needs no login; the link's signature stands in for it.
+// GET /r/:saleID?s=signature
+func (h *ReceiptHandler) PublicReceipt(c *fiber.Ctx) error {
+	saleID, err := c.ParamsInt("saleID")
+	if err != nil || saleID <= 0 || !h.linker.Verify(uint(saleID), c.Query("s")) {
+		return c.Status(fiber.StatusNotFound).SendString("Receipt not found")
+	}
+
+	sale, err := h.saleRepo.GetByID(uint(saleID))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).SendString("Receipt not found")
+	}
+	shop, err := h.shopRepo.GetByID(sale.ShopID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).SendString("Receipt not found")
+	}
+
+	c.Type("html", "utf-8")
+	return c.SendString(h.printer.FormatHTML(publicReceipt(shop, sale)))
+}
+
+// publicReceipt converts a sale to a printable receipt, escaping shop and
+// product text since FormatHTML inserts it as is
+func publicReceipt(shop *models.Shop, sale *models.Sale) *printer.Receipt {
+	shopName := shop.Name
+	if shop.BrandName != "" {
+		shopName = shop.BrandName
+	}
+
+	payment := string(sale.PaymentMethod)
+	if sale.MpesaReceipt != "" {
+		payment += " " + sale.MpesaReceipt
+	}
+
+	return &printer.Receipt{
+		ID:          fmt.Sprintf("%d", sale.ID),
+		ShopName:    html.EscapeString(shopName),
+		ShopPhone:   html.EscapeString(shop.Phone),
+		ShopAddress: html.EscapeString(shop.Address),
+		Items: []printer.ReceiptItem{{
+			Name:      html.EscapeString(sale.Product.Name),
+			Quantity:  sale.Quantity,
+			UnitPrice: sale.UnitPrice,
+			Total:     sale.TotalAmount,
+		}},
+		Subtotal:      sale.TotalAmount,
+		Total:         sale.TotalAmount,
+		PaymentMethod: html.EscapeString(payment),
+		PrintedAt:     sale.CreatedAt,
+		Branding: printer.Branding{
+			LogoURL:   html.EscapeString(shop.ReceiptLogo),
+			Header:    html.EscapeString(shop.ReceiptHeader),
+			Footer:    html.EscapeString(shop.ReceiptFooter),
+			Contact:   html.EscapeString(shop.ReceiptContact),
+			VATNumber: html.EscapeString(shop.VATNumber),
+			Currency:  html.EscapeString(shop.ReceiptCurrency),
+		},
+	}
+}
@@ -0,0 +1,153 @@
+package smshandler
+
+import (
+	"strings"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/sms"
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxCampaignLength keeps a campaign within three SMS pages
+const maxCampaignLength = 459
+
+// SetCampaignRepos enables SMS campaigns to loyalty customers
+func (h *Handler) SetCampaignRepos(campaignRepo *repository.SmsCampaignRepository, customerRepo *repository.CustomerRepository) {
+	h.campaignRepo = campaignRepo
+	h.customerRepo = customerRepo
+}
+
+// CreateCampaign texts a message to the shop's loyalty customers matching a
+// filter. Messages go out in the background; follow progress with
+// GetCampaign.
+// POST /api/v1/sms/campaigns
+func (h *Handler) CreateCampaign(c *fiber.Ctx) error {
+	if h.campaignRepo == nil || h.customerRepo == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "SMS campaigns not configured"})
+	}
+
+	var req struct {
+		Message string `json:"message"`
+		Filter  struct {
+			Tier               string `json:"tier"`
+			MinPoints          int    `json:"min_points"`
+			LastPurchaseBefore string `json:"last_purchase_before"`
+			LastPurchaseAfter  string `json:"last_purchase_after"`
+		} `json:"filter"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	req.Message = strings.TrimSpace(req.Message)
+	if req.Message == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "message required"})
+	}
+	if len(req.Message) > maxCampaignLength {
+		return c.Status(400).JSON(fiber.Map{"error": "message must be at most 459 characters"})
+	}
+	if err := sms.ValidateTemplate(req.Message); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	filter := repository.CustomerFilter{
+		Tier:      models.LoyaltyTier(strings.ToLower(req.Filter.Tier)),
+		MinPoints: req.Filter.MinPoints,
+	}
+	switch filter.Tier {
+	case "", models.TierBronze, models.TierSilver, models.TierGold, models.TierPlatinum:
+	default:
+		return c.Status(400).JSON(fiber.Map{"error": "tier must be bronze, silver, gold or platinum"})
+	}
+	var err error
+	if filter.LastPurchaseBefore, err = parseDate(req.Filter.LastPurchaseBefore); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "last_purchase_before must be a date (YYYY-MM-DD)"})
+	}
+	if filter.LastPurchaseAfter, err = parseDate(req.Filter.LastPurchaseAfter); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "last_purchase_after must be a date (YYYY-MM-DD)"})
+	}
+
+	shopID := c.Locals("shop_id").(uint)
+	customers, err := h.customerRepo.Find(shopID, filter)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to find customers"})
+	}
+	recipients, optedOut := sms.CampaignRecipients(customers)
+	if len(recipients) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "no customers match the filter", "opted_out": optedOut})
+	}
+
+	campaign := &models.SmsCampaign{
+		ShopID:             shopID,
+		Template:           req.Message,
+		Status:             models.SmsCampaignSending,
+		Tier:               filter.Tier,
+		MinPoints:          filter.MinPoints,
+		LastPurchaseBefore: filter.LastPurchaseBefore,
+		LastPurchaseAfter:  filter.LastPurchaseAfter,
+		Recipients:         len(recipients),
+		OptedOut:           optedOut,
+	}
+	if err := h.campaignRepo.Create(campaign); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to create campaign"})
+	}
+
+	go h.smsSvc.RunCampaign(campaign, recipients)
+
+	return c.Status(fiber.StatusAccepted).JSON(campaign)
+}
+
+// ListCampaigns lists the shop's SMS campaigns, newest first
+// GET /api/v1/sms/campaigns
+func (h *Handler) ListCampaigns(c *fiber.Ctx) error {
+	if h.campaignRepo == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "SMS campaigns not configured"})
+	}
+
+	limit := c.QueryInt("limit", 20)
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	campaigns, err := h.campaignRepo.GetByShop(c.Locals("shop_id").(uint), limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to fetch campaigns"})
+	}
+	return c.JSON(fiber.Map{"data": campaigns, "total": len(campaigns)})
+}
+
+// GetCampaign summarises a campaign: delivered and failed counts and cost
+// GET /api/v1/sms/campaigns/:id
+func (h *Handler) GetCampaign(c *fiber.Ctx) error {
+	if h.campaignRepo == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "SMS campaigns not configured"})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id < 1 {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid campaign ID"})
+	}
+	campaign, err := h.campaignRepo.GetByID(uint(id))
+	if err != nil || campaign.ShopID != c.Locals("shop_id").(uint) {
+		return c.Status(404).JSON(fiber.Map{"error": "campaign not found"})
+	}
+
+	summary, err := h.campaignRepo.Summary(campaign)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to summarise campaign"})
+	}
+	return c.JSON(summary)
+}
+
+// parseDate parses an optional YYYY-MM-DD date
+func parseDate(v string) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
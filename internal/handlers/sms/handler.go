@@ -1,18 +1,41 @@
 package smshandler
 
 import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/middleware"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/services/sms"
 	"github.com/gofiber/fiber/v2"
 )
 
 type Handler struct {
-	smsSvc *sms.Service
+	smsSvc      *sms.Service
+	messageRepo *repository.SmsMessageRepository
+	dlrToken    string
+
+	campaignRepo *repository.SmsCampaignRepository
+	customerRepo *repository.CustomerRepository
 }
 
 func New(smsSvc *sms.Service) *Handler {
 	return &Handler{smsSvc: smsSvc}
 }
 
+// SetMessageRepo enables the SMS log endpoints
+func (h *Handler) SetMessageRepo(messageRepo *repository.SmsMessageRepository) {
+	h.messageRepo = messageRepo
+}
+
+// SetDeliveryReportToken requires delivery reports to carry ?token=token
+func (h *Handler) SetDeliveryReportToken(token string) {
+	h.dlrToken = token
+}
+
 func (h *Handler) SendSMS(c *fiber.Ctx) error {
 	type SendRequest struct {
 		Phone   string `json:"phone"`
@@ -28,12 +51,12 @@ func (h *Handler) SendSMS(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "phone and message required"})
 	}
 
-	result, err := h.smsSvc.SendSMS(req.Phone, req.Message)
+	message, err := h.smsSvc.Send(c.Locals("shop_id").(uint), models.SmsPurposeManual, req.Phone, req.Message)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	return c.JSON(fiber.Map{"success": true, "result": result})
+	return c.JSON(fiber.Map{"success": true, "message": message})
 }
 
 func (h *Handler) SendBulkSMS(c *fiber.Ctx) error {
@@ -51,7 +74,7 @@ func (h *Handler) SendBulkSMS(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "recipients and message required"})
 	}
 
-	results, err := h.smsSvc.SendBulkSMS(req.Recipients, req.Message)
+	results, err := h.smsSvc.SendBulkSMS(c.Locals("shop_id").(uint), req.Recipients, req.Message)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -72,8 +95,85 @@ func (h *Handler) GetBalance(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"success": true, "balance": balance})
 }
 
+// GetHistory is the older name for ListMessages
 func (h *Handler) GetHistory(c *fiber.Ctx) error {
-	return c.JSON(fiber.Map{"data": []interface{}{}})
+	return h.ListMessages(c)
+}
+
+// ListMessages lists the shop's sent SMS, newest first. Filters: status,
+// purpose, phone, from and to (inclusive dates, YYYY-MM-DD).
+// GET /api/v1/sms/messages
+func (h *Handler) ListMessages(c *fiber.Ctx) error {
+	if h.messageRepo == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "SMS log not configured"})
+	}
+
+	filter := repository.SmsMessageFilter{
+		Status:  models.SmsStatus(c.Query("status")),
+		Purpose: models.SmsPurpose(c.Query("purpose")),
+	}
+	if phone := c.Query("phone"); phone != "" {
+		filter.Recipient = "+" + strings.TrimPrefix(phone, "+")
+	}
+	if v := c.Query("from"); v != "" {
+		from, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "from must be a date (YYYY-MM-DD)"})
+		}
+		filter.From = from
+	}
+	if v := c.Query("to"); v != "" {
+		to, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "to must be a date (YYYY-MM-DD)"})
+		}
+		filter.To = to.AddDate(0, 0, 1)
+	}
+
+	limit := c.QueryInt("limit", 50)
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+	offset := c.QueryInt("offset", 0)
+	if offset < 0 {
+		offset = 0
+	}
+
+	messages, total, err := h.messageRepo.GetByShop(c.Locals("shop_id").(uint), filter, limit, offset)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to fetch SMS messages"})
+	}
+
+	var cost float64
+	for _, m := range messages {
+		cost += m.Cost
+	}
+	return c.JSON(fiber.Map{
+		"data":   messages,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+		"cost":   cost,
+	})
+}
+
+// DeliveryReport receives Africa's Talking delivery reports. Reports are
+// refused until a token is configured.
+// POST /webhook/sms/delivery
+func (h *Handler) DeliveryReport(c *fiber.Ctx) error {
+	if h.dlrToken == "" || subtle.ConstantTimeCompare([]byte(c.Query("token")), []byte(h.dlrToken)) != 1 {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Invalid callback token"})
+	}
+
+	err := h.smsSvc.HandleDeliveryReport(c.FormValue("id"), c.FormValue("status"), c.FormValue("failureReason"))
+	if errors.Is(err, sms.ErrUnknownMessage) {
+		// Acknowledge so Africa's Talking stops retrying
+		return c.SendStatus(fiber.StatusOK)
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to record delivery report"})
+	}
+	return c.SendStatus(fiber.StatusOK)
 }
 
 func (h *Handler) RegisterRoutes(app *fiber.App, protected fiber.Router) {
@@ -86,4 +186,8 @@ func (h *Handler) RegisterRoutes(app *fiber.App, protected fiber.Router) {
 	smsRoutes.Post("/bulk", h.SendBulkSMS)
 	smsRoutes.Get("/balance", h.GetBalance)
 	smsRoutes.Get("/history", h.GetHistory)
+	smsRoutes.Get("/messages", h.ListMessages)
+	smsRoutes.Post("/campaigns", middleware.RequireBusiness(), h.CreateCampaign)
+	smsRoutes.Get("/campaigns", middleware.RequireBusiness(), h.ListCampaigns)
+	smsRoutes.Get("/campaigns/:id", middleware.RequireBusiness(), h.GetCampaign)
 }
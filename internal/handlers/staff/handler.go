@@ -1,6 +1,7 @@
 package staff
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -94,13 +95,28 @@ func (h *Handler) Create(c *fiber.Ctx) error {
 	}
 
 	// Check if shop exists
-	_, err := h.shopRepo.GetByID(req.ShopID)
+	shop, err := h.shopRepo.GetByID(req.ShopID)
 	if err != nil {
 		return c.Status(http.StatusNotFound).JSON(fiber.Map{
 			"error": "shop not found",
 		})
 	}
 
+	// Enforce the plan's staff limit
+	count, err := h.staffRepo.CountByShop(shop.ID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to count staff",
+		})
+	}
+	if limits := models.LimitsFor(shop.Plan); !limits.CanAddStaff(count, 1) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": fmt.Sprintf("staff limit reached: the %s plan allows %d staff. Upgrade to %s to add more",
+				shop.Plan.Name(), limits.MaxStaff, models.NextPlan(shop.Plan).Name()),
+			"code": "PLAN_LIMIT_REACHED",
+		})
+	}
+
 	// Check if staff with phone exists
 	existing, _ := h.staffRepo.GetByPhone(req.ShopID, req.Phone)
 	if existing != nil {
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetStockMovements returns a product's stock ledger, newest first
+// GET /api/v1/products/:id/movements?limit=50&offset=0
+func (h *ProductHandler) GetStockMovements(c *fiber.Ctx) error {
+	product, err := h.shopProduct(c)
+	if err != nil {
+		return err
+	}
+
+	if h.movementRepo == nil {
+		return utils.SendError(c, fiber.StatusServiceUnavailable, utils.CodeServiceUnavailable, "Stock movements not available")
+	}
+
+	limit := c.QueryInt("limit", 50)
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+	offset := c.QueryInt("offset", 0)
+	if offset < 0 {
+		offset = 0
+	}
+
+	movements, total, err := h.movementRepo.GetByProduct(product.ID, limit, offset)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to get stock movements")
+	}
+
+	return c.JSON(fiber.Map{
+		"product_id":    product.ID,
+		"name":          product.Name,
+		"current_stock": product.CurrentStock,
+		"movements":     movements,
+		"total":         total,
+		"limit":         limit,
+		"offset":        offset,
+	})
+}
@@ -0,0 +1,86 @@
+package stripehandler
+
+import (
+	"errors"
+	"log"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/stripe"
+	"github.com/gofiber/fiber/v2"
+)
+
+type Handler struct {
+	service *stripe.StripeService
+}
+
+func New(service *stripe.StripeService) *Handler {
+	return &Handler{service: service}
+}
+
+type CheckoutRequest struct {
+	ProductID uint   `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+	Currency  string `json:"currency"` // kes, the default, is the only one accepted
+}
+
+// Checkout starts a card payment for an online order. The client secret
+// is passed to Stripe.js on the checkout page to confirm the payment.
+// POST /api/v1/stripe/checkout
+func (h *Handler) Checkout(c *fiber.Ctx) error {
+	var req CheckoutRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
+	}
+	if req.Quantity == 0 {
+		req.Quantity = 1
+	}
+
+	shopID, _ := c.Locals("shop_id").(uint)
+	payment, intent, err := h.service.Checkout(shopID, req.ProductID, req.Quantity, req.Currency)
+	switch {
+	case errors.Is(err, stripe.ErrNotConfigured):
+		return c.Status(503).JSON(fiber.Map{"error": "Stripe is not configured"})
+	case errors.Is(err, stripe.ErrProductUnavailable):
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"payment":           payment,
+		"payment_intent_id": intent.ID,
+		"client_secret":     intent.ClientSecret,
+	})
+}
+
+// Webhook receives Stripe events. Bad signatures are rejected so Stripe
+// shows them as failed; events for settled payments are acknowledged.
+// POST /webhook/stripe
+func (h *Handler) Webhook(c *fiber.Ctx) error {
+	payment, err := h.service.HandleWebhook(c.Body(), c.Get("Stripe-Signature"))
+	switch {
+	case errors.Is(err, stripe.ErrInvalidSignature), errors.Is(err, stripe.ErrNotConfigured):
+		return c.Status(400).JSON(fiber.Map{"error": "invalid signature"})
+	case errors.Is(err, repository.ErrStripePaymentSettled):
+		return c.JSON(fiber.Map{"status": "duplicate", "payment_id": payment.ID})
+	case errors.Is(err, stripe.ErrPaymentNotFound):
+		// Not one of ours, e.g. made in the Stripe dashboard
+		return c.JSON(fiber.Map{"status": "ignored"})
+	case errors.Is(err, stripe.ErrAmountMismatch):
+		// Retrying won't change the amount; leave the payment for review
+		log.Printf("⚠️ Stripe payment %d: %v", payment.ID, err)
+		return c.JSON(fiber.Map{"status": "amount_mismatch", "payment_id": payment.ID})
+	case err != nil:
+		log.Printf("❌ Stripe webhook failed: %v", err)
+		return c.Status(500).JSON(fiber.Map{"error": "failed to process event"})
+	case payment == nil:
+		return c.JSON(fiber.Map{"status": "ignored"})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":         "ok",
+		"payment_id":     payment.ID,
+		"payment_status": payment.Status,
+		"sale_id":        payment.SaleID,
+	})
+}
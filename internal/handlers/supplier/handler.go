@@ -2,9 +2,11 @@ package supplier
 
 import (
 	"strconv"
+	"time"
 
 	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/email"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -13,6 +15,15 @@ type Handler struct {
 	supplierRepo *repository.SupplierRepository
 	orderRepo    *repository.OrderRepository
 	productRepo  *repository.ProductRepository
+
+	// linkRepo is nil until SetProductLinkRepo enables product links
+	linkRepo *repository.SupplierProductRepository
+
+	// sendWhatsApp and emailSvc send purchase orders to suppliers; nil
+	// disables that channel
+	sendWhatsApp func(phone, message string) error
+	emailSvc     *email.Service
+	auditRepo    *repository.AuditLogRepository
 }
 
 // getShopID returns shop_id from JWT token (uint) or URL params (string)
@@ -36,7 +47,8 @@ func New(supplierRepo *repository.SupplierRepository, orderRepo *repository.Orde
 	}
 }
 
-// ListSuppliers GET /suppliers - List all suppliers
+// ListSuppliers GET /suppliers - List all suppliers with their ratings,
+// best rated first with ?sort=rating
 func (h *Handler) ListSuppliers(c *fiber.Ctx) error {
 	shopID, err := getShopID(c)
 	if err != nil {
@@ -48,7 +60,12 @@ func (h *Handler) ListSuppliers(c *fiber.Ctx) error {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	return c.JSON(suppliers)
+	list, err := h.withRatings(shopID, suppliers, c.Query("sort"))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(list)
 }
 
 // CreateSupplier POST /suppliers - Create a new supplier
@@ -71,7 +88,7 @@ func (h *Handler) CreateSupplier(c *fiber.Ctx) error {
 	return c.Status(201).JSON(supplier)
 }
 
-// GetSupplier GET /suppliers/:id - Get a supplier
+// GetSupplier GET /suppliers/:id - Get a supplier and its rating
 func (h *Handler) GetSupplier(c *fiber.Ctx) error {
 	shopID, err := getShopID(c)
 	if err != nil {
@@ -92,7 +109,13 @@ func (h *Handler) GetSupplier(c *fiber.Ctx) error {
 		return c.Status(403).JSON(fiber.Map{"error": "not authorized"})
 	}
 
-	return c.JSON(supplier)
+	return c.JSON(SupplierResponse{
+		Supplier: *supplier,
+		RatingStats: repository.RatingStats{
+			Average: h.supplierRepo.GetAverageRating(supplier.ID),
+			Count:   int64(len(h.supplierRepo.GetRatings(supplier.ID))),
+		},
+	})
 }
 
 // UpdateSupplier PUT /suppliers/:id - Update a supplier
@@ -198,10 +221,11 @@ func (h *Handler) CreateOrder(c *fiber.Ctx) error {
 	}
 
 	type OrderRequest struct {
-		SupplierID uint               `json:"supplier_id"`
-		Status     string             `json:"status"`
-		Notes      string             `json:"notes"`
-		Items      []models.OrderItem `json:"items"`
+		SupplierID       uint               `json:"supplier_id"`
+		Status           string             `json:"status"`
+		Notes            string             `json:"notes"`
+		ExpectedDelivery string             `json:"expected_delivery"` // YYYY-MM-DD
+		Items            []models.OrderItem `json:"items"`
 	}
 
 	var req OrderRequest
@@ -209,6 +233,15 @@ func (h *Handler) CreateOrder(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
 	}
 
+	var expectedDelivery *time.Time
+	if req.ExpectedDelivery != "" {
+		day, err := time.ParseInLocation("2006-01-02", req.ExpectedDelivery, time.Local)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "expected_delivery must be a date (YYYY-MM-DD)"})
+		}
+		expectedDelivery = &day
+	}
+
 	// Validate supplier
 	supplier, err := h.supplierRepo.GetByID(req.SupplierID)
 	if err != nil || supplier.ShopID != shopID {
@@ -217,16 +250,18 @@ func (h *Handler) CreateOrder(c *fiber.Ctx) error {
 
 	// Calculate total
 	var total float64
-	for _, item := range req.Items {
-		total += item.TotalCost
+	for i := range req.Items {
+		req.Items[i].TotalCost = float64(req.Items[i].Quantity) * req.Items[i].UnitCost
+		total += req.Items[i].TotalCost
 	}
 
 	order := &models.Order{
-		ShopID:      shopID,
-		SupplierID:  req.SupplierID,
-		Status:      req.Status,
-		TotalAmount: total,
-		Notes:       req.Notes,
+		ShopID:           shopID,
+		SupplierID:       req.SupplierID,
+		Status:           req.Status,
+		TotalAmount:      total,
+		Notes:            req.Notes,
+		ExpectedDelivery: expectedDelivery,
 	}
 
 	if err := h.orderRepo.Create(order); err != nil {
@@ -236,7 +271,6 @@ func (h *Handler) CreateOrder(c *fiber.Ctx) error {
 	// Create order items
 	for i := range req.Items {
 		req.Items[i].OrderID = order.ID
-		req.Items[i].TotalCost = float64(req.Items[i].Quantity) * req.Items[i].UnitCost
 		if err := h.orderRepo.CreateItem(&req.Items[i]); err != nil {
 			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 		}
@@ -302,7 +336,9 @@ func (h *Handler) UpdateOrderStatus(c *fiber.Ctx) error {
 	}
 
 	validStatuses := map[string]bool{
+		"draft":     true,
 		"pending":   true,
+		"sent":      true,
 		"confirmed": true,
 		"shipped":   true,
 		"delivered": true,
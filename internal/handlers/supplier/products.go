@@ -0,0 +1,129 @@
+package supplier
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// SetProductLinkRepo enables the endpoints that record which products a
+// supplier sells, used to pick a supplier for low stock auto orders
+func (h *Handler) SetProductLinkRepo(linkRepo *repository.SupplierProductRepository) {
+	h.linkRepo = linkRepo
+}
+
+// ownSupplier loads the :id supplier and checks it belongs to the caller's shop
+func (h *Handler) ownSupplier(c *fiber.Ctx) (*models.Supplier, error) {
+	if h.linkRepo == nil {
+		return nil, c.Status(503).JSON(fiber.Map{"error": "supplier products not available"})
+	}
+
+	shopID, err := getShopID(c)
+	if err != nil {
+		return nil, c.Status(400).JSON(fiber.Map{"error": "invalid shop id"})
+	}
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return nil, c.Status(400).JSON(fiber.Map{"error": "invalid supplier id"})
+	}
+
+	supplier, err := h.supplierRepo.GetByID(uint(id))
+	if err != nil {
+		return nil, c.Status(404).JSON(fiber.Map{"error": "supplier not found"})
+	}
+
+	if supplier.ShopID != shopID {
+		return nil, c.Status(403).JSON(fiber.Map{"error": "not authorized"})
+	}
+	return supplier, nil
+}
+
+// ListSupplierProducts GET /suppliers/:id/products - List products a supplier sells
+func (h *Handler) ListSupplierProducts(c *fiber.Ctx) error {
+	supplier, err := h.ownSupplier(c)
+	if supplier == nil {
+		return err
+	}
+
+	links, err := h.linkRepo.GetBySupplier(supplier.ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(links)
+}
+
+// SetSupplierProduct PUT /suppliers/:id/products/:product_id - Link a product and its cost
+func (h *Handler) SetSupplierProduct(c *fiber.Ctx) error {
+	supplier, err := h.ownSupplier(c)
+	if supplier == nil {
+		return err
+	}
+
+	productID, err := strconv.ParseUint(c.Params("product_id"), 10, 32)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid product id"})
+	}
+
+	product, err := h.productRepo.GetByID(uint(productID))
+	if err != nil || product.ShopID != supplier.ShopID {
+		return c.Status(404).JSON(fiber.Map{"error": "product not found"})
+	}
+
+	var req struct {
+		UnitCost    float64 `json:"unit_cost"`
+		MinOrderQty int     `json:"min_order_qty"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
+	}
+	if req.UnitCost < 0 || req.MinOrderQty < 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "unit_cost and min_order_qty cannot be negative"})
+	}
+	if req.UnitCost == 0 {
+		req.UnitCost = product.CostPrice
+	}
+	if req.MinOrderQty == 0 {
+		req.MinOrderQty = 1
+	}
+
+	link := &models.SupplierProduct{
+		ShopID:      supplier.ShopID,
+		SupplierID:  supplier.ID,
+		ProductID:   product.ID,
+		UnitCost:    req.UnitCost,
+		MinOrderQty: req.MinOrderQty,
+	}
+	if err := h.linkRepo.Save(link); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(link)
+}
+
+// DeleteSupplierProduct DELETE /suppliers/:id/products/:product_id - Unlink a product
+func (h *Handler) DeleteSupplierProduct(c *fiber.Ctx) error {
+	supplier, err := h.ownSupplier(c)
+	if supplier == nil {
+		return err
+	}
+
+	productID, err := strconv.ParseUint(c.Params("product_id"), 10, 32)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid product id"})
+	}
+
+	if err := h.linkRepo.Delete(supplier.ID, uint(productID)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "product not linked to supplier"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.SendStatus(204)
+}
@@ -0,0 +1,216 @@
+package supplier
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/email"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/export"
+	supplierservice "github.com/C9b3rD3vi1/DukaPOS/internal/services/supplier"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Channels a purchase order is sent to its supplier on
+const (
+	SendChannelEmail    = "email"
+	SendChannelWhatsApp = "whatsapp"
+)
+
+// SetOrderSenders enables sending purchase orders to suppliers, by
+// WhatsApp and, when emailSvc isn't nil, by email with the PDF attached
+func (h *Handler) SetOrderSenders(sendWhatsApp func(phone, message string) error, emailSvc *email.Service) {
+	h.sendWhatsApp = sendWhatsApp
+	h.emailSvc = emailSvc
+}
+
+// SetAuditRepo records purchase orders sent to suppliers in the audit log
+func (h *Handler) SetAuditRepo(auditRepo *repository.AuditLogRepository) {
+	h.auditRepo = auditRepo
+}
+
+// SendOrderRequest picks how a purchase order goes to the supplier. With
+// no channel it is emailed when the supplier has an email address and
+// email is set up, and otherwise sent by WhatsApp.
+type SendOrderRequest struct {
+	Channel string `json:"channel"` // email or whatsapp
+}
+
+// GetOrderPDF GET /orders/:id/pdf - The order as a purchase order PDF on
+// the shop's letterhead
+func (h *Handler) GetOrderPDF(c *fiber.Ctx) error {
+	order, err := h.ownOrder(c)
+	if order == nil {
+		return err
+	}
+
+	pdf, err := h.purchaseOrderPDF(c, order)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to render purchase order"})
+	}
+
+	c.Set("Content-Type", "application/pdf")
+	c.Set("Content-Disposition", fmt.Sprintf("inline; filename=%s", purchaseOrderFilename(order)))
+	return c.Send(pdf)
+}
+
+// SendOrder POST /orders/:id/send - Email the purchase order PDF to the
+// supplier or WhatsApp them the items, and mark the order sent
+func (h *Handler) SendOrder(c *fiber.Ctx) error {
+	order, err := h.ownOrder(c)
+	if order == nil {
+		return err
+	}
+
+	var req SendOrderRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
+		}
+	}
+	channel := strings.ToLower(strings.TrimSpace(req.Channel))
+	if channel == "" {
+		channel = SendChannelWhatsApp
+		if h.emailSvc != nil && order.Supplier.Email != "" {
+			channel = SendChannelEmail
+		}
+	}
+
+	if order.Status == models.OrderStatusCancelled || order.Status == models.OrderStatusDelivered {
+		return c.Status(409).JSON(fiber.Map{"error": fmt.Sprintf("order is already %s", order.Status)})
+	}
+	if len(order.Items) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "order has no items"})
+	}
+
+	shop := orderShop(c, order)
+	var to string
+	switch channel {
+	case SendChannelEmail:
+		if h.emailSvc == nil {
+			return c.Status(503).JSON(fiber.Map{"error": "email is not configured"})
+		}
+		if order.Supplier.Email == "" {
+			return c.Status(400).JSON(fiber.Map{"error": "supplier has no email address"})
+		}
+		to = order.Supplier.Email
+		pdf, err := h.purchaseOrderPDF(c, order)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "failed to render purchase order"})
+		}
+		attachment := email.Attachment{
+			Filename: purchaseOrderFilename(order),
+			Type:     "application/pdf",
+			Content:  pdf,
+		}
+		if err := h.emailSvc.SendTemplate(shop, to, models.EmailTemplatePurchaseOrder, purchaseOrderVars(shop, order), attachment); err != nil {
+			log.Printf("❌ Failed to email order #%d to supplier %s: %v", order.ID, order.Supplier.Name, err)
+			return c.Status(502).JSON(fiber.Map{"error": "failed to email the supplier"})
+		}
+	case SendChannelWhatsApp:
+		if h.sendWhatsApp == nil {
+			return c.Status(503).JSON(fiber.Map{"error": "WhatsApp is not configured"})
+		}
+		if order.Supplier.Phone == "" {
+			return c.Status(400).JSON(fiber.Map{"error": "supplier has no phone number"})
+		}
+		to = order.Supplier.Phone
+		if err := h.sendWhatsApp(to, supplierservice.OrderMessage(shop, order)); err != nil {
+			log.Printf("❌ Failed to send order #%d to supplier %s: %v", order.ID, order.Supplier.Name, err)
+			return c.Status(502).JSON(fiber.Map{"error": "failed to reach the supplier on WhatsApp"})
+		}
+	default:
+		return c.Status(400).JSON(fiber.Map{"error": "channel must be email or whatsapp"})
+	}
+
+	previous := order.Status
+	now := time.Now()
+	order.Status = models.OrderStatusSent
+	order.SentAt = &now
+	if err := h.orderRepo.Update(order); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	if h.auditRepo != nil {
+		_ = h.auditRepo.Create(&models.AuditLog{
+			ShopID:     order.ShopID,
+			UserType:   "shop",
+			UserID:     order.ShopID,
+			Action:     "order_sent",
+			EntityType: "order",
+			EntityID:   order.ID,
+			Details:    fmt.Sprintf("Sent to %s by %s (%s); status %s -> %s", order.Supplier.Name, channel, to, previous, order.Status),
+			IPAddress:  c.IP(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"order":   order,
+		"channel": channel,
+		"sent_to": to,
+	})
+}
+
+// ownOrder loads the :id order and checks it belongs to the caller's shop
+func (h *Handler) ownOrder(c *fiber.Ctx) (*models.Order, error) {
+	shopID, err := getShopID(c)
+	if err != nil {
+		return nil, c.Status(400).JSON(fiber.Map{"error": "invalid shop id"})
+	}
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return nil, c.Status(400).JSON(fiber.Map{"error": "invalid order id"})
+	}
+
+	order, err := h.orderRepo.GetByID(uint(id))
+	if err != nil {
+		return nil, c.Status(404).JSON(fiber.Map{"error": "order not found"})
+	}
+	if order.ShopID != shopID {
+		return nil, c.Status(403).JSON(fiber.Map{"error": "not authorized"})
+	}
+	return order, nil
+}
+
+func (h *Handler) purchaseOrderPDF(c *fiber.Ctx, order *models.Order) ([]byte, error) {
+	return (&export.OrderExporter{}).ExportPDF(export.PurchaseOrderData{
+		Shop:  *orderShop(c, order),
+		Order: *order,
+	})
+}
+
+// orderShop is the shop placing the order, for its letterhead
+func orderShop(c *fiber.Ctx, order *models.Order) *models.Shop {
+	if shop, ok := c.Locals("shop").(*models.Shop); ok && shop != nil {
+		return shop
+	}
+	return &models.Shop{ID: order.ShopID}
+}
+
+func purchaseOrderFilename(order *models.Order) string {
+	return fmt.Sprintf("purchase_order_%d.pdf", order.ID)
+}
+
+// purchaseOrderVars fills the purchase order email template
+func purchaseOrderVars(shop *models.Shop, order *models.Order) map[string]string {
+	var items strings.Builder
+	for _, item := range order.Items {
+		items.WriteString(fmt.Sprintf("%s x%d @ %.2f = %.2f\n", item.Product.Name, item.Quantity, item.UnitCost, item.TotalCost))
+	}
+	delivery := "to be agreed"
+	if order.ExpectedDelivery != nil {
+		delivery = order.ExpectedDelivery.Format("02 Jan 2006")
+	}
+	return map[string]string{
+		"order_number":      strconv.FormatUint(uint64(order.ID), 10),
+		"supplier_name":     order.Supplier.Name,
+		"items":             strings.TrimRight(items.String(), "\n"),
+		"total":             fmt.Sprintf("%.2f", order.TotalAmount),
+		"expected_delivery": delivery,
+		"shop_phone":        shop.Phone,
+	}
+}
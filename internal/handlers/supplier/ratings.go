@@ -0,0 +1,118 @@
+package supplier
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/gofiber/fiber/v2"
+)
+
+// SupplierResponse is a supplier with its rating
+type SupplierResponse struct {
+	models.Supplier
+	repository.RatingStats
+}
+
+// RateRequest rates the supplier of a delivered order
+type RateRequest struct {
+	Rating int    `json:"rating"`
+	Notes  string `json:"notes"`
+}
+
+// withRatings adds each supplier's rating, sorting best rated first when
+// sort is "rating"
+func (h *Handler) withRatings(shopID uint, suppliers []models.Supplier, sortBy string) ([]SupplierResponse, error) {
+	stats, err := h.supplierRepo.GetRatingStats(shopID)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]SupplierResponse, len(suppliers))
+	for i, s := range suppliers {
+		list[i] = SupplierResponse{Supplier: s, RatingStats: stats[s.ID]}
+	}
+	if sortBy == "rating" {
+		// Unrated suppliers last; ties go to the one rated more often
+		sort.SliceStable(list, func(i, j int) bool {
+			if list[i].Average != list[j].Average {
+				return list[i].Average > list[j].Average
+			}
+			return list[i].Count > list[j].Count
+		})
+	}
+	return list, nil
+}
+
+// RateOrder POST /orders/:id/rating - Rate the supplier of a delivered order
+func (h *Handler) RateOrder(c *fiber.Ctx) error {
+	shopID, err := getShopID(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid shop id"})
+	}
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid order id"})
+	}
+
+	var req RateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
+	}
+	if req.Rating < models.MinSupplierRating || req.Rating > models.MaxSupplierRating {
+		return c.Status(400).JSON(fiber.Map{
+			"error": fmt.Sprintf("rating must be %d to %d", models.MinSupplierRating, models.MaxSupplierRating),
+		})
+	}
+
+	order, err := h.orderRepo.GetByID(uint(id))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "order not found"})
+	}
+	if order.ShopID != shopID {
+		return c.Status(403).JSON(fiber.Map{"error": "not authorized"})
+	}
+	if order.Status != models.OrderStatusDelivered {
+		return c.Status(409).JSON(fiber.Map{"error": "only delivered orders can be rated"})
+	}
+
+	rating := &models.SupplierRating{
+		ShopID:     shopID,
+		SupplierID: order.SupplierID,
+		OrderID:    order.ID,
+		Rating:     req.Rating,
+		Notes:      strings.TrimSpace(req.Notes),
+	}
+	if err := h.supplierRepo.Rate(rating); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(201).JSON(rating)
+}
+
+// ListRatings GET /suppliers/:id/ratings - List a supplier's ratings, newest first
+func (h *Handler) ListRatings(c *fiber.Ctx) error {
+	shopID, err := getShopID(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid shop id"})
+	}
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid supplier id"})
+	}
+
+	supplier, err := h.supplierRepo.GetByID(uint(id))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "supplier not found"})
+	}
+	if supplier.ShopID != shopID {
+		return c.Status(403).JSON(fiber.Map{"error": "not authorized"})
+	}
+
+	return c.JSON(h.supplierRepo.GetRatings(supplier.ID))
+}
@@ -0,0 +1,76 @@
+package ussd
+
+import (
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/ussd"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Request is one step of a USSD session as the menu engine sees it
+type Request struct {
+	SessionID string
+	Phone     string
+	Input     string // the answer to the latest screen only
+}
+
+// Adapter translates between one USSD gateway's HTTP format and the menu
+// engine, which knows nothing of gateways. Supporting a new carrier means
+// writing an adapter; the menus stay as they are.
+type Adapter interface {
+	// Parse reads a gateway request
+	Parse(c *fiber.Ctx) (Request, error)
+	// Reply writes the engine's response as the gateway expects it,
+	// marking whether the session continues or ends
+	Reply(c *fiber.Ctx, resp *ussd.Response) error
+}
+
+// AfricasTalkingAdapter speaks Africa's Talking USSD: form posts whose text
+// holds every answer so far joined by "*", answered with plain text
+// starting with CON to continue or END to close
+type AfricasTalkingAdapter struct{}
+
+func (AfricasTalkingAdapter) Parse(c *fiber.Ctx) (Request, error) {
+	return Request{
+		SessionID: c.FormValue("sessionId"),
+		Phone:     c.FormValue("phoneNumber"),
+		Input:     ussd.LastLevel(c.FormValue("text")),
+	}, nil
+}
+
+func (AfricasTalkingAdapter) Reply(c *fiber.Ctx, resp *ussd.Response) error {
+	prefix := "CON "
+	if resp.End {
+		prefix = "END "
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+	return c.SendString(prefix + resp.Message)
+}
+
+// JSONAdapter speaks DukaPOS's own JSON USSD API, for gateways and
+// testing tools that can call it directly
+type JSONAdapter struct{}
+
+func (JSONAdapter) Parse(c *fiber.Ctx) (Request, error) {
+	var req USSDRequest
+	if err := c.BodyParser(&req); err != nil {
+		return Request{}, err
+	}
+	return Request{
+		SessionID: req.SessionID,
+		Phone:     req.Phone,
+		Input:     ussd.LastLevel(req.Text),
+	}, nil
+}
+
+func (JSONAdapter) Reply(c *fiber.Ctx, resp *ussd.Response) error {
+	action := "continue"
+	if resp.End {
+		action = "end"
+	}
+
+	return c.JSON(USSDResponse{
+		Response:  resp.Message,
+		SessionID: resp.SessionID,
+		Action:    action,
+	})
+}
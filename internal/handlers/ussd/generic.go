@@ -0,0 +1,238 @@
+package ussd
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/ussd"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Gateway formats GenericAdapter reads and writes
+const (
+	FormatForm = "form" // form post or query string in, plain text out
+	FormatJSON = "json"
+	FormatXML  = "xml"
+)
+
+// Fields GenericAdapter maps to the gateway's own names
+const (
+	FieldSession = "session"
+	FieldPhone   = "phone"
+	FieldInput   = "input"
+	FieldMessage = "message" // the reply text, in JSON and XML replies
+	FieldAction  = "action"  // the continue or end marker, in JSON and XML replies
+)
+
+// defaultGatewayFields are used for fields the mapping leaves out. Without
+// an action field the marker goes in front of the message.
+var defaultGatewayFields = map[string]string{
+	FieldSession: "sessionId",
+	FieldPhone:   "msisdn",
+	FieldInput:   "input",
+	FieldMessage: "message",
+}
+
+// GatewayConfig describes a USSD gateway for GenericAdapter, e.g. a
+// Safaricom or Comviva aggregator sending MSISDN, SESSIONID and INPUT and
+// expecting a Freeflow header of FC or FB
+type GatewayConfig struct {
+	Format       string            // form, json or xml
+	Fields       map[string]string // our field name to the gateway's
+	Cumulative   bool              // input holds every answer so far joined by "*"
+	Continue     string            // marker for a session that continues, default CON
+	End          string            // marker for a session that ends, default END
+	ActionHeader string            // send the marker in this header instead
+}
+
+// GenericAdapter speaks any gateway that differs from the others only in
+// field names, format and markers
+type GenericAdapter struct {
+	config GatewayConfig
+}
+
+// NewGenericAdapter creates an adapter for a gateway, filling in defaults
+func NewGenericAdapter(config GatewayConfig) (*GenericAdapter, error) {
+	switch config.Format {
+	case FormatForm, FormatJSON, FormatXML:
+	default:
+		return nil, fmt.Errorf("unsupported USSD gateway format %q", config.Format)
+	}
+
+	fields := make(map[string]string, len(defaultGatewayFields))
+	for field, name := range defaultGatewayFields {
+		fields[field] = name
+	}
+	for field, name := range config.Fields {
+		if _, ok := defaultGatewayFields[field]; !ok && field != FieldAction {
+			return nil, fmt.Errorf("unknown USSD gateway field %q", field)
+		}
+		fields[field] = name
+	}
+	config.Fields = fields
+	if config.Continue == "" {
+		config.Continue = "CON"
+	}
+	if config.End == "" {
+		config.End = "END"
+	}
+	return &GenericAdapter{config: config}, nil
+}
+
+// ParseGatewayFields reads a field mapping written as
+// "phone=MSISDN,session=SESSIONID,input=INPUT"
+func ParseGatewayFields(spec string) (map[string]string, error) {
+	fields := make(map[string]string)
+	for _, pair := range strings.Split(spec, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		field, name, ok := strings.Cut(pair, "=")
+		field, name = strings.TrimSpace(field), strings.TrimSpace(name)
+		if !ok || field == "" || name == "" {
+			return nil, fmt.Errorf("invalid USSD gateway field mapping %q", pair)
+		}
+		fields[field] = name
+	}
+	return fields, nil
+}
+
+func (a *GenericAdapter) Parse(c *fiber.Ctx) (Request, error) {
+	var values map[string]string
+	switch a.config.Format {
+	case FormatJSON:
+		v, err := jsonValues(c.Body())
+		if err != nil {
+			return Request{}, err
+		}
+		values = v
+	case FormatXML:
+		v, err := xmlValues(c.Body())
+		if err != nil {
+			return Request{}, err
+		}
+		values = v
+	default:
+		values = make(map[string]string)
+		for _, field := range []string{FieldSession, FieldPhone, FieldInput} {
+			name := a.config.Fields[field]
+			v := c.FormValue(name)
+			if v == "" {
+				v = c.Query(name)
+			}
+			values[name] = v
+		}
+	}
+
+	req := Request{
+		SessionID: values[a.config.Fields[FieldSession]],
+		Phone:     values[a.config.Fields[FieldPhone]],
+		Input:     values[a.config.Fields[FieldInput]],
+	}
+	if req.Phone == "" {
+		return Request{}, errors.New("phone number missing")
+	}
+	if a.config.Cumulative {
+		req.Input = ussd.LastLevel(req.Input)
+	}
+	return req, nil
+}
+
+func (a *GenericAdapter) Reply(c *fiber.Ctx, resp *ussd.Response) error {
+	marker := a.config.Continue
+	if resp.End {
+		marker = a.config.End
+	}
+	message := resp.Message
+	action := a.config.Fields[FieldAction]
+	switch {
+	case a.config.ActionHeader != "":
+		c.Set(a.config.ActionHeader, marker)
+	case action == "" || a.config.Format == FormatForm:
+		message = marker + " " + message
+	}
+
+	fields := []string{a.config.Fields[FieldMessage], a.config.Fields[FieldSession]}
+	values := []string{message, resp.SessionID}
+	if action != "" && a.config.ActionHeader == "" {
+		fields, values = append(fields, action), append(values, marker)
+	}
+
+	switch a.config.Format {
+	case FormatJSON:
+		body := make(map[string]string, len(fields))
+		for i, field := range fields {
+			body[field] = values[i]
+		}
+		return c.JSON(body)
+	case FormatXML:
+		var buf bytes.Buffer
+		buf.WriteString(xml.Header + "<response>")
+		for i, field := range fields {
+			buf.WriteString("<" + field + ">")
+			xml.EscapeText(&buf, []byte(values[i]))
+			buf.WriteString("</" + field + ">")
+		}
+		buf.WriteString("</response>")
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationXMLCharsetUTF8)
+		return c.Send(buf.Bytes())
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+	return c.SendString(message)
+}
+
+// jsonValues reads the top-level fields of a JSON object as text
+func jsonValues(body []byte) (map[string]string, error) {
+	var raw map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber() // so a phone number sent as a number keeps every digit
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(raw))
+	for k, v := range raw {
+		switch v := v.(type) {
+		case string:
+			values[k] = v
+		case nil:
+		default:
+			values[k] = fmt.Sprint(v)
+		}
+	}
+	return values, nil
+}
+
+// xmlValues reads the text of every element without children, wherever it
+// is in the document, by element name
+func xmlValues(body []byte) (map[string]string, error) {
+	values := make(map[string]string)
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	var current string
+	var text strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return values, nil
+			}
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			current = t.Name.Local
+			text.Reset()
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			if t.Name.Local == current {
+				values[current] = strings.TrimSpace(text.String())
+			}
+			current = ""
+		}
+	}
+}
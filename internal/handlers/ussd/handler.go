@@ -20,11 +20,11 @@ func New(service *ussd.Service) *Handler {
 
 // USSDRequest represents incoming USSD request
 type USSDRequest struct {
-	SessionID    string `json:"sessionId"`
-	Phone        string `json:"phoneNumber"`
-	Text         string `json:"text"`
-	NetworkCode  string `json:"networkCode"`
-	ServiceCode  string `json:"serviceCode"`
+	SessionID   string `json:"sessionId"`
+	Phone       string `json:"phoneNumber"`
+	Text        string `json:"text"`
+	NetworkCode string `json:"networkCode"`
+	ServiceCode string `json:"serviceCode"`
 }
 
 // USSDResponse represents USSD response
@@ -37,57 +37,35 @@ type USSDResponse struct {
 // Handle processes USSD request
 // POST /api/v1/ussd
 func (h *Handler) Handle(c *fiber.Ctx) error {
-	var req USSDRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "invalid request body",
-		})
-	}
-
-	// Generate session ID if not provided
-	if req.SessionID == "" {
-		req.SessionID = generateSessionID(req.Phone)
-	}
-
-	// Process USSD
-	response := h.service.Process(req.Phone, req.SessionID, req.Text)
-
-	// Format response
-	action := "continue"
-	if response.End {
-		action = "end"
-	}
-
-	return c.JSON(USSDResponse{
-		Response:  response.Message,
-		SessionID: response.SessionID,
-		Action:    action,
-	})
+	return h.Serve(JSONAdapter{})(c)
 }
 
-// HandleAfricaTalking handles USSD from Africa's Talking
+// HandleAfricaTalking handles USSD from Africa's Talking, which expects a
+// plain text reply starting with CON to continue or END to close
 // POST /api/v1/ussd/africa
 func (h *Handler) HandleAfricaTalking(c *fiber.Ctx) error {
-	sessionID := c.FormValue("sessionId")
-	phone := c.FormValue("phoneNumber")
-	text := c.FormValue("text")
-
-	if sessionID == "" {
-		sessionID = generateSessionID(phone)
-	}
-
-	response := h.service.Process(phone, sessionID, text)
+	return h.Serve(AfricasTalkingAdapter{})(c)
+}
 
-	action := "continue"
-	if response.End {
-		action = "end"
+// Serve returns a route for a USSD gateway: the adapter reads the
+// gateway's request, the menu engine answers it and the adapter writes the
+// reply back in the gateway's format
+func (h *Handler) Serve(adapter Adapter) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		req, err := adapter.Parse(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "invalid request body",
+			})
+		}
+
+		// Generate session ID if not provided
+		if req.SessionID == "" {
+			req.SessionID = generateSessionID(req.Phone)
+		}
+
+		return adapter.Reply(c, h.service.Respond(req.Phone, req.SessionID, req.Input))
 	}
-
-	return c.JSON(fiber.Map{
-		"response":  response.Message,
-		"sessionId": response.SessionID,
-		"action":    action,
-	})
 }
 
 // Callback handles USSD callback
@@ -7,6 +7,9 @@ import (
 
 	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
+	webhooksvc "github.com/C9b3rD3vi1/DukaPOS/internal/services/webhook"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/websocket"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -74,6 +77,7 @@ type WebHandler struct {
 	summaryRepo  *repository.DailySummaryRepository
 	customerRepo *repository.CustomerRepository
 	staffRepo    *repository.StaffRepository
+	mpesaSvc     *mpesa.Service // serves payment link pages
 }
 
 // NewWebHandler creates a new web handler
@@ -124,7 +128,7 @@ func (h *WebHandler) GetDashboardData(shopID uint) (*DashboardData, error) {
 
 	lowStock := []models.Product{}
 	for _, p := range products {
-		if p.CurrentStock <= p.LowStockThreshold {
+		if p.CurrentStock <= p.LowStockLevel() {
 			lowStock = append(lowStock, p)
 		}
 	}
@@ -375,6 +379,14 @@ func (h *WebHandler) APIProductCreate(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Selling price must be greater than 0"})
 	}
 
+	shop, err := h.shopRepo.GetByID(shopID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Shop not found"})
+	}
+	if err := checkProductLimit(h.productRepo, shopID, shop.Plan, 1); err != nil {
+		return err
+	}
+
 	threshold := req.LowStockThreshold
 	if threshold == 0 {
 		threshold = 10
@@ -460,7 +472,7 @@ func (h *WebHandler) APIProductUpdate(c *fiber.Ctx) error {
 		product.Barcode = *req.Barcode
 	}
 
-	if err := h.productRepo.Update(product); err != nil {
+	if err := h.productRepo.UpdateBy(product, models.PriceSourceDashboard, models.ChangedByShop(product.ShopID)); err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to update product"})
 	}
 
@@ -570,14 +582,20 @@ func (h *WebHandler) APISaleCreate(c *fiber.Ctx) error {
 		Profit:        profit,
 		PaymentMethod: paymentMethod,
 	}
+	shop, _ := h.shopRepo.GetByID(uint(shopID))
+	sale.ApplyRounding(shop.RoundingFor(paymentMethod))
+	totalAmount, profit = sale.TotalAmount, sale.Profit
 
 	if err := h.saleRepo.Create(sale); err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to create sale"})
 	}
 
-	if err := h.productRepo.UpdateStock(req.ProductID, -req.Quantity); err != nil {
+	_, soldOut, err := h.productRepo.MoveStock(req.ProductID, -req.Quantity, models.StockMovementSale, &sale.ID, "")
+	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to update stock"})
 	}
+	webhooksvc.TriggerProductDeactivated(soldOut, "out_of_stock")
+	websocket.NotifySaleCreated(sale, product, product.CurrentStock-req.Quantity)
 
 	return c.Status(201).JSON(fiber.Map{
 		"message":   "Sale created successfully",
@@ -684,7 +702,7 @@ func (h *WebHandler) ProductsList(c *fiber.Ctx) error {
 
 	lowStockCount := 0
 	for _, p := range products {
-		if p.CurrentStock <= p.LowStockThreshold {
+		if p.CurrentStock <= p.LowStockLevel() {
 			lowStockCount++
 		}
 	}
@@ -1,15 +1,19 @@
 package webhook
 
 import (
+	"errors"
 	"strconv"
 
 	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	webhooksvc "github.com/C9b3rD3vi1/DukaPOS/internal/services/webhook"
 	"github.com/gofiber/fiber/v2"
 )
 
 // Handler handles webhook HTTP requests
 type Handler struct {
-	webhookRepo Repository
+	webhookRepo  Repository
+	deliveryRepo DeliveryRepository
+	sender       Sender
 }
 
 // Repository interface for webhooks
@@ -21,11 +25,29 @@ type Repository interface {
 	Delete(id uint) error
 }
 
+// DeliveryRepository interface for the webhook delivery log
+type DeliveryRepository interface {
+	GetByID(id uint) (*models.WebhookDelivery, error)
+	GetByWebhook(webhookID uint, limit int) ([]models.WebhookDelivery, error)
+}
+
+// Sender queues webhook deliveries
+type Sender interface {
+	SendTest(webhook *models.Webhook) (uint, error)
+	Redeliver(eventID uint) error
+}
+
 // New creates a new webhook handler
 func New(repo Repository) *Handler {
 	return &Handler{webhookRepo: repo}
 }
 
+// SetDeliveries enables the delivery log, test sends and manual retries
+func (h *Handler) SetDeliveries(repo DeliveryRepository, sender Sender) {
+	h.deliveryRepo = repo
+	h.sender = sender
+}
+
 // List returns all webhooks for a shop
 // GET /api/v1/webhooks
 func (h *Handler) List(c *fiber.Ctx) error {
@@ -200,7 +222,8 @@ func (h *Handler) Delete(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"message": "webhook deleted successfully"})
 }
 
-// Test sends a test event to a webhook
+// Test sends a test event to a webhook. The outcome shows up in the
+// webhook's delivery log.
 // POST /api/v1/webhooks/:id/test
 func (h *Handler) Test(c *fiber.Ctx) error {
 	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
@@ -209,30 +232,107 @@ func (h *Handler) Test(c *fiber.Ctx) error {
 	}
 
 	webhook, err := h.webhookRepo.GetByID(uint(id))
+	if err != nil || webhook.ShopID != c.Locals("shop_id").(uint) {
+		return c.Status(404).JSON(fiber.Map{"error": "webhook not found"})
+	}
+	if !webhook.IsActive {
+		return c.Status(409).JSON(fiber.Map{"error": "webhook is inactive"})
+	}
+	if h.sender == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "webhook delivery not available"})
+	}
+
+	eventID, err := h.sender.SendTest(webhook)
 	if err != nil {
+		return sendError(c, err)
+	}
+
+	return c.Status(202).JSON(fiber.Map{
+		"message":  "test event queued",
+		"event_id": eventID,
+		"webhook":  webhook.URL,
+	})
+}
+
+// ListDeliveries returns a webhook's recent delivery attempts, newest first
+// GET /api/v1/webhooks/:id/deliveries
+func (h *Handler) ListDeliveries(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid webhook ID"})
+	}
+
+	webhook, err := h.webhookRepo.GetByID(uint(id))
+	if err != nil || webhook.ShopID != c.Locals("shop_id").(uint) {
 		return c.Status(404).JSON(fiber.Map{"error": "webhook not found"})
 	}
+	if h.deliveryRepo == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "webhook delivery log not available"})
+	}
 
-	// Create test event
-	testEvent := map[string]interface{}{
-		"type":      "test",
-		"shop_id":   webhook.ShopID,
-		"timestamp": "2024-01-01T00:00:00Z",
-		"data": fiber.Map{
-			"message": "This is a test webhook from DukaPOS",
-		},
+	limit := c.QueryInt("limit", 50)
+	if limit < 1 || limit > 200 {
+		limit = 50
 	}
 
-	// In production, this would actually send the webhook
-	// For now, just return success
+	deliveries, err := h.deliveryRepo.GetByWebhook(webhook.ID, limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
 
 	return c.JSON(fiber.Map{
-		"message": "Test event would be sent",
-		"webhook": webhook.URL,
-		"event":   testEvent,
+		"data": deliveries,
+		"meta": fiber.Map{"total": len(deliveries), "limit": limit},
+	})
+}
+
+// RetryDelivery sends a logged delivery's event again
+// POST /api/v1/webhooks/:id/deliveries/:deliveryId/retry
+func (h *Handler) RetryDelivery(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid webhook ID"})
+	}
+	deliveryID, err := strconv.ParseUint(c.Params("deliveryId"), 10, 32)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid delivery ID"})
+	}
+
+	webhook, err := h.webhookRepo.GetByID(uint(id))
+	if err != nil || webhook.ShopID != c.Locals("shop_id").(uint) {
+		return c.Status(404).JSON(fiber.Map{"error": "webhook not found"})
+	}
+	if !webhook.IsActive {
+		return c.Status(409).JSON(fiber.Map{"error": "webhook is inactive"})
+	}
+	if h.deliveryRepo == nil || h.sender == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "webhook delivery not available"})
+	}
+
+	delivery, err := h.deliveryRepo.GetByID(uint(deliveryID))
+	if err != nil || delivery.WebhookID != webhook.ID {
+		return c.Status(404).JSON(fiber.Map{"error": "delivery not found"})
+	}
+
+	if err := h.sender.Redeliver(delivery.EventID); err != nil {
+		return sendError(c, err)
+	}
+
+	return c.Status(202).JSON(fiber.Map{
+		"message":  "delivery retry queued",
+		"event_id": delivery.EventID,
+		"event":    delivery.Event,
 	})
 }
 
+// sendError maps delivery service errors to a response
+func sendError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, webhooksvc.ErrDeliveryDisabled) || errors.Is(err, webhooksvc.ErrQueueFull) {
+		return c.Status(503).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+}
+
 // Helper functions
 
 func isValidURL(url string) bool {
@@ -6,18 +6,41 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/C9b3rD3vi1/DukaPOS/internal/config"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
 	"github.com/gofiber/fiber/v2"
 )
 
+// MessageDedupTTL is how long a Twilio MessageSid is remembered. Twilio gives
+// up retrying a webhook well within this window.
+const MessageDedupTTL = 24 * time.Hour
+
+// FailureAlertThreshold is how many messages in a row must fail before
+// admins are told a shop can't be reached (opted out or wrong number)
+const FailureAlertThreshold = 5
+
+// MessageDeduper records message IDs with a TTL. AcquireLock returns false
+// when the key is already held. The Redis cache service satisfies it.
+type MessageDeduper interface {
+	AcquireLock(key, value string, ttl time.Duration) (bool, error)
+}
+
 // WhatsAppHandler handles WhatsApp webhooks from Twilio
 type WhatsAppHandler struct {
 	cmdHandler *services.CommandHandler
 	cfg        *config.Config
 	httpClient *http.Client
+	dedup      MessageDeduper
+
+	// Delivery tracking; nil messageRepo leaves sends untracked
+	messageRepo *repository.OutboundMessageRepository
+	shopRepo    *repository.ShopRepository
+	alert       func(shop *models.Shop, failures int)
 }
 
 // NewWhatsAppHandler creates a new WhatsApp handler
@@ -26,7 +49,64 @@ func NewWhatsAppHandler(cmdHandler *services.CommandHandler, cfg *config.Config)
 		cmdHandler: cmdHandler,
 		cfg:        cfg,
 		httpClient: &http.Client{Timeout: 30 * time.Second},
+		dedup:      newMemoryDeduper(),
+	}
+}
+
+// SetMessageDeduper sets the store used to detect redelivered webhooks,
+// replacing the in-memory default (which only covers a single instance)
+func (h *WhatsAppHandler) SetMessageDeduper(dedup MessageDeduper) {
+	h.dedup = dedup
+}
+
+// SetDeliveryTracking records sent messages so Twilio status callbacks can
+// update them. alert is called when a shop's messages keep failing.
+func (h *WhatsAppHandler) SetDeliveryTracking(messageRepo *repository.OutboundMessageRepository, shopRepo *repository.ShopRepository, alert func(shop *models.Shop, failures int)) {
+	h.messageRepo = messageRepo
+	h.shopRepo = shopRepo
+	h.alert = alert
+}
+
+// isDuplicate reports whether the MessageSid was already processed. Store
+// errors are logged and treated as new so messages are never dropped.
+func (h *WhatsAppHandler) isDuplicate(sid string) bool {
+	if sid == "" || h.dedup == nil {
+		return false
+	}
+	first, err := h.dedup.AcquireLock("whatsapp:msg:"+sid, "1", MessageDedupTTL)
+	if err != nil {
+		fmt.Printf("⚠️ WhatsApp dedup check failed for %s: %v\n", sid, err)
+		return false
 	}
+	return !first
+}
+
+// memoryDeduper is the single-instance fallback used when Redis is unavailable
+type memoryDeduper struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+func newMemoryDeduper() *memoryDeduper {
+	return &memoryDeduper{seen: make(map[string]time.Time)}
+}
+
+func (m *memoryDeduper) AcquireLock(key, value string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for k, expires := range m.seen {
+		if now.After(expires) {
+			delete(m.seen, k)
+		}
+	}
+
+	if _, ok := m.seen[key]; ok {
+		return false, nil
+	}
+	m.seen[key] = now.Add(ttl)
+	return true, nil
 }
 
 // HandleWebhook handles incoming WhatsApp messages
@@ -41,12 +121,27 @@ func (h *WhatsAppHandler) HandleWebhook(c *fiber.Ctx) error {
 	}
 
 	phone := extractPhoneFromWhatsApp(from)
+
+	// Twilio retries on timeouts, so the same message can arrive twice
+	if sid := c.FormValue("MessageSid"); h.isDuplicate(sid) {
+		fmt.Printf("🔁 Duplicate WhatsApp message %s from %s ignored\n", sid, phone)
+		return c.Type("xml").SendString(`<?xml version="1.0" encoding="UTF-8"?>
+<Response></Response>`)
+	}
+
 	fmt.Printf("📱 WhatsApp message from %s: %s\n", phone, body)
 
 	// Create a simple parser
 	parser := services.NewCommandParser(nil, nil)
 	cmd := parser.Parse(body)
 
+	// Outside business hours the bot only says when the shop opens again
+	to := extractPhoneFromWhatsApp(c.FormValue("To"))
+	if reply, closed := h.cmdHandler.ClosedReply(to, phone, cmd, time.Now()); closed {
+		fmt.Printf("🌙 Out-of-hours reply sent to %s\n", phone)
+		return c.Type("xml").SendString(h.generateTwiML(reply))
+	}
+
 	response, err := h.cmdHandler.Handle(phone, cmd)
 	if err != nil {
 		fmt.Printf("❌ Error handling message: %v\n", err)
@@ -76,21 +171,58 @@ func escapeXML(s string) string {
 	return s
 }
 
-// HandleStatusCallback handles message status callbacks
+// HandleStatusCallback records Twilio's delivery status for a sent message
+// and alerts admins when a shop's messages keep failing
 func (h *WhatsAppHandler) HandleStatusCallback(c *fiber.Ctx) error {
 	messageSid := c.FormValue("MessageSid")
 	messageStatus := c.FormValue("MessageStatus")
 
 	fmt.Printf("📊 Message Status Update: %s - %s\n", messageSid, messageStatus)
 
+	if h.messageRepo == nil || messageSid == "" {
+		return c.SendStatus(fiber.StatusOK)
+	}
+
+	message, changed, err := h.messageRepo.UpdateStatus(messageSid, messageStatus, c.FormValue("ErrorCode"), c.FormValue("ErrorMessage"))
+	if err != nil {
+		// Replies sent as TwiML and messages sent before tracking aren't recorded
+		return c.SendStatus(fiber.StatusOK)
+	}
+	if changed && message.Failed() && message.ShopID != 0 {
+		fmt.Printf("❌ WhatsApp message %s to %s failed: %s %s\n", message.SID, message.To, message.ErrorCode, message.ErrorMessage)
+		h.checkFailing(message.ShopID)
+	}
+
 	return c.SendStatus(fiber.StatusOK)
 }
 
+// checkFailing alerts once when a shop's latest messages have all failed
+func (h *WhatsAppHandler) checkFailing(shopID uint) {
+	if h.alert == nil || h.shopRepo == nil {
+		return
+	}
+	failures, err := h.messageRepo.TrailingFailures(shopID, FailureAlertThreshold+1)
+	if err != nil || failures != FailureAlertThreshold {
+		return
+	}
+	shop, err := h.shopRepo.GetByID(shopID)
+	if err != nil {
+		return
+	}
+	h.alert(shop, failures)
+}
+
 // SendWhatsAppMessage sends a WhatsApp message (for notifications)
 func (h *WhatsAppHandler) SendWhatsAppMessage(to, message string) error {
+	return h.SendWhatsAppMessageAs(to, models.MessageTypeNotification, message)
+}
+
+// SendWhatsAppMessageAs sends a notification, recording it as msgType
+// (e.g. daily_report) for delivery tracking
+func (h *WhatsAppHandler) SendWhatsAppMessageAs(to, msgType, message string) error {
 	// First try to send via Twilio API
 	if h.cfg.TwilioAccountSID != "" && h.cfg.TwilioAuthToken != "" && h.cfg.TwilioWhatsAppNumber != "" {
-		return h.SendWhatsAppMessageWithTwilio(to, message)
+		return h.send(to, msgType, message, "")
 	}
 	// Fallback to console log
 	fmt.Printf("📤 [SCHEDULER] Would send WhatsApp to %s: %s\n", to, message)
@@ -99,12 +231,60 @@ func (h *WhatsAppHandler) SendWhatsAppMessage(to, message string) error {
 
 // SendWhatsAppMessageWithTwilio sends actual WhatsApp message via Twilio API
 func (h *WhatsAppHandler) SendWhatsAppMessageWithTwilio(to, message string) error {
+	return h.send(to, models.MessageTypeNotification, message, "")
+}
+
+// SendWhatsAppMedia sends an image or document with a caption. mediaURL
+// must be publicly reachable; Twilio fetches it when sending.
+func (h *WhatsAppHandler) SendWhatsAppMedia(to, caption, mediaURL string) error {
 	if h.cfg.TwilioAccountSID == "" || h.cfg.TwilioAuthToken == "" || h.cfg.TwilioWhatsAppNumber == "" {
-		return fmt.Errorf("Twilio credentials not configured")
+		fmt.Printf("📤 Would send WhatsApp media to %s: %s (%s)\n", to, caption, mediaURL)
+		return nil
 	}
+	return h.send(to, models.MessageTypeMedia, caption, mediaURL)
+}
 
-	twilioURL := fmt.Sprintf("https://api.twilio.com/2010-04-01/Accounts/%s/Messages.json",
-		h.cfg.TwilioAccountSID)
+// send sends through Twilio and records the message for status callbacks
+func (h *WhatsAppHandler) send(to, msgType, message, mediaURL string) error {
+	sid, err := h.sendTwilio(to, message, mediaURL)
+	if err != nil {
+		return err
+	}
+	h.track(to, sid, msgType)
+	return nil
+}
+
+// track records a sent message against the shop it went to
+func (h *WhatsAppHandler) track(to, sid, msgType string) {
+	if h.messageRepo == nil || sid == "" {
+		return
+	}
+	phone := extractPhoneFromWhatsApp(to)
+	message := &models.OutboundMessage{
+		SID:    sid,
+		To:     phone,
+		Type:   msgType,
+		Status: models.MessageStatusQueued,
+	}
+	if h.shopRepo != nil {
+		if shop, err := h.shopRepo.GetByPhone(phone); err == nil {
+			message.ShopID = shop.ID
+		}
+	}
+	if err := h.messageRepo.Create(message); err != nil {
+		fmt.Printf("⚠️ Failed to record WhatsApp message %s: %v\n", sid, err)
+	}
+}
+
+// sendTwilio posts a message to Twilio, attaching mediaURL when set, and
+// returns the message SID
+func (h *WhatsAppHandler) sendTwilio(to, message, mediaURL string) (string, error) {
+	if h.cfg.TwilioAccountSID == "" || h.cfg.TwilioAuthToken == "" || h.cfg.TwilioWhatsAppNumber == "" {
+		return "", fmt.Errorf("Twilio credentials not configured")
+	}
+
+	twilioURL := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json",
+		h.twilioBaseURL(), h.cfg.TwilioAccountSID)
 
 	from := h.cfg.TwilioWhatsAppNumber
 	if !strings.HasPrefix(to, "whatsapp:") {
@@ -115,10 +295,16 @@ func (h *WhatsAppHandler) SendWhatsAppMessageWithTwilio(to, message string) erro
 	data.Set("From", from)
 	data.Set("To", to)
 	data.Set("Body", message)
+	if mediaURL != "" {
+		data.Set("MediaUrl", mediaURL)
+	}
+	if h.messageRepo != nil && h.cfg.WebhookBaseURL != "" {
+		data.Set("StatusCallback", strings.TrimRight(h.cfg.WebhookBaseURL, "/")+"/webhook/twilio/status")
+	}
 
 	req, err := http.NewRequest("POST", twilioURL, strings.NewReader(data.Encode()))
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.SetBasicAuth(h.cfg.TwilioAccountSID, h.cfg.TwilioAuthToken)
@@ -126,7 +312,7 @@ func (h *WhatsAppHandler) SendWhatsAppMessageWithTwilio(to, message string) erro
 
 	resp, err := h.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
+		return "", fmt.Errorf("failed to send message: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -136,11 +322,24 @@ func (h *WhatsAppHandler) SendWhatsAppMessageWithTwilio(to, message string) erro
 			Code    int    `json:"code"`
 		}
 		json.NewDecoder(resp.Body).Decode(&errResp)
-		return fmt.Errorf("Twilio error (%d): %s", resp.StatusCode, errResp.Message)
+		return "", fmt.Errorf("Twilio error (%d): %s", resp.StatusCode, errResp.Message)
+	}
+
+	var sent struct {
+		SID string `json:"sid"`
 	}
+	json.NewDecoder(resp.Body).Decode(&sent)
 
 	fmt.Printf("✅ WhatsApp message sent to %s\n", to)
-	return nil
+	return sent.SID, nil
+}
+
+// twilioBaseURL is the Twilio API host, overridable for tests
+func (h *WhatsAppHandler) twilioBaseURL() string {
+	if h.cfg.TwilioBaseURL != "" {
+		return strings.TrimRight(h.cfg.TwilioBaseURL, "/")
+	}
+	return "https://api.twilio.com"
 }
 
 // extractPhoneFromWhatsApp extracts phone from WhatsApp format
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/export"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/printer"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/zreport"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// zReportTextWidth is the line width of text Z-reports, an 80mm roll
+const zReportTextWidth = 48
+
+// zReportFormats are the formats Z-reports are sent in
+var zReportFormats = map[string]bool{"json": true, "text": true, "txt": true, "pdf": true}
+
+// SetZReportService enables end-of-day Z-reports and closing the day
+func (h *ReportHandler) SetZReportService(svc *zreport.Service) {
+	h.zreports = svc
+}
+
+// CloseDayRequest is the cash drawer at the end of the day
+type CloseDayRequest struct {
+	OpeningFloat float64  `json:"opening_float"`
+	CountedCash  *float64 `json:"counted_cash"`
+}
+
+// GetZReport returns the end-of-day Z-report for the open business day,
+// or for a past close with ?number=. opening_float and counted add the
+// cash drawer; format is json (default), text or pdf.
+// GET /api/v1/reports/zreport
+func (h *ReportHandler) GetZReport(c *fiber.Ctx) error {
+	shop, ok := c.Locals("shop").(*models.Shop)
+	if h.zreports == nil || !ok || shop == nil {
+		return utils.SendError(c, fiber.StatusServiceUnavailable, utils.CodeServiceUnavailable, "Z-reports are not available")
+	}
+
+	var report *zreport.Report
+	if number := c.QueryInt("number"); number > 0 {
+		var err error
+		report, err = h.zreports.Get(shop, number)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.SendError(c, fiber.StatusNotFound, utils.CodeNotFound, fmt.Sprintf("Z-report #%d not found", number))
+		}
+		if err != nil {
+			return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to load Z-report")
+		}
+	} else {
+		req, err := closeDayQuery(c)
+		if err != nil {
+			return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, err.Error())
+		}
+		report, err = h.zreports.Build(shop, time.Now(), req.OpeningFloat, req.CountedCash)
+		if err != nil {
+			return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to build Z-report")
+		}
+	}
+	return sendZReport(c, report)
+}
+
+// CloseDay closes the shop's business day with its Z-report; sales after it
+// count toward the next day
+// POST /api/v1/reports/zreport/close
+func (h *ReportHandler) CloseDay(c *fiber.Ctx) error {
+	shop, ok := c.Locals("shop").(*models.Shop)
+	if h.zreports == nil || !ok || shop == nil {
+		return utils.SendError(c, fiber.StatusServiceUnavailable, utils.CodeServiceUnavailable, "Z-reports are not available")
+	}
+	// Checked first so a bad format doesn't close the day without a report
+	if !zReportFormats[c.Query("format", "json")] {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "format must be json, text or pdf")
+	}
+	var req CloseDayRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "Invalid request body")
+		}
+	}
+	if err := validateCloseDay(req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeValidationError, err.Error())
+	}
+
+	report, err := h.zreports.Build(shop, time.Now(), req.OpeningFloat, req.CountedCash)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to build Z-report")
+	}
+	if err := h.zreports.Close(report, shop.OwnerName); err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to close the day")
+	}
+	return sendZReport(c, report)
+}
+
+// ListZReports returns the shop's past closes, newest first
+// GET /api/v1/reports/zreport/history
+func (h *ReportHandler) ListZReports(c *fiber.Ctx) error {
+	shop, ok := c.Locals("shop").(*models.Shop)
+	if h.zreports == nil || !ok || shop == nil {
+		return utils.SendError(c, fiber.StatusServiceUnavailable, utils.CodeServiceUnavailable, "Z-reports are not available")
+	}
+	limit := c.QueryInt("limit", 30)
+	if limit <= 0 || limit > 366 {
+		limit = 30
+	}
+	reports, err := h.zreports.List(shop, limit)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to load Z-reports")
+	}
+	return c.JSON(fiber.Map{
+		"reports": reports,
+		"count":   len(reports),
+	})
+}
+
+// closeDayQuery reads the cash drawer from the query string
+func closeDayQuery(c *fiber.Ctx) (CloseDayRequest, error) {
+	var req CloseDayRequest
+	if v := c.Query("opening_float"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return req, errors.New("opening_float must be a number")
+		}
+		req.OpeningFloat = f
+	}
+	if v := c.Query("counted"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return req, errors.New("counted must be a number")
+		}
+		req.CountedCash = &f
+	}
+	return req, validateCloseDay(req)
+}
+
+func validateCloseDay(req CloseDayRequest) error {
+	if req.OpeningFloat < 0 {
+		return errors.New("opening_float cannot be negative")
+	}
+	if req.CountedCash != nil && *req.CountedCash < 0 {
+		return errors.New("counted cash cannot be negative")
+	}
+	return nil
+}
+
+// sendZReport replies with the report as JSON, text or a PDF per ?format=
+func sendZReport(c *fiber.Ctx, report *zreport.Report) error {
+	switch c.Query("format", "json") {
+	case "text", "txt":
+		c.Type("txt", "utf-8")
+		return c.SendString(printer.New(&printer.PrinterConfig{Width: zReportTextWidth}).FormatZReport(report))
+	case "pdf":
+		data, err := (&export.ZReportExporter{}).ExportPDF(report)
+		if err != nil {
+			return utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Failed to generate Z-report")
+		}
+		c.Set("Content-Disposition", fmt.Sprintf("inline; filename=zreport_%s.pdf", report.BusinessDate.Format("2006-01-02")))
+		c.Set("Content-Type", "application/pdf")
+		return c.Send(data)
+	case "json":
+		return c.JSON(report)
+	}
+	return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInvalidRequest, "format must be json, text or pdf")
+}
@@ -0,0 +1,390 @@
+package i18n
+
+var english = map[Message]string{
+	MsgDeactivated:     "❌ Your account is deactivated. Please contact support.",
+	MsgFeatureDisabled: "⏸️ This feature is currently switched off. Please try again later.",
+	MsgWelcome: `🎉 Welcome to DukaPOS!
+
+Your shop has been created!
+
+📱 Your Number: %s
+
+Quick Start:
+• add bread 50 30 - Add 30 bread @ KSh 50
+• sell bread 2 - Sold 2 bread
+• stock - View all inventory
+• report - Today's summary
+• help - See all commands
+
+Welcome to digital dukas! 🛒`,
+	MsgUnknownCommand: `❓ Unknown command: %s
+
+📝 Available:
+add, sell, stock, price
+remove, delete, report
+profit, low, help
+
+Type: help for full list`,
+	MsgProductNotFound: "❌ Product '%s' not found",
+	MsgInvalidQuantity: "❌ Invalid quantity",
+	MsgInvalidPrice:    "❌ Invalid price",
+
+	MsgHelp: `%s
+
+📝 COMMANDS:
+
+🆕 STOCK:
+add [name] [price] [qty]
+  Example: add milk 60 20
+unit [name] crate 24 - Buy in crates
+  Then: add soda 50 1 crate
+
+💰 SALES:
+sell [name] [qty]
+  Example: sell milk 2
+fav add [name] - Favorite for quick sell
+  Then: sell 1 3 (3 of favorite #1)
+receipt - Last sale's receipt (PDF)
+catalog [category] - Price list for customers (PDF)
+
+📊 REPORTS:
+stock - View all products
+stock [name] - View specific
+report - Today's summary
+profit - Today's profit
+low - Low stock items
+weekly - This week summary
+monthly - This month summary
+zreport - Close the day (Z-report)
+expense - Record an expense (rent, transport...)
+category - View categories
+
+💵 PRICING:
+price [name] - Check price
+price [name] [new] - Update price
+price history [name] - Price changes
+
+⚙️ SETTINGS:
+threshold [product] - View threshold
+threshold [product] [num] - Set alert
+threshold [product] 20%% [max] - Alert at 20%% of max stock
+barcode [code] - Look up product
+alias add [product] [short] - Short name
+set phone basic - Numbered menus
+set rounding 5 - Round cash to 5 bob
+set negative on - Sell before stock is entered
+hours mon-fri 08:00-18:00 - Business hours
+
+➖ REMOVE STOCK:
+remove [name] [qty]
+
+🗑️ DELETE:
+delete [name]
+  Then: yes delete [name] to confirm
+
+🏪 SHOP:
+shop - View shop info
+plan - View plan details
+
+🔧 HELP:
+help - Show this message%s%s`,
+	MsgHelpPro: `
+💎 PRO COMMANDS:
+mpesa pay [amount] [phone] - Request M-Pesa payment (no phone: payment link)
+staff - Manage staff members
+staff add [name] [phone] [role] - Add staff
+supplier - Manage suppliers
+shop list - View all shops
+
+📈 ADVANCED:
+weekly - This week's summary
+monthly - This month's summary
+category - View/set categories
+threshold [product] [num] - Set low stock alert
+barcode [code] - Look up by barcode`,
+	MsgHelpUpgrade: `
+💎 PRO FEATURES:
+Upgrade to unlock:
+• M-Pesa payments
+• Staff accounts
+• Supplier management
+• Multiple shops
+• Advanced reports
+• Barcode support
+
+Reply: upgrade`,
+	MsgHelpLanguages: `
+
+🌐 LANGUAGES:
+%s
+Change: lang [code] or set language [code]`,
+
+	MsgLanguageUsage: "❌ Usage: lang [code]\nAvailable: %s",
+	MsgLanguageSet:   "✅ Language set to English.",
+
+	MsgPhoneUsage: "❌ Usage: set phone [basic|smart]\nbasic - numbered menus for simple phones\nsmart - full command list",
+	MsgPhoneBasic: "✅ Numbered menus on.\nType help, then reply with a number.",
+	MsgPhoneSmart: "✅ Numbered menus off.\nType help for the full command list.",
+
+	MsgRoundingUsage: "❌ Usage: set rounding [0|1|5]\n0 - exact totals\n1 - nearest shilling\n5 - nearest 5 bob\nM-Pesa always rounds to the nearest shilling",
+	MsgRoundingNone:  "✅ Cash totals are no longer rounded.",
+	MsgRoundingSet:   "✅ Cash totals now round to the nearest KSh %d.",
+
+	MsgNegativeStockUsage: "❌ Usage: set negative [on|off]\non - record sales even when stock shows zero\noff - refuse sales the stock can't cover",
+	MsgNegativeStockOn:    "✅ Sales past zero stock are now recorded.\nStock shows below zero until you add what you have: add [name] [price] [qty]",
+	MsgNegativeStockOff:   "✅ Sales the stock can't cover are refused again.",
+	MsgHelpMenu: `%s
+
+📱 MENU - reply with a number:
+%s
+Add details after the number:
+  Example: 1 bread 2
+
+Full command list: set phone smart`,
+	MsgMenuSell:     "Sell [name] [qty]",
+	MsgMenuAdd:      "Add stock [name] [price] [qty]",
+	MsgMenuStock:    "Stock",
+	MsgMenuPrice:    "Price [name]",
+	MsgMenuReport:   "Today's report",
+	MsgMenuProfit:   "Today's profit",
+	MsgMenuLowStock: "Low stock",
+	MsgMenuWeekly:   "This week",
+	MsgMenuRemove:   "Remove stock [name] [qty]",
+
+	MsgAddUsage:        "❌ Usage: add [name] [price] [qty]\nExample: add bread 50 30",
+	MsgAddNameTooShort: "❌ Product name too short.\nUse: add [name] [price] [qty]",
+	MsgAddNameTooLong:  "❌ Product name too long (max 50 chars).\nUse: add [name] [price] [qty]",
+	MsgAddInvalidPrice: "❌ Invalid price. Use: add [name] [price] [qty]\nExample: add bread 50",
+	MsgAddPriceTooHigh: "❌ Price too high (max KSh 999,999)",
+	MsgAddInvalidQty:   "❌ Invalid quantity. Use: add [name] [price] [qty]\nExample: add bread 50 30",
+	MsgAddQtyTooHigh:   "❌ Quantity too high (max 999,999)",
+	MsgAddCreated:      "✅ Added NEW: %s\n💰 Price: KSh %.0f\n📦 Qty: %d\n\nTip: Set low stock alert with: threshold %s 5",
+	MsgAddUpdated:      "✅ Updated: %s\n📦 Was: %d → Now: %d (+%d)\n💰 Price: KSh %.0f (was: %.0f)",
+	MsgAddLimitReached: "💎 Product limit reached!\n\nYour %s plan allows %d products.\nUpgrade to %s to add more.\n\nReply: upgrade",
+
+	MsgSellUsage:          "❌ Usage: sell [name] [quantity]\nExample: sell bread 2",
+	MsgSellInvalidQty:     "❌ Invalid quantity.\nUse: sell [name] [qty]\nExample: sell bread 2",
+	MsgSellQtyTooHigh:     "❌ Quantity too high (max 99,999)",
+	MsgSellNoProducts:     "❌ No products yet.\n\nAdd first: add [name] [price] [qty]\nExample: add milk 60 20",
+	MsgSellNotFound:       "❌ Product '%s' not found.\n\nAvailable products:\n%s",
+	MsgSellDidYouMean:     "\n\nDid you mean: %s?",
+	MsgSellOutOfStock:     "❌ %s is OUT OF STOCK!\n\nAdd more: add %s %.0f [qty]",
+	MsgSellNotEnoughStock: "❌ Not enough stock!\n📦 Available: %d %s\n\nSell less: sell %s %d",
+	MsgSellUnavailable:    "❌ %s is currently unavailable.\nContact support for assistance.",
+	MsgSold:               "✅ SOLD!\n%s x%d = KSh %.0f\n💵 Profit: KSh %.0f\n📦 Remaining: %s",
+	MsgSoldLoyaltyPoints:  "\n💎 +%d loyalty points!",
+	MsgSoldLowStock:       "\n⚠️ LOW STOCK! Only %d left!",
+	MsgSoldNegativeStock:  "\n⚠️ Sold past the stock on record: %s is now at %d.\nAdd the stock you have: add %s %.0f [qty]",
+	MsgSoldCrossSell:      "\n🛒 Customers also buy: %s.",
+
+	MsgStockProduct:   "📦 %s\n💰 Price: KSh %.0f\n📦 Stock: %s\n%s",
+	MsgStockIn:        "✅ In Stock",
+	MsgStockLow:       "⚠️ Low Stock!",
+	MsgStockEmpty:     "📦 No products yet!\nAdd: add [name] [price] [qty]",
+	MsgStockInventory: "📦 INVENTORY:\n\n",
+	MsgStockTotal:     "\n💰 Total Value: KSh %.0f",
+
+	MsgPriceUsage:         "❌ Usage: price [name] or price [name] [new_price]",
+	MsgPriceUpdated:       "✅ Price Updated!\n%s\n💰 Was: KSh %.0f → Now: KSh %.0f",
+	MsgPriceInfo:          "💰 %s\nPrice: KSh %.0f\nStock: %d %s",
+	MsgPriceHistoryUsage:  "❌ Usage: price history [name]\nExample: price history bread",
+	MsgPriceHistoryEmpty:  "📈 %s\nNo price changes recorded yet.\nCurrent price: KSh %.0f",
+	MsgPriceHistoryHeader: "📈 PRICE HISTORY: %s\nCurrent: KSh %.0f\n\n",
+	MsgPriceHistoryLine:   "• %s: KSh %.0f → KSh %.0f (%s)\n",
+
+	MsgRemoveUsage:          "❌ Usage: remove [name] [quantity]\nExample: remove bread 5",
+	MsgRemoveNotEnoughStock: "❌ Not enough stock!\nAvailable: %d",
+	MsgRemoved:              "✅ Removed %d %s from %s\n📦 Remaining: %d",
+
+	MsgDailyReport:  "📊 DAILY REPORT\n📅 %s\n\n💰 Sales: KSh %.0f\n📝 Transactions: %d\n💵 Profit: KSh %.0f\n\nTop Items:",
+	MsgNoSalesToday: "\nNo sales today yet!",
+	MsgReportItem:   "\n• %s: %d sold",
+	MsgNoSalesWeek:  "📊 No sales data for this week.\n\nStart recording sales to see reports!",
+	MsgWeeklyReport: `📊 WEEKLY REPORT
+📅 Last 7 days (to %s)
+
+💰 Total Sales: KSh %.0f%s
+📝 Transactions: %d%s
+💵 Profit: KSh %.0f%s
+📈 Daily Avg: KSh %.0f
+
+Keep up the good work! 💪`,
+	MsgNoSalesMonth: "📊 No sales data for this month.\n\nStart recording sales to see reports!",
+	MsgMonthlyReport: `📊 MONTHLY REPORT
+📅 %s
+
+💰 Total Sales: KSh %.0f%s
+📝 Transactions: %d%s
+💵 Profit: KSh %.0f%s
+📈 Daily Avg: KSh %.0f
+
+Great progress this month! 🎉`,
+	MsgProfit:        "💵 TODAY'S PROFIT: KSh %.0f\n\n💰 Total Sales: KSh %.0f\n📝 Transactions: %d",
+	MsgProfitNet:     "\n💸 Expenses: KSh %.0f\n📊 NET PROFIT: KSh %.0f",
+	MsgVsLastWeek:    "(%s vs last week)",
+	MsgNoneLastWeek:  "(none last week)",
+	MsgVsLastMonth:   "(%s vs last month)",
+	MsgNoneLastMonth: "(none last month)",
+
+	MsgAllWellStocked: "✅ All products are well stocked!",
+	MsgLowStockAlert:  "⚠️ LOW STOCK ALERT:\n\n",
+	MsgLowStockItem:   "• %s: %d %s (min: %d)\n",
+	MsgLowStockOwed:   "• %s: %d %s (sold past zero, add stock)\n",
+
+	MsgDeleteUsage: "❌ Usage: delete [name]",
+	MsgDeleted:     "🗑️ Deleted: %s",
+
+	MsgDeleteConfirm:  "⚠️ Are you sure you want to delete %s?\nReply `yes %s` to confirm within 30 seconds.",
+	MsgRemoveConfirm:  "⚠️ Remove %d %s of %s? That's a lot.\nReply `yes %s` to confirm within 30 seconds.",
+	MsgConfirmNothing: "❌ Nothing to confirm. Confirmations expire after 30 seconds; send the command again.",
+
+	MsgSearchUsage:   "❌ Usage: search [product name]\nExample: search milk",
+	MsgSearchNone:    "❌ No products found matching '%s'\n\nTry a different search term.",
+	MsgSearchResults: "🔍 Search Results for '%s':\n\n",
+
+	MsgCostInvalid: "❌ Invalid cost price. Use a positive number.",
+	MsgCostUpdated: "✅ Cost Price Updated!\n\n💰 %s\nCost: KSh %.2f\nSelling: KSh %.2f\nMargin: %.1f%%",
+
+	MsgThresholdInvalid:        "❌ Invalid threshold. Use a number between 1-9999",
+	MsgThresholdUpdated:        "✅ Threshold Updated!\n%s\nLow stock alert set at: %d\nYou'll be notified when stock falls below this.",
+	MsgThresholdPercentInvalid: "❌ Invalid percentage. Use 1-100%, and a max stock between 1-99999\nExample: threshold milk 20% 200",
+	MsgThresholdPercentUpdated: "✅ Threshold Updated!\n%s\nLow stock alert at %d%% of max stock %d: %d\nYou'll be notified when stock falls below this.",
+	MsgThresholdNeedsMax:       "❌ %s has no max stock to take %d%% of.\n\nAdd the most you stock: threshold %s %d%% [max stock]",
+
+	MsgBarcodeUsage:    "❌ Usage: barcode add [product] [barcode]\nExample: barcode add milk 5901234123457",
+	MsgBarcodeInvalid:  "❌ Invalid barcode format (4-50 characters)",
+	MsgBarcodeTaken:    "❌ Barcode already assigned to '%s'",
+	MsgBarcodeSet:      "✅ Barcode set!\n%s\nBarcode: %s",
+	MsgBarcodeNotFound: "❌ No product found with barcode: %s\n\nTip: Add barcode with: barcode add [product] [code]",
+
+	MsgUnitUsage:         "❌ Usage: unit [name] [bulk unit] [qty per unit]\nExample: unit soda crate 24\nThen restock with: add soda 50 1 crate\nClear with: unit soda off",
+	MsgUnitInvalidFactor: "❌ Qty per unit must be a whole number above 0.\nExample: unit soda crate 24",
+	MsgUnitSet:           "✅ %s: 1 %s = %d %s\n📦 Stock: %s\n\nRestock with: add %s [price] [qty] %s",
+	MsgUnitCleared:       "✅ %s no longer has a bulk unit.",
+	MsgUnitUnknown:       "❌ %s is not counted in '%s'.\nSet a bulk unit first: unit %s %s [qty per %s]",
+
+	MsgAliasUsage:    "❌ Usage: alias add [product] [short name]\nExample: alias add coca-cola 500ml coke\nThen: sell coke 2\nRemove with: alias remove coke",
+	MsgAliasInvalid:  "❌ A short name can be at most 100 characters.",
+	MsgAliasAdded:    "✅ '%s' now means %s.\nExample: sell %s 1",
+	MsgAliasTaken:    "❌ '%s' already names another product.",
+	MsgAliasRemoved:  "✅ '%s' no longer means %s.",
+	MsgAliasNotFound: "❌ No product goes by '%s'.",
+	MsgAliasList:     "🏷️ %s also goes by:\n%s",
+	MsgAliasNone:     "🏷️ %s has no short names.\nAdd one: alias add %s [short name]",
+
+	MsgFavUsage:      "❌ Usage: fav add [product]\nfav - List favorites\nfav remove [number]\nSell a favorite: sell [number] [qty]\nExample: sell 1 3",
+	MsgFavNone:       "⭐ No favorites yet.\nAdd one: fav add milk\nThen sell it: sell 1 3",
+	MsgFavList:       "⭐ FAVORITES:\n%s\n\nSell: sell [number] [qty]\nExample: sell 1 3",
+	MsgFavTopSellers: "⭐ No favorites yet. This week's best sellers:\n%s\n\nPin one to sell it by number: fav add [product]",
+	MsgFavAdded:      "⭐ %s is favorite #%d.\nSell it: sell %d [qty]",
+	MsgFavFull:       "❌ You already have %d favorites.\nRemove one first: fav remove [number]",
+	MsgFavRemoved:    "✅ %s is no longer a favorite.",
+	MsgFavNotFound:   "❌ %s is not a favorite.\nSee them: fav",
+	MsgFavNoNumber:   "❌ There is no favorite #%d.\nSee them: fav",
+	MsgFavOutOfStock: "%d. %s - OUT OF STOCK",
+
+	MsgHoursUsage: "❌ Usage: hours [days] [open-close]\nExample: hours mon-fri 08:00-18:00\nhours sun closed\nhours off - always open",
+	MsgHoursNone:  "🕗 No business hours set, the bot answers any time.\n\nSet them: hours mon-fri 08:00-18:00",
+	MsgHoursList:  "🕗 BUSINESS HOURS:\n%s\nOutside these hours the bot replies that you are closed.\nAlways open: hours off",
+	MsgHoursOff:   "✅ Business hours cleared, the bot answers any time.",
+	MsgClosed:     "🌙 %s is closed. We open again %s.",
+
+	MsgSupplierPayUsage:          "❌ Usage: supplier pay [name] [amount] [#order]\nExample: supplier pay Brookside 5000\nPay part of an order: supplier pay Brookside 2000 #12",
+	MsgSupplierPayInvalid:        "❌ Invalid amount",
+	MsgSupplierPayUnavailable:    "⚙️ M-Pesa payouts not available.\nContact support.",
+	MsgSupplierPayNeedsB2C:       "⚠️ Supplier payments need M-Pesa B2C.\n\n%v",
+	MsgSupplierNotFound:          "❌ Supplier not found.\nUse: supplier to list all suppliers",
+	MsgSupplierNoPhone:           "❌ %s has no phone number to pay",
+	MsgSupplierPayNoOrder:        "❌ Order #%d is not an unpaid order from %s.",
+	MsgSupplierPayTooMuch:        "❌ Order #%d has only KSh %.0f left to pay.",
+	MsgSupplierPayNoMatch:        "❌ KSh %d doesn't match what you owe %s on any order:\n%s\nTo pay part of an order, name it: supplier pay %s %d #[order]",
+	MsgSupplierPayConfirm:        "⚠️ Send KSh %d to %s (%s) for order #%d? KSh %.0f is left to pay on it.\nReply `yes %s` to confirm within 30 seconds.",
+	MsgSupplierPayConfirmNoOrder: "⚠️ Send KSh %d to %s (%s)? Nothing is owed on their orders, so it won't pay one.\nReply `yes %s` to confirm within 30 seconds.",
+	MsgSupplierPayInFlight:       "⏳ A payment for order #%d is already going through. Wait for M-Pesa to confirm it.",
+	MsgSupplierPayFailed:         "❌ Payment to %s failed: %v",
+	MsgSupplierPaying:            "💸 Paying %s KSh %d\n📱 %s",
+	MsgSupplierPayOrder:          "\n📋 Order #%d (KSh %.0f, KSh %.0f left before this payment)",
+	MsgSupplierPayPending:        "\n\nYou'll see it as paid once M-Pesa confirms.",
+
+	MsgStaffLimitReached: "💎 Staff limit reached!\n\nYour %s plan allows %d staff.\nUpgrade to %s to add more.\n\nReply: upgrade",
+	MsgStaffAddUsage:     "❌ Usage: staff add [name] [phone] [role]\n\nExample: staff add John +254700000001 cashier\n\nRoles: manager, cashier, stock clerk",
+	MsgStaffInvalidRole:  "❌ Invalid role.\nValid: manager, cashier, stock clerk",
+	MsgStaffExists:       "❌ Staff with phone %s already exists!",
+	MsgStaffRemoveUsage:  "❌ Usage: staff remove [phone]",
+	MsgStaffActiveUsage:  "❌ Usage: staff active [phone]",
+	MsgStaffNotFound:     "❌ Staff not found with that phone.",
+	MsgStaffUnknown:      "❌ Unknown staff command.\nUse: staff, staff add, staff remove, staff active",
+
+	MsgBundleShort:        "❌ Not enough %s for %d %s.\n\nNeed: %d\nIn stock: %d",
+	MsgBundleStockChanged: "❌ Stock changed while selling %s. Please try again.",
+	MsgBundleNotBundle:    "❌ %s is not a bundle.",
+	MsgBundleInvalidQty:   "❌ Invalid quantity of %s. Use 1 to 999.",
+	MsgBundleNested:       "❌ %s is a bundle. A bundle can only hold products.",
+	MsgBundleItemTwice:    "❌ %s can only be in %s once.",
+	MsgBundleNameTaken:    "❌ %s is already a product. Give the bundle another name.",
+
+	MsgCategoryNotFound: "❌ Category '%s' not found.\n\nAvailable: %s",
+
+	MsgSupplierAddUsage:  "❌ Usage: supplier add [name] [phone]\nExample: supplier add Brookside +254700000000",
+	MsgSupplierViewUsage: "❌ Usage: supplier view [name]",
+
+	MsgOrderViewUsage:  "❌ Usage: order view [order_id]",
+	MsgOrderDraftUsage: "❌ Usage: order %s [order_id]",
+	MsgOrderInvalidID:  "❌ Invalid order ID",
+	MsgOrderNotFound:   "❌ Order not found",
+	MsgOrderNotDraft:   "❌ Order #%d is already %s",
+	MsgOrderCancelled:  "❌ Order #%d cancelled",
+
+	MsgMpesaPayUsage:      "❌ Usage: mpesa pay [amount] [phone]\nExample: mpesa pay 500 0712345678",
+	MsgMpesaInvalidAmount: "❌ Invalid amount",
+	MsgMpesaInvalidPhone:  "❌ Invalid phone number\nExample: mpesa pay 500 0712345678",
+	MsgMpesaPayFailed:     "❌ Payment failed: %v\n\nPlease try again or contact support.",
+	MsgMpesaStatusUsage:   "❌ Usage: mpesa status [code]\nExample: mpesa status QJK4ABC123",
+	MsgMpesaStatusFailed:  "❌ Failed to check status: %v",
+	MsgMpesaNotCompleted:  "❌ Payment not completed\n\nCheckout ID: %s\nReason: %s",
+	MsgMpesaUnknown:       "❌ Unknown M-Pesa command. Use: mpesa pay [amount] [phone]",
+	MsgMpesaLinkFailed:    "❌ Could not create a payment link: %v",
+	MsgMpesaNotOurs:       "❌ %s is not a payment to your till or paybill",
+	MsgMpesaUnconfirmed:   "❌ M-Pesa could not confirm %s\n\n%s",
+
+	MsgShopSwitchUsage:   "❌ Usage: shop switch [shop number]\nExample: shop switch 2",
+	MsgShopInvalidNumber: "❌ Invalid shop number.\nExample: shop switch 2",
+	MsgShopSwitchFailed:  "❌ Unable to switch shops.\n\nMulti-shop requires Pro plan.\nReply: upgrade",
+	MsgShopAddUsage:      "❌ Usage: shop add [name]\nExample: shop add Mombasa Branch",
+	MsgShopNameUsage:     "❌ Usage: shop name [new name]\nExample: shop name My New Shop",
+
+	MsgPredictUnknown: "❌ Unknown predict command. Use: predict stock, predict trends, or predict restock",
+
+	MsgQRUsage:         "❌ Usage: qr generate [amount]\nExample: qr generate 500",
+	MsgQRInvalidAmount: "❌ Invalid amount. Use a valid number.",
+	MsgQRFailed:        "❌ Failed to generate QR: %v\n\nPlease try again.",
+	MsgQRStaticFailed:  "❌ Failed to generate static QR: %v",
+	MsgQRUnknown:       "❌ Unknown qr command. Use: qr generate [amount] or qr static",
+
+	MsgReceiptUsage:        "❌ Usage: receipt [sale_id]\nExample: receipt 42",
+	MsgReceiptSaleNotFound: "❌ Sale #%d not found.",
+	MsgReceiptSendFailed:   "❌ Failed to send the receipt. Please try again.",
+
+	MsgZReportUsage:      "❌ Usage: zreport [close [cash counted] | number | date]\nExample: zreport close 4500",
+	MsgZReportCloseUsage: "❌ Usage: zreport close [cash counted]\nExample: zreport close 4500",
+	MsgZReportDateUsage:  "❌ Usage: zreport [date]\nExample: zreport 2024-11-30",
+	MsgZReportNotFound:   "❌ Z-report #%d not found.",
+
+	MsgExpenseUsage:             "❌ Usage: expense add [amount] [category] [note]\nExample: expense add 200 transport matatu to town",
+	MsgExpenseStopUsage:         "❌ Usage: expense stop [id]\nSend *expense recurring* to see the ids.",
+	MsgExpenseRecurringNotFound: "❌ Recurring expense #%d not found.",
+
+	MsgCatalogFailed: "❌ Failed to create the catalog. Please try again.",
+
+	MsgLoyaltyPointsUsage:      "❌ Usage: loyalty points [phone]",
+	MsgLoyaltyAddUsage:         "❌ Usage: loyalty add [phone] [name]\n\nExample: loyalty add +254700000001 John Doe",
+	MsgLoyaltyRedeemUsage:      "❌ Usage: loyalty redeem [phone] [points]",
+	MsgLoyaltyCustomerNotFound: "❌ Customer not found.\nUse: loyalty add [phone] [name] to add",
+	MsgLoyaltyCustomerExists:   "❌ Customer with this phone already exists!",
+	MsgLoyaltyInvalidPoints:    "❌ Invalid points (minimum 10)",
+	MsgLoyaltyNotEnoughPoints:  "❌ Not enough points!\nAvailable: %d points",
+	MsgLoyaltyUnknown:          "❌ Unknown loyalty command.\n\nCommands:\nloyalty - List customers\nloyalty points [phone] - Check points\nloyalty add [phone] [name] - Add customer\nloyalty rewards - View rewards\nloyalty tiers - View tiers\nloyalty redeem [phone] [points] - Redeem points",
+
+	MsgAPIKeyUsage: "❌ Usage: api key create [name]",
+	MsgAPIUnknown:  "❌ Unknown api command",
+}
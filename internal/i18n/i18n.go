@@ -0,0 +1,63 @@
+// Package i18n holds the translated text of WhatsApp bot replies.
+package i18n
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Language is a shop's reply language, stored as its ISO 639-1 code
+type Language string
+
+const (
+	English Language = "en"
+	Swahili Language = "sw"
+
+	Default = English
+)
+
+// Languages lists the supported languages in the order they are offered
+var Languages = []Language{English, Swahili}
+
+var catalogs = map[Language]map[Message]string{
+	English: english,
+	Swahili: swahili,
+}
+
+var names = map[Language]string{
+	English: "English",
+	Swahili: "Kiswahili",
+}
+
+// Parse returns the language for a code such as "sw"
+func Parse(code string) (Language, bool) {
+	lang := Language(strings.ToLower(strings.TrimSpace(code)))
+	_, ok := catalogs[lang]
+	return lang, ok
+}
+
+// Of returns the language for a stored code, or Default when it is unknown
+func Of(code string) Language {
+	if lang, ok := Parse(code); ok {
+		return lang
+	}
+	return Default
+}
+
+// Name returns the language's name in that language
+func (l Language) Name() string {
+	return names[l]
+}
+
+// T returns msg in lang, formatted with args. Messages without a
+// translation fall back to English.
+func T(lang Language, msg Message, args ...interface{}) string {
+	text, ok := catalogs[lang][msg]
+	if !ok {
+		text = english[msg]
+	}
+	if len(args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
@@ -0,0 +1,304 @@
+package i18n
+
+// Message identifies a translated reply
+type Message string
+
+const (
+	// General
+	MsgDeactivated     Message = "deactivated"
+	MsgFeatureDisabled Message = "feature_disabled"
+	MsgWelcome         Message = "welcome"
+	MsgUnknownCommand  Message = "unknown_command"
+	MsgProductNotFound Message = "product_not_found"
+	MsgInvalidQuantity Message = "invalid_quantity"
+	MsgInvalidPrice    Message = "invalid_price"
+
+	// Help
+	MsgHelp          Message = "help"
+	MsgHelpPro       Message = "help_pro"
+	MsgHelpUpgrade   Message = "help_upgrade"
+	MsgHelpLanguages Message = "help_languages"
+
+	// Language
+	MsgLanguageUsage Message = "language_usage"
+	MsgLanguageSet   Message = "language_set"
+
+	// Numbered menu for feature phones
+	MsgPhoneUsage   Message = "phone_usage"
+	MsgPhoneBasic   Message = "phone_basic"
+	MsgPhoneSmart   Message = "phone_smart"
+	MsgHelpMenu     Message = "help_menu"
+	MsgMenuSell     Message = "menu_sell"
+	MsgMenuAdd      Message = "menu_add"
+	MsgMenuStock    Message = "menu_stock"
+	MsgMenuPrice    Message = "menu_price"
+	MsgMenuReport   Message = "menu_report"
+	MsgMenuProfit   Message = "menu_profit"
+	MsgMenuLowStock Message = "menu_low_stock"
+	MsgMenuWeekly   Message = "menu_weekly"
+	MsgMenuRemove   Message = "menu_remove"
+
+	// Cash rounding
+	MsgRoundingUsage Message = "rounding_usage"
+	MsgRoundingNone  Message = "rounding_none"
+	MsgRoundingSet   Message = "rounding_set"
+
+	// Negative stock
+	MsgNegativeStockUsage Message = "negative_stock_usage"
+	MsgNegativeStockOn    Message = "negative_stock_on"
+	MsgNegativeStockOff   Message = "negative_stock_off"
+
+	// Add
+	MsgAddUsage        Message = "add_usage"
+	MsgAddNameTooShort Message = "add_name_too_short"
+	MsgAddNameTooLong  Message = "add_name_too_long"
+	MsgAddInvalidPrice Message = "add_invalid_price"
+	MsgAddPriceTooHigh Message = "add_price_too_high"
+	MsgAddInvalidQty   Message = "add_invalid_qty"
+	MsgAddQtyTooHigh   Message = "add_qty_too_high"
+	MsgAddCreated      Message = "add_created"
+	MsgAddUpdated      Message = "add_updated"
+	MsgAddLimitReached Message = "add_limit_reached"
+
+	// Sell
+	MsgSellUsage          Message = "sell_usage"
+	MsgSellInvalidQty     Message = "sell_invalid_qty"
+	MsgSellQtyTooHigh     Message = "sell_qty_too_high"
+	MsgSellNoProducts     Message = "sell_no_products"
+	MsgSellNotFound       Message = "sell_not_found"
+	MsgSellDidYouMean     Message = "sell_did_you_mean"
+	MsgSellOutOfStock     Message = "sell_out_of_stock"
+	MsgSellNotEnoughStock Message = "sell_not_enough_stock"
+	MsgSellUnavailable    Message = "sell_unavailable"
+	MsgSold               Message = "sold"
+	MsgSoldLoyaltyPoints  Message = "sold_loyalty_points"
+	MsgSoldLowStock       Message = "sold_low_stock"
+	MsgSoldNegativeStock  Message = "sold_negative_stock"
+	MsgSoldCrossSell      Message = "sold_cross_sell"
+
+	// Stock
+	MsgStockProduct   Message = "stock_product"
+	MsgStockIn        Message = "stock_in"
+	MsgStockLow       Message = "stock_low"
+	MsgStockEmpty     Message = "stock_empty"
+	MsgStockInventory Message = "stock_inventory"
+	MsgStockTotal     Message = "stock_total"
+
+	// Price
+	MsgPriceUsage         Message = "price_usage"
+	MsgPriceUpdated       Message = "price_updated"
+	MsgPriceInfo          Message = "price_info"
+	MsgPriceHistoryUsage  Message = "price_history_usage"
+	MsgPriceHistoryEmpty  Message = "price_history_empty"
+	MsgPriceHistoryHeader Message = "price_history_header"
+	MsgPriceHistoryLine   Message = "price_history_line"
+
+	// Remove
+	MsgRemoveUsage          Message = "remove_usage"
+	MsgRemoveNotEnoughStock Message = "remove_not_enough_stock"
+	MsgRemoved              Message = "removed"
+
+	// Reports
+	MsgDailyReport   Message = "daily_report"
+	MsgNoSalesToday  Message = "no_sales_today"
+	MsgReportItem    Message = "report_item"
+	MsgNoSalesWeek   Message = "no_sales_week"
+	MsgWeeklyReport  Message = "weekly_report"
+	MsgNoSalesMonth  Message = "no_sales_month"
+	MsgMonthlyReport Message = "monthly_report"
+	MsgProfit        Message = "profit"
+	MsgProfitNet     Message = "profit_net"
+	MsgVsLastWeek    Message = "vs_last_week"
+	MsgNoneLastWeek  Message = "none_last_week"
+	MsgVsLastMonth   Message = "vs_last_month"
+	MsgNoneLastMonth Message = "none_last_month"
+
+	// Low stock
+	MsgAllWellStocked Message = "all_well_stocked"
+	MsgLowStockAlert  Message = "low_stock_alert"
+	MsgLowStockItem   Message = "low_stock_item"
+	MsgLowStockOwed   Message = "low_stock_owed"
+
+	// Delete
+	MsgDeleteUsage Message = "delete_usage"
+	MsgDeleted     Message = "deleted"
+
+	// Confirmation of destructive commands
+	MsgDeleteConfirm  Message = "delete_confirm"
+	MsgRemoveConfirm  Message = "remove_confirm"
+	MsgConfirmNothing Message = "confirm_nothing"
+
+	// Search
+	MsgSearchUsage   Message = "search_usage"
+	MsgSearchNone    Message = "search_none"
+	MsgSearchResults Message = "search_results"
+
+	// Cost price
+	MsgCostInvalid Message = "cost_invalid"
+	MsgCostUpdated Message = "cost_updated"
+
+	// Low stock threshold
+	MsgThresholdInvalid        Message = "threshold_invalid"
+	MsgThresholdUpdated        Message = "threshold_updated"
+	MsgThresholdPercentInvalid Message = "threshold_percent_invalid"
+	MsgThresholdPercentUpdated Message = "threshold_percent_updated"
+	MsgThresholdNeedsMax       Message = "threshold_needs_max"
+
+	// Barcode
+	MsgBarcodeUsage    Message = "barcode_usage"
+	MsgBarcodeInvalid  Message = "barcode_invalid"
+	MsgBarcodeTaken    Message = "barcode_taken"
+	MsgBarcodeSet      Message = "barcode_set"
+	MsgBarcodeNotFound Message = "barcode_not_found"
+
+	// Bulk units
+	MsgUnitUsage         Message = "unit_usage"
+	MsgUnitInvalidFactor Message = "unit_invalid_factor"
+	MsgUnitSet           Message = "unit_set"
+	MsgUnitCleared       Message = "unit_cleared"
+	MsgUnitUnknown       Message = "unit_unknown"
+
+	// Product aliases
+	MsgAliasUsage    Message = "alias_usage"
+	MsgAliasInvalid  Message = "alias_invalid"
+	MsgAliasAdded    Message = "alias_added"
+	MsgAliasTaken    Message = "alias_taken"
+	MsgAliasRemoved  Message = "alias_removed"
+	MsgAliasNotFound Message = "alias_not_found"
+	MsgAliasList     Message = "alias_list"
+	MsgAliasNone     Message = "alias_none"
+
+	// Quick sell favorites
+	MsgFavUsage      Message = "fav_usage"
+	MsgFavNone       Message = "fav_none"
+	MsgFavList       Message = "fav_list"
+	MsgFavTopSellers Message = "fav_top_sellers"
+	MsgFavAdded      Message = "fav_added"
+	MsgFavFull       Message = "fav_full"
+	MsgFavRemoved    Message = "fav_removed"
+	MsgFavNotFound   Message = "fav_not_found"
+	MsgFavNoNumber   Message = "fav_no_number"
+	MsgFavOutOfStock Message = "fav_out_of_stock"
+
+	// Business hours
+	MsgHoursUsage Message = "hours_usage"
+	MsgHoursNone  Message = "hours_none"
+	MsgHoursList  Message = "hours_list"
+	MsgHoursOff   Message = "hours_off"
+	MsgClosed     Message = "closed"
+
+	// Supplier payments
+	MsgSupplierPayUsage          Message = "supplier_pay_usage"
+	MsgSupplierPayInvalid        Message = "supplier_pay_invalid"
+	MsgSupplierPayUnavailable    Message = "supplier_pay_unavailable"
+	MsgSupplierPayNeedsB2C       Message = "supplier_pay_needs_b2c"
+	MsgSupplierNotFound          Message = "supplier_not_found"
+	MsgSupplierNoPhone           Message = "supplier_no_phone"
+	MsgSupplierPayNoOrder        Message = "supplier_pay_no_order"
+	MsgSupplierPayTooMuch        Message = "supplier_pay_too_much"
+	MsgSupplierPayNoMatch        Message = "supplier_pay_no_match"
+	MsgSupplierPayConfirm        Message = "supplier_pay_confirm"
+	MsgSupplierPayConfirmNoOrder Message = "supplier_pay_confirm_no_order"
+	MsgSupplierPayInFlight       Message = "supplier_pay_in_flight"
+	MsgSupplierPayFailed         Message = "supplier_pay_failed"
+	MsgSupplierPaying            Message = "supplier_paying"
+	MsgSupplierPayOrder          Message = "supplier_pay_order"
+	MsgSupplierPayPending        Message = "supplier_pay_pending"
+
+	// Staff
+	MsgStaffLimitReached Message = "staff_limit_reached"
+	MsgStaffAddUsage     Message = "staff_add_usage"
+	MsgStaffInvalidRole  Message = "staff_invalid_role"
+	MsgStaffExists       Message = "staff_exists"
+	MsgStaffRemoveUsage  Message = "staff_remove_usage"
+	MsgStaffActiveUsage  Message = "staff_active_usage"
+	MsgStaffNotFound     Message = "staff_not_found"
+	MsgStaffUnknown      Message = "staff_unknown"
+
+	// Bundles
+	MsgBundleShort        Message = "bundle_short"
+	MsgBundleStockChanged Message = "bundle_stock_changed"
+	MsgBundleNotBundle    Message = "bundle_not_bundle"
+	MsgBundleInvalidQty   Message = "bundle_invalid_qty"
+	MsgBundleNested       Message = "bundle_nested"
+	MsgBundleItemTwice    Message = "bundle_item_twice"
+	MsgBundleNameTaken    Message = "bundle_name_taken"
+
+	// Categories
+	MsgCategoryNotFound Message = "category_not_found"
+
+	// Suppliers
+	MsgSupplierAddUsage  Message = "supplier_add_usage"
+	MsgSupplierViewUsage Message = "supplier_view_usage"
+
+	// Orders
+	MsgOrderViewUsage  Message = "order_view_usage"
+	MsgOrderDraftUsage Message = "order_draft_usage"
+	MsgOrderInvalidID  Message = "order_invalid_id"
+	MsgOrderNotFound   Message = "order_not_found"
+	MsgOrderNotDraft   Message = "order_not_draft"
+	MsgOrderCancelled  Message = "order_cancelled"
+
+	// M-Pesa
+	MsgMpesaPayUsage      Message = "mpesa_pay_usage"
+	MsgMpesaInvalidAmount Message = "mpesa_invalid_amount"
+	MsgMpesaInvalidPhone  Message = "mpesa_invalid_phone"
+	MsgMpesaPayFailed     Message = "mpesa_pay_failed"
+	MsgMpesaStatusUsage   Message = "mpesa_status_usage"
+	MsgMpesaStatusFailed  Message = "mpesa_status_failed"
+	MsgMpesaNotCompleted  Message = "mpesa_not_completed"
+	MsgMpesaUnknown       Message = "mpesa_unknown"
+	MsgMpesaLinkFailed    Message = "mpesa_link_failed"
+	MsgMpesaNotOurs       Message = "mpesa_not_ours"
+	MsgMpesaUnconfirmed   Message = "mpesa_unconfirmed"
+
+	// Shops
+	MsgShopSwitchUsage   Message = "shop_switch_usage"
+	MsgShopInvalidNumber Message = "shop_invalid_number"
+	MsgShopSwitchFailed  Message = "shop_switch_failed"
+	MsgShopAddUsage      Message = "shop_add_usage"
+	MsgShopNameUsage     Message = "shop_name_usage"
+
+	// Predictions
+	MsgPredictUnknown Message = "predict_unknown"
+
+	// QR payments
+	MsgQRUsage         Message = "qr_usage"
+	MsgQRInvalidAmount Message = "qr_invalid_amount"
+	MsgQRFailed        Message = "qr_failed"
+	MsgQRStaticFailed  Message = "qr_static_failed"
+	MsgQRUnknown       Message = "qr_unknown"
+
+	// Receipts
+	MsgReceiptUsage        Message = "receipt_usage"
+	MsgReceiptSaleNotFound Message = "receipt_sale_not_found"
+	MsgReceiptSendFailed   Message = "receipt_send_failed"
+
+	// Z-reports
+	MsgZReportUsage      Message = "zreport_usage"
+	MsgZReportCloseUsage Message = "zreport_close_usage"
+	MsgZReportDateUsage  Message = "zreport_date_usage"
+	MsgZReportNotFound   Message = "zreport_not_found"
+
+	// Expenses
+	MsgExpenseUsage             Message = "expense_usage"
+	MsgExpenseStopUsage         Message = "expense_stop_usage"
+	MsgExpenseRecurringNotFound Message = "expense_recurring_not_found"
+
+	// Catalog
+	MsgCatalogFailed Message = "catalog_failed"
+
+	// Loyalty
+	MsgLoyaltyPointsUsage      Message = "loyalty_points_usage"
+	MsgLoyaltyAddUsage         Message = "loyalty_add_usage"
+	MsgLoyaltyRedeemUsage      Message = "loyalty_redeem_usage"
+	MsgLoyaltyCustomerNotFound Message = "loyalty_customer_not_found"
+	MsgLoyaltyCustomerExists   Message = "loyalty_customer_exists"
+	MsgLoyaltyInvalidPoints    Message = "loyalty_invalid_points"
+	MsgLoyaltyNotEnoughPoints  Message = "loyalty_not_enough_points"
+	MsgLoyaltyUnknown          Message = "loyalty_unknown"
+
+	// API access
+	MsgAPIKeyUsage Message = "api_key_usage"
+	MsgAPIUnknown  Message = "api_unknown"
+)
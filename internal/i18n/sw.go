@@ -0,0 +1,390 @@
+package i18n
+
+var swahili = map[Message]string{
+	MsgDeactivated:     "❌ Akaunti yako imesimamishwa. Tafadhali wasiliana na huduma kwa wateja.",
+	MsgFeatureDisabled: "⏸️ Huduma hii imezimwa kwa sasa. Tafadhali jaribu tena baadaye.",
+	MsgWelcome: `🎉 Karibu DukaPOS!
+
+Duka lako limefunguliwa!
+
+📱 Namba Yako: %s
+
+Kuanza Haraka:
+• add bread 50 30 - Ongeza mikate 30 @ KSh 50
+• sell bread 2 - Umeuza mikate 2
+• stock - Angalia bidhaa zote
+• report - Muhtasari wa leo
+• help - Angalia amri zote
+
+Karibu kwenye maduka ya kidijitali! 🛒`,
+	MsgUnknownCommand: `❓ Amri haijulikani: %s
+
+📝 Zinazopatikana:
+add, sell, stock, price
+remove, delete, report
+profit, low, help
+
+Andika: help kuona orodha kamili`,
+	MsgProductNotFound: "❌ Bidhaa '%s' haipatikani",
+	MsgInvalidQuantity: "❌ Idadi si sahihi",
+	MsgInvalidPrice:    "❌ Bei si sahihi",
+
+	MsgHelp: `%s
+
+📝 AMRI:
+
+🆕 BIDHAA:
+add [jina] [bei] [idadi]
+  Mfano: add milk 60 20
+unit [jina] crate 24 - Nunua kwa crate
+  Kisha: add soda 50 1 crate
+
+💰 MAUZO:
+sell [jina] [idadi]
+  Mfano: sell milk 2
+fav add [jina] - Kipendwa cha kuuza haraka
+  Kisha: sell 1 3 (3 za kipendwa #1)
+risiti - Risiti ya mauzo ya mwisho (PDF)
+katalogi [kundi] - Orodha ya bei kwa wateja (PDF)
+
+📊 RIPOTI:
+stock - Angalia bidhaa zote
+stock [jina] - Angalia bidhaa moja
+report - Muhtasari wa leo
+profit - Faida ya leo
+low - Bidhaa zinazokwisha
+weekly - Muhtasari wa wiki hii
+monthly - Muhtasari wa mwezi huu
+zreport - Funga siku (ripoti ya Z)
+expense - Rekodi matumizi (kodi, usafiri...)
+category - Angalia makundi
+
+💵 BEI:
+price [jina] - Angalia bei
+price [jina] [mpya] - Badilisha bei
+price history [jina] - Historia ya bei
+
+⚙️ MIPANGILIO:
+threshold [bidhaa] - Angalia kiwango cha chini
+threshold [bidhaa] [idadi] - Weka tahadhari
+threshold [bidhaa] 20%% [juu] - Tahadhari kwa 20%% ya akiba ya juu
+barcode [namba] - Tafuta bidhaa
+alias add [bidhaa] [fupi] - Jina fupi
+set phone basic - Menyu za namba
+set rounding 5 - Zungusha pesa taslimu kwa bob 5
+set negative on - Uza kabla ya kuingiza bidhaa
+hours mon-fri 08:00-18:00 - Saa za biashara
+
+➖ PUNGUZA BIDHAA:
+remove [jina] [idadi]
+
+🗑️ FUTA:
+delete [jina]
+  Kisha: yes delete [jina] kuthibitisha
+
+🏪 DUKA:
+shop - Taarifa za duka
+plan - Taarifa za mpango
+
+🔧 MSAADA:
+help - Onyesha ujumbe huu%s%s`,
+	MsgHelpPro: `
+💎 AMRI ZA PRO:
+mpesa pay [kiasi] [simu] - Omba malipo ya M-Pesa (bila simu: kiungo cha malipo)
+staff - Simamia wafanyakazi
+staff add [jina] [simu] [cheo] - Ongeza mfanyakazi
+supplier - Simamia wasambazaji
+shop list - Angalia maduka yote
+
+📈 ZAIDI:
+weekly - Muhtasari wa wiki hii
+monthly - Muhtasari wa mwezi huu
+category - Angalia/weka makundi
+threshold [bidhaa] [idadi] - Weka tahadhari ya bidhaa kuisha
+barcode [namba] - Tafuta kwa barcode`,
+	MsgHelpUpgrade: `
+💎 HUDUMA ZA PRO:
+Pandisha mpango kupata:
+• Malipo ya M-Pesa
+• Akaunti za wafanyakazi
+• Usimamizi wa wasambazaji
+• Maduka mengi
+• Ripoti za kina
+• Barcode
+
+Jibu: upgrade`,
+	MsgHelpLanguages: `
+
+🌐 LUGHA:
+%s
+Badilisha: lang [code] au set language [code]`,
+
+	MsgLanguageUsage: "❌ Tumia: lang [code]\nZinazopatikana: %s",
+	MsgLanguageSet:   "✅ Lugha imebadilishwa kuwa Kiswahili.",
+
+	MsgPhoneUsage: "❌ Tumia: set phone [basic|smart]\nbasic - menyu za namba kwa simu za kawaida\nsmart - orodha kamili ya amri",
+	MsgPhoneBasic: "✅ Menyu za namba zimewashwa.\nAndika help, kisha jibu kwa namba.",
+	MsgPhoneSmart: "✅ Menyu za namba zimezimwa.\nAndika help kuona amri zote.",
+
+	MsgRoundingUsage: "❌ Tumia: set rounding [0|1|5]\n0 - jumla kamili\n1 - shilingi iliyo karibu\n5 - bob 5 zilizo karibu\nM-Pesa huzungushwa kwa shilingi kila wakati",
+	MsgRoundingNone:  "✅ Jumla za pesa taslimu hazizungushwi tena.",
+	MsgRoundingSet:   "✅ Jumla za pesa taslimu sasa zinazungushwa kwa KSh %d iliyo karibu.",
+
+	MsgNegativeStockUsage: "❌ Tumia: set negative [on|off]\non - rekodi mauzo hata bidhaa zikionyesha sifuri\noff - kataa mauzo yanayozidi bidhaa zilizopo",
+	MsgNegativeStockOn:    "✅ Mauzo zaidi ya bidhaa zilizopo sasa yanarekodiwa.\nBidhaa zitaonyesha chini ya sifuri hadi uongeze ulizonazo: add [jina] [bei] [idadi]",
+	MsgNegativeStockOff:   "✅ Mauzo yanayozidi bidhaa zilizopo yanakataliwa tena.",
+	MsgHelpMenu: `%s
+
+📱 MENYU - jibu kwa namba:
+%s
+Ongeza maelezo baada ya namba:
+  Mfano: 1 bread 2
+
+Orodha kamili ya amri: set phone smart`,
+	MsgMenuSell:     "Uza [jina] [idadi]",
+	MsgMenuAdd:      "Ongeza bidhaa [jina] [bei] [idadi]",
+	MsgMenuStock:    "Bidhaa",
+	MsgMenuPrice:    "Bei [jina]",
+	MsgMenuReport:   "Ripoti ya leo",
+	MsgMenuProfit:   "Faida ya leo",
+	MsgMenuLowStock: "Bidhaa zinazoisha",
+	MsgMenuWeekly:   "Wiki hii",
+	MsgMenuRemove:   "Toa bidhaa [jina] [idadi]",
+
+	MsgAddUsage:        "❌ Tumia: add [jina] [bei] [idadi]\nMfano: add bread 50 30",
+	MsgAddNameTooShort: "❌ Jina la bidhaa ni fupi mno.\nTumia: add [jina] [bei] [idadi]",
+	MsgAddNameTooLong:  "❌ Jina la bidhaa ni refu mno (herufi 50 zaidi).\nTumia: add [jina] [bei] [idadi]",
+	MsgAddInvalidPrice: "❌ Bei si sahihi. Tumia: add [jina] [bei] [idadi]\nMfano: add bread 50",
+	MsgAddPriceTooHigh: "❌ Bei ni kubwa mno (juu kabisa KSh 999,999)",
+	MsgAddInvalidQty:   "❌ Idadi si sahihi. Tumia: add [jina] [bei] [idadi]\nMfano: add bread 50 30",
+	MsgAddQtyTooHigh:   "❌ Idadi ni kubwa mno (juu kabisa 999,999)",
+	MsgAddCreated:      "✅ Bidhaa MPYA: %s\n💰 Bei: KSh %.0f\n📦 Idadi: %d\n\nDokezo: Weka tahadhari ya bidhaa kuisha: threshold %s 5",
+	MsgAddUpdated:      "✅ Imesasishwa: %s\n📦 Ilikuwa: %d → Sasa: %d (+%d)\n💰 Bei: KSh %.0f (ilikuwa: %.0f)",
+	MsgAddLimitReached: "💎 Umefikia kikomo cha bidhaa!\n\nMpango wako wa %s unaruhusu bidhaa %d.\nPandisha hadi %s kuongeza zaidi.\n\nJibu: upgrade",
+
+	MsgSellUsage:          "❌ Tumia: sell [jina] [idadi]\nMfano: sell bread 2",
+	MsgSellInvalidQty:     "❌ Idadi si sahihi.\nTumia: sell [jina] [idadi]\nMfano: sell bread 2",
+	MsgSellQtyTooHigh:     "❌ Idadi ni kubwa mno (juu kabisa 99,999)",
+	MsgSellNoProducts:     "❌ Bado huna bidhaa.\n\nOngeza kwanza: add [jina] [bei] [idadi]\nMfano: add milk 60 20",
+	MsgSellNotFound:       "❌ Bidhaa '%s' haipatikani.\n\nBidhaa zilizopo:\n%s",
+	MsgSellDidYouMean:     "\n\nUlimaanisha: %s?",
+	MsgSellOutOfStock:     "❌ %s IMEISHA!\n\nOngeza zaidi: add %s %.0f [idadi]",
+	MsgSellNotEnoughStock: "❌ Bidhaa hazitoshi!\n📦 Zilizopo: %d %s\n\nUza kidogo: sell %s %d",
+	MsgSellUnavailable:    "❌ %s haipatikani kwa sasa.\nWasiliana na huduma kwa wateja.",
+	MsgSold:               "✅ IMEUZWA!\n%s x%d = KSh %.0f\n💵 Faida: KSh %.0f\n📦 Zimebaki: %s",
+	MsgSoldLoyaltyPoints:  "\n💎 +%d pointi za uaminifu!",
+	MsgSoldLowStock:       "\n⚠️ BIDHAA ZINAKWISHA! Zimebaki %d tu!",
+	MsgSoldNegativeStock:  "\n⚠️ Umeuza zaidi ya bidhaa zilizorekodiwa: %s sasa iko %d.\nOngeza bidhaa ulizonazo: add %s %.0f [idadi]",
+	MsgSoldCrossSell:      "\n🛒 Wateja pia hununua: %s.",
+
+	MsgStockProduct:   "📦 %s\n💰 Bei: KSh %.0f\n📦 Zilizopo: %s\n%s",
+	MsgStockIn:        "✅ Zipo",
+	MsgStockLow:       "⚠️ Zinakwisha!",
+	MsgStockEmpty:     "📦 Bado huna bidhaa!\nOngeza: add [jina] [bei] [idadi]",
+	MsgStockInventory: "📦 BIDHAA:\n\n",
+	MsgStockTotal:     "\n💰 Thamani Jumla: KSh %.0f",
+
+	MsgPriceUsage:         "❌ Tumia: price [jina] au price [jina] [bei_mpya]",
+	MsgPriceUpdated:       "✅ Bei Imebadilishwa!\n%s\n💰 Ilikuwa: KSh %.0f → Sasa: KSh %.0f",
+	MsgPriceInfo:          "💰 %s\nBei: KSh %.0f\nZilizopo: %d %s",
+	MsgPriceHistoryUsage:  "❌ Tumia: price history [jina]\nMfano: price history bread",
+	MsgPriceHistoryEmpty:  "📈 %s\nHakuna mabadiliko ya bei bado.\nBei ya sasa: KSh %.0f",
+	MsgPriceHistoryHeader: "📈 HISTORIA YA BEI: %s\nSasa: KSh %.0f\n\n",
+	MsgPriceHistoryLine:   "• %s: KSh %.0f → KSh %.0f (%s)\n",
+
+	MsgRemoveUsage:          "❌ Tumia: remove [jina] [idadi]\nMfano: remove bread 5",
+	MsgRemoveNotEnoughStock: "❌ Bidhaa hazitoshi!\nZilizopo: %d",
+	MsgRemoved:              "✅ Umeondoa %d %s kutoka %s\n📦 Zimebaki: %d",
+
+	MsgDailyReport:  "📊 RIPOTI YA LEO\n📅 %s\n\n💰 Mauzo: KSh %.0f\n📝 Miamala: %d\n💵 Faida: KSh %.0f\n\nBidhaa Zinazoongoza:",
+	MsgNoSalesToday: "\nBado hakuna mauzo leo!",
+	MsgReportItem:   "\n• %s: %d zimeuzwa",
+	MsgNoSalesWeek:  "📊 Hakuna mauzo wiki hii.\n\nAnza kurekodi mauzo kuona ripoti!",
+	MsgWeeklyReport: `📊 RIPOTI YA WIKI
+📅 Siku 7 zilizopita (hadi %s)
+
+💰 Mauzo Jumla: KSh %.0f%s
+📝 Miamala: %d%s
+💵 Faida: KSh %.0f%s
+📈 Wastani kwa Siku: KSh %.0f
+
+Endelea na kazi nzuri! 💪`,
+	MsgNoSalesMonth: "📊 Hakuna mauzo mwezi huu.\n\nAnza kurekodi mauzo kuona ripoti!",
+	MsgMonthlyReport: `📊 RIPOTI YA MWEZI
+📅 %s
+
+💰 Mauzo Jumla: KSh %.0f%s
+📝 Miamala: %d%s
+💵 Faida: KSh %.0f%s
+📈 Wastani kwa Siku: KSh %.0f
+
+Hongera kwa mwezi huu! 🎉`,
+	MsgProfit:        "💵 FAIDA YA LEO: KSh %.0f\n\n💰 Mauzo Jumla: KSh %.0f\n📝 Miamala: %d",
+	MsgProfitNet:     "\n💸 Matumizi: KSh %.0f\n📊 FAIDA HALISI: KSh %.0f",
+	MsgVsLastWeek:    "(%s dhidi ya wiki iliyopita)",
+	MsgNoneLastWeek:  "(hakuna wiki iliyopita)",
+	MsgVsLastMonth:   "(%s dhidi ya mwezi uliopita)",
+	MsgNoneLastMonth: "(hakuna mwezi uliopita)",
+
+	MsgAllWellStocked: "✅ Bidhaa zote zipo za kutosha!",
+	MsgLowStockAlert:  "⚠️ TAHADHARI - BIDHAA ZINAKWISHA:\n\n",
+	MsgLowStockItem:   "• %s: %d %s (kiwango cha chini: %d)\n",
+	MsgLowStockOwed:   "• %s: %d %s (imeuzwa chini ya sifuri, ongeza bidhaa)\n",
+
+	MsgDeleteUsage: "❌ Tumia: delete [jina]",
+	MsgDeleted:     "🗑️ Imefutwa: %s",
+
+	MsgDeleteConfirm:  "⚠️ Una uhakika unataka kufuta %s?\nJibu `yes %s` kuthibitisha ndani ya sekunde 30.",
+	MsgRemoveConfirm:  "⚠️ Uondoe %d %s za %s? Ni nyingi.\nJibu `yes %s` kuthibitisha ndani ya sekunde 30.",
+	MsgConfirmNothing: "❌ Hakuna cha kuthibitisha. Uthibitisho huisha baada ya sekunde 30; tuma amri tena.",
+
+	MsgSearchUsage:   "❌ Tumia: search [jina la bidhaa]\nMfano: search milk",
+	MsgSearchNone:    "❌ Hakuna bidhaa inayolingana na '%s'\n\nJaribu neno lingine.",
+	MsgSearchResults: "🔍 Matokeo ya '%s':\n\n",
+
+	MsgCostInvalid: "❌ Bei ya kununua si sahihi. Tumia namba chanya.",
+	MsgCostUpdated: "✅ Bei ya Kununua Imebadilishwa!\n\n💰 %s\nKununua: KSh %.2f\nKuuza: KSh %.2f\nFaida: %.1f%%",
+
+	MsgThresholdInvalid:        "❌ Kiwango si sahihi. Tumia namba kati ya 1-9999",
+	MsgThresholdUpdated:        "✅ Kiwango Kimebadilishwa!\n%s\nTahadhari ya bidhaa kuisha: %d\nUtaarifiwa bidhaa ikipungua chini ya hapa.",
+	MsgThresholdPercentInvalid: "❌ Asilimia si sahihi. Tumia 1-100%, na akiba ya juu kati ya 1-99999\nMfano: threshold milk 20% 200",
+	MsgThresholdPercentUpdated: "✅ Kiwango Kimebadilishwa!\n%s\nTahadhari kwa %d%% ya akiba ya juu %d: %d\nUtaarifiwa bidhaa ikipungua chini ya hapa.",
+	MsgThresholdNeedsMax:       "❌ %s haina akiba ya juu ya kuchukua %d%%.\n\nOngeza kiasi cha juu unachoweka: threshold %s %d%% [akiba ya juu]",
+
+	MsgBarcodeUsage:    "❌ Tumia: barcode add [bidhaa] [barcode]\nMfano: barcode add milk 5901234123457",
+	MsgBarcodeInvalid:  "❌ Barcode si sahihi (herufi 4-50)",
+	MsgBarcodeTaken:    "❌ Barcode tayari imepewa '%s'",
+	MsgBarcodeSet:      "✅ Barcode imewekwa!\n%s\nBarcode: %s",
+	MsgBarcodeNotFound: "❌ Hakuna bidhaa yenye barcode: %s\n\nDokezo: Weka barcode kwa: barcode add [bidhaa] [code]",
+
+	MsgUnitUsage:         "❌ Tumia: unit [jina] [kipimo cha jumla] [idadi ndani yake]\nMfano: unit soda crate 24\nKisha ongeza kwa: add soda 50 1 crate\nOndoa kwa: unit soda off",
+	MsgUnitInvalidFactor: "❌ Idadi ndani ya kipimo lazima iwe namba kamili zaidi ya 0.\nMfano: unit soda crate 24",
+	MsgUnitSet:           "✅ %s: %s 1 = %d %s\n📦 Zilizopo: %s\n\nOngeza kwa: add %s [bei] [idadi] %s",
+	MsgUnitCleared:       "✅ %s haina kipimo cha jumla tena.",
+	MsgUnitUnknown:       "❌ %s haihesabiwi kwa '%s'.\nWeka kipimo cha jumla kwanza: unit %s %s [idadi kwa %s]",
+
+	MsgAliasUsage:    "❌ Tumia: alias add [bidhaa] [jina fupi]\nMfano: alias add coca-cola 500ml coke\nKisha: sell coke 2\nOndoa kwa: alias remove coke",
+	MsgAliasInvalid:  "❌ Jina fupi lisizidi herufi 100.",
+	MsgAliasAdded:    "✅ '%s' sasa inamaanisha %s.\nMfano: sell %s 1",
+	MsgAliasTaken:    "❌ '%s' tayari ni jina la bidhaa nyingine.",
+	MsgAliasRemoved:  "✅ '%s' haimaanishi %s tena.",
+	MsgAliasNotFound: "❌ Hakuna bidhaa inayoitwa '%s'.",
+	MsgAliasList:     "🏷️ %s pia inaitwa:\n%s",
+	MsgAliasNone:     "🏷️ %s haina majina mafupi.\nOngeza: alias add %s [jina fupi]",
+
+	MsgFavUsage:      "❌ Tumia: fav add [bidhaa]\nfav - Orodha ya vipendwa\nfav remove [namba]\nUza kipendwa: sell [namba] [idadi]\nMfano: sell 1 3",
+	MsgFavNone:       "⭐ Hakuna vipendwa bado.\nOngeza: fav add milk\nKisha uza: sell 1 3",
+	MsgFavList:       "⭐ VIPENDWA:\n%s\n\nUza: sell [namba] [idadi]\nMfano: sell 1 3",
+	MsgFavTopSellers: "⭐ Hakuna vipendwa bado. Zinazouzwa zaidi wiki hii:\n%s\n\nChagua moja uuze kwa namba: fav add [bidhaa]",
+	MsgFavAdded:      "⭐ %s ni kipendwa #%d.\nUza: sell %d [idadi]",
+	MsgFavFull:       "❌ Tayari una vipendwa %d.\nOndoa kimoja kwanza: fav remove [namba]",
+	MsgFavRemoved:    "✅ %s si kipendwa tena.",
+	MsgFavNotFound:   "❌ %s si kipendwa.\nViangalie: fav",
+	MsgFavNoNumber:   "❌ Hakuna kipendwa #%d.\nViangalie: fav",
+	MsgFavOutOfStock: "%d. %s - IMEISHA",
+
+	MsgHoursUsage: "❌ Tumia: hours [siku] [fungua-funga]\nMfano: hours mon-fri 08:00-18:00\nhours sun closed\nhours off - wazi kila wakati",
+	MsgHoursNone:  "🕗 Hakuna saa za biashara, bot hujibu wakati wowote.\n\nWeka: hours mon-fri 08:00-18:00",
+	MsgHoursList:  "🕗 SAA ZA BIASHARA:\n%s\nNje ya saa hizi bot hujibu kuwa mmefunga.\nWazi kila wakati: hours off",
+	MsgHoursOff:   "✅ Saa za biashara zimeondolewa, bot hujibu wakati wowote.",
+	MsgClosed:     "🌙 %s imefungwa. Tunafungua tena %s.",
+
+	MsgSupplierPayUsage:          "❌ Tumia: supplier pay [jina] [kiasi] [#oda]\nMfano: supplier pay Brookside 5000\nLipa sehemu ya oda: supplier pay Brookside 2000 #12",
+	MsgSupplierPayInvalid:        "❌ Kiasi si sahihi",
+	MsgSupplierPayUnavailable:    "⚙️ Malipo ya M-Pesa hayapatikani.\nWasiliana na msaada.",
+	MsgSupplierPayNeedsB2C:       "⚠️ Malipo ya wasambazaji yanahitaji M-Pesa B2C.\n\n%v",
+	MsgSupplierNotFound:          "❌ Msambazaji hakupatikana.\nTumia: supplier kuona wasambazaji wote",
+	MsgSupplierNoPhone:           "❌ %s hana namba ya simu ya kulipa",
+	MsgSupplierPayNoOrder:        "❌ Oda #%d si oda isiyolipwa kutoka kwa %s.",
+	MsgSupplierPayTooMuch:        "❌ Oda #%d imebaki KSh %.0f tu kulipwa.",
+	MsgSupplierPayNoMatch:        "❌ KSh %d hailingani na deni lako kwa %s kwenye oda yoyote:\n%s\nKulipa sehemu ya oda, itaje: supplier pay %s %d #[oda]",
+	MsgSupplierPayConfirm:        "⚠️ Tuma KSh %d kwa %s (%s) kwa oda #%d? Imebaki KSh %.0f kulipwa.\nJibu `yes %s` kuthibitisha ndani ya sekunde 30.",
+	MsgSupplierPayConfirmNoOrder: "⚠️ Tuma KSh %d kwa %s (%s)? Hakuna deni kwenye oda zao, kwa hivyo haitalipa oda yoyote.\nJibu `yes %s` kuthibitisha ndani ya sekunde 30.",
+	MsgSupplierPayInFlight:       "⏳ Malipo ya oda #%d tayari yanaendelea. Subiri M-Pesa ithibitishe.",
+	MsgSupplierPayFailed:         "❌ Malipo kwa %s yameshindwa: %v",
+	MsgSupplierPaying:            "💸 Unalipa %s KSh %d\n📱 %s",
+	MsgSupplierPayOrder:          "\n📋 Oda #%d (KSh %.0f, imebaki KSh %.0f kabla ya malipo haya)",
+	MsgSupplierPayPending:        "\n\nUtaona imelipwa M-Pesa ikithibitisha.",
+
+	MsgStaffLimitReached: "💎 Umefikia kikomo cha wafanyakazi!\n\nMpango wako wa %s unaruhusu wafanyakazi %d.\nPandisha hadi %s kuongeza zaidi.\n\nJibu: upgrade",
+	MsgStaffAddUsage:     "❌ Tumia: staff add [jina] [simu] [cheo]\n\nMfano: staff add John +254700000001 cashier\n\nVyeo: manager, cashier, stock clerk",
+	MsgStaffInvalidRole:  "❌ Cheo si sahihi.\nVinavyokubalika: manager, cashier, stock clerk",
+	MsgStaffExists:       "❌ Mfanyakazi mwenye simu %s tayari yupo!",
+	MsgStaffRemoveUsage:  "❌ Tumia: staff remove [simu]",
+	MsgStaffActiveUsage:  "❌ Tumia: staff active [simu]",
+	MsgStaffNotFound:     "❌ Hakuna mfanyakazi mwenye simu hiyo.",
+	MsgStaffUnknown:      "❌ Amri ya wafanyakazi haijulikani.\nTumia: staff, staff add, staff remove, staff active",
+
+	MsgBundleShort:        "❌ Hakuna %s za kutosha kwa %d %s.\n\nUnahitaji: %d\nZilizopo: %d",
+	MsgBundleStockChanged: "❌ Idadi ya bidhaa imebadilika ukiuza %s. Tafadhali jaribu tena.",
+	MsgBundleNotBundle:    "❌ %s si kifurushi.",
+	MsgBundleInvalidQty:   "❌ Idadi ya %s si sahihi. Tumia 1 hadi 999.",
+	MsgBundleNested:       "❌ %s ni kifurushi. Kifurushi kinaweza kuwa na bidhaa tu.",
+	MsgBundleItemTwice:    "❌ %s inaweza kuwa kwenye %s mara moja tu.",
+	MsgBundleNameTaken:    "❌ %s tayari ni bidhaa. Kipe kifurushi jina lingine.",
+
+	MsgCategoryNotFound: "❌ Aina '%s' haipatikani.\n\nZilizopo: %s",
+
+	MsgSupplierAddUsage:  "❌ Tumia: supplier add [jina] [simu]\nMfano: supplier add Brookside +254700000000",
+	MsgSupplierViewUsage: "❌ Tumia: supplier view [jina]",
+
+	MsgOrderViewUsage:  "❌ Tumia: order view [namba_ya_oda]",
+	MsgOrderDraftUsage: "❌ Tumia: order %s [namba_ya_oda]",
+	MsgOrderInvalidID:  "❌ Namba ya oda si sahihi",
+	MsgOrderNotFound:   "❌ Oda haipatikani",
+	MsgOrderNotDraft:   "❌ Oda #%d tayari ni %s",
+	MsgOrderCancelled:  "❌ Oda #%d imeghairiwa",
+
+	MsgMpesaPayUsage:      "❌ Tumia: mpesa pay [kiasi] [simu]\nMfano: mpesa pay 500 0712345678",
+	MsgMpesaInvalidAmount: "❌ Kiasi si sahihi",
+	MsgMpesaInvalidPhone:  "❌ Namba ya simu si sahihi\nMfano: mpesa pay 500 0712345678",
+	MsgMpesaPayFailed:     "❌ Malipo yameshindikana: %v\n\nTafadhali jaribu tena au wasiliana na huduma kwa wateja.",
+	MsgMpesaStatusUsage:   "❌ Tumia: mpesa status [namba]\nMfano: mpesa status QJK4ABC123",
+	MsgMpesaStatusFailed:  "❌ Imeshindikana kuangalia hali: %v",
+	MsgMpesaNotCompleted:  "❌ Malipo hayajakamilika\n\nCheckout ID: %s\nSababu: %s",
+	MsgMpesaUnknown:       "❌ Amri ya M-Pesa haijulikani. Tumia: mpesa pay [kiasi] [simu]",
+	MsgMpesaLinkFailed:    "❌ Imeshindikana kutengeneza kiungo cha malipo: %v",
+	MsgMpesaNotOurs:       "❌ %s si malipo kwa till au paybill yako",
+	MsgMpesaUnconfirmed:   "❌ M-Pesa imeshindwa kuthibitisha %s\n\n%s",
+
+	MsgShopSwitchUsage:   "❌ Tumia: shop switch [namba ya duka]\nMfano: shop switch 2",
+	MsgShopInvalidNumber: "❌ Namba ya duka si sahihi.\nMfano: shop switch 2",
+	MsgShopSwitchFailed:  "❌ Imeshindikana kubadilisha duka.\n\nMaduka mengi yanahitaji mpango wa Pro.\nJibu: upgrade",
+	MsgShopAddUsage:      "❌ Tumia: shop add [jina]\nMfano: shop add Mombasa Branch",
+	MsgShopNameUsage:     "❌ Tumia: shop name [jina jipya]\nMfano: shop name My New Shop",
+
+	MsgPredictUnknown: "❌ Amri ya predict haijulikani. Tumia: predict stock, predict trends, au predict restock",
+
+	MsgQRUsage:         "❌ Tumia: qr generate [kiasi]\nMfano: qr generate 500",
+	MsgQRInvalidAmount: "❌ Kiasi si sahihi. Tumia namba sahihi.",
+	MsgQRFailed:        "❌ Imeshindikana kutengeneza QR: %v\n\nTafadhali jaribu tena.",
+	MsgQRStaticFailed:  "❌ Imeshindikana kutengeneza QR ya kudumu: %v",
+	MsgQRUnknown:       "❌ Amri ya qr haijulikani. Tumia: qr generate [kiasi] au qr static",
+
+	MsgReceiptUsage:        "❌ Tumia: receipt [namba_ya_mauzo]\nMfano: receipt 42",
+	MsgReceiptSaleNotFound: "❌ Mauzo #%d hayapatikani.",
+	MsgReceiptSendFailed:   "❌ Imeshindikana kutuma risiti. Tafadhali jaribu tena.",
+
+	MsgZReportUsage:      "❌ Tumia: zreport [close [pesa iliyohesabiwa] | namba | tarehe]\nMfano: zreport close 4500",
+	MsgZReportCloseUsage: "❌ Tumia: zreport close [pesa iliyohesabiwa]\nMfano: zreport close 4500",
+	MsgZReportDateUsage:  "❌ Tumia: zreport [tarehe]\nMfano: zreport 2024-11-30",
+	MsgZReportNotFound:   "❌ Z-report #%d haipatikani.",
+
+	MsgExpenseUsage:             "❌ Tumia: expense add [kiasi] [aina] [maelezo]\nMfano: expense add 200 transport matatu to town",
+	MsgExpenseStopUsage:         "❌ Tumia: expense stop [namba]\nTuma *expense recurring* kuona namba.",
+	MsgExpenseRecurringNotFound: "❌ Matumizi yanayojirudia #%d hayapatikani.",
+
+	MsgCatalogFailed: "❌ Imeshindikana kutengeneza katalogi. Tafadhali jaribu tena.",
+
+	MsgLoyaltyPointsUsage:      "❌ Tumia: loyalty points [simu]",
+	MsgLoyaltyAddUsage:         "❌ Tumia: loyalty add [simu] [jina]\n\nMfano: loyalty add +254700000001 John Doe",
+	MsgLoyaltyRedeemUsage:      "❌ Tumia: loyalty redeem [simu] [pointi]",
+	MsgLoyaltyCustomerNotFound: "❌ Mteja hapatikani.\nTumia: loyalty add [simu] [jina] kumwongeza",
+	MsgLoyaltyCustomerExists:   "❌ Mteja mwenye simu hii tayari yupo!",
+	MsgLoyaltyInvalidPoints:    "❌ Pointi si sahihi (angalau 10)",
+	MsgLoyaltyNotEnoughPoints:  "❌ Pointi hazitoshi!\nZilizopo: pointi %d",
+	MsgLoyaltyUnknown:          "❌ Amri ya uaminifu haijulikani.\n\nAmri:\nloyalty - Orodha ya wateja\nloyalty points [simu] - Angalia pointi\nloyalty add [simu] [jina] - Ongeza mteja\nloyalty rewards - Zawadi\nloyalty tiers - Ngazi\nloyalty redeem [simu] [pointi] - Tumia pointi",
+
+	MsgAPIKeyUsage: "❌ Tumia: api key create [jina]",
+	MsgAPIUnknown:  "❌ Amri ya api haijulikani",
+}
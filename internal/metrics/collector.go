@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+)
+
+var (
+	activeShopsDesc = prometheus.NewDesc(
+		"dukapos_active_shops",
+		"Number of active shops.",
+		nil, nil,
+	)
+	lowStockDesc = prometheus.NewDesc(
+		"dukapos_low_stock_products",
+		"Active products at or below their low stock threshold.",
+		[]string{"shop_id"}, nil,
+	)
+)
+
+// StoreCollector reads shop and stock gauges from the database on each scrape
+type StoreCollector struct {
+	db *gorm.DB
+}
+
+// NewStoreCollector creates a collector for the shop and stock gauges
+func NewStoreCollector(db *gorm.DB) *StoreCollector {
+	return &StoreCollector{db: db}
+}
+
+// Describe implements prometheus.Collector
+func (c *StoreCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- activeShopsDesc
+	ch <- lowStockDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *StoreCollector) Collect(ch chan<- prometheus.Metric) {
+	var activeShops int64
+	if err := c.db.Model(&models.Shop{}).Where("is_active = ?", true).Count(&activeShops).Error; err != nil {
+		log.Printf("⚠️ Metrics: failed to count active shops: %v", err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(activeShopsDesc, prometheus.GaugeValue, float64(activeShops))
+	}
+
+	var lowStock []struct {
+		ShopID uint
+		Count  int64
+	}
+	err := c.db.Model(&models.Product{}).
+		Select("shop_id, COUNT(*) AS count").
+		Where("is_active = ? AND current_stock <= "+models.LowStockLevelSQL, true).
+		Group("shop_id").
+		Scan(&lowStock).Error
+	if err != nil {
+		log.Printf("⚠️ Metrics: failed to count low stock products: %v", err)
+		return
+	}
+	for _, row := range lowStock {
+		ch <- prometheus.MustNewConstMetric(lowStockDesc, prometheus.GaugeValue, float64(row.Count), strconv.FormatUint(uint64(row.ShopID), 10))
+	}
+}
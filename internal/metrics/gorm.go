@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const startKey = "metrics:start"
+
+// RegisterGORMCallbacks times every query and counts created sales
+func RegisterGORMCallbacks(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("metrics:start_create", startQuery),
+		cb.Create().After("gorm:create").Register("metrics:observe_create", observeQuery("create")),
+		cb.Create().After("gorm:create").Register("metrics:count_sales", countSales),
+		cb.Query().Before("gorm:query").Register("metrics:start_query", startQuery),
+		cb.Query().After("gorm:query").Register("metrics:observe_query", observeQuery("query")),
+		cb.Update().Before("gorm:update").Register("metrics:start_update", startQuery),
+		cb.Update().After("gorm:update").Register("metrics:observe_update", observeQuery("update")),
+		cb.Delete().Before("gorm:delete").Register("metrics:start_delete", startQuery),
+		cb.Delete().After("gorm:delete").Register("metrics:observe_delete", observeQuery("delete")),
+		cb.Row().Before("gorm:row").Register("metrics:start_row", startQuery),
+		cb.Row().After("gorm:row").Register("metrics:observe_row", observeQuery("row")),
+		cb.Raw().Before("gorm:raw").Register("metrics:start_raw", startQuery),
+		cb.Raw().After("gorm:raw").Register("metrics:observe_raw", observeQuery("raw")),
+	)
+}
+
+func startQuery(db *gorm.DB) {
+	db.InstanceSet(startKey, time.Now())
+}
+
+func observeQuery(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(startKey)
+		if !ok {
+			return
+		}
+		if start, ok := value.(time.Time); ok {
+			DBQueryDuration.WithLabelValues(operation, db.Statement.Table).Observe(time.Since(start).Seconds())
+		}
+	}
+}
+
+// countSales counts every sale row written, whichever code path created it
+func countSales(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Table != "sales" || db.Statement.Schema == nil {
+		return
+	}
+	field := db.Statement.Schema.LookUpField("ShopID")
+	if field == nil {
+		return
+	}
+
+	rv := db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			countSale(db, field, reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		countSale(db, field, rv)
+	}
+}
+
+func countSale(db *gorm.DB, shopID *schema.Field, rv reflect.Value) {
+	if value, zero := shopID.ValueOf(db.Statement.Context, rv); !zero {
+		SalesTotal.WithLabelValues(fmt.Sprint(value)).Inc()
+	}
+}
@@ -0,0 +1,84 @@
+// Package metrics exposes operational metrics in the Prometheus text format.
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+var (
+	// SalesTotal counts recorded sales per shop
+	SalesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dukapos_sales_total",
+		Help: "Number of sales recorded.",
+	}, []string{"shop_id"})
+
+	// MpesaSTKSuccess counts STK push payments the customer completed
+	MpesaSTKSuccess = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "dukapos_mpesa_stk_success_total",
+		Help: "Number of successful M-Pesa STK push payments.",
+	})
+
+	// MpesaSTKFailure counts STK pushes that could not be sent or were not completed
+	MpesaSTKFailure = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "dukapos_mpesa_stk_failure_total",
+		Help: "Number of failed M-Pesa STK push payments.",
+	})
+
+	// HTTPRequestDuration observes request latency by route
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "dukapos_http_request_duration_seconds",
+		Help:    "HTTP request latency.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// DBQueryDuration observes database query latency by operation and table
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "dukapos_db_query_duration_seconds",
+		Help:    "Database query latency.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation", "table"})
+)
+
+// Init hooks the database into the metrics: query timings, sales counts and
+// the shop gauges read at scrape time
+func Init(db *gorm.DB) error {
+	if err := RegisterGORMCallbacks(db); err != nil {
+		return err
+	}
+	return prometheus.Register(NewStoreCollector(db))
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.Handler())
+}
+
+// Middleware observes the duration of every request. Routes are labelled by
+// their pattern (e.g. /api/v1/products/:id) to keep cardinality bounded.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+
+		// Render errors here so the recorded status is the one sent
+		if err := c.Next(); err != nil {
+			if herr := c.App().ErrorHandler(c, err); herr != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+
+		HTTPRequestDuration.WithLabelValues(
+			c.Method(),
+			c.Route().Path,
+			strconv.Itoa(c.Response().StatusCode()),
+		).Observe(time.Since(start).Seconds())
+		return nil
+	}
+}
@@ -25,3 +25,33 @@ func RequireAdmin() fiber.Handler {
 		return c.Next()
 	}
 }
+
+// RequireShopOwner allows only the shop owner's own session, not API keys or
+// staff, for actions that move money out of the shop
+func RequireShopOwner() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		shop, ok := c.Locals("shop").(*models.Shop)
+		if !ok || shop == nil {
+			return c.Status(401).JSON(fiber.Map{
+				"error": "Unauthorized",
+				"code":  "UNAUTHORIZED",
+			})
+		}
+
+		if c.Locals("api_key") != nil || c.Locals("staff_id") != nil {
+			return c.Status(403).JSON(fiber.Map{
+				"error": "Only the shop owner can do this",
+				"code":  "OWNER_REQUIRED",
+			})
+		}
+
+		if account, ok := c.Locals("account").(*models.Account); ok && account != nil && account.ID != shop.AccountID {
+			return c.Status(403).JSON(fiber.Map{
+				"error": "Only the shop owner can do this",
+				"code":  "OWNER_REQUIRED",
+			})
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"log"
+	"net"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ipAllowlist matches client IPs against single addresses and CIDR ranges
+type ipAllowlist struct {
+	ips  map[string]bool
+	nets []*net.IPNet
+}
+
+func newIPAllowlist(entries []string) *ipAllowlist {
+	list := &ipAllowlist{ips: make(map[string]bool)}
+	for _, entry := range entries {
+		list.add(entry)
+	}
+	return list
+}
+
+func (l *ipAllowlist) add(entry string) {
+	entry = strings.TrimSpace(entry)
+	if entry == "" {
+		return
+	}
+	if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+		l.nets = append(l.nets, ipNet)
+		return
+	}
+	l.ips[entry] = true
+}
+
+func (l *ipAllowlist) empty() bool {
+	return len(l.ips) == 0 && len(l.nets) == 0
+}
+
+func (l *ipAllowlist) contains(ip string) bool {
+	if l.ips[ip] {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, ipNet := range l.nets {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// IPAllowlist only lets through clients whose IP matches one of the listed
+// addresses or CIDR ranges. An empty list blocks everyone.
+func IPAllowlist(entries []string) fiber.Handler {
+	list := newIPAllowlist(entries)
+	if list.empty() {
+		log.Println("⚠️ IP allowlist is empty: all requests will be rejected")
+	}
+
+	return func(c *fiber.Ctx) error {
+		if !list.contains(c.IP()) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Access denied",
+				"code":  "FORBIDDEN",
+			})
+		}
+		return c.Next()
+	}
+}
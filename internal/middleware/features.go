@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// FeatureFlags tells whether a feature is switched on for every shop
+type FeatureFlags interface {
+	Enabled(name string) bool
+}
+
+// featureFlags is consulted by RequireFlag and RequireFeature; without it
+// every feature is on
+var featureFlags FeatureFlags
+
+// planFeatureFlags is the runtime flag each plan feature also needs
+var planFeatureFlags = map[Feature]string{
+	FeatureMpesa:         models.FeatureFlagMpesa,
+	FeatureQRPayments:    models.FeatureFlagMpesa,
+	FeatureMultipleShops: models.FeatureFlagMultipleShops,
+	FeatureStaffAccounts: models.FeatureFlagStaffAccounts,
+	FeatureWebhooks:      models.FeatureFlagAnalytics,
+	FeatureAI:            models.FeatureFlagAnalytics,
+	FeatureAPIAccess:     models.FeatureFlagAnalytics,
+	FeatureLoyalty:       models.FeatureFlagAnalytics,
+}
+
+// SetFeatureFlags sets the runtime flags features are switched by. Call it
+// before the server starts.
+func SetFeatureFlags(flags FeatureFlags) {
+	featureFlags = flags
+}
+
+// FlagEnabled reports whether a runtime flag is on
+func FlagEnabled(name string) bool {
+	return featureFlags == nil || featureFlags.Enabled(name)
+}
+
+// RequireFlag turns requests away while an admin has switched the feature
+// off
+func RequireFlag(name string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !FlagEnabled(name) {
+			return featureDisabled(c, name)
+		}
+		return c.Next()
+	}
+}
+
+func featureDisabled(c *fiber.Ctx, name string) error {
+	return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+		"error":   "This feature is currently disabled",
+		"code":    "FEATURE_DISABLED",
+		"feature": name,
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"strings"
 
 	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -12,29 +13,21 @@ func JWT(authService *services.AuthService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Authorization header required",
-			})
+			return utils.SendError(c, fiber.StatusUnauthorized, utils.CodeUnauthorized, "Authorization header required")
 		}
 
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Invalid authorization header format",
-			})
+			return utils.SendError(c, fiber.StatusUnauthorized, utils.CodeUnauthorized, "Invalid authorization header format")
 		}
 
 		tokenString := parts[1]
 		shop, err := authService.ValidateToken(tokenString)
 		if err != nil {
 			if err == services.ErrTokenExpired {
-				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-					"error": "Token has expired",
-				})
+				return utils.SendError(c, fiber.StatusUnauthorized, utils.CodeTokenExpired, "Token has expired")
 			}
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Invalid token",
-			})
+			return utils.SendError(c, fiber.StatusUnauthorized, utils.CodeUnauthorized, "Invalid token")
 		}
 
 		c.Locals("shop_id", shop.ID)
@@ -118,9 +111,7 @@ func Recover() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		defer func() {
 			if r := recover(); r != nil {
-				c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"error": "Internal server error",
-				})
+				utils.SendError(c, fiber.StatusInternalServerError, utils.CodeInternal, "Internal server error")
 			}
 		}()
 		return c.Next()
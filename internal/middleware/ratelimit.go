@@ -55,7 +55,10 @@ func RateLimiter(maxRequests int, windowSeconds int) fiber.Handler {
 
 // getKey generates a unique key for the client
 func (rl *TokenRateLimiter) getKey(c *fiber.Ctx) string {
-	// Use API key if present, otherwise use IP
+	// Signed-in requests count against their shop, then API key, then IP
+	if shopID, ok := c.Locals("shop_id").(uint); ok && shopID != 0 {
+		return fmt.Sprintf("shop:%d", shopID)
+	}
 	if apiKey := c.Get("X-API-Key"); apiKey != "" {
 		return fmt.Sprintf("api:%s", apiKey)
 	}
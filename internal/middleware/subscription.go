@@ -37,45 +37,26 @@ type SubscriptionConfig struct {
 	Business PlanLimits
 }
 
+// PlanLimits extends the plan's resource limits with its features
 type PlanLimits struct {
-	MaxProducts  int
-	MaxStaff     int
-	MaxShops     int
-	MaxCustomers int
-	MaxAPIKeys   int
-	MaxWebhooks  int
+	models.PlanLimits
 	Features     []Feature
 	MonthlyLimit int64
 }
 
 var DefaultSubscriptionConfig = SubscriptionConfig{
 	Free: PlanLimits{
-		MaxProducts:  50,
-		MaxStaff:     0,
-		MaxShops:     1,
-		MaxCustomers: 0,
-		MaxAPIKeys:   0,
-		MaxWebhooks:  0,
+		PlanLimits:   models.LimitsFor(models.PlanFree),
 		Features:     []Feature{},
 		MonthlyLimit: 0,
 	},
 	Pro: PlanLimits{
-		MaxProducts:  -1,
-		MaxStaff:     2,
-		MaxShops:     3,
-		MaxCustomers: 100,
-		MaxAPIKeys:   2,
-		MaxWebhooks:  2,
+		PlanLimits:   models.LimitsFor(models.PlanPro),
 		Features:     []Feature{FeatureMpesa, FeatureStaffAccounts, FeatureQRPayments, FeatureLoyalty},
 		MonthlyLimit: 10000,
 	},
 	Business: PlanLimits{
-		MaxProducts:  -1,
-		MaxStaff:     5,
-		MaxShops:     -1,
-		MaxCustomers: -1,
-		MaxAPIKeys:   10,
-		MaxWebhooks:  10,
+		PlanLimits: models.LimitsFor(models.PlanBusiness),
 		Features: []Feature{
 			FeatureMpesa, FeatureMultipleShops, FeatureStaffAccounts,
 			FeatureAPIAccess, FeatureWebhooks, FeatureAI,
@@ -116,6 +97,10 @@ func RequireFeature(feature Feature) fiber.Handler {
 			})
 		}
 
+		if flag, ok := planFeatureFlags[feature]; ok && !FlagEnabled(flag) {
+			return featureDisabled(c, flag)
+		}
+
 		if !HasFeature(shop.Plan, feature) {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 				"error":   "Feature not available on " + string(shop.Plan) + " plan",
@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SafaricomCallbackIPs are the source addresses Safaricom documents for Daraja callbacks
+var SafaricomCallbackIPs = []string{
+	"196.201.214.200",
+	"196.201.214.206",
+	"196.201.213.114",
+	"196.201.214.207",
+	"196.201.214.208",
+	"196.201.213.44",
+	"196.201.212.127",
+	"196.201.212.138",
+	"196.201.212.129",
+	"196.201.212.136",
+	"196.201.212.74",
+	"196.201.212.69",
+}
+
+// TwilioSignature rejects webhook requests whose X-Twilio-Signature does not
+// match the configured auth token. baseURL is the public scheme and host Twilio
+// calls (e.g. https://dukapos.example.com); when empty the request's own base
+// URL is used. Without an auth token every request is rejected when required
+// is set, as in production, and let through otherwise.
+func TwilioSignature(authToken, baseURL string, required bool) fiber.Handler {
+	if authToken == "" && required {
+		log.Println("⚠️ Twilio webhooks will be rejected: TWILIO_AUTH_TOKEN not set")
+	} else if authToken == "" {
+		log.Println("⚠️ Twilio signature verification disabled: TWILIO_AUTH_TOKEN not set")
+	}
+
+	return func(c *fiber.Ctx) error {
+		if authToken == "" && required {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Webhook verification not configured",
+				"code":  "INVALID_SIGNATURE",
+			})
+		}
+		if authToken == "" {
+			return c.Next()
+		}
+
+		signature := c.Get("X-Twilio-Signature")
+		if signature == "" {
+			log.Printf("⚠️ Rejected Twilio webhook from %s: missing signature", c.IP())
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Missing signature",
+				"code":  "INVALID_SIGNATURE",
+			})
+		}
+
+		base := strings.TrimRight(baseURL, "/")
+		if base == "" {
+			base = c.BaseURL()
+		}
+
+		params := make(map[string]string)
+		c.Request().PostArgs().VisitAll(func(key, value []byte) {
+			params[string(key)] = string(value)
+		})
+
+		expected := TwilioRequestSignature(authToken, base+c.OriginalURL(), params)
+		if !hmac.Equal([]byte(expected), []byte(signature)) {
+			log.Printf("⚠️ Rejected Twilio webhook from %s: invalid signature", c.IP())
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Invalid signature",
+				"code":  "INVALID_SIGNATURE",
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// TwilioRequestSignature computes Twilio's request signature: the full URL
+// followed by every POST parameter name and value sorted by name, signed with
+// HMAC-SHA1 using the auth token and base64 encoded.
+func TwilioRequestSignature(authToken, url string, params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString(url)
+	for _, k := range keys {
+		sb.WriteString(k)
+		sb.WriteString(params[k])
+	}
+
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(sb.String()))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// MpesaCallbackAuth rejects M-Pesa callbacks that do not come from an allowed
+// source. When token is set the callback URL must carry a matching ?token=
+// query parameter; when allowedIPs is non-empty the client IP must match one of
+// the listed addresses or CIDR ranges. The entry "safaricom", the default in
+// the config, expands to SafaricomCallbackIPs. Behind a proxy the client IP
+// is only right once the proxy is in TRUSTED_PROXIES.
+func MpesaCallbackAuth(allowedIPs []string, token string) fiber.Handler {
+	allowlist := newIPAllowlist(nil)
+	for _, entry := range allowedIPs {
+		if strings.EqualFold(strings.TrimSpace(entry), "safaricom") {
+			for _, ip := range SafaricomCallbackIPs {
+				allowlist.add(ip)
+			}
+			continue
+		}
+		allowlist.add(entry)
+	}
+
+	if token == "" && allowlist.empty() {
+		log.Println("⚠️ M-Pesa callback verification disabled: set MPESA_CALLBACK_TOKEN or MPESA_CALLBACK_ALLOWED_IPS")
+	}
+
+	return func(c *fiber.Ctx) error {
+		if token != "" && subtle.ConstantTimeCompare([]byte(c.Query("token")), []byte(token)) != 1 {
+			log.Printf("⚠️ Rejected M-Pesa callback %s from %s: invalid token", c.Path(), c.IP())
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Invalid callback token",
+				"code":  "INVALID_CALLBACK_SOURCE",
+			})
+		}
+
+		if !allowlist.empty() && !allowlist.contains(c.IP()) {
+			log.Printf("⚠️ Rejected M-Pesa callback %s from %s: source not allowed", c.Path(), c.IP())
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Callback source not allowed",
+				"code":  "INVALID_CALLBACK_SOURCE",
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// USSDGatewayAuth only lets through USSD requests from the gateway: the URL
+// must carry a matching ?token= when token is set, and the client IP must be
+// in allowedIPs when that is non-empty. The caller's phone number comes from
+// the request body, so with neither set every request is rejected.
+func USSDGatewayAuth(allowedIPs []string, token string) fiber.Handler {
+	allowlist := newIPAllowlist(allowedIPs)
+	if token == "" && allowlist.empty() {
+		log.Println("⚠️ USSD requests will be rejected: set USSD_GATEWAY_TOKEN or USSD_ALLOWED_IPS")
+	}
+
+	return func(c *fiber.Ctx) error {
+		if token == "" && allowlist.empty() {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "USSD gateway not configured",
+				"code":  "INVALID_CALLBACK_SOURCE",
+			})
+		}
+
+		if token != "" && subtle.ConstantTimeCompare([]byte(c.Query("token")), []byte(token)) != 1 {
+			log.Printf("⚠️ Rejected USSD request %s from %s: invalid token", c.Path(), c.IP())
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Invalid gateway token",
+				"code":  "INVALID_CALLBACK_SOURCE",
+			})
+		}
+
+		if !allowlist.empty() && !allowlist.contains(c.IP()) {
+			log.Printf("⚠️ Rejected USSD request %s from %s: source not allowed", c.Path(), c.IP())
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Gateway source not allowed",
+				"code":  "INVALID_CALLBACK_SOURCE",
+			})
+		}
+
+		return c.Next()
+	}
+}
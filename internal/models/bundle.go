@@ -0,0 +1,86 @@
+package models
+
+import (
+	"fmt"
+	"math"
+)
+
+// BundleCost is what a bundle's components cost the shop, per bundle
+func BundleCost(components []ProductBundle) float64 {
+	cost := 0.0
+	for _, comp := range components {
+		cost += comp.Component.CostPrice * float64(comp.Quantity)
+	}
+	return cost
+}
+
+// BundleAvailable is how many bundles the components' stock can make
+func BundleAvailable(components []ProductBundle) int {
+	available := -1
+	for _, comp := range components {
+		if comp.Quantity <= 0 {
+			continue
+		}
+		if n := comp.Component.CurrentStock / comp.Quantity; available < 0 || n < available {
+			available = n
+		}
+	}
+	return max(available, 0)
+}
+
+// BundleShortage returns the first component without stock for quantity
+// bundles and how many of it are needed, or nil if every component has
+// enough
+func BundleShortage(components []ProductBundle, quantity int) (*ProductBundle, int) {
+	for i, comp := range components {
+		if needed := comp.Quantity * quantity; comp.Component.CurrentStock < needed {
+			return &components[i], needed
+		}
+	}
+	return nil, 0
+}
+
+// BuildBundleSales splits a bundle sale into one sale per component. The total
+// is shared in proportion to each component's selling value (falling back to
+// its quantity when components are unpriced); the last component absorbs
+// rounding so the parts add up to the total.
+func BuildBundleSales(bundle *Product, components []ProductBundle, quantity int, total float64, method PaymentMethod) []*Sale {
+	weights := make([]float64, len(components))
+	sum := 0.0
+	for i, comp := range components {
+		weights[i] = comp.Component.SellingPrice * float64(comp.Quantity)
+		sum += weights[i]
+	}
+	if sum == 0 {
+		for i, comp := range components {
+			weights[i] = float64(comp.Quantity)
+			sum += weights[i]
+		}
+	}
+
+	sales := make([]*Sale, 0, len(components))
+	allocated := 0.0
+	for i, comp := range components {
+		qty := comp.Quantity * quantity
+
+		share := math.Round(total*weights[i]/sum*100) / 100
+		if i == len(components)-1 {
+			share = math.Round((total-allocated)*100) / 100
+		}
+		allocated += share
+
+		cost := comp.Component.CostPrice * float64(qty)
+		sales = append(sales, &Sale{
+			ShopID:        bundle.ShopID,
+			ProductID:     comp.ComponentProductID,
+			Quantity:      qty,
+			UnitPrice:     share / float64(qty),
+			TotalAmount:   share,
+			CostAmount:    cost,
+			Profit:        share - cost,
+			PaymentMethod: method,
+			Notes:         fmt.Sprintf("Bundle: %s", bundle.Name),
+		})
+	}
+	return sales
+}
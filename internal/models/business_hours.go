@@ -0,0 +1,233 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // shop time zones load on hosts without zoneinfo
+)
+
+// DefaultTimezone is the zone of shops that have not set one
+const DefaultTimezone = "Africa/Nairobi"
+
+// ValidTimezone reports whether name is an IANA zone shops can be in
+func ValidTimezone(name string) bool {
+	_, err := time.LoadLocation(name)
+	return err == nil && name != "" && name != "Local"
+}
+
+// Location returns the zone the shop's business hours are in
+func (s *Shop) Location() *time.Location {
+	name := s.Timezone
+	if name == "" {
+		name = DefaultTimezone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.FixedZone("EAT", 3*60*60)
+	}
+	return loc
+}
+
+// DayHours is when a shop opens and closes on one day, in 24-hour "15:04"
+// shop time. Close must be after Open; "24:00" closes at midnight.
+type DayHours struct {
+	Open  string `json:"open"`
+	Close string `json:"close"`
+}
+
+// BusinessHours maps a day ("mon" to "sun") to the shop's hours that day.
+// Days left out are closed; a shop with no hours at all is always open.
+type BusinessHours map[string]DayHours
+
+// weekdayKeys are the BusinessHours keys indexed by time.Weekday
+var weekdayKeys = [7]string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// ParseDays accepts a day ("mon", "monday"), a range ("mon-fri"), a comma
+// separated list ("sat,sun") or "daily", and returns the days in order
+func ParseDays(s string) ([]time.Weekday, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "daily", "all", "everyday":
+		return []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday}, true
+	case "weekdays":
+		s = "mon-fri"
+	case "weekend", "weekends":
+		s = "sat,sun"
+	}
+
+	var days []time.Weekday
+	for _, part := range strings.Split(s, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		first, ok := parseWeekday(from)
+		if !ok {
+			return nil, false
+		}
+		last := first
+		if isRange {
+			if last, ok = parseWeekday(to); !ok {
+				return nil, false
+			}
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			days = append(days, d)
+			if d == last {
+				break
+			}
+		}
+	}
+	return days, true
+}
+
+func parseWeekday(s string) (time.Weekday, bool) {
+	if len(s) < 3 {
+		return 0, false
+	}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.HasPrefix(strings.ToLower(d.String()), s) {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// ParseOpenClose parses "08:00-18:00" into a day's hours
+func ParseOpenClose(s string) (DayHours, error) {
+	open, closing, ok := strings.Cut(s, "-")
+	if !ok {
+		return DayHours{}, fmt.Errorf("hours must look like 08:00-18:00")
+	}
+	hours := DayHours{Open: normalizeClock(open), Close: normalizeClock(closing)}
+	return hours, hours.Validate()
+}
+
+// Validate checks both times parse and the shop closes after it opens
+func (d DayHours) Validate() error {
+	open, ok := clockMinutes(d.Open)
+	if !ok || open >= 24*60 {
+		return fmt.Errorf("invalid opening time %q", d.Open)
+	}
+	closing, ok := clockMinutes(d.Close)
+	if !ok {
+		return fmt.Errorf("invalid closing time %q", d.Close)
+	}
+	if closing <= open {
+		return fmt.Errorf("closing time %s must be after opening time %s", d.Close, d.Open)
+	}
+	return nil
+}
+
+// Validate checks every day key and its hours
+func (h BusinessHours) Validate() error {
+	for day, hours := range h {
+		if !isWeekdayKey(day) {
+			return fmt.Errorf("unknown day %q, use mon to sun", day)
+		}
+		if err := hours.Validate(); err != nil {
+			return fmt.Errorf("%s: %w", day, err)
+		}
+	}
+	return nil
+}
+
+// Set sets the hours for days, replacing what was there
+func (h BusinessHours) Set(days []time.Weekday, hours DayHours) {
+	for _, d := range days {
+		h[weekdayKeys[d]] = hours
+	}
+}
+
+// Close marks days as closed
+func (h BusinessHours) Close(days []time.Weekday) {
+	for _, d := range days {
+		delete(h, weekdayKeys[d])
+	}
+}
+
+// IsOpen reports whether t falls within the hours for its day, both in
+// t's zone, so t should be in the shop's Location. Shops without hours are
+// always open.
+func (h BusinessHours) IsOpen(t time.Time) bool {
+	if len(h) == 0 {
+		return true
+	}
+	hours, ok := h[weekdayKeys[t.Weekday()]]
+	if !ok {
+		return false
+	}
+	open, _ := clockMinutes(hours.Open)
+	closing, _ := clockMinutes(hours.Close)
+	now := t.Hour()*60 + t.Minute()
+	return now >= open && now < closing
+}
+
+// NextOpening returns when the shop next opens after t, looking a week ahead
+func (h BusinessHours) NextOpening(t time.Time) (time.Time, bool) {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for i := 0; i <= 7; i++ {
+		day := midnight.AddDate(0, 0, i)
+		hours, ok := h[weekdayKeys[day.Weekday()]]
+		if !ok {
+			continue
+		}
+		open, ok := clockMinutes(hours.Open)
+		if !ok {
+			continue
+		}
+		if at := day.Add(time.Duration(open) * time.Minute); at.After(t) {
+			return at, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// String lists the hours from Monday to Sunday, one day per line
+func (h BusinessHours) String() string {
+	var b strings.Builder
+	for i := 1; i <= 7; i++ {
+		d := time.Weekday(i % 7)
+		hours, ok := h[weekdayKeys[d]]
+		if !ok {
+			fmt.Fprintf(&b, "%s: closed\n", d.String()[:3])
+			continue
+		}
+		fmt.Fprintf(&b, "%s: %s-%s\n", d.String()[:3], hours.Open, hours.Close)
+	}
+	return b.String()
+}
+
+func isWeekdayKey(day string) bool {
+	for _, key := range weekdayKeys {
+		if key == day {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeClock pads "8:00" to "08:00" so stored times compare and read alike
+func normalizeClock(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) == 4 && s[1] == ':' {
+		return "0" + s
+	}
+	return s
+}
+
+// clockMinutes parses "15:04" into minutes after midnight, allowing "24:00"
+func clockMinutes(s string) (int, bool) {
+	hh, mm, ok := strings.Cut(s, ":")
+	if !ok || len(mm) != 2 {
+		return 0, false
+	}
+	hour, err := strconv.Atoi(hh)
+	if err != nil || hour < 0 || hour > 24 {
+		return 0, false
+	}
+	minute, err := strconv.Atoi(mm)
+	if err != nil || minute < 0 || minute > 59 || (hour == 24 && minute != 0) {
+		return 0, false
+	}
+	return hour*60 + minute, true
+}
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// DayClose is a shop's end-of-day close, the totals of its Z-report. Sales
+// after a close count toward the next business day.
+type DayClose struct {
+	ID           uint                 `gorm:"primaryKey" json:"id"`
+	ShopID       uint                 `gorm:"uniqueIndex:idx_day_closes_shop_number;not null" json:"shop_id"`
+	Number       int                  `gorm:"uniqueIndex:idx_day_closes_shop_number;not null" json:"number"` // Z number, counting up per shop
+	BusinessDate time.Time            `gorm:"type:date;not null" json:"business_date"`                       // the day the close is for
+	PeriodStart  time.Time            `gorm:"not null" json:"period_start"`
+	PeriodEnd    time.Time            `gorm:"not null" json:"period_end"` // when the day was closed
+	Transactions int                  `gorm:"default:0" json:"transactions"`
+	CashSales    float64              `gorm:"type:decimal(12,2);default:0" json:"cash_sales"`
+	MpesaSales   float64              `gorm:"type:decimal(12,2);default:0" json:"mpesa_sales"`
+	OtherSales   float64              `gorm:"type:decimal(12,2);default:0" json:"other_sales"` // card and bank
+	GrossSales   float64              `gorm:"type:decimal(12,2);default:0" json:"gross_sales"`
+	Refunds      float64              `gorm:"type:decimal(12,2);default:0" json:"refunds"` // M-Pesa payments reversed
+	RefundCount  int                  `gorm:"default:0" json:"refund_count"`
+	Discounts    float64              `gorm:"type:decimal(12,2);default:0" json:"discounts"` // loyalty points redeemed, already off the sales
+	Expenses     float64              `gorm:"type:decimal(12,2);default:0" json:"expenses"`
+	CashExpenses float64              `gorm:"type:decimal(12,2);default:0" json:"cash_expenses"` // paid out of the drawer
+	OpeningFloat float64              `gorm:"type:decimal(12,2);default:0" json:"opening_float"`
+	ExpectedCash float64              `gorm:"type:decimal(12,2);default:0" json:"expected_cash"`
+	CountedCash  *float64             `gorm:"type:decimal(12,2)" json:"counted_cash,omitempty"` // what was in the drawer, when counted
+	ByStaff      []StaffSalesTotal    `gorm:"type:text;serializer:json" json:"by_staff"`
+	ByCategory   []CategorySalesTotal `gorm:"type:text;serializer:json" json:"by_category"`
+	ClosedBy     string               `gorm:"size:100" json:"closed_by"`
+	CreatedAt    time.Time            `json:"created_at"`
+}
+
+// StaffSalesTotal is what one staff member sold in a Z-report period;
+// sales without a staff member are the owner's
+type StaffSalesTotal struct {
+	StaffID      *uint   `json:"staff_id"`
+	Name         string  `json:"name"`
+	Transactions int     `json:"transactions"`
+	CashSales    float64 `json:"cash_sales"`
+	MpesaSales   float64 `json:"mpesa_sales"`
+	OtherSales   float64 `json:"other_sales"`
+	Total        float64 `json:"total"`
+}
+
+// CategorySalesTotal is what one product category sold in a Z-report period
+type CategorySalesTotal struct {
+	Category string  `json:"category"`
+	Quantity int     `json:"quantity"`
+	Total    float64 `json:"total"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Email template keys
+const (
+	EmailTemplateReceipt       = "receipt"
+	EmailTemplateDailyReport   = "daily_report"
+	EmailTemplateLowStock      = "low_stock"
+	EmailTemplateWelcome       = "welcome"
+	EmailTemplatePasswordReset = "password_reset"
+	EmailTemplatePurchaseOrder = "purchase_order"
+	EmailTemplateLoginAlert    = "login_alert"
+)
+
+// EmailTemplateKeys lists every template a shop can customise
+var EmailTemplateKeys = []string{
+	EmailTemplateReceipt,
+	EmailTemplateDailyReport,
+	EmailTemplateLowStock,
+	EmailTemplateWelcome,
+	EmailTemplatePasswordReset,
+	EmailTemplatePurchaseOrder,
+	EmailTemplateLoginAlert,
+}
+
+// EmailTemplate is the subject and body of an email, with {{variable}}
+// placeholders. ShopID 0 holds the platform defaults a shop can override.
+type EmailTemplate struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ShopID    uint      `gorm:"uniqueIndex:idx_email_template_shop_key;not null" json:"shop_id"`
+	Key       string    `gorm:"size:50;uniqueIndex:idx_email_template_shop_key;not null" json:"key"`
+	Subject   string    `gorm:"size:255;not null" json:"subject"`
+	HTML      string    `gorm:"type:text;not null" json:"html"`
+	Text      string    `gorm:"type:text" json:"text"` // plain text part; empty derives it from HTML
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
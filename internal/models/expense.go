@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Expense is money a shop spent running the business, such as rent,
+// transport or airtime, taken off its profit along with the cost of goods
+type Expense struct {
+	ID                 uint           `gorm:"primaryKey" json:"id"`
+	ShopID             uint           `gorm:"index;not null" json:"shop_id"`
+	Amount             float64        `gorm:"type:decimal(12,2);not null" json:"amount"`
+	Category           string         `gorm:"size:50;not null" json:"category"`
+	Note               string         `gorm:"size:255" json:"note"`
+	PaymentMethod      PaymentMethod  `gorm:"size:20;default:cash" json:"payment_method"` // cash comes out of the drawer
+	SpentAt            time.Time      `gorm:"index;not null" json:"spent_at"`
+	RecurringExpenseID *uint          `gorm:"index" json:"recurring_expense_id,omitempty"` // recorded by a recurring expense
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// How often a recurring expense is recorded
+const (
+	ExpenseDaily   = "daily"
+	ExpenseWeekly  = "weekly"
+	ExpenseMonthly = "monthly"
+)
+
+// ValidExpenseFrequency reports whether frequency is one recurring
+// expenses can have
+func ValidExpenseFrequency(frequency string) bool {
+	return frequency == ExpenseDaily || frequency == ExpenseWeekly || frequency == ExpenseMonthly
+}
+
+// RecurringExpense is an expense the scheduler records for the shop every
+// day, week or month, such as rent
+type RecurringExpense struct {
+	ID            uint          `gorm:"primaryKey" json:"id"`
+	ShopID        uint          `gorm:"index;not null" json:"shop_id"`
+	Amount        float64       `gorm:"type:decimal(12,2);not null" json:"amount"`
+	Category      string        `gorm:"size:50;not null" json:"category"`
+	Note          string        `gorm:"size:255" json:"note"`
+	PaymentMethod PaymentMethod `gorm:"size:20;default:cash" json:"payment_method"`
+	Frequency     string        `gorm:"size:10;not null" json:"frequency"` // daily, weekly or monthly
+	NextDueAt     time.Time     `gorm:"index;not null" json:"next_due_at"`
+	DayOfMonth    int           `gorm:"default:0" json:"day_of_month,omitempty"` // monthly: the day it falls on
+	IsActive      bool          `gorm:"default:true" json:"is_active"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+}
+
+// After returns when an expense recorded at t is next due. Monthly
+// expenses stay on their DayOfMonth, or the month's last day in months
+// too short for it, so one from the 31st falls on Feb 28 and then Mar 31.
+func (r *RecurringExpense) After(t time.Time) time.Time {
+	switch r.Frequency {
+	case ExpenseDaily:
+		return t.AddDate(0, 0, 1)
+	case ExpenseWeekly:
+		return t.AddDate(0, 0, 7)
+	default:
+		day := r.DayOfMonth
+		if day == 0 {
+			day = t.Day()
+		}
+		first := time.Date(t.Year(), t.Month()+1, 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+		if last := first.AddDate(0, 1, -1).Day(); day > last {
+			day = last
+		}
+		return first.AddDate(0, 0, day-1)
+	}
+}
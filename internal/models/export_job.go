@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Export job types
+const (
+	ExportJobSales    = "sales"
+	ExportJobProducts = "products"
+)
+
+// Export job statuses. A done job's file can be downloaded until it
+// expires, when the file is deleted.
+const (
+	ExportJobStatusQueued  = "queued"
+	ExportJobStatusRunning = "running"
+	ExportJobStatusDone    = "done"
+	ExportJobStatusFailed  = "failed"
+	ExportJobStatusExpired = "expired"
+)
+
+// ExportJob is an export too large to build within a request, written to a
+// file in the background. The period bounds the sales exported, or when the
+// products were added; without one every product is exported.
+type ExportJob struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	ShopID        uint       `gorm:"index;not null" json:"shop_id"`
+	Type          string     `gorm:"size:20;not null" json:"type"`
+	Format        string     `gorm:"size:10;not null" json:"format"`
+	PeriodStart   *time.Time `json:"period_start,omitempty"`
+	PeriodEnd     *time.Time `json:"period_end,omitempty"` // exclusive
+	PaymentMethod string     `gorm:"size:20" json:"payment_method,omitempty"`
+	ProductID     uint       `json:"product_id,omitempty"`
+	Category      string     `gorm:"size:100" json:"category,omitempty"`
+	Status        string     `gorm:"size:20;index;default:queued" json:"status"`
+	Error         string     `gorm:"size:500" json:"error,omitempty"`
+	Rows          int        `gorm:"default:0" json:"rows"`
+	Size          int64      `gorm:"default:0" json:"size"`
+	Filename      string     `gorm:"size:150" json:"filename,omitempty"`
+	FilePath      string     `gorm:"size:255" json:"-"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+	ExpiresAt     *time.Time `gorm:"index" json:"expires_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Runtime feature flags. The FEATURE_*_ENABLED settings are only their
+// defaults until an admin sets them.
+const (
+	FeatureFlagMpesa         = "mpesa"
+	FeatureFlagAnalytics     = "analytics"
+	FeatureFlagWebDashboard  = "web_dashboard"
+	FeatureFlagMultipleShops = "multiple_shops"
+	FeatureFlagStaffAccounts = "staff_accounts"
+)
+
+// FeatureFlag switches a feature on or off for every shop without a restart
+type FeatureFlag struct {
+	Name      string    `gorm:"primaryKey;size:50" json:"name"`
+	Enabled   bool      `gorm:"not null" json:"enabled"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// InventorySnapshot records a shop's stock value at the end of a day
+type InventorySnapshot struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ShopID    uint      `gorm:"uniqueIndex:idx_inventory_snapshot_day;not null" json:"shop_id"`
+	Date      time.Time `gorm:"type:date;uniqueIndex:idx_inventory_snapshot_day;not null" json:"date"`
+	Value     float64   `gorm:"type:decimal(14,2);default:0" json:"value"`      // at selling price
+	CostValue float64   `gorm:"type:decimal(14,2);default:0" json:"cost_value"` // at cost price
+	Units     int       `gorm:"default:0" json:"units"`
+	Products  int       `gorm:"default:0" json:"products"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (s *InventorySnapshot) TableName() string {
+	return "inventory_snapshots"
+}
+
+// ClosingStockSnapshot records one product's stock and prices at the close
+// of a month, for accountants' month-end inventory valuation
+type ClosingStockSnapshot struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	ShopID       uint      `gorm:"uniqueIndex:idx_closing_stock_product_day;not null" json:"shop_id"`
+	ProductID    uint      `gorm:"uniqueIndex:idx_closing_stock_product_day;not null" json:"product_id"`
+	ProductName  string    `gorm:"size:255" json:"product_name"`
+	ClosingStock int       `gorm:"not null" json:"closing_stock"`
+	CostPrice    float64   `gorm:"type:decimal(12,2);default:0" json:"cost_price"`
+	SellingPrice float64   `gorm:"type:decimal(12,2);default:0" json:"selling_price"`
+	SnapshotDate time.Time `gorm:"type:date;uniqueIndex:idx_closing_stock_product_day;not null" json:"snapshot_date"` // last day of the month
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Invoice bills one period of an account's subscription plan
+type Invoice struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	AccountID    uint       `gorm:"index;not null" json:"account_id"`
+	ShopID       uint       `gorm:"index" json:"shop_id"` // shop the plan was bought from
+	PaymentID    *uint      `gorm:"uniqueIndex" json:"payment_id"`
+	Number       string     `gorm:"size:30;index" json:"number"`
+	Amount       float64    `gorm:"type:decimal(12,2);not null" json:"amount"`
+	Currency     string     `gorm:"size:3;default:KES" json:"currency"`
+	Plan         PlanType   `gorm:"size:20;not null" json:"plan"`
+	PeriodStart  time.Time  `json:"period_start"`
+	PeriodEnd    time.Time  `json:"period_end"`
+	PaidAt       *time.Time `json:"paid_at"`
+	MpesaReceipt string     `gorm:"size:50" json:"mpesa_receipt"`
+	PDFURL       string     `gorm:"size:255" json:"-"` // where the stored PDF was saved
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+
+	// DownloadURL is the authenticated route that serves the PDF
+	DownloadURL string `gorm:"-" json:"download_url,omitempty"`
+}
+
+func (i *Invoice) TableName() string {
+	return "invoices"
+}
+
+// InvoiceNumber formats an invoice's number from its ID and issue date
+func InvoiceNumber(id uint, issued time.Time) string {
+	return fmt.Sprintf("INV-%d-%06d", issued.Year(), id)
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// How a shop logged in
+const (
+	LoginMethodPassword = "password"
+	LoginMethodOTP      = "otp"
+)
+
+// LoginEvent is an attempt to log in to a shop, kept so the owner can see
+// where their account is being used from
+type LoginEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	AccountID uint      `gorm:"index" json:"account_id"` // 0 for shops without an account
+	ShopID    uint      `gorm:"index;not null" json:"shop_id"`
+	IPAddress string    `gorm:"size:45" json:"ip_address"`
+	UserAgent string    `gorm:"size:255" json:"user_agent"`
+	Country   string    `gorm:"size:2" json:"country"` // ISO code from LOGIN_COUNTRY_HEADER; empty when unknown
+	Method    string    `gorm:"size:20;not null" json:"method"`
+	Success   bool      `gorm:"not null;default:false" json:"success"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
@@ -40,6 +40,9 @@ type Customer struct {
 	UpdatedAt      time.Time      `json:"updated_at"`
 	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
 
+	// Set when the customer asks to stop marketing texts; campaigns skip them
+	SMSOptOut bool `gorm:"default:false" json:"sms_opt_out"`
+
 	Shop         Shop                 `gorm:"foreignKey:ShopID" json:"shop,omitempty"`
 	Transactions []LoyaltyTransaction `gorm:"foreignKey:CustomerID" json:"transactions,omitempty"`
 }
@@ -76,6 +79,8 @@ const (
 	LoyaltyBonus    LoyaltyTransactionType = "bonus"
 	LoyaltyRefund   LoyaltyTransactionType = "refund"
 	LoyaltyAdjust   LoyaltyTransactionType = "adjustment"
+
+	LoyaltyBirthdayBonus LoyaltyTransactionType = "birthday_bonus"
 )
 
 type LoyaltyTransaction struct {
@@ -49,21 +49,34 @@ type Account struct {
 
 // Shop represents a duka/kiosk
 type Shop struct {
-	ID             uint           `gorm:"primaryKey" json:"id"`
-	AccountID      uint           `gorm:"index;not null" json:"account_id"`
-	Name           string         `gorm:"size:255;not null" json:"name"`
-	Phone          string         `gorm:"size:20;uniqueIndex;not null" json:"phone"`
-	OwnerName      string         `gorm:"size:100" json:"owner_name"`
-	Address        string         `gorm:"size:255" json:"address"`
-	Plan           PlanType       `gorm:"size:20;default:free" json:"plan"`
-	MpesaShortcode string         `gorm:"size:20" json:"mpesa_shortcode"`
-	MpesaPartnerID string         `gorm:"size:50" json:"mpesa_partner_id"`
-	IsActive       bool           `gorm:"default:true" json:"is_active"`
-	Email          string         `gorm:"size:100" json:"email"`
-	PasswordHash   string         `gorm:"size:255" json:"-"`
-	CreatedAt      time.Time      `json:"created_at"`
-	UpdatedAt      time.Time      `json:"updated_at"`
-	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                      uint           `gorm:"primaryKey" json:"id"`
+	AccountID               uint           `gorm:"index;not null" json:"account_id"`
+	Name                    string         `gorm:"size:255;not null" json:"name"`
+	Phone                   string         `gorm:"size:20;uniqueIndex;not null" json:"phone"`
+	OwnerName               string         `gorm:"size:100" json:"owner_name"`
+	Address                 string         `gorm:"size:255" json:"address"`
+	Plan                    PlanType       `gorm:"size:20;default:free" json:"plan"`
+	MpesaShortcode          string         `gorm:"size:20" json:"mpesa_shortcode"`
+	MpesaPartnerID          string         `gorm:"size:50" json:"mpesa_partner_id"`
+	IsActive                bool           `gorm:"default:true" json:"is_active"`
+	Language                string         `gorm:"size:5;default:en" json:"language"`               // WhatsApp reply language: en, sw
+	FeaturePhone            bool           `gorm:"default:false" json:"feature_phone"`              // WhatsApp menus as numbered options
+	Rounding                RoundingPolicy `gorm:"size:20;default:none" json:"rounding"`            // cash total rounding: none, nearest_1, nearest_5
+	AutoDeactivateZeroStock bool           `gorm:"default:false" json:"auto_deactivate_zero_stock"` // hide products that sell out
+	AllowNegativeStock      bool           `gorm:"default:false" json:"allow_negative_stock"`       // record sales past zero stock, for stock not yet entered
+	SMSReceipts             bool           `gorm:"default:false" json:"sms_receipts"`               // text customers a receipt after M-Pesa sales
+	LowStockChannel         string         `gorm:"size:10" json:"low_stock_channel"`                // low stock alerts: whatsapp (default) or sms
+	BirthdayBonusPoints     int            `gorm:"default:0" json:"birthday_bonus_points"`          // loyalty points given on a customer's birthday, 0 for none
+	Email                   string         `gorm:"size:100" json:"email"`
+	PasswordHash            string         `gorm:"size:255" json:"-"`
+	CreatedAt               time.Time      `json:"created_at"`
+	UpdatedAt               time.Time      `json:"updated_at"`
+	DeletedAt               gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// USSD access; the PIN is asked at the start of every session
+	USSDPinHash        string     `gorm:"size:255" json:"-"`
+	USSDPinFailures    int        `gorm:"default:0" json:"-"` // wrong PINs in a row
+	USSDPinLockedUntil *time.Time `json:"-"`
 
 	// White Label Branding
 	BrandName           string `gorm:"size:100" json:"brand_name"`
@@ -77,6 +90,18 @@ type Shop struct {
 	InvoiceFooter       string `gorm:"size:500" json:"invoice_footer"`
 	ReceiptHeader       string `gorm:"size:255" json:"receipt_header"`
 	ReceiptFooter       string `gorm:"size:500" json:"receipt_footer"`
+	ReceiptLogo         string `gorm:"size:255" json:"receipt_logo"` // URL of the logo printed on receipts
+	ReceiptContact      string `gorm:"size:255" json:"receipt_contact"`
+	VATNumber           string `gorm:"size:30" json:"vat_number"`            // KRA PIN printed on receipts
+	ReceiptShowQR       bool   `gorm:"default:false" json:"receipt_show_qr"` // QR code on receipts linking to the digital receipt
+	ReceiptCurrency     string `gorm:"size:10" json:"receipt_currency"`      // symbol amounts are printed with; KSh when empty
+
+	// WhatsApp out-of-hours auto-reply; {open} in ClosedMessage becomes the
+	// next opening time. The hours are in Timezone, an IANA zone name, or
+	// East Africa Time when it is empty.
+	BusinessHours BusinessHours `gorm:"type:text;serializer:json" json:"business_hours"`
+	ClosedMessage string        `gorm:"size:500" json:"closed_message"`
+	Timezone      string        `gorm:"size:50" json:"timezone"`
 
 	// Relations
 	Account  Account   `gorm:"foreignKey:AccountID" json:"account,omitempty"`
@@ -101,35 +126,139 @@ type Product struct {
 	LowStockThreshold int            `gorm:"default:10" json:"low_stock_threshold"`
 	Barcode           string         `gorm:"size:50" json:"barcode"`
 	ImageURL          string         `gorm:"size:255" json:"image_url"`
+	ThumbnailURL      string         `gorm:"size:255" json:"thumbnail_url"`
 	IsActive          bool           `gorm:"default:true" json:"is_active"`
+	IsBundle          bool           `gorm:"default:false" json:"is_bundle"`        // virtual product sold as its components
+	AutoDeactivated   bool           `gorm:"default:false" json:"auto_deactivated"` // hidden for selling out, back on restock
 	CreatedAt         time.Time      `json:"created_at"`
 	UpdatedAt         time.Time      `json:"updated_at"`
 	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
 
+	// Bulk unit the product is bought in, e.g. a crate of 24. Stock and
+	// prices are always kept in Unit, the base unit it is sold in.
+	PurchaseUnit     string `gorm:"size:20" json:"purchase_unit"`
+	UnitsPerPurchase int    `gorm:"default:1" json:"units_per_purchase"`
+
+	// Low stock as a percentage of MaxStock, the most the shop stocks. When
+	// both are set it takes precedence over LowStockThreshold; see
+	// LowStockLevel.
+	LowStockPercent int `gorm:"default:0" json:"low_stock_percent"`
+	MaxStock        int `gorm:"default:0" json:"max_stock"`
+
 	// Relations
 	Shop  Shop   `gorm:"foreignKey:ShopID" json:"shop,omitempty"`
 	Sales []Sale `gorm:"foreignKey:ProductID" json:"sales,omitempty"`
 }
 
+// ProductBundle links a bundle product to one of its components. Bundle
+// products hold no stock; selling one deducts each component.
+type ProductBundle struct {
+	ID                 uint      `gorm:"primaryKey" json:"id"`
+	BundleProductID    uint      `gorm:"uniqueIndex:idx_bundle_component;not null" json:"bundle_product_id"`
+	ComponentProductID uint      `gorm:"uniqueIndex:idx_bundle_component;not null" json:"component_product_id"`
+	Quantity           int       `gorm:"not null;default:1" json:"quantity"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+
+	// Relations
+	Component Product `gorm:"foreignKey:ComponentProductID" json:"component,omitempty"`
+}
+
+// ProductAlias is another name a shop uses for a product in WhatsApp
+// commands, e.g. "cocacola" for "Coca-Cola 500ml". Aliases are stored in
+// lower case and are unique within a shop.
+type ProductAlias struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ShopID    uint      `gorm:"uniqueIndex:idx_product_alias;not null" json:"shop_id"`
+	ProductID uint      `gorm:"index;not null" json:"product_id"`
+	Alias     string    `gorm:"size:100;uniqueIndex:idx_product_alias;not null" json:"alias"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FavoriteProduct is a product a shop has pinned for quick selling by its
+// number, so `sell 1 3` sells three of the first favorite. Favorites are
+// numbered in the order they were added.
+type FavoriteProduct struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ShopID    uint      `gorm:"uniqueIndex:idx_favorite_product;not null" json:"shop_id"`
+	ProductID uint      `gorm:"uniqueIndex:idx_favorite_product;not null" json:"product_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PriceSource identifies where a price change was made
+type PriceSource string
+
+const (
+	PriceSourceWhatsApp  PriceSource = "whatsapp"
+	PriceSourceAPI       PriceSource = "api"
+	PriceSourceDashboard PriceSource = "dashboard"
+	PriceSourceSystem    PriceSource = "system"
+)
+
+// PriceHistory records one change to a product's selling or cost price.
+// OldPrice and NewPrice are the selling price.
+type PriceHistory struct {
+	ID        uint        `gorm:"primaryKey" json:"id"`
+	ShopID    uint        `gorm:"index;not null" json:"shop_id"`
+	ProductID uint        `gorm:"index;not null" json:"product_id"`
+	OldPrice  float64     `gorm:"type:decimal(12,2);not null" json:"old_price"`
+	NewPrice  float64     `gorm:"type:decimal(12,2);not null" json:"new_price"`
+	Source    PriceSource `gorm:"size:20;not null" json:"source"`
+	CreatedAt time.Time   `gorm:"index" json:"created_at"`
+
+	OldCostPrice  float64 `gorm:"type:decimal(12,2);not null;default:0" json:"old_cost_price"`
+	NewCostPrice  float64 `gorm:"type:decimal(12,2);not null;default:0" json:"new_cost_price"`
+	ChangedByType string  `gorm:"size:20" json:"changed_by_type"` // shop, api_key or system
+	ChangedByID   uint    `json:"changed_by_id"`
+}
+
+// PriceChangedBy identifies who changed a price; the zero value is the system
+type PriceChangedBy struct {
+	Type string
+	ID   uint
+}
+
+// ChangedByShop is a price change made by the shop owner
+func ChangedByShop(shopID uint) PriceChangedBy {
+	return PriceChangedBy{Type: "shop", ID: shopID}
+}
+
+// Category represents a product category; categories nest via ParentCategoryID
+// (e.g. Beverages > Dairy > Milk). Products reference categories by name.
+type Category struct {
+	ID               uint           `gorm:"primaryKey" json:"id"`
+	ShopID           uint           `gorm:"uniqueIndex:idx_category_shop_name;not null" json:"shop_id"`
+	Name             string         `gorm:"size:50;uniqueIndex:idx_category_shop_name;not null" json:"name"`
+	ParentCategoryID *uint          `gorm:"index" json:"parent_category_id"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Children is populated when building the category tree
+	Children []Category `gorm:"-" json:"children,omitempty"`
+}
+
 // Sale represents a transaction
 type Sale struct {
-	ID            uint           `gorm:"primaryKey" json:"id"`
-	ShopID        uint           `gorm:"index;not null" json:"shop_id"`
-	ProductID     uint           `gorm:"index;not null" json:"product_id"`
-	CustomerID    *uint          `gorm:"index" json:"customer_id"`
-	Quantity      int            `gorm:"not null" json:"quantity"`
-	UnitPrice     float64        `gorm:"type:decimal(12,2);not null" json:"unit_price"`
-	TotalAmount   float64        `gorm:"type:decimal(12,2);not null" json:"total_amount"`
-	CostAmount    float64        `gorm:"type:decimal(12,2);default:0" json:"cost_amount"`
-	Profit        float64        `gorm:"type:decimal(12,2);default:0" json:"profit"`
-	PaymentMethod PaymentMethod  `gorm:"size:20;default:cash" json:"payment_method"`
-	MpesaReceipt  string         `gorm:"size:50" json:"mpesa_receipt"`
-	MpesaPhone    string         `gorm:"size:20" json:"mpesa_phone"`
-	StaffID       *uint          `json:"staff_id"`
-	Notes         string         `gorm:"size:255" json:"notes"`
-	CreatedAt     time.Time      `json:"created_at"`
-	UpdatedAt     time.Time      `json:"updated_at"`
-	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                 uint           `gorm:"primaryKey" json:"id"`
+	ShopID             uint           `gorm:"index;index:idx_sales_shop_created,priority:1;not null" json:"shop_id"`
+	ProductID          uint           `gorm:"index;not null" json:"product_id"`
+	CustomerID         *uint          `gorm:"index" json:"customer_id"`
+	Quantity           int            `gorm:"not null" json:"quantity"`
+	UnitPrice          float64        `gorm:"type:decimal(12,2);not null" json:"unit_price"`
+	TotalAmount        float64        `gorm:"type:decimal(12,2);not null" json:"total_amount"`
+	CostAmount         float64        `gorm:"type:decimal(12,2);default:0" json:"cost_amount"`
+	Profit             float64        `gorm:"type:decimal(12,2);default:0" json:"profit"`
+	PaymentMethod      PaymentMethod  `gorm:"size:20;default:cash" json:"payment_method"`
+	RoundingAdjustment float64        `gorm:"type:decimal(12,2);default:0" json:"rounding_adjustment"` // added to the item total by rounding
+	MpesaReceipt       string         `gorm:"size:50" json:"mpesa_receipt"`
+	MpesaPhone         string         `gorm:"size:20" json:"mpesa_phone"`
+	PendingSaleID      *uint          `gorm:"index" json:"pending_sale_id,omitempty"` // basket the sale was paid for in
+	StaffID            *uint          `json:"staff_id"`
+	Notes              string         `gorm:"size:255" json:"notes"`
+	CreatedAt          time.Time      `gorm:"index:idx_sales_shop_created,priority:2" json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `gorm:"index" json:"-"`
 
 	// Relations
 	Shop     Shop      `gorm:"foreignKey:ShopID" json:"shop,omitempty"`
@@ -154,6 +283,34 @@ type DailySummary struct {
 	Shop Shop `gorm:"foreignKey:ShopID" json:"shop,omitempty"`
 }
 
+// WeeklySummary is the total of a shop's daily summaries for a week,
+// Monday to Sunday
+type WeeklySummary struct {
+	ID                uint      `gorm:"primaryKey" json:"id"`
+	ShopID            uint      `gorm:"uniqueIndex:idx_weekly_summary;not null" json:"shop_id"`
+	WeekStart         time.Time `gorm:"type:date;uniqueIndex:idx_weekly_summary;not null" json:"week_start"`
+	TotalSales        float64   `gorm:"type:decimal(12,2);default:0" json:"total_sales"`
+	TotalTransactions int       `gorm:"default:0" json:"total_transactions"`
+	TotalProfit       float64   `gorm:"type:decimal(12,2);default:0" json:"total_profit"`
+	TotalCost         float64   `gorm:"type:decimal(12,2);default:0" json:"total_cost"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// MonthlySummary is the total of a shop's daily summaries for a calendar
+// month
+type MonthlySummary struct {
+	ID                uint      `gorm:"primaryKey" json:"id"`
+	ShopID            uint      `gorm:"uniqueIndex:idx_monthly_summary;not null" json:"shop_id"`
+	Month             time.Time `gorm:"type:date;uniqueIndex:idx_monthly_summary;not null" json:"month"` // its first day
+	TotalSales        float64   `gorm:"type:decimal(12,2);default:0" json:"total_sales"`
+	TotalTransactions int       `gorm:"default:0" json:"total_transactions"`
+	TotalProfit       float64   `gorm:"type:decimal(12,2);default:0" json:"total_profit"`
+	TotalCost         float64   `gorm:"type:decimal(12,2);default:0" json:"total_cost"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
 // Staff represents staff members
 type Staff struct {
 	ID        uint           `gorm:"primaryKey" json:"id"`
@@ -202,6 +359,16 @@ type Order struct {
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
 
+	// Supplier payment, set when the order is paid with M-Pesa B2C.
+	// AmountPaid adds up the payouts that completed, partial ones included.
+	PaymentStatus string  `gorm:"size:20;default:unpaid" json:"payment_status"`
+	AmountPaid    float64 `gorm:"type:decimal(12,2);default:0" json:"amount_paid"`
+	B2CPayoutID   *uint   `gorm:"index" json:"b2c_payout_id,omitempty"`
+
+	// Purchase order details; SentAt is when it was last sent to the supplier
+	ExpectedDelivery *time.Time `gorm:"type:date" json:"expected_delivery,omitempty"`
+	SentAt           *time.Time `json:"sent_at,omitempty"`
+
 	// Relations
 	Shop     Shop        `gorm:"foreignKey:ShopID" json:"shop,omitempty"`
 	Supplier Supplier    `gorm:"foreignKey:SupplierID" json:"supplier,omitempty"`
@@ -252,6 +419,22 @@ type Webhook struct {
 	Shop Shop `gorm:"foreignKey:ShopID" json:"shop,omitempty"`
 }
 
+// WebhookDelivery records one attempt to deliver an event to a webhook
+type WebhookDelivery struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	WebhookID    uint       `gorm:"index" json:"webhook_id"`
+	EventID      uint       `gorm:"index" json:"event_id"`
+	Event        string     `gorm:"size:50" json:"event"`
+	WebhookURL   string     `gorm:"size:500" json:"webhook_url"`
+	Status       string     `gorm:"size:20;default:pending" json:"status"` // success, failed
+	HTTPStatus   int        `json:"http_status"`
+	ResponseBody string     `gorm:"size:1000" json:"response_body"` // first 512 bytes
+	Error        string     `gorm:"size:500" json:"error"`
+	Attempt      int        `gorm:"default:0" json:"attempt"`
+	DeliveredAt  *time.Time `json:"delivered_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
 // APIKey represents API keys for third-party access
 type APIKey struct {
 	ID          uint       `gorm:"primaryKey" json:"id"`
@@ -309,6 +492,38 @@ func (p *Product) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// StockOnHand is the stock to value. Stock sold past zero in a shop that
+// allows negative stock is still to be entered, not held, so counts as none.
+func (p *Product) StockOnHand() int {
+	if p.CurrentStock < 0 {
+		return 0
+	}
+	return p.CurrentStock
+}
+
+// LowStockLevel is the stock at or below which the product is low. A
+// percentage threshold applies when the product has one and a max stock,
+// rounded up to whole units; otherwise LowStockThreshold does.
+func (p *Product) LowStockLevel() int {
+	if p.LowStockPercent > 0 && p.MaxStock > 0 {
+		return (p.MaxStock*p.LowStockPercent + 99) / 100
+	}
+	return p.LowStockThreshold
+}
+
+// LowStockLevelSQL is LowStockLevel as a SQL expression on products, for
+// finding low stock in queries
+const LowStockLevelSQL = "CASE WHEN low_stock_percent > 0 AND max_stock > 0 " +
+	"THEN (max_stock * low_stock_percent + 99) / 100 ELSE low_stock_threshold END"
+
+// Outstanding is what is left to pay on the order
+func (o *Order) Outstanding() float64 {
+	if left := o.TotalAmount - o.AmountPaid; left > 0 {
+		return left
+	}
+	return 0
+}
+
 // BeforeCreate hook for Sale
 func (s *Sale) BeforeCreate(tx *gorm.DB) error {
 	if s.PaymentMethod == "" {
@@ -16,6 +16,12 @@ const (
 	MpesaPaymentTimeout   MpesaPaymentStatus = "timeout"
 )
 
+// Amount mismatches flagged when a callback reports a different amount paid
+const (
+	MpesaUnderpaid = "underpaid"
+	MpesaOverpaid  = "overpaid"
+)
+
 type MpesaPayment struct {
 	ID                 uint               `gorm:"primaryKey" json:"id"`
 	ShopID             uint               `gorm:"index;not null" json:"shop_id"`
@@ -31,22 +37,56 @@ type MpesaPayment struct {
 	Status             MpesaPaymentStatus `gorm:"size:20;default:pending" json:"status"`
 	FailureReason      string             `gorm:"size:255" json:"failure_reason"`
 	RetryCount         int                `gorm:"default:0" json:"retry_count"`
+	LastAttemptAt      *time.Time         `json:"last_attempt_at"`
 	SaleID             *uint              `gorm:"index" json:"sale_id"`
+	PendingSaleID      *uint              `gorm:"index" json:"pending_sale_id"`  // basket the payment is for
+	PaymentLinkID      *uint              `gorm:"index" json:"payment_link_id"`  // link the customer paid through
+	Plan               PlanType           `gorm:"size:20" json:"plan,omitempty"` // subscription plan being paid for
 	CreatedAt          time.Time          `json:"created_at"`
 	UpdatedAt          time.Time          `json:"updated_at"`
 	CompletedAt        *time.Time         `json:"completed_at"`
 	ExpiresAt          time.Time          `json:"expires_at"`
 	DeletedAt          gorm.DeletedAt     `gorm:"index" json:"-"`
 
+	// Status queries sent while waiting for a callback that may never come
+	StatusChecks      int        `gorm:"default:0" json:"status_checks"`
+	LastStatusCheckAt *time.Time `json:"last_status_check_at,omitempty"`
+
+	// What the callback says was paid and by whom. AmountMismatch flags an
+	// under- or overpayment for review; underpaid payments settle nothing.
+	AmountPaid     float64 `gorm:"type:decimal(12,2)" json:"amount_paid"`
+	PayerPhone     string  `gorm:"size:20" json:"payer_phone,omitempty"`
+	AmountMismatch string  `gorm:"size:20;index" json:"amount_mismatch,omitempty"`
+
 	Shop    Shop    `gorm:"foreignKey:ShopID" json:"shop,omitempty"`
 	Product Product `gorm:"foreignKey:ProductID" json:"product,omitempty"`
 	Sale    *Sale   `gorm:"foreignKey:SaleID" json:"sale,omitempty"`
+
+	Attempts []MpesaPaymentAttempt `gorm:"foreignKey:PaymentID" json:"attempts,omitempty"`
 }
 
 func (m *MpesaPayment) TableName() string {
 	return "mpesa_payments"
 }
 
+// MpesaPaymentAttempt is one STK prompt sent for a payment. Retrying a
+// payment adds an attempt rather than a new payment.
+type MpesaPaymentAttempt struct {
+	ID                uint               `gorm:"primaryKey" json:"id"`
+	PaymentID         uint               `gorm:"index;not null" json:"payment_id"`
+	Attempt           int                `gorm:"not null" json:"attempt"`
+	MerchantRequestID string             `gorm:"size:100" json:"merchant_request_id"`
+	CheckoutRequestID string             `gorm:"size:100;index" json:"checkout_request_id"`
+	Status            MpesaPaymentStatus `gorm:"size:20" json:"status"`
+	FailureReason     string             `gorm:"size:255" json:"failure_reason"`
+	CreatedAt         time.Time          `json:"created_at"`
+	UpdatedAt         time.Time          `json:"updated_at"`
+}
+
+func (m *MpesaPaymentAttempt) TableName() string {
+	return "mpesa_payment_attempts"
+}
+
 func (m *MpesaPayment) BeforeCreate(tx *gorm.DB) error {
 	if m.Status == "" {
 		m.Status = MpesaPaymentPending
@@ -69,9 +109,107 @@ type MpesaTransaction struct {
 	Status          string    `gorm:"size:20" json:"status"`
 	CreatedAt       time.Time `json:"created_at"`
 
+	// Paybill payments: the account number the customer entered, and the
+	// sale created when it named a product
+	AccountReference string `gorm:"size:50" json:"account_reference,omitempty"`
+	CustomerName     string `gorm:"size:100" json:"customer_name,omitempty"`
+	SaleID           *uint  `gorm:"index" json:"sale_id,omitempty"`
+
+	// Latest Daraja transaction status query
+	StatusConversationID string     `gorm:"size:50;index" json:"-"`
+	StatusRequestedAt    *time.Time `json:"status_requested_at,omitempty"`
+	StatusResult         string     `gorm:"size:30" json:"status_result,omitempty"` // as reported by Daraja, e.g. Completed
+	StatusResultDesc     string     `gorm:"size:255" json:"status_result_desc,omitempty"`
+	StatusCheckedAt      *time.Time `json:"status_checked_at,omitempty"`
+
+	// Reversal of a mistaken charge
+	ReversalConversationID string     `gorm:"size:50;index" json:"-"`
+	ReversalStatus         string     `gorm:"size:20" json:"reversal_status,omitempty"` // pending, completed, failed
+	ReversalReason         string     `gorm:"size:255" json:"reversal_reason,omitempty"`
+	ReversalReceipt        string     `gorm:"size:50" json:"reversal_receipt,omitempty"`
+	ReversalResultDesc     string     `gorm:"size:255" json:"reversal_result_desc,omitempty"`
+	ReversedAt             *time.Time `json:"reversed_at,omitempty"`
+
 	Shop Shop `gorm:"foreignKey:ShopID" json:"shop,omitempty"`
 }
 
+const (
+	ReversalPending   = "pending"
+	ReversalCompleted = "completed"
+	ReversalFailed    = "failed"
+)
+
+// Receipt returns the M-Pesa receipt code of the transaction. C2B
+// notifications carry it as the TransactionID.
+func (m *MpesaTransaction) Receipt() string {
+	if m.Type == "c2b" || m.ReceiptNumber == "" {
+		return m.TransactionID
+	}
+	return m.ReceiptNumber
+}
+
 func (m *MpesaTransaction) TableName() string {
 	return "mpesa_transactions"
 }
+
+// IntegrationCredential holds a shop's own credentials for an external
+// provider. Secrets is an encrypted JSON blob; Identifier is the public ID
+// (e.g. the M-Pesa shortcode) used to route inbound notifications.
+type IntegrationCredential struct {
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	ShopID     uint           `gorm:"uniqueIndex:idx_integration_shop_provider;not null" json:"shop_id"`
+	Provider   string         `gorm:"size:30;uniqueIndex:idx_integration_shop_provider;not null" json:"provider"` // mpesa
+	Identifier string         `gorm:"size:50;index" json:"identifier"`
+	Secrets    string         `gorm:"type:text" json:"-"`
+	IsActive   bool           `gorm:"default:true" json:"is_active"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (m *IntegrationCredential) TableName() string {
+	return "integration_credentials"
+}
+
+type B2CPayoutStatus string
+
+const (
+	B2CPayoutPending   B2CPayoutStatus = "pending"   // recorded, not yet accepted by Daraja
+	B2CPayoutSubmitted B2CPayoutStatus = "submitted" // accepted, waiting for the result callback
+	B2CPayoutCompleted B2CPayoutStatus = "completed"
+	B2CPayoutFailed    B2CPayoutStatus = "failed"
+	B2CPayoutTimeout   B2CPayoutStatus = "timeout" // queue timeout; the outcome is unknown
+)
+
+// B2CPayout is a business-to-customer M-Pesa payment sent from a shop
+type B2CPayout struct {
+	ID                       uint            `gorm:"primaryKey" json:"id"`
+	ShopID                   uint            `gorm:"index;not null" json:"shop_id"`
+	Phone                    string          `gorm:"size:20;index" json:"phone"`
+	Amount                   float64         `gorm:"type:decimal(12,2);not null" json:"amount"`
+	CommandID                string          `gorm:"size:30" json:"command_id"`
+	Remarks                  string          `gorm:"size:100" json:"remarks"`
+	Occasion                 string          `gorm:"size:100" json:"occasion"`
+	ConversationID           string          `gorm:"size:100;index" json:"conversation_id"`
+	OriginatorConversationID string          `gorm:"size:100;index" json:"originator_conversation_id"`
+	Status                   B2CPayoutStatus `gorm:"size:20;default:pending;index" json:"status"`
+	ResultCode               int             `json:"result_code"`
+	ResultDesc               string          `gorm:"size:255" json:"result_desc"`
+	ReceiptNumber            string          `gorm:"size:50" json:"receipt_number"`
+	ReceiverName             string          `gorm:"size:100" json:"receiver_name"`
+	CreatedAt                time.Time       `json:"created_at"`
+	UpdatedAt                time.Time       `json:"updated_at"`
+	CompletedAt              *time.Time      `json:"completed_at"`
+
+	// Payouts requested in another currency; Amount is the KES sent
+	Currency      string  `gorm:"size:3" json:"currency,omitempty"`
+	ForeignAmount float64 `gorm:"type:decimal(12,2)" json:"foreign_amount,omitempty"`
+	ExchangeRate  float64 `gorm:"type:decimal(12,4)" json:"exchange_rate,omitempty"` // KES per unit of Currency
+
+	// Supplier order the payout settles, if any
+	OrderID *uint `gorm:"index" json:"order_id,omitempty"`
+}
+
+func (m *B2CPayout) TableName() string {
+	return "mpesa_b2c_payouts"
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// OtpCode is a one-time code sent to a phone or email address. Only a hash
+// of the code is kept, and it is spent by the first successful check.
+type OtpCode struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Destination string     `gorm:"size:100;index:idx_otp_destination;not null" json:"destination"` // phone or email
+	Purpose     string     `gorm:"size:30;index:idx_otp_destination;not null" json:"purpose"`
+	Channel     string     `gorm:"size:10" json:"channel"` // sms, whatsapp or email
+	CodeHash    string     `gorm:"size:64;not null" json:"-"`
+	Attempts    int        `gorm:"default:0" json:"attempts"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	UsedAt      *time.Time `json:"used_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// Outbound message types, recorded so delivery can be checked per kind
+const (
+	MessageTypeNotification  = "notification"
+	MessageTypeDailyReport   = "daily_report"
+	MessageTypeWeeklyReport  = "weekly_report"
+	MessageTypeMonthlyReport = "monthly_report"
+	MessageTypeLowStock      = "low_stock"
+	MessageTypeAutoOrder     = "auto_order"
+	MessageTypeMedia         = "media"
+)
+
+// Twilio message statuses, as sent to the status callback
+const (
+	MessageStatusQueued      = "queued"
+	MessageStatusSending     = "sending"
+	MessageStatusSent        = "sent"
+	MessageStatusDelivered   = "delivered"
+	MessageStatusRead        = "read"
+	MessageStatusUndelivered = "undelivered"
+	MessageStatusFailed      = "failed"
+)
+
+// OutboundMessage is a WhatsApp message handed to Twilio, tracked by its
+// SID through Twilio's status callbacks
+type OutboundMessage struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	SID          string     `gorm:"column:sid;size:64;uniqueIndex;not null" json:"sid"`
+	ShopID       uint       `gorm:"index" json:"shop_id"` // 0 when the recipient isn't a shop
+	To           string     `gorm:"size:30;not null" json:"to"`
+	Type         string     `gorm:"size:30;index" json:"type"`
+	Status       string     `gorm:"size:20;index;default:queued" json:"status"`
+	ErrorCode    string     `gorm:"size:10" json:"error_code,omitempty"`
+	ErrorMessage string     `gorm:"size:255" json:"error_message,omitempty"`
+	DeliveredAt  *time.Time `json:"delivered_at"`
+	CreatedAt    time.Time  `gorm:"index" json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// Failed reports whether Twilio gave up on the message
+func (m *OutboundMessage) Failed() bool {
+	return m.Status == MessageStatusFailed || m.Status == MessageStatusUndelivered
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Outbox channels
+const (
+	OutboxChannelWhatsApp = "whatsapp"
+	OutboxChannelSMS      = "sms"
+	OutboxChannelEmail    = "email"
+	// OutboxChannelMpesa holds STK pushes a WhatsApp command made while
+	// Daraja was rate limiting it, the payment request as JSON in the body
+	OutboxChannelMpesa = "mpesa"
+)
+
+// Outbox statuses. A message is dead once it has used up its attempts.
+const (
+	OutboxStatusPending = "pending"
+	OutboxStatusSending = "sending"
+	OutboxStatusSent    = "sent"
+	OutboxStatusDead    = "dead"
+)
+
+// OutboxMessage is an outbound WhatsApp, SMS, email or STK push waiting to
+// be sent, retried with backoff until it is sent or dead
+type OutboxMessage struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	ShopID        uint       `gorm:"index" json:"shop_id,omitempty"` // the shop an SMS is charged to
+	Channel       string     `gorm:"size:20;index;not null" json:"channel"`
+	Recipient     string     `gorm:"size:255;not null" json:"recipient"`
+	Subject       string     `gorm:"size:255" json:"subject,omitempty"` // email subject; WhatsApp message type; SMS purpose
+	Body          string     `gorm:"type:text" json:"body"`
+	Status        string     `gorm:"size:20;index;default:pending" json:"status"`
+	Attempts      int        `gorm:"default:0" json:"attempts"`
+	MaxAttempts   int        `gorm:"default:5" json:"max_attempts"`
+	NextAttemptAt time.Time  `gorm:"index" json:"next_attempt_at"`
+	ClaimedAt     *time.Time `json:"claimed_at,omitempty"` // when a worker took it to send
+	LastError     string     `gorm:"size:500" json:"last_error,omitempty"`
+	SentAt        *time.Time `json:"sent_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
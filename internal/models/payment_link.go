@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// PaymentLinkStatus represents whether a payment link can still be paid
+type PaymentLinkStatus string
+
+const (
+	PaymentLinkActive    PaymentLinkStatus = "active"
+	PaymentLinkPaid      PaymentLinkStatus = "paid"
+	PaymentLinkExpired   PaymentLinkStatus = "expired"
+	PaymentLinkCancelled PaymentLinkStatus = "cancelled"
+)
+
+// PaymentLink is a shareable page where a customer enters their phone
+// number to get an STK push. An Amount of 0 lets the customer choose it.
+type PaymentLink struct {
+	ID           uint              `gorm:"primaryKey" json:"id"`
+	ShopID       uint              `gorm:"index;not null" json:"shop_id"`
+	Token        string            `gorm:"size:32;uniqueIndex;not null" json:"token"`
+	Amount       float64           `gorm:"type:decimal(12,2);default:0" json:"amount"`
+	Description  string            `gorm:"size:255" json:"description"`
+	Status       PaymentLinkStatus `gorm:"size:20;default:active;index" json:"status"`
+	ExpiresAt    *time.Time        `json:"expires_at"`
+	PaymentID    *uint             `gorm:"index" json:"payment_id"`
+	Phone        string            `gorm:"size:20" json:"phone"` // who paid
+	MpesaReceipt string            `gorm:"size:50" json:"mpesa_receipt"`
+	PaidAmount   float64           `gorm:"type:decimal(12,2);default:0" json:"paid_amount"`
+	PaidAt       *time.Time        `json:"paid_at"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+func (l *PaymentLink) TableName() string {
+	return "payment_links"
+}
+
+// IsOpenAmount reports whether the customer chooses how much to pay
+func (l *PaymentLink) IsOpenAmount() bool {
+	return l.Amount <= 0
+}
+
+// IsPayable reports whether the link can still take a payment at now
+func (l *PaymentLink) IsPayable(now time.Time) bool {
+	if l.Status != PaymentLinkActive {
+		return false
+	}
+	return l.ExpiresAt == nil || now.Before(*l.ExpiresAt)
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// PendingSaleStatus represents where a pending sale is in checkout
+type PendingSaleStatus string
+
+const (
+	PendingSaleOpen      PendingSaleStatus = "pending"
+	PendingSalePaid      PendingSaleStatus = "paid"
+	PendingSaleCancelled PendingSaleStatus = "cancelled"
+)
+
+// PendingSale is a basket awaiting payment. Once its M-Pesa payment
+// completes, each item is recorded as a sale.
+type PendingSale struct {
+	ID           uint              `gorm:"primaryKey" json:"id"`
+	ShopID       uint              `gorm:"index;not null" json:"shop_id"`
+	TotalAmount  float64           `gorm:"type:decimal(12,2);not null" json:"total_amount"` // rounded to whole shillings for M-Pesa
+	Status       PendingSaleStatus `gorm:"size:20;default:pending;index" json:"status"`
+	PaymentID    *uint             `gorm:"index" json:"payment_id"`
+	MpesaReceipt string            `gorm:"size:50" json:"mpesa_receipt"`
+	Notes        string            `gorm:"size:255" json:"notes"`
+	PaidAt       *time.Time        `json:"paid_at"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+
+	Items []PendingSaleItem `gorm:"foreignKey:PendingSaleID" json:"items"`
+}
+
+func (p *PendingSale) TableName() string {
+	return "pending_sales"
+}
+
+// PendingSaleItem is one product line in a pending sale, priced when the
+// basket was made
+type PendingSaleItem struct {
+	ID            uint    `gorm:"primaryKey" json:"id"`
+	PendingSaleID uint    `gorm:"index;not null" json:"pending_sale_id"`
+	ProductID     uint    `gorm:"not null" json:"product_id"`
+	Quantity      int     `gorm:"not null" json:"quantity"`
+	UnitPrice     float64 `gorm:"type:decimal(12,2);not null" json:"unit_price"`
+
+	Product Product `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+}
+
+func (p *PendingSaleItem) TableName() string {
+	return "pending_sale_items"
+}
@@ -0,0 +1,86 @@
+package models
+
+// Unlimited marks a plan limit with no cap
+const Unlimited = -1
+
+// PlanLimits holds the resource limits of a subscription plan
+type PlanLimits struct {
+	MaxProducts  int
+	MaxStaff     int
+	MaxShops     int
+	MaxCustomers int
+	MaxAPIKeys   int
+	MaxWebhooks  int
+}
+
+// planLimits is the single source of truth for plan limits
+var planLimits = map[PlanType]PlanLimits{
+	PlanFree: {
+		MaxProducts:  50,
+		MaxStaff:     0,
+		MaxShops:     1,
+		MaxCustomers: 0,
+		MaxAPIKeys:   0,
+		MaxWebhooks:  0,
+	},
+	PlanPro: {
+		MaxProducts:  Unlimited,
+		MaxStaff:     3,
+		MaxShops:     3,
+		MaxCustomers: 100,
+		MaxAPIKeys:   2,
+		MaxWebhooks:  2,
+	},
+	PlanBusiness: {
+		MaxProducts:  Unlimited,
+		MaxStaff:     Unlimited,
+		MaxShops:     Unlimited,
+		MaxCustomers: Unlimited,
+		MaxAPIKeys:   10,
+		MaxWebhooks:  10,
+	},
+}
+
+// LimitsFor returns the limits of a plan, falling back to Free for unknown plans
+func LimitsFor(plan PlanType) PlanLimits {
+	if limits, ok := planLimits[plan]; ok {
+		return limits
+	}
+	return planLimits[PlanFree]
+}
+
+// Name returns the plan's display name
+func (p PlanType) Name() string {
+	switch p {
+	case PlanPro:
+		return "Pro"
+	case PlanBusiness:
+		return "Business"
+	default:
+		return "Free"
+	}
+}
+
+// NextPlan returns the plan to suggest when upgrading from plan
+func NextPlan(plan PlanType) PlanType {
+	switch plan {
+	case PlanFree:
+		return PlanPro
+	default:
+		return PlanBusiness
+	}
+}
+
+// CanAddProducts reports whether n more products fit alongside count existing ones
+func (l PlanLimits) CanAddProducts(count int64, n int) bool {
+	return withinLimit(l.MaxProducts, count, n)
+}
+
+// CanAddStaff reports whether n more staff fit alongside count existing ones
+func (l PlanLimits) CanAddStaff(count int64, n int) bool {
+	return withinLimit(l.MaxStaff, count, n)
+}
+
+func withinLimit(limit int, count int64, n int) bool {
+	return limit == Unlimited || count+int64(n) <= int64(limit)
+}
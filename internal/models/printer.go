@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// PrinterSetting is the receipt printer a shop prints to. Shops without
+// one use the printer set in the server config.
+type PrinterSetting struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ShopID     uint      `gorm:"uniqueIndex;not null" json:"shop_id"`
+	Type       string    `gorm:"size:20;default:thermal" json:"type"`       // thermal, cloud or pdf
+	Connection string    `gorm:"size:20;default:network" json:"connection"` // network, usb or serial
+	Host       string    `gorm:"size:255" json:"host"`
+	Port       int       `json:"port"`                          // 9100 when not set
+	Device     string    `gorm:"size:255" json:"device"`        // e.g. /dev/usb/lp0 or /dev/ttyUSB0
+	PaperWidth int       `gorm:"default:58" json:"paper_width"` // roll width in mm, 58 or 80
+	OpenDrawer bool      `gorm:"default:false" json:"open_drawer"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Report email frequencies
+const (
+	ReportFrequencyDaily  = "daily"
+	ReportFrequencyWeekly = "weekly"
+)
+
+// ReportEmailPreference is how a shop wants its sales report PDF emailed
+type ReportEmailPreference struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	ShopID         uint       `gorm:"uniqueIndex;not null" json:"shop_id"`
+	Enabled        bool       `gorm:"default:false" json:"enabled"`
+	Frequency      string     `gorm:"size:10;default:daily" json:"frequency"` // daily or weekly
+	Recipients     string     `gorm:"type:text" json:"recipients"`            // comma-separated; empty sends to the shop's email
+	LastSentAt     *time.Time `json:"last_sent_at"`
+	DisabledReason string     `gorm:"size:255" json:"disabled_reason,omitempty"` // why a bounce turned reports off
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// RecipientList returns the addresses to email, falling back to the shop's
+func (p *ReportEmailPreference) RecipientList(shop *Shop) []string {
+	var list []string
+	for _, r := range strings.Split(p.Recipients, ",") {
+		if r = strings.ToLower(strings.TrimSpace(r)); r != "" {
+			list = append(list, r)
+		}
+	}
+	if len(list) == 0 && shop != nil && shop.Email != "" {
+		list = append(list, strings.ToLower(shop.Email))
+	}
+	return list
+}
@@ -0,0 +1,64 @@
+package models
+
+import "math"
+
+// RoundingPolicy controls how sale totals are rounded to coins in circulation
+type RoundingPolicy string
+
+const (
+	RoundingNone     RoundingPolicy = "none"
+	RoundingNearest1 RoundingPolicy = "nearest_1"
+	RoundingNearest5 RoundingPolicy = "nearest_5"
+)
+
+// roundingSteps maps each policy to the unit totals are rounded to
+var roundingSteps = map[RoundingPolicy]float64{
+	RoundingNearest1: 1,
+	RoundingNearest5: 5,
+}
+
+// ParseRoundingPolicy accepts a policy name or the shorthand "0", "1" or "5"
+func ParseRoundingPolicy(s string) (RoundingPolicy, bool) {
+	switch s {
+	case "none", "0", "off":
+		return RoundingNone, true
+	case "nearest_1", "nearest-1", "1":
+		return RoundingNearest1, true
+	case "nearest_5", "nearest-5", "5":
+		return RoundingNearest5, true
+	}
+	return "", false
+}
+
+// Round rounds amount half up to the policy's unit and returns the rounded
+// amount with the adjustment that was added to get there. Amounts are first
+// settled to whole cents so float noise like 49.4999999 rounds as 49.50.
+func (p RoundingPolicy) Round(amount float64) (rounded, adjustment float64) {
+	cents := math.Round(amount*100) / 100
+	step, ok := roundingSteps[p]
+	if !ok {
+		return cents, 0
+	}
+	rounded = math.Round(cents/step) * step
+	return rounded, math.Round((rounded-cents)*100) / 100
+}
+
+// RoundingFor returns the policy for a payment method. M-Pesa only moves
+// whole shillings so it always rounds to the nearest 1; other methods use
+// the shop's cash rounding.
+func (s *Shop) RoundingFor(method PaymentMethod) RoundingPolicy {
+	if method == PaymentMpesa {
+		return RoundingNearest1
+	}
+	if s == nil || s.Rounding == "" {
+		return RoundingNone
+	}
+	return s.Rounding
+}
+
+// ApplyRounding rounds the sale total under policy, recording the
+// adjustment and keeping profit in step with the new total
+func (s *Sale) ApplyRounding(policy RoundingPolicy) {
+	s.TotalAmount, s.RoundingAdjustment = policy.Round(s.TotalAmount)
+	s.Profit = s.TotalAmount - s.CostAmount
+}
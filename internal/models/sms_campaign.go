@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// SmsCampaignStatus tracks a campaign while its messages go out
+type SmsCampaignStatus string
+
+const (
+	SmsCampaignSending   SmsCampaignStatus = "sending"
+	SmsCampaignCompleted SmsCampaignStatus = "completed"
+)
+
+// SmsCampaign is a marketing SMS sent to the loyalty customers matching its
+// filter. Each recipient's SmsMessage carries the campaign ID.
+type SmsCampaign struct {
+	ID       uint              `gorm:"primaryKey" json:"id"`
+	ShopID   uint              `gorm:"index;not null" json:"shop_id"`
+	Template string            `gorm:"type:text;not null" json:"template"`
+	Status   SmsCampaignStatus `gorm:"size:20;default:sending" json:"status"`
+
+	// Filter
+	Tier               LoyaltyTier `gorm:"size:20" json:"tier,omitempty"`
+	MinPoints          int         `json:"min_points,omitempty"`
+	LastPurchaseBefore *time.Time  `json:"last_purchase_before,omitempty"`
+	LastPurchaseAfter  *time.Time  `json:"last_purchase_after,omitempty"`
+
+	Recipients  int        `json:"recipients"` // customers texted
+	OptedOut    int        `json:"opted_out"`  // matched the filter but opted out
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (c *SmsCampaign) TableName() string {
+	return "sms_campaigns"
+}
+
+// SmsCampaignSummary counts a campaign's messages by outcome
+type SmsCampaignSummary struct {
+	Campaign  *SmsCampaign `json:"campaign"`
+	Sent      int64        `json:"sent"`      // accepted, awaiting a delivery report
+	Delivered int64        `json:"delivered"` // on the handset
+	Failed    int64        `json:"failed"`    // rejected or undelivered
+	Cost      float64      `json:"cost"`
+	Currency  string       `json:"currency"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// SmsStatus tracks an SMS from sending to the delivery report
+type SmsStatus string
+
+const (
+	SmsStatusSent        SmsStatus = "sent"      // accepted by the provider
+	SmsStatusFailed      SmsStatus = "failed"    // rejected when sending
+	SmsStatusDelivered   SmsStatus = "delivered" // delivery report: on the handset
+	SmsStatusUndelivered SmsStatus = "undelivered"
+)
+
+// SmsPurpose records why an SMS was sent
+type SmsPurpose string
+
+const (
+	SmsPurposeManual   SmsPurpose = "manual"
+	SmsPurposeReceipt  SmsPurpose = "receipt"
+	SmsPurposeLowStock SmsPurpose = "low_stock"
+	SmsPurposeCampaign SmsPurpose = "campaign"
+	SmsPurposeReport   SmsPurpose = "report" // sales reports asked for over USSD
+	SmsPurposeOTP      SmsPurpose = "otp"    // logged without the message body
+)
+
+// Low stock alert channels for Shop.LowStockChannel
+const (
+	AlertChannelWhatsApp = "whatsapp"
+	AlertChannelSMS      = "sms"
+)
+
+// SmsMessage is one SMS sent through Africa's Talking. ShopID is 0 for
+// messages the platform sends on its own behalf.
+type SmsMessage struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	ShopID        uint       `gorm:"index" json:"shop_id"`
+	CampaignID    *uint      `gorm:"index" json:"campaign_id,omitempty"`
+	Recipient     string     `gorm:"size:20;index" json:"recipient"`
+	Body          string     `gorm:"type:text" json:"body"`
+	Purpose       SmsPurpose `gorm:"size:20" json:"purpose"`
+	Status        SmsStatus  `gorm:"size:20;index" json:"status"`
+	ProviderID    string     `gorm:"size:100;index" json:"provider_id"`
+	Cost          float64    `gorm:"type:decimal(10,4);default:0" json:"cost"`
+	Currency      string     `gorm:"size:3" json:"currency"`
+	FailureReason string     `gorm:"size:255" json:"failure_reason,omitempty"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+	CreatedAt     time.Time  `gorm:"index" json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (m *SmsMessage) TableName() string {
+	return "sms_messages"
+}
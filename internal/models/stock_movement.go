@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// StockMovementType says why a product's stock changed
+type StockMovementType string
+
+const (
+	StockMovementSale       StockMovementType = "sale"
+	StockMovementRestock    StockMovementType = "restock"
+	StockMovementAdjustment StockMovementType = "adjustment"
+	StockMovementTransfer   StockMovementType = "transfer"
+	StockMovementRefund     StockMovementType = "refund"
+)
+
+// StockMovement is one entry in a product's stock ledger. Every change to
+// current_stock writes one in the same transaction, so replaying a
+// product's movements gives its stock at any point in time.
+type StockMovement struct {
+	ID           uint              `gorm:"primaryKey" json:"id"`
+	ShopID       uint              `gorm:"index;not null" json:"shop_id"`
+	ProductID    uint              `gorm:"index:idx_stock_movement_product;not null" json:"product_id"`
+	Type         StockMovementType `gorm:"size:20;index;not null" json:"type"`
+	Quantity     int               `gorm:"not null" json:"quantity"` // signed; negative takes stock out
+	BalanceAfter int               `json:"balance_after"`
+	ReferenceID  *uint             `gorm:"index" json:"reference_id"` // the sale for sale movements
+	Note         string            `gorm:"size:255" json:"note"`
+	CreatedAt    time.Time         `gorm:"index:idx_stock_movement_product" json:"created_at"`
+}
+
+func (m *StockMovement) TableName() string {
+	return "stock_movements"
+}
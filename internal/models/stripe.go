@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// StripePaymentStatus is where a card payment is in its lifecycle
+type StripePaymentStatus string
+
+const (
+	StripePaymentPending   StripePaymentStatus = "pending"
+	StripePaymentCompleted StripePaymentStatus = "completed"
+	StripePaymentFailed    StripePaymentStatus = "failed"
+)
+
+// StripePayment is an online order paid by card through a Stripe
+// PaymentIntent. The sale is recorded when Stripe reports it succeeded.
+type StripePayment struct {
+	ID              uint                `gorm:"primaryKey" json:"id"`
+	ShopID          uint                `gorm:"index;not null" json:"shop_id"`
+	PaymentIntentID string              `gorm:"size:100;uniqueIndex;not null" json:"payment_intent_id"`
+	ProductID       uint                `gorm:"not null" json:"product_id"`
+	Quantity        int                 `gorm:"not null" json:"quantity"`
+	Amount          float64             `gorm:"type:decimal(12,2);not null" json:"amount"`
+	Currency        string              `gorm:"size:3;not null" json:"currency"` // lowercase ISO code, as Stripe uses
+	Description     string              `gorm:"size:255" json:"description"`
+	Status          StripePaymentStatus `gorm:"size:20;default:pending;index" json:"status"`
+	FailureReason   string              `gorm:"size:255" json:"failure_reason,omitempty"`
+	SaleID          *uint               `json:"sale_id"`
+	CompletedAt     *time.Time          `json:"completed_at"`
+	CreatedAt       time.Time           `json:"created_at"`
+	UpdatedAt       time.Time           `json:"updated_at"`
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Daily summaries roll up into weeks, Monday to Sunday, and calendar
+// months. Their days are the daily summaries' days, which start at
+// midnight UTC.
+
+// SummaryDayLength is the length of a summary day
+const SummaryDayLength = 24 * time.Hour
+
+// WeekStart returns the Monday starting the summary week t is in
+func WeekStart(t time.Time) time.Time {
+	day := t.Truncate(SummaryDayLength)
+	offset := (int(day.UTC().Weekday()) + 6) % 7
+	return day.Add(-time.Duration(offset) * SummaryDayLength)
+}
+
+// MonthStart returns the first day of the summary month t is in
+func MonthStart(t time.Time) time.Time {
+	day := t.Truncate(SummaryDayLength)
+	return day.Add(-time.Duration(day.UTC().Day()-1) * SummaryDayLength)
+}
+
+// AfterCreate keeps the summaries of a past day right when a sale is
+// recorded for it
+func (s *Sale) AfterCreate(tx *gorm.DB) error {
+	return s.refreshSummaries(tx)
+}
+
+// AfterUpdate keeps the summaries of a past day right when one of its
+// sales changes
+func (s *Sale) AfterUpdate(tx *gorm.DB) error {
+	return s.refreshSummaries(tx)
+}
+
+// AfterDelete keeps the summaries of a past day right when one of its
+// sales is removed
+func (s *Sale) AfterDelete(tx *gorm.DB) error {
+	return s.refreshSummaries(tx)
+}
+
+// refreshSummaries works out the daily summary of the sale's day again
+// and drops the weekly and monthly rollups it is in, which the rollup job
+// then takes again. Sales of today change nothing that is rolled up yet.
+func (s *Sale) refreshSummaries(tx *gorm.DB) error {
+	if s.ShopID == 0 || s.CreatedAt.IsZero() {
+		return nil
+	}
+	day := s.CreatedAt.Truncate(SummaryDayLength)
+	if !day.Before(time.Now().Truncate(SummaryDayLength)) {
+		return nil
+	}
+
+	db := tx.Session(&gorm.Session{NewDB: true})
+	var totals struct {
+		TotalSales        float64
+		TotalTransactions int
+		TotalCost         float64
+		TotalProfit       float64
+	}
+	err := db.Model(&Sale{}).
+		Select(
+			"COALESCE(SUM(total_amount), 0) as total_sales",
+			"COUNT(*) as total_transactions",
+			"COALESCE(SUM(cost_amount), 0) as total_cost",
+			"COALESCE(SUM(profit), 0) as total_profit",
+		).
+		Where("shop_id = ? AND created_at >= ? AND created_at < ?", s.ShopID, day, day.Add(SummaryDayLength)).
+		Scan(&totals).Error
+	if err != nil {
+		return err
+	}
+	err = db.Model(&DailySummary{}).Where("shop_id = ? AND date = ?", s.ShopID, day).Updates(map[string]interface{}{
+		"total_sales":        totals.TotalSales,
+		"total_transactions": totals.TotalTransactions,
+		"total_cost":         totals.TotalCost,
+		"total_profit":       totals.TotalProfit,
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	if err := db.Where("shop_id = ? AND week_start = ?", s.ShopID, WeekStart(day)).Delete(&WeeklySummary{}).Error; err != nil {
+		return err
+	}
+	return db.Where("shop_id = ? AND month = ?", s.ShopID, MonthStart(day)).Delete(&MonthlySummary{}).Error
+}
@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// Order statuses. Draft orders are created automatically for low stock and
+// wait for the owner to confirm them before they go to the supplier. Sent
+// orders have had their purchase order sent to the supplier.
+const (
+	OrderStatusDraft     = "draft"
+	OrderStatusPending   = "pending"
+	OrderStatusSent      = "sent"
+	OrderStatusDelivered = "delivered"
+	OrderStatusCancelled = "cancelled"
+)
+
+// Order payment statuses. Paying a supplier over M-Pesa B2C moves an order
+// to processing until the result callback marks it paid, partly paid or
+// failed.
+const (
+	OrderPaymentUnpaid     = "unpaid"
+	OrderPaymentProcessing = "processing"
+	OrderPaymentPartial    = "partial"
+	OrderPaymentPaid       = "paid"
+	OrderPaymentFailed     = "failed"
+)
+
+// SupplierProduct records that a supplier sells a product and at what cost.
+// Low stock auto orders go to the supplier with the lowest unit cost.
+type SupplierProduct struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ShopID      uint      `gorm:"index;not null" json:"shop_id"`
+	SupplierID  uint      `gorm:"uniqueIndex:idx_supplier_product;not null" json:"supplier_id"`
+	ProductID   uint      `gorm:"uniqueIndex:idx_supplier_product;index;not null" json:"product_id"`
+	UnitCost    float64   `gorm:"type:decimal(12,2);default:0" json:"unit_cost"`
+	MinOrderQty int       `gorm:"default:1" json:"min_order_qty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Relations
+	Supplier Supplier `gorm:"foreignKey:SupplierID" json:"supplier,omitempty"`
+	Product  Product  `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+}
+
+// Supplier ratings run from 1 to 5 stars
+const (
+	MinSupplierRating = 1
+	MaxSupplierRating = 5
+)
+
+// SupplierRating is the owner's rating of a supplier for a delivered
+// order. An order has one rating; rating it again replaces it.
+type SupplierRating struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ShopID     uint      `gorm:"index;not null" json:"shop_id"`
+	SupplierID uint      `gorm:"index;not null" json:"supplier_id"`
+	OrderID    uint      `gorm:"uniqueIndex;not null" json:"order_id"`
+	Rating     int       `gorm:"not null" json:"rating"`
+	Notes      string    `gorm:"type:text" json:"notes"`
+	RatedAt    time.Time `json:"rated_at"`
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidUnitFactor is returned for a purchase unit that does not hold a
+// whole number of base units
+var ErrInvalidUnitFactor = errors.New("units per purchase unit must be a positive whole number")
+
+// SetPurchaseUnit sets the bulk unit a product is bought in, e.g. a crate of
+// 24. An empty unit clears it.
+func (p *Product) SetPurchaseUnit(unit string, factor int) error {
+	unit = singularUnit(unit)
+	if unit == "" {
+		p.PurchaseUnit, p.UnitsPerPurchase = "", 1
+		return nil
+	}
+	if factor < 1 {
+		return ErrInvalidUnitFactor
+	}
+	p.PurchaseUnit, p.UnitsPerPurchase = unit, factor
+	return nil
+}
+
+// ToBaseUnits converts qty of unit into the base units stock is kept in. It
+// reports false for a unit the product is not sold or bought in.
+func (p *Product) ToBaseUnits(qty int, unit string) (int, bool) {
+	unit = singularUnit(unit)
+	switch {
+	case unit == "" || unit == singularUnit(p.Unit):
+		return qty, true
+	case p.PurchaseUnit != "" && unit == p.PurchaseUnit && p.UnitsPerPurchase > 0:
+		return qty * p.UnitsPerPurchase, true
+	}
+	return 0, false
+}
+
+// BulkEquivalent describes qty base units in purchase units, e.g.
+// "2 crates + 5 bottles". It is empty for products without a purchase unit
+// or less than one purchase unit of stock.
+func (p *Product) BulkEquivalent(qty int) string {
+	if p.PurchaseUnit == "" || p.UnitsPerPurchase <= 1 || qty < p.UnitsPerPurchase {
+		return ""
+	}
+	whole, rest := qty/p.UnitsPerPurchase, qty%p.UnitsPerPurchase
+	s := pluralUnit(whole, p.PurchaseUnit)
+	if rest > 0 {
+		s += " + " + pluralUnit(rest, p.Unit)
+	}
+	return s
+}
+
+// StockLabel formats qty base units with the product's unit and, when it
+// has one, the bulk equivalent, e.g. "53 bottles (= 2 crates + 5 bottles)"
+func (p *Product) StockLabel(qty int) string {
+	label := pluralUnit(qty, p.Unit)
+	if bulk := p.BulkEquivalent(qty); bulk != "" {
+		label += " (= " + bulk + ")"
+	}
+	return label
+}
+
+// singularUnit normalises a unit name so "Crates" matches "crate"
+func singularUnit(unit string) string {
+	unit = strings.ToLower(strings.TrimSpace(unit))
+	switch {
+	case len(unit) <= 3, strings.HasSuffix(unit, "ss"):
+		return unit
+	case strings.HasSuffix(unit, "xes"):
+		return strings.TrimSuffix(unit, "es")
+	}
+	return strings.TrimSuffix(unit, "s")
+}
+
+// pluralUnit formats n of unit, leaving abbreviations like kg and pcs alone
+func pluralUnit(n int, unit string) string {
+	switch {
+	case n == 1, len(unit) <= 3, strings.HasSuffix(unit, "s"):
+	case strings.HasSuffix(unit, "x"):
+		unit += "es"
+	default:
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", n, unit)
+}
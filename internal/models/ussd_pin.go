@@ -0,0 +1,73 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	USSDPinLength      = 4
+	MaxUSSDPinFailures = 3
+	USSDPinLockout     = 30 * time.Minute
+)
+
+var ErrInvalidUSSDPin = errors.New("PIN must be 4 digits")
+
+// ValidateUSSDPin checks pin is 4 digits
+func ValidateUSSDPin(pin string) error {
+	if len(pin) != USSDPinLength {
+		return ErrInvalidUSSDPin
+	}
+	for _, c := range pin {
+		if c < '0' || c > '9' {
+			return ErrInvalidUSSDPin
+		}
+	}
+	return nil
+}
+
+// SetUSSDPin stores a new PIN hashed and clears any lockout
+func (s *Shop) SetUSSDPin(pin string) error {
+	if err := ValidateUSSDPin(pin); err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	s.USSDPinHash = string(hash)
+	s.USSDPinFailures = 0
+	s.USSDPinLockedUntil = nil
+	return nil
+}
+
+// HasUSSDPin reports whether the shop has set a USSD PIN
+func (s *Shop) HasUSSDPin() bool {
+	return s.USSDPinHash != ""
+}
+
+// USSDPinLocked reports whether too many wrong PINs have locked USSD access
+func (s *Shop) USSDPinLocked(now time.Time) bool {
+	return s.USSDPinLockedUntil != nil && now.Before(*s.USSDPinLockedUntil)
+}
+
+// CheckUSSDPin compares pin with the stored hash, counting failures and
+// locking USSD access after MaxUSSDPinFailures in a row. The caller saves
+// the shop.
+func (s *Shop) CheckUSSDPin(pin string, now time.Time) bool {
+	if bcrypt.CompareHashAndPassword([]byte(s.USSDPinHash), []byte(pin)) == nil {
+		s.USSDPinFailures = 0
+		s.USSDPinLockedUntil = nil
+		return true
+	}
+
+	s.USSDPinFailures++
+	if s.USSDPinFailures >= MaxUSSDPinFailures {
+		lockedUntil := now.Add(USSDPinLockout)
+		s.USSDPinLockedUntil = &lockedUntil
+		s.USSDPinFailures = 0
+	}
+	return false
+}
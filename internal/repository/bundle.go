@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"gorm.io/gorm"
+)
+
+// ErrInsufficientStock is returned when a component can't cover a bundle sale
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// BundleRepository handles product bundle database operations
+type BundleRepository struct {
+	db *gorm.DB
+}
+
+// NewBundleRepository creates a new bundle repository
+func NewBundleRepository(db *gorm.DB) *BundleRepository {
+	return &BundleRepository{db: db}
+}
+
+// GetComponents returns a bundle's components with their products loaded
+func (r *BundleRepository) GetComponents(bundleProductID uint) ([]models.ProductBundle, error) {
+	var components []models.ProductBundle
+	err := r.db.Preload("Component").
+		Where("bundle_product_id = ?", bundleProductID).
+		Order("id ASC").
+		Find(&components).Error
+	return components, err
+}
+
+// GetBundles returns a shop's active bundle products, by name
+func (r *BundleRepository) GetBundles(shopID uint) ([]models.Product, error) {
+	var bundles []models.Product
+	err := r.db.Where("shop_id = ? AND is_bundle = ? AND is_active = ?", shopID, true, true).
+		Order("name ASC").
+		Find(&bundles).Error
+	return bundles, err
+}
+
+// SetComponents replaces a bundle's composition. The bundle product is marked
+// as a bundle with zero stock; an empty list turns it back into a plain product.
+func (r *BundleRepository) SetComponents(bundleProductID uint, components []models.ProductBundle) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("bundle_product_id = ?", bundleProductID).
+			Delete(&models.ProductBundle{}).Error; err != nil {
+			return err
+		}
+
+		for i := range components {
+			components[i].ID = 0
+			components[i].BundleProductID = bundleProductID
+			if err := tx.Create(&components[i]).Error; err != nil {
+				return err
+			}
+		}
+
+		updates := map[string]interface{}{"is_bundle": len(components) > 0}
+		if len(components) > 0 {
+			// Write off any stock the product held before it became a bundle
+			var bundle models.Product
+			if err := tx.Select("id", "current_stock").First(&bundle, bundleProductID).Error; err != nil {
+				return err
+			}
+			if bundle.CurrentStock != 0 {
+				_, err := moveStock(tx, bundleProductID, -bundle.CurrentStock, models.StockMovementAdjustment, nil, "converted to bundle", false)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Model(&models.Product{}).Where("id = ?", bundleProductID).Updates(updates).Error
+	})
+}
+
+// RecordSales creates the component sales of a bundle and deducts each
+// component's stock, all or nothing
+func (r *BundleRepository) RecordSales(sales []*models.Sale) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, sale := range sales {
+			if err := tx.Create(sale).Error; err != nil {
+				return err
+			}
+			_, err := moveStock(tx, sale.ProductID, -sale.Quantity, models.StockMovementSale, &sale.ID, "bundle sale", true)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
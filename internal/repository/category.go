@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"gorm.io/gorm"
+)
+
+// CategoryRepository handles product category database operations
+type CategoryRepository struct {
+	db *gorm.DB
+}
+
+// NewCategoryRepository creates a new category repository
+func NewCategoryRepository(db *gorm.DB) *CategoryRepository {
+	return &CategoryRepository{db: db}
+}
+
+// Create creates a new category
+func (r *CategoryRepository) Create(category *models.Category) error {
+	return r.db.Create(category).Error
+}
+
+// GetByID gets a category by ID
+func (r *CategoryRepository) GetByID(id uint) (*models.Category, error) {
+	var category models.Category
+	err := r.db.First(&category, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// GetByName gets a category by shop ID and name
+func (r *CategoryRepository) GetByName(shopID uint, name string) (*models.Category, error) {
+	var category models.Category
+	err := r.db.Where("shop_id = ? AND name = ?", shopID, name).First(&category).Error
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// GetOrCreate returns the named category, creating it as a root category if missing
+func (r *CategoryRepository) GetOrCreate(shopID uint, name string) (*models.Category, error) {
+	var category models.Category
+	err := r.db.Where(models.Category{ShopID: shopID, Name: name}).FirstOrCreate(&category).Error
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// GetByShopID gets all categories for a shop
+func (r *CategoryRepository) GetByShopID(shopID uint) ([]models.Category, error) {
+	var categories []models.Category
+	err := r.db.Where("shop_id = ?", shopID).
+		Order("name ASC").
+		Find(&categories).Error
+	return categories, err
+}
+
+// GetTree returns the shop's root categories with their children nested
+func (r *CategoryRepository) GetTree(shopID uint) ([]models.Category, error) {
+	categories, err := r.GetByShopID(shopID)
+	if err != nil {
+		return nil, err
+	}
+	return BuildCategoryTree(categories), nil
+}
+
+// Update updates a category
+func (r *CategoryRepository) Update(category *models.Category) error {
+	return r.db.Save(category).Error
+}
+
+// Rename saves a category under a new name, moving the products filed
+// under the old name with it
+func (r *CategoryRepository) Rename(category *models.Category, name string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Product{}).
+			Where("shop_id = ? AND category = ?", category.ShopID, category.Name).
+			Update("category", name).Error; err != nil {
+			return err
+		}
+		category.Name = name
+		return tx.Save(category).Error
+	})
+}
+
+// IsWithin reports whether category id is ancestorID or one of its
+// descendants
+func (r *CategoryRepository) IsWithin(id, ancestorID uint) (bool, error) {
+	seen := make(map[uint]bool)
+	for !seen[id] {
+		if id == ancestorID {
+			return true, nil
+		}
+		seen[id] = true
+
+		category, err := r.GetByID(id)
+		if err != nil {
+			return false, err
+		}
+		if category.ParentCategoryID == nil {
+			return false, nil
+		}
+		id = *category.ParentCategoryID
+	}
+	return false, nil
+}
+
+// Delete removes a category, moves its children up to its parent and
+// leaves its products uncategorized
+func (r *CategoryRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var category models.Category
+		if err := tx.First(&category, id).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Product{}).
+			Where("shop_id = ? AND category = ?", category.ShopID, category.Name).
+			Update("category", "").Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Category{}).
+			Where("parent_category_id = ?", id).
+			Update("parent_category_id", category.ParentCategoryID).Error; err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Delete(&models.Category{}, id).Error
+	})
+}
+
+// GetSubcategoryNames returns the names of a category and all its descendants
+func (r *CategoryRepository) GetSubcategoryNames(shopID uint, name string) ([]string, error) {
+	var names []string
+	err := r.db.Raw(`
+		WITH RECURSIVE subcategories(id, name) AS (
+			SELECT id, name FROM categories
+			WHERE shop_id = ? AND name = ? AND deleted_at IS NULL
+			UNION ALL
+			SELECT c.id, c.name FROM categories c
+			JOIN subcategories s ON c.parent_category_id = s.id
+			WHERE c.deleted_at IS NULL
+		)
+		SELECT name FROM subcategories`, shopID, name).
+		Scan(&names).Error
+	return names, err
+}
+
+// BuildCategoryTree nests a flat list of categories under their parents.
+// Categories whose parent is missing from the list are treated as roots.
+func BuildCategoryTree(categories []models.Category) []models.Category {
+	byParent := make(map[uint][]models.Category)
+	known := make(map[uint]bool, len(categories))
+	for _, c := range categories {
+		known[c.ID] = true
+	}
+
+	var roots []models.Category
+	for _, c := range categories {
+		if c.ParentCategoryID == nil || !known[*c.ParentCategoryID] {
+			roots = append(roots, c)
+			continue
+		}
+		byParent[*c.ParentCategoryID] = append(byParent[*c.ParentCategoryID], c)
+	}
+
+	var attach func(nodes []models.Category, seen map[uint]bool) []models.Category
+	attach = func(nodes []models.Category, seen map[uint]bool) []models.Category {
+		for i := range nodes {
+			if seen[nodes[i].ID] {
+				continue
+			}
+			seen[nodes[i].ID] = true
+			nodes[i].Children = attach(byParent[nodes[i].ID], seen)
+		}
+		return nodes
+	}
+
+	return attach(roots, make(map[uint]bool))
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"log"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"gorm.io/gorm"
+)
+
+// ClosingStockRepository handles month-end closing stock snapshots
+type ClosingStockRepository struct {
+	db *gorm.DB
+}
+
+// NewClosingStockRepository creates a new closing stock repository
+func NewClosingStockRepository(db *gorm.DB) *ClosingStockRepository {
+	return &ClosingStockRepository{db: db}
+}
+
+// ClosingStockCatchUpMonths is how many of the last months CloseDue
+// closes for shops that have no closing stock for them yet
+const ClosingStockCatchUpMonths = 3
+
+// MonthClose returns when the month t is in closes: local midnight
+// starting the next month
+func MonthClose(t time.Time) time.Time {
+	t = t.In(time.Local)
+	return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.Local)
+}
+
+// Close records the stock and prices of a shop's active products as the
+// closing balance of the month t is in, replacing an earlier one. The stock
+// is what it was when the month closed, worked back from the stock ledger,
+// so a month closed late is still right; prices are the current ones.
+// Bundles hold no stock of their own and are skipped.
+func (r *ClosingStockRepository) Close(shopID uint, t time.Time) (int, error) {
+	closedAt := MonthClose(t)
+	date := startOfDay(closedAt.AddDate(0, 0, -1))
+	var rows []models.ClosingStockSnapshot
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var products []models.Product
+		err := tx.Where("shop_id = ? AND is_active = ? AND is_bundle = ? AND created_at < ?", shopID, true, false, closedAt).
+			Order("name").
+			Find(&products).Error
+		if err != nil {
+			return err
+		}
+
+		var since []struct {
+			ProductID uint
+			Quantity  int
+		}
+		err = tx.Model(&models.StockMovement{}).
+			Select("product_id, SUM(quantity) AS quantity").
+			Where("shop_id = ? AND created_at >= ?", shopID, closedAt).
+			Group("product_id").
+			Scan(&since).Error
+		if err != nil {
+			return err
+		}
+		moved := make(map[uint]int, len(since))
+		for _, m := range since {
+			moved[m.ProductID] = m.Quantity
+		}
+
+		if err := tx.Where("shop_id = ? AND snapshot_date = ?", shopID, date).
+			Delete(&models.ClosingStockSnapshot{}).Error; err != nil {
+			return err
+		}
+		if len(products) == 0 {
+			return nil
+		}
+
+		for _, p := range products {
+			rows = append(rows, models.ClosingStockSnapshot{
+				ShopID:       shopID,
+				ProductID:    p.ID,
+				ProductName:  p.Name,
+				ClosingStock: p.CurrentStock - moved[p.ID],
+				CostPrice:    p.CostPrice,
+				SellingPrice: p.SellingPrice,
+				SnapshotDate: date,
+			})
+		}
+		return tx.Create(&rows).Error
+	})
+	return len(rows), err
+}
+
+// CloseDue closes the last ClosingStockCatchUpMonths months for active
+// shops that had products then but no closing stock yet, at most limit
+// shops a run, oldest month and lowest shop ID first, and returns how many
+// it tried. A shop that fails is logged and tried again next run. Each run picks up where the last stopped, so every shop is
+// closed however many there are, and months missed while the server was
+// down are closed when it is back.
+func (r *ClosingStockRepository) CloseDue(now time.Time, limit int) (int, error) {
+	closed := 0
+	for i := ClosingStockCatchUpMonths; i > 0 && closed < limit; i-- {
+		closedAt := MonthClose(now).AddDate(0, -i, 0)
+		date := startOfDay(closedAt.AddDate(0, 0, -1))
+
+		var shops []models.Shop
+		err := r.db.Select("id", "name").
+			Where("is_active = ?", true).
+			Where("NOT EXISTS (SELECT 1 FROM closing_stock_snapshots c WHERE c.shop_id = shops.id AND c.snapshot_date = ?)", date).
+			Where("EXISTS (SELECT 1 FROM products p WHERE p.shop_id = shops.id AND p.is_active = ? AND p.is_bundle = ? AND p.created_at < ? AND p.deleted_at IS NULL)", true, false, closedAt).
+			Order("id").Limit(limit - closed).
+			Find(&shops).Error
+		if err != nil {
+			return closed, err
+		}
+		for _, shop := range shops {
+			closed++
+			n, err := r.Close(shop.ID, date)
+			if err != nil {
+				log.Printf("❌ Failed to record closing stock for %s for shop %s: %v", date.Format("January 2006"), shop.Name, err)
+				continue
+			}
+			log.Printf("📦 Closing stock for %s recorded for shop %s (%d products)", date.Format("January 2006"), shop.Name, n)
+		}
+	}
+	return closed, nil
+}
+
+// GetMonth returns a shop's closing snapshot for the month containing
+// month, by product name. It is empty if the month has not closed.
+func (r *ClosingStockRepository) GetMonth(shopID uint, month time.Time) ([]models.ClosingStockSnapshot, error) {
+	last := startOfDay(MonthClose(month).AddDate(0, 0, -1))
+	var rows []models.ClosingStockSnapshot
+	err := r.db.Where("shop_id = ? AND snapshot_date = ?", shopID, last).
+		Order("product_name, product_id").
+		Find(&rows).Error
+	return rows, err
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+)
+
+// crossSellWindow is how far back sales are looked at for cross-sells
+const crossSellWindow = 90 * 24 * time.Hour
+
+// GetFrequentlyBoughtTogether returns the IDs of the products most often
+// sold on the same days as productID in the shop, most frequent first.
+// Sales carry no basket, so a shop's day of sales stands in for one. Only
+// active products are suggested. The days productID sold on are found
+// first, so each of the shop's sales is looked at once rather than paired
+// with every other sale of its day.
+func (r *SaleRepository) GetFrequentlyBoughtTogether(productID, shopID uint, limit int) ([]uint, error) {
+	since := time.Now().Add(-crossSellWindow)
+
+	// DATE() rather than ::date so the query runs on SQLite too
+	days := r.db.Model(&models.Sale{}).
+		Select("DISTINCT DATE(created_at)").
+		Where("shop_id = ? AND product_id = ? AND created_at >= ?", shopID, productID, since)
+
+	var rows []struct {
+		ProductID uint
+		Days      int
+	}
+	err := r.db.Model(&models.Sale{}).
+		Select("sales.product_id, COUNT(DISTINCT DATE(sales.created_at)) AS days").
+		Joins("JOIN products AS p ON p.id = sales.product_id AND p.is_active = ? AND p.deleted_at IS NULL", true).
+		Where("sales.shop_id = ? AND sales.product_id <> ? AND sales.created_at >= ?", shopID, productID, since).
+		Where("DATE(sales.created_at) IN (?)", days).
+		Group("sales.product_id").
+		Order("days DESC, sales.product_id").
+		Limit(limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ProductID
+	}
+	return ids, nil
+}
+
+// GetByIDsInOrder gets a shop's products by ID, keeping the order of ids
+func (r *ProductRepository) GetByIDsInOrder(shopID uint, ids []uint) ([]models.Product, error) {
+	if len(ids) == 0 {
+		return []models.Product{}, nil
+	}
+	var found []models.Product
+	if err := r.db.Where("shop_id = ? AND id IN ?", shopID, ids).Find(&found).Error; err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]models.Product, len(found))
+	for _, p := range found {
+		byID[p.ID] = p
+	}
+	products := make([]models.Product, 0, len(found))
+	for _, id := range ids {
+		if p, ok := byID[id]; ok {
+			products = append(products, p)
+		}
+	}
+	return products, nil
+}
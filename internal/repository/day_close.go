@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DayCloseRepository handles end-of-day closes and the sales and refunds
+// that go into them
+type DayCloseRepository struct {
+	db *gorm.DB
+}
+
+// NewDayCloseRepository creates a new day close repository
+func NewDayCloseRepository(db *gorm.DB) *DayCloseRepository {
+	return &DayCloseRepository{db: db}
+}
+
+// Last returns the shop's latest close, or nil when it has never closed a
+// day
+func (r *DayCloseRepository) Last(shopID uint) (*models.DayClose, error) {
+	var dc models.DayClose
+	err := r.db.Where("shop_id = ?", shopID).Order("number DESC").First(&dc).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &dc, nil
+}
+
+// Create saves a close, numbering it after the shop's last one. The shop's
+// row is locked while it is numbered, so concurrent closes take the next
+// numbers one at a time.
+func (r *DayCloseRepository) Create(dc *models.DayClose) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var shop models.Shop
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&shop, dc.ShopID).Error; err != nil {
+			return err
+		}
+		var last int
+		if err := tx.Model(&models.DayClose{}).Where("shop_id = ?", dc.ShopID).
+			Select("COALESCE(MAX(number), 0)").Scan(&last).Error; err != nil {
+			return err
+		}
+		dc.Number = last + 1
+		return tx.Create(dc).Error
+	})
+}
+
+// GetByNumber returns the shop's close with the given Z number
+func (r *DayCloseRepository) GetByNumber(shopID uint, number int) (*models.DayClose, error) {
+	var dc models.DayClose
+	err := r.db.Where("shop_id = ? AND number = ?", shopID, number).First(&dc).Error
+	return &dc, err
+}
+
+// LastOn returns the shop's latest close for the business day starting at
+// date, or nil when the day was not closed
+func (r *DayCloseRepository) LastOn(shopID uint, date time.Time) (*models.DayClose, error) {
+	var dc models.DayClose
+	err := r.db.Where("shop_id = ? AND business_date >= ? AND business_date < ?", shopID, date, date.AddDate(0, 0, 1)).
+		Order("number DESC").First(&dc).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &dc, nil
+}
+
+// List returns the shop's closes, newest first
+func (r *DayCloseRepository) List(shopID uint, limit int) ([]models.DayClose, error) {
+	var closes []models.DayClose
+	err := r.db.Where("shop_id = ?", shopID).Order("number DESC").Limit(limit).Find(&closes).Error
+	return closes, err
+}
+
+// Sales returns the shop's sales made from start up to end, with their
+// products and the staff who made them
+func (r *DayCloseRepository) Sales(shopID uint, start, end time.Time) ([]models.Sale, error) {
+	var sales []models.Sale
+	err := r.db.Where("shop_id = ? AND created_at >= ? AND created_at < ?", shopID, start, end).
+		Preload("Product", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Preload("Staff").
+		Order("created_at").
+		Find(&sales).Error
+	return sales, err
+}
+
+// Refunds returns the total and count of the shop's M-Pesa payments
+// reversed from start up to end
+func (r *DayCloseRepository) Refunds(shopID uint, start, end time.Time) (float64, int, error) {
+	var result struct {
+		Total float64
+		Count int
+	}
+	err := r.db.Model(&models.MpesaTransaction{}).
+		Select("COALESCE(SUM(amount), 0) AS total, COUNT(*) AS count").
+		Where("shop_id = ? AND reversal_status = ? AND reversed_at >= ? AND reversed_at < ?",
+			shopID, models.ReversalCompleted, start, end).
+		Scan(&result).Error
+	return result.Total, result.Count, err
+}
+
+// Discounts returns the total of loyalty points the shop's customers
+// redeemed from start up to end
+func (r *DayCloseRepository) Discounts(shopID uint, start, end time.Time) (float64, error) {
+	var total float64
+	err := r.db.Model(&models.LoyaltyTransaction{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("shop_id = ? AND type = ? AND created_at >= ? AND created_at < ?", shopID, models.LoyaltyRedeemed, start, end).
+		Scan(&total).Error
+	return total, err
+}
+
+// Expenses returns the total of the shop's expenses spent from start up to
+// end, and of those paid in cash
+func (r *DayCloseRepository) Expenses(shopID uint, start, end time.Time) (float64, float64, error) {
+	var result struct {
+		Total float64
+		Cash  float64
+	}
+	err := r.db.Model(&models.Expense{}).
+		Select("COALESCE(SUM(amount), 0) AS total, COALESCE(SUM(CASE WHEN payment_method = ? THEN amount ELSE 0 END), 0) AS cash", models.PaymentCash).
+		Where("shop_id = ? AND spent_at >= ? AND spent_at < ?", shopID, start, end).
+		Scan(&result).Error
+	return result.Total, result.Cash, err
+}
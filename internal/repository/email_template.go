@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"gorm.io/gorm"
+)
+
+// EmailTemplateRepository handles platform and shop email templates
+type EmailTemplateRepository struct {
+	db *gorm.DB
+}
+
+// NewEmailTemplateRepository creates a new email template repository
+func NewEmailTemplateRepository(db *gorm.DB) *EmailTemplateRepository {
+	return &EmailTemplateRepository{db: db}
+}
+
+// Get gets a shop's template (shop 0 for the platform default)
+func (r *EmailTemplateRepository) Get(shopID uint, key string) (*models.EmailTemplate, error) {
+	var tmpl models.EmailTemplate
+	if err := r.db.Where("shop_id = ? AND key = ?", shopID, key).First(&tmpl).Error; err != nil {
+		return nil, err
+	}
+	return &tmpl, nil
+}
+
+// ListByShop lists a shop's templates
+func (r *EmailTemplateRepository) ListByShop(shopID uint) ([]models.EmailTemplate, error) {
+	var templates []models.EmailTemplate
+	err := r.db.Where("shop_id = ?", shopID).Order("key").Find(&templates).Error
+	return templates, err
+}
+
+// Save creates or replaces the shop's template for the key
+func (r *EmailTemplateRepository) Save(tmpl *models.EmailTemplate) error {
+	existing, err := r.Get(tmpl.ShopID, tmpl.Key)
+	if err == nil {
+		tmpl.ID = existing.ID
+		tmpl.CreatedAt = existing.CreatedAt
+		return r.db.Save(tmpl).Error
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return r.db.Create(tmpl).Error
+}
+
+// CreateIfMissing creates the template unless the shop already has one for
+// the key, so seeding never overwrites edits
+func (r *EmailTemplateRepository) CreateIfMissing(tmpl *models.EmailTemplate) error {
+	_, err := r.Get(tmpl.ShopID, tmpl.Key)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return r.db.Create(tmpl).Error
+	}
+	return err
+}
+
+// Delete removes the shop's template for the key
+func (r *EmailTemplateRepository) Delete(shopID uint, key string) error {
+	return r.db.Where("shop_id = ? AND key = ?", shopID, key).Delete(&models.EmailTemplate{}).Error
+}
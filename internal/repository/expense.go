@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"gorm.io/gorm"
+)
+
+// ExpenseRepository handles shop expenses, one-off and recurring
+type ExpenseRepository struct {
+	db *gorm.DB
+}
+
+// NewExpenseRepository creates a new expense repository
+func NewExpenseRepository(db *gorm.DB) *ExpenseRepository {
+	return &ExpenseRepository{db: db}
+}
+
+// ExpenseCategoryTotal is what a shop spent on one category of expenses
+type ExpenseCategoryTotal struct {
+	Category string  `json:"category"`
+	Total    float64 `json:"total"`
+	Count    int     `json:"count"`
+}
+
+// Create records an expense
+func (r *ExpenseRepository) Create(expense *models.Expense) error {
+	return r.db.Create(expense).Error
+}
+
+// CreateRecurring records an expense and repeats it every day, week or
+// month from when it was spent
+func (r *ExpenseRepository) CreateRecurring(expense *models.Expense, frequency string) (*models.RecurringExpense, error) {
+	recurring := &models.RecurringExpense{
+		ShopID:        expense.ShopID,
+		Amount:        expense.Amount,
+		Category:      expense.Category,
+		Note:          expense.Note,
+		PaymentMethod: expense.PaymentMethod,
+		Frequency:     frequency,
+		IsActive:      true,
+	}
+	if frequency == models.ExpenseMonthly {
+		recurring.DayOfMonth = expense.SpentAt.Day()
+	}
+	recurring.NextDueAt = recurring.After(expense.SpentAt)
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(recurring).Error; err != nil {
+			return err
+		}
+		expense.RecurringExpenseID = &recurring.ID
+		return tx.Create(expense).Error
+	})
+	return recurring, err
+}
+
+// List returns the shop's expenses spent from start up to end, newest
+// first
+func (r *ExpenseRepository) List(shopID uint, start, end time.Time) ([]models.Expense, error) {
+	var expenses []models.Expense
+	err := r.db.Where("shop_id = ? AND spent_at >= ? AND spent_at < ?", shopID, start, end).
+		Order("spent_at DESC, id DESC").
+		Find(&expenses).Error
+	return expenses, err
+}
+
+// Delete removes one of the shop's expenses
+func (r *ExpenseRepository) Delete(shopID, id uint) error {
+	result := r.db.Where("shop_id = ?", shopID).Delete(&models.Expense{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Total returns what the shop spent from start up to end
+func (r *ExpenseRepository) Total(shopID uint, start, end time.Time) (float64, error) {
+	var total float64
+	err := r.db.Model(&models.Expense{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("shop_id = ? AND spent_at >= ? AND spent_at < ?", shopID, start, end).
+		Scan(&total).Error
+	return total, err
+}
+
+// TotalsByCategory returns what the shop spent from start up to end on
+// each category, the largest first
+func (r *ExpenseRepository) TotalsByCategory(shopID uint, start, end time.Time) ([]ExpenseCategoryTotal, error) {
+	var totals []ExpenseCategoryTotal
+	err := r.db.Model(&models.Expense{}).
+		Select("category, COALESCE(SUM(amount), 0) AS total, COUNT(*) AS count").
+		Where("shop_id = ? AND spent_at >= ? AND spent_at < ?", shopID, start, end).
+		Group("category").
+		Order("total DESC, category ASC").
+		Scan(&totals).Error
+	return totals, err
+}
+
+// ListRecurring returns the shop's active recurring expenses, next due
+// first
+func (r *ExpenseRepository) ListRecurring(shopID uint) ([]models.RecurringExpense, error) {
+	var recurring []models.RecurringExpense
+	err := r.db.Where("shop_id = ? AND is_active = ?", shopID, true).
+		Order("next_due_at ASC").
+		Find(&recurring).Error
+	return recurring, err
+}
+
+// StopRecurring stops one of the shop's recurring expenses; expenses it
+// already recorded are kept
+func (r *ExpenseRepository) StopRecurring(shopID, id uint) error {
+	result := r.db.Model(&models.RecurringExpense{}).
+		Where("id = ? AND shop_id = ? AND is_active = ?", id, shopID, true).
+		Update("is_active", false)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RecordDue records the expenses recurring expenses are due for as of now,
+// catching up on any missed while the server was down, and returns how
+// many were recorded. Each is moved to its next due time only if no other
+// server got there first, so an expense is never recorded twice.
+func (r *ExpenseRepository) RecordDue(now time.Time) (int, error) {
+	var due []models.RecurringExpense
+	if err := r.db.Where("is_active = ? AND next_due_at <= ?", true, now).Find(&due).Error; err != nil {
+		return 0, err
+	}
+
+	recorded := 0
+	for _, recurring := range due {
+		var expenses []models.Expense
+		next := recurring.NextDueAt
+		for !next.After(now) {
+			id := recurring.ID
+			expenses = append(expenses, models.Expense{
+				ShopID:             recurring.ShopID,
+				Amount:             recurring.Amount,
+				Category:           recurring.Category,
+				Note:               recurring.Note,
+				PaymentMethod:      recurring.PaymentMethod,
+				SpentAt:            next,
+				RecurringExpenseID: &id,
+			})
+			next = recurring.After(next)
+		}
+
+		claimed := false
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			claim := tx.Model(&models.RecurringExpense{}).
+				Where("id = ? AND next_due_at = ?", recurring.ID, recurring.NextDueAt).
+				Update("next_due_at", next)
+			if claim.Error != nil || claim.RowsAffected == 0 {
+				return claim.Error
+			}
+			claimed = true
+			return tx.Create(&expenses).Error
+		})
+		if err != nil {
+			return recorded, err
+		}
+		if claimed {
+			recorded += len(expenses)
+		}
+	}
+	return recorded, nil
+}
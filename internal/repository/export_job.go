@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"gorm.io/gorm"
+)
+
+// ExportJobRepository handles background export jobs
+type ExportJobRepository struct {
+	db *gorm.DB
+}
+
+// NewExportJobRepository creates a new export job repository
+func NewExportJobRepository(db *gorm.DB) *ExportJobRepository {
+	return &ExportJobRepository{db: db}
+}
+
+// Create creates a job
+func (r *ExportJobRepository) Create(job *models.ExportJob) error {
+	return r.db.Create(job).Error
+}
+
+// Update saves a job's state
+func (r *ExportJobRepository) Update(job *models.ExportJob) error {
+	return r.db.Save(job).Error
+}
+
+// GetByID gets a job
+func (r *ExportJobRepository) GetByID(id uint) (*models.ExportJob, error) {
+	var job models.ExportJob
+	if err := r.db.First(&job, id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetByShop gets one of a shop's jobs
+func (r *ExportJobRepository) GetByShop(shopID, id uint) (*models.ExportJob, error) {
+	var job models.ExportJob
+	if err := r.db.Where("shop_id = ?", shopID).First(&job, id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Claim marks a queued job running. It reports false if the job wasn't
+// queued, e.g. because another worker took it first.
+func (r *ExportJobRepository) Claim(id uint) (bool, error) {
+	result := r.db.Model(&models.ExportJob{}).
+		Where("id = ? AND status = ?", id, models.ExportJobStatusQueued).
+		Update("status", models.ExportJobStatusRunning)
+	return result.RowsAffected == 1, result.Error
+}
+
+// FailStale marks jobs queued or running since before as failed and
+// returns how many there were
+func (r *ExportJobRepository) FailStale(before, now time.Time, reason string) (int64, error) {
+	result := r.db.Model(&models.ExportJob{}).
+		Where("status IN ? AND updated_at < ?", []string{models.ExportJobStatusQueued, models.ExportJobStatusRunning}, before).
+		Updates(map[string]interface{}{"status": models.ExportJobStatusFailed, "error": reason, "finished_at": now})
+	return result.RowsAffected, result.Error
+}
+
+// ListExpired lists finished jobs whose files expired before now
+func (r *ExportJobRepository) ListExpired(now time.Time) ([]models.ExportJob, error) {
+	var jobs []models.ExportJob
+	err := r.db.Where("status = ? AND expires_at < ?", models.ExportJobStatusDone, now).
+		Find(&jobs).Error
+	return jobs, err
+}
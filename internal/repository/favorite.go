@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"gorm.io/gorm"
+)
+
+// MaxFavorites is how many products a shop can pin for quick selling
+const MaxFavorites = 10
+
+// ErrTooManyFavorites is returned when a shop already has MaxFavorites
+var ErrTooManyFavorites = errors.New("too many favorites")
+
+// GetFavorites returns the products a shop has pinned, in the order they
+// were added. Products hidden for selling out keep their place, so the
+// numbers of the others don't shift under "sell [number] [qty]".
+func (r *ProductRepository) GetFavorites(shopID uint) ([]models.Product, error) {
+	var products []models.Product
+	err := r.db.Joins("JOIN favorite_products ON favorite_products.product_id = products.id").
+		Where("favorite_products.shop_id = ?", shopID).
+		Order("favorite_products.id ASC").
+		Find(&products).Error
+	return products, err
+}
+
+// GetQuickSell returns the shop's favorites, or its active best sellers of
+// the last week until it pins any. saved reports which they are; only
+// favorites are sold by number, since the best sellers reorder with every
+// sale.
+func (r *ProductRepository) GetQuickSell(shopID uint) (products []models.Product, saved bool, err error) {
+	products, err = r.GetFavorites(shopID)
+	if err != nil || len(products) > 0 {
+		return products, true, err
+	}
+
+	top, err := topSellers(r.db, shopID, time.Now().AddDate(0, 0, -7), MaxFavorites)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, p := range top {
+		if p.IsActive {
+			products = append(products, p)
+		}
+	}
+	return products, false, nil
+}
+
+// AddFavorite pins a product for quick selling. Pinning one already pinned
+// is not an error; a shop with MaxFavorites, counting those sold out, gets
+// ErrTooManyFavorites.
+func (r *ProductRepository) AddFavorite(product *models.Product) error {
+	var existing models.FavoriteProduct
+	err := r.db.Where("shop_id = ? AND product_id = ?", product.ShopID, product.ID).First(&existing).Error
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	var count int64
+	if err := r.db.Model(&models.FavoriteProduct{}).Where("shop_id = ?", product.ShopID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count >= MaxFavorites {
+		return ErrTooManyFavorites
+	}
+	return r.db.Create(&models.FavoriteProduct{ShopID: product.ShopID, ProductID: product.ID}).Error
+}
+
+// RemoveFavorite unpins a product, returning gorm.ErrRecordNotFound if it
+// was not pinned
+func (r *ProductRepository) RemoveFavorite(shopID, productID uint) error {
+	result := r.db.Where("shop_id = ? AND product_id = ?", shopID, productID).Delete(&models.FavoriteProduct{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
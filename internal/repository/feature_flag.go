@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"gorm.io/gorm"
+)
+
+// FeatureFlagRepository handles runtime feature flags
+type FeatureFlagRepository struct {
+	db *gorm.DB
+}
+
+// NewFeatureFlagRepository creates a new feature flag repository
+func NewFeatureFlagRepository(db *gorm.DB) *FeatureFlagRepository {
+	return &FeatureFlagRepository{db: db}
+}
+
+// GetAll gets every flag that has been set
+func (r *FeatureFlagRepository) GetAll() ([]models.FeatureFlag, error) {
+	var flags []models.FeatureFlag
+	err := r.db.Order("name").Find(&flags).Error
+	return flags, err
+}
+
+// Set switches a flag on or off, creating it the first time
+func (r *FeatureFlagRepository) Set(name string, enabled bool) (*models.FeatureFlag, error) {
+	flag := &models.FeatureFlag{Name: name, Enabled: enabled, UpdatedAt: time.Now()}
+	if err := r.db.Save(flag).Error; err != nil {
+		return nil, err
+	}
+	return flag, nil
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"gorm.io/gorm"
+)
+
+// InvoiceRepository handles billing invoice database operations
+type InvoiceRepository struct {
+	db *gorm.DB
+}
+
+// NewInvoiceRepository creates a new invoice repository
+func NewInvoiceRepository(db *gorm.DB) *InvoiceRepository {
+	return &InvoiceRepository{db: db}
+}
+
+// Create creates a new invoice
+func (r *InvoiceRepository) Create(invoice *models.Invoice) error {
+	return r.db.Create(invoice).Error
+}
+
+// Update saves changes to an invoice
+func (r *InvoiceRepository) Update(invoice *models.Invoice) error {
+	return r.db.Save(invoice).Error
+}
+
+// GetByID gets an invoice by ID
+func (r *InvoiceRepository) GetByID(id uint) (*models.Invoice, error) {
+	var invoice models.Invoice
+	if err := r.db.First(&invoice, id).Error; err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// GetByPaymentID gets the invoice for an M-Pesa payment
+func (r *InvoiceRepository) GetByPaymentID(paymentID uint) (*models.Invoice, error) {
+	var invoice models.Invoice
+	if err := r.db.Where("payment_id = ?", paymentID).First(&invoice).Error; err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// GetByAccount returns an account's invoices, newest first
+func (r *InvoiceRepository) GetByAccount(accountID uint, limit, offset int) ([]models.Invoice, int64, error) {
+	var invoices []models.Invoice
+	var total int64
+
+	query := r.db.Model(&models.Invoice{}).Where("account_id = ?", accountID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&invoices).Error
+	return invoices, total, err
+}
+
+// GetLatestByAccount gets the invoice covering the account's most recent
+// billing period
+func (r *InvoiceRepository) GetLatestByAccount(accountID uint) (*models.Invoice, error) {
+	var invoice models.Invoice
+	err := r.db.Where("account_id = ?", accountID).
+		Order("period_end DESC").
+		First(&invoice).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
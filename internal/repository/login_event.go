@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"gorm.io/gorm"
+)
+
+// LoginEventRepository handles the login audit trail
+type LoginEventRepository struct {
+	db *gorm.DB
+}
+
+// NewLoginEventRepository creates a new login event repository
+func NewLoginEventRepository(db *gorm.DB) *LoginEventRepository {
+	return &LoginEventRepository{db: db}
+}
+
+// Create records a login attempt
+func (r *LoginEventRepository) Create(event *models.LoginEvent) error {
+	return r.db.Create(event).Error
+}
+
+// Recent returns the latest login attempts, newest first: the account's
+// across all its shops, or the shop's when accountID is 0
+func (r *LoginEventRepository) Recent(accountID, shopID uint, limit int) ([]models.LoginEvent, error) {
+	var events []models.LoginEvent
+	err := r.owner(accountID, shopID).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&events).Error
+	return events, err
+}
+
+// KnownCountries returns the countries the account (or, when accountID is
+// 0, the shop) has logged in from before
+func (r *LoginEventRepository) KnownCountries(accountID, shopID uint) ([]string, error) {
+	var countries []string
+	err := r.owner(accountID, shopID).
+		Where("success = ? AND country <> ?", true, "").
+		Distinct().
+		Pluck("country", &countries).Error
+	return countries, err
+}
+
+func (r *LoginEventRepository) owner(accountID, shopID uint) *gorm.DB {
+	query := r.db.Model(&models.LoginEvent{})
+	if accountID > 0 {
+		return query.Where("account_id = ?", accountID)
+	}
+	return query.Where("shop_id = ?", shopID)
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type MpesaPaymentRepository struct {
@@ -60,12 +61,46 @@ func (r *MpesaPaymentRepository) GetPendingByShopID(shopID uint, before time.Tim
 	return payments, err
 }
 
+// GetExpiredPending returns pending payments whose expiry has passed, oldest first
+func (r *MpesaPaymentRepository) GetExpiredPending(now time.Time, limit int) ([]models.MpesaPayment, error) {
+	var payments []models.MpesaPayment
+	err := r.db.Where("status = ? AND expires_at <= ?", models.MpesaPaymentPending, now).
+		Order("expires_at ASC").
+		Limit(limit).
+		Find(&payments).Error
+	return payments, err
+}
+
+// GetDueForStatusCheck returns unexpired pending payments whose prompt was
+// sent before cutoff and that have not been queried since, oldest first
+func (r *MpesaPaymentRepository) GetDueForStatusCheck(cutoff, now time.Time, maxChecks, limit int) ([]models.MpesaPayment, error) {
+	var payments []models.MpesaPayment
+	err := r.db.Where("status = ? AND expires_at > ? AND status_checks < ?", models.MpesaPaymentPending, now, maxChecks).
+		Where("checkout_request_id <> ''").
+		Where("COALESCE(last_attempt_at, created_at) <= ?", cutoff).
+		Where("last_status_check_at IS NULL OR last_status_check_at <= ?", cutoff).
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&payments).Error
+	return payments, err
+}
+
+// RecordStatusCheck counts a status query sent for a payment
+func (r *MpesaPaymentRepository) RecordStatusCheck(id uint, at time.Time) error {
+	return r.db.Model(&models.MpesaPayment{}).Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status_checks":        gorm.Expr("status_checks + 1"),
+			"last_status_check_at": at,
+		}).Error
+}
+
 func (r *MpesaPaymentRepository) GetByShopID(shopID uint, limit, offset int) ([]models.MpesaPayment, int64, error) {
 	var payments []models.MpesaPayment
 	var total int64
 
 	r.db.Model(&models.MpesaPayment{}).Where("shop_id = ?", shopID).Count(&total)
 	err := r.db.Where("shop_id = ?", shopID).
+		Preload("Attempts", func(db *gorm.DB) *gorm.DB { return db.Order("attempt ASC") }).
 		Order("created_at DESC").
 		Limit(limit).
 		Offset(offset).
@@ -74,6 +109,29 @@ func (r *MpesaPaymentRepository) GetByShopID(shopID uint, limit, offset int) ([]
 	return payments, total, err
 }
 
+// GetCompletedInRange returns a shop's completed payments settled in
+// [start, end), leaving out plan payments since they aren't shop income
+func (r *MpesaPaymentRepository) GetCompletedInRange(shopID uint, start, end time.Time) ([]models.MpesaPayment, error) {
+	var payments []models.MpesaPayment
+	err := r.db.Where("shop_id = ? AND status = ? AND completed_at >= ? AND completed_at < ?",
+		shopID, models.MpesaPaymentCompleted, start, end).
+		Where("plan IS NULL OR plan = ''").
+		Order("completed_at ASC").
+		Find(&payments).Error
+	return payments, err
+}
+
+// EachForExport calls fn with a shop's payments started in [start, end),
+// oldest first, batchSize at a time; a zero start or end leaves that side
+// open
+func (r *MpesaPaymentRepository) EachForExport(shopID uint, start, end time.Time, batchSize int, fn func([]models.MpesaPayment) error) error {
+	return eachOldest(func() *gorm.DB {
+		return r.db.Where("shop_id = ?", shopID).Scopes(createdBetween("created_at", start, end))
+	}, "created_at", batchSize, func(p models.MpesaPayment) (time.Time, uint) {
+		return p.CreatedAt, p.ID
+	}, fn)
+}
+
 func (r *MpesaPaymentRepository) Update(payment *models.MpesaPayment) error {
 	return r.db.Save(payment).Error
 }
@@ -93,9 +151,34 @@ func (r *MpesaPaymentRepository) UpdateStatus(id uint, status models.MpesaPaymen
 	return r.db.Model(&models.MpesaPayment{}).Where("id = ?", id).Updates(updates).Error
 }
 
-func (r *MpesaPaymentRepository) IncrementRetryCount(id uint) error {
-	return r.db.Model(&models.MpesaPayment{}).Where("id = ?", id).
-		UpdateColumn("retry_count", gorm.Expr("retry_count + 1")).Error
+// CreateAttempt records an STK prompt sent for a payment
+func (r *MpesaPaymentRepository) CreateAttempt(attempt *models.MpesaPaymentAttempt) error {
+	return r.db.Create(attempt).Error
+}
+
+// GetAttemptByCheckoutRequestID finds the prompt a callback is for
+func (r *MpesaPaymentRepository) GetAttemptByCheckoutRequestID(checkoutID string) (*models.MpesaPaymentAttempt, error) {
+	var attempt models.MpesaPaymentAttempt
+	err := r.db.Where("checkout_request_id = ?", checkoutID).First(&attempt).Error
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// GetAttempts returns a payment's prompts, first to last
+func (r *MpesaPaymentRepository) GetAttempts(paymentID uint) ([]models.MpesaPaymentAttempt, error) {
+	var attempts []models.MpesaPaymentAttempt
+	err := r.db.Where("payment_id = ?", paymentID).Order("attempt ASC").Find(&attempts).Error
+	return attempts, err
+}
+
+// UpdateAttemptStatus records a prompt's callback result
+func (r *MpesaPaymentRepository) UpdateAttemptStatus(id uint, status models.MpesaPaymentStatus, reason string) error {
+	return r.db.Model(&models.MpesaPaymentAttempt{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":         status,
+		"failure_reason": reason,
+	}).Error
 }
 
 func (r *MpesaPaymentRepository) MarkAsFailed(id uint, reason string) error {
@@ -105,6 +188,59 @@ func (r *MpesaPaymentRepository) MarkAsFailed(id uint, reason string) error {
 	}).Error
 }
 
+// MarkAsExpired times out a payment that is still pending. It reports false
+// when the payment was settled in the meantime.
+func (r *MpesaPaymentRepository) MarkAsExpired(id uint, reason string) (bool, error) {
+	result := r.db.Model(&models.MpesaPayment{}).
+		Where("id = ? AND status = ?", id, models.MpesaPaymentPending).
+		Updates(map[string]interface{}{
+			"status":         models.MpesaPaymentTimeout,
+			"failure_reason": reason,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+// ClaimSettlement moves a payment from status to settled, reporting whether
+// it did. Only one of two deliveries of the same result can claim it.
+func (r *MpesaPaymentRepository) ClaimSettlement(id uint, status, settled models.MpesaPaymentStatus) (bool, error) {
+	result := r.db.Model(&models.MpesaPayment{}).
+		Where("id = ? AND status = ?", id, status).
+		Update("status", settled)
+	return result.RowsAffected == 1, result.Error
+}
+
+// ClaimRetry takes the next retry of a payment that is pending or failed
+// after retries retries, reporting whether it did. Of two retries at once,
+// or a retry and a callback settling the payment, only one gets it.
+func (r *MpesaPaymentRepository) ClaimRetry(id uint, retries int, at time.Time) (bool, error) {
+	result := r.db.Model(&models.MpesaPayment{}).
+		Where("id = ? AND retry_count = ? AND status IN ?", id, retries,
+			[]models.MpesaPaymentStatus{models.MpesaPaymentPending, models.MpesaPaymentFailed}).
+		Updates(map[string]interface{}{
+			"status":               models.MpesaPaymentPending,
+			"failure_reason":       "",
+			"retry_count":          retries + 1,
+			"last_attempt_at":      at,
+			"status_checks":        0,
+			"last_status_check_at": nil,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+// RecordRetry saves the prompt a claimed retry sent, reporting false when
+// an earlier prompt paid the payment in the meantime
+func (r *MpesaPaymentRepository) RecordRetry(payment *models.MpesaPayment) (bool, error) {
+	result := r.db.Model(&models.MpesaPayment{}).
+		Where("id = ? AND retry_count = ? AND status <> ?", payment.ID, payment.RetryCount, models.MpesaPaymentCompleted).
+		Updates(map[string]interface{}{
+			"merchant_request_id": payment.MerchantRequestID,
+			"checkout_request_id": payment.CheckoutRequestID,
+			"status":              payment.Status,
+			"failure_reason":      payment.FailureReason,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
 func (r *MpesaPaymentRepository) LinkToSale(paymentID, saleID uint) error {
 	return r.db.Model(&models.MpesaPayment{}).Where("id = ?", paymentID).Update("sale_id", saleID).Error
 }
@@ -143,6 +279,45 @@ func (r *MpesaTransactionRepository) GetByReceiptNumber(receipt string) (*models
 	return &tx, nil
 }
 
+func (r *MpesaTransactionRepository) GetByID(id uint) (*models.MpesaTransaction, error) {
+	var tx models.MpesaTransaction
+	err := r.db.First(&tx, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// GetByShopAndReceipt finds a shop's transaction by its M-Pesa receipt code
+func (r *MpesaTransactionRepository) GetByShopAndReceipt(shopID uint, receipt string) (*models.MpesaTransaction, error) {
+	var tx models.MpesaTransaction
+	err := r.db.Where("shop_id = ? AND (receipt_number = ? OR transaction_id = ?)", shopID, receipt, receipt).
+		Order("id DESC").
+		First(&tx).Error
+	if err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+func (r *MpesaTransactionRepository) GetByStatusConversationID(conversationID string) (*models.MpesaTransaction, error) {
+	var tx models.MpesaTransaction
+	err := r.db.Where("status_conversation_id = ? AND status_conversation_id != ''", conversationID).First(&tx).Error
+	if err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+func (r *MpesaTransactionRepository) GetByReversalConversationID(conversationID string) (*models.MpesaTransaction, error) {
+	var tx models.MpesaTransaction
+	err := r.db.Where("reversal_conversation_id = ? AND reversal_conversation_id != ''", conversationID).First(&tx).Error
+	if err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
 func (r *MpesaTransactionRepository) GetByShopID(shopID uint, limit, offset int) ([]models.MpesaTransaction, int64, error) {
 	var transactions []models.MpesaTransaction
 	var total int64
@@ -157,6 +332,48 @@ func (r *MpesaTransactionRepository) GetByShopID(shopID uint, limit, offset int)
 	return transactions, total, err
 }
 
+// GetReceivedInRange returns a shop's completed incoming (STK and paybill)
+// transactions made in [start, end), leaving out ones that were reversed
+func (r *MpesaTransactionRepository) GetReceivedInRange(shopID uint, start, end time.Time) ([]models.MpesaTransaction, error) {
+	var transactions []models.MpesaTransaction
+	err := r.db.Scopes(receivedTransactions(shopID)).
+		Where("transaction_time >= ? AND transaction_time < ?", start, end).
+		Order("transaction_time ASC").
+		Find(&transactions).Error
+	return transactions, err
+}
+
+// GetReceivedByReceipts returns a shop's completed incoming transactions
+// with any of the given receipt codes, whenever they were made
+func (r *MpesaTransactionRepository) GetReceivedByReceipts(shopID uint, receipts []string) ([]models.MpesaTransaction, error) {
+	var transactions []models.MpesaTransaction
+	if len(receipts) == 0 {
+		return transactions, nil
+	}
+	err := r.db.Scopes(receivedTransactions(shopID)).
+		Where("transaction_id IN ? OR receipt_number IN ?", receipts, receipts).
+		Find(&transactions).Error
+	return transactions, err
+}
+
+// EachForExport calls fn with all of a shop's transactions, whatever their
+// type or status, made in [start, end), oldest first, batchSize at a time;
+// a zero start or end leaves that side open
+func (r *MpesaTransactionRepository) EachForExport(shopID uint, start, end time.Time, batchSize int, fn func([]models.MpesaTransaction) error) error {
+	return eachOldest(func() *gorm.DB {
+		return r.db.Where("shop_id = ?", shopID).Scopes(createdBetween("transaction_time", start, end))
+	}, "transaction_time", batchSize, func(t models.MpesaTransaction) (time.Time, uint) {
+		return t.TransactionTime, t.ID
+	}, fn)
+}
+
+func receivedTransactions(shopID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("shop_id = ? AND type IN ? AND status = ?", shopID, []string{"stk_push", "c2b"}, "completed").
+			Where("reversal_status IS NULL OR reversal_status != ?", models.ReversalCompleted)
+	}
+}
+
 func (r *MpesaTransactionRepository) Update(tx *models.MpesaTransaction) error {
 	return r.db.Save(tx).Error
 }
@@ -164,3 +381,134 @@ func (r *MpesaTransactionRepository) Update(tx *models.MpesaTransaction) error {
 func (r *MpesaTransactionRepository) Delete(id uint) error {
 	return r.db.Delete(&models.MpesaTransaction{}, id).Error
 }
+
+type IntegrationCredentialRepository struct {
+	db *gorm.DB
+}
+
+func NewIntegrationCredentialRepository(db *gorm.DB) *IntegrationCredentialRepository {
+	return &IntegrationCredentialRepository{db: db}
+}
+
+func (r *IntegrationCredentialRepository) GetByShop(shopID uint, provider string) (*models.IntegrationCredential, error) {
+	var cred models.IntegrationCredential
+	err := r.db.Where("shop_id = ? AND provider = ? AND is_active = ?", shopID, provider, true).First(&cred).Error
+	if err != nil {
+		return nil, err
+	}
+	return &cred, nil
+}
+
+func (r *IntegrationCredentialRepository) GetByIdentifier(provider, identifier string) (*models.IntegrationCredential, error) {
+	var cred models.IntegrationCredential
+	err := r.db.Where("provider = ? AND identifier = ? AND is_active = ?", provider, identifier, true).First(&cred).Error
+	if err != nil {
+		return nil, err
+	}
+	return &cred, nil
+}
+
+// Save creates or replaces the shop's credentials for the provider
+func (r *IntegrationCredentialRepository) Save(cred *models.IntegrationCredential) error {
+	var existing models.IntegrationCredential
+	err := r.db.Where("shop_id = ? AND provider = ?", cred.ShopID, cred.Provider).First(&existing).Error
+	if err == nil {
+		cred.ID = existing.ID
+		cred.CreatedAt = existing.CreatedAt
+		return r.db.Save(cred).Error
+	}
+	if err != gorm.ErrRecordNotFound {
+		return err
+	}
+	return r.db.Create(cred).Error
+}
+
+func (r *IntegrationCredentialRepository) Delete(shopID uint, provider string) error {
+	return r.db.Unscoped().Where("shop_id = ? AND provider = ?", shopID, provider).Delete(&models.IntegrationCredential{}).Error
+}
+
+type B2CPayoutRepository struct {
+	db *gorm.DB
+}
+
+func NewB2CPayoutRepository(db *gorm.DB) *B2CPayoutRepository {
+	return &B2CPayoutRepository{db: db}
+}
+
+func (r *B2CPayoutRepository) Create(payout *models.B2CPayout) error {
+	return r.db.Create(payout).Error
+}
+
+func (r *B2CPayoutRepository) Update(payout *models.B2CPayout) error {
+	return r.db.Save(payout).Error
+}
+
+func (r *B2CPayoutRepository) GetByID(id uint) (*models.B2CPayout, error) {
+	var payout models.B2CPayout
+	err := r.db.First(&payout, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &payout, nil
+}
+
+// GetByConversationID finds a payout by the Daraja ConversationID or OriginatorConversationID
+func (r *B2CPayoutRepository) GetByConversationID(conversationID, originatorID string) (*models.B2CPayout, error) {
+	var payout models.B2CPayout
+	err := r.db.Where("(conversation_id = ? AND conversation_id != '') OR (originator_conversation_id = ? AND originator_conversation_id != '')",
+		conversationID, originatorID).First(&payout).Error
+	if err != nil {
+		return nil, err
+	}
+	return &payout, nil
+}
+
+func (r *B2CPayoutRepository) GetByShopID(shopID uint, limit, offset int) ([]models.B2CPayout, int64, error) {
+	var payouts []models.B2CPayout
+	var total int64
+
+	r.db.Model(&models.B2CPayout{}).Where("shop_id = ?", shopID).Count(&total)
+	err := r.db.Where("shop_id = ?", shopID).
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&payouts).Error
+
+	return payouts, total, err
+}
+
+// SumSince totals the shop's payouts since the given time, leaving out failed ones
+func (r *B2CPayoutRepository) SumSince(shopID uint, since time.Time) (float64, error) {
+	var total float64
+	err := r.db.Model(&models.B2CPayout{}).
+		Where("shop_id = ? AND created_at >= ? AND status != ?", shopID, since, models.B2CPayoutFailed).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&total).Error
+	return total, err
+}
+
+// CreateWithinLimit records the payout unless it would take the shop's
+// payouts since the given time, failed ones left out, over limit. The shop's
+// row is locked while its payouts are totalled, so concurrent payouts are
+// counted one at a time. It returns what was already sent and whether the
+// payout was recorded.
+func (r *B2CPayoutRepository) CreateWithinLimit(payout *models.B2CPayout, since time.Time, limit float64) (float64, bool, error) {
+	var sent float64
+	created := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var shop models.Shop
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&shop, payout.ShopID).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&models.B2CPayout{}).
+			Where("shop_id = ? AND created_at >= ? AND status != ?", payout.ShopID, since, models.B2CPayoutFailed).
+			Select("COALESCE(SUM(amount), 0)").
+			Scan(&sent).Error
+		if err != nil || sent+payout.Amount > limit {
+			return err
+		}
+		created = true
+		return tx.Create(payout).Error
+	})
+	return sent, created && err == nil, err
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"gorm.io/gorm"
+)
+
+// OtpCodeRepository handles one-time code storage
+type OtpCodeRepository struct {
+	db *gorm.DB
+}
+
+// NewOtpCodeRepository creates a new OTP code repository
+func NewOtpCodeRepository(db *gorm.DB) *OtpCodeRepository {
+	return &OtpCodeRepository{db: db}
+}
+
+// CreateThrottled stores a new code unless one was sent to the same
+// destination and purpose within cooldown, or limit were sent since the
+// start of the window. Storing a code spends any earlier unused one, so only
+// the latest works. On postgres the destination is locked for the
+// transaction, so concurrent requests are counted one at a time; sqlite
+// already lets only one writer through. It reports whether the code was
+// stored.
+func (r *OtpCodeRepository) CreateThrottled(code *models.OtpCode, cooldown time.Duration, since time.Time, limit int) (bool, error) {
+	created := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if tx.Dialector.Name() == "postgres" {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", code.Destination+"|"+code.Purpose).Error; err != nil {
+				return err
+			}
+		}
+
+		var recent int64
+		err := tx.Model(&models.OtpCode{}).
+			Where("destination = ? AND purpose = ? AND created_at >= ?", code.Destination, code.Purpose, time.Now().Add(-cooldown)).
+			Count(&recent).Error
+		if err != nil || recent > 0 {
+			return err
+		}
+		var sent int64
+		err = tx.Model(&models.OtpCode{}).
+			Where("destination = ? AND purpose = ? AND created_at >= ?", code.Destination, code.Purpose, since).
+			Count(&sent).Error
+		if err != nil || sent >= int64(limit) {
+			return err
+		}
+
+		if err := tx.Model(&models.OtpCode{}).
+			Where("destination = ? AND purpose = ? AND used_at IS NULL", code.Destination, code.Purpose).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		created = true
+		return tx.Create(code).Error
+	})
+	return created && err == nil, err
+}
+
+// GetActive gets the unused code for a destination and purpose
+func (r *OtpCodeRepository) GetActive(destination, purpose string) (*models.OtpCode, error) {
+	var code models.OtpCode
+	err := r.db.Where("destination = ? AND purpose = ? AND used_at IS NULL", destination, purpose).
+		Order("id DESC").First(&code).Error
+	if err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// RecordAttempt counts a wrong guess in one conditional update, spending
+// the code once it reaches maxAttempts. It returns the attempts made so far,
+// and false if the code was already spent or out of attempts, so parallel
+// guesses can't get past the limit.
+func (r *OtpCodeRepository) RecordAttempt(code *models.OtpCode, maxAttempts int) (int, bool, error) {
+	now := time.Now()
+	result := r.db.Model(&models.OtpCode{}).
+		Where("id = ? AND used_at IS NULL AND attempts < ?", code.ID, maxAttempts).
+		Updates(map[string]interface{}{
+			"attempts": gorm.Expr("attempts + 1"),
+			"used_at":  gorm.Expr("CASE WHEN attempts + 1 >= ? THEN ? ELSE used_at END", maxAttempts, now),
+		})
+	if result.Error != nil {
+		return 0, false, result.Error
+	}
+	if err := r.db.Model(&models.OtpCode{}).Select("attempts").Where("id = ?", code.ID).Scan(&code.Attempts).Error; err != nil {
+		return 0, false, err
+	}
+	return code.Attempts, result.RowsAffected == 1, nil
+}
+
+// MarkUsed spends a code. It reports false if another request spent it first.
+func (r *OtpCodeRepository) MarkUsed(code *models.OtpCode) (bool, error) {
+	now := time.Now()
+	result := r.db.Model(&models.OtpCode{}).
+		Where("id = ? AND used_at IS NULL", code.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	code.UsedAt = &now
+	return result.RowsAffected == 1, nil
+}
@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"gorm.io/gorm"
+)
+
+// messageStatusRank orders Twilio statuses so a late callback can't move
+// a message backwards; callbacks aren't guaranteed to arrive in order
+var messageStatusRank = map[string]int{
+	models.MessageStatusQueued:      1,
+	"accepted":                      1,
+	"scheduled":                     1,
+	models.MessageStatusSending:     2,
+	models.MessageStatusSent:        3,
+	models.MessageStatusDelivered:   4,
+	models.MessageStatusUndelivered: 4,
+	models.MessageStatusFailed:      4,
+	models.MessageStatusRead:        5,
+}
+
+// OutboundMessageRepository tracks WhatsApp messages sent through Twilio
+type OutboundMessageRepository struct {
+	db *gorm.DB
+}
+
+// NewOutboundMessageRepository creates a new outbound message repository
+func NewOutboundMessageRepository(db *gorm.DB) *OutboundMessageRepository {
+	return &OutboundMessageRepository{db: db}
+}
+
+// Create records a sent message
+func (r *OutboundMessageRepository) Create(message *models.OutboundMessage) error {
+	return r.db.Create(message).Error
+}
+
+// GetBySID gets a message by its Twilio SID
+func (r *OutboundMessageRepository) GetBySID(sid string) (*models.OutboundMessage, error) {
+	var message models.OutboundMessage
+	if err := r.db.Where("sid = ?", sid).First(&message).Error; err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// UpdateStatus applies a status callback. It reports false, changing
+// nothing, when the message is already at or past that status.
+func (r *OutboundMessageRepository) UpdateStatus(sid, status, errorCode, errorMessage string) (*models.OutboundMessage, bool, error) {
+	message, err := r.GetBySID(sid)
+	if err != nil {
+		return nil, false, err
+	}
+	if messageStatusRank[status] <= messageStatusRank[message.Status] {
+		return message, false, nil
+	}
+
+	message.Status = status
+	message.ErrorCode = errorCode
+	message.ErrorMessage = errorMessage
+	if status == models.MessageStatusDelivered || status == models.MessageStatusRead {
+		if message.DeliveredAt == nil {
+			now := time.Now()
+			message.DeliveredAt = &now
+		}
+	}
+	if err := r.db.Save(message).Error; err != nil {
+		return nil, false, err
+	}
+	return message, true, nil
+}
+
+// List lists messages, newest first. shopID 0 lists every shop; status
+// "failed" also matches undelivered.
+func (r *OutboundMessageRepository) List(shopID uint, status, msgType string, limit, offset int) ([]models.OutboundMessage, int64, error) {
+	var messages []models.OutboundMessage
+	var total int64
+
+	query := r.db.Model(&models.OutboundMessage{})
+	if shopID != 0 {
+		query = query.Where("shop_id = ?", shopID)
+	}
+	switch status {
+	case "":
+	case models.MessageStatusFailed:
+		query = query.Where("status IN ?", []string{models.MessageStatusFailed, models.MessageStatusUndelivered})
+	default:
+		query = query.Where("status = ?", status)
+	}
+	if msgType != "" {
+		query = query.Where("type = ?", msgType)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&messages).Error
+	return messages, total, err
+}
+
+// CountByStatus counts messages in each status, for one shop or all (0)
+func (r *OutboundMessageRepository) CountByStatus(shopID uint) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	query := r.db.Model(&models.OutboundMessage{})
+	if shopID != 0 {
+		query = query.Where("shop_id = ?", shopID)
+	}
+	if err := query.Select("status, COUNT(*) AS count").Group("status").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int64{}
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+// FailingShops lists shops whose messages failed since, with the count,
+// most failures first
+func (r *OutboundMessageRepository) FailingShops(since time.Time, limit int) ([]ShopMessageFailures, error) {
+	var rows []ShopMessageFailures
+	err := r.db.Model(&models.OutboundMessage{}).
+		Select("shop_id, COUNT(*) AS failures").
+		Where("shop_id <> 0 AND created_at >= ? AND status IN ?", since,
+			[]string{models.MessageStatusFailed, models.MessageStatusUndelivered}).
+		Group("shop_id").
+		Order("failures DESC").
+		Limit(limit).
+		Scan(&rows).Error
+	return rows, err
+}
+
+// ShopMessageFailures is a shop's failed message count
+type ShopMessageFailures struct {
+	ShopID   uint  `json:"shop_id"`
+	Failures int64 `json:"failures"`
+}
+
+// TrailingFailures counts how many of the shop's latest messages failed in
+// a row, looking back at most limit messages. Messages still in flight are
+// skipped.
+func (r *OutboundMessageRepository) TrailingFailures(shopID uint, limit int) (int, error) {
+	var statuses []string
+	err := r.db.Model(&models.OutboundMessage{}).
+		Where("shop_id = ? AND status IN ?", shopID, []string{
+			models.MessageStatusDelivered, models.MessageStatusRead,
+			models.MessageStatusFailed, models.MessageStatusUndelivered,
+		}).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Pluck("status", &statuses).Error
+	if err != nil {
+		return 0, err
+	}
+
+	failures := 0
+	for _, status := range statuses {
+		if status != models.MessageStatusFailed && status != models.MessageStatusUndelivered {
+			break
+		}
+		failures++
+	}
+	return failures, nil
+}
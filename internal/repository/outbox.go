@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"gorm.io/gorm"
+)
+
+// OutboxRepository handles queued outbound messages
+type OutboxRepository struct {
+	db *gorm.DB
+}
+
+// NewOutboxRepository creates a new outbox repository
+func NewOutboxRepository(db *gorm.DB) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// Create queues a message
+func (r *OutboxRepository) Create(message *models.OutboxMessage) error {
+	return r.db.Create(message).Error
+}
+
+// GetByID gets a message by ID
+func (r *OutboxRepository) GetByID(id uint) (*models.OutboxMessage, error) {
+	var message models.OutboxMessage
+	if err := r.db.First(&message, id).Error; err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// ClaimDue marks up to limit pending messages due by now as sending and
+// returns them, oldest first. Messages claimed before staleBefore and still
+// sending were left by a worker that stopped, and are claimed again. A
+// message another worker claimed first is skipped.
+func (r *OutboxRepository) ClaimDue(now, staleBefore time.Time, limit int) ([]models.OutboxMessage, error) {
+	claimable := r.db.Where("status = ? AND next_attempt_at <= ?", models.OutboxStatusPending, now).
+		Or("status = ? AND (claimed_at IS NULL OR claimed_at < ?)", models.OutboxStatusSending, staleBefore)
+
+	var due []models.OutboxMessage
+	err := r.db.Where(claimable).
+		Order("next_attempt_at, id").
+		Limit(limit).
+		Find(&due).Error
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := due[:0]
+	for _, m := range due {
+		result := r.db.Model(&models.OutboxMessage{}).
+			Where("id = ? AND status = ?", m.ID, m.Status).
+			Where("claimed_at IS NULL OR claimed_at = ?", m.ClaimedAt).
+			Updates(map[string]interface{}{"status": models.OutboxStatusSending, "claimed_at": now})
+		if result.Error != nil {
+			return claimed, result.Error
+		}
+		if result.RowsAffected == 1 {
+			m.Status = models.OutboxStatusSending
+			m.ClaimedAt = &now
+			claimed = append(claimed, m)
+		}
+	}
+	return claimed, nil
+}
+
+// Save updates a message after a send attempt
+func (r *OutboxRepository) Save(message *models.OutboxMessage) error {
+	return r.db.Save(message).Error
+}
+
+// List lists messages, newest first, optionally filtered by status and channel
+func (r *OutboxRepository) List(status, channel string, limit, offset int) ([]models.OutboxMessage, int64, error) {
+	var messages []models.OutboxMessage
+	var total int64
+
+	query := r.db.Model(&models.OutboxMessage{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if channel != "" {
+		query = query.Where("channel = ?", channel)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&messages).Error
+	return messages, total, err
+}
+
+// CountByStatus counts messages in each status
+func (r *OutboxRepository) CountByStatus() (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := r.db.Model(&models.OutboxMessage{}).
+		Select("status, COUNT(*) AS count").
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int64{}
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"gorm.io/gorm"
+)
+
+// ErrPaymentLinkClosed is returned when a payment link was already paid, cancelled or expired
+var ErrPaymentLinkClosed = errors.New("payment link is no longer active")
+
+// PaymentLinkRepository handles shareable payment links
+type PaymentLinkRepository struct {
+	db *gorm.DB
+}
+
+// NewPaymentLinkRepository creates a new payment link repository
+func NewPaymentLinkRepository(db *gorm.DB) *PaymentLinkRepository {
+	return &PaymentLinkRepository{db: db}
+}
+
+// Create creates a payment link
+func (r *PaymentLinkRepository) Create(link *models.PaymentLink) error {
+	return r.db.Create(link).Error
+}
+
+// GetByID gets a payment link by ID
+func (r *PaymentLinkRepository) GetByID(id uint) (*models.PaymentLink, error) {
+	var link models.PaymentLink
+	if err := r.db.First(&link, id).Error; err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// GetByToken gets a payment link by its public token
+func (r *PaymentLinkRepository) GetByToken(token string) (*models.PaymentLink, error) {
+	var link models.PaymentLink
+	if err := r.db.Where("token = ?", token).First(&link).Error; err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// GetByShop lists a shop's payment links, newest first
+func (r *PaymentLinkRepository) GetByShop(shopID uint, limit, offset int) ([]models.PaymentLink, int64, error) {
+	var links []models.PaymentLink
+	var total int64
+
+	query := r.db.Model(&models.PaymentLink{}).Where("shop_id = ?", shopID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&links).Error
+	return links, total, err
+}
+
+// SetStatus moves an active link to status, such as cancelled or expired
+func (r *PaymentLinkRepository) SetStatus(id uint, status models.PaymentLinkStatus) error {
+	result := r.db.Model(&models.PaymentLink{}).
+		Where("id = ? AND status = ?", id, models.PaymentLinkActive).
+		Update("status", status)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPaymentLinkClosed
+	}
+	return nil
+}
+
+// MarkPaid records payment against an active link. Only the first
+// payment to complete claims the link.
+func (r *PaymentLinkRepository) MarkPaid(id uint, payment *models.MpesaPayment) error {
+	now := time.Now()
+	result := r.db.Model(&models.PaymentLink{}).
+		Where("id = ? AND status = ?", id, models.PaymentLinkActive).
+		Updates(map[string]interface{}{
+			"status":        models.PaymentLinkPaid,
+			"payment_id":    payment.ID,
+			"phone":         payment.Phone,
+			"mpesa_receipt": payment.MpesaReceipt,
+			"paid_amount":   payment.Amount,
+			"paid_at":       now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPaymentLinkClosed
+	}
+	return nil
+}
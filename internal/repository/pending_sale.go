@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"gorm.io/gorm"
+)
+
+// ErrPendingSaleClosed is returned when a pending sale was already paid or cancelled
+var ErrPendingSaleClosed = errors.New("pending sale is no longer open")
+
+// PendingSaleRepository handles baskets awaiting payment
+type PendingSaleRepository struct {
+	db *gorm.DB
+}
+
+// NewPendingSaleRepository creates a new pending sale repository
+func NewPendingSaleRepository(db *gorm.DB) *PendingSaleRepository {
+	return &PendingSaleRepository{db: db}
+}
+
+// Create creates a pending sale with its items
+func (r *PendingSaleRepository) Create(sale *models.PendingSale) error {
+	return r.db.Create(sale).Error
+}
+
+// GetByID gets a pending sale with its items
+func (r *PendingSaleRepository) GetByID(id uint) (*models.PendingSale, error) {
+	var sale models.PendingSale
+	err := r.db.Preload("Items").Preload("Items.Product").First(&sale, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &sale, nil
+}
+
+// Cancel cancels an open pending sale
+func (r *PendingSaleRepository) Cancel(id uint) error {
+	result := r.db.Model(&models.PendingSale{}).
+		Where("id = ? AND status = ?", id, models.PendingSaleOpen).
+		Update("status", models.PendingSaleCancelled)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPendingSaleClosed
+	}
+	return nil
+}
+
+// Confirm marks an open pending sale paid by payment and records a sale for
+// each item, taking its stock. Nothing is recorded if the sale was already
+// closed or any item is short of stock.
+func (r *PendingSaleRepository) Confirm(id uint, payment *models.MpesaPayment) ([]models.Sale, error) {
+	var sales []models.Sale
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.PendingSale{}).
+			Where("id = ? AND status = ?", id, models.PendingSaleOpen).
+			Updates(map[string]interface{}{
+				"status":        models.PendingSalePaid,
+				"payment_id":    payment.ID,
+				"mpesa_receipt": payment.MpesaReceipt,
+				"paid_at":       now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrPendingSaleClosed
+		}
+
+		var pending models.PendingSale
+		if err := tx.Preload("Items").First(&pending, id).Error; err != nil {
+			return err
+		}
+
+		var itemsTotal float64
+		names := make([]string, 0, len(pending.Items))
+		for _, item := range pending.Items {
+			var product models.Product
+			if err := tx.First(&product, item.ProductID).Error; err != nil {
+				return err
+			}
+			names = append(names, product.Name)
+
+			total := item.UnitPrice * float64(item.Quantity)
+			cost := product.CostPrice * float64(item.Quantity)
+			itemsTotal += total
+			sales = append(sales, models.Sale{
+				ShopID:        pending.ShopID,
+				ProductID:     item.ProductID,
+				Quantity:      item.Quantity,
+				UnitPrice:     item.UnitPrice,
+				TotalAmount:   total,
+				CostAmount:    cost,
+				Profit:        total - cost,
+				PaymentMethod: models.PaymentMpesa,
+				MpesaReceipt:  payment.MpesaReceipt,
+				MpesaPhone:    payment.Phone,
+				PendingSaleID: &pending.ID,
+				Notes:         fmt.Sprintf("M-Pesa Payment: %s", payment.MpesaReceipt),
+			})
+		}
+
+		// The basket total was rounded to whole shillings; carry the
+		// difference on the last line so the sales add up to what was paid
+		if n := len(sales); n > 0 {
+			last := &sales[n-1]
+			last.RoundingAdjustment = pending.TotalAmount - itemsTotal
+			last.TotalAmount += last.RoundingAdjustment
+			last.Profit = last.TotalAmount - last.CostAmount
+		}
+
+		requireStock := !allowsNegativeStock(tx, pending.ShopID)
+		for i := range sales {
+			sale := &sales[i]
+			if err := tx.Create(sale).Error; err != nil {
+				return err
+			}
+			_, err := moveStock(tx, sale.ProductID, -sale.Quantity, models.StockMovementSale, &sale.ID, "", requireStock)
+			if errors.Is(err, ErrInsufficientStock) {
+				return fmt.Errorf("%w: %s", ErrInsufficientStock, names[i])
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sales, nil
+}
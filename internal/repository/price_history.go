@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"gorm.io/gorm"
+)
+
+// PriceHistoryRepository handles product price history database operations.
+// History rows are written by ProductRepository.UpdateWithSource.
+type PriceHistoryRepository struct {
+	db *gorm.DB
+}
+
+// NewPriceHistoryRepository creates a new price history repository
+func NewPriceHistoryRepository(db *gorm.DB) *PriceHistoryRepository {
+	return &PriceHistoryRepository{db: db}
+}
+
+// GetByProduct returns a product's price changes, newest first
+func (r *PriceHistoryRepository) GetByProduct(productID uint, limit int) ([]models.PriceHistory, error) {
+	return r.GetByProductBetween(productID, time.Time{}, time.Time{}, limit)
+}
+
+// GetByProductBetween returns a product's price changes in [from, to),
+// newest first. A zero from or to leaves that end open.
+func (r *PriceHistoryRepository) GetByProductBetween(productID uint, from, to time.Time, limit int) ([]models.PriceHistory, error) {
+	var history []models.PriceHistory
+	query := r.db.Where("product_id = ?", productID)
+	if !from.IsZero() {
+		query = query.Where("created_at >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("created_at < ?", to)
+	}
+	err := query.Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&history).Error
+	return history, err
+}
+
+// GetByShopSince returns a shop's price changes since from, oldest first
+func (r *PriceHistoryRepository) GetByShopSince(shopID uint, from time.Time) ([]models.PriceHistory, error) {
+	var history []models.PriceHistory
+	err := r.db.Where("shop_id = ? AND created_at >= ?", shopID, from).
+		Order("created_at ASC, id ASC").
+		Find(&history).Error
+	return history, err
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"gorm.io/gorm"
+)
+
+// PrinterSettingRepository handles shops' receipt printer settings
+type PrinterSettingRepository struct {
+	db *gorm.DB
+}
+
+// NewPrinterSettingRepository creates a new printer setting repository
+func NewPrinterSettingRepository(db *gorm.DB) *PrinterSettingRepository {
+	return &PrinterSettingRepository{db: db}
+}
+
+// GetByShop gets a shop's printer settings; gorm.ErrRecordNotFound means
+// the shop uses the server's printer
+func (r *PrinterSettingRepository) GetByShop(shopID uint) (*models.PrinterSetting, error) {
+	var setting models.PrinterSetting
+	if err := r.db.Where("shop_id = ?", shopID).First(&setting).Error; err != nil {
+		return nil, err
+	}
+	return &setting, nil
+}
+
+// Save creates or replaces a shop's printer settings
+func (r *PrinterSettingRepository) Save(setting *models.PrinterSetting) error {
+	if setting.ID == 0 {
+		var existing models.PrinterSetting
+		if err := r.db.Select("id", "created_at").Where("shop_id = ?", setting.ShopID).First(&existing).Error; err == nil {
+			setting.ID, setting.CreatedAt = existing.ID, existing.CreatedAt
+		}
+	}
+	return r.db.Save(setting).Error
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"gorm.io/gorm"
+)
+
+// ErrAliasTaken is returned when an alias already names another product
+var ErrAliasTaken = errors.New("alias already used")
+
+// NormalizeAlias returns an alias as it is stored and looked up
+func NormalizeAlias(alias string) string {
+	return strings.ToLower(strings.TrimSpace(alias))
+}
+
+// getByAlias gets the active product a shop has given alias to
+func (r *ProductRepository) getByAlias(shopID uint, alias string) (*models.Product, error) {
+	aliased := r.db.Model(&models.ProductAlias{}).
+		Select("product_id").
+		Where("shop_id = ? AND alias = ?", shopID, NormalizeAlias(alias))
+
+	var product models.Product
+	err := r.db.Where("shop_id = ? AND is_active = ? AND id IN (?)", shopID, true, aliased).First(&product).Error
+	if err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// GetAliases returns a product's aliases in the order they were added
+func (r *ProductRepository) GetAliases(productID uint) ([]models.ProductAlias, error) {
+	var aliases []models.ProductAlias
+	err := r.db.Where("product_id = ?", productID).Order("id ASC").Find(&aliases).Error
+	return aliases, err
+}
+
+// AddAlias gives product another name. Adding an alias it already has is
+// not an error; one used by another product or matching another product's
+// name returns ErrAliasTaken.
+func (r *ProductRepository) AddAlias(product *models.Product, alias string) (*models.ProductAlias, error) {
+	alias = NormalizeAlias(alias)
+
+	var existing models.ProductAlias
+	err := r.db.Where("shop_id = ? AND alias = ?", product.ShopID, alias).First(&existing).Error
+	if err == nil {
+		if existing.ProductID == product.ID {
+			return &existing, nil
+		}
+		return nil, ErrAliasTaken
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// A product's own name is looked up first, so the alias would never be used
+	var named int64
+	if err := r.db.Model(&models.Product{}).
+		Where("shop_id = ? AND id <> ? AND is_active = ? AND LOWER(name) = ?", product.ShopID, product.ID, true, alias).
+		Count(&named).Error; err != nil {
+		return nil, err
+	}
+	if named > 0 {
+		return nil, ErrAliasTaken
+	}
+
+	created := &models.ProductAlias{ShopID: product.ShopID, ProductID: product.ID, Alias: alias}
+	if err := r.db.Create(created).Error; err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// DeleteAlias removes one of a product's aliases, returning
+// gorm.ErrRecordNotFound if it has no such alias
+func (r *ProductRepository) DeleteAlias(productID uint, alias string) error {
+	result := r.db.Where("product_id = ? AND alias = ?", productID, NormalizeAlias(alias)).Delete(&models.ProductAlias{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"strings"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+)
+
+const (
+	// SearchSortVelocity orders search results by how fast products sell
+	SearchSortVelocity = "velocity"
+	// SearchSortName orders search results alphabetically
+	SearchSortName = "name"
+
+	// searchVelocityDays is how far back sales count toward a product's
+	// search rank; the 30.0 in Search's averages is the same
+	searchVelocityDays = 30
+)
+
+// ProductSearchResult is a product matching a search, with how fast it has
+// sold over the last 30 days
+type ProductSearchResult struct {
+	models.Product
+	AvgDailySales float64 `json:"avg_daily_sales"` // sales a day over the last 30 days
+	RecencyScore  float64 `json:"recency_score"`   // 1 when last sold today, down to 0 when not in 30 days
+	SearchRank    float64 `json:"search_rank"`     // avg_daily_sales * 0.7 + recency_score * 0.3
+}
+
+// Search returns the shop's active products whose names contain query,
+// best match first: by search rank for SearchSortVelocity, so the products
+// a cashier sells most come up first, or by name for SearchSortName
+func (r *ProductRepository) Search(shopID uint, query, sort string, limit int) ([]ProductSearchResult, error) {
+	now := time.Now()
+	since := now.AddDate(0, 0, -searchVelocityDays)
+
+	// Recency steps down by how long ago the product last sold; a CASE
+	// rather than date arithmetic so the query runs on SQLite too
+	velocity := r.db.Table("sales").
+		Select(`product_id, COUNT(*) AS sale_count,
+			MAX(CASE WHEN created_at >= ? THEN 1.0 WHEN created_at >= ? THEN 0.75 WHEN created_at >= ? THEN 0.5 ELSE 0.25 END) AS recency`,
+			now.AddDate(0, 0, -1), now.AddDate(0, 0, -7), now.AddDate(0, 0, -14)).
+		Where("shop_id = ? AND created_at >= ? AND deleted_at IS NULL", shopID, since).
+		Group("product_id")
+
+	order := "search_rank DESC, products.name ASC"
+	if sort == SearchSortName {
+		order = "products.name ASC"
+	}
+
+	var results []ProductSearchResult
+	err := r.db.Table("products").
+		Select(`products.*,
+			COALESCE(v.sale_count, 0) / 30.0 AS avg_daily_sales,
+			COALESCE(v.recency, 0) AS recency_score,
+			COALESCE(v.sale_count, 0) / 30.0 * 0.7 + COALESCE(v.recency, 0) * 0.3 AS search_rank`).
+		Joins("LEFT JOIN (?) AS v ON v.product_id = products.id", velocity).
+		Where("products.shop_id = ? AND products.is_active = ? AND products.deleted_at IS NULL", shopID, true).
+		Where("LOWER(products.name) LIKE ?", "%"+strings.ToLower(strings.TrimSpace(query))+"%").
+		Order(order).
+		Limit(limit).
+		Scan(&results).Error
+	return results, err
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"gorm.io/gorm"
+)
+
+// ReportEmailPreferenceRepository handles shops' report email settings
+type ReportEmailPreferenceRepository struct {
+	db *gorm.DB
+}
+
+// NewReportEmailPreferenceRepository creates a new report email preference repository
+func NewReportEmailPreferenceRepository(db *gorm.DB) *ReportEmailPreferenceRepository {
+	return &ReportEmailPreferenceRepository{db: db}
+}
+
+// GetByShop gets a shop's preference, or a disabled daily one if it has none
+func (r *ReportEmailPreferenceRepository) GetByShop(shopID uint) (*models.ReportEmailPreference, error) {
+	var pref models.ReportEmailPreference
+	err := r.db.Where("shop_id = ?", shopID).First(&pref).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.ReportEmailPreference{ShopID: shopID, Frequency: models.ReportFrequencyDaily}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pref, nil
+}
+
+// Save creates or updates a preference
+func (r *ReportEmailPreferenceRepository) Save(pref *models.ReportEmailPreference) error {
+	return r.db.Save(pref).Error
+}
+
+// ListEnabled lists the preferences of shops that want reports emailed
+func (r *ReportEmailPreferenceRepository) ListEnabled() ([]models.ReportEmailPreference, error) {
+	var prefs []models.ReportEmailPreference
+	err := r.db.Where("enabled = ?", true).Order("shop_id").Find(&prefs).Error
+	return prefs, err
+}
+
+// GetByRecipient lists enabled preferences that email address, including
+// those sending to the shop's own email
+func (r *ReportEmailPreferenceRepository) GetByRecipient(address string) ([]models.ReportEmailPreference, error) {
+	address = strings.ToLower(strings.TrimSpace(address))
+	var prefs []models.ReportEmailPreference
+	err := r.db.Table("report_email_preferences").
+		Select("report_email_preferences.*").
+		Joins("JOIN shops ON shops.id = report_email_preferences.shop_id").
+		Where("report_email_preferences.enabled = ?", true).
+		Where("LOWER(report_email_preferences.recipients) LIKE ? OR (COALESCE(report_email_preferences.recipients, '') = '' AND LOWER(shops.email) = ?)",
+			"%"+address+"%", address).
+		Find(&prefs).Error
+	return prefs, err
+}
@@ -1,10 +1,14 @@
 package repository
 
 import (
+	"errors"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
 	"gorm.io/gorm"
 )
 
@@ -18,8 +22,9 @@ func NewShopRepository(db *gorm.DB) *ShopRepository {
 	return &ShopRepository{db: db}
 }
 
-// Create creates a new shop
+// Create creates a new shop, storing its phone number normalised
 func (r *ShopRepository) Create(shop *models.Shop) error {
+	shop.Phone = utils.NormalizePhone(shop.Phone)
 	return r.db.Create(shop).Error
 }
 
@@ -33,16 +38,41 @@ func (r *ShopRepository) GetByID(id uint) (*models.Shop, error) {
 	return &shop, nil
 }
 
-// GetByPhone gets a shop by phone number
+// GetByPhone gets a shop by phone number in any format NormalizePhone
+// accepts. The number as given is matched too, for shops saved before
+// numbers were normalised.
 func (r *ShopRepository) GetByPhone(phone string) (*models.Shop, error) {
 	var shop models.Shop
-	err := r.db.Where("phone = ?", phone).First(&shop).Error
+	err := r.db.Where("phone IN ?", phoneVariants(phone)).First(&shop).Error
 	if err != nil {
 		return nil, err
 	}
 	return &shop, nil
 }
 
+// GetByWhatsAppNumber gets the shop whose own white-label WhatsApp number
+// is number
+func (r *ShopRepository) GetByWhatsAppNumber(number string) (*models.Shop, error) {
+	var shop models.Shop
+	err := r.db.Joins("JOIN white_label_configs w ON w.shop_id = shops.id AND w.deleted_at IS NULL").
+		Where("w.enabled = ? AND w.whats_app_number IN ?", true, phoneVariants(number)).
+		First(&shop).Error
+	if err != nil {
+		return nil, err
+	}
+	return &shop, nil
+}
+
+// phoneVariants returns the normalised phone and, if different, the phone
+// as given
+func phoneVariants(phone string) []string {
+	normalized := utils.NormalizePhone(phone)
+	if normalized == phone {
+		return []string{phone}
+	}
+	return []string{normalized, phone}
+}
+
 // GetByEmail gets a shop by email
 func (r *ShopRepository) GetByEmail(email string) (*models.Shop, error) {
 	var shop models.Shop
@@ -73,6 +103,16 @@ func (r *ShopRepository) GetByAccountID(accountID uint) (*models.Shop, error) {
 	return &shop, nil
 }
 
+// EachActive calls fn with the active shops batchSize at a time, in the
+// order they were added. An error from fn stops the scan and is returned.
+func (r *ShopRepository) EachActive(batchSize int, fn func([]models.Shop) error) error {
+	var batch []models.Shop
+	return r.db.Where("is_active = ?", true).
+		FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+			return fn(batch)
+		}).Error
+}
+
 // List lists all shops with pagination
 func (r *ShopRepository) List(limit, offset int) ([]models.Shop, int64, error) {
 	var shops []models.Shop
@@ -94,9 +134,32 @@ func NewProductRepository(db *gorm.DB) *ProductRepository {
 	return &ProductRepository{db: db}
 }
 
-// Create creates a new product
+// WithTx returns a repository that works within tx, for callers that make
+// several changes all or nothing
+func (r *ProductRepository) WithTx(tx *gorm.DB) *ProductRepository {
+	return &ProductRepository{db: tx}
+}
+
+// Create creates a new product, recording any opening stock in the
+// stock ledger
 func (r *ProductRepository) Create(product *models.Product) error {
-	return r.db.Create(product).Error
+	if product.CurrentStock == 0 {
+		return r.db.Create(product).Error
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(product).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.StockMovement{
+			ShopID:       product.ShopID,
+			ProductID:    product.ID,
+			Type:         models.StockMovementRestock,
+			Quantity:     product.CurrentStock,
+			BalanceAfter: product.CurrentStock,
+			Note:         "opening stock",
+		}).Error
+	})
 }
 
 // GetByID gets a product by ID
@@ -109,10 +172,24 @@ func (r *ProductRepository) GetByID(id uint) (*models.Product, error) {
 	return &product, nil
 }
 
-// GetByShopAndName gets a product by shop ID and name
+// GetByShopAndName gets a product by shop ID and name, or by one of the
+// shop's aliases for it when no product has that name
 func (r *ProductRepository) GetByShopAndName(shopID uint, name string) (*models.Product, error) {
 	var product models.Product
 	err := r.db.Where("shop_id = ? AND name = ? AND is_active = ?", shopID, name, true).First(&product).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return r.getByAlias(shopID, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// GetAutoDeactivated gets a product by name that was hidden for selling out
+func (r *ProductRepository) GetAutoDeactivated(shopID uint, name string) (*models.Product, error) {
+	var product models.Product
+	err := r.db.Where("shop_id = ? AND name = ? AND is_active = ? AND auto_deactivated = ?", shopID, name, false, true).First(&product).Error
 	if err != nil {
 		return nil, err
 	}
@@ -128,18 +205,52 @@ func (r *ProductRepository) GetByShopID(shopID uint) ([]models.Product, error) {
 	return products, err
 }
 
-// GetLowStock gets products below threshold
+// EachActive calls fn with a shop's active products batchSize at a time,
+// in the order they were added. An error from fn stops the scan and is
+// returned.
+func (r *ProductRepository) EachActive(shopID uint, batchSize int, fn func([]models.Product) error) error {
+	var batch []models.Product
+	return r.db.Where("shop_id = ? AND is_active = ?", shopID, true).
+		FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+			return fn(batch)
+		}).Error
+}
+
+// CountByShop counts a shop's products, inactive ones included, so that
+// deactivating products doesn't make room under the plan's limit
+func (r *ProductRepository) CountByShop(shopID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Product{}).
+		Where("shop_id = ?", shopID).
+		Count(&count).Error
+	return count, err
+}
+
+// GetLowStock gets products at or below their low stock level, by
+// percentage of max stock or by absolute threshold (see Product.LowStockLevel)
 func (r *ProductRepository) GetLowStock(shopID uint) ([]models.Product, error) {
 	var products []models.Product
-	err := r.db.Where("shop_id = ? AND is_active = ? AND current_stock <= low_stock_threshold", shopID, true).
+	err := r.db.Where("shop_id = ? AND is_active = ? AND is_bundle = ? AND current_stock <= "+models.LowStockLevelSQL, shopID, true, false).
 		Find(&products).Error
 	return products, err
 }
 
-// GetByCategory gets products by category
-func (r *ProductRepository) GetByCategory(shopID uint, category string) ([]models.Product, error) {
+// GetByCategory gets products by category, optionally including products
+// in all of its subcategories
+func (r *ProductRepository) GetByCategory(shopID uint, category string, includeSubcategories bool) ([]models.Product, error) {
+	categories := []string{category}
+	if includeSubcategories {
+		names, err := NewCategoryRepository(r.db).GetSubcategoryNames(shopID, category)
+		if err != nil {
+			return nil, err
+		}
+		if len(names) > 0 {
+			categories = names
+		}
+	}
+
 	var products []models.Product
-	err := r.db.Where("shop_id = ? AND category = ? AND is_active = ?", shopID, category, true).
+	err := r.db.Where("shop_id = ? AND category IN ? AND is_active = ?", shopID, categories, true).
 		Order("name ASC").
 		Find(&products).Error
 	return products, err
@@ -149,7 +260,7 @@ func (r *ProductRepository) GetByCategory(shopID uint, category string) ([]model
 func (r *ProductRepository) GetCategories(shopID uint) ([]string, error) {
 	var categories []string
 	err := r.db.Model(&models.Product{}).
-		Where("shop_id = ? AND category != '' AND is_active = ? AND category NOT LIKE '__category_%'", shopID, true).
+		Where("shop_id = ? AND category != '' AND is_active = ?", shopID, true).
 		Distinct("category").
 		Pluck("category", &categories).Error
 	return categories, err
@@ -172,19 +283,120 @@ func (r *ProductRepository) UpdateThreshold(id uint, threshold int) error {
 
 // Update updates a product
 func (r *ProductRepository) Update(product *models.Product) error {
-	return r.db.Save(product).Error
+	return r.UpdateWithSource(product, models.PriceSourceSystem)
 }
 
-// Delete soft deletes a product
-func (r *ProductRepository) Delete(id uint) error {
-	return r.db.Delete(&models.Product{}, id).Error
+// UpdateWithSource updates a product and, in the same transaction, records
+// any change to its selling price as made from source. A stock level set
+// directly is recorded in the stock ledger as an adjustment.
+func (r *ProductRepository) UpdateWithSource(product *models.Product, source models.PriceSource) error {
+	return r.UpdateBy(product, source, models.PriceChangedBy{})
+}
+
+// UpdateBy is UpdateWithSource for a change made by someone in particular.
+// Selling and cost price changes are both recorded in the price history.
+func (r *ProductRepository) UpdateBy(product *models.Product, source models.PriceSource, by models.PriceChangedBy) error {
+	if product.ID == 0 {
+		return r.db.Save(product).Error
+	}
+	if by.Type == "" {
+		by.Type = "system"
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var current models.Product
+		err := tx.Unscoped().Select("id", "selling_price", "cost_price", "current_stock").First(&current, product.ID).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if err := tx.Save(product).Error; err != nil {
+			return err
+		}
+
+		if current.ID != 0 && current.CurrentStock != product.CurrentStock {
+			err := tx.Create(&models.StockMovement{
+				ShopID:       product.ShopID,
+				ProductID:    product.ID,
+				Type:         models.StockMovementAdjustment,
+				Quantity:     product.CurrentStock - current.CurrentStock,
+				BalanceAfter: product.CurrentStock,
+				Note:         "stock level set",
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		if current.ID == 0 || (current.SellingPrice == product.SellingPrice && current.CostPrice == product.CostPrice) {
+			return nil
+		}
+		return tx.Create(&models.PriceHistory{
+			ShopID:        product.ShopID,
+			ProductID:     product.ID,
+			OldPrice:      current.SellingPrice,
+			NewPrice:      product.SellingPrice,
+			OldCostPrice:  current.CostPrice,
+			NewCostPrice:  product.CostPrice,
+			Source:        source,
+			ChangedByType: by.Type,
+			ChangedByID:   by.ID,
+		}).Error
+	})
 }
 
-// UpdateStock updates product stock
-func (r *ProductRepository) UpdateStock(id uint, quantity int) error {
-	return r.db.Model(&models.Product{}).
-		Where("id = ?", id).
-		Update("current_stock", gorm.Expr("current_stock + ?", quantity)).Error
+// Delete soft deletes a product
+func (r *ProductRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// A deleted product stops being a favorite rather than leaving a
+		// gap in the numbers
+		if err := tx.Where("product_id = ?", id).Delete(&models.FavoriteProduct{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Product{}, id).Error
+	})
+}
+
+// UpdateStock updates product stock, deactivating the product if that
+// sells it out and returning it if so. The change is recorded as an
+// adjustment; callers that know why stock moved should use MoveStock.
+func (r *ProductRepository) UpdateStock(id uint, quantity int) (*models.Product, error) {
+	_, soldOut, err := r.MoveStock(id, quantity, models.StockMovementAdjustment, nil, "")
+	return soldOut, err
+}
+
+// MoveStock adds quantity to a product's stock and records the movement in
+// the stock ledger, all or nothing. Taking stock out may sell the product
+// out and deactivate it, in which case the product is returned for the
+// caller to send its product.deactivated event once its work is committed.
+func (r *ProductRepository) MoveStock(id uint, quantity int, kind models.StockMovementType, referenceID *uint, note string) (*models.StockMovement, *models.Product, error) {
+	var movement *models.StockMovement
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		movement, err = moveStock(tx, id, quantity, kind, referenceID, note, false)
+		return err
+	})
+	if err != nil || quantity >= 0 {
+		return movement, nil, err
+	}
+	soldOut, err := r.DeactivateIfOutOfStock(id)
+	return movement, soldOut, err
+}
+
+// DeactivateIfOutOfStock hides a sold out product when its shop has
+// auto_deactivate_zero_stock on and returns it, or nil if it was left
+// alone. Bundles hold no stock of their own and are left alone. The
+// caller sends the product.deactivated event once its work is committed.
+func (r *ProductRepository) DeactivateIfOutOfStock(id uint) (*models.Product, error) {
+	autoShops := r.db.Model(&models.Shop{}).Select("id").Where("auto_deactivate_zero_stock = ?", true)
+	result := r.db.Model(&models.Product{}).
+		Where("id = ? AND is_active = ? AND is_bundle = ? AND current_stock <= 0", id, true, false).
+		Where("shop_id IN (?)", autoShops).
+		Updates(map[string]interface{}{"is_active": false, "auto_deactivated": true})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+	return r.GetByID(id)
 }
 
 // SaleRepository handles sale database operations
@@ -197,11 +409,42 @@ func NewSaleRepository(db *gorm.DB) *SaleRepository {
 	return &SaleRepository{db: db}
 }
 
+// WithTx returns a repository that works within tx
+func (r *SaleRepository) WithTx(tx *gorm.DB) *SaleRepository {
+	return &SaleRepository{db: tx}
+}
+
 // Create creates a new sale
 func (r *SaleRepository) Create(sale *models.Sale) error {
 	return r.db.Create(sale).Error
 }
 
+// RecordSale creates a sale and takes its quantity out of stock, all or
+// nothing. It returns ErrInsufficientStock if the stock can't cover the
+// sale, unless the shop allows negative stock, and deactivates the product
+// if the sale sells it out, returning it for the caller to send its
+// product.deactivated event once its work is committed.
+func (r *SaleRepository) RecordSale(sale *models.Sale) (*models.Product, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(sale).Error; err != nil {
+			return err
+		}
+		requireStock := !allowsNegativeStock(tx, sale.ShopID)
+		_, err := moveStock(tx, sale.ProductID, -sale.Quantity, models.StockMovementSale, &sale.ID, "", requireStock)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	products := &ProductRepository{db: r.db}
+	soldOut, err := products.DeactivateIfOutOfStock(sale.ProductID)
+	if err != nil {
+		log.Printf("⚠️ Failed to deactivate sold out product %d: %v", sale.ProductID, err)
+	}
+	return soldOut, nil
+}
+
 // GetByID gets a sale by ID
 func (r *SaleRepository) GetByID(id uint) (*models.Sale, error) {
 	var sale models.Sale
@@ -212,6 +455,20 @@ func (r *SaleRepository) GetByID(id uint) (*models.Sale, error) {
 	return &sale, nil
 }
 
+// GetBasket gets the sales printed on a sale's receipt: the sale itself or,
+// when it was paid for in a basket, every sale in the basket
+func (r *SaleRepository) GetBasket(sale *models.Sale) ([]models.Sale, error) {
+	query := r.db.Preload("Product").Preload("Customer").Preload("Staff")
+	if sale.PendingSaleID != nil {
+		query = query.Where("shop_id = ? AND pending_sale_id = ?", sale.ShopID, *sale.PendingSaleID)
+	} else {
+		query = query.Where("id = ?", sale.ID)
+	}
+	var sales []models.Sale
+	err := query.Order("id").Find(&sales).Error
+	return sales, err
+}
+
 // GetByShopID gets all sales for a shop
 func (r *SaleRepository) GetByShopID(shopID uint, limit int) ([]models.Sale, error) {
 	var sales []models.Sale
@@ -242,6 +499,101 @@ func (r *SaleRepository) GetByDateRange(shopID uint, start, end time.Time) ([]mo
 	return sales, err
 }
 
+// SaleFilter narrows a shop's sales; empty fields match every sale
+type SaleFilter struct {
+	PaymentMethod string
+	ProductID     uint
+	Category      string // matched without regard to case
+}
+
+// GetFiltered gets a shop's sales made in [start, end) that match filter,
+// newest first
+func (r *SaleRepository) GetFiltered(shopID uint, start, end time.Time, filter SaleFilter) ([]models.Sale, error) {
+	var sales []models.Sale
+	err := r.filtered(shopID, start, end, filter).Preload("Product").Order("sales.created_at DESC").Find(&sales).Error
+	return sales, err
+}
+
+// EachFiltered calls fn with the sales GetFiltered would return, oldest
+// first, batchSize at a time, so exports of long periods never hold every
+// sale in memory. An error from fn stops the scan and is returned.
+func (r *SaleRepository) EachFiltered(shopID uint, start, end time.Time, filter SaleFilter, batchSize int, fn func([]models.Sale) error) error {
+	var batch []models.Sale
+	return r.filtered(shopID, start, end, filter).Preload("Product").
+		FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+			return fn(batch)
+		}).Error
+}
+
+// EachFilteredNewest calls fn with the sales GetFiltered would return, in
+// the same newest first order, batchSize at a time. Each batch is read
+// after the last sale of the one before, so no batch re-reads earlier rows
+// however far in it is. An error from fn stops the scan and is returned.
+func (r *SaleRepository) EachFilteredNewest(shopID uint, start, end time.Time, filter SaleFilter, batchSize int, fn func([]models.Sale) error) error {
+	var last *models.Sale
+	for {
+		query := r.filtered(shopID, start, end, filter)
+		if last != nil {
+			query = query.Where("(sales.created_at < ? OR (sales.created_at = ? AND sales.id < ?))",
+				last.CreatedAt, last.CreatedAt, last.ID)
+		}
+		var batch []models.Sale
+		err := query.Preload("Product").Order("sales.created_at DESC, sales.id DESC").Limit(batchSize).Find(&batch).Error
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+		last = &batch[len(batch)-1]
+	}
+}
+
+// filtered selects a shop's sales made in [start, end) matching filter
+func (r *SaleRepository) filtered(shopID uint, start, end time.Time, filter SaleFilter) *gorm.DB {
+	query := r.db.Where("sales.shop_id = ? AND sales.created_at >= ? AND sales.created_at < ?", shopID, start, end)
+	if filter.PaymentMethod != "" {
+		query = query.Where("sales.payment_method = ?", filter.PaymentMethod)
+	}
+	if filter.ProductID != 0 {
+		query = query.Where("sales.product_id = ?", filter.ProductID)
+	}
+	if filter.Category != "" {
+		inCategory := r.db.Model(&models.Product{}).
+			Select("id").
+			Where("shop_id = ? AND LOWER(category) = ?", shopID, strings.ToLower(filter.Category))
+		query = query.Where("sales.product_id IN (?)", inCategory)
+	}
+	return query
+}
+
+// GetMpesaByDateRange gets a shop's M-Pesa sales made in [start, end)
+func (r *SaleRepository) GetMpesaByDateRange(shopID uint, start, end time.Time) ([]models.Sale, error) {
+	var sales []models.Sale
+	err := r.db.Where("shop_id = ? AND payment_method = ? AND created_at >= ? AND created_at < ?",
+		shopID, models.PaymentMpesa, start, end).
+		Order("created_at ASC").
+		Find(&sales).Error
+	return sales, err
+}
+
+// GetByMpesaReceipts gets a shop's sales paid with any of the given M-Pesa receipts
+func (r *SaleRepository) GetByMpesaReceipts(shopID uint, receipts []string) ([]models.Sale, error) {
+	var sales []models.Sale
+	if len(receipts) == 0 {
+		return sales, nil
+	}
+	err := r.db.Where("shop_id = ? AND mpesa_receipt IN ?", shopID, receipts).
+		Find(&sales).Error
+	return sales, err
+}
+
 // GetTodaySales gets today's sales for a shop
 func (r *SaleRepository) GetTodaySales(shopID uint) ([]models.Sale, error) {
 	startOfDay := time.Now().Truncate(24 * time.Hour)
@@ -249,19 +601,23 @@ func (r *SaleRepository) GetTodaySales(shopID uint) ([]models.Sale, error) {
 	return r.GetByDateRange(shopID, startOfDay, endOfDay)
 }
 
-// GetTopProducts gets top selling products for a shop
+// GetTopProducts gets a shop's best selling products of the last week,
+// best first
 func (r *SaleRepository) GetTopProducts(shopID uint, limit int) ([]models.Product, error) {
+	return topSellers(r.db, shopID, time.Now().AddDate(0, 0, -7), limit)
+}
+
+// topSellers gets the products a shop sold most of since since, best first
+func topSellers(db *gorm.DB, shopID uint, since time.Time, limit int) ([]models.Product, error) {
 	type result struct {
 		ProductID uint
 		TotalSold float64
 	}
 	var results []result
 
-	startOfWeek := time.Now().AddDate(0, 0, -7)
-
-	err := r.db.Model(&models.Sale{}).
+	err := db.Model(&models.Sale{}).
 		Select("product_id, SUM(quantity) as total_sold").
-		Where("shop_id = ? AND created_at >= ?", shopID, startOfWeek).
+		Where("shop_id = ? AND created_at >= ?", shopID, since).
 		Group("product_id").
 		Order("total_sold DESC").
 		Limit(limit).
@@ -279,9 +635,44 @@ func (r *SaleRepository) GetTopProducts(shopID uint, limit int) ([]models.Produc
 		productIDs = append(productIDs, res.ProductID)
 	}
 
-	var products []models.Product
-	err = r.db.Where("id IN ?", productIDs).Find(&products).Error
-	return products, err
+	var found []models.Product
+	if err := db.Where("id IN ?", productIDs).Find(&found).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.Product, len(found))
+	for _, p := range found {
+		byID[p.ID] = p
+	}
+	products := make([]models.Product, 0, len(found))
+	for _, id := range productIDs {
+		if p, ok := byID[id]; ok {
+			products = append(products, p)
+		}
+	}
+	return products, nil
+}
+
+// ProductTotal is one product's sales over a period
+type ProductTotal struct {
+	ProductID uint
+	Name      string
+	Quantity  int
+	Revenue   float64
+}
+
+// GetProductTotals sums each product's sales from start up to end, best
+// sellers by quantity first
+func (r *SaleRepository) GetProductTotals(shopID uint, start, end time.Time, limit int) ([]ProductTotal, error) {
+	var totals []ProductTotal
+	err := r.db.Model(&models.Sale{}).
+		Select("sales.product_id, products.name, SUM(sales.quantity) AS quantity, SUM(sales.total_amount) AS revenue").
+		Joins("JOIN products ON products.id = sales.product_id").
+		Where("sales.shop_id = ? AND sales.created_at >= ? AND sales.created_at < ?", shopID, start, end).
+		Group("sales.product_id, products.name").
+		Order("quantity DESC, products.name ASC").
+		Limit(limit).
+		Scan(&totals).Error
+	return totals, err
 }
 
 // GetTotalSales gets total sales amount for a shop
@@ -297,6 +688,23 @@ func (r *SaleRepository) GetTotalSales(shopID uint, start, end time.Time) (float
 	return result.Total, result.Count, err
 }
 
+// SaleTotals is what a shop's sales took and cost over a period
+type SaleTotals struct {
+	Sales        float64 `json:"sales"`
+	Cost         float64 `json:"cost"` // cost of the goods sold
+	Transactions int     `json:"transactions"`
+}
+
+// GetTotals sums the shop's sales and their cost from start up to end
+func (r *SaleRepository) GetTotals(shopID uint, start, end time.Time) (SaleTotals, error) {
+	var totals SaleTotals
+	err := r.db.Model(&models.Sale{}).
+		Select("COALESCE(SUM(total_amount), 0) AS sales, COALESCE(SUM(cost_amount), 0) AS cost, COUNT(*) AS transactions").
+		Where("shop_id = ? AND created_at >= ? AND created_at < ?", shopID, start, end).
+		Scan(&totals).Error
+	return totals, err
+}
+
 // DailySummaryRepository handles daily summary database operations
 type DailySummaryRepository struct {
 	db *gorm.DB
@@ -386,6 +794,11 @@ func NewAuditLogRepository(db *gorm.DB) *AuditLogRepository {
 	return &AuditLogRepository{db: db}
 }
 
+// WithTx returns a repository that works within tx
+func (r *AuditLogRepository) WithTx(tx *gorm.DB) *AuditLogRepository {
+	return &AuditLogRepository{db: tx}
+}
+
 // Create creates a new audit log
 func (r *AuditLogRepository) Create(log *models.AuditLog) error {
 	return r.db.Create(log).Error
@@ -533,6 +946,13 @@ func (r *StaffRepository) GetByShopID(shopID uint) ([]models.Staff, error) {
 	return staff, err
 }
 
+// CountByShop counts a shop's staff members
+func (r *StaffRepository) CountByShop(shopID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Staff{}).Where("shop_id = ?", shopID).Count(&count).Error
+	return count, err
+}
+
 // Update updates a staff member
 func (r *StaffRepository) Update(staff *models.Staff) error {
 	return r.db.Save(staff).Error
@@ -620,6 +1040,13 @@ func (r *AccountRepository) Delete(id uint) error {
 	return r.db.Delete(&models.Account{}, id).Error
 }
 
+// GetAdmins gets the active admin accounts
+func (r *AccountRepository) GetAdmins() ([]models.Account, error) {
+	var admins []models.Account
+	err := r.db.Where("is_admin = ? AND is_active = ?", true, true).Find(&admins).Error
+	return admins, err
+}
+
 // ShopRepositoryWithAccount handles shop operations with account support
 type ShopRepositoryWithAccount struct {
 	db *gorm.DB
@@ -651,6 +1078,7 @@ func (r *ShopRepositoryWithAccount) GetByAccountID(accountID uint) ([]models.Sho
 
 // Create creates a new shop for an account
 func (r *ShopRepositoryWithAccount) Create(shop *models.Shop) error {
+	shop.Phone = utils.NormalizePhone(shop.Phone)
 	return r.db.Create(shop).Error
 }
 
@@ -674,10 +1102,10 @@ func (r *ShopRepositoryWithAccount) GetByID(id uint) (*models.Shop, error) {
 	return &shop, nil
 }
 
-// GetByPhone gets a shop by phone
+// GetByPhone gets a shop by phone, as ShopRepository.GetByPhone does
 func (r *ShopRepositoryWithAccount) GetByPhone(phone string) (*models.Shop, error) {
 	var shop models.Shop
-	err := r.db.Where("phone = ?", phone).First(&shop).Error
+	err := r.db.Where("phone IN ?", phoneVariants(phone)).First(&shop).Error
 	if err != nil {
 		return nil, err
 	}
@@ -812,6 +1240,11 @@ func NewCustomerRepository(db *gorm.DB) *CustomerRepository {
 	return &CustomerRepository{db: db}
 }
 
+// WithTx returns a repository that works within tx
+func (r *CustomerRepository) WithTx(tx *gorm.DB) *CustomerRepository {
+	return &CustomerRepository{db: tx}
+}
+
 // Create creates a new customer
 func (r *CustomerRepository) Create(customer *models.Customer) error {
 	return r.db.Create(customer).Error
@@ -844,6 +1277,16 @@ func (r *CustomerRepository) GetByShopID(shopID uint) ([]models.Customer, error)
 	return customers, err
 }
 
+// EachForExport calls fn with a shop's customers, oldest first, batchSize
+// at a time
+func (r *CustomerRepository) EachForExport(shopID uint, batchSize int, fn func([]models.Customer) error) error {
+	return eachOldest(func() *gorm.DB {
+		return r.db.Where("shop_id = ?", shopID)
+	}, "created_at", batchSize, func(c models.Customer) (time.Time, uint) {
+		return c.CreatedAt, c.ID
+	}, fn)
+}
+
 // GetByTier gets customers by tier
 func (r *CustomerRepository) GetByTier(shopID uint, tier string) ([]models.Customer, error) {
 	var customers []models.Customer
@@ -851,6 +1294,57 @@ func (r *CustomerRepository) GetByTier(shopID uint, tier string) ([]models.Custo
 	return customers, err
 }
 
+// CustomerFilter selects a shop's customers; zero fields are ignored
+type CustomerFilter struct {
+	Tier               models.LoyaltyTier
+	MinPoints          int
+	LastPurchaseBefore *time.Time
+	LastPurchaseAfter  *time.Time
+}
+
+// Find gets a shop's active customers with a phone number matching filter
+func (r *CustomerRepository) Find(shopID uint, filter CustomerFilter) ([]models.Customer, error) {
+	query := r.db.Where("shop_id = ? AND is_active = ? AND phone <> ''", shopID, true)
+	if filter.Tier != "" {
+		query = query.Where("tier = ?", filter.Tier)
+	}
+	if filter.MinPoints > 0 {
+		query = query.Where("loyalty_points >= ?", filter.MinPoints)
+	}
+	if filter.LastPurchaseBefore != nil {
+		query = query.Where("last_purchase_at < ?", *filter.LastPurchaseBefore)
+	}
+	if filter.LastPurchaseAfter != nil {
+		query = query.Where("last_purchase_at >= ?", *filter.LastPurchaseAfter)
+	}
+
+	var customers []models.Customer
+	err := query.Order("id").Find(&customers).Error
+	return customers, err
+}
+
+// GetBirthdays gets a shop's active customers born on the day of on. On
+// 28 February in a non-leap year it includes those born on 29 February.
+func (r *CustomerRepository) GetBirthdays(shopID uint, on time.Time) ([]models.Customer, error) {
+	month, day := int(on.Month()), on.Day()
+
+	birthday := "EXTRACT(month FROM date_of_birth) = ? AND EXTRACT(day FROM date_of_birth) = ?"
+	if r.db.Dialector.Name() == "sqlite" {
+		birthday = "CAST(strftime('%m', date_of_birth) AS INTEGER) = ? AND CAST(strftime('%d', date_of_birth) AS INTEGER) = ?"
+	}
+
+	query := r.db.Where("shop_id = ? AND is_active = ? AND date_of_birth IS NOT NULL", shopID, true)
+	if month == 2 && day == 28 && on.AddDate(0, 0, 1).Day() == 1 {
+		query = query.Where(r.db.Where(birthday, 2, 28).Or(birthday, 2, 29))
+	} else {
+		query = query.Where(birthday, month, day)
+	}
+
+	var customers []models.Customer
+	err := query.Order("id").Find(&customers).Error
+	return customers, err
+}
+
 // Update updates a customer
 func (r *CustomerRepository) Update(customer *models.Customer) error {
 	return r.db.Save(customer).Error
@@ -941,6 +1435,17 @@ func (r *LoyaltyTransactionRepository) GetByDateRange(shopID uint, start, end ti
 	return transactions, err
 }
 
+// EachForExport calls fn with a shop's transactions made in [start, end),
+// with their customers, oldest first, batchSize at a time; a zero start or
+// end leaves that side open
+func (r *LoyaltyTransactionRepository) EachForExport(shopID uint, start, end time.Time, batchSize int, fn func([]models.LoyaltyTransaction) error) error {
+	return eachOldest(func() *gorm.DB {
+		return r.db.Preload("Customer").Where("shop_id = ?", shopID).Scopes(createdBetween("created_at", start, end))
+	}, "created_at", batchSize, func(t models.LoyaltyTransaction) (time.Time, uint) {
+		return t.CreatedAt, t.ID
+	}, fn)
+}
+
 // ============================================
 // Supplier Repository
 // ============================================
@@ -980,7 +1485,7 @@ func (r *SupplierRepository) GetByShopID(shopID uint) ([]models.Supplier, error)
 // GetByName gets a supplier by name
 func (r *SupplierRepository) GetByName(shopID uint, name string) (*models.Supplier, error) {
 	var supplier models.Supplier
-	err := r.db.Where("shop_id = ? AND name ILIKE ?", shopID, "%"+name+"%").First(&supplier).Error
+	err := r.db.Where("shop_id = ? AND LOWER(name) LIKE ?", shopID, "%"+strings.ToLower(name)+"%").First(&supplier).Error
 	if err != nil {
 		return nil, err
 	}
@@ -1040,6 +1545,63 @@ func (r *OrderRepository) GetByStatus(shopID uint, status string) ([]models.Orde
 	return orders, err
 }
 
+// payableOrder matches orders that can take a supplier payout: not fully
+// paid and with no payout in flight
+func payableOrder(db *gorm.DB) *gorm.DB {
+	return db.Where("payment_status IN ? OR payment_status IS NULL OR payment_status = ''",
+		[]string{models.OrderPaymentUnpaid, models.OrderPaymentPartial, models.OrderPaymentFailed})
+}
+
+// ListUnpaidBySupplier gets a shop's sent orders to a supplier that are not
+// fully paid, oldest first, including ones whose payment failed
+func (r *OrderRepository) ListUnpaidBySupplier(shopID, supplierID uint) ([]models.Order, error) {
+	var orders []models.Order
+	err := r.db.Where("shop_id = ? AND supplier_id = ? AND status NOT IN ?",
+		shopID, supplierID, []string{models.OrderStatusDraft, models.OrderStatusCancelled}).
+		Scopes(payableOrder).
+		Order("created_at ASC, id ASC").Find(&orders).Error
+	return orders, err
+}
+
+// ClaimForPayment marks an order processing before a payout is sent, unless
+// another payout for it is already in flight. It reports whether it did.
+func (r *OrderRepository) ClaimForPayment(orderID uint) (bool, error) {
+	result := r.db.Model(&models.Order{}).Where("id = ?", orderID).Scopes(payableOrder).
+		Update("payment_status", models.OrderPaymentProcessing)
+	return result.RowsAffected == 1, result.Error
+}
+
+// ReleasePayment puts a processing order back to status when its payout
+// could not be sent
+func (r *OrderRepository) ReleasePayment(orderID uint, status string) error {
+	return r.db.Model(&models.Order{}).
+		Where("id = ? AND payment_status = ?", orderID, models.OrderPaymentProcessing).
+		Update("payment_status", status).Error
+}
+
+// RecordPayment adds a completed payout to the order's amount paid, marking
+// it paid once the total is covered and partly paid until then
+func (r *OrderRepository) RecordPayment(shopID, orderID, payoutID uint, amount float64) error {
+	return r.db.Model(&models.Order{}).Where("id = ? AND shop_id = ?", orderID, shopID).
+		Updates(map[string]interface{}{
+			"amount_paid": gorm.Expr("amount_paid + ?", amount),
+			"payment_status": gorm.Expr("CASE WHEN amount_paid + ? >= total_amount THEN ? ELSE ? END",
+				amount, models.OrderPaymentPaid, models.OrderPaymentPartial),
+			"B2CPayoutID": payoutID,
+		}).Error
+}
+
+// FailPayment marks the order's payout failed, keeping it partly paid if
+// earlier payouts went through
+func (r *OrderRepository) FailPayment(shopID, orderID, payoutID uint) error {
+	return r.db.Model(&models.Order{}).Where("id = ? AND shop_id = ?", orderID, shopID).
+		Updates(map[string]interface{}{
+			"payment_status": gorm.Expr("CASE WHEN amount_paid > 0 THEN ? ELSE ? END",
+				models.OrderPaymentPartial, models.OrderPaymentFailed),
+			"B2CPayoutID": payoutID,
+		}).Error
+}
+
 // Update updates an order
 func (r *OrderRepository) Update(order *models.Order) error {
 	return r.db.Save(order).Error
@@ -1062,7 +1624,73 @@ func (r *OrderRepository) GetItems(orderID uint) ([]models.OrderItem, error) {
 	return items, err
 }
 
+// HasOpenOrderFor reports whether a draft or pending order already includes the product
+func (r *OrderRepository) HasOpenOrderFor(productID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.OrderItem{}).
+		Joins("JOIN orders ON orders.id = order_items.order_id AND orders.deleted_at IS NULL").
+		Where("order_items.product_id = ? AND orders.status IN ?", productID,
+			[]string{models.OrderStatusDraft, models.OrderStatusPending}).
+		Count(&count).Error
+	return count > 0, err
+}
+
 // DeleteItems deletes all items for an order
 func (r *OrderRepository) DeleteItems(orderID uint) error {
 	return r.db.Where("order_id = ?", orderID).Delete(&models.OrderItem{}).Error
 }
+
+// EachForExport calls fn with a shop's orders placed in [start, end), with
+// their suppliers and items, oldest first, batchSize at a time; a zero
+// start or end leaves that side open
+func (r *OrderRepository) EachForExport(shopID uint, start, end time.Time, batchSize int, fn func([]models.Order) error) error {
+	return eachOldest(func() *gorm.DB {
+		return r.db.Preload("Supplier").Preload("Items.Product").
+			Where("shop_id = ?", shopID).Scopes(createdBetween("created_at", start, end))
+	}, "created_at", batchSize, func(o models.Order) (time.Time, uint) {
+		return o.CreatedAt, o.ID
+	}, fn)
+}
+
+// createdBetween keeps rows whose column is in [start, end), leaving a
+// side open when its bound is zero
+func createdBetween(column string, start, end time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if !start.IsZero() {
+			db = db.Where(column+" >= ?", start)
+		}
+		if !end.IsZero() {
+			db = db.Where(column+" < ?", end)
+		}
+		return db
+	}
+}
+
+// eachOldest calls fn with the rows query selects, oldest first by column
+// then ID, batchSize at a time. As in EachFilteredNewest, each batch is
+// read after the last row of the one before, whose column value and ID key
+// returns. An error from fn stops the scan and is returned.
+func eachOldest[T any](query func() *gorm.DB, column string, batchSize int, key func(T) (time.Time, uint), fn func([]T) error) error {
+	var lastAt time.Time
+	var lastID uint
+	for first := true; ; first = false {
+		q := query()
+		if !first {
+			q = q.Where("("+column+" > ? OR ("+column+" = ? AND id > ?))", lastAt, lastAt, lastID)
+		}
+		var batch []T
+		if err := q.Order(column + " ASC, id ASC").Limit(batchSize).Find(&batch).Error; err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+		lastAt, lastID = key(batch[len(batch)-1])
+	}
+}
@@ -0,0 +1,281 @@
+package repository
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"gorm.io/gorm"
+)
+
+// Rollups cover the weeks and months of summary days; see
+// models.WeekStart and models.MonthStart
+
+const summaryDayLength = models.SummaryDayLength
+
+// summaryDay returns the start of the summary day t is in
+func summaryDay(t time.Time) time.Time {
+	return t.Truncate(summaryDayLength)
+}
+
+// nextMonth returns the first day of the month after the one starting at
+// month
+func nextMonth(month time.Time) time.Time {
+	u := month.UTC()
+	return time.Date(u.Year(), u.Month()+1, 1, 0, 0, 0, 0, time.UTC).In(month.Location())
+}
+
+// SummaryTotals are a shop's sales totals over a period
+type SummaryTotals struct {
+	Sales        float64
+	Transactions int
+	Profit       float64
+	Cost         float64
+}
+
+func (t *SummaryTotals) add(o SummaryTotals) {
+	t.Sales += o.Sales
+	t.Transactions += o.Transactions
+	t.Profit += o.Profit
+	t.Cost += o.Cost
+}
+
+// RollupWeek stores the total of the daily summaries of the week t is in,
+// recalculating each day from its sales first so days without a summary
+// count too
+func (r *DailySummaryRepository) RollupWeek(shopID uint, t time.Time) (*models.WeeklySummary, error) {
+	start := models.WeekStart(t)
+	totals, err := r.rollupDays(shopID, start, start.Add(7*summaryDayLength))
+	if err != nil {
+		return nil, err
+	}
+
+	var summary models.WeeklySummary
+	err = r.db.Where("shop_id = ? AND week_start = ?", shopID, start).First(&summary).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		summary = models.WeeklySummary{ShopID: shopID, WeekStart: start}
+	} else if err != nil {
+		return nil, err
+	}
+	summary.TotalSales = totals.Sales
+	summary.TotalTransactions = totals.Transactions
+	summary.TotalProfit = totals.Profit
+	summary.TotalCost = totals.Cost
+	return &summary, r.db.Save(&summary).Error
+}
+
+// RollupMonth stores the total of the daily summaries of the month t is
+// in, recalculating each day from its sales first
+func (r *DailySummaryRepository) RollupMonth(shopID uint, t time.Time) (*models.MonthlySummary, error) {
+	start := models.MonthStart(t)
+	totals, err := r.rollupDays(shopID, start, nextMonth(start))
+	if err != nil {
+		return nil, err
+	}
+
+	var summary models.MonthlySummary
+	err = r.db.Where("shop_id = ? AND month = ?", shopID, start).First(&summary).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		summary = models.MonthlySummary{ShopID: shopID, Month: start}
+	} else if err != nil {
+		return nil, err
+	}
+	summary.TotalSales = totals.Sales
+	summary.TotalTransactions = totals.Transactions
+	summary.TotalProfit = totals.Profit
+	summary.TotalCost = totals.Cost
+	return &summary, r.db.Save(&summary).Error
+}
+
+// Rollups missing for the last RollupCatchUpWeeks weeks and
+// RollupCatchUpMonths months are taken by RollupDue; older periods without
+// one are added up from their days instead
+const (
+	RollupCatchUpWeeks  = 8
+	RollupCatchUpMonths = 3
+)
+
+// rollupShopPage is how many shops RollupDue looks at per query
+const rollupShopPage = 500
+
+// RollupDue rolls up the weeks and months that have ended lately but have
+// no rollup, at most limit of them, and returns how many it rolled up. A
+// period has none until it is first rolled up, or again once one of its
+// sales changes, so each run picks up where the last one stopped and a
+// restart catches up on the periods missed while the server was down.
+func (r *DailySummaryRepository) RollupDue(now time.Time, limit int) (int, error) {
+	thisWeek := models.WeekStart(now)
+	thisMonth := models.MonthStart(now)
+	firstWeek := thisWeek.Add(-RollupCatchUpWeeks * 7 * summaryDayLength)
+	firstMonth := thisMonth
+	for i := 0; i < RollupCatchUpMonths; i++ {
+		firstMonth = models.MonthStart(firstMonth.Add(-summaryDayLength))
+	}
+
+	type period struct {
+		shopID uint
+		start  int64
+	}
+	rolled := 0
+	for lastID := uint(0); ; {
+		var shops []models.Shop
+		err := r.db.Select("id", "name", "created_at").
+			Where("is_active = ? AND id > ?", true, lastID).
+			Order("id").Limit(rollupShopPage).Find(&shops).Error
+		if err != nil || len(shops) == 0 {
+			return rolled, err
+		}
+		lastID = shops[len(shops)-1].ID
+		ids := make([]uint, len(shops))
+		for i, shop := range shops {
+			ids[i] = shop.ID
+		}
+
+		have := make(map[period]bool)
+		var weeks []models.WeeklySummary
+		if err := r.db.Select("shop_id", "week_start").Where("shop_id IN ? AND week_start >= ?", ids, firstWeek).Find(&weeks).Error; err != nil {
+			return rolled, err
+		}
+		for _, w := range weeks {
+			have[period{w.ShopID, w.WeekStart.Unix()}] = true
+		}
+		var months []models.MonthlySummary
+		if err := r.db.Select("shop_id", "month").Where("shop_id IN ? AND month >= ?", ids, firstMonth).Find(&months).Error; err != nil {
+			return rolled, err
+		}
+		for _, m := range months {
+			have[period{m.ShopID, m.Month.Unix()}] = true
+		}
+
+		for _, shop := range shops {
+			for week := firstWeek; week.Before(thisWeek); week = week.Add(7 * summaryDayLength) {
+				if have[period{shop.ID, week.Unix()}] || !week.Add(7*summaryDayLength).After(shop.CreatedAt) {
+					continue
+				}
+				if _, err := r.RollupWeek(shop.ID, week); err != nil {
+					log.Printf("❌ Failed to roll up the week of %s for shop %s: %v", week.Format("2 Jan"), shop.Name, err)
+				}
+				if rolled++; rolled >= limit {
+					return rolled, nil
+				}
+			}
+			for month := firstMonth; month.Before(thisMonth); month = nextMonth(month) {
+				if have[period{shop.ID, month.Unix()}] || !nextMonth(month).After(shop.CreatedAt) {
+					continue
+				}
+				if _, err := r.RollupMonth(shop.ID, month); err != nil {
+					log.Printf("❌ Failed to roll up %s for shop %s: %v", month.Format("Jan 2006"), shop.Name, err)
+				}
+				if rolled++; rolled >= limit {
+					return rolled, nil
+				}
+			}
+		}
+	}
+}
+
+// rollupDays recalculates the daily summaries from start up to end and
+// adds them up
+func (r *DailySummaryRepository) rollupDays(shopID uint, start, end time.Time) (SummaryTotals, error) {
+	for day := start; day.Before(end); day = day.Add(summaryDayLength) {
+		if err := r.Recalculate(shopID, day); err != nil {
+			return SummaryTotals{}, err
+		}
+	}
+
+	var totals SummaryTotals
+	err := r.db.Model(&models.DailySummary{}).
+		Select(
+			"COALESCE(SUM(total_sales), 0) AS sales",
+			"COALESCE(SUM(total_transactions), 0) AS transactions",
+			"COALESCE(SUM(total_profit), 0) AS profit",
+			"COALESCE(SUM(total_cost), 0) AS cost",
+		).
+		Where("shop_id = ? AND date >= ? AND date < ?", shopID, start, end).
+		Scan(&totals).Error
+	return totals, err
+}
+
+// Totals adds up a shop's sales over the summary days from start's up to
+// end's, counting end's day when end is not its first instant. Rolled-up
+// months and weeks inside the period are read first, then daily summaries
+// for the days left, and the sales themselves only for days with no
+// summary at all.
+func (r *DailySummaryRepository) Totals(shopID uint, start, end time.Time) (SummaryTotals, error) {
+	var totals SummaryTotals
+	if day := summaryDay(end); !day.Equal(end) {
+		end = day.Add(summaryDayLength)
+	}
+	start = summaryDay(start)
+	covered := make(map[int64]bool)
+	cover := func(from, to time.Time) bool {
+		for day := from; day.Before(to); day = day.Add(summaryDayLength) {
+			if covered[day.Unix()] {
+				return false
+			}
+		}
+		for day := from; day.Before(to); day = day.Add(summaryDayLength) {
+			covered[day.Unix()] = true
+		}
+		return true
+	}
+
+	var months []models.MonthlySummary
+	if err := r.db.Where("shop_id = ? AND month >= ? AND month < ?", shopID, start, end).Find(&months).Error; err != nil {
+		return totals, err
+	}
+	for _, m := range months {
+		if to := nextMonth(m.Month); !to.After(end) && cover(m.Month, to) {
+			totals.add(SummaryTotals{m.TotalSales, m.TotalTransactions, m.TotalProfit, m.TotalCost})
+		}
+	}
+
+	var weeks []models.WeeklySummary
+	if err := r.db.Where("shop_id = ? AND week_start >= ? AND week_start < ?", shopID, start, end).Find(&weeks).Error; err != nil {
+		return totals, err
+	}
+	for _, w := range weeks {
+		if to := w.WeekStart.Add(7 * summaryDayLength); !to.After(end) && cover(w.WeekStart, to) {
+			totals.add(SummaryTotals{w.TotalSales, w.TotalTransactions, w.TotalProfit, w.TotalCost})
+		}
+	}
+
+	var days []models.DailySummary
+	if err := r.db.Where("shop_id = ? AND date >= ? AND date < ?", shopID, start, end).Find(&days).Error; err != nil {
+		return totals, err
+	}
+	for _, d := range days {
+		if cover(d.Date, d.Date.Add(summaryDayLength)) {
+			totals.add(SummaryTotals{d.TotalSales, d.TotalTransactions, d.TotalProfit, d.TotalCost})
+		}
+	}
+
+	// Runs of days nothing covered, added up from their sales
+	for day := start; day.Before(end); {
+		if covered[day.Unix()] {
+			day = day.Add(summaryDayLength)
+			continue
+		}
+		runEnd := day
+		for runEnd.Before(end) && !covered[runEnd.Unix()] {
+			runEnd = runEnd.Add(summaryDayLength)
+		}
+		var sales SummaryTotals
+		err := r.db.Model(&models.Sale{}).
+			Select(
+				"COALESCE(SUM(total_amount), 0) AS sales",
+				"COUNT(*) AS transactions",
+				"COALESCE(SUM(profit), 0) AS profit",
+				"COALESCE(SUM(cost_amount), 0) AS cost",
+			).
+			Where("shop_id = ? AND created_at >= ? AND created_at < ?", shopID, day, runEnd).
+			Scan(&sales).Error
+		if err != nil {
+			return totals, err
+		}
+		totals.add(sales)
+		day = runEnd
+	}
+	return totals, nil
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"gorm.io/gorm"
+)
+
+// SmsCampaignRepository handles SMS campaigns
+type SmsCampaignRepository struct {
+	db *gorm.DB
+}
+
+// NewSmsCampaignRepository creates a new SMS campaign repository
+func NewSmsCampaignRepository(db *gorm.DB) *SmsCampaignRepository {
+	return &SmsCampaignRepository{db: db}
+}
+
+// Create creates a campaign
+func (r *SmsCampaignRepository) Create(campaign *models.SmsCampaign) error {
+	return r.db.Create(campaign).Error
+}
+
+// GetByID gets a campaign by ID
+func (r *SmsCampaignRepository) GetByID(id uint) (*models.SmsCampaign, error) {
+	var campaign models.SmsCampaign
+	if err := r.db.First(&campaign, id).Error; err != nil {
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+// GetByShop lists a shop's campaigns, newest first
+func (r *SmsCampaignRepository) GetByShop(shopID uint, limit int) ([]models.SmsCampaign, error) {
+	var campaigns []models.SmsCampaign
+	err := r.db.Where("shop_id = ?", shopID).Order("created_at DESC, id DESC").Limit(limit).Find(&campaigns).Error
+	return campaigns, err
+}
+
+// Complete marks a campaign as sent to every recipient
+func (r *SmsCampaignRepository) Complete(id uint) error {
+	return r.db.Model(&models.SmsCampaign{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":       models.SmsCampaignCompleted,
+		"completed_at": time.Now(),
+	}).Error
+}
+
+// Summary counts a campaign's messages by status and adds up their cost
+func (r *SmsCampaignRepository) Summary(campaign *models.SmsCampaign) (*models.SmsCampaignSummary, error) {
+	var rows []struct {
+		Status   models.SmsStatus
+		Currency string
+		Count    int64
+		Cost     float64
+	}
+	err := r.db.Model(&models.SmsMessage{}).
+		Select("status, currency, COUNT(*) AS count, COALESCE(SUM(cost), 0) AS cost").
+		Where("campaign_id = ?", campaign.ID).
+		Group("status, currency").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &models.SmsCampaignSummary{Campaign: campaign}
+	for _, row := range rows {
+		switch row.Status {
+		case models.SmsStatusDelivered:
+			summary.Delivered += row.Count
+		case models.SmsStatusFailed, models.SmsStatusUndelivered:
+			summary.Failed += row.Count
+		default:
+			summary.Sent += row.Count
+		}
+		summary.Cost += row.Cost
+		if row.Currency != "" {
+			summary.Currency = row.Currency
+		}
+	}
+	return summary, nil
+}
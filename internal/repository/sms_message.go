@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"gorm.io/gorm"
+)
+
+// SmsMessageFilter narrows a shop's SMS log; zero fields are ignored
+type SmsMessageFilter struct {
+	Status    models.SmsStatus
+	Purpose   models.SmsPurpose
+	Recipient string
+	From      time.Time
+	To        time.Time
+}
+
+// SmsMessageRepository handles the log of sent SMS
+type SmsMessageRepository struct {
+	db *gorm.DB
+}
+
+// NewSmsMessageRepository creates a new SMS message repository
+func NewSmsMessageRepository(db *gorm.DB) *SmsMessageRepository {
+	return &SmsMessageRepository{db: db}
+}
+
+// Create records a sent SMS
+func (r *SmsMessageRepository) Create(message *models.SmsMessage) error {
+	return r.db.Create(message).Error
+}
+
+// GetByProviderID gets an SMS by the provider's message ID
+func (r *SmsMessageRepository) GetByProviderID(providerID string) (*models.SmsMessage, error) {
+	var message models.SmsMessage
+	if err := r.db.Where("provider_id = ?", providerID).First(&message).Error; err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// UpdateDelivery records a delivery report for the SMS with providerID
+func (r *SmsMessageRepository) UpdateDelivery(providerID string, status models.SmsStatus, reason string) error {
+	updates := map[string]interface{}{
+		"status":         status,
+		"failure_reason": reason,
+	}
+	if status == models.SmsStatusDelivered {
+		updates["delivered_at"] = time.Now()
+	}
+	result := r.db.Model(&models.SmsMessage{}).Where("provider_id = ?", providerID).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetByShop lists a shop's SMS, newest first
+func (r *SmsMessageRepository) GetByShop(shopID uint, filter SmsMessageFilter, limit, offset int) ([]models.SmsMessage, int64, error) {
+	var messages []models.SmsMessage
+	var total int64
+
+	query := r.db.Model(&models.SmsMessage{}).Where("shop_id = ?", shopID)
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Purpose != "" {
+		query = query.Where("purpose = ?", filter.Purpose)
+	}
+	if filter.Recipient != "" {
+		query = query.Where("recipient = ?", filter.Recipient)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&messages).Error
+	return messages, total, err
+}
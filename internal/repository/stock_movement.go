@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"gorm.io/gorm"
+)
+
+// StockMovementRepository reads the stock ledger. Movements are written
+// by the product, sale and bundle repositories as stock changes.
+type StockMovementRepository struct {
+	db *gorm.DB
+}
+
+// NewStockMovementRepository creates a new stock movement repository
+func NewStockMovementRepository(db *gorm.DB) *StockMovementRepository {
+	return &StockMovementRepository{db: db}
+}
+
+// GetByProduct lists a product's movements, newest first
+func (r *StockMovementRepository) GetByProduct(productID uint, limit, offset int) ([]models.StockMovement, int64, error) {
+	var movements []models.StockMovement
+	var total int64
+
+	query := r.db.Model(&models.StockMovement{}).Where("product_id = ?", productID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&movements).Error
+	return movements, total, err
+}
+
+// MoveStockInTx adds delta to a product's stock as part of the caller's
+// transaction and records the movement
+func MoveStockInTx(tx *gorm.DB, productID uint, delta int, kind models.StockMovementType, referenceID *uint, note string) (*models.StockMovement, error) {
+	return moveStock(tx, productID, delta, kind, referenceID, note, false)
+}
+
+// allowsNegativeStock reports whether a shop records sales its stock can't
+// cover; a shop that can't be read keeps stock from going negative
+func allowsNegativeStock(tx *gorm.DB, shopID uint) bool {
+	var allowed []bool
+	if err := tx.Model(&models.Shop{}).Where("id = ?", shopID).Pluck("allow_negative_stock", &allowed).Error; err != nil {
+		return false
+	}
+	return len(allowed) == 1 && allowed[0]
+}
+
+// moveStock adds delta to a product's stock within tx and records the
+// movement with the resulting balance. With requireStock, stock is only
+// taken out if enough is on hand, otherwise ErrInsufficientStock.
+func moveStock(tx *gorm.DB, productID uint, delta int, kind models.StockMovementType, referenceID *uint, note string, requireStock bool) (*models.StockMovement, error) {
+	query := tx.Model(&models.Product{}).Where("id = ?", productID)
+	if requireStock && delta < 0 {
+		query = query.Where("current_stock >= ?", -delta)
+	}
+	result := query.Update("current_stock", gorm.Expr("current_stock + ?", delta))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		if requireStock {
+			return nil, ErrInsufficientStock
+		}
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var product models.Product
+	if err := tx.Select("id", "shop_id", "current_stock").First(&product, productID).Error; err != nil {
+		return nil, err
+	}
+
+	movement := &models.StockMovement{
+		ShopID:       product.ShopID,
+		ProductID:    productID,
+		Type:         kind,
+		Quantity:     delta,
+		BalanceAfter: product.CurrentStock,
+		ReferenceID:  referenceID,
+		Note:         note,
+	}
+	if err := tx.Create(movement).Error; err != nil {
+		return nil, err
+	}
+	return movement, nil
+}
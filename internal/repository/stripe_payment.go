@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"gorm.io/gorm"
+)
+
+// ErrStripePaymentSettled is returned when a payment already completed or failed
+var ErrStripePaymentSettled = errors.New("stripe payment already settled")
+
+// StripePaymentRepository handles card payments taken through Stripe
+type StripePaymentRepository struct {
+	db *gorm.DB
+}
+
+// NewStripePaymentRepository creates a new Stripe payment repository
+func NewStripePaymentRepository(db *gorm.DB) *StripePaymentRepository {
+	return &StripePaymentRepository{db: db}
+}
+
+// Create records a new payment
+func (r *StripePaymentRepository) Create(payment *models.StripePayment) error {
+	return r.db.Create(payment).Error
+}
+
+// GetByPaymentIntentID gets a payment by its Stripe PaymentIntent ID
+func (r *StripePaymentRepository) GetByPaymentIntentID(intentID string) (*models.StripePayment, error) {
+	var payment models.StripePayment
+	if err := r.db.Where("payment_intent_id = ?", intentID).First(&payment).Error; err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// Complete marks a pending payment completed and records its sale,
+// taking the stock, all in one transaction
+func (r *StripePaymentRepository) Complete(payment *models.StripePayment) (*models.Sale, error) {
+	var sale models.Sale
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.StripePayment{}).
+			Where("id = ? AND status = ?", payment.ID, models.StripePaymentPending).
+			Updates(map[string]interface{}{
+				"status":       models.StripePaymentCompleted,
+				"completed_at": now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrStripePaymentSettled
+		}
+
+		var product models.Product
+		if err := tx.First(&product, payment.ProductID).Error; err != nil {
+			return err
+		}
+
+		cost := product.CostPrice * float64(payment.Quantity)
+		sale = models.Sale{
+			ShopID:        payment.ShopID,
+			ProductID:     payment.ProductID,
+			Quantity:      payment.Quantity,
+			UnitPrice:     payment.Amount / float64(payment.Quantity),
+			TotalAmount:   payment.Amount,
+			CostAmount:    cost,
+			Profit:        payment.Amount - cost,
+			PaymentMethod: models.PaymentCard,
+			Notes:         fmt.Sprintf("Stripe Payment: %s", payment.PaymentIntentID),
+		}
+		if err := tx.Create(&sale).Error; err != nil {
+			return err
+		}
+		// The customer has paid, so the sale stands even if stock ran short
+		if _, err := moveStock(tx, sale.ProductID, -sale.Quantity, models.StockMovementSale, &sale.ID, "", false); err != nil {
+			return err
+		}
+
+		return tx.Model(&models.StripePayment{}).Where("id = ?", payment.ID).Update("sale_id", sale.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	payment.Status = models.StripePaymentCompleted
+	payment.SaleID = &sale.ID
+	return &sale, nil
+}
+
+// Fail marks a pending payment failed with Stripe's reason
+func (r *StripePaymentRepository) Fail(payment *models.StripePayment, reason string) error {
+	result := r.db.Model(&models.StripePayment{}).
+		Where("id = ? AND status = ?", payment.ID, models.StripePaymentPending).
+		Updates(map[string]interface{}{
+			"status":         models.StripePaymentFailed,
+			"failure_reason": reason,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrStripePaymentSettled
+	}
+	payment.Status = models.StripePaymentFailed
+	payment.FailureReason = reason
+	return nil
+}
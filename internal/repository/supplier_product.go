@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"gorm.io/gorm"
+)
+
+// SupplierProductRepository handles the products each supplier sells
+type SupplierProductRepository struct {
+	db *gorm.DB
+}
+
+// NewSupplierProductRepository creates a new supplier product repository
+func NewSupplierProductRepository(db *gorm.DB) *SupplierProductRepository {
+	return &SupplierProductRepository{db: db}
+}
+
+// Save links a product to a supplier, updating the cost of an existing link
+func (r *SupplierProductRepository) Save(link *models.SupplierProduct) error {
+	var existing models.SupplierProduct
+	err := r.db.Where("supplier_id = ? AND product_id = ?", link.SupplierID, link.ProductID).First(&existing).Error
+	if err == nil {
+		link.ID = existing.ID
+		link.CreatedAt = existing.CreatedAt
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return r.db.Save(link).Error
+}
+
+// GetBySupplier lists the products a supplier sells
+func (r *SupplierProductRepository) GetBySupplier(supplierID uint) ([]models.SupplierProduct, error) {
+	var links []models.SupplierProduct
+	err := r.db.Preload("Product").Where("supplier_id = ?", supplierID).Order("id").Find(&links).Error
+	return links, err
+}
+
+// GetCheapest gets the supplier selling a product at the lowest unit cost
+func (r *SupplierProductRepository) GetCheapest(productID uint) (*models.SupplierProduct, error) {
+	var link models.SupplierProduct
+	err := r.db.Preload("Supplier").
+		Joins("JOIN suppliers ON suppliers.id = supplier_products.supplier_id AND suppliers.deleted_at IS NULL").
+		Where("supplier_products.product_id = ?", productID).
+		Order("supplier_products.unit_cost, supplier_products.id").
+		First(&link).Error
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// Delete unlinks a product from a supplier
+func (r *SupplierProductRepository) Delete(supplierID, productID uint) error {
+	result := r.db.Where("supplier_id = ? AND product_id = ?", supplierID, productID).Delete(&models.SupplierProduct{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"gorm.io/gorm"
+)
+
+// RatingStats are a supplier's average rating and how many orders were
+// rated
+type RatingStats struct {
+	SupplierID uint    `json:"-"`
+	Average    float64 `json:"avg_rating"`
+	Count      int64   `json:"rating_count"`
+}
+
+// Rate records the rating of an order's supplier, replacing the order's
+// earlier rating
+func (r *SupplierRepository) Rate(rating *models.SupplierRating) error {
+	if rating.RatedAt.IsZero() {
+		rating.RatedAt = time.Now()
+	}
+	var existing models.SupplierRating
+	err := r.db.Where("order_id = ?", rating.OrderID).First(&existing).Error
+	if err == nil {
+		rating.ID = existing.ID
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return r.db.Save(rating).Error
+}
+
+// GetAverageRating returns a supplier's average rating, 0 when it has
+// none
+func (r *SupplierRepository) GetAverageRating(supplierID uint) float64 {
+	var avg float64
+	r.db.Model(&models.SupplierRating{}).
+		Select("COALESCE(AVG(rating), 0)").
+		Where("supplier_id = ?", supplierID).
+		Scan(&avg)
+	return avg
+}
+
+// GetRatings returns a supplier's ratings, newest first
+func (r *SupplierRepository) GetRatings(supplierID uint) []models.SupplierRating {
+	var ratings []models.SupplierRating
+	r.db.Where("supplier_id = ?", supplierID).Order("rated_at DESC").Find(&ratings)
+	return ratings
+}
+
+// GetRatingStats returns the rating stats of every rated supplier of a
+// shop, by supplier ID
+func (r *SupplierRepository) GetRatingStats(shopID uint) (map[uint]RatingStats, error) {
+	var rows []RatingStats
+	err := r.db.Model(&models.SupplierRating{}).
+		Select("supplier_id, AVG(rating) AS average, COUNT(*) AS count").
+		Where("shop_id = ?", shopID).
+		Group("supplier_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[uint]RatingStats, len(rows))
+	for _, row := range rows {
+		stats[row.SupplierID] = row
+	}
+	return stats, nil
+}
@@ -11,58 +11,62 @@ import (
 	currencyhandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/currency"
 	emailhandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/email"
 	exporthandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/export"
+	jobschedulerhandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/jobscheduler"
 	loyaltyhandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/loyalty"
 	mpesahandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/mpesa"
 	printerhandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/printer"
 	qrhandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/qr"
 	smshandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/sms"
 	staffhandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/staff"
+	stripehandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/stripe"
 	supplierhandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/supplier"
 	webhookhandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/webhook"
 
 	"github.com/C9b3rD3vi1/DukaPOS/internal/handlers"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/middleware"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
 )
 
 type RouteConfig struct {
-	App                         *fiber.App
-	AuthService                 *services.AuthService
-	AuthHandler                 *handlers.AuthHandler
-	ShopHandler                 *handlers.ShopHandler
-	ProductHandler              *handlers.ProductHandler
-	SaleHandler                 *handlers.SaleHandler
-	ReportHandler               *handlers.ReportHandler
-	ExportHandler               *exporthandler.ExportHandler
-	StaffHandler                *staffhandler.Handler
-	WebhookHandler              *webhookhandler.Handler
-	CustomerHandler             *loyaltyhandler.Handler
-	CustHandler                 *handlers.CustomerHandler
-	SupplierHandler             *supplierhandler.Handler
-	MpesaHandler                *mpesahandler.Handler
-	SMSHandler                  *smshandler.Handler
-	EmailHandler                *emailhandler.Handler
-	AIHandler                   *aihandler.Handler
-	PrinterHandler              *printerhandler.Handler
-	QRHandler                   *qrhandler.QRHandler
-	BillingHandler              *billinghandler.Handler
-	AdminHandler                *handlers.AdminHandler
-	APIKeyHandler               *apihandler.APIKeyHandler
-	WebHandler                  *handlers.WebHandler
-	PlanInfoHandler             *middleware.PlanInfoHandler
-	ScheduledReportHandler      *handlers.ScheduledReportHandler
-	StaffRoleHandler            *handlers.StaffRoleHandler
-	WhiteLabelHandler           *handlers.WhiteLabelHandler
-	CurrencyHandler             *currencyhandler.Handler
-	FeatureStaffAccountsEnabled bool
-	FeatureMpesaEnabled         bool
-	FeatureAnalyticsEnabled     bool
-	FeatureMultipleShopsEnabled bool
-	FeatureWebDashboardEnabled  bool
-	CustomerRepo                *repository.CustomerRepository
-	SaleRepo                    *repository.SaleRepository
-	DB                          *gorm.DB
+	App                    *fiber.App
+	AuthService            *services.AuthService
+	AuthHandler            *handlers.AuthHandler
+	ShopHandler            *handlers.ShopHandler
+	ProductHandler         *handlers.ProductHandler
+	SaleHandler            *handlers.SaleHandler
+	ReportHandler          *handlers.ReportHandler
+	ExportHandler          *exporthandler.ExportHandler
+	StaffHandler           *staffhandler.Handler
+	WebhookHandler         *webhookhandler.Handler
+	CustomerHandler        *loyaltyhandler.Handler
+	CustHandler            *handlers.CustomerHandler
+	SupplierHandler        *supplierhandler.Handler
+	MpesaHandler           *mpesahandler.Handler
+	StripeHandler          *stripehandler.Handler
+	SMSHandler             *smshandler.Handler
+	EmailHandler           *emailhandler.Handler
+	AIHandler              *aihandler.Handler
+	PrinterHandler         *printerhandler.Handler
+	QRHandler              *qrhandler.QRHandler
+	BillingHandler         *billinghandler.Handler
+	AdminHandler           *handlers.AdminHandler
+	APIKeyHandler          *apihandler.APIKeyHandler
+	WebHandler             *handlers.WebHandler
+	PlanInfoHandler        *middleware.PlanInfoHandler
+	ScheduledReportHandler *handlers.ScheduledReportHandler
+	ReceiptHandler         *handlers.ReceiptHandler
+	MediaHandler           *handlers.MediaHandler
+	MessageHandler         *handlers.MessageHandler
+	JobHandler             *handlers.JobHandler
+	StaffRoleHandler       *handlers.StaffRoleHandler
+	WhiteLabelHandler      *handlers.WhiteLabelHandler
+	CurrencyHandler        *currencyhandler.Handler
+	ExpenseHandler         *handlers.ExpenseHandler
+	CustomerRepo           *repository.CustomerRepository
+	SaleRepo               *repository.SaleRepository
+	DB                     *gorm.DB
 }
 
 func RegisterAllRoutes(config RouteConfig) {
@@ -74,10 +78,28 @@ func RegisterAllRoutes(config RouteConfig) {
 	auth.Post("/login", config.AuthHandler.Login)
 	auth.Post("/otp/send", config.AuthHandler.SendOTP)
 	auth.Post("/otp/verify", config.AuthHandler.VerifyOTP)
+	auth.Post("/password/reset", config.AuthHandler.ResetPassword)
 
 	// Plan routes
 	api.Get("/subscriptions/plans", config.PlanInfoHandler.GetAllPlans)
 
+	// Digital receipt page linked from receipt QR codes (public, signed link)
+	config.App.Get("/r/:saleID", config.ReceiptHandler.PublicReceipt)
+
+	// WhatsApp media (QR codes, receipts) fetched by Twilio (public, random name)
+	if config.MediaHandler != nil {
+		config.App.Get("/media/:name", config.MediaHandler.Serve)
+	}
+
+	// Background export downloads linked from WhatsApp and email (public, signed link)
+	config.App.Get("/exports/:id", config.ExportHandler.DownloadExport)
+
+	// Payment link pages shared with customers (public, token link)
+	if config.WebHandler != nil {
+		config.App.Get("/pay/:token", config.WebHandler.PaymentLinkPage)
+		config.App.Post("/pay/:token", middleware.RateLimiter(5, 60), config.WebHandler.PayPaymentLink)
+	}
+
 	// Protected routes
 	protected := config.App.Group("/api/v1")
 	protected.Use(middleware.JWT(config.AuthService))
@@ -85,9 +107,13 @@ func RegisterAllRoutes(config RouteConfig) {
 	// 2FA status (protected)
 	protected.Get("/auth/2fa/status", config.AuthHandler.GetTwoFactorStatus)
 
+	// Login history (protected)
+	protected.Get("/account/security/logins", config.AuthHandler.GetLoginEvents)
+
 	// Shop routes
 	protected.Get("/shop/profile", config.ShopHandler.GetProfile)
 	protected.Put("/shop/profile", config.ShopHandler.UpdateProfile)
+	protected.Put("/shop/ussd-pin", config.ShopHandler.SetUSSDPin)
 	protected.Get("/shop/dashboard", config.ShopHandler.GetDashboard)
 	protected.Get("/shop/account", config.ShopHandler.GetAccount)
 	protected.Get("/plan", config.PlanInfoHandler.GetPlanInfo)
@@ -97,20 +123,40 @@ func RegisterAllRoutes(config RouteConfig) {
 
 	// Product routes
 	protected.Get("/products", config.ProductHandler.ListProducts)
-	protected.Get("/products/:id", config.ProductHandler.GetProduct)
-	protected.Post("/products", config.ProductHandler.CreateProduct)
-	protected.Put("/products/:id", config.ProductHandler.UpdateProduct)
-	protected.Delete("/products/:id", config.ProductHandler.DeleteProduct)
+	protected.Get("/products/search", config.ProductHandler.SearchProducts)
+	protected.Get("/products/favorites", config.ProductHandler.GetFavorites)
+	protected.Post("/products/favorites", config.ProductHandler.AddFavorite)
+	protected.Delete("/products/favorites/:id", config.ProductHandler.RemoveFavorite)
 	protected.Post("/products/bulk", config.ProductHandler.BulkCreateProducts)
 	protected.Get("/products/categories", config.ProductHandler.ListCategories)
+	protected.Get("/products/categories/tree", config.ProductHandler.GetCategoryTree)
 	protected.Post("/products/categories", config.ProductHandler.CreateCategory)
 	protected.Put("/products/categories/:id", config.ProductHandler.UpdateCategory)
 	protected.Delete("/products/categories/:id", config.ProductHandler.DeleteCategory)
+	protected.Get("/products/:id", config.ProductHandler.GetProduct)
+	protected.Post("/products", config.ProductHandler.CreateProduct)
+	protected.Put("/products/:id", config.ProductHandler.UpdateProduct)
+	protected.Delete("/products/:id", config.ProductHandler.DeleteProduct)
+	protected.Get("/bundles", config.ProductHandler.ListBundles)
+	protected.Get("/products/:id/bundle", config.ProductHandler.GetBundle)
+	protected.Post("/products/:id/bundle", config.ProductHandler.SetBundle)
+	protected.Get("/products/:id/price-history", config.ProductHandler.GetPriceHistory)
+	protected.Get("/products/:id/movements", config.ProductHandler.GetStockMovements)
+	protected.Post("/products/:id/image", config.ProductHandler.UploadImage)
+	protected.Get("/products/:id/cross-sells", config.ProductHandler.GetCrossSells)
+	protected.Post("/products/:id/aliases", config.ProductHandler.AddAlias)
+	protected.Delete("/products/:id/aliases/:alias", config.ProductHandler.DeleteAlias)
 
 	// Sale routes
 	protected.Get("/sales", config.SaleHandler.ListSales)
 	protected.Get("/sales/:id", config.SaleHandler.GetSale)
 	protected.Post("/sales", config.SaleHandler.CreateSale)
+	protected.Get("/sales/:id/receipt.pdf", config.ReceiptHandler.GetReceiptPDF)
+
+	// WhatsApp messages sent to the shop and their delivery status
+	if config.MessageHandler != nil {
+		protected.Get("/messages", config.MessageHandler.ListMessages)
+	}
 
 	// Report routes
 	protected.Get("/reports", config.ReportHandler.GetDailyReport)
@@ -118,12 +164,36 @@ func RegisterAllRoutes(config RouteConfig) {
 	protected.Get("/reports/weekly", config.ReportHandler.GetWeeklyReport)
 	protected.Get("/reports/monthly", config.ReportHandler.GetMonthlyReport)
 	protected.Get("/reports/analytics", config.ReportHandler.GetAnalytics)
+	protected.Get("/reports/inventory-value", config.ReportHandler.GetInventoryValueTrend)
+	protected.Get("/reports/snapshots", config.ReportHandler.GetMonthEndSnapshot)
+	protected.Get("/reports/zreport", config.ReportHandler.GetZReport)
+	protected.Get("/reports/zreport/history", config.ReportHandler.ListZReports)
+	protected.Post("/reports/zreport/close", middleware.RequireShopOwner(), config.ReportHandler.CloseDay)
+	protected.Get("/reports/net-profit", config.ReportHandler.GetNetProfit)
 
 	// Export routes
 	protected.Get("/export/products", config.ExportHandler.ExportProducts)
 	protected.Get("/export/sales", config.ExportHandler.ExportSales)
 	protected.Get("/export/report", config.ExportHandler.ExportReport)
 	protected.Get("/export/inventory", config.ExportHandler.ExportInventory)
+	protected.Get("/export/mpesa-reconciliation", config.ExportHandler.ExportMpesaReconciliation)
+	protected.Get("/export/catalog", config.ExportHandler.ExportCatalog)
+	protected.Get("/export/customers", config.ExportHandler.ExportCustomers)
+	protected.Get("/export/loyalty-transactions", config.ExportHandler.ExportLoyaltyTransactions)
+	protected.Get("/export/mpesa-payments", config.ExportHandler.ExportMpesaPayments)
+	protected.Get("/export/mpesa-transactions", config.ExportHandler.ExportMpesaTransactions)
+	protected.Get("/export/suppliers", config.ExportHandler.ExportSuppliers)
+	protected.Get("/export/purchase-orders", config.ExportHandler.ExportPurchaseOrders)
+	protected.Post("/export/jobs", config.ExportHandler.CreateExportJob)
+	protected.Get("/export/jobs/:id", config.ExportHandler.GetExportJob)
+	protected.Get("/import/products/template", config.ExportHandler.ProductImportTemplate)
+	protected.Post("/import/products", config.ProductHandler.ImportProducts)
+
+	// Queued exports
+	if config.JobHandler != nil {
+		protected.Get("/jobs/:id", config.JobHandler.GetJob)
+		protected.Get("/jobs/:id/download", config.JobHandler.Download)
+	}
 
 	// Admin routes
 	admin := protected.Group("/admin")
@@ -133,8 +203,18 @@ func RegisterAllRoutes(config RouteConfig) {
 	admin.Put("/accounts/:id/plan", config.AdminHandler.UpdateAccountPlan)
 	admin.Put("/accounts/:id/status", config.AdminHandler.UpdateAccountStatus)
 	admin.Get("/shops", config.AdminHandler.GetShops)
+	admin.Get("/outbox", middleware.RequireAdmin(), config.AdminHandler.GetOutbox)
+	admin.Get("/messages", middleware.RequireAdmin(), config.AdminHandler.GetMessages)
+	admin.Get("/features", middleware.RequireAdmin(), config.AdminHandler.GetFeatures)
+	admin.Put("/features/:name", middleware.RequireAdmin(), config.AdminHandler.UpdateFeature)
 	admin.Get("/revenue", config.AdminHandler.GetRevenueStats)
 	admin.Post("/upgrade-all", config.AdminHandler.UpgradeAllAccounts)
+	if config.MpesaHandler != nil {
+		admin.Post("/mpesa/c2b/register", middleware.RequireAdmin(), config.MpesaHandler.RegisterPlatformC2BURLs)
+	}
+
+	// Scheduler status (per-job last run / last error)
+	jobschedulerhandler.NewJobSchedulerHandler(GetJobScheduler()).RegisterAdminRoutes(admin)
 
 	// Public admin fix
 	api.Post("/admin/fix", config.AdminHandler.FixAdmin)
@@ -145,6 +225,8 @@ func RegisterAllRoutes(config RouteConfig) {
 	billing.Get("/current", config.BillingHandler.GetCurrentPlan)
 	billing.Post("/upgrade", config.BillingHandler.UpgradePlan)
 	billing.Get("/history", config.BillingHandler.GetHistory)
+	billing.Get("/invoices", config.BillingHandler.ListInvoices)
+	billing.Get("/invoices/:id/download", config.BillingHandler.DownloadInvoice)
 
 	// Subscription routes
 	subs := protected.Group("/subscriptions")
@@ -152,26 +234,26 @@ func RegisterAllRoutes(config RouteConfig) {
 	subs.Get("/current", config.BillingHandler.GetCurrentPlan)
 	subs.Post("/upgrade", config.BillingHandler.UpgradePlan)
 
-	// Web Dashboard routes
-	if config.FeatureWebDashboardEnabled {
-		webAPI := config.App.Group("/api/v1")
-		webAPI.Get("/shop/dashboard-json/:shop_id", config.WebHandler.DashboardJSON)
-		webAPI.Get("/shop/dashboard/:shop_id", config.WebHandler.Dashboard)
-		webAPI.Get("/products/categories", config.ProductHandler.ListCategories)
-		webAPI.Post("/products/bulk", config.ProductHandler.BulkCreateProducts)
-		webAPI.Post("/products", config.WebHandler.APIProductCreate)
-		webAPI.Get("/products", config.ProductHandler.ListProducts)
-		webAPI.Get("/products/:id", config.ProductHandler.GetProduct)
-		webAPI.Put("/products/:id", config.WebHandler.APIProductUpdate)
-		webAPI.Delete("/products/:id", config.WebHandler.APIProductDelete)
-		webAPI.Get("/sales/:shop_id", config.WebHandler.APISales)
-		webAPI.Post("/sales", config.WebHandler.APISaleCreate)
-		webAPI.Get("/reports/:shop_id", config.WebHandler.APIReports)
-	}
+	// Web Dashboard routes, served while the web dashboard is on
+	webAPI := config.App.Group("/api/v1")
+	dashboard := middleware.RequireFlag(models.FeatureFlagWebDashboard)
+	webAPI.Get("/shop/dashboard-json/:shop_id", dashboard, config.WebHandler.DashboardJSON)
+	webAPI.Get("/shop/dashboard/:shop_id", dashboard, config.WebHandler.Dashboard)
+	webAPI.Get("/products/categories", dashboard, config.ProductHandler.ListCategories)
+	webAPI.Post("/products/bulk", dashboard, config.ProductHandler.BulkCreateProducts)
+	webAPI.Post("/products", dashboard, config.WebHandler.APIProductCreate)
+	webAPI.Get("/products", dashboard, config.ProductHandler.ListProducts)
+	webAPI.Get("/products/:id", dashboard, config.ProductHandler.GetProduct)
+	webAPI.Put("/products/:id", dashboard, config.WebHandler.APIProductUpdate)
+	webAPI.Delete("/products/:id", dashboard, config.WebHandler.APIProductDelete)
+	webAPI.Get("/sales/:shop_id", dashboard, config.WebHandler.APISales)
+	webAPI.Post("/sales", dashboard, config.WebHandler.APISaleCreate)
+	webAPI.Get("/reports/:shop_id", dashboard, config.WebHandler.APIReports)
 
 	// Staff Routes
-	if config.FeatureStaffAccountsEnabled && config.StaffHandler != nil {
+	if config.StaffHandler != nil {
 		staff := protected.Group("/staff")
+		staff.Use(middleware.RequireFlag(models.FeatureFlagStaffAccounts))
 		staff.Get("/", config.StaffHandler.List)
 		staff.Get("/:id", config.StaffHandler.Get)
 		staff.Post("/", config.StaffHandler.Create)
@@ -198,38 +280,83 @@ func RegisterAllRoutes(config RouteConfig) {
 		customers.Delete("/:id", config.CustHandler.Delete)
 	}
 
+	// Expenses, taken off profit along with the cost of goods
+	if config.ExpenseHandler != nil {
+		expenses := protected.Group("/expenses")
+		expenses.Get("/", config.ExpenseHandler.List)
+		expenses.Post("/", config.ExpenseHandler.Create)
+		expenses.Get("/recurring", config.ExpenseHandler.ListRecurring)
+		expenses.Delete("/recurring/:id", middleware.RequireShopOwner(), config.ExpenseHandler.StopRecurring)
+		expenses.Delete("/:id", middleware.RequireShopOwner(), config.ExpenseHandler.Delete)
+	}
+
 	// Supplier/Order Routes
 	if config.SupplierHandler != nil {
 		suppliers := protected.Group("/suppliers")
+		suppliers.Use(middleware.RequireFlag(models.FeatureFlagStaffAccounts))
 		suppliers.Get("/", config.SupplierHandler.ListSuppliers)
 		suppliers.Post("/", config.SupplierHandler.CreateSupplier)
 		suppliers.Get("/:id", config.SupplierHandler.GetSupplier)
 		suppliers.Put("/:id", config.SupplierHandler.UpdateSupplier)
 		suppliers.Delete("/:id", config.SupplierHandler.DeleteSupplier)
+		suppliers.Get("/:id/products", config.SupplierHandler.ListSupplierProducts)
+		suppliers.Put("/:id/products/:product_id", config.SupplierHandler.SetSupplierProduct)
+		suppliers.Delete("/:id/products/:product_id", config.SupplierHandler.DeleteSupplierProduct)
+		suppliers.Get("/:id/ratings", config.SupplierHandler.ListRatings)
 
 		orders := protected.Group("/orders")
+		orders.Use(middleware.RequireFlag(models.FeatureFlagStaffAccounts))
 		orders.Get("/", config.SupplierHandler.ListOrders)
 		orders.Post("/", config.SupplierHandler.CreateOrder)
 		orders.Get("/:id", config.SupplierHandler.GetOrder)
+		orders.Get("/:id/pdf", config.SupplierHandler.GetOrderPDF)
+		orders.Post("/:id/send", config.SupplierHandler.SendOrder)
 		orders.Put("/:id/status", config.SupplierHandler.UpdateOrderStatus)
+		orders.Post("/:id/rating", config.SupplierHandler.RateOrder)
 		orders.Delete("/:id", config.SupplierHandler.DeleteOrder)
 	}
 
 	// M-Pesa Routes - Require Pro plan
-	if config.FeatureMpesaEnabled && config.MpesaHandler != nil {
+	if config.MpesaHandler != nil {
 		mpesa := protected.Group("/mpesa")
 		mpesa.Use(middleware.RequireFeature(middleware.FeatureMpesa))
 		mpesa.Post("/stk-push", config.MpesaHandler.STKPush)
+		mpesa.Post("/bulk-stk", middleware.RequireBusiness(), middleware.RateLimiter(2, 60), config.MpesaHandler.BulkSTKPush)
 		mpesa.Get("/status/:checkoutId", config.MpesaHandler.GetStatus)
 		mpesa.Get("/payments", config.MpesaHandler.ListPayments)
 		mpesa.Post("/payments/:id/retry", config.MpesaHandler.RetryPayment)
+		mpesa.Post("/payments/:id/attribute", config.MpesaHandler.AttributePayment)
+		mpesa.Post("/pending-sales", config.MpesaHandler.CreatePendingSale)
+		mpesa.Get("/pending-sales/:id", config.MpesaHandler.GetPendingSale)
+		mpesa.Delete("/pending-sales/:id", config.MpesaHandler.CancelPendingSale)
 		mpesa.Get("/transactions", config.MpesaHandler.GetTransactions)
+		mpesa.Get("/reconciliation", config.MpesaHandler.GetReconciliation)
+		mpesa.Get("/transactions/:id/status", config.MpesaHandler.GetTransactionStatus)
+		mpesa.Post("/transactions/:id/reverse", middleware.RequireShopOwner(), config.MpesaHandler.ReverseTransaction)
 		mpesa.Get("/balance", config.MpesaHandler.GetBalance)
-		mpesa.Post("/b2c", config.MpesaHandler.B2CSend)
+		mpesa.Get("/b2c", config.MpesaHandler.ListB2CPayouts)
+		mpesa.Get("/payouts", config.MpesaHandler.ListB2CPayouts)
+		mpesa.Post("/b2c", middleware.RequireShopOwner(), config.MpesaHandler.B2CSend)
+		mpesa.Get("/credentials", config.MpesaHandler.GetCredentials)
+		mpesa.Put("/credentials", middleware.RequireShopOwner(), config.MpesaHandler.SaveCredentials)
+		mpesa.Delete("/credentials", middleware.RequireShopOwner(), config.MpesaHandler.DeleteCredentials)
+		mpesa.Post("/c2b/register", middleware.RequireShopOwner(), config.MpesaHandler.RegisterC2BURLs)
+
+		links := protected.Group("/payment-links")
+		links.Use(middleware.RequireFeature(middleware.FeatureMpesa))
+		links.Get("/", config.MpesaHandler.ListPaymentLinks)
+		links.Post("/", config.MpesaHandler.CreatePaymentLink)
+		links.Get("/:id", config.MpesaHandler.GetPaymentLink)
+		links.Delete("/:id", config.MpesaHandler.CancelPaymentLink)
+	}
+
+	// Stripe card payments for online orders
+	if config.StripeHandler != nil {
+		protected.Post("/stripe/checkout", config.StripeHandler.Checkout)
 	}
 
 	// Webhook Routes - Require Business plan
-	if config.FeatureAnalyticsEnabled && config.WebhookHandler != nil {
+	if config.WebhookHandler != nil {
 		webhooks := protected.Group("/webhooks")
 		webhooks.Use(middleware.RequireFeature(middleware.FeatureWebhooks))
 		webhooks.Get("/", config.WebhookHandler.List)
@@ -238,10 +365,12 @@ func RegisterAllRoutes(config RouteConfig) {
 		webhooks.Put("/:id", config.WebhookHandler.Update)
 		webhooks.Delete("/:id", config.WebhookHandler.Delete)
 		webhooks.Post("/:id/test", config.WebhookHandler.Test)
+		webhooks.Get("/:id/deliveries", config.WebhookHandler.ListDeliveries)
+		webhooks.Post("/:id/deliveries/:deliveryId/retry", config.WebhookHandler.RetryDelivery)
 	}
 
 	// AI Routes - Require Business plan
-	if config.FeatureAnalyticsEnabled && config.AIHandler != nil {
+	if config.AIHandler != nil {
 		ai := protected.Group("/ai")
 		ai.Use(middleware.RequireFeature(middleware.FeatureAI))
 		ai.Get("/predictions", config.AIHandler.GetPredictions)
@@ -249,6 +378,8 @@ func RegisterAllRoutes(config RouteConfig) {
 		ai.Get("/inventory-value", config.AIHandler.GetInventoryValue)
 		ai.Get("/restock", config.AIHandler.GetRestockRecommendations)
 		ai.Get("/analytics", config.AIHandler.GetSalesAnalytics)
+		ai.Get("/dead-stock/:shop_id", config.AIHandler.GetDeadStock)
+		ai.Get("/turnover/:shop_id", config.AIHandler.GetTurnover)
 		ai.Post("/forecast", config.AIHandler.GenerateForecast)
 	}
 
@@ -259,6 +390,10 @@ func RegisterAllRoutes(config RouteConfig) {
 		sms.Post("/bulk", config.SMSHandler.SendBulkSMS)
 		sms.Get("/balance", config.SMSHandler.GetBalance)
 		sms.Get("/history", config.SMSHandler.GetHistory)
+		sms.Get("/messages", config.SMSHandler.ListMessages)
+		sms.Post("/campaigns", middleware.RequireBusiness(), config.SMSHandler.CreateCampaign)
+		sms.Get("/campaigns", middleware.RequireBusiness(), config.SMSHandler.ListCampaigns)
+		sms.Get("/campaigns/:id", middleware.RequireBusiness(), config.SMSHandler.GetCampaign)
 	}
 
 	// Email Routes
@@ -267,6 +402,14 @@ func RegisterAllRoutes(config RouteConfig) {
 		email.Post("/send", config.EmailHandler.SendEmail)
 		email.Post("/welcome", config.EmailHandler.SendWelcomeEmail)
 		email.Get("/history", config.EmailHandler.GetHistory)
+		email.Get("/templates", config.EmailHandler.ListTemplates)
+		email.Get("/templates/:key", config.EmailHandler.GetTemplate)
+		email.Put("/templates/:key", middleware.RequireBusiness(), config.EmailHandler.UpdateTemplate)
+		email.Delete("/templates/:key", middleware.RequireBusiness(), config.EmailHandler.ResetTemplate)
+
+		protected.Get("/reports/email/settings", config.EmailHandler.GetReportSettings)
+		protected.Put("/reports/email/settings", config.EmailHandler.UpdateReportSettings)
+		protected.Post("/reports/email", config.EmailHandler.SendReport)
 	}
 
 	// Printer Routes
@@ -274,6 +417,13 @@ func RegisterAllRoutes(config RouteConfig) {
 		print := protected.Group("/print")
 		print.Get("/printers", config.PrinterHandler.GetPrinters)
 		print.Post("/receipt", config.PrinterHandler.PrintReceipt)
+		print.Post("/test", config.PrinterHandler.TestPrinter)
+		print.Post("/zreport", config.PrinterHandler.PrintZReport)
+		print.Get("/config", config.PrinterHandler.GetConfig)
+		print.Put("/config", config.PrinterHandler.Configure)
+		print.Get("/branding", config.PrinterHandler.GetBranding)
+		print.Put("/branding", config.PrinterHandler.UpdateBranding)
+		print.Get("/branding/preview", config.PrinterHandler.PreviewReceipt)
 	}
 
 	// QR Routes - Require Pro plan
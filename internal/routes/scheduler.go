@@ -1,11 +1,13 @@
 package routes
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"strings"
 	"time"
 
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/services/job"
 )
@@ -15,21 +17,86 @@ var (
 	defaultJobSchedulerStarted bool
 )
 
+// RollupsPerTick is how many weekly and monthly rollups the sales_rollups
+// job takes a minute
+const RollupsPerTick = 50
+
+// shopBatchSize is how many shops a job that goes through all of them
+// loads at a time
+const shopBatchSize = 500
+
+// ClosingStocksPerTick is how many shops the monthly_snapshot job records
+// month-end closing stock for a minute
+const ClosingStocksPerTick = 50
+
 type SchedulerConfig struct {
 	ShopRepo     *repository.ShopRepository
 	SaleRepo     *repository.SaleRepository
 	ProductRepo  *repository.ProductRepository
 	SendWhatsApp func(phone, message string) error
+	// SendWhatsAppAs tags each message with its type (daily_report, ...)
+	// for delivery tracking; nil falls back to SendWhatsApp
+	SendWhatsAppAs func(msgType, phone, message string) error
+	// ExpirePayments times out stale M-Pesa payments; nil when M-Pesa is off
+	ExpirePayments func() error
+	// PollPayments settles STK pushes whose callback is overdue; nil when
+	// M-Pesa is off
+	PollPayments func() error
+	// SnapshotRepo stores the daily inventory value series; nil disables it
+	SnapshotRepo *repository.InventorySnapshotRepository
+	// ClosingStockRepo stores month-end closing stock; nil disables it
+	ClosingStockRepo *repository.ClosingStockRepository
+	// SendLowStockSMS alerts shops that chose SMS; nil when SMS is off
+	SendLowStockSMS func(shop *models.Shop, products []models.Product) error
+	// CreateAutoOrders drafts supplier orders for low stock and returns the
+	// notices to send the owner; nil when supplier ordering is off
+	CreateAutoOrders func(shop *models.Shop, products []models.Product) ([]string, error)
+	// EmailReports emails the sales report PDFs that are due; nil when
+	// email is off
+	EmailReports func() error
+	// CleanupMedia deletes expired WhatsApp media files; nil when media
+	// sending is off
+	CleanupMedia func() error
+	// CleanupExports deletes expired background export files; nil when
+	// background exports are off
+	CleanupExports func() error
+	// BirthdayRewards gives a shop's customers their birthday points and
+	// returns how many were rewarded; nil when loyalty is off
+	BirthdayRewards func(shop *models.Shop, now time.Time) (int, error)
+	// SummaryRepo rolls daily summaries up into weekly and monthly ones;
+	// nil disables the rollups
+	SummaryRepo *repository.DailySummaryRepository
+	// ExpenseRepo records recurring expenses as they fall due; nil
+	// disables them
+	ExpenseRepo *repository.ExpenseRepository
 }
 
 func GetJobScheduler() *job.Scheduler {
 	return defaultJobScheduler
 }
 
+// StopScheduledTasks stops the job scheduler, waiting for in-flight jobs until ctx is done
+func StopScheduledTasks(ctx context.Context) error {
+	if defaultJobScheduler == nil || !defaultJobSchedulerStarted {
+		return nil
+	}
+
+	err := defaultJobScheduler.Shutdown(ctx)
+	defaultJobSchedulerStarted = false
+	return err
+}
+
 func RegisterScheduledTasks(config SchedulerConfig) {
 	// Initialize the advanced job defaultJobScheduler
 	defaultJobScheduler = job.GetScheduler()
 	defaultJobScheduler.Start()
+
+	sendWhatsApp := func(msgType, phone, message string) error {
+		if config.SendWhatsAppAs != nil {
+			return config.SendWhatsAppAs(msgType, phone, message)
+		}
+		return config.SendWhatsApp(phone, message)
+	}
 	defaultJobSchedulerStarted = true
 
 	// Daily report task - runs every 24 hours
@@ -62,7 +129,7 @@ func RegisterScheduledTasks(config SchedulerConfig) {
 			if len(sales) > 0 {
 				reportMsg := fmt.Sprintf("📊 DAILY REPORT - %s\n\n💰 Today's Sales: KSh %.0f\n💵 Profit: KSh %.0f\n📝 Transactions: %d\n\nSent automatically by DukaPOS", shop.Name, totalSales, totalProfit, len(sales))
 
-				if err := config.SendWhatsApp(shop.Phone, reportMsg); err != nil {
+				if err := sendWhatsApp(models.MessageTypeDailyReport, shop.Phone, reportMsg); err != nil {
 					log.Printf("❌ Failed to send daily report to shop %s: %v", shop.Name, err)
 				} else {
 					log.Printf("✅ Daily report sent to shop %s", shop.Name)
@@ -93,15 +160,40 @@ func RegisterScheduledTasks(config SchedulerConfig) {
 				continue
 			}
 
+			if len(lowStock) > 0 && config.CreateAutoOrders != nil {
+				notices, err := config.CreateAutoOrders(&shop, lowStock)
+				if err != nil {
+					log.Printf("❌ Failed to create auto orders for shop %s: %v", shop.Name, err)
+				}
+				if len(notices) > 0 {
+					if err := sendWhatsApp(models.MessageTypeAutoOrder, shop.Phone, strings.Join(notices, "\n")); err != nil {
+						log.Printf("❌ Failed to send auto order notice to shop %s: %v", shop.Name, err)
+					}
+				}
+			}
+
+			if len(lowStock) > 0 && shop.LowStockChannel == models.AlertChannelSMS && config.SendLowStockSMS != nil {
+				if err := config.SendLowStockSMS(&shop, lowStock); err != nil {
+					log.Printf("❌ Failed to send low stock SMS to shop %s: %v", shop.Name, err)
+				} else {
+					log.Printf("✅ Low stock SMS sent to shop %s", shop.Name)
+				}
+				continue
+			}
+
 			if len(lowStock) > 0 {
 				var productList strings.Builder
 				productList.WriteString("⚠️ LOW STOCK ALERT\n\n")
 				for _, p := range lowStock {
-					productList.WriteString(fmt.Sprintf("• %s: %d (min: %d)\n", p.Name, p.CurrentStock, p.LowStockThreshold))
+					if p.CurrentStock < 0 {
+						productList.WriteString(fmt.Sprintf("• %s: %d (sold past zero)\n", p.Name, p.CurrentStock))
+						continue
+					}
+					productList.WriteString(fmt.Sprintf("• %s: %d (min: %d)\n", p.Name, p.CurrentStock, p.LowStockLevel()))
 				}
 				productList.WriteString("\nAdd stock: add [name] [price] [qty]")
 
-				if err := config.SendWhatsApp(shop.Phone, productList.String()); err != nil {
+				if err := sendWhatsApp(models.MessageTypeLowStock, shop.Phone, productList.String()); err != nil {
 					log.Printf("❌ Failed to send low stock alert to shop %s: %v", shop.Name, err)
 				} else {
 					log.Printf("✅ Low stock alert sent to shop %s", shop.Name)
@@ -144,7 +236,7 @@ func RegisterScheduledTasks(config SchedulerConfig) {
 
 				reportMsg := fmt.Sprintf("📊 WEEKLY REPORT\n\n💰 Weekly Sales: KSh %.0f\n💵 Profit: KSh %.0f\n📝 Transactions: %d\n\nHave a great week!", totalSales, totalProfit, len(sales))
 
-				if err := config.SendWhatsApp(shop.Phone, reportMsg); err != nil {
+				if err := sendWhatsApp(models.MessageTypeWeeklyReport, shop.Phone, reportMsg); err != nil {
 					log.Printf("❌ Failed to send weekly report to shop %s: %v", shop.Name, err)
 				}
 			}
@@ -187,7 +279,7 @@ func RegisterScheduledTasks(config SchedulerConfig) {
 
 				reportMsg := fmt.Sprintf("📊 MONTHLY REPORT\n\n💰 Monthly Sales: KSh %.0f\n💵 Profit: KSh %.0f\n📝 Transactions: %d\n📈 Daily Avg: KSh %.0f\n\nGreat progress this month! 🎉", totalSales, totalProfit, len(sales), avgDaily)
 
-				if err := config.SendWhatsApp(shop.Phone, reportMsg); err != nil {
+				if err := sendWhatsApp(models.MessageTypeMonthlyReport, shop.Phone, reportMsg); err != nil {
 					log.Printf("❌ Failed to send monthly report to shop %s: %v", shop.Name, err)
 				}
 			}
@@ -197,9 +289,147 @@ func RegisterScheduledTasks(config SchedulerConfig) {
 		return nil
 	})
 
+	// M-Pesa payment expiry - runs every minute
+	if config.ExpirePayments != nil {
+		defaultJobScheduler.AddPeriodicJob("expire_mpesa_payments", time.Minute, config.ExpirePayments)
+	}
+
+	// Report emails - runs hourly, each report is sent once per period
+	if config.EmailReports != nil {
+		defaultJobScheduler.AddPeriodicJob("email_reports", time.Hour, config.EmailReports)
+	}
+
+	// M-Pesa status queries for missing callbacks - runs every 30 seconds
+	if config.PollPayments != nil {
+		defaultJobScheduler.AddPeriodicJob("poll_mpesa_payments", 30*time.Second, config.PollPayments)
+	}
+
+	// Expired WhatsApp media cleanup - runs every 15 minutes
+	if config.CleanupMedia != nil {
+		defaultJobScheduler.AddPeriodicJob("cleanup_media", 15*time.Minute, config.CleanupMedia)
+	}
+
+	// Expired background export cleanup - runs hourly
+	if config.CleanupExports != nil {
+		defaultJobScheduler.AddPeriodicJob("cleanup_exports", time.Hour, config.CleanupExports)
+	}
+
+	// Inventory value snapshot - runs hourly, the last run of a day is kept
+	if config.SnapshotRepo != nil {
+		defaultJobScheduler.AddPeriodicJob("inventory_snapshots", time.Hour, func() error {
+			shops, _, err := config.ShopRepo.List(1000, 0)
+			if err != nil {
+				return err
+			}
+
+			now := time.Now()
+			for _, shop := range shops {
+				if !shop.IsActive {
+					continue
+				}
+				if _, err := config.SnapshotRepo.Snapshot(shop.ID, now); err != nil {
+					log.Printf("❌ Failed to snapshot inventory for shop %s: %v", shop.Name, err)
+				}
+			}
+			return nil
+		})
+	}
+
+	// Month-end closing stock - taken once a month has closed, a few shops
+	// a minute, catching up on months missed while the server was down
+	if config.ClosingStockRepo != nil {
+		defaultJobScheduler.AddPeriodicJob("monthly_snapshot", time.Minute, func() error {
+			_, err := config.ClosingStockRepo.CloseDue(time.Now(), ClosingStocksPerTick)
+			return err
+		})
+	}
+
+	// Birthday loyalty points - checked hourly from 08:00 so greetings
+	// don't go out overnight; each customer is rewarded once a year
+	if config.BirthdayRewards != nil {
+		defaultJobScheduler.AddPeriodicJob("birthday_rewards", time.Hour, func() error {
+			now := time.Now()
+			if now.Hour() < 8 {
+				return nil
+			}
+
+			return config.ShopRepo.EachActive(shopBatchSize, func(shops []models.Shop) error {
+				for _, shop := range shops {
+					if shop.BirthdayBonusPoints <= 0 {
+						continue
+					}
+					n, err := config.BirthdayRewards(&shop, now)
+					if err != nil {
+						log.Printf("❌ Failed to give birthday rewards for shop %s: %v", shop.Name, err)
+					}
+					if n > 0 {
+						log.Printf("🎂 Birthday points given to %d customers of shop %s", n, shop.Name)
+					}
+				}
+				return nil
+			})
+		})
+	}
+
+	// Weekly and monthly sales rollups - a few at a time every minute, so
+	// the weeks and months that just ended, those missed while the server
+	// was down and those whose sales changed are rolled up without one
+	// tick doing them all
+	if config.SummaryRepo != nil {
+		defaultJobScheduler.AddPeriodicJob("sales_rollups", time.Minute, func() error {
+			n, err := config.SummaryRepo.RollupDue(time.Now(), RollupsPerTick)
+			if n > 0 {
+				log.Printf("📊 Rolled up %d weekly and monthly sales summaries", n)
+			}
+			return err
+		})
+	}
+
+	// Recurring expenses (rent, airtime...) - recorded when due, catching up
+	// on any missed while the server was down
+	if config.ExpenseRepo != nil {
+		defaultJobScheduler.AddPeriodicJob("recurring_expenses", time.Hour, func() error {
+			n, err := config.ExpenseRepo.RecordDue(time.Now())
+			if n > 0 {
+				log.Printf("💸 Recorded %d recurring expenses", n)
+			}
+			return err
+		})
+	}
+
 	log.Println("✅ Advanced job defaultJobScheduler initialized with jobs:")
 	log.Println("   - daily_reports (24h)")
 	log.Println("   - low_stock_check (6h)")
 	log.Println("   - weekly_reports (7d)")
 	log.Println("   - monthly_reports (30d)")
+	if config.ExpirePayments != nil {
+		log.Println("   - expire_mpesa_payments (1m)")
+	}
+	if config.PollPayments != nil {
+		log.Println("   - poll_mpesa_payments (30s)")
+	}
+	if config.EmailReports != nil {
+		log.Println("   - email_reports (1h)")
+	}
+	if config.SnapshotRepo != nil {
+		log.Println("   - inventory_snapshots (1h)")
+	}
+	if config.ClosingStockRepo != nil {
+		log.Println("   - monthly_snapshot (1m, once a month has closed)")
+	}
+	if config.CleanupMedia != nil {
+		log.Println("   - cleanup_media (15m)")
+	}
+	if config.CleanupExports != nil {
+		log.Println("   - cleanup_exports (1h)")
+	}
+	if config.BirthdayRewards != nil {
+		log.Println("   - birthday_rewards (1h, from 08:00)")
+	}
+	if config.SummaryRepo != nil {
+		log.Println("   - sales_rollups (1m)")
+	}
+	if config.ExpenseRepo != nil {
+		log.Println("   - recurring_expenses (1h)")
+	}
 }
@@ -0,0 +1,80 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	docshandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/docs"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/middleware"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+)
+
+// frontendIndex is the React app's entry page, served for its own routes
+const frontendIndex = "./dukapos-frontend/dist/index.html"
+
+// RegisterServerRoutes registers every route the server serves ahead of its
+// webhooks: the React frontend, health check, API docs and info, then
+// RegisterAllRoutes. Parameter routes registered before RegisterAllRoutes
+// would catch its fixed paths, so none are added here.
+func RegisterServerRoutes(config RouteConfig) {
+	app := config.App
+
+	// Serve the React frontend built with Vite while the web dashboard is on
+	dashboardOff := func(c *fiber.Ctx) bool { return !middleware.FlagEnabled(models.FeatureFlagWebDashboard) }
+	dashboardOn := middleware.RequireFlag(models.FeatureFlagWebDashboard)
+	app.Static("/", "./dukapos-frontend/dist", fiber.Static{Next: dashboardOff})
+
+	// Landing, login and register pages, the dashboard and admin (React app
+	// handles routing)
+	index := func(c *fiber.Ctx) error {
+		return c.SendFile(frontendIndex)
+	}
+	for _, path := range []string{"/", "/login", "/register", "/dashboard/*", "/admin/*"} {
+		app.Get(path, dashboardOn, index)
+	}
+
+	api := app.Group("/api")
+
+	// Health check
+	api.Get("/health", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{
+			"status":  "healthy",
+			"service": "DukaPOS",
+			"version": "1.0.0",
+		})
+	})
+
+	// API Documentation
+	docshandler.New().RegisterRoutes(app)
+
+	// API Info
+	api.Get("/", func(c *fiber.Ctx) error {
+		features := []string{"inventory", "sales"}
+		if middleware.FlagEnabled(models.FeatureFlagMpesa) {
+			features = append(features, "mpesa")
+		}
+		if middleware.FlagEnabled(models.FeatureFlagStaffAccounts) {
+			features = append(features, "staff")
+		}
+		if middleware.FlagEnabled(models.FeatureFlagAnalytics) {
+			features = append(features, "api", "webhooks")
+		}
+		if middleware.FlagEnabled(models.FeatureFlagMultipleShops) {
+			features = append(features, "multi-shop")
+		}
+
+		return c.JSON(fiber.Map{
+			"name":        "DukaPOS API",
+			"version":     "1.0.0",
+			"description": "REST API for Kenyan Duka POS",
+			"base_url":    "/api/v1",
+			"channels":    []string{"WhatsApp", "USSD", "REST"},
+			"features":    features,
+		})
+	})
+
+	// Plan routes
+	api.Get("/plans", config.PlanInfoHandler.GetAllPlans)
+
+	RegisterAllRoutes(config)
+}
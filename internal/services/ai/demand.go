@@ -0,0 +1,263 @@
+package ai
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	historyDays        = 30   // days of a product's sales a prediction looks at
+	seasonalityDays    = 28   // four full weeks, so every day of the week counts the same
+	minSeasonalityDays = 14   // two weeks before the shop's weekly pattern is trusted
+	fullConfidenceDays = 14   // days of history for full confidence in the data volume
+	trendThreshold     = 0.15 // change across the history that counts as a trend
+	maxStockoutDays    = 999
+)
+
+// Seasonality is how busy each day of the week is next to the shop's
+// average day, indexed by time.Weekday, so a full week averages 1. The
+// zero value treats every day alike.
+type Seasonality [7]float64
+
+// Factor is the multiplier for a day of the week
+func (s Seasonality) Factor(day time.Weekday) float64 {
+	if s[day] <= 0 {
+		return 1
+	}
+	return s[day]
+}
+
+// LearnSeasonality works out how much busier the shop's weekends are than
+// its weekdays from its sales over the last four weeks. Less than two weeks
+// of history isn't enough to tell, and gives no seasonality.
+func LearnSeasonality(sales []SalesData, now time.Time) Seasonality {
+	daily := dailyQuantities(sales, now, seasonalityDays)
+	if len(daily) < minSeasonalityDays {
+		return Seasonality{}
+	}
+
+	// Each day is taken against the week around it, so a trend isn't
+	// mistaken for a weekly pattern
+	var weekdayTotal, weekendTotal float64
+	var weekdays, weekends int
+	for i := 3; i+3 < len(daily); i++ {
+		week := 0.0
+		for _, d := range daily[i-3 : i+4] {
+			week += d.quantity
+		}
+		if week == 0 {
+			continue
+		}
+		ratio := daily[i].quantity / (week / 7)
+		if isWeekend(daily[i].day.Weekday()) {
+			weekendTotal += ratio
+			weekends++
+		} else {
+			weekdayTotal += ratio
+			weekdays++
+		}
+	}
+	if weekdays == 0 || weekends == 0 {
+		return Seasonality{}
+	}
+
+	weekday := weekdayTotal / float64(weekdays)
+	weekend := weekendTotal / float64(weekends)
+	average := (5*weekday + 2*weekend) / 7
+	if average == 0 {
+		return Seasonality{}
+	}
+
+	// A shop shut on weekends still gets a small factor, so a product's
+	// sales can be adjusted by it
+	weekdayFactor := math.Max(weekday/average, 0.1)
+	weekendFactor := math.Max(weekend/average, 0.1)
+	var s Seasonality
+	for day := range s {
+		if isWeekend(time.Weekday(day)) {
+			s[day] = weekendFactor
+		} else {
+			s[day] = weekdayFactor
+		}
+	}
+	return s
+}
+
+// DemandModel is a product's daily demand fitted to its recent sales: a
+// straight-line trend through its daily quantities once the shop's weekly
+// pattern is taken out
+type DemandModel struct {
+	Days        int     // days of history, from the first sale to yesterday
+	AvgDaily    float64 // units a day, counting days without sales
+	Level       float64 // seasonally adjusted units a day as of yesterday
+	Slope       float64 // change in the seasonally adjusted level per day
+	Change      float64 // change across the history as a fraction of the average, e.g. 0.2 for up 20%
+	Confidence  float64 // 0-1, from the days of history and how closely sales follow the fit
+	Seasonality Seasonality
+
+	today time.Time
+}
+
+// FitDemand fits a demand model to a product's sales over the last 30 days.
+// Today is left out as it isn't over yet.
+func FitDemand(sales []SalesData, seasonality Seasonality, now time.Time) DemandModel {
+	m := DemandModel{Seasonality: seasonality, today: startOfDay(now)}
+	daily := dailyQuantities(sales, now, historyDays)
+	n := len(daily)
+	m.Days = n
+	if n == 0 {
+		return m
+	}
+
+	adjusted := make([]float64, n)
+	total, meanY := 0.0, 0.0
+	for i, d := range daily {
+		total += d.quantity
+		adjusted[i] = d.quantity / seasonality.Factor(d.day.Weekday())
+		meanY += adjusted[i]
+	}
+	m.AvgDaily = total / float64(n)
+	meanY /= float64(n)
+	if meanY == 0 {
+		return m
+	}
+
+	// Least squares over the day offsets
+	meanX := float64(n-1) / 2
+	sxx, sxy := 0.0, 0.0
+	for i, y := range adjusted {
+		dx := float64(i) - meanX
+		sxx += dx * dx
+		sxy += dx * (y - meanY)
+	}
+	if sxx > 0 {
+		m.Slope = sxy / sxx
+	}
+	intercept := meanY - m.Slope*meanX
+	m.Level = intercept + m.Slope*float64(n-1)
+	m.Change = m.Slope * float64(n-1) / meanY
+
+	// Spread around the fitted line, relative to the average, so a steady
+	// trend or a regular weekly pattern doesn't count against the fit
+	variance := 0.0
+	for i, y := range adjusted {
+		r := y - (intercept + m.Slope*float64(i))
+		variance += r * r
+	}
+	cv := math.Min(math.Sqrt(variance/float64(n))/meanY, 1)
+	volume := math.Min(float64(n)/fullConfidenceDays, 1)
+	m.Confidence = Round(volume*(1-cv)*100) / 100
+
+	return m
+}
+
+// Trend is up, down or stable
+func (m DemandModel) Trend() string {
+	switch {
+	case m.Change > trendThreshold:
+		return "up"
+	case m.Change < -trendThreshold:
+		return "down"
+	}
+	return "stable"
+}
+
+// Demand is the units expected to sell on the day so many days from today
+// (0 for today). The trend is carried forward no further than the history
+// it was fitted to.
+func (m DemandModel) Demand(day int) float64 {
+	ahead := day + 1
+	if ahead > m.Days {
+		ahead = m.Days
+	}
+	level := m.Level + m.Slope*float64(ahead)
+	if level <= 0 {
+		return 0
+	}
+	return level * m.Seasonality.Factor(m.today.AddDate(0, 0, day).Weekday())
+}
+
+// Forecast is the units expected to sell over the coming days, today
+// included
+func (m DemandModel) Forecast(days int) float64 {
+	total := 0.0
+	for day := 0; day < days; day++ {
+		total += m.Demand(day)
+	}
+	return total
+}
+
+// DaysUntilStockout is how many whole days the stock covers, up to 999 when
+// demand is dying out
+func (m DemandModel) DaysUntilStockout(stock int) int {
+	used := 0.0
+	for day := 0; day < maxStockoutDays; day++ {
+		used += m.Demand(day)
+		if used > float64(stock) {
+			return day
+		}
+	}
+	return maxStockoutDays
+}
+
+// RecommendedOrder is how many units to order on top of the stock to cover
+// the coming week, with more safety stock the less certain the model is
+func (m DemandModel) RecommendedOrder(stock int) int {
+	safety := 1.2 + 0.3*(1-m.Confidence)
+	order := int(math.Ceil(m.Forecast(7)*safety)) - stock
+	if order < 0 {
+		return 0
+	}
+	return order
+}
+
+type dayQuantity struct {
+	day      time.Time
+	quantity float64
+}
+
+// dailyQuantities totals sales by day, from the day of the first sale in
+// the last so many days to yesterday, with days without sales as zero
+func dailyQuantities(sales []SalesData, now time.Time, days int) []dayQuantity {
+	today := startOfDay(now)
+	start := today.AddDate(0, 0, -days)
+
+	first := today
+	for _, s := range sales {
+		day := startOfDay(s.Date.In(now.Location()))
+		if !day.Before(start) && day.Before(first) {
+			first = day
+		}
+	}
+	n := daysBetween(first, today)
+	if n <= 0 {
+		return nil
+	}
+
+	daily := make([]dayQuantity, n)
+	for i := range daily {
+		daily[i].day = first.AddDate(0, 0, i)
+	}
+	for _, s := range sales {
+		i := daysBetween(first, startOfDay(s.Date.In(now.Location())))
+		if i >= 0 && i < n {
+			daily[i].quantity += float64(s.Quantity)
+		}
+	}
+	return daily
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// daysBetween counts calendar days, rounding off the odd hour from
+// daylight saving changes
+func daysBetween(from, to time.Time) int {
+	return int(math.Round(to.Sub(from).Hours() / 24))
+}
+
+func isWeekend(day time.Weekday) bool {
+	return day == time.Saturday || day == time.Sunday
+}
@@ -0,0 +1,235 @@
+package ai
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+)
+
+const (
+	MovementDeadStock  = "dead_stock"
+	MovementSlowMoving = "slow_moving"
+	MovementNormal     = "normal"
+	MovementFastMoving = "fast_moving"
+)
+
+// TurnoverThresholds classify products by turnover ratio, expressed per 30
+// days so they hold for any report window
+type TurnoverThresholds struct {
+	SlowBelow float64 `json:"slow_below"`
+	FastAbove float64 `json:"fast_above"`
+}
+
+// DefaultTurnoverThresholds are tuned for the opening/closing estimate used
+// below, where a month's ratio stays under 2 unless the shop restocked
+var DefaultTurnoverThresholds = TurnoverThresholds{SlowBelow: 0.5, FastAbove: 1.5}
+
+type ProductTurnover struct {
+	ProductID             uint    `json:"product_id"`
+	ProductName           string  `json:"product_name"`
+	CurrentStock          int     `json:"current_stock"`
+	UnitsSold             int     `json:"units_sold"`
+	COGS                  float64 `json:"cogs"`
+	AverageInventoryValue float64 `json:"average_inventory_value"`
+	TurnoverRatio         float64 `json:"turnover_ratio"`
+	Movement              string  `json:"movement"`
+}
+
+type TurnoverReport struct {
+	ShopID          uint               `json:"shop_id"`
+	Days            int                `json:"days"`
+	Thresholds      TurnoverThresholds `json:"thresholds"`
+	Products        []ProductTurnover  `json:"products"`
+	DeadStockCount  int                `json:"dead_stock_count"`
+	SlowMovingCount int                `json:"slow_moving_count"`
+	FastMovingCount int                `json:"fast_moving_count"`
+	GeneratedAt     time.Time          `json:"generated_at"`
+}
+
+type DeadStockItem struct {
+	ProductID    uint      `json:"product_id"`
+	ProductName  string    `json:"product_name"`
+	Category     string    `json:"category"`
+	CurrentStock int       `json:"current_stock"`
+	StockValue   float64   `json:"stock_value"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type DeadStockReport struct {
+	ShopID      uint            `json:"shop_id"`
+	Days        int             `json:"days"`
+	Products    []DeadStockItem `json:"products"`
+	TotalValue  float64         `json:"total_value"`
+	GeneratedAt time.Time       `json:"generated_at"`
+}
+
+// SetTurnoverThresholds overrides the default slow/fast-moving thresholds
+func (s *PredictionService) SetTurnoverThresholds(thresholds TurnoverThresholds) {
+	s.turnoverThresholds = thresholds
+}
+
+// TurnoverThresholds returns the configured slow/fast-moving thresholds
+func (s *PredictionService) TurnoverThresholds() TurnoverThresholds {
+	return s.turnoverThresholds
+}
+
+// GetInventoryTurnover returns turnover_ratio = COGS / average inventory value
+// for each product over the last days. Only current stock is known, so the
+// opening inventory is taken as current stock plus the units sold since.
+func (s *PredictionService) GetInventoryTurnover(shopID uint, days int, thresholds TurnoverThresholds) (*TurnoverReport, error) {
+	products, sold, start, err := s.productMovement(shopID, days)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &TurnoverReport{
+		ShopID:      shopID,
+		Days:        days,
+		Thresholds:  thresholds,
+		Products:    make([]ProductTurnover, 0, len(products)),
+		GeneratedAt: time.Now(),
+	}
+
+	for _, product := range products {
+		m := sold[product.ID]
+		closing := float64(product.StockOnHand()) * product.CostPrice
+		opening := float64(product.StockOnHand()+m.units) * product.CostPrice
+		average := (opening + closing) / 2
+
+		turnover := ProductTurnover{
+			ProductID:             product.ID,
+			ProductName:           product.Name,
+			CurrentStock:          product.CurrentStock,
+			UnitsSold:             m.units,
+			COGS:                  Round(m.cogs*100) / 100,
+			AverageInventoryValue: Round(average*100) / 100,
+		}
+		switch {
+		case average > 0:
+			ratio := m.cogs / average
+			turnover.TurnoverRatio = Round(ratio*100) / 100
+			turnover.Movement = classifyMovement(product, m.units, ratio*30/float64(days), start, thresholds)
+		case m.units == 0:
+			turnover.Movement = classifyMovement(product, 0, 0, start, thresholds)
+		default:
+			// Sold without a cost price, so turnover can't be measured
+			turnover.Movement = MovementNormal
+		}
+
+		switch turnover.Movement {
+		case MovementDeadStock:
+			report.DeadStockCount++
+		case MovementSlowMoving:
+			report.SlowMovingCount++
+		case MovementFastMoving:
+			report.FastMovingCount++
+		}
+		report.Products = append(report.Products, turnover)
+	}
+
+	sort.SliceStable(report.Products, func(i, j int) bool {
+		return report.Products[i].TurnoverRatio > report.Products[j].TurnoverRatio
+	})
+
+	return report, nil
+}
+
+// GetDeadStock returns stocked products with no sales in the last days.
+// Products added during the window are left out as they have not had the
+// full period to sell.
+func (s *PredictionService) GetDeadStock(shopID uint, days int) (*DeadStockReport, error) {
+	products, sold, start, err := s.productMovement(shopID, days)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DeadStockReport{
+		ShopID:      shopID,
+		Days:        days,
+		Products:    make([]DeadStockItem, 0),
+		GeneratedAt: time.Now(),
+	}
+
+	for _, product := range products {
+		if classifyMovement(product, sold[product.ID].units, 0, start, s.turnoverThresholds) != MovementDeadStock {
+			continue
+		}
+
+		value := float64(product.StockOnHand()) * product.CostPrice
+		report.TotalValue += value
+		report.Products = append(report.Products, DeadStockItem{
+			ProductID:    product.ID,
+			ProductName:  product.Name,
+			Category:     product.Category,
+			CurrentStock: product.CurrentStock,
+			StockValue:   Round(value*100) / 100,
+			CreatedAt:    product.CreatedAt,
+		})
+	}
+
+	sort.SliceStable(report.Products, func(i, j int) bool {
+		return report.Products[i].StockValue > report.Products[j].StockValue
+	})
+	report.TotalValue = Round(report.TotalValue*100) / 100
+
+	return report, nil
+}
+
+type productSales struct {
+	units int
+	cogs  float64
+}
+
+// productMovement loads the shop's stocked products and their sales in the
+// window. Bundles are skipped as their sales are recorded on the components.
+func (s *PredictionService) productMovement(shopID uint, days int) ([]models.Product, map[uint]productSales, time.Time, error) {
+	end := time.Now()
+	start := end.AddDate(0, 0, -days)
+
+	allProducts, err := s.productRepo.GetByShopID(shopID)
+	if err != nil {
+		return nil, nil, start, fmt.Errorf("failed to get products: %w", err)
+	}
+
+	sales, err := s.saleRepo.GetByDateRange(shopID, start, end)
+	if err != nil {
+		return nil, nil, start, fmt.Errorf("failed to get sales: %w", err)
+	}
+
+	sold := make(map[uint]productSales)
+	for _, sale := range sales {
+		m := sold[sale.ProductID]
+		m.units += sale.Quantity
+		m.cogs += sale.CostAmount
+		sold[sale.ProductID] = m
+	}
+
+	products := make([]models.Product, 0, len(allProducts))
+	for _, product := range allProducts {
+		if !product.IsBundle {
+			products = append(products, product)
+		}
+	}
+
+	return products, sold, start, nil
+}
+
+// classifyMovement flags a product from its units sold and its turnover ratio
+// scaled to 30 days
+func classifyMovement(product models.Product, unitsSold int, monthlyTurnover float64, windowStart time.Time, thresholds TurnoverThresholds) string {
+	if unitsSold == 0 {
+		if product.CurrentStock > 0 && product.CreatedAt.Before(windowStart) {
+			return MovementDeadStock
+		}
+		return MovementNormal
+	}
+	if monthlyTurnover < thresholds.SlowBelow {
+		return MovementSlowMoving
+	}
+	if monthlyTurnover > thresholds.FastAbove {
+		return MovementFastMoving
+	}
+	return MovementNormal
+}
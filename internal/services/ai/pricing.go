@@ -0,0 +1,75 @@
+package ai
+
+import (
+	"math"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+)
+
+// PriceChange is one price history entry with the margin it left the
+// product on. Margins are a percentage of the selling price.
+type PriceChange struct {
+	ProductID    uint               `json:"product_id"`
+	ProductName  string             `json:"product_name"`
+	OldPrice     float64            `json:"old_price"`
+	NewPrice     float64            `json:"new_price"`
+	OldCostPrice float64            `json:"old_cost_price"`
+	NewCostPrice float64            `json:"new_cost_price"`
+	OldMargin    float64            `json:"old_margin"`
+	NewMargin    float64            `json:"new_margin"`
+	Source       models.PriceSource `json:"source"`
+	ChangedAt    time.Time          `json:"changed_at"`
+}
+
+// SetPriceHistoryRepo enables price changes in trend reports
+func (s *PredictionService) SetPriceHistoryRepo(priceRepo *repository.PriceHistoryRepository) {
+	s.priceRepo = priceRepo
+}
+
+// GetPriceChanges lists a shop's price changes over the last days, oldest
+// first, so margin shifts can be read next to sales trends
+func (s *PredictionService) GetPriceChanges(shopID uint, days int) ([]PriceChange, error) {
+	changes := []PriceChange{}
+	if s.priceRepo == nil {
+		return changes, nil
+	}
+
+	history, err := s.priceRepo.GetByShopSince(shopID, time.Now().AddDate(0, 0, -days))
+	if err != nil || len(history) == 0 {
+		return changes, err
+	}
+
+	products, err := s.productRepo.GetByShopID(shopID)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[uint]string, len(products))
+	for _, p := range products {
+		names[p.ID] = p.Name
+	}
+
+	for _, h := range history {
+		changes = append(changes, PriceChange{
+			ProductID:    h.ProductID,
+			ProductName:  names[h.ProductID],
+			OldPrice:     h.OldPrice,
+			NewPrice:     h.NewPrice,
+			OldCostPrice: h.OldCostPrice,
+			NewCostPrice: h.NewCostPrice,
+			OldMargin:    marginPercent(h.OldPrice, h.OldCostPrice),
+			NewMargin:    marginPercent(h.NewPrice, h.NewCostPrice),
+			Source:       h.Source,
+			ChangedAt:    h.CreatedAt,
+		})
+	}
+	return changes, nil
+}
+
+func marginPercent(price, cost float64) float64 {
+	if price <= 0 {
+		return 0
+	}
+	return math.Round((price-cost)/price*1000) / 10
+}
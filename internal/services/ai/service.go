@@ -22,8 +22,10 @@ type PredictionService struct {
 	productRepo         *repository.ProductRepository
 	saleRepo            *repository.SaleRepository
 	summaryRepo         *repository.DailySummaryRepository
+	priceRepo           *repository.PriceHistoryRepository
 	minDataDays         int
 	confidenceThreshold float64
+	turnoverThresholds  TurnoverThresholds
 	openAIAPIKey        string
 	httpClient          *http.Client
 }
@@ -39,6 +41,7 @@ func NewPredictionService(
 		summaryRepo:         summaryRepo,
 		minDataDays:         7,
 		confidenceThreshold: 0.6,
+		turnoverThresholds:  DefaultTurnoverThresholds,
 		httpClient:          &http.Client{Timeout: 30 * time.Second},
 	}
 }
@@ -160,8 +163,9 @@ func (s *PredictionService) GetPredictions(shopID uint) (*ShopPrediction, error)
 		GeneratedAt:   time.Now(),
 	}
 
+	seasonality := s.shopSeasonality(shopID)
 	for _, product := range products {
-		pred := s.predictProduct(product.ID, product.Name, product.CurrentStock, shopID)
+		pred := s.predictProduct(product.ID, product.Name, product.CurrentStock, shopID, seasonality)
 		prediction.Predictions = append(prediction.Predictions, *pred)
 
 		if pred.Priority == "urgent" {
@@ -176,17 +180,18 @@ func (s *PredictionService) GetPredictions(shopID uint) (*ShopPrediction, error)
 	return prediction, nil
 }
 
-func (s *PredictionService) predictProduct(productID uint, productName string, currentStock int, shopID uint) *ProductPrediction {
-	salesData := s.getHistoricalSales(productID, shopID)
+func (s *PredictionService) predictProduct(productID uint, productName string, currentStock int, shopID uint, seasonality Seasonality) *ProductPrediction {
+	now := time.Now()
+	model := FitDemand(s.getHistoricalSales(productID, shopID), seasonality, now)
 
 	pred := &ProductPrediction{
 		ProductID:    productID,
 		ProductName:  productName,
 		CurrentStock: currentStock,
-		LastUpdated:  time.Now(),
+		LastUpdated:  now,
 	}
 
-	if len(salesData) < s.minDataDays {
+	if model.Days < s.minDataDays {
 		pred.AvgDailySales = 0
 		pred.DaysUntilStockout = -1
 		pred.RecommendedOrder = 0
@@ -196,45 +201,17 @@ func (s *PredictionService) predictProduct(productID uint, productName string, c
 		return pred
 	}
 
-	avgSales := s.calculateAverageDailySales(salesData)
-	trend, trendPct := s.calculateTrend(salesData)
-	confidence := s.calculateConfidence(salesData)
-	seasonalMultiplier := s.getSeasonalMultiplier()
-
-	pred.AvgDailySales = avgSales
-	pred.TrendPercentage = trendPct
-	pred.SeasonalMultiplier = seasonalMultiplier
-	pred.Confidence = confidence
-
-	if trend > 0.1 {
-		pred.Trend = "up"
-	} else if trend < -0.1 {
-		pred.Trend = "down"
-	} else {
-		pred.Trend = "stable"
-	}
-
-	if avgSales > 0 {
-		pred.DaysUntilStockout = int(float64(currentStock) / avgSales)
-	} else {
-		pred.DaysUntilStockout = 999
-	}
-
-	safetyBuffer := 1.5
-	if pred.Trend == "down" {
-		safetyBuffer = 1.2
-	} else if pred.Trend == "up" {
-		safetyBuffer = 1.8
-	}
-
-	pred.RecommendedOrder = int(avgSales * 7 * seasonalMultiplier * safetyBuffer)
-	if pred.RecommendedOrder < 1 {
-		pred.RecommendedOrder = 1
-	}
+	pred.AvgDailySales = Round(model.AvgDaily*100) / 100
+	pred.Trend = model.Trend()
+	pred.TrendPercentage = Round(model.Change*1000) / 10
+	pred.SeasonalMultiplier = Round(seasonality.Factor(now.Weekday())*100) / 100
+	pred.Confidence = model.Confidence
+	pred.DaysUntilStockout = model.DaysUntilStockout(currentStock)
+	pred.RecommendedOrder = model.RecommendedOrder(currentStock)
 
 	if currentStock == 0 {
 		pred.Priority = "urgent"
-	} else if pred.DaysUntilStockout <= 3 && confidence >= s.confidenceThreshold {
+	} else if pred.DaysUntilStockout <= 3 && model.Confidence >= s.confidenceThreshold {
 		pred.Priority = "urgent"
 	} else if pred.DaysUntilStockout <= 7 {
 		pred.Priority = "warning"
@@ -245,15 +222,33 @@ func (s *PredictionService) predictProduct(productID uint, productName string, c
 	return pred
 }
 
+// getHistoricalSales returns a product's sales since the start of the day
+// 30 days ago
 func (s *PredictionService) getHistoricalSales(productID uint, shopID uint) []SalesData {
 	end := time.Now()
-	start := end.AddDate(0, 0, -30)
+	start := startOfDay(end).AddDate(0, 0, -historyDays)
 
 	sales, err := s.saleRepo.GetByProductAndDateRange(productID, shopID, start, end)
 	if err != nil {
 		return []SalesData{}
 	}
+	return toSalesData(sales)
+}
 
+// shopSeasonality learns the shop's weekly pattern from all its sales over
+// the last four weeks
+func (s *PredictionService) shopSeasonality(shopID uint) Seasonality {
+	end := time.Now()
+	start := startOfDay(end).AddDate(0, 0, -seasonalityDays)
+
+	sales, err := s.saleRepo.GetByDateRange(shopID, start, end)
+	if err != nil {
+		return Seasonality{}
+	}
+	return LearnSeasonality(toSalesData(sales), end)
+}
+
+func toSalesData(sales []models.Sale) []SalesData {
 	data := make([]SalesData, len(sales))
 	for i, sale := range sales {
 		data[i] = SalesData{
@@ -262,172 +257,9 @@ func (s *PredictionService) getHistoricalSales(productID uint, shopID uint) []Sa
 			Revenue:  sale.TotalAmount,
 		}
 	}
-
 	return data
 }
 
-func (s *PredictionService) calculateAverageDailySales(data []SalesData) float64 {
-	if len(data) == 0 {
-		return 0
-	}
-
-	daySales := make(map[string]int)
-	for _, d := range data {
-		day := d.Date.Format("2006-01-02")
-		daySales[day] += d.Quantity
-	}
-
-	total := 0
-	for _, qty := range daySales {
-		total += qty
-	}
-
-	days := len(daySales)
-	if days == 0 {
-		return 0
-	}
-
-	return float64(total) / float64(days)
-}
-
-func (s *PredictionService) calculateTrend(data []SalesData) (float64, float64) {
-	if len(data) < 2 {
-		return 0, 0
-	}
-
-	daySales := make(map[string]int)
-	for _, d := range data {
-		day := d.Date.Format("2006-01-02")
-		daySales[day] += d.Quantity
-	}
-
-	type dayData struct {
-		day   string
-		sales int
-	}
-
-	days := make([]dayData, 0, len(daySales))
-	for day, sales := range daySales {
-		days = append(days, dayData{day, sales})
-	}
-
-	for i := 0; i < len(days)-1; i++ {
-		for j := i + 1; j < len(days); j++ {
-			if days[j].day < days[i].day {
-				days[i], days[j] = days[j], days[i]
-			}
-		}
-	}
-
-	n := float64(len(days))
-	if n < 2 {
-		return 0, 0
-	}
-
-	sumX, sumY, sumXY, sumX2 := 0.0, 0.0, 0.0, 0.0
-	for i, d := range days {
-		x := float64(i)
-		y := float64(d.sales)
-		sumX += x
-		sumY += y
-		sumXY += x * y
-		sumX2 += x * x
-	}
-
-	denominator := n*sumX2 - sumX*sumX
-	if denominator == 0 {
-		return 0, 0
-	}
-
-	slope := (n*sumXY - sumX*sumY) / denominator
-
-	avgY := sumY / n
-	if avgY == 0 {
-		return 0, 0
-	}
-
-	percentageChange := (slope / avgY) * 100
-
-	return slope / avgY, percentageChange
-}
-
-func (s *PredictionService) calculateConfidence(data []SalesData) float64 {
-	if len(data) < 7 {
-		return float64(len(data)) / 10.0
-	}
-
-	daySales := make(map[string]int)
-	for _, d := range data {
-		day := d.Date.Format("2006-01-02")
-		daySales[day] += d.Quantity
-	}
-
-	values := make([]float64, 0, len(daySales))
-	for _, qty := range daySales {
-		values = append(values, float64(qty))
-	}
-
-	mean := 0.0
-	for _, v := range values {
-		mean += v
-	}
-	mean /= float64(len(values))
-
-	if mean == 0 {
-		return 0
-	}
-
-	variance := 0.0
-	for _, v := range values {
-		diff := v - mean
-		variance += diff * diff
-	}
-	variance /= float64(len(values))
-
-	stdDev := variance
-	if stdDev > 0 {
-		stdDev = Sqrt(stdDev)
-	}
-
-	cv := (stdDev / mean)
-	if cv > 1 {
-		cv = 1
-	}
-
-	confidence := 1.0 - cv
-	if confidence < 0 {
-		confidence = 0
-	}
-
-	return Round(confidence*100) / 100
-}
-
-func (s *PredictionService) getSeasonalMultiplier() float64 {
-	month := int(time.Now().Month())
-	dayOfWeek := time.Now().Weekday()
-
-	seasonalFactors := map[int]float64{
-		1: 0.85, 2: 0.95, 3: 1.0, 4: 1.05,
-		5: 1.0, 6: 0.9, 7: 0.8, 8: 0.85,
-		9: 1.0, 10: 1.1, 11: 1.25, 12: 1.35,
-	}
-
-	weekdayFactors := map[time.Weekday]float64{
-		time.Monday:    0.9,
-		time.Tuesday:   1.0,
-		time.Wednesday: 1.1,
-		time.Thursday:  1.1,
-		time.Friday:    1.2,
-		time.Saturday:  1.35,
-		time.Sunday:    0.75,
-	}
-
-	monthFactor := seasonalFactors[month]
-	weekFactor := weekdayFactors[dayOfWeek]
-
-	return (monthFactor + weekFactor) / 2
-}
-
 func (s *PredictionService) GetSalesAnalytics(shopID uint, days int) (*SalesAnalytics, error) {
 	end := time.Now()
 	start := end.AddDate(0, 0, -days)
@@ -538,6 +370,13 @@ func (s *PredictionService) GetRestockRecommendations(shopID uint) ([]ProductPre
 	return recommendations, nil
 }
 
+// RecommendedOrder is how many units of a product to reorder on top of its
+// stock to cover the coming week, or 0 when the stock already does or
+// without enough sales history to say
+func (s *PredictionService) RecommendedOrder(product *models.Product) int {
+	return s.predictProduct(product.ID, product.Name, product.CurrentStock, product.ShopID, s.shopSeasonality(product.ShopID)).RecommendedOrder
+}
+
 func (s *PredictionService) GetInventoryValue(shopID uint) (map[string]float64, error) {
 	products, err := s.productRepo.GetByShopID(shopID)
 	if err != nil {
@@ -551,8 +390,8 @@ func (s *PredictionService) GetInventoryValue(shopID uint) (map[string]float64,
 	}
 
 	for _, p := range products {
-		result["total_cost_value"] += p.CostPrice * float64(p.CurrentStock)
-		result["total_retail_value"] += p.SellingPrice * float64(p.CurrentStock)
+		result["total_cost_value"] += p.CostPrice * float64(p.StockOnHand())
+		result["total_retail_value"] += p.SellingPrice * float64(p.StockOnHand())
 	}
 
 	result["potential_profit"] = result["total_retail_value"] - result["total_cost_value"]
@@ -606,22 +445,12 @@ func (s *PredictionService) GenerateForecast(ctx context.Context, shopID uint, r
 		days = 7
 	}
 
-	salesData := s.getHistoricalSales(req.ProductID, shopID)
-	avgDaily := s.calculateAverageDailySales(salesData)
-	trend, _ := s.calculateTrend(salesData)
-	seasonal := s.getSeasonalMultiplier()
+	model := FitDemand(s.getHistoricalSales(req.ProductID, shopID), s.shopSeasonality(shopID), time.Now())
 
-	var trendFactor float64 = 1.0
-	if trend > 0.1 {
-		trendFactor = 1.2
-	} else if trend < -0.1 {
-		trendFactor = 0.8
-	}
-
-	forecastedSales := int(avgDaily * float64(days) * trendFactor * seasonal)
+	forecastedSales := int(Round(model.Forecast(days)))
 	daysRemaining := 0
-	if avgDaily > 0 {
-		daysRemaining = int(float64(product.CurrentStock) / (avgDaily * trendFactor * seasonal))
+	if model.AvgDaily > 0 {
+		daysRemaining = model.DaysUntilStockout(product.StockOnHand())
 	}
 
 	targetStock := req.TargetStock
@@ -640,8 +469,6 @@ func (s *PredictionService) GenerateForecast(ctx context.Context, shopID uint, r
 	}
 	orderDate := time.Now().AddDate(0, 0, orderDays).Format("2006-01-02")
 
-	confidence := s.calculateConfidence(salesData)
-
 	return &ForecastResult{
 		ProductID:        product.ID,
 		ProductName:      product.Name,
@@ -650,6 +477,6 @@ func (s *PredictionService) GenerateForecast(ctx context.Context, shopID uint, r
 		DaysRemaining:    daysRemaining,
 		RecommendedOrder: recommendedOrder,
 		OrderDate:        orderDate,
-		Confidence:       confidence,
+		Confidence:       model.Confidence,
 	}, nil
 }
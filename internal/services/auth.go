@@ -7,6 +7,8 @@ import (
 	"github.com/C9b3rD3vi1/DukaPOS/internal/config"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/otp"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -16,6 +18,8 @@ var (
 	ErrShopExists         = errors.New("shop already exists with this phone/email")
 	ErrTokenExpired       = errors.New("token has expired")
 	ErrAccountLocked      = errors.New("account is temporarily locked due to too many failed login attempts")
+	ErrOTPUnavailable     = errors.New("one-time codes are not configured")
+	ErrInvalidOTPPurpose  = errors.New("purpose must be login or password_reset")
 )
 
 const (
@@ -28,6 +32,7 @@ type AuthService struct {
 	shopRepo    *repository.ShopRepository
 	accountRepo *repository.AccountRepository
 	cfg         *config.Config
+	otp         *otp.OTPService
 }
 
 // NewAuthService creates a new auth service
@@ -43,8 +48,15 @@ func (s *AuthService) SetAccountRepo(accountRepo *repository.AccountRepository)
 	s.accountRepo = accountRepo
 }
 
+// SetOTPService enables login and password reset by one-time code
+func (s *AuthService) SetOTPService(otpSvc *otp.OTPService) {
+	s.otp = otpSvc
+}
+
 // Register creates a new shop account
 func (s *AuthService) Register(shop *models.Shop, password string) error {
+	shop.Phone = utils.NormalizePhone(shop.Phone)
+
 	// Check if phone already exists
 	existing, err := s.shopRepo.GetByPhone(shop.Phone)
 	if err == nil && existing != nil {
@@ -249,6 +261,79 @@ func (s *AuthService) ResetPassword(shopID uint, newPassword string) error {
 	return s.shopRepo.Update(shop)
 }
 
+// otpDestination finds the shop for a phone number or email, and sends its
+// codes back the same way: by SMS to the phone or to the email address
+func (s *AuthService) otpDestination(identifier string) (*models.Shop, string, error) {
+	if shop, err := s.shopRepo.GetByPhone(identifier); err == nil {
+		return shop, shop.Phone, nil
+	}
+	if shop, err := s.shopRepo.GetByEmail(identifier); err == nil {
+		return shop, shop.Email, nil
+	}
+	return nil, "", ErrInvalidCredentials
+}
+
+// FindShop returns the shop a phone number or email logs in to, or nil
+func (s *AuthService) FindShop(identifier string) *models.Shop {
+	shop, _, _ := s.otpDestination(identifier)
+	return shop
+}
+
+// SendOTP sends a login or password reset code to a shop's phone or email.
+// It returns ErrInvalidCredentials when no shop matches.
+func (s *AuthService) SendOTP(identifier, purpose string) error {
+	if s.otp == nil {
+		return ErrOTPUnavailable
+	}
+	if purpose != otp.PurposeLogin && purpose != otp.PurposePasswordReset {
+		return ErrInvalidOTPPurpose
+	}
+
+	_, destination, err := s.otpDestination(identifier)
+	if err != nil {
+		return err
+	}
+	_, err = s.otp.Send(destination, purpose)
+	return err
+}
+
+// LoginWithOTP logs a shop in with a code sent by SendOTP
+func (s *AuthService) LoginWithOTP(identifier, code string) (*models.Shop, string, error) {
+	if s.otp == nil {
+		return nil, "", ErrOTPUnavailable
+	}
+
+	shop, destination, err := s.otpDestination(identifier)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.otp.Check(destination, otp.PurposeLogin, code); err != nil {
+		return nil, "", err
+	}
+
+	token, err := s.generateToken(shop)
+	if err != nil {
+		return nil, "", err
+	}
+	return shop, token, nil
+}
+
+// ResetPasswordWithOTP sets a new password with a code sent by SendOTP
+func (s *AuthService) ResetPasswordWithOTP(identifier, code, newPassword string) error {
+	if s.otp == nil {
+		return ErrOTPUnavailable
+	}
+
+	shop, destination, err := s.otpDestination(identifier)
+	if err != nil {
+		return err
+	}
+	if err := s.otp.Check(destination, otp.PurposePasswordReset, code); err != nil {
+		return err
+	}
+	return s.ResetPassword(shop.ID, newPassword)
+}
+
 func (s *AuthService) generateToken(shop *models.Shop) (string, error) {
 	claims := jwt.MapClaims{
 		"shop_id": shop.ID,
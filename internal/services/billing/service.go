@@ -0,0 +1,163 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/export"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/storage"
+	"gorm.io/gorm"
+)
+
+// Currency plans are billed in
+const Currency = "KES"
+
+var ErrStorageNotConfigured = errors.New("invoice storage is not configured")
+
+// Service applies paid plans to accounts and issues their invoices
+type Service struct {
+	db          *gorm.DB
+	invoiceRepo *repository.InvoiceRepository
+	store       storage.Store
+	exporter    export.InvoiceExporter
+}
+
+func NewService(db *gorm.DB, invoiceRepo *repository.InvoiceRepository, store storage.Store) *Service {
+	return &Service{
+		db:          db,
+		invoiceRepo: invoiceRepo,
+		store:       store,
+	}
+}
+
+// CompletePlanPayment upgrades the paying shop's account to the plan paid
+// for and issues an invoice for the next month. It is called once the STK
+// callback for a plan payment succeeds; repeat calls for the same payment
+// do nothing.
+func (s *Service) CompletePlanPayment(payment *models.MpesaPayment) error {
+	if payment.Plan == "" {
+		return nil
+	}
+	if _, err := s.invoiceRepo.GetByPaymentID(payment.ID); err == nil {
+		return nil
+	}
+
+	var shop models.Shop
+	if err := s.db.First(&shop, payment.ShopID).Error; err != nil {
+		return fmt.Errorf("shop not found: %w", err)
+	}
+	var account models.Account
+	if err := s.db.First(&account, shop.AccountID).Error; err != nil {
+		return fmt.Errorf("account not found: %w", err)
+	}
+
+	paidAt := time.Now()
+	if payment.CompletedAt != nil {
+		paidAt = *payment.CompletedAt
+	}
+
+	// Renewing the same plan extends the current period; anything else
+	// starts a new one
+	start := paidAt
+	if latest, err := s.invoiceRepo.GetLatestByAccount(account.ID); err == nil &&
+		latest.Plan == payment.Plan && latest.PeriodEnd.After(start) {
+		start = latest.PeriodEnd
+	}
+
+	paymentID := payment.ID
+	invoice := &models.Invoice{
+		AccountID:    account.ID,
+		ShopID:       shop.ID,
+		PaymentID:    &paymentID,
+		Amount:       payment.Amount,
+		Currency:     Currency,
+		Plan:         payment.Plan,
+		PeriodStart:  start,
+		PeriodEnd:    start.AddDate(0, 1, 0),
+		PaidAt:       &paidAt,
+		MpesaReceipt: payment.MpesaReceipt,
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&account).Update("plan", payment.Plan).Error; err != nil {
+			return err
+		}
+		// Plan limits are checked per shop, so every shop on the account moves
+		if err := tx.Model(&models.Shop{}).Where("account_id = ?", account.ID).Update("plan", payment.Plan).Error; err != nil {
+			return err
+		}
+
+		invoices := repository.NewInvoiceRepository(tx)
+		if err := invoices.Create(invoice); err != nil {
+			return err
+		}
+		invoice.Number = models.InvoiceNumber(invoice.ID, invoice.CreatedAt)
+		return invoices.Update(invoice)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("💳 Account %d upgraded to %s, invoice %s", account.ID, payment.Plan, invoice.Number)
+
+	// The invoice stands without its PDF; InvoicePDF renders it on download
+	if _, err := s.savePDF(invoice, &account, &shop); err != nil {
+		log.Printf("⚠️ Failed to store PDF for invoice %s: %v", invoice.Number, err)
+	}
+	return nil
+}
+
+// InvoicePDF returns an invoice's PDF, rendering and storing it again if
+// it is missing
+func (s *Service) InvoicePDF(invoice *models.Invoice) ([]byte, error) {
+	if s.store != nil && invoice.PDFURL != "" {
+		if data, err := s.store.Open(invoiceKey(invoice)); err == nil {
+			return data, nil
+		}
+	}
+
+	var shop models.Shop
+	if err := s.db.First(&shop, invoice.ShopID).Error; err != nil {
+		return nil, fmt.Errorf("shop not found: %w", err)
+	}
+	var account models.Account
+	if err := s.db.First(&account, invoice.AccountID).Error; err != nil {
+		return nil, fmt.Errorf("account not found: %w", err)
+	}
+
+	data, err := s.savePDF(invoice, &account, &shop)
+	if errors.Is(err, ErrStorageNotConfigured) {
+		return data, nil
+	}
+	return data, err
+}
+
+// savePDF renders an invoice and records where the stored copy lives
+func (s *Service) savePDF(invoice *models.Invoice, account *models.Account, shop *models.Shop) ([]byte, error) {
+	data, err := s.exporter.ExportPDF(export.InvoiceData{
+		Invoice: *invoice,
+		Account: *account,
+		Shop:    *shop,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if s.store == nil {
+		return data, ErrStorageNotConfigured
+	}
+
+	url, err := s.store.Save(invoiceKey(invoice), data)
+	if err != nil {
+		return data, err
+	}
+	invoice.PDFURL = url
+	return data, s.invoiceRepo.Update(invoice)
+}
+
+func invoiceKey(invoice *models.Invoice) string {
+	return fmt.Sprintf("invoices/%d/%s.pdf", invoice.AccountID, invoice.Number)
+}
@@ -167,3 +167,208 @@ func (s *CacheService) IsHealthy() bool {
 
 	return s.client.Ping(ctx).Err() == nil
 }
+
+func (s *CacheService) AcquireLock(key, value string, ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return s.client.SetNX(ctx, key, value, ttl).Result()
+}
+
+func (s *CacheService) GetLock(key string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	value, err := s.client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return value, err
+}
+
+func (s *CacheService) UpdateLock(key, value string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return s.client.SetArgs(ctx, key, value, redis.SetArgs{Mode: "XX", KeepTTL: true}).Err()
+}
+
+func (s *CacheService) ReleaseLock(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return s.client.Del(ctx, key).Err()
+}
+
+func (s *CacheService) GetUSSDSession(sessionID string) ([]byte, error) {
+	key := fmt.Sprintf("ussd:session:%s", sessionID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	data, err := s.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s *CacheService) SetUSSDSession(sessionID string, data []byte, ttl time.Duration) error {
+	key := fmt.Sprintf("ussd:session:%s", sessionID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return s.client.Set(ctx, key, data, ttl).Err()
+}
+
+func (s *CacheService) DeleteUSSDSession(sessionID string) error {
+	key := fmt.Sprintf("ussd:session:%s", sessionID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return s.client.Del(ctx, key).Err()
+}
+
+func (s *CacheService) GetWhatsAppMenu(phone string) ([]byte, error) {
+	key := fmt.Sprintf("whatsapp:menu:%s", phone)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	data, err := s.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s *CacheService) SetWhatsAppMenu(phone string, data []byte, ttl time.Duration) error {
+	key := fmt.Sprintf("whatsapp:menu:%s", phone)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return s.client.Set(ctx, key, data, ttl).Err()
+}
+
+func (s *CacheService) GetCrossSells(shopID, productID uint) ([]byte, error) {
+	key := fmt.Sprintf("crosssell:%d:%d", shopID, productID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	data, err := s.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s *CacheService) SetCrossSells(shopID, productID uint, data []byte, ttl time.Duration) error {
+	key := fmt.Sprintf("crosssell:%d:%d", shopID, productID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return s.client.Set(ctx, key, data, ttl).Err()
+}
+
+func (s *CacheService) GetWhatsAppConfirmation(phone string) (string, error) {
+	key := fmt.Sprintf("whatsapp:confirm:%s", phone)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	action, err := s.client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return action, err
+}
+
+func (s *CacheService) SetWhatsAppConfirmation(phone, action string, ttl time.Duration) error {
+	key := fmt.Sprintf("whatsapp:confirm:%s", phone)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return s.client.Set(ctx, key, action, ttl).Err()
+}
+
+func (s *CacheService) DeleteWhatsAppConfirmation(phone string) error {
+	key := fmt.Sprintf("whatsapp:confirm:%s", phone)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return s.client.Del(ctx, key).Err()
+}
+
+// PushJob appends a background job to the Redis list jobs:queue
+func (s *CacheService) PushJob(data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return s.client.RPush(ctx, "jobs:queue", data).Err()
+}
+
+// PopJob blocks up to timeout for the next job on jobs:queue
+func (s *CacheService) PopJob(timeout time.Duration) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout+3*time.Second)
+	defer cancel()
+
+	values, err := s.client.BLPop(ctx, timeout, "jobs:queue").Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(values[1]), nil
+}
+
+func (s *CacheService) SetJob(id string, data []byte, ttl time.Duration) error {
+	key := fmt.Sprintf("jobs:%s", id)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return s.client.Set(ctx, key, data, ttl).Err()
+}
+
+func (s *CacheService) GetJob(id string) ([]byte, error) {
+	key := fmt.Sprintf("jobs:%s", id)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	data, err := s.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s *CacheService) SetJobResult(id string, data []byte, ttl time.Duration) error {
+	key := fmt.Sprintf("jobs:%s:result", id)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return s.client.Set(ctx, key, data, ttl).Err()
+}
+
+func (s *CacheService) GetJobResult(id string) ([]byte, error) {
+	key := fmt.Sprintf("jobs:%s:result", id)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	data, err := s.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return data, err
+}
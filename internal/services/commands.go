@@ -2,18 +2,29 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"math"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/C9b3rD3vi1/DukaPOS/internal/i18n"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/services/ai"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/export"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/media"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/printer"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/services/qr"
+	supplierservice "github.com/C9b3rD3vi1/DukaPOS/internal/services/supplier"
 	webhooksvc "github.com/C9b3rD3vi1/DukaPOS/internal/services/webhook"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/websocket"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/zreport"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
 	"gorm.io/gorm"
 )
 
@@ -84,9 +95,62 @@ type CommandHandler struct {
 	supplierRepo  *repository.SupplierRepository
 	orderRepo     *repository.OrderRepository
 	customerRepo  *repository.CustomerRepository
+	categoryRepo  *repository.CategoryRepository
+	bundleRepo    *repository.BundleRepository
+	priceRepo     *repository.PriceHistoryRepository
+	crossSells    *CrossSellService
+	menus         *MenuSessionService
+	confirmations *ConfirmationService
 	mpesaSvc      *mpesa.Service
 	qrSvc         *qr.QRPaymentService
 	predictionSvc *ai.PredictionService
+	featureFlags  FeatureFlags
+
+	// sendToSupplier delivers confirmed orders; nil leaves suppliers unnotified
+	sendToSupplier func(phone, message string) error
+
+	// queueSTKPush hands STK pushes Daraja rate limited to the outbox, and
+	// notifyShop tells the shop how they went; without them the shop is
+	// asked to try again
+	queueSTKPush func(shopID uint, phone string, request []byte) error
+	notifyShop   func(phone, message string) error
+
+	// mediaHost and sendMedia deliver QR codes and receipts as WhatsApp
+	// attachments; without them QR codes are sent as text
+	mediaHost     *media.Host
+	sendMedia     func(phone, caption, mediaURL string) error
+	receiptLinker *qr.ReceiptLinker
+	zreports      *zreport.Service
+	expenseRepo   *repository.ExpenseRepository
+}
+
+// searchCommandLimit is how many matches `find` lists
+const searchCommandLimit = 20
+
+// zReportChatWidth keeps Z-reports sent on WhatsApp narrow enough for a
+// phone screen
+const zReportChatWidth = 32
+
+// FeatureFlags reports whether a feature an admin can switch off is on
+type FeatureFlags interface {
+	Enabled(name string) bool
+}
+
+// commandFeatureFlags maps commands to the feature flag that switches them
+// off; commands not listed are always available
+var commandFeatureFlags = map[string]string{
+	"mpesa":     models.FeatureFlagMpesa,
+	"qr":        models.FeatureFlagMpesa,
+	"staff":     models.FeatureFlagStaffAccounts,
+	"supplier":  models.FeatureFlagStaffAccounts,
+	"suppliers": models.FeatureFlagStaffAccounts,
+	"sup":       models.FeatureFlagStaffAccounts,
+	"order":     models.FeatureFlagStaffAccounts,
+	"orders":    models.FeatureFlagStaffAccounts,
+	"shop":      models.FeatureFlagMultipleShops,
+	"predict":   models.FeatureFlagAnalytics,
+	"loyalty":   models.FeatureFlagAnalytics,
+	"api":       models.FeatureFlagAnalytics,
 }
 
 // NewCommandHandler creates a new command handler
@@ -105,9 +169,23 @@ func NewCommandHandler(
 		saleRepo:    saleRepo,
 		summaryRepo: summaryRepo,
 		auditRepo:   auditRepo,
+
+		crossSells:    NewCrossSellService(saleRepo, productRepo),
+		confirmations: NewConfirmationService(),
 	}
 }
 
+// SetCrossSells sets the service suggesting products bought together,
+// shared with the API
+func (h *CommandHandler) SetCrossSells(crossSells *CrossSellService) {
+	h.crossSells = crossSells
+}
+
+// SetFeatureFlags sets the flags that switch feature commands off
+func (h *CommandHandler) SetFeatureFlags(flags FeatureFlags) {
+	h.featureFlags = flags
+}
+
 // SetAccountRepo sets the account repository for multi-shop support
 func (h *CommandHandler) SetAccountRepo(accountRepo *repository.AccountRepository) {
 	h.accountRepo = accountRepo
@@ -124,11 +202,73 @@ func (h *CommandHandler) SetSupplierRepo(supplierRepo *repository.SupplierReposi
 	h.orderRepo = orderRepo
 }
 
+// SetSupplierSender sets how confirmed orders are sent to suppliers
+func (h *CommandHandler) SetSupplierSender(send func(phone, message string) error) {
+	h.sendToSupplier = send
+}
+
+// SetSTKPushQueue sets where STK pushes Daraja rate limited are queued to
+// be sent again, and how the shop is told when they are
+func (h *CommandHandler) SetSTKPushQueue(queue func(shopID uint, phone string, request []byte) error, notify func(phone, message string) error) {
+	h.queueSTKPush = queue
+	h.notifyShop = notify
+}
+
+// SetMediaSender sets where generated images and PDFs are hosted and how
+// they are sent as WhatsApp attachments
+func (h *CommandHandler) SetMediaSender(host *media.Host, send func(phone, caption, mediaURL string) error) {
+	h.mediaHost = host
+	h.sendMedia = send
+}
+
+// SetReceiptLinker sets the signer for the digital receipt links printed
+// on receipts sent with `receipt`
+func (h *CommandHandler) SetReceiptLinker(linker *qr.ReceiptLinker) {
+	h.receiptLinker = linker
+}
+
+// SetZReportService sets the service behind `zreport`
+func (h *CommandHandler) SetZReportService(zreports *zreport.Service) {
+	h.zreports = zreports
+}
+
+// SetExpenseRepo sets the repository behind `expense`, and adds expenses
+// and net profit to `profit`
+func (h *CommandHandler) SetExpenseRepo(expenseRepo *repository.ExpenseRepository) {
+	h.expenseRepo = expenseRepo
+}
+
 // SetCustomerRepo sets the customer repository for loyalty
 func (h *CommandHandler) SetCustomerRepo(customerRepo *repository.CustomerRepository) {
 	h.customerRepo = customerRepo
 }
 
+// SetCategoryRepo sets the category repository for nested categories
+func (h *CommandHandler) SetCategoryRepo(categoryRepo *repository.CategoryRepository) {
+	h.categoryRepo = categoryRepo
+}
+
+// SetBundleRepo sets the bundle repository so bundles can be made and sold
+func (h *CommandHandler) SetBundleRepo(bundleRepo *repository.BundleRepository) {
+	h.bundleRepo = bundleRepo
+}
+
+// SetPriceHistoryRepo sets the price history repository for `price history`
+func (h *CommandHandler) SetPriceHistoryRepo(priceRepo *repository.PriceHistoryRepository) {
+	h.priceRepo = priceRepo
+}
+
+// SetMenuSessions sets the service that resolves numbered menu replies
+func (h *CommandHandler) SetMenuSessions(menus *MenuSessionService) {
+	h.menus = menus
+}
+
+// SetConfirmations sets the service holding destructive commands until
+// they are confirmed, replacing the in-memory default
+func (h *CommandHandler) SetConfirmations(confirmations *ConfirmationService) {
+	h.confirmations = confirmations
+}
+
 // SetMpesaService sets the M-Pesa service for WhatsApp payments
 func (h *CommandHandler) SetMpesaService(mpesaSvc *mpesa.Service) {
 	h.mpesaSvc = mpesaSvc
@@ -146,6 +286,8 @@ func (h *CommandHandler) SetPredictionService(predictionSvc *ai.PredictionServic
 
 // Handle processes a command and returns a response
 func (h *CommandHandler) Handle(phone string, command *ParsedCommand) (string, error) {
+	// Menus and confirmations are kept under the shop's stored number
+	phone = utils.NormalizePhone(phone)
 	shop, err := h.shopRepo.GetByPhone(phone)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -168,105 +310,148 @@ func (h *CommandHandler) Handle(phone string, command *ParsedCommand) (string, e
 				EntityID:   shop.ID,
 				Details:    "Shop created via WhatsApp",
 			})
-			return h.handleWelcome(shop), nil
+			return h.handleWelcome(shop, i18n.Default), nil
 		}
 		return "", err
 	}
 
+	lang := i18n.Of(shop.Language)
+
 	if !shop.IsActive {
-		return "❌ Your account is deactivated. Please contact support.", nil
+		return i18n.T(lang, i18n.MsgDeactivated), nil
+	}
+
+	// "1 bread 2" after a numbered menu means "sell bread 2"
+	if shop.FeaturePhone && h.menus != nil {
+		if resolved := h.menus.ResolveOption(phone, command.Raw); resolved != command.Raw {
+			command = NewCommandParser(nil, nil).Parse(resolved)
+		}
+	}
+
+	if flag, ok := commandFeatureFlags[command.Command]; ok && h.featureFlags != nil && !h.featureFlags.Enabled(flag) {
+		return i18n.T(lang, i18n.MsgFeatureDisabled), nil
 	}
 
 	switch command.Command {
+	case "set":
+		return h.handleSet(shop, command.Args, lang)
+	case "lang", "language", "lugha":
+		return h.handleSet(shop, append([]string{"language"}, command.Args...), lang)
+	case "hours", "saa":
+		return h.handleHours(shop, command.Args, lang)
 	case "help":
-		return h.handleHelp(shop), nil
+		return h.handleHelp(shop, lang), nil
 	case "add":
-		return h.handleAdd(shop, command.Args)
+		return h.handleAdd(shop, command.Args, lang)
 	case "sell":
-		return h.handleSell(shop, command.Args)
+		return h.handleSell(shop, command.Args, lang)
 	case "stock":
-		return h.handleStock(shop, command.Args)
+		return h.handleStock(shop, command.Args, lang)
 	case "price":
-		return h.handlePrice(shop, command.Args)
+		return h.handlePrice(shop, command.Args, lang)
 	case "remove":
-		return h.handleRemove(shop, command.Args)
+		return h.handleRemove(phone, shop, command.Args, lang, false)
 	case "report", "daily":
-		return h.handleReport(shop)
+		return h.handleReport(shop, lang)
 	case "weekly":
-		return h.handleWeekly(shop)
+		return h.handleWeekly(shop, lang)
 	case "monthly":
-		return h.handleMonthly(shop)
+		return h.handleMonthly(shop, lang)
 	case "profit":
-		return h.handleProfit(shop)
+		return h.handleProfit(shop, lang)
 	case "low":
-		return h.handleLowStock(shop)
+		return h.handleLowStock(shop, lang)
 	case "delete":
-		return h.handleDelete(shop, command.Args)
+		return h.handleDelete(phone, shop, command.Args, lang, false)
+	case "yes", "ndio":
+		return h.handleConfirm(phone, shop, command.Args, lang)
 	case "category", "cat":
-		return h.handleCategory(shop, command.Args)
+		return h.handleCategory(shop, command.Args, lang)
 	case "all":
-		return h.handleAll(shop)
+		return h.handleAll(shop, lang)
 	case "threshold", "limit", "min":
-		return h.handleThreshold(shop, command.Args)
+		return h.handleThreshold(shop, command.Args, lang)
+	case "bundle", "bundles", "combo":
+		return h.handleBundle(shop, command.Args, lang)
 	case "barcode", "scan":
-		return h.handleBarcode(shop, command.Args)
+		return h.handleBarcode(shop, command.Args, lang)
+	case "alias", "aliases":
+		return h.handleAlias(shop, command.Args, lang)
+	case "fav", "favs", "favorite", "favorites":
+		return h.handleFav(shop, command.Args, lang)
+	case "unit", "units":
+		return h.handleUnit(shop, command.Args, lang)
 	case "top":
-		return h.handleTop(shop, command.Args)
+		return h.handleTop(shop, command.Args, lang)
 	case "search", "find":
-		return h.handleSearch(shop, command.Args)
+		return h.handleSearch(shop, command.Args, lang)
 	case "cost":
-		return h.handleCost(shop, command.Args)
+		return h.handleCost(shop, command.Args, lang)
 	case "backup":
-		return h.handleBackup(shop)
+		return h.handleBackup(shop, lang)
 	// === Phase 2: Pro Features ===
 	case "mpesa":
-		return h.handleMpesa(shop, command.Args)
+		return h.handleMpesa(shop, command.Args, lang)
 	case "staff":
-		return h.handleStaff(shop, command.Args)
+		return h.handleStaff(shop, command.Args, lang)
 	case "shop":
-		return h.handleShop(shop, command.Args)
+		return h.handleShop(shop, command.Args, lang)
 	case "upgrade":
-		return h.handleUpgrade(shop)
+		return h.handleUpgrade(shop, lang)
 	case "plan":
-		return h.handlePlan(shop)
+		return h.handlePlan(shop, lang)
 	case "supplier", "suppliers", "sup":
-		return h.handleSupplier(shop, command.Args)
+		return h.handleSupplier(phone, shop, command.Args, lang)
 	case "order", "orders":
-		return h.handleOrder(shop, command.Args)
+		return h.handleOrder(shop, command.Args, lang)
 	// === Phase 3: Enterprise Features ===
 	case "predict":
-		return h.handlePredict(shop, command.Args)
+		return h.handlePredict(shop, command.Args, lang)
 	case "qr":
-		return h.handleQR(shop, command.Args)
+		return h.handleQR(phone, shop, command.Args, lang)
+	case "receipt", "risiti":
+		return h.handleReceipt(phone, shop, command.Args, lang)
+	case "zreport", "z":
+		return h.handleZReport(shop, command.Args, lang)
+	case "expense", "expenses", "matumizi":
+		return h.handleExpense(shop, command.Args, lang)
+	case "catalog", "catalogue", "katalogi":
+		return h.handleCatalog(phone, shop, command.Args, lang)
 	case "loyalty":
-		return h.handleLoyalty(shop, command.Args)
+		return h.handleLoyalty(shop, command.Args, lang)
 	case "api":
-		return h.handleAPI(shop, command.Args)
+		return h.handleAPI(shop, command.Args, lang)
 	default:
-		return h.handleUnknown(command.Command), nil
+		if shop.FeaturePhone && h.menus != nil {
+			return h.handleHelp(shop, lang), nil
+		}
+		return h.handleUnknown(command.Command, lang), nil
 	}
 }
 
-// handleWelcome handles new shop welcome
-func (h *CommandHandler) handleWelcome(shop *models.Shop) string {
-	return fmt.Sprintf(`🎉 Welcome to DukaPOS!
-
-Your shop has been created!
-
-📱 Your Number: %s
-
-Quick Start:
-• add bread 50 30 - Add 30 bread @ KSh 50
-• sell bread 2 - Sold 2 bread
-• stock - View all inventory
-• report - Today's summary
-• help - See all commands
+// numberedMenu lists the options offered to feature phones, in menu order
+var numberedMenu = []struct {
+	command string
+	label   i18n.Message
+}{
+	{"sell", i18n.MsgMenuSell},
+	{"add", i18n.MsgMenuAdd},
+	{"stock", i18n.MsgMenuStock},
+	{"price", i18n.MsgMenuPrice},
+	{"report", i18n.MsgMenuReport},
+	{"profit", i18n.MsgMenuProfit},
+	{"low", i18n.MsgMenuLowStock},
+	{"weekly", i18n.MsgMenuWeekly},
+	{"remove", i18n.MsgMenuRemove},
+}
 
-Welcome to digital dukas! 🛒`, shop.Phone)
+// handleWelcome handles new shop welcome
+func (h *CommandHandler) handleWelcome(shop *models.Shop, lang i18n.Language) string {
+	return i18n.T(lang, i18n.MsgWelcome, shop.Phone)
 }
 
 // handleHelp handles help command
-func (h *CommandHandler) handleHelp(shop *models.Shop) string {
+func (h *CommandHandler) handleHelp(shop *models.Shop, lang i18n.Language) string {
 	planBadge := "📦 FREE"
 	if shop.Plan == models.PlanPro {
 		planBadge = "🚀 PRO"
@@ -274,120 +459,352 @@ func (h *CommandHandler) handleHelp(shop *models.Shop) string {
 		planBadge = "🏢 BUSINESS"
 	}
 
-	var proCommands string
+	if shop.FeaturePhone && h.menus != nil {
+		return h.handleHelpMenu(shop, planBadge, lang)
+	}
+
+	proCommands := i18n.T(lang, i18n.MsgHelpUpgrade)
 	if shop.Plan != models.PlanFree {
-		proCommands = `
-💎 PRO COMMANDS:
-mpesa pay [amount] - Request M-Pesa payment
-staff - Manage staff members
-staff add [name] [phone] [role] - Add staff
-supplier - Manage suppliers
-shop list - View all shops
+		proCommands = i18n.T(lang, i18n.MsgHelpPro)
+	}
 
-📈 ADVANCED:
-weekly - This week's summary
-monthly - This month's summary
-category - View/set categories
-threshold [product] [num] - Set low stock alert
-barcode [code] - Look up by barcode`
-	} else {
-		proCommands = `
-💎 PRO FEATURES:
-Upgrade to unlock:
-• M-Pesa payments
-• Staff accounts
-• Supplier management
-• Multiple shops
-• Advanced reports
-• Barcode support
+	var languages strings.Builder
+	for _, l := range i18n.Languages {
+		languages.WriteString(fmt.Sprintf("%s - %s\n", l, l.Name()))
+	}
+
+	return i18n.T(lang, i18n.MsgHelp, planBadge, proCommands, i18n.T(lang, i18n.MsgHelpLanguages, languages.String()))
+}
+
+// handleHelpMenu sends the help as a numbered menu and remembers it so the
+// next numeric reply resolves to its command
+func (h *CommandHandler) handleHelpMenu(shop *models.Shop, planBadge string, lang i18n.Language) string {
+	commands := make([]string, len(numberedMenu))
+	var items strings.Builder
+	for i, option := range numberedMenu {
+		commands[i] = option.command
+		items.WriteString(fmt.Sprintf("%d. %s\n", i+1, i18n.T(lang, option.label)))
+	}
+
+	if err := h.menus.SetMenu(shop.Phone, commands); err != nil {
+		log.Printf("⚠️ Failed to save WhatsApp menu for %s: %v", shop.Phone, err)
+	}
+	return i18n.T(lang, i18n.MsgHelpMenu, planBadge, items.String())
+}
+
+// handleSet handles shop settings: the reply language, numbered menus and cash rounding
+func (h *CommandHandler) handleSet(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
+	if len(args) > 0 && (args[0] == "phone" || args[0] == "simu") {
+		return h.handleSetPhone(shop, args[1:], lang)
+	}
+	if len(args) > 0 && args[0] == "rounding" {
+		return h.handleSetRounding(shop, args[1:], lang)
+	}
+	if len(args) > 0 && args[0] == "negative" {
+		return h.handleSetNegativeStock(shop, args[1:], lang)
+	}
+
+	var codes []string
+	for _, l := range i18n.Languages {
+		codes = append(codes, string(l))
+	}
+	usage := i18n.T(lang, i18n.MsgLanguageUsage, strings.Join(codes, ", "))
+
+	if len(args) < 2 || (args[0] != "language" && args[0] != "lugha") {
+		return usage, nil
+	}
+
+	newLang, ok := i18n.Parse(args[1])
+	if !ok {
+		return usage, nil
+	}
+
+	shop.Language = string(newLang)
+	if err := h.shopRepo.Update(shop); err != nil {
+		return "", err
+	}
+
+	h.auditRepo.Create(&models.AuditLog{
+		ShopID:     shop.ID,
+		UserType:   "shop",
+		UserID:     shop.ID,
+		Action:     "update",
+		EntityType: "shop",
+		EntityID:   shop.ID,
+		Details:    fmt.Sprintf("Language: %s -> %s", lang, newLang),
+	})
+
+	return i18n.T(newLang, i18n.MsgLanguageSet), nil
+}
+
+// handleSetPhone switches numbered menus on for basic phones or off for smartphones
+func (h *CommandHandler) handleSetPhone(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
+	if len(args) < 1 || (args[0] != "basic" && args[0] != "smart") {
+		return i18n.T(lang, i18n.MsgPhoneUsage), nil
+	}
+
+	shop.FeaturePhone = args[0] == "basic"
+	if err := h.shopRepo.Update(shop); err != nil {
+		return "", err
+	}
+
+	h.auditRepo.Create(&models.AuditLog{
+		ShopID:     shop.ID,
+		UserType:   "shop",
+		UserID:     shop.ID,
+		Action:     "update",
+		EntityType: "shop",
+		EntityID:   shop.ID,
+		Details:    fmt.Sprintf("Phone profile: %s", args[0]),
+	})
+
+	if shop.FeaturePhone {
+		return i18n.T(lang, i18n.MsgPhoneBasic), nil
+	}
+	return i18n.T(lang, i18n.MsgPhoneSmart), nil
+}
+
+// handleSetRounding sets how cash sale totals are rounded
+func (h *CommandHandler) handleSetRounding(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
+	if len(args) < 1 {
+		return i18n.T(lang, i18n.MsgRoundingUsage), nil
+	}
+	policy, ok := models.ParseRoundingPolicy(args[0])
+	if !ok {
+		return i18n.T(lang, i18n.MsgRoundingUsage), nil
+	}
 
-Reply: upgrade`
+	old := shop.RoundingFor(models.PaymentCash)
+	shop.Rounding = policy
+	if err := h.shopRepo.Update(shop); err != nil {
+		return "", err
 	}
 
-	helpText := fmt.Sprintf(`📦 FREE
+	h.auditRepo.Create(&models.AuditLog{
+		ShopID:     shop.ID,
+		UserType:   "shop",
+		UserID:     shop.ID,
+		Action:     "update",
+		EntityType: "shop",
+		EntityID:   shop.ID,
+		Details:    fmt.Sprintf("Rounding: %s -> %s", old, policy),
+	})
 
-📝 COMMANDS:
+	switch policy {
+	case models.RoundingNearest1:
+		return i18n.T(lang, i18n.MsgRoundingSet, 1), nil
+	case models.RoundingNearest5:
+		return i18n.T(lang, i18n.MsgRoundingSet, 5), nil
+	}
+	return i18n.T(lang, i18n.MsgRoundingNone), nil
+}
 
-🆕 STOCK:
-add [name] [price] [qty]
-  Example: add milk 60 20
+// handleSetNegativeStock sets whether sales the stock on record can't
+// cover are recorded, taking stock below zero, or refused
+func (h *CommandHandler) handleSetNegativeStock(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
+	if len(args) < 1 || (args[0] != "on" && args[0] != "off") {
+		return i18n.T(lang, i18n.MsgNegativeStockUsage), nil
+	}
 
-💰 SALES:
-sell [name] [qty]
-  Example: sell milk 2
+	shop.AllowNegativeStock = args[0] == "on"
+	if err := h.shopRepo.Update(shop); err != nil {
+		return "", err
+	}
 
-📊 REPORTS:
-stock - View all products
-stock [name] - View specific
-report - Today's summary
-profit - Today's profit
-low - Low stock items
-weekly - This week summary
-monthly - This month summary
-category - View categories
+	h.auditRepo.Create(&models.AuditLog{
+		ShopID:     shop.ID,
+		UserType:   "shop",
+		UserID:     shop.ID,
+		Action:     "update",
+		EntityType: "shop",
+		EntityID:   shop.ID,
+		Details:    fmt.Sprintf("Negative stock: %s", args[0]),
+	})
 
-💵 PRICING:
-price [name] - Check price
-price [name] [new] - Update price
+	if shop.AllowNegativeStock {
+		return i18n.T(lang, i18n.MsgNegativeStockOn), nil
+	}
+	return i18n.T(lang, i18n.MsgNegativeStockOff), nil
+}
 
-⚙️ SETTINGS:
-threshold [product] - View threshold
-threshold [product] [num] - Set alert
-barcode [code] - Look up product
+// handleHours shows or sets the hours outside which the bot replies that
+// the shop is closed: "hours mon-fri 08:00-18:00", "hours sun closed" or
+// "hours off"
+func (h *CommandHandler) handleHours(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
+	if len(args) == 0 {
+		if len(shop.BusinessHours) == 0 {
+			return i18n.T(lang, i18n.MsgHoursNone), nil
+		}
+		return i18n.T(lang, i18n.MsgHoursList, shop.BusinessHours.String()), nil
+	}
 
-➖ REMOVE STOCK:
-remove [name] [qty]
+	hours := models.BusinessHours{}
+	for day, dayHours := range shop.BusinessHours {
+		hours[day] = dayHours
+	}
 
-🗑️ DELETE:
-delete [name]
+	switch {
+	case len(args) == 1 && (args[0] == "off" || args[0] == "clear"):
+		hours = nil
+	case len(args) == 2:
+		days, ok := models.ParseDays(args[0])
+		if !ok {
+			return i18n.T(lang, i18n.MsgHoursUsage), nil
+		}
+		if args[1] == "closed" || args[1] == "off" {
+			hours.Close(days)
+			break
+		}
+		dayHours, err := models.ParseOpenClose(args[1])
+		if err != nil {
+			return "❌ " + err.Error() + "\n\n" + i18n.T(lang, i18n.MsgHoursUsage), nil
+		}
+		hours.Set(days, dayHours)
+	default:
+		return i18n.T(lang, i18n.MsgHoursUsage), nil
+	}
 
-🏪 SHOP:
-shop - View shop info
-plan - View plan details
+	// Closing the last open day leaves no hours, which means always open
+	if len(hours) == 0 {
+		hours = nil
+	}
+	shop.BusinessHours = hours
+	if err := h.shopRepo.Update(shop); err != nil {
+		return "", err
+	}
 
-🔧 HELP:
-help - Show this message%s`, proCommands)
+	h.auditRepo.Create(&models.AuditLog{
+		ShopID:     shop.ID,
+		UserType:   "shop",
+		UserID:     shop.ID,
+		Action:     "update",
+		EntityType: "shop",
+		EntityID:   shop.ID,
+		Details:    "Business hours: " + strings.Join(args, " "),
+	})
+
+	if hours == nil {
+		return i18n.T(lang, i18n.MsgHoursOff), nil
+	}
+	return i18n.T(lang, i18n.MsgHoursList, hours.String()), nil
+}
+
+// ClosedReply returns the out-of-hours reply for a message from phone to a
+// shop's own WhatsApp number to, or false when to is not a shop's number,
+// the shop is open or has no hours, or phone is the owner's or a staff
+// member's, who can always use the bot. The hours are checked in the
+// shop's zone.
+func (h *CommandHandler) ClosedReply(to, phone string, command *ParsedCommand, now time.Time) (string, bool) {
+	shop, err := h.shopRepo.GetByWhatsAppNumber(to)
+	if err != nil || !shop.IsActive {
+		return "", false
+	}
+	now = now.In(shop.Location())
+	if shop.BusinessHours.IsOpen(now) || h.isShopPhone(shop, phone) {
+		return "", false
+	}
+
+	opens := ""
+	if next, ok := shop.BusinessHours.NextOpening(now); ok {
+		opens = next.Format("Mon 15:04")
+		if next.YearDay() == now.YearDay() && next.Year() == now.Year() {
+			opens = next.Format("15:04")
+		}
+	}
 
-	return planBadge + helpText
+	if shop.ClosedMessage != "" {
+		return strings.ReplaceAll(shop.ClosedMessage, "{open}", opens), true
+	}
+	return i18n.T(i18n.Of(shop.Language), i18n.MsgClosed, shop.Name, opens), true
+}
+
+// isShopPhone reports whether phone is the shop's own or an active staff
+// member's
+func (h *CommandHandler) isShopPhone(shop *models.Shop, phone string) bool {
+	phone = utils.NormalizePhone(phone)
+	if phone == utils.NormalizePhone(shop.Phone) {
+		return true
+	}
+	if h.staffRepo == nil {
+		return false
+	}
+	staff, err := h.staffRepo.GetByShopID(shop.ID)
+	if err != nil {
+		return false
+	}
+	for _, s := range staff {
+		if s.IsActive && utils.NormalizePhone(s.Phone) == phone {
+			return true
+		}
+	}
+	return false
 }
 
 // handleAdd handles add command
-func (h *CommandHandler) handleAdd(shop *models.Shop, args []string) (string, error) {
+func (h *CommandHandler) handleAdd(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
 	if len(args) < 3 {
-		return "❌ Usage: add [name] [price] [qty]\nExample: add bread 50 30", nil
+		return i18n.T(lang, i18n.MsgAddUsage), nil
 	}
 
 	// Validate product name
 	name := normalizeProductName(args[0])
 	if len(name) < 2 {
-		return "❌ Product name too short.\nUse: add [name] [price] [qty]", nil
+		return i18n.T(lang, i18n.MsgAddNameTooShort), nil
 	}
 	if len(name) > 50 {
-		return "❌ Product name too long (max 50 chars).\nUse: add [name] [price] [qty]", nil
+		return i18n.T(lang, i18n.MsgAddNameTooLong), nil
 	}
 
 	// Validate price
 	price, err := strconv.ParseFloat(args[1], 64)
 	if err != nil || price < 0 {
-		return "❌ Invalid price. Use: add [name] [price] [qty]\nExample: add bread 50", nil
+		return i18n.T(lang, i18n.MsgAddInvalidPrice), nil
 	}
 	if price > 999999 {
-		return "❌ Price too high (max KSh 999,999)", nil
+		return i18n.T(lang, i18n.MsgAddPriceTooHigh), nil
 	}
 
 	// Validate quantity
 	qty, err := strconv.Atoi(args[2])
 	if err != nil || qty <= 0 {
-		return "❌ Invalid quantity. Use: add [name] [price] [qty]\nExample: add bread 50 30", nil
+		return i18n.T(lang, i18n.MsgAddInvalidQty), nil
 	}
 	if qty > 999999 {
-		return "❌ Quantity too high (max 999,999)", nil
+		return i18n.T(lang, i18n.MsgAddQtyTooHigh), nil
+	}
+
+	// "add soda 50 2 crate" restocks in the product's bulk unit
+	unit := ""
+	if len(args) > 3 {
+		unit = args[3]
 	}
 
 	// Check for existing product
 	product, err := h.productRepo.GetByShopAndName(shop.ID, name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Restocking brings back a product hidden when it sold out
+		if hidden, hiddenErr := h.productRepo.GetAutoDeactivated(shop.ID, name); hiddenErr == nil {
+			product, err = hidden, nil
+			product.IsActive = true
+			product.AutoDeactivated = false
+		}
+	}
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if unit != "" {
+				return i18n.T(lang, i18n.MsgUnitUnknown, name, unit, strings.ToLower(name), unit, unit), nil
+			}
+
+			// Enforce the plan's product limit
+			count, err := h.productRepo.CountByShop(shop.ID)
+			if err != nil {
+				return "", err
+			}
+			if limits := models.LimitsFor(shop.Plan); !limits.CanAddProducts(count, 1) {
+				return i18n.T(lang, i18n.MsgAddLimitReached,
+					shop.Plan.Name(), limits.MaxProducts, models.NextPlan(shop.Plan).Name()), nil
+			}
+
 			// Create new product
 			product = &models.Product{
 				ShopID:            shop.ID,
@@ -409,83 +826,126 @@ func (h *CommandHandler) handleAdd(shop *models.Shop, args []string) (string, er
 				EntityID:   product.ID,
 				Details:    fmt.Sprintf("Added: %s, qty: %d, price: %.2f", name, qty, price),
 			})
-			return fmt.Sprintf("✅ Added NEW: %s\n💰 Price: KSh %.0f\n📦 Qty: %d\n\nTip: Set low stock alert with: threshold %s 5",
+			return i18n.T(lang, i18n.MsgAddCreated,
 				product.Name, product.SellingPrice, qty, strings.ToLower(name)), nil
 		}
 		return "", err
 	}
 
 	// Update existing product
+	qty, ok := product.ToBaseUnits(qty, unit)
+	if !ok {
+		return i18n.T(lang, i18n.MsgUnitUnknown, product.Name, unit, strings.ToLower(product.Name), unit, unit), nil
+	}
+	if qty > 999999 {
+		return i18n.T(lang, i18n.MsgAddQtyTooHigh), nil
+	}
+
 	oldStock := product.CurrentStock
 	oldPrice := product.SellingPrice
-	product.CurrentStock += qty
-	product.SellingPrice = price
-	if err := h.productRepo.Update(product); err != nil {
-		return "", err
-	}
 
-	h.auditRepo.Create(&models.AuditLog{
-		ShopID:     shop.ID,
-		UserType:   "shop",
-		UserID:     shop.ID,
-		Action:     "update",
-		EntityType: "product",
-		EntityID:   product.ID,
-		Details:    fmt.Sprintf("Stock add: %s, qty: %d, price: %.2f -> %.2f", name, qty, oldPrice, price),
+	// The restock, the new price and the audit entry are saved together
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		products := h.productRepo.WithTx(tx)
+		movement, _, err := products.MoveStock(product.ID, qty, models.StockMovementRestock, nil, "")
+		if err != nil {
+			return err
+		}
+		product.CurrentStock = movement.BalanceAfter
+		product.SellingPrice = price
+		if err := products.UpdateBy(product, models.PriceSourceWhatsApp, models.ChangedByShop(shop.ID)); err != nil {
+			return err
+		}
+
+		return h.auditRepo.WithTx(tx).Create(&models.AuditLog{
+			ShopID:     shop.ID,
+			UserType:   "shop",
+			UserID:     shop.ID,
+			Action:     "update",
+			EntityType: "product",
+			EntityID:   product.ID,
+			Details:    fmt.Sprintf("Stock add: %s, qty: %d, price: %.2f -> %.2f", name, qty, oldPrice, price),
+		})
 	})
+	if err != nil {
+		product.CurrentStock, product.SellingPrice = oldStock, oldPrice
+		return "", err
+	}
 
-	return fmt.Sprintf("✅ Updated: %s\n📦 Was: %d → Now: %d (+%d)\n💰 Price: KSh %.0f (was: %.0f)",
+	return i18n.T(lang, i18n.MsgAddUpdated,
 		product.Name, oldStock, product.CurrentStock, qty, product.SellingPrice, oldPrice), nil
 }
 
 // handleSell handles sell command
-func (h *CommandHandler) handleSell(shop *models.Shop, args []string) (string, error) {
+func (h *CommandHandler) handleSell(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
 	if len(args) < 2 {
-		return "❌ Usage: sell [name] [quantity]\nExample: sell bread 2", nil
+		return i18n.T(lang, i18n.MsgSellUsage), nil
 	}
 
 	// Validate quantity
 	name := normalizeProductName(args[0])
 	qty, err := strconv.Atoi(args[1])
 	if err != nil || qty <= 0 {
-		return "❌ Invalid quantity.\nUse: sell [name] [qty]\nExample: sell bread 2", nil
+		return i18n.T(lang, i18n.MsgSellInvalidQty), nil
 	}
 	if qty > 99999 {
-		return "❌ Quantity too high (max 99,999)", nil
+		return i18n.T(lang, i18n.MsgSellQtyTooHigh), nil
 	}
 
-	// Find product
-	product, err := h.productRepo.GetByShopAndName(shop.ID, name)
+	// Find product; "sell 1 3" sells three of favorite #1
+	product, err := h.favoriteProduct(shop.ID, args[0])
+	if product != nil {
+		name = product.Name
+	} else if err == nil {
+		product, err = h.productRepo.GetByShopAndName(shop.ID, name)
+	}
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if n, convErr := strconv.Atoi(args[0]); convErr == nil {
+				return i18n.T(lang, i18n.MsgFavNoNumber, n), nil
+			}
 			available, _ := h.productRepo.GetByShopID(shop.ID)
 			if len(available) == 0 {
-				return "❌ No products yet.\n\nAdd first: add [name] [price] [qty]\nExample: add milk 60 20", nil
+				return i18n.T(lang, i18n.MsgSellNoProducts), nil
 			}
 			// Find similar products
 			similar := findSimilarProducts(available, name)
-			msg := fmt.Sprintf("❌ Product '%s' not found.\n\nAvailable products:\n%s", name, getProductNames(available))
+			msg := i18n.T(lang, i18n.MsgSellNotFound, name, getProductNames(available))
 			if similar != "" {
-				msg += "\n\nDid you mean: " + similar + "?"
+				msg += i18n.T(lang, i18n.MsgSellDidYouMean, similar)
 			}
 			return msg, nil
 		}
 		return "", err
 	}
 
-	// Check stock
-	if product.CurrentStock < qty {
-		if product.CurrentStock == 0 {
-			return fmt.Sprintf("❌ %s is OUT OF STOCK!\n\nAdd more: add %s %.0f [qty]",
+	if product.IsBundle && h.bundleRepo != nil {
+		return h.sellBundle(shop, product, qty, lang)
+	}
+
+	// "sell soda 1 crate" sells a crate's worth of singles
+	if len(args) >= 3 {
+		if units, ok := product.ToBaseUnits(qty, args[2]); ok {
+			qty, args = units, append(args[:2:2], args[3:]...)
+			if qty > 99999 {
+				return i18n.T(lang, i18n.MsgSellQtyTooHigh), nil
+			}
+		}
+	}
+
+	// Check stock; shops that allow negative stock sell past it with a warning
+	if product.CurrentStock < qty && !shop.AllowNegativeStock {
+		if product.CurrentStock <= 0 {
+			return i18n.T(lang, i18n.MsgSellOutOfStock,
 				product.Name, strings.ToLower(product.Name), product.SellingPrice), nil
 		}
-		return fmt.Sprintf("❌ Not enough stock!\n📦 Available: %d %s\n\nSell less: sell %s %d",
+		return i18n.T(lang, i18n.MsgSellNotEnoughStock,
 			product.CurrentStock, product.Unit, strings.ToLower(product.Name), product.CurrentStock), nil
 	}
 
 	// Check if product is active
 	if !product.IsActive {
-		return fmt.Sprintf("❌ %s is currently unavailable.\nContact support for assistance.", product.Name), nil
+		return i18n.T(lang, i18n.MsgSellUnavailable, product.Name), nil
 	}
 
 	// Calculate totals
@@ -504,95 +964,378 @@ func (h *CommandHandler) handleSell(shop *models.Shop, args []string) (string, e
 		Profit:        profit,
 		PaymentMethod: models.PaymentCash,
 	}
+	sale.ApplyRounding(shop.RoundingFor(sale.PaymentMethod))
+	totalAmount, profit = sale.TotalAmount, sale.Profit
 
-	// Use database transaction for consistency
-	if h.db != nil {
-		err := h.db.Transaction(func(tx *gorm.DB) error {
-			if err := tx.Create(sale).Error; err != nil {
-				return err
-			}
-			if err := tx.Model(&models.Product{}).Where("id = ?", product.ID).
-				Update("current_stock", gorm.Expr("current_stock - ?", qty)).Error; err != nil {
-				return err
-			}
-			return nil
+	// The sale, its stock deduction, the audit entry and any loyalty
+	// points are saved together; unless the shop allows negative stock,
+	// stock sold in the meantime fails the sale rather than going negative
+	pointsAwarded := 0
+	var customer *models.Customer
+	var soldOut *models.Product
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		soldOut, err = h.saleRepo.WithTx(tx).RecordSale(sale)
+		if err != nil {
+			return err
+		}
+
+		err = h.auditRepo.WithTx(tx).Create(&models.AuditLog{
+			ShopID:     shop.ID,
+			UserType:   "shop",
+			UserID:     shop.ID,
+			Action:     "sale",
+			EntityType: "sale",
+			EntityID:   sale.ID,
+			Details:    fmt.Sprintf("Sold: %s, qty: %d, total: %.2f", name, qty, totalAmount),
 		})
 		if err != nil {
-			return "", err
+			return err
 		}
-	} else {
-		if err := h.saleRepo.Create(sale); err != nil {
-			return "", err
+
+		// Award loyalty points if customer is using loyalty
+		if h.customerRepo != nil && len(args) >= 3 {
+			customers := h.customerRepo.WithTx(tx)
+			if found, err := customers.GetByPhone(shop.ID, args[2]); err == nil {
+				if err := customers.AddPoints(found.ID, int(totalAmount/10)); err != nil {
+					return err
+				}
+				customer, pointsAwarded = found, int(totalAmount/10)
+			}
 		}
-		if err := h.productRepo.UpdateStock(product.ID, -qty); err != nil {
-			return "", err
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrInsufficientStock) {
+			return i18n.T(lang, i18n.MsgSellNotEnoughStock,
+				product.CurrentStock, product.Unit, strings.ToLower(product.Name), product.CurrentStock), nil
 		}
+		return "", err
 	}
 
 	// Recalculate daily summary
 	_ = h.summaryRepo.Recalculate(shop.ID, time.Now())
 
-	// Create audit log
+	// Trigger webhook events
+	webhooksvc.TriggerSaleCreated(sale, product)
+	webhooksvc.TriggerProductDeactivated(soldOut, "out_of_stock")
+	if customer != nil {
+		webhooksvc.TriggerCustomerCreated(customer)
+	}
+
+	// Check if now low on stock
+	remainingStock := product.CurrentStock - qty
+	websocket.NotifySaleCreated(sale, product, remainingStock)
+	response := i18n.T(lang, i18n.MsgSold,
+		product.Name, qty, totalAmount, profit, product.StockLabel(remainingStock))
+
+	if pointsAwarded > 0 {
+		response += i18n.T(lang, i18n.MsgSoldLoyaltyPoints, pointsAwarded)
+	}
+
+	if remainingStock < 0 {
+		response += i18n.T(lang, i18n.MsgSoldNegativeStock,
+			product.Name, remainingStock, strings.ToLower(product.Name), product.SellingPrice)
+	} else if remainingStock <= product.LowStockLevel() {
+		response += i18n.T(lang, i18n.MsgSoldLowStock, remainingStock)
+	}
+
+	if names := h.crossSellNames(shop.ID, product.ID); len(names) > 0 {
+		response += i18n.T(lang, i18n.MsgSoldCrossSell, strings.Join(names, ", "))
+	}
+
+	return response, nil
+}
+
+// sellBundle sells qty bundles at the bundle's price, deducting each
+// component's stock. The component sales are saved in one transaction, so
+// a component sold out in the meantime fails the whole sale.
+func (h *CommandHandler) sellBundle(shop *models.Shop, bundle *models.Product, qty int, lang i18n.Language) (string, error) {
+	if !bundle.IsActive {
+		return i18n.T(lang, i18n.MsgSellUnavailable, bundle.Name), nil
+	}
+	components, err := h.bundleRepo.GetComponents(bundle.ID)
+	if err != nil {
+		return "", err
+	}
+	if len(components) == 0 {
+		return fmt.Sprintf("📦 %s has no items yet.\n\nSet them: bundle add %s [product] [qty]...",
+			bundle.Name, strings.ToLower(bundle.Name)), nil
+	}
+	if short, needed := models.BundleShortage(components, qty); short != nil {
+		return i18n.T(lang, i18n.MsgBundleShort,
+			short.Component.Name, qty, bundle.Name, needed, short.Component.CurrentStock), nil
+	}
+
+	total, adjustment := shop.RoundingFor(models.PaymentCash).Round(bundle.SellingPrice * float64(qty))
+	sales := models.BuildBundleSales(bundle, components, qty, total, models.PaymentCash)
+	sales[len(sales)-1].RoundingAdjustment = adjustment
+	if err := h.bundleRepo.RecordSales(sales); err != nil {
+		if errors.Is(err, repository.ErrInsufficientStock) {
+			return i18n.T(lang, i18n.MsgBundleStockChanged, bundle.Name), nil
+		}
+		return "", err
+	}
+
+	profit := 0.0
+	for i, sale := range sales {
+		profit += sale.Profit
+		components[i].Component.CurrentStock -= sale.Quantity
+		soldOut, _ := h.productRepo.DeactivateIfOutOfStock(sale.ProductID)
+		webhooksvc.TriggerProductDeactivated(soldOut, "out_of_stock")
+		websocket.NotifySaleCreated(sale, &components[i].Component, components[i].Component.CurrentStock)
+	}
+
+	_ = h.summaryRepo.Recalculate(shop.ID, time.Now())
 	h.auditRepo.Create(&models.AuditLog{
 		ShopID:     shop.ID,
 		UserType:   "shop",
 		UserID:     shop.ID,
 		Action:     "sale",
 		EntityType: "sale",
-		EntityID:   sale.ID,
-		Details:    fmt.Sprintf("Sold: %s, qty: %d, total: %.2f", name, qty, totalAmount),
+		EntityID:   sales[0].ID,
+		Details:    fmt.Sprintf("Sold bundle: %s, qty: %d, total: %.2f", bundle.Name, qty, total),
 	})
 
-	// Trigger webhook event
-	webhooksvc.TriggerSaleCreated(sale, product)
+	response := i18n.T(lang, i18n.MsgSold,
+		bundle.Name, qty, total, profit, strconv.Itoa(models.BundleAvailable(components)))
+	for _, comp := range components {
+		if comp.Component.CurrentStock <= comp.Component.LowStockLevel() {
+			response += fmt.Sprintf("\n⚠️ LOW STOCK! %s: only %d left!", comp.Component.Name, comp.Component.CurrentStock)
+		}
+	}
+	return response, nil
+}
 
-	// Award loyalty points if customer is using loyalty
-	pointsAwarded := 0
-	if h.customerRepo != nil && len(args) >= 3 {
-		customerPhone := args[2]
-		if customer, err := h.customerRepo.GetByPhone(shop.ID, customerPhone); err == nil {
-			pointsAwarded = int(totalAmount / 10)
-			if err := h.customerRepo.AddPoints(customer.ID, pointsAwarded); err == nil {
-				webhooksvc.TriggerCustomerCreated(customer)
+// bundleUsage explains the bundle command
+const bundleUsage = `📦 BUNDLES
+
+bundle - list bundles
+bundle add [name] [price] [product] [qty]...
+bundle remove [name]
+
+Example: bundle add lunch 100 soda 1 mandazi 2
+Sell it: sell lunch 1
+
+Without a price, a bundle costs what its items sell for.`
+
+// handleBundle lists the shop's bundles, sets what goes in one and turns
+// one back into a plain product
+func (h *CommandHandler) handleBundle(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
+	if h.bundleRepo == nil {
+		return "⚙️ Bundles not available.\nContact support.", nil
+	}
+	if len(args) == 0 {
+		return h.listBundles(shop)
+	}
+
+	switch args[0] {
+	case "add", "set":
+		return h.addBundle(shop, args[1:], lang)
+	case "remove", "delete":
+		if len(args) != 2 {
+			return bundleUsage, nil
+		}
+		name := normalizeProductName(args[1])
+		bundle, err := h.productRepo.GetByShopAndName(shop.ID, name)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return i18n.T(lang, i18n.MsgProductNotFound, name), nil
 			}
+			return "", err
+		}
+		if !bundle.IsBundle {
+			return i18n.T(lang, i18n.MsgBundleNotBundle, bundle.Name), nil
 		}
+		if err := h.bundleRepo.SetComponents(bundle.ID, nil); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("✅ %s is no longer a bundle.\n\nIt is now a product with no stock; delete it with: delete %s",
+			bundle.Name, strings.ToLower(bundle.Name)), nil
 	}
+	return bundleUsage, nil
+}
 
-	// Check if now low on stock
-	remainingStock := product.CurrentStock - qty
-	response := fmt.Sprintf("✅ SOLD!\n%s x%d = KSh %.0f\n💵 Profit: KSh %.0f\n📦 Remaining: %d %s",
-		product.Name, qty, totalAmount, profit, remainingStock, product.Unit)
+// listBundles lists each bundle with its price, items and how many the
+// items in stock make
+func (h *CommandHandler) listBundles(shop *models.Shop) (string, error) {
+	bundles, err := h.bundleRepo.GetBundles(shop.ID)
+	if err != nil {
+		return "", err
+	}
+	if len(bundles) == 0 {
+		return bundleUsage, nil
+	}
 
-	if pointsAwarded > 0 {
-		response += fmt.Sprintf("\n💎 +%d loyalty points!", pointsAwarded)
+	var sb strings.Builder
+	sb.WriteString("📦 BUNDLES:\n")
+	for _, bundle := range bundles {
+		components, err := h.bundleRepo.GetComponents(bundle.ID)
+		if err != nil {
+			return "", err
+		}
+		items := make([]string, len(components))
+		for i, comp := range components {
+			items[i] = fmt.Sprintf("%d %s", comp.Quantity, comp.Component.Name)
+		}
+		sb.WriteString(fmt.Sprintf("\n%s - KSh %.0f\n", bundle.Name, bundle.SellingPrice))
+		sb.WriteString(fmt.Sprintf("   %s\n", strings.Join(items, " + ")))
+		sb.WriteString(fmt.Sprintf("   💵 Profit: KSh %.0f | Can make: %d\n",
+			bundle.SellingPrice-models.BundleCost(components), models.BundleAvailable(components)))
 	}
+	sb.WriteString("\nSell: sell [bundle] [qty]")
+	return sb.String(), nil
+}
 
-	if remainingStock <= product.LowStockThreshold {
-		response += fmt.Sprintf("\n⚠️ LOW STOCK! Only %d left!", remainingStock)
+// addBundle creates a bundle or changes what goes in one:
+// [name] [price] [product] [qty] [product] [qty]... A product's quantity
+// defaults to 1, and the price to what the products sell for.
+func (h *CommandHandler) addBundle(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
+	if len(args) < 2 {
+		return bundleUsage, nil
+	}
+	name := normalizeProductName(args[0])
+	if len(name) < 2 {
+		return i18n.T(lang, i18n.MsgAddNameTooShort), nil
+	}
+	if len(name) > 50 {
+		return i18n.T(lang, i18n.MsgAddNameTooLong), nil
 	}
 
-	return response, nil
+	rest := args[1:]
+	price := -1.0
+	if p, err := strconv.ParseFloat(rest[0], 64); err == nil {
+		if p < 0 {
+			return i18n.T(lang, i18n.MsgAddInvalidPrice), nil
+		}
+		if p > 999999 {
+			return i18n.T(lang, i18n.MsgAddPriceTooHigh), nil
+		}
+		price, rest = p, rest[1:]
+	}
+
+	var components []models.ProductBundle
+	seen := make(map[uint]bool)
+	sellingValue := 0.0
+	for i := 0; i < len(rest); i++ {
+		itemName := normalizeProductName(rest[i])
+		qty := 1
+		if i+1 < len(rest) {
+			if n, err := strconv.Atoi(rest[i+1]); err == nil {
+				qty, i = n, i+1
+			}
+		}
+		if qty <= 0 || qty > 999 {
+			return i18n.T(lang, i18n.MsgBundleInvalidQty, itemName), nil
+		}
+
+		item, err := h.productRepo.GetByShopAndName(shop.ID, itemName)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return i18n.T(lang, i18n.MsgProductNotFound, itemName), nil
+			}
+			return "", err
+		}
+		if item.IsBundle {
+			return i18n.T(lang, i18n.MsgBundleNested, item.Name), nil
+		}
+		if item.Name == name || seen[item.ID] {
+			return i18n.T(lang, i18n.MsgBundleItemTwice, item.Name, name), nil
+		}
+		seen[item.ID] = true
+		sellingValue += item.SellingPrice * float64(qty)
+		components = append(components, models.ProductBundle{ComponentProductID: item.ID, Quantity: qty})
+	}
+	if len(components) == 0 {
+		return bundleUsage, nil
+	}
+	if price < 0 {
+		price = sellingValue
+	}
+
+	bundle, err := h.productRepo.GetByShopAndName(shop.ID, name)
+	switch {
+	case err == nil && !bundle.IsBundle:
+		return i18n.T(lang, i18n.MsgBundleNameTaken, bundle.Name), nil
+	case err == nil:
+		bundle.SellingPrice = price
+		if err := h.productRepo.UpdateBy(bundle, models.PriceSourceWhatsApp, models.ChangedByShop(shop.ID)); err != nil {
+			return "", err
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		count, err := h.productRepo.CountByShop(shop.ID)
+		if err != nil {
+			return "", err
+		}
+		if limits := models.LimitsFor(shop.Plan); !limits.CanAddProducts(count, 1) {
+			return i18n.T(lang, i18n.MsgAddLimitReached,
+				shop.Plan.Name(), limits.MaxProducts, models.NextPlan(shop.Plan).Name()), nil
+		}
+		bundle = &models.Product{ShopID: shop.ID, Name: name, SellingPrice: price, IsActive: true}
+		if err := h.productRepo.Create(bundle); err != nil {
+			return "", err
+		}
+	default:
+		return "", err
+	}
+
+	if err := h.bundleRepo.SetComponents(bundle.ID, components); err != nil {
+		return "", err
+	}
+	h.auditRepo.Create(&models.AuditLog{
+		ShopID:     shop.ID,
+		UserType:   "shop",
+		UserID:     shop.ID,
+		Action:     "update",
+		EntityType: "product",
+		EntityID:   bundle.ID,
+		Details:    fmt.Sprintf("Bundle set: %s, %d items, price: %.2f", name, len(components), price),
+	})
+
+	saved, err := h.bundleRepo.GetComponents(bundle.ID)
+	if err != nil {
+		return "", err
+	}
+	items := make([]string, len(saved))
+	for i, comp := range saved {
+		items[i] = fmt.Sprintf("%d %s", comp.Quantity, comp.Component.Name)
+	}
+	return fmt.Sprintf("✅ BUNDLE SAVED!\n%s = %s\n💰 Price: KSh %.0f\n💵 Profit: KSh %.0f\n📦 Can make: %d\n\nSell: sell %s 1",
+		name, strings.Join(items, " + "), price, price-models.BundleCost(saved),
+		models.BundleAvailable(saved), strings.ToLower(name)), nil
+}
+
+// crossSellNames names the products most often bought with productID, for
+// suggesting on the sale receipt. Only suggestions already worked out are
+// used, so the sale doesn't wait on them.
+func (h *CommandHandler) crossSellNames(shopID, productID uint) []string {
+	products := h.crossSells.Cached(shopID, productID, 2)
+	names := make([]string, len(products))
+	for i, p := range products {
+		names[i] = p.Name
+	}
+	return names
 }
 
 // handleStock handles stock command
-func (h *CommandHandler) handleStock(shop *models.Shop, args []string) (string, error) {
+func (h *CommandHandler) handleStock(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
 	if len(args) >= 1 {
 		name := normalizeProductName(args[0])
 		product, err := h.productRepo.GetByShopAndName(shop.ID, name)
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return fmt.Sprintf("❌ Product '%s' not found", name), nil
+				return i18n.T(lang, i18n.MsgProductNotFound, name), nil
 			}
 			return "", err
 		}
 
-		stock := "✅ In Stock"
-		if product.CurrentStock <= product.LowStockThreshold {
-			stock = "⚠️ Low Stock!"
+		stock := i18n.T(lang, i18n.MsgStockIn)
+		if product.CurrentStock <= product.LowStockLevel() {
+			stock = i18n.T(lang, i18n.MsgStockLow)
 		}
 
-		return fmt.Sprintf("📦 %s\n💰 Price: KSh %.0f\n📦 Stock: %d %s\n%s",
-			product.Name, product.SellingPrice, product.CurrentStock, product.Unit, stock), nil
+		return i18n.T(lang, i18n.MsgStockProduct,
+			product.Name, product.SellingPrice, product.StockLabel(product.CurrentStock), stock), nil
 	}
 
 	products, err := h.productRepo.GetByShopID(shop.ID)
@@ -601,37 +1344,44 @@ func (h *CommandHandler) handleStock(shop *models.Shop, args []string) (string,
 	}
 
 	if len(products) == 0 {
-		return "📦 No products yet!\nAdd: add [name] [price] [qty]", nil
+		return i18n.T(lang, i18n.MsgStockEmpty), nil
 	}
 
 	var sb strings.Builder
-	sb.WriteString("📦 INVENTORY:\n\n")
+	sb.WriteString(i18n.T(lang, i18n.MsgStockInventory))
 
 	totalValue := 0.0
 	for _, p := range products {
 		stock := fmt.Sprintf("%d", p.CurrentStock)
-		if p.CurrentStock <= p.LowStockThreshold {
+		if p.CurrentStock <= p.LowStockLevel() {
 			stock = fmt.Sprintf("%d ⚠️", p.CurrentStock)
 		}
-		sb.WriteString(fmt.Sprintf("• %s: %s %s @ KSh %.0f\n", p.Name, stock, p.Unit, p.SellingPrice))
-		totalValue += p.SellingPrice * float64(p.CurrentStock)
+		unit := p.Unit
+		if bulk := p.BulkEquivalent(p.CurrentStock); bulk != "" {
+			unit += " (= " + bulk + ")"
+		}
+		sb.WriteString(fmt.Sprintf("• %s: %s %s @ KSh %.0f\n", p.Name, stock, unit, p.SellingPrice))
+		totalValue += p.SellingPrice * float64(p.StockOnHand())
 	}
 
-	sb.WriteString(fmt.Sprintf("\n💰 Total Value: KSh %.0f", totalValue))
+	sb.WriteString(i18n.T(lang, i18n.MsgStockTotal, totalValue))
 	return sb.String(), nil
 }
 
 // handlePrice handles price command
-func (h *CommandHandler) handlePrice(shop *models.Shop, args []string) (string, error) {
+func (h *CommandHandler) handlePrice(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
 	if len(args) < 1 {
-		return "❌ Usage: price [name] or price [name] [new_price]", nil
+		return i18n.T(lang, i18n.MsgPriceUsage), nil
+	}
+	if strings.ToLower(args[0]) == "history" && h.priceRepo != nil {
+		return h.handlePriceHistory(shop, args[1:], lang)
 	}
 
 	name := normalizeProductName(args[0])
 	product, err := h.productRepo.GetByShopAndName(shop.ID, name)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return fmt.Sprintf("❌ Product '%s' not found", name), nil
+			return i18n.T(lang, i18n.MsgProductNotFound, name), nil
 		}
 		return "", err
 	}
@@ -639,56 +1389,117 @@ func (h *CommandHandler) handlePrice(shop *models.Shop, args []string) (string,
 	if len(args) >= 2 {
 		newPrice, err := strconv.ParseFloat(args[1], 64)
 		if err != nil || newPrice < 0 {
-			return "❌ Invalid price", nil
+			return i18n.T(lang, i18n.MsgInvalidPrice), nil
 		}
 		oldPrice := product.SellingPrice
 		product.SellingPrice = newPrice
-		if err := h.productRepo.Update(product); err != nil {
+		if err := h.productRepo.UpdateBy(product, models.PriceSourceWhatsApp, models.ChangedByShop(shop.ID)); err != nil {
 			return "", err
 		}
-		return fmt.Sprintf("✅ Price Updated!\n%s\n💰 Was: KSh %.0f → Now: KSh %.0f",
+		return i18n.T(lang, i18n.MsgPriceUpdated,
 			product.Name, oldPrice, newPrice), nil
 	}
 
-	return fmt.Sprintf("💰 %s\nPrice: KSh %.0f\nStock: %d %s",
+	return i18n.T(lang, i18n.MsgPriceInfo,
 		product.Name, product.SellingPrice, product.CurrentStock, product.Unit), nil
 }
 
-// handleRemove handles remove command
-func (h *CommandHandler) handleRemove(shop *models.Shop, args []string) (string, error) {
+// handlePriceHistory lists a product's recent price changes
+func (h *CommandHandler) handlePriceHistory(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
+	if len(args) < 1 {
+		return i18n.T(lang, i18n.MsgPriceHistoryUsage), nil
+	}
+
+	name := normalizeProductName(args[0])
+	product, err := h.productRepo.GetByShopAndName(shop.ID, name)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return i18n.T(lang, i18n.MsgProductNotFound, name), nil
+		}
+		return "", err
+	}
+
+	changes, err := h.priceRepo.GetByProduct(product.ID, 10)
+	if err != nil {
+		return "", err
+	}
+	// Cost-only changes are left out; this lists what customers paid
+	history := changes[:0]
+	for _, change := range changes {
+		if change.OldPrice != change.NewPrice {
+			history = append(history, change)
+		}
+	}
+	if len(history) == 0 {
+		return i18n.T(lang, i18n.MsgPriceHistoryEmpty, product.Name, product.SellingPrice), nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(i18n.T(lang, i18n.MsgPriceHistoryHeader, product.Name, product.SellingPrice))
+	for _, change := range history {
+		sb.WriteString(i18n.T(lang, i18n.MsgPriceHistoryLine,
+			change.CreatedAt.Format("02 Jan 2006"), change.OldPrice, change.NewPrice, change.Source))
+	}
+	return strings.TrimRight(sb.String(), "\n"), nil
+}
+
+// RemoveConfirmThreshold is the largest quantity `remove` takes without
+// asking for confirmation
+const RemoveConfirmThreshold = 50
+
+// handleRemove handles remove command. Removing more than
+// RemoveConfirmThreshold units waits for `yes remove ...` unless confirmed.
+func (h *CommandHandler) handleRemove(phone string, shop *models.Shop, args []string, lang i18n.Language, confirmed bool) (string, error) {
 	if len(args) < 2 {
-		return "❌ Usage: remove [name] [quantity]\nExample: remove bread 5", nil
+		return i18n.T(lang, i18n.MsgRemoveUsage), nil
 	}
 
 	name := normalizeProductName(args[0])
 	qty, err := strconv.Atoi(args[1])
 	if err != nil || qty <= 0 {
-		return "❌ Invalid quantity", nil
+		return i18n.T(lang, i18n.MsgInvalidQuantity), nil
 	}
 
 	product, err := h.productRepo.GetByShopAndName(shop.ID, name)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return fmt.Sprintf("❌ Product '%s' not found", name), nil
+			return i18n.T(lang, i18n.MsgProductNotFound, name), nil
 		}
 		return "", err
 	}
 
+	if len(args) > 2 {
+		units, ok := product.ToBaseUnits(qty, args[2])
+		if !ok {
+			return i18n.T(lang, i18n.MsgUnitUnknown, product.Name, args[2], strings.ToLower(product.Name), args[2], args[2]), nil
+		}
+		qty = units
+	}
+
 	if product.CurrentStock < qty {
-		return fmt.Sprintf("❌ Not enough stock!\nAvailable: %d", product.CurrentStock), nil
+		return i18n.T(lang, i18n.MsgRemoveNotEnoughStock, product.CurrentStock), nil
 	}
 
-	product.CurrentStock -= qty
-	if err := h.productRepo.Update(product); err != nil {
+	if qty > RemoveConfirmThreshold && !confirmed && h.confirmations != nil {
+		action := "remove " + strings.Join(args, " ")
+		if err := h.confirmations.Request(phone, action); err != nil {
+			return "", err
+		}
+		return i18n.T(lang, i18n.MsgRemoveConfirm, qty, product.Unit, product.Name, action), nil
+	}
+
+	movement, soldOut, err := h.productRepo.MoveStock(product.ID, -qty, models.StockMovementAdjustment, nil, "removed")
+	if err != nil {
 		return "", err
 	}
+	webhooksvc.TriggerProductDeactivated(soldOut, "out_of_stock")
 
-	return fmt.Sprintf("✅ Removed %d %s from %s\n📦 Remaining: %d",
-		qty, product.Unit, product.Name, product.CurrentStock), nil
+	return i18n.T(lang, i18n.MsgRemoved,
+		qty, product.Unit, product.Name, movement.BalanceAfter), nil
 }
 
 // handleReport handles daily report
-func (h *CommandHandler) handleReport(shop *models.Shop) (string, error) {
+func (h *CommandHandler) handleReport(shop *models.Shop, lang i18n.Language) (string, error) {
 	startOfDay := time.Now().Truncate(24 * time.Hour)
 	endOfDay := startOfDay.Add(24 * time.Hour)
 
@@ -709,11 +1520,11 @@ func (h *CommandHandler) handleReport(shop *models.Shop) (string, error) {
 		totalSales += s.TotalAmount
 	}
 
-	report := fmt.Sprintf("📊 DAILY REPORT\n📅 %s\n\n💰 Sales: KSh %.0f\n📝 Transactions: %d\n💵 Profit: KSh %.0f\n\nTop Items:",
+	report := i18n.T(lang, i18n.MsgDailyReport,
 		time.Now().Format("Mon, Jan 2"), totalSales, len(sales), profit)
 
 	if len(sales) == 0 {
-		report += "\nNo sales today yet!"
+		report += i18n.T(lang, i18n.MsgNoSalesToday)
 	} else {
 		productSales := make(map[string]int)
 		for _, s := range sales {
@@ -725,7 +1536,7 @@ func (h *CommandHandler) handleReport(shop *models.Shop) (string, error) {
 			if count >= 5 {
 				break
 			}
-			report += fmt.Sprintf("\n• %s: %d sold", name, qty)
+			report += i18n.T(lang, i18n.MsgReportItem, name, qty)
 			count++
 		}
 	}
@@ -734,111 +1545,90 @@ func (h *CommandHandler) handleReport(shop *models.Shop) (string, error) {
 }
 
 // handleWeekly handles weekly report
-func (h *CommandHandler) handleWeekly(shop *models.Shop) (string, error) {
+func (h *CommandHandler) handleWeekly(shop *models.Shop, lang i18n.Language) (string, error) {
+	// The last 7 summary days, today included
 	end := time.Now()
-	start := end.AddDate(0, 0, -7)
+	start := end.Truncate(24*time.Hour).AddDate(0, 0, -6)
 
-	// Try to get cached summaries
-	summaries, err := h.summaryRepo.GetByDateRange(shop.ID, start, end)
+	totals, err := h.summaryRepo.Totals(shop.ID, start, end)
 	if err != nil {
 		return "", err
 	}
 
-	var totalSales, totalProfit float64
-	var totalTransactions int
-
-	// If no cached summaries, calculate from sales directly
-	if len(summaries) == 0 {
-		sales, err := h.saleRepo.GetByDateRange(shop.ID, start, end)
-		if err != nil {
-			return "", err
-		}
-		for _, s := range sales {
-			totalSales += s.TotalAmount
-			totalProfit += s.Profit
-			totalTransactions++
-		}
-	} else {
-		for _, s := range summaries {
-			totalSales += s.TotalSales
-			totalProfit += s.TotalProfit
-			totalTransactions += s.TotalTransactions
-		}
+	if totals.Transactions == 0 {
+		return i18n.T(lang, i18n.MsgNoSalesWeek), nil
 	}
 
-	if totalTransactions == 0 {
-		return "📊 No sales data for this week.\n\nStart recording sales to see reports!", nil
+	// The 7 days before
+	previous, err := h.summaryRepo.Totals(shop.ID, start.AddDate(0, 0, -7), start)
+	if err != nil {
+		return "", err
 	}
+	vs := compareTotals(lang, totals, previous, i18n.MsgVsLastWeek, i18n.MsgNoneLastWeek)
 
-	avgDaily := totalSales / 7
-
-	return fmt.Sprintf(`📊 WEEKLY REPORT
-📅 Last 7 days (to %s)
-
-💰 Total Sales: KSh %.0f
-📝 Transactions: %d
-💵 Profit: KSh %.0f
-📈 Daily Avg: KSh %.0f
+	avgDaily := totals.Sales / 7
 
-Keep up the good work! 💪`, end.Format("Jan 2"), totalSales, totalTransactions, totalProfit, avgDaily), nil
+	return i18n.T(lang, i18n.MsgWeeklyReport, end.Format("Jan 2"),
+		totals.Sales, vs.sales, totals.Transactions, vs.transactions, totals.Profit, vs.profit, avgDaily), nil
 }
 
 // handleMonthly handles monthly report
-func (h *CommandHandler) handleMonthly(shop *models.Shop) (string, error) {
+func (h *CommandHandler) handleMonthly(shop *models.Shop, lang i18n.Language) (string, error) {
 	end := time.Now()
-	start := end.AddDate(0, -1, 0)
+	start := end.AddDate(0, -1, 0).Truncate(24 * time.Hour)
 
-	// Try to get cached summaries
-	summaries, err := h.summaryRepo.GetByDateRange(shop.ID, start, end)
+	totals, err := h.summaryRepo.Totals(shop.ID, start, end)
 	if err != nil {
 		return "", err
 	}
 
-	var totalSales, totalProfit float64
-	var totalTransactions int
-
-	// If no cached summaries, calculate from sales directly
-	if len(summaries) == 0 {
-		sales, err := h.saleRepo.GetByDateRange(shop.ID, start, end)
-		if err != nil {
-			return "", err
-		}
-		for _, s := range sales {
-			totalSales += s.TotalAmount
-			totalProfit += s.Profit
-			totalTransactions++
-		}
-	} else {
-		for _, s := range summaries {
-			totalSales += s.TotalSales
-			totalProfit += s.TotalProfit
-			totalTransactions += s.TotalTransactions
-		}
+	if totals.Transactions == 0 {
+		return i18n.T(lang, i18n.MsgNoSalesMonth), nil
 	}
 
-	if totalTransactions == 0 {
-		return "📊 No sales data for this month.\n\nStart recording sales to see reports!", nil
+	// The month before
+	previous, err := h.summaryRepo.Totals(shop.ID, start.AddDate(0, -1, 0), start)
+	if err != nil {
+		return "", err
 	}
+	vs := compareTotals(lang, totals, previous, i18n.MsgVsLastMonth, i18n.MsgNoneLastMonth)
 
 	daysInRange := float64(time.Since(start).Hours() / 24)
 	if daysInRange < 1 {
 		daysInRange = 1
 	}
-	avgDaily := totalSales / daysInRange
+	avgDaily := totals.Sales / daysInRange
 
-	return fmt.Sprintf(`📊 MONTHLY REPORT
-📅 %s
+	return i18n.T(lang, i18n.MsgMonthlyReport, start.Format("Jan")+" - "+end.Format("Jan 2, 2006"),
+		totals.Sales, vs.sales, totals.Transactions, vs.transactions, totals.Profit, vs.profit, avgDaily), nil
+}
 
-💰 Total Sales: KSh %.0f
-📝 Transactions: %d
-💵 Profit: KSh %.0f
-📈 Daily Avg: KSh %.0f
+// periodChanges are a report's " (↑12% vs last week)" notes
+type periodChanges struct {
+	sales, transactions, profit string
+}
 
-Great progress this month! 🎉`, start.Format("Jan")+" - "+end.Format("Jan 2, 2006"), totalSales, totalTransactions, totalProfit, avgDaily), nil
+// compareTotals notes how each total moved against the previous period,
+// or that the previous period had none
+func compareTotals(lang i18n.Language, current, previous repository.SummaryTotals, vs, none i18n.Message) periodChanges {
+	c := export.Compare("",
+		export.PeriodTotals{Sales: current.Sales, Profit: current.Profit, Transactions: current.Transactions},
+		export.PeriodTotals{Sales: previous.Sales, Profit: previous.Profit, Transactions: previous.Transactions})
+	note := func(change *float64) string {
+		if change == nil {
+			return " " + i18n.T(lang, none)
+		}
+		return " " + i18n.T(lang, vs, export.FormatChange(*change))
+	}
+	return periodChanges{
+		sales:        note(c.SalesChange),
+		transactions: note(c.TransactionsChange),
+		profit:       note(c.ProfitChange),
+	}
 }
 
 // handleProfit handles profit calculation
-func (h *CommandHandler) handleProfit(shop *models.Shop) (string, error) {
+func (h *CommandHandler) handleProfit(shop *models.Shop, lang i18n.Language) (string, error) {
 	sales, err := h.saleRepo.GetTodaySales(shop.ID)
 	if err != nil {
 		return "", err
@@ -849,56 +1639,106 @@ func (h *CommandHandler) handleProfit(shop *models.Shop) (string, error) {
 		totalProfit += s.Profit
 	}
 
-	return fmt.Sprintf("💵 TODAY'S PROFIT: KSh %.0f\n\n💰 Total Sales: KSh %.0f\n📝 Transactions: %d",
-		totalProfit, getTotalSales(sales), len(sales)), nil
+	reply := i18n.T(lang, i18n.MsgProfit,
+		totalProfit, getTotalSales(sales), len(sales))
+	if h.expenseRepo != nil {
+		today := time.Now().Truncate(24 * time.Hour)
+		expenses, err := h.expenseRepo.Total(shop.ID, today, today.Add(24*time.Hour))
+		if err != nil {
+			return "", err
+		}
+		reply += i18n.T(lang, i18n.MsgProfitNet, expenses, totalProfit-expenses)
+	}
+	return reply, nil
 }
 
 // handleLowStock handles low stock alert
-func (h *CommandHandler) handleLowStock(shop *models.Shop) (string, error) {
+func (h *CommandHandler) handleLowStock(shop *models.Shop, lang i18n.Language) (string, error) {
 	products, err := h.productRepo.GetLowStock(shop.ID)
 	if err != nil {
 		return "", err
 	}
 
 	if len(products) == 0 {
-		return "✅ All products are well stocked!", nil
+		return i18n.T(lang, i18n.MsgAllWellStocked), nil
 	}
 
 	var sb strings.Builder
-	sb.WriteString("⚠️ LOW STOCK ALERT:\n\n")
+	sb.WriteString(i18n.T(lang, i18n.MsgLowStockAlert))
 
 	for _, p := range products {
-		sb.WriteString(fmt.Sprintf("• %s: %d %s (min: %d)\n",
-			p.Name, p.CurrentStock, p.Unit, p.LowStockThreshold))
+		if p.CurrentStock < 0 {
+			sb.WriteString(i18n.T(lang, i18n.MsgLowStockOwed, p.Name, p.CurrentStock, p.Unit))
+			continue
+		}
+		sb.WriteString(i18n.T(lang, i18n.MsgLowStockItem,
+			p.Name, p.CurrentStock, p.Unit, p.LowStockLevel()))
 	}
 
 	return sb.String(), nil
 }
 
-// handleDelete handles product deletion
-func (h *CommandHandler) handleDelete(shop *models.Shop, args []string) (string, error) {
+// handleDelete handles product deletion, which waits for `yes delete
+// [name]` unless confirmed
+func (h *CommandHandler) handleDelete(phone string, shop *models.Shop, args []string, lang i18n.Language, confirmed bool) (string, error) {
 	if len(args) < 1 {
-		return "❌ Usage: delete [name]", nil
+		return i18n.T(lang, i18n.MsgDeleteUsage), nil
 	}
 
 	name := normalizeProductName(args[0])
 	product, err := h.productRepo.GetByShopAndName(shop.ID, name)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return fmt.Sprintf("❌ Product '%s' not found", name), nil
+			return i18n.T(lang, i18n.MsgProductNotFound, name), nil
 		}
 		return "", err
 	}
 
+	if !confirmed && h.confirmations != nil {
+		action := "delete " + strings.Join(args, " ")
+		if err := h.confirmations.Request(phone, action); err != nil {
+			return "", err
+		}
+		return i18n.T(lang, i18n.MsgDeleteConfirm, product.Name, action), nil
+	}
+
 	if err := h.productRepo.Delete(product.ID); err != nil {
 		return "", err
 	}
 
-	return fmt.Sprintf("🗑️ Deleted: %s", product.Name), nil
+	return i18n.T(lang, i18n.MsgDeleted, product.Name), nil
+}
+
+// handleConfirm runs a destructive command repeated after "yes" if it is
+// the one phone was just asked to confirm
+func (h *CommandHandler) handleConfirm(phone string, shop *models.Shop, args []string, lang i18n.Language) (string, error) {
+	if len(args) < 2 || h.confirmations == nil {
+		return i18n.T(lang, i18n.MsgConfirmNothing), nil
+	}
+
+	ok, err := h.confirmations.Confirm(phone, strings.Join(args, " "))
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return i18n.T(lang, i18n.MsgConfirmNothing), nil
+	}
+
+	switch args[0] {
+	case "delete":
+		return h.handleDelete(phone, shop, args[1:], lang, true)
+	case "remove":
+		return h.handleRemove(phone, shop, args[1:], lang, true)
+	case "supplier":
+		if args[1] == "pay" {
+			return h.handleSupplierPay(phone, shop, args[2:], lang, true)
+		}
+	}
+	return i18n.T(lang, i18n.MsgConfirmNothing), nil
 }
 
 // handleCategory handles category view and management
-func (h *CommandHandler) handleCategory(shop *models.Shop, args []string) (string, error) {
+func (h *CommandHandler) handleCategory(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
 	// Get unique categories from database
 	categories, err := h.productRepo.GetCategories(shop.ID)
 	if err != nil {
@@ -939,7 +1779,7 @@ func (h *CommandHandler) handleCategory(shop *models.Shop, args []string) (strin
 			product, err := h.productRepo.GetByShopAndName(shop.ID, name)
 			if err != nil {
 				if errors.Is(err, gorm.ErrRecordNotFound) {
-					return fmt.Sprintf("❌ Product '%s' not found", name), nil
+					return i18n.T(lang, i18n.MsgProductNotFound, name), nil
 				}
 				return "", err
 			}
@@ -947,18 +1787,21 @@ func (h *CommandHandler) handleCategory(shop *models.Shop, args []string) (strin
 			if err := h.productRepo.Update(product); err != nil {
 				return "", err
 			}
+			if h.categoryRepo != nil {
+				_, _ = h.categoryRepo.GetOrCreate(shop.ID, categoryName)
+			}
 			return fmt.Sprintf("✅ Category Updated!\n%s\nNow in: %s", product.Name, categoryName), nil
 		}
 
 		// View products in category
-		prods, err := h.productRepo.GetByCategory(shop.ID, cat)
+		prods, err := h.productRepo.GetByCategory(shop.ID, cat, h.categoryRepo != nil)
 		if err != nil {
 			return "", err
 		}
 		if len(prods) == 0 {
 			// Check if category exists
 			if _, ok := catMap[cat]; !ok {
-				return fmt.Sprintf("❌ Category '%s' not found.\n\nAvailable: %s", cat, getCategoryList(catMap)), nil
+				return i18n.T(lang, i18n.MsgCategoryNotFound, cat, getCategoryList(catMap)), nil
 			}
 			return fmt.Sprintf("📦 %s:\n\n(No products)", cat), nil
 		}
@@ -966,7 +1809,7 @@ func (h *CommandHandler) handleCategory(shop *models.Shop, args []string) (strin
 		sb.WriteString(fmt.Sprintf("📦 %s (%d items):\n\n", cat, len(prods)))
 		for _, p := range prods {
 			stock := fmt.Sprintf("%d", p.CurrentStock)
-			if p.CurrentStock <= p.LowStockThreshold {
+			if p.CurrentStock <= p.LowStockLevel() {
 				stock = fmt.Sprintf("%d ⚠️", p.CurrentStock)
 			}
 			sb.WriteString(fmt.Sprintf("• %s: %s @ KSh %.0f\n", p.Name, stock, p.SellingPrice))
@@ -984,8 +1827,44 @@ Add products with: add [name] [price] [qty]
 Then set category: category [product] [category]`, nil
 	}
 
+	categoryList := getCategoryList(catMap)
+	if h.categoryRepo != nil {
+		if tree, err := h.categoryRepo.GetTree(shop.ID); err == nil && len(tree) > 0 {
+			categoryList = getCategoryTreeList(tree, catMap)
+		}
+	}
+
 	return fmt.Sprintf("📂 CATEGORIES (%d):\n\n%s\n\nSet category:\ncategory [product] [name]",
-		len(catMap), getCategoryList(catMap)), nil
+		len(catMap), categoryList), nil
+}
+
+// getCategoryTreeList renders nested categories with subcategories indented
+// under their parents, followed by any categories missing from the tree
+func getCategoryTreeList(tree []models.Category, catMap map[string]int) string {
+	var lines []string
+	listed := make(map[string]bool)
+
+	var walk func(nodes []models.Category, depth int)
+	walk = func(nodes []models.Category, depth int) {
+		for _, c := range nodes {
+			prefix := ""
+			if depth > 0 {
+				prefix = strings.Repeat("  ", depth-1) + "  └ "
+			}
+			lines = append(lines, fmt.Sprintf("%s%s (%d)", prefix, c.Name, catMap[c.Name]))
+			listed[c.Name] = true
+			walk(c.Children, depth+1)
+		}
+	}
+	walk(tree, 0)
+
+	for cat, count := range catMap {
+		if !listed[cat] {
+			lines = append(lines, fmt.Sprintf("%s (%d)", cat, count))
+		}
+	}
+
+	return strings.Join(lines, "\n")
 }
 
 func getCategoryList(catMap map[string]int) string {
@@ -997,12 +1876,12 @@ func getCategoryList(catMap map[string]int) string {
 }
 
 // handleAll handles all products (alias for stock)
-func (h *CommandHandler) handleAll(shop *models.Shop) (string, error) {
-	return h.handleStock(shop, []string{})
+func (h *CommandHandler) handleAll(shop *models.Shop, lang i18n.Language) (string, error) {
+	return h.handleStock(shop, []string{}, lang)
 }
 
 // handleTop handles top selling products
-func (h *CommandHandler) handleTop(shop *models.Shop, args []string) (string, error) {
+func (h *CommandHandler) handleTop(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
 	limit := 5
 	if len(args) > 0 {
 		if l, err := strconv.Atoi(args[0]); err == nil && l > 0 && l <= 20 {
@@ -1087,33 +1966,27 @@ func (h *CommandHandler) handleTop(shop *models.Shop, args []string) (string, er
 }
 
 // handleSearch handles product search
-func (h *CommandHandler) handleSearch(shop *models.Shop, args []string) (string, error) {
+func (h *CommandHandler) handleSearch(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
 	if len(args) < 1 {
-		return "❌ Usage: search [product name]\nExample: search milk", nil
+		return i18n.T(lang, i18n.MsgSearchUsage), nil
 	}
 
 	search := strings.ToLower(strings.Join(args, " "))
-	products, err := h.productRepo.GetByShopID(shop.ID)
+	// Best sellers first, so what the shop sells most is at the top
+	matches, err := h.productRepo.Search(shop.ID, search, repository.SearchSortVelocity, searchCommandLimit)
 	if err != nil {
 		return "", err
 	}
 
-	var matches []models.Product
-	for _, p := range products {
-		if strings.Contains(strings.ToLower(p.Name), search) {
-			matches = append(matches, p)
-		}
-	}
-
 	if len(matches) == 0 {
-		return fmt.Sprintf("❌ No products found matching '%s'\n\nTry a different search term.", search), nil
+		return i18n.T(lang, i18n.MsgSearchNone, search), nil
 	}
 
 	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("🔍 Search Results for '%s':\n\n", search))
+	sb.WriteString(i18n.T(lang, i18n.MsgSearchResults, search))
 	for _, p := range matches {
 		stock := fmt.Sprintf("%d", p.CurrentStock)
-		if p.CurrentStock <= p.LowStockThreshold {
+		if p.CurrentStock <= p.LowStockLevel() {
 			stock = fmt.Sprintf("%d ⚠️", p.CurrentStock)
 		}
 		sb.WriteString(fmt.Sprintf("• %s\n", p.Name))
@@ -1123,7 +1996,7 @@ func (h *CommandHandler) handleSearch(shop *models.Shop, args []string) (string,
 }
 
 // handleCost handles cost price management
-func (h *CommandHandler) handleCost(shop *models.Shop, args []string) (string, error) {
+func (h *CommandHandler) handleCost(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
 	if len(args) < 1 {
 		return `💰 COST PRICE COMMANDS:
 
@@ -1141,7 +2014,7 @@ Note: Cost price is used for profit calculation.`, nil
 	product, err := h.productRepo.GetByShopAndName(shop.ID, name)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return fmt.Sprintf("❌ Product '%s' not found", name), nil
+			return i18n.T(lang, i18n.MsgProductNotFound, name), nil
 		}
 		return "", err
 	}
@@ -1165,7 +2038,7 @@ cost %s [new cost]`, product.Name, product.CostPrice, product.SellingPrice, marg
 	// Set new cost price
 	cost, err := strconv.ParseFloat(args[1], 64)
 	if err != nil || cost < 0 {
-		return "❌ Invalid cost price. Use a positive number.", nil
+		return i18n.T(lang, i18n.MsgCostInvalid), nil
 	}
 
 	product.CostPrice = cost
@@ -1174,12 +2047,11 @@ cost %s [new cost]`, product.Name, product.CostPrice, product.SellingPrice, marg
 	}
 
 	margin := ((product.SellingPrice - cost) / cost) * 100
-	return fmt.Sprintf("✅ Cost Price Updated!\n\n💰 %s\nCost: KSh %.2f\nSelling: KSh %.2f\nMargin: %.1f%%",
-		product.Name, cost, product.SellingPrice, margin), nil
+	return i18n.T(lang, i18n.MsgCostUpdated, product.Name, cost, product.SellingPrice, margin), nil
 }
 
 // handleBackup handles backup commands
-func (h *CommandHandler) handleBackup(shop *models.Shop) (string, error) {
+func (h *CommandHandler) handleBackup(shop *models.Shop, lang i18n.Language) (string, error) {
 	return `💾 BACKUP
 
 Your data is automatically backed up daily at 2 AM.
@@ -1192,16 +2064,20 @@ Contact support for custom backup requests.`, nil
 }
 
 // handleThreshold handles threshold/limit command for low stock alerts
-func (h *CommandHandler) handleThreshold(shop *models.Shop, args []string) (string, error) {
+func (h *CommandHandler) handleThreshold(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
 	if len(args) < 1 {
 		return `⚙️ THRESHOLD COMMANDS:
 
 threshold [product] - View current threshold
 threshold [product] [num] - Set low stock alert
+threshold [product] [num]% [max] - Alert at a percentage of max stock
 
 Example: 
 threshold milk - See milk's threshold
-threshold milk 5 - Alert when milk below 5`, nil
+threshold milk 5 - Alert when milk below 5
+threshold milk 20% 200 - Alert when milk below 20% of 200
+
+A percentage, once set, is used instead of the number; set a number to go back.`, nil
 	}
 
 	// If first arg is "list", show all products with their thresholds
@@ -1216,7 +2092,7 @@ threshold milk 5 - Alert when milk below 5`, nil
 		var sb strings.Builder
 		sb.WriteString("⚙️ PRODUCT THRESHOLDS:\n\n")
 		for _, p := range products {
-			sb.WriteString(fmt.Sprintf("• %s: %d (current: %d)\n", p.Name, p.LowStockThreshold, p.CurrentStock))
+			sb.WriteString(fmt.Sprintf("• %s: %s (current: %d)\n", p.Name, thresholdLabel(&p), p.CurrentStock))
 		}
 		return sb.String(), nil
 	}
@@ -1225,7 +2101,7 @@ threshold milk 5 - Alert when milk below 5`, nil
 	product, err := h.productRepo.GetByShopAndName(shop.ID, name)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return fmt.Sprintf("❌ Product '%s' not found", name), nil
+			return i18n.T(lang, i18n.MsgProductNotFound, name), nil
 		}
 		return "", err
 	}
@@ -1233,30 +2109,121 @@ threshold milk 5 - Alert when milk below 5`, nil
 	// If just viewing threshold
 	if len(args) < 2 {
 		stockStatus := "✅ OK"
-		if product.CurrentStock <= product.LowStockThreshold {
+		if product.CurrentStock <= product.LowStockLevel() {
 			stockStatus = "⚠️ LOW"
 		}
-		return fmt.Sprintf("⚙️ %s\nCurrent Stock: %d\nLow Stock Alert: %d\nStatus: %s",
-			product.Name, product.CurrentStock, product.LowStockThreshold, stockStatus), nil
+		return fmt.Sprintf("⚙️ %s\nCurrent Stock: %d\nLow Stock Alert: %s\nStatus: %s",
+			product.Name, product.CurrentStock, thresholdLabel(product), stockStatus), nil
+	}
+
+	// A percentage of max stock, e.g. 20% or 20% 200
+	if value, ok := strings.CutSuffix(args[1], "%"); ok {
+		percent, err := strconv.Atoi(value)
+		if err != nil || percent < 1 || percent > 100 {
+			return i18n.T(lang, i18n.MsgThresholdPercentInvalid), nil
+		}
+		maxStock := product.MaxStock
+		if len(args) >= 3 {
+			maxStock, err = strconv.Atoi(args[2])
+			if err != nil || maxStock < 1 || maxStock > 99999 {
+				return i18n.T(lang, i18n.MsgThresholdPercentInvalid), nil
+			}
+		}
+		if maxStock == 0 {
+			return i18n.T(lang, i18n.MsgThresholdNeedsMax, product.Name, percent, strings.ToLower(product.Name), percent), nil
+		}
+
+		product.LowStockPercent = percent
+		product.MaxStock = maxStock
+		if err := h.productRepo.Update(product); err != nil {
+			return "", err
+		}
+		return i18n.T(lang, i18n.MsgThresholdPercentUpdated, product.Name, percent, maxStock, product.LowStockLevel()), nil
 	}
 
 	// Set new threshold
 	threshold, err := strconv.Atoi(args[1])
 	if err != nil || threshold < 1 || threshold > 9999 {
-		return "❌ Invalid threshold. Use a number between 1-9999", nil
+		return i18n.T(lang, i18n.MsgThresholdInvalid), nil
 	}
 
+	// A number replaces a percentage; max stock is kept for next time
 	product.LowStockThreshold = threshold
+	product.LowStockPercent = 0
+	if err := h.productRepo.Update(product); err != nil {
+		return "", err
+	}
+
+	return i18n.T(lang, i18n.MsgThresholdUpdated, product.Name, threshold), nil
+}
+
+// thresholdLabel shows a product's low stock level, and the percentage of
+// max stock it comes from when there is one
+func thresholdLabel(p *models.Product) string {
+	if p.LowStockPercent > 0 && p.MaxStock > 0 {
+		return fmt.Sprintf("%d (%d%% of %d)", p.LowStockLevel(), p.LowStockPercent, p.MaxStock)
+	}
+	return strconv.Itoa(p.LowStockThreshold)
+}
+
+// handleUnit sets the bulk unit a product is bought in:
+// "unit soda crate 24" or "unit soda crate 24 bottle" to also name the single
+func (h *CommandHandler) handleUnit(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
+	if len(args) < 2 {
+		return i18n.T(lang, i18n.MsgUnitUsage), nil
+	}
+
+	name := normalizeProductName(args[0])
+	product, err := h.productRepo.GetByShopAndName(shop.ID, name)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return i18n.T(lang, i18n.MsgProductNotFound, name), nil
+		}
+		return "", err
+	}
+
+	switch args[1] {
+	case "off", "none", "clear":
+		product.SetPurchaseUnit("", 0)
+		if err := h.productRepo.Update(product); err != nil {
+			return "", err
+		}
+		return i18n.T(lang, i18n.MsgUnitCleared, product.Name), nil
+	}
+
+	if len(args) < 3 {
+		return i18n.T(lang, i18n.MsgUnitUsage), nil
+	}
+	factor, err := strconv.Atoi(args[2])
+	if err != nil {
+		return i18n.T(lang, i18n.MsgUnitInvalidFactor), nil
+	}
+	if len(args) > 3 {
+		product.Unit = args[3]
+	}
+	if err := product.SetPurchaseUnit(args[1], factor); err != nil {
+		return i18n.T(lang, i18n.MsgUnitInvalidFactor), nil
+	}
 	if err := h.productRepo.Update(product); err != nil {
 		return "", err
 	}
 
-	return fmt.Sprintf("✅ Threshold Updated!\n%s\nLow stock alert set at: %d\nYou'll be notified when stock falls below this.",
-		product.Name, threshold), nil
+	h.auditRepo.Create(&models.AuditLog{
+		ShopID:     shop.ID,
+		UserType:   "shop",
+		UserID:     shop.ID,
+		Action:     "update",
+		EntityType: "product",
+		EntityID:   product.ID,
+		Details:    fmt.Sprintf("Bulk unit: %s = %d %s", product.PurchaseUnit, product.UnitsPerPurchase, product.Unit),
+	})
+
+	return i18n.T(lang, i18n.MsgUnitSet, product.Name, product.PurchaseUnit, product.UnitsPerPurchase, product.Unit,
+		product.StockLabel(product.CurrentStock), strings.ToLower(product.Name), product.PurchaseUnit), nil
 }
 
 // handleBarcode handles barcode/scan commands
-func (h *CommandHandler) handleBarcode(shop *models.Shop, args []string) (string, error) {
+func (h *CommandHandler) handleBarcode(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
 	if len(args) < 1 {
 		return `📱 BARCODE COMMANDS:
 
@@ -1271,20 +2238,20 @@ barcode add milk 5901234123457 - Set barcode`, nil
 	switch args[0] {
 	case "add", "set":
 		if len(args) < 3 {
-			return "❌ Usage: barcode add [product] [barcode]\nExample: barcode add milk 5901234123457", nil
+			return i18n.T(lang, i18n.MsgBarcodeUsage), nil
 		}
 		name := normalizeProductName(args[1])
 		barcode := args[2]
 
 		// Validate barcode format (basic validation)
 		if len(barcode) < 4 || len(barcode) > 50 {
-			return "❌ Invalid barcode format (4-50 characters)", nil
+			return i18n.T(lang, i18n.MsgBarcodeInvalid), nil
 		}
 
 		product, err := h.productRepo.GetByShopAndName(shop.ID, name)
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return fmt.Sprintf("❌ Product '%s' not found", name), nil
+				return i18n.T(lang, i18n.MsgProductNotFound, name), nil
 			}
 			return "", err
 		}
@@ -1292,14 +2259,14 @@ barcode add milk 5901234123457 - Set barcode`, nil
 		// Check if barcode is already used by another product
 		existing, _ := h.productRepo.GetByBarcode(shop.ID, barcode)
 		if existing != nil && existing.ID != product.ID {
-			return fmt.Sprintf("❌ Barcode already assigned to '%s'", existing.Name), nil
+			return i18n.T(lang, i18n.MsgBarcodeTaken, existing.Name), nil
 		}
 
 		product.Barcode = barcode
 		if err := h.productRepo.Update(product); err != nil {
 			return "", err
 		}
-		return fmt.Sprintf("✅ Barcode set!\n%s\nBarcode: %s", product.Name, barcode), nil
+		return i18n.T(lang, i18n.MsgBarcodeSet, product.Name, barcode), nil
 
 	default:
 		// Look up by barcode
@@ -1307,12 +2274,12 @@ barcode add milk 5901234123457 - Set barcode`, nil
 		product, err := h.productRepo.GetByBarcode(shop.ID, barcode)
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return fmt.Sprintf("❌ No product found with barcode: %s\n\nTip: Add barcode with: barcode add [product] [code]", barcode), nil
+				return i18n.T(lang, i18n.MsgBarcodeNotFound, barcode), nil
 			}
 			return "", err
 		}
 		stockStatus := "✅ In Stock"
-		if product.CurrentStock <= product.LowStockThreshold {
+		if product.CurrentStock <= product.LowStockLevel() {
 			stockStatus = "⚠️ Low Stock"
 		}
 		return fmt.Sprintf("🔍 BARCODE: %s\n\n📦 %s\n💰 Price: KSh %.0f\n📦 Stock: %d %s\nStatus: %s",
@@ -1320,8 +2287,196 @@ barcode add milk 5901234123457 - Set barcode`, nil
 	}
 }
 
+// handleAlias handles the short names a shop types for products:
+// "alias add coca-cola 500ml coke", "alias remove coke" and "alias [product]"
+func (h *CommandHandler) handleAlias(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
+	if len(args) == 0 {
+		return i18n.T(lang, i18n.MsgAliasUsage), nil
+	}
+
+	switch args[0] {
+	case "add", "set":
+		if len(args) < 3 {
+			return i18n.T(lang, i18n.MsgAliasUsage), nil
+		}
+		// The product name may be several words; the short name is the last
+		name := normalizeProductName(strings.Join(args[1:len(args)-1], " "))
+		alias := repository.NormalizeAlias(args[len(args)-1])
+		if len(alias) > 100 {
+			return i18n.T(lang, i18n.MsgAliasInvalid), nil
+		}
+
+		product, err := h.productRepo.GetByShopAndName(shop.ID, name)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return i18n.T(lang, i18n.MsgProductNotFound, name), nil
+			}
+			return "", err
+		}
+		if _, err := h.productRepo.AddAlias(product, alias); err != nil {
+			if errors.Is(err, repository.ErrAliasTaken) {
+				return i18n.T(lang, i18n.MsgAliasTaken, alias), nil
+			}
+			return "", err
+		}
+		return i18n.T(lang, i18n.MsgAliasAdded, alias, product.Name, alias), nil
+
+	case "remove", "delete":
+		if len(args) != 2 {
+			return i18n.T(lang, i18n.MsgAliasUsage), nil
+		}
+		alias := repository.NormalizeAlias(args[1])
+		product, err := h.productRepo.GetByShopAndName(shop.ID, alias)
+		if err == nil {
+			err = h.productRepo.DeleteAlias(product.ID, alias)
+		}
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return i18n.T(lang, i18n.MsgAliasNotFound, alias), nil
+			}
+			return "", err
+		}
+		return i18n.T(lang, i18n.MsgAliasRemoved, alias, product.Name), nil
+
+	default:
+		name := normalizeProductName(strings.Join(args, " "))
+		product, err := h.productRepo.GetByShopAndName(shop.ID, name)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return i18n.T(lang, i18n.MsgProductNotFound, name), nil
+			}
+			return "", err
+		}
+		aliases, err := h.productRepo.GetAliases(product.ID)
+		if err != nil {
+			return "", err
+		}
+		if len(aliases) == 0 {
+			return i18n.T(lang, i18n.MsgAliasNone, product.Name, strings.ToLower(product.Name)), nil
+		}
+		names := make([]string, 0, len(aliases))
+		for _, a := range aliases {
+			names = append(names, "• "+a.Alias)
+		}
+		return i18n.T(lang, i18n.MsgAliasList, product.Name, strings.Join(names, "\n")), nil
+	}
+}
+
+// handleFav handles the favorites a shop sells by number: "fav" lists
+// them, "fav add milk" pins one and "fav remove 2" unpins one
+func (h *CommandHandler) handleFav(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
+	if len(args) == 0 {
+		return h.listFavorites(shop, lang)
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) < 2 {
+			return i18n.T(lang, i18n.MsgFavUsage), nil
+		}
+		name := normalizeProductName(strings.Join(args[1:], " "))
+		product, err := h.productRepo.GetByShopAndName(shop.ID, name)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return i18n.T(lang, i18n.MsgProductNotFound, name), nil
+			}
+			return "", err
+		}
+		if err := h.productRepo.AddFavorite(product); err != nil {
+			if errors.Is(err, repository.ErrTooManyFavorites) {
+				return i18n.T(lang, i18n.MsgFavFull, repository.MaxFavorites), nil
+			}
+			return "", err
+		}
+		favorites, err := h.productRepo.GetFavorites(shop.ID)
+		if err != nil {
+			return "", err
+		}
+		n := len(favorites)
+		for i, f := range favorites {
+			if f.ID == product.ID {
+				n = i + 1
+			}
+		}
+		return i18n.T(lang, i18n.MsgFavAdded, product.Name, n, n), nil
+
+	case "remove", "delete":
+		if len(args) < 2 {
+			return i18n.T(lang, i18n.MsgFavUsage), nil
+		}
+		// A favorite goes by its number or its name
+		name := normalizeProductName(strings.Join(args[1:], " "))
+		product, err := h.productRepo.GetByShopAndName(shop.ID, name)
+		if n, convErr := strconv.Atoi(args[1]); convErr == nil && len(args) == 2 {
+			favorites, ferr := h.productRepo.GetFavorites(shop.ID)
+			if ferr != nil {
+				return "", ferr
+			}
+			if n < 1 || n > len(favorites) {
+				return i18n.T(lang, i18n.MsgFavNoNumber, n), nil
+			}
+			product, err = &favorites[n-1], nil
+		}
+		if err == nil {
+			err = h.productRepo.RemoveFavorite(shop.ID, product.ID)
+		}
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return i18n.T(lang, i18n.MsgFavNotFound, name), nil
+			}
+			return "", err
+		}
+		return i18n.T(lang, i18n.MsgFavRemoved, product.Name), nil
+	}
+	return i18n.T(lang, i18n.MsgFavUsage), nil
+}
+
+// listFavorites numbers the shop's favorites for selling with
+// "sell [number] [qty]", marking those sold out, or lists this week's best
+// sellers until it pins any
+func (h *CommandHandler) listFavorites(shop *models.Shop, lang i18n.Language) (string, error) {
+	products, saved, err := h.productRepo.GetQuickSell(shop.ID)
+	if err != nil {
+		return "", err
+	}
+	if len(products) == 0 {
+		return i18n.T(lang, i18n.MsgFavNone), nil
+	}
+
+	lines := make([]string, len(products))
+	for i, p := range products {
+		switch {
+		case !saved:
+			lines[i] = fmt.Sprintf("• %s - KSh %.0f | %s", p.Name, p.SellingPrice, p.StockLabel(p.CurrentStock))
+		case !p.IsActive, p.CurrentStock <= 0 && !shop.AllowNegativeStock:
+			lines[i] = i18n.T(lang, i18n.MsgFavOutOfStock, i+1, p.Name)
+		default:
+			lines[i] = fmt.Sprintf("%d. %s - KSh %.0f | %s", i+1, p.Name, p.SellingPrice, p.StockLabel(p.CurrentStock))
+		}
+	}
+	if !saved {
+		return i18n.T(lang, i18n.MsgFavTopSellers, strings.Join(lines, "\n")), nil
+	}
+	return i18n.T(lang, i18n.MsgFavList, strings.Join(lines, "\n")), nil
+}
+
+// favoriteProduct returns the favorite numbered arg as listed by `fav`, or
+// nil when arg is not the number of one. A sold out favorite is returned
+// as is, for the sale to be refused.
+func (h *CommandHandler) favoriteProduct(shopID uint, arg string) (*models.Product, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 {
+		return nil, nil
+	}
+	products, err := h.productRepo.GetFavorites(shopID)
+	if err != nil || n > len(products) {
+		return nil, err
+	}
+	return &products[n-1], nil
+}
+
 // handleSupplier handles supplier management commands
-func (h *CommandHandler) handleSupplier(shop *models.Shop, args []string) (string, error) {
+func (h *CommandHandler) handleSupplier(phone string, shop *models.Shop, args []string, lang i18n.Language) (string, error) {
 	// Check if Pro plan
 	if shop.Plan == models.PlanFree {
 		return `💎 Supplier Management requires Pro plan!
@@ -1368,7 +2523,7 @@ Example: supplier add Brookside +254700000000`, nil
 	switch args[0] {
 	case "add":
 		if len(args) < 2 {
-			return "❌ Usage: supplier add [name] [phone]\nExample: supplier add Brookside +254700000000", nil
+			return i18n.T(lang, i18n.MsgSupplierAddUsage), nil
 		}
 		name := strings.Title(strings.Join(args[1:], " "))
 		var phone string
@@ -1389,12 +2544,20 @@ Example: supplier add Brookside +254700000000`, nil
 
 	case "view":
 		if len(args) < 2 {
-			return "❌ Usage: supplier view [name]", nil
+			return i18n.T(lang, i18n.MsgSupplierViewUsage), nil
 		}
 		searchName := strings.Join(args[1:], " ")
 		supplier, err := h.supplierRepo.GetByName(shop.ID, searchName)
 		if err != nil {
-			return "❌ Supplier not found.\nUse: supplier to list all suppliers", nil
+			return i18n.T(lang, i18n.MsgSupplierNotFound), nil
+		}
+
+		rating := "⭐ Not rated yet"
+		if ratings := h.supplierRepo.GetRatings(supplier.ID); len(ratings) > 0 {
+			rating = fmt.Sprintf("⭐ %.1f/5 (%d ratings)", h.supplierRepo.GetAverageRating(supplier.ID), len(ratings))
+			if notes := ratings[0].Notes; notes != "" {
+				rating += fmt.Sprintf("\n💬 \"%s\"", notes)
+			}
 		}
 
 		return fmt.Sprintf(`📦 SUPPLIER DETAILS
@@ -1403,23 +2566,190 @@ Example: supplier add Brookside +254700000000`, nil
 📱 %s
 📧 %s
 📍 %s
+%s
 
 Added: %s`,
-			supplier.Name, supplier.Phone, supplier.Email, supplier.Address, supplier.CreatedAt.Format("02 Jan 2006")), nil
+			supplier.Name, supplier.Phone, supplier.Email, supplier.Address, rating, supplier.CreatedAt.Format("02 Jan 2006")), nil
+
+	case "pay":
+		return h.handleSupplierPay(phone, shop, args[1:], lang, false)
+
+	default:
+		return `📦 SUPPLIER COMMANDS:
+
+supplier - List all suppliers
+supplier add [name] [phone] - Add supplier
+supplier view [name] - View details
+supplier pay [name] [amount] [#order] - Pay via M-Pesa
+
+Example: supplier add Brookside +254700000000`, nil
+	}
+}
+
+// handleSupplierPay sends a supplier money over M-Pesa B2C once the owner
+// confirms. A payout settles an unpaid order: the one named with #number,
+// which may be paid in part, or else the one whose balance equals the
+// amount. Payouts only go unattributed when nothing is owed to the
+// supplier. The order is marked paid, partly paid or failed when the B2C
+// result comes back.
+func (h *CommandHandler) handleSupplierPay(phone string, shop *models.Shop, args []string, lang i18n.Language, confirmed bool) (string, error) {
+	var orderNumber uint64
+	if n := len(args); n > 0 && strings.HasPrefix(args[n-1], "#") {
+		number, err := strconv.ParseUint(strings.TrimPrefix(args[n-1], "#"), 10, 64)
+		if err != nil || number == 0 {
+			return i18n.T(lang, i18n.MsgSupplierPayUsage), nil
+		}
+		orderNumber = number
+		args = args[:n-1]
+	}
+	if len(args) < 2 {
+		return i18n.T(lang, i18n.MsgSupplierPayUsage), nil
+	}
+	amount, err := strconv.Atoi(args[len(args)-1])
+	if err != nil || amount <= 0 {
+		return i18n.T(lang, i18n.MsgSupplierPayInvalid), nil
+	}
+
+	if h.mpesaSvc == nil {
+		return i18n.T(lang, i18n.MsgSupplierPayUnavailable), nil
+	}
+	if err := h.mpesaSvc.B2CConfigError(shop.ID); err != nil {
+		return i18n.T(lang, i18n.MsgSupplierPayNeedsB2C, err), nil
+	}
+
+	supplier, err := h.supplierRepo.GetByName(shop.ID, strings.Join(args[:len(args)-1], " "))
+	if err != nil {
+		return i18n.T(lang, i18n.MsgSupplierNotFound), nil
+	}
+	if supplier.Phone == "" {
+		return i18n.T(lang, i18n.MsgSupplierNoPhone, supplier.Name), nil
+	}
+
+	var order *models.Order
+	if h.orderRepo != nil {
+		unpaid, err := h.orderRepo.ListUnpaidBySupplier(shop.ID, supplier.ID)
+		if err != nil {
+			return "", err
+		}
+		if order, err = supplierOrderToPay(unpaid, orderNumber, amount); err != nil {
+			return supplierPayMismatch(lang, supplier, unpaid, order, orderNumber, amount, err), nil
+		}
+	} else if orderNumber > 0 {
+		return i18n.T(lang, i18n.MsgSupplierPayNoOrder, orderNumber, supplier.Name), nil
+	}
+
+	if !confirmed && h.confirmations != nil {
+		action := "supplier pay " + strings.Join(args, " ")
+		if order != nil {
+			action += fmt.Sprintf(" #%d", order.ID)
+		}
+		if err := h.confirmations.Request(phone, action); err != nil {
+			return "", err
+		}
+		if order != nil {
+			return i18n.T(lang, i18n.MsgSupplierPayConfirm, amount, supplier.Name, supplier.Phone,
+				order.ID, order.Outstanding(), action), nil
+		}
+		return i18n.T(lang, i18n.MsgSupplierPayConfirmNoOrder, amount, supplier.Name, supplier.Phone, action), nil
+	}
+
+	// Mark the order before sending so a fast result callback can't be
+	// overwritten once InitiateB2C returns, and so two payouts can't race
+	// for the same balance
+	if order != nil {
+		claimed, err := h.orderRepo.ClaimForPayment(order.ID)
+		if err != nil {
+			return "", err
+		}
+		if !claimed {
+			return i18n.T(lang, i18n.MsgSupplierPayInFlight, order.ID), nil
+		}
+	}
+
+	req := &mpesa.B2CRequest{
+		ShopID:  shop.ID,
+		Phone:   supplier.Phone,
+		Amount:  float64(amount),
+		Remarks: fmt.Sprintf("Payment from %s", shop.Name),
+	}
+	if order != nil {
+		req.OrderID = &order.ID
+		req.Occasion = fmt.Sprintf("Order #%d", order.ID)
+	}
+
+	payout, err := h.mpesaSvc.InitiateB2C(mpesa.WithoutRetry(context.Background()), req)
+	if err != nil {
+		// Payouts that were recorded already marked the order failed
+		if order != nil && payout == nil {
+			if err := h.orderRepo.ReleasePayment(order.ID, order.PaymentStatus); err != nil {
+				log.Printf("❌ Failed to release order %d: %v", order.ID, err)
+			}
+		}
+		if errors.Is(err, mpesa.ErrRateLimited) {
+			return mpesaBusyReply, nil
+		}
+		return i18n.T(lang, i18n.MsgSupplierPayFailed, supplier.Name, err), nil
+	}
+
+	msg := i18n.T(lang, i18n.MsgSupplierPaying, supplier.Name, amount, payout.Phone)
+	if order != nil {
+		msg += i18n.T(lang, i18n.MsgSupplierPayOrder, order.ID, order.TotalAmount, order.Outstanding())
+	}
+	return msg + i18n.T(lang, i18n.MsgSupplierPayPending), nil
+}
+
+var (
+	errNoSupplierOrder    = errors.New("no such unpaid order")
+	errSupplierOverpay    = errors.New("amount is more than the order balance")
+	errNoSupplierOrderFit = errors.New("amount matches no order balance")
+)
+
+// supplierOrderToPay picks the unpaid order a payout settles. A named order
+// can take any amount up to its balance; without one, the amount must match
+// an order's balance exactly. No order is needed when none are unpaid.
+func supplierOrderToPay(unpaid []models.Order, orderNumber uint64, amount int) (*models.Order, error) {
+	if orderNumber > 0 {
+		for i := range unpaid {
+			if uint64(unpaid[i].ID) != orderNumber {
+				continue
+			}
+			if float64(amount) > math.Ceil(unpaid[i].Outstanding()) {
+				return &unpaid[i], errSupplierOverpay
+			}
+			return &unpaid[i], nil
+		}
+		return nil, errNoSupplierOrder
+	}
 
-	default:
-		return `📦 SUPPLIER COMMANDS:
+	if len(unpaid) == 0 {
+		return nil, nil
+	}
+	for i := range unpaid {
+		if math.Round(unpaid[i].Outstanding()) == float64(amount) {
+			return &unpaid[i], nil
+		}
+	}
+	return nil, errNoSupplierOrderFit
+}
 
-supplier - List all suppliers
-supplier add [name] [phone] - Add supplier
-supplier view [name] - View details
+// supplierPayMismatch explains why a payout can't settle an order
+func supplierPayMismatch(lang i18n.Language, supplier *models.Supplier, unpaid []models.Order, order *models.Order, orderNumber uint64, amount int, err error) string {
+	switch err {
+	case errNoSupplierOrder:
+		return i18n.T(lang, i18n.MsgSupplierPayNoOrder, orderNumber, supplier.Name)
+	case errSupplierOverpay:
+		return i18n.T(lang, i18n.MsgSupplierPayTooMuch, order.ID, order.Outstanding())
+	}
 
-Example: supplier add Brookside +254700000000`, nil
+	var sb strings.Builder
+	for _, order := range unpaid {
+		sb.WriteString(fmt.Sprintf("📋 #%d: KSh %.0f\n", order.ID, order.Outstanding()))
 	}
+	return i18n.T(lang, i18n.MsgSupplierPayNoMatch, amount, supplier.Name, sb.String(), supplier.Name, amount)
 }
 
 // handleOrder handles order management commands
-func (h *CommandHandler) handleOrder(shop *models.Shop, args []string) (string, error) {
+func (h *CommandHandler) handleOrder(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
 	// Check if Pro plan
 	if shop.Plan == models.PlanFree {
 		return `📋 Orders require Pro plan!
@@ -1437,20 +2767,20 @@ Reply: upgrade`, nil
 	// Handle view subcommand
 	if len(args) > 0 && args[0] == "view" {
 		if len(args) < 2 {
-			return "❌ Usage: order view [order_id]", nil
+			return i18n.T(lang, i18n.MsgOrderViewUsage), nil
 		}
 		orderID, err := strconv.ParseUint(args[1], 10, 32)
 		if err != nil {
-			return "❌ Invalid order ID", nil
+			return i18n.T(lang, i18n.MsgOrderInvalidID), nil
 		}
 
 		order, err := h.orderRepo.GetByID(uint(orderID))
 		if err != nil {
-			return "❌ Order not found", nil
+			return i18n.T(lang, i18n.MsgOrderNotFound), nil
 		}
 
 		if order.ShopID != shop.ID {
-			return "❌ Order not found", nil
+			return i18n.T(lang, i18n.MsgOrderNotFound), nil
 		}
 
 		statusIcon := "⏳"
@@ -1461,20 +2791,35 @@ Reply: upgrade`, nil
 			statusIcon = "❌"
 		case "shipped":
 			statusIcon = "📦"
+		case models.OrderStatusDraft:
+			statusIcon = "📝"
 		case "pending":
 			statusIcon = "⏳"
 		}
 
+		paymentStatus := order.PaymentStatus
+		if paymentStatus == "" {
+			paymentStatus = models.OrderPaymentUnpaid
+		}
+		if order.AmountPaid > 0 && paymentStatus != models.OrderPaymentPaid {
+			paymentStatus += fmt.Sprintf(" (KSh %.0f paid, KSh %.0f left)", order.AmountPaid, order.Outstanding())
+		}
+
 		return fmt.Sprintf(`📋 ORDER #%d
 
 💰 Total: KSh %.0f
 📦 Status: %s %s
+💳 Payment: %s
 📅 Created: %s
 📝 Notes: %s`,
-			order.ID, order.TotalAmount, order.Status, statusIcon,
+			order.ID, order.TotalAmount, order.Status, statusIcon, paymentStatus,
 			order.CreatedAt.Format("02 Jan 2006 15:04"), order.Notes), nil
 	}
 
+	if len(args) > 0 && (args[0] == "confirm" || args[0] == "cancel") {
+		return h.handleOrderDraft(shop, args, lang)
+	}
+
 	if len(args) < 1 {
 		// List recent orders
 		orders, err := h.orderRepo.GetByShopID(shop.ID)
@@ -1513,20 +2858,64 @@ Create orders via dashboard or API.`, nil
 
 order - Recent orders
 order view [id] - Order details
+order confirm [id] - Send a draft order
+order cancel [id] - Drop a draft order
 
 Manage orders via dashboard.`, nil
 }
 
-// handleUnknown handles unknown commands
-func (h *CommandHandler) handleUnknown(cmd string) string {
-	return fmt.Sprintf(`❓ Unknown command: %s
+// handleOrderDraft confirms or cancels a draft order, such as one created
+// automatically for low stock. Confirming sends it to the supplier.
+func (h *CommandHandler) handleOrderDraft(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
+	if len(args) < 2 {
+		return i18n.T(lang, i18n.MsgOrderDraftUsage, args[0]), nil
+	}
+	orderID, err := strconv.ParseUint(args[1], 10, 32)
+	if err != nil {
+		return i18n.T(lang, i18n.MsgOrderInvalidID), nil
+	}
+
+	order, err := h.orderRepo.GetByID(uint(orderID))
+	if err != nil || order.ShopID != shop.ID {
+		return i18n.T(lang, i18n.MsgOrderNotFound), nil
+	}
+	if order.Status != models.OrderStatusDraft {
+		return i18n.T(lang, i18n.MsgOrderNotDraft, order.ID, order.Status), nil
+	}
+
+	if args[0] == "cancel" {
+		order.Status = models.OrderStatusCancelled
+		if err := h.orderRepo.Update(order); err != nil {
+			return "", err
+		}
+		return i18n.T(lang, i18n.MsgOrderCancelled, order.ID), nil
+	}
+
+	order.Status = models.OrderStatusPending
+	if err := h.orderRepo.Update(order); err != nil {
+		return "", err
+	}
+
+	var items strings.Builder
+	for _, item := range order.Items {
+		items.WriteString(fmt.Sprintf("• %s x%d\n", item.Product.Name, item.Quantity))
+	}
+
+	if h.sendToSupplier == nil || order.Supplier.Phone == "" {
+		return fmt.Sprintf("✅ Order #%d confirmed\n\n%s\nSend it to %s yourself.", order.ID, items.String(), order.Supplier.Name), nil
+	}
+
+	if err := h.sendToSupplier(order.Supplier.Phone, supplierservice.OrderMessage(shop, order)); err != nil {
+		log.Printf("❌ Failed to send order #%d to supplier %s: %v", order.ID, order.Supplier.Name, err)
+		return fmt.Sprintf("✅ Order #%d confirmed\n\n⚠️ Could not reach %s. Send it yourself.", order.ID, order.Supplier.Name), nil
+	}
 
-📝 Available:
-add, sell, stock, price
-remove, delete, report
-profit, low, help
+	return fmt.Sprintf("✅ Order #%d confirmed and sent to %s", order.ID, order.Supplier.Name), nil
+}
 
-Type: help for full list`, cmd)
+// handleUnknown handles unknown commands
+func (h *CommandHandler) handleUnknown(cmd string, lang i18n.Language) string {
+	return i18n.T(lang, i18n.MsgUnknownCommand, cmd)
 }
 
 // Helper functions
@@ -1584,7 +2973,7 @@ func findSimilarProducts(products []models.Product, search string) string {
 // ============================================
 
 // handleMpesa handles M-Pesa commands
-func (h *CommandHandler) handleMpesa(shop *models.Shop, args []string) (string, error) {
+func (h *CommandHandler) handleMpesa(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
 	// Check if M-Pesa is enabled for this shop
 	if shop.Plan == models.PlanFree {
 		return `💎 M-Pesa requires Pro plan!
@@ -1598,23 +2987,24 @@ Upgrade: upgrade`, nil
 	if len(args) < 1 {
 		return `💰 M-PESA COMMANDS:
 
-pay [amount] - Request payment from customer
+pay [amount] [phone] - Send a payment prompt to the customer
+pay [amount] - Get a payment link to share
 status [code] - Check payment status
 
-Example: pay 500`, nil
+Example: mpesa pay 500 0712345678`, nil
 	}
 
 	switch args[0] {
 	case "pay":
 		if len(args) < 2 {
-			return "❌ Usage: mpesa pay [amount]\nExample: mpesa pay 500", nil
+			return i18n.T(lang, i18n.MsgMpesaPayUsage), nil
 		}
 		amount, err := strconv.Atoi(args[1])
 		if err != nil || amount <= 0 {
-			return "❌ Invalid amount", nil
+			return i18n.T(lang, i18n.MsgMpesaInvalidAmount), nil
 		}
 
-		if h.mpesaSvc == nil {
+		if h.mpesaSvc == nil || !h.mpesaSvc.IsConfiguredForShop(shop.ID) {
 			return `⚠️ M-Pesa service not configured.
 
 To enable M-Pesa payments:
@@ -1624,34 +3014,34 @@ To enable M-Pesa payments:
 Contact support for setup assistance.`, nil
 		}
 
+		// Without the customer's number, hand back a link they can pay from
+		if len(args) < 3 {
+			return h.mpesaPaymentLink(shop, float64(amount), lang)
+		}
+
 		req := &mpesa.PaymentRequest{
-			Phone:            shop.Phone,
+			Phone:            strings.Join(args[2:], ""),
 			Amount:           float64(amount),
 			AccountReference: fmt.Sprintf("DUKA%d", shop.ID),
 			Description:      fmt.Sprintf("Payment to %s", shop.Name),
 			ShopID:           shop.ID,
 		}
 
-		payment, stkResp, err := h.mpesaSvc.InitiateSTKPush(context.Background(), req)
+		// Twilio gives up on a slow webhook, so a rate-limited push is left
+		// to the outbox to send again rather than waited out here
+		payment, stkResp, err := h.mpesaSvc.InitiateSTKPush(mpesa.WithoutRetry(context.Background()), req)
+		if errors.Is(err, mpesa.ErrInvalidPhone) {
+			return i18n.T(lang, i18n.MsgMpesaInvalidPhone), nil
+		}
+		if errors.Is(err, mpesa.ErrRateLimited) {
+			return h.queueRateLimitedPush(req), nil
+		}
 		if err != nil {
-			return fmt.Sprintf(`❌ Payment failed: %v
-
-Please try again or contact support.`, err), nil
+			return i18n.T(lang, i18n.MsgMpesaPayFailed, err), nil
 		}
 
 		if payment != nil {
-			return fmt.Sprintf(`📲 STK Push Sent!
-
-Amount: KSh %d
-To: %s
-Reference: %s
-
-💡 Customer will receive a payment prompt on their phone.
-
-Checkout ID: %s
-
-Reply "mpesa status %s" to check payment status.`,
-				amount, shop.Phone, req.AccountReference, payment.CheckoutRequestID, payment.CheckoutRequestID[:8]), nil
+			return stkPushSentReply(payment), nil
 		}
 
 		return fmt.Sprintf(`📲 STK Push Sent!
@@ -1662,50 +3052,186 @@ To: %s
 💡 Customer will receive a payment prompt on their phone.
 
 %s`,
-			amount, shop.Phone, stkResp.CustomerMessage), nil
+			amount, req.Phone, stkResp.CustomerMessage), nil
 
 	case "status":
 		if len(args) < 2 {
-			return "❌ Usage: mpesa status [checkout_id]\nExample: mpesa status wsCOGZY", nil
-		}
-		checkoutID := args[1]
-		if len(checkoutID) < 8 {
-			checkoutID = checkoutID + "xxxxx"
+			return i18n.T(lang, i18n.MsgMpesaStatusUsage), nil
 		}
+		code := strings.ToUpper(args[1])
 
 		if h.mpesaSvc == nil {
 			return "💰 Payment status: Unknown\nNote: Configure M-Pesa API to enable status checks.", nil
 		}
 
-		status, err := h.mpesaSvc.QuerySTKStatus(context.Background(), checkoutID)
+		if mpesa.IsReceiptCode(code) {
+			return h.mpesaReceiptStatus(shop, code, lang), nil
+		}
+
+		status, err := h.mpesaSvc.QuerySTKStatus(mpesa.WithoutRetry(context.Background()), args[1])
+		if errors.Is(err, mpesa.ErrRateLimited) {
+			return mpesaBusyReply, nil
+		}
 		if err != nil {
-			return fmt.Sprintf("❌ Failed to check status: %v", err), nil
+			return i18n.T(lang, i18n.MsgMpesaStatusFailed, err), nil
 		}
 
-		resultCode, _ := strconv.Atoi(status.ResponseCode)
-		if resultCode == 0 {
+		switch status.ResultCode {
+		case "0":
 			return fmt.Sprintf(`✅ Payment Successful!
 
 Checkout ID: %s
-Status: %s
+Status: %s`, args[1], status.ResultDesc), nil
+		case "":
+			return fmt.Sprintf(`⏳ Payment still processing
+
+%s
 
-Thank you for your payment!`, checkoutID, status.ResponseDescription), nil
+Reply "mpesa status %s" to check again.`, status.ResponseDescription, args[1]), nil
+		default:
+			return i18n.T(lang, i18n.MsgMpesaNotCompleted, args[1], status.ResultDesc), nil
 		}
 
-		return fmt.Sprintf(`⏳ Payment Status: Pending
+	default:
+		return i18n.T(lang, i18n.MsgMpesaUnknown), nil
+	}
+}
+
+// mpesaPaymentLink creates a payment link for amount and returns the
+// message to forward to the customer
+func (h *CommandHandler) mpesaPaymentLink(shop *models.Shop, amount float64, lang i18n.Language) (string, error) {
+	link, err := h.mpesaSvc.CreatePaymentLink(shop.ID, amount, fmt.Sprintf("Payment to %s", shop.Name), 0)
+	if err != nil {
+		return i18n.T(lang, i18n.MsgMpesaLinkFailed, err), nil
+	}
+
+	return fmt.Sprintf(`🔗 Payment link for KSh %.0f
 
 %s
 
-Reply with "mpesa status %s" to check again.`,
-			status.CustomerMessage, checkoutID[:8]), nil
+Send this link to your customer. They enter their M-Pesa number and get a payment prompt.
+
+Expires: %s`,
+		amount, h.mpesaSvc.PaymentLinkURL(link), link.ExpiresAt.Format("02 Jan 2006")), nil
+}
+
+// mpesaBusyReply is sent when M-Pesa is still rate limiting us after every
+// retry
+var mpesaBusyReply = "⏳ " + mpesa.RateLimitMessage + "."
+
+// stkPushSentReply tells the shop a payment prompt is on its way
+func stkPushSentReply(payment *models.MpesaPayment) string {
+	return fmt.Sprintf(`📲 STK Push Sent!
+
+Amount: KSh %.0f
+To: %s
+Reference: %s
+
+💡 Customer will receive a payment prompt on their phone.
+
+Checkout ID: %s
+
+Reply "mpesa status %s" to check payment status.`,
+		payment.Amount, payment.Phone, payment.AccountReference, payment.CheckoutRequestID, payment.CheckoutRequestID)
+}
+
+// queueRateLimitedPush queues a push Daraja rate limited for the outbox to
+// send again, falling back to asking the shop to try again
+func (h *CommandHandler) queueRateLimitedPush(req *mpesa.PaymentRequest) string {
+	if h.queueSTKPush == nil {
+		return mpesaBusyReply
+	}
+	data, err := json.Marshal(req)
+	if err == nil {
+		err = h.queueSTKPush(req.ShopID, req.Phone, data)
+	}
+	if err != nil {
+		log.Printf("❌ Failed to queue STK push for shop %d: %v", req.ShopID, err)
+		return mpesaBusyReply
+	}
+	return fmt.Sprintf("⏳ M-Pesa is busy right now. The KSh %.0f prompt to %s will be sent as soon as it is free, and you'll get a message when it is.",
+		req.Amount, req.Phone)
+}
+
+// SendQueuedSTKPush is the outbox sender for pushes queued while Daraja
+// was rate limiting them. Still rate limited, it fails so the outbox tries
+// again later, telling the shop when it gives up; any other outcome is
+// final and sent to the shop.
+func (h *CommandHandler) SendQueuedSTKPush(m *models.OutboxMessage) error {
+	var req mpesa.PaymentRequest
+	if err := json.Unmarshal([]byte(m.Body), &req); err != nil {
+		log.Printf("❌ Queued STK push %d is unreadable: %v", m.ID, err)
+		return nil
+	}
+	shop, err := h.shopRepo.GetByID(req.ShopID)
+	if err != nil {
+		return err
+	}
+	if h.mpesaSvc == nil {
+		return errors.New("M-Pesa service not configured")
+	}
 
+	payment, _, err := h.mpesaSvc.InitiateSTKPush(mpesa.WithoutRetry(context.Background()), &req)
+	var reply string
+	switch {
+	case errors.Is(err, mpesa.ErrRateLimited):
+		if m.Attempts+1 < m.MaxAttempts {
+			return err
+		}
+		reply = fmt.Sprintf("❌ M-Pesa stayed busy, so the KSh %.0f prompt to %s was not sent.\n\nSend it again with: mpesa pay %.0f %s",
+			req.Amount, req.Phone, req.Amount, req.Phone)
+	case err != nil:
+		// Sending it again can't help
+		reply = i18n.T(i18n.Of(shop.Language), i18n.MsgMpesaPayFailed, err)
+		err = nil
 	default:
-		return "❌ Unknown M-Pesa command. Use: mpesa pay [amount]", nil
+		reply = stkPushSentReply(payment)
+	}
+	if h.notifyShop != nil {
+		if err := h.notifyShop(shop.Phone, reply); err != nil {
+			log.Printf("⚠️ Failed to tell shop %d about a queued STK push: %v", shop.ID, err)
+		}
+	}
+	return err
+}
+
+// mpesaReceiptStatus reports what M-Pesa said about a receipt code and asks
+// again. Results arrive asynchronously, so a fresh code needs a second check.
+func (h *CommandHandler) mpesaReceiptStatus(shop *models.Shop, code string, lang i18n.Language) string {
+	tx, err := h.mpesaSvc.TransactionStatusByReceipt(mpesa.WithoutRetry(context.Background()), shop.ID, code)
+	if errors.Is(err, mpesa.ErrStatusNotConfigured) {
+		return "⚠️ M-Pesa status checks need your own paybill or till credentials with an initiator.\nAdd them in the dashboard."
+	}
+	if errors.Is(err, mpesa.ErrTransactionNotFound) {
+		return i18n.T(lang, i18n.MsgMpesaNotOurs, code)
+	}
+	if errors.Is(err, mpesa.ErrRateLimited) {
+		return mpesaBusyReply
 	}
+	if err != nil {
+		return i18n.T(lang, i18n.MsgMpesaStatusFailed, err)
+	}
+
+	if tx.StatusCheckedAt == nil {
+		return fmt.Sprintf(`🔍 Checking %s with M-Pesa...
+
+Reply "mpesa status %s" in a minute for the result.`, code, code)
+	}
+
+	if tx.StatusResult == "" {
+		return i18n.T(lang, i18n.MsgMpesaUnconfirmed, code, tx.StatusResultDesc)
+	}
+
+	return fmt.Sprintf(`💰 M-Pesa %s
+
+Status: %s
+Amount: KSh %.0f
+Phone: %s
+Checked: %s`, code, tx.StatusResult, tx.Amount, tx.Phone, tx.StatusCheckedAt.Format("02 Jan 15:04"))
 }
 
 // handleStaff handles staff management commands
-func (h *CommandHandler) handleStaff(shop *models.Shop, args []string) (string, error) {
+func (h *CommandHandler) handleStaff(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
 	// Check if staff feature is available
 	if shop.Plan == models.PlanFree {
 		return `💎 Staff Accounts require Pro plan!
@@ -1767,11 +3293,7 @@ Example: staff add John +254700000001 cashier`, nil
 
 	case "add":
 		if len(args) < 4 {
-			return `❌ Usage: staff add [name] [phone] [role]
-
-Example: staff add John +254700000001 cashier
-
-Roles: manager, cashier, stock clerk`, nil
+			return i18n.T(lang, i18n.MsgStaffAddUsage), nil
 		}
 		name := strings.Title(args[1])
 		phone := args[2]
@@ -1782,13 +3304,23 @@ Roles: manager, cashier, stock clerk`, nil
 			"manager": true, "cashier": true, "stock clerk": true,
 		}
 		if !validRoles[role] {
-			return "❌ Invalid role.\nValid: manager, cashier, stock clerk", nil
+			return i18n.T(lang, i18n.MsgStaffInvalidRole), nil
 		}
 
 		// Check if staff with phone already exists
 		existing, _ := h.staffRepo.GetByPhone(shop.ID, phone)
 		if existing != nil {
-			return fmt.Sprintf("❌ Staff with phone %s already exists!", phone), nil
+			return i18n.T(lang, i18n.MsgStaffExists, phone), nil
+		}
+
+		// Enforce the plan's staff limit
+		count, err := h.staffRepo.CountByShop(shop.ID)
+		if err != nil {
+			return "", err
+		}
+		if limits := models.LimitsFor(shop.Plan); !limits.CanAddStaff(count, 1) {
+			return i18n.T(lang, i18n.MsgStaffLimitReached,
+				shop.Plan.Name(), limits.MaxStaff, models.NextPlan(shop.Plan).Name()), nil
 		}
 
 		// Generate PIN
@@ -1817,12 +3349,12 @@ Share PIN with staff member.`, name, phone, role, pin), nil
 
 	case "remove", "delete":
 		if len(args) < 2 {
-			return "❌ Usage: staff remove [phone]", nil
+			return i18n.T(lang, i18n.MsgStaffRemoveUsage), nil
 		}
 		phone := args[1]
 		staff, err := h.staffRepo.GetByPhone(shop.ID, phone)
 		if err != nil {
-			return "❌ Staff not found with that phone.", nil
+			return i18n.T(lang, i18n.MsgStaffNotFound), nil
 		}
 		if err := h.staffRepo.Delete(staff.ID); err != nil {
 			return "", err
@@ -1831,12 +3363,12 @@ Share PIN with staff member.`, name, phone, role, pin), nil
 
 	case "active", "activate", "deactivate":
 		if len(args) < 2 {
-			return "❌ Usage: staff active [phone]", nil
+			return i18n.T(lang, i18n.MsgStaffActiveUsage), nil
 		}
 		phone := args[1]
 		staff, err := h.staffRepo.GetByPhone(shop.ID, phone)
 		if err != nil {
-			return "❌ Staff not found with that phone.", nil
+			return i18n.T(lang, i18n.MsgStaffNotFound), nil
 		}
 		// Toggle active status
 		staff.IsActive = !staff.IsActive
@@ -1850,7 +3382,7 @@ Share PIN with staff member.`, name, phone, role, pin), nil
 		return fmt.Sprintf("✅ Staff %s: %s", status, staff.Name), nil
 
 	default:
-		return "❌ Unknown staff command.\nUse: staff, staff add, staff remove, staff active", nil
+		return i18n.T(lang, i18n.MsgStaffUnknown), nil
 	}
 }
 
@@ -1860,7 +3392,7 @@ func generateStaffPIN() string {
 }
 
 // handleShop handles multi-shop commands
-func (h *CommandHandler) handleShop(shop *models.Shop, args []string) (string, error) {
+func (h *CommandHandler) handleShop(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
 	if len(args) < 1 {
 		return `🏪 SHOP COMMANDS:
 
@@ -1904,11 +3436,11 @@ Note: Multiple shops require Pro plan.`, nil
 
 	case "switch":
 		if len(args) < 2 {
-			return "❌ Usage: shop switch [shop number]\nExample: shop switch 2", nil
+			return i18n.T(lang, i18n.MsgShopSwitchUsage), nil
 		}
 		shopNum, err := strconv.Atoi(args[1])
 		if err != nil || shopNum < 1 {
-			return "❌ Invalid shop number.\nExample: shop switch 2", nil
+			return i18n.T(lang, i18n.MsgShopInvalidNumber), nil
 		}
 
 		// If account repo is set, try to switch
@@ -1919,7 +3451,7 @@ Note: Multiple shops require Pro plan.`, nil
 				return fmt.Sprintf("🏪 Switched to: %s\n\nUse this shop's inventory for all commands.\n\nReply: shop switch [number] to change again.", targetShop.Name), nil
 			}
 		}
-		return "❌ Unable to switch shops.\n\nMulti-shop requires Pro plan.\nReply: upgrade", nil
+		return i18n.T(lang, i18n.MsgShopSwitchFailed), nil
 
 	case "add":
 		if shop.Plan == models.PlanFree {
@@ -1931,7 +3463,7 @@ Required: Pro (KSh 500/month)
 Reply: upgrade`, nil
 		}
 		if len(args) < 2 {
-			return "❌ Usage: shop add [name]\nExample: shop add Mombasa Branch", nil
+			return i18n.T(lang, i18n.MsgShopAddUsage), nil
 		}
 		newShopName := strings.Join(args[1:], " ")
 		return fmt.Sprintf(`🏪 NEW SHOP CREATED!
@@ -1946,7 +3478,7 @@ Note: Shop activation pending.`, newShopName, shop.OwnerName), nil
 
 	case "name":
 		if len(args) < 2 {
-			return "❌ Usage: shop name [new name]\nExample: shop name My New Shop", nil
+			return i18n.T(lang, i18n.MsgShopNameUsage), nil
 		}
 		newName := strings.Join(args[1:], " ")
 		shop.Name = newName
@@ -1968,34 +3500,35 @@ Reply: shop list to see all shops`, shop.Name, shop.Phone, shop.Plan, activeStat
 }
 
 // handleUpgrade handles plan upgrade
-func (h *CommandHandler) handleUpgrade(shop *models.Shop) (string, error) {
+func (h *CommandHandler) handleUpgrade(shop *models.Shop, lang i18n.Language) (string, error) {
 	if shop.Plan == models.PlanBusiness {
 		return "🎉 You're on the Business plan - the highest tier! Nothing to upgrade.", nil
 	}
 
-	return `💎 UPGRADE TO PRO:
+	pro := models.LimitsFor(models.PlanPro)
+	return fmt.Sprintf(`💎 UPGRADE TO PRO:
 
 📱 Features:
-• Unlimited products
+• %s products
 • M-Pesa payments
-• Multiple shops (up to 5)
-• Staff accounts (up to 3)
+• Multiple shops (up to %d)
+• Staff accounts (up to %d)
 • Advanced analytics
 
 💰 Price: KSh 500/month
 
 Reply: pro to upgrade
 
-Or visit: https://dukapos.io/upgrade`, nil
+Or visit: https://dukapos.io/upgrade`, formatLimit(pro.MaxProducts), pro.MaxShops, pro.MaxStaff), nil
 }
 
 // handlePlan handles plan info
-func (h *CommandHandler) handlePlan(shop *models.Shop) (string, error) {
+func (h *CommandHandler) handlePlan(shop *models.Shop, lang i18n.Language) (string, error) {
 	info := getPlanInfo(shop.Plan)
 
 	msg := fmt.Sprintf(`💎 YOUR PLAN: %s
 
-🛒 Shops: %d
+🛒 Shops: %s
 📦 Products: %s
 👥 Staff: %s
 💰 M-Pesa: %s
@@ -2003,7 +3536,7 @@ func (h *CommandHandler) handlePlan(shop *models.Shop) (string, error) {
 
 %s`,
 		info["name"],
-		info["shops"].(int),
+		info["shops"].(string),
 		info["products"].(string),
 		info["staff"].(string),
 		info["mpesa"].(string),
@@ -2021,40 +3554,38 @@ func activeStatus(active bool) string {
 }
 
 func getPlanInfo(plan models.PlanType) map[string]interface{} {
+	limits := models.LimitsFor(plan)
+	info := map[string]interface{}{
+		"name":     plan.Name(),
+		"shops":    formatLimit(limits.MaxShops),
+		"products": formatLimit(limits.MaxProducts),
+		"staff":    formatLimit(limits.MaxStaff),
+	}
+
 	switch plan {
-	case models.PlanFree:
-		return map[string]interface{}{
-			"name":      "Free",
-			"shops":     1,
-			"products":  "50",
-			"staff":     "0",
-			"mpesa":     "❌",
-			"analytics": "Basic",
-			"cta":       "Reply: upgrade to go Pro!",
-		}
 	case models.PlanPro:
-		return map[string]interface{}{
-			"name":      "Pro",
-			"shops":     5,
-			"products":  "Unlimited",
-			"staff":     "3",
-			"mpesa":     "✅",
-			"analytics": "Advanced",
-			"cta":       "Reply: business for Enterprise",
-		}
+		info["mpesa"] = "✅"
+		info["analytics"] = "Advanced"
+		info["cta"] = "Reply: business for Enterprise"
 	case models.PlanBusiness:
-		return map[string]interface{}{
-			"name":      "Business",
-			"shops":     50,
-			"products":  "Unlimited",
-			"staff":     "Unlimited",
-			"mpesa":     "✅",
-			"analytics": "Advanced + AI",
-			"cta":       "🎉 You're maxed out!",
-		}
+		info["mpesa"] = "✅"
+		info["analytics"] = "Advanced + AI"
+		info["cta"] = "🎉 You're maxed out!"
 	default:
-		return getPlanInfo(models.PlanFree)
+		info["name"] = models.PlanFree.Name()
+		info["mpesa"] = "❌"
+		info["analytics"] = "Basic"
+		info["cta"] = "Reply: upgrade to go Pro!"
+	}
+	return info
+}
+
+// formatLimit formats a plan limit, spelling out unlimited ones
+func formatLimit(limit int) string {
+	if limit == models.Unlimited {
+		return "Unlimited"
 	}
+	return strconv.Itoa(limit)
 }
 
 // ============================================
@@ -2062,7 +3593,7 @@ func getPlanInfo(plan models.PlanType) map[string]interface{} {
 // ============================================
 
 // handlePredict handles AI predictions
-func (h *CommandHandler) handlePredict(shop *models.Shop, args []string) (string, error) {
+func (h *CommandHandler) handlePredict(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
 	// Check if Business plan (required for AI)
 	if shop.Plan != models.PlanBusiness {
 		return `🤖 AI PREDICTIONS require Business plan!
@@ -2226,7 +3757,7 @@ Keep recording sales to build prediction data.`, nil
 		return sb.String(), nil
 
 	default:
-		return "❌ Unknown predict command. Use: predict stock, predict trends, or predict restock", nil
+		return i18n.T(lang, i18n.MsgPredictUnknown), nil
 	}
 }
 
@@ -2244,8 +3775,11 @@ func formatTopProducts(products []ai.ProductSummary) string {
 	return sb.String()
 }
 
+// qrImagePixels is the size of QR codes sent as WhatsApp images
+const qrImagePixels = 512
+
 // handleQR handles QR payment commands
-func (h *CommandHandler) handleQR(shop *models.Shop, args []string) (string, error) {
+func (h *CommandHandler) handleQR(phone string, shop *models.Shop, args []string, lang i18n.Language) (string, error) {
 	if len(args) < 1 {
 		return `📱 QR PAYMENTS:
 
@@ -2259,12 +3793,12 @@ Note: QR payments require Business plan.`, nil
 	switch args[0] {
 	case "generate":
 		if len(args) < 2 {
-			return "❌ Usage: qr generate [amount]\nExample: qr generate 500", nil
+			return i18n.T(lang, i18n.MsgQRUsage), nil
 		}
 		amountStr := args[1]
 		amount, err := strconv.Atoi(amountStr)
 		if err != nil || amount <= 0 {
-			return "❌ Invalid amount. Use a valid number.", nil
+			return i18n.T(lang, i18n.MsgQRInvalidAmount), nil
 		}
 
 		if h.qrSvc == nil {
@@ -2286,23 +3820,28 @@ Contact support for setup.`, nil
 
 		resp, err := h.qrSvc.GenerateDynamicQR(context.Background(), req)
 		if err != nil {
-			return fmt.Sprintf("❌ Failed to generate QR: %v\n\nPlease try again.", err), nil
+			return i18n.T(lang, i18n.MsgQRFailed, err), nil
 		}
 
 		expiresIn := resp.ExpiresAt.Sub(time.Now()).Round(time.Minute)
+		qrLine := "📲 QR Code: " + resp.QRCode
+		caption := fmt.Sprintf("📱 Pay KSh %d to %s (%s)", amount, shop.Name, resp.Reference)
+		if h.sendQRImage(phone, caption, resp.QRCode) {
+			qrLine = "📲 QR code sent as an image."
+		}
 		return fmt.Sprintf(`📱 QR CODE READY!
 
 Amount: KSh %d
 Shop: %s
 Reference: %s
 
-📲 QR Code: %s
+%s
 
 ⏰ Expires in: %d minutes
 
 💡 Customer scans to pay via M-Pesa.
 
-Show this QR to your customer.`, amount, shop.Name, resp.Reference, resp.QRCode, int(expiresIn.Minutes())), nil
+Show this QR to your customer.`, amount, shop.Name, resp.Reference, qrLine, int(expiresIn.Minutes())), nil
 
 	case "static":
 		if h.qrSvc == nil {
@@ -2322,26 +3861,328 @@ Contact support for setup.`, nil
 
 		resp, err := h.qrSvc.GenerateStaticQR(req)
 		if err != nil {
-			return fmt.Sprintf("❌ Failed to generate static QR: %v", err), nil
+			return i18n.T(lang, i18n.MsgQRStaticFailed, err), nil
 		}
 
+		qrLine := "📲 QR Code: " + resp.QRCode
+		if h.sendQRImage(phone, fmt.Sprintf("🏪 %s - scan to pay", shop.Name), resp.QRCode) {
+			qrLine = "📲 QR code sent as an image."
+		}
 		return fmt.Sprintf(`🏪 SHOP STATIC QR
 
 Shop: %s
 ID: %d
 
-📲 QR Code: %s
+%s
 
 💡 Customers can scan to pay any amount.
-Print and display at your shop!`, shop.Name, shop.ID, resp.QRCode), nil
+Print and display at your shop!`, shop.Name, shop.ID, qrLine), nil
+
+	default:
+		return i18n.T(lang, i18n.MsgQRUnknown), nil
+	}
+}
+
+// sendQRImage renders a QR payload as a PNG and sends it to phone,
+// reporting whether it went out
+func (h *CommandHandler) sendQRImage(phone, caption, payload string) bool {
+	if h.mediaHost == nil || h.sendMedia == nil {
+		return false
+	}
+	png, err := qr.GenerateQRImage(payload, qrImagePixels)
+	if err != nil {
+		log.Printf("⚠️ Failed to render QR image: %v", err)
+		return false
+	}
+	return h.sendAttachment(phone, caption, png, "png")
+}
+
+// sendAttachment hosts data and sends it to phone as a WhatsApp
+// attachment, reporting whether it went out
+func (h *CommandHandler) sendAttachment(phone, caption string, data []byte, ext string) bool {
+	url, err := h.mediaHost.Put(data, ext)
+	if err != nil {
+		log.Printf("⚠️ Failed to host WhatsApp media: %v", err)
+		return false
+	}
+	if err := h.sendMedia(phone, caption, url); err != nil {
+		log.Printf("⚠️ Failed to send WhatsApp media to %s: %v", phone, err)
+		return false
+	}
+	return true
+}
+
+// handleReceipt sends a sale's receipt as a PDF, for shops without a
+// printer. With no sale ID it sends the latest sale's.
+func (h *CommandHandler) handleReceipt(phone string, shop *models.Shop, args []string, lang i18n.Language) (string, error) {
+	if h.mediaHost == nil || h.sendMedia == nil {
+		return "⚠️ Sending receipts is not configured.\nContact support for setup.", nil
+	}
+
+	var sale *models.Sale
+	if len(args) > 0 {
+		id, err := strconv.ParseUint(strings.TrimPrefix(args[0], "#"), 10, 64)
+		if err != nil || id == 0 {
+			return i18n.T(lang, i18n.MsgReceiptUsage), nil
+		}
+		sale, err = h.saleRepo.GetByID(uint(id))
+		if err != nil || sale.ShopID != shop.ID {
+			return i18n.T(lang, i18n.MsgReceiptSaleNotFound, id), nil
+		}
+	} else {
+		sales, err := h.saleRepo.GetByShopID(shop.ID, 1)
+		if err != nil {
+			return "", err
+		}
+		if len(sales) == 0 {
+			return "📭 No sales yet.", nil
+		}
+		sale = &sales[0]
+	}
+
+	data := export.ReceiptData{Shop: *shop, Sale: *sale}
+	if h.receiptLinker != nil {
+		data.URL = h.receiptLinker.URL(sale.ID)
+		if png, err := qr.GenerateQRImage(data.URL, 256); err == nil {
+			data.QRCode = png
+		}
+	}
+	pdf, err := (&export.ReceiptExporter{}).ExportPDF(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to render receipt: %w", err)
+	}
+
+	caption := fmt.Sprintf("🧾 Receipt #%d - %s", sale.ID, shop.Name)
+	if !h.sendAttachment(phone, caption, pdf, "pdf") {
+		return i18n.T(lang, i18n.MsgReceiptSendFailed), nil
+	}
+	return fmt.Sprintf("🧾 Receipt #%d sent.", sale.ID), nil
+}
+
+// handleZReport shows the end-of-day Z-report for the open business day,
+// a past one by its Z number, or a day's by its date (for shops that never
+// close, its sales); `zreport close [counted]` closes the day so later
+// sales count toward the next
+func (h *CommandHandler) handleZReport(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
+	if h.zreports == nil {
+		return "⚠️ Z-reports are not available.", nil
+	}
+
+	var report *zreport.Report
+	var err error
+	switch {
+	case len(args) > 0 && strings.EqualFold(args[0], "close"):
+		var counted *float64
+		if len(args) > 1 {
+			amount, err := strconv.ParseFloat(args[1], 64)
+			if err != nil || amount < 0 {
+				return i18n.T(lang, i18n.MsgZReportCloseUsage), nil
+			}
+			counted = &amount
+		}
+		report, err = h.zreports.Build(shop, time.Now(), 0, counted)
+		if err != nil {
+			return "", err
+		}
+		if err := h.zreports.Close(report, shop.OwnerName); err != nil {
+			return "", err
+		}
+	case len(args) > 0 && (strings.EqualFold(args[0], "yesterday") || strings.Count(args[0], "-") == 2):
+		now := time.Now()
+		date := now.AddDate(0, 0, -1)
+		if !strings.EqualFold(args[0], "yesterday") {
+			date, err = time.Parse("2006-01-02", args[0])
+			if err != nil || date.After(now) {
+				return i18n.T(lang, i18n.MsgZReportDateUsage), nil
+			}
+		}
+		report, err = h.zreports.ForDate(shop, date, now)
+		if err != nil {
+			return "", err
+		}
+	case len(args) > 0:
+		number, convErr := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+		if convErr != nil || number <= 0 {
+			return i18n.T(lang, i18n.MsgZReportUsage), nil
+		}
+		report, err = h.zreports.Get(shop, number)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return i18n.T(lang, i18n.MsgZReportNotFound, number), nil
+		}
+		if err != nil {
+			return "", err
+		}
+	default:
+		report, err = h.zreports.Build(shop, time.Now(), 0, nil)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	text := printer.New(&printer.PrinterConfig{Width: zReportChatWidth}).FormatZReport(report)
+	reply := "```\n" + text + "```"
+	if !report.Closed && report.BusinessDate.Equal(time.Now().Truncate(24*time.Hour)) {
+		reply += "\n\nSend *zreport close* to close the day."
+	}
+	return reply, nil
+}
 
+// handleExpense records and lists the shop's expenses:
+//
+//	expense                                 today's and this month's expenses
+//	expense add [amount] [category] [note]  record an expense
+//	expense monthly [amount] [category]     record one now and every month
+//	expense recurring                       list recurring expenses
+//	expense stop [id]                       stop a recurring expense
+func (h *CommandHandler) handleExpense(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
+	if h.expenseRepo == nil {
+		return "⚠️ Expense tracking is not available.", nil
+	}
+	usage := i18n.T(lang, i18n.MsgExpenseUsage)
+
+	if len(args) == 0 {
+		return h.expenseSummary(shop)
+	}
+	switch action := args[0]; {
+	case action == "add" || models.ValidExpenseFrequency(action):
+		if len(args) < 3 {
+			return usage, nil
+		}
+		amount, err := strconv.ParseFloat(strings.ReplaceAll(args[1], ",", ""), 64)
+		if err != nil || amount <= 0 {
+			return usage, nil
+		}
+		category := args[2]
+		if len(category) > 50 {
+			category = category[:50]
+		}
+		note := strings.Join(args[3:], " ")
+		if len(note) > 255 {
+			note = note[:255]
+		}
+		expense := &models.Expense{ShopID: shop.ID, Amount: amount, Category: category, Note: note, SpentAt: time.Now()}
+		if action == "add" {
+			if err := h.expenseRepo.Create(expense); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("💸 Expense recorded: KSh %.0f on %s\n\nSend *profit* to see today's net profit.", amount, category), nil
+		}
+		recurring, err := h.expenseRepo.CreateRecurring(expense, action)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("💸 Expense recorded: KSh %.0f on %s, repeating %s (#%d).\nNext: %s\n\nSend *expense stop %d* to stop it.",
+			amount, category, action, recurring.ID, recurring.NextDueAt.Format("Jan 2, 2006"), recurring.ID), nil
+	case action == "recurring":
+		recurring, err := h.expenseRepo.ListRecurring(shop.ID)
+		if err != nil {
+			return "", err
+		}
+		if len(recurring) == 0 {
+			return "📭 No recurring expenses.\nExample: expense monthly 15000 rent", nil
+		}
+		var sb strings.Builder
+		sb.WriteString("🔁 RECURRING EXPENSES\n\n")
+		for _, r := range recurring {
+			sb.WriteString(fmt.Sprintf("#%d %s: KSh %.0f %s, next %s\n", r.ID, r.Category, r.Amount, r.Frequency, r.NextDueAt.Format("Jan 2")))
+		}
+		return sb.String(), nil
+	case action == "stop":
+		if len(args) < 2 {
+			return i18n.T(lang, i18n.MsgExpenseStopUsage), nil
+		}
+		id, err := strconv.ParseUint(strings.TrimPrefix(args[1], "#"), 10, 32)
+		if err != nil {
+			return i18n.T(lang, i18n.MsgExpenseStopUsage), nil
+		}
+		if err := h.expenseRepo.StopRecurring(shop.ID, uint(id)); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return i18n.T(lang, i18n.MsgExpenseRecurringNotFound, id), nil
+			}
+			return "", err
+		}
+		return fmt.Sprintf("✅ Recurring expense #%d stopped.", id), nil
 	default:
-		return "❌ Unknown qr command. Use: qr generate [amount] or qr static", nil
+		return usage, nil
+	}
+}
+
+// expenseSummary lists today's expenses and this month's totals by category
+func (h *CommandHandler) expenseSummary(shop *models.Shop) (string, error) {
+	now := time.Now()
+	today := now.Truncate(24 * time.Hour)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+
+	expenses, err := h.expenseRepo.List(shop.ID, today, today.Add(24*time.Hour))
+	if err != nil {
+		return "", err
+	}
+	byCategory, err := h.expenseRepo.TotalsByCategory(shop.ID, month, month.AddDate(0, 1, 0))
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("💸 TODAY'S EXPENSES\n\n")
+	var todayTotal float64
+	for _, e := range expenses {
+		todayTotal += e.Amount
+		line := fmt.Sprintf("• %s: KSh %.0f", e.Category, e.Amount)
+		if e.Note != "" {
+			line += " (" + e.Note + ")"
+		}
+		sb.WriteString(line + "\n")
+	}
+	if len(expenses) == 0 {
+		sb.WriteString("None yet.\n")
+	}
+	sb.WriteString(fmt.Sprintf("Total: KSh %.0f\n\n📅 %s\n", todayTotal, strings.ToUpper(now.Format("January"))))
+	var monthTotal float64
+	for _, c := range byCategory {
+		monthTotal += c.Total
+		sb.WriteString(fmt.Sprintf("• %s: KSh %.0f\n", c.Category, c.Total))
+	}
+	sb.WriteString(fmt.Sprintf("Total: KSh %.0f\n\nAdd one: expense add [amount] [category] [note]", monthTotal))
+	return sb.String(), nil
+}
+
+// handleCatalog sends a price list PDF of the products in stock to pass on
+// to customers, optionally for one category, with a link to share it
+func (h *CommandHandler) handleCatalog(phone string, shop *models.Shop, args []string, lang i18n.Language) (string, error) {
+	if h.mediaHost == nil || h.sendMedia == nil {
+		return "⚠️ Sending catalogs is not configured.\nContact support for setup.", nil
+	}
+
+	products, err := h.productRepo.GetByShopID(shop.ID)
+	if err != nil {
+		return "", err
+	}
+	opts := export.CatalogOptions{Category: strings.Join(args, " "), InStockOnly: true}
+	count := len(export.CatalogProducts(products, opts))
+	if count == 0 {
+		if opts.Category != "" {
+			return fmt.Sprintf("📭 No products in stock in category '%s'.", opts.Category), nil
+		}
+		return "📭 No products in stock to list.", nil
 	}
+
+	pdf, err := (&export.CatalogExporter{}).ExportPDF(export.CatalogData{Shop: *shop, Products: products, Options: opts})
+	if err != nil {
+		return "", fmt.Errorf("failed to render catalog: %w", err)
+	}
+	url, err := h.mediaHost.Put(pdf, "pdf")
+	if err != nil {
+		log.Printf("⚠️ Failed to host catalog: %v", err)
+		return i18n.T(lang, i18n.MsgCatalogFailed), nil
+	}
+	if err := h.sendMedia(phone, "📒 Price list - "+shop.Name, url); err != nil {
+		log.Printf("⚠️ Failed to send catalog to %s: %v", phone, err)
+	}
+	return fmt.Sprintf("📒 Price list with %d products:\n%s\n\nForward the PDF to customers; the link works for the next hour.", count, url), nil
 }
 
 // handleLoyalty handles loyalty program commands
-func (h *CommandHandler) handleLoyalty(shop *models.Shop, args []string) (string, error) {
+func (h *CommandHandler) handleLoyalty(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
 	if shop.Plan != models.PlanBusiness {
 		return fmt.Sprintf(`🎁 LOYALTY PROGRAM requires Business plan!
 
@@ -2393,12 +4234,12 @@ Example: loyalty add +254700000001 John`, nil
 	switch args[0] {
 	case "points":
 		if len(args) < 2 {
-			return "❌ Usage: loyalty points [phone]", nil
+			return i18n.T(lang, i18n.MsgLoyaltyPointsUsage), nil
 		}
 		phone := args[1]
 		customer, err := h.customerRepo.GetByPhone(shop.ID, phone)
 		if err != nil {
-			return "❌ Customer not found.\nUse: loyalty add [phone] [name] to add", nil
+			return i18n.T(lang, i18n.MsgLoyaltyCustomerNotFound), nil
 		}
 		pointsValue := float64(customer.LoyaltyPoints) / 10 // 10 points = KSh 1
 		return fmt.Sprintf(`🎁 LOYALTY POINTS
@@ -2414,9 +4255,7 @@ Earn 1 point per KSh spent!`, customer.Phone, customer.LoyaltyPoints, pointsValu
 
 	case "add":
 		if len(args) < 3 {
-			return `❌ Usage: loyalty add [phone] [name]
-
-Example: loyalty add +254700000001 John Doe`, nil
+			return i18n.T(lang, i18n.MsgLoyaltyAddUsage), nil
 		}
 		phone := args[1]
 		name := strings.Title(args[2])
@@ -2424,7 +4263,7 @@ Example: loyalty add +254700000001 John Doe`, nil
 		// Check if customer already exists
 		existing, _ := h.customerRepo.GetByPhone(shop.ID, phone)
 		if existing != nil {
-			return "❌ Customer with this phone already exists!", nil
+			return i18n.T(lang, i18n.MsgLoyaltyCustomerExists), nil
 		}
 
 		customer := &models.Customer{
@@ -2484,21 +4323,21 @@ Redeem: loyalty redeem [phone] [points]`, nil
 
 	case "redeem":
 		if len(args) < 3 {
-			return "❌ Usage: loyalty redeem [phone] [points]", nil
+			return i18n.T(lang, i18n.MsgLoyaltyRedeemUsage), nil
 		}
 		phone := args[1]
 		points, err := strconv.Atoi(args[2])
 		if err != nil || points < 10 {
-			return "❌ Invalid points (minimum 10)", nil
+			return i18n.T(lang, i18n.MsgLoyaltyInvalidPoints), nil
 		}
 
 		customer, err := h.customerRepo.GetByPhone(shop.ID, phone)
 		if err != nil {
-			return "❌ Customer not found", nil
+			return i18n.T(lang, i18n.MsgLoyaltyCustomerNotFound), nil
 		}
 
 		if customer.LoyaltyPoints < points {
-			return fmt.Sprintf("❌ Not enough points!\nAvailable: %d points", customer.LoyaltyPoints), nil
+			return i18n.T(lang, i18n.MsgLoyaltyNotEnoughPoints, customer.LoyaltyPoints), nil
 		}
 
 		if err := h.customerRepo.DeductPoints(customer.ID, points); err != nil {
@@ -2515,20 +4354,12 @@ Redeem: loyalty redeem [phone] [points]`, nil
 Remaining: %d points`, customer.Phone, points, value, customer.LoyaltyPoints-points), nil
 
 	default:
-		return `❌ Unknown loyalty command.
-
-Commands:
-loyalty - List customers
-loyalty points [phone] - Check points
-loyalty add [phone] [name] - Add customer
-loyalty rewards - View rewards
-loyalty tiers - View tiers
-loyalty redeem [phone] [points] - Redeem points`, nil
+		return i18n.T(lang, i18n.MsgLoyaltyUnknown), nil
 	}
 }
 
 // handleAPI handles API access commands
-func (h *CommandHandler) handleAPI(shop *models.Shop, args []string) (string, error) {
+func (h *CommandHandler) handleAPI(shop *models.Shop, args []string, lang i18n.Language) (string, error) {
 	if shop.Plan != models.PlanBusiness {
 		return `🔗 API ACCESS requires Business plan!
 
@@ -2557,7 +4388,7 @@ Note: API requires configuration.`, nil
 	switch args[0] {
 	case "key":
 		if len(args) < 2 {
-			return "❌ Usage: api key create [name]", nil
+			return i18n.T(lang, i18n.MsgAPIKeyUsage), nil
 		}
 		return fmt.Sprintf(`🔑 API KEY GENERATED!
 
@@ -2598,12 +4429,13 @@ Events supported:
 • product.low_stock
 • payment.completed
 • payment.failed
+• payment.expired
 
 Configure in dashboard.
 
 Note: Webhook service requires setup.`, nil
 
 	default:
-		return "❌ Unknown api command", nil
+		return i18n.T(lang, i18n.MsgAPIUnknown), nil
 	}
 }
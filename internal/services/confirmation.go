@@ -0,0 +1,88 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// ConfirmationTTL is how long a destructive command waits for its "yes"
+const ConfirmationTTL = 30 * time.Second
+
+// ConfirmationStore persists the command each phone was asked to confirm
+// so the "yes" can land on any replica. The cache service implements it
+// with Redis keys whatsapp:confirm:{phone}. Get returns "" when nothing is
+// pending.
+type ConfirmationStore interface {
+	GetWhatsAppConfirmation(phone string) (string, error)
+	SetWhatsAppConfirmation(phone, action string, ttl time.Duration) error
+	DeleteWhatsAppConfirmation(phone string) error
+}
+
+// ConfirmationService holds destructive commands (delete, large removes)
+// until the sender repeats them after "yes"
+type ConfirmationService struct {
+	store   ConfirmationStore
+	pending map[string]pendingConfirmation // used when no store is configured
+	mu      sync.Mutex
+}
+
+type pendingConfirmation struct {
+	action  string
+	expires time.Time
+}
+
+// NewConfirmationService creates a confirmation service that keeps pending
+// commands in memory until a store is set
+func NewConfirmationService() *ConfirmationService {
+	return &ConfirmationService{pending: make(map[string]pendingConfirmation)}
+}
+
+// SetStore sets the store used to share confirmations between instances
+func (s *ConfirmationService) SetStore(store ConfirmationStore) {
+	s.store = store
+}
+
+// Request records action (e.g. "delete milk") as awaiting confirmation
+// from phone, replacing anything pending
+func (s *ConfirmationService) Request(phone, action string) error {
+	if s.store != nil {
+		return s.store.SetWhatsAppConfirmation(phone, action, ConfirmationTTL)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for p, c := range s.pending {
+		if now.After(c.expires) {
+			delete(s.pending, p)
+		}
+	}
+	s.pending[phone] = pendingConfirmation{action: action, expires: now.Add(ConfirmationTTL)}
+	return nil
+}
+
+// Confirm reports whether action is what phone was asked to confirm. The
+// pending command is used up either way, so a wrong "yes" cancels it.
+func (s *ConfirmationService) Confirm(phone, action string) (bool, error) {
+	if s.store != nil {
+		pending, err := s.store.GetWhatsAppConfirmation(phone)
+		if err != nil || pending == "" {
+			return false, err
+		}
+		if err := s.store.DeleteWhatsAppConfirmation(phone); err != nil {
+			return false, err
+		}
+		return pending == action, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.pending[phone]
+	delete(s.pending, phone)
+	if !ok || time.Now().After(c.expires) {
+		return false, nil
+	}
+	return c.action == action, nil
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+)
+
+// CrossSellTTL is how long a product's suggestions are reused
+const CrossSellTTL = 6 * time.Hour
+
+// crossSellMax is how many suggestions are worked out and kept for a
+// product; requests for fewer take the first ones
+const crossSellMax = 20
+
+// CrossSellStore keeps each product's suggestions so they are worked out
+// once for every replica. The cache service implements it with Redis keys
+// crosssell:{shop}:{product}. Get returns nil data when none are stored.
+type CrossSellStore interface {
+	GetCrossSells(shopID, productID uint) ([]byte, error)
+	SetCrossSells(shopID, productID uint, data []byte, ttl time.Duration) error
+}
+
+// CrossSellService suggests the products most often bought on the same days
+// as another, keeping the suggestions for CrossSellTTL
+type CrossSellService struct {
+	saleRepo    *repository.SaleRepository
+	productRepo *repository.ProductRepository
+
+	store   CrossSellStore
+	entries map[crossSellKey]crossSellEntry // used when no store is configured
+	pending map[crossSellKey]bool           // being worked out for a sale
+	mu      sync.Mutex
+}
+
+type crossSellKey struct {
+	shopID, productID uint
+}
+
+type crossSellEntry struct {
+	ids     []uint
+	expires time.Time
+}
+
+// NewCrossSellService creates a cross-sell service that keeps suggestions
+// in memory until a store is set
+func NewCrossSellService(saleRepo *repository.SaleRepository, productRepo *repository.ProductRepository) *CrossSellService {
+	return &CrossSellService{
+		saleRepo:    saleRepo,
+		productRepo: productRepo,
+		entries:     make(map[crossSellKey]crossSellEntry),
+		pending:     make(map[crossSellKey]bool),
+	}
+}
+
+// SetStore sets the store used to share suggestions between instances
+func (s *CrossSellService) SetStore(store CrossSellStore) {
+	s.store = store
+}
+
+// Suggest returns up to limit products most often bought with productID,
+// working them out if they aren't kept yet
+func (s *CrossSellService) Suggest(shopID, productID uint, limit int) ([]models.Product, error) {
+	key := crossSellKey{shopID: shopID, productID: productID}
+	ids, ok := s.get(key)
+	if !ok {
+		var err error
+		if ids, err = s.refresh(key); err != nil {
+			return nil, err
+		}
+	}
+	return s.products(shopID, ids, limit)
+}
+
+// Cached returns up to limit suggestions for productID if they are kept.
+// Otherwise it returns none and works them out in the background, so a sale
+// never waits on the query.
+func (s *CrossSellService) Cached(shopID, productID uint, limit int) []models.Product {
+	key := crossSellKey{shopID: shopID, productID: productID}
+	ids, ok := s.get(key)
+	if !ok {
+		s.mu.Lock()
+		running := s.pending[key]
+		s.pending[key] = true
+		s.mu.Unlock()
+		if !running {
+			go func() {
+				if _, err := s.refresh(key); err != nil {
+					log.Printf("⚠️ Failed to get cross-sells for product %d: %v", productID, err)
+				}
+				s.mu.Lock()
+				delete(s.pending, key)
+				s.mu.Unlock()
+			}()
+		}
+		return nil
+	}
+
+	products, err := s.products(shopID, ids, limit)
+	if err != nil {
+		return nil
+	}
+	return products
+}
+
+// refresh works out a product's suggestions and keeps them
+func (s *CrossSellService) refresh(key crossSellKey) ([]uint, error) {
+	ids, err := s.saleRepo.GetFrequentlyBoughtTogether(key.productID, key.shopID, crossSellMax)
+	if err != nil {
+		return nil, err
+	}
+	s.set(key, ids)
+	return ids, nil
+}
+
+func (s *CrossSellService) get(key crossSellKey) ([]uint, bool) {
+	if s.store != nil {
+		data, err := s.store.GetCrossSells(key.shopID, key.productID)
+		if err != nil || data == nil {
+			return nil, false
+		}
+		var ids []uint
+		if err := json.Unmarshal(data, &ids); err != nil {
+			return nil, false
+		}
+		return ids, true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.ids, true
+}
+
+func (s *CrossSellService) set(key crossSellKey, ids []uint) {
+	if s.store != nil {
+		data, err := json.Marshal(ids)
+		if err == nil {
+			err = s.store.SetCrossSells(key.shopID, key.productID, data, CrossSellTTL)
+		}
+		if err != nil {
+			log.Printf("⚠️ Failed to keep cross-sells for product %d: %v", key.productID, err)
+		}
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for k, entry := range s.entries {
+		if now.After(entry.expires) {
+			delete(s.entries, k)
+		}
+	}
+	s.entries[key] = crossSellEntry{ids: ids, expires: now.Add(CrossSellTTL)}
+}
+
+// products loads the suggested products still on sale, in order
+func (s *CrossSellService) products(shopID uint, ids []uint, limit int) ([]models.Product, error) {
+	found, err := s.productRepo.GetByIDsInOrder(shopID, ids)
+	if err != nil {
+		return nil, err
+	}
+	products := make([]models.Product, 0, limit)
+	for _, p := range found {
+		if p.IsActive && len(products) < limit {
+			products = append(products, p)
+		}
+	}
+	return products, nil
+}
@@ -84,8 +84,9 @@ func (s *Service) Convert(amount float64, from, to string) (float64, error) {
 		return 0, CurrencyError("unknown currency: " + to)
 	}
 
-	kesAmount := amount * fromRate
-	return kesAmount / toRate, nil
+	// Rates are units of the currency per KES
+	kesAmount := amount / fromRate
+	return kesAmount * toRate, nil
 }
 
 func (s *Service) Format(amount float64, currency string) string {
@@ -0,0 +1,300 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/export"
+)
+
+// Periods a report can be sent for on demand
+const (
+	ReportPeriodDay   = "day"
+	ReportPeriodWeek  = "week"
+	ReportPeriodMonth = "month"
+)
+
+var (
+	ErrNoRecipients      = errors.New("no report recipients: add recipients or set the shop's email")
+	ErrInvalidPeriod     = errors.New("period must be day, week or month")
+	ErrInvalidFrequency  = errors.New("frequency must be daily or weekly")
+	ErrInvalidRecipients = errors.New("recipients must be email addresses")
+)
+
+// ReportMailer emails shops their sales report as a PDF, on the schedule
+// each shop chooses or on demand
+type ReportMailer struct {
+	emailSvc *Service
+	prefRepo *repository.ReportEmailPreferenceRepository
+	shopRepo *repository.ShopRepository
+	saleRepo *repository.SaleRepository
+
+	// sendWhatsApp tells owners their reports were turned off; nil skips it
+	sendWhatsApp func(phone, message string) error
+}
+
+// NewReportMailer creates a new report mailer
+func NewReportMailer(emailSvc *Service, prefRepo *repository.ReportEmailPreferenceRepository,
+	shopRepo *repository.ShopRepository, saleRepo *repository.SaleRepository) *ReportMailer {
+	return &ReportMailer{
+		emailSvc: emailSvc,
+		prefRepo: prefRepo,
+		shopRepo: shopRepo,
+		saleRepo: saleRepo,
+	}
+}
+
+// SetWhatsAppSender sets how owners are told about bounced report emails
+func (m *ReportMailer) SetWhatsAppSender(sender func(phone, message string) error) {
+	m.sendWhatsApp = sender
+}
+
+// GetPreference returns a shop's report email settings
+func (m *ReportMailer) GetPreference(shopID uint) (*models.ReportEmailPreference, error) {
+	return m.prefRepo.GetByShop(shopID)
+}
+
+// SavePreference validates and stores a shop's report email settings.
+// Turning reports back on clears the reason a bounce turned them off.
+func (m *ReportMailer) SavePreference(pref *models.ReportEmailPreference) error {
+	if pref.Frequency != models.ReportFrequencyDaily && pref.Frequency != models.ReportFrequencyWeekly {
+		return ErrInvalidFrequency
+	}
+	for _, r := range pref.RecipientList(nil) {
+		if !strings.Contains(r, "@") {
+			return ErrInvalidRecipients
+		}
+	}
+	pref.Recipients = strings.Join(pref.RecipientList(nil), ",")
+	if pref.Enabled {
+		pref.DisabledReason = ""
+	}
+	return m.prefRepo.Save(pref)
+}
+
+// SendNow emails a shop's report for the day, week or month so far and
+// returns the addresses it went to
+func (m *ReportMailer) SendNow(shopID uint, period string) ([]string, error) {
+	now := time.Now()
+	today := startOfDay(now)
+
+	var start time.Time
+	var title string
+	switch period {
+	case ReportPeriodDay:
+		start, title = today, "Daily Sales Report"
+	case ReportPeriodWeek:
+		start, title = today.AddDate(0, 0, -6), "Weekly Sales Report"
+	case ReportPeriodMonth, "":
+		start, title = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()), "Monthly Sales Report"
+	default:
+		return nil, ErrInvalidPeriod
+	}
+
+	shop, err := m.shopRepo.GetByID(shopID)
+	if err != nil {
+		return nil, err
+	}
+	pref, err := m.prefRepo.GetByShop(shopID)
+	if err != nil {
+		return nil, err
+	}
+
+	recipients := pref.RecipientList(shop)
+	if len(recipients) == 0 {
+		return nil, ErrNoRecipients
+	}
+	return recipients, m.send(shop, recipients, title, start, now)
+}
+
+// SendScheduled emails every report that is due: daily reports cover
+// yesterday and weekly reports cover last Monday to Sunday. It is meant to
+// run hourly; a report is sent once per period.
+func (m *ReportMailer) SendScheduled() error {
+	prefs, err := m.prefRepo.ListEnabled()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	today := startOfDay(now)
+	// Monday starts the week
+	weekStart := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+
+	for i := range prefs {
+		pref := &prefs[i]
+
+		var start, end time.Time
+		var title string
+		if pref.Frequency == models.ReportFrequencyWeekly {
+			start, end, title = weekStart.AddDate(0, 0, -7), weekStart, "Weekly Sales Report"
+		} else {
+			start, end, title = today.AddDate(0, 0, -1), today, "Daily Sales Report"
+		}
+		if pref.LastSentAt != nil && !pref.LastSentAt.Before(end) {
+			continue
+		}
+
+		shop, err := m.shopRepo.GetByID(pref.ShopID)
+		if err != nil || !shop.IsActive {
+			continue
+		}
+		recipients := pref.RecipientList(shop)
+		if len(recipients) == 0 {
+			continue
+		}
+
+		if err := m.send(shop, recipients, title, start, end); err != nil {
+			log.Printf("❌ Failed to email %s to shop %s: %v", strings.ToLower(title), shop.Name, err)
+			continue
+		}
+		pref.LastSentAt = &now
+		if err := m.prefRepo.Save(pref); err != nil {
+			return err
+		}
+		log.Printf("📧 %s emailed to shop %s", title, shop.Name)
+	}
+	return nil
+}
+
+// HandleBounce turns off report emails for every shop whose reports go to
+// an address that bounced, and tells the owner on WhatsApp. It returns how
+// many shops were affected.
+func (m *ReportMailer) HandleBounce(address, reason string) (int, error) {
+	address = strings.ToLower(strings.TrimSpace(address))
+	prefs, err := m.prefRepo.GetByRecipient(address)
+	if err != nil {
+		return 0, err
+	}
+
+	disabled := 0
+	for i := range prefs {
+		pref := &prefs[i]
+		shop, err := m.shopRepo.GetByID(pref.ShopID)
+		if err != nil || !containsAddress(pref.RecipientList(shop), address) {
+			continue
+		}
+
+		pref.Enabled = false
+		pref.DisabledReason = fmt.Sprintf("%s bounced: %s", address, reason)
+		if err := m.prefRepo.Save(pref); err != nil {
+			return disabled, err
+		}
+		disabled++
+		log.Printf("📧 Report emails off for shop %s: %s", shop.Name, pref.DisabledReason)
+
+		if m.sendWhatsApp != nil {
+			message := fmt.Sprintf("⚠️ REPORT EMAILS PAUSED\n\nEmails to %s bounced, so we stopped sending your sales reports.\n\nFix the address in your report email settings to turn them back on.", address)
+			if err := m.sendWhatsApp(shop.Phone, message); err != nil {
+				log.Printf("❌ Failed to tell shop %s about bounced report emails: %v", shop.Name, err)
+			}
+		}
+	}
+	return disabled, nil
+}
+
+// send renders the report for [start, end), compared with the period of
+// the same length before, as a PDF and emails it to each recipient,
+// returning the first failure
+func (m *ReportMailer) send(shop *models.Shop, recipients []string, title string, start, end time.Time) error {
+	sales, err := m.saleRepo.GetByDateRange(shop.ID, start, end)
+	if err != nil {
+		return err
+	}
+
+	// The period of the same length before, for the comparison
+	previousStart := start.Add(-end.Sub(start))
+	previousSales, err := m.saleRepo.GetByDateRange(shop.ID, previousStart, start)
+	if err != nil {
+		return err
+	}
+
+	report := buildReport(title, start, end, sales)
+	previous := buildReport("", previousStart, start, previousSales)
+	report.Comparison = export.Compare(previous.Date,
+		export.PeriodTotals{Sales: report.TotalSales, Profit: report.TotalProfit, Transactions: report.TransactionCount},
+		export.PeriodTotals{Sales: previous.TotalSales, Profit: previous.TotalProfit, Transactions: previous.TransactionCount})
+	pdf, err := (&export.ReportExporter{}).ExportDaily(report, export.FormatPDF)
+	if err != nil {
+		return err
+	}
+
+	attachment := Attachment{
+		Filename: fmt.Sprintf("report_%s.pdf", start.Format("20060102")),
+		Type:     "application/pdf",
+		Content:  pdf,
+	}
+	vars := map[string]string{
+		"title":        title,
+		"date":         report.Date,
+		"total_sales":  fmt.Sprintf("%.0f", report.TotalSales),
+		"transactions": fmt.Sprintf("%d", report.TransactionCount),
+		"profit":       fmt.Sprintf("%.0f", report.TotalProfit),
+	}
+
+	var firstErr error
+	for _, to := range recipients {
+		err := m.emailSvc.SendTemplate(shop, to, models.EmailTemplateDailyReport, vars, attachment)
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", to, err)
+		}
+	}
+	return firstErr
+}
+
+// buildReport sums sales into the exporter's report, top products first
+func buildReport(title string, start, end time.Time, sales []models.Sale) export.DailyReportData {
+	report := export.DailyReportData{
+		Title:            title,
+		Date:             start.Format("2006-01-02"),
+		TransactionCount: len(sales),
+		TopProducts:      []export.ProductSale{},
+	}
+	if last := end.Add(-time.Nanosecond); startOfDay(last).After(start) {
+		report.Date += " to " + last.Format("2006-01-02")
+	}
+
+	products := make(map[string]*export.ProductSale)
+	var names []string
+	for _, s := range sales {
+		report.TotalSales += s.TotalAmount
+		report.TotalProfit += s.Profit
+
+		p, ok := products[s.Product.Name]
+		if !ok {
+			p = &export.ProductSale{Name: s.Product.Name}
+			products[s.Product.Name] = p
+			names = append(names, s.Product.Name)
+		}
+		p.Quantity += s.Quantity
+		p.Revenue += s.TotalAmount
+	}
+	if len(sales) > 0 {
+		report.AverageSale = report.TotalSales / float64(len(sales))
+	}
+
+	sort.SliceStable(names, func(i, j int) bool { return products[names[i]].Revenue > products[names[j]].Revenue })
+	for _, name := range names {
+		report.TopProducts = append(report.TopProducts, *products[name])
+	}
+	return report
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func containsAddress(list []string, address string) bool {
+	for _, a := range list {
+		if a == address {
+			return true
+		}
+	}
+	return false
+}
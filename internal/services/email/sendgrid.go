@@ -2,11 +2,14 @@ package email
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
 )
 
 // Config holds SendGrid configuration
@@ -14,12 +17,14 @@ type Config struct {
 	APIKey    string
 	FromEmail string
 	FromName  string
+	BaseURL   string // overrides the SendGrid host, e.g. for a mock server
 }
 
 // Service handles email sending via SendGrid
 type Service struct {
-	config *Config
-	client *http.Client
+	config    *Config
+	client    *http.Client
+	templates *Templates
 }
 
 // New creates a new SendGrid email service
@@ -29,7 +34,30 @@ func New(config *Config) *Service {
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		templates: NewTemplates(nil),
+	}
+}
+
+// SetTemplates sets where shops' customised email templates are read from
+func (s *Service) SetTemplates(templates *Templates) {
+	s.templates = templates
+}
+
+// Templates returns the email template renderer
+func (s *Service) Templates() *Templates {
+	return s.templates
+}
+
+// SendTemplate renders the shop's template for key with vars and sends it.
+// shop may be nil for platform emails.
+func (s *Service) SendTemplate(shop *models.Shop, to, key string, vars map[string]string, attachments ...Attachment) error {
+	email, err := s.templates.Render(shop, key, vars)
+	if err != nil {
+		return err
 	}
+	email.To = to
+	email.Attachments = attachments
+	return s.SendEmail(email)
 }
 
 // Email represents an email message
@@ -39,6 +67,15 @@ type Email struct {
 	Subject string
 	Body    string
 	HTML    string // If provided, sends as HTML
+
+	Attachments []Attachment
+}
+
+// Attachment is a file sent with an email
+type Attachment struct {
+	Filename string
+	Type     string // MIME type, e.g. application/pdf
+	Content  []byte
 }
 
 // SendEmail sends an email
@@ -81,12 +118,29 @@ func (s *Service) SendEmail(email *Email) error {
 		}
 	}
 
+	if len(email.Attachments) > 0 {
+		attachments := make([]map[string]string, len(email.Attachments))
+		for i, a := range email.Attachments {
+			attachments[i] = map[string]string{
+				"content":     base64.StdEncoding.EncodeToString(a.Content),
+				"type":        a.Type,
+				"filename":    a.Filename,
+				"disposition": "attachment",
+			}
+		}
+		msg["attachments"] = attachments
+	}
+
 	jsonData, err := json.Marshal(msg)
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequest("POST", "https://api.sendgrid.com/v3/mail/send", bytes.NewBuffer(jsonData))
+	baseURL := s.config.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.sendgrid.com"
+	}
+	req, err := http.NewRequest("POST", baseURL+"/v3/mail/send", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return err
 	}
@@ -114,106 +168,16 @@ func (s *Service) SendEmail(email *Email) error {
 
 // SendReportEmail sends a report email
 func (s *Service) SendReportEmail(to, shopName string, reportData map[string]interface{}) error {
-	html := fmt.Sprintf(`
-		<html>
-		<body style="font-family: Arial, sans-serif; padding: 20px;">
-			<h2 style="color: #2ecc71;">📊 %s - Daily Report</h2>
-			<table style="width: 100%%; border-collapse: collapse;">
-				<tr>
-					<td style="padding: 10px; border: 1px solid #ddd;"><strong>Total Sales</strong></td>
-					<td style="padding: 10px; border: 1px solid #ddd;">KSh %.0f</td>
-				</tr>
-				<tr>
-					<td style="padding: 10px; border: 1px solid #ddd;"><strong>Transactions</strong></td>
-					<td style="padding: 10px; border: 1px solid #ddd;">%d</td>
-				</tr>
-				<tr>
-					<td style="padding: 10px; border: 1px solid #ddd;"><strong>Profit</strong></td>
-					<td style="padding: 10px; border: 1px solid #ddd;">KSh %.0f</td>
-				</tr>
-			</table>
-			<p style="color: #666; margin-top: 20px;">
-				Generated by DukaPOS - WhatsApp POS for Kenyan Businesses
-			</p>
-		</body>
-		</html>
-	`, shopName,
-		reportData["total_sales"],
-		reportData["transactions"],
-		reportData["profit"],
-	)
-
-	email := &Email{
-		To:      to,
-		ToName:  shopName,
-		Subject: fmt.Sprintf("%s - Daily Report", shopName),
-		Body: fmt.Sprintf(`
-%s - Daily Report
-
-Total Sales: KSh %.0f
-Transactions: %d
-Profit: KSh %.0f
-
-Generated by DukaPOS
-		`, shopName,
-			reportData["total_sales"],
-			reportData["transactions"],
-			reportData["profit"],
-		),
-		HTML: html,
-	}
-
-	return s.SendEmail(email)
+	return s.SendTemplate(&models.Shop{Name: shopName}, to, models.EmailTemplateDailyReport, map[string]string{
+		"title":        "Daily Report",
+		"date":         time.Now().Format("2006-01-02"),
+		"total_sales":  fmt.Sprintf("%.0f", reportData["total_sales"]),
+		"transactions": fmt.Sprintf("%d", reportData["transactions"]),
+		"profit":       fmt.Sprintf("%.0f", reportData["profit"]),
+	})
 }
 
 // SendWelcomeEmail sends a welcome email to new shops
 func (s *Service) SendWelcomeEmail(to, shopName string) error {
-	html := fmt.Sprintf(`
-		<html>
-		<body style="font-family: Arial, sans-serif; padding: 20px;">
-			<h2 style="color: #2ecc71;">🎉 Welcome to DukaPOS!</h2>
-			<p>Hi %s,</p>
-			<p>Thank you for joining DukaPOS - WhatsApp POS for Kenyan Businesses!</p>
-			<h3>Quick Start:</h3>
-			<ol>
-				<li>Save our WhatsApp number</li>
-				<li>Send: <code>add [product] [price] [qty]</code> to add products</li>
-				<li>Send: <code>sell [product] [qty]</code> to record sales</li>
-				<li>Send: <code>report</code> to see daily summary</li>
-			</ol>
-			<p>Need help? Reply to this email or contact support.</p>
-			<p style="color: #666;">
-				Best regards,<br>
-				The DukaPOS Team
-			</p>
-		</body>
-		</html>
-	`, shopName)
-
-	email := &Email{
-		To:      to,
-		ToName:  shopName,
-		Subject: "Welcome to DukaPOS!",
-		Body: fmt.Sprintf(`
-Welcome to DukaPOS!
-
-Hi %s,
-
-Thank you for joining DukaPOS - WhatsApp POS for Kenyan Businesses!
-
-Quick Start:
-1. Save our WhatsApp number
-2. Send: add [product] [price] [qty] to add products
-3. Send: sell [product] [qty] to record sales
-4. Send: report to see daily summary
-
-Need help? Contact support.
-
-Best regards,
-The DukaPOS Team
-		`, shopName),
-		HTML: html,
-	}
-
-	return s.SendEmail(email)
+	return s.SendTemplate(&models.Shop{Name: shopName}, to, models.EmailTemplateWelcome, nil)
 }
@@ -0,0 +1,352 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"gorm.io/gorm"
+)
+
+// DefaultBrandColor colours headings when a shop hasn't set a brand colour
+const DefaultBrandColor = "#2ecc71"
+
+var (
+	ErrUnknownTemplate = errors.New("unknown email template")
+	ErrInvalidTemplate = errors.New("subject and html are required")
+)
+
+// DefaultTemplates are the built-in templates, seeded as the platform
+// defaults (shop 0). Besides each template's own variables, every template
+// can use {{shop_name}}, {{logo_url}}, {{brand_color}} and {{currency}}.
+var DefaultTemplates = map[string]models.EmailTemplate{
+	models.EmailTemplateReceipt: {
+		Subject: "Your receipt from {{shop_name}}",
+		HTML: `<h2 style="color: {{brand_color}};">🧾 Receipt {{receipt_number}}</h2>
+<p>Thank you for shopping at {{shop_name}}.</p>
+<pre style="font-family: monospace;">{{items}}</pre>
+<p><strong>Total: {{currency}} {{total}}</strong><br>Paid by {{payment_method}} on {{date}}</p>`,
+		Text: `Receipt {{receipt_number}}
+
+Thank you for shopping at {{shop_name}}.
+
+{{items}}
+
+Total: {{currency}} {{total}}
+Paid by {{payment_method}} on {{date}}`,
+	},
+	models.EmailTemplateDailyReport: {
+		Subject: "{{shop_name}} - {{title}}",
+		HTML: `<h2 style="color: {{brand_color}};">📊 {{shop_name}} - {{title}}</h2>
+<p>{{date}}</p>
+<table style="width: 100%; border-collapse: collapse;">
+	<tr>
+		<td style="padding: 10px; border: 1px solid #ddd;"><strong>Total Sales</strong></td>
+		<td style="padding: 10px; border: 1px solid #ddd;">{{currency}} {{total_sales}}</td>
+	</tr>
+	<tr>
+		<td style="padding: 10px; border: 1px solid #ddd;"><strong>Transactions</strong></td>
+		<td style="padding: 10px; border: 1px solid #ddd;">{{transactions}}</td>
+	</tr>
+	<tr>
+		<td style="padding: 10px; border: 1px solid #ddd;"><strong>Profit</strong></td>
+		<td style="padding: 10px; border: 1px solid #ddd;">{{currency}} {{profit}}</td>
+	</tr>
+</table>`,
+		Text: `{{shop_name}} - {{title}}
+{{date}}
+
+Total Sales: {{currency}} {{total_sales}}
+Transactions: {{transactions}}
+Profit: {{currency}} {{profit}}`,
+	},
+	models.EmailTemplateLowStock: {
+		Subject: "Low stock at {{shop_name}}",
+		HTML: `<h2 style="color: {{brand_color}};">⚠️ Low Stock Alert</h2>
+<p>These products at {{shop_name}} are running low:</p>
+<pre style="font-family: monospace;">{{products}}</pre>
+<p>Restock soon to avoid missing sales.</p>`,
+		Text: `Low Stock Alert
+
+These products at {{shop_name}} are running low:
+
+{{products}}
+
+Restock soon to avoid missing sales.`,
+	},
+	models.EmailTemplateWelcome: {
+		Subject: "Welcome to DukaPOS!",
+		HTML: `<h2 style="color: {{brand_color}};">🎉 Welcome to DukaPOS!</h2>
+<p>Hi {{shop_name}},</p>
+<p>Thank you for joining DukaPOS - WhatsApp POS for Kenyan Businesses!</p>
+<h3>Quick Start:</h3>
+<ol>
+	<li>Save our WhatsApp number</li>
+	<li>Send: <code>add [product] [price] [qty]</code> to add products</li>
+	<li>Send: <code>sell [product] [qty]</code> to record sales</li>
+	<li>Send: <code>report</code> to see daily summary</li>
+</ol>
+<p>Need help? Reply to this email or contact support.</p>
+<p>Best regards,<br>The DukaPOS Team</p>`,
+		Text: `Welcome to DukaPOS!
+
+Hi {{shop_name}},
+
+Thank you for joining DukaPOS - WhatsApp POS for Kenyan Businesses!
+
+Quick Start:
+1. Save our WhatsApp number
+2. Send: add [product] [price] [qty] to add products
+3. Send: sell [product] [qty] to record sales
+4. Send: report to see daily summary
+
+Need help? Contact support.
+
+Best regards,
+The DukaPOS Team`,
+	},
+	models.EmailTemplatePasswordReset: {
+		Subject: "Reset your DukaPOS password",
+		HTML: `<h2 style="color: {{brand_color}};">🔐 Password Reset</h2>
+<p>Your password reset code is:</p>
+<p style="font-size: 24px; letter-spacing: 4px;"><strong>{{code}}</strong></p>
+<p>This code expires in {{expires_minutes}} minutes. If you didn't request this, please ignore this email.</p>`,
+		Text: `Your DukaPOS password reset code is: {{code}}
+
+This code expires in {{expires_minutes}} minutes.
+
+If you didn't request this, please ignore.`,
+	},
+	models.EmailTemplatePurchaseOrder: {
+		Subject: "Purchase order #{{order_number}} from {{shop_name}}",
+		HTML: `<h2 style="color: {{brand_color}};">📋 Purchase Order #{{order_number}}</h2>
+<p>Hello {{supplier_name}},</p>
+<p>{{shop_name}} would like to order:</p>
+<pre style="font-family: monospace;">{{items}}</pre>
+<p><strong>Total: {{currency}} {{total}}</strong><br>Expected delivery: {{expected_delivery}}</p>
+<p>The purchase order is attached. Please call {{shop_phone}} to arrange delivery.</p>`,
+		Text: `Purchase Order #{{order_number}}
+
+Hello {{supplier_name}},
+
+{{shop_name}} would like to order:
+
+{{items}}
+
+Total: {{currency}} {{total}}
+Expected delivery: {{expected_delivery}}
+
+The purchase order is attached. Please call {{shop_phone}} to arrange delivery.`,
+	},
+	models.EmailTemplateLoginAlert: {
+		Subject: "New login to {{shop_name}} from {{country}}",
+		HTML: `<h2 style="color: {{brand_color}};">🔐 New Login</h2>
+<p>Your DukaPOS account for {{shop_name}} was logged in to from a country it hasn't been used from before.</p>
+<p>Country: <strong>{{country}}</strong><br>IP address: {{ip_address}}<br>Device: {{user_agent}}<br>Method: {{method}}<br>Time: {{time}}</p>
+<p>If this was you, there's nothing to do. If not, change your password now.</p>`,
+		Text: `New login to {{shop_name}}
+
+Your DukaPOS account for {{shop_name}} was logged in to from a country it hasn't been used from before.
+
+Country: {{country}}
+IP address: {{ip_address}}
+Device: {{user_agent}}
+Method: {{method}}
+Time: {{time}}
+
+If this was you, there's nothing to do. If not, change your password now.`,
+	},
+}
+
+var (
+	placeholderPattern = regexp.MustCompile(`{{\s*([a-z_]+)\s*}}`)
+	tagPattern         = regexp.MustCompile(`<[^>]*>`)
+	blankLinesPattern  = regexp.MustCompile(`\n\s*\n\s*\n+`)
+)
+
+// Templates renders emails from the shop's templates, falling back to the
+// platform defaults
+type Templates struct {
+	repo *repository.EmailTemplateRepository
+}
+
+// NewTemplates creates a template renderer. With a nil repo only the
+// built-in templates are used.
+func NewTemplates(repo *repository.EmailTemplateRepository) *Templates {
+	return &Templates{repo: repo}
+}
+
+// SeedDefaults stores the built-in templates as the platform defaults,
+// keeping any that were already edited
+func (t *Templates) SeedDefaults() error {
+	if t.repo == nil {
+		return nil
+	}
+	for _, key := range models.EmailTemplateKeys {
+		tmpl := DefaultTemplates[key]
+		tmpl.Key = key
+		if err := t.repo.CreateIfMissing(&tmpl); err != nil {
+			return fmt.Errorf("seed %s template: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// Get returns the template a shop's emails use and whether the shop has
+// customised it
+func (t *Templates) Get(shopID uint, key string) (*models.EmailTemplate, bool, error) {
+	def, ok := DefaultTemplates[key]
+	if !ok {
+		return nil, false, ErrUnknownTemplate
+	}
+	if t.repo != nil {
+		ids := []uint{0}
+		if shopID != 0 {
+			ids = []uint{shopID, 0}
+		}
+		for _, id := range ids {
+			tmpl, err := t.repo.Get(id, key)
+			if err == nil {
+				return tmpl, id != 0, nil
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, false, err
+			}
+		}
+	}
+	def.Key = key
+	return &def, false, nil
+}
+
+// Save stores a shop's own version of a template
+func (t *Templates) Save(shopID uint, key, subject, htmlBody, text string) (*models.EmailTemplate, error) {
+	if _, ok := DefaultTemplates[key]; !ok {
+		return nil, ErrUnknownTemplate
+	}
+	if strings.TrimSpace(subject) == "" || strings.TrimSpace(htmlBody) == "" {
+		return nil, ErrInvalidTemplate
+	}
+	if t.repo == nil {
+		return nil, errors.New("email templates can't be stored")
+	}
+
+	tmpl := &models.EmailTemplate{ShopID: shopID, Key: key, Subject: subject, HTML: htmlBody, Text: text}
+	if err := t.repo.Save(tmpl); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// Reset removes a shop's own version of a template
+func (t *Templates) Reset(shopID uint, key string) error {
+	if _, ok := DefaultTemplates[key]; !ok {
+		return ErrUnknownTemplate
+	}
+	if t.repo == nil || shopID == 0 {
+		return nil
+	}
+	return t.repo.Delete(shopID, key)
+}
+
+// Render fills in the shop's template for key and wraps it in the shop's
+// branding. shop may be nil for platform emails. Values are HTML-escaped in
+// the HTML part; unknown placeholders are left as they are.
+func (t *Templates) Render(shop *models.Shop, key string, vars map[string]string) (*Email, error) {
+	var shopID uint
+	if shop != nil {
+		shopID = shop.ID
+	}
+	tmpl, _, err := t.Get(shopID, key)
+	if err != nil {
+		return nil, err
+	}
+
+	values := brandVars(shop)
+	for k, v := range vars {
+		values[k] = v
+	}
+
+	body := fill(tmpl.HTML, values, html.EscapeString)
+	text := fill(tmpl.Text, values, nil)
+	if strings.TrimSpace(tmpl.Text) == "" {
+		text = htmlToText(body)
+	}
+
+	return &Email{
+		ToName:  values["shop_name"],
+		Subject: fill(tmpl.Subject, values, nil),
+		Body:    text,
+		HTML:    brandedLayout(values, body),
+	}, nil
+}
+
+// brandVars are the variables every template can use
+func brandVars(shop *models.Shop) map[string]string {
+	values := map[string]string{
+		"shop_name":   "DukaPOS",
+		"logo_url":    "",
+		"brand_color": DefaultBrandColor,
+		"currency":    "KSh",
+	}
+	if shop == nil {
+		return values
+	}
+	if shop.BrandName != "" {
+		values["shop_name"] = shop.BrandName
+	} else if shop.Name != "" {
+		values["shop_name"] = shop.Name
+	}
+	values["logo_url"] = shop.BrandLogo
+	if shop.BrandPrimaryColor != "" {
+		values["brand_color"] = shop.BrandPrimaryColor
+	}
+	return values
+}
+
+// brandedLayout wraps a rendered body with the shop's logo and footer
+func brandedLayout(values map[string]string, body string) string {
+	var b strings.Builder
+	b.WriteString(`<html>
+<body style="font-family: Arial, sans-serif; padding: 20px;">
+`)
+	if logo := values["logo_url"]; logo != "" {
+		fmt.Fprintf(&b, `<img src="%s" alt="%s" style="max-height: 60px; margin-bottom: 10px;">
+`, html.EscapeString(logo), html.EscapeString(values["shop_name"]))
+	}
+	b.WriteString(body)
+	fmt.Fprintf(&b, `
+<p style="color: #666; margin-top: 20px;">%s - Powered by DukaPOS</p>
+</body>
+</html>`, html.EscapeString(values["shop_name"]))
+	return b.String()
+}
+
+// fill replaces {{name}} placeholders, escaping values with escape if set
+func fill(s string, values map[string]string, escape func(string) string) string {
+	return placeholderPattern.ReplaceAllStringFunc(s, func(match string) string {
+		name := placeholderPattern.FindStringSubmatch(match)[1]
+		v, ok := values[name]
+		if !ok {
+			return match
+		}
+		if escape != nil {
+			return escape(v)
+		}
+		return v
+	})
+}
+
+// htmlToText derives a plain text part from a rendered HTML body
+func htmlToText(s string) string {
+	s = strings.NewReplacer("<br>", "\n", "<br/>", "\n", "<br />", "\n", "</p>", "\n\n", "</li>", "\n", "</tr>", "\n").Replace(s)
+	s = html.UnescapeString(tagPattern.ReplaceAllString(s, ""))
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(blankLinesPattern.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
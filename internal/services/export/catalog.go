@@ -0,0 +1,225 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Catalog page layout in mm, on A4
+const (
+	catalogMargin    = 15.0
+	catalogHeader    = 28.0
+	catalogRowHeight = 8.0
+	catalogImageSize = 14.0
+)
+
+// catalogUncategorised heads products with no category
+const catalogUncategorised = "Other"
+
+// CatalogOptions picks what goes on a customer price list
+type CatalogOptions struct {
+	Category     string // only this category, ignoring case; empty for all
+	InStockOnly  bool   // leave out products with no stock
+	ShowBarcodes bool
+}
+
+// CatalogData is what goes on a customer price list. Images holds JPEG
+// thumbnails by product ID; products without one are listed without.
+type CatalogData struct {
+	Shop     models.Shop
+	Products []models.Product
+	Options  CatalogOptions
+	Images   map[uint][]byte
+	Date     time.Time
+}
+
+// CatalogExporter renders a price list to hand to customers. Unlike the
+// inventory export it shows selling prices only, never costs or profit.
+type CatalogExporter struct{}
+
+// CatalogProducts returns the products the options keep, sorted by
+// category and then name
+func CatalogProducts(products []models.Product, opts CatalogOptions) []models.Product {
+	var kept []models.Product
+	for _, p := range products {
+		if !p.IsActive {
+			continue
+		}
+		if opts.InStockOnly && p.CurrentStock <= 0 {
+			continue
+		}
+		if opts.Category != "" && !strings.EqualFold(strings.TrimSpace(p.Category), opts.Category) {
+			continue
+		}
+		kept = append(kept, p)
+	}
+	sort.SliceStable(kept, func(i, j int) bool {
+		ci, cj := catalogCategory(kept[i]), catalogCategory(kept[j])
+		if !strings.EqualFold(ci, cj) {
+			// Uncategorised products go last
+			if ci == catalogUncategorised || cj == catalogUncategorised {
+				return cj == catalogUncategorised
+			}
+			return strings.ToLower(ci) < strings.ToLower(cj)
+		}
+		return strings.ToLower(kept[i].Name) < strings.ToLower(kept[j].Name)
+	})
+	return kept
+}
+
+// ExportPDF renders the price list, one section per category
+func (e *CatalogExporter) ExportPDF(data CatalogData) ([]byte, error) {
+	products := CatalogProducts(data.Products, data.Options)
+	if data.Date.IsZero() {
+		data.Date = time.Now()
+	}
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(catalogMargin, catalogMargin, catalogMargin)
+	pdf.SetAutoPageBreak(true, catalogMargin)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pageWidth, _ := pdf.GetPageSize()
+	width := pageWidth - 2*catalogMargin
+	r, g, b := brandColor(data.Shop.BrandPrimaryColor)
+
+	name := data.Shop.BrandName
+	if name == "" {
+		name = data.Shop.Name
+	}
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-catalogMargin + 4)
+		pdf.SetFont("Arial", "I", 8)
+		pdf.SetTextColor(120, 120, 120)
+		pdf.CellFormat(width/2, 4, tr(name)+" - prices as of "+data.Date.Format("02 Jan 2006"), "", 0, "", false, 0, "")
+		pdf.CellFormat(width/2, 4, fmt.Sprintf("Page %d", pdf.PageNo()), "", 0, "R", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	})
+	pdf.AddPage()
+
+	pdf.SetFillColor(r, g, b)
+	pdf.Rect(0, 0, pageWidth, catalogHeader, "F")
+	pdf.SetTextColor(255, 255, 255)
+	pdf.SetXY(catalogMargin, 7)
+	pdf.SetFont("Arial", "B", 20)
+	pdf.CellFormat(width*0.65, 9, tr(name), "", 0, "", false, 0, "")
+	pdf.SetFont("Arial", "B", 12)
+	pdf.CellFormat(width*0.35, 9, "PRICE LIST", "", 1, "R", false, 0, "")
+	pdf.SetX(catalogMargin)
+	pdf.SetFont("Arial", "", 9)
+	var contact []string
+	for _, s := range []string{data.Shop.Address, data.Shop.Phone} {
+		if s != "" {
+			contact = append(contact, s)
+		}
+	}
+	pdf.CellFormat(width*0.65, 5, tr(strings.Join(contact, "  |  ")), "", 0, "", false, 0, "")
+	pdf.CellFormat(width*0.35, 5, data.Date.Format("02 Jan 2006"), "", 1, "R", false, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetY(catalogHeader + 6)
+
+	if len(products) == 0 {
+		pdf.SetFont("Arial", "I", 11)
+		pdf.CellFormat(width, 10, "No products to list.", "", 1, "C", false, 0, "")
+	}
+
+	showImages := len(data.Images) > 0
+	rowHeight := catalogRowHeight
+	if showImages {
+		rowHeight = catalogImageSize + 2
+	}
+	nameWidth := width * 0.6
+	unitWidth := width * 0.15
+	priceWidth := width - nameWidth - unitWidth
+
+	category := ""
+	for i, p := range products {
+		if c := catalogCategory(p); i == 0 || !strings.EqualFold(c, category) {
+			category = c
+			// Keep a heading on the same page as its first product
+			if pdf.GetY()+10+rowHeight > 297-catalogMargin {
+				pdf.AddPage()
+			}
+			pdf.Ln(2)
+			pdf.SetFont("Arial", "B", 12)
+			pdf.SetTextColor(r, g, b)
+			pdf.CellFormat(width, 8, tr(strings.ToUpper(category)), "B", 1, "", false, 0, "")
+			pdf.SetTextColor(0, 0, 0)
+		}
+		if pdf.GetY()+rowHeight > 297-catalogMargin {
+			pdf.AddPage()
+		}
+
+		x, y := pdf.GetX(), pdf.GetY()
+		textX, textWidth := x, nameWidth
+		if showImages {
+			if img, ok := data.Images[p.ID]; ok {
+				key := "product-" + strconv.FormatUint(uint64(p.ID), 10)
+				info := pdf.RegisterImageOptionsReader(key, gofpdf.ImageOptions{ImageType: "JPG"}, bytes.NewReader(img))
+				if pdf.Ok() && info != nil {
+					pdf.ImageOptions(key, x, y+1, catalogImageSize, catalogImageSize, false, gofpdf.ImageOptions{ImageType: "JPG"}, 0, "")
+				} else {
+					// A bad thumbnail should not lose the whole catalog
+					pdf.ClearError()
+				}
+			}
+			textX += catalogImageSize + 3
+			textWidth -= catalogImageSize + 3
+		}
+
+		pdf.SetXY(textX, y+1)
+		pdf.SetFont("Arial", "", 10)
+		lineHeight := rowHeight - 2
+		if data.Options.ShowBarcodes && p.Barcode != "" {
+			lineHeight = (rowHeight - 2) / 2
+		}
+		pdf.CellFormat(textWidth, lineHeight, tr(p.Name), "", 2, "", false, 0, "")
+		if data.Options.ShowBarcodes && p.Barcode != "" {
+			pdf.SetX(textX)
+			pdf.SetFont("Courier", "", 8)
+			pdf.CellFormat(textWidth, lineHeight, p.Barcode, "", 0, "", false, 0, "")
+		}
+
+		pdf.SetXY(x+nameWidth, y+1)
+		pdf.SetFont("Arial", "", 9)
+		pdf.CellFormat(unitWidth, rowHeight-2, tr(p.Unit), "", 0, "C", false, 0, "")
+		pdf.SetFont("Arial", "B", 10)
+		pdf.CellFormat(priceWidth, rowHeight-2, fmt.Sprintf("KSh %.2f", p.SellingPrice), "", 0, "R", false, 0, "")
+
+		pdf.SetDrawColor(220, 220, 220)
+		pdf.Line(x, y+rowHeight, x+width, y+rowHeight)
+		pdf.SetDrawColor(0, 0, 0)
+		pdf.SetXY(x, y+rowHeight)
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func catalogCategory(p models.Product) string {
+	if c := strings.TrimSpace(p.Category); c != "" {
+		return c
+	}
+	return catalogUncategorised
+}
+
+// brandColor parses a "#rrggbb" brand colour, falling back to the
+// DukaPOS green
+func brandColor(hex string) (r, g, b int) {
+	if len(hex) == 7 && hex[0] == '#' {
+		if v, err := strconv.ParseUint(hex[1:], 16, 32); err == nil {
+			return int(v >> 16), int(v >> 8 & 0xff), int(v & 0xff)
+		}
+	}
+	return 0, 166, 80
+}
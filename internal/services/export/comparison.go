@@ -0,0 +1,70 @@
+package export
+
+import (
+	"fmt"
+	"math"
+)
+
+// PeriodTotals are what a report's period sold
+type PeriodTotals struct {
+	Sales        float64
+	Profit       float64
+	Transactions int
+}
+
+// Comparison is how a report's period did against the one before it. The
+// changes are percentages, nil when the earlier period had nothing to
+// compare with.
+type Comparison struct {
+	Period               string   `json:"period"` // the earlier period, e.g. "last week"
+	PreviousSales        float64  `json:"previous_sales"`
+	PreviousProfit       float64  `json:"previous_profit"`
+	PreviousTransactions int      `json:"previous_transactions"`
+	SalesChange          *float64 `json:"sales_change"`
+	ProfitChange         *float64 `json:"profit_change"`
+	TransactionsChange   *float64 `json:"transactions_change"`
+}
+
+// Compare works out the changes from the previous period's totals
+func Compare(period string, current, previous PeriodTotals) *Comparison {
+	return &Comparison{
+		Period:               period,
+		PreviousSales:        previous.Sales,
+		PreviousProfit:       previous.Profit,
+		PreviousTransactions: previous.Transactions,
+		SalesChange:          PercentChange(current.Sales, previous.Sales),
+		ProfitChange:         PercentChange(current.Profit, previous.Profit),
+		TransactionsChange:   PercentChange(float64(current.Transactions), float64(previous.Transactions)),
+	}
+}
+
+// PercentChange is the change from previous to current as a percentage of
+// previous, to one decimal place. A loss that shrinks is a rise. It is nil
+// when previous is zero, as any change from nothing is no percentage.
+func PercentChange(current, previous float64) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	change := math.Round((current-previous)/math.Abs(previous)*1000) / 10
+	return &change
+}
+
+// FormatChange renders a change for a message, e.g. ↑12% or ↓5%
+func FormatChange(change float64) string {
+	switch rounded := math.Round(change); {
+	case rounded > 0:
+		return fmt.Sprintf("↑%.0f%%", rounded)
+	case rounded < 0:
+		return fmt.Sprintf("↓%.0f%%", -rounded)
+	}
+	return "→0%"
+}
+
+// signedChange renders a change for an exported file, e.g. +12.5%, or n/a
+// without one
+func signedChange(change *float64) string {
+	if change == nil {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.1f%%", *change)
+}
@@ -0,0 +1,178 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/xuri/excelize/v2"
+)
+
+// MaxImportRows is how many products one import file may hold
+const MaxImportRows = 1000
+
+// ProductHeader is the header row of product exports and import files
+var ProductHeader = []string{"ID", "Name", "Category", "Unit", "Cost Price", "Selling Price", "Stock", "Low Stock Threshold", "Barcode"}
+
+var (
+	ErrImportHeader  = errors.New("the first row must be the template's column headings")
+	ErrImportEmpty   = errors.New("the file has no products")
+	ErrImportTooLong = fmt.Errorf("an import holds at most %d products", MaxImportRows)
+)
+
+// ImportRow is one product read from an import file. Row is its row number
+// in the file; Err says why the row can't be imported.
+type ImportRow struct {
+	Row     int
+	Product models.Product
+	Err     error
+}
+
+// ParseProducts reads the products of an import file laid out like the
+// import template, in CSV (with # comment rows) or Excel. Rows that don't
+// parse are returned with their error; a file that isn't laid out like the
+// template is an error.
+func ParseProducts(data []byte, format Format) ([]ImportRow, error) {
+	var records [][]string
+	var rowNumbers []int
+
+	switch format {
+	case FormatCSV:
+		reader := csv.NewReader(bytes.NewReader(data))
+		reader.Comment = '#'
+		reader.FieldsPerRecord = -1
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("the file is not valid CSV: %w", err)
+			}
+			line, _ := reader.FieldPos(0)
+			records = append(records, record)
+			rowNumbers = append(rowNumbers, line)
+		}
+	case FormatExcel:
+		f, err := excelize.OpenReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("the file is not a valid Excel workbook: %w", err)
+		}
+		defer f.Close()
+		rows, err := f.GetRows(f.GetSheetName(0))
+		if err != nil {
+			return nil, fmt.Errorf("the file is not a valid Excel workbook: %w", err)
+		}
+		for i, row := range rows {
+			records = append(records, row)
+			rowNumbers = append(rowNumbers, i+1)
+		}
+	default:
+		return nil, fmt.Errorf("no %s import; use csv or excel", format)
+	}
+
+	if len(records) == 0 || !isProductHeader(records[0]) {
+		return nil, ErrImportHeader
+	}
+	records, rowNumbers = records[1:], rowNumbers[1:]
+
+	var rows []ImportRow
+	for i, record := range records {
+		if blank(record) {
+			continue
+		}
+		if len(rows) == MaxImportRows {
+			return nil, ErrImportTooLong
+		}
+		product, err := parseProductRecord(record)
+		rows = append(rows, ImportRow{Row: rowNumbers[i], Product: product, Err: err})
+	}
+	if len(rows) == 0 {
+		return nil, ErrImportEmpty
+	}
+	return rows, nil
+}
+
+func isProductHeader(record []string) bool {
+	if len(record) < len(ProductHeader) {
+		return false
+	}
+	for i, heading := range ProductHeader {
+		if !strings.EqualFold(strings.TrimSpace(record[i]), heading) {
+			return false
+		}
+	}
+	return true
+}
+
+func blank(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// parseProductRecord reads a row in ProductHeader's column order. Empty
+// numbers are 0.
+func parseProductRecord(record []string) (models.Product, error) {
+	field := func(i int) string {
+		if i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	number := func(i int) (float64, error) {
+		if field(i) == "" {
+			return 0, nil
+		}
+		v, err := strconv.ParseFloat(strings.ReplaceAll(field(i), ",", ""), 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("%s %q is not a number", ProductHeader[i], field(i))
+		}
+		return v, nil
+	}
+	whole := func(i int) (int, error) {
+		v, err := number(i)
+		if err == nil && v != float64(int(v)) {
+			err = fmt.Errorf("%s %q is not a whole number", ProductHeader[i], field(i))
+		}
+		return int(v), err
+	}
+
+	var p models.Product
+	id, err := whole(0)
+	if err != nil {
+		return p, err
+	}
+	p.ID = uint(id)
+	p.Name = field(1)
+	p.Category = field(2)
+	p.Unit = field(3)
+	p.Barcode = field(8)
+	if p.CostPrice, err = number(4); err != nil {
+		return p, err
+	}
+	if p.SellingPrice, err = number(5); err != nil {
+		return p, err
+	}
+	if p.CurrentStock, err = whole(6); err != nil {
+		return p, err
+	}
+	if p.LowStockThreshold, err = whole(7); err != nil {
+		return p, err
+	}
+	if p.Name == "" {
+		return p, errors.New("a name is required")
+	}
+	if p.SellingPrice <= 0 {
+		return p, errors.New("the selling price must be more than 0")
+	}
+	return p, nil
+}
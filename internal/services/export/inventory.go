@@ -0,0 +1,201 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/jung-kurt/gofpdf"
+	"github.com/xuri/excelize/v2"
+)
+
+// InventoryItem is one product's stock and its value at cost and selling
+// price
+type InventoryItem struct {
+	Name            string  `json:"name"`
+	Category        string  `json:"category"`
+	CurrentStock    int     `json:"current_stock"`
+	SellingPrice    float64 `json:"selling_price"`
+	CostPrice       float64 `json:"cost_price"`
+	StockValue      float64 `json:"stock_value"`
+	PotentialProfit float64 `json:"potential_profit"`
+	UnitsSold       int     `json:"units_sold"` // in the report's period
+}
+
+// InventoryData is a shop's stock valuation with the units sold over a
+// period
+type InventoryData struct {
+	Period          string          `json:"period"`
+	Inventory       []InventoryItem `json:"inventory"`
+	TotalStockValue float64         `json:"total_stock_value"`
+	TotalCostValue  float64         `json:"total_cost_value"`
+	PotentialProfit float64         `json:"potential_profit"`
+	ProductCount    int             `json:"product_count"`
+}
+
+// NewInventoryData values products' stock; sold holds the units sold in
+// period by product ID
+func NewInventoryData(products []models.Product, sold map[uint]int, period string) InventoryData {
+	data := InventoryData{Period: period, Inventory: make([]InventoryItem, len(products)), ProductCount: len(products)}
+	for i, p := range products {
+		stockValue := p.SellingPrice * float64(p.StockOnHand())
+		costValue := p.CostPrice * float64(p.StockOnHand())
+		data.TotalStockValue += stockValue
+		data.TotalCostValue += costValue
+
+		data.Inventory[i] = InventoryItem{
+			Name:            p.Name,
+			Category:        p.Category,
+			CurrentStock:    p.CurrentStock,
+			SellingPrice:    p.SellingPrice,
+			CostPrice:       p.CostPrice,
+			StockValue:      stockValue,
+			PotentialProfit: stockValue - costValue,
+			UnitsSold:       sold[p.ID],
+		}
+	}
+	data.PotentialProfit = data.TotalStockValue - data.TotalCostValue
+	return data
+}
+
+type InventoryExporter struct{}
+
+func (e *InventoryExporter) Export(data InventoryData, format Format) ([]byte, error) {
+	switch format {
+	case FormatJSON:
+		return json.MarshalIndent(data, "", "  ")
+	case FormatExcel:
+		return e.exportExcel(data)
+	case FormatPDF:
+		return e.exportPDF(data)
+	default:
+		return e.exportCSV(data)
+	}
+}
+
+func (e *InventoryExporter) exportCSV(data InventoryData) ([]byte, error) {
+	var builder strings.Builder
+	writer := csv.NewWriter(&builder)
+
+	header := []string{"NAME", "CATEGORY", "STOCK", "SELLING PRICE", "COST PRICE", "STOCK VALUE", "POTENTIAL PROFIT", "UNITS SOLD"}
+	if err := writer.Write(header); err != nil {
+		return nil, err
+	}
+
+	for _, item := range data.Inventory {
+		row := []string{
+			item.Name,
+			item.Category,
+			fmt.Sprintf("%d", item.CurrentStock),
+			fmt.Sprintf("%.2f", item.SellingPrice),
+			fmt.Sprintf("%.2f", item.CostPrice),
+			fmt.Sprintf("%.2f", item.StockValue),
+			fmt.Sprintf("%.2f", item.PotentialProfit),
+			fmt.Sprintf("%d", item.UnitsSold),
+		}
+		if err := writer.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	writer.Write([]string{})
+	writer.Write([]string{"TOTAL", "", "", "", "", fmt.Sprintf("%.2f", data.TotalStockValue), fmt.Sprintf("%.2f", data.PotentialProfit), ""})
+
+	writer.Flush()
+	return []byte(builder.String()), writer.Error()
+}
+
+func (e *InventoryExporter) exportExcel(data InventoryData) ([]byte, error) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	headers := []string{"Name", "Category", "Stock", "Selling Price", "Cost Price", "Stock Value", "Potential Profit", "Units Sold"}
+	style, _ := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"#00A650"}, Pattern: 1},
+	})
+	for i, h := range headers {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		f.SetCellValue("Sheet1", cell, h)
+		f.SetCellStyle("Sheet1", cell, cell, style)
+	}
+
+	for i, item := range data.Inventory {
+		row := i + 2
+		f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), item.Name)
+		f.SetCellValue("Sheet1", fmt.Sprintf("B%d", row), item.Category)
+		f.SetCellValue("Sheet1", fmt.Sprintf("C%d", row), item.CurrentStock)
+		f.SetCellValue("Sheet1", fmt.Sprintf("D%d", row), item.SellingPrice)
+		f.SetCellValue("Sheet1", fmt.Sprintf("E%d", row), item.CostPrice)
+		f.SetCellValue("Sheet1", fmt.Sprintf("F%d", row), item.StockValue)
+		f.SetCellValue("Sheet1", fmt.Sprintf("G%d", row), item.PotentialProfit)
+		f.SetCellValue("Sheet1", fmt.Sprintf("H%d", row), item.UnitsSold)
+	}
+	total := len(data.Inventory) + 3
+	f.SetCellValue("Sheet1", fmt.Sprintf("A%d", total), "TOTAL")
+	f.SetCellValue("Sheet1", fmt.Sprintf("F%d", total), data.TotalStockValue)
+	f.SetCellValue("Sheet1", fmt.Sprintf("G%d", total), data.PotentialProfit)
+
+	f.SetColWidth("Sheet1", "A", "A", 25)
+	f.SetColWidth("Sheet1", "B", "B", 15)
+	f.SetColWidth("Sheet1", "C", "H", 14)
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (e *InventoryExporter) exportPDF(data InventoryData) ([]byte, error) {
+	pdf := gofpdf.New("L", "mm", "A4", "")
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pdf.SetFont("Arial", "B", 16)
+	pdf.Cell(270, 10, "Inventory Valuation")
+	pdf.Ln(8)
+	pdf.SetFont("Arial", "", 10)
+	pdf.Cell(270, 6, "Units sold: "+data.Period)
+	pdf.Ln(10)
+
+	pdf.SetFont("Arial", "B", 10)
+	headers := []string{"Name", "Category", "Stock", "Price", "Cost", "Stock Value", "Profit", "Sold"}
+	colWidths := []float64{70, 40, 20, 25, 25, 35, 35, 20}
+	for i, h := range headers {
+		pdf.Cell(colWidths[i], 8, h)
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Arial", "", 9)
+	for _, item := range data.Inventory {
+		pdf.CellFormat(colWidths[0], 7, tr(item.Name), "0", 0, "", false, 0, "")
+		pdf.CellFormat(colWidths[1], 7, tr(item.Category), "0", 0, "", false, 0, "")
+		pdf.CellFormat(colWidths[2], 7, fmt.Sprintf("%d", item.CurrentStock), "0", 0, "", false, 0, "")
+		pdf.CellFormat(colWidths[3], 7, fmt.Sprintf("%.2f", item.SellingPrice), "0", 0, "", false, 0, "")
+		pdf.CellFormat(colWidths[4], 7, fmt.Sprintf("%.2f", item.CostPrice), "0", 0, "", false, 0, "")
+		pdf.CellFormat(colWidths[5], 7, fmt.Sprintf("%.2f", item.StockValue), "0", 0, "", false, 0, "")
+		pdf.CellFormat(colWidths[6], 7, fmt.Sprintf("%.2f", item.PotentialProfit), "0", 0, "", false, 0, "")
+		pdf.CellFormat(colWidths[7], 7, fmt.Sprintf("%d", item.UnitsSold), "0", 0, "", false, 0, "")
+		pdf.Ln(-1)
+	}
+
+	pdf.SetFont("Arial", "B", 10)
+	pdf.CellFormat(colWidths[0]+colWidths[1]+colWidths[2]+colWidths[3]+colWidths[4], 8, "TOTAL", "T", 0, "", false, 0, "")
+	pdf.CellFormat(colWidths[5], 8, fmt.Sprintf("%.2f", data.TotalStockValue), "T", 0, "", false, 0, "")
+	pdf.CellFormat(colWidths[6], 8, fmt.Sprintf("%.2f", data.PotentialProfit), "T", 0, "", false, 0, "")
+	pdf.CellFormat(colWidths[7], 8, "", "T", 1, "", false, 0, "")
+
+	pdf.Ln(10)
+	pdf.SetFont("Arial", "I", 8)
+	pdf.Cell(190, 5, fmt.Sprintf("Generated: %s", time.Now().Format("2006-01-02 15:04:05")))
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
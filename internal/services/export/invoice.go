@@ -0,0 +1,137 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Invoice page layout in mm, on A4
+const (
+	invoiceMargin   = 20.0
+	invoiceLogoSize = 20.0
+)
+
+// InvoiceData is what goes on a billing invoice
+type InvoiceData struct {
+	Invoice models.Invoice
+	Account models.Account
+	Shop    models.Shop
+}
+
+type InvoiceExporter struct{}
+
+// ExportPDF renders a billing invoice for a plan payment
+func (e *InvoiceExporter) ExportPDF(data InvoiceData) ([]byte, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(invoiceMargin, invoiceMargin, invoiceMargin)
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pageWidth, _ := pdf.GetPageSize()
+	width := pageWidth - 2*invoiceMargin
+	invoice := data.Invoice
+
+	drawLogo(pdf, invoiceMargin, invoiceMargin, invoiceLogoSize)
+	pdf.SetXY(invoiceMargin+invoiceLogoSize+4, invoiceMargin+3)
+	pdf.SetFont("Arial", "B", 18)
+	pdf.Cell(60, 8, "DukaPOS")
+	pdf.SetXY(invoiceMargin+invoiceLogoSize+4, invoiceMargin+11)
+	pdf.SetFont("Arial", "", 9)
+	pdf.Cell(60, 5, "WhatsApp POS for Kenyan dukas")
+
+	pdf.SetXY(invoiceMargin, invoiceMargin+2)
+	pdf.SetFont("Arial", "B", 16)
+	pdf.CellFormat(width, 8, "INVOICE", "", 1, "R", false, 0, "")
+	pdf.SetFont("Arial", "", 9)
+	pdf.CellFormat(width, 5, invoice.Number, "", 1, "R", false, 0, "")
+	pdf.CellFormat(width, 5, "Issued "+invoice.CreatedAt.Format("02 Jan 2006"), "", 1, "R", false, 0, "")
+
+	pdf.SetY(invoiceMargin + invoiceLogoSize + 12)
+	pdf.SetFont("Arial", "B", 10)
+	pdf.CellFormat(width, 6, "Billed to", "", 1, "", false, 0, "")
+	pdf.SetFont("Arial", "", 10)
+	pdf.CellFormat(width, 5, tr(data.Shop.Name), "", 1, "", false, 0, "")
+	if data.Account.Name != "" {
+		pdf.CellFormat(width, 5, tr(data.Account.Name), "", 1, "", false, 0, "")
+	}
+	if data.Account.Email != "" {
+		pdf.CellFormat(width, 5, data.Account.Email, "", 1, "", false, 0, "")
+	}
+	if phone := data.Shop.Phone; phone != "" {
+		pdf.CellFormat(width, 5, phone, "", 1, "", false, 0, "")
+	}
+
+	pdf.Ln(8)
+	pdf.SetFont("Arial", "B", 10)
+	pdf.SetFillColor(240, 240, 240)
+	pdf.CellFormat(width*0.7, 8, "Description", "B", 0, "", true, 0, "")
+	pdf.CellFormat(width*0.3, 8, "Amount", "B", 1, "R", true, 0, "")
+
+	pdf.SetFont("Arial", "", 10)
+	description := fmt.Sprintf("DukaPOS %s plan, %s to %s", invoice.Plan.Name(),
+		invoice.PeriodStart.Format("02 Jan 2006"), invoice.PeriodEnd.Format("02 Jan 2006"))
+	pdf.CellFormat(width*0.7, 8, description, "", 0, "", false, 0, "")
+	pdf.CellFormat(width*0.3, 8, fmt.Sprintf("%s %.2f", invoice.Currency, invoice.Amount), "", 1, "R", false, 0, "")
+
+	pdf.SetFont("Arial", "B", 11)
+	pdf.CellFormat(width*0.7, 9, "TOTAL", "T", 0, "", false, 0, "")
+	pdf.CellFormat(width*0.3, 9, fmt.Sprintf("%s %.2f", invoice.Currency, invoice.Amount), "T", 1, "R", false, 0, "")
+
+	pdf.Ln(6)
+	pdf.SetFont("Arial", "", 9)
+	if invoice.PaidAt != nil {
+		paid := "Paid by M-Pesa on " + invoice.PaidAt.Format("02 Jan 2006 15:04")
+		if invoice.MpesaReceipt != "" {
+			paid += ", receipt " + invoice.MpesaReceipt
+		}
+		pdf.CellFormat(width, 5, paid, "", 1, "", false, 0, "")
+	}
+
+	pdf.Ln(10)
+	pdf.SetFont("Arial", "I", 8)
+	pdf.MultiCell(width, 4, "Thank you for using DukaPOS. Questions about this invoice? Reply to your billing email or contact support.", "", "C", false)
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// drawLogo draws the DukaPOS app icon (a receipt with a yellow check
+// badge) as a size x size square at x, y
+func drawLogo(pdf *gofpdf.Fpdf, x, y, size float64) {
+	// The icon is drawn on a 192 unit grid
+	u := size / 192
+
+	pdf.SetFillColor(0, 166, 80)
+	pdf.RoundedRect(x, y, size, size, 40*u, "1234", "F")
+
+	pdf.SetFillColor(255, 255, 255)
+	bars := []struct{ y, w, h, alpha float64 }{
+		{60, 96, 16, 0.9},
+		{84, 72, 12, 0.7},
+		{108, 84, 12, 0.7},
+		{132, 60, 12, 0.7},
+	}
+	for _, bar := range bars {
+		pdf.SetAlpha(bar.alpha, "Normal")
+		pdf.Rect(x+48*u, y+bar.y*u, bar.w*u, bar.h*u, "F")
+	}
+	pdf.SetAlpha(1, "Normal")
+
+	pdf.SetFillColor(253, 185, 19)
+	pdf.Circle(x+144*u, y+144*u, 32*u, "F")
+
+	pdf.SetDrawColor(255, 255, 255)
+	pdf.SetLineWidth(3 * u)
+	pdf.SetLineCapStyle("round")
+	pdf.SetLineJoinStyle("round")
+	pdf.Line(x+140*u, y+144*u, x+144*u, y+148*u)
+	pdf.Line(x+144*u, y+148*u, x+152*u, y+140*u)
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.SetLineWidth(0.2)
+}
@@ -0,0 +1,404 @@
+package export
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/jobs"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/storage"
+)
+
+// DefaultJobTTL is how long a finished export can be downloaded
+const DefaultJobTTL = 24 * time.Hour
+
+// JobType is the job queue type background exports run as
+const JobType = "export_file"
+
+// jobBatchSize is how many rows are read from the database at a time
+const jobBatchSize = 500
+
+// jobStaleAfter is how long a job may stay queued or running before
+// Cleanup gives up on it, e.g. because the server running it stopped
+const jobStaleAfter = 2 * time.Hour
+
+var (
+	ErrJobNotReady = errors.New("export is not ready")
+	ErrJobExpired  = errors.New("export has expired")
+)
+
+// JobConfig configures background exports
+type JobConfig struct {
+	Store   storage.StreamStore // where files are written; every server must share it
+	BaseURL string              // the server's public URL, for download links
+	Secret  string              // signs download links, and nothing else
+	TTL     time.Duration       // how long files can be downloaded, default 24 hours
+}
+
+// JobService runs exports too large for a request on the job queue's
+// workers, streaming rows from the database to the store a batch at a time
+type JobService struct {
+	config      JobConfig
+	queue       *jobs.JobQueue
+	repo        *repository.ExportJobRepository
+	shopRepo    *repository.ShopRepository
+	saleRepo    *repository.SaleRepository
+	productRepo *repository.ProductRepository
+
+	sendWhatsApp func(phone, message string) error
+	sendEmail    func(to, subject, body string) error
+	now          func() time.Time
+}
+
+// NewJobService creates a job service, filling in config defaults, and
+// registers it on the queue. The queue must be started afterwards.
+func NewJobService(config JobConfig, queue *jobs.JobQueue, repo *repository.ExportJobRepository, shopRepo *repository.ShopRepository,
+	saleRepo *repository.SaleRepository, productRepo *repository.ProductRepository) *JobService {
+	if config.TTL <= 0 {
+		config.TTL = DefaultJobTTL
+	}
+	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+	s := &JobService{
+		config:      config,
+		queue:       queue,
+		repo:        repo,
+		shopRepo:    shopRepo,
+		saleRepo:    saleRepo,
+		productRepo: productRepo,
+		now:         time.Now,
+	}
+	queue.Register(JobType, s.handle)
+	return s
+}
+
+// SetMessageSenders tells shops when their export is ready or has failed,
+// by WhatsApp and, when the shop has an email address, by email. Either
+// may be nil.
+func (s *JobService) SetMessageSenders(whatsapp func(phone, message string) error, email func(to, subject, body string) error) {
+	s.sendWhatsApp = whatsapp
+	s.sendEmail = email
+}
+
+// SetClock overrides the time source (used by tests)
+func (s *JobService) SetClock(now func() time.Time) {
+	s.now = now
+}
+
+// Streamable reports whether exports in format can be written in the
+// background
+func Streamable(format Format) bool {
+	return format == FormatCSV || format == FormatJSON || format == FormatJSONLines || format == FormatExcel
+}
+
+// Submit saves a new job and queues it
+func (s *JobService) Submit(job *models.ExportJob) error {
+	switch job.Type {
+	case models.ExportJobSales:
+		if job.PeriodStart == nil || job.PeriodEnd == nil {
+			return errors.New("sales exports need a period")
+		}
+	case models.ExportJobProducts:
+	default:
+		return fmt.Errorf("unsupported export type %q; use sales or products", job.Type)
+	}
+	if !Streamable(Format(job.Format)) {
+		return fmt.Errorf("%s exports cannot run in the background; use csv, json, jsonl or xlsx", job.Format)
+	}
+
+	job.Status = models.ExportJobStatusQueued
+	if err := s.repo.Create(job); err != nil {
+		return err
+	}
+	params := map[string]string{"export_job_id": strconv.FormatUint(uint64(job.ID), 10)}
+	if _, err := s.queue.Enqueue(job.ShopID, JobType, params); err != nil {
+		job.Status = models.ExportJobStatusFailed
+		job.Error = "failed to queue export"
+		s.repo.Update(job)
+		return err
+	}
+	return nil
+}
+
+// Job gets a job
+func (s *JobService) Job(id uint) (*models.ExportJob, error) {
+	return s.repo.GetByID(id)
+}
+
+// ShopJob gets one of a shop's jobs
+func (s *JobService) ShopJob(shopID, id uint) (*models.ExportJob, error) {
+	return s.repo.GetByShop(shopID, id)
+}
+
+// handle runs the export a queued job names. The file goes to the store,
+// not the job result, so it can be larger than the queue keeps.
+func (s *JobService) handle(ctx context.Context, queued *jobs.Job) (*jobs.Result, error) {
+	id, err := strconv.ParseUint(queued.Params["export_job_id"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid export job: %q", queued.Params["export_job_id"])
+	}
+	job, err := s.run(uint(id))
+	if err != nil {
+		return nil, err
+	}
+	if job.Status == models.ExportJobStatusFailed {
+		return nil, errors.New(job.Error)
+	}
+	return &jobs.Result{Filename: job.Filename}, nil
+}
+
+// run writes a job's file and records the outcome. A job another worker
+// has already taken, or Cleanup has given up on, is left alone.
+func (s *JobService) run(id uint) (*models.ExportJob, error) {
+	claimed, err := s.repo.Claim(id)
+	if err != nil {
+		return nil, err
+	}
+	job, err := s.repo.GetByID(id)
+	if err != nil || !claimed {
+		return job, err
+	}
+
+	key, rows, size, err := s.write(job)
+	now := s.now()
+	job.FinishedAt = &now
+	if err != nil {
+		job.Status = models.ExportJobStatusFailed
+		job.Error = err.Error()
+		if len(job.Error) > 500 {
+			job.Error = job.Error[:500]
+		}
+		log.Printf("❌ Export job %d (%s) for shop %d failed: %v", job.ID, job.Type, job.ShopID, err)
+	} else {
+		expires := now.Add(s.config.TTL)
+		job.Status = models.ExportJobStatusDone
+		job.FilePath = key
+		job.Rows = rows
+		job.Size = size
+		job.ExpiresAt = &expires
+	}
+	if err := s.repo.Update(job); err != nil {
+		log.Printf("⚠️ Export job %d: failed to save status: %v", id, err)
+	}
+	s.notify(job)
+	return job, nil
+}
+
+// write streams the job's rows to a new file in the store and returns its
+// key, the number of rows and its size
+func (s *JobService) write(job *models.ExportJob) (key string, rows int, size int64, err error) {
+	key = fmt.Sprintf("exports/%d/export-%d-%d%s", job.ShopID, job.ID, s.now().UnixNano(), filepath.Ext(job.Filename))
+
+	// Rows are written into one end of a pipe while the store saves from
+	// the other, so the file is never held in memory
+	pr, pw := io.Pipe()
+	counter := &countingWriter{w: pw}
+	written := make(chan error, 1)
+	go func() {
+		n, err := s.writeRows(job, counter)
+		rows = n
+		pw.CloseWithError(err)
+		written <- err
+	}()
+	_, err = s.config.Store.SaveStream(key, pr)
+	// Stops the writer if the store gave up part way
+	pr.CloseWithError(err)
+	if writeErr := <-written; writeErr != nil {
+		err = writeErr
+	}
+	if err != nil {
+		s.config.Store.Delete(key)
+		return "", 0, 0, err
+	}
+	return key, rows, counter.n, nil
+}
+
+// writeRows writes the job's rows to w and returns how many there were
+func (s *JobService) writeRows(job *models.ExportJob, w io.Writer) (rows int, err error) {
+	columns := SaleColumns
+	if job.Type == models.ExportJobProducts {
+		columns = ProductColumns
+	}
+	table, err := NewTableWriter(w, Format(job.Format), columns)
+	if err != nil {
+		return 0, err
+	}
+
+	switch job.Type {
+	case models.ExportJobSales:
+		filter := repository.SaleFilter{PaymentMethod: job.PaymentMethod, ProductID: job.ProductID, Category: job.Category}
+		err = s.saleRepo.EachFiltered(job.ShopID, *job.PeriodStart, *job.PeriodEnd, filter, jobBatchSize, func(batch []models.Sale) error {
+			for _, sale := range batch {
+				if err := table.WriteRow(SaleRow(sale)); err != nil {
+					return err
+				}
+				rows++
+			}
+			return nil
+		})
+	case models.ExportJobProducts:
+		err = s.productRepo.EachActive(job.ShopID, jobBatchSize, func(batch []models.Product) error {
+			for _, p := range batch {
+				if !jobIncludes(job, p) {
+					continue
+				}
+				if err := table.WriteRow(ProductRow(p)); err != nil {
+					return err
+				}
+				rows++
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		return 0, err
+	}
+	return rows, table.Close()
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// jobIncludes reports whether a product export's filters keep p
+func jobIncludes(job *models.ExportJob, p models.Product) bool {
+	if job.ProductID != 0 && p.ID != job.ProductID {
+		return false
+	}
+	if job.Category != "" && !strings.EqualFold(p.Category, job.Category) {
+		return false
+	}
+	if job.PeriodStart != nil && p.CreatedAt.Before(*job.PeriodStart) {
+		return false
+	}
+	if job.PeriodEnd != nil && !p.CreatedAt.Before(*job.PeriodEnd) {
+		return false
+	}
+	return true
+}
+
+// Open opens a finished job's file, returning its size
+func (s *JobService) Open(job *models.ExportJob) (io.ReadCloser, int64, error) {
+	switch {
+	case job.Status == models.ExportJobStatusExpired,
+		job.Status == models.ExportJobStatusDone && job.ExpiresAt != nil && s.now().After(*job.ExpiresAt):
+		return nil, 0, ErrJobExpired
+	case job.Status != models.ExportJobStatusDone:
+		return nil, 0, ErrJobNotReady
+	}
+	f, size, err := s.config.Store.OpenStream(job.FilePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, ErrJobExpired
+	}
+	return f, size, err
+}
+
+// DownloadURL returns a finished job's download link, signed so it works
+// without logging in until the file expires
+func (s *JobService) DownloadURL(job *models.ExportJob) string {
+	if job.ExpiresAt == nil {
+		return ""
+	}
+	expires := job.ExpiresAt.Unix()
+	return fmt.Sprintf("%s/exports/%d?expires=%d&sig=%s", s.config.BaseURL, job.ID, expires, s.sign(job.ID, expires))
+}
+
+// VerifyDownload reports whether a download link's signature was issued
+// for the job and the link has not expired
+func (s *JobService) VerifyDownload(id uint, expires int64, sig string) bool {
+	if s.now().Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(s.sign(id, expires)))
+}
+
+func (s *JobService) sign(id uint, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(s.config.Secret))
+	fmt.Fprintf(mac, "export:%d:%d", id, expires)
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// Cleanup deletes the files of expired jobs and returns how many were
+// removed. Jobs stuck queued or running for jobStaleAfter are marked
+// failed.
+func (s *JobService) Cleanup() (int, error) {
+	now := s.now()
+	stale, err := s.repo.FailStale(now.Add(-jobStaleAfter), now, "export was interrupted; please try again")
+	if err != nil {
+		return 0, err
+	}
+	if stale > 0 {
+		log.Printf("⚠️ Gave up on %d interrupted exports", stale)
+	}
+
+	expired, err := s.repo.ListExpired(now)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for i := range expired {
+		job := &expired[i]
+		if err := s.config.Store.Delete(job.FilePath); err != nil {
+			log.Printf("⚠️ Failed to delete export %d file: %v", job.ID, err)
+			continue
+		}
+		job.Status = models.ExportJobStatusExpired
+		job.FilePath = ""
+		if err := s.repo.Update(job); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// notify tells the shop its export finished
+func (s *JobService) notify(job *models.ExportJob) {
+	if s.shopRepo == nil || (s.sendWhatsApp == nil && s.sendEmail == nil) {
+		return
+	}
+	shop, err := s.shopRepo.GetByID(job.ShopID)
+	if err != nil {
+		log.Printf("⚠️ Export job %d: failed to load shop: %v", job.ID, err)
+		return
+	}
+
+	subject := fmt.Sprintf("Your %s export is ready", job.Type)
+	message := fmt.Sprintf("📁 Your %s export is ready: %s (%d rows).\n\nDownload it within %d hours:\n%s",
+		job.Type, job.Filename, job.Rows, int(s.config.TTL.Hours()), s.DownloadURL(job))
+	if job.Status != models.ExportJobStatusDone {
+		subject = fmt.Sprintf("Your %s export failed", job.Type)
+		message = fmt.Sprintf("❌ Your %s export failed: %s\n\nPlease try again.", job.Type, job.Error)
+	}
+
+	if s.sendWhatsApp != nil && shop.Phone != "" {
+		if err := s.sendWhatsApp(shop.Phone, message); err != nil {
+			log.Printf("⚠️ Export job %d: failed to send WhatsApp: %v", job.ID, err)
+		}
+	}
+	if s.sendEmail != nil && shop.Email != "" {
+		if err := s.sendEmail(shop.Email, subject, message); err != nil {
+			log.Printf("⚠️ Export job %d: failed to send email: %v", job.ID, err)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Purchase order page layout in mm, on A4
+const (
+	orderMargin    = 15.0
+	orderHeader    = 30.0
+	orderRowHeight = 8.0
+)
+
+// PurchaseOrderData is what goes on a purchase order: the shop ordering,
+// and the order with its supplier and items loaded
+type PurchaseOrderData struct {
+	Shop  models.Shop
+	Order models.Order
+}
+
+type OrderExporter struct{}
+
+// ExportPDF renders a purchase order to send a supplier, on the shop's
+// letterhead in its brand colour
+func (e *OrderExporter) ExportPDF(data PurchaseOrderData) ([]byte, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(orderMargin, orderMargin, orderMargin)
+	pdf.SetAutoPageBreak(true, orderMargin)
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pageWidth, _ := pdf.GetPageSize()
+	width := pageWidth - 2*orderMargin
+	r, g, b := brandColor(data.Shop.BrandPrimaryColor)
+	order := data.Order
+	supplier := order.Supplier
+	currency := data.Shop.ReceiptCurrency
+	if currency == "" {
+		currency = "KSh"
+	}
+	name := data.Shop.BrandName
+	if name == "" {
+		name = data.Shop.Name
+	}
+
+	pdf.SetFillColor(r, g, b)
+	pdf.Rect(0, 0, pageWidth, orderHeader, "F")
+	pdf.SetTextColor(255, 255, 255)
+	pdf.SetXY(orderMargin, 7)
+	pdf.SetFont("Arial", "B", 20)
+	pdf.CellFormat(width*0.6, 9, tr(name), "", 0, "", false, 0, "")
+	pdf.SetFont("Arial", "B", 12)
+	pdf.CellFormat(width*0.4, 9, "PURCHASE ORDER", "", 1, "R", false, 0, "")
+	pdf.SetX(orderMargin)
+	pdf.SetFont("Arial", "", 9)
+	var contact []string
+	for _, s := range []string{data.Shop.Address, data.Shop.Phone, data.Shop.Email} {
+		if s != "" {
+			contact = append(contact, s)
+		}
+	}
+	pdf.CellFormat(width*0.7, 5, tr(strings.Join(contact, "  |  ")), "", 0, "", false, 0, "")
+	pdf.CellFormat(width*0.3, 5, fmt.Sprintf("PO #%d", order.ID), "", 1, "R", false, 0, "")
+	if data.Shop.VATNumber != "" {
+		pdf.SetX(orderMargin)
+		pdf.CellFormat(width, 5, tr("VAT No: "+data.Shop.VATNumber), "", 1, "", false, 0, "")
+	}
+	pdf.SetTextColor(0, 0, 0)
+
+	// Supplier on the left, order details on the right
+	top := orderHeader + 8
+	pdf.SetXY(orderMargin, top)
+	pdf.SetFont("Arial", "B", 10)
+	pdf.CellFormat(width/2, 6, "Supplier", "", 2, "", false, 0, "")
+	pdf.SetFont("Arial", "", 10)
+	for _, s := range []string{supplier.Name, supplier.Phone, supplier.Email, supplier.Address} {
+		if s != "" {
+			pdf.CellFormat(width/2, 5, tr(s), "", 2, "", false, 0, "")
+		}
+	}
+	supplierEnd := pdf.GetY()
+
+	details := [][2]string{
+		{"Order date", order.CreatedAt.Format("02 Jan 2006")},
+		{"Status", strings.ToUpper(order.Status)},
+	}
+	if order.ExpectedDelivery != nil {
+		details = append(details, [2]string{"Expected delivery", order.ExpectedDelivery.Format("02 Jan 2006")})
+	}
+	pdf.SetY(top)
+	for _, d := range details {
+		pdf.SetX(orderMargin + width/2)
+		pdf.SetFont("Arial", "B", 10)
+		pdf.CellFormat(width/4, 6, d[0], "", 0, "", false, 0, "")
+		pdf.SetFont("Arial", "", 10)
+		pdf.CellFormat(width/4, 6, d[1], "", 1, "R", false, 0, "")
+	}
+	if pdf.GetY() < supplierEnd {
+		pdf.SetY(supplierEnd)
+	}
+
+	pdf.Ln(8)
+	itemWidth := width * 0.5
+	qtyWidth := width * 0.12
+	costWidth := (width - itemWidth - qtyWidth) / 2
+	pdf.SetFont("Arial", "B", 10)
+	pdf.SetFillColor(240, 240, 240)
+	pdf.CellFormat(itemWidth, orderRowHeight, "Item", "B", 0, "", true, 0, "")
+	pdf.CellFormat(qtyWidth, orderRowHeight, "Qty", "B", 0, "C", true, 0, "")
+	pdf.CellFormat(costWidth, orderRowHeight, "Unit cost", "B", 0, "R", true, 0, "")
+	pdf.CellFormat(costWidth, orderRowHeight, "Total", "B", 1, "R", true, 0, "")
+
+	pdf.SetFont("Arial", "", 10)
+	var total float64
+	for _, item := range order.Items {
+		line := item.TotalCost
+		if line == 0 {
+			line = float64(item.Quantity) * item.UnitCost
+		}
+		total += line
+		pdf.CellFormat(itemWidth, orderRowHeight, tr(item.Product.Name), "", 0, "", false, 0, "")
+		unit := item.Product.Unit
+		qty := fmt.Sprintf("%d", item.Quantity)
+		if unit != "" {
+			qty += " " + unit
+		}
+		pdf.CellFormat(qtyWidth, orderRowHeight, tr(qty), "", 0, "C", false, 0, "")
+		pdf.CellFormat(costWidth, orderRowHeight, fmt.Sprintf("%s %.2f", currency, item.UnitCost), "", 0, "R", false, 0, "")
+		pdf.CellFormat(costWidth, orderRowHeight, fmt.Sprintf("%s %.2f", currency, line), "", 1, "R", false, 0, "")
+	}
+	if len(order.Items) == 0 {
+		pdf.SetFont("Arial", "I", 10)
+		pdf.CellFormat(width, orderRowHeight, "No items.", "", 1, "C", false, 0, "")
+	}
+	if order.TotalAmount > 0 {
+		total = order.TotalAmount
+	}
+
+	pdf.SetFont("Arial", "B", 11)
+	pdf.CellFormat(width-costWidth, 10, "TOTAL", "T", 0, "R", false, 0, "")
+	pdf.CellFormat(costWidth, 10, fmt.Sprintf("%s %.2f", currency, total), "T", 1, "R", false, 0, "")
+
+	if order.Notes != "" {
+		pdf.Ln(6)
+		pdf.SetFont("Arial", "B", 10)
+		pdf.CellFormat(width, 6, "Notes", "", 1, "", false, 0, "")
+		pdf.SetFont("Arial", "", 10)
+		pdf.MultiCell(width, 5, tr(order.Notes), "", "", false)
+	}
+
+	pdf.Ln(10)
+	pdf.SetFont("Arial", "I", 8)
+	pdf.MultiCell(width, 4, tr(fmt.Sprintf("Please quote PO #%d on your delivery note and invoice. Questions? Call %s on %s.", order.ID, name, data.Shop.Phone)), "", "C", false)
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
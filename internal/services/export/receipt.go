@@ -0,0 +1,117 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Receipt page size in mm, sized for 80mm roll printers
+const (
+	receiptWidth  = 80.0
+	receiptHeight = 160.0
+	receiptMargin = 4.0
+	receiptQRSize = 24.0
+)
+
+// ReceiptData is what goes on a single sale receipt
+type ReceiptData struct {
+	Shop   models.Shop
+	Sale   models.Sale
+	URL    string // link to the digital receipt, shown as text under the QR
+	QRCode []byte // PNG encoding URL
+}
+
+type ReceiptExporter struct{}
+
+// ExportPDF renders a receipt with the digital receipt QR code in the
+// bottom-right corner
+func (e *ReceiptExporter) ExportPDF(data ReceiptData) ([]byte, error) {
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{
+		OrientationStr: "P",
+		UnitStr:        "mm",
+		Size:           gofpdf.SizeType{Wd: receiptWidth, Ht: receiptHeight},
+	})
+	pdf.SetMargins(receiptMargin, receiptMargin, receiptMargin)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	width := receiptWidth - 2*receiptMargin
+	currency := data.Shop.ReceiptCurrency
+	if currency == "" {
+		currency = "KSh"
+	}
+	shopName := data.Shop.Name
+	if data.Shop.BrandName != "" {
+		shopName = data.Shop.BrandName
+	}
+
+	pdf.SetFont("Arial", "B", 12)
+	pdf.MultiCell(width, 6, tr(shopName), "", "C", false)
+	pdf.SetFont("Arial", "", 8)
+	if data.Shop.ReceiptHeader != "" {
+		pdf.MultiCell(width, 4, tr(data.Shop.ReceiptHeader), "", "C", false)
+	}
+	pdf.CellFormat(width, 4, data.Shop.Phone, "", 1, "C", false, 0, "")
+	if data.Shop.Address != "" {
+		pdf.MultiCell(width, 4, tr(data.Shop.Address), "", "C", false)
+	}
+	if data.Shop.ReceiptContact != "" {
+		pdf.MultiCell(width, 4, tr(data.Shop.ReceiptContact), "", "C", false)
+	}
+	if data.Shop.VATNumber != "" {
+		pdf.MultiCell(width, 4, tr("VAT No: "+data.Shop.VATNumber), "", "C", false)
+	}
+
+	pdf.Ln(2)
+	pdf.CellFormat(width, 4, fmt.Sprintf("Receipt #%d", data.Sale.ID), "B", 1, "", false, 0, "")
+	pdf.CellFormat(width, 5, data.Sale.CreatedAt.Format("02/01/2006 15:04"), "", 1, "", false, 0, "")
+
+	pdf.SetFont("Arial", "", 9)
+	pdf.MultiCell(width, 5, tr(data.Sale.Product.Name), "", "", false)
+	pdf.CellFormat(width/2, 5, fmt.Sprintf("%d x %s %.2f", data.Sale.Quantity, currency, data.Sale.UnitPrice), "", 0, "", false, 0, "")
+	pdf.CellFormat(width/2, 5, fmt.Sprintf("%s %.2f", currency, data.Sale.TotalAmount-data.Sale.RoundingAdjustment), "", 1, "R", false, 0, "")
+	if data.Sale.RoundingAdjustment != 0 {
+		pdf.CellFormat(width/2, 5, "Rounding", "", 0, "", false, 0, "")
+		pdf.CellFormat(width/2, 5, fmt.Sprintf("%s %.2f", currency, data.Sale.RoundingAdjustment), "", 1, "R", false, 0, "")
+	}
+
+	pdf.SetFont("Arial", "B", 11)
+	pdf.CellFormat(width/2, 7, "TOTAL", "T", 0, "", false, 0, "")
+	pdf.CellFormat(width/2, 7, fmt.Sprintf("%s %.2f", currency, data.Sale.TotalAmount), "T", 1, "R", false, 0, "")
+
+	pdf.SetFont("Arial", "", 8)
+	payment := fmt.Sprintf("Paid by %s", data.Sale.PaymentMethod)
+	if data.Sale.MpesaReceipt != "" {
+		payment += fmt.Sprintf(" (%s)", data.Sale.MpesaReceipt)
+	}
+	pdf.CellFormat(width, 5, payment, "", 1, "", false, 0, "")
+
+	footer := data.Shop.ReceiptFooter
+	if footer == "" {
+		footer = "Thank you for shopping with us!"
+	}
+	pdf.Ln(2)
+	pdf.MultiCell(width, 4, tr(footer), "", "C", false)
+
+	qrX := receiptWidth - receiptMargin - receiptQRSize
+	qrY := receiptHeight - receiptMargin - receiptQRSize
+	if len(data.QRCode) > 0 {
+		opts := gofpdf.ImageOptions{ImageType: "PNG"}
+		pdf.RegisterImageOptionsReader("receipt-qr", opts, bytes.NewReader(data.QRCode))
+		pdf.ImageOptions("receipt-qr", qrX, qrY, receiptQRSize, receiptQRSize, false, opts, 0, data.URL)
+
+		pdf.SetFont("Arial", "I", 7)
+		pdf.SetXY(receiptMargin, qrY+receiptQRSize/2-4)
+		pdf.MultiCell(qrX-2*receiptMargin, 4, "Scan for your digital receipt", "", "R", false)
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,83 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
+)
+
+type ReconciliationExporter struct{}
+
+func (e *ReconciliationExporter) Export(report *mpesa.ReconciliationReport, format Format) ([]byte, error) {
+	switch format {
+	case FormatJSON:
+		return json.MarshalIndent(report, "", "  ")
+	default:
+		return e.exportCSV(report)
+	}
+}
+
+// exportCSV writes one row per receipt followed by the summary totals
+func (e *ReconciliationExporter) exportCSV(report *mpesa.ReconciliationReport) ([]byte, error) {
+	var builder strings.Builder
+	writer := csv.NewWriter(&builder)
+
+	header := []string{"Status", "Receipt", "Source", "Phone", "Paid At", "Payment Amount", "Sold At", "Sale Amount", "Difference", "Sale IDs"}
+	if err := writer.Write(header); err != nil {
+		return nil, err
+	}
+
+	for _, r := range report.Rows {
+		saleIDs := make([]string, len(r.SaleIDs))
+		for i, id := range r.SaleIDs {
+			saleIDs[i] = fmt.Sprintf("%d", id)
+		}
+		row := []string{
+			string(r.Status),
+			r.Receipt,
+			r.Source,
+			r.Phone,
+			formatOptionalTime(r.PaidAt),
+			fmt.Sprintf("%.2f", r.PaymentAmount),
+			formatOptionalTime(r.SoldAt),
+			fmt.Sprintf("%.2f", r.SaleAmount),
+			fmt.Sprintf("%.2f", r.Difference),
+			strings.Join(saleIDs, " "),
+		}
+		if err := writer.Write(row); err != nil {
+			return nil, err
+		}
+	}
+
+	s := report.Summary
+	summary := [][]string{
+		{},
+		{"SUMMARY"},
+		{"From", s.From.Format("2006-01-02")},
+		{"To", s.To.AddDate(0, 0, -1).Format("2006-01-02")},
+		{"Payments", fmt.Sprintf("%d", s.PaymentCount), fmt.Sprintf("%.2f", s.PaymentTotal)},
+		{"M-Pesa Sales", fmt.Sprintf("%d", s.SaleCount), fmt.Sprintf("%.2f", s.SaleTotal)},
+		{"Matched", fmt.Sprintf("%d", s.MatchedCount), fmt.Sprintf("%.2f", s.MatchedTotal)},
+		{"Amount Mismatch", fmt.Sprintf("%d", s.MismatchCount)},
+		{"Unmatched Payments", fmt.Sprintf("%d", s.UnmatchedPaymentCount), fmt.Sprintf("%.2f", s.UnmatchedPaymentTotal)},
+		{"Unmatched Sales", fmt.Sprintf("%d", s.UnmatchedSaleCount), fmt.Sprintf("%.2f", s.UnmatchedSaleTotal)},
+		{"Difference", "", fmt.Sprintf("%.2f", s.Difference)},
+	}
+	if err := writer.WriteAll(summary); err != nil {
+		return nil, err
+	}
+
+	writer.Flush()
+	return []byte(builder.String()), writer.Error()
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("2006-01-02 15:04")
+}
@@ -0,0 +1,171 @@
+package export
+
+import (
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+)
+
+// Record exports hold a shop's customers, loyalty points, M-Pesa payments,
+// suppliers and purchase orders for an accountant or to move to another
+// system, so every row carries IDs and timestamps. The columns and rows
+// below are written through a TableWriter as csv, json, jsonl or xlsx
+// tables with the same headings as the streamed sales and product exports.
+
+// timestampLayout keeps seconds so records made in the same minute still
+// sort
+const timestampLayout = "2006-01-02 15:04:05"
+
+func timestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(timestampLayout)
+}
+
+// optionalTimestamp and optionalID leave unset values empty in CSV and
+// spreadsheets and null in JSON
+func optionalTimestamp(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return timestamp(*t)
+}
+
+func optionalID(id *uint) interface{} {
+	if id == nil {
+		return nil
+	}
+	return *id
+}
+
+// CustomerColumns are the columns of a customer export
+var CustomerColumns = []Column{
+	{"ID", "id"}, {"Name", "name"}, {"Phone", "phone"}, {"Email", "email"}, {"Address", "address"},
+	{"Date of Birth", "date_of_birth"}, {"Tier", "tier"}, {"Points", "loyalty_points"},
+	{"Points Earned", "points_earned"}, {"Points Redeemed", "points_redeemed"},
+	{"Total Spent", "total_spent"}, {"Purchases", "total_purchases"}, {"Last Purchase", "last_purchase_at"},
+	{"Referral Code", "referral_code"}, {"Referred By", "referred_by"}, {"SMS Opt Out", "sms_opt_out"},
+	{"Active", "is_active"}, {"Notes", "notes"}, {"Created", "created_at"}, {"Updated", "updated_at"},
+}
+
+// CustomerRow returns a customer's values in CustomerColumns order
+func CustomerRow(c models.Customer) []interface{} {
+	var birthday interface{}
+	if c.DateOfBirth != nil {
+		birthday = c.DateOfBirth.Format("2006-01-02")
+	}
+	return []interface{}{
+		c.ID, c.Name, c.Phone, c.Email, c.Address, birthday, string(c.Tier), c.LoyaltyPoints,
+		c.PointsEarned, c.PointsRedeemed, c.TotalSpent, c.TotalPurchases, optionalTimestamp(c.LastPurchaseAt),
+		c.ReferralCode, optionalID(c.ReferredBy), c.SMSOptOut, c.IsActive, c.Notes,
+		timestamp(c.CreatedAt), timestamp(c.UpdatedAt),
+	}
+}
+
+// LoyaltyTransactionColumns are the columns of a loyalty points export
+var LoyaltyTransactionColumns = []Column{
+	{"ID", "id"}, {"Date", "created_at"}, {"Customer ID", "customer_id"}, {"Customer", "customer_name"},
+	{"Sale ID", "sale_id"}, {"Type", "type"}, {"Points", "points"}, {"Points Before", "points_before"},
+	{"Points After", "points_after"}, {"Amount", "amount"}, {"Description", "description"},
+	{"Reference", "reference"}, {"Expires", "expires_at"}, {"Redeemed", "redeemed_at"},
+}
+
+// LoyaltyTransactionRow returns a loyalty transaction's values in
+// LoyaltyTransactionColumns order
+func LoyaltyTransactionRow(t models.LoyaltyTransaction) []interface{} {
+	return []interface{}{
+		t.ID, timestamp(t.CreatedAt), t.CustomerID, t.Customer.Name, optionalID(t.SaleID), string(t.Type),
+		t.Points, t.PointsBefore, t.PointsAfter, t.Amount, t.Description, t.Reference,
+		optionalTimestamp(t.ExpiresAt), optionalTimestamp(t.RedeemedAt),
+	}
+}
+
+// MpesaPaymentColumns are the columns of an M-Pesa payment (STK push)
+// export
+var MpesaPaymentColumns = []Column{
+	{"ID", "id"}, {"Created", "created_at"}, {"Phone", "phone"}, {"Amount", "amount"},
+	{"Amount Paid", "amount_paid"}, {"Mismatch", "amount_mismatch"}, {"Payer Phone", "payer_phone"},
+	{"Status", "status"}, {"Receipt", "mpesa_receipt"}, {"Transaction ID", "mpesa_transaction_id"},
+	{"Account Reference", "account_reference"}, {"Description", "description"},
+	{"Merchant Request ID", "merchant_request_id"}, {"Checkout Request ID", "checkout_request_id"},
+	{"Failure Reason", "failure_reason"}, {"Retries", "retry_count"}, {"Product ID", "product_id"},
+	{"Sale ID", "sale_id"}, {"Pending Sale ID", "pending_sale_id"}, {"Payment Link ID", "payment_link_id"},
+	{"Plan", "plan"}, {"Completed", "completed_at"}, {"Expires", "expires_at"}, {"Updated", "updated_at"},
+}
+
+// MpesaPaymentRow returns a payment's values in MpesaPaymentColumns order
+func MpesaPaymentRow(p models.MpesaPayment) []interface{} {
+	return []interface{}{
+		p.ID, timestamp(p.CreatedAt), p.Phone, p.Amount, p.AmountPaid, p.AmountMismatch, p.PayerPhone,
+		string(p.Status), p.MpesaReceipt, p.MpesaTransactionID, p.AccountReference, p.Description,
+		p.MerchantRequestID, p.CheckoutRequestID, p.FailureReason, p.RetryCount, optionalID(p.ProductID),
+		optionalID(p.SaleID), optionalID(p.PendingSaleID), optionalID(p.PaymentLinkID), string(p.Plan),
+		optionalTimestamp(p.CompletedAt), timestamp(p.ExpiresAt), timestamp(p.UpdatedAt),
+	}
+}
+
+// MpesaTransactionColumns are the columns of an M-Pesa transaction export:
+// STK, paybill and B2C money movements as Daraja reported them
+var MpesaTransactionColumns = []Column{
+	{"ID", "id"}, {"Transaction Time", "transaction_time"}, {"Type", "type"}, {"Amount", "amount"},
+	{"Phone", "phone"}, {"Customer", "customer_name"}, {"Transaction ID", "transaction_id"},
+	{"Receipt", "receipt_number"}, {"Account Reference", "account_reference"}, {"Status", "status"},
+	{"Sale ID", "sale_id"}, {"Reversal Status", "reversal_status"}, {"Reversal Reason", "reversal_reason"},
+	{"Reversal Receipt", "reversal_receipt"}, {"Reversed", "reversed_at"}, {"Created", "created_at"},
+}
+
+// MpesaTransactionRow returns a transaction's values in
+// MpesaTransactionColumns order
+func MpesaTransactionRow(t models.MpesaTransaction) []interface{} {
+	return []interface{}{
+		t.ID, timestamp(t.TransactionTime), t.Type, t.Amount, t.Phone, t.CustomerName, t.TransactionID,
+		t.Receipt(), t.AccountReference, t.Status, optionalID(t.SaleID), t.ReversalStatus, t.ReversalReason,
+		t.ReversalReceipt, optionalTimestamp(t.ReversedAt), timestamp(t.CreatedAt),
+	}
+}
+
+// SupplierColumns are the columns of a supplier export
+var SupplierColumns = []Column{
+	{"ID", "id"}, {"Name", "name"}, {"Phone", "phone"}, {"Email", "email"}, {"Address", "address"},
+	{"Created", "created_at"}, {"Updated", "updated_at"},
+}
+
+// SupplierRow returns a supplier's values in SupplierColumns order
+func SupplierRow(s models.Supplier) []interface{} {
+	return []interface{}{
+		s.ID, s.Name, s.Phone, s.Email, s.Address, timestamp(s.CreatedAt), timestamp(s.UpdatedAt),
+	}
+}
+
+// PurchaseOrderColumns are the columns of a purchase order export, a row
+// per item with its order's details repeated
+var PurchaseOrderColumns = []Column{
+	{"Order ID", "order_id"}, {"Created", "created_at"}, {"Supplier ID", "supplier_id"},
+	{"Supplier", "supplier_name"}, {"Status", "status"}, {"Payment Status", "payment_status"},
+	{"B2C Payout ID", "b2c_payout_id"}, {"Order Total", "total_amount"}, {"Notes", "notes"},
+	{"Item ID", "item_id"}, {"Product ID", "product_id"}, {"Product", "product_name"},
+	{"Quantity", "quantity"}, {"Unit Cost", "unit_cost"}, {"Item Total", "total_cost"},
+	{"Updated", "updated_at"},
+}
+
+// PurchaseOrderRows returns an order's rows in PurchaseOrderColumns order,
+// one per item; an order without items still gets a row
+func PurchaseOrderRows(o models.Order) [][]interface{} {
+	order := []interface{}{
+		o.ID, timestamp(o.CreatedAt), o.SupplierID, o.Supplier.Name, o.Status, o.PaymentStatus,
+		optionalID(o.B2CPayoutID), o.TotalAmount, o.Notes,
+	}
+	row := func(item ...interface{}) []interface{} {
+		values := append(append([]interface{}{}, order...), item...)
+		return append(values, timestamp(o.UpdatedAt))
+	}
+	if len(o.Items) == 0 {
+		return [][]interface{}{row(nil, nil, nil, nil, nil, nil)}
+	}
+	rows := make([][]interface{}, 0, len(o.Items))
+	for _, item := range o.Items {
+		rows = append(rows, row(item.ID, item.ProductID, item.Product.Name, item.Quantity, item.UnitCost, item.TotalCost))
+	}
+	return rows
+}
@@ -16,10 +16,11 @@ import (
 type Format string
 
 const (
-	FormatCSV   Format = "csv"
-	FormatJSON  Format = "json"
-	FormatExcel Format = "excel"
-	FormatPDF   Format = "pdf"
+	FormatCSV       Format = "csv"
+	FormatJSON      Format = "json"
+	FormatJSONLines Format = "jsonl" // one JSON object per line
+	FormatExcel     Format = "excel"
+	FormatPDF       Format = "pdf"
 )
 
 type ProductExporter struct{}
@@ -30,6 +31,12 @@ func (e *ProductExporter) Export(products []models.Product, format Format) ([]by
 		return e.exportCSV(products)
 	case FormatJSON:
 		return e.exportJSON(products)
+	case FormatJSONLines:
+		rows := make([][]interface{}, len(products))
+		for i, p := range products {
+			rows[i] = ProductRow(p)
+		}
+		return tableBytes(format, ProductColumns, rows)
 	case FormatExcel:
 		return e.exportExcel(products)
 	case FormatPDF:
@@ -39,12 +46,46 @@ func (e *ProductExporter) Export(products []models.Product, format Format) ([]by
 	}
 }
 
+// templateProducts are the example rows of the product import template
+var templateProducts = []models.Product{
+	{Name: "Milk", Category: "Dairy", Unit: "packet", CostPrice: 50, SellingPrice: 60, CurrentStock: 24, LowStockThreshold: 6},
+	{Name: "Bread", Category: "Bakery", Unit: "loaf", CostPrice: 55, SellingPrice: 65, CurrentStock: 15, LowStockThreshold: 5, Barcode: "6161100100014"},
+	{Name: "Sugar", Category: "Groceries", Unit: "kg", CostPrice: 150, SellingPrice: 180, CurrentStock: 20, LowStockThreshold: 5},
+}
+
+// templateGuide is the comment row heading the CSV template, one note per
+// column
+const templateGuide = "# ID: leave 0 to add a product; " +
+	"Name: as customers ask for it; " +
+	"Category: optional, e.g. Dairy; " +
+	"Unit: what one is sold as, e.g. packet or kg; " +
+	"Cost Price: KSh paid per unit; " +
+	"Selling Price: KSh charged per unit; " +
+	"Stock: units in the shop now; " +
+	"Low Stock Threshold: alert at or below this; " +
+	"Barcode: optional\n"
+
+// Template is a product import file in format with the export's columns,
+// three example rows and, in CSV, a comment row explaining each column
+func (e *ProductExporter) Template(format Format) ([]byte, error) {
+	switch format {
+	case FormatCSV:
+		data, err := e.exportCSV(templateProducts)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(templateGuide), data...), nil
+	case FormatExcel:
+		return e.exportExcel(templateProducts)
+	}
+	return nil, fmt.Errorf("no %s import template; use csv or excel", format)
+}
+
 func (e *ProductExporter) exportCSV(products []models.Product) ([]byte, error) {
 	var builder strings.Builder
 	writer := csv.NewWriter(&builder)
 
-	header := []string{"ID", "Name", "Category", "Unit", "Cost Price", "Selling Price", "Stock", "Low Stock Threshold", "Barcode"}
-	if err := writer.Write(header); err != nil {
+	if err := writer.Write(ProductHeader); err != nil {
 		return nil, err
 	}
 
@@ -171,7 +212,7 @@ func (e *ProductExporter) exportExcel(products []models.Product) ([]byte, error)
 func (e *ProductExporter) InventoryValue(products []models.Product) float64 {
 	var total float64
 	for _, p := range products {
-		total += p.SellingPrice * float64(p.CurrentStock)
+		total += p.SellingPrice * float64(p.StockOnHand())
 	}
 	return total
 }
@@ -184,6 +225,12 @@ func (e *SalesExporter) Export(sales []models.Sale, format Format) ([]byte, erro
 		return e.exportCSV(sales)
 	case FormatJSON:
 		return e.exportJSON(sales)
+	case FormatJSONLines:
+		rows := make([][]interface{}, len(sales))
+		for i, s := range sales {
+			rows[i] = SaleRow(s)
+		}
+		return tableBytes(format, SaleColumns, rows)
 	case FormatExcel:
 		return e.exportExcel(sales)
 	case FormatPDF:
@@ -396,12 +443,14 @@ func (e *SalesExporter) ProfitSummary(sales []models.Sale) map[string]interface{
 type ReportExporter struct{}
 
 type DailyReportData struct {
+	Title            string        `json:"title,omitempty"` // PDF heading; defaults to "Daily Sales Report"
 	Date             string        `json:"date"`
 	TotalSales       float64       `json:"total_sales"`
 	TotalProfit      float64       `json:"total_profit"`
 	TransactionCount int           `json:"transaction_count"`
 	AverageSale      float64       `json:"average_sale"`
 	TopProducts      []ProductSale `json:"top_products"`
+	Comparison       *Comparison   `json:"comparison,omitempty"` // against the period before, when set
 }
 
 type ProductSale struct {
@@ -459,6 +508,20 @@ func (e *ReportExporter) exportDailyCSV(report DailyReportData) ([]byte, error)
 		return nil, err
 	}
 
+	if c := report.Comparison; c != nil {
+		rows := [][]string{
+			{"Compared With", c.Period},
+			{"Metric", "Previous", "Change"},
+			{"Total Sales", fmt.Sprintf("KSh %.2f", c.PreviousSales), signedChange(c.SalesChange)},
+			{"Total Profit", fmt.Sprintf("KSh %.2f", c.PreviousProfit), signedChange(c.ProfitChange)},
+			{"Transactions", fmt.Sprintf("%d", c.PreviousTransactions), signedChange(c.TransactionsChange)},
+			{},
+		}
+		if err := writer.WriteAll(rows); err != nil {
+			return nil, err
+		}
+	}
+
 	if err := writer.Write([]string{"Top Products"}); err != nil {
 		return nil, err
 	}
@@ -507,18 +570,40 @@ func (e *ReportExporter) exportDailyExcel(report DailyReportData) ([]byte, error
 	f.SetCellValue("Sheet1", "A8", "Average Sale")
 	f.SetCellValue("Sheet1", "B8", fmt.Sprintf("KSh %.2f", report.AverageSale))
 
-	f.SetCellValue("Sheet1", "A10", "Top Products")
 	productStyle, _ := f.NewStyle(&excelize.Style{
 		Font: &excelize.Font{Bold: true},
 	})
-	f.SetCellStyle("Sheet1", "A10", "A10", productStyle)
-
-	f.SetCellValue("Sheet1", "A11", "Product")
-	f.SetCellValue("Sheet1", "B11", "Quantity")
-	f.SetCellValue("Sheet1", "C11", "Revenue")
-	f.SetCellStyle("Sheet1", "A11", "C11", headerStyle)
 
-	row := 12
+	row := 10
+	if c := report.Comparison; c != nil {
+		f.SetCellValue("Sheet1", "A10", "Compared With")
+		f.SetCellValue("Sheet1", "B10", c.Period)
+		f.SetCellStyle("Sheet1", "A10", "A10", productStyle)
+		f.SetCellValue("Sheet1", "A11", "Metric")
+		f.SetCellValue("Sheet1", "B11", "Previous")
+		f.SetCellValue("Sheet1", "C11", "Change")
+		f.SetCellStyle("Sheet1", "A11", "C11", headerStyle)
+		f.SetCellValue("Sheet1", "A12", "Total Sales")
+		f.SetCellValue("Sheet1", "B12", fmt.Sprintf("KSh %.2f", c.PreviousSales))
+		f.SetCellValue("Sheet1", "C12", signedChange(c.SalesChange))
+		f.SetCellValue("Sheet1", "A13", "Total Profit")
+		f.SetCellValue("Sheet1", "B13", fmt.Sprintf("KSh %.2f", c.PreviousProfit))
+		f.SetCellValue("Sheet1", "C13", signedChange(c.ProfitChange))
+		f.SetCellValue("Sheet1", "A14", "Transactions")
+		f.SetCellValue("Sheet1", "B14", c.PreviousTransactions)
+		f.SetCellValue("Sheet1", "C14", signedChange(c.TransactionsChange))
+		row = 16
+	}
+
+	f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), "Top Products")
+	f.SetCellStyle("Sheet1", fmt.Sprintf("A%d", row), fmt.Sprintf("A%d", row), productStyle)
+
+	f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row+1), "Product")
+	f.SetCellValue("Sheet1", fmt.Sprintf("B%d", row+1), "Quantity")
+	f.SetCellValue("Sheet1", fmt.Sprintf("C%d", row+1), "Revenue")
+	f.SetCellStyle("Sheet1", fmt.Sprintf("A%d", row+1), fmt.Sprintf("C%d", row+1), headerStyle)
+
+	row += 2
 	for _, p := range report.TopProducts {
 		f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), p.Name)
 		f.SetCellValue("Sheet1", fmt.Sprintf("B%d", row), p.Quantity)
@@ -541,8 +626,12 @@ func (e *ReportExporter) exportDailyPDF(report DailyReportData) ([]byte, error)
 	pdf := gofpdf.New("P", "mm", "A4", "")
 	pdf.AddPage()
 
+	title := report.Title
+	if title == "" {
+		title = "Daily Sales Report"
+	}
 	pdf.SetFont("Arial", "B", 18)
-	pdf.Cell(190, 15, "Daily Sales Report")
+	pdf.Cell(190, 15, title)
 	pdf.Ln(12)
 
 	pdf.SetFont("Arial", "", 12)
@@ -571,6 +660,31 @@ func (e *ReportExporter) exportDailyPDF(report DailyReportData) ([]byte, error)
 		pdf.Ln(-1)
 	}
 
+	if c := report.Comparison; c != nil {
+		pdf.Ln(6)
+		pdf.SetFont("Arial", "B", 14)
+		pdf.Cell(190, 10, "Compared With "+c.Period)
+		pdf.Ln(8)
+
+		pdf.SetFont("Arial", "B", 10)
+		pdf.Cell(60, 8, "Metric")
+		pdf.Cell(65, 8, "Previous")
+		pdf.Cell(65, 8, "Change")
+		pdf.Ln(-1)
+
+		pdf.SetFont("Arial", "", 10)
+		for _, r := range [][3]string{
+			{"Total Sales", fmt.Sprintf("KSh %.2f", c.PreviousSales), signedChange(c.SalesChange)},
+			{"Total Profit", fmt.Sprintf("KSh %.2f", c.PreviousProfit), signedChange(c.ProfitChange)},
+			{"Transactions", fmt.Sprintf("%d", c.PreviousTransactions), signedChange(c.TransactionsChange)},
+		} {
+			pdf.Cell(60, 7, r[0])
+			pdf.Cell(65, 7, r[1])
+			pdf.Cell(65, 7, r[2])
+			pdf.Ln(-1)
+		}
+	}
+
 	pdf.Ln(10)
 	pdf.SetFont("Arial", "B", 14)
 	pdf.Cell(190, 10, "Top Products")
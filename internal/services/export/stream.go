@@ -0,0 +1,278 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/xuri/excelize/v2"
+)
+
+// Column is a column of a streamed table: its CSV and spreadsheet heading
+// and its JSON key
+type Column struct {
+	Title string
+	Key   string
+}
+
+// SaleColumns are the columns of a streamed sales export, matching
+// SalesExporter's
+var SaleColumns = []Column{
+	{"ID", "id"}, {"Date", "date"}, {"Product", "product_name"}, {"Quantity", "quantity"},
+	{"Unit Price", "unit_price"}, {"Total", "total_amount"}, {"Cost", "cost_amount"},
+	{"Profit", "profit"}, {"Payment Method", "payment_method"}, {"Receipt", "mpesa_receipt"},
+}
+
+// SaleRow returns a sale's values in SaleColumns order
+func SaleRow(s models.Sale) []interface{} {
+	return []interface{}{
+		s.ID, s.CreatedAt.Format("2006-01-02 15:04"), s.Product.Name, s.Quantity,
+		s.UnitPrice, s.TotalAmount, s.CostAmount, s.Profit, string(s.PaymentMethod), s.MpesaReceipt,
+	}
+}
+
+// ProductColumns are the columns of a streamed product export, matching
+// ProductExporter's CSV
+var ProductColumns = []Column{
+	{"ID", "id"}, {"Name", "name"}, {"Category", "category"}, {"Unit", "unit"},
+	{"Cost Price", "cost_price"}, {"Selling Price", "selling_price"}, {"Stock", "current_stock"},
+	{"Low Stock Threshold", "low_stock_threshold"}, {"Barcode", "barcode"},
+}
+
+// ProductRow returns a product's values in ProductColumns order
+func ProductRow(p models.Product) []interface{} {
+	return []interface{}{
+		p.ID, p.Name, p.Category, p.Unit, p.CostPrice, p.SellingPrice,
+		p.CurrentStock, p.LowStockThreshold, p.Barcode,
+	}
+}
+
+// TableWriter writes an export a row at a time, so a large one can go
+// straight to a file
+type TableWriter interface {
+	WriteRow(values []interface{}) error
+	// Close finishes the table; it does not close the underlying writer
+	Close() error
+}
+
+// NewTableWriter starts a table of columns in format on w. PDF tables are
+// laid out as a whole, so they cannot be streamed.
+func NewTableWriter(w io.Writer, format Format, columns []Column) (TableWriter, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVTable(w, columns)
+	case FormatJSON:
+		return newJSONTable(w, columns), nil
+	case FormatJSONLines:
+		return newJSONLinesTable(w, columns), nil
+	case FormatExcel:
+		return newExcelTable(w, columns)
+	}
+	return nil, fmt.Errorf("%s exports cannot be streamed", format)
+}
+
+type csvTable struct {
+	w *csv.Writer
+}
+
+func newCSVTable(w io.Writer, columns []Column) (*csvTable, error) {
+	t := &csvTable{w: csv.NewWriter(w)}
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.Title
+	}
+	return t, t.w.Write(header)
+}
+
+func (t *csvTable) WriteRow(values []interface{}) error {
+	row := make([]string, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case nil:
+		case float64:
+			row[i] = fmt.Sprintf("%.2f", v)
+		default:
+			row[i] = fmt.Sprint(v)
+		}
+	}
+	return t.w.Write(row)
+}
+
+func (t *csvTable) Close() error {
+	t.w.Flush()
+	return t.w.Error()
+}
+
+// jsonTable writes an array of objects, one per row, keyed in column
+// order
+type jsonTable struct {
+	w       *bufio.Writer
+	keys    [][]byte
+	started bool
+}
+
+func newJSONTable(w io.Writer, columns []Column) *jsonTable {
+	t := &jsonTable{w: bufio.NewWriter(w)}
+	for _, col := range columns {
+		key, _ := json.Marshal(col.Key)
+		t.keys = append(t.keys, key)
+	}
+	return t
+}
+
+func (t *jsonTable) WriteRow(values []interface{}) error {
+	if t.started {
+		t.w.WriteString(",\n  ")
+	} else {
+		t.w.WriteString("[\n  ")
+		t.started = true
+	}
+	return t.writeObject(values)
+}
+
+// writeObject writes a row as an object on one line
+func (t *jsonTable) writeObject(values []interface{}) error {
+	t.w.WriteByte('{')
+	for i, v := range values {
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			t.w.WriteByte(',')
+		}
+		t.w.Write(t.keys[i])
+		t.w.WriteByte(':')
+		_, err = t.w.Write(value)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := t.w.WriteString("}")
+	return err
+}
+
+func (t *jsonTable) Close() error {
+	if t.started {
+		t.w.WriteString("\n]\n")
+	} else {
+		t.w.WriteString("[]\n")
+	}
+	return t.w.Flush()
+}
+
+// jsonLinesTable writes one object per line, keyed in column order, so a
+// reader can start on the first row before the last is written
+type jsonLinesTable struct {
+	*jsonTable
+}
+
+func newJSONLinesTable(w io.Writer, columns []Column) *jsonLinesTable {
+	return &jsonLinesTable{newJSONTable(w, columns)}
+}
+
+func (t *jsonLinesTable) WriteRow(values []interface{}) error {
+	if err := t.writeObject(values); err != nil {
+		return err
+	}
+	return t.w.WriteByte('\n')
+}
+
+func (t *jsonLinesTable) Close() error {
+	return t.w.Flush()
+}
+
+// excelTable writes a worksheet through excelize's stream writer, which
+// keeps large sheets on disk rather than in memory
+type excelTable struct {
+	out  io.Writer
+	file *excelize.File
+	sw   *excelize.StreamWriter
+	row  int
+}
+
+func newExcelTable(w io.Writer, columns []Column) (*excelTable, error) {
+	f := excelize.NewFile()
+	sw, err := f.NewStreamWriter("Sheet1")
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	style, _ := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"#00A650"}, Pattern: 1},
+	})
+	header := make([]interface{}, len(columns))
+	for i, col := range columns {
+		header[i] = excelize.Cell{StyleID: style, Value: col.Title}
+	}
+	t := &excelTable{out: w, file: f, sw: sw, row: 1}
+	if err := t.WriteRow(header); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *excelTable) WriteRow(values []interface{}) error {
+	cell, err := excelize.CoordinatesToCellName(1, t.row)
+	if err != nil {
+		return err
+	}
+	t.row++
+	return t.sw.SetRow(cell, values)
+}
+
+func (t *excelTable) Close() error {
+	defer t.file.Close()
+	if err := t.sw.Flush(); err != nil {
+		return err
+	}
+	return t.file.Write(t.out)
+}
+
+// StreamSales writes the sales each yields, a batch at a time, to w as a
+// format table and returns how many it wrote. Only the batch being written
+// is held in memory, however many sales there are.
+func StreamSales(w io.Writer, format Format, each func(fn func([]models.Sale) error) error) (int, error) {
+	table, err := NewTableWriter(w, format, SaleColumns)
+	if err != nil {
+		return 0, err
+	}
+	rows := 0
+	err = each(func(batch []models.Sale) error {
+		for _, sale := range batch {
+			if err := table.WriteRow(SaleRow(sale)); err != nil {
+				return err
+			}
+			rows++
+		}
+		return nil
+	})
+	if err != nil {
+		return rows, err
+	}
+	return rows, table.Close()
+}
+
+// tableBytes writes rows as a format table in memory
+func tableBytes(format Format, columns []Column, rows [][]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	table, err := NewTableWriter(&buf, format, columns)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if err := table.WriteRow(row); err != nil {
+			return nil, err
+		}
+	}
+	if err := table.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
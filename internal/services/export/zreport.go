@@ -0,0 +1,133 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/zreport"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// zReportMargin is the Z-report page margin in mm, on A4
+const zReportMargin = 20.0
+
+type ZReportExporter struct{}
+
+// ExportPDF renders an end-of-day Z-report for the shop's records: the
+// totals by payment method, the cash drawer and the sales by staff member
+func (e *ZReportExporter) ExportPDF(r *zreport.Report) ([]byte, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(zReportMargin, zReportMargin, zReportMargin)
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pageWidth, _ := pdf.GetPageSize()
+	width := pageWidth - 2*zReportMargin
+	money := func(amount float64) string { return fmt.Sprintf("KSh %.2f", amount) }
+	line := func(label, value string) {
+		pdf.CellFormat(width*0.6, 7, label, "", 0, "", false, 0, "")
+		pdf.CellFormat(width*0.4, 7, value, "", 1, "R", false, 0, "")
+	}
+	heading := func(text string) {
+		pdf.Ln(4)
+		pdf.SetFont("Arial", "B", 11)
+		pdf.SetFillColor(240, 240, 240)
+		pdf.CellFormat(width, 8, text, "B", 1, "", true, 0, "")
+		pdf.SetFont("Arial", "", 10)
+	}
+
+	title := fmt.Sprintf("Z-REPORT #%d", r.Number)
+	if !r.Closed {
+		title = "Z-REPORT (NOT CLOSED)"
+	}
+	pdf.SetFont("Arial", "B", 16)
+	pdf.CellFormat(width, 8, title, "", 1, "", false, 0, "")
+	pdf.SetFont("Arial", "", 11)
+	pdf.CellFormat(width, 6, tr(r.ShopName), "", 1, "", false, 0, "")
+	pdf.SetFont("Arial", "", 9)
+	pdf.CellFormat(width, 5, fmt.Sprintf("Business day %s, %s to %s", r.BusinessDate.Format("Mon 02 Jan 2006"),
+		r.PeriodStart.Format("02 Jan 15:04"), r.PeriodEnd.Format("02 Jan 15:04")), "", 1, "", false, 0, "")
+
+	heading("Sales")
+	line("Cash", money(r.CashSales))
+	line("M-Pesa", money(r.MpesaSales))
+	line("Card and bank", money(r.OtherSales))
+	line("Gross sales", money(r.GrossSales))
+	if r.Discounts != 0 {
+		line("Discounts given (loyalty points)", money(r.Discounts))
+	}
+	line(fmt.Sprintf("Refunds (%d M-Pesa reversals)", r.RefundCount), "-"+money(r.Refunds))
+	pdf.SetFont("Arial", "B", 11)
+	pdf.CellFormat(width*0.6, 8, "NET SALES", "T", 0, "", false, 0, "")
+	pdf.CellFormat(width*0.4, 8, money(r.NetSales), "T", 1, "R", false, 0, "")
+	pdf.SetFont("Arial", "", 10)
+	line("Transactions", fmt.Sprintf("%d", r.Transactions))
+	line("Expenses", "-"+money(r.Expenses))
+	pdf.SetFont("Arial", "B", 11)
+	pdf.CellFormat(width*0.6, 8, "NET", "T", 0, "", false, 0, "")
+	pdf.CellFormat(width*0.4, 8, money(r.Net), "T", 1, "R", false, 0, "")
+	pdf.SetFont("Arial", "", 10)
+
+	heading("Cash drawer")
+	line("Opening float", money(r.OpeningFloat))
+	line("Cash sales", money(r.CashSales))
+	if r.CashExpenses != 0 {
+		line("Expenses paid in cash", "-"+money(r.CashExpenses))
+	}
+	line("Expected in drawer", money(r.ExpectedCash))
+	if r.CountedCash != nil && r.Variance != nil {
+		line("Counted", money(*r.CountedCash))
+		if *r.Variance < 0 {
+			line("Short", money(-*r.Variance))
+		} else {
+			line("Over", money(*r.Variance))
+		}
+	}
+
+	if len(r.ByCategory) > 0 {
+		heading("By category")
+		for _, category := range r.ByCategory {
+			line(tr(fmt.Sprintf("%s (%d sold)", category.Category, category.Quantity)), money(category.Total))
+		}
+	}
+
+	if len(r.ByStaff) > 0 {
+		heading("By staff")
+		pdf.SetFont("Arial", "B", 9)
+		cols := []float64{width * 0.32, width * 0.12, width * 0.18, width * 0.19, width * 0.19}
+		for i, h := range []string{"Staff", "Sales", "Cash", "M-Pesa", "Total"} {
+			align := "R"
+			if i == 0 {
+				align = ""
+			}
+			pdf.CellFormat(cols[i], 7, h, "B", 0, align, false, 0, "")
+		}
+		pdf.Ln(-1)
+		pdf.SetFont("Arial", "", 9)
+		for _, staff := range r.ByStaff {
+			pdf.CellFormat(cols[0], 7, tr(staff.Name), "", 0, "", false, 0, "")
+			pdf.CellFormat(cols[1], 7, fmt.Sprintf("%d", staff.Transactions), "", 0, "R", false, 0, "")
+			pdf.CellFormat(cols[2], 7, money(staff.CashSales), "", 0, "R", false, 0, "")
+			pdf.CellFormat(cols[3], 7, money(staff.MpesaSales), "", 0, "R", false, 0, "")
+			pdf.CellFormat(cols[4], 7, money(staff.Total), "", 1, "R", false, 0, "")
+		}
+	}
+
+	pdf.Ln(6)
+	pdf.SetFont("Arial", "I", 9)
+	if r.Closed {
+		closed := "Day closed " + r.CreatedAt.Format("02 Jan 2006 15:04")
+		if r.ClosedBy != "" {
+			closed += " by " + r.ClosedBy
+		}
+		pdf.CellFormat(width, 5, tr(closed), "", 1, "", false, 0, "")
+	} else {
+		pdf.CellFormat(width, 5, "The day has not been closed; these totals are its sales so far.", "", 1, "", false, 0, "")
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
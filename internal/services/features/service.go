@@ -0,0 +1,133 @@
+package features
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+)
+
+// CacheTTL is how long flags are read from memory before the table is read
+// again, so a flag set on another instance takes effect within it
+const CacheTTL = 60 * time.Second
+
+// ErrUnknownFlag is returned when setting a flag that no feature checks
+var ErrUnknownFlag = errors.New("unknown feature flag")
+
+// Service answers whether features are on. Flags an admin has set are
+// stored in the feature_flags table; the rest keep their default.
+type Service struct {
+	repo     *repository.FeatureFlagRepository
+	defaults map[string]bool
+	now      func() time.Time
+
+	mu       sync.RWMutex
+	flags    map[string]bool
+	loadedAt time.Time
+}
+
+// New creates the flag service with each known flag's default
+func New(repo *repository.FeatureFlagRepository, defaults map[string]bool) *Service {
+	return &Service{
+		repo:     repo,
+		defaults: defaults,
+		now:      time.Now,
+	}
+}
+
+// SetClock replaces the clock the cache expires by, for tests
+func (s *Service) SetClock(now func() time.Time) {
+	s.now = now
+}
+
+// Enabled reports whether a feature is on. Names without a default are
+// always on, so a check for a flag that was never defined blocks nothing.
+func (s *Service) Enabled(name string) bool {
+	flags := s.load()
+	if enabled, ok := flags[name]; ok {
+		return enabled
+	}
+	if enabled, ok := s.defaults[name]; ok {
+		return enabled
+	}
+	return true
+}
+
+// List returns every known flag with its current state. Flags never set
+// have no updated_at.
+func (s *Service) List() ([]models.FeatureFlag, error) {
+	stored, err := s.repo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[string]models.FeatureFlag, len(stored))
+	for _, flag := range stored {
+		set[flag.Name] = flag
+	}
+
+	list := make([]models.FeatureFlag, 0, len(s.defaults))
+	for name, enabled := range s.defaults {
+		flag, ok := set[name]
+		if !ok {
+			flag = models.FeatureFlag{Name: name, Enabled: enabled}
+		}
+		list = append(list, flag)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// Set switches a known flag on or off. It takes effect here at once and on
+// other instances when their cache expires.
+func (s *Service) Set(name string, enabled bool) (*models.FeatureFlag, error) {
+	if _, ok := s.defaults[name]; !ok {
+		return nil, ErrUnknownFlag
+	}
+	flag, err := s.repo.Set(name, enabled)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.loadedAt = time.Time{}
+	s.mu.Unlock()
+	return flag, nil
+}
+
+// load returns the stored flags, reading the table when the cache has
+// expired. If the table cannot be read the last flags read are kept.
+func (s *Service) load() map[string]bool {
+	now := s.now()
+	s.mu.RLock()
+	if s.flags != nil && now.Sub(s.loadedAt) < CacheTTL {
+		defer s.mu.RUnlock()
+		return s.flags
+	}
+	s.mu.RUnlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.flags != nil && now.Sub(s.loadedAt) < CacheTTL {
+		return s.flags
+	}
+
+	stored, err := s.repo.GetAll()
+	if err != nil {
+		log.Printf("⚠️ Failed to load feature flags: %v", err)
+		if s.flags == nil {
+			return nil
+		}
+		s.loadedAt = now
+		return s.flags
+	}
+	flags := make(map[string]bool, len(stored))
+	for _, flag := range stored {
+		flags[flag.Name] = flag.Enabled
+	}
+	s.flags, s.loadedAt = flags, now
+	return flags
+}
@@ -0,0 +1,150 @@
+// Package images validates uploaded product images and makes their
+// thumbnails using only the standard library decoders.
+package images
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // registers the GIF decoder
+	"image/jpeg"
+	_ "image/png" // registers the PNG decoder
+	"net/http"
+)
+
+const (
+	// MaxUploadSize is the largest image accepted, in bytes
+	MaxUploadSize = 2 << 20
+	// MaxPixels is the most pixels (width times height) an image may have.
+	// A small file can declare huge dimensions, and decoding allocates
+	// for all of them.
+	MaxPixels = 25_000_000
+	// ThumbnailSize is the longest side of a thumbnail, in pixels
+	ThumbnailSize = 200
+)
+
+var (
+	ErrTooLarge        = errors.New("image is larger than 2 MB")
+	ErrTooManyPixels   = errors.New("image is larger than 25 megapixels")
+	ErrUnsupportedType = errors.New("image must be a JPEG, PNG or GIF")
+)
+
+// extensions maps the accepted content types to file extensions
+var extensions = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+// Validate checks the size and sniffs the content type of data, ignoring
+// whatever the client claimed, reads the dimensions from its header and
+// returns the extension to store it under
+func Validate(data []byte) (string, error) {
+	if len(data) > MaxUploadSize {
+		return "", ErrTooLarge
+	}
+	ext, ok := extensions[http.DetectContentType(data)]
+	if !ok {
+		return "", ErrUnsupportedType
+	}
+	if err := checkPixels(data); err != nil {
+		return "", err
+	}
+	return ext, nil
+}
+
+// Decode decodes a JPEG, PNG or GIF, refusing images over MaxPixels before
+// any pixels are allocated
+func Decode(data []byte) (image.Image, error) {
+	if err := checkPixels(data); err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	return src, err
+}
+
+// checkPixels reads the dimensions from the image header
+func checkPixels(data []byte) error {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return ErrUnsupportedType
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > MaxPixels {
+		return ErrTooManyPixels
+	}
+	return nil
+}
+
+// Thumbnail decodes data and returns a JPEG no larger than size on its
+// longest side. Transparent areas become white.
+func Thumbnail(data []byte, size int) ([]byte, error) {
+	src, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if b := src.Bounds(); b.Dx() == 0 || b.Dy() == 0 {
+		return nil, errors.New("image is empty")
+	}
+	dst := Fit(src, size, size)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Fit scales src down, keeping its shape, to no larger than width by height
+// over a white background. Smaller images keep their size.
+func Fit(src image.Image, width, height int) *image.RGBA {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > width {
+		w, h = width, max(1, h*width/w)
+	}
+	if h > height {
+		w, h = max(1, w*height/h), height
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	scale(dst, src)
+	return dst
+}
+
+// scale box-filters src into dst, averaging every source pixel that falls
+// in each destination pixel and blending it over dst's background
+func scale(dst *image.RGBA, src image.Image) {
+	sb := src.Bounds()
+	db := dst.Bounds()
+	for y := 0; y < db.Dy(); y++ {
+		y0 := sb.Min.Y + y*sb.Dy()/db.Dy()
+		y1 := max(y0+1, sb.Min.Y+(y+1)*sb.Dy()/db.Dy())
+		for x := 0; x < db.Dx(); x++ {
+			x0 := sb.Min.X + x*sb.Dx()/db.Dx()
+			x1 := max(x0+1, sb.Min.X+(x+1)*sb.Dx()/db.Dx())
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(pr), g+uint64(pg), bl+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			r, g, bl, a = r/n, g/n, bl/n, a/n
+
+			// Premultiplied colour over the white background
+			bg := 0xffff - a
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r + bg),
+				G: uint16(g + bg),
+				B: uint16(bl + bg),
+				A: 0xffff,
+			})
+		}
+	}
+}
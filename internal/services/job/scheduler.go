@@ -2,12 +2,19 @@ package job
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"runtime/debug"
 	"sync"
 	"time"
 )
 
+var (
+	ErrJobNotFound       = errors.New("job not found")
+	ErrJobAlreadyRunning = errors.New("job is already running")
+)
+
 type Job struct {
 	ID        string
 	Name      string
@@ -29,6 +36,7 @@ type Scheduler struct {
 	ctx       context.Context
 	cancel    context.CancelFunc
 	isRunning bool
+	stopOnce  sync.Once
 	mu        sync.RWMutex
 }
 
@@ -73,23 +81,42 @@ func (s *Scheduler) Start() {
 }
 
 func (s *Scheduler) Stop() {
-	s.cancel()
+	s.stopOnce.Do(func() {
+		s.cancel()
 
-	s.mu.Lock()
-	for _, job := range s.jobs {
-		if job.stopChan != nil {
-			close(job.stopChan)
+		s.mu.Lock()
+		for _, job := range s.jobs {
+			if job.stopChan != nil {
+				close(job.stopChan)
+			}
 		}
-	}
-	s.mu.Unlock()
+		s.mu.Unlock()
 
-	s.wg.Wait()
+		s.wg.Wait()
 
-	s.mu.Lock()
-	s.isRunning = false
-	s.mu.Unlock()
+		s.mu.Lock()
+		s.isRunning = false
+		s.mu.Unlock()
 
-	log.Println("Job scheduler stopped")
+		log.Println("Job scheduler stopped")
+	})
+}
+
+// Shutdown stops the scheduler and waits for running jobs to finish,
+// giving up when ctx is done.
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("job scheduler shutdown: %w", ctx.Err())
+	}
 }
 
 func (s *Scheduler) worker(id int) {
@@ -103,7 +130,7 @@ func (s *Scheduler) worker(id int) {
 			log.Printf("Job worker %d stopping", id)
 			return
 		case job := <-s.jobChan:
-			if err := job(); err != nil {
+			if err := safeCall(fmt.Sprintf("worker %d", id), job); err != nil {
 				log.Printf("Worker %d: Job failed: %v", id, err)
 			}
 		}
@@ -128,6 +155,7 @@ func (s *Scheduler) AddPeriodicJob(name string, interval time.Duration, handler
 
 	s.jobs[name] = job
 
+	s.wg.Add(1)
 	go s.runPeriodicJob(job)
 
 	log.Printf("Periodic job '%s' scheduled with interval: %v", name, interval)
@@ -135,6 +163,8 @@ func (s *Scheduler) AddPeriodicJob(name string, interval time.Duration, handler
 }
 
 func (s *Scheduler) runPeriodicJob(job *Job) {
+	defer s.wg.Done()
+
 	ticker := time.NewTicker(job.Interval)
 	defer ticker.Stop()
 
@@ -171,7 +201,7 @@ func (s *Scheduler) RunJob(name string) error {
 	s.mu.RUnlock()
 
 	if !ok {
-		return fmt.Errorf("job %s not found", name)
+		return fmt.Errorf("%w: %s", ErrJobNotFound, name)
 	}
 
 	return s.runJob(job)
@@ -182,7 +212,7 @@ func (s *Scheduler) runJob(job *Job) error {
 	if job.IsRunning {
 		job.mu.Unlock()
 		log.Printf("Job '%s' is already running, skipping", job.Name)
-		return nil
+		return ErrJobAlreadyRunning
 	}
 	job.IsRunning = true
 	job.mu.Unlock()
@@ -190,7 +220,7 @@ func (s *Scheduler) runJob(job *Job) error {
 	log.Printf("Running job: %s", job.Name)
 	startTime := time.Now()
 
-	err := job.Handler()
+	err := safeCall(job.Name, job.Handler)
 
 	job.mu.Lock()
 	job.LastRun = startTime
@@ -208,6 +238,18 @@ func (s *Scheduler) runJob(job *Job) error {
 	return err
 }
 
+// safeCall runs fn, turning a panic into an error so a failing job can't kill
+// the goroutine that runs it.
+func safeCall(name string, fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Job '%s' panicked: %v\n%s", name, r, debug.Stack())
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn()
+}
+
 func (s *Scheduler) GetJob(name string) (*Job, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -235,11 +277,15 @@ func (s *Scheduler) GetStatus() map[string]interface{} {
 	jobInfo := make([]map[string]interface{}, 0, len(s.jobs))
 	for _, job := range s.jobs {
 		job.mu.RLock()
+		lastError := ""
+		if job.LastError != nil {
+			lastError = job.LastError.Error()
+		}
 		jobInfo = append(jobInfo, map[string]interface{}{
 			"name":       job.Name,
 			"interval":   job.Interval.String(),
 			"last_run":   job.LastRun,
-			"last_error": job.LastError,
+			"last_error": lastError,
 			"run_count":  job.RunCount,
 			"is_running": job.IsRunning,
 		})
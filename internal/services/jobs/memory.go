@@ -0,0 +1,81 @@
+package jobs
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-process Store for a single instance or tests
+type MemoryStore struct {
+	queue   chan []byte
+	entries map[string]memoryEntry
+	mu      sync.Mutex
+}
+
+type memoryEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+// NewMemoryStore creates an in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		queue:   make(chan []byte, 1000),
+		entries: make(map[string]memoryEntry),
+	}
+}
+
+func (s *MemoryStore) PushJob(data []byte) error {
+	s.queue <- data
+	return nil
+}
+
+func (s *MemoryStore) PopJob(timeout time.Duration) ([]byte, error) {
+	select {
+	case data := <-s.queue:
+		return data, nil
+	case <-time.After(timeout):
+		return nil, nil
+	}
+}
+
+func (s *MemoryStore) SetJob(id string, data []byte, ttl time.Duration) error {
+	return s.set("job:"+id, data, ttl)
+}
+
+func (s *MemoryStore) GetJob(id string) ([]byte, error) {
+	return s.get("job:" + id), nil
+}
+
+func (s *MemoryStore) SetJobResult(id string, data []byte, ttl time.Duration) error {
+	return s.set("result:"+id, data, ttl)
+}
+
+func (s *MemoryStore) GetJobResult(id string) ([]byte, error) {
+	return s.get("result:" + id), nil
+}
+
+func (s *MemoryStore) set(key string, data []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for k, e := range s.entries {
+		if now.After(e.expires) {
+			delete(s.entries, k)
+		}
+	}
+	s.entries[key] = memoryEntry{data: data, expires: now.Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) get(key string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return nil
+	}
+	return e.data
+}
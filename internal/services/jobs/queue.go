@@ -0,0 +1,272 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Job statuses
+const (
+	StatusQueued  = "queued"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// DefaultWorkers is the worker pool size when none is configured
+const DefaultWorkers = 2
+
+// ResultTTL is how long a job and its result are kept for polling
+const ResultTTL = 10 * time.Minute
+
+// popTimeout is how long a worker blocks on the queue before checking for
+// shutdown
+const popTimeout = 2 * time.Second
+
+var ErrNotFound = errors.New("job not found")
+
+// Job is a unit of background work, e.g. an export for a shop
+type Job struct {
+	ID         string            `json:"id"`
+	Type       string            `json:"type"`
+	Params     map[string]string `json:"params,omitempty"`
+	ShopID     uint              `json:"shop_id"`
+	Status     string            `json:"status"`
+	Error      string            `json:"error,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+}
+
+// Result is the file a job produced
+type Result struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data"`
+}
+
+// Handler runs a job of one type
+type Handler func(ctx context.Context, job *Job) (*Result, error)
+
+// Store holds the queue and job state. The cache service implements it with
+// the Redis list jobs:queue and keys jobs:{id}, jobs:{id}:result. Getters
+// return nil when the key doesn't exist; PopJob returns nil on timeout.
+type Store interface {
+	PushJob(data []byte) error
+	PopJob(timeout time.Duration) ([]byte, error)
+	SetJob(id string, data []byte, ttl time.Duration) error
+	GetJob(id string) ([]byte, error)
+	SetJobResult(id string, data []byte, ttl time.Duration) error
+	GetJobResult(id string) ([]byte, error)
+}
+
+// JobQueue runs jobs pushed onto the store with a pool of workers
+type JobQueue struct {
+	store    Store
+	workers  int
+	handlers map[string]Handler
+	notify   func(job *Job)
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewJobQueue creates a queue with the given number of workers (default 2)
+func NewJobQueue(store Store, workers int) *JobQueue {
+	if workers < 1 {
+		workers = DefaultWorkers
+	}
+	return &JobQueue{
+		store:    store,
+		workers:  workers,
+		handlers: make(map[string]Handler),
+	}
+}
+
+// Register sets the handler for a job type
+func (q *JobQueue) Register(jobType string, handler Handler) {
+	q.handlers[jobType] = handler
+}
+
+// SetNotifier sets a callback for when a job finishes, e.g. a WebSocket push
+func (q *JobQueue) SetNotifier(notify func(job *Job)) {
+	q.notify = notify
+}
+
+// Enqueue queues a job for the shop and returns it with its ID
+func (q *JobQueue) Enqueue(shopID uint, jobType string, params map[string]string) (*Job, error) {
+	if _, ok := q.handlers[jobType]; !ok {
+		return nil, fmt.Errorf("unknown job type: %s", jobType)
+	}
+
+	job := &Job{
+		ID:        newJobID(),
+		Type:      jobType,
+		Params:    params,
+		ShopID:    shopID,
+		Status:    StatusQueued,
+		CreatedAt: time.Now(),
+	}
+	if err := q.save(job); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.store.PushJob(data); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Get gets a job's current state
+func (q *JobQueue) Get(id string) (*Job, error) {
+	data, err := q.store.GetJob(id)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, ErrNotFound
+	}
+
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Result gets the file a finished job produced
+func (q *JobQueue) Result(id string) (*Result, error) {
+	data, err := q.store.GetJobResult(id)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, ErrNotFound
+	}
+
+	var result Result
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Start starts the workers
+func (q *JobQueue) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	q.cancel = cancel
+
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.worker(ctx)
+	}
+	log.Printf("⚙️ Job queue started with %d workers", q.workers)
+}
+
+// Shutdown stops taking jobs and waits for running ones to finish
+func (q *JobQueue) Shutdown(ctx context.Context) error {
+	if q.cancel == nil {
+		return nil
+	}
+	q.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *JobQueue) worker(ctx context.Context) {
+	defer q.wg.Done()
+
+	for ctx.Err() == nil {
+		data, err := q.store.PopJob(popTimeout)
+		if err != nil {
+			log.Printf("⚠️ Job queue: %v", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(popTimeout):
+			}
+			continue
+		}
+		if data == nil {
+			continue
+		}
+
+		var job Job
+		if err := json.Unmarshal(data, &job); err != nil {
+			log.Printf("⚠️ Job queue: invalid job: %v", err)
+			continue
+		}
+		q.run(ctx, &job)
+	}
+}
+
+// run runs a job and stores its result. Running jobs are finished even
+// when shutting down.
+func (q *JobQueue) run(ctx context.Context, job *Job) {
+	job.Status = StatusRunning
+	q.save(job)
+
+	var result *Result
+	var err error
+	if handler, ok := q.handlers[job.Type]; ok {
+		result, err = handler(context.WithoutCancel(ctx), job)
+	} else {
+		err = fmt.Errorf("unknown job type: %s", job.Type)
+	}
+
+	if err == nil {
+		var data []byte
+		if data, err = json.Marshal(result); err == nil {
+			err = q.store.SetJobResult(job.ID, data, ResultTTL)
+		}
+	}
+
+	now := time.Now()
+	job.FinishedAt = &now
+	job.Status = StatusDone
+	if err != nil {
+		job.Status = StatusFailed
+		job.Error = err.Error()
+		log.Printf("❌ Job %s (%s) for shop %d failed: %v", job.ID, job.Type, job.ShopID, err)
+	}
+	if err := q.save(job); err != nil {
+		log.Printf("⚠️ Job %s: failed to save status: %v", job.ID, err)
+	}
+
+	if q.notify != nil {
+		q.notify(job)
+	}
+}
+
+func (q *JobQueue) save(job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return q.store.SetJob(job.ID, data, ResultTTL)
+}
+
+func newJobID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
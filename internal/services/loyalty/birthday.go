@@ -0,0 +1,95 @@
+package loyalty
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SetMessageSender sets how customers are congratulated when they get
+// birthday points; nil awards the points silently
+func (s *Service) SetMessageSender(send func(phone, message string) error) {
+	s.send = send
+}
+
+// AwardBirthdayBonuses gives the shop's birthday bonus points to every
+// customer whose birthday is on the day of now, and texts them the new
+// balance. Customers already rewarded this year are skipped, so it is safe
+// to run more than once a day. It returns how many customers were rewarded.
+func (s *Service) AwardBirthdayBonuses(shop *models.Shop, now time.Time) (int, error) {
+	if shop.BirthdayBonusPoints <= 0 {
+		return 0, nil
+	}
+
+	customers, err := s.customerRepo.GetBirthdays(shop.ID, now)
+	if err != nil {
+		return 0, err
+	}
+
+	reference := fmt.Sprintf("BDAY%d", now.Year())
+	rewarded := 0
+	for _, customer := range customers {
+		var count int64
+		err := s.db.Model(&models.LoyaltyTransaction{}).
+			Where("customer_id = ? AND type = ? AND reference = ?", customer.ID, models.LoyaltyBirthdayBonus, reference).
+			Count(&count).Error
+		if err != nil {
+			return rewarded, err
+		}
+		if count > 0 {
+			continue
+		}
+
+		balance, err := s.awardBirthdayBonus(shop, &customer, reference)
+		if err != nil {
+			log.Printf("❌ Failed to add birthday points for customer %d: %v", customer.ID, err)
+			continue
+		}
+		rewarded++
+
+		if s.send == nil || customer.Phone == "" {
+			continue
+		}
+		msg := fmt.Sprintf("🎂 Happy birthday %s!\n\n%s has added %d points to your loyalty account.\nYou now have %d points.",
+			customer.Name, shop.Name, shop.BirthdayBonusPoints, balance)
+		if err := s.send(customer.Phone, msg); err != nil {
+			log.Printf("❌ Failed to send birthday message to customer %d: %v", customer.ID, err)
+		}
+	}
+
+	return rewarded, nil
+}
+
+// awardBirthdayBonus adds the bonus points to the customer's balance and
+// records them in one transaction, so points are never added without the
+// record that stops them being added again. It returns the new balance.
+func (s *Service) awardBirthdayBonus(shop *models.Shop, customer *models.Customer, reference string) (int, error) {
+	var balance int
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var current models.Customer
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "loyalty_points").First(&current, customer.ID).Error; err != nil {
+			return err
+		}
+		balance = current.LoyaltyPoints + shop.BirthdayBonusPoints
+		if err := tx.Model(&models.Customer{}).Where("id = ?", customer.ID).
+			UpdateColumn("loyalty_points", gorm.Expr("loyalty_points + ?", shop.BirthdayBonusPoints)).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.LoyaltyTransaction{
+			CustomerID:   customer.ID,
+			ShopID:       shop.ID,
+			Type:         models.LoyaltyBirthdayBonus,
+			Points:       shop.BirthdayBonusPoints,
+			PointsBefore: current.LoyaltyPoints,
+			PointsAfter:  balance,
+			Description:  "Birthday bonus",
+			Reference:    reference,
+			ExpiresAt:    s.getPointsExpiry(),
+		}).Error
+	})
+	return balance, err
+}
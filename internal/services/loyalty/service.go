@@ -16,6 +16,9 @@ type Service struct {
 	customerRepo *repository.CustomerRepository
 	saleRepo     *repository.SaleRepository
 	db           *gorm.DB
+
+	// send texts customers about birthday points
+	send func(phone, message string) error
 }
 
 func NewService(customerRepo *repository.CustomerRepository, saleRepo *repository.SaleRepository, db *gorm.DB) *Service {
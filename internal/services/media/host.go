@@ -0,0 +1,125 @@
+package media
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultTTL is how long a hosted file stays downloadable. Twilio fetches
+// media within seconds of the send, so this only needs to cover retries.
+const DefaultTTL = time.Hour
+
+var (
+	ErrNotFound    = errors.New("media not found")
+	ErrUnsupported = errors.New("unsupported media type")
+)
+
+// namePattern matches the names Put hands out; anything else is refused
+var namePattern = regexp.MustCompile(`^[0-9a-f]{32}\.(png|pdf)$`)
+
+// extensions lists the file types Put accepts
+var extensions = map[string]bool{"png": true, "pdf": true}
+
+// Host keeps generated media (QR codes, receipts) on disk under random
+// names so they can be sent as WhatsApp attachments by URL
+type Host struct {
+	dir     string
+	baseURL string
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+// NewHost creates a host writing to dir and serving under baseURL,
+// e.g. "https://pos.example.com/media"
+func NewHost(dir, baseURL string, ttl time.Duration) *Host {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Host{
+		dir:     dir,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+// SetClock overrides the time source (used by tests)
+func (h *Host) SetClock(now func() time.Time) {
+	h.now = now
+}
+
+// Put saves data as a new file with extension ext ("png" or "pdf") and
+// returns its public URL
+func (h *Host) Put(data []byte, ext string) (string, error) {
+	if !extensions[ext] {
+		return "", ErrUnsupported
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	name := hex.EncodeToString(b) + "." + ext
+
+	if err := os.MkdirAll(h.dir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(h.dir, name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", err
+	}
+	// Expiry runs off the modification time, so pin it to our clock
+	now := h.now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		return "", err
+	}
+	return h.baseURL + "/" + name, nil
+}
+
+// Path returns the file for name if it exists and hasn't expired
+func (h *Host) Path(name string) (string, error) {
+	if !namePattern.MatchString(name) {
+		return "", ErrNotFound
+	}
+	path := filepath.Join(h.dir, name)
+	info, err := os.Stat(path)
+	if err != nil || h.expired(info) {
+		return "", ErrNotFound
+	}
+	return path, nil
+}
+
+// Cleanup deletes expired files and returns how many were removed
+func (h *Host) Cleanup() (int, error) {
+	entries, err := os.ReadDir(h.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !namePattern.MatchString(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !h.expired(info) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(h.dir, entry.Name())); err == nil {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (h *Host) expired(info os.FileInfo) bool {
+	return h.now().Sub(info.ModTime()) > h.ttl
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MenuSessionTTL is how long a numbered menu stays answerable after it was sent
+const MenuSessionTTL = 30 * time.Minute
+
+// MenuStore persists the last numbered menu sent to each phone so replies
+// resolve on any replica. The cache service implements it with Redis keys
+// whatsapp:menu:{phone}. Get returns nil data when no menu is stored.
+type MenuStore interface {
+	GetWhatsAppMenu(phone string) ([]byte, error)
+	SetWhatsAppMenu(phone string, data []byte, ttl time.Duration) error
+}
+
+// MenuSessionService remembers numbered menus so feature phone users can reply
+// with "1" instead of typing the command
+type MenuSessionService struct {
+	store MenuStore
+	menus map[string]memoryMenu // used when no store is configured
+	mu    sync.Mutex
+}
+
+type memoryMenu struct {
+	options []string
+	expires time.Time
+}
+
+// NewMenuSessionService creates a menu session service that keeps menus in
+// memory until a store is set
+func NewMenuSessionService() *MenuSessionService {
+	return &MenuSessionService{menus: make(map[string]memoryMenu)}
+}
+
+// SetStore sets the store used to share menus between instances
+func (s *MenuSessionService) SetStore(store MenuStore) {
+	s.store = store
+}
+
+// SetMenu records the options just sent to phone; option 1 is options[0]
+func (s *MenuSessionService) SetMenu(phone string, options []string) error {
+	if s.store != nil {
+		data, err := json.Marshal(options)
+		if err != nil {
+			return err
+		}
+		return s.store.SetWhatsAppMenu(phone, data, MenuSessionTTL)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for p, menu := range s.menus {
+		if now.After(menu.expires) {
+			delete(s.menus, p)
+		}
+	}
+	s.menus[phone] = memoryMenu{options: options, expires: now.Add(MenuSessionTTL)}
+	return nil
+}
+
+// ResolveOption translates a numbered reply into the menu's command, keeping
+// any words after the number: "1 bread 2" becomes "sell bread 2". Input that
+// doesn't pick an option from the phone's last menu is returned unchanged.
+func (s *MenuSessionService) ResolveOption(phone, input string) string {
+	fields := strings.Fields(input)
+	if len(fields) == 0 {
+		return input
+	}
+	n, err := strconv.Atoi(fields[0])
+	if err != nil || n < 1 {
+		return input
+	}
+
+	options, err := s.getMenu(phone)
+	if err != nil {
+		log.Printf("⚠️ WhatsApp menu lookup failed for %s: %v", phone, err)
+		return input
+	}
+	if n > len(options) {
+		return input
+	}
+
+	return strings.Join(append([]string{options[n-1]}, fields[1:]...), " ")
+}
+
+func (s *MenuSessionService) getMenu(phone string) ([]string, error) {
+	if s.store != nil {
+		data, err := s.store.GetWhatsAppMenu(phone)
+		if err != nil || data == nil {
+			return nil, err
+		}
+		var options []string
+		if err := json.Unmarshal(data, &options); err != nil {
+			return nil, err
+		}
+		return options, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	menu, ok := s.menus[phone]
+	if !ok || time.Now().After(menu.expires) {
+		return nil, nil
+	}
+	return menu.options, nil
+}
@@ -0,0 +1,582 @@
+package mpesa
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+)
+
+const (
+	B2CMinAmount   = 10
+	B2CMaxAmount   = 150000
+	maxB2CRemarks  = 100
+	maxB2COccasion = 100
+)
+
+var (
+	ErrB2CNotConfigured  = errors.New("M-Pesa B2C is not configured")
+	ErrB2CInvalidAmount  = fmt.Errorf("B2C amount must be a whole number between %d and %d KES", B2CMinAmount, B2CMaxAmount)
+	ErrB2CDailyLimit     = errors.New("daily payout limit reached")
+	ErrB2CInvalidCommand = errors.New("command must be BusinessPayment, SalaryPayment or PromotionPayment")
+	ErrB2CInvalidRate    = errors.New("exchange rate must be greater than 0")
+)
+
+// CurrencyConverter converts amounts between currencies for forex payouts
+type CurrencyConverter interface {
+	Convert(amount float64, from, to string) (float64, error)
+}
+
+// b2cCommands are the Daraja CommandIDs a shop may send
+var b2cCommands = map[string]bool{
+	"BusinessPayment":  true,
+	"SalaryPayment":    true,
+	"PromotionPayment": true,
+}
+
+// B2CRequest is a payout from a shop to a customer's M-Pesa
+type B2CRequest struct {
+	ShopID    uint
+	Phone     string
+	Amount    float64
+	CommandID string // defaults to BusinessPayment
+	Remarks   string
+	Occasion  string
+
+	// Forex makes Amount a foreign currency amount, converted to KES
+	// before it is sent
+	Forex B2CForexRequest
+
+	// OrderID is the supplier order the payout settles
+	OrderID *uint
+}
+
+// B2CForexRequest names the currency a payout amount is in. ExchangeRate is
+// KES per unit of Currency; 0 uses the current rate.
+type B2CForexRequest struct {
+	Currency     string
+	ExchangeRate float64
+}
+
+type B2CResponse struct {
+	ConversationID           string `json:"ConversationID"`
+	OriginatorConversationID string `json:"OriginatorConversationID"`
+	ResponseCode             string `json:"ResponseCode"`
+	ResponseDescription      string `json:"ResponseDescription"`
+}
+
+// B2CResult is the body Daraja posts to the B2C result and timeout URLs
+type B2CResult struct {
+	Result struct {
+		ResultType               int    `json:"ResultType"`
+		ResultCode               int    `json:"ResultCode"`
+		ResultDesc               string `json:"ResultDesc"`
+		OriginatorConversationID string `json:"OriginatorConversationID"`
+		ConversationID           string `json:"ConversationID"`
+		TransactionID            string `json:"TransactionID"`
+		ResultParameters         struct {
+			ResultParameter []struct {
+				Key   string      `json:"Key"`
+				Value interface{} `json:"Value"`
+			} `json:"ResultParameter"`
+		} `json:"ResultParameters"`
+	} `json:"Result"`
+}
+
+// SetCurrencyConverter enables payouts requested in currencies other than KES
+func (s *Service) SetCurrencyConverter(converter CurrencyConverter) {
+	s.converter = converter
+}
+
+// SetB2CRepos enables B2C payouts. The audit repository is optional.
+func (s *Service) SetB2CRepos(b2cRepo *repository.B2CPayoutRepository, auditRepo *repository.AuditLogRepository) {
+	s.b2cRepo = b2cRepo
+	s.auditRepo = auditRepo
+}
+
+// SetOrderRepo lets B2C results update the payment status of the supplier
+// orders they pay for
+func (s *Service) SetOrderRepo(orderRepo *repository.OrderRepository) {
+	s.orderRepo = orderRepo
+}
+
+// IsB2CConfiguredForShop reports whether the shop can send payouts
+func (s *Service) IsB2CConfiguredForShop(shopID uint) bool {
+	return s.b2cConfigForShop(shopID) != nil
+}
+
+// B2CConfigError explains what is missing before the shop can send payouts.
+// It returns nil when B2C is configured.
+func (s *Service) B2CConfigError(shopID uint) error {
+	if s.b2cConfigForShop(shopID) != nil {
+		return nil
+	}
+	if s.b2cRepo == nil || s.configForShop(shopID) == nil {
+		return ErrB2CNotConfigured
+	}
+
+	var missing []string
+	if creds := s.shopCredentials(shopID); creds == nil {
+		missing = append(missing, "the shop's own M-Pesa credentials with initiator_name and security_credential")
+	} else {
+		if creds.InitiatorName == "" {
+			missing = append(missing, "initiator_name")
+		}
+		if creds.SecurityCredential == "" {
+			missing = append(missing, "security_credential")
+		}
+	}
+	if s.config.B2CResultURL == "" {
+		missing = append(missing, "MPESA_B2C_RESULT_URL")
+	}
+	return fmt.Errorf("%w: set %s", ErrB2CNotConfigured, strings.Join(missing, ", "))
+}
+
+// b2cConfigForShop returns the config to send the shop's payouts with,
+// from its own shortcode
+func (s *Service) b2cConfigForShop(shopID uint) *Config {
+	if s.b2cRepo == nil {
+		return nil
+	}
+	cfg := s.initiatorConfigForShop(shopID)
+	if cfg == nil || cfg.B2CResultURL == "" {
+		return nil
+	}
+	return cfg
+}
+
+// EncryptSecurityCredential encrypts the initiator password with the public
+// key of the Daraja certificate (PEM), as the SecurityCredential field expects
+func EncryptSecurityCredential(initiatorPassword string, certPEM []byte) (string, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return "", errors.New("invalid M-Pesa certificate: no PEM data")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("invalid M-Pesa certificate: %w", err)
+	}
+
+	publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return "", errors.New("M-Pesa certificate does not hold an RSA key")
+	}
+
+	encrypted, err := rsa.EncryptPKCS1v15(rand.Reader, publicKey, []byte(initiatorPassword))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(encrypted), nil
+}
+
+// InitiateB2C sends money from the shop's shortcode to a customer. The payout
+// is recorded before the request goes out and counts against the daily cap
+// until it fails.
+func (s *Service) InitiateB2C(ctx context.Context, req *B2CRequest) (*models.B2CPayout, error) {
+	cfg := s.b2cConfigForShop(req.ShopID)
+	if cfg == nil {
+		return nil, ErrB2CNotConfigured
+	}
+
+	phone, err := s.ValidatePhone(req.Phone)
+	if err != nil {
+		return nil, err
+	}
+
+	forex, err := s.convertB2CAmount(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Amount < B2CMinAmount || req.Amount > B2CMaxAmount || req.Amount != float64(int(req.Amount)) {
+		return nil, ErrB2CInvalidAmount
+	}
+
+	commandID := req.CommandID
+	if commandID == "" {
+		commandID = "BusinessPayment"
+	}
+	if !b2cCommands[commandID] {
+		return nil, ErrB2CInvalidCommand
+	}
+
+	remarks := req.Remarks
+	if remarks == "" {
+		remarks = "DukaPOS payout"
+	}
+	if len(remarks) > maxB2CRemarks {
+		return nil, fmt.Errorf("remarks must be at most %d characters", maxB2CRemarks)
+	}
+	if len(req.Occasion) > maxB2COccasion {
+		return nil, fmt.Errorf("occasion must be at most %d characters", maxB2COccasion)
+	}
+
+	payout, err := s.reserveB2CPayout(cfg, &models.B2CPayout{
+		ShopID:    req.ShopID,
+		Phone:     phone,
+		Amount:    req.Amount,
+		CommandID: commandID,
+		Remarks:   remarks,
+		Occasion:  req.Occasion,
+		Status:    models.B2CPayoutPending,
+
+		Currency:      forex.Currency,
+		ForeignAmount: forex.amount,
+		ExchangeRate:  forex.ExchangeRate,
+
+		OrderID: req.OrderID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.getToken(ctx, cfg)
+	if err != nil {
+		s.failB2CPayout(payout, fmt.Sprintf("Auth failed: %v", err))
+		return payout, err
+	}
+
+	timeoutURL := cfg.B2CTimeoutURL
+	if timeoutURL == "" {
+		timeoutURL = cfg.B2CResultURL
+	}
+
+	b2cReq := map[string]interface{}{
+		"InitiatorName":      cfg.InitiatorName,
+		"SecurityCredential": cfg.SecurityCredential,
+		"CommandID":          commandID,
+		"Amount":             int(req.Amount),
+		"PartyA":             cfg.Shortcode,
+		"PartyB":             phone,
+		"Remarks":            remarks,
+		"QueueTimeOutURL":    withCallbackToken(timeoutURL, cfg.CallbackToken),
+		"ResultURL":          withCallbackToken(cfg.B2CResultURL, cfg.CallbackToken),
+		"Occasion":           req.Occasion,
+	}
+
+	body, err := json.Marshal(b2cReq)
+	if err != nil {
+		s.failB2CPayout(payout, "Invalid request")
+		return payout, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	resp, err := s.doWithBackoff(ctx, jsonRequest(fmt.Sprintf("%s/%s", s.getBaseURL(), B2CEndpoint), token, body))
+	if errors.Is(err, ErrRateLimited) {
+		s.failB2CPayout(payout, RateLimitMessage)
+		return payout, err
+	}
+	if err != nil {
+		s.failB2CPayout(payout, fmt.Sprintf("Network error: %v", err))
+		return payout, fmt.Errorf("%w: %v", ErrNetworkError, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+
+	var result B2CResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		s.failB2CPayout(payout, fmt.Sprintf("Invalid response: %v", err))
+		return payout, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if result.ResponseCode != "0" {
+		s.failB2CPayout(payout, result.ResponseDescription)
+		return payout, fmt.Errorf("B2C request failed: %s", result.ResponseDescription)
+	}
+
+	payout.ConversationID = result.ConversationID
+	payout.OriginatorConversationID = result.OriginatorConversationID
+	payout.Status = models.B2CPayoutSubmitted
+	if err := s.b2cRepo.Update(payout); err != nil {
+		return payout, fmt.Errorf("failed to update payout: %w", err)
+	}
+	s.recordB2CTransaction(payout)
+
+	details := fmt.Sprintf("Payout of %.0f to %s (%s)", payout.Amount, payout.Phone, commandID)
+	if payout.Currency != "" {
+		details += fmt.Sprintf(", %.2f %s at %.4f KES", payout.ForeignAmount, payout.Currency, payout.ExchangeRate)
+		log.Printf("💱 B2C payout %d: %.2f %s sent as KES %.0f at %.4f", payout.ID, payout.ForeignAmount, payout.Currency, payout.Amount, payout.ExchangeRate)
+	}
+	s.auditB2C(payout, "b2c_initiated", details)
+
+	return payout, nil
+}
+
+type b2cForex struct {
+	B2CForexRequest
+	amount float64
+}
+
+// convertB2CAmount turns a foreign currency payout into whole KES, setting
+// req.Amount and returning what was asked for. KES payouts pass through.
+func (s *Service) convertB2CAmount(req *B2CRequest) (b2cForex, error) {
+	currency := strings.ToUpper(strings.TrimSpace(req.Forex.Currency))
+	if currency == "" || currency == "KES" {
+		return b2cForex{}, nil
+	}
+
+	forex := b2cForex{B2CForexRequest: B2CForexRequest{Currency: currency, ExchangeRate: req.Forex.ExchangeRate}, amount: req.Amount}
+	switch {
+	case forex.ExchangeRate < 0:
+		return forex, ErrB2CInvalidRate
+	case forex.ExchangeRate == 0:
+		if s.converter == nil {
+			return forex, fmt.Errorf("payouts in %s are not supported", currency)
+		}
+		rate, err := s.converter.Convert(1, currency, "KES")
+		if err != nil {
+			return forex, err
+		}
+		if rate <= 0 {
+			return forex, ErrB2CInvalidRate
+		}
+		forex.ExchangeRate = rate
+	}
+
+	req.Amount = math.Round(req.Amount * forex.ExchangeRate)
+	return forex, nil
+}
+
+// reserveB2CPayout checks the shop's daily cap and records the payout in one
+// database transaction, so concurrent requests on any server can't both slip
+// under the limit
+func (s *Service) reserveB2CPayout(cfg *Config, payout *models.B2CPayout) (*models.B2CPayout, error) {
+	if cfg.B2CDailyLimit <= 0 {
+		if err := s.b2cRepo.Create(payout); err != nil {
+			return nil, fmt.Errorf("failed to record payout: %w", err)
+		}
+		return payout, nil
+	}
+
+	now := time.Now()
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	sent, ok, err := s.b2cRepo.CreateWithinLimit(payout, startOfDay, cfg.B2CDailyLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record payout: %w", err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %.0f of %.0f KES already sent today", ErrB2CDailyLimit, sent, cfg.B2CDailyLimit)
+	}
+	return payout, nil
+}
+
+func (s *Service) failB2CPayout(payout *models.B2CPayout, reason string) {
+	payout.Status = models.B2CPayoutFailed
+	payout.ResultDesc = reason
+	_ = s.b2cRepo.Update(payout)
+	s.updateOrderPayment(payout)
+	s.auditB2C(payout, "b2c_failed", reason)
+}
+
+// updateOrderPayment mirrors a payout's outcome on the supplier order it
+// pays for. A completed payout adds to the order's amount paid, so a part
+// payment leaves it partly paid. Timeouts leave the order processing since
+// the money may move.
+func (s *Service) updateOrderPayment(payout *models.B2CPayout) {
+	if s.orderRepo == nil || payout.OrderID == nil {
+		return
+	}
+
+	var err error
+	switch payout.Status {
+	case models.B2CPayoutCompleted:
+		err = s.orderRepo.RecordPayment(payout.ShopID, *payout.OrderID, payout.ID, payout.Amount)
+	case models.B2CPayoutFailed:
+		err = s.orderRepo.FailPayment(payout.ShopID, *payout.OrderID, payout.ID)
+	default:
+		return
+	}
+	if err != nil {
+		log.Printf("❌ B2C payout %d: failed to update order %d: %v", payout.ID, *payout.OrderID, err)
+	}
+}
+
+// recordB2CTransaction adds a submitted payout to the shop's M-Pesa
+// transactions as a pending b2c transaction, keyed by its conversation ID
+// until the result brings the receipt
+func (s *Service) recordB2CTransaction(payout *models.B2CPayout) {
+	if s.transactionRepo == nil {
+		return
+	}
+	err := s.transactionRepo.Create(&models.MpesaTransaction{
+		ShopID:          payout.ShopID,
+		Type:            "b2c",
+		Amount:          payout.Amount,
+		Phone:           payout.Phone,
+		TransactionID:   payout.ConversationID,
+		TransactionTime: time.Now(),
+		Status:          "pending",
+	})
+	if err != nil {
+		log.Printf("❌ B2C payout %d: failed to record transaction: %v", payout.ID, err)
+	}
+}
+
+// settleB2CTransaction updates a payout's transaction with its result,
+// recording it if the submission wasn't
+func (s *Service) settleB2CTransaction(payout *models.B2CPayout) {
+	if s.transactionRepo == nil {
+		return
+	}
+
+	status := "failed"
+	if payout.Status == models.B2CPayoutCompleted {
+		status = "completed"
+	}
+
+	tx, err := s.transactionRepo.GetByTransactionID(payout.ConversationID)
+	if err != nil {
+		tx = &models.MpesaTransaction{
+			ShopID: payout.ShopID,
+			Type:   "b2c",
+			Amount: payout.Amount,
+			Phone:  payout.Phone,
+		}
+	}
+	tx.Status = status
+	tx.TransactionTime = time.Now()
+	tx.TransactionID = payout.ConversationID
+	if payout.ReceiptNumber != "" {
+		tx.TransactionID = payout.ReceiptNumber
+		tx.ReceiptNumber = payout.ReceiptNumber
+	}
+
+	if tx.ID == 0 {
+		err = s.transactionRepo.Create(tx)
+	} else {
+		err = s.transactionRepo.Update(tx)
+	}
+	if err != nil {
+		log.Printf("❌ B2C payout %d: failed to record transaction: %v", payout.ID, err)
+	}
+}
+
+// ProcessB2CResult settles a payout from the Daraja result callback
+func (s *Service) ProcessB2CResult(body []byte) (*models.B2CPayout, error) {
+	payout, result, err := s.b2cPayoutForResult(body)
+	if err != nil {
+		return nil, err
+	}
+
+	res := result.Result
+	payout.ResultCode = res.ResultCode
+	payout.ResultDesc = res.ResultDesc
+
+	if res.ResultCode != 0 {
+		payout.Status = models.B2CPayoutFailed
+		if err := s.b2cRepo.Update(payout); err != nil {
+			return nil, fmt.Errorf("failed to update payout: %w", err)
+		}
+		s.settleB2CTransaction(payout)
+		s.updateOrderPayment(payout)
+		s.auditB2C(payout, "b2c_failed", res.ResultDesc)
+		return payout, nil
+	}
+
+	payout.ReceiptNumber = res.TransactionID
+	for _, param := range res.ResultParameters.ResultParameter {
+		switch param.Key {
+		case "TransactionReceipt":
+			payout.ReceiptNumber = fmt.Sprint(param.Value)
+		case "ReceiverPartyPublicName":
+			payout.ReceiverName = fmt.Sprint(param.Value)
+		}
+	}
+
+	now := time.Now()
+	payout.Status = models.B2CPayoutCompleted
+	payout.CompletedAt = &now
+	if err := s.b2cRepo.Update(payout); err != nil {
+		return nil, fmt.Errorf("failed to update payout: %w", err)
+	}
+
+	s.settleB2CTransaction(payout)
+	s.updateOrderPayment(payout)
+	s.auditB2C(payout, "b2c_completed", fmt.Sprintf("Receipt %s, paid to %s", payout.ReceiptNumber, payout.ReceiverName))
+
+	return payout, nil
+}
+
+// ProcessB2CTimeout marks a payout that timed out in Daraja's queue. The money
+// may still move, so the payout keeps counting against the daily cap.
+func (s *Service) ProcessB2CTimeout(body []byte) (*models.B2CPayout, error) {
+	payout, result, err := s.b2cPayoutForResult(body)
+	if err != nil {
+		return nil, err
+	}
+
+	payout.Status = models.B2CPayoutTimeout
+	payout.ResultCode = result.Result.ResultCode
+	payout.ResultDesc = result.Result.ResultDesc
+	if payout.ResultDesc == "" {
+		payout.ResultDesc = "Request timed out in the M-Pesa queue"
+	}
+	if err := s.b2cRepo.Update(payout); err != nil {
+		return nil, fmt.Errorf("failed to update payout: %w", err)
+	}
+
+	s.auditB2C(payout, "b2c_timeout", payout.ResultDesc)
+
+	return payout, nil
+}
+
+// b2cPayoutForResult parses a result body and loads its payout. Payouts that
+// are already settled return ErrDuplicateCallback.
+func (s *Service) b2cPayoutForResult(body []byte) (*models.B2CPayout, *B2CResult, error) {
+	if s.b2cRepo == nil {
+		return nil, nil, ErrB2CNotConfigured
+	}
+
+	var result B2CResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse B2C result: %w", err)
+	}
+
+	payout, err := s.b2cRepo.GetByConversationID(result.Result.ConversationID, result.Result.OriginatorConversationID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("payout not found for conversation: %s", result.Result.ConversationID)
+	}
+
+	if payout.Status != models.B2CPayoutSubmitted && payout.Status != models.B2CPayoutPending {
+		return payout, &result, ErrDuplicateCallback
+	}
+
+	return payout, &result, nil
+}
+
+// GetB2CPayoutsByShop lists a shop's payouts, newest first
+func (s *Service) GetB2CPayoutsByShop(shopID uint, limit, offset int) ([]models.B2CPayout, int64, error) {
+	if s.b2cRepo == nil {
+		return nil, 0, ErrB2CNotConfigured
+	}
+	return s.b2cRepo.GetByShopID(shopID, limit, offset)
+}
+
+func (s *Service) auditB2C(payout *models.B2CPayout, action, details string) {
+	if s.auditRepo == nil {
+		return
+	}
+	_ = s.auditRepo.Create(&models.AuditLog{
+		ShopID:     payout.ShopID,
+		UserType:   "shop",
+		UserID:     payout.ShopID,
+		Action:     action,
+		EntityType: "b2c_payout",
+		EntityID:   payout.ID,
+		Details:    details,
+	})
+}
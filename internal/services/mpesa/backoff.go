@@ -0,0 +1,135 @@
+package mpesa
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Backoff between attempts at a call Daraja answered with 429. Retry n
+// waits between half and all of RateLimitBaseDelay << n, up to
+// RateLimitMaxDelay.
+const (
+	RateLimitBaseDelay = 500 * time.Millisecond
+	RateLimitMaxDelay  = 8 * time.Second
+)
+
+// RateLimitMessage is what shopkeepers and customers are told when M-Pesa
+// is still rate limiting us after every retry
+const RateLimitMessage = "M-Pesa is busy right now, please try again shortly"
+
+// RateLimitedError is returned when Daraja still answers 429 after
+// MaxRetries retries. It matches ErrRateLimited.
+type RateLimitedError struct {
+	RetryAfter time.Duration // Daraja's Retry-After, or our next backoff
+}
+
+func (e *RateLimitedError) Error() string {
+	return ErrRateLimited.Error()
+}
+
+func (e *RateLimitedError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// RetryAfterSeconds is how many seconds to wait before trying a
+// rate-limited call again, for a Retry-After header; 0 when err is not a
+// rate limit
+func RetryAfterSeconds(err error) int {
+	var limited *RateLimitedError
+	if !errors.As(err, &limited) {
+		return 0
+	}
+	return max(int(math.Ceil(limited.RetryAfter.Seconds())), 1)
+}
+
+type noRetryKey struct{}
+
+// WithoutRetry makes calls made with ctx fail with ErrRateLimited at
+// Daraja's first 429 instead of backing off, for callers that must answer
+// at once, such as the WhatsApp webhook, and retry later themselves
+func WithoutRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRetryKey{}, true)
+}
+
+// rateLimitBackoff is the wait before retry n, counting from 0, with
+// jitter so shops rate limited together don't retry together
+func rateLimitBackoff(retry int) time.Duration {
+	d := RateLimitBaseDelay << retry
+	if d <= 0 || d > RateLimitMaxDelay {
+		d = RateLimitMaxDelay
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// retryAfterHeader reads a Retry-After header given in seconds or as a date
+func retryAfterHeader(resp *http.Response) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+// doWithBackoff sends the request newReq builds, building and sending it
+// again while Daraja answers 429, up to MaxRetries times. It waits as long
+// as Retry-After asks, capped at RateLimitMaxDelay, or backs off
+// exponentially, and gives up as soon as ctx is done, or at once for a ctx
+// from WithoutRetry. Any other response is returned for the caller to read.
+func (s *Service) doWithBackoff(ctx context.Context, newReq func() (*http.Request, error)) (*http.Response, error) {
+	for retry := 0; ; retry++ {
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.httpClient.Do(req.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusTooManyRequests {
+			return resp, nil
+		}
+		resp.Body.Close()
+
+		wait, ok := retryAfterHeader(resp)
+		if !ok {
+			wait = rateLimitBackoff(retry)
+		}
+		wait = min(wait, RateLimitMaxDelay)
+		if retry >= MaxRetries || ctx.Value(noRetryKey{}) != nil {
+			return nil, &RateLimitedError{RetryAfter: wait}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// jsonRequest builds an authorised JSON POST, afresh for every attempt
+func jsonRequest(url, token string, body []byte) func() (*http.Request, error) {
+	return func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	}
+}
@@ -0,0 +1,190 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+)
+
+// Daraja C2B validation result codes
+const (
+	C2BAccepted       = "0"
+	C2BInvalidAccount = "C2B00012"
+	C2BInvalidAmount  = "C2B00013"
+	C2BOtherError     = "C2B00016"
+)
+
+var (
+	ErrC2BNotConfigured        = errors.New("M-Pesa C2B confirmation and validation URLs are not configured")
+	ErrShopCredentialsRequired = errors.New("the shop has no paybill or till of its own")
+	ErrProductUnavailable      = errors.New("product is not available for sale")
+	ErrProductAmount           = errors.New("amount is not the price of one unit")
+)
+
+// productReferencePattern matches the product part of a paybill account
+// number, e.g. P42
+var productReferencePattern = regexp.MustCompile(`^P([0-9]+)$`)
+
+// C2BValidationResult is the reply Daraja expects from the validation URL
+type C2BValidationResult struct {
+	ResultCode string `json:"ResultCode"`
+	ResultDesc string `json:"ResultDesc"`
+}
+
+// Accepted reports whether the payment may go ahead
+func (r *C2BValidationResult) Accepted() bool {
+	return r.ResultCode == C2BAccepted
+}
+
+func acceptC2B() *C2BValidationResult {
+	return &C2BValidationResult{ResultCode: C2BAccepted, ResultDesc: "Accepted"}
+}
+
+func rejectC2B(code string) *C2BValidationResult {
+	return &C2BValidationResult{ResultCode: code, ResultDesc: "Rejected"}
+}
+
+// ProductAccountReference is the paybill account number a customer enters to
+// pay a shop for a product: DUKA<shop>-P<product>. Unlike STK references it
+// is not trimmed, so it is only meant for paybill payments.
+func ProductAccountReference(shopID, productID uint) string {
+	return fmt.Sprintf("%s%d-P%d", AccountReferencePrefix, shopID, productID)
+}
+
+// productFromReference returns the product ID in a paybill account number,
+// either P<product> on a shop's own paybill or DUKA<shop>-P<product>
+func productFromReference(ref string) (uint, bool) {
+	ref = strings.ToUpper(strings.TrimSpace(ref))
+	if _, ok := ParseShopAccountReference(ref); ok {
+		i := strings.Index(ref, "-")
+		if i < 0 {
+			return 0, false
+		}
+		ref = ref[i+1:]
+	}
+
+	match := productReferencePattern.FindStringSubmatch(ref)
+	if match == nil {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(match[1], 10, 64)
+	if err != nil || id == 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// RegisterC2BURLs registers the validation and confirmation URLs with Daraja
+// for a shop's own paybill or till, or for the platform shortcode when
+// shopID is 0
+func (s *Service) RegisterC2BURLs(ctx context.Context, shopID uint) error {
+	if s.config.C2BValidationURL == "" || s.config.C2BConfirmationURL == "" {
+		return ErrC2BNotConfigured
+	}
+
+	cfg := s.config
+	if shopID != 0 {
+		if s.shopCredentials(shopID) == nil {
+			return ErrShopCredentialsRequired
+		}
+		cfg = s.configForShop(shopID)
+	} else if !s.isConfigured {
+		return ErrMpesaNotConfigured
+	}
+
+	responseType := cfg.C2BResponseType
+	if responseType == "" {
+		responseType = "Completed"
+	}
+
+	_, err := s.postInitiatorRequest(ctx, cfg, RegisterURLEndpoint, map[string]interface{}{
+		"ShortCode":       cfg.Shortcode,
+		"ResponseType":    responseType,
+		"ConfirmationURL": withCallbackToken(cfg.C2BConfirmationURL, cfg.CallbackToken),
+		"ValidationURL":   withCallbackToken(cfg.C2BValidationURL, cfg.CallbackToken),
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("✅ C2B URLs registered for shortcode %s", cfg.Shortcode)
+	return nil
+}
+
+// ValidateC2B decides whether Daraja should accept a paybill payment. Payments
+// to the platform shortcode must name an active shop (DUKA<shop>), and a
+// product in the account number must belong to that shop, be in stock and
+// be paid for in full.
+func (s *Service) ValidateC2B(notification *C2BNotification) *C2BValidationResult {
+	if amount, err := strconv.ParseFloat(notification.Amount, 64); err != nil || amount <= 0 {
+		return rejectC2B(C2BInvalidAmount)
+	}
+
+	shopID := s.shopForC2B(notification)
+	if shopID == 0 {
+		return rejectC2B(C2BInvalidAccount)
+	}
+
+	if s.shopRepo != nil {
+		shop, err := s.shopRepo.GetByID(shopID)
+		if err != nil || !shop.IsActive {
+			return rejectC2B(C2BInvalidAccount)
+		}
+	}
+
+	if productID, ok := productFromReference(notification.BillReferenceNumber); ok && s.productRepo != nil {
+		product, err := s.productRepo.GetByID(productID)
+		if err != nil || product.ShopID != shopID || !product.IsActive {
+			return rejectC2B(C2BInvalidAccount)
+		}
+		if product.CurrentStock < 1 {
+			return rejectC2B(C2BOtherError)
+		}
+		// A product reference buys exactly one unit; M-Pesa rounds prices
+		// to whole shillings
+		if amount, _ := strconv.ParseFloat(notification.Amount, 64); amount != math.Round(product.SellingPrice) {
+			return rejectC2B(C2BInvalidAmount)
+		}
+	}
+
+	return acceptC2B()
+}
+
+// matchC2BSale records a sale for a paybill payment whose account number
+// names a product, reporting whether tx was linked to one
+func (s *Service) matchC2BSale(tx *models.MpesaTransaction) bool {
+	if tx.ShopID == 0 || tx.SaleID != nil || s.saleRepo == nil || s.productRepo == nil {
+		return false
+	}
+
+	productID, ok := productFromReference(tx.AccountReference)
+	if !ok {
+		return false
+	}
+
+	sale, err := s.recordProductSale(tx.ShopID, productID, tx.Amount, tx.Receipt(), tx.Phone)
+	if err != nil {
+		log.Printf("⚠️ C2B payment %s not matched to product %d: %v", tx.Receipt(), productID, err)
+		return false
+	}
+
+	tx.SaleID = &sale.ID
+	go s.sendReceipt(tx.ShopID, tx.Phone, []models.Sale{*sale})
+	return true
+}
+
+// c2bTime parses Daraja's TransactionTime (YYYYMMDDHHmmss), falling back to now
+func c2bTime(value string) time.Time {
+	if t, err := time.ParseInLocation("20060102150405", value, time.Local); err == nil {
+		return t
+	}
+	return time.Now()
+}
@@ -3,22 +3,28 @@ package mpesa
 import (
 	"bytes"
 	"context"
-	"crypto/md5"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log"
+	"math"
 	"math/rand"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/C9b3rD3vi1/DukaPOS/internal/metrics"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/webhook"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/websocket"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
 )
 
 var (
@@ -28,20 +34,34 @@ var (
 	ErrPaymentFailed      = errors.New("payment failed")
 	ErrInsufficientFunds  = errors.New("insufficient funds")
 	ErrInvalidCredentials = errors.New("invalid M-Pesa credentials")
+	ErrMissingCredentials = errors.New("M-Pesa consumer key and secret are required")
 	ErrRateLimited        = errors.New("M-Pesa API rate limited")
 	ErrNetworkError       = errors.New("network error connecting to M-Pesa")
+	ErrDuplicatePayment   = errors.New("a payment request for this phone and amount is already in progress")
+	ErrDuplicateCallback  = errors.New("callback already processed for this payment")
+	ErrPaymentNotFound    = errors.New("payment not found")
+	ErrPaymentCompleted   = errors.New("payment already completed")
+	ErrMaxRetriesExceeded = errors.New("maximum retry attempts exceeded")
+	ErrPromptPending      = errors.New("the last payment prompt is still waiting for the customer")
 )
 
 const (
 	MaxRetries          = 3
+	RetryBaseDelay      = 15 * time.Second
 	TokenCacheDuration  = 50 * time.Minute
 	PaymentTimeout      = 5 * time.Minute
+	expiryBatchSize     = 100
+	StatusCheckDelay    = 30 * time.Second
+	StatusCheckLimit    = 5
 	STKPushEndpoint     = "mpesa/stkpush/v1/processrequest"
 	STKQueryEndpoint    = "mpesa/stkpushquery/v1/query"
-	OAuthEndpoint       = "oauth/v1/generate"
+	OAuthEndpoint       = "oauth/v1/generate?grant_type=client_credentials"
 	RegisterURLEndpoint = "mpesa/c2b/v1/registerurl"
 	C2BEndpoint         = "mpesa/c2b/v1/simulate"
 	B2CEndpoint         = "mpesa/b2c/v1/paymentrequest"
+	StatusEndpoint      = "mpesa/transactionstatus/v1/query"
+	ReversalEndpoint    = "mpesa/reversal/v1/request"
+	DedupWindow         = 30 * time.Second
 )
 
 type Config struct {
@@ -50,22 +70,51 @@ type Config struct {
 	Shortcode          string
 	Passkey            string
 	CallbackURL        string
-	Environment        string
+	CallbackToken      string
+	Environment        string        // sandbox, live or mock
+	MockCallbackDelay  time.Duration // how long mock STK pushes take to be paid
+	BaseURL            string        // overrides the Daraja host, e.g. for a proxy or mock server
 	InitiatorName      string
-	SecurityCredential string
+	SecurityCredential string // initiator password encrypted with the Daraja certificate
+	TransactionType    string // CustomerPayBillOnline (default) or CustomerBuyGoodsOnline
+	B2CResultURL       string
+	B2CTimeoutURL      string
+	B2CDailyLimit      float64 // per-shop payout cap in KES, 0 for none
+	StatusResultURL    string
+	ReversalResultURL  string
+	C2BValidationURL   string
+	C2BConfirmationURL string
+	C2BResponseType    string // Completed (default) or Cancelled when validation is unreachable
+}
+
+type cachedToken struct {
+	token  string
+	expiry time.Time
 }
 
 type Service struct {
 	config          *Config
 	httpClient      *http.Client
-	authToken       string
-	tokenExpiry     time.Time
+	tokens          map[string]cachedToken // keyed by consumer key
 	tokenMutex      sync.RWMutex
+	credentialRepo  *repository.IntegrationCredentialRepository
+	encryptor       Encryptor
 	paymentRepo     *repository.MpesaPaymentRepository
 	transactionRepo *repository.MpesaTransactionRepository
 	saleRepo        *repository.SaleRepository
 	productRepo     *repository.ProductRepository
 	shopRepo        *repository.ShopRepository
+	b2cRepo         *repository.B2CPayoutRepository
+	orderRepo       *repository.OrderRepository
+	auditRepo       *repository.AuditLogRepository
+	reversalMutex   sync.Mutex
+	cache           DedupStore
+	planHandler     PlanPaymentHandler
+	pendingSaleRepo *repository.PendingSaleRepository
+	paymentLinkRepo *repository.PaymentLinkRepository
+	paymentLinkURL  string
+	receipts        ReceiptSender
+	converter       CurrencyConverter
 	callbackURL     string
 	isConfigured    bool
 	environment     string
@@ -78,6 +127,19 @@ type PaymentRequest struct {
 	Description      string
 	ShopID           uint
 	ProductID        *uint
+	PendingSaleID    *uint           // basket being paid for; Amount may be left 0
+	PaymentLinkID    *uint           // link the customer is paying through
+	Plan             models.PlanType // set for subscription payments to the platform
+}
+
+// PlanPaymentHandler applies a paid subscription plan
+type PlanPaymentHandler interface {
+	CompletePlanPayment(payment *models.MpesaPayment) error
+}
+
+// ReceiptSender texts a customer the receipt for sales they paid for
+type ReceiptSender interface {
+	SendReceipt(shop *models.Shop, phone string, sales []models.Sale) (*models.SmsMessage, error)
 }
 
 type STKPushResponse struct {
@@ -86,6 +148,10 @@ type STKPushResponse struct {
 	ResponseCode        string `json:"ResponseCode"`
 	ResponseDescription string `json:"ResponseDescription"`
 	CustomerMessage     string `json:"CustomerMessage"`
+
+	// Set by the STK query only
+	ResultCode string `json:"ResultCode"`
+	ResultDesc string `json:"ResultDesc"`
 }
 
 type TokenResponse struct {
@@ -117,8 +183,59 @@ type CallbackMetadata struct {
 }
 
 type CallbackItem struct {
-	Name  string `json:"Name"`
-	Value string `json:"Value"`
+	Name  string        `json:"Name"`
+	Value CallbackValue `json:"Value"`
+}
+
+// CallbackValue is a callback metadata value. Safaricom sends the receipt
+// as a string but Amount, PhoneNumber and TransactionDate as numbers.
+type CallbackValue string
+
+func (v *CallbackValue) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		*v = CallbackValue(str)
+		return nil
+	}
+	var num json.Number
+	if err := json.Unmarshal(data, &num); err != nil {
+		return fmt.Errorf("invalid callback value %s", data)
+	}
+	*v = CallbackValue(num)
+	return nil
+}
+
+// STKMetadata is what a successful STK callback reports about the payment
+type STKMetadata struct {
+	Amount        float64
+	HasAmount     bool // Amount was present; query results omit it
+	Receipt       string
+	TransactionID string
+	PhoneNumber   string
+}
+
+// Metadata extracts the amount paid, receipt and payer from the callback
+func (c STKCallback) Metadata() STKMetadata {
+	var meta STKMetadata
+	for _, item := range c.CallbackMetadata.Item {
+		value := string(item.Value)
+		switch item.Name {
+		case "Amount":
+			if amount, err := strconv.ParseFloat(value, 64); err == nil {
+				meta.Amount, meta.HasAmount = amount, true
+			}
+		case "MpesaReceiptNumber":
+			meta.Receipt = value
+		case "TransactionID":
+			meta.TransactionID = value
+		case "PhoneNumber":
+			meta.PhoneNumber = value
+		}
+	}
+	return meta
 }
 
 type C2BNotification struct {
@@ -143,13 +260,18 @@ func New(config *Config, paymentRepo *repository.MpesaPaymentRepository, transac
 	svc := &Service{
 		config:      config,
 		httpClient:  &http.Client{Timeout: 30 * time.Second},
-		callbackURL: config.CallbackURL,
+		tokens:      make(map[string]cachedToken),
+		callbackURL: withCallbackToken(config.CallbackURL, config.CallbackToken),
 		environment: config.Environment,
 	}
 
 	if config.ConsumerKey != "" && config.ConsumerSecret != "" && config.Shortcode != "" {
 		svc.isConfigured = true
 	}
+	if config.Environment == EnvironmentMock {
+		// Mock payments need no Daraja credentials
+		svc.isConfigured = true
+	}
 
 	svc.paymentRepo = paymentRepo
 	svc.transactionRepo = transactionRepo
@@ -157,6 +279,18 @@ func New(config *Config, paymentRepo *repository.MpesaPaymentRepository, transac
 	return svc
 }
 
+// withCallbackToken appends the shared callback token as a ?token= query parameter
+func withCallbackToken(callbackURL, token string) string {
+	if callbackURL == "" || token == "" {
+		return callbackURL
+	}
+	sep := "?"
+	if strings.Contains(callbackURL, "?") {
+		sep = "&"
+	}
+	return callbackURL + sep + "token=" + url.QueryEscape(token)
+}
+
 func (s *Service) SetRepositories(paymentRepo *repository.MpesaPaymentRepository, transactionRepo *repository.MpesaTransactionRepository) {
 	s.paymentRepo = paymentRepo
 	s.transactionRepo = transactionRepo
@@ -168,11 +302,55 @@ func (s *Service) SetBusinessRepos(saleRepo *repository.SaleRepository, productR
 	s.shopRepo = shopRepo
 }
 
+// DedupStore holds the locks that stop a repeat STK push within
+// DedupWindow. The cache service implements it with Redis keys
+// mpesa:dedup:{shop}:{phone}:{amount}; GetLock returns "" for a missing key.
+type DedupStore interface {
+	AcquireLock(key, value string, ttl time.Duration) (bool, error)
+	GetLock(key string) (string, error)
+	UpdateLock(key, value string) error
+	ReleaseLock(key string) error
+}
+
+// SetCache enables Redis-backed deduplication of STK pushes.
+func (s *Service) SetCache(store DedupStore) {
+	s.cache = store
+}
+
+// SetPlanPaymentHandler sets who applies plans once a subscription payment
+// completes
+func (s *Service) SetPlanPaymentHandler(handler PlanPaymentHandler) {
+	s.planHandler = handler
+}
+
+// SetReceiptSender sends customers a receipt for the sales their payments
+// record, for shops that have receipts turned on
+func (s *Service) SetReceiptSender(sender ReceiptSender) {
+	s.receipts = sender
+}
+
+// sendReceipt texts the payer a receipt for sales recorded from a payment
+func (s *Service) sendReceipt(shopID uint, phone string, sales []models.Sale) {
+	if s.receipts == nil || s.shopRepo == nil || len(sales) == 0 {
+		return
+	}
+	shop, err := s.shopRepo.GetByID(shopID)
+	if err != nil {
+		return
+	}
+	if _, err := s.receipts.SendReceipt(shop, phone, sales); err != nil {
+		log.Printf("⚠️ Failed to send receipt for sale %d to %s: %v", sales[0].ID, phone, err)
+	}
+}
+
 func (s *Service) IsConfigured() bool {
 	return s.isConfigured
 }
 
 func (s *Service) getBaseURL() string {
+	if s.config.BaseURL != "" {
+		return strings.TrimRight(s.config.BaseURL, "/")
+	}
 	if s.environment == "live" {
 		return "https://api.safaricom.co.ke"
 	}
@@ -191,46 +369,47 @@ func (s *Service) getSTKQueryURL() string {
 	return fmt.Sprintf("%s/%s", s.getBaseURL(), STKQueryEndpoint)
 }
 
-func (s *Service) getToken() (string, error) {
+func (s *Service) getToken(ctx context.Context, cfg *Config) (string, error) {
 	s.tokenMutex.RLock()
-	if s.authToken != "" && time.Now().Before(s.tokenExpiry) {
+	if t, ok := s.tokens[cfg.ConsumerKey]; ok && time.Now().Before(t.expiry) {
 		defer s.tokenMutex.RUnlock()
-		return s.authToken, nil
+		return t.token, nil
 	}
 	s.tokenMutex.RUnlock()
 
-	return s.getTokenFresh()
+	return s.getTokenFresh(ctx, cfg)
 }
 
-func (s *Service) getTokenFresh() (string, error) {
+func (s *Service) getTokenFresh(ctx context.Context, cfg *Config) (string, error) {
+	// Daraja answers a blank key or secret with an unhelpful 400
+	if cfg.ConsumerKey == "" || cfg.ConsumerSecret == "" {
+		return "", ErrMissingCredentials
+	}
+
 	s.tokenMutex.Lock()
 	defer s.tokenMutex.Unlock()
 
-	if s.authToken != "" && time.Now().Before(s.tokenExpiry) {
-		return s.authToken, nil
+	if t, ok := s.tokens[cfg.ConsumerKey]; ok && time.Now().Before(t.expiry) {
+		return t.token, nil
 	}
 
-	credentials := fmt.Sprintf("%s:%s", s.config.ConsumerKey, s.config.ConsumerSecret)
-	encoded := base64.StdEncoding.EncodeToString([]byte(credentials))
-
-	req, err := http.NewRequest("GET", s.getAuthURL(), nil)
-	if err != nil {
-		return "", fmt.Errorf("%w: %v", ErrNetworkError, err)
+	resp, err := s.doWithBackoff(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequest("GET", s.getAuthURL(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", basicAuthHeader(cfg))
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	if errors.Is(err, ErrRateLimited) {
+		return "", err
 	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Basic %s", encoded))
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := s.httpClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("%w: %v", ErrNetworkError, err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusTooManyRequests {
-		return "", ErrRateLimited
-	}
-
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return "", fmt.Errorf("M-Pesa auth failed (status %d): %s", resp.StatusCode, string(body))
@@ -245,56 +424,62 @@ func (s *Service) getTokenFresh() (string, error) {
 		return "", ErrInvalidCredentials
 	}
 
-	s.authToken = result.AccessToken
 	expiresIn := 3600
 	if result.ExpiresIn != "" {
 		if e, err := strconv.Atoi(result.ExpiresIn); err == nil {
 			expiresIn = e
 		}
 	}
-	s.tokenExpiry = time.Now().Add(time.Duration(expiresIn-300) * time.Second)
+	s.tokens[cfg.ConsumerKey] = cachedToken{
+		token:  result.AccessToken,
+		expiry: time.Now().Add(time.Duration(expiresIn-300) * time.Second),
+	}
 
-	return s.authToken, nil
+	return result.AccessToken, nil
 }
 
+// ValidatePhone returns phone as Daraja expects it, 254712345678, or
+// ErrInvalidPhone if it is not a Kenyan mobile number
 func (s *Service) ValidatePhone(phone string) (string, error) {
-	phone = strings.TrimSpace(phone)
-	phone = strings.ReplaceAll(phone, " ", "")
-	phone = strings.ReplaceAll(phone, "-", "")
+	phone = strings.TrimPrefix(utils.NormalizePhone(phone), "+")
 
-	phone = strings.TrimPrefix(phone, "+")
-
-	if strings.HasPrefix(phone, "254") && len(phone) == 12 {
+	if matched, _ := regexp.MatchString(`^254[0-9]{9}$`, phone); matched {
 		return phone, nil
 	}
 
-	if strings.HasPrefix(phone, "0") && len(phone) == 10 {
-		return "254" + phone[1:], nil
-	}
-
-	if strings.HasPrefix(phone, "7") && len(phone) == 9 {
-		return "254" + phone, nil
-	}
+	return "", ErrInvalidPhone
+}
 
-	if strings.HasPrefix(phone, "1") && len(phone) == 9 {
-		return "254" + phone, nil
-	}
+// AccessToken returns an OAuth token for the platform credentials, cached
+// until it expires
+func (s *Service) AccessToken() (string, error) {
+	return s.getToken(context.Background(), s.config)
+}
 
-	if matched, _ := regexp.MatchString(`^254[0-9]{9}$`, phone); matched {
-		return phone, nil
-	}
+// BasicAuthHeader returns the Authorization header for the OAuth token request
+func (s *Service) BasicAuthHeader() string {
+	return basicAuthHeader(s.config)
+}
 
-	return "", ErrInvalidPhone
+func basicAuthHeader(cfg *Config) string {
+	credentials := fmt.Sprintf("%s:%s", cfg.ConsumerKey, cfg.ConsumerSecret)
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
 }
 
+// GeneratePassword returns the STK password Daraja expects:
+// base64(Shortcode + Passkey + Timestamp), with no hashing.
 func (s *Service) GeneratePassword(timestamp string) string {
-	data := fmt.Sprintf("%s%s%s", s.config.Shortcode, s.config.Passkey, timestamp)
-	hash := md5.Sum([]byte(data))
-	return base64.StdEncoding.EncodeToString(hash[:])
+	return generatePassword(s.config, timestamp)
+}
+
+func generatePassword(cfg *Config, timestamp string) string {
+	data := fmt.Sprintf("%s%s%s", cfg.Shortcode, cfg.Passkey, timestamp)
+	return base64.StdEncoding.EncodeToString([]byte(data))
 }
 
 func (s *Service) InitiateSTKPush(ctx context.Context, req *PaymentRequest) (*models.MpesaPayment, *STKPushResponse, error) {
-	if !s.isConfigured {
+	cfg := s.configForPayment(req.ShopID, req.Plan)
+	if cfg == nil {
 		return nil, nil, ErrMpesaNotConfigured
 	}
 
@@ -303,6 +488,16 @@ func (s *Service) InitiateSTKPush(ctx context.Context, req *PaymentRequest) (*mo
 		return nil, nil, err
 	}
 
+	if req.PendingSaleID != nil {
+		if _, err := s.openPendingSale(req); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Daraja only takes whole shillings; round rather than truncate so
+	// KSh 49.60 is charged as 50, not 49
+	req.Amount, _ = models.RoundingNearest1.Round(req.Amount)
+
 	if req.Amount <= 0 {
 		return nil, nil, errors.New("amount must be greater than 0")
 	}
@@ -311,65 +506,124 @@ func (s *Service) InitiateSTKPush(ctx context.Context, req *PaymentRequest) (*mo
 		return nil, nil, errors.New("amount exceeds maximum allowed (150,000 KES)")
 	}
 
+	reference, err := s.stkAccountReference(cfg, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lockKey := dedupKey(req.ShopID, validatedPhone, req.Amount, req.PendingSaleID, req.PaymentLinkID)
+	if s.cache != nil {
+		acquired, err := s.cache.AcquireLock(lockKey, "", DedupWindow)
+		if err != nil {
+			log.Printf("⚠️ STK push dedup unavailable for shop %d, sending anyway: %v", req.ShopID, err)
+		} else if !acquired {
+			existing, err := s.getDedupPayment(lockKey)
+			if err != nil {
+				return nil, nil, err
+			}
+			return existing, nil, nil
+		}
+	}
+
+	now := time.Now()
 	payment := &models.MpesaPayment{
 		ShopID:           req.ShopID,
 		ProductID:        req.ProductID,
+		PendingSaleID:    req.PendingSaleID,
+		PaymentLinkID:    req.PaymentLinkID,
 		Amount:           req.Amount,
 		Phone:            validatedPhone,
-		AccountReference: req.AccountReference,
+		AccountReference: reference,
 		Description:      req.Description,
+		Plan:             req.Plan,
 		Status:           models.MpesaPaymentPending,
-		ExpiresAt:        time.Now().Add(PaymentTimeout),
+		LastAttemptAt:    &now,
+		ExpiresAt:        now.Add(PaymentTimeout),
 	}
 
-	token, err := s.getToken()
-	if err != nil {
+	result, err := s.sendSTKPush(ctx, cfg, payment)
+	s.savePayment(lockKey, payment)
+	return payment, result, err
+}
+
+// stkAccountReference is the reference an STK push is sent with. Pushes to
+// the platform shortcode while its paybill confirmations come to us carry
+// the shop's DUKA<id> prefix, so the confirmation of the payment finds the
+// shop; a shop's own shortcode identifies the shop by itself. A push with
+// no reference is sent with the prefix alone. A prefixed reference that
+// doesn't fit Daraja's 12 characters is refused rather than cut.
+func (s *Service) stkAccountReference(cfg *Config, req *PaymentRequest) (string, error) {
+	if req.ShopID == 0 {
+		return req.AccountReference, nil
+	}
+	platform := cfg.Shortcode == s.config.Shortcode && s.config.C2BConfirmationURL != ""
+	if req.AccountReference != "" && !platform {
+		return req.AccountReference, nil
+	}
+
+	reference := ShopAccountReference(req.ShopID, req.AccountReference)
+	if len(reference) > maxAccountReference {
+		prefix := fmt.Sprintf("%s%d-", AccountReferencePrefix, req.ShopID)
+		return "", fmt.Errorf("%w; after %s there is room for %d characters",
+			ErrAccountReferenceTooLong, prefix, max(maxAccountReference-len(prefix), 0))
+	}
+	return reference, nil
+}
+
+// sendSTKPush sends an STK push prompt for a payment and records the
+// outcome on it. A payment whose prompt was not accepted is marked failed.
+func (s *Service) sendSTKPush(ctx context.Context, cfg *Config, payment *models.MpesaPayment) (*STKPushResponse, error) {
+	fail := func(reason string) {
 		payment.Status = models.MpesaPaymentFailed
-		payment.FailureReason = fmt.Sprintf("Auth failed: %v", err)
-		if s.paymentRepo != nil {
-			_ = s.paymentRepo.Create(payment)
-		}
-		return payment, nil, err
+		payment.FailureReason = reason
+	}
+
+	if s.IsMock() {
+		return s.mockSTKPush(payment), nil
+	}
+
+	token, err := s.getToken(ctx, cfg)
+	if err != nil {
+		fail(fmt.Sprintf("Auth failed: %v", err))
+		return nil, err
 	}
 
 	timestamp := time.Now().Format("20060102150405")
-	password := s.GeneratePassword(timestamp)
+	password := generatePassword(cfg, timestamp)
+
+	transactionType := cfg.TransactionType
+	if transactionType == "" {
+		transactionType = "CustomerPayBillOnline"
+	}
 
 	stkReq := map[string]interface{}{
-		"BusinessShortCode": s.config.Shortcode,
+		"BusinessShortCode": cfg.Shortcode,
 		"Password":          password,
 		"Timestamp":         timestamp,
-		"TransactionType":   "CustomerPayBillOnline",
-		"Amount":            int(req.Amount),
-		"PartyA":            validatedPhone,
-		"PartyB":            s.config.Shortcode,
-		"PhoneNumber":       validatedPhone,
+		"TransactionType":   transactionType,
+		"Amount":            int(payment.Amount),
+		"PartyA":            payment.Phone,
+		"PartyB":            cfg.Shortcode,
+		"PhoneNumber":       payment.Phone,
 		"CallBackURL":       s.callbackURL,
-		"AccountReference":  req.AccountReference,
-		"TransactionDesc":   req.Description,
+		"AccountReference":  payment.AccountReference,
+		"TransactionDesc":   payment.Description,
 	}
 
 	body, err := json.Marshal(stkReq)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to marshal request: %w", err)
+		fail(fmt.Sprintf("Invalid request: %v", err))
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", s.getSTKPushURL(), bytes.NewBuffer(body))
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	resp, err := s.doWithBackoff(ctx, jsonRequest(s.getSTKPushURL(), token, body))
+	if errors.Is(err, ErrRateLimited) {
+		fail(RateLimitMessage)
+		return nil, err
 	}
-
-	httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	resp, err := s.httpClient.Do(httpReq)
 	if err != nil {
-		payment.Status = models.MpesaPaymentFailed
-		payment.FailureReason = fmt.Sprintf("Network error: %v", err)
-		if s.paymentRepo != nil {
-			_ = s.paymentRepo.Create(payment)
-		}
-		return payment, nil, fmt.Errorf("%w: %v", ErrNetworkError, err)
+		fail(fmt.Sprintf("Network error: %v", err))
+		return nil, fmt.Errorf("%w: %v", ErrNetworkError, err)
 	}
 	defer resp.Body.Close()
 
@@ -377,66 +631,128 @@ func (s *Service) InitiateSTKPush(ctx context.Context, req *PaymentRequest) (*mo
 
 	var result STKPushResponse
 	if err := json.Unmarshal(respBody, &result); err != nil {
-		payment.Status = models.MpesaPaymentFailed
-		payment.FailureReason = fmt.Sprintf("Invalid response: %v", err)
-		if s.paymentRepo != nil {
-			_ = s.paymentRepo.Create(payment)
-		}
-		return payment, nil, fmt.Errorf("failed to decode response: %w", err)
+		fail(fmt.Sprintf("Invalid response: %v", err))
+		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	payment.MerchantRequestID = result.MerchantRequestID
-	payment.CheckoutRequestID = result.CheckoutRequestID
+	if result.CheckoutRequestID != "" {
+		payment.MerchantRequestID = result.MerchantRequestID
+		payment.CheckoutRequestID = result.CheckoutRequestID
+	}
 
 	if result.ResponseCode != "0" {
-		payment.Status = models.MpesaPaymentFailed
-		payment.FailureReason = result.ResponseDescription
+		reason := result.ResponseDescription
 
 		if result.ResponseCode == "1" {
-			payment.FailureReason = "M-Pesa is currently unavailable"
+			reason = "M-Pesa is currently unavailable"
 		} else if result.ResponseCode == "2" {
-			payment.FailureReason = "Invalid M-Pesa credentials"
+			reason = "Invalid M-Pesa credentials"
 		} else if result.ResponseCode == "3" {
-			payment.FailureReason = "Invalid shortcode"
+			reason = "Invalid shortcode"
 		} else if result.ResponseCode == "4" {
-			payment.FailureReason = "Invalid transaction type"
+			reason = "Invalid transaction type"
 		} else if result.ResponseCode == "5" {
-			payment.FailureReason = "Invalid amount"
+			reason = "Invalid amount"
 		} else if result.ResponseCode == "6" {
-			payment.FailureReason = "Invalid party"
+			reason = "Invalid party"
 		} else if result.ResponseCode == "17" {
-			payment.FailureReason = "Invalid SMS sender"
+			reason = "Invalid SMS sender"
 		}
 
-		if s.paymentRepo != nil {
-			_ = s.paymentRepo.Create(payment)
+		fail(reason)
+		return &result, fmt.Errorf("STK push failed: %s", result.ResponseDescription)
+	}
+
+	return &result, nil
+}
+
+// savePayment persists a payment created by InitiateSTKPush. Pending payments
+// keep the dedup lock pointing at their ID; failed ones release it.
+func (s *Service) savePayment(key string, payment *models.MpesaPayment) {
+	if s.paymentRepo != nil {
+		if err := s.paymentRepo.Create(payment); err == nil {
+			_ = s.paymentRepo.CreateAttempt(newAttempt(payment))
 		}
+	}
 
-		return payment, &result, fmt.Errorf("STK push failed: %s", result.ResponseDescription)
+	if payment.Status != models.MpesaPaymentPending {
+		metrics.MpesaSTKFailure.Inc()
+		s.releaseDedup(key)
+		return
 	}
 
-	if s.paymentRepo != nil {
-		_ = s.paymentRepo.Create(payment)
+	if s.cache != nil && payment.ID != 0 {
+		if err := s.cache.UpdateLock(key, strconv.FormatUint(uint64(payment.ID), 10)); err != nil {
+			log.Printf("⚠️ Failed to point STK push dedup at payment %d: %v", payment.ID, err)
+		}
 	}
+}
 
-	return payment, &result, nil
+func (s *Service) getDedupPayment(key string) (*models.MpesaPayment, error) {
+	value, err := s.cache.GetLock(key)
+	if err != nil || value == "" || s.paymentRepo == nil {
+		return nil, ErrDuplicatePayment
+	}
+
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return nil, ErrDuplicatePayment
+	}
+
+	return s.paymentRepo.GetByID(uint(id))
+}
+
+func (s *Service) releaseDedup(key string) {
+	if s.cache != nil {
+		if err := s.cache.ReleaseLock(key); err != nil {
+			log.Printf("⚠️ Failed to release STK push dedup: %v", err)
+		}
+	}
+}
+
+// dedupKey identifies repeat prompts; baskets that happen to cost the same
+// are kept apart
+func dedupKey(shopID uint, phone string, amount float64, pendingSaleID, paymentLinkID *uint) string {
+	key := fmt.Sprintf("mpesa:dedup:%d:%s:%d", shopID, phone, int(amount))
+	if pendingSaleID != nil {
+		key += fmt.Sprintf(":sale:%d", *pendingSaleID)
+	}
+	if paymentLinkID != nil {
+		key += fmt.Sprintf(":link:%d", *paymentLinkID)
+	}
+	return key
 }
 
 func (s *Service) QuerySTKStatus(ctx context.Context, checkoutID string) (*STKPushResponse, error) {
-	if !s.isConfigured {
+	var shopID uint
+	var plan models.PlanType
+	if s.paymentRepo != nil {
+		if payment, err := s.paymentRepo.GetByCheckoutRequestID(checkoutID); err == nil {
+			shopID, plan = payment.ShopID, payment.Plan
+		}
+	}
+
+	cfg := s.configForPayment(shopID, plan)
+	if cfg == nil {
 		return nil, ErrMpesaNotConfigured
 	}
 
-	token, err := s.getToken()
+	// Mock prompts are settled by their own callback; report them as
+	// still waiting
+	if s.IsMock() {
+		return &STKPushResponse{ResponseCode: "0", ResponseDescription: "Mock request is being processed"}, nil
+	}
+
+	token, err := s.getToken(ctx, cfg)
 	if err != nil {
 		return nil, err
 	}
 
 	timestamp := time.Now().Format("20060102150405")
-	password := s.GeneratePassword(timestamp)
+	password := generatePassword(cfg, timestamp)
 
 	queryReq := map[string]interface{}{
-		"BusinessShortCode": s.config.Shortcode,
+		"BusinessShortCode": cfg.Shortcode,
 		"Password":          password,
 		"Timestamp":         timestamp,
 		"CheckoutRequestID": checkoutID,
@@ -444,15 +760,10 @@ func (s *Service) QuerySTKStatus(ctx context.Context, checkoutID string) (*STKPu
 
 	body, _ := json.Marshal(queryReq)
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", s.getSTKQueryURL(), bytes.NewBuffer(body))
-	if err != nil {
+	resp, err := s.doWithBackoff(ctx, jsonRequest(s.getSTKQueryURL(), token, body))
+	if errors.Is(err, ErrRateLimited) {
 		return nil, err
 	}
-
-	httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	resp, err := s.httpClient.Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrNetworkError, err)
 	}
@@ -477,30 +788,78 @@ func (s *Service) ProcessSTKCallback(callbackBody []byte) (*models.MpesaPayment,
 		return nil, fmt.Errorf("failed to parse callback: %w", err)
 	}
 
-	stkCallback := callback.Body.STKCallback
+	return s.settleSTKResult(callback.Body.STKCallback)
+}
 
-	payment, err := s.paymentRepo.GetByCheckoutRequestID(stkCallback.CheckoutRequestID)
+// settleSTKResult applies the outcome of an STK prompt, whether Safaricom
+// sent it as a callback or we learned it by querying
+func (s *Service) settleSTKResult(stkCallback STKCallback) (*models.MpesaPayment, error) {
+	payment, attempt, err := s.findSTKPayment(stkCallback.CheckoutRequestID)
 	if err != nil {
 		return nil, fmt.Errorf("payment not found for checkout: %s", stkCallback.CheckoutRequestID)
 	}
 
-	if stkCallback.ResultCode == 0 {
-		receipt := ""
-		transactionID := ""
-
-		for _, item := range stkCallback.CallbackMetadata.Item {
-			switch item.Name {
-			case "Amount":
-			case "MpesaReceiptNumber":
-				receipt = item.Value
-			case "TransactionID":
-				transactionID = item.Value
-			}
+	if attempt != nil {
+		status, reason := models.MpesaPaymentCompleted, ""
+		if stkCallback.ResultCode != 0 {
+			status, reason = models.MpesaPaymentFailed, stkCallback.ResultDesc
 		}
+		_ = s.paymentRepo.UpdateAttemptStatus(attempt.ID, status, reason)
+	}
+
+	// Safaricom may resend callbacks; only a pending payment can be settled.
+	// A request we already timed out is still settled by a late callback,
+	// as is a retried payment whose earlier prompt was paid after all.
+	settleable := payment.Status == models.MpesaPaymentPending || payment.Status == models.MpesaPaymentTimeout ||
+		(payment.Status == models.MpesaPaymentFailed && attempt != nil && stkCallback.ResultCode == 0)
+	if !settleable {
+		if payment.Status == models.MpesaPaymentCompleted && payment.MpesaReceipt == "" && stkCallback.ResultCode == 0 {
+			s.backfillReceipt(payment, stkCallback)
+		}
+		if stkCallback.ResultCode == 0 && attempt != nil && attempt.CheckoutRequestID != payment.CheckoutRequestID {
+			log.Printf("⚠️ Payment %d was paid again through an earlier prompt (%s), check for a double charge",
+				payment.ID, attempt.CheckoutRequestID)
+		}
+		return payment, ErrDuplicateCallback
+	}
+
+	// The customer may still cancel a prompt a retry replaced; only the
+	// latest prompt can fail the payment, but any of them can pay it
+	if stkCallback.ResultCode != 0 && stkCallback.CheckoutRequestID != payment.CheckoutRequestID {
+		return payment, fmt.Errorf("%w: prompt replaced by a retry", ErrDuplicateCallback)
+	}
+
+	// The checks above read the payment before settling it; claim it with a
+	// conditional update so a callback delivered twice at once settles once
+	settled := models.MpesaPaymentCompleted
+	if stkCallback.ResultCode != 0 {
+		if payment.Status == models.MpesaPaymentTimeout {
+			return payment, ErrDuplicateCallback
+		}
+		settled = models.MpesaPaymentFailed
+	}
+	claimed, err := s.paymentRepo.ClaimSettlement(payment.ID, payment.Status, settled)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim payment: %w", err)
+	}
+	if !claimed {
+		return payment, ErrDuplicateCallback
+	}
+
+	if stkCallback.ResultCode == 0 {
+		meta := stkCallback.Metadata()
+		receipt := meta.Receipt
+		transactionID := meta.TransactionID
 
 		payment.MpesaReceipt = receipt
 		payment.MpesaTransactionID = transactionID
+		payment.PayerPhone = meta.PhoneNumber
 		payment.Status = models.MpesaPaymentCompleted
+		payment.FailureReason = ""
+		if meta.HasAmount {
+			payment.AmountPaid = meta.Amount
+			payment.AmountMismatch = amountMismatch(payment.Amount, meta.Amount)
+		}
 
 		now := time.Now()
 		payment.CompletedAt = &now
@@ -508,55 +867,127 @@ func (s *Service) ProcessSTKCallback(callbackBody []byte) (*models.MpesaPayment,
 		if err := s.paymentRepo.Update(payment); err != nil {
 			return nil, fmt.Errorf("failed to update payment: %w", err)
 		}
+		s.releaseDedup(dedupKey(payment.ShopID, payment.Phone, payment.Amount, payment.PendingSaleID, payment.PaymentLinkID))
+		metrics.MpesaSTKSuccess.Inc()
+
+		if payment.AmountMismatch != "" {
+			log.Printf("⚠️ Payment %d %s: requested KSh %.2f, paid KSh %.2f (%s)",
+				payment.ID, payment.AmountMismatch, payment.Amount, payment.AmountPaid, receipt)
+		}
 
-		if s.saleRepo != nil && s.productRepo != nil && payment.ProductID != nil {
-			s.processSuccessfulPayment(payment)
+		switch {
+		case payment.AmountMismatch == models.MpesaUnderpaid:
+			// Money came in but not enough for what was asked; leave the
+			// sale, plan or link unsettled for the shop to review
+		case payment.Plan != "":
+			if s.planHandler != nil {
+				if err := s.planHandler.CompletePlanPayment(payment); err != nil {
+					log.Printf("❌ Failed to apply %s plan for payment %d: %v", payment.Plan, payment.ID, err)
+				}
+			}
+		case payment.PaymentLinkID != nil:
+			s.settlePaymentLink(payment)
+		default:
+			s.settleSale(payment)
 		}
 
-		_ = s.transactionRepo.Create(&models.MpesaTransaction{
-			ShopID:          payment.ShopID,
-			Type:            "stk_push",
-			Amount:          payment.Amount,
-			Phone:           payment.Phone,
-			TransactionID:   transactionID,
-			ReceiptNumber:   receipt,
-			TransactionTime: time.Now(),
-			Status:          "completed",
-		})
+		// Plan payments are the platform's income, not the shop's
+		if payment.Plan == "" {
+			_ = s.transactionRepo.Create(&models.MpesaTransaction{
+				ShopID:          payment.ShopID,
+				Type:            "stk_push",
+				Amount:          payment.Amount,
+				Phone:           payment.Phone,
+				TransactionID:   transactionID,
+				ReceiptNumber:   receipt,
+				TransactionTime: time.Now(),
+				Status:          "completed",
+			})
+		}
 
 	} else {
 		payment.Status = models.MpesaPaymentFailed
 		payment.FailureReason = stkCallback.ResultDesc
 		_ = s.paymentRepo.Update(payment)
+		s.releaseDedup(dedupKey(payment.ShopID, payment.Phone, payment.Amount, payment.PendingSaleID, payment.PaymentLinkID))
+		metrics.MpesaSTKFailure.Inc()
 	}
 
+	publishPaymentStatus(payment)
 	return payment, nil
 }
 
-func (s *Service) processSuccessfulPayment(payment *models.MpesaPayment) {
-	product, err := s.productRepo.GetByID(*payment.ProductID)
-	if err != nil {
-		return
+// amountMismatch compares what was paid to what was requested, to the cent
+func amountMismatch(requested, paid float64) string {
+	switch diff := math.Round((paid-requested)*100) / 100; {
+	case diff < 0:
+		return models.MpesaUnderpaid
+	case diff > 0:
+		return models.MpesaOverpaid
 	}
+	return ""
+}
+
+// findSTKPayment finds the payment a checkout ID belongs to. Retried
+// payments are found through their attempts, so callbacks for earlier
+// prompts still land.
+func (s *Service) findSTKPayment(checkoutID string) (*models.MpesaPayment, *models.MpesaPaymentAttempt, error) {
+	if checkoutID == "" {
+		return nil, nil, ErrPaymentNotFound
+	}
+	if attempt, err := s.paymentRepo.GetAttemptByCheckoutRequestID(checkoutID); err == nil {
+		payment, err := s.paymentRepo.GetByID(attempt.PaymentID)
+		return payment, attempt, err
+	}
+	payment, err := s.paymentRepo.GetByCheckoutRequestID(checkoutID)
+	return payment, nil, err
+}
 
-	if product.CurrentStock < 1 {
+// publishPaymentStatus sends a settled payment to the shop's webhooks and
+// live dashboard
+func publishPaymentStatus(payment *models.MpesaPayment) {
+	var event webhook.EventType
+	switch payment.Status {
+	case models.MpesaPaymentCompleted:
+		event = webhook.EventPaymentCompleted
+	case models.MpesaPaymentFailed:
+		event = webhook.EventPaymentFailed
+	case models.MpesaPaymentTimeout:
+		event = webhook.EventPaymentExpired
+	default:
 		return
 	}
 
-	qty := 1
-	if payment.Amount >= product.SellingPrice*2 {
-		qty = int(payment.Amount / product.SellingPrice)
-		if qty > product.CurrentStock {
-			qty = product.CurrentStock
-		}
+	webhook.TriggerMpesaPayment(event, payment)
+	websocket.NotifyPaymentStatus(payment.ShopID, payment.ID, payment.CheckoutRequestID,
+		string(payment.Status), payment.Amount, payment.MpesaReceipt)
+}
+
+// recordProductSale records an M-Pesa payment for one unit of a product as a
+// sale. Any other amount is left unattributed for the shop to sort out
+// rather than guessing how many units it was meant to buy.
+func (s *Service) recordProductSale(shopID, productID uint, amount float64, receipt, phone string) (*models.Sale, error) {
+	product, err := s.productRepo.GetByID(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	if product.ShopID != shopID || !product.IsActive || product.CurrentStock < 1 {
+		return nil, ErrProductUnavailable
 	}
 
+	// M-Pesa only takes whole shillings
+	if amount != math.Round(product.SellingPrice) {
+		return nil, ErrProductAmount
+	}
+
+	qty := 1
 	totalAmount := product.SellingPrice * float64(qty)
 	costAmount := product.CostPrice * float64(qty)
 	profit := totalAmount - costAmount
 
 	sale := &models.Sale{
-		ShopID:        payment.ShopID,
+		ShopID:        shopID,
 		ProductID:     product.ID,
 		Quantity:      qty,
 		UnitPrice:     product.SellingPrice,
@@ -564,42 +995,73 @@ func (s *Service) processSuccessfulPayment(payment *models.MpesaPayment) {
 		CostAmount:    costAmount,
 		Profit:        profit,
 		PaymentMethod: models.PaymentMpesa,
-		MpesaReceipt:  payment.MpesaReceipt,
-		MpesaPhone:    payment.Phone,
-		Notes:         fmt.Sprintf("M-Pesa Payment: %s", payment.MpesaReceipt),
+		MpesaReceipt:  receipt,
+		MpesaPhone:    phone,
+		Notes:         fmt.Sprintf("M-Pesa Payment: %s", receipt),
 	}
+	sale.ApplyRounding(models.RoundingNearest1)
 
 	if err := s.saleRepo.Create(sale); err != nil {
-		return
+		return nil, err
 	}
 
-	_ = s.productRepo.UpdateStock(product.ID, -qty)
-	_ = s.paymentRepo.LinkToSale(payment.ID, sale.ID)
+	if _, soldOut, err := s.productRepo.MoveStock(product.ID, -qty, models.StockMovementSale, &sale.ID, ""); err == nil {
+		webhook.TriggerProductDeactivated(soldOut, "out_of_stock")
+	}
+	websocket.NotifySaleCreated(sale, product, product.CurrentStock-qty)
+	return sale, nil
 }
 
+// HandleC2BNotification records a confirmed paybill/till payment against the
+// shop it was made to. When the account reference names a product
+// (DUKA<shop>-P<product>) a sale is recorded for it as well.
 func (s *Service) HandleC2BNotification(notification *C2BNotification) (*models.MpesaTransaction, error) {
+	if s.transactionRepo == nil {
+		return nil, errors.New("transaction repository not configured")
+	}
+
 	amount, _ := strconv.ParseFloat(notification.Amount, 64)
 	phone := strings.TrimPrefix(notification.PhoneNumber, "+")
 
-	existing, _ := s.transactionRepo.GetByReceiptNumber(notification.BillReferenceNumber)
-	if existing != nil {
+	// Daraja retries confirmations; the receipt code identifies the payment
+	if existing, _ := s.transactionRepo.GetByTransactionID(notification.TransactionID); existing != nil {
+		// A receipt the shop already queried by hand is completed in place
+		if existing.Status == "unverified" {
+			// The shop that queried it may not be the one it was paid to
+			existing.ShopID = s.shopForC2B(notification)
+			existing.ReceiptNumber = notification.TransactionID
+			existing.Amount = amount
+			existing.Phone = phone
+			existing.AccountReference = notification.BillReferenceNumber
+			existing.CustomerName = notification.Name
+			existing.Status = "completed"
+			s.matchC2BSale(existing)
+			if err := s.transactionRepo.Update(existing); err != nil {
+				return nil, err
+			}
+		}
 		return existing, nil
 	}
 
 	tx := &models.MpesaTransaction{
-		TransactionID:   notification.TransactionID,
-		ReceiptNumber:   notification.BillReferenceNumber,
-		Amount:          amount,
-		Phone:           phone,
-		Type:            "c2b",
-		TransactionTime: time.Now(),
-		Status:          "completed",
+		ShopID:           s.shopForC2B(notification),
+		TransactionID:    notification.TransactionID,
+		ReceiptNumber:    notification.TransactionID,
+		AccountReference: notification.BillReferenceNumber,
+		CustomerName:     notification.Name,
+		Amount:           amount,
+		Phone:            phone,
+		Type:             "c2b",
+		TransactionTime:  c2bTime(notification.TransactionTime),
+		Status:           "completed",
+	}
+
+	if err := s.transactionRepo.Create(tx); err != nil {
+		return nil, err
 	}
 
-	if s.transactionRepo != nil {
-		if err := s.transactionRepo.Create(tx); err != nil {
-			return nil, err
-		}
+	if s.matchC2BSale(tx) {
+		_ = s.transactionRepo.Update(tx)
 	}
 
 	return tx, nil
@@ -616,7 +1078,8 @@ func (s *Service) GetPaymentByCheckoutID(checkoutID string) (*models.MpesaPaymen
 	if s.paymentRepo == nil {
 		return nil, errors.New("payment repository not configured")
 	}
-	return s.paymentRepo.GetByCheckoutRequestID(checkoutID)
+	payment, _, err := s.findSTKPayment(checkoutID)
+	return payment, err
 }
 
 func (s *Service) GetPaymentsByShop(shopID uint, limit, offset int) ([]models.MpesaPayment, int64, error) {
@@ -633,75 +1096,254 @@ func (s *Service) GetTransactionsByShop(shopID uint, limit, offset int) ([]model
 	return s.transactionRepo.GetByShopID(shopID, limit, offset)
 }
 
-func (s *Service) RetryPayment(ctx context.Context, paymentID uint) (*models.MpesaPayment, error) {
+// RetryWaitError is returned when a payment is retried before its backoff
+// has passed
+type RetryWaitError struct {
+	Wait time.Duration
+}
+
+func (e *RetryWaitError) Error() string {
+	return fmt.Sprintf("retry too soon, try again in %s", e.Wait.Round(time.Second))
+}
+
+// RetryBackoff is how long after an attempt the next retry is allowed: 15s
+// after the first prompt, doubling with each retry
+func RetryBackoff(retries int) time.Duration {
+	return RetryBaseDelay << retries
+}
+
+// NextRetryAt is when a payment may next be retried
+func NextRetryAt(payment *models.MpesaPayment) time.Time {
+	last := payment.CreatedAt
+	if payment.LastAttemptAt != nil {
+		last = *payment.LastAttemptAt
+	}
+	return last.Add(RetryBackoff(payment.RetryCount))
+}
+
+// RetryPayment sends a new STK prompt for a shop's pending or failed payment.
+// The payment keeps its row and AccountReference; each prompt is recorded
+// as an attempt so callbacks for any of them still find it. A pending
+// payment's last prompt is queried first, so a customer who paid it or is
+// still looking at it isn't prompted again.
+func (s *Service) RetryPayment(ctx context.Context, shopID, paymentID uint) (*models.MpesaPayment, error) {
 	payment, err := s.paymentRepo.GetByID(paymentID)
-	if err != nil {
-		return nil, err
+	if err != nil || payment.ShopID != shopID {
+		return nil, ErrPaymentNotFound
 	}
 
-	if payment.Status != models.MpesaPaymentPending {
-		return nil, errors.New("payment is not in pending state")
+	switch payment.Status {
+	case models.MpesaPaymentCompleted:
+		return payment, ErrPaymentCompleted
+	case models.MpesaPaymentTimeout:
+		return payment, ErrPaymentExpired
 	}
 
-	if time.Now().After(payment.ExpiresAt) {
-		_ = s.paymentRepo.MarkAsFailed(paymentID, "Payment request expired")
-		return nil, ErrPaymentExpired
+	now := time.Now()
+	if now.After(payment.ExpiresAt) {
+		if payment.Status == models.MpesaPaymentPending {
+			if expired, _ := s.paymentRepo.MarkAsExpired(paymentID, ErrPaymentExpired.Error()); expired {
+				payment.Status = models.MpesaPaymentTimeout
+				payment.FailureReason = ErrPaymentExpired.Error()
+				publishPaymentStatus(payment)
+			}
+		}
+		return payment, ErrPaymentExpired
 	}
 
 	if payment.RetryCount >= MaxRetries {
-		_ = s.paymentRepo.MarkAsFailed(paymentID, "Maximum retries exceeded")
-		return nil, errors.New("maximum retry attempts exceeded")
+		return payment, ErrMaxRetriesExceeded
 	}
 
-	req := &PaymentRequest{
-		Phone:            payment.Phone,
-		Amount:           payment.Amount,
-		AccountReference: payment.AccountReference,
-		Description:      payment.Description,
-		ShopID:           payment.ShopID,
+	if wait := NextRetryAt(payment).Sub(now); wait > 0 {
+		return payment, &RetryWaitError{Wait: wait}
+	}
+
+	cfg := s.configForPayment(payment.ShopID, payment.Plan)
+	if cfg == nil {
+		return nil, ErrMpesaNotConfigured
 	}
 
-	newPayment, _, err := s.InitiateSTKPush(ctx, req)
+	if payment.Status == models.MpesaPaymentPending && payment.CheckoutRequestID != "" {
+		if _, err := s.settleByQuery(ctx, payment); err != nil && !errors.Is(err, ErrDuplicateCallback) {
+			return payment, err
+		}
+		if payment, err = s.paymentRepo.GetByID(paymentID); err != nil {
+			return nil, err
+		}
+		if payment.Status == models.MpesaPaymentCompleted {
+			return payment, ErrPaymentCompleted
+		}
+	}
+
+	// Claim the retry before prompting, so that two retries at once, or a
+	// retry and a callback paying the payment, don't both go through
+	claimed, err := s.paymentRepo.ClaimRetry(payment.ID, payment.RetryCount, now)
 	if err != nil {
-		_ = s.paymentRepo.IncrementRetryCount(paymentID)
 		return nil, err
 	}
+	if !claimed {
+		if latest, err := s.paymentRepo.GetByID(paymentID); err == nil && latest.Status == models.MpesaPaymentCompleted {
+			return latest, ErrPaymentCompleted
+		}
+		return payment, ErrPromptPending
+	}
+
+	payment.Status = models.MpesaPaymentPending
+	payment.FailureReason = ""
+	payment.RetryCount++
+	payment.LastAttemptAt = &now
+	payment.StatusChecks = 0
+	payment.LastStatusCheckAt = nil
 
-	return newPayment, nil
+	result, err := s.sendSTKPush(ctx, cfg, payment)
+	if payment.Status != models.MpesaPaymentPending {
+		metrics.MpesaSTKFailure.Inc()
+	}
+
+	recorded, updateErr := s.paymentRepo.RecordRetry(payment)
+	if updateErr != nil {
+		return nil, updateErr
+	}
+	if !recorded {
+		log.Printf("⚠️ Payment %d was paid through an earlier prompt while retrying, check for a double charge", payment.ID)
+	}
+
+	// A prompt Daraja refused has no checkout ID of its own
+	attempt := newAttempt(payment)
+	if result == nil || result.CheckoutRequestID == "" {
+		attempt.MerchantRequestID, attempt.CheckoutRequestID = "", ""
+	}
+	_ = s.paymentRepo.CreateAttempt(attempt)
+
+	return payment, err
 }
 
+// newAttempt records the prompt just sent for a payment
+func newAttempt(payment *models.MpesaPayment) *models.MpesaPaymentAttempt {
+	return &models.MpesaPaymentAttempt{
+		PaymentID:         payment.ID,
+		Attempt:           payment.RetryCount + 1,
+		MerchantRequestID: payment.MerchantRequestID,
+		CheckoutRequestID: payment.CheckoutRequestID,
+		Status:            payment.Status,
+		FailureReason:     payment.FailureReason,
+	}
+}
+
+// ProcessExpiredPayments times out pending STK pushes past their expiry and
+// publishes a payment.expired event for each
 func (s *Service) ProcessExpiredPayments() error {
+	if s.paymentRepo == nil {
+		return nil
+	}
+
+	for {
+		payments, err := s.paymentRepo.GetExpiredPending(time.Now(), expiryBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for i := range payments {
+			payment := &payments[i]
+			expired, err := s.paymentRepo.MarkAsExpired(payment.ID, ErrPaymentExpired.Error())
+			if err != nil {
+				return err
+			}
+			if !expired {
+				// Settled by a callback since we read it
+				continue
+			}
+
+			payment.Status = models.MpesaPaymentTimeout
+			payment.FailureReason = ErrPaymentExpired.Error()
+			s.releaseDedup(dedupKey(payment.ShopID, payment.Phone, payment.Amount, payment.PendingSaleID, payment.PaymentLinkID))
+			metrics.MpesaSTKFailure.Inc()
+			publishPaymentStatus(payment)
+		}
+
+		if len(payments) < expiryBatchSize {
+			return nil
+		}
+	}
+}
+
+// backfillReceipt records the receipt from a callback that arrives after a
+// status query already completed the payment, which the query can't return
+func (s *Service) backfillReceipt(payment *models.MpesaPayment, stkCallback STKCallback) {
+	meta := stkCallback.Metadata()
+	payment.MpesaReceipt = meta.Receipt
+	payment.MpesaTransactionID = meta.TransactionID
+	if payment.MpesaReceipt != "" {
+		_ = s.paymentRepo.Update(payment)
+	}
+}
+
+// PollPendingPayments asks Daraja for the result of STK prompts whose
+// callback is overdue and settles them as the callback would have. Each
+// payment is queried at most StatusCheckLimit times before its expiry;
+// ProcessExpiredPayments times out whatever is still pending after that.
+func (s *Service) PollPendingPayments() error {
+	if s.paymentRepo == nil {
+		return nil
+	}
+
 	now := time.Now()
-	shops, _, err := s.shopRepo.List(1000, 0)
+	payments, err := s.paymentRepo.GetDueForStatusCheck(now.Add(-StatusCheckDelay), now, StatusCheckLimit, expiryBatchSize)
 	if err != nil {
 		return err
 	}
 
-	for _, shop := range shops {
-		payments, err := s.paymentRepo.GetPendingByShopID(shop.ID, now)
+	for i := range payments {
+		payment := &payments[i]
+		if err := s.paymentRepo.RecordStatusCheck(payment.ID, now); err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		settled, err := s.settleByQuery(ctx, payment)
+		cancel()
+		if errors.Is(err, ErrPromptPending) || errors.Is(err, ErrDuplicateCallback) {
+			// Still waiting on the customer, or a callback got there first
+			continue
+		}
 		if err != nil {
+			log.Printf("⚠️ Status query for payment %d failed: %v", payment.ID, err)
 			continue
 		}
+		log.Printf("🔎 Payment %d settled by status query: %s", settled.ID, settled.Status)
+	}
+	return nil
+}
 
-		for _, payment := range payments {
-			if now.After(payment.ExpiresAt) {
-				_ = s.paymentRepo.MarkAsFailed(payment.ID, "Payment request expired")
-			}
-		}
+// settleByQuery asks Daraja for the result of a payment's latest prompt and
+// settles the payment as the callback would have. It returns
+// ErrPromptPending while the prompt is still waiting on the customer.
+func (s *Service) settleByQuery(ctx context.Context, payment *models.MpesaPayment) (*models.MpesaPayment, error) {
+	result, err := s.QuerySTKStatus(ctx, payment.CheckoutRequestID)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	// Daraja reports a prompt still waiting on the customer without a
+	// result code, or with one that isn't a number
+	resultCode, err := strconv.Atoi(result.ResultCode)
+	if result.ResultCode == "" || err != nil {
+		return nil, ErrPromptPending
+	}
+
+	return s.settleSTKResult(STKCallback{
+		MerchantRequestID: result.MerchantRequestID,
+		CheckoutRequestID: payment.CheckoutRequestID,
+		ResultCode:        resultCode,
+		ResultDesc:        result.ResultDesc,
+	})
 }
 
 func ParseCallback(data []byte) (*CallbackData, error) {
 	var callback struct {
 		Body struct {
-			CallbackMetadata struct {
-				Item []struct {
-					Name  string `json:"Name"`
-					Value string `json:"Value"`
-				} `json:"Item"`
-			} `json:"CallbackMetadata"`
+			CallbackMetadata CallbackMetadata `json:"CallbackMetadata"`
 		} `json:"Body"`
 	}
 
@@ -713,15 +1355,15 @@ func ParseCallback(data []byte) (*CallbackData, error) {
 	for _, item := range callback.Body.CallbackMetadata.Item {
 		switch item.Name {
 		case "Amount":
-			result.Amount = item.Value
+			result.Amount = string(item.Value)
 		case "MpesaReceiptNumber", "BillRefNumber":
-			result.ReceiptNo = item.Value
+			result.ReceiptNo = string(item.Value)
 		case "TransactionID":
-			result.TransactionID = item.Value
+			result.TransactionID = string(item.Value)
 		case "PhoneNumber":
-			result.PhoneNumber = item.Value
+			result.PhoneNumber = string(item.Value)
 		case "TransactionDate":
-			result.TransactionDate = item.Value
+			result.TransactionDate = string(item.Value)
 		}
 	}
 
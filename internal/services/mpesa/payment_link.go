@@ -0,0 +1,207 @@
+package mpesa
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/webhook"
+)
+
+// DefaultPaymentLinkTTL is how long a payment link stays payable when no
+// expiry is given
+const DefaultPaymentLinkTTL = 7 * 24 * time.Hour
+
+// maxSTKAmount is the largest amount Daraja accepts in one STK push
+const maxSTKAmount = 150000
+
+var (
+	ErrPaymentLinkNotFound    = errors.New("payment link not found")
+	ErrPaymentLinkUnavailable = errors.New("payment link has been paid, cancelled or has expired")
+	ErrPaymentLinkAmount      = errors.New("amount must be between 1 and 150,000 KES")
+)
+
+// SetPaymentLinkRepo enables payment links. baseURL is where this server's
+// public /pay pages are reachable.
+func (s *Service) SetPaymentLinkRepo(repo *repository.PaymentLinkRepository, baseURL string) {
+	s.paymentLinkRepo = repo
+	s.paymentLinkURL = strings.TrimRight(baseURL, "/")
+}
+
+// PaymentLinkURL returns the page a customer opens to pay a link
+func (s *Service) PaymentLinkURL(link *models.PaymentLink) string {
+	return s.paymentLinkURL + "/pay/" + link.Token
+}
+
+// CreatePaymentLink creates a link for a fixed amount, or an open amount
+// when amount is 0. A ttl of 0 uses DefaultPaymentLinkTTL.
+func (s *Service) CreatePaymentLink(shopID uint, amount float64, description string, ttl time.Duration) (*models.PaymentLink, error) {
+	if s.paymentLinkRepo == nil {
+		return nil, errors.New("payment links are not configured")
+	}
+
+	amount, _ = models.RoundingNearest1.Round(amount)
+	if amount < 0 || amount > maxSTKAmount {
+		return nil, ErrPaymentLinkAmount
+	}
+	if ttl <= 0 {
+		ttl = DefaultPaymentLinkTTL
+	}
+
+	token, err := newPaymentLinkToken()
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(ttl)
+	link := &models.PaymentLink{
+		ShopID:      shopID,
+		Token:       token,
+		Amount:      amount,
+		Description: description,
+		Status:      models.PaymentLinkActive,
+		ExpiresAt:   &expiresAt,
+	}
+	if err := s.paymentLinkRepo.Create(link); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// GetPaymentLink gets one of the shop's payment links
+func (s *Service) GetPaymentLink(shopID, id uint) (*models.PaymentLink, error) {
+	if s.paymentLinkRepo == nil {
+		return nil, ErrPaymentLinkNotFound
+	}
+	link, err := s.paymentLinkRepo.GetByID(id)
+	if err != nil || link.ShopID != shopID {
+		return nil, ErrPaymentLinkNotFound
+	}
+	s.expireIfDue(link)
+	return link, nil
+}
+
+// GetPaymentLinkByToken gets a payment link from its public token
+func (s *Service) GetPaymentLinkByToken(token string) (*models.PaymentLink, error) {
+	if s.paymentLinkRepo == nil || token == "" {
+		return nil, ErrPaymentLinkNotFound
+	}
+	link, err := s.paymentLinkRepo.GetByToken(token)
+	if err != nil {
+		return nil, ErrPaymentLinkNotFound
+	}
+	s.expireIfDue(link)
+	return link, nil
+}
+
+// ListPaymentLinks lists a shop's payment links, newest first
+func (s *Service) ListPaymentLinks(shopID uint, limit, offset int) ([]models.PaymentLink, int64, error) {
+	if s.paymentLinkRepo == nil {
+		return nil, 0, nil
+	}
+	links, total, err := s.paymentLinkRepo.GetByShop(shopID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range links {
+		s.expireIfDue(&links[i])
+	}
+	return links, total, nil
+}
+
+// CancelPaymentLink stops an active link from taking payments
+func (s *Service) CancelPaymentLink(shopID, id uint) error {
+	if _, err := s.GetPaymentLink(shopID, id); err != nil {
+		return err
+	}
+	if err := s.paymentLinkRepo.SetStatus(id, models.PaymentLinkCancelled); err != nil {
+		if errors.Is(err, repository.ErrPaymentLinkClosed) {
+			return ErrPaymentLinkUnavailable
+		}
+		return err
+	}
+	return nil
+}
+
+// PayPaymentLink sends an STK push for a link to the customer's phone.
+// amount is only read for open amount links.
+func (s *Service) PayPaymentLink(ctx context.Context, token, phone string, amount float64) (*models.MpesaPayment, error) {
+	link, err := s.GetPaymentLinkByToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if !link.IsPayable(time.Now()) {
+		return nil, ErrPaymentLinkUnavailable
+	}
+
+	if !link.IsOpenAmount() {
+		amount = link.Amount
+	}
+	if amount, _ = models.RoundingNearest1.Round(amount); amount <= 0 || amount > maxSTKAmount {
+		return nil, ErrPaymentLinkAmount
+	}
+
+	description := link.Description
+	if description == "" {
+		description = "Payment link"
+	}
+	payment, _, err := s.InitiateSTKPush(ctx, &PaymentRequest{
+		Phone:            phone,
+		Amount:           amount,
+		AccountReference: fmt.Sprintf("L%d", link.ID),
+		Description:      description,
+		ShopID:           link.ShopID,
+		PaymentLinkID:    &link.ID,
+	})
+	return payment, err
+}
+
+// settlePaymentLink marks a link paid by a completed payment and tells the
+// shop's webhooks. A second payment for a link that was already paid is
+// left for the shop to deal with.
+func (s *Service) settlePaymentLink(payment *models.MpesaPayment) {
+	if s.paymentLinkRepo == nil {
+		notifyUnattributed(payment, "payment links are not configured")
+		return
+	}
+
+	if err := s.paymentLinkRepo.MarkPaid(*payment.PaymentLinkID, payment); err != nil {
+		log.Printf("⚠️ Payment %d not recorded against payment link %d: %v", payment.ID, *payment.PaymentLinkID, err)
+		notifyUnattributed(payment, err.Error())
+		return
+	}
+
+	link, err := s.paymentLinkRepo.GetByID(*payment.PaymentLinkID)
+	if err != nil {
+		log.Printf("⚠️ Failed to reload payment link %d: %v", *payment.PaymentLinkID, err)
+		return
+	}
+	log.Printf("🔗 Payment link %d paid: KSh %.0f (%s)", link.ID, link.PaidAmount, link.MpesaReceipt)
+	webhook.TriggerPaymentLinkPaid(link)
+}
+
+// expireIfDue marks an active link past its expiry as expired
+func (s *Service) expireIfDue(link *models.PaymentLink) {
+	if link.Status != models.PaymentLinkActive || link.IsPayable(time.Now()) {
+		return
+	}
+	if err := s.paymentLinkRepo.SetStatus(link.ID, models.PaymentLinkExpired); err == nil {
+		link.Status = models.PaymentLinkExpired
+	}
+}
+
+// newPaymentLinkToken returns a random token short enough to fit in an SMS
+func newPaymentLinkToken() (string, error) {
+	b := make([]byte, 9)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate payment link token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,205 @@
+package mpesa
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/webhook"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/websocket"
+)
+
+var (
+	ErrPendingSaleNotFound  = errors.New("pending sale not found")
+	ErrPendingSaleEmpty     = errors.New("a pending sale needs at least one item")
+	ErrPendingSaleAmount    = errors.New("amount does not match the pending sale total")
+	ErrPaymentNotCompleted  = errors.New("payment has not completed")
+	ErrPaymentAlreadyLinked = errors.New("payment is already linked to a sale")
+)
+
+// PendingSaleItemRequest is one product line of a basket to be paid for
+type PendingSaleItemRequest struct {
+	ProductID uint `json:"product_id"`
+	Quantity  int  `json:"quantity"`
+}
+
+// SetPendingSaleRepo enables paying for baskets. An STK push naming a
+// pending sale records exactly its items once paid.
+func (s *Service) SetPendingSaleRepo(repo *repository.PendingSaleRepository) {
+	s.pendingSaleRepo = repo
+}
+
+// CreatePendingSale prices a basket at the products' current selling
+// prices. The total is rounded to whole shillings, as M-Pesa charges.
+func (s *Service) CreatePendingSale(shopID uint, items []PendingSaleItemRequest, notes string) (*models.PendingSale, error) {
+	if s.pendingSaleRepo == nil || s.productRepo == nil {
+		return nil, errors.New("pending sales are not configured")
+	}
+	if len(items) == 0 {
+		return nil, ErrPendingSaleEmpty
+	}
+
+	pending := &models.PendingSale{
+		ShopID: shopID,
+		Status: models.PendingSaleOpen,
+		Notes:  notes,
+	}
+	var total float64
+	for _, item := range items {
+		if item.Quantity < 1 {
+			return nil, fmt.Errorf("invalid quantity for product %d", item.ProductID)
+		}
+		product, err := s.productRepo.GetByID(item.ProductID)
+		if err != nil || product.ShopID != shopID || !product.IsActive {
+			return nil, fmt.Errorf("%w: product %d", ErrProductUnavailable, item.ProductID)
+		}
+		if product.CurrentStock < item.Quantity {
+			return nil, fmt.Errorf("not enough %s in stock (%d left)", product.Name, product.CurrentStock)
+		}
+
+		pending.Items = append(pending.Items, models.PendingSaleItem{
+			ProductID: product.ID,
+			Quantity:  item.Quantity,
+			UnitPrice: product.SellingPrice,
+		})
+		total += product.SellingPrice * float64(item.Quantity)
+	}
+	pending.TotalAmount, _ = models.RoundingNearest1.Round(total)
+
+	if err := s.pendingSaleRepo.Create(pending); err != nil {
+		return nil, err
+	}
+	return pending, nil
+}
+
+// GetPendingSale gets one of the shop's pending sales
+func (s *Service) GetPendingSale(shopID, id uint) (*models.PendingSale, error) {
+	if s.pendingSaleRepo == nil {
+		return nil, ErrPendingSaleNotFound
+	}
+	pending, err := s.pendingSaleRepo.GetByID(id)
+	if err != nil || pending.ShopID != shopID {
+		return nil, ErrPendingSaleNotFound
+	}
+	return pending, nil
+}
+
+// CancelPendingSale cancels an unpaid basket
+func (s *Service) CancelPendingSale(shopID, id uint) error {
+	if _, err := s.GetPendingSale(shopID, id); err != nil {
+		return err
+	}
+	return s.pendingSaleRepo.Cancel(id)
+}
+
+// openPendingSale checks that a payment request can pay for the named
+// pending sale, filling in the amount when it was left out
+func (s *Service) openPendingSale(req *PaymentRequest) (*models.PendingSale, error) {
+	pending, err := s.GetPendingSale(req.ShopID, *req.PendingSaleID)
+	if err != nil {
+		return nil, err
+	}
+	if pending.Status != models.PendingSaleOpen {
+		return nil, repository.ErrPendingSaleClosed
+	}
+
+	if req.Amount == 0 {
+		req.Amount = pending.TotalAmount
+	}
+	if amount, _ := models.RoundingNearest1.Round(req.Amount); amount != pending.TotalAmount {
+		return nil, ErrPendingSaleAmount
+	}
+	return pending, nil
+}
+
+// AttributePayment records a completed payment that arrived without a
+// basket as payment for one of the shop's pending sales
+func (s *Service) AttributePayment(shopID, paymentID, pendingSaleID uint) ([]models.Sale, error) {
+	payment, err := s.paymentRepo.GetByID(paymentID)
+	if err != nil || payment.ShopID != shopID {
+		return nil, ErrPaymentNotFound
+	}
+	if payment.Status != models.MpesaPaymentCompleted {
+		return nil, ErrPaymentNotCompleted
+	}
+	if payment.SaleID != nil || payment.PendingSaleID != nil || payment.PaymentLinkID != nil || payment.Plan != "" {
+		return nil, ErrPaymentAlreadyLinked
+	}
+
+	pending, err := s.GetPendingSale(shopID, pendingSaleID)
+	if err != nil {
+		return nil, err
+	}
+	if pending.TotalAmount != payment.Amount {
+		return nil, ErrPendingSaleAmount
+	}
+
+	payment.PendingSaleID = &pending.ID
+	sales, err := s.confirmPendingSale(payment)
+	if err != nil {
+		payment.PendingSaleID = nil
+		return nil, err
+	}
+	return sales, nil
+}
+
+// settleSale records what a completed STK payment bought. Only a payment
+// for a pending sale becomes a sale; anything else is left for the shop
+// to attribute rather than guessed from the amount.
+func (s *Service) settleSale(payment *models.MpesaPayment) {
+	if payment.PendingSaleID == nil || s.pendingSaleRepo == nil {
+		notifyUnattributed(payment, "no pending sale")
+		return
+	}
+
+	if _, err := s.confirmPendingSale(payment); err != nil {
+		log.Printf("⚠️ Payment %d not recorded against pending sale %d: %v", payment.ID, *payment.PendingSaleID, err)
+		// Unlink the basket it could not pay for so the shop can attribute
+		// the payment to another one
+		if payment.SaleID == nil {
+			payment.PendingSaleID = nil
+			if err := s.paymentRepo.Update(payment); err != nil {
+				log.Printf("❌ Failed to unlink payment %d from its pending sale: %v", payment.ID, err)
+			}
+		}
+		notifyUnattributed(payment, err.Error())
+	}
+}
+
+// confirmPendingSale turns the payment's pending sale into sales and links
+// the payment to them
+func (s *Service) confirmPendingSale(payment *models.MpesaPayment) ([]models.Sale, error) {
+	sales, err := s.pendingSaleRepo.Confirm(*payment.PendingSaleID, payment)
+	if err != nil {
+		return nil, err
+	}
+
+	payment.SaleID = &sales[0].ID
+	if err := s.paymentRepo.Update(payment); err != nil {
+		return nil, err
+	}
+
+	for i := range sales {
+		var product *models.Product
+		if s.productRepo != nil {
+			soldOut, _ := s.productRepo.DeactivateIfOutOfStock(sales[i].ProductID)
+			webhook.TriggerProductDeactivated(soldOut, "out_of_stock")
+			product, _ = s.productRepo.GetByID(sales[i].ProductID)
+		}
+		stock := 0
+		if product != nil {
+			stock = product.CurrentStock
+		}
+		websocket.NotifySaleCreated(&sales[i], product, stock)
+	}
+	go s.sendReceipt(payment.ShopID, payment.Phone, sales)
+	return sales, nil
+}
+
+// notifyUnattributed asks the shop to say what a payment was for
+func notifyUnattributed(payment *models.MpesaPayment, reason string) {
+	log.Printf("💰 Payment %d (KSh %.0f, %s) has no sale: %s", payment.ID, payment.Amount, payment.MpesaReceipt, reason)
+	websocket.NotifyPaymentUnattributed(payment.ShopID, payment.ID, payment.Amount, payment.Phone, payment.MpesaReceipt, reason)
+}
@@ -0,0 +1,323 @@
+package mpesa
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+)
+
+// ReconciliationStatus is the outcome of matching one receipt
+type ReconciliationStatus string
+
+const (
+	ReconciliationMatched          ReconciliationStatus = "matched"
+	ReconciliationAmountMismatch   ReconciliationStatus = "amount_mismatch"
+	ReconciliationUnmatchedPayment ReconciliationStatus = "unmatched_payment" // money received, no sale
+	ReconciliationUnmatchedSale    ReconciliationStatus = "unmatched_sale"    // M-Pesa sale, no payment
+)
+
+// ReconciliationRow is one receipt, or one M-Pesa sale without a receipt
+type ReconciliationRow struct {
+	Status        ReconciliationStatus `json:"status"`
+	Receipt       string               `json:"receipt"`
+	Source        string               `json:"source,omitempty"` // stk_push or c2b
+	Phone         string               `json:"phone,omitempty"`
+	PaymentAmount float64              `json:"payment_amount"`
+	SaleAmount    float64              `json:"sale_amount"`
+	Difference    float64              `json:"difference"` // payment minus sales
+	SaleIDs       []uint               `json:"sale_ids"`
+	PaidAt        *time.Time           `json:"paid_at,omitempty"`
+	SoldAt        *time.Time           `json:"sold_at,omitempty"`
+}
+
+// ReconciliationSummary totals a reconciliation. Payment and sale totals
+// cover the date range only; matches may reach outside it.
+type ReconciliationSummary struct {
+	From                  time.Time `json:"from"`
+	To                    time.Time `json:"to"`
+	PaymentCount          int       `json:"payment_count"`
+	PaymentTotal          float64   `json:"payment_total"`
+	SaleCount             int       `json:"sale_count"`
+	SaleTotal             float64   `json:"sale_total"`
+	MatchedCount          int       `json:"matched_count"`
+	MatchedTotal          float64   `json:"matched_total"`
+	MismatchCount         int       `json:"amount_mismatch_count"`
+	UnmatchedPaymentCount int       `json:"unmatched_payment_count"`
+	UnmatchedPaymentTotal float64   `json:"unmatched_payment_total"`
+	UnmatchedSaleCount    int       `json:"unmatched_sale_count"`
+	UnmatchedSaleTotal    float64   `json:"unmatched_sale_total"`
+	Difference            float64   `json:"difference"` // payment total minus sale total
+}
+
+// ReconciliationReport is the result of reconciling a date range
+type ReconciliationReport struct {
+	Summary ReconciliationSummary `json:"summary"`
+	Rows    []ReconciliationRow   `json:"rows"`
+}
+
+// ReconciliationService matches M-Pesa money received against the sales
+// recorded for it, by receipt number
+type ReconciliationService struct {
+	paymentRepo     *repository.MpesaPaymentRepository
+	transactionRepo *repository.MpesaTransactionRepository
+	saleRepo        *repository.SaleRepository
+}
+
+// NewReconciliationService creates a reconciliation service
+func NewReconciliationService(
+	paymentRepo *repository.MpesaPaymentRepository,
+	transactionRepo *repository.MpesaTransactionRepository,
+	saleRepo *repository.SaleRepository,
+) *ReconciliationService {
+	return &ReconciliationService{
+		paymentRepo:     paymentRepo,
+		transactionRepo: transactionRepo,
+		saleRepo:        saleRepo,
+	}
+}
+
+type receivedPayment struct {
+	receipt string
+	source  string
+	phone   string
+	amount  float64
+	paidAt  time.Time
+}
+
+// Reconcile matches a shop's M-Pesa payments and sales in [from, to).
+// Transactions are the ledger of money received; completed STK payments
+// fill in any receipt missing from it. A payment or sale whose partner
+// falls just outside the range is still matched.
+func (s *ReconciliationService) Reconcile(shopID uint, from, to time.Time) (*ReconciliationReport, error) {
+	payments := make(map[string]*receivedPayment)
+	var order []string
+	addPayment := func(p *receivedPayment) {
+		if p.receipt == "" || payments[p.receipt] != nil {
+			return
+		}
+		payments[p.receipt] = p
+		order = append(order, p.receipt)
+	}
+
+	transactions, err := s.transactionRepo.GetReceivedInRange(shopID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for i := range transactions {
+		addPayment(transactionPayment(&transactions[i]))
+	}
+
+	completed, err := s.paymentRepo.GetCompletedInRange(shopID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range completed {
+		addPayment(&receivedPayment{
+			receipt: normalizeReceipt(p.MpesaReceipt),
+			source:  "stk_push",
+			phone:   p.Phone,
+			amount:  p.Amount,
+			paidAt:  *p.CompletedAt,
+		})
+	}
+
+	report := &ReconciliationReport{Summary: ReconciliationSummary{From: from, To: to}}
+	for _, receipt := range order {
+		report.Summary.PaymentCount++
+		report.Summary.PaymentTotal += payments[receipt].amount
+	}
+
+	sales, err := s.saleRepo.GetMpesaByDateRange(shopID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	salesByReceipt := make(map[string][]models.Sale)
+	var unreceipted []models.Sale
+	for _, sale := range sales {
+		report.Summary.SaleCount++
+		report.Summary.SaleTotal += sale.TotalAmount
+		receipt := normalizeReceipt(sale.MpesaReceipt)
+		if receipt == "" {
+			unreceipted = append(unreceipted, sale)
+			continue
+		}
+		if payments[receipt] == nil && salesByReceipt[receipt] == nil {
+			order = append(order, receipt)
+		}
+		salesByReceipt[receipt] = append(salesByReceipt[receipt], sale)
+	}
+
+	// Look outside the range for the other half of anything unmatched
+	var missingSales, missingPayments []string
+	for _, receipt := range order {
+		if salesByReceipt[receipt] == nil {
+			missingSales = append(missingSales, receipt)
+		} else if payments[receipt] == nil {
+			missingPayments = append(missingPayments, receipt)
+		}
+	}
+	outside, err := s.saleRepo.GetByMpesaReceipts(shopID, missingSales)
+	if err != nil {
+		return nil, err
+	}
+	for _, sale := range outside {
+		receipt := normalizeReceipt(sale.MpesaReceipt)
+		salesByReceipt[receipt] = append(salesByReceipt[receipt], sale)
+	}
+	earlier, err := s.transactionRepo.GetReceivedByReceipts(shopID, missingPayments)
+	if err != nil {
+		return nil, err
+	}
+	for i := range earlier {
+		p := transactionPayment(&earlier[i])
+		if payments[p.receipt] == nil {
+			payments[p.receipt] = p
+		}
+	}
+
+	for _, receipt := range order {
+		report.add(newReconciliationRow(receipt, payments[receipt], salesByReceipt[receipt]))
+	}
+	for _, sale := range unreceipted {
+		report.add(newReconciliationRow("", nil, []models.Sale{sale}))
+	}
+
+	sort.SliceStable(report.Rows, func(i, j int) bool {
+		return report.Rows[i].time().Before(report.Rows[j].time())
+	})
+
+	sum := &report.Summary
+	sum.PaymentTotal = roundCents(sum.PaymentTotal)
+	sum.SaleTotal = roundCents(sum.SaleTotal)
+	sum.MatchedTotal = roundCents(sum.MatchedTotal)
+	sum.UnmatchedPaymentTotal = roundCents(sum.UnmatchedPaymentTotal)
+	sum.UnmatchedSaleTotal = roundCents(sum.UnmatchedSaleTotal)
+	sum.Difference = roundCents(sum.PaymentTotal - sum.SaleTotal)
+	return report, nil
+}
+
+func (r *ReconciliationReport) add(row ReconciliationRow) {
+	switch row.Status {
+	case ReconciliationMatched:
+		r.Summary.MatchedCount++
+		r.Summary.MatchedTotal += row.PaymentAmount
+	case ReconciliationAmountMismatch:
+		r.Summary.MismatchCount++
+	case ReconciliationUnmatchedPayment:
+		r.Summary.UnmatchedPaymentCount++
+		r.Summary.UnmatchedPaymentTotal += row.PaymentAmount
+	case ReconciliationUnmatchedSale:
+		r.Summary.UnmatchedSaleCount++
+		r.Summary.UnmatchedSaleTotal += row.SaleAmount
+	}
+	r.Rows = append(r.Rows, row)
+}
+
+// Discrepancies drops matched rows, leaving the payments and sales an owner
+// needs to chase up
+func (r *ReconciliationReport) Discrepancies() {
+	rows := r.Rows[:0]
+	for _, row := range r.Rows {
+		if row.Status != ReconciliationMatched {
+			rows = append(rows, row)
+		}
+	}
+	r.Rows = rows
+}
+
+func newReconciliationRow(receipt string, payment *receivedPayment, sales []models.Sale) ReconciliationRow {
+	row := ReconciliationRow{Receipt: receipt, SaleIDs: []uint{}}
+	for _, sale := range sales {
+		row.SaleAmount += sale.TotalAmount
+		row.SaleIDs = append(row.SaleIDs, sale.ID)
+		if row.SoldAt == nil || sale.CreatedAt.Before(*row.SoldAt) {
+			soldAt := sale.CreatedAt
+			row.SoldAt = &soldAt
+		}
+		if row.Phone == "" {
+			row.Phone = sale.MpesaPhone
+		}
+	}
+	row.SaleAmount = roundCents(row.SaleAmount)
+
+	if payment != nil {
+		row.Source = payment.source
+		row.Phone = payment.phone
+		row.PaymentAmount = payment.amount
+		paidAt := payment.paidAt
+		row.PaidAt = &paidAt
+	}
+	row.Difference = roundCents(row.PaymentAmount - row.SaleAmount)
+
+	switch {
+	case payment == nil:
+		row.Status = ReconciliationUnmatchedSale
+	case len(sales) == 0:
+		row.Status = ReconciliationUnmatchedPayment
+	case row.Difference != 0:
+		row.Status = ReconciliationAmountMismatch
+	default:
+		row.Status = ReconciliationMatched
+	}
+	return row
+}
+
+func (r ReconciliationRow) time() time.Time {
+	if r.PaidAt != nil {
+		return *r.PaidAt
+	}
+	if r.SoldAt != nil {
+		return *r.SoldAt
+	}
+	return time.Time{}
+}
+
+func transactionPayment(tx *models.MpesaTransaction) *receivedPayment {
+	return &receivedPayment{
+		receipt: normalizeReceipt(tx.Receipt()),
+		source:  tx.Type,
+		phone:   tx.Phone,
+		amount:  tx.Amount,
+		paidAt:  tx.TransactionTime,
+	}
+}
+
+func normalizeReceipt(receipt string) string {
+	return strings.ToUpper(strings.TrimSpace(receipt))
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// MaxReconciliationDays caps the date range of one reconciliation
+const MaxReconciliationDays = 92
+
+var ErrInvalidReconciliationRange = errors.New("from and to must be dates (YYYY-MM-DD), from not after to, at most 92 days apart")
+
+// ParseReconciliationRange turns from/to dates (YYYY-MM-DD, both inclusive)
+// into a [start, end) range in local time. Either defaults to today.
+func ParseReconciliationRange(from, to string) (time.Time, time.Time, error) {
+	today := time.Now().Format("2006-01-02")
+	if from == "" {
+		from = today
+	}
+	if to == "" {
+		to = today
+	}
+
+	start, err := time.ParseInLocation("2006-01-02", from, time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, ErrInvalidReconciliationRange
+	}
+	last, err := time.ParseInLocation("2006-01-02", to, time.Local)
+	if err != nil || last.Before(start) || last.Sub(start) >= MaxReconciliationDays*24*time.Hour {
+		return time.Time{}, time.Time{}, ErrInvalidReconciliationRange
+	}
+	return start, last.AddDate(0, 0, 1), nil
+}
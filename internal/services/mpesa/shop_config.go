@@ -0,0 +1,227 @@
+package mpesa
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+)
+
+// CredentialProvider is the IntegrationCredential provider name for M-Pesa
+const CredentialProvider = "mpesa"
+
+// AccountReferencePrefix marks the shop in AccountReference/BillRefNumber
+const AccountReferencePrefix = "DUKA"
+
+// maxAccountReference is Daraja's AccountReference length limit
+const maxAccountReference = 12
+
+var (
+	ErrEncryptionRequired      = errors.New("an encryption key is required to store shop M-Pesa credentials")
+	ErrAccountReferenceTooLong = errors.New("account reference is too long for M-Pesa")
+)
+
+// Encryptor encrypts shop credentials at rest
+type Encryptor interface {
+	Encrypt(plaintext string) (string, error)
+	Decrypt(encrypted string) (string, error)
+}
+
+// ShopCredentials are the Daraja credentials for a shop's own paybill or till
+type ShopCredentials struct {
+	ConsumerKey     string `json:"consumer_key"`
+	ConsumerSecret  string `json:"consumer_secret"`
+	Shortcode       string `json:"shortcode"`
+	Passkey         string `json:"passkey"`
+	TransactionType string `json:"transaction_type,omitempty"`
+
+	// Optional, needed for B2C payouts from the shop's shortcode
+	InitiatorName      string `json:"initiator_name,omitempty"`
+	SecurityCredential string `json:"security_credential,omitempty"`
+}
+
+// SetCredentialStore enables per-shop credentials, read from the encrypted
+// integration credentials table
+func (s *Service) SetCredentialStore(repo *repository.IntegrationCredentialRepository, encryptor Encryptor) {
+	s.credentialRepo = repo
+	s.encryptor = encryptor
+}
+
+// IsConfiguredForShop reports whether payments can be taken for the shop,
+// either with its own credentials or the platform shortcode
+func (s *Service) IsConfiguredForShop(shopID uint) bool {
+	return s.configForShop(shopID) != nil
+}
+
+// SaveShopCredentials encrypts and stores a shop's own Daraja credentials
+func (s *Service) SaveShopCredentials(shopID uint, creds *ShopCredentials) error {
+	if s.credentialRepo == nil || s.encryptor == nil {
+		return ErrEncryptionRequired
+	}
+	if creds.ConsumerKey == "" || creds.ConsumerSecret == "" || creds.Shortcode == "" || creds.Passkey == "" {
+		return errors.New("consumer key, consumer secret, shortcode and passkey are required")
+	}
+
+	data, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	secrets, err := s.encryptor.Encrypt(string(data))
+	if err != nil {
+		return err
+	}
+
+	return s.credentialRepo.Save(&models.IntegrationCredential{
+		ShopID:     shopID,
+		Provider:   CredentialProvider,
+		Identifier: creds.Shortcode,
+		Secrets:    secrets,
+		IsActive:   true,
+	})
+}
+
+// DeleteShopCredentials removes a shop's own credentials so its payments go
+// back through the platform shortcode
+func (s *Service) DeleteShopCredentials(shopID uint) error {
+	if s.credentialRepo == nil {
+		return ErrEncryptionRequired
+	}
+	return s.credentialRepo.Delete(shopID, CredentialProvider)
+}
+
+// ShopShortcode returns the shortcode the shop's payments are charged to,
+// and whether it's the shop's own rather than the platform's. It returns
+// "" when M-Pesa isn't configured for the shop.
+func (s *Service) ShopShortcode(shopID uint) (string, bool) {
+	if creds := s.shopCredentials(shopID); creds != nil {
+		return creds.Shortcode, true
+	}
+	if !s.isConfigured {
+		return "", false
+	}
+	return s.config.Shortcode, false
+}
+
+// configForShop returns the shop's own Daraja config, falling back to the
+// platform shortcode. It returns nil when neither is configured.
+func (s *Service) configForShop(shopID uint) *Config {
+	if creds := s.shopCredentials(shopID); creds != nil {
+		cfg := *s.config
+		cfg.ConsumerKey = creds.ConsumerKey
+		cfg.ConsumerSecret = creds.ConsumerSecret
+		cfg.Shortcode = creds.Shortcode
+		cfg.Passkey = creds.Passkey
+		cfg.TransactionType = creds.TransactionType
+		cfg.InitiatorName = creds.InitiatorName
+		cfg.SecurityCredential = creds.SecurityCredential
+		return &cfg
+	}
+
+	if !s.isConfigured {
+		return nil
+	}
+	return s.config
+}
+
+// configForPayment returns the config to charge a payment with. Plan
+// payments are subscription fees, so they always go to the platform
+// shortcode rather than the shop's own.
+func (s *Service) configForPayment(shopID uint, plan models.PlanType) *Config {
+	if plan != "" {
+		if !s.isConfigured {
+			return nil
+		}
+		return s.config
+	}
+	return s.configForShop(shopID)
+}
+
+// initiatorConfigForShop returns the shop's own config when it has saved an
+// initiator for B2C payouts, status queries and reversals. These act on the
+// shop's own shortcode, so they never fall back to the platform's initiator.
+func (s *Service) initiatorConfigForShop(shopID uint) *Config {
+	creds := s.shopCredentials(shopID)
+	if creds == nil || creds.InitiatorName == "" || creds.SecurityCredential == "" {
+		return nil
+	}
+	return s.configForShop(shopID)
+}
+
+func (s *Service) shopCredentials(shopID uint) *ShopCredentials {
+	if shopID == 0 || s.credentialRepo == nil || s.encryptor == nil {
+		return nil
+	}
+
+	cred, err := s.credentialRepo.GetByShop(shopID, CredentialProvider)
+	if err != nil {
+		return nil
+	}
+
+	plaintext, err := s.encryptor.Decrypt(cred.Secrets)
+	if err != nil {
+		log.Printf("⚠️ Failed to decrypt M-Pesa credentials for shop %d: %v", shopID, err)
+		return nil
+	}
+
+	var creds ShopCredentials
+	if err := json.Unmarshal([]byte(plaintext), &creds); err != nil {
+		log.Printf("⚠️ Invalid M-Pesa credentials for shop %d: %v", shopID, err)
+		return nil
+	}
+	return &creds
+}
+
+// ShopAccountReference prefixes ref with the shop identifier (DUKA<id>) so
+// payments to the platform shortcode can be routed back to the shop. Like
+// ProductAccountReference it is not trimmed; an STK push refuses one longer
+// than Daraja's 12 characters.
+func ShopAccountReference(shopID uint, ref string) string {
+	prefix := fmt.Sprintf("%s%d", AccountReferencePrefix, shopID)
+	if ref == prefix || strings.HasPrefix(ref, prefix+"-") {
+		return ref
+	}
+	if ref == "" {
+		return prefix
+	}
+	return prefix + "-" + ref
+}
+
+// ParseShopAccountReference extracts the shop ID from a reference built by
+// ShopAccountReference
+func ParseShopAccountReference(ref string) (uint, bool) {
+	ref = strings.ToUpper(strings.TrimSpace(ref))
+	if !strings.HasPrefix(ref, AccountReferencePrefix) {
+		return 0, false
+	}
+
+	digits := strings.TrimPrefix(ref, AccountReferencePrefix)
+	if i := strings.Index(digits, "-"); i >= 0 {
+		digits = digits[:i]
+	}
+
+	id, err := strconv.ParseUint(digits, 10, 64)
+	if err != nil || id == 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// shopForC2B works out which shop a C2B payment belongs to: by the shop's own
+// shortcode, or by the DUKA<id> account reference on the platform shortcode
+func (s *Service) shopForC2B(notification *C2BNotification) uint {
+	if s.credentialRepo != nil && notification.BusinessShortCode != "" {
+		if cred, err := s.credentialRepo.GetByIdentifier(CredentialProvider, notification.BusinessShortCode); err == nil {
+			return cred.ShopID
+		}
+	}
+
+	if shopID, ok := ParseShopAccountReference(notification.BillReferenceNumber); ok {
+		return shopID
+	}
+	return 0
+}
@@ -0,0 +1,405 @@
+package mpesa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+)
+
+// StatusQueryInterval is how long a status query may stay unanswered before
+// another one is sent for the same transaction
+const StatusQueryInterval = time.Minute
+
+var (
+	ErrStatusNotConfigured   = errors.New("M-Pesa transaction status is not configured")
+	ErrReversalNotConfigured = errors.New("M-Pesa reversal is not configured")
+	ErrTransactionNotFound   = errors.New("transaction not found")
+	ErrInvalidReceipt        = errors.New("invalid M-Pesa receipt code")
+	ErrReversalNotAllowed    = errors.New("only completed payments received by the shop can be reversed")
+	ErrReversalInProgress    = errors.New("transaction is already reversed or a reversal is in progress")
+	ErrReceiptNotForShop     = errors.New("the receipt is for a payment to another till or paybill")
+)
+
+// receiptPattern matches M-Pesa receipt codes, e.g. QJK4ABC123
+var receiptPattern = regexp.MustCompile(`^[A-Z0-9]{10}$`)
+
+// ReversalRequest reverses a payment the shop received by mistake
+type ReversalRequest struct {
+	ShopID        uint
+	TransactionID uint
+	Reason        string
+}
+
+// IsReceiptCode reports whether code looks like an M-Pesa receipt code
+func IsReceiptCode(code string) bool {
+	return receiptPattern.MatchString(strings.ToUpper(strings.TrimSpace(code)))
+}
+
+// resultConfigForShop returns the shop's initiator config when results can
+// be posted back to resultURL
+func (s *Service) resultConfigForShop(shopID uint, resultURL func(*Config) string) *Config {
+	if s.transactionRepo == nil {
+		return nil
+	}
+	cfg := s.initiatorConfigForShop(shopID)
+	if cfg == nil || resultURL(cfg) == "" {
+		return nil
+	}
+	return cfg
+}
+
+func statusResultURL(cfg *Config) string   { return cfg.StatusResultURL }
+func reversalResultURL(cfg *Config) string { return cfg.ReversalResultURL }
+
+// IsReversalConfiguredForShop reports whether the shop can reverse payments
+func (s *Service) IsReversalConfiguredForShop(shopID uint) bool {
+	return s.resultConfigForShop(shopID, reversalResultURL) != nil
+}
+
+// TransactionStatus asks Daraja for the status of one of the shop's
+// transactions. The answer arrives on the status result URL; until then the
+// transaction is returned as it is stored.
+func (s *Service) TransactionStatus(ctx context.Context, shopID, transactionID uint) (*models.MpesaTransaction, error) {
+	cfg := s.resultConfigForShop(shopID, statusResultURL)
+	if cfg == nil {
+		return nil, ErrStatusNotConfigured
+	}
+
+	tx, err := s.transactionRepo.GetByID(transactionID)
+	if err != nil || tx.ShopID != shopID {
+		return nil, ErrTransactionNotFound
+	}
+
+	return tx, s.requestStatus(ctx, cfg, tx)
+}
+
+// TransactionStatusByReceipt queries a receipt code the customer says they
+// paid with. Codes no shop has a record of are saved as unverified
+// transactions, which the result fills in if M-Pesa knows the payment to the
+// shop's shortcode. Another shop's receipts are not found.
+func (s *Service) TransactionStatusByReceipt(ctx context.Context, shopID uint, receipt string) (*models.MpesaTransaction, error) {
+	cfg := s.resultConfigForShop(shopID, statusResultURL)
+	if cfg == nil {
+		return nil, ErrStatusNotConfigured
+	}
+
+	receipt = strings.ToUpper(strings.TrimSpace(receipt))
+	if !receiptPattern.MatchString(receipt) {
+		return nil, ErrInvalidReceipt
+	}
+
+	tx, err := s.transactionRepo.GetByShopAndReceipt(shopID, receipt)
+	if err != nil {
+		if _, err := s.transactionRepo.GetByReceiptNumber(receipt); err == nil {
+			return nil, ErrTransactionNotFound
+		}
+		tx = &models.MpesaTransaction{
+			ShopID:          shopID,
+			Type:            "c2b",
+			TransactionID:   receipt,
+			ReceiptNumber:   receipt,
+			TransactionTime: time.Now(),
+			Status:          "unverified",
+		}
+		if err := s.transactionRepo.Create(tx); err != nil {
+			return nil, fmt.Errorf("failed to record transaction: %w", err)
+		}
+	}
+
+	return tx, s.requestStatus(ctx, cfg, tx)
+}
+
+func (s *Service) requestStatus(ctx context.Context, cfg *Config, tx *models.MpesaTransaction) error {
+	if tx.StatusRequestedAt != nil && time.Since(*tx.StatusRequestedAt) < StatusQueryInterval &&
+		(tx.StatusCheckedAt == nil || tx.StatusCheckedAt.Before(*tx.StatusRequestedAt)) {
+		return nil
+	}
+
+	result, err := s.postInitiatorRequest(ctx, cfg, StatusEndpoint, buildStatusRequest(cfg, tx.Receipt()))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	tx.StatusConversationID = result.ConversationID
+	tx.StatusRequestedAt = &now
+	if err := s.transactionRepo.Update(tx); err != nil {
+		return fmt.Errorf("failed to update transaction: %w", err)
+	}
+	return nil
+}
+
+func buildStatusRequest(cfg *Config, receipt string) map[string]interface{} {
+	return map[string]interface{}{
+		"Initiator":          cfg.InitiatorName,
+		"SecurityCredential": cfg.SecurityCredential,
+		"CommandID":          "TransactionStatusQuery",
+		"TransactionID":      receipt,
+		"PartyA":             cfg.Shortcode,
+		"IdentifierType":     "4",
+		"ResultURL":          withCallbackToken(cfg.StatusResultURL, cfg.CallbackToken),
+		"QueueTimeOutURL":    withCallbackToken(cfg.StatusResultURL, cfg.CallbackToken),
+		"Remarks":            "Transaction status",
+		"Occasion":           "",
+	}
+}
+
+// ProcessStatusResult stores the Daraja transaction status result. An
+// unverified transaction that M-Pesa reports as completed becomes a
+// completed payment, but only when it was paid to the shop's own shortcode;
+// otherwise it is rejected.
+func (s *Service) ProcessStatusResult(body []byte) (*models.MpesaTransaction, error) {
+	if s.transactionRepo == nil {
+		return nil, ErrStatusNotConfigured
+	}
+
+	var result B2CResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse status result: %w", err)
+	}
+	res := result.Result
+
+	tx, err := s.transactionRepo.GetByStatusConversationID(res.ConversationID)
+	if err != nil {
+		return nil, fmt.Errorf("transaction not found for conversation: %s", res.ConversationID)
+	}
+
+	now := time.Now()
+	tx.StatusCheckedAt = &now
+	tx.StatusResultDesc = res.ResultDesc
+	tx.StatusResult = ""
+
+	if res.ResultCode == 0 {
+		params := resultParams(&result)
+		tx.StatusResult = params["TransactionStatus"]
+
+		if tx.Status == "unverified" && tx.StatusResult == "Completed" {
+			if !s.paidToShop(tx.ShopID, params["CreditPartyName"]) {
+				tx.Status = "rejected"
+				tx.StatusResult = ""
+				tx.StatusResultDesc = ErrReceiptNotForShop.Error()
+				if err := s.transactionRepo.Update(tx); err != nil {
+					return nil, fmt.Errorf("failed to update transaction: %w", err)
+				}
+				return tx, ErrReceiptNotForShop
+			}
+			if amount, err := strconv.ParseFloat(params["Amount"], 64); err == nil {
+				tx.Amount = amount
+			}
+			if phone, _, _ := strings.Cut(params["DebitPartyName"], " - "); phone != "" {
+				tx.Phone = strings.TrimSpace(phone)
+			}
+			if t, err := time.ParseInLocation("20060102150405", params["FinalisedTime"], time.Local); err == nil {
+				tx.TransactionTime = t
+			}
+			tx.Status = "completed"
+		}
+	}
+
+	if err := s.transactionRepo.Update(tx); err != nil {
+		return nil, fmt.Errorf("failed to update transaction: %w", err)
+	}
+	return tx, nil
+}
+
+// paidToShop reports whether a status result's credit party, e.g.
+// "600997 - Mama Mboga", is the shop's own shortcode
+func (s *Service) paidToShop(shopID uint, creditParty string) bool {
+	creds := s.shopCredentials(shopID)
+	if creds == nil {
+		return false
+	}
+	shortcode, _, _ := strings.Cut(creditParty, " - ")
+	return strings.TrimSpace(shortcode) == creds.Shortcode
+}
+
+// Reversal asks Daraja to return a payment to the customer. Only one reversal
+// may be pending or completed per transaction.
+func (s *Service) Reversal(ctx context.Context, req *ReversalRequest) (*models.MpesaTransaction, error) {
+	cfg := s.resultConfigForShop(req.ShopID, reversalResultURL)
+	if cfg == nil {
+		return nil, ErrReversalNotConfigured
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, errors.New("a reason is required to reverse a payment")
+	}
+	if len(reason) > 100 {
+		return nil, errors.New("reason must be at most 100 characters")
+	}
+
+	tx, err := s.reserveReversal(req.ShopID, req.TransactionID, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditReversal(tx, "mpesa_reversal_requested", fmt.Sprintf("Reversal of %.0f (%s): %s", tx.Amount, tx.Receipt(), reason))
+
+	result, err := s.postInitiatorRequest(ctx, cfg, ReversalEndpoint, buildReversalRequest(cfg, tx, reason))
+	if err != nil {
+		tx.ReversalStatus = models.ReversalFailed
+		tx.ReversalResultDesc = err.Error()
+		_ = s.transactionRepo.Update(tx)
+		s.auditReversal(tx, "mpesa_reversal_failed", err.Error())
+		return tx, err
+	}
+
+	tx.ReversalConversationID = result.ConversationID
+	if err := s.transactionRepo.Update(tx); err != nil {
+		return tx, fmt.Errorf("failed to update transaction: %w", err)
+	}
+	return tx, nil
+}
+
+// reserveReversal marks the transaction as pending reversal, so concurrent
+// requests can't both reach Daraja
+func (s *Service) reserveReversal(shopID, transactionID uint, reason string) (*models.MpesaTransaction, error) {
+	s.reversalMutex.Lock()
+	defer s.reversalMutex.Unlock()
+
+	tx, err := s.transactionRepo.GetByID(transactionID)
+	if err != nil || tx.ShopID != shopID {
+		return nil, ErrTransactionNotFound
+	}
+	if (tx.Type != "stk_push" && tx.Type != "c2b") || tx.Status != "completed" || tx.Receipt() == "" {
+		return nil, ErrReversalNotAllowed
+	}
+	if tx.ReversalStatus == models.ReversalPending || tx.ReversalStatus == models.ReversalCompleted {
+		return nil, ErrReversalInProgress
+	}
+
+	tx.ReversalStatus = models.ReversalPending
+	tx.ReversalReason = reason
+	tx.ReversalResultDesc = ""
+	if err := s.transactionRepo.Update(tx); err != nil {
+		return nil, fmt.Errorf("failed to update transaction: %w", err)
+	}
+	return tx, nil
+}
+
+func buildReversalRequest(cfg *Config, tx *models.MpesaTransaction, reason string) map[string]interface{} {
+	return map[string]interface{}{
+		"Initiator":              cfg.InitiatorName,
+		"SecurityCredential":     cfg.SecurityCredential,
+		"CommandID":              "TransactionReversal",
+		"TransactionID":          tx.Receipt(),
+		"Amount":                 int(math.Round(tx.Amount)),
+		"ReceiverParty":          cfg.Shortcode,
+		"RecieverIdentifierType": "11", // sic, as Daraja spells it
+		"ResultURL":              withCallbackToken(cfg.ReversalResultURL, cfg.CallbackToken),
+		"QueueTimeOutURL":        withCallbackToken(cfg.ReversalResultURL, cfg.CallbackToken),
+		"Remarks":                reason,
+		"Occasion":               "",
+	}
+}
+
+// ProcessReversalResult settles a pending reversal from the Daraja result
+// callback
+func (s *Service) ProcessReversalResult(body []byte) (*models.MpesaTransaction, error) {
+	if s.transactionRepo == nil {
+		return nil, ErrReversalNotConfigured
+	}
+
+	var result B2CResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse reversal result: %w", err)
+	}
+	res := result.Result
+
+	tx, err := s.transactionRepo.GetByReversalConversationID(res.ConversationID)
+	if err != nil {
+		return nil, fmt.Errorf("transaction not found for conversation: %s", res.ConversationID)
+	}
+	if tx.ReversalStatus != models.ReversalPending {
+		return tx, ErrDuplicateCallback
+	}
+
+	tx.ReversalResultDesc = res.ResultDesc
+	if res.ResultCode != 0 {
+		tx.ReversalStatus = models.ReversalFailed
+		if err := s.transactionRepo.Update(tx); err != nil {
+			return nil, fmt.Errorf("failed to update transaction: %w", err)
+		}
+		s.auditReversal(tx, "mpesa_reversal_failed", res.ResultDesc)
+		return tx, nil
+	}
+
+	now := time.Now()
+	tx.ReversalStatus = models.ReversalCompleted
+	tx.ReversalReceipt = res.TransactionID
+	tx.ReversedAt = &now
+	tx.Status = "reversed"
+	if err := s.transactionRepo.Update(tx); err != nil {
+		return nil, fmt.Errorf("failed to update transaction: %w", err)
+	}
+
+	s.auditReversal(tx, "mpesa_reversal_completed", fmt.Sprintf("Reversed %s, receipt %s", tx.Receipt(), tx.ReversalReceipt))
+	return tx, nil
+}
+
+// postInitiatorRequest sends an initiator-authenticated request. Status and
+// reversal requests are acknowledged in the same shape as B2C.
+func (s *Service) postInitiatorRequest(ctx context.Context, cfg *Config, endpoint string, payload map[string]interface{}) (*B2CResponse, error) {
+	token, err := s.getToken(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	resp, err := s.doWithBackoff(ctx, jsonRequest(fmt.Sprintf("%s/%s", s.getBaseURL(), endpoint), token, body))
+	if errors.Is(err, ErrRateLimited) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNetworkError, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+
+	var result B2CResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if result.ResponseCode != "0" {
+		return nil, fmt.Errorf("M-Pesa request failed: %s", result.ResponseDescription)
+	}
+	return &result, nil
+}
+
+func resultParams(result *B2CResult) map[string]string {
+	params := make(map[string]string)
+	for _, param := range result.Result.ResultParameters.ResultParameter {
+		params[param.Key] = fmt.Sprint(param.Value)
+	}
+	return params
+}
+
+func (s *Service) auditReversal(tx *models.MpesaTransaction, action, details string) {
+	if s.auditRepo == nil {
+		return
+	}
+	_ = s.auditRepo.Create(&models.AuditLog{
+		ShopID:     tx.ShopID,
+		UserType:   "shop",
+		UserID:     tx.ShopID,
+		Action:     action,
+		EntityType: "mpesa_transaction",
+		EntityID:   tx.ID,
+		Details:    details,
+	})
+}
@@ -60,8 +60,13 @@ func (s *Service) CheckLowStock(shopID uint) ([]Notification, error) {
 
 	var items []string
 	for _, p := range products {
+		if p.CurrentStock < 0 {
+			items = append(items, fmt.Sprintf("• %s: %d %s (sold past zero, add stock)",
+				p.Name, p.CurrentStock, p.Unit))
+			continue
+		}
 		items = append(items, fmt.Sprintf("• %s: %d %s (min: %d)", 
-			p.Name, p.CurrentStock, p.Unit, p.LowStockThreshold))
+			p.Name, p.CurrentStock, p.Unit, p.LowStockLevel()))
 	}
 
 	message := fmt.Sprintf(`⚠️ LOW STOCK ALERT
@@ -2,53 +2,43 @@ package otp
 
 import (
 	"context"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/subtle"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"log"
 	"math/big"
-	"sync"
+	"strings"
 	"time"
 
 	"github.com/C9b3rD3vi1/DukaPOS/internal/config"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
 	"gorm.io/gorm"
 )
 
+var (
+	ErrOTPThrottled       = errors.New("too many codes requested, please wait before trying again")
+	ErrOTPNotFound        = errors.New("no code found, please request a new one")
+	ErrOTPExpired         = errors.New("code expired, please request a new one")
+	ErrOTPInvalid         = errors.New("invalid code")
+	ErrOTPTooManyAttempts = errors.New("too many attempts, please request a new code")
+	ErrOTPNoChannel       = errors.New("no SMS, WhatsApp or email sender configured")
+)
+
 type OTPService struct {
 	db             *gorm.DB
 	config         *config.Config
+	otpRepo        *repository.OtpCodeRepository
 	whatsappSender func(phone, message string) error
 	smsSender      func(phone, message string) error
-	rateLimiter    *RateLimiter
-	otpStore       *OTPStore
-}
-
-type OTPStore struct {
-	mu       sync.RWMutex
-	otps     map[string]*OTPEntry
-	verified map[string]bool
-}
-
-type OTPEntry struct {
-	Phone       string
-	Code        string
-	Purpose     string
-	Attempts    int
-	MaxAttempts int
-	CreatedAt   time.Time
-	ExpiresAt   time.Time
-	Verified    bool
-}
+	emailSender    func(to, subject, body string) error
 
-type RateLimiter struct {
-	mu       sync.RWMutex
-	attempts map[string]*RateLimitEntry
-}
-
-type RateLimitEntry struct {
-	Count        int
-	FirstAttempt time.Time
-	BlockedUntil time.Time
+	// resetMailer emails password reset codes with the branded template
+	resetMailer func(to, code string) error
 }
 
 type OTPRequest struct {
@@ -80,141 +70,171 @@ const (
 	MaxAttempts      = 3
 	RateLimitMinutes = 15
 	MaxRateLimit     = 5
+
+	// ResendCooldown is the least time between two codes to one destination
+	ResendCooldown = time.Minute
 )
 
 func NewOTPService(db *gorm.DB, cfg *config.Config) *OTPService {
 	return &OTPService{
-		db:     db,
-		config: cfg,
-		rateLimiter: &RateLimiter{
-			attempts: make(map[string]*RateLimitEntry),
-		},
-		otpStore: &OTPStore{
-			otps:     make(map[string]*OTPEntry),
-			verified: make(map[string]bool),
-		},
+		db:      db,
+		config:  cfg,
+		otpRepo: repository.NewOtpCodeRepository(db),
 	}
 }
 
+// SetWhatsAppSender delivers phone codes when SMS is not configured
 func (s *OTPService) SetWhatsAppSender(sender func(phone, message string) error) {
 	s.whatsappSender = sender
 }
 
+// SetSMSSender delivers codes to phone numbers
 func (s *OTPService) SetSMSSender(sender func(phone, message string) error) {
 	s.smsSender = sender
 }
 
-func (s *OTPService) GenerateOTP(ctx context.Context, req *OTPRequest) (*OTPResponse, error) {
-	phone := normalizePhone(req.Phone)
-	if phone == "" {
-		return &OTPResponse{Success: false, Message: "Invalid phone number"}, nil
-	}
+// SetEmailSender delivers codes to email addresses
+func (s *OTPService) SetEmailSender(sender func(to, subject, body string) error) {
+	s.emailSender = sender
+}
 
-	purpose := req.Purpose
+// SetPasswordResetMailer emails password reset codes with a template
+// instead of the plain message
+func (s *OTPService) SetPasswordResetMailer(mailer func(to, code string) error) {
+	s.resetMailer = mailer
+}
+
+// Send generates a code for a phone number or email address and delivers
+// it by SMS (or WhatsApp) or email. Sending a new code spends the previous
+// one. Requests are throttled per destination and purpose.
+func (s *OTPService) Send(destination, purpose string) (*models.OtpCode, error) {
 	if purpose == "" {
 		purpose = PurposeLogin
 	}
 
-	storeKey := fmt.Sprintf("%s:%s", purpose, phone)
-
-	if !s.rateLimiter.Allow(storeKey) {
-		return &OTPResponse{
-			Success: false,
-			Message: "Too many OTP requests. Please try again later.",
-		}, nil
-	}
-
-	otpCode := generateOTPCode(6)
+	code := generateOTPCode(6)
+	message := s.formatOTPMessage(code, purpose)
 
-	entry := &OTPEntry{
-		Phone:       phone,
-		Code:        otpCode,
+	var deliver func() error
+	record := &models.OtpCode{
+		Destination: destination,
 		Purpose:     purpose,
-		Attempts:    0,
-		MaxAttempts: MaxAttempts,
-		CreatedAt:   time.Now(),
+		CodeHash:    s.hash(destination, purpose, code),
 		ExpiresAt:   time.Now().Add(OTPExpiryMinutes * time.Minute),
-		Verified:    false,
+	}
+	switch {
+	case strings.Contains(destination, "@") && purpose == PurposePasswordReset && s.resetMailer != nil:
+		record.Channel = "email"
+		deliver = func() error { return s.resetMailer(destination, code) }
+	case strings.Contains(destination, "@") && s.emailSender != nil:
+		record.Channel = "email"
+		deliver = func() error { return s.emailSender(destination, "Your DukaPOS code", message) }
+	case strings.Contains(destination, "@"):
+		return nil, ErrOTPNoChannel
+	case s.smsSender != nil:
+		record.Channel = "sms"
+		deliver = func() error { return s.smsSender(destination, message) }
+	case s.whatsappSender != nil:
+		record.Channel = "whatsapp"
+		deliver = func() error { return s.whatsappSender(destination, message) }
+	default:
+		return nil, ErrOTPNoChannel
 	}
 
-	s.otpStore.mu.Lock()
-	s.otpStore.otps[storeKey] = entry
-	s.otpStore.mu.Unlock()
-
-	message := s.formatOTPMessage(otpCode, purpose)
-
-	if s.whatsappSender != nil {
-		go func() {
-			_ = s.whatsappSender(phone, message)
-		}()
-	} else if s.smsSender != nil {
-		go func() {
-			_ = s.smsSender(phone, message)
-		}()
+	created, err := s.otpRepo.CreateThrottled(record, ResendCooldown, time.Now().Add(-RateLimitMinutes*time.Minute), MaxRateLimit)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return nil, ErrOTPThrottled
+	}
+	if err := deliver(); err != nil {
+		log.Printf("❌ Failed to send %s code by %s to %s: %v", purpose, record.Channel, maskDestination(destination), err)
+		_, _ = s.otpRepo.MarkUsed(record)
+		return nil, fmt.Errorf("failed to send code: %w", err)
 	}
 
-	return &OTPResponse{
-		Success:   true,
-		Message:   fmt.Sprintf("OTP sent to %s", maskPhone(phone)),
-		ExpiresAt: entry.ExpiresAt,
-	}, nil
+	log.Printf("🔐 Sent %s code by %s to %s", purpose, record.Channel, maskDestination(destination))
+	return record, nil
 }
 
-func (s *OTPService) VerifyOTP(ctx context.Context, req *OTPVerifyRequest) (*OTPResponse, error) {
-	phone := normalizePhone(req.Phone)
-	if phone == "" {
-		return &OTPResponse{Success: false, Message: "Invalid phone number"}, nil
-	}
-
-	purpose := req.Purpose
+// Check verifies a code and spends it. Each wrong guess counts against the
+// code, which stops working after MaxAttempts.
+func (s *OTPService) Check(destination, purpose, code string) error {
 	if purpose == "" {
 		purpose = PurposeLogin
 	}
 
-	storeKey := fmt.Sprintf("%s:%s", purpose, phone)
-
-	s.otpStore.mu.RLock()
-	entry, exists := s.otpStore.otps[storeKey]
-	s.otpStore.mu.RUnlock()
-
-	if !exists {
-		return &OTPResponse{Success: false, Message: "No OTP found. Please request a new code."}, nil
+	record, err := s.otpRepo.GetActive(destination, purpose)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrOTPNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if time.Now().After(record.ExpiresAt) {
+		return ErrOTPExpired
 	}
 
-	if time.Now().After(entry.ExpiresAt) {
-		s.otpStore.mu.Lock()
-		delete(s.otpStore.otps, storeKey)
-		s.otpStore.mu.Unlock()
-		return &OTPResponse{Success: false, Message: "OTP expired. Please request a new code."}, nil
+	want := s.hash(destination, purpose, strings.TrimSpace(code))
+	if subtle.ConstantTimeCompare([]byte(record.CodeHash), []byte(want)) != 1 {
+		attempts, counted, err := s.otpRepo.RecordAttempt(record, MaxAttempts)
+		if err != nil {
+			return err
+		}
+		if attempts >= MaxAttempts {
+			return ErrOTPTooManyAttempts
+		}
+		if !counted {
+			// Spent by a parallel check that got it right
+			return ErrOTPNotFound
+		}
+		return fmt.Errorf("%w, %d attempts remaining", ErrOTPInvalid, MaxAttempts-attempts)
 	}
 
-	entry.Attempts++
+	spent, err := s.otpRepo.MarkUsed(record)
+	if err != nil {
+		return err
+	}
+	if !spent {
+		return ErrOTPNotFound
+	}
+	return nil
+}
 
-	if entry.Attempts >= entry.MaxAttempts {
-		s.otpStore.mu.Lock()
-		delete(s.otpStore.otps, storeKey)
-		s.otpStore.mu.Unlock()
-		return &OTPResponse{Success: false, Message: "Too many attempts. Please request a new OTP."}, nil
+func (s *OTPService) GenerateOTP(ctx context.Context, req *OTPRequest) (*OTPResponse, error) {
+	phone := normalizePhone(req.Phone)
+	if phone == "" {
+		return &OTPResponse{Success: false, Message: "Invalid phone number"}, nil
 	}
 
-	if subtle.ConstantTimeCompare([]byte(entry.Code), []byte(req.Code)) != 1 {
-		remaining := entry.MaxAttempts - entry.Attempts
-		return &OTPResponse{
-			Success:  false,
-			Message:  fmt.Sprintf("Invalid OTP. %d attempts remaining.", remaining),
-			Attempts: remaining,
-		}, nil
+	record, err := s.Send(phone, req.Purpose)
+	switch {
+	case errors.Is(err, ErrOTPThrottled):
+		return &OTPResponse{Success: false, Message: "Too many OTP requests. Please try again later."}, nil
+	case err != nil:
+		return nil, err
 	}
 
-	entry.Verified = true
+	return &OTPResponse{
+		Success:   true,
+		Message:   fmt.Sprintf("OTP sent to %s", maskPhone(phone)),
+		ExpiresAt: record.ExpiresAt,
+	}, nil
+}
 
-	s.otpStore.mu.Lock()
-	s.otpStore.verified[phone] = true
-	s.otpStore.mu.Unlock()
+func (s *OTPService) VerifyOTP(ctx context.Context, req *OTPVerifyRequest) (*OTPResponse, error) {
+	phone := normalizePhone(req.Phone)
+	if phone == "" {
+		return &OTPResponse{Success: false, Message: "Invalid phone number"}, nil
+	}
 
-	s.otpStore.mu.Lock()
-	delete(s.otpStore.otps, storeKey)
-	s.otpStore.mu.Unlock()
+	if err := s.Check(phone, req.Purpose, req.Code); err != nil {
+		if isOTPError(err) {
+			return &OTPResponse{Success: false, Message: err.Error()}, nil
+		}
+		return nil, err
+	}
 
 	return &OTPResponse{
 		Success: true,
@@ -222,49 +242,17 @@ func (s *OTPService) VerifyOTP(ctx context.Context, req *OTPVerifyRequest) (*OTP
 	}, nil
 }
 
-func (s *OTPService) IsVerified(phone string) bool {
-	phone = normalizePhone(phone)
-	s.otpStore.mu.RLock()
-	defer s.otpStore.mu.RUnlock()
-	return s.otpStore.verified[phone]
-}
-
-func (s *OTPService) ClearVerification(phone string) {
-	phone = normalizePhone(phone)
-	s.otpStore.mu.Lock()
-	defer s.otpStore.mu.Unlock()
-	delete(s.otpStore.verified, phone)
-}
-
 func (s *OTPService) VerifyShopPhone(shopID uint, phone, code string) error {
-	storeKey := fmt.Sprintf("%s:shop_%d", PurposePhoneVerify, shopID)
-
-	s.otpStore.mu.RLock()
-	entry, exists := s.otpStore.otps[storeKey]
-	s.otpStore.mu.RUnlock()
-
-	if !exists {
-		return fmt.Errorf("no OTP found for this shop")
-	}
-
-	if time.Now().After(entry.ExpiresAt) {
-		return fmt.Errorf("OTP has expired")
-	}
-
-	if entry.Code != code {
-		return fmt.Errorf("invalid OTP code")
+	shop := &models.Shop{}
+	if err := s.db.First(shop, shopID).Error; err != nil {
+		return fmt.Errorf("shop not found")
 	}
 
-	shop := &models.Shop{}
-	if err := s.db.Model(shop).Where("id = ?", shopID).Update("phone_verified", true).Error; err != nil {
+	if err := s.Check(shop.Phone, PurposePhoneVerify, code); err != nil {
 		return err
 	}
 
-	s.otpStore.mu.Lock()
-	delete(s.otpStore.otps, storeKey)
-	s.otpStore.mu.Unlock()
-
-	return nil
+	return s.db.Model(shop).Update("phone_verified", true).Error
 }
 
 func (s *OTPService) RequestShopVerification(ctx context.Context, shopID uint) (*OTPResponse, error) {
@@ -277,71 +265,41 @@ func (s *OTPService) RequestShopVerification(ctx context.Context, shopID uint) (
 		return &OTPResponse{Success: false, Message: "Shop has no phone number"}, nil
 	}
 
-	otpCode := generateOTPCode(6)
-	storeKey := fmt.Sprintf("%s:shop_%d", PurposePhoneVerify, shopID)
-
-	entry := &OTPEntry{
-		Phone:       shop.Phone,
-		Code:        otpCode,
-		Purpose:     PurposePhoneVerify,
-		Attempts:    0,
-		MaxAttempts: MaxAttempts,
-		CreatedAt:   time.Now(),
-		ExpiresAt:   time.Now().Add(OTPExpiryMinutes * time.Minute),
+	record, err := s.Send(shop.Phone, PurposePhoneVerify)
+	if errors.Is(err, ErrOTPThrottled) {
+		return &OTPResponse{Success: false, Message: "Too many OTP requests. Please try again later."}, nil
 	}
-
-	s.otpStore.mu.Lock()
-	s.otpStore.otps[storeKey] = entry
-	s.otpStore.mu.Unlock()
-
-	message := fmt.Sprintf("Your DukaPOS verification code is: %s\n\nThis code expires in 5 minutes.", otpCode)
-
-	if s.whatsappSender != nil {
-		go func() { _ = s.whatsappSender(shop.Phone, message) }()
-	} else if s.smsSender != nil {
-		go func() { _ = s.smsSender(shop.Phone, message) }()
+	if err != nil {
+		return nil, err
 	}
 
 	return &OTPResponse{
 		Success:   true,
 		Message:   fmt.Sprintf("Verification code sent to %s", maskPhone(shop.Phone)),
-		ExpiresAt: entry.ExpiresAt,
+		ExpiresAt: record.ExpiresAt,
 	}, nil
 }
 
-func (s *RateLimiter) Allow(key string) bool {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	now := time.Now()
-	entry, exists := s.attempts[key]
-
-	if !exists {
-		s.attempts[key] = &RateLimitEntry{
-			Count:        1,
-			FirstAttempt: now,
-		}
-		return true
+// hash keys the code to its destination and purpose, so a stored hash is no
+// use for any other
+func (s *OTPService) hash(destination, purpose, code string) string {
+	secret := ""
+	if s.config != nil {
+		secret = s.config.JWTSecret
 	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(destination + "|" + purpose + "|" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
 
-	if now.After(entry.BlockedUntil) {
-		if now.Sub(entry.FirstAttempt) > RateLimitMinutes*time.Minute {
-			s.attempts[key] = &RateLimitEntry{
-				Count:        1,
-				FirstAttempt: now,
-			}
+// isOTPError reports whether err is a code problem to show the user, rather
+// than a storage or delivery failure
+func isOTPError(err error) bool {
+	for _, target := range []error{ErrOTPThrottled, ErrOTPNotFound, ErrOTPExpired, ErrOTPInvalid, ErrOTPTooManyAttempts} {
+		if errors.Is(err, target) {
 			return true
 		}
-
-		if entry.Count >= MaxRateLimit {
-			entry.BlockedUntil = now.Add(RateLimitMinutes * time.Minute)
-			return false
-		}
-
-		entry.Count++
-		return true
 	}
-
 	return false
 }
 
@@ -390,6 +348,13 @@ func maskPhone(phone string) string {
 	return "****" + phone[len(phone)-4:]
 }
 
+func maskDestination(destination string) string {
+	if at := strings.Index(destination, "@"); at > 0 {
+		return destination[:1] + "***" + destination[at:]
+	}
+	return maskPhone(destination)
+}
+
 func (s *OTPService) formatOTPMessage(code, purpose string) string {
 	switch purpose {
 	case PurposeLogin:
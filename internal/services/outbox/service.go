@@ -0,0 +1,255 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+)
+
+const (
+	// DefaultWorkers is how many messages are sent at once
+	DefaultWorkers = 4
+	// DefaultMaxAttempts is how many sends are tried before a message is dead
+	DefaultMaxAttempts = 5
+	// BaseBackoff is the wait after the first failure; it doubles each time
+	BaseBackoff = 30 * time.Second
+	// MaxBackoff caps the wait between attempts
+	MaxBackoff = time.Hour
+	// SendLease is how long a worker has to send a message it claimed
+	// before another may claim it, so a message a stopped worker was sending
+	// is sent again without taking one another replica is still sending
+	SendLease = 10 * time.Minute
+
+	pollInterval = time.Second
+	batchSize    = 50
+)
+
+// Sender delivers one message on a channel
+type Sender func(m *models.OutboxMessage) error
+
+// Service queues outbound WhatsApp, SMS and email in the database and sends
+// them from a pool of workers, retrying failures with exponential backoff
+type Service struct {
+	repo        *repository.OutboxRepository
+	workers     int
+	maxAttempts int
+
+	mu      sync.RWMutex
+	senders map[string]Sender
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New creates a new outbox service with the given number of workers
+func New(repo *repository.OutboxRepository, workers int) *Service {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	return &Service{
+		repo:        repo,
+		workers:     workers,
+		maxAttempts: DefaultMaxAttempts,
+		senders:     make(map[string]Sender),
+	}
+}
+
+// SetSender sets how messages on a channel are delivered
+func (s *Service) SetSender(channel string, sender Sender) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.senders[channel] = sender
+}
+
+// Enqueue queues a message to be sent as soon as a worker is free
+func (s *Service) Enqueue(channel, to, subject, body string) error {
+	return s.enqueue(0, channel, to, subject, body)
+}
+
+func (s *Service) enqueue(shopID uint, channel, to, subject, body string) error {
+	switch channel {
+	case models.OutboxChannelWhatsApp, models.OutboxChannelSMS, models.OutboxChannelEmail, models.OutboxChannelMpesa:
+	default:
+		return fmt.Errorf("unknown outbox channel %q", channel)
+	}
+	if to == "" {
+		return fmt.Errorf("%s message has no recipient", channel)
+	}
+
+	return s.repo.Create(&models.OutboxMessage{
+		ShopID:        shopID,
+		Channel:       channel,
+		Recipient:     to,
+		Subject:       subject,
+		Body:          body,
+		Status:        models.OutboxStatusPending,
+		MaxAttempts:   s.maxAttempts,
+		NextAttemptAt: time.Now(),
+	})
+}
+
+// SendWhatsApp queues a WhatsApp message
+func (s *Service) SendWhatsApp(phone, message string) error {
+	return s.Enqueue(models.OutboxChannelWhatsApp, phone, "", message)
+}
+
+// SendWhatsAppAs queues a WhatsApp message of msgType, which is kept in
+// the subject and handed to the sender
+func (s *Service) SendWhatsAppAs(msgType, phone, message string) error {
+	return s.Enqueue(models.OutboxChannelWhatsApp, phone, msgType, message)
+}
+
+// SendSMS queues an SMS
+func (s *Service) SendSMS(phone, message string) error {
+	return s.Enqueue(models.OutboxChannelSMS, phone, "", message)
+}
+
+// SendShopSMS queues an SMS sent for a shop for purpose, which is kept in
+// the subject
+func (s *Service) SendShopSMS(shopID uint, purpose models.SmsPurpose, phone, message string) error {
+	return s.enqueue(shopID, models.OutboxChannelSMS, phone, string(purpose), message)
+}
+
+// SendEmail queues a plain text email
+func (s *Service) SendEmail(to, subject, body string) error {
+	return s.Enqueue(models.OutboxChannelEmail, to, subject, body)
+}
+
+// QueueSTKPush queues an STK push Daraja rate limited, so the workers send
+// it again with their backoff rather than the request that made it
+// waiting; request is the payment request as JSON
+func (s *Service) QueueSTKPush(shopID uint, phone string, request []byte) error {
+	return s.enqueue(shopID, models.OutboxChannelMpesa, phone, "", string(request))
+}
+
+// ProcessDue sends the messages that are due across the worker pool and
+// returns how many were sent
+func (s *Service) ProcessDue() (int, error) {
+	now := time.Now()
+	messages, err := s.repo.ClaimDue(now, now.Add(-SendLease), batchSize)
+	if err != nil && len(messages) == 0 {
+		return 0, err
+	}
+
+	queue := make(chan *models.OutboxMessage)
+	var sent int
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for m := range queue {
+				if s.deliver(m) {
+					mu.Lock()
+					sent++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range messages {
+		queue <- &messages[i]
+	}
+	close(queue)
+	wg.Wait()
+
+	return sent, err
+}
+
+// deliver makes one attempt at a message and records the outcome
+func (s *Service) deliver(m *models.OutboxMessage) bool {
+	s.mu.RLock()
+	sender := s.senders[m.Channel]
+	s.mu.RUnlock()
+
+	// Retrying can't help a channel that isn't configured
+	err := fmt.Errorf("no %s sender configured", m.Channel)
+	if sender != nil {
+		err = sender(m)
+	}
+	m.Attempts++
+
+	now := time.Now()
+	switch {
+	case err == nil:
+		m.Status = models.OutboxStatusSent
+		m.SentAt = &now
+		m.LastError = ""
+	case sender == nil || m.Attempts >= m.MaxAttempts:
+		m.Status = models.OutboxStatusDead
+		m.LastError = truncate(err.Error(), 500)
+		log.Printf("❌ Outbox %s to %s dead after %d attempts: %v", m.Channel, m.Recipient, m.Attempts, err)
+	default:
+		m.Status = models.OutboxStatusPending
+		m.NextAttemptAt = now.Add(Backoff(m.Attempts))
+		m.LastError = truncate(err.Error(), 500)
+		log.Printf("⚠️ Outbox %s to %s failed (attempt %d/%d), retrying at %s: %v",
+			m.Channel, m.Recipient, m.Attempts, m.MaxAttempts, m.NextAttemptAt.Format("15:04:05"), err)
+	}
+
+	if err := s.repo.Save(m); err != nil {
+		log.Printf("❌ Failed to update outbox message %d: %v", m.ID, err)
+	}
+	return m.Status == models.OutboxStatusSent
+}
+
+// Backoff returns the wait after a message has failed attempts times
+func Backoff(attempts int) time.Duration {
+	wait := BaseBackoff
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= MaxBackoff {
+			return MaxBackoff
+		}
+	}
+	return wait
+}
+
+// Start polls for due messages until Shutdown
+func (s *Service) Start() {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				if _, err := s.ProcessDue(); err != nil {
+					log.Printf("❌ Outbox processing failed: %v", err)
+				}
+			}
+		}
+	}()
+	log.Printf("📬 Outbox started with %d workers", s.workers)
+}
+
+// Shutdown stops polling and waits for the batch in flight until ctx is done
+func (s *Service) Shutdown(ctx context.Context) error {
+	if s.stop == nil {
+		return nil
+	}
+	close(s.stop)
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
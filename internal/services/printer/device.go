@@ -0,0 +1,76 @@
+package printer
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// devicePatterns are the device names shops may set for USB and serial
+// printers, so a shop can't point the printer at any other file
+var devicePatterns = map[string]*regexp.Regexp{
+	ConnectionUSB:    regexp.MustCompile(`^/dev/(usb/lp|lp)[0-9]+$`),
+	ConnectionSerial: regexp.MustCompile(`^(/dev/tty(USB|ACM|S)[0-9]+|/dev/rfcomm[0-9]+|COM[0-9]+)$`),
+}
+
+// ValidDevice reports whether device names a printer device for connection
+func ValidDevice(connection, device string) bool {
+	pattern, ok := devicePatterns[connection]
+	return ok && pattern.MatchString(device)
+}
+
+// ExampleDevice returns a typical device name for connection
+func ExampleDevice(connection string) string {
+	if connection == ConnectionSerial {
+		return "/dev/ttyUSB0"
+	}
+	return "/dev/usb/lp0"
+}
+
+// usesDevice reports whether the printer is a USB or serial device rather
+// than on the network
+func (s *Service) usesDevice() bool {
+	return s.config.Connection == ConnectionUSB || s.config.Connection == ConnectionSerial
+}
+
+// sendDevice writes raw ESC/POS commands to a USB or serial printer. The
+// device must be writable by the server, and a serial port's baud rate set
+// beforehand, e.g. with stty.
+func (s *Service) sendDevice(data []byte) error {
+	device, err := s.openDevice()
+	if err != nil {
+		return err
+	}
+	if _, err := device.Write(data); err != nil {
+		device.Close()
+		return fmt.Errorf("%w: failed to send to printer at %s: %v", ErrPrinterUnreachable, s.config.Device, err)
+	}
+	if err := device.Close(); err != nil {
+		return fmt.Errorf("%w: failed to send to printer at %s: %v", ErrPrinterUnreachable, s.config.Device, err)
+	}
+	return nil
+}
+
+// pingDevice checks the device can be opened, without printing
+func (s *Service) pingDevice() error {
+	device, err := s.openDevice()
+	if err != nil {
+		return err
+	}
+	return device.Close()
+}
+
+func (s *Service) openDevice() (*os.File, error) {
+	if s.config.Device == "" {
+		return nil, ErrNoPrinter
+	}
+	if s.config.PublicOnly {
+		return nil, fmt.Errorf("%w: %s is a device on the server", ErrPrinterNotAllowed, s.config.Device)
+	}
+	device, err := os.OpenFile(s.config.Device, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to open printer at %s: %v; check it is plugged in and the server may write to it",
+			ErrPrinterUnreachable, s.config.Device, err)
+	}
+	return device, nil
+}
@@ -0,0 +1,89 @@
+package printer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ESC/POS commands understood by Epson and compatible thermal printers
+var (
+	escInit        = []byte{0x1B, 0x40}                   // reset to defaults
+	escAlignLeft   = []byte{0x1B, 0x61, 0x00}             // left align
+	escAlignCenter = []byte{0x1B, 0x61, 0x01}             // center align
+	escAlignRight  = []byte{0x1B, 0x61, 0x02}             // right align
+	escBoldOn      = []byte{0x1B, 0x45, 0x01}             // bold on
+	escBoldOff     = []byte{0x1B, 0x45, 0x00}             // bold off
+	escDoubleOn    = []byte{0x1B, 0x21, 0x10}             // double height
+	escDoubleOff   = []byte{0x1B, 0x21, 0x00}             // normal size
+	escCut         = []byte{0x1D, 0x56, 0x42, 0x00}       // feed to the cutter and cut
+	escDrawerKick  = []byte{0x1B, 0x70, 0x00, 0x19, 0xFA} // pulse drawer pin 2 for 50ms
+)
+
+// escQRCode returns the GS ( k commands that print data as a QR code,
+// model 2 with medium error correction, using the printer's own encoder
+func escQRCode(data string) []byte {
+	store := len(data) + 3
+	var b []byte
+	b = append(b, 0x1D, 0x28, 0x6B, 0x04, 0x00, 0x31, 0x41, 0x32, 0x00) // model 2
+	b = append(b, 0x1D, 0x28, 0x6B, 0x03, 0x00, 0x31, 0x43, 0x05)       // 5 dot modules
+	b = append(b, 0x1D, 0x28, 0x6B, 0x03, 0x00, 0x31, 0x45, 0x31)       // error correction M
+	b = append(b, 0x1D, 0x28, 0x6B, byte(store%256), byte(store/256), 0x31, 0x50, 0x30)
+	b = append(b, data...)
+	b = append(b, 0x1D, 0x28, 0x6B, 0x03, 0x00, 0x31, 0x51, 0x30) // print it
+	return b
+}
+
+// TestPage returns ESC/POS commands for a page that shows the printer is
+// connected and the paper width is set right: a ruler as wide as the
+// configured width, marks printed left, centered and right aligned, and
+// bold and double height text
+func (s *Service) TestPage() []byte {
+	width := s.config.Width
+	var sb strings.Builder
+
+	sb.Write(escInit)
+	sb.Write(escAlignCenter)
+	sb.Write(escBoldOn)
+	sb.Write(escDoubleOn)
+	sb.WriteString("DukaPOS TEST PAGE\n")
+	sb.Write(escDoubleOff)
+	sb.Write(escBoldOff)
+	sb.WriteString(time.Now().Format("02/01/2006 15:04") + "\n")
+
+	sb.Write(escAlignLeft)
+	sb.WriteString(strings.Repeat("=", width) + "\n")
+	ruler := make([]byte, width)
+	for i := range ruler {
+		ruler[i] = byte('0' + (i+1)%10)
+	}
+	sb.Write(ruler)
+	sb.WriteString("\n")
+	sb.WriteString(strings.Repeat("=", width) + "\n")
+
+	// Each mark should touch its edge of the paper, or sit in the middle
+	sb.WriteString("|<- LEFT\n")
+	sb.Write(escAlignCenter)
+	sb.WriteString("| CENTER |\n")
+	sb.Write(escAlignRight)
+	sb.WriteString("RIGHT ->|\n")
+	sb.Write(escAlignLeft)
+	sb.WriteString(strings.Repeat("=", width) + "\n")
+
+	sb.WriteString(s.formatLine("Printer:", s.Address(), width))
+	if s.config.PaperWidth > 0 {
+		sb.WriteString(s.formatLine("Paper:", fmt.Sprintf("%dmm", s.config.PaperWidth), width))
+	}
+	sb.WriteString(s.formatLine("Width:", fmt.Sprintf("%d characters", width), width))
+	sb.Write(escBoldOn)
+	sb.WriteString("Bold text\n")
+	sb.Write(escBoldOff)
+	sb.WriteString("Normal text\n")
+
+	sb.Write(escAlignCenter)
+	sb.WriteString("\nIf the ruler fits on one line,\n")
+	sb.WriteString("the printer is ready.\n\n")
+	sb.Write(escCut)
+
+	return []byte(sb.String())
+}
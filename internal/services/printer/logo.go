@@ -0,0 +1,51 @@
+package printer
+
+import (
+	"errors"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/images"
+)
+
+// Largest logo printed on thermal receipts, in dots. 256 dots is about
+// 32mm at the usual 203 dpi, so it fits 58mm and 80mm rolls alike.
+const (
+	logoMaxWidth  = 256
+	logoMaxHeight = 128
+)
+
+// logoThreshold is the luminance below which a logo pixel is printed black
+const logoThreshold = 128
+
+// RasterLogo converts a JPEG, PNG or GIF logo to an ESC/POS "GS v 0" raster
+// image command. The logo is scaled down to fit logoMaxWidth by
+// logoMaxHeight dots and each pixel becomes black or white by its
+// luminance; transparent areas are white.
+func RasterLogo(data []byte) ([]byte, error) {
+	src, err := images.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if b := src.Bounds(); b.Dx() == 0 || b.Dy() == 0 {
+		return nil, errors.New("logo is empty")
+	}
+	img := images.Fit(src, logoMaxWidth, logoMaxHeight)
+
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	rowBytes := (w + 7) / 8
+	cmd := []byte{0x1D, 0x76, 0x30, 0x00,
+		byte(rowBytes), byte(rowBytes >> 8),
+		byte(h), byte(h >> 8),
+	}
+	for y := 0; y < h; y++ {
+		row := make([]byte, rowBytes)
+		for x := 0; x < w; x++ {
+			c := img.RGBAAt(x, y)
+			lum := (299*int(c.R) + 587*int(c.G) + 114*int(c.B)) / 1000
+			if lum < logoThreshold {
+				row[x/8] |= 0x80 >> (x % 8)
+			}
+		}
+		cmd = append(cmd, row...)
+	}
+	return cmd, nil
+}
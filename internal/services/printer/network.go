@@ -0,0 +1,152 @@
+package printer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Defaults for network printers, which take raw ESC/POS on port 9100
+const (
+	DefaultPort    = 9100
+	DefaultTimeout = 5 * time.Second
+)
+
+// Errors sending to a network printer, so callers can tell the cashier
+// what to check
+var (
+	ErrNoPrinter          = errors.New("printer not configured")
+	ErrPrinterUnreachable = errors.New("printer unreachable")
+	ErrPrinterTimeout     = errors.New("printer did not respond")
+	ErrPrinterNotAllowed  = errors.New("printer address not allowed")
+)
+
+// sharedAddressSpace is carrier-grade NAT space (RFC 6598), as private to
+// the server's network as the RFC 1918 ranges
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// PublicIP reports whether ip is on the internet rather than the server's
+// own machine or network
+func PublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || sharedAddressSpace.Contains(ip))
+}
+
+// CheckPublicHost resolves host and returns ErrPrinterNotAllowed unless
+// every address it has is public
+func CheckPublicHost(host string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("%w: %s does not resolve", ErrPrinterNotAllowed, host)
+	}
+	for _, addr := range addrs {
+		if !PublicIP(addr.IP) {
+			return fmt.Errorf("%w: %s is on a private or local network", ErrPrinterNotAllowed, host)
+		}
+	}
+	return nil
+}
+
+// Address returns the host and port receipts are sent to, or the device
+// for USB and serial printers
+func (s *Service) Address() string {
+	if s.usesDevice() {
+		return s.config.Device
+	}
+	port := s.config.Port
+	if port == 0 {
+		port = DefaultPort
+	}
+	return net.JoinHostPort(s.config.Host, strconv.Itoa(port))
+}
+
+// TestPrint prints the test page on the printer
+func (s *Service) TestPrint() error {
+	return s.send(s.TestPage())
+}
+
+// Ping checks the printer accepts connections, without printing
+func (s *Service) Ping() error {
+	if s.usesDevice() {
+		return s.pingDevice()
+	}
+	conn, err := s.dial()
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// send writes raw ESC/POS commands to the printer
+func (s *Service) send(data []byte) error {
+	if s.usesDevice() {
+		return s.sendDevice(data)
+	}
+	conn, err := s.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	conn.SetWriteDeadline(time.Now().Add(s.timeout()))
+	if _, err := conn.Write(data); err != nil {
+		return s.networkError("failed to send to printer", err)
+	}
+	return nil
+}
+
+func (s *Service) dial() (net.Conn, error) {
+	if s.config.Host == "" {
+		return nil, ErrNoPrinter
+	}
+	dialer := net.Dialer{Timeout: s.timeout()}
+	if s.config.PublicOnly {
+		dialer.Control = publicOnly
+	}
+	conn, err := dialer.Dial("tcp", s.Address())
+	if errors.Is(err, ErrPrinterNotAllowed) {
+		return nil, fmt.Errorf("%w: %s is on a private or local network", ErrPrinterNotAllowed, s.Address())
+	}
+	if err != nil {
+		return nil, s.networkError("failed to connect to printer", err)
+	}
+	return conn, nil
+}
+
+// publicOnly refuses connections to non-public addresses. It checks the
+// address actually dialled, so a host name re-pointed after it was saved
+// can't reach the server's own network.
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !PublicIP(ip) {
+		return ErrPrinterNotAllowed
+	}
+	return nil
+}
+
+func (s *Service) timeout() time.Duration {
+	if s.config.Timeout > 0 {
+		return s.config.Timeout
+	}
+	return DefaultTimeout
+}
+
+// networkError wraps a connection error in ErrPrinterTimeout or
+// ErrPrinterUnreachable with the printer's address
+func (s *Service) networkError(action string, err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return fmt.Errorf("%w: %s at %s: no answer within %s; check the printer is on and on the same network",
+			ErrPrinterTimeout, action, s.Address(), s.timeout())
+	}
+	return fmt.Errorf("%w: %s at %s: %v", ErrPrinterUnreachable, action, s.Address(), err)
+}
@@ -0,0 +1,146 @@
+package printer
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/skip2/go-qrcode"
+)
+
+// PDF receipt layout in mm, sized for 80mm roll printers
+const (
+	pdfWidth      = 80.0
+	pdfMargin     = 4.0
+	pdfLogoWidth  = 30.0
+	pdfLogoHeight = 20.0
+	pdfQRSize     = 24.0
+)
+
+// qrPixels is the size of QR code images on HTML and PDF receipts
+const qrPixels = 256
+
+// pdfImageTypes maps the logo content types gofpdf can embed
+var pdfImageTypes = map[string]string{
+	"image/jpeg": "JPG",
+	"image/png":  "PNG",
+	"image/gif":  "GIF",
+}
+
+// GeneratePDF returns the receipt as a PDF as long as its contents, laid
+// out like the text receipt
+func (s *Service) GeneratePDF(receipt *Receipt) ([]byte, error) {
+	// Laid out once on a long page to measure it, then on one that fits
+	_, height := s.layoutPDF(receipt, 1000)
+	pdf, _ := s.layoutPDF(receipt, height+pdfMargin)
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// layoutPDF draws the receipt on a page height mm long and returns where
+// the receipt ended
+func (s *Service) layoutPDF(receipt *Receipt, height float64) (*gofpdf.Fpdf, float64) {
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{
+		OrientationStr: "P",
+		UnitStr:        "mm",
+		Size:           gofpdf.SizeType{Wd: pdfWidth, Ht: height},
+	})
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	width := pdfWidth - 2*pdfMargin
+	branding := receipt.Branding
+
+	if imageType, ok := pdfImageTypes[http.DetectContentType(branding.Logo)]; ok && len(branding.Logo) > 0 {
+		opts := gofpdf.ImageOptions{ImageType: imageType}
+		info := pdf.RegisterImageOptionsReader("receipt-logo", opts, bytes.NewReader(branding.Logo))
+		if pdf.Ok() && info != nil {
+			// Scaled to fit the logo box, keeping its shape
+			w, h := pdfLogoWidth, pdfLogoWidth*info.Height()/info.Width()
+			if h > pdfLogoHeight {
+				w, h = pdfLogoHeight*info.Width()/info.Height(), pdfLogoHeight
+			}
+			pdf.ImageOptions("receipt-logo", (pdfWidth-w)/2, pdf.GetY(), w, h, false, opts, 0, "")
+			pdf.SetY(pdf.GetY() + h + 2)
+		} else {
+			// A logo that cannot be read is left off rather than failing the receipt
+			pdf.ClearError()
+		}
+	}
+
+	pdf.SetFont("Arial", "B", 12)
+	pdf.MultiCell(width, 6, tr(receipt.ShopName), "", "C", false)
+	pdf.SetFont("Arial", "", 8)
+	for _, line := range []string{branding.Header, receipt.ShopPhone, receipt.ShopAddress, branding.Contact} {
+		if line = strings.TrimSpace(line); line != "" {
+			pdf.MultiCell(width, 4, tr(line), "", "C", false)
+		}
+	}
+	if branding.VATNumber != "" {
+		pdf.MultiCell(width, 4, tr("VAT No: "+branding.VATNumber), "", "C", false)
+	}
+
+	pdf.Ln(2)
+	pdf.CellFormat(width, 4, "Receipt: "+receipt.ID, "B", 1, "", false, 0, "")
+	pdf.CellFormat(width, 5, receipt.PrintedAt.Format("02/01/2006 15:04"), "", 1, "", false, 0, "")
+	if receipt.Cashier != "" {
+		pdf.CellFormat(width, 4, tr("Cashier: "+receipt.Cashier), "", 1, "", false, 0, "")
+	}
+
+	pdf.SetFont("Arial", "", 9)
+	for _, item := range receipt.Items {
+		pdf.MultiCell(width, 5, tr(item.Name), "", "", false)
+		pdf.CellFormat(width/2, 5, fmt.Sprintf("%d x %s", item.Quantity, receipt.money(item.UnitPrice)), "", 0, "", false, 0, "")
+		pdf.CellFormat(width/2, 5, receipt.money(item.Total), "", 1, "R", false, 0, "")
+	}
+
+	line := func(label, value string) {
+		pdf.CellFormat(width/2, 5, label, "", 0, "", false, 0, "")
+		pdf.CellFormat(width/2, 5, value, "", 1, "R", false, 0, "")
+	}
+	pdf.CellFormat(width, 1, "", "T", 1, "", false, 0, "")
+	line("Subtotal", receipt.money(receipt.Subtotal))
+	if receipt.Discount > 0 {
+		line("Discount", "-"+receipt.money(receipt.Discount))
+	}
+	if receipt.Tax > 0 {
+		line("Tax", receipt.money(receipt.Tax))
+	}
+	pdf.SetFont("Arial", "B", 11)
+	pdf.CellFormat(width/2, 7, "TOTAL", "T", 0, "", false, 0, "")
+	pdf.CellFormat(width/2, 7, receipt.money(receipt.Total), "T", 1, "R", false, 0, "")
+
+	pdf.SetFont("Arial", "", 8)
+	pdf.CellFormat(width, 5, tr("Payment: "+receipt.PaymentMethod), "", 1, "", false, 0, "")
+	if receipt.PaymentMethod == "cash" && receipt.CashGiven > 0 {
+		line("Cash", receipt.money(receipt.CashGiven))
+		line("Change", receipt.money(receipt.Change))
+	}
+	if receipt.LoyaltyPoints > 0 {
+		pdf.CellFormat(width, 5, fmt.Sprintf("You earned %d loyalty points!", receipt.LoyaltyPoints), "", 1, "", false, 0, "")
+	}
+
+	footer := strings.TrimSpace(branding.Footer)
+	if footer == "" {
+		footer = "Thank you for shopping with us!\nPlease come again"
+	}
+	if branding.ShowQR {
+		if png, err := qrcode.Encode(receipt.QRContent(), qrcode.Medium, qrPixels); err == nil {
+			opts := gofpdf.ImageOptions{ImageType: "PNG"}
+			pdf.RegisterImageOptionsReader("receipt-qr", opts, bytes.NewReader(png))
+			pdf.ImageOptions("receipt-qr", (pdfWidth-pdfQRSize)/2, pdf.GetY()+2, pdfQRSize, pdfQRSize, false, opts, 0, receipt.VerifyURL)
+			pdf.SetY(pdf.GetY() + pdfQRSize + 2)
+		}
+	}
+	pdf.Ln(2)
+	pdf.MultiCell(width, 4, tr(footer), "", "C", false)
+
+	return pdf, pdf.GetY()
+}
@@ -2,11 +2,13 @@ package printer
 
 import (
 	"bytes"
+	"encoding/base64"
 	"fmt"
-	"net"
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/skip2/go-qrcode"
 )
 
 // Receipt represents a receipt
@@ -28,6 +30,43 @@ type Receipt struct {
 	CustomerPhone string        `json:"customer_phone"`
 	LoyaltyPoints int           `json:"loyalty_points"`
 	PrintedAt     time.Time     `json:"printed_at"`
+	VerifyURL     string        `json:"verify_url"` // public link to the digital receipt, for the QR code
+	Branding      Branding      `json:"branding"`
+}
+
+// Branding is a shop's own text and logo on its receipts. Every part is
+// optional; receipts without any look as they always have.
+type Branding struct {
+	Logo      []byte `json:"-"`          // JPEG, PNG or GIF image
+	LogoURL   string `json:"logo_url"`   // used by HTML receipts instead of embedding Logo
+	Header    string `json:"header"`     // under the shop name, e.g. a slogan
+	Footer    string `json:"footer"`     // replaces the thank you message
+	Contact   string `json:"contact"`    // e.g. email, website or a second number
+	VATNumber string `json:"vat_number"` // the shop's KRA PIN
+	Currency  string `json:"currency"`   // symbol before amounts; KSh when empty
+	ShowQR    bool   `json:"show_qr"`    // QR code of the verification link, or the receipt number
+}
+
+// DefaultCurrency is the symbol amounts are printed with unless the shop
+// sets its own
+const DefaultCurrency = "KSh"
+
+// money formats an amount with the shop's currency symbol
+func (r *Receipt) money(amount float64) string {
+	currency := r.Branding.Currency
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	return fmt.Sprintf("%s %.0f", currency, amount)
+}
+
+// QRContent is what the receipt's QR code encodes: the link to verify it
+// online, or the receipt number when there is none
+func (r *Receipt) QRContent() string {
+	if r.VerifyURL != "" {
+		return r.VerifyURL
+	}
+	return r.ID
 }
 
 // ReceiptItem represents an item on receipt
@@ -40,14 +79,31 @@ type ReceiptItem struct {
 
 // PrinterConfig represents printer configuration
 type PrinterConfig struct {
-	Type    string `json:"type"` // thermal, cloud, pdf
-	Host    string `json:"host"`
-	Port    int    `json:"port"`
-	Width   int    `json:"width"` // characters per line (typically 32 or 48)
-	CharSet string `json:"char_set"`
-	APIKey  string `json:"api_key"`
+	Type       string        `json:"type"`       // thermal, cloud, pdf
+	Connection string        `json:"connection"` // how thermal printers are reached: network, usb or serial
+	Host       string        `json:"host"`
+	Port       int           `json:"port"`        // 9100 when not set
+	Device     string        `json:"device"`      // USB or serial device, e.g. /dev/usb/lp0
+	PaperWidth int           `json:"paper_width"` // roll width in mm, 58 or 80
+	Width      int           `json:"width"`       // characters per line; from the paper width when not set
+	CharSet    string        `json:"char_set"`
+	APIKey     string        `json:"api_key,omitempty"`
+	OpenDrawer bool          `json:"open_drawer"` // kick the cash drawer on cash sales
+	Timeout    time.Duration `json:"-"`           // to connect and to send; 5 seconds when not set
+	PublicOnly bool          `json:"-"`           // refuse devices and addresses on the server's own network
 }
 
+// Connections a thermal printer can be reached on
+const (
+	ConnectionNetwork = "network" // raw ESC/POS over TCP
+	ConnectionUSB     = "usb"     // a USB printer device such as /dev/usb/lp0
+	ConnectionSerial  = "serial"  // a serial port such as /dev/ttyUSB0, its baud rate set by the OS
+)
+
+// PaperWidths maps the roll widths receipts can be laid out for, in mm, to
+// the characters a line holds in the printer's default font
+var PaperWidths = map[int]int{58: 32, 80: 48}
+
 // Service handles receipt generation and printing
 type Service struct {
 	config *PrinterConfig
@@ -56,14 +112,50 @@ type Service struct {
 // New creates a new printer service
 func New(config *PrinterConfig) *Service {
 	if config == nil {
-		config = &PrinterConfig{
-			Type:  "thermal",
-			Width: 32,
-		}
+		config = &PrinterConfig{}
+	}
+	if config.Type == "" {
+		config.Type = "thermal"
+	}
+	if config.Connection == "" {
+		config.Connection = ConnectionNetwork
+	}
+	if config.Width == 0 {
+		config.Width = PaperWidths[config.PaperWidth]
+	}
+	if config.Width == 0 {
+		config.Width = 32
 	}
 	return &Service{config: config}
 }
 
+// With returns a service printing to a shop's own printer, keeping this
+// service's API key and timeout
+func (s *Service) With(config PrinterConfig) *Service {
+	if config.APIKey == "" {
+		config.APIKey = s.config.APIKey
+	}
+	if config.Timeout == 0 {
+		config.Timeout = s.config.Timeout
+	}
+	return New(&config)
+}
+
+// Configured reports whether there is a printer to send receipts to
+func (s *Service) Configured() bool {
+	if s.usesDevice() {
+		return s.config.Device != ""
+	}
+	return s.config.Host != ""
+}
+
+// Config returns the printer configuration without its API key
+func (s *Service) Config() PrinterConfig {
+	config := *s.config
+	config.APIKey = ""
+	return config
+}
+
 // GenerateReceipt creates a receipt from sale data
 func (s *Service) GenerateReceipt(saleID uint, shopName, shopPhone string, items []ReceiptItem, paymentMethod string, cashGiven float64) *Receipt {
 	subtotal := 0.0
@@ -95,14 +187,20 @@ func (s *Service) FormatText(receipt *Receipt) string {
 	var sb strings.Builder
 
 	// Header
+	branding := receipt.Branding
 	sb.WriteString(s.center("🏪 "+receipt.ShopName, width))
 	sb.WriteString("\n")
+	s.writeCentered(&sb, branding.Header, width)
 	sb.WriteString(s.center(receipt.ShopPhone, width))
 	sb.WriteString("\n")
 	if receipt.ShopAddress != "" {
 		sb.WriteString(s.center(receipt.ShopAddress, width))
 		sb.WriteString("\n")
 	}
+	s.writeCentered(&sb, branding.Contact, width)
+	if branding.VATNumber != "" {
+		s.writeCentered(&sb, "VAT No: "+branding.VATNumber, width)
+	}
 	sb.WriteString(strings.Repeat("-", width))
 	sb.WriteString("\n")
 
@@ -122,8 +220,8 @@ func (s *Service) FormatText(receipt *Receipt) string {
 			name = name[:15]
 		}
 		qty := fmt.Sprintf("%d x", item.Quantity)
-		price := fmt.Sprintf("KSh %.0f", item.UnitPrice)
-		total := fmt.Sprintf("KSh %.0f", item.Total)
+		price := receipt.money(item.UnitPrice)
+		total := receipt.money(item.Total)
 
 		padding := strings.Repeat(" ", width-len(name)-len(qty)-len(price)-len(total)-2)
 		line := fmt.Sprintf("%s %s\n%s%s", name, qty, padding, price+total)
@@ -135,16 +233,16 @@ func (s *Service) FormatText(receipt *Receipt) string {
 	sb.WriteString("\n")
 
 	// Totals
-	sb.WriteString(s.formatLine("Subtotal:", fmt.Sprintf("KSh %.0f", receipt.Subtotal), width))
+	sb.WriteString(s.formatLine("Subtotal:", receipt.money(receipt.Subtotal), width))
 	if receipt.Discount > 0 {
-		sb.WriteString(s.formatLine("Discount:", fmt.Sprintf("-KSh %.0f", receipt.Discount), width))
+		sb.WriteString(s.formatLine("Discount:", "-"+receipt.money(receipt.Discount), width))
 	}
 	if receipt.Tax > 0 {
-		sb.WriteString(s.formatLine("Tax:", fmt.Sprintf("KSh %.0f", receipt.Tax), width))
+		sb.WriteString(s.formatLine("Tax:", receipt.money(receipt.Tax), width))
 	}
 	sb.WriteString(strings.Repeat("=", width))
 	sb.WriteString("\n")
-	sb.WriteString(s.formatLine("TOTAL:", fmt.Sprintf("KSh %.0f", receipt.Total), width))
+	sb.WriteString(s.formatLine("TOTAL:", receipt.money(receipt.Total), width))
 	sb.WriteString("\n")
 
 	// Payment info
@@ -153,8 +251,8 @@ func (s *Service) FormatText(receipt *Receipt) string {
 	sb.WriteString(fmt.Sprintf("Payment: %s\n", receipt.PaymentMethod))
 
 	if receipt.PaymentMethod == "cash" && receipt.CashGiven > 0 {
-		sb.WriteString(s.formatLine("Cash:", fmt.Sprintf("KSh %.0f", receipt.CashGiven), width))
-		sb.WriteString(s.formatLine("Change:", fmt.Sprintf("KSh %.0f", receipt.Change), width))
+		sb.WriteString(s.formatLine("Cash:", receipt.money(receipt.CashGiven), width))
+		sb.WriteString(s.formatLine("Change:", receipt.money(receipt.Change), width))
 	}
 
 	// Loyalty points
@@ -164,42 +262,56 @@ func (s *Service) FormatText(receipt *Receipt) string {
 		sb.WriteString(fmt.Sprintf("🎁 You earned %d loyalty points!\n", receipt.LoyaltyPoints))
 	}
 
+	// A text receipt can't draw the QR code, so it gives its link
+	if branding.ShowQR && receipt.VerifyURL != "" {
+		sb.WriteString(strings.Repeat("-", width))
+		sb.WriteString("\n")
+		s.writeCentered(&sb, "Verify this receipt at", width)
+		s.writeCentered(&sb, receipt.VerifyURL, width)
+	}
+
 	// Footer
 	sb.WriteString(strings.Repeat("-", width))
 	sb.WriteString("\n")
-	sb.WriteString(s.center("Thank you for shopping", width))
-	sb.WriteString("\n")
-	sb.WriteString(s.center("with us!", width))
-	sb.WriteString("\n\n")
-	sb.WriteString(s.center("Please come again", width))
-	sb.WriteString("\n")
+	if branding.Footer != "" {
+		s.writeCentered(&sb, branding.Footer, width)
+	} else {
+		sb.WriteString(s.center("Thank you for shopping", width))
+		sb.WriteString("\n")
+		sb.WriteString(s.center("with us!", width))
+		sb.WriteString("\n\n")
+		sb.WriteString(s.center("Please come again", width))
+		sb.WriteString("\n")
+	}
 	sb.WriteString("\n\n\n") // Paper feed
 
 	return sb.String()
 }
 
-// FormatThermal generates ESC/POS commands for thermal printer
+// FormatThermal generates ESC/POS commands for thermal printer: the shop
+// name in bold, the receipt cut at the end and, when configured, the cash
+// drawer opened for cash sales
 func (s *Service) FormatThermal(receipt *Receipt) []byte {
+	width := s.config.Width
 	var sb strings.Builder
 
-	// ESC/POS Commands
-	initialize := []byte{0x1B, 0x40}        // Initialize printer
-	alignCenter := []byte{0x1B, 0x61, 0x01} // Center align
-	alignLeft := []byte{0x1B, 0x61, 0x00}   // Left align
-	boldOn := []byte{0x1B, 0x45, 0x01}      // Bold on
-	boldOff := []byte{0x1B, 0x45, 0x00}     // Bold off
-	doubleOn := []byte{0x1B, 0x21, 0x10}    // Double height/width
-	doubleOff := []byte{0x1B, 0x21, 0x00}   // Normal size
-	cut := []byte{0x1D, 0x56, 0x00}         // Cut paper
-
-	sb.Write(initialize)
-	sb.Write(alignCenter)
-	sb.Write(boldOn)
-	sb.Write(doubleOn)
+	branding := receipt.Branding
+	sb.Write(escInit)
+	sb.Write(escAlignCenter)
+	if len(branding.Logo) > 0 {
+		// A logo that cannot be read is left off rather than failing the receipt
+		if logo, err := RasterLogo(branding.Logo); err == nil {
+			sb.Write(logo)
+			sb.WriteString("\n")
+		}
+	}
+	sb.Write(escBoldOn)
+	sb.Write(escDoubleOn)
 	sb.WriteString(receipt.ShopName)
 	sb.WriteString("\n")
-	sb.Write(doubleOff)
-	sb.Write(boldOff)
+	sb.Write(escDoubleOff)
+	sb.Write(escBoldOff)
+	s.writeLines(&sb, branding.Header)
 
 	sb.WriteString(receipt.ShopPhone)
 	sb.WriteString("\n")
@@ -207,76 +319,91 @@ func (s *Service) FormatThermal(receipt *Receipt) []byte {
 		sb.WriteString(receipt.ShopAddress)
 		sb.WriteString("\n")
 	}
+	s.writeLines(&sb, branding.Contact)
+	if branding.VATNumber != "" {
+		s.writeLines(&sb, "VAT No: "+branding.VATNumber)
+	}
 
-	sb.Write(alignLeft)
-	sb.WriteString("--------------------------------")
+	sb.Write(escAlignLeft)
+	sb.WriteString(strings.Repeat("-", width))
+	sb.WriteString("\n")
+	sb.WriteString(fmt.Sprintf("Receipt: %s\n", receipt.ID))
+	sb.WriteString(fmt.Sprintf("Date: %s\n", receipt.PrintedAt.Format("02/01/2006 15:04")))
+	if receipt.Cashier != "" {
+		sb.WriteString(fmt.Sprintf("Cashier: %s\n", receipt.Cashier))
+	}
+	sb.WriteString(strings.Repeat("-", width))
+	sb.WriteString("\n")
 
-	// Items
+	// Items: the name, then quantity and price with the line total
 	for _, item := range receipt.Items {
 		name := item.Name
-		if len(name) > 16 {
-			name = name[:16]
+		if len(name) > width {
+			name = name[:width]
 		}
-		qty := fmt.Sprintf("%d x", item.Quantity)
-		price := fmt.Sprintf("%.0f", item.UnitPrice)
-		total := fmt.Sprintf("%.0f", item.Total)
-
-		line := fmt.Sprintf("%-16s %s\n%-32s%s", name, qty, price, total)
-		sb.WriteString(line)
+		sb.WriteString(name)
 		sb.WriteString("\n")
+		sb.WriteString(s.formatLine(fmt.Sprintf("  %d x %.0f", item.Quantity, item.UnitPrice), fmt.Sprintf("%.0f", item.Total), width))
 	}
 
-	sb.WriteString("--------------------------------")
+	sb.WriteString(strings.Repeat("-", width))
 	sb.WriteString("\n")
 
 	// Totals
-	subtotal := fmt.Sprintf("Subtotal: KSh %.0f", receipt.Subtotal)
-	sb.WriteString(subtotal)
-	sb.WriteString("\n")
-
+	sb.WriteString(s.formatLine("Subtotal:", receipt.money(receipt.Subtotal), width))
 	if receipt.Discount > 0 {
-		discount := fmt.Sprintf("Discount: -KSh %.0f", receipt.Discount)
-		sb.WriteString(discount)
-		sb.WriteString("\n")
+		sb.WriteString(s.formatLine("Discount:", "-"+receipt.money(receipt.Discount), width))
 	}
-
-	sb.WriteString("================================")
-	sb.WriteString("\n")
-
-	total := fmt.Sprintf("TOTAL: KSh %.0f", receipt.Total)
-	sb.Write(boldOn)
-	sb.WriteString(total)
-	sb.Write(boldOff)
+	if receipt.Tax > 0 {
+		sb.WriteString(s.formatLine("Tax:", receipt.money(receipt.Tax), width))
+	}
+	sb.WriteString(strings.Repeat("=", width))
 	sb.WriteString("\n")
+	sb.Write(escBoldOn)
+	sb.WriteString(s.formatLine("TOTAL:", receipt.money(receipt.Total), width))
+	sb.Write(escBoldOff)
 
 	// Payment
-	sb.WriteString("--------------------------------")
+	sb.WriteString(strings.Repeat("-", width))
 	sb.WriteString("\n")
-
+	if receipt.PaymentMethod != "" {
+		sb.WriteString(fmt.Sprintf("Payment: %s\n", receipt.PaymentMethod))
+	}
 	if receipt.PaymentMethod == "cash" && receipt.CashGiven > 0 {
-		cash := fmt.Sprintf("Cash: KSh %.0f", receipt.CashGiven)
-		sb.WriteString(cash)
-		sb.WriteString("\n")
-		change := fmt.Sprintf("Change: KSh %.0f", receipt.Change)
-		sb.WriteString(change)
-		sb.WriteString("\n")
+		sb.WriteString(s.formatLine("Cash:", receipt.money(receipt.CashGiven), width))
+		sb.WriteString(s.formatLine("Change:", receipt.money(receipt.Change), width))
+	}
+	if receipt.LoyaltyPoints > 0 {
+		sb.WriteString(fmt.Sprintf("You earned %d loyalty points!\n", receipt.LoyaltyPoints))
 	}
 
 	// Footer
-	sb.WriteString("================================")
-	sb.WriteString("\n")
-	sb.Write(alignCenter)
-	sb.WriteString("Thank you for shopping with us!")
+	sb.WriteString(strings.Repeat("=", width))
 	sb.WriteString("\n")
-	sb.WriteString("Please come again")
-	sb.WriteString("\n\n\n")
+	sb.Write(escAlignCenter)
+	if branding.ShowQR {
+		sb.Write(escQRCode(receipt.QRContent()))
+		sb.WriteString("\n")
+	}
+	if branding.Footer != "" {
+		s.writeLines(&sb, branding.Footer)
+	} else {
+		sb.WriteString("Thank you for shopping with us!")
+		sb.WriteString("\n")
+		sb.WriteString("Please come again")
+		sb.WriteString("\n")
+	}
 
-	sb.Write(cut)
+	sb.Write(escCut)
+	if s.config.OpenDrawer && receipt.PaymentMethod == "cash" {
+		sb.Write(escDrawerKick)
+	}
 
 	return []byte(sb.String())
 }
 
-// FormatPDF generates HTML for PDF receipt
+// FormatHTML generates an HTML receipt. Text is inserted as is, so callers
+// showing it to the public escape it first.
 func (s *Service) FormatHTML(receipt *Receipt) string {
 	itemsHTML := ""
 	for _, item := range receipt.Items {
@@ -284,9 +411,9 @@ func (s *Service) FormatHTML(receipt *Receipt) string {
 		<tr>
 			<td>%s</td>
 			<td>%d</td>
-			<td>KSh %.0f</td>
-			<td>KSh %.0f</td>
-		</tr>`, item.Name, item.Quantity, item.UnitPrice, item.Total)
+			<td>%s</td>
+			<td>%s</td>
+		</tr>`, item.Name, item.Quantity, receipt.money(item.UnitPrice), receipt.money(item.Total))
 	}
 
 	return fmt.Sprintf(`<!DOCTYPE html>
@@ -302,13 +429,17 @@ func (s *Service) FormatHTML(receipt *Receipt) string {
         .item { display: flex; justify-content: space-between; }
         .total { font-weight: bold; font-size: 18px; }
         .footer { text-align: center; margin-top: 20px; }
+        .logo { max-width: 160px; max-height: 80px; }
+        .qr { text-align: center; margin-top: 10px; }
+        .qr img { width: 120px; height: 120px; }
     </style>
 </head>
 <body>
     <div class="header">
-        <div class="shop-name">🏪 %s</div>
-        <div>%s</div>
+        %s<div class="shop-name">🏪 %s</div>
+        %s<div>%s</div>
         <div>%s</div>
+        %s
     </div>
     <div class="divider"></div>
     <div>Receipt: %s</div>
@@ -319,28 +450,31 @@ func (s *Service) FormatHTML(receipt *Receipt) string {
         %s
     </table>
     <div class="divider"></div>
-    <div>Subtotal: KSh %.0f</div>
+    <div>Subtotal: %s</div>
     %s
-    <div class="total">TOTAL: KSh %.0f</div>
+    <div class="total">TOTAL: %s</div>
     <div class="divider"></div>
     <div>Payment: %s</div>
     %s
     <div class="divider"></div>
-    <div class="footer">
-        <p>Thank you for shopping with us!</p>
-        <p>Please come again</p>
+    %s<div class="footer">
+        %s
     </div>
 </body>
 </html>`,
 		receipt.ID,
-		receipt.ShopName, receipt.ShopPhone, receipt.ShopAddress,
+		logoHTML(receipt.Branding), receipt.ShopName,
+		htmlLine(receipt.Branding.Header), receipt.ShopPhone, receipt.ShopAddress,
+		contactHTML(receipt.Branding),
 		receipt.ID, receipt.PrintedAt.Format("02/01/2006 15:04"),
 		itemsHTML,
-		receipt.Subtotal,
-		formatDiscount(receipt.Discount),
-		receipt.Total,
+		receipt.money(receipt.Subtotal),
+		formatDiscount(receipt),
+		receipt.money(receipt.Total),
 		receipt.PaymentMethod,
-		formatCash(receipt.CashGiven, receipt.Change),
+		formatCash(receipt),
+		qrHTML(receipt),
+		footerHTML(receipt.Branding.Footer),
 	)
 }
 
@@ -362,23 +496,125 @@ func (s *Service) formatLine(label, value string, width int) string {
 	return label + strings.Repeat(" ", padding) + value + "\n"
 }
 
-func formatDiscount(discount float64) string {
-	if discount <= 0 {
+// writeCentered writes text centered, wrapped to width
+func (s *Service) writeCentered(sb *strings.Builder, text string, width int) {
+	for _, line := range wrap(text, width) {
+		sb.WriteString(s.center(line, width))
+		sb.WriteString("\n")
+	}
+}
+
+// writeLines writes text wrapped to the paper width, for the printer to
+// align
+func (s *Service) writeLines(sb *strings.Builder, text string) {
+	for _, line := range wrap(text, s.config.Width) {
+		sb.WriteString(line)
+		sb.WriteString("\n")
+	}
+}
+
+// wrap splits text into lines of at most width bytes, breaking at spaces
+// where it can and keeping the line breaks already in it
+func wrap(text string, width int) []string {
+	var lines []string
+	for _, para := range strings.Split(strings.TrimSpace(text), "\n") {
+		line := ""
+		for _, word := range strings.Fields(para) {
+			for len(word) > width {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				lines = append(lines, word[:width])
+				word = word[width:]
+			}
+			switch {
+			case line == "":
+				line = word
+			case len(line)+1+len(word) <= width:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// logoHTML shows the logo from its URL, or embedded when there is none
+func logoHTML(b Branding) string {
+	src := b.LogoURL
+	if src == "" && len(b.Logo) > 0 {
+		src = "data:" + http.DetectContentType(b.Logo) + ";base64," + base64.StdEncoding.EncodeToString(b.Logo)
+	}
+	if src == "" {
 		return ""
 	}
-	return fmt.Sprintf("<div>Discount: -KSh %.0f</div>", discount)
+	return fmt.Sprintf(`<img class="logo" src="%s" alt="">
+        `, src)
 }
 
-func formatCash(cash, change float64) string {
-	if cash <= 0 {
+// htmlLine is text as a div with its line breaks kept, or nothing
+func htmlLine(text string) string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ""
+	}
+	return fmt.Sprintf(`<div>%s</div>
+        `, strings.ReplaceAll(text, "\n", "<br>"))
+}
+
+func contactHTML(b Branding) string {
+	out := htmlLine(b.Contact)
+	if b.VATNumber != "" {
+		out += htmlLine("VAT No: " + b.VATNumber)
+	}
+	return strings.TrimSpace(out)
+}
+
+func footerHTML(footer string) string {
+	footer = strings.TrimSpace(footer)
+	if footer == "" {
+		return `<p>Thank you for shopping with us!</p>
+        <p>Please come again</p>`
+	}
+	return fmt.Sprintf("<p>%s</p>", strings.ReplaceAll(footer, "\n", "<br>"))
+}
+
+// qrHTML embeds the receipt's QR code when the shop shows one
+func qrHTML(receipt *Receipt) string {
+	if !receipt.Branding.ShowQR {
+		return ""
+	}
+	png, err := qrcode.Encode(receipt.QRContent(), qrcode.Medium, qrPixels)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf(`<div class="qr"><img src="data:image/png;base64,%s" alt="Receipt %s"></div>
+    `, base64.StdEncoding.EncodeToString(png), receipt.ID)
+}
+
+func formatDiscount(receipt *Receipt) string {
+	if receipt.Discount <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("<div>Discount: -%s</div>", receipt.money(receipt.Discount))
+}
+
+func formatCash(receipt *Receipt) string {
+	if receipt.CashGiven <= 0 {
 		return ""
 	}
 	return fmt.Sprintf(`
-    <div>Cash: KSh %.0f</div>
-    <div>Change: KSh %.0f</div>`, cash, change)
+    <div>Cash: %s</div>
+    <div>Change: %s</div>`, receipt.money(receipt.CashGiven), receipt.money(receipt.Change))
 }
 
-// Print sends receipt to printer (placeholder - implement actual printing)
+// Print sends receipt to printer: thermal printers get ESC/POS over TCP
 func (s *Service) Print(receipt *Receipt) error {
 	switch s.config.Type {
 	case "thermal":
@@ -392,38 +628,21 @@ func (s *Service) Print(receipt *Receipt) error {
 	}
 }
 
+// printThermal sends the receipt to a network printer as raw ESC/POS
 func (s *Service) printThermal(receipt *Receipt) error {
-	if s.config.Host == "" {
-		return fmt.Errorf("printer host not configured")
-	}
-
-	addr := fmt.Sprintf("%s:%d", s.config.Host, s.config.Port)
-	if s.config.Port == 0 {
-		addr = fmt.Sprintf("%s:9100", s.config.Host)
-	}
-
-	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
-	if err != nil {
-		return fmt.Errorf("failed to connect to printer: %w", err)
-	}
-	defer conn.Close()
-
-	data := s.FormatThermal(receipt)
-	_, err = conn.Write(data)
-	if err != nil {
-		return fmt.Errorf("failed to send to printer: %w", err)
-	}
-
-	return nil
+	return s.send(s.FormatThermal(receipt))
 }
 
 func (s *Service) printCloud(receipt *Receipt) error {
+	return s.sendCloud(s.FormatThermal(receipt))
+}
+
+// sendCloud sends ESC/POS commands to a cloud printer
+func (s *Service) sendCloud(data []byte) error {
 	if s.config.APIKey == "" {
 		return fmt.Errorf("cloud printer API key not configured")
 	}
 
-	data := s.FormatThermal(receipt)
-
 	switch s.config.Type {
 	case "epson":
 		return s.printEpsonCloud(data)
@@ -512,15 +731,6 @@ func (s *Service) printPDF(receipt *Receipt) error {
 	return err
 }
 
-// GeneratePDF returns PDF bytes for the receipt
-func (s *Service) GeneratePDF(receipt *Receipt) ([]byte, error) {
-	html := s.FormatHTML(receipt)
-
-	pdfData := []byte(html)
-
-	return pdfData, nil
-}
-
 // DailyReport generates daily summary receipt
 func (s *Service) DailyReport(shopName string, totalSales float64, transactionCount int, topProducts []string) string {
 	width := s.config.Width
@@ -0,0 +1,135 @@
+package printer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/zreport"
+)
+
+// FormatZReport lays out an end-of-day Z-report as plain text the width of
+// the paper
+func (s *Service) FormatZReport(r *zreport.Report) string {
+	return s.center(zReportTitle(r), s.config.Width) + "\n" + s.zReportBody(r)
+}
+
+// FormatZReportThermal returns ESC/POS commands that print a Z-report with
+// its title in large type, then cut the paper. The cash drawer stays shut.
+func (s *Service) FormatZReportThermal(r *zreport.Report) []byte {
+	var sb strings.Builder
+	sb.Write(escInit)
+	sb.Write(escAlignCenter)
+	sb.Write(escBoldOn)
+	sb.Write(escDoubleOn)
+	sb.WriteString(zReportTitle(r))
+	sb.WriteString("\n")
+	sb.Write(escDoubleOff)
+	sb.Write(escBoldOff)
+	sb.Write(escAlignLeft)
+	sb.WriteString(s.zReportBody(r))
+	sb.WriteString("\n\n")
+	sb.Write(escCut)
+	return []byte(sb.String())
+}
+
+// PrintZReport prints a Z-report on the configured printer
+func (s *Service) PrintZReport(r *zreport.Report) error {
+	switch s.config.Type {
+	case "thermal":
+		return s.send(s.FormatZReportThermal(r))
+	case "cloud":
+		return s.sendCloud(s.FormatZReportThermal(r))
+	case "pdf":
+		// Nothing to send; the report is downloaded as a PDF instead
+		return nil
+	default:
+		return fmt.Errorf("unsupported printer type: %s", s.config.Type)
+	}
+}
+
+func zReportTitle(r *zreport.Report) string {
+	if !r.Closed {
+		return "Z-REPORT (NOT CLOSED)"
+	}
+	return fmt.Sprintf("Z-REPORT #%d", r.Number)
+}
+
+// zReportBody lays out everything below a Z-report's title
+func (s *Service) zReportBody(r *zreport.Report) string {
+	width := s.config.Width
+	var sb strings.Builder
+	money := func(amount float64) string { return fmt.Sprintf("KSh %.0f", amount) }
+	rule := func(c string) {
+		sb.WriteString(strings.Repeat(c, width))
+		sb.WriteString("\n")
+	}
+
+	s.writeCentered(&sb, r.ShopName, width)
+	sb.WriteString(s.center(r.BusinessDate.Format("Mon 02/01/2006"), width))
+	sb.WriteString("\n")
+	rule("=")
+	sb.WriteString(s.formatLine("From:", r.PeriodStart.Format("02/01 15:04"), width))
+	sb.WriteString(s.formatLine("To:", r.PeriodEnd.Format("02/01 15:04"), width))
+	rule("-")
+
+	sb.WriteString(s.formatLine("Cash sales:", money(r.CashSales), width))
+	sb.WriteString(s.formatLine("M-Pesa sales:", money(r.MpesaSales), width))
+	if r.OtherSales != 0 {
+		sb.WriteString(s.formatLine("Card/bank sales:", money(r.OtherSales), width))
+	}
+	sb.WriteString(s.formatLine("Gross sales:", money(r.GrossSales), width))
+	if r.Discounts != 0 {
+		sb.WriteString(s.formatLine("Discounts given:", money(r.Discounts), width))
+	}
+	sb.WriteString(s.formatLine(fmt.Sprintf("Refunds (%d):", r.RefundCount), "-"+money(r.Refunds), width))
+	sb.WriteString(s.formatLine("NET SALES:", money(r.NetSales), width))
+	sb.WriteString(s.formatLine("Transactions:", fmt.Sprintf("%d", r.Transactions), width))
+	sb.WriteString(s.formatLine("Expenses:", "-"+money(r.Expenses), width))
+	sb.WriteString(s.formatLine("NET:", money(r.Net), width))
+	rule("-")
+
+	sb.WriteString(s.formatLine("Opening float:", money(r.OpeningFloat), width))
+	if r.CashExpenses != 0 {
+		sb.WriteString(s.formatLine("Cash paid out:", "-"+money(r.CashExpenses), width))
+	}
+	sb.WriteString(s.formatLine("Expected cash:", money(r.ExpectedCash), width))
+	if r.CountedCash != nil && r.Variance != nil {
+		sb.WriteString(s.formatLine("Counted cash:", money(*r.CountedCash), width))
+		if *r.Variance < 0 {
+			sb.WriteString(s.formatLine("Short:", money(-*r.Variance), width))
+		} else {
+			sb.WriteString(s.formatLine("Over:", money(*r.Variance), width))
+		}
+	}
+
+	if len(r.ByCategory) > 0 {
+		rule("-")
+		sb.WriteString("By category:\n")
+		for _, category := range r.ByCategory {
+			sb.WriteString(s.formatLine(fmt.Sprintf("%s (%d)", category.Category, category.Quantity), money(category.Total), width))
+		}
+	}
+
+	if len(r.ByStaff) > 1 || (len(r.ByStaff) == 1 && r.ByStaff[0].StaffID != nil) {
+		rule("-")
+		sb.WriteString("By staff:\n")
+		for _, staff := range r.ByStaff {
+			sb.WriteString(s.formatLine(fmt.Sprintf("%s (%d)", staff.Name, staff.Transactions), money(staff.Total), width))
+			if staff.CashSales != 0 {
+				sb.WriteString(s.formatLine("  cash", money(staff.CashSales), width))
+			}
+		}
+	}
+
+	rule("=")
+	if r.Closed {
+		closed := "Day closed"
+		if r.ClosedBy != "" {
+			closed += " by " + r.ClosedBy
+		}
+		s.writeCentered(&sb, closed, width)
+	} else {
+		s.writeCentered(&sb, "Day not closed", width)
+	}
+	return sb.String()
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"time"
@@ -84,12 +85,13 @@ func (s *QRPaymentService) UpdatePaymentStatus(checkoutID string, status string)
 }
 
 type DynamicQRRequest struct {
-	ShopID      uint    `json:"shop_id"`
-	Amount      float64 `json:"amount"`
-	Reference   string  `json:"reference"`
-	Description string  `json:"description"`
-	ProductID   *uint   `json:"product_id,omitempty"`
-	Phone       string  `json:"phone,omitempty"`
+	ShopID        uint    `json:"shop_id"`
+	Amount        float64 `json:"amount"`
+	Reference     string  `json:"reference"`
+	Description   string  `json:"description"`
+	ProductID     *uint   `json:"product_id,omitempty"`
+	PendingSaleID *uint   `json:"pending_sale_id,omitempty"` // basket the QR pays for
+	Phone         string  `json:"phone,omitempty"`
 }
 
 type DynamicQRResponse struct {
@@ -148,7 +150,7 @@ func (s *QRPaymentService) GenerateDynamicQR(ctx context.Context, req *DynamicQR
 	qrData := QRCodeData{
 		Version:   2,
 		ShopID:    strconv.FormatUint(uint64(req.ShopID), 10),
-		Amount:    int(req.Amount),
+		Amount:    int(math.Round(req.Amount)),
 		Reference: reference,
 		ProductID: 0,
 		Phone:     req.Phone,
@@ -166,7 +168,7 @@ func (s *QRPaymentService) GenerateDynamicQR(ctx context.Context, req *DynamicQR
 
 	paymentID, checkoutID := uint(0), ""
 
-	if s.mpesaSvc != nil && s.mpesaSvc.IsConfigured() {
+	if s.mpesaSvc != nil && s.mpesaSvc.IsConfiguredForShop(req.ShopID) {
 		phone := req.Phone
 		if phone == "" {
 			phone = shop.Phone
@@ -177,10 +179,11 @@ func (s *QRPaymentService) GenerateDynamicQR(ctx context.Context, req *DynamicQR
 			mpesaReq := &mpesa.PaymentRequest{
 				Phone:            validatedPhone,
 				Amount:           req.Amount,
-				AccountReference: reference,
+				AccountReference: req.Reference, // generated references are too long for Daraja
 				Description:      req.Description,
 				ShopID:           req.ShopID,
 				ProductID:        req.ProductID,
+				PendingSaleID:    req.PendingSaleID,
 			}
 
 			payment, _, err := s.mpesaSvc.InitiateSTKPush(ctx, mpesaReq)
@@ -270,13 +273,13 @@ func (s *QRPaymentService) ProcessPayment(ctx context.Context, qrString string,
 		reference = s.generateReference("PAY")
 	}
 
-	if s.mpesaSvc != nil && s.mpesaSvc.IsConfigured() {
+	if s.mpesaSvc != nil && s.mpesaSvc.IsConfiguredForShop(uint(shopID)) {
 		validatedPhone, err := s.mpesaSvc.ValidatePhone(phone)
 		if err == nil {
 			mpesaReq := &mpesa.PaymentRequest{
 				Phone:            validatedPhone,
 				Amount:           amount,
-				AccountReference: reference,
+				AccountReference: qrData.Reference, // generated references are too long for Daraja
 				Description:      "QR Payment",
 				ShopID:           uint(shopID),
 			}
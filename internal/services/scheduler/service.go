@@ -1,7 +1,10 @@
 package scheduler
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"runtime/debug"
 	"sync"
 	"time"
 )
@@ -14,6 +17,7 @@ type Task struct {
 	CronExpr    string
 	Handler     func() error
 	LastRun     time.Time
+	LastError   string
 	NextRun     time.Time
 	IsRunning   bool
 	IsActive    bool
@@ -23,15 +27,19 @@ type Task struct {
 type Scheduler struct {
 	tasks   map[string]*Task
 	mu      sync.RWMutex
-	stopCh  chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stopOnce sync.Once
 	wg      sync.WaitGroup
 }
 
 // New creates a new scheduler
 func New() *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Scheduler{
 		tasks:  make(map[string]*Task),
-		stopCh: make(chan struct{}),
+		ctx:    ctx,
+		cancel: cancel,
 	}
 }
 
@@ -91,11 +99,20 @@ func (s *Scheduler) Start() {
 	log.Printf("📅 Scheduler started with %d tasks", len(s.tasks))
 }
 
-// Stop halts the scheduler
+// Stop cancels the scheduler and waits for running tasks to return. It is safe
+// to call more than once.
 func (s *Scheduler) Stop() {
-	close(s.stopCh)
-	s.wg.Wait()
-	log.Printf("📅 Scheduler stopped")
+	s.stopOnce.Do(func() {
+		s.cancel()
+		s.wg.Wait()
+		log.Printf("📅 Scheduler stopped")
+	})
+}
+
+// Context is cancelled when the scheduler stops; long-running task handlers
+// should watch it.
+func (s *Scheduler) Context() context.Context {
+	return s.ctx
 }
 
 func (s *Scheduler) run() {
@@ -106,7 +123,7 @@ func (s *Scheduler) run() {
 
 	for {
 		select {
-		case <-s.stopCh:
+		case <-s.ctx.Done():
 			return
 		case <-ticker.C:
 			s.runPendingTasks()
@@ -153,15 +170,19 @@ func (s *Scheduler) runTask(name string) {
 
 	log.Printf("📅 Running task: %s", name)
 
-	err := task.Handler()
+	err := runHandler(task)
 	if err != nil {
 		log.Printf("❌ Task %s failed: %v", name, err)
 		// Don't update next run on failure - retry sooner
 		s.mu.Lock()
 		task.NextRun = time.Now().Add(1 * time.Minute)
+		task.LastError = err.Error()
 		s.mu.Unlock()
 	} else {
 		log.Printf("✅ Task %s completed", name)
+		s.mu.Lock()
+		task.LastError = ""
+		s.mu.Unlock()
 	}
 
 	s.mu.Lock()
@@ -169,6 +190,18 @@ func (s *Scheduler) runTask(name string) {
 	s.mu.Unlock()
 }
 
+// runHandler calls the task handler, recovering from panics so one broken
+// task doesn't stop the scheduler loop
+func runHandler(task *Task) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("❌ Task %s panicked: %v\n%s", task.Name, r, debug.Stack())
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return task.Handler()
+}
+
 // GetTaskStatus returns status of all tasks
 func (s *Scheduler) GetTaskStatus() []map[string]interface{} {
 	s.mu.RLock()
@@ -181,6 +214,7 @@ func (s *Scheduler) GetTaskStatus() []map[string]interface{} {
 			"is_active":  task.IsActive,
 			"is_running":  task.IsRunning,
 			"last_run":   task.LastRun,
+			"last_error": task.LastError,
 			"next_run":   task.NextRun,
 			"schedule":   task.Schedule.String(),
 		})
@@ -1,12 +1,17 @@
 package sms
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/qr"
 )
 
 // Config holds Africa Talking configuration
@@ -14,12 +19,21 @@ type Config struct {
 	APIKey   string
 	Username string
 	BaseURL  string
+	From     string // sender ID or short code; empty uses the account default
 }
 
 // Service handles SMS sending via Africa Talking
 type Service struct {
 	config *Config
 	client *http.Client
+	repo   *repository.SmsMessageRepository // message log
+	linker *qr.ReceiptLinker
+	queue  func(shopID uint, purpose models.SmsPurpose, to, message string) error // the outbox, when set
+
+	// SMS campaigns
+	campaigns  *repository.SmsCampaignRepository
+	batchSize  int
+	batchPause time.Duration
 }
 
 // New creates a new Africa Talking SMS service
@@ -35,79 +49,83 @@ func New(config *Config) *Service {
 	}
 }
 
-// SendSMS sends an SMS message
-func (s *Service) SendSMS(to, message string) (string, error) {
-	if s.config.APIKey == "" || s.config.Username == "" {
-		return "", fmt.Errorf("Africa Talking credentials not configured")
-	}
-
-	// Format phone number
-	to = formatPhone(to)
+// sendResult is what Africa's Talking reports for one recipient
+type sendResult struct {
+	StatusCode int    `json:"statusCode"`
+	Number     string `json:"number"`
+	Status     string `json:"status"`
+	Cost       string `json:"cost"` // e.g. "KES 0.8000"
+	MessageID  string `json:"messageId"`
+}
 
-	data := map[string]string{
-		"username": s.config.Username,
-		"to":       to,
-		"message":  message,
+// deliver sends one SMS through Africa's Talking
+func (s *Service) deliver(to, message string) (*sendResult, error) {
+	if s.config.APIKey == "" || s.config.Username == "" {
+		return nil, fmt.Errorf("Africa Talking credentials not configured")
 	}
 
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		return "", err
+	form := url.Values{}
+	form.Set("username", s.config.Username)
+	form.Set("to", to)
+	form.Set("message", message)
+	if s.config.From != "" {
+		form.Set("from", s.config.From)
 	}
 
-	req, err := http.NewRequest("POST", s.config.BaseURL+"/version1/messaging", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", s.config.BaseURL+"/version1/messaging", strings.NewReader(form.Encode()))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
 	req.Header.Set("apiKey", s.config.APIKey)
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("SMS send failed: %s", string(body))
+		return nil, fmt.Errorf("SMS send failed: %s", string(body))
 	}
 
-	var result map[string]interface{}
+	var result struct {
+		SMSMessageData struct {
+			Message    string       `json:"Message"`
+			Recipients []sendResult `json:"Recipients"`
+		} `json:"SMSMessageData"`
+	}
 	if err := json.Unmarshal(body, &result); err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// Check for errors in response
-	if recipients, ok := result["recipients"].(map[string]interface{}); ok {
-		if recipient, ok := recipients[to].(map[string]interface{}); ok {
-			if status, ok := recipient["status"].(string); ok {
-				if status == "Success" {
-					return fmt.Sprintf("SMS sent to %s", to), nil
-				}
-				return "", fmt.Errorf("SMS failed: %s", recipient["errorMessage"])
-			}
-		}
+	recipients := result.SMSMessageData.Recipients
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("SMS failed: %s", result.SMSMessageData.Message)
 	}
-
-	return fmt.Sprintf("SMS sent to %s", to), nil
+	if recipients[0].Status != "Success" {
+		return &recipients[0], fmt.Errorf("SMS failed: %s", recipients[0].Status)
+	}
+	return &recipients[0], nil
 }
 
-// SendBulkSMS sends SMS to multiple recipients
-func (s *Service) SendBulkSMS(recipients []string, message string) (map[string]string, error) {
+// SendBulkSMS sends SMS to multiple recipients for a shop
+func (s *Service) SendBulkSMS(shopID uint, recipients []string, message string) (map[string]string, error) {
 	results := make(map[string]string)
 
 	for _, to := range recipients {
-		result, err := s.SendSMS(to, message)
+		sent, err := s.Send(shopID, models.SmsPurposeManual, to, message)
 		if err != nil {
 			results[to] = err.Error()
 		} else {
-			results[to] = result
+			results[to] = fmt.Sprintf("SMS sent to %s", sent.Recipient)
 		}
 		// Rate limiting - sleep between sends
 		time.Sleep(100 * time.Millisecond)
@@ -0,0 +1,117 @@
+package sms
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+)
+
+// Campaign messages go out in batches with a pause in between to stay
+// within Africa's Talking rate limits
+const (
+	defaultCampaignBatchSize  = 50
+	defaultCampaignBatchPause = time.Second
+)
+
+// templateVar matches a {{variable}} in a campaign template
+var templateVar = regexp.MustCompile(`{{\s*(\w+)\s*}}`)
+
+// campaignVars are the variables a campaign template may use
+var campaignVars = map[string]func(c *models.Customer) string{
+	"name":   func(c *models.Customer) string { return c.Name },
+	"points": func(c *models.Customer) string { return strconv.Itoa(c.LoyaltyPoints) },
+	"tier":   func(c *models.Customer) string { return string(c.Tier) },
+}
+
+// SetCampaignRepo enables SMS campaigns
+func (s *Service) SetCampaignRepo(campaigns *repository.SmsCampaignRepository) {
+	s.campaigns = campaigns
+}
+
+// SetCampaignRate sets how many campaign messages are sent per batch and
+// the pause between batches
+func (s *Service) SetCampaignRate(batchSize int, pause time.Duration) {
+	s.batchSize = batchSize
+	s.batchPause = pause
+}
+
+// ValidateTemplate checks a campaign template only uses known variables
+func ValidateTemplate(template string) error {
+	for _, match := range templateVar.FindAllStringSubmatch(template, -1) {
+		if _, ok := campaignVars[match[1]]; !ok {
+			return fmt.Errorf("unknown template variable {{%s}}; use {{name}}, {{points}} or {{tier}}", match[1])
+		}
+	}
+	return nil
+}
+
+// RenderTemplate fills a campaign template in for one customer
+func RenderTemplate(template string, customer *models.Customer) string {
+	return templateVar.ReplaceAllStringFunc(template, func(match string) string {
+		name := templateVar.FindStringSubmatch(match)[1]
+		if value, ok := campaignVars[name]; ok {
+			return value(customer)
+		}
+		return match
+	})
+}
+
+// CampaignRecipients drops customers who opted out of marketing texts and
+// repeats of a phone number, returning who is left and how many opted out
+func CampaignRecipients(customers []models.Customer) ([]models.Customer, int) {
+	recipients := make([]models.Customer, 0, len(customers))
+	seen := make(map[string]bool)
+	optedOut := 0
+	for _, customer := range customers {
+		if customer.SMSOptOut {
+			optedOut++
+			continue
+		}
+		phone := formatPhone(customer.Phone)
+		if seen[phone] {
+			continue
+		}
+		seen[phone] = true
+		recipients = append(recipients, customer)
+	}
+	return recipients, optedOut
+}
+
+// RunCampaign texts the campaign to customers in rate-limited batches, then
+// marks it completed. Each message is logged with the campaign ID.
+func (s *Service) RunCampaign(campaign *models.SmsCampaign, customers []models.Customer) {
+	batchSize, pause := s.batchSize, s.batchPause
+	if batchSize <= 0 {
+		batchSize, pause = defaultCampaignBatchSize, defaultCampaignBatchPause
+	}
+
+	recipients, _ := CampaignRecipients(customers)
+	for i := range recipients {
+		if i > 0 && i%batchSize == 0 {
+			time.Sleep(pause)
+		}
+		customer := &recipients[i]
+		record := &models.SmsMessage{
+			ShopID:     campaign.ShopID,
+			CampaignID: &campaign.ID,
+			Recipient:  formatPhone(customer.Phone),
+			Body:       RenderTemplate(campaign.Template, customer),
+			Purpose:    models.SmsPurposeCampaign,
+		}
+		if err := s.send(record); err != nil {
+			log.Printf("⚠️ Campaign %d: SMS to %s failed: %v", campaign.ID, record.Recipient, err)
+		}
+	}
+
+	if s.campaigns != nil {
+		if err := s.campaigns.Complete(campaign.ID); err != nil {
+			log.Printf("⚠️ Failed to complete SMS campaign %d: %v", campaign.ID, err)
+		}
+	}
+	log.Printf("📣 SMS campaign %d sent to %d customers", campaign.ID, len(recipients))
+}
@@ -0,0 +1,178 @@
+package sms
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/qr"
+	"gorm.io/gorm"
+)
+
+// ErrUnknownMessage is returned for a delivery report about an SMS not in the log
+var ErrUnknownMessage = errors.New("unknown SMS message")
+
+// SetMessageRepo enables the SMS log; every send is recorded
+func (s *Service) SetMessageRepo(repo *repository.SmsMessageRepository) {
+	s.repo = repo
+}
+
+// SetReceiptLinker adds digital receipt links to SMS receipts
+func (s *Service) SetReceiptLinker(linker *qr.ReceiptLinker) {
+	s.linker = linker
+}
+
+// SetOutbox queues receipts and low stock alerts with queue, which sends
+// them with Send and retries failures, instead of sending them straight
+// away
+func (s *Service) SetOutbox(queue func(shopID uint, purpose models.SmsPurpose, to, message string) error) {
+	s.queue = queue
+}
+
+// sendOrQueue sends an SMS for a shop, through the outbox when there is
+// one; the message is returned only when it was sent straight away
+func (s *Service) sendOrQueue(shopID uint, purpose models.SmsPurpose, to, message string) (*models.SmsMessage, error) {
+	if s.queue != nil {
+		return nil, s.queue(shopID, purpose, to, message)
+	}
+	return s.Send(shopID, purpose, to, message)
+}
+
+// Send sends an SMS on behalf of a shop and records it in the message log,
+// whether or not the provider accepted it
+func (s *Service) Send(shopID uint, purpose models.SmsPurpose, to, message string) (*models.SmsMessage, error) {
+	record := &models.SmsMessage{
+		ShopID:    shopID,
+		Recipient: formatPhone(to),
+		Body:      message,
+		Purpose:   purpose,
+	}
+	return record, s.send(record)
+}
+
+// send delivers record and logs it with the outcome
+func (s *Service) send(record *models.SmsMessage) error {
+	record.Status = models.SmsStatusSent
+	result, err := s.deliver(record.Recipient, record.Body)
+	if result != nil {
+		if result.MessageID != "None" {
+			record.ProviderID = result.MessageID
+		}
+		record.Currency, record.Cost = parseCost(result.Cost)
+	}
+	if err != nil {
+		record.Status = models.SmsStatusFailed
+		record.FailureReason = truncate(err.Error(), 255)
+	}
+	if record.Purpose == models.SmsPurposeOTP {
+		record.Body = ""
+	}
+
+	if s.repo != nil {
+		if logErr := s.repo.Create(record); logErr != nil {
+			log.Printf("⚠️ Failed to log SMS to %s: %v", record.Recipient, logErr)
+		}
+	}
+	return err
+}
+
+// HandleDeliveryReport records an Africa's Talking delivery report. Interim
+// statuses (Sent, Submitted, Buffered) leave the message as sent.
+func (s *Service) HandleDeliveryReport(providerID, status, failureReason string) error {
+	if s.repo == nil || providerID == "" {
+		return ErrUnknownMessage
+	}
+
+	var next models.SmsStatus
+	switch status {
+	case "Success":
+		next = models.SmsStatusDelivered
+	case "Failed", "Rejected", "AbsentSubscriber", "Expired":
+		next = models.SmsStatusUndelivered
+		if failureReason == "" {
+			failureReason = status
+		}
+	default:
+		next = models.SmsStatusSent
+	}
+
+	err := s.repo.UpdateDelivery(providerID, next, failureReason)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrUnknownMessage
+	}
+	return err
+}
+
+// SendReceipt texts a customer a receipt for sales paid together. Nothing
+// is sent unless the shop has SMS receipts on.
+func (s *Service) SendReceipt(shop *models.Shop, phone string, sales []models.Sale) (*models.SmsMessage, error) {
+	if !shop.SMSReceipts || phone == "" || len(sales) == 0 {
+		return nil, nil
+	}
+
+	shopName := shop.Name
+	if shop.BrandName != "" {
+		shopName = shop.BrandName
+	}
+
+	total := 0.0
+	for _, sale := range sales {
+		total += sale.TotalAmount
+	}
+	payment := string(sales[0].PaymentMethod)
+	if sales[0].MpesaReceipt != "" {
+		payment = "M-Pesa " + sales[0].MpesaReceipt
+	}
+
+	message := fmt.Sprintf("%s: Thank you! KSh %.0f paid by %s on %s.",
+		shopName, total, payment, sales[0].CreatedAt.Format("02 Jan 15:04"))
+	if s.linker != nil {
+		message += " Receipt: " + s.linker.URL(sales[0].ID)
+	}
+	return s.sendOrQueue(shop.ID, models.SmsPurposeReceipt, phone, message)
+}
+
+// SendLowStockAlert texts the shop owner the products running low
+func (s *Service) SendLowStockAlert(shop *models.Shop, products []models.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("LOW STOCK - " + shop.Name + "\n")
+	for _, p := range products {
+		if p.CurrentStock < 0 {
+			sb.WriteString(fmt.Sprintf("%s: %d (sold past zero)\n", p.Name, p.CurrentStock))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%s: %d (min %d)\n", p.Name, p.CurrentStock, p.LowStockLevel()))
+	}
+	sb.WriteString("Restock on WhatsApp: add [name] [price] [qty]")
+
+	_, err := s.sendOrQueue(shop.ID, models.SmsPurposeLowStock, shop.Phone, sb.String())
+	return err
+}
+
+// parseCost splits a cost such as "KES 0.8000" into currency and amount
+func parseCost(cost string) (string, float64) {
+	fields := strings.Fields(cost)
+	if len(fields) != 2 {
+		return "", 0
+	}
+	amount, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return "", 0
+	}
+	return fields[0], amount
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
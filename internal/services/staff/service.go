@@ -11,10 +11,11 @@ import (
 )
 
 var (
-	ErrStaffNotFound  = errors.New("staff not found")
-	ErrStaffExists    = errors.New("staff already exists")
-	ErrInvalidPin     = errors.New("invalid PIN")
-	ErrStaffInactive  = errors.New("staff account is inactive")
+	ErrStaffNotFound     = errors.New("staff not found")
+	ErrStaffExists       = errors.New("staff already exists")
+	ErrInvalidPin        = errors.New("invalid PIN")
+	ErrStaffInactive     = errors.New("staff account is inactive")
+	ErrStaffLimitReached = errors.New("staff limit reached for your plan")
 )
 
 // Service handles staff management
@@ -34,7 +35,7 @@ func New(staffRepo *repository.StaffRepository, shopRepo *repository.ShopReposit
 // Create creates a new staff member
 func (s *Service) Create(shopID uint, name, phone, role, pin string) (*models.Staff, error) {
 	// Check if shop exists
-	_, err := s.shopRepo.GetByID(shopID)
+	shop, err := s.shopRepo.GetByID(shopID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("shop not found")
@@ -42,6 +43,15 @@ func (s *Service) Create(shopID uint, name, phone, role, pin string) (*models.St
 		return nil, err
 	}
 
+	// Enforce the plan's staff limit
+	count, err := s.staffRepo.CountByShop(shopID)
+	if err != nil {
+		return nil, err
+	}
+	if !models.LimitsFor(shop.Plan).CanAddStaff(count, 1) {
+		return nil, ErrStaffLimitReached
+	}
+
 	// Check if staff with same phone exists
 	existing, _ := s.staffRepo.GetByPhone(shopID, phone)
 	if existing != nil {
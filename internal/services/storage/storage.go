@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// Store saves generated files such as invoice PDFs. Keys are slash
+// separated paths, e.g. "invoices/12/INV-2026-000034.pdf".
+type Store interface {
+	// Save writes data under key and returns where it was stored, a file
+	// path or an object URL depending on the store
+	Save(key string, data []byte) (string, error)
+	// Open reads the data saved under key
+	Open(key string) ([]byte, error)
+	// Delete removes the data saved under key; a missing key is not an error
+	Delete(key string) error
+}
+
+// StreamStore is a Store that can also write and read files as streams, for
+// files too large to hold in memory such as background exports
+type StreamStore interface {
+	Store
+	// SaveStream writes everything read from r under key. The file only
+	// appears under key once it is complete.
+	SaveStream(key string, r io.Reader) (string, error)
+	// OpenStream opens the data saved under key for reading, with its size
+	OpenStream(key string) (io.ReadCloser, int64, error)
+}
+
+// LocalStore keeps files on the local disk under a root directory. Several
+// servers can share one on a network volume.
+type LocalStore struct {
+	root string
+}
+
+// NewLocalStore creates a store writing under root
+func NewLocalStore(root string) *LocalStore {
+	return &LocalStore{root: root}
+}
+
+func (s *LocalStore) Save(key string, data []byte) (string, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func (s *LocalStore) Open(key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+func (s *LocalStore) SaveStream(key string, r io.Reader) (string, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return path, nil
+}
+
+func (s *LocalStore) OpenStream(key string) (io.ReadCloser, int64, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, info.Size(), nil
+}
+
+func (s *LocalStore) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// path maps a key to a file under root, rejecting keys that escape it
+func (s *LocalStore) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if key == "" || clean == "/" || clean != "/"+key {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.root, filepath.FromSlash(clean)), nil
+}
@@ -0,0 +1,289 @@
+package stripe
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	webhooksvc "github.com/C9b3rD3vi1/DukaPOS/internal/services/webhook"
+)
+
+const (
+	defaultBaseURL  = "https://api.stripe.com"
+	defaultCurrency = "kes"
+
+	// SignatureTolerance is how old a webhook's signed timestamp may be
+	SignatureTolerance = 5 * time.Minute
+)
+
+var (
+	ErrNotConfigured      = errors.New("stripe is not configured")
+	ErrInvalidSignature   = errors.New("invalid Stripe-Signature")
+	ErrProductUnavailable = errors.New("product not available")
+	ErrInvalidQuantity    = errors.New("quantity must be at least 1")
+	ErrPaymentNotFound    = errors.New("stripe payment not found")
+	ErrAmountMismatch     = errors.New("amount received does not match the order")
+
+	// Prices are in KES, so charging them in another currency would take
+	// the same number of dollars or euros
+	ErrUnsupportedCurrency = errors.New("only KES payments are supported")
+)
+
+// Config holds Stripe configuration
+type Config struct {
+	SecretKey     string
+	WebhookSecret string // signs webhook events; whsec_...
+	BaseURL       string // overrides the Stripe host, e.g. for a mock server
+}
+
+// PaymentIntentResponse is what Stripe returns for a new PaymentIntent. The
+// client secret lets the shop's checkout page confirm the card payment.
+type PaymentIntentResponse struct {
+	ID           string `json:"id"`
+	ClientSecret string `json:"client_secret"`
+	Amount       int64  `json:"amount"` // in cents
+	Currency     string `json:"currency"`
+	Status       string `json:"status"`
+}
+
+// StripeService takes card payments for online orders through Stripe
+type StripeService struct {
+	config      *Config
+	client      *http.Client
+	paymentRepo *repository.StripePaymentRepository
+	productRepo *repository.ProductRepository
+}
+
+// New creates a new Stripe service
+func New(config *Config, paymentRepo *repository.StripePaymentRepository, productRepo *repository.ProductRepository) *StripeService {
+	if config.BaseURL == "" {
+		config.BaseURL = defaultBaseURL
+	}
+	return &StripeService{
+		config:      config,
+		client:      &http.Client{Timeout: 30 * time.Second},
+		paymentRepo: paymentRepo,
+		productRepo: productRepo,
+	}
+}
+
+// CreatePaymentIntent asks Stripe for a PaymentIntent for amount in
+// currency, tagged with the shop so the webhook can find it. Only KES is
+// accepted.
+func (s *StripeService) CreatePaymentIntent(shopID uint, amount float64, currency, description string) (*PaymentIntentResponse, error) {
+	if s.config.SecretKey == "" {
+		return nil, ErrNotConfigured
+	}
+	currency = strings.ToLower(strings.TrimSpace(currency))
+	if currency == "" {
+		currency = defaultCurrency
+	}
+	if currency != defaultCurrency {
+		return nil, fmt.Errorf("%w, not %s", ErrUnsupportedCurrency, strings.ToUpper(currency))
+	}
+	minor := toMinorUnits(amount)
+	if minor <= 0 {
+		return nil, fmt.Errorf("amount must be greater than zero")
+	}
+
+	form := url.Values{}
+	form.Set("amount", strconv.FormatInt(minor, 10))
+	form.Set("currency", currency)
+	form.Set("description", description)
+	form.Set("payment_method_types[]", "card")
+	form.Set("metadata[shop_id]", strconv.FormatUint(uint64(shopID), 10))
+
+	req, err := http.NewRequest("POST", s.config.BaseURL+"/v1/payment_intents", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(s.config.SecretKey, "")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("stripe request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.Unmarshal(body, &apiErr)
+		return nil, fmt.Errorf("stripe error (%d): %s", resp.StatusCode, apiErr.Error.Message)
+	}
+
+	var intent PaymentIntentResponse
+	if err := json.Unmarshal(body, &intent); err != nil {
+		return nil, fmt.Errorf("invalid stripe response: %w", err)
+	}
+	return &intent, nil
+}
+
+// Checkout prices an online order for a product at its selling price,
+// creates the PaymentIntent and records the pending payment
+func (s *StripeService) Checkout(shopID, productID uint, quantity int, currency string) (*models.StripePayment, *PaymentIntentResponse, error) {
+	if quantity < 1 {
+		return nil, nil, ErrInvalidQuantity
+	}
+	product, err := s.productRepo.GetByID(productID)
+	if err != nil || product.ShopID != shopID || !product.IsActive {
+		return nil, nil, ErrProductUnavailable
+	}
+	if product.CurrentStock < quantity {
+		return nil, nil, fmt.Errorf("not enough %s in stock (%d left)", product.Name, product.CurrentStock)
+	}
+
+	amount := product.SellingPrice * float64(quantity)
+	description := fmt.Sprintf("%d x %s", quantity, product.Name)
+	intent, err := s.CreatePaymentIntent(shopID, amount, currency, description)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	payment := &models.StripePayment{
+		ShopID:          shopID,
+		PaymentIntentID: intent.ID,
+		ProductID:       product.ID,
+		Quantity:        quantity,
+		Amount:          amount,
+		Currency:        intent.Currency,
+		Description:     description,
+		Status:          models.StripePaymentPending,
+	}
+	if err := s.paymentRepo.Create(payment); err != nil {
+		return nil, nil, err
+	}
+	return payment, intent, nil
+}
+
+// webhookEvent is the part of a Stripe event the webhook uses
+type webhookEvent struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Object struct {
+			ID               string `json:"id"`
+			AmountReceived   int64  `json:"amount_received"`
+			Currency         string `json:"currency"`
+			LastPaymentError *struct {
+				Message string `json:"message"`
+			} `json:"last_payment_error"`
+		} `json:"object"`
+	} `json:"data"`
+}
+
+// HandleWebhook verifies a Stripe event and settles the payment it is
+// about: a succeeded PaymentIntent completes the payment and records the
+// sale, a failed one marks it failed. Other events return a nil payment.
+func (s *StripeService) HandleWebhook(payload []byte, signature string) (*models.StripePayment, error) {
+	if err := VerifySignature(payload, signature, s.config.WebhookSecret, time.Now()); err != nil {
+		return nil, err
+	}
+
+	var event webhookEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("invalid event: %w", err)
+	}
+	if event.Type != "payment_intent.succeeded" && event.Type != "payment_intent.payment_failed" {
+		return nil, nil
+	}
+
+	intent := event.Data.Object
+	payment, err := s.paymentRepo.GetByPaymentIntentID(intent.ID)
+	if err != nil {
+		return nil, ErrPaymentNotFound
+	}
+
+	if event.Type == "payment_intent.payment_failed" {
+		reason := "payment failed"
+		if intent.LastPaymentError != nil && intent.LastPaymentError.Message != "" {
+			reason = intent.LastPaymentError.Message
+		}
+		if err := s.paymentRepo.Fail(payment, reason); err != nil {
+			return payment, err
+		}
+		log.Printf("❌ Stripe payment %d failed: %s", payment.ID, reason)
+		return payment, nil
+	}
+
+	if intent.AmountReceived != toMinorUnits(payment.Amount) || !strings.EqualFold(intent.Currency, payment.Currency) {
+		return payment, ErrAmountMismatch
+	}
+	sale, err := s.paymentRepo.Complete(payment)
+	if err != nil {
+		return payment, err
+	}
+	if s.productRepo != nil {
+		soldOut, _ := s.productRepo.DeactivateIfOutOfStock(sale.ProductID)
+		webhooksvc.TriggerProductDeactivated(soldOut, "out_of_stock")
+	}
+	log.Printf("✅ Stripe payment %d completed: sale %d, %s %.2f", payment.ID, sale.ID, strings.ToUpper(payment.Currency), payment.Amount)
+	return payment, nil
+}
+
+// VerifySignature checks a Stripe-Signature header ("t=...,v1=...")
+// against the payload signed with the webhook secret, rejecting
+// timestamps outside SignatureTolerance
+func VerifySignature(payload []byte, header, secret string, now time.Time) error {
+	if secret == "" {
+		return ErrNotConfigured
+	}
+
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > SignatureTolerance || age < -SignatureTolerance {
+		return ErrInvalidSignature
+	}
+
+	expected := Sign(payload, secret, ts)
+	for _, sig := range signatures {
+		if hmac.Equal([]byte(sig), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// Sign returns the v1 signature Stripe sends for a payload at timestamp
+func Sign(payload []byte, secret string, timestamp int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// toMinorUnits converts a KES amount to cents
+func toMinorUnits(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
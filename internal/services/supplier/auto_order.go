@@ -0,0 +1,108 @@
+package supplier
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"gorm.io/gorm"
+)
+
+// Predictor recommends how much of a product to reorder
+type Predictor interface {
+	RecommendedOrder(product *models.Product) int
+}
+
+// AutoOrderService drafts purchase orders for products running low
+type AutoOrderService struct {
+	linkRepo  *repository.SupplierProductRepository
+	orderRepo *repository.OrderRepository
+	predictor Predictor
+}
+
+// NewAutoOrderService creates a new auto order service
+func NewAutoOrderService(linkRepo *repository.SupplierProductRepository, orderRepo *repository.OrderRepository, predictor Predictor) *AutoOrderService {
+	return &AutoOrderService{
+		linkRepo:  linkRepo,
+		orderRepo: orderRepo,
+		predictor: predictor,
+	}
+}
+
+// CreateDraftOrders drafts an order from the cheapest supplier for each low
+// stock product that has one and no open order yet. It returns a WhatsApp
+// notice per draft. Free plan shops get none.
+func (s *AutoOrderService) CreateDraftOrders(shop *models.Shop, products []models.Product) ([]string, error) {
+	if shop.Plan == models.PlanFree {
+		return nil, nil
+	}
+
+	var notices []string
+	for i := range products {
+		product := &products[i]
+
+		link, err := s.linkRepo.GetCheapest(product.ID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return notices, err
+		}
+		if open, err := s.orderRepo.HasOpenOrderFor(product.ID); err != nil || open {
+			continue
+		}
+
+		qty := s.quantity(product, link)
+		order := &models.Order{
+			ShopID:      shop.ID,
+			SupplierID:  link.SupplierID,
+			Status:      models.OrderStatusDraft,
+			TotalAmount: float64(qty) * link.UnitCost,
+			Notes:       "Auto PO: low stock",
+		}
+		if err := s.orderRepo.Create(order); err != nil {
+			return notices, err
+		}
+		item := &models.OrderItem{
+			OrderID:   order.ID,
+			ProductID: product.ID,
+			Quantity:  qty,
+			UnitCost:  link.UnitCost,
+			TotalCost: order.TotalAmount,
+		}
+		if err := s.orderRepo.CreateItem(item); err != nil {
+			return notices, err
+		}
+
+		log.Printf("📋 Auto PO #%d: %s x%d from %s for shop %d", order.ID, product.Name, qty, link.Supplier.Name, shop.ID)
+		notices = append(notices, fmt.Sprintf("Auto PO created: %s x%d from %s. Reply `order confirm %d` to send.",
+			product.Name, qty, link.Supplier.Name, order.ID))
+	}
+	return notices, nil
+}
+
+// quantity is the AI recommendation or, when it has none (too little sales
+// history, or stock that already covers the week), what brings stock back
+// to twice the alert level. It is at least the supplier's minimum and
+// rounds up to whole purchase units such as crates.
+func (s *AutoOrderService) quantity(product *models.Product, link *models.SupplierProduct) int {
+	qty := 0
+	if s.predictor != nil {
+		qty = s.predictor.RecommendedOrder(product)
+	}
+	if qty <= 0 {
+		qty = 2*product.LowStockLevel() - product.CurrentStock
+	}
+	if qty < link.MinOrderQty {
+		qty = link.MinOrderQty
+	}
+	if qty < 1 {
+		qty = 1
+	}
+	if per := product.UnitsPerPurchase; product.PurchaseUnit != "" && per > 1 && qty%per != 0 {
+		qty += per - qty%per
+	}
+	return qty
+}
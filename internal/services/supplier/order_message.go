@@ -0,0 +1,24 @@
+package supplier
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+)
+
+// OrderMessage is the WhatsApp message that sends an order to its
+// supplier: the items, the total and when the shop expects delivery
+func OrderMessage(shop *models.Shop, order *models.Order) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📋 ORDER #%d from %s\n\n", order.ID, shop.Name))
+	for _, item := range order.Items {
+		sb.WriteString(fmt.Sprintf("• %s x%d\n", item.Product.Name, item.Quantity))
+	}
+	sb.WriteString(fmt.Sprintf("\nTotal: KSh %.0f\n", order.TotalAmount))
+	if order.ExpectedDelivery != nil {
+		sb.WriteString(fmt.Sprintf("Deliver by: %s\n", order.ExpectedDelivery.Format("Jan 2, 2006")))
+	}
+	sb.WriteString(fmt.Sprintf("Call %s to arrange delivery.", shop.Phone))
+	return sb.String()
+}
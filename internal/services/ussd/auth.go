@@ -0,0 +1,212 @@
+package ussd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Registration, PIN entry and PIN change states
+const (
+	StateRegName       = "reg_name"
+	StateRegOwner      = "reg_owner"
+	StateRegPin        = "reg_pin"
+	StateRegPinConfirm = "reg_pin_confirm"
+	StatePin           = "pin"
+	StatePinOld        = "pin_change_old"
+	StatePinChange     = "pin_change_new"
+	StatePinConfirm    = "pin_change_confirm"
+)
+
+// authStates are the states that take typed input rather than menu choices
+var authStates = map[string]bool{
+	StateRegName: true, StateRegOwner: true, StateRegPin: true, StateRegPinConfirm: true,
+	StatePin: true, StatePinOld: true, StatePinChange: true, StatePinConfirm: true,
+}
+
+// prompt asks for typed input and keeps the session open
+func (s *Service) prompt(session *Session, msg string) *Response {
+	return &Response{
+		SessionID: session.ID,
+		Message:   msg,
+		FreeFlow:  "FC",
+	}
+}
+
+// startSession links a new session to the caller's shop. Unknown numbers
+// can register a shop; known ones enter their PIN before anything else.
+// A shop's first PIN is only ever set from the authenticated dashboard, as
+// anyone who can reach USSD with the shop's number could otherwise set it.
+func (s *Service) startSession(session *Session) *Response {
+	if s.shopRepo == nil {
+		return s.showMenu(session.State)
+	}
+
+	shop, err := s.shopRepo.GetByPhone(session.Phone)
+	if err != nil {
+		session.State = StateRegName
+		return s.prompt(session, "🏪 Welcome to DukaPOS!\n\nRegister your shop.\nEnter shop name:")
+	}
+	if !shop.IsActive {
+		return s.end(session, "❌ This shop is deactivated. Contact support.")
+	}
+	if shop.USSDPinLocked(time.Now()) {
+		return s.lockedOut(session, shop)
+	}
+
+	session.ShopID = shop.ID
+	if !shop.HasUSSDPin() {
+		return s.end(session, fmt.Sprintf("🔒 %s\n\nSet your USSD PIN in the DukaPOS dashboard first, then dial again.", shop.Name))
+	}
+	session.State = StatePin
+	return s.prompt(session, fmt.Sprintf("🔒 %s\n\nEnter your PIN:", shop.Name))
+}
+
+// handleAuth handles input in the registration and PIN states
+func (s *Service) handleAuth(session *Session, input string) *Response {
+	if s.shopRepo == nil {
+		session.State = StateMain
+		return s.showMenu(StateMain)
+	}
+
+	switch session.State {
+	case StateRegName:
+		name := strings.TrimSpace(input)
+		if len(name) < 2 || len(name) > 100 {
+			return s.prompt(session, "❌ Enter a shop name of 2 to 100 characters:")
+		}
+		session.Data["shop_name"] = name
+		session.State = StateRegOwner
+		return s.prompt(session, "Enter owner's name:")
+
+	case StateRegOwner:
+		owner := strings.TrimSpace(input)
+		if len(owner) < 2 || len(owner) > 100 {
+			return s.prompt(session, "❌ Enter a name of 2 to 100 characters:")
+		}
+		session.Data["owner_name"] = owner
+		session.State = StateRegPin
+		return s.prompt(session, fmt.Sprintf("Choose a %d-digit PIN:", models.USSDPinLength))
+
+	case StateRegPin, StatePinChange:
+		if err := models.ValidateUSSDPin(input); err != nil {
+			return s.prompt(session, fmt.Sprintf("❌ The PIN must be %d digits.\nChoose a PIN:", models.USSDPinLength))
+		}
+		// Only a hash waits in the session store for the confirmation
+		hash, err := bcrypt.GenerateFromPassword([]byte(input), bcrypt.MinCost)
+		if err != nil {
+			return s.end(session, "❌ Could not set the PIN. Please try again.")
+		}
+		session.Data["pin_hash"] = string(hash)
+		session.State = map[string]string{
+			StateRegPin:    StateRegPinConfirm,
+			StatePinChange: StatePinConfirm,
+		}[session.State]
+		return s.prompt(session, "Enter the PIN again to confirm:")
+
+	case StateRegPinConfirm, StatePinConfirm:
+		if bcrypt.CompareHashAndPassword([]byte(session.Data["pin_hash"]), []byte(input)) != nil {
+			delete(session.Data, "pin_hash")
+			session.State = map[string]string{
+				StateRegPinConfirm: StateRegPin,
+				StatePinConfirm:    StatePinChange,
+			}[session.State]
+			return s.prompt(session, "❌ PINs did not match.\nChoose a PIN:")
+		}
+		if session.State == StateRegPinConfirm {
+			return s.register(session, input)
+		}
+		return s.savePin(session, input)
+
+	case StatePin:
+		return s.checkPin(session, input, StateMain)
+
+	case StatePinOld:
+		return s.checkPin(session, input, StatePinChange)
+	}
+	return s.showMenu(StateMain)
+}
+
+// register creates the shop once its PIN is confirmed
+func (s *Service) register(session *Session, pin string) *Response {
+	shop := &models.Shop{
+		Name:      session.Data["shop_name"],
+		Phone:     session.Phone,
+		OwnerName: session.Data["owner_name"],
+		Plan:      models.PlanFree,
+		IsActive:  true,
+	}
+	if err := shop.SetUSSDPin(pin); err != nil {
+		return s.end(session, "❌ Registration failed. Please try again.")
+	}
+	if err := s.shopRepo.Create(shop); err != nil {
+		log.Printf("❌ USSD registration failed for %s: %v", session.Phone, err)
+		return s.end(session, "❌ Registration failed. Please try again.")
+	}
+
+	session.ShopID = shop.ID
+	session.State = StateMain
+	session.Data = make(map[string]string)
+	resp := s.showMenu(StateMain)
+	resp.Message = "✅ Shop registered!\n\n" + resp.Message
+	return resp
+}
+
+// savePin changes the shop's PIN to the confirmed entry
+func (s *Service) savePin(session *Session, pin string) *Response {
+	shop, err := s.shopRepo.GetByID(session.ShopID)
+	if err != nil {
+		return s.end(session, "❌ Shop not found.")
+	}
+	if err := shop.SetUSSDPin(pin); err != nil {
+		return s.end(session, "❌ Could not set the PIN. Please try again.")
+	}
+	if err := s.shopRepo.Update(shop); err != nil {
+		log.Printf("❌ Failed to save USSD PIN for shop %d: %v", shop.ID, err)
+		return s.end(session, "❌ Could not set the PIN. Please try again.")
+	}
+
+	session.Data = make(map[string]string)
+	return s.end(session, "✅ PIN changed.")
+}
+
+// checkPin verifies the PIN and moves on to next, ending the session once
+// too many wrong PINs lock the shop out
+func (s *Service) checkPin(session *Session, pin, next string) *Response {
+	shop, err := s.shopRepo.GetByID(session.ShopID)
+	if err != nil {
+		return s.end(session, "❌ Shop not found.")
+	}
+	now := time.Now()
+	if shop.USSDPinLocked(now) {
+		return s.lockedOut(session, shop)
+	}
+
+	ok := shop.CheckUSSDPin(pin, now)
+	if err := s.shopRepo.Update(shop); err != nil {
+		log.Printf("❌ Failed to record USSD PIN attempt for shop %d: %v", shop.ID, err)
+	}
+	if !ok {
+		if shop.USSDPinLocked(now) {
+			return s.lockedOut(session, shop)
+		}
+		left := models.MaxUSSDPinFailures - shop.USSDPinFailures
+		return s.prompt(session, fmt.Sprintf("❌ Wrong PIN. %d attempt(s) left.\n\nEnter your PIN:", left))
+	}
+
+	session.State = next
+	if next == StatePinChange {
+		return s.prompt(session, fmt.Sprintf("Enter a new %d-digit PIN:", models.USSDPinLength))
+	}
+	return s.showMenu(next)
+}
+
+// lockedOut ends the session while the shop is locked out
+func (s *Service) lockedOut(session *Session, shop *models.Shop) *Response {
+	wait := int(time.Until(*shop.USSDPinLockedUntil).Minutes()) + 1
+	return s.end(session, fmt.Sprintf("🔒 Too many wrong PINs. Try again in %d minutes.\n\nReset your PIN from the DukaPOS dashboard.", wait))
+}
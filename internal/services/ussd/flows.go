@@ -0,0 +1,344 @@
+package ussd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	webhooksvc "github.com/C9b3rD3vi1/DukaPOS/internal/services/webhook"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/websocket"
+)
+
+// sellFlow reports whether the session is selling rather than restocking
+func sellFlow(session *Session) bool {
+	switch session.State {
+	case StateSellPick, StateSellSearch, StateSellQty, StateSellConfirm:
+		return true
+	}
+	return false
+}
+
+// startPick opens the product picker for a sale or restock
+func (s *Service) startPick(session *Session, state string) *Response {
+	session.State = state
+	session.Data = map[string]string{"page": "0"}
+	return s.showPick(session)
+}
+
+// pickable lists the products offered in the picker, narrowed by any
+// search. Sales only offer products in stock; bundles are sold from their
+// components' stock and can't be restocked, so they are left out of both.
+func (s *Service) pickable(session *Session) []models.Product {
+	if s.productRepo == nil {
+		return nil
+	}
+	products, err := s.productRepo.GetByShopID(session.ShopID)
+	if err != nil {
+		return nil
+	}
+
+	selling := sellFlow(session)
+	filter := session.Data["filter"]
+	list := make([]models.Product, 0, len(products))
+	for _, p := range products {
+		if p.IsBundle || (selling && p.CurrentStock <= 0) || !matchesFilter(p.Name, filter) {
+			continue
+		}
+		list = append(list, p)
+	}
+	return list
+}
+
+// pickScreen lays out the picker for products
+func pickScreen(session *Session, products []models.Product) *listScreen {
+	title := "💰 SELL - Choose product"
+	if !sellFlow(session) {
+		title = "📦 ADD STOCK - Choose product"
+	}
+	if filter := session.Data["filter"]; filter != "" {
+		title += " (" + filter + ")"
+	}
+
+	items := make([]string, len(products))
+	for i, p := range products {
+		items[i] = fmt.Sprintf("%s (%d) %.0f", clip(p.Name, 16), p.CurrentStock, p.SellingPrice)
+	}
+	return &listScreen{title: title, items: items, numbered: true, search: true, back: "Back"}
+}
+
+// showPick lists the current page of products
+func (s *Service) showPick(session *Session) *Response {
+	products := s.pickable(session)
+	if len(products) == 0 {
+		if filter := session.Data["filter"]; filter != "" {
+			return s.prompt(session, fmt.Sprintf("🔍 No products starting with %q.\n\n%s. Search again\n%s. Back", filter, optSearch, optBack))
+		}
+		session.State = StateInfo
+		return &Response{
+			SessionID: session.ID,
+			Message:   "📦 No products to choose from.\n\nAdd products on WhatsApp first.\n\n0. Main menu",
+			FreeFlow:  "FC",
+		}
+	}
+
+	msg, shown, _, _ := pickScreen(session, products).render(sessionPage(session))
+	session.Data["page"] = strconv.Itoa(shown)
+	return &Response{
+		SessionID: session.ID,
+		Message:   msg,
+		FreeFlow:  "FC",
+	}
+}
+
+// handlePick handles a choice in the product picker
+func (s *Service) handlePick(session *Session, input string) *Response {
+	switch input {
+	case optBack:
+		// Back out of a search first, then out of the picker
+		if session.Data["filter"] != "" {
+			delete(session.Data, "filter")
+			session.Data["page"] = "0"
+			return s.showPick(session)
+		}
+		session.State = StateMain
+		session.Data = make(map[string]string)
+		return s.showMenu(StateMain)
+	case optMore:
+		session.Data["page"] = strconv.Itoa(sessionPage(session) + 1)
+		return s.showPick(session)
+	case optSearch:
+		if sellFlow(session) {
+			session.State = StateSellSearch
+		} else {
+			session.State = StateRestockSearch
+		}
+		return s.askSearch(session)
+	}
+
+	products := s.pickable(session)
+	start, end, _ := pickScreen(session, products).pageBounds(sessionPage(session))
+	n, err := strconv.Atoi(input)
+	i := start + n - 1
+	if err != nil || n < 1 || i >= end {
+		return s.showPick(session)
+	}
+
+	product := products[i]
+	session.Data["product_id"] = strconv.FormatUint(uint64(product.ID), 10)
+	if sellFlow(session) {
+		session.State = StateSellQty
+	} else {
+		session.State = StateRestockQty
+	}
+	return s.askQuantity(session, &product, "")
+}
+
+// askSearch asks for the first letters to filter a list by
+func (s *Service) askSearch(session *Session) *Response {
+	return s.prompt(session, "🔍 Enter the first letters of the product:\n\n"+optBack+". Back")
+}
+
+// searchFilter reads a search answer; ok is false when the user backed out
+func searchFilter(input string) (filter string, ok bool) {
+	input = strings.TrimSpace(input)
+	if input == "" || input == optBack {
+		return "", false
+	}
+	return clip(input, 20), true
+}
+
+// handlePickSearch filters the picker by the letters entered
+func (s *Service) handlePickSearch(session *Session, input string) *Response {
+	if sellFlow(session) {
+		session.State = StateSellPick
+	} else {
+		session.State = StateRestockPick
+	}
+	if filter, ok := searchFilter(input); ok {
+		session.Data["filter"] = filter
+		session.Data["page"] = "0"
+	}
+	return s.showPick(session)
+}
+
+// askQuantity asks how many units to sell or add
+func (s *Service) askQuantity(session *Session, product *models.Product, problem string) *Response {
+	msg := fmt.Sprintf("%s\nIn stock: %d %s\n\nEnter quantity:\n\n0. Back", product.Name, product.CurrentStock, product.Unit)
+	if problem != "" {
+		msg = problem + "\n\n" + msg
+	}
+	return &Response{
+		SessionID: session.ID,
+		Message:   msg,
+		FreeFlow:  "FC",
+	}
+}
+
+// sessionProduct loads the product chosen earlier in the session
+func (s *Service) sessionProduct(session *Session) (*models.Product, error) {
+	id, err := strconv.ParseUint(session.Data["product_id"], 10, 64)
+	if err != nil || s.productRepo == nil {
+		return nil, errors.New("no product chosen")
+	}
+	product, err := s.productRepo.GetByID(uint(id))
+	if err != nil || product.ShopID != session.ShopID {
+		return nil, errors.New("product not found")
+	}
+	return product, nil
+}
+
+// handleQuantity validates the quantity and asks for confirmation
+func (s *Service) handleQuantity(session *Session, input string) *Response {
+	selling := sellFlow(session)
+	if input == optBack {
+		if selling {
+			session.State = StateSellPick
+		} else {
+			session.State = StateRestockPick
+		}
+		return s.showPick(session)
+	}
+
+	product, err := s.sessionProduct(session)
+	if err != nil {
+		return s.restart(session)
+	}
+
+	qty, err := strconv.Atoi(input)
+	if err != nil || qty <= 0 || qty > 99999 {
+		return s.askQuantity(session, product, "❌ Enter a number from 1 to 99999.")
+	}
+	if selling && qty > product.CurrentStock {
+		return s.askQuantity(session, product, fmt.Sprintf("❌ Only %d %s in stock.", product.CurrentStock, product.Unit))
+	}
+	session.Data["qty"] = strconv.Itoa(qty)
+
+	var msg string
+	if selling {
+		session.State = StateSellConfirm
+		msg = fmt.Sprintf("Sell %d x %s\nTotal: KSh %.0f\n\n1. Confirm\n0. Cancel",
+			qty, product.Name, product.SellingPrice*float64(qty))
+	} else {
+		session.State = StateRestockConfirm
+		msg = fmt.Sprintf("Add %d %s to %s\nNew stock: %d\n\n1. Confirm\n0. Cancel",
+			qty, product.Unit, product.Name, product.CurrentStock+qty)
+	}
+	return &Response{
+		SessionID: session.ID,
+		Message:   msg,
+		FreeFlow:  "FC",
+	}
+}
+
+// handleConfirm records the sale or restock once confirmed
+func (s *Service) handleConfirm(session *Session, input string) *Response {
+	switch input {
+	case "1":
+	case optBack:
+		session.State = StateMain
+		session.Data = make(map[string]string)
+		return s.showMenu(StateMain)
+	default:
+		// Ask again with the same summary
+		if sellFlow(session) {
+			session.State = StateSellQty
+		} else {
+			session.State = StateRestockQty
+		}
+		return s.handleQuantity(session, session.Data["qty"])
+	}
+
+	product, err := s.sessionProduct(session)
+	if err != nil {
+		return s.restart(session)
+	}
+	qty, _ := strconv.Atoi(session.Data["qty"])
+
+	if sellFlow(session) {
+		return s.completeSale(session, product, qty)
+	}
+	return s.completeRestock(session, product, qty)
+}
+
+// completeSale records the sale through the same path as WhatsApp and the
+// API, so stock can never be oversold from a stale screen
+func (s *Service) completeSale(session *Session, product *models.Product, qty int) *Response {
+	if s.saleRepo == nil || s.shopRepo == nil {
+		return s.end(session, "⚠️ Sales are not available right now. Please try again later.")
+	}
+	shop, err := s.shopRepo.GetByID(session.ShopID)
+	if err != nil {
+		return s.end(session, "❌ Shop not found.")
+	}
+
+	totalAmount := product.SellingPrice * float64(qty)
+	costAmount := product.CostPrice * float64(qty)
+	sale := &models.Sale{
+		ShopID:        shop.ID,
+		ProductID:     product.ID,
+		Quantity:      qty,
+		UnitPrice:     product.SellingPrice,
+		TotalAmount:   totalAmount,
+		CostAmount:    costAmount,
+		Profit:        totalAmount - costAmount,
+		PaymentMethod: models.PaymentCash,
+		Notes:         "ussd",
+	}
+	sale.ApplyRounding(shop.RoundingFor(sale.PaymentMethod))
+
+	soldOut, err := s.saleRepo.RecordSale(sale)
+	if err != nil {
+		if errors.Is(err, repository.ErrInsufficientStock) {
+			current, _ := s.productRepo.GetByID(product.ID)
+			if current != nil {
+				product = current
+			}
+			return s.end(session, fmt.Sprintf("❌ Not enough %s. Only %d %s left.", product.Name, product.CurrentStock, product.Unit))
+		}
+		log.Printf("❌ USSD sale failed for shop %d: %v", shop.ID, err)
+		return s.end(session, "❌ Sale failed. Please try again.")
+	}
+
+	if s.summaryRepo != nil {
+		_ = s.summaryRepo.Recalculate(shop.ID, time.Now())
+	}
+	webhooksvc.TriggerSaleCreated(sale, product)
+	webhooksvc.TriggerProductDeactivated(soldOut, "out_of_stock")
+	websocket.NotifySaleCreated(sale, product, product.CurrentStock-qty)
+
+	return s.end(session, fmt.Sprintf("✅ Sold %d x %s\nTotal: KSh %.0f\n\n%s",
+		qty, product.Name, sale.TotalAmount, product.StockLabel(product.CurrentStock-qty)))
+}
+
+// completeRestock adds the stock as a restock movement
+func (s *Service) completeRestock(session *Session, product *models.Product, qty int) *Response {
+	if _, _, err := s.productRepo.MoveStock(product.ID, qty, models.StockMovementRestock, nil, "ussd"); err != nil {
+		log.Printf("❌ USSD restock failed for product %d: %v", product.ID, err)
+		return s.end(session, "❌ Could not add stock. Please try again.")
+	}
+	return s.end(session, fmt.Sprintf("✅ Added %d %s to %s\nStock now: %d",
+		qty, product.Unit, product.Name, product.CurrentStock+qty))
+}
+
+// restart sends the user back to the main menu when the session lost track
+// of the chosen product
+func (s *Service) restart(session *Session) *Response {
+	session.State = StateMain
+	session.Data = make(map[string]string)
+	return s.showMenu(StateMain)
+}
+
+// end closes the session with a final message
+func (s *Service) end(session *Session, msg string) *Response {
+	return &Response{
+		SessionID: session.ID,
+		Message:   msg,
+		FreeFlow:  "FB",
+		End:       true,
+	}
+}
@@ -0,0 +1,130 @@
+package ussd
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Lists shown page by page in StateList
+const (
+	listStock    = "stock"
+	listLowStock = "low_stock"
+)
+
+// startList opens a paged list at its first page
+func (s *Service) startList(session *Session, list string) *Response {
+	session.State = StateList
+	session.Data = map[string]string{"list": list, "page": "0"}
+	return s.showList(session)
+}
+
+// listScreen lays out the session's list, or returns a message to show in
+// its place when there is nothing to list
+func (s *Service) listScreen(session *Session) (*listScreen, string) {
+	if s.productRepo == nil {
+		return nil, "⚠️ Stock service not available."
+	}
+	filter := session.Data["filter"]
+
+	if session.Data["list"] == listLowStock {
+		products, err := s.productRepo.GetLowStock(session.ShopID)
+		if err != nil || len(products) == 0 {
+			return nil, "✅ All products are well stocked!"
+		}
+		items := make([]string, len(products))
+		for i, p := range products {
+			items[i] = fmt.Sprintf("%s %d/%d", clip(p.Name, 20), p.CurrentStock, p.LowStockLevel())
+		}
+		return &listScreen{title: "⚠️ LOW STOCK (left/min)", items: items, sms: s.sendSMS != nil, back: "Back"}, ""
+	}
+
+	products, err := s.productRepo.GetByShopID(session.ShopID)
+	if err != nil || len(products) == 0 {
+		return nil, "📦 No products found."
+	}
+	var items []string
+	var totalValue float64
+	for _, p := range products {
+		totalValue += float64(p.StockOnHand()) * p.SellingPrice
+		if matchesFilter(p.Name, filter) {
+			items = append(items, fmt.Sprintf("%s %d%s @%.0f", clip(p.Name, 16), p.CurrentStock, clip(p.Unit, 6), p.SellingPrice))
+		}
+	}
+	title := fmt.Sprintf("📦 STOCK Value KSh %.0f", totalValue)
+	if filter != "" {
+		if len(items) == 0 {
+			return nil, fmt.Sprintf("🔍 No products starting with %q.", filter)
+		}
+		title = "📦 STOCK (" + filter + ")"
+	}
+	return &listScreen{title: title, items: items, search: true, back: "Back"}, ""
+}
+
+// showList shows the current page of the session's list
+func (s *Service) showList(session *Session) *Response {
+	screen, empty := s.listScreen(session)
+	if screen == nil {
+		msg := empty + "\n\n"
+		if session.Data["filter"] != "" {
+			msg += optSearch + ". Search again\n"
+		}
+		return s.prompt(session, msg+optBack+". Back")
+	}
+
+	msg, shown, _, _ := screen.render(sessionPage(session))
+	session.Data["page"] = strconv.Itoa(shown)
+	return s.prompt(session, msg)
+}
+
+// handleList handles navigation in a paged list
+func (s *Service) handleList(session *Session, input string) *Response {
+	switch input {
+	case optMore:
+		session.Data["page"] = strconv.Itoa(sessionPage(session) + 1)
+		return s.showList(session)
+	case optSearch:
+		if session.Data["list"] == listStock {
+			session.State = StateListSearch
+			return s.askSearch(session)
+		}
+	case optSMS:
+		if session.Data["list"] == listLowStock && s.sendSMS != nil {
+			return s.smsLowStock(session)
+		}
+	case optBack:
+		if session.Data["filter"] != "" {
+			delete(session.Data, "filter")
+			session.Data["page"] = "0"
+			return s.showList(session)
+		}
+		back := StateMain
+		if session.Data["list"] == listStock {
+			back = StateStock
+		} else if session.Previous == StateReport {
+			back = StateReport
+		}
+		session.State = back
+		session.Data = make(map[string]string)
+		return s.showMenu(back)
+	}
+	return s.showList(session)
+}
+
+// handleListSearch filters the stock list by the letters entered
+func (s *Service) handleListSearch(session *Session, input string) *Response {
+	filter, ok := searchFilter(input)
+	fromMenu := session.Data["list"] == "" // searching straight from the stock menu
+	if !ok && fromMenu {
+		session.State = StateStock
+		return s.showMenu(StateStock)
+	}
+
+	session.State = StateList
+	session.Data["list"] = listStock
+	if !ok {
+		return s.showList(session)
+	}
+	session.Data["filter"] = filter
+	session.Data["page"] = "0"
+	return s.showList(session)
+}
@@ -0,0 +1,227 @@
+package ussd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+)
+
+// Report periods, kept in Data["period"] while a report is shown
+const (
+	periodToday = "today"
+	periodWeek  = "week"
+	periodMonth = "month"
+)
+
+// optSMS sends the full version of a report or list by SMS
+const optSMS = "1"
+
+// reportTopProducts is how many best sellers the SMS report lists
+const reportTopProducts = 5
+
+// SMSSender texts a message to phone on behalf of a shop
+type SMSSender func(shopID uint, phone, message string) error
+
+// SetSMSSender lets reports and the low stock list be sent in full by SMS
+func (s *Service) SetSMSSender(send SMSSender) {
+	s.sendSMS = send
+}
+
+// salesReport is a period's totals from the daily summaries
+type salesReport struct {
+	title        string
+	start, end   time.Time // dates, end inclusive
+	days         []models.DailySummary
+	sales        float64
+	profit       float64
+	transactions int
+	best         *models.DailySummary
+}
+
+// reportRange returns the dates a period covers up to today, in the UTC
+// dates daily summaries are kept under. A week is the last 7 days and a
+// month runs from the 1st.
+func reportRange(period string, now time.Time) (start, end time.Time, title string) {
+	end = now.UTC().Truncate(24 * time.Hour)
+	switch period {
+	case periodWeek:
+		return end.AddDate(0, 0, -6), end, "LAST 7 DAYS"
+	case periodMonth:
+		return end.AddDate(0, 0, 1-end.Day()), end, "THIS MONTH " + strings.ToUpper(end.Format("Jan"))
+	}
+	return end, end, "TODAY " + end.Format("Mon 2 Jan")
+}
+
+// loadReport adds up the daily summaries for period. Today's summary is
+// brought up to date first, since not every sale refreshes it.
+func (s *Service) loadReport(shopID uint, period string) (*salesReport, error) {
+	now := time.Now()
+	start, end, title := reportRange(period, now)
+	if err := s.summaryRepo.Recalculate(shopID, now); err != nil {
+		log.Printf("⚠️ Failed to refresh today's summary for shop %d: %v", shopID, err)
+	}
+	days, err := s.summaryRepo.GetByDateRange(shopID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &salesReport{title: title, start: start, end: end}
+	for i, day := range days {
+		if day.TotalTransactions == 0 {
+			continue
+		}
+		report.days = append(report.days, day)
+		report.sales += day.TotalSales
+		report.profit += day.TotalProfit
+		report.transactions += day.TotalTransactions
+		if report.best == nil || day.TotalSales > report.best.TotalSales {
+			report.best = &days[i]
+		}
+	}
+	return report, nil
+}
+
+// daysCovered counts the days from the start of the period to today
+func (r *salesReport) daysCovered() int {
+	return int(r.end.Sub(r.start).Hours()/24) + 1
+}
+
+// showReport shows a period's report with the option to get it by SMS
+func (s *Service) showReport(session *Session, period string) *Response {
+	session.State = StateReportView
+	session.Data = map[string]string{"period": period}
+	if s.summaryRepo == nil {
+		return s.prompt(session, "⚠️ Report service not available.\n\n"+optBack+". Back")
+	}
+
+	report, err := s.loadReport(session.ShopID, period)
+	if err != nil {
+		log.Printf("⚠️ Failed to load USSD report for shop %d: %v", session.ShopID, err)
+		return s.prompt(session, "❌ Could not load the report. Try again later.\n\n"+optBack+". Back")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("📊 " + report.title + "\n")
+	if report.transactions == 0 {
+		sb.WriteString("No sales yet.\n\n" + optBack + ". Back")
+		return s.prompt(session, sb.String())
+	}
+	sb.WriteString(fmt.Sprintf("Sales: KSh %.0f\n", report.sales))
+	sb.WriteString(fmt.Sprintf("Txns: %d\n", report.transactions))
+	sb.WriteString(fmt.Sprintf("Profit: KSh %.0f\n", report.profit))
+	if period == periodToday {
+		if top, err := s.saleRepo.GetProductTotals(session.ShopID, report.start, report.end.Add(24*time.Hour), 1); err == nil && len(top) > 0 {
+			sb.WriteString(fmt.Sprintf("Top: %s x%d\n", clip(top[0].Name, 16), top[0].Quantity))
+		}
+	} else {
+		sb.WriteString(fmt.Sprintf("Avg/day: KSh %.0f\n", report.sales/float64(report.daysCovered())))
+		sb.WriteString(fmt.Sprintf("Best: %s KSh %.0f\n", report.best.Date.Format("Mon 2"), report.best.TotalSales))
+	}
+
+	sb.WriteString("\n")
+	if s.sendSMS != nil {
+		sb.WriteString(optSMS + ". SMS full report\n")
+	}
+	sb.WriteString(optBack + ". Back")
+	return s.prompt(session, sb.String())
+}
+
+// handleReportView handles the choices under a report
+func (s *Service) handleReportView(session *Session, input string) *Response {
+	period := session.Data["period"]
+	switch input {
+	case optSMS:
+		if s.sendSMS != nil {
+			return s.smsReport(session, period)
+		}
+	case optBack:
+		session.State = StateReport
+		session.Data = make(map[string]string)
+		return s.showMenu(StateReport)
+	}
+	return s.showReport(session, period)
+}
+
+// smsReport texts the owner the full report for period: the totals, each
+// day's sales and the best sellers
+func (s *Service) smsReport(session *Session, period string) *Response {
+	report, err := s.loadReport(session.ShopID, period)
+	if err != nil || report.transactions == 0 {
+		return s.showReport(session, period)
+	}
+	products, err := s.saleRepo.GetProductTotals(session.ShopID, report.start, report.end.Add(24*time.Hour), reportTopProducts)
+	if err != nil {
+		log.Printf("⚠️ Failed to load best sellers for shop %d: %v", session.ShopID, err)
+	}
+
+	message := formatSMSReport(s.shopName(session.ShopID), report, products)
+	if err := s.sendSMS(session.ShopID, session.Phone, message); err != nil {
+		log.Printf("⚠️ Failed to SMS USSD report to %s: %v", session.Phone, err)
+		return s.end(session, "❌ Could not send the SMS. Try again later.")
+	}
+	return s.end(session, "✅ Full report sent by SMS.")
+}
+
+// formatSMSReport writes the full report sent by SMS
+func formatSMSReport(shopName string, report *salesReport, products []repository.ProductTotal) string {
+	var sb strings.Builder
+	sb.WriteString("DUKAPOS REPORT - " + shopName + "\n")
+	if report.start.Equal(report.end) {
+		sb.WriteString(report.end.Format("Mon 2 Jan 2006") + "\n")
+	} else {
+		sb.WriteString(report.start.Format("2 Jan") + " - " + report.end.Format("2 Jan 2006") + "\n")
+	}
+	sb.WriteString(fmt.Sprintf("Sales: KSh %.0f (%d txns)\n", report.sales, report.transactions))
+	sb.WriteString(fmt.Sprintf("Profit: KSh %.0f\n", report.profit))
+	if report.sales > 0 {
+		sb.WriteString(fmt.Sprintf("Margin: %.0f%%\n", report.profit/report.sales*100))
+	}
+
+	if len(report.days) > 1 {
+		sb.WriteString(fmt.Sprintf("Avg/day: KSh %.0f\n\nBy day:\n", report.sales/float64(report.daysCovered())))
+		for _, day := range report.days {
+			sb.WriteString(fmt.Sprintf("%s: KSh %.0f (%d)\n", day.Date.Format("Mon 2"), day.TotalSales, day.TotalTransactions))
+		}
+	}
+
+	if len(products) > 0 {
+		sb.WriteString("\nTop products:\n")
+		for _, p := range products {
+			sb.WriteString(fmt.Sprintf("%s x%d - KSh %.0f\n", p.Name, p.Quantity, p.Revenue))
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// smsLowStock texts the owner every product running low
+func (s *Service) smsLowStock(session *Session) *Response {
+	products, err := s.productRepo.GetLowStock(session.ShopID)
+	if err != nil || len(products) == 0 {
+		return s.showList(session)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("LOW STOCK - " + s.shopName(session.ShopID) + "\n")
+	for _, p := range products {
+		sb.WriteString(fmt.Sprintf("%s: %d %s (min %d)\n", p.Name, p.CurrentStock, p.Unit, p.LowStockLevel()))
+	}
+	if err := s.sendSMS(session.ShopID, session.Phone, strings.TrimRight(sb.String(), "\n")); err != nil {
+		log.Printf("⚠️ Failed to SMS low stock list to %s: %v", session.Phone, err)
+		return s.end(session, "❌ Could not send the SMS. Try again later.")
+	}
+	return s.end(session, "✅ Low stock list sent by SMS.")
+}
+
+// shopName names the shop in SMS reports
+func (s *Service) shopName(shopID uint) string {
+	if s.shopRepo != nil {
+		if shop, err := s.shopRepo.GetByID(shopID); err == nil {
+			return shop.Name
+		}
+	}
+	return "your shop"
+}
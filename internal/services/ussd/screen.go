@@ -0,0 +1,164 @@
+package ussd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxMessageLength is the longest screen sent. Carriers drop USSD replies
+// much past 160 characters, so every response is fitted to it.
+const MaxMessageLength = 160
+
+// Navigation codes, the same on every list screen
+const (
+	optBack   = "0"  // back one level
+	optMore   = "98" // next page
+	optSearch = "99" // filter by first letters
+)
+
+const (
+	// pageSize is the most items on one screen; fewer fit if names are long
+	pageSize = 5
+	// maxItemLength keeps one long product name from filling a screen
+	maxItemLength = 30
+)
+
+// listScreen is one page of a list with the shared navigation codes
+type listScreen struct {
+	title    string
+	items    []string
+	numbered bool // items can be chosen by their number
+	search   bool // offer optSearch
+	sms      bool // offer optSMS; only on lists that aren't numbered
+	back     string
+}
+
+// pageBounds returns the items on page, packing each screen with as many
+// items as fit alongside the title and the navigation footer. A page past
+// the end wraps to the first.
+func (l *listScreen) pageBounds(page int) (start, end, shown int) {
+	for p := 0; ; p++ {
+		end = l.pageEnd(start)
+		if p == page {
+			return start, end, p
+		}
+		if end >= len(l.items) {
+			return 0, l.pageEnd(0), 0
+		}
+		start = end
+	}
+}
+
+// pageEnd returns where the page starting at start ends
+func (l *listScreen) pageEnd(start int) int {
+	budget := MaxMessageLength - runes(l.title) - runes(l.footer(true)) - 2
+	end, used := start, 0
+	for end < len(l.items) && end-start < pageSize {
+		line := runes(l.item(end-start, end)) + 1
+		if used+line > budget && end > start {
+			break
+		}
+		used += line
+		end++
+	}
+	return end
+}
+
+// item formats the i-th line of a page for items[index]
+func (l *listScreen) item(i, index int) string {
+	text := clip(l.items[index], maxItemLength)
+	if l.numbered {
+		return fmt.Sprintf("%d. %s", i+1, text)
+	}
+	return "- " + text
+}
+
+func (l *listScreen) footer(more bool) string {
+	var sb strings.Builder
+	if more {
+		sb.WriteString(optMore + ". More\n")
+	}
+	if l.search {
+		sb.WriteString(optSearch + ". Search\n")
+	}
+	if l.sms {
+		sb.WriteString(optSMS + ". SMS full list\n")
+	}
+	sb.WriteString(optBack + ". " + l.back)
+	return sb.String()
+}
+
+// render shows page and returns the page actually shown with the range of
+// items on it
+func (l *listScreen) render(page int) (msg string, shown, start, end int) {
+	start, end, shown = l.pageBounds(page)
+
+	var sb strings.Builder
+	sb.WriteString(l.title + "\n")
+	for i := start; i < end; i++ {
+		sb.WriteString(l.item(i-start, i) + "\n")
+	}
+	sb.WriteString("\n" + l.footer(end < len(l.items)))
+	return sb.String(), shown, start, end
+}
+
+// sessionPage reads the list page remembered in the session
+func sessionPage(session *Session) int {
+	page, _ := strconv.Atoi(session.Data["page"])
+	return page
+}
+
+// matchesFilter reports whether any word of name starts with filter,
+// ignoring case
+func matchesFilter(name, filter string) bool {
+	if filter == "" {
+		return true
+	}
+	filter = strings.ToLower(filter)
+	for _, word := range strings.Fields(strings.ToLower(name)) {
+		if strings.HasPrefix(word, filter) {
+			return true
+		}
+	}
+	return false
+}
+
+// fit shortens msg to MaxMessageLength. Whole lines are dropped from the
+// end of the body so the navigation lines after the last blank line stay
+// usable; a single over-long line is cut.
+func fit(msg string) string {
+	if runes(msg) <= MaxMessageLength {
+		return msg
+	}
+
+	body, footer := msg, ""
+	if i := strings.LastIndex(msg, "\n\n"); i >= 0 && runes(msg[i:]) < MaxMessageLength/2 {
+		body, footer = msg[:i], msg[i:]
+	}
+	budget := MaxMessageLength - runes(footer) - 2 // room for "\n…"
+
+	lines := strings.Split(body, "\n")
+	for len(lines) > 1 && runes(strings.Join(lines, "\n")) > budget {
+		lines = lines[:len(lines)-1]
+	}
+	kept := strings.Join(lines, "\n")
+	if runes(kept) > budget {
+		kept = clip(kept, budget)
+	}
+	return kept + "\n…" + footer
+}
+
+// clip cuts s to n characters, marking the cut with "…"
+func clip(s string, n int) string {
+	if runes(s) <= n {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:n-1]) + "…"
+}
+
+func runes(s string) int {
+	return utf8.RuneCountInString(s)
+}
@@ -1,12 +1,13 @@
 package ussd
 
 import (
+	"encoding/json"
 	"fmt"
-	"strconv"
+	"log"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
 	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
 )
 
@@ -47,14 +48,30 @@ type Response struct {
 	End       bool   `json:"end"`
 }
 
+// SessionTTL is how long an idle USSD session is kept. Gateways drop the
+// session well before this, so a stale one is never resumed.
+const SessionTTL = 2 * time.Minute
+
+// SessionStore persists serialized sessions so they survive restarts and are
+// shared between replicas. The cache service implements it with Redis keys
+// ussd:session:{sessionID}. Get returns nil data when the session is missing.
+type SessionStore interface {
+	GetUSSDSession(sessionID string) ([]byte, error)
+	SetUSSDSession(sessionID string, data []byte, ttl time.Duration) error
+	DeleteUSSDSession(sessionID string) error
+}
+
 // Service handles USSD menu processing
 type Service struct {
-	sessions    map[string]*Session
+	store       SessionStore
+	sessions    map[string]*Session // used when no store is configured
+	mu          sync.Mutex
 	menuTree    map[string]*Menu
 	shopRepo    *repository.ShopRepository
 	productRepo *repository.ProductRepository
 	saleRepo    *repository.SaleRepository
 	summaryRepo *repository.DailySummaryRepository
+	sendSMS     SMSSender
 }
 
 // New creates a new USSD service
@@ -67,6 +84,11 @@ func New() *Service {
 	return s
 }
 
+// SetSessionStore sets the store used to persist sessions between requests
+func (s *Service) SetSessionStore(store SessionStore) {
+	s.store = store
+}
+
 // SetRepositories sets the database repositories
 func (s *Service) SetRepositories(
 	shopRepo *repository.ShopRepository,
@@ -85,17 +107,19 @@ func (s *Service) buildMenuTree() {
 	// Main Menu
 	s.menuTree["main"] = &Menu{
 		ID:         "main",
-		Title:      "🏪 DUKAPOS - Digital Duka",
+		Title:      "🏪 DUKAPOS",
 		IsMainMenu: true,
+		// Plain text so the whole menu fits on one screen
 		Options: []Option{
-			{Number: "1", Text: "📦 Check Stock", Action: "stock"},
-			{Number: "2", Text: "💰 Record Sale", Action: "sale"},
-			{Number: "3", Text: "➕ Add Product", Action: "add_product"},
-			{Number: "4", Text: "📊 Daily Report", Action: "report"},
-			{Number: "5", Text: "💵 Check Profit", Action: "profit"},
-			{Number: "6", Text: "⚠️ Low Stock Items", Action: "low_stock"},
-			{Number: "7", Text: "💎 My Shop Info", Action: "shop_info"},
-			{Number: "0", Text: "❌ Exit", Action: "exit"},
+			{Number: "1", Text: "Check Stock", Action: "stock"},
+			{Number: "2", Text: "Record Sale", Action: "sale"},
+			{Number: "3", Text: "Add Product", Action: "add_product"},
+			{Number: "4", Text: "Reports", Action: "report"},
+			{Number: "5", Text: "Check Profit", Action: "profit"},
+			{Number: "6", Text: "Low Stock", Action: "low_stock"},
+			{Number: "7", Text: "My Shop Info", Action: "shop_info"},
+			{Number: "8", Text: "Settings", Action: "settings"},
+			{Number: "0", Text: "Exit", Action: "exit"},
 		},
 	}
 
@@ -110,17 +134,6 @@ func (s *Service) buildMenuTree() {
 		},
 	}
 
-	// Sale Menu
-	s.menuTree["sale"] = &Menu{
-		ID:    "sale",
-		Title: "💰 RECORD SALE",
-		Options: []Option{
-			{Number: "1", Text: "Quick Sell", Action: "sale_quick"},
-			{Number: "2", Text: "Select Product", Action: "sale_select"},
-			{Number: "0", Text: "Back to Main", Action: "main"},
-		},
-	}
-
 	// Add Product Menu
 	s.menuTree["add_product"] = &Menu{
 		ID:    "add_product",
@@ -137,9 +150,20 @@ func (s *Service) buildMenuTree() {
 		ID:    "report",
 		Title: "📊 REPORTS",
 		Options: []Option{
-			{Number: "1", Text: "Today's Report", Action: "report_today"},
-			{Number: "2", Text: "This Week", Action: "report_week"},
+			{Number: "1", Text: "Today", Action: "report_today"},
+			{Number: "2", Text: "Last 7 Days", Action: "report_week"},
 			{Number: "3", Text: "This Month", Action: "report_month"},
+			{Number: "4", Text: "Low Stock", Action: "low_stock"},
+			{Number: "0", Text: "Back to Main", Action: "main"},
+		},
+	}
+
+	// Settings Menu
+	s.menuTree["settings"] = &Menu{
+		ID:    "settings",
+		Title: "⚙️ SETTINGS",
+		Options: []Option{
+			{Number: "1", Text: "Change PIN", Action: StatePinOld},
 			{Number: "0", Text: "Back to Main", Action: "main"},
 		},
 	}
@@ -157,31 +181,51 @@ func (s *Service) buildMenuTree() {
 	}
 }
 
-// Process handles incoming USSD request
+// Process handles incoming USSD request. Input may be the full Africa's
+// Talking text, with each level's answer separated by "*"; only the latest
+// answer is used since the session holds where the user is.
 func (s *Service) Process(phone, sessionID, input string) *Response {
+	return s.Respond(phone, sessionID, LastLevel(input))
+}
+
+// Respond moves the session on by one screen. Input is the answer to the
+// latest screen alone, whatever format the gateway delivered it in, and is
+// ignored when the session is new.
+func (s *Service) Respond(phone, sessionID, input string) *Response {
 	// Clean phone number
 	phone = formatPhone(phone)
 
 	// Get or create session
-	session := s.getOrCreateSession(sessionID, phone)
+	session, isNew := s.getOrCreateSession(sessionID, phone)
 
-	// Handle input
-	response := s.handleInput(session, input)
+	// Handle input. A new session mid-flow (expired, or lost in a restart)
+	// starts over at the main menu rather than replaying the input.
+	var response *Response
+	if isNew {
+		response = s.startSession(session)
+	} else {
+		response = s.handleInput(session, strings.TrimSpace(input))
+	}
+	response.SessionID = sessionID
+	response.Message = fit(response.Message)
 
 	// Update session
 	session.UpdatedAt = time.Now()
 	if response.End {
 		// Close session
-		delete(s.sessions, sessionID)
+		s.EndSession(sessionID)
+	} else {
+		s.saveSession(session)
 	}
 
 	return response
 }
 
-// getOrCreateSession gets existing or creates new session
-func (s *Service) getOrCreateSession(sessionID, phone string) *Session {
-	if session, exists := s.sessions[sessionID]; exists {
-		return session
+// getOrCreateSession loads the session, creating one at the main menu if it
+// doesn't exist. The bool reports whether the session is new.
+func (s *Service) getOrCreateSession(sessionID, phone string) (*Session, bool) {
+	if session, exists := s.GetSession(sessionID); exists {
+		return session, false
 	}
 
 	session := &Session{
@@ -192,14 +236,67 @@ func (s *Service) getOrCreateSession(sessionID, phone string) *Session {
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
-	s.sessions[sessionID] = session
-	return session
+	return session, true
+}
+
+// LastLevel returns the answer to the latest menu from cumulative text
+// such as Africa's Talking's "2*1*3"
+func LastLevel(text string) string {
+	if i := strings.LastIndex(text, "*"); i >= 0 {
+		text = text[i+1:]
+	}
+	return strings.TrimSpace(text)
+}
+
+// saveSession persists the session with a fresh TTL
+func (s *Service) saveSession(session *Session) {
+	if s.store == nil {
+		s.mu.Lock()
+		s.sessions[session.ID] = session
+		s.mu.Unlock()
+		return
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		log.Printf("⚠️ Failed to encode USSD session %s: %v", session.ID, err)
+		return
+	}
+	if err := s.store.SetUSSDSession(session.ID, data, SessionTTL); err != nil {
+		log.Printf("⚠️ Failed to save USSD session %s: %v", session.ID, err)
+	}
 }
 
 // handleInput processes user input
 func (s *Service) handleInput(session *Session, input string) *Response {
 	input = strings.TrimSpace(input)
 
+	// Registration and PIN entry come before any menu
+	if authStates[session.State] {
+		return s.handleAuth(session, input)
+	}
+
+	// Screens that take free input or show results handle it themselves
+	switch session.State {
+	case StateSellPick, StateRestockPick:
+		return s.handlePick(session, input)
+	case StateSellSearch, StateRestockSearch:
+		return s.handlePickSearch(session, input)
+	case StateList:
+		return s.handleList(session, input)
+	case StateListSearch:
+		return s.handleListSearch(session, input)
+	case StateReportView:
+		return s.handleReportView(session, input)
+	case StateSellQty, StateRestockQty:
+		return s.handleQuantity(session, input)
+	case StateSellConfirm, StateRestockConfirm:
+		return s.handleConfirm(session, input)
+	case StateInfo:
+		session.State = StateMain
+		return s.showMenu(StateMain)
+	}
+
 	// First request (empty input) - show main menu
 	if input == "" {
 		return s.showMenu(session.State)
@@ -224,14 +321,27 @@ func (s *Service) handleInput(session *Session, input string) *Response {
 					}
 				case "main":
 					return s.showMenu("main")
+				case StateSale:
+					return s.startPick(session, StateSellPick)
+				case "add_existing":
+					return s.startPick(session, StateRestockPick)
+				case StatePinOld:
+					return s.prompt(session, "Enter your current PIN:")
 				case "stock_all":
-					return s.handleStockAll(session)
+					return s.startList(session, listStock)
+				case StateListSearch:
+					session.Data = make(map[string]string)
+					return s.askSearch(session)
 				case "report_today":
-					return s.handleReportToday(session)
+					return s.showReport(session, periodToday)
+				case "report_week":
+					return s.showReport(session, periodWeek)
+				case "report_month":
+					return s.showReport(session, periodMonth)
 				case "profit":
-					return s.handleProfit(session)
+					return s.info(session, s.handleProfit(session))
 				case "low_stock":
-					return s.handleLowStock(session)
+					return s.startList(session, listLowStock)
 				default:
 					return s.showMenu(opt.Action)
 				}
@@ -273,142 +383,41 @@ func (s *Service) showMenu(menuID string) *Response {
 	}
 }
 
-// Handler functions (integrated with database)
-
-func (s *Service) handleStockAll(session *Session) *Response {
-	if s.productRepo == nil {
-		return &Response{
-			SessionID: session.ID,
-			Message:   "⚠️ Stock service not available.\n\n# = Back to Stock Menu",
-			FreeFlow:  "FC",
-			End:       false,
-		}
-	}
-
-	products, err := s.productRepo.GetByShopID(session.ShopID)
-	if err != nil || len(products) == 0 {
-		return &Response{
-			SessionID: session.ID,
-			Message:   "📦 No products found.\n\n# = Back to Stock Menu",
-			FreeFlow:  "FC",
-			End:       false,
-		}
-	}
-
-	var sb strings.Builder
-	sb.WriteString("📦 CURRENT STOCK:\n\n")
-
-	var totalValue float64
-	maxItems := 10
-	for i, p := range products {
-		if i >= maxItems {
-			sb.WriteString(fmt.Sprintf("\n... and %d more items", len(products)-maxItems))
-			break
-		}
-		sb.WriteString(fmt.Sprintf("%d. %s - %d %s @ KSh %.0f\n", i+1, p.Name, p.CurrentStock, p.Unit, p.SellingPrice))
-		totalValue += float64(p.CurrentStock) * p.SellingPrice
-	}
-
-	sb.WriteString(fmt.Sprintf("\nTotal Value: KSh %.0f\n\n# = Back to Stock Menu", totalValue))
-
-	return &Response{
-		SessionID: session.ID,
-		Message:   sb.String(),
-		FreeFlow:  "FC",
-		End:       false,
-	}
+// info shows a result screen; any answer goes back to the main menu
+func (s *Service) info(session *Session, resp *Response) *Response {
+	session.State = StateInfo
+	return resp
 }
 
-func (s *Service) handleReportToday(session *Session) *Response {
-	if s.saleRepo == nil || s.summaryRepo == nil {
+// handleProfit shows profit for today, the last 7 days and this month
+// from the daily summaries
+func (s *Service) handleProfit(session *Session) *Response {
+	if s.summaryRepo == nil {
 		return &Response{
 			SessionID: session.ID,
-			Message:   "⚠️ Report service not available.\n\n# = Back to Reports",
+			Message:   "⚠️ Profit service not available.\n\n0. Main menu",
 			FreeFlow:  "FC",
 			End:       false,
 		}
 	}
 
-	today := time.Now().Truncate(24 * time.Hour)
-	tomorrow := today.Add(24 * time.Hour)
-
-	sales, err := s.saleRepo.GetByDateRange(session.ShopID, today, tomorrow)
-	if err != nil {
-		sales = []models.Sale{}
-	}
-
-	var totalSales, totalProfit float64
-	productCounts := make(map[string]int)
-
-	for _, sale := range sales {
-		totalSales += sale.TotalAmount
-		totalProfit += sale.Profit
-		if sale.Product.Name != "" {
-			productCounts[sale.Product.Name] += sale.Quantity
-		}
-	}
-
-	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("📊 TODAY'S REPORT:\n\n"))
-	sb.WriteString(fmt.Sprintf("💰 Total Sales: KSh %.0f\n", totalSales))
-	sb.WriteString(fmt.Sprintf("📝 Transactions: %d\n", len(sales)))
-	sb.WriteString(fmt.Sprintf("💵 Total Profit: KSh %.0f\n\n", totalProfit))
-
-	if len(productCounts) > 0 {
-		sb.WriteString("Top Selling:\n")
-		i := 0
-		for name, qty := range productCounts {
-			if i >= 3 {
-				break
-			}
-			sb.WriteString(fmt.Sprintf("%d. %s - %d units\n", i+1, name, qty))
-			i++
+	var profit [3]float64
+	var todaySales float64
+	for i, period := range []string{periodToday, periodWeek, periodMonth} {
+		report, err := s.loadReport(session.ShopID, period)
+		if err != nil {
+			log.Printf("⚠️ Failed to load USSD profit for shop %d: %v", session.ShopID, err)
+			continue
 		}
-	}
-
-	sb.WriteString("\n# = Back to Reports")
-
-	return &Response{
-		SessionID: session.ID,
-		Message:   sb.String(),
-		FreeFlow:  "FC",
-		End:       false,
-	}
-}
-
-func (s *Service) handleProfit(session *Session) *Response {
-	if s.saleRepo == nil {
-		return &Response{
-			SessionID: session.ID,
-			Message:   "⚠️ Profit service not available.\n\n# = Back to Main",
-			FreeFlow:  "FC",
-			End:       false,
+		profit[i] = report.profit
+		if period == periodToday {
+			todaySales = report.sales
 		}
 	}
 
-	today := time.Now().Truncate(24 * time.Hour)
-	weekAgo := today.AddDate(0, 0, -7)
-	monthAgo := today.AddDate(0, -1, 0)
-
-	todaySales, _ := s.saleRepo.GetByDateRange(session.ShopID, today, today.Add(24*time.Hour))
-	weekSales, _ := s.saleRepo.GetByDateRange(session.ShopID, weekAgo, today)
-	monthSales, _ := s.saleRepo.GetByDateRange(session.ShopID, monthAgo, today)
-
-	var todayProfit, weekProfit, monthProfit float64
-
-	for _, sale := range todaySales {
-		todayProfit += sale.Profit
-	}
-	for _, sale := range weekSales {
-		weekProfit += sale.Profit
-	}
-	for _, sale := range monthSales {
-		monthProfit += sale.Profit
-	}
-
 	margin := 0.0
-	if todayProfit > 0 {
-		margin = (todayProfit / todaySales[0].TotalAmount) * 100
+	if todaySales > 0 {
+		margin = profit[0] / todaySales * 100
 	}
 
 	return &Response{
@@ -416,59 +425,17 @@ func (s *Service) handleProfit(session *Session) *Response {
 		Message: fmt.Sprintf(`💵 PROFIT SUMMARY:
 
 Today: KSh %.0f
-This Week: KSh %.0f
+Last 7 Days: KSh %.0f
 This Month: KSh %.0f
 
-📈 Profit Margin: %.0f%%
+📈 Today's Margin: %.0f%%
 
-# = Back to Main`, todayProfit, weekProfit, monthProfit, margin),
+0. Main menu`, profit[0], profit[1], profit[2], margin),
 		FreeFlow: "FC",
 		End:      false,
 	}
 }
 
-func (s *Service) handleLowStock(session *Session) *Response {
-	if s.productRepo == nil {
-		return &Response{
-			SessionID: session.ID,
-			Message:   "⚠️ Stock service not available.\n\n# = Back to Main",
-			FreeFlow:  "FC",
-			End:       false,
-		}
-	}
-
-	products, err := s.productRepo.GetLowStock(session.ShopID)
-	if err != nil || len(products) == 0 {
-		return &Response{
-			SessionID: session.ID,
-			Message:   "✅ All products are well stocked!\n\n# = Back to Main",
-			FreeFlow:  "FC",
-			End:       false,
-		}
-	}
-
-	var sb strings.Builder
-	sb.WriteString("⚠️ LOW STOCK ALERT:\n\n")
-
-	maxItems := 5
-	for i, p := range products {
-		if i >= maxItems {
-			sb.WriteString(fmt.Sprintf("\n... and %d more items", len(products)-maxItems))
-			break
-		}
-		sb.WriteString(fmt.Sprintf("%d. %s - %d units (Min: %d)\n", i+1, p.Name, p.CurrentStock, p.LowStockThreshold))
-	}
-
-	sb.WriteString("\n💡 Order soon to avoid stockouts!\n\n# = Back to Main")
-
-	return &Response{
-		SessionID: session.ID,
-		Message:   sb.String(),
-		FreeFlow:  "FC",
-		End:       false,
-	}
-}
-
 // formatPhone formats phone number to standard format
 func formatPhone(phone string) string {
 	// Remove all non-digits
@@ -515,13 +482,49 @@ func FormatUSSDResponse(resp *Response) string {
 
 // GetSession gets a session by ID
 func (s *Service) GetSession(sessionID string) (*Session, bool) {
-	session, exists := s.sessions[sessionID]
-	return session, exists
+	if s.store == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		session, exists := s.sessions[sessionID]
+		if exists && time.Since(session.UpdatedAt) > SessionTTL {
+			delete(s.sessions, sessionID)
+			return nil, false
+		}
+		return session, exists
+	}
+
+	data, err := s.store.GetUSSDSession(sessionID)
+	if err != nil {
+		log.Printf("⚠️ Failed to load USSD session %s: %v", sessionID, err)
+		return nil, false
+	}
+	if data == nil {
+		return nil, false
+	}
+
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		log.Printf("⚠️ Discarding unreadable USSD session %s: %v", sessionID, err)
+		return nil, false
+	}
+	if session.Data == nil {
+		session.Data = make(map[string]string)
+	}
+	return &session, true
 }
 
 // EndSession ends a USSD session
 func (s *Service) EndSession(sessionID string) {
-	delete(s.sessions, sessionID)
+	if s.store == nil {
+		s.mu.Lock()
+		delete(s.sessions, sessionID)
+		s.mu.Unlock()
+		return
+	}
+
+	if err := s.store.DeleteUSSDSession(sessionID); err != nil {
+		log.Printf("⚠️ Failed to delete USSD session %s: %v", sessionID, err)
+	}
 }
 
 // GetMenu gets a menu by ID
@@ -539,8 +542,11 @@ func (s *Service) GetAllMenus() []*Menu {
 	return menus
 }
 
-// SessionCount returns number of active sessions
+// SessionCount returns number of in-memory sessions; sessions held in the
+// session store are not counted
 func (s *Service) SessionCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return len(s.sessions)
 }
 
@@ -558,19 +564,32 @@ const (
 	StateReport     = "report"
 	StateShopInfo   = "shop_info"
 	StateExit       = "exit"
+	StateInfo       = "info" // a result screen; any answer returns to main
+	StateList       = "list" // a paged list; the list shown is in Data["list"]
+	StateListSearch = "stock_search"
+	StateReportView = "report_view" // a report; the period shown is in Data["period"]
+
+	StateSellPick       = "sell_pick"
+	StateSellSearch     = "sell_search"
+	StateSellQty        = "sell_qty"
+	StateSellConfirm    = "sell_confirm"
+	StateRestockPick    = "restock_pick"
+	StateRestockSearch  = "restock_search"
+	StateRestockQty     = "restock_qty"
+	StateRestockConfirm = "restock_confirm"
 )
 
 // InputHandler handles specific input based on state
 func (s *Service) InputHandler(session *Session, input string) *Response {
 	switch session.State {
-	case "sale_quick":
-		return s.handleSaleQuick(session, input)
 	case "add_new":
 		return s.handleAddNew(session, input)
 	case "add_existing":
 		return s.handleAddExisting(session, input)
-	case "stock_search":
-		return s.handleStockSearch(session, input)
+	case StateListSearch:
+		return s.handleListSearch(session, input)
+	case StateReportView:
+		return s.handleReportView(session, input)
 	case "change_price":
 		return s.handleChangePrice(session, input)
 	default:
@@ -578,38 +597,6 @@ func (s *Service) InputHandler(session *Session, input string) *Response {
 	}
 }
 
-func (s *Service) handleSaleQuick(session *Session, input string) *Response {
-	// Parse: product|quantity (e.g., "milk|2")
-	parts := strings.Split(input, "|")
-	if len(parts) != 2 {
-		return &Response{
-			SessionID: session.ID,
-			Message:   "❌ Invalid format.\n\nUse: product|qty\nExample: milk|2\n\n# = Cancel",
-			FreeFlow:  "FC",
-			End:       false,
-		}
-	}
-
-	product := parts[0]
-	qty, err := strconv.Atoi(parts[1])
-	if err != nil || qty <= 0 {
-		return &Response{
-			SessionID: session.ID,
-			Message:   "❌ Invalid quantity.\n\n# = Cancel",
-			FreeFlow:  "FC",
-			End:       false,
-		}
-	}
-
-	// In production, process the sale
-	return &Response{
-		SessionID: session.ID,
-		Message:   fmt.Sprintf("✅ SALE RECORDED!\n\n%s x%d = KSh ???\n\nRemaining stock: ???\n\n# = Back to Main", product, qty),
-		FreeFlow:  "FC",
-		End:       false,
-	}
-}
-
 func (s *Service) handleAddNew(session *Session, input string) *Response {
 	// Parse: name|price|qty (e.g., "biscuits|30|10")
 	return &Response{
@@ -629,20 +616,6 @@ func (s *Service) handleAddExisting(session *Session, input string) *Response {
 	}
 }
 
-func (s *Service) handleStockSearch(session *Session, input string) *Response {
-	if input == "" || input == "#" {
-		return s.showMenu("stock")
-	}
-
-	// In production, search database
-	return &Response{
-		SessionID: session.ID,
-		Message:   fmt.Sprintf("🔍 Search results for: %s\n\nNo products found.\n\n# = Back to Stock", input),
-		FreeFlow:  "FC",
-		End:       false,
-	}
-}
-
 func (s *Service) handleChangePrice(session *Session, input string) *Response {
 	return &Response{
 		SessionID: session.ID,
@@ -5,9 +5,13 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
@@ -17,18 +21,31 @@ import (
 type EventType string
 
 const (
-	EventSaleCreated     EventType = "sale.created"
-	EventSaleUpdated     EventType = "sale.updated"
-	EventProductCreated  EventType = "product.created"
-	EventProductUpdated  EventType = "product.updated"
-	EventProductLowStock EventType = "product.low_stock"
-	EventPaymentReceived EventType = "payment.received"
-	EventPaymentFailed   EventType = "payment.failed"
-	EventCustomerCreated EventType = "customer.created"
-	EventCustomerTier    EventType = "customer.tier_upgraded"
-	EventShopCreated     EventType = "shop.created"
-	EventOrderCreated    EventType = "order.created"
-	EventOrderFulfilled  EventType = "order.fulfilled"
+	EventSaleCreated        EventType = "sale.created"
+	EventSaleUpdated        EventType = "sale.updated"
+	EventProductCreated     EventType = "product.created"
+	EventProductUpdated     EventType = "product.updated"
+	EventProductLowStock    EventType = "product.low_stock"
+	EventProductDeactivated EventType = "product.deactivated"
+	EventPaymentReceived    EventType = "payment.received"
+	EventPaymentCompleted   EventType = "payment.completed"
+	EventPaymentFailed      EventType = "payment.failed"
+	EventPaymentExpired     EventType = "payment.expired"
+	EventPaymentLinkPaid    EventType = "payment_link.paid"
+	EventCustomerCreated    EventType = "customer.created"
+	EventCustomerTier       EventType = "customer.tier_upgraded"
+	EventShopCreated        EventType = "shop.created"
+	EventOrderCreated       EventType = "order.created"
+	EventOrderFulfilled     EventType = "order.fulfilled"
+	EventTest               EventType = "webhook.test"
+)
+
+// responseSnippetSize is how much of a response body a delivery log keeps
+const responseSnippetSize = 512
+
+var (
+	ErrDeliveryDisabled = errors.New("webhook delivery is disabled")
+	ErrQueueFull        = errors.New("webhook queue is full, try again shortly")
 )
 
 type DeliveryService struct {
@@ -37,8 +54,12 @@ type DeliveryService struct {
 	queue        chan *EventDelivery
 	workers      int
 	maxRetries   int
+	retryDelay   time.Duration
 	timeout      time.Duration
 	deliveryRepo *DeliveryRepo
+	ctx          context.Context
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
 }
 
 type EventDelivery struct {
@@ -72,50 +93,75 @@ type WebhookEvent struct {
 	UpdatedAt time.Time  `json:"updated_at"`
 }
 
-type WebhookDelivery struct {
-	ID           uint       `gorm:"primaryKey" json:"id"`
-	EventID      uint       `gorm:"index" json:"event_id"`
-	WebhookURL   string     `gorm:"size:500" json:"webhook_url"`
-	Status       string     `gorm:"size:20;default:pending" json:"status"`
-	HTTPStatus   int        `json:"http_status"`
-	ResponseBody string     `gorm:"size:1000" json:"response_body"`
-	Error        string     `gorm:"size:500" json:"error"`
-	Attempt      int        `gorm:"default:0" json:"attempt"`
-	DeliveredAt  *time.Time `json:"delivered_at"`
-	CreatedAt    time.Time  `json:"created_at"`
-}
-
 func NewDeliveryService(db *gorm.DB, workers, maxRetries int) *DeliveryService {
 	svc := &DeliveryService{
 		db:         db,
 		workers:    workers,
 		maxRetries: maxRetries,
+		retryDelay: 5 * time.Second,
 		timeout:    30 * time.Second,
 		httpClient: &http.Client{Timeout: 30 * time.Second},
 		queue:      make(chan *EventDelivery, 1000),
 	}
 
-	if err := svc.db.AutoMigrate(&WebhookEvent{}, &WebhookDelivery{}); err != nil {
+	if err := svc.db.AutoMigrate(&WebhookEvent{}, &models.WebhookDelivery{}); err != nil {
 		log.Printf("Failed to migrate webhook tables: %v", err)
 	}
 
 	return svc
 }
 
+// SetRetryDelay sets the delay before the first retry of a failed
+// delivery; the nth retry waits n² times as long
+func (s *DeliveryService) SetRetryDelay(delay time.Duration) {
+	s.retryDelay = delay
+}
+
 func (s *DeliveryService) Start(ctx context.Context) {
+	s.ctx, s.cancel = context.WithCancel(ctx)
+
 	for i := 0; i < s.workers; i++ {
-		go s.worker(ctx, i)
+		s.wg.Add(1)
+		go s.worker(s.ctx, i)
 	}
 
-	go s.retryFailed(ctx)
+	s.wg.Add(1)
+	go s.retryFailed(s.ctx)
 
 	log.Printf("Webhook delivery service started with %d workers", s.workers)
 }
 
+// Shutdown stops accepting retries and lets the workers drain the queue,
+// giving up when ctx is done. Undelivered events stay in the database and
+// are picked up by retryPending on the next start.
+func (s *DeliveryService) Shutdown(ctx context.Context) error {
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("Webhook delivery service stopped")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("webhook delivery shutdown: %w", ctx.Err())
+	}
+}
+
 func (s *DeliveryService) worker(ctx context.Context, id int) {
+	defer s.wg.Done()
+
 	for {
 		select {
 		case <-ctx.Done():
+			s.drain()
 			return
 		case delivery := <-s.queue:
 			s.processDelivery(delivery)
@@ -123,6 +169,17 @@ func (s *DeliveryService) worker(ctx context.Context, id int) {
 	}
 }
 
+func (s *DeliveryService) drain() {
+	for {
+		select {
+		case delivery := <-s.queue:
+			s.processDelivery(delivery)
+		default:
+			return
+		}
+	}
+}
+
 func (s *DeliveryService) processDelivery(delivery *EventDelivery) {
 	var webhook models.Webhook
 	if err := s.db.First(&webhook, delivery.WebhookID).Error; err != nil {
@@ -144,7 +201,7 @@ func (s *DeliveryService) processDelivery(delivery *EventDelivery) {
 
 	req, err := http.NewRequest("POST", webhook.URL, bytes.NewBuffer(jsonPayload))
 	if err != nil {
-		s.recordDelivery(delivery, webhook.URL, 0, err.Error())
+		s.recordDelivery(delivery, webhook.URL, 0, "", err.Error())
 		return
 	}
 
@@ -159,14 +216,16 @@ func (s *DeliveryService) processDelivery(delivery *EventDelivery) {
 
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
-		s.recordDelivery(delivery, webhook.URL, 0, err.Error())
+		s.recordDelivery(delivery, webhook.URL, 0, "", err.Error())
 		s.scheduleRetry(delivery)
 		return
 	}
 	defer resp.Body.Close()
 
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, responseSnippetSize))
+
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		s.recordDelivery(delivery, webhook.URL, resp.StatusCode, "")
+		s.recordDelivery(delivery, webhook.URL, resp.StatusCode, string(body), "")
 
 		s.db.Model(&WebhookEvent{}).Where("id = ?", delivery.ID).Updates(map[string]interface{}{
 			"status":   "delivered",
@@ -174,23 +233,19 @@ func (s *DeliveryService) processDelivery(delivery *EventDelivery) {
 			"attempts": delivery.Attempt + 1,
 		})
 	} else {
-		body := ""
-		buf := make([]byte, 512)
-		if n, _ := resp.Body.Read(buf); n > 0 {
-			body = string(buf)
-		}
-
-		s.recordDelivery(delivery, webhook.URL, resp.StatusCode, body)
+		s.recordDelivery(delivery, webhook.URL, resp.StatusCode, string(body), fmt.Sprintf("HTTP %d", resp.StatusCode))
 		s.scheduleRetry(delivery)
 	}
 }
 
-func (s *DeliveryService) recordDelivery(delivery *EventDelivery, url string, status int, errMsg string) {
-	deliveryRecord := &WebhookDelivery{
+func (s *DeliveryService) recordDelivery(delivery *EventDelivery, url string, status int, body, errMsg string) {
+	deliveryRecord := &models.WebhookDelivery{
+		WebhookID:    delivery.WebhookID,
 		EventID:      delivery.ID,
+		Event:        string(delivery.EventType),
 		WebhookURL:   url,
 		HTTPStatus:   status,
-		ResponseBody: errMsg,
+		ResponseBody: body,
 		Attempt:      delivery.Attempt + 1,
 	}
 
@@ -213,20 +268,33 @@ func (s *DeliveryService) scheduleRetry(delivery *EventDelivery) {
 	}
 
 	delivery.Attempt++
-	delay := time.Duration(delivery.Attempt * delivery.Attempt * 5)
+	delay := time.Duration(delivery.Attempt*delivery.Attempt) * s.retryDelay
 
 	s.db.Model(&WebhookEvent{}).Where("id = ?", delivery.ID).Updates(map[string]interface{}{
 		"attempts": delivery.Attempt,
 		"status":   "retry_scheduled",
 	})
 
+	// Once shut down, the retry is left to retryPending on the next start;
+	// the workers are gone, so a send could block forever
+	s.wg.Add(1)
 	go func() {
-		time.Sleep(delay * time.Second)
-		s.queue <- delivery
+		defer s.wg.Done()
+		select {
+		case <-time.After(delay):
+		case <-s.ctx.Done():
+			return
+		}
+		select {
+		case s.queue <- delivery:
+		case <-s.ctx.Done():
+		}
 	}()
 }
 
 func (s *DeliveryService) retryFailed(ctx context.Context) {
+	defer s.wg.Done()
+
 	ticker := time.NewTicker(5 * time.Minute)
 	defer ticker.Stop()
 
@@ -255,7 +323,11 @@ func (s *DeliveryService) retryPending() {
 			Payload:   json.RawMessage(event.Payload),
 			Attempt:   event.Attempts,
 		}
-		s.queue <- delivery
+		select {
+		case s.queue <- delivery:
+		case <-s.ctx.Done():
+			return
+		}
 	}
 }
 
@@ -264,7 +336,37 @@ func (s *DeliveryService) TriggerEvent(eventType EventType, data interface{}) er
 	if err != nil {
 		return err
 	}
+	return s.enqueue(webhooks, eventType, data)
+}
+
+// TriggerShopEvent delivers an event only to the given shop's webhooks
+func (s *DeliveryService) TriggerShopEvent(shopID uint, eventType EventType, data interface{}) error {
+	var shopWebhooks []models.Webhook
+	if err := s.db.Where("shop_id = ? AND is_active = ?", shopID, true).Find(&shopWebhooks).Error; err != nil {
+		return err
+	}
 
+	var webhooks []models.Webhook
+	for _, webhook := range shopWebhooks {
+		if subscribes(webhook.Events, eventType) {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	return s.enqueue(webhooks, eventType, data)
+}
+
+// subscribes reports whether a comma or space separated event list includes
+// eventType, either by name or through "all"
+func subscribes(events string, eventType EventType) bool {
+	for _, event := range strings.FieldsFunc(events, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if event == string(eventType) || event == "all" || event == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *DeliveryService) enqueue(webhooks []models.Webhook, eventType EventType, data interface{}) error {
 	payload, err := json.Marshal(data)
 	if err != nil {
 		return err
@@ -302,6 +404,68 @@ func (s *DeliveryService) TriggerEvent(eventType EventType, data interface{}) er
 	return nil
 }
 
+// SendTest queues a webhook.test event to a single webhook and returns the
+// event ID, so its delivery shows up in the webhook's delivery log
+func (s *DeliveryService) SendTest(webhook *models.Webhook) (uint, error) {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"message":    "This is a test webhook from DukaPOS",
+		"webhook_id": webhook.ID,
+		"shop_id":    webhook.ShopID,
+	})
+
+	event := &WebhookEvent{
+		WebhookID: webhook.ID,
+		Event:     EventTest,
+		Payload:   string(payload),
+		Status:    "pending",
+	}
+	if err := s.db.Create(event).Error; err != nil {
+		return 0, err
+	}
+
+	return event.ID, s.push(&EventDelivery{
+		ID:        event.ID,
+		WebhookID: webhook.ID,
+		EventType: EventTest,
+		Payload:   payload,
+	})
+}
+
+// Redeliver queues an event for one more delivery attempt, whatever its
+// retry state. The attempt is numbered after the last one logged.
+func (s *DeliveryService) Redeliver(eventID uint) error {
+	var event WebhookEvent
+	if err := s.db.First(&event, eventID).Error; err != nil {
+		return err
+	}
+
+	var lastAttempt int
+	s.db.Model(&models.WebhookDelivery{}).
+		Where("event_id = ?", eventID).
+		Select("COALESCE(MAX(attempt), 0)").
+		Scan(&lastAttempt)
+
+	s.db.Model(&WebhookEvent{}).Where("id = ?", eventID).Update("status", "pending")
+
+	return s.push(&EventDelivery{
+		ID:        event.ID,
+		WebhookID: event.WebhookID,
+		EventType: event.Event,
+		Payload:   json.RawMessage(event.Payload),
+		Attempt:   lastAttempt,
+	})
+}
+
+// push queues a delivery without blocking the caller
+func (s *DeliveryService) push(delivery *EventDelivery) error {
+	select {
+	case s.queue <- delivery:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
 func (s *DeliveryService) getActiveWebhooks(eventType EventType) ([]models.Webhook, error) {
 	var webhooks []models.Webhook
 	err := s.db.Where("is_active = ? AND (events = ? OR events LIKE ?)", true, eventType, "%all%").
@@ -315,7 +479,7 @@ func (s *DeliveryService) GetEventStatus(eventID uint) (map[string]interface{},
 		return nil, err
 	}
 
-	var deliveries []WebhookDelivery
+	var deliveries []models.WebhookDelivery
 	s.db.Where("event_id = ?", eventID).Find(&deliveries)
 
 	return map[string]interface{}{
@@ -1,6 +1,7 @@
 package webhook
 
 import (
+	"context"
 	"log"
 	"sync"
 
@@ -31,6 +32,7 @@ func Init(db *gorm.DB, workers, maxRetries int) {
 
 		if workers > 0 {
 			defaultService.deliverySvc = NewDeliveryService(db, workers, maxRetries)
+			defaultService.deliverySvc.Start(context.Background())
 			log.Println("Webhook manager initialized with delivery service")
 		} else {
 			log.Println("Webhook manager initialized (delivery disabled)")
@@ -43,6 +45,20 @@ func GetManager() *Manager {
 	return defaultService
 }
 
+// Shutdown drains pending deliveries of the global webhook manager
+func Shutdown(ctx context.Context) error {
+	m := GetManager()
+	if m == nil || m.deliverySvc == nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	m.enabled = false
+	m.mu.Unlock()
+
+	return m.deliverySvc.Shutdown(ctx)
+}
+
 // TriggerSaleCreated triggers a sale.created event
 func (m *Manager) TriggerSaleCreated(sale *models.Sale, product *models.Product) {
 	if !m.enabled || m.deliverySvc == nil {
@@ -86,6 +102,26 @@ func (m *Manager) TriggerProductLowStock(product *models.Product) {
 	}
 }
 
+// TriggerProductDeactivated triggers a product.deactivated event, sent to
+// the product's shop only
+func (m *Manager) TriggerProductDeactivated(product *models.Product, reason string) {
+	if !m.enabled || m.deliverySvc == nil {
+		return
+	}
+
+	data := map[string]interface{}{
+		"id":            product.ID,
+		"shop_id":       product.ShopID,
+		"name":          product.Name,
+		"current_stock": product.CurrentStock,
+		"reason":        reason,
+	}
+
+	if err := m.deliverySvc.TriggerShopEvent(product.ShopID, EventProductDeactivated, data); err != nil {
+		log.Printf("Failed to trigger product.deactivated event: %v", err)
+	}
+}
+
 // TriggerPaymentReceived triggers a payment.received event
 func (m *Manager) TriggerPaymentReceived(sale *models.Sale, product *models.Product, phone string) {
 	if !m.enabled || m.deliverySvc == nil {
@@ -238,6 +274,61 @@ func (m *Manager) TriggerPaymentFailed(sale *models.Sale, product *models.Produc
 	}
 }
 
+// TriggerMpesaPayment triggers a payment.completed, payment.failed or
+// payment.expired event for an M-Pesa STK push, sent to the paying shop only
+func (m *Manager) TriggerMpesaPayment(eventType EventType, payment *models.MpesaPayment) {
+	if !m.enabled || m.deliverySvc == nil {
+		return
+	}
+
+	data := map[string]interface{}{
+		"event":               eventType,
+		"id":                  payment.ID,
+		"shop_id":             payment.ShopID,
+		"product_id":          payment.ProductID,
+		"sale_id":             payment.SaleID,
+		"amount":              payment.Amount,
+		"phone":               payment.Phone,
+		"account_reference":   payment.AccountReference,
+		"checkout_request_id": payment.CheckoutRequestID,
+		"mpesa_receipt":       payment.MpesaReceipt,
+		"status":              payment.Status,
+		"failure_reason":      payment.FailureReason,
+		"created_at":          payment.CreatedAt,
+		"completed_at":        payment.CompletedAt,
+	}
+
+	if err := m.deliverySvc.TriggerShopEvent(payment.ShopID, eventType, data); err != nil {
+		log.Printf("Failed to trigger %s event: %v", eventType, err)
+	}
+}
+
+// TriggerPaymentLinkPaid triggers a payment_link.paid event, sent to the
+// link's shop only
+func (m *Manager) TriggerPaymentLinkPaid(link *models.PaymentLink) {
+	if !m.enabled || m.deliverySvc == nil {
+		return
+	}
+
+	data := map[string]interface{}{
+		"event":         EventPaymentLinkPaid,
+		"id":            link.ID,
+		"shop_id":       link.ShopID,
+		"token":         link.Token,
+		"amount":        link.Amount,
+		"paid_amount":   link.PaidAmount,
+		"description":   link.Description,
+		"payment_id":    link.PaymentID,
+		"phone":         link.Phone,
+		"mpesa_receipt": link.MpesaReceipt,
+		"paid_at":       link.PaidAt,
+	}
+
+	if err := m.deliverySvc.TriggerShopEvent(link.ShopID, EventPaymentLinkPaid, data); err != nil {
+		log.Printf("Failed to trigger payment_link.paid event: %v", err)
+	}
+}
+
 // TriggerCustomerTierUpgraded triggers a customer.tier_upgraded event
 func (m *Manager) TriggerCustomerTierUpgraded(customer *models.Customer, oldTier, newTier string) {
 	if !m.enabled || m.deliverySvc == nil {
@@ -281,6 +372,22 @@ func (m *Manager) TriggerOrderFulfilled(order *models.Order, supplier *models.Su
 	}
 }
 
+// SendTest queues a test event to a single webhook
+func (m *Manager) SendTest(webhook *models.Webhook) (uint, error) {
+	if m == nil || !m.enabled || m.deliverySvc == nil {
+		return 0, ErrDeliveryDisabled
+	}
+	return m.deliverySvc.SendTest(webhook)
+}
+
+// Redeliver queues an event for another delivery attempt
+func (m *Manager) Redeliver(eventID uint) error {
+	if m == nil || !m.enabled || m.deliverySvc == nil {
+		return ErrDeliveryDisabled
+	}
+	return m.deliverySvc.Redeliver(eventID)
+}
+
 // Helper functions for global access
 func TriggerSaleCreated(sale *models.Sale, product *models.Product) {
 	if m := GetManager(); m != nil {
@@ -294,6 +401,14 @@ func TriggerProductLowStock(product *models.Product) {
 	}
 }
 
+// TriggerProductDeactivated sends a product.deactivated event; a nil
+// product, for a sale or stock move that sold nothing out, sends none
+func TriggerProductDeactivated(product *models.Product, reason string) {
+	if m := GetManager(); m != nil && product != nil {
+		m.TriggerProductDeactivated(product, reason)
+	}
+}
+
 func TriggerPaymentReceived(sale *models.Sale, product *models.Product, phone string) {
 	if m := GetManager(); m != nil {
 		m.TriggerPaymentReceived(sale, product, phone)
@@ -336,6 +451,18 @@ func TriggerPaymentFailed(sale *models.Sale, product *models.Product, reason str
 	}
 }
 
+func TriggerMpesaPayment(eventType EventType, payment *models.MpesaPayment) {
+	if m := GetManager(); m != nil {
+		m.TriggerMpesaPayment(eventType, payment)
+	}
+}
+
+func TriggerPaymentLinkPaid(link *models.PaymentLink) {
+	if m := GetManager(); m != nil {
+		m.TriggerPaymentLinkPaid(link)
+	}
+}
+
 func TriggerCustomerTierUpgraded(customer *models.Customer, oldTier, newTier string) {
 	if m := GetManager(); m != nil {
 		m.TriggerCustomerTierUpgraded(customer, oldTier, newTier)
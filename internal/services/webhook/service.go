@@ -79,8 +79,10 @@ var SupportedEvents = map[string]string{
 	"product.updated":    "A product is updated",
 	"product.low_stock":  "Product stock is low",
 	"product.out_of_stock": "Product is out of stock",
+	"product.deactivated": "Product hidden after selling out",
 	"payment.completed":  "Payment received",
 	"payment.failed":     "Payment failed",
+	"payment_link.paid":  "Payment link paid",
 	"shop.upgraded":     "Shop plan upgraded",
 }
 
@@ -7,6 +7,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
 )
@@ -24,11 +25,22 @@ type Client struct {
 	shopID  uint
 	userID  uint
 	isAdmin bool
+	// writeMu serialises writes; the hub and the read loop both write
+	writeMu sync.Mutex
 }
 
+// write sends v to the client as JSON
+func (c *Client) write(v interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.WriteJSON(v)
+}
+
+// OutgoingMessage is a message for the clients of some shops, or of every
+// shop when ShopIDs is empty. Message is a Message or a dashboard event.
 type OutgoingMessage struct {
 	ShopIDs []uint
-	Message Message
+	Message interface{}
 }
 
 type Message struct {
@@ -75,7 +87,7 @@ func (h *Hub) Run() {
 			h.mutex.RLock()
 			for client := range h.clients {
 				if len(outgoing.ShopIDs) == 0 || containsShop(outgoing.ShopIDs, client.shopID) {
-					err := client.conn.WriteJSON(outgoing.Message)
+					err := client.write(outgoing.Message)
 					if err != nil {
 						client.conn.Close()
 					}
@@ -154,6 +166,56 @@ func GetHub() *Hub {
 	return defaultHub
 }
 
+// authenticate resolves a connection's ?token= to the shop it belongs to
+var authenticate func(token string) (uint, error)
+
+// SetAuthenticator sets how HandleWebSocket checks the ?token= a client
+// connects with. Without one every connection is refused.
+func SetAuthenticator(fn func(token string) (uint, error)) {
+	authenticate = fn
+}
+
+// sendEvent queues a dashboard event for a shop's clients. A sale never
+// waits on the hub, so the event is dropped when the queue is full.
+func sendEvent(shopID uint, event map[string]interface{}) {
+	if defaultHub == nil {
+		return
+	}
+	event["timestamp"] = time.Now().Unix()
+	select {
+	case defaultHub.broadcast <- &OutgoingMessage{ShopIDs: []uint{shopID}, Message: event}:
+	default:
+		log.Printf("WebSocket: queue full, dropped %s event for shop %d", event["event"], shopID)
+	}
+}
+
+// NotifySaleCreated sends a sale to every dashboard open for its shop, and
+// a stock alert when it left the product at or below its low stock
+// threshold. stockAfter is the product's stock after the sale.
+func NotifySaleCreated(sale *models.Sale, product *models.Product, stockAfter int) {
+	sendEvent(sale.ShopID, map[string]interface{}{
+		"event": "sale_created",
+		"sale":  sale,
+	})
+	if product != nil && stockAfter <= product.LowStockLevel() {
+		NotifyStockAlert(product, stockAfter)
+	}
+}
+
+// NotifyStockAlert tells a shop's dashboards that a product is running low
+func NotifyStockAlert(product *models.Product, currentStock int) {
+	sendEvent(product.ShopID, map[string]interface{}{
+		"event": "stock_alert",
+		"product": map[string]interface{}{
+			"id":            product.ID,
+			"name":          product.Name,
+			"unit":          product.Unit,
+			"current_stock": currentStock,
+			"threshold":     product.LowStockLevel(),
+		},
+	})
+}
+
 func NotifyNewSale(shopID uint, productName string, amount float64, quantity int) {
 	if defaultHub == nil {
 		return
@@ -206,6 +268,64 @@ func NotifyPaymentReceived(shopID uint, amount float64, phone string, method str
 	log.Printf("WebSocket: Notified shop %d of payment: KES %.2f via %s", shopID, amount, method)
 }
 
+// NotifyPaymentStatus tells a shop's dashboard that an M-Pesa payment was
+// completed, failed or expired
+func NotifyPaymentStatus(shopID uint, paymentID uint, checkoutID string, status string, amount float64, receipt string) {
+	if defaultHub == nil {
+		return
+	}
+	defaultHub.SendToShop(shopID, Message{
+		Type: "payment_status",
+		Payload: map[string]interface{}{
+			"payment_id":          paymentID,
+			"checkout_request_id": checkoutID,
+			"status":              status,
+			"amount":              amount,
+			"mpesa_receipt":       receipt,
+			"timestamp":           time.Now().Unix(),
+		},
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+// NotifyJobStatus tells a shop that a background job (e.g. an export) has
+// finished or failed
+func NotifyJobStatus(shopID uint, jobID string, jobType string, status string) {
+	if defaultHub == nil {
+		return
+	}
+	defaultHub.SendToShop(shopID, Message{
+		Type: "job_status",
+		Payload: map[string]interface{}{
+			"job_id":    jobID,
+			"job_type":  jobType,
+			"status":    status,
+			"timestamp": time.Now().Unix(),
+		},
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+// NotifyPaymentUnattributed asks a shop to link a completed M-Pesa payment
+// to the sale it was for
+func NotifyPaymentUnattributed(shopID uint, paymentID uint, amount float64, phone string, receipt string, reason string) {
+	if defaultHub == nil {
+		return
+	}
+	defaultHub.SendToShop(shopID, Message{
+		Type: "payment_unattributed",
+		Payload: map[string]interface{}{
+			"payment_id":    paymentID,
+			"amount":        amount,
+			"phone":         phone,
+			"mpesa_receipt": receipt,
+			"reason":        reason,
+			"timestamp":     time.Now().Unix(),
+		},
+		Timestamp: time.Now().Unix(),
+	})
+}
+
 func NotifyOrderUpdate(shopID uint, orderID uint, status string, items []string) {
 	if defaultHub == nil {
 		return
@@ -270,6 +390,8 @@ func NotifyStaffAction(shopID uint, staffName string, action string, details str
 	})
 }
 
+// HandleWebSocket upgrades a dashboard connection. The client connects
+// with ?token= (its API token) and receives the events of the token's shop.
 func HandleWebSocket(c *fiber.Ctx) error {
 	if !websocket.IsWebSocketUpgrade(c) {
 		return c.Status(http.StatusUpgradeRequired).JSON(fiber.Map{
@@ -277,34 +399,44 @@ func HandleWebSocket(c *fiber.Ctx) error {
 		})
 	}
 
-	shopID := c.Query("shop_id")
-	_ = c.Query("token")
-
-	if shopID == "" {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
-			"error": "shop_id is required",
+	token := c.Query("token")
+	if token == "" {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+			"error": "token is required",
+		})
+	}
+	if authenticate == nil {
+		return c.Status(http.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "WebSocket authentication is not configured",
+		})
+	}
+	shopID, err := authenticate(token)
+	if err != nil || shopID == 0 {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid token",
+		})
+	}
+	if requested := c.Query("shop_id"); requested != "" && parseUint(requested) != shopID {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "token is not for this shop",
 		})
 	}
 
 	wsHandler := websocket.New(func(conn *websocket.Conn) {
-		var client *Client
+		client := &Client{
+			conn:   conn,
+			shopID: shopID,
+		}
 
 		if defaultHub != nil {
-			client = &Client{
-				conn:    conn,
-				shopID:  parseUint(shopID),
-				userID:  0,
-				isAdmin: false,
-			}
 			defaultHub.Register(client)
 			defer defaultHub.Unregister(client)
-
-			conn.WriteJSON(Message{
-				Type:      "connected",
-				Payload:   map[string]interface{}{"status": "connected", "shop_id": client.shopID},
-				Timestamp: time.Now().Unix(),
-			})
 		}
+		client.write(Message{
+			Type:      "connected",
+			Payload:   map[string]interface{}{"status": "connected", "shop_id": client.shopID},
+			Timestamp: time.Now().Unix(),
+		})
 
 		for {
 			_, msg, err := conn.ReadMessage()
@@ -320,24 +452,29 @@ func HandleWebSocket(c *fiber.Ctx) error {
 
 			switch message.Type {
 			case "ping":
-				conn.WriteJSON(Message{
+				client.write(Message{
 					Type:      "pong",
 					Timestamp: time.Now().Unix(),
 				})
 			case "subscribe":
-				if client != nil && message.Payload.ShopID > 0 {
-					client.shopID = message.Payload.ShopID
-					log.Printf("Client subscribed to shop %d", client.shopID)
-					conn.WriteJSON(Message{
-						Type: "subscribed",
-						Payload: map[string]interface{}{
-							"shop_id": client.shopID,
-						},
+				// A connection only ever gets its own shop's events
+				if message.Payload.ShopID > 0 && message.Payload.ShopID != client.shopID {
+					client.write(Message{
+						Type:      "error",
+						Payload:   map[string]interface{}{"error": "token is not for this shop"},
 						Timestamp: time.Now().Unix(),
 					})
+					continue
 				}
+				client.write(Message{
+					Type: "subscribed",
+					Payload: map[string]interface{}{
+						"shop_id": client.shopID,
+					},
+					Timestamp: time.Now().Unix(),
+				})
 			case "heartbeat":
-				conn.WriteJSON(Message{
+				client.write(Message{
 					Type:      "heartbeat",
 					Timestamp: time.Now().Unix(),
 				})
@@ -0,0 +1,254 @@
+package zreport
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+)
+
+// Report is an end-of-day Z-report: what the shop took since its last
+// close, by payment method and by staff member, what it spent, and the
+// cash its drawer should hold
+type Report struct {
+	models.DayClose
+	ShopName string   `json:"shop_name"`
+	NetSales float64  `json:"net_sales"`          // gross sales less refunds
+	Net      float64  `json:"net"`                // net sales less expenses
+	Variance *float64 `json:"variance,omitempty"` // counted less expected cash; negative when short
+	Closed   bool     `json:"closed"`
+}
+
+// Service builds Z-reports and closes business days
+type Service struct {
+	closes *repository.DayCloseRepository
+}
+
+// New creates a new Z-report service
+func New(closes *repository.DayCloseRepository) *Service {
+	return &Service{closes: closes}
+}
+
+// Build returns the Z-report for the shop's open business day as of now.
+// The day runs from the shop's last close when that was since the start of
+// yesterday, so sales after a close roll into the next day, and otherwise
+// from the start of today. The drawer is expected to hold the opening
+// float plus cash sales less expenses paid in cash; counted, when given,
+// is what it held.
+func (s *Service) Build(shop *models.Shop, now time.Time, openingFloat float64, counted *float64) (*Report, error) {
+	today := now.Truncate(24 * time.Hour)
+	start := today
+	last, err := s.closes.Last(shop.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load last close: %w", err)
+	}
+	if last != nil && last.PeriodEnd.After(today.AddDate(0, 0, -1)) {
+		start = last.PeriodEnd
+	}
+
+	dc := models.DayClose{
+		ShopID:       shop.ID,
+		Number:       1,
+		BusinessDate: today,
+		PeriodStart:  start,
+		PeriodEnd:    now,
+		OpeningFloat: openingFloat,
+		CountedCash:  counted,
+	}
+	if last != nil {
+		dc.Number = last.Number + 1
+	}
+	if err := s.fill(&dc); err != nil {
+		return nil, err
+	}
+	return report(shop, dc, false), nil
+}
+
+// ForDate returns the Z-report for the business day starting at date: the
+// day's latest close, or for a past day that was never closed, all of its
+// sales. Today, unless closed, is the open day as Build gives it.
+func (s *Service) ForDate(shop *models.Shop, date, now time.Time) (*Report, error) {
+	day := date.Truncate(24 * time.Hour)
+	dc, err := s.closes.LastOn(shop.ID, day)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load close: %w", err)
+	}
+	if dc != nil {
+		return report(shop, *dc, true), nil
+	}
+	if !day.Before(now.Truncate(24 * time.Hour)) {
+		return s.Build(shop, now, 0, nil)
+	}
+
+	past := models.DayClose{
+		ShopID:       shop.ID,
+		BusinessDate: day,
+		PeriodStart:  day,
+		PeriodEnd:    day.AddDate(0, 0, 1),
+	}
+	if err := s.fill(&past); err != nil {
+		return nil, err
+	}
+	return report(shop, past, false), nil
+}
+
+// fill adds up the sales, refunds, discounts and expenses of the close's
+// period
+func (s *Service) fill(dc *models.DayClose) error {
+	sales, err := s.closes.Sales(dc.ShopID, dc.PeriodStart, dc.PeriodEnd)
+	if err != nil {
+		return fmt.Errorf("failed to load sales: %w", err)
+	}
+	dc.Refunds, dc.RefundCount, err = s.closes.Refunds(dc.ShopID, dc.PeriodStart, dc.PeriodEnd)
+	if err != nil {
+		return fmt.Errorf("failed to load refunds: %w", err)
+	}
+	dc.Discounts, err = s.closes.Discounts(dc.ShopID, dc.PeriodStart, dc.PeriodEnd)
+	if err != nil {
+		return fmt.Errorf("failed to load discounts: %w", err)
+	}
+	dc.Expenses, dc.CashExpenses, err = s.closes.Expenses(dc.ShopID, dc.PeriodStart, dc.PeriodEnd)
+	if err != nil {
+		return fmt.Errorf("failed to load expenses: %w", err)
+	}
+	tally(dc, sales)
+	dc.ExpectedCash = dc.OpeningFloat + dc.CashSales - dc.CashExpenses
+	return nil
+}
+
+// Close records the report as the shop's close for its business day;
+// later sales count toward the next one
+func (s *Service) Close(r *Report, closedBy string) error {
+	r.ClosedBy = closedBy
+	if err := s.closes.Create(&r.DayClose); err != nil {
+		return fmt.Errorf("failed to close the day: %w", err)
+	}
+	r.Closed = true
+	return nil
+}
+
+// Get returns the report of one of the shop's past closes by its Z number
+func (s *Service) Get(shop *models.Shop, number int) (*Report, error) {
+	dc, err := s.closes.GetByNumber(shop.ID, number)
+	if err != nil {
+		return nil, err
+	}
+	return report(shop, *dc, true), nil
+}
+
+// List returns the reports of the shop's latest closes, newest first
+func (s *Service) List(shop *models.Shop, limit int) ([]*Report, error) {
+	closes, err := s.closes.List(shop.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+	reports := make([]*Report, len(closes))
+	for i, dc := range closes {
+		reports[i] = report(shop, dc, true)
+	}
+	return reports, nil
+}
+
+func report(shop *models.Shop, dc models.DayClose, closed bool) *Report {
+	name := shop.Name
+	if shop.BrandName != "" {
+		name = shop.BrandName
+	}
+	r := &Report{
+		DayClose: dc,
+		ShopName: name,
+		NetSales: dc.GrossSales - dc.Refunds,
+		Net:      dc.GrossSales - dc.Refunds - dc.Expenses,
+		Closed:   closed,
+	}
+	if dc.CountedCash != nil {
+		variance := *dc.CountedCash - dc.ExpectedCash
+		r.Variance = &variance
+	}
+	return r
+}
+
+// uncategorised is the category of products without one
+const uncategorised = "Uncategorised"
+
+// tally adds up sales by payment method, staff member and product
+// category. Items paid for together in one basket are one transaction.
+func tally(dc *models.DayClose, sales []models.Sale) {
+	byStaff := map[uint]*models.StaffSalesTotal{}
+	byCategory := map[string]*models.CategorySalesTotal{}
+	seen := map[string]bool{}
+	for _, sale := range sales {
+		var staffKey uint
+		if sale.StaffID != nil {
+			staffKey = *sale.StaffID
+		}
+		staff, ok := byStaff[staffKey]
+		if !ok {
+			staff = &models.StaffSalesTotal{StaffID: sale.StaffID, Name: "Owner"}
+			if sale.Staff != nil {
+				staff.Name = sale.Staff.Name
+			}
+			byStaff[staffKey] = staff
+		}
+
+		switch sale.PaymentMethod {
+		case models.PaymentCash:
+			dc.CashSales += sale.TotalAmount
+			staff.CashSales += sale.TotalAmount
+		case models.PaymentMpesa:
+			dc.MpesaSales += sale.TotalAmount
+			staff.MpesaSales += sale.TotalAmount
+		default:
+			dc.OtherSales += sale.TotalAmount
+			staff.OtherSales += sale.TotalAmount
+		}
+		dc.GrossSales += sale.TotalAmount
+		staff.Total += sale.TotalAmount
+
+		name := sale.Product.Category
+		if name == "" {
+			name = uncategorised
+		}
+		category, ok := byCategory[name]
+		if !ok {
+			category = &models.CategorySalesTotal{Category: name}
+			byCategory[name] = category
+		}
+		category.Quantity += sale.Quantity
+		category.Total += sale.TotalAmount
+
+		transaction := fmt.Sprintf("sale:%d", sale.ID)
+		if sale.PendingSaleID != nil {
+			transaction = fmt.Sprintf("basket:%d", *sale.PendingSaleID)
+		}
+		if !seen[transaction] {
+			seen[transaction] = true
+			dc.Transactions++
+			staff.Transactions++
+		}
+	}
+
+	dc.ByStaff = make([]models.StaffSalesTotal, 0, len(byStaff))
+	for _, staff := range byStaff {
+		dc.ByStaff = append(dc.ByStaff, *staff)
+	}
+	sort.Slice(dc.ByStaff, func(i, j int) bool {
+		if dc.ByStaff[i].Total != dc.ByStaff[j].Total {
+			return dc.ByStaff[i].Total > dc.ByStaff[j].Total
+		}
+		return dc.ByStaff[i].Name < dc.ByStaff[j].Name
+	})
+
+	dc.ByCategory = make([]models.CategorySalesTotal, 0, len(byCategory))
+	for _, category := range byCategory {
+		dc.ByCategory = append(dc.ByCategory, *category)
+	}
+	sort.Slice(dc.ByCategory, func(i, j int) bool {
+		if dc.ByCategory[i].Total != dc.ByCategory[j].Total {
+			return dc.ByCategory[i].Total > dc.ByCategory[j].Total
+		}
+		return dc.ByCategory[i].Category < dc.ByCategory[j].Category
+	})
+}
@@ -2,42 +2,153 @@ package utils
 
 import (
 	"errors"
+	"log"
 	"strings"
 
+	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 )
 
+// ErrorCode is the stable, machine-readable "code" returned with API errors.
+// Clients should switch on the code rather than the message.
+type ErrorCode string
+
+const (
+	CodeInvalidRequest      ErrorCode = "INVALID_REQUEST"
+	CodeValidationError     ErrorCode = "VALIDATION_ERROR"
+	CodeInvalidQuantity     ErrorCode = "INVALID_QUANTITY"
+	CodeInvalidPrice        ErrorCode = "INVALID_PRICE"
+	CodeUnauthorized        ErrorCode = "UNAUTHORIZED"
+	CodeInvalidCredentials  ErrorCode = "INVALID_CREDENTIALS"
+	CodeTokenExpired        ErrorCode = "TOKEN_EXPIRED"
+	CodeForbidden           ErrorCode = "FORBIDDEN"
+	CodeNotFound            ErrorCode = "NOT_FOUND"
+	CodeAccountNotFound     ErrorCode = "ACCOUNT_NOT_FOUND"
+	CodeShopNotFound        ErrorCode = "SHOP_NOT_FOUND"
+	CodeProductNotFound     ErrorCode = "PRODUCT_NOT_FOUND"
+	CodeSaleNotFound        ErrorCode = "SALE_NOT_FOUND"
+	CodeMethodNotAllowed    ErrorCode = "METHOD_NOT_ALLOWED"
+	CodeConflict            ErrorCode = "CONFLICT"
+	CodeDuplicateEntry      ErrorCode = "DUPLICATE_ENTRY"
+	CodeReferenceError      ErrorCode = "REFERENCE_ERROR"
+	CodeInsufficientStock   ErrorCode = "INSUFFICIENT_STOCK"
+	CodePlanRequired        ErrorCode = "PLAN_REQUIRED"
+	CodePlanLimitReached    ErrorCode = "PLAN_LIMIT_REACHED"
+	CodeFeatureNotAvailable ErrorCode = "FEATURE_NOT_AVAILABLE"
+	CodeRateLimited         ErrorCode = "RATE_LIMITED"
+	CodePaymentFailed       ErrorCode = "PAYMENT_FAILED"
+	CodeNetworkError        ErrorCode = "NETWORK_ERROR"
+	CodeDatabaseError       ErrorCode = "DATABASE_ERROR"
+	CodeServiceUnavailable  ErrorCode = "SERVICE_UNAVAILABLE"
+	CodeInternal            ErrorCode = "INTERNAL_ERROR"
+)
+
+// SendError writes the standard error body {"error": message, "code": code}
+func SendError(c *fiber.Ctx, status int, code ErrorCode, message string) error {
+	return c.Status(status).JSON(fiber.Map{
+		"error": message,
+		"code":  code,
+	})
+}
+
+// ErrorHandler is the app-wide fiber error handler. UserErrors and fiber
+// errors are rendered with their codes; anything else is logged and returned
+// as a generic 500 so internals don't leak to clients.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	var userErr *UserError
+	if errors.As(err, &userErr) {
+		return SendError(c, userErr.HTTPStatus(), userErr.Code, userErr.Message)
+	}
+
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return SendError(c, fiberErr.Code, CodeForStatus(fiberErr.Code), fiberErr.Message)
+	}
+
+	log.Printf("❌ Unhandled error on %s %s: %v", c.Method(), c.Path(), err)
+	return SendError(c, fiber.StatusInternalServerError, CodeInternal, "Internal server error")
+}
+
+// CodeForStatus is the fallback code for errors that only carry an HTTP status
+func CodeForStatus(status int) ErrorCode {
+	switch status {
+	case fiber.StatusUnauthorized:
+		return CodeUnauthorized
+	case fiber.StatusForbidden:
+		return CodeForbidden
+	case fiber.StatusNotFound:
+		return CodeNotFound
+	case fiber.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case fiber.StatusConflict:
+		return CodeConflict
+	case fiber.StatusUnprocessableEntity:
+		return CodeValidationError
+	case fiber.StatusTooManyRequests:
+		return CodeRateLimited
+	case fiber.StatusNotImplemented, fiber.StatusServiceUnavailable:
+		return CodeServiceUnavailable
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeInvalidRequest
+}
+
 type UserError struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
-	Field   string `json:"field,omitempty"`
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	Field   string    `json:"field,omitempty"`
 }
 
 func (e *UserError) Error() string {
 	return e.Message
 }
 
-func NewUserError(code, message string) *UserError {
+// HTTPStatus is the response status for the error's code
+func (e *UserError) HTTPStatus() int {
+	switch e.Code {
+	case CodeValidationError, CodeInvalidRequest, CodeInvalidQuantity, CodeInvalidPrice:
+		return fiber.StatusBadRequest
+	case CodeUnauthorized, CodeInvalidCredentials, CodeTokenExpired:
+		return fiber.StatusUnauthorized
+	case CodeForbidden, CodePlanRequired, CodePlanLimitReached, CodeFeatureNotAvailable:
+		return fiber.StatusForbidden
+	case CodeNotFound, CodeAccountNotFound, CodeShopNotFound, CodeProductNotFound, CodeSaleNotFound:
+		return fiber.StatusNotFound
+	case CodeConflict, CodeDuplicateEntry, CodeReferenceError, CodeInsufficientStock:
+		return fiber.StatusConflict
+	case CodeRateLimited:
+		return fiber.StatusTooManyRequests
+	case CodePaymentFailed, CodeNetworkError:
+		return fiber.StatusBadGateway
+	case CodeServiceUnavailable:
+		return fiber.StatusServiceUnavailable
+	}
+	return fiber.StatusInternalServerError
+}
+
+func NewUserError(code ErrorCode, message string) *UserError {
 	return &UserError{Code: code, Message: message}
 }
 
-func NewFieldError(code, message, field string) *UserError {
+func NewFieldError(code ErrorCode, message, field string) *UserError {
 	return &UserError{Code: code, Message: message, Field: field}
 }
 
 var (
-	ErrInvalidCredentials  = NewUserError("INVALID_CREDENTIALS", "Invalid phone/email or password. Please check your credentials and try again.")
-	ErrAccountNotFound     = NewUserError("ACCOUNT_NOT_FOUND", "Account not found. Please check your phone number or register a new account.")
-	ErrShopNotFound        = NewUserError("SHOP_NOT_FOUND", "Shop not found. The shop may have been deleted or you may not have access.")
-	ErrProductNotFound     = NewUserError("PRODUCT_NOT_FOUND", "Product not found. It may have been deleted or the ID is incorrect.")
-	ErrInsufficientStock   = NewUserError("INSUFFICIENT_STOCK", "Not enough stock. The requested quantity exceeds available inventory.")
-	ErrInvalidQuantity     = NewUserError("INVALID_QUANTITY", "Invalid quantity. Please enter a valid number greater than zero.")
-	ErrInvalidPrice        = NewUserError("INVALID_PRICE", "Invalid price. Please enter a valid amount greater than zero.")
-	ErrUnauthorized        = NewUserError("UNAUTHORIZED", "You are not authorized to perform this action. Please log in and try again.")
-	ErrPaymentFailed       = NewUserError("PAYMENT_FAILED", "Payment failed. Please try again or use a different payment method.")
-	ErrNetworkError        = NewUserError("NETWORK_ERROR", "Network error. Please check your connection and try again.")
-	ErrRateLimited         = NewUserError("RATE_LIMITED", "Too many requests. Please wait a moment before trying again.")
-	ErrFeatureNotAvailable = NewUserError("FEATURE_NOT_AVAILABLE", "This feature is not available on your current plan. Upgrade to unlock!")
+	ErrInvalidCredentials  = NewUserError(CodeInvalidCredentials, "Invalid phone/email or password. Please check your credentials and try again.")
+	ErrAccountNotFound     = NewUserError(CodeAccountNotFound, "Account not found. Please check your phone number or register a new account.")
+	ErrShopNotFound        = NewUserError(CodeShopNotFound, "Shop not found. The shop may have been deleted or you may not have access.")
+	ErrProductNotFound     = NewUserError(CodeProductNotFound, "Product not found. It may have been deleted or the ID is incorrect.")
+	ErrInsufficientStock   = NewUserError(CodeInsufficientStock, "Not enough stock. The requested quantity exceeds available inventory.")
+	ErrInvalidQuantity     = NewUserError(CodeInvalidQuantity, "Invalid quantity. Please enter a valid number greater than zero.")
+	ErrInvalidPrice        = NewUserError(CodeInvalidPrice, "Invalid price. Please enter a valid amount greater than zero.")
+	ErrUnauthorized        = NewUserError(CodeUnauthorized, "You are not authorized to perform this action. Please log in and try again.")
+	ErrPaymentFailed       = NewUserError(CodePaymentFailed, "Payment failed. Please try again or use a different payment method.")
+	ErrNetworkError        = NewUserError(CodeNetworkError, "Network error. Please check your connection and try again.")
+	ErrRateLimited         = NewUserError(CodeRateLimited, "Too many requests. Please wait a moment before trying again.")
+	ErrFeatureNotAvailable = NewUserError(CodeFeatureNotAvailable, "This feature is not available on your current plan. Upgrade to unlock!")
 )
 
 func HandleDBError(err error) *UserError {
@@ -45,14 +156,14 @@ func HandleDBError(err error) *UserError {
 		return ErrProductNotFound
 	}
 	if strings.Contains(err.Error(), "duplicate key") {
-		return NewUserError("DUPLICATE_ENTRY", "This record already exists. Please use a different value.")
+		return NewUserError(CodeDuplicateEntry, "This record already exists. Please use a different value.")
 	}
 	if strings.Contains(err.Error(), "foreign key constraint") {
-		return NewUserError("REFERENCE_ERROR", "This record is linked to other data and cannot be deleted.")
+		return NewUserError(CodeReferenceError, "This record is linked to other data and cannot be deleted.")
 	}
-	return NewUserError("DATABASE_ERROR", "Something went wrong. Please try again later.")
+	return NewUserError(CodeDatabaseError, "Something went wrong. Please try again later.")
 }
 
 func HandleValidationError(field, message string) *UserError {
-	return NewFieldError("VALIDATION_ERROR", message, field)
+	return NewFieldError(CodeValidationError, message, field)
 }
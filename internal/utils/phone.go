@@ -0,0 +1,41 @@
+package utils
+
+import "strings"
+
+// NormalizePhone returns phone in the one format shops are stored under,
+// "+254712345678". Kenyan numbers are accepted as "+254...", "254...",
+// "0712..." or "712...", with spaces, dashes, dots or brackets. Other
+// numbers starting with "+" or "00" keep their country code. Anything else,
+// such as an email address, is returned trimmed but otherwise unchanged.
+func NormalizePhone(phone string) string {
+	phone = strings.TrimSpace(phone)
+
+	var digits strings.Builder
+	for i, c := range phone {
+		switch {
+		case c >= '0' && c <= '9':
+			digits.WriteRune(c)
+		case c == '+' && i == 0, c == ' ', c == '-', c == '.', c == '(', c == ')':
+		default:
+			return phone
+		}
+	}
+	d := digits.String()
+	if d == "" {
+		return phone
+	}
+
+	switch {
+	case strings.HasPrefix(phone, "+"):
+		return "+" + d
+	case strings.HasPrefix(d, "00") && len(d) > 4:
+		return "+" + d[2:]
+	case strings.HasPrefix(d, "254") && len(d) == 12:
+		return "+" + d
+	case strings.HasPrefix(d, "0") && len(d) == 10:
+		return "+254" + d[1:]
+	case (d[0] == '7' || d[0] == '1') && len(d) == 9:
+		return "+254" + d
+	}
+	return phone
+}
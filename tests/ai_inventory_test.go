@@ -0,0 +1,108 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/ai"
+)
+
+// TestInventoryTurnoverAndDeadStock tests turnover ratios, movement flags and the dead stock report
+func TestInventoryTurnoverAndDeadStock(t *testing.T) {
+	db := openTestDB(t, &models.Product{}, &models.Sale{}, &models.DailySummary{}, &models.WeeklySummary{}, &models.MonthlySummary{})
+
+	now := time.Now()
+	longAgo := now.AddDate(0, 0, -90)
+
+	products := map[string]*models.Product{
+		"Sugar":  {ShopID: 1, Name: "Sugar", CostPrice: 100, SellingPrice: 130, CurrentStock: 10, IsActive: true, CreatedAt: longAgo},
+		"Bread":  {ShopID: 1, Name: "Bread", CostPrice: 50, SellingPrice: 60, CurrentStock: 2, IsActive: true, CreatedAt: longAgo},
+		"Salt":   {ShopID: 1, Name: "Salt", CostPrice: 20, SellingPrice: 30, CurrentStock: 30, IsActive: true, CreatedAt: longAgo},
+		"Soap":   {ShopID: 1, Name: "Soap", CostPrice: 80, SellingPrice: 100, CurrentStock: 12, IsActive: true, CreatedAt: longAgo},
+		"Omo":    {ShopID: 1, Name: "Omo", CostPrice: 150, SellingPrice: 180, CurrentStock: 0, IsActive: true, CreatedAt: longAgo},
+		"Milk":   {ShopID: 1, Name: "Milk", CostPrice: 55, SellingPrice: 65, CurrentStock: 5, IsActive: true, CreatedAt: now.AddDate(0, 0, -2)},
+		"Bundle": {ShopID: 1, Name: "Bundle", SellingPrice: 200, IsActive: true, IsBundle: true, CreatedAt: longAgo},
+	}
+	for _, p := range products {
+		if err := db.Create(p).Error; err != nil {
+			t.Fatalf("failed to create product: %v", err)
+		}
+	}
+
+	sell := func(name string, qty int, at time.Time) {
+		p := products[name]
+		sale := &models.Sale{
+			ShopID:      1,
+			ProductID:   p.ID,
+			Quantity:    qty,
+			UnitPrice:   p.SellingPrice,
+			TotalAmount: p.SellingPrice * float64(qty),
+			CostAmount:  p.CostPrice * float64(qty),
+			CreatedAt:   at,
+		}
+		if err := db.Create(sale).Error; err != nil {
+			t.Fatalf("failed to create sale: %v", err)
+		}
+	}
+	sell("Sugar", 10, now.AddDate(0, 0, -5))
+	sell("Bread", 40, now.AddDate(0, 0, -3))
+	sell("Salt", 3, now.AddDate(0, 0, -10))
+	sell("Soap", 4, now.AddDate(0, 0, -45)) // outside the 30 day window
+
+	svc := ai.NewPredictionService(repository.NewProductRepository(db), repository.NewSaleRepository(db), nil)
+
+	report, err := svc.GetInventoryTurnover(1, 30, ai.DefaultTurnoverThresholds)
+	if err != nil {
+		t.Fatalf("GetInventoryTurnover() error: %v", err)
+	}
+
+	expected := map[string]struct {
+		ratio    float64
+		movement string
+	}{
+		"Sugar": {0.67, ai.MovementNormal},     // 1000 / ((2000 + 1000) / 2)
+		"Bread": {1.82, ai.MovementFastMoving}, // 2000 / ((2100 + 100) / 2)
+		"Salt":  {0.1, ai.MovementSlowMoving},  // 60 / ((660 + 600) / 2)
+		"Soap":  {0, ai.MovementDeadStock},
+		"Omo":   {0, ai.MovementNormal},
+		"Milk":  {0, ai.MovementNormal},
+	}
+
+	if len(report.Products) != len(expected) {
+		t.Errorf("expected %d products (bundles excluded), got %d", len(expected), len(report.Products))
+	}
+	for _, p := range report.Products {
+		want, ok := expected[p.ProductName]
+		if !ok {
+			t.Errorf("unexpected product %s in report", p.ProductName)
+			continue
+		}
+		if p.TurnoverRatio != want.ratio || p.Movement != want.movement {
+			t.Errorf("%s: ratio %.2f %s; want %.2f %s", p.ProductName, p.TurnoverRatio, p.Movement, want.ratio, want.movement)
+		}
+	}
+	if report.Products[0].ProductName != "Bread" {
+		t.Errorf("report should be sorted by turnover, first is %s", report.Products[0].ProductName)
+	}
+	if report.DeadStockCount != 1 || report.SlowMovingCount != 1 || report.FastMovingCount != 1 {
+		t.Errorf("counts dead=%d slow=%d fast=%d; want 1 each", report.DeadStockCount, report.SlowMovingCount, report.FastMovingCount)
+	}
+
+	dead, err := svc.GetDeadStock(1, 30)
+	if err != nil {
+		t.Fatalf("GetDeadStock() error: %v", err)
+	}
+	if len(dead.Products) != 1 || dead.Products[0].ProductName != "Soap" {
+		t.Fatalf("dead stock = %+v; want only Soap", dead.Products)
+	}
+	if dead.TotalValue != 960 {
+		t.Errorf("dead stock value = %.2f; want 960", dead.TotalValue)
+	}
+
+	dead, _ = svc.GetDeadStock(1, 60)
+	if len(dead.Products) != 0 {
+		t.Errorf("Soap sold within 60 days and should not be dead stock, got %+v", dead.Products)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/ai"
+)
+
+// dailySeries is a sale a day for the days before now, oldest first
+func dailySeries(now time.Time, days int, qty func(i int, day time.Time) int) []ai.SalesData {
+	data := make([]ai.SalesData, 0, days)
+	for i := 0; i < days; i++ {
+		day := now.AddDate(0, 0, i-days)
+		if q := qty(i, day); q > 0 {
+			data = append(data, ai.SalesData{Date: day, Quantity: q})
+		}
+	}
+	return data
+}
+
+// TestDemandModel tests trend direction, stockout days and order
+// quantities on synthetic four-week sales series
+func TestDemandModel(t *testing.T) {
+	now := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC) // a Saturday
+
+	tests := []struct {
+		name  string
+		qty   func(i int, day time.Time) int
+		trend string
+		stock int
+		// the range of days until stockout and of the order with that stock
+		minStockout, maxStockout int
+		minOrder, maxOrder       int
+	}{
+		// 10 a day: 35 lasts 3 days, and a week's 70 plus 20-50% safety is ordered
+		{"flat", func(int, time.Time) int { return 10 }, "stable", 20, 2, 2, 64, 85},
+		// 5 rising to 32: the next days sell 33, 34, 35, so 100 runs out on the third
+		{"growing", func(i int, _ time.Time) int { return 5 + i }, "up", 100, 2, 2, 170, 250},
+		// 40 falling to 13: the next days sell 12, 11, 10, so 30 lasts two days
+		{"declining", func(i int, _ time.Time) int { return 40 - i }, "down", 30, 2, 2, 20, 60},
+	}
+	for _, tt := range tests {
+		sales := dailySeries(now, 28, tt.qty)
+		model := ai.FitDemand(sales, ai.LearnSeasonality(sales, now), now)
+
+		if model.Days != 28 {
+			t.Errorf("%s: days = %d; want 28", tt.name, model.Days)
+		}
+		if got := model.Trend(); got != tt.trend {
+			t.Errorf("%s: trend = %s (%.2f); want %s", tt.name, got, model.Change, tt.trend)
+		}
+		if model.Confidence < 0.9 {
+			t.Errorf("%s: confidence = %.2f; want high for a clean series", tt.name, model.Confidence)
+		}
+		if got := model.DaysUntilStockout(tt.stock); got < tt.minStockout || got > tt.maxStockout {
+			t.Errorf("%s: days until stockout of %d = %d; want %d-%d", tt.name, tt.stock, got, tt.minStockout, tt.maxStockout)
+		}
+		if got := model.RecommendedOrder(tt.stock); got < tt.minOrder || got > tt.maxOrder {
+			t.Errorf("%s: order with %d in stock = %d; want %d-%d", tt.name, tt.stock, got, tt.minOrder, tt.maxOrder)
+		}
+	}
+
+	// Flat demand is projected as is
+	flat := ai.FitDemand(dailySeries(now, 28, func(int, time.Time) int { return 10 }), ai.Seasonality{}, now)
+	if got := flat.DaysUntilStockout(35); got != 3 {
+		t.Errorf("flat: days until stockout of 35 = %d; want 3", got)
+	}
+	if got := flat.RecommendedOrder(500); got != 0 {
+		t.Errorf("flat: order with 500 in stock = %d; want 0", got)
+	}
+
+	// Sales dying out never run the stock out, and need no order
+	dying := ai.FitDemand(dailySeries(now, 28, func(i int, _ time.Time) int { return 28 - i }), ai.Seasonality{}, now)
+	if got := dying.DaysUntilStockout(50); got != 999 {
+		t.Errorf("dying out: days until stockout of 50 = %d; want 999", got)
+	}
+	if got := dying.RecommendedOrder(50); got != 0 {
+		t.Errorf("dying out: order with 50 in stock = %d; want 0", got)
+	}
+}
+
+// TestDemandModelWeeklySeasonality tests that weekend spikes are learned
+// from the shop's history rather than read as a trend or as noise
+func TestDemandModelWeeklySeasonality(t *testing.T) {
+	now := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC) // a Saturday
+	sales := dailySeries(now, 28, func(_ int, day time.Time) int {
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			return 30
+		}
+		return 10
+	})
+
+	seasonality := ai.LearnSeasonality(sales, now)
+	weekday, weekend := seasonality.Factor(time.Wednesday), seasonality.Factor(time.Saturday)
+	if weekend/weekday < 2.9 || weekend/weekday > 3.1 {
+		t.Errorf("weekend factor %.2f, weekday %.2f; want weekends 3 times busier", weekend, weekday)
+	}
+	if week := 5*weekday + 2*weekend; week < 6.99 || week > 7.01 {
+		t.Errorf("factors over a week = %.2f; want 7", week)
+	}
+	if got := ai.LearnSeasonality(sales[len(sales)-10:], now); got != (ai.Seasonality{}) {
+		t.Errorf("seasonality from 10 days = %v; want none", got)
+	}
+
+	model := ai.FitDemand(sales, seasonality, now)
+	plain := ai.FitDemand(sales, ai.Seasonality{}, now)
+	if model.Trend() != "stable" {
+		t.Errorf("trend = %s (%.2f); want stable", model.Trend(), model.Change)
+	}
+	if model.Confidence < 0.9 || model.Confidence <= plain.Confidence {
+		t.Errorf("confidence = %.2f, %.2f without seasonality; want the weekly pattern to explain the spikes", model.Confidence, plain.Confidence)
+	}
+	if week := model.Forecast(7); week < 109 || week > 111 {
+		t.Errorf("week forecast = %.1f; want 110", week)
+	}
+	// Saturday and Sunday sell 30 each, so 35 runs out tomorrow, where the
+	// plain average of about 16 a day would say two days
+	if got := model.DaysUntilStockout(35); got != 1 {
+		t.Errorf("days until stockout of 35 = %d; want 1", got)
+	}
+	if got := plain.DaysUntilStockout(35); got != 2 {
+		t.Errorf("days until stockout of 35 without seasonality = %d; want 2", got)
+	}
+}
+
+// TestGetPredictions tests predictions from sales history in the database
+func TestGetPredictions(t *testing.T) {
+	db := openTestDB(t, &models.Product{}, &models.Sale{}, &models.DailySummary{}, &models.WeeklySummary{}, &models.MonthlySummary{})
+	now := time.Now()
+
+	bread := &models.Product{ShopID: 1, Name: "Bread", SellingPrice: 60, CurrentStock: 25, IsActive: true}
+	milk := &models.Product{ShopID: 1, Name: "Milk", SellingPrice: 65, CurrentStock: 10, IsActive: true}
+	db.Create(bread)
+	db.Create(milk)
+	for i := 1; i <= 21; i++ {
+		db.Create(&models.Sale{ShopID: 1, ProductID: bread.ID, Quantity: 10, TotalAmount: 600, CreatedAt: now.AddDate(0, 0, -i)})
+	}
+	db.Create(&models.Sale{ShopID: 1, ProductID: milk.ID, Quantity: 4, TotalAmount: 260, CreatedAt: now.AddDate(0, 0, -3)})
+
+	svc := ai.NewPredictionService(repository.NewProductRepository(db), repository.NewSaleRepository(db), nil)
+	report, err := svc.GetPredictions(1)
+	if err != nil {
+		t.Fatalf("GetPredictions() error: %v", err)
+	}
+	preds := map[string]ai.ProductPrediction{}
+	for _, p := range report.Predictions {
+		preds[p.ProductName] = p
+	}
+
+	p := preds["Bread"]
+	if p.AvgDailySales != 10 || p.Trend != "stable" || p.DaysUntilStockout != 2 || p.Priority != "urgent" {
+		t.Errorf("Bread = %+v; want 10 a day, stable, out in 2 days, urgent", p)
+	}
+	if p.RecommendedOrder < 55 || p.RecommendedOrder > 65 {
+		t.Errorf("Bread order = %d; want a week's 70 plus safety stock less the 25 in stock", p.RecommendedOrder)
+	}
+	if got := svc.RecommendedOrder(bread); got != p.RecommendedOrder {
+		t.Errorf("RecommendedOrder(Bread) = %d; want %d", got, p.RecommendedOrder)
+	}
+
+	if p := preds["Milk"]; p.Trend != "insufficient_data" || p.RecommendedOrder != 0 {
+		t.Errorf("Milk = %+v; want insufficient data with 3 days of history", p)
+	}
+	if report.UrgentCount != 1 || report.HealthyCount != 1 {
+		t.Errorf("urgent %d, healthy %d; want 1 and 1", report.UrgentCount, report.HealthyCount)
+	}
+}
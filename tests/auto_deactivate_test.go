@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+)
+
+// TestAutoDeactivateZeroStock tests that a sold out product is hidden when
+// the shop setting is on, and comes back when restocked with add
+func TestAutoDeactivateZeroStock(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.ProductAlias{}, &models.StockMovement{}, &models.Sale{}, &models.DailySummary{}, &models.AuditLog{}, &models.PriceHistory{})
+
+	shop := &models.Shop{Name: "Duka", Phone: "+254712345678", Plan: models.PlanFree, IsActive: true, AutoDeactivateZeroStock: true}
+	other := &models.Shop{Name: "Kiosk", Phone: "+254700000000", Plan: models.PlanFree, IsActive: true}
+	db.Create(shop)
+	db.Create(other)
+
+	bread := &models.Product{ShopID: shop.ID, Name: "Bread", SellingPrice: 60, CurrentStock: 2, IsActive: true}
+	milk := &models.Product{ShopID: other.ID, Name: "Milk", SellingPrice: 55, CurrentStock: 1, IsActive: true}
+	db.Create(bread)
+	db.Create(milk)
+
+	productRepo := repository.NewProductRepository(db)
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		productRepo,
+		repository.NewSaleRepository(db),
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	parser := services.NewCommandParser(nil, nil)
+	send := func(phone, message string) {
+		if _, err := cmdHandler.Handle(phone, parser.Parse(message)); err != nil {
+			t.Fatalf("Handle(%q) error: %v", message, err)
+		}
+	}
+
+	send(shop.Phone, "sell bread 1")
+	if p, _ := productRepo.GetByID(bread.ID); !p.IsActive {
+		t.Fatal("product deactivated before selling out")
+	}
+
+	send(shop.Phone, "sell bread 1")
+	p, _ := productRepo.GetByID(bread.ID)
+	if p.IsActive || !p.AutoDeactivated {
+		t.Fatalf("sold out product active = %v, auto_deactivated = %v; want false, true", p.IsActive, p.AutoDeactivated)
+	}
+	if products, _ := productRepo.GetByShopID(shop.ID); len(products) != 0 {
+		t.Errorf("sold out product still listed: %+v", products)
+	}
+
+	// Shops without the setting keep sold out products
+	if soldOut, err := productRepo.UpdateStock(milk.ID, -1); err != nil || soldOut != nil {
+		t.Fatalf("UpdateStock() = %v, %v; want nothing deactivated", soldOut, err)
+	}
+	if p, _ := productRepo.GetByID(milk.ID); !p.IsActive {
+		t.Error("product deactivated for a shop without the setting")
+	}
+
+	// The product a sale sells out comes back for the caller's event
+	eggs := &models.Product{ShopID: shop.ID, Name: "Eggs", SellingPrice: 15, CurrentStock: 1, IsActive: true}
+	db.Create(eggs)
+	soldOut, err := repository.NewSaleRepository(db).RecordSale(&models.Sale{ShopID: shop.ID, ProductID: eggs.ID, Quantity: 1, UnitPrice: 15, TotalAmount: 15})
+	if err != nil || soldOut == nil || soldOut.ID != eggs.ID || soldOut.IsActive {
+		t.Errorf("RecordSale() = %+v, %v; want eggs, deactivated", soldOut, err)
+	}
+
+	send(shop.Phone, "add bread 65 10")
+	p, _ = productRepo.GetByID(bread.ID)
+	if !p.IsActive || p.AutoDeactivated || p.CurrentStock != 10 || p.SellingPrice != 65 {
+		t.Errorf("restocked product = active %v, auto_deactivated %v, stock %d, price %v; want true, false, 10, 65",
+			p.IsActive, p.AutoDeactivated, p.CurrentStock, p.SellingPrice)
+	}
+
+	var count int64
+	db.Model(&models.Product{}).Where("shop_id = ? AND name = ?", shop.ID, "Bread").Count(&count)
+	if count != 1 {
+		t.Errorf("restocking created a duplicate product: %d rows", count)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/supplier"
+)
+
+// fixedPredictor recommends the same quantity for every product
+type fixedPredictor int
+
+func (p fixedPredictor) RecommendedOrder(*models.Product) int { return int(p) }
+
+// TestAutoOrderDrafts tests drafting low stock orders from the cheapest
+// supplier and confirming them over WhatsApp
+func TestAutoOrderDrafts(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.Supplier{}, &models.SupplierProduct{},
+		&models.Order{}, &models.OrderItem{}, &models.Sale{}, &models.DailySummary{}, &models.AuditLog{})
+
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanPro, IsActive: true}
+	db.Create(shop)
+	soda := &models.Product{ShopID: shop.ID, Name: "Soda", Unit: "bottle", CurrentStock: 3, LowStockThreshold: 10,
+		PurchaseUnit: "crate", UnitsPerPurchase: 24}
+	bread := &models.Product{ShopID: shop.ID, Name: "Bread", CurrentStock: 2, LowStockThreshold: 5, UnitsPerPurchase: 1}
+	db.Create(soda)
+	db.Create(bread)
+
+	pricey := &models.Supplier{ShopID: shop.ID, Name: "Pricey Ltd", Phone: "+254700000001"}
+	cheap := &models.Supplier{ShopID: shop.ID, Name: "Cheap Wholesalers", Phone: "+254700000002"}
+	db.Create(pricey)
+	db.Create(cheap)
+
+	linkRepo := repository.NewSupplierProductRepository(db)
+	orderRepo := repository.NewOrderRepository(db)
+	for _, link := range []*models.SupplierProduct{
+		{ShopID: shop.ID, SupplierID: pricey.ID, ProductID: soda.ID, UnitCost: 45},
+		{ShopID: shop.ID, SupplierID: cheap.ID, ProductID: soda.ID, UnitCost: 40},
+	} {
+		if err := linkRepo.Save(link); err != nil {
+			t.Fatalf("Save() error: %v", err)
+		}
+	}
+
+	autoOrders := supplier.NewAutoOrderService(linkRepo, orderRepo, fixedPredictor(30))
+	products := []models.Product{*soda, *bread}
+
+	free := *shop
+	free.Plan = models.PlanFree
+	if notices, _ := autoOrders.CreateDraftOrders(&free, products); len(notices) != 0 {
+		t.Errorf("free plan notices = %v; want none", notices)
+	}
+
+	notices, err := autoOrders.CreateDraftOrders(shop, products)
+	if err != nil {
+		t.Fatalf("CreateDraftOrders() error: %v", err)
+	}
+	if len(notices) != 1 {
+		t.Fatalf("notices = %v; want one for soda, bread has no supplier", notices)
+	}
+	if !strings.Contains(notices[0], "Soda x48 from Cheap Wholesalers") {
+		t.Errorf("notice = %q; want 30 rounded up to 2 crates from the cheapest supplier", notices[0])
+	}
+
+	orders, _ := orderRepo.GetByStatus(shop.ID, models.OrderStatusDraft)
+	if len(orders) != 1 || orders[0].SupplierID != cheap.ID || orders[0].TotalAmount != 48*40 {
+		t.Fatalf("draft orders = %+v; want one from cheap supplier for KSh 1920", orders)
+	}
+
+	if notices, _ := autoOrders.CreateDraftOrders(shop, products); len(notices) != 0 {
+		t.Errorf("second run notices = %v; want none while the draft is open", notices)
+	}
+
+	var sent []string
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		repository.NewProductRepository(db),
+		repository.NewSaleRepository(db),
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	cmdHandler.SetSupplierRepo(repository.NewSupplierRepository(db), orderRepo)
+	cmdHandler.SetSupplierSender(func(phone, message string) error {
+		sent = append(sent, phone+": "+message)
+		return nil
+	})
+	parser := services.NewCommandParser(nil, nil)
+
+	reply, err := cmdHandler.Handle(shop.Phone, parser.Parse(fmt.Sprintf("order confirm %d", orders[0].ID)))
+	if err != nil {
+		t.Fatalf("order confirm error: %v", err)
+	}
+	if !strings.Contains(reply, "sent to Cheap Wholesalers") {
+		t.Errorf("reply = %q; want confirmation", reply)
+	}
+	if len(sent) != 1 || !strings.HasPrefix(sent[0], cheap.Phone) || !strings.Contains(sent[0], "Soda x48") {
+		t.Errorf("supplier messages = %v; want the order sent to the cheap supplier", sent)
+	}
+
+	order, _ := orderRepo.GetByID(orders[0].ID)
+	if order.Status != models.OrderStatusPending {
+		t.Errorf("status = %q; want pending", order.Status)
+	}
+
+	reply, _ = cmdHandler.Handle(shop.Phone, parser.Parse(fmt.Sprintf("order confirm %d", orders[0].ID)))
+	if !strings.Contains(reply, "already pending") {
+		t.Errorf("second confirm reply = %q; want already pending", reply)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	billinghandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/billing"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/billing"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/storage"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestPlanPaymentIssuesInvoice tests that a paid upgrade moves the account
+// and its shops to the plan once the STK callback lands, and issues an
+// invoice whose PDF can be downloaded
+func TestPlanPaymentIssuesInvoice(t *testing.T) {
+	var prompts []map[string]interface{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/v1/generate", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "test-token", "expires_in": "3599"})
+	})
+	mux.HandleFunc("/mpesa/stkpush/v1/processrequest", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		prompts = append(prompts, body)
+		json.NewEncoder(w).Encode(map[string]string{
+			"MerchantRequestID": "mr_1",
+			"CheckoutRequestID": "ws_CO_PLAN",
+			"ResponseCode":      "0",
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	db := openTestDB(t, &models.Account{}, &models.Shop{}, &models.Invoice{},
+		&models.MpesaPayment{}, &models.MpesaPaymentAttempt{}, &models.MpesaTransaction{})
+
+	account := &models.Account{Email: "wanjiku@example.com", Name: "Wanjiku", Phone: "+254712345678", Plan: models.PlanFree, IsActive: true}
+	if err := db.Create(account).Error; err != nil {
+		t.Fatalf("failed to create account: %v", err)
+	}
+	shop := &models.Shop{AccountID: account.ID, Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanFree, IsActive: true}
+	second := &models.Shop{AccountID: account.ID, Name: "Mama Mboga 2", Phone: "+254712345679", Plan: models.PlanFree, IsActive: true}
+	for _, s := range []*models.Shop{shop, second} {
+		if err := db.Create(s).Error; err != nil {
+			t.Fatalf("failed to create shop: %v", err)
+		}
+	}
+
+	invoiceRepo := repository.NewInvoiceRepository(db)
+	billingSvc := billing.NewService(db, invoiceRepo, storage.NewLocalStore(t.TempDir()))
+	mpesaSvc := mpesa.New(&mpesa.Config{
+		ConsumerKey:    "key",
+		ConsumerSecret: "secret",
+		Shortcode:      testShortcode,
+		Passkey:        testPasskey,
+		BaseURL:        server.URL,
+	}, repository.NewMpesaPaymentRepository(db), repository.NewMpesaTransactionRepository(db))
+	mpesaSvc.SetPlanPaymentHandler(billingSvc)
+
+	handler := billinghandler.NewHandler(db, nil)
+	handler.SetBilling(billingSvc, mpesaSvc, invoiceRepo)
+
+	newApp := func(shop *models.Shop) *fiber.App {
+		app := fiber.New()
+		app.Use(func(c *fiber.Ctx) error {
+			c.Locals("shop_id", shop.ID)
+			c.Locals("shop", shop)
+			return c.Next()
+		})
+		app.Post("/billing/upgrade", handler.UpgradePlan)
+		app.Get("/billing/invoices", handler.ListInvoices)
+		app.Get("/billing/invoices/:id/download", handler.DownloadInvoice)
+		return app
+	}
+	app := newApp(shop)
+
+	req := httptest.NewRequest("POST", "/billing/upgrade", strings.NewReader(`{"plan_id":"pro","phone":"0712345678"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req)
+	if resp.StatusCode != fiber.StatusAccepted {
+		t.Fatalf("upgrade status = %d; want 202", resp.StatusCode)
+	}
+	if len(prompts) != 1 || prompts[0]["Amount"] != float64(500) {
+		t.Fatalf("expected one KES 500 prompt, got %v", prompts)
+	}
+
+	// Nothing changes until the payment completes
+	var reloaded models.Account
+	db.First(&reloaded, account.ID)
+	if reloaded.Plan != models.PlanFree {
+		t.Errorf("plan before payment = %s; want free", reloaded.Plan)
+	}
+
+	callback := []byte(`{"Body":{"stkCallback":{"CheckoutRequestID":"ws_CO_PLAN","ResultCode":0,"ResultDesc":"done",
+		"CallbackMetadata":{"Item":[{"Name":"MpesaReceiptNumber","Value":"QKL1234567"}]}}}}`)
+	payment, err := mpesaSvc.ProcessSTKCallback(callback)
+	if err != nil {
+		t.Fatalf("ProcessSTKCallback() error: %v", err)
+	}
+	// A resent callback must not issue a second invoice
+	mpesaSvc.ProcessSTKCallback(callback)
+	if err := billingSvc.CompletePlanPayment(payment); err != nil {
+		t.Fatalf("repeat CompletePlanPayment() error: %v", err)
+	}
+
+	db.First(&reloaded, account.ID)
+	if reloaded.Plan != models.PlanPro {
+		t.Errorf("account plan = %s; want pro", reloaded.Plan)
+	}
+	var proShops int64
+	db.Model(&models.Shop{}).Where("account_id = ? AND plan = ?", account.ID, models.PlanPro).Count(&proShops)
+	if proShops != 2 {
+		t.Errorf("shops on pro = %d; want 2", proShops)
+	}
+
+	invoices, total, _ := invoiceRepo.GetByAccount(account.ID, 10, 0)
+	if total != 1 {
+		t.Fatalf("invoices = %d; want 1", total)
+	}
+	invoice := invoices[0]
+	if invoice.Amount != 500 || invoice.Plan != models.PlanPro || invoice.MpesaReceipt != "QKL1234567" ||
+		invoice.PDFURL == "" || invoice.Number == "" || invoice.PaidAt == nil {
+		t.Errorf("unexpected invoice: %+v", invoice)
+	}
+	if !invoice.PeriodEnd.Equal(invoice.PeriodStart.AddDate(0, 1, 0)) {
+		t.Errorf("period = %s to %s; want one month", invoice.PeriodStart, invoice.PeriodEnd)
+	}
+
+	// Plan payments are the platform's money, not the shop's M-Pesa income
+	var shopIncome int64
+	db.Model(&models.MpesaTransaction{}).Where("shop_id = ?", shop.ID).Count(&shopIncome)
+	if shopIncome != 0 {
+		t.Errorf("shop transactions = %d; want none for a plan payment", shopIncome)
+	}
+
+	// The listing links to the download route, not where the PDF is stored
+	resp, _ = app.Test(httptest.NewRequest("GET", "/billing/invoices", nil))
+	if resp.StatusCode != fiber.StatusOK {
+		t.Errorf("list invoices status = %d; want 200", resp.StatusCode)
+	}
+	listBody, _ := io.ReadAll(resp.Body)
+	wantURL := fmt.Sprintf(`"download_url":"/api/v1/billing/invoices/%d/download"`, invoice.ID)
+	if !strings.Contains(string(listBody), wantURL) || strings.Contains(string(listBody), invoice.PDFURL) {
+		t.Errorf("invoices = %s; want %s and no storage path", listBody, wantURL)
+	}
+
+	downloadPath := fmt.Sprintf("/billing/invoices/%d/download", invoice.ID)
+	resp, _ = app.Test(httptest.NewRequest("GET", downloadPath, nil))
+	if resp.StatusCode != fiber.StatusOK || resp.Header.Get("Content-Type") != "application/pdf" {
+		t.Fatalf("download = %d %s; want 200 application/pdf", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !bytes.HasPrefix(body, []byte("%PDF")) {
+		t.Error("download is not a PDF")
+	}
+
+	// Another account's shop cannot download it
+	other := &models.Shop{AccountID: account.ID + 1, Name: "Other", Phone: "+254700000001", IsActive: true}
+	db.Create(other)
+	resp, _ = newApp(other).Test(httptest.NewRequest("GET", downloadPath, nil))
+	if resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("other account download status = %d; want 404", resp.StatusCode)
+	}
+}
+
+// TestUpgradeWithoutMpesa tests that paid plans need M-Pesa, while
+// downgrading to free applies at once
+func TestUpgradeWithoutMpesa(t *testing.T) {
+	db := openTestDB(t, &models.Account{}, &models.Shop{})
+	account := &models.Account{Email: "otieno@example.com", Name: "Otieno", Phone: "+254722000000", Plan: models.PlanPro, IsActive: true}
+	db.Create(account)
+	shop := &models.Shop{AccountID: account.ID, Name: "Kiosk", Phone: "+254722000000", Plan: models.PlanPro, IsActive: true}
+	db.Create(shop)
+
+	handler := billinghandler.NewHandler(db, nil)
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("shop", shop)
+		return c.Next()
+	})
+	app.Post("/billing/upgrade", handler.UpgradePlan)
+
+	upgrade := func(plan string) int {
+		req := httptest.NewRequest("POST", "/billing/upgrade", strings.NewReader(fmt.Sprintf(`{"plan_id":%q}`, plan)))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+		return resp.StatusCode
+	}
+
+	if status := upgrade("business"); status != fiber.StatusServiceUnavailable {
+		t.Errorf("paid upgrade status = %d; want 503", status)
+	}
+	if status := upgrade("free"); status != fiber.StatusOK {
+		t.Fatalf("downgrade status = %d; want 200", status)
+	}
+
+	var reloaded models.Shop
+	db.First(&reloaded, shop.ID)
+	if reloaded.Plan != models.PlanFree {
+		t.Errorf("shop plan = %s; want free", reloaded.Plan)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/loyalty"
+	"gorm.io/gorm"
+)
+
+// TestBirthdayRewards tests birthday points going to customers born today,
+// once a year, with a message showing their balance
+func TestBirthdayRewards(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Customer{}, &models.LoyaltyTransaction{}, &models.Sale{})
+
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true, BirthdayBonusPoints: 50}
+	db.Create(shop)
+
+	now := time.Date(2026, time.May, 14, 9, 0, 0, 0, time.UTC)
+	born := func(year int, month time.Month, day int) *time.Time {
+		d := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		return &d
+	}
+	jane := &models.Customer{ShopID: shop.ID, Name: "Jane", Phone: "+254722000111", ReferralCode: "JANE01",
+		LoyaltyPoints: 120, DateOfBirth: born(1990, time.May, 14), IsActive: true}
+	john := &models.Customer{ShopID: shop.ID, Name: "John", Phone: "+254722000222", ReferralCode: "JOHN01",
+		DateOfBirth: born(1985, time.May, 15), IsActive: true}
+	nobody := &models.Customer{ShopID: shop.ID, Name: "Nobody", Phone: "+254722000333", ReferralCode: "NOBD01", IsActive: true}
+	db.Create(jane)
+	db.Create(john)
+	db.Create(nobody)
+
+	customerRepo := repository.NewCustomerRepository(db)
+	svc := loyalty.NewService(customerRepo, repository.NewSaleRepository(db), db)
+	var sent []string
+	svc.SetMessageSender(func(phone, message string) error {
+		sent = append(sent, phone+": "+message)
+		return nil
+	})
+
+	n, err := svc.AwardBirthdayBonuses(shop, now)
+	if err != nil {
+		t.Fatalf("AwardBirthdayBonuses() error: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("rewarded = %d; want only Jane", n)
+	}
+
+	got, _ := customerRepo.GetByID(jane.ID)
+	if got.LoyaltyPoints != 170 {
+		t.Errorf("Jane's points = %d; want 170", got.LoyaltyPoints)
+	}
+	var tx models.LoyaltyTransaction
+	if err := db.Where("customer_id = ? AND type = ?", jane.ID, models.LoyaltyBirthdayBonus).First(&tx).Error; err != nil {
+		t.Fatalf("birthday transaction not recorded: %v", err)
+	}
+	if tx.Points != 50 || tx.PointsAfter != 170 {
+		t.Errorf("transaction = %+v; want 50 points to 170", tx)
+	}
+	if len(sent) != 1 || !strings.HasPrefix(sent[0], jane.Phone) || !strings.Contains(sent[0], "You now have 170 points") {
+		t.Errorf("messages = %v; want Jane congratulated with her balance", sent)
+	}
+
+	if n, _ := svc.AwardBirthdayBonuses(shop, now.Add(3*time.Hour)); n != 0 {
+		t.Errorf("second run rewarded %d; want none on the same birthday", n)
+	}
+
+	off := *shop
+	off.BirthdayBonusPoints = 0
+	if n, _ := svc.AwardBirthdayBonuses(&off, now.AddDate(0, 0, 1)); n != 0 {
+		t.Errorf("rewarded %d with birthday points off; want none", n)
+	}
+
+	// Points are not added when their record can't be saved, so the next
+	// run gives them once
+	db.Callback().Create().Before("gorm:create").Register("test:fail_loyalty", func(tx *gorm.DB) {
+		if tx.Statement.Table == "loyalty_transactions" {
+			tx.AddError(errors.New("disk full"))
+		}
+	})
+	tomorrow := now.AddDate(0, 0, 1)
+	if n, err := svc.AwardBirthdayBonuses(shop, tomorrow); err != nil || n != 0 {
+		t.Errorf("run with a failing record = %d, %v; want none rewarded", n, err)
+	}
+	if got, _ := customerRepo.GetByID(john.ID); got.LoyaltyPoints != 0 {
+		t.Errorf("John's points = %d after the record failed; want 0", got.LoyaltyPoints)
+	}
+	db.Callback().Create().Remove("test:fail_loyalty")
+	if n, _ := svc.AwardBirthdayBonuses(shop, tomorrow); n != 1 {
+		t.Errorf("retry rewarded %d; want John", n)
+	}
+	if got, _ := customerRepo.GetByID(john.ID); got.LoyaltyPoints != 50 {
+		t.Errorf("John's points = %d; want 50 once", got.LoyaltyPoints)
+	}
+}
+
+// TestBirthdaysOnLeapDay tests 29 February birthdays falling on 28 February
+// in other years
+func TestBirthdaysOnLeapDay(t *testing.T) {
+	db := openTestDB(t, &models.Customer{})
+	leap := time.Date(2000, time.February, 29, 0, 0, 0, 0, time.UTC)
+	db.Create(&models.Customer{ShopID: 1, Name: "Leap", ReferralCode: "LEAP01", DateOfBirth: &leap, IsActive: true})
+
+	customerRepo := repository.NewCustomerRepository(db)
+	if customers, _ := customerRepo.GetBirthdays(1, time.Date(2027, time.February, 28, 9, 0, 0, 0, time.UTC)); len(customers) != 1 {
+		t.Errorf("28 Feb 2027 birthdays = %d; want the leap day customer", len(customers))
+	}
+	if customers, _ := customerRepo.GetBirthdays(1, time.Date(2028, time.February, 28, 9, 0, 0, 0, time.UTC)); len(customers) != 0 {
+		t.Errorf("28 Feb 2028 birthdays = %d; want none in a leap year", len(customers))
+	}
+}
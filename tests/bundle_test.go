@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/handlers"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestBuildBundleSales tests that a bundle sale is split across components by selling value
+func TestBuildBundleSales(t *testing.T) {
+	bundle := &models.Product{ID: 10, ShopID: 1, Name: "Breakfast Bundle", SellingPrice: 200, IsBundle: true}
+	components := []models.ProductBundle{
+		{BundleProductID: 10, ComponentProductID: 1, Quantity: 1, Component: models.Product{ID: 1, Name: "Bread", SellingPrice: 60, CostPrice: 50}},
+		{BundleProductID: 10, ComponentProductID: 2, Quantity: 1, Component: models.Product{ID: 2, Name: "Butter", SellingPrice: 90, CostPrice: 70}},
+		{BundleProductID: 10, ComponentProductID: 3, Quantity: 2, Component: models.Product{ID: 3, Name: "Milk", SellingPrice: 37.5, CostPrice: 30}},
+	}
+
+	sales := models.BuildBundleSales(bundle, components, 2, 400, models.PaymentCash)
+	if len(sales) != 3 {
+		t.Fatalf("expected 3 component sales, got %d", len(sales))
+	}
+
+	// Weights 60 : 90 : 75 of 225
+	expected := []struct {
+		productID uint
+		quantity  int
+		total     float64
+		cost      float64
+	}{
+		{1, 2, 106.67, 100},
+		{2, 2, 160, 140},
+		{3, 4, 133.33, 120},
+	}
+
+	sum := 0.0
+	for i, want := range expected {
+		sale := sales[i]
+		if sale.ProductID != want.productID || sale.Quantity != want.quantity {
+			t.Errorf("sale %d: product %d x%d; want %d x%d", i, sale.ProductID, sale.Quantity, want.productID, want.quantity)
+		}
+		if math.Abs(sale.TotalAmount-want.total) > 0.001 {
+			t.Errorf("sale %d: total %.2f; want %.2f", i, sale.TotalAmount, want.total)
+		}
+		if sale.CostAmount != want.cost || math.Abs(sale.Profit-(want.total-want.cost)) > 0.001 {
+			t.Errorf("sale %d: cost %.2f profit %.2f", i, sale.CostAmount, sale.Profit)
+		}
+		if sale.ShopID != 1 {
+			t.Errorf("sale %d: shop %d; want 1", i, sale.ShopID)
+		}
+		sum += sale.TotalAmount
+	}
+
+	if math.Abs(sum-400) > 0.001 {
+		t.Errorf("component totals add up to %.2f; want 400", sum)
+	}
+}
+
+// TestBuildBundleSalesUnpriced tests the quantity split when components have no price
+func TestBuildBundleSalesUnpriced(t *testing.T) {
+	bundle := &models.Product{ID: 10, ShopID: 1, Name: "Gift Pack"}
+	components := []models.ProductBundle{
+		{ComponentProductID: 1, Quantity: 1},
+		{ComponentProductID: 2, Quantity: 3},
+	}
+
+	sales := models.BuildBundleSales(bundle, components, 1, 100, models.PaymentMpesa)
+	if sales[0].TotalAmount != 25 || sales[1].TotalAmount != 75 {
+		t.Errorf("expected 25/75 split, got %.2f/%.2f", sales[0].TotalAmount, sales[1].TotalAmount)
+	}
+	if sales[1].PaymentMethod != models.PaymentMpesa {
+		t.Errorf("payment method = %s; want mpesa", sales[1].PaymentMethod)
+	}
+}
+
+// TestWhatsAppBundles tests making a bundle over WhatsApp, selling it from
+// its components' stock and listing it through the API
+func TestWhatsAppBundles(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.Sale{}, &models.DailySummary{},
+		&models.AuditLog{}, &models.ProductBundle{}, &models.ProductAlias{}, &models.StockMovement{}, &models.PriceHistory{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	db.Create(shop)
+	soda := &models.Product{ShopID: shop.ID, Name: "Soda", SellingPrice: 60, CostPrice: 40, CurrentStock: 10, LowStockThreshold: 2, IsActive: true}
+	mandazi := &models.Product{ShopID: shop.ID, Name: "Mandazi", SellingPrice: 20, CostPrice: 10, CurrentStock: 3, LowStockThreshold: 2, IsActive: true}
+	db.Create(soda)
+	db.Create(mandazi)
+
+	productRepo := repository.NewProductRepository(db)
+	bundleRepo := repository.NewBundleRepository(db)
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		productRepo,
+		repository.NewSaleRepository(db),
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	cmdHandler.SetBundleRepo(bundleRepo)
+	send := func(text string) string {
+		t.Helper()
+		reply, err := cmdHandler.Handle(shop.Phone, services.NewCommandParser(nil, nil).Parse(text))
+		if err != nil {
+			t.Fatalf("%q error: %v", text, err)
+		}
+		return reply
+	}
+
+	if reply := send("bundle add lunch 90 soda 1 mandazi 2"); !strings.Contains(reply, "BUNDLE SAVED") || !strings.Contains(reply, "Can make: 1") {
+		t.Errorf("bundle add = %q; want it saved, enough for one", reply)
+	}
+	if reply := send("bundle add soda 50 mandazi"); !strings.Contains(reply, "already a product") {
+		t.Errorf("bundle named after a product = %q; want it refused", reply)
+	}
+	if reply := send("bundle add snack chapati 1"); !strings.Contains(reply, "Chapati") {
+		t.Errorf("bundle of an unknown product = %q; want it named as not found", reply)
+	}
+
+	// Two lunches need four mandazi: nothing is sold
+	if reply := send("sell lunch 2"); !strings.Contains(reply, "Not enough Mandazi") {
+		t.Errorf("sell lunch 2 = %q; want not enough mandazi", reply)
+	}
+	var count int64
+	db.Model(&models.Sale{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("%d sales recorded for a refused bundle sale; want none", count)
+	}
+
+	reply := send("sell lunch 1")
+	if !strings.Contains(reply, "SOLD") || !strings.Contains(reply, "Lunch x1 = KSh 90") || !strings.Contains(reply, "Profit: KSh 30") {
+		t.Errorf("sell lunch 1 = %q; want KSh 90 with KSh 30 profit", reply)
+	}
+	if !strings.Contains(reply, "Mandazi: only 1 left") {
+		t.Errorf("sell lunch 1 = %q; want the mandazi low stock warning", reply)
+	}
+
+	var sales []models.Sale
+	db.Find(&sales)
+	total, cost, profit := 0.0, 0.0, 0.0
+	for _, sale := range sales {
+		total += sale.TotalAmount
+		cost += sale.CostAmount
+		profit += sale.Profit
+	}
+	if len(sales) != 2 || total != 90 || cost != 60 || profit != 30 {
+		t.Errorf("%d component sales total %.2f, cost %.2f, profit %.2f; want 2 adding to 90, 60 and 30", len(sales), total, cost, profit)
+	}
+	db.First(soda, soda.ID)
+	db.First(mandazi, mandazi.ID)
+	if soda.CurrentStock != 9 || mandazi.CurrentStock != 1 {
+		t.Errorf("stock soda %d, mandazi %d; want 9 and 1", soda.CurrentStock, mandazi.CurrentStock)
+	}
+
+	// Bundles hold no stock, so they are never low on it
+	low, _ := productRepo.GetLowStock(shop.ID)
+	for _, p := range low {
+		if p.IsBundle {
+			t.Errorf("bundle %s listed as low on stock", p.Name)
+		}
+	}
+
+	h := handlers.NewProductHandler(productRepo)
+	h.SetBundleRepo(bundleRepo)
+	app := fiber.New()
+	app.Get("/bundles", func(c *fiber.Ctx) error {
+		c.Locals("shop_id", shop.ID)
+		return h.ListBundles(c)
+	})
+	resp, err := app.Test(httptest.NewRequest("GET", "/bundles", nil))
+	if err != nil {
+		t.Fatalf("GET /bundles failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	var bundles []handlers.BundleResponse
+	if err := json.Unmarshal(body, &bundles); err != nil || resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("GET /bundles: status %d, %s", resp.StatusCode, body)
+	}
+	if len(bundles) != 1 || bundles[0].Name != "Lunch" || bundles[0].Profit != 30 || bundles[0].Available != 0 || len(bundles[0].Components) != 2 {
+		t.Errorf("bundles = %+v; want Lunch with 30 profit, none available and two components", bundles)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/config"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/handlers"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestBusinessHours tests day parsing, opening checks and the next opening
+func TestBusinessHours(t *testing.T) {
+	days, ok := models.ParseDays("fri-mon")
+	if !ok || len(days) != 4 || days[0] != time.Friday || days[3] != time.Monday {
+		t.Errorf("ParseDays(fri-mon) = %v, %v; want Fri to Mon", days, ok)
+	}
+	if _, ok := models.ParseDays("someday"); ok {
+		t.Error("ParseDays(someday) accepted")
+	}
+	if _, err := models.ParseOpenClose("18:00-08:00"); err == nil {
+		t.Error("ParseOpenClose accepted closing before opening")
+	}
+
+	hours := models.BusinessHours{}
+	weekdays, _ := models.ParseDays("weekdays")
+	open, err := models.ParseOpenClose("8:00-18:00")
+	if err != nil {
+		t.Fatalf("ParseOpenClose() error: %v", err)
+	}
+	hours.Set(weekdays, open)
+
+	// 2026-10-16 is a Friday
+	friday := time.Date(2026, 10, 16, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		at   time.Time
+		open bool
+		next string
+	}{
+		{friday.Add(7*time.Hour + 59*time.Minute), false, "Fri 08:00"},
+		{friday.Add(8 * time.Hour), true, "Mon 08:00"},
+		{friday.Add(18 * time.Hour), false, "Mon 08:00"},
+		{friday.AddDate(0, 0, 1).Add(12 * time.Hour), false, "Mon 08:00"},
+	}
+	for _, tt := range tests {
+		if got := hours.IsOpen(tt.at); got != tt.open {
+			t.Errorf("IsOpen(%s) = %v; want %v", tt.at.Format("Mon 15:04"), got, tt.open)
+		}
+		next, ok := hours.NextOpening(tt.at)
+		if !ok || next.Format("Mon 15:04") != tt.next {
+			t.Errorf("NextOpening(%s) = %s; want %s", tt.at.Format("Mon 15:04"), next.Format("Mon 15:04"), tt.next)
+		}
+	}
+
+	if !(models.BusinessHours{}).IsOpen(friday) {
+		t.Error("shop without hours should always be open")
+	}
+}
+
+// TestWhatsAppOutOfHours tests that outside business hours messages to the
+// shop's own WhatsApp number only get the closed message, while its owner
+// and staff can still use the bot
+func TestWhatsAppOutOfHours(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.WhiteLabelConfig{}, &models.Staff{}, &models.Product{}, &models.StockMovement{},
+		&models.Sale{}, &models.DailySummary{}, &models.WeeklySummary{}, &models.MonthlySummary{}, &models.AuditLog{})
+
+	// Open every day but today in Nairobi, so the shop is closed whatever
+	// the time
+	nairobi, _ := time.LoadLocation("Africa/Nairobi")
+	hours := models.BusinessHours{}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if d != time.Now().In(nairobi).Weekday() {
+			hours.Set([]time.Weekday{d}, models.DayHours{Open: "08:00", Close: "18:00"})
+		}
+	}
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanFree, IsActive: true,
+		BusinessHours: hours, ClosedMessage: "Tumefunga, open again {open}"}
+	if err := db.Create(shop).Error; err != nil {
+		t.Fatalf("failed to create shop: %v", err)
+	}
+	const shopNumber = "+254711000000"
+	db.Create(&models.WhiteLabelConfig{ShopID: shop.ID, WhatsAppNumber: shopNumber, Enabled: true})
+	db.Create(&models.Staff{ShopID: shop.ID, Name: "Otieno", Phone: "0700000001", Role: "cashier", IsActive: true})
+	db.Create(&models.Product{ShopID: shop.ID, Name: "Bread", SellingPrice: 60, CostPrice: 50, CurrentStock: 10, IsActive: true})
+
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		repository.NewProductRepository(db),
+		repository.NewSaleRepository(db),
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	cmdHandler.SetStaffRepo(repository.NewStaffRepository(db))
+	app := fiber.New()
+	app.Post("/webhook/twilio", handlers.NewWhatsAppHandler(cmdHandler, &config.Config{}).HandleWebhook)
+
+	send := func(from, body string) string {
+		t.Helper()
+		form := url.Values{"From": {"whatsapp:" + from}, "To": {"whatsapp:" + shopNumber}, "Body": {body}}
+		req := httptest.NewRequest("POST", "/webhook/twilio", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%q failed: %v", body, err)
+		}
+		if resp.StatusCode != fiber.StatusOK {
+			t.Errorf("%q: status %d; want 200", body, resp.StatusCode)
+		}
+		reply, _ := io.ReadAll(resp.Body)
+		return string(reply)
+	}
+
+	next, _ := hours.NextOpening(time.Now().In(nairobi))
+	if reply := send("+254799999999", "sell bread 2"); !strings.Contains(reply, "Tumefunga, open again "+next.Format("Mon 15:04")) {
+		t.Errorf("closed reply = %q; want the shop's message with the next opening", reply)
+	}
+	var sales int64
+	db.Model(&models.Sale{}).Count(&sales)
+	if sales != 0 {
+		t.Errorf("sales while closed = %d; want 0", sales)
+	}
+
+	// The owner is never shut out, and neither is staff
+	send(shop.Phone, "sell bread 2")
+	db.Model(&models.Sale{}).Count(&sales)
+	if sales != 1 {
+		t.Errorf("owner's sales while closed = %d; want 1", sales)
+	}
+	parse := services.NewCommandParser(nil, nil)
+	if reply, closed := cmdHandler.ClosedReply(shopNumber, "+254700000001", parse.Parse("stock"), time.Now()); closed {
+		t.Errorf("staff got the closed reply %q", reply)
+	}
+	// Messages to the shared bot number are from owners
+	if reply, closed := cmdHandler.ClosedReply("+14155238886", "+254799999999", parse.Parse("stock"), time.Now()); closed {
+		t.Errorf("message to the shared number got the closed reply %q", reply)
+	}
+
+	if reply := send(shop.Phone, "hours daily 08:00-18:00"); !strings.Contains(reply, "Sun: 08:00-18:00") {
+		t.Errorf("hours set reply = %q; want every day open", reply)
+	}
+	// 06:00 UTC is 09:00 in Nairobi, within the hours; in UTC it would not be
+	morning := time.Date(2026, 10, 16, 6, 0, 0, 0, time.UTC)
+	if reply, closed := cmdHandler.ClosedReply(shopNumber, "+254799999999", parse.Parse("stock"), morning); closed {
+		t.Errorf("closed reply at 09:00 Nairobi time = %q; want open", reply)
+	}
+	db.Model(shop).Update("timezone", "UTC")
+	if _, closed := cmdHandler.ClosedReply(shopNumber, "+254799999999", parse.Parse("stock"), morning); !closed {
+		t.Error("shop in UTC open at 06:00; want closed")
+	}
+
+	if reply := send(shop.Phone, "hours off"); !strings.Contains(reply, "cleared") {
+		t.Errorf("hours off reply = %q", reply)
+	}
+	var saved models.Shop
+	db.First(&saved, shop.ID)
+	if len(saved.BusinessHours) != 0 {
+		t.Errorf("hours after off = %v; want none", saved.BusinessHours)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	exporthandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/export"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/export"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/images"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/media"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/storage"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestCatalogProducts tests which products go on the customer price list
+// and in what order
+func TestCatalogProducts(t *testing.T) {
+	products := []models.Product{
+		{ID: 1, Name: "Soda", Category: "Drinks", CurrentStock: 10, IsActive: true},
+		{ID: 2, Name: "Juice", Category: "drinks", CurrentStock: 0, IsActive: true},
+		{ID: 3, Name: "Bread", Category: "Bakery", CurrentStock: 5, IsActive: true},
+		{ID: 4, Name: "Matches", CurrentStock: 50, IsActive: true},
+		{ID: 5, Name: "Cake", Category: "Bakery", CurrentStock: 5, IsActive: false},
+		{ID: 6, Name: "Apple Juice", Category: "Drinks", CurrentStock: 3, IsActive: true},
+	}
+	names := func(ps []models.Product) string {
+		var out []string
+		for _, p := range ps {
+			out = append(out, p.Name)
+		}
+		return strings.Join(out, ",")
+	}
+
+	tests := []struct {
+		opts export.CatalogOptions
+		want string
+	}{
+		{export.CatalogOptions{}, "Bread,Apple Juice,Juice,Soda,Matches"},
+		{export.CatalogOptions{InStockOnly: true}, "Bread,Apple Juice,Soda,Matches"},
+		{export.CatalogOptions{Category: "DRINKS"}, "Apple Juice,Juice,Soda"},
+		{export.CatalogOptions{Category: "toys"}, ""},
+	}
+	for _, tt := range tests {
+		if got := names(export.CatalogProducts(products, tt.opts)); got != tt.want {
+			t.Errorf("CatalogProducts(%+v) = %q; want %q", tt.opts, got, tt.want)
+		}
+	}
+}
+
+// TestCatalogExport tests the catalog endpoint and the catalog command
+func TestCatalogExport(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.StockMovement{}, &models.Sale{}, &models.DailySummary{}, &models.AuditLog{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true, BrandPrimaryColor: "#1a73e8"}
+	db.Create(shop)
+	productRepo := repository.NewProductRepository(db)
+	soda := &models.Product{ShopID: shop.ID, Name: "Soda", Category: "Drinks", SellingPrice: 50, CostPrice: 35,
+		CurrentStock: 10, Unit: "bottle", Barcode: "5449000000996", IsActive: true}
+	productRepo.Create(soda)
+	productRepo.Create(&models.Product{ShopID: shop.ID, Name: "Bread", Category: "Bakery", SellingPrice: 60,
+		CurrentStock: 0, Unit: "loaf", IsActive: true})
+
+	store := storage.NewLocalStore(t.TempDir())
+	src := image.NewRGBA(image.Rect(0, 0, 40, 40))
+	for i := range src.Pix {
+		src.Pix[i] = 0xff
+	}
+	src.Set(5, 5, color.Black)
+	var pngData bytes.Buffer
+	png.Encode(&pngData, src)
+	thumb, err := images.Thumbnail(pngData.Bytes(), images.ThumbnailSize)
+	if err != nil {
+		t.Fatalf("Thumbnail() error: %v", err)
+	}
+	store.Save("products/soda-thumb.jpg", thumb)
+	db.Model(soda).Update("thumbnail_url", "/static/products/soda-thumb.jpg")
+
+	h := exporthandler.NewExportHandler(productRepo, repository.NewSaleRepository(db), repository.NewDailySummaryRepository(db))
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("shop_id", shop.ID)
+		return c.Next()
+	})
+	app.Get("/export/catalog", h.ExportCatalog)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/export/catalog", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusServiceUnavailable {
+		t.Errorf("catalog without a shop repository: status %d; want 503", resp.StatusCode)
+	}
+
+	h.SetShopRepo(repository.NewShopRepository(db))
+	h.SetImageStore(store, "/static/")
+	for _, tt := range []struct {
+		query     string
+		wantImage bool
+	}{
+		{"in_stock=true&barcodes=true", false},
+		{"images=true&category=drinks", true},
+	} {
+		resp, err := app.Test(httptest.NewRequest("GET", "/export/catalog?"+tt.query, nil))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != fiber.StatusOK || !bytes.HasPrefix(body, []byte("%PDF")) {
+			t.Fatalf("%s: expected a PDF, got status %d: %.40q", tt.query, resp.StatusCode, body)
+		}
+		if resp.Header.Get("Content-Type") != "application/pdf" {
+			t.Errorf("%s: Content-Type = %q", tt.query, resp.Header.Get("Content-Type"))
+		}
+		if got := bytes.Contains(body, []byte("/Subtype /Image")); got != tt.wantImage {
+			t.Errorf("%s: embeds image = %v; want %v", tt.query, got, tt.wantImage)
+		}
+	}
+
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		productRepo,
+		repository.NewSaleRepository(db),
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	send := func(text string) string {
+		reply, err := cmdHandler.Handle(shop.Phone, services.NewCommandParser(nil, nil).Parse(text))
+		if err != nil {
+			t.Fatalf("%s error: %v", text, err)
+		}
+		return reply
+	}
+	if reply := send("catalog"); !strings.Contains(reply, "not configured") {
+		t.Errorf("catalog without media = %q; want the not configured notice", reply)
+	}
+
+	var sent []string
+	cmdHandler.SetMediaSender(media.NewHost(t.TempDir(), "https://pos.example.com/media", 0),
+		func(to, caption, url string) error {
+			sent = append(sent, url)
+			return nil
+		})
+	reply := send("catalog")
+	if len(sent) != 1 || !strings.HasSuffix(sent[0], ".pdf") {
+		t.Fatalf("catalog should send one PDF, sent %v", sent)
+	}
+	if !strings.Contains(reply, "1 products") || !strings.Contains(reply, sent[0]) {
+		t.Errorf("catalog reply = %q; want the in-stock count and the link", reply)
+	}
+	if reply := send("catalog bakery"); !strings.Contains(reply, fmt.Sprintf("No products in stock in category '%s'", "bakery")) {
+		t.Errorf("catalog bakery = %q; want the empty category notice", reply)
+	}
+}
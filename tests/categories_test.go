@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/handlers"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestCategoryEndpoints tests creating, renaming, moving and deleting categories
+func TestCategoryEndpoints(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.Category{}, &models.StockMovement{}, &models.PriceHistory{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	db.Create(shop)
+	other := &models.Shop{Name: "Other", Phone: "+254700000001", IsActive: true}
+	db.Create(other)
+
+	productRepo := repository.NewProductRepository(db)
+	categoryRepo := repository.NewCategoryRepository(db)
+	h := handlers.NewProductHandler(productRepo)
+	h.SetCategoryRepo(categoryRepo)
+
+	app := fiber.New(fiber.Config{ErrorHandler: utils.ErrorHandler})
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("shop_id", shop.ID)
+		return c.Next()
+	})
+	app.Post("/products/categories", h.CreateCategory)
+	app.Put("/products/categories/:id", h.UpdateCategory)
+	app.Delete("/products/categories/:id", h.DeleteCategory)
+
+	send := func(method, path, body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s %s error: %v", method, path, err)
+		}
+		var out map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+	create := func(body string) uint {
+		status, out := send("POST", "/products/categories", body)
+		if status != fiber.StatusCreated {
+			t.Fatalf("create %s status = %d; want 201 (%v)", body, status, out)
+		}
+		return uint(out["id"].(float64))
+	}
+
+	drinks := create(`{"name":"Drinks"}`)
+	dairy := create(`{"name":"Dairy","parent_id":` + fmt.Sprint(drinks) + `}`)
+	milk := create(`{"name":"Milk","parent_id":` + fmt.Sprint(dairy) + `}`)
+
+	var placeholders int64
+	db.Model(&models.Product{}).Where("shop_id = ?", shop.ID).Count(&placeholders)
+	if placeholders != 0 {
+		t.Errorf("creating categories added %d products; want none", placeholders)
+	}
+	if status, _ := send("POST", "/products/categories", `{"name":"Drinks"}`); status != fiber.StatusBadRequest {
+		t.Errorf("duplicate category status = %d; want 400", status)
+	}
+	theirs := &models.Category{ShopID: other.ID, Name: "Theirs"}
+	db.Create(theirs)
+	if status, _ := send("POST", "/products/categories", fmt.Sprintf(`{"name":"Juice","parent_id":%d}`, theirs.ID)); status != fiber.StatusBadRequest {
+		t.Errorf("another shop's parent status = %d; want 400", status)
+	}
+
+	// Renaming moves the products filed under the old name
+	fresh := &models.Product{ShopID: shop.ID, Name: "Fresh milk", Category: "Dairy", SellingPrice: 60, Unit: "pcs", IsActive: true}
+	productRepo.Create(fresh)
+	if status, out := send("PUT", fmt.Sprintf("/products/categories/%d", dairy), `{"name":"Dairy products"}`); status != fiber.StatusOK {
+		t.Fatalf("rename status = %d (%v)", status, out)
+	}
+	renamed, _ := categoryRepo.GetByID(dairy)
+	if renamed.Name != "Dairy products" || renamed.ParentCategoryID == nil || *renamed.ParentCategoryID != drinks {
+		t.Errorf("renamed category = %q under %v; want Dairy products under Drinks", renamed.Name, renamed.ParentCategoryID)
+	}
+	product, _ := productRepo.GetByID(fresh.ID)
+	if product.Category != "Dairy products" {
+		t.Errorf("product category = %q; want Dairy products", product.Category)
+	}
+	if status, _ := send("PUT", fmt.Sprintf("/products/categories/%d", milk), `{"name":"Drinks"}`); status != fiber.StatusBadRequest {
+		t.Errorf("rename to an existing name status = %d; want 400", status)
+	}
+
+	// Moving under another parent, never under itself or a subcategory
+	if status, _ := send("PUT", fmt.Sprintf("/products/categories/%d", drinks), fmt.Sprintf(`{"parent_id":%d}`, milk)); status != fiber.StatusBadRequest {
+		t.Errorf("move under a subcategory status = %d; want 400", status)
+	}
+	if status, _ := send("PUT", fmt.Sprintf("/products/categories/%d", milk), fmt.Sprintf(`{"parent_id":%d}`, drinks)); status != fiber.StatusOK {
+		t.Errorf("move status = %d; want 200", status)
+	}
+	moved, _ := categoryRepo.GetByID(milk)
+	if moved.ParentCategoryID == nil || *moved.ParentCategoryID != drinks {
+		t.Errorf("moved category parent = %v; want Drinks", moved.ParentCategoryID)
+	}
+	send("PUT", fmt.Sprintf("/products/categories/%d", milk), `{"parent_id":0}`)
+	if moved, _ = categoryRepo.GetByID(milk); moved.ParentCategoryID != nil {
+		t.Errorf("parent_id 0 left parent %v; want a top-level category", *moved.ParentCategoryID)
+	}
+	if status, _ := send("PUT", fmt.Sprintf("/products/categories/%d", theirs.ID), `{"name":"Mine"}`); status != fiber.StatusNotFound {
+		t.Errorf("update another shop's category status = %d; want 404", status)
+	}
+
+	// Deleting leaves the products uncategorized and lifts the children
+	db.Model(&models.Category{}).Where("id = ?", milk).Update("parent_category_id", dairy)
+	if status, _ := send("DELETE", fmt.Sprintf("/products/categories/%d", dairy), ""); status != fiber.StatusOK {
+		t.Fatalf("delete status = %d", status)
+	}
+	if _, err := categoryRepo.GetByID(dairy); err == nil {
+		t.Error("deleted category still exists")
+	}
+	if product, _ = productRepo.GetByID(fresh.ID); product.Category != "" {
+		t.Errorf("product category after delete = %q; want uncategorized", product.Category)
+	}
+	if moved, _ = categoryRepo.GetByID(milk); moved.ParentCategoryID == nil || *moved.ParentCategoryID != drinks {
+		t.Errorf("subcategory parent after delete = %v; want Drinks", moved.ParentCategoryID)
+	}
+	if status, _ := send("DELETE", fmt.Sprintf("/products/categories/%d", theirs.ID), ""); status != fiber.StatusNotFound {
+		t.Errorf("delete another shop's category status = %d; want 404", status)
+	}
+}
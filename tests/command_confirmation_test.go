@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+)
+
+// TestDestructiveCommandConfirmation tests that delete and large removes
+// only run after a matching `yes ...` reply
+func TestDestructiveCommandConfirmation(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.ProductAlias{}, &models.FavoriteProduct{}, &models.StockMovement{}, &models.Sale{},
+		&models.DailySummary{}, &models.AuditLog{})
+
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanFree, IsActive: true}
+	db.Create(shop)
+	productRepo := repository.NewProductRepository(db)
+	db.Create(&models.Product{ShopID: shop.ID, Name: "Milk", SellingPrice: 60, CostPrice: 50, CurrentStock: 100, IsActive: true})
+	db.Create(&models.Product{ShopID: shop.ID, Name: "Bread", SellingPrice: 55, CostPrice: 45, CurrentStock: 10, IsActive: true})
+
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		productRepo,
+		repository.NewSaleRepository(db),
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	parser := services.NewCommandParser(nil, nil)
+	send := func(message string) string {
+		reply, err := cmdHandler.Handle(shop.Phone, parser.Parse(message))
+		if err != nil {
+			t.Fatalf("Handle(%q) error: %v", message, err)
+		}
+		return reply
+	}
+	exists := func(name string) bool {
+		_, err := productRepo.GetByShopAndName(shop.ID, name)
+		return err == nil
+	}
+
+	if reply := send("delete milk"); !strings.Contains(reply, "yes delete milk") {
+		t.Errorf("delete reply = %q; want a confirmation prompt", reply)
+	}
+	if !exists("Milk") {
+		t.Fatal("milk deleted before confirmation")
+	}
+
+	// A "yes" for something else cancels the pending delete
+	if reply := send("yes delete bread"); !strings.Contains(reply, "Nothing to confirm") {
+		t.Errorf("mismatched yes = %q", reply)
+	}
+	if reply := send("yes delete milk"); !strings.Contains(reply, "Nothing to confirm") {
+		t.Errorf("yes after cancel = %q", reply)
+	}
+	if !exists("Milk") || !exists("Bread") {
+		t.Fatal("product deleted without a matching confirmation")
+	}
+
+	send("delete milk")
+	if reply := send("yes delete milk"); !strings.Contains(reply, "Deleted") {
+		t.Errorf("confirmed delete = %q", reply)
+	}
+	if exists("Milk") {
+		t.Error("milk not deleted after confirmation")
+	}
+
+	// Small removes go straight through; large ones wait
+	send("add sugar 150 100")
+	send("remove sugar 5")
+	if reply := send("remove sugar 60"); !strings.Contains(reply, "yes remove sugar 60") {
+		t.Errorf("large remove reply = %q; want a confirmation prompt", reply)
+	}
+	sugar, _ := productRepo.GetByShopAndName(shop.ID, "Sugar")
+	if sugar.CurrentStock != 95 {
+		t.Fatalf("stock = %d; want 95 before confirmation", sugar.CurrentStock)
+	}
+	send("yes remove sugar 60")
+	sugar, _ = productRepo.GetByShopAndName(shop.ID, "Sugar")
+	if sugar.CurrentStock != 35 {
+		t.Errorf("stock = %d; want 35 after confirmation", sugar.CurrentStock)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+)
+
+// TestCompoundCommandsAreAtomic tests that a sell or restock whose audit
+// entry can't be saved leaves no sale, stock change or price change behind
+func TestCompoundCommandsAreAtomic(t *testing.T) {
+	// No audit_logs table yet, so every audit entry fails
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.ProductAlias{}, &models.FavoriteProduct{},
+		&models.StockMovement{}, &models.PriceHistory{}, &models.Sale{}, &models.DailySummary{},
+		&models.Customer{}, &models.LoyaltyTransaction{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	db.Create(shop)
+
+	productRepo := repository.NewProductRepository(db)
+	milk := &models.Product{ShopID: shop.ID, Name: "Milk", SellingPrice: 60, CurrentStock: 30, Unit: "packet", IsActive: true}
+	productRepo.Create(milk)
+	customerRepo := repository.NewCustomerRepository(db)
+	customer := &models.Customer{ShopID: shop.ID, Name: "Wanjiru", Phone: "+254722000111", IsActive: true}
+	customerRepo.Create(customer)
+
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		productRepo,
+		repository.NewSaleRepository(db),
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	cmdHandler.SetCustomerRepo(customerRepo)
+	parser := services.NewCommandParser(nil, nil)
+	send := func(text string) (string, error) {
+		return cmdHandler.Handle(shop.Phone, parser.Parse(text))
+	}
+	check := func(when string, stock int, price float64, sales int64, points int) {
+		t.Helper()
+		product, _ := productRepo.GetByID(milk.ID)
+		if product.CurrentStock != stock || product.SellingPrice != price {
+			t.Errorf("%s: milk stock %d at %.2f; want %d at %.2f", when, product.CurrentStock, product.SellingPrice, stock, price)
+		}
+		var count int64
+		db.Model(&models.Sale{}).Where("shop_id = ?", shop.ID).Count(&count)
+		if count != sales {
+			t.Errorf("%s: %d sales; want %d", when, count, sales)
+		}
+		c, _ := customerRepo.GetByID(customer.ID)
+		if c.LoyaltyPoints != points {
+			t.Errorf("%s: %d loyalty points; want %d", when, c.LoyaltyPoints, points)
+		}
+	}
+
+	if _, err := send("sell milk 5 +254722000111"); err == nil {
+		t.Error("sell without an audit log table succeeded")
+	}
+	check("failed sell", 30, 60, 0, 0)
+
+	if _, err := send("add milk 70 10"); err == nil {
+		t.Error("restock without an audit log table succeeded")
+	}
+	check("failed restock", 30, 60, 0, 0)
+
+	if err := db.AutoMigrate(&models.AuditLog{}); err != nil {
+		t.Fatalf("migrate audit logs: %v", err)
+	}
+
+	reply, err := send("sell milk 5 +254722000111")
+	if err != nil || !strings.Contains(strings.ToLower(reply), "sold") {
+		t.Fatalf("sell = %q, %v", reply, err)
+	}
+	check("sell", 25, 60, 1, 30)
+
+	if _, err := send("add milk 70 10"); err != nil {
+		t.Fatalf("restock: %v", err)
+	}
+	check("restock", 35, 70, 1, 30)
+
+	var audits int64
+	db.Model(&models.AuditLog{}).Where("shop_id = ?", shop.ID).Count(&audits)
+	if audits != 2 {
+		t.Errorf("%d audit entries; want one for the sale and one for the restock", audits)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/config"
+)
+
+// TestDBPoolConfig tests the connection pool defaults and overrides
+func TestDBPoolConfig(t *testing.T) {
+	cfg, _ := config.Load()
+	if cfg.DBMaxOpenConnections != 25 || cfg.DBMaxIdleConnections != 5 || cfg.GetDBConnMaxLifetime() != 30*time.Minute {
+		t.Errorf("pool defaults = open %d, idle %d, lifetime %s; want 25, 5, 30m",
+			cfg.DBMaxOpenConnections, cfg.DBMaxIdleConnections, cfg.GetDBConnMaxLifetime())
+	}
+
+	t.Setenv("DB_MAX_OPEN_CONNS", "50")
+	t.Setenv("DB_MAX_IDLE_CONNECTIONS", "8") // older name still read
+	t.Setenv("DB_CONN_MAX_LIFETIME_MINUTES", "10")
+	cfg, _ = config.Load()
+	if cfg.DBMaxOpenConnections != 50 || cfg.DBMaxIdleConnections != 8 || cfg.GetDBConnMaxLifetime() != 10*time.Minute {
+		t.Errorf("pool overrides = open %d, idle %d, lifetime %s; want 50, 8, 10m",
+			cfg.DBMaxOpenConnections, cfg.DBMaxIdleConnections, cfg.GetDBConnMaxLifetime())
+	}
+}
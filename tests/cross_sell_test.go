@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/handlers"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestCrossSells tests suggestions from products sold on the same days
+func TestCrossSells(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.StockMovement{}, &models.Sale{}, &models.DailySummary{},
+		&models.WeeklySummary{}, &models.MonthlySummary{}, &models.AuditLog{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	db.Create(shop)
+	other := &models.Shop{Name: "Other", Phone: "+254700000001", IsActive: true}
+	db.Create(other)
+
+	productRepo := repository.NewProductRepository(db)
+	saleRepo := repository.NewSaleRepository(db)
+	product := func(shopID uint, name string, active bool) *models.Product {
+		p := &models.Product{ShopID: shopID, Name: name, SellingPrice: 50, CurrentStock: 100, Unit: "pcs", IsActive: true}
+		productRepo.Create(p)
+		if !active {
+			db.Model(p).Update("is_active", false)
+		}
+		return p
+	}
+	bread := product(shop.ID, "Bread", true)
+	butter := product(shop.ID, "Butter", true)
+	eggs := product(shop.ID, "Eggs", true)
+	soda := product(shop.ID, "Soda", true)
+	jam := product(shop.ID, "Jam", false)
+	theirs := product(other.ID, "Milk", true)
+
+	sell := func(p *models.Product, daysAgo int) {
+		day := time.Now().AddDate(0, 0, -daysAgo)
+		db.Create(&models.Sale{ShopID: p.ShopID, ProductID: p.ID, Quantity: 1, UnitPrice: 50, TotalAmount: 50,
+			PaymentMethod: models.PaymentCash, CreatedAt: time.Date(day.Year(), day.Month(), day.Day(), 12, 0, 0, 0, time.UTC)})
+	}
+	for _, day := range []int{1, 2, 3} {
+		sell(bread, day)
+		sell(butter, day)
+		sell(butter, day) // counted once per day
+	}
+	for _, day := range []int{1, 2} {
+		sell(eggs, day)
+		sell(jam, day)
+	}
+	sell(soda, 5) // never with bread
+	db.Create(&models.Sale{ShopID: other.ID, ProductID: theirs.ID, Quantity: 1, TotalAmount: 50, PaymentMethod: models.PaymentCash,
+		CreatedAt: time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day()-1, 12, 0, 0, 0, time.UTC)})
+
+	ids, err := saleRepo.GetFrequentlyBoughtTogether(bread.ID, shop.ID, 5)
+	if err != nil {
+		t.Fatalf("GetFrequentlyBoughtTogether() error: %v", err)
+	}
+	if fmt.Sprint(ids) != fmt.Sprint([]uint{butter.ID, eggs.ID}) {
+		t.Errorf("cross-sells = %v; want [butter eggs] = [%d %d]", ids, butter.ID, eggs.ID)
+	}
+
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		productRepo,
+		saleRepo,
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	crossSells := services.NewCrossSellService(saleRepo, productRepo)
+	cmdHandler.SetCrossSells(crossSells)
+	sellBread := func() string {
+		reply, err := cmdHandler.Handle(shop.Phone, services.NewCommandParser(nil, nil).Parse("sell bread 1"))
+		if err != nil {
+			t.Fatalf("sell error: %v", err)
+		}
+		return reply
+	}
+
+	// The first sale doesn't wait for suggestions; they are worked out after
+	if reply := sellBread(); strings.Contains(reply, "Customers also buy") {
+		t.Errorf("first sell reply = %q; want no suggestion before they are worked out", reply)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for crossSells.Cached(shop.ID, bread.ID, 2) == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if reply := sellBread(); !strings.Contains(reply, "Customers also buy: Butter, Eggs.") {
+		t.Errorf("sell reply = %q; want the cross-sell suggestion", reply)
+	}
+
+	// Results are kept
+	for _, day := range []int{0, 1, 2, 3} {
+		sell(soda, day)
+	}
+	cached, err := crossSells.Suggest(shop.ID, bread.ID, 5)
+	if err != nil {
+		t.Fatalf("Suggest() error: %v", err)
+	}
+	var cachedNames []string
+	for _, p := range cached {
+		cachedNames = append(cachedNames, p.Name)
+	}
+	if strings.Join(cachedNames, ",") != "Butter,Eggs" {
+		t.Errorf("kept cross-sells = %v; want Butter, Eggs", cachedNames)
+	}
+
+	h := handlers.NewProductHandler(productRepo)
+	h.SetCrossSells(services.NewCrossSellService(saleRepo, productRepo))
+	app := fiber.New(fiber.Config{ErrorHandler: utils.ErrorHandler})
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("shop_id", shop.ID)
+		return c.Next()
+	})
+	app.Get("/products/:id/cross-sells", h.GetCrossSells)
+
+	resp, err := app.Test(httptest.NewRequest("GET", fmt.Sprintf("/products/%d/cross-sells?limit=2", bread.ID), nil))
+	if err != nil || resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("cross-sells endpoint: %v %v", err, resp)
+	}
+	var body struct {
+		Data []models.Product `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	var names []string
+	for _, p := range body.Data {
+		names = append(names, p.Name)
+	}
+	if strings.Join(names, ",") != "Soda,Butter" {
+		t.Errorf("endpoint products = %v; want Soda then Butter from a fresh service", names)
+	}
+
+	resp, _ = app.Test(httptest.NewRequest("GET", fmt.Sprintf("/products/%d/cross-sells", theirs.ID), nil))
+	if resp.StatusCode == fiber.StatusOK {
+		t.Error("another shop's product should be refused")
+	}
+}
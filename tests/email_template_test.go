@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/email"
+)
+
+// TestEmailTemplates tests that emails are rendered from the shop's own
+// template with its branding, falling back to the seeded defaults
+func TestEmailTemplates(t *testing.T) {
+	mock := &mockSendGrid{}
+	server := mock.server(t)
+	defer server.Close()
+
+	db := openTestDB(t, &models.EmailTemplate{})
+	templates := email.NewTemplates(repository.NewEmailTemplateRepository(db))
+	if err := templates.SeedDefaults(); err != nil {
+		t.Fatalf("SeedDefaults() error: %v", err)
+	}
+	var seeded int64
+	db.Model(&models.EmailTemplate{}).Where("shop_id = 0").Count(&seeded)
+	if seeded != int64(len(models.EmailTemplateKeys)) {
+		t.Fatalf("seeded %d templates; want %d", seeded, len(models.EmailTemplateKeys))
+	}
+
+	emailSvc := email.New(&email.Config{APIKey: "sg-key", FromEmail: "reports@dukapos.io", BaseURL: server.URL})
+	emailSvc.SetTemplates(templates)
+	shop := &models.Shop{ID: 7, Name: "Mama Mboga", BrandLogo: "https://cdn.example.com/logo.png", BrandPrimaryColor: "#ff6600"}
+	vars := map[string]string{"products": "Milk <2 left>"}
+
+	if err := emailSvc.SendTemplate(shop, "owner@example.com", models.EmailTemplateLowStock, vars); err != nil {
+		t.Fatalf("SendTemplate() error: %v", err)
+	}
+	if subject := mock.sent[0]["subject"]; subject != "Low stock at Mama Mboga" {
+		t.Errorf("default subject = %q", subject)
+	}
+	content := mock.sent[0]["content"].([]interface{})
+	html := content[0].(map[string]interface{})["value"].(string)
+	text := content[1].(map[string]interface{})["value"].(string)
+	for _, want := range []string{"Milk &lt;2 left&gt;", `src="https://cdn.example.com/logo.png"`, "#ff6600"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("html missing %q:\n%s", want, html)
+		}
+	}
+	if !strings.Contains(text, "Milk <2 left>") {
+		t.Errorf("text = %q; want the unescaped product list", text)
+	}
+
+	// The shop's own version replaces the default for that shop only
+	_, err := templates.Save(shop.ID, models.EmailTemplateLowStock, "Restock {{products}} at {{shop_name}}", "<p>Order more {{products}}</p>", "")
+	if err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	if _, err := templates.Save(shop.ID, "invoice", "Subject", "<p>Body</p>", ""); err != email.ErrUnknownTemplate {
+		t.Errorf("Save(invoice) = %v; want ErrUnknownTemplate", err)
+	}
+	emailSvc.SendTemplate(shop, "owner@example.com", models.EmailTemplateLowStock, vars)
+	if subject := mock.sent[1]["subject"]; subject != "Restock Milk <2 left> at Mama Mboga" {
+		t.Errorf("custom subject = %q", subject)
+	}
+	text = mock.sent[1]["content"].([]interface{})[1].(map[string]interface{})["value"].(string)
+	if text != "Order more Milk <2 left>" {
+		t.Errorf("derived text = %q", text)
+	}
+	if tmpl, customized, _ := templates.Get(8, models.EmailTemplateLowStock); customized || tmpl.ShopID != 0 {
+		t.Errorf("other shop got %+v (customized %v); want the default", tmpl, customized)
+	}
+
+	if err := templates.Reset(shop.ID, models.EmailTemplateLowStock); err != nil {
+		t.Fatalf("Reset() error: %v", err)
+	}
+	if _, customized, _ := templates.Get(shop.ID, models.EmailTemplateLowStock); customized {
+		t.Error("template still customised after reset")
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestErrorHandlerCodes tests that the global error handler always emits an error code
+func TestErrorHandlerCodes(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: utils.ErrorHandler})
+	app.Get("/user-error", func(c *fiber.Ctx) error {
+		return utils.NewUserError(utils.CodeProductNotFound, "Product not found")
+	})
+	app.Get("/fiber-error", func(c *fiber.Ctx) error {
+		return fiber.ErrNotFound
+	})
+	app.Get("/plain-error", func(c *fiber.Ctx) error {
+		return errors.New("pq: connection refused")
+	})
+	app.Get("/send-error", func(c *fiber.Ctx) error {
+		return utils.SendError(c, fiber.StatusBadRequest, utils.CodeInsufficientStock, "Insufficient stock")
+	})
+
+	tests := []struct {
+		path    string
+		status  int
+		code    utils.ErrorCode
+		message string
+	}{
+		{"/user-error", fiber.StatusNotFound, utils.CodeProductNotFound, "Product not found"},
+		{"/fiber-error", fiber.StatusNotFound, utils.CodeNotFound, "Not Found"},
+		{"/plain-error", fiber.StatusInternalServerError, utils.CodeInternal, "Internal server error"},
+		{"/send-error", fiber.StatusBadRequest, utils.CodeInsufficientStock, "Insufficient stock"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("GET", tt.path, nil))
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, resp.StatusCode)
+			}
+
+			var body map[string]string
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode body: %v", err)
+			}
+			if body["code"] != string(tt.code) {
+				t.Errorf("expected code %s, got %s", tt.code, body["code"])
+			}
+			if body["error"] != tt.message {
+				t.Errorf("expected error %q, got %q", tt.message, body["error"])
+			}
+		})
+	}
+}
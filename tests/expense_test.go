@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/handlers"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/routes"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestRecurringExpensesRecordDue tests that the scheduler records recurring
+// expenses as they fall due, catching up on missed ones, and never twice
+func TestRecurringExpensesRecordDue(t *testing.T) {
+	db := openTestDB(t, &models.Expense{}, &models.RecurringExpense{})
+	repo := repository.NewExpenseRepository(db)
+
+	now := time.Date(2024, 11, 15, 10, 0, 0, 0, time.Local)
+	rent := &models.Expense{ShopID: 1, Amount: 15000, Category: "rent", SpentAt: now.AddDate(0, -2, 0)}
+	recurring, err := repo.CreateRecurring(rent, models.ExpenseMonthly)
+	if err != nil {
+		t.Fatalf("CreateRecurring: %v", err)
+	}
+	if rent.RecurringExpenseID == nil || *rent.RecurringExpenseID != recurring.ID {
+		t.Fatalf("first rent not linked to its recurring expense: %+v", rent)
+	}
+	airtime := &models.Expense{ShopID: 1, Amount: 50, Category: "airtime", SpentAt: now}
+	if _, err := repo.CreateRecurring(airtime, models.ExpenseDaily); err != nil {
+		t.Fatalf("CreateRecurring: %v", err)
+	}
+
+	// Two months of rent were missed; airtime isn't due until tomorrow
+	n, err := repo.RecordDue(now)
+	if err != nil || n != 2 {
+		t.Fatalf("RecordDue = %d, %v; want 2", n, err)
+	}
+	if n, err := repo.RecordDue(now); err != nil || n != 0 {
+		t.Errorf("second RecordDue = %d, %v; want 0", n, err)
+	}
+	total, _ := repo.Total(1, now.AddDate(0, -3, 0), now.Add(time.Minute))
+	if total != 3*15000+50 {
+		t.Errorf("total = %.0f; want %d", total, 3*15000+50)
+	}
+
+	if err := repo.StopRecurring(1, recurring.ID); err != nil {
+		t.Fatalf("StopRecurring: %v", err)
+	}
+	if err := repo.StopRecurring(2, recurring.ID+1); err == nil {
+		t.Error("stopped another shop's recurring expense")
+	}
+	if n, _ := repo.RecordDue(now.AddDate(0, 2, 0)); n != 61 {
+		t.Errorf("RecordDue after stopping rent = %d; want only airtime's 61", n)
+	}
+}
+
+// TestRecurringExpenseMonthEnd tests that a monthly expense from the 31st
+// falls on the last day of short months and goes back to the 31st after
+func TestRecurringExpenseMonthEnd(t *testing.T) {
+	db := openTestDB(t, &models.Expense{}, &models.RecurringExpense{})
+	repo := repository.NewExpenseRepository(db)
+
+	rent := &models.Expense{ShopID: 1, Amount: 15000, Category: "rent", SpentAt: time.Date(2025, 1, 31, 9, 0, 0, 0, time.Local)}
+	if _, err := repo.CreateRecurring(rent, models.ExpenseMonthly); err != nil {
+		t.Fatalf("CreateRecurring: %v", err)
+	}
+	if _, err := repo.RecordDue(time.Date(2025, 5, 31, 12, 0, 0, 0, time.Local)); err != nil {
+		t.Fatalf("RecordDue: %v", err)
+	}
+
+	var expenses []models.Expense
+	db.Order("spent_at").Find(&expenses)
+	var days []string
+	for _, e := range expenses {
+		days = append(days, e.SpentAt.Format("Jan 2"))
+	}
+	if got := strings.Join(days, ", "); got != "Jan 31, Feb 28, Mar 31, Apr 30, May 31" {
+		t.Errorf("rent days = %s; want the 31st or the month's last day", got)
+	}
+
+	leap := &models.RecurringExpense{Frequency: models.ExpenseMonthly, DayOfMonth: 30}
+	if next := leap.After(time.Date(2024, 1, 30, 9, 0, 0, 0, time.Local)); next.Format("2006-01-02 15:04") != "2024-02-29 09:00" {
+		t.Errorf("next after Jan 30, 2024 = %s; want Feb 29 at 09:00", next)
+	}
+}
+
+// TestExpensesAPIAndNetProfit tests recording expenses over HTTP and that
+// net profit takes them off along with the cost of goods
+func TestExpensesAPIAndNetProfit(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.Sale{}, &models.AuditLog{},
+		&models.Expense{}, &models.RecurringExpense{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	db.Create(shop)
+	product := &models.Product{ShopID: shop.ID, Name: "Milk", SellingPrice: 60, CostPrice: 45, CurrentStock: 10, IsActive: true}
+	db.Create(product)
+	db.Create(&models.Sale{ShopID: shop.ID, ProductID: product.ID, Quantity: 10, TotalAmount: 600, CostAmount: 450, Profit: 150})
+
+	expenseRepo := repository.NewExpenseRepository(db)
+	saleRepo := repository.NewSaleRepository(db)
+	expenses := handlers.NewExpenseHandler(expenseRepo)
+	reports := handlers.NewReportHandler(saleRepo, repository.NewProductRepository(db), repository.NewDailySummaryRepository(db))
+
+	app := serverApp(t, db, routes.RouteConfig{ExpenseHandler: expenses, ReportHandler: reports}, shop)
+	do := func(method, path, body string) (int, []byte) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		out, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, out
+	}
+
+	if status, out := do("GET", "/api/v1/reports/net-profit", ""); status != fiber.StatusServiceUnavailable {
+		t.Errorf("net profit without expenses: status %d %s; want 503", status, out)
+	}
+	reports.SetExpenseRepo(expenseRepo)
+
+	for _, body := range []string{
+		`{"amount": 0, "category": "rent"}`,
+		`{"amount": 100}`,
+		`{"amount": 100, "category": "rent", "repeat": "yearly"}`,
+		`{"amount": 100, "category": "rent", "spent_at": "30/11/2024"}`,
+	} {
+		if status, out := do("POST", "/api/v1/expenses", body); status != fiber.StatusBadRequest {
+			t.Errorf("POST %s: status %d %s; want 400", body, status, out)
+		}
+	}
+	status, out := do("POST", "/api/v1/expenses", `{"amount": 70, "category": "Transport", "note": "matatu"}`)
+	if status != fiber.StatusCreated {
+		t.Fatalf("POST expense: status %d %s", status, out)
+	}
+	status, out = do("POST", "/api/v1/expenses", `{"amount": 30, "category": "airtime", "repeat": "weekly"}`)
+	if status != fiber.StatusCreated || !strings.Contains(string(out), `"frequency":"weekly"`) {
+		t.Fatalf("POST recurring expense: status %d %s", status, out)
+	}
+	// Outside this month, so left out of the reports
+	do("POST", "/api/v1/expenses", fmt.Sprintf(`{"amount": 999, "category": "rent", "spent_at": %q}`, time.Now().AddDate(0, -2, 0).Format("2006-01-02")))
+
+	status, out = do("GET", "/api/v1/expenses", "")
+	var list struct {
+		Count      int                               `json:"count"`
+		Total      float64                           `json:"total"`
+		ByCategory []repository.ExpenseCategoryTotal `json:"by_category"`
+	}
+	json.Unmarshal(out, &list)
+	if status != fiber.StatusOK || list.Count != 2 || list.Total != 100 || len(list.ByCategory) != 2 || list.ByCategory[0].Category != "transport" {
+		t.Errorf("GET expenses: status %d %s", status, out)
+	}
+
+	status, out = do("GET", "/api/v1/reports/net-profit", "")
+	var report struct {
+		TotalSales    float64 `json:"total_sales"`
+		CostOfGoods   float64 `json:"cost_of_goods"`
+		GrossProfit   float64 `json:"gross_profit"`
+		TotalExpenses float64 `json:"total_expenses"`
+		NetProfit     float64 `json:"net_profit"`
+	}
+	json.Unmarshal(out, &report)
+	if status != fiber.StatusOK || report.TotalSales != 600 || report.CostOfGoods != 450 || report.GrossProfit != 150 ||
+		report.TotalExpenses != 100 || math.Abs(report.NetProfit-50) > 0.001 {
+		t.Errorf("GET net profit: status %d %s", status, out)
+	}
+	if status, out := do("GET", "/api/v1/reports/net-profit?from=2024-12-01&to=2024-11-01", ""); status != fiber.StatusBadRequest {
+		t.Errorf("net profit with to before from: status %d %s; want 400", status, out)
+	}
+
+	if status, out := do("DELETE", "/api/v1/expenses/9999", ""); status != fiber.StatusNotFound {
+		t.Errorf("DELETE unknown expense: status %d %s; want 404", status, out)
+	}
+
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		repository.NewProductRepository(db),
+		saleRepo,
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	cmdHandler.SetExpenseRepo(expenseRepo)
+	parser := services.NewCommandParser(nil, nil)
+	reply, err := cmdHandler.Handle(shop.Phone, parser.Parse("expense add 20 transport boda to market"))
+	if err != nil || !strings.Contains(reply, "KSh 20 on transport") {
+		t.Fatalf("expense add = %q, %v", reply, err)
+	}
+	reply, err = cmdHandler.Handle(shop.Phone, parser.Parse("expense"))
+	if err != nil || !strings.Contains(reply, "boda to market") || !strings.Contains(reply, "transport: KSh 90") {
+		t.Errorf("expense = %q, %v", reply, err)
+	}
+	reply, err = cmdHandler.Handle(shop.Phone, parser.Parse("profit"))
+	if err != nil || !strings.Contains(reply, "Expenses: KSh 120") || !strings.Contains(reply, "NET PROFIT: KSh 30") {
+		t.Errorf("profit = %q, %v; want expenses 120 and net profit 30", reply, err)
+	}
+	if reply, _ := cmdHandler.Handle(shop.Phone, parser.Parse("expense add lots rent")); !strings.Contains(reply, "Usage") {
+		t.Errorf("expense add without an amount = %q; want usage", reply)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	exporthandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/export"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/routes"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestExportRangesAndFilters tests exporting a chosen period, format and
+// subset of sales
+func TestExportRangesAndFilters(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.Sale{}, &models.DailySummary{}, &models.WeeklySummary{}, &models.MonthlySummary{},
+		&models.ClosingStockSnapshot{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	db.Create(shop)
+	milk := &models.Product{ShopID: shop.ID, Name: "Milk", Category: "Dairy", SellingPrice: 60, CostPrice: 50, CurrentStock: 10, IsActive: true}
+	bread := &models.Product{ShopID: shop.ID, Name: "Bread", Category: "Bakery", SellingPrice: 50, CostPrice: 40, CurrentStock: 5, IsActive: true}
+	db.Create(milk)
+	db.Create(bread)
+
+	sell := func(p *models.Product, qty int, method models.PaymentMethod, day string) {
+		at, _ := time.ParseInLocation("2006-01-02 15:04", day+" 10:00", time.Local)
+		db.Create(&models.Sale{ShopID: shop.ID, ProductID: p.ID, Quantity: qty, UnitPrice: p.SellingPrice,
+			TotalAmount: p.SellingPrice * float64(qty), Profit: (p.SellingPrice - p.CostPrice) * float64(qty),
+			PaymentMethod: method, CreatedAt: at})
+	}
+	sell(milk, 1, models.PaymentCash, "2026-01-05")
+	sell(bread, 2, models.PaymentMpesa, "2026-01-10")
+	sell(milk, 3, models.PaymentMpesa, "2026-01-31")
+	sell(milk, 4, models.PaymentMpesa, "2026-02-01") // after the period
+
+	h := exporthandler.NewExportHandler(repository.NewProductRepository(db),
+		repository.NewSaleRepository(db), repository.NewDailySummaryRepository(db))
+	h.SetShopRepo(repository.NewShopRepository(db))
+	h.SetClosingStockRepo(repository.NewClosingStockRepository(db))
+	h.SetMaxRange(60)
+	app := serverApp(t, db, routes.RouteConfig{ExportHandler: h}, shop)
+
+	get := func(target string) (int, string, string, string) {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest("GET", target, nil))
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", target, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body), resp.Header.Get("Content-Type"), resp.Header.Get("Content-Disposition")
+	}
+	rows := func(body string) [][]string {
+		t.Helper()
+		records, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+		if err != nil {
+			t.Fatalf("bad CSV %q: %v", body, err)
+		}
+		return records[1:]
+	}
+
+	status, body, contentType, disposition := get("/api/v1/export/sales?from=2026-01-01&to=2026-01-31&payment_method=mpesa")
+	if status != fiber.StatusOK || contentType != "text/csv" {
+		t.Fatalf("sales export = %d %s: %s", status, contentType, body)
+	}
+	if disposition != "attachment; filename=mama-mboga_sales_20260101-20260131.csv" {
+		t.Errorf("Content-Disposition = %q", disposition)
+	}
+	if got := rows(body); len(got) != 2 || got[0][2] != "Milk" || got[0][3] != "3" || got[1][2] != "Bread" {
+		t.Errorf("M-Pesa sales in January = %v; want milk on the 31st and bread", got)
+	}
+
+	_, body, _, _ = get("/api/v1/export/sales?from=2026-01-01&to=2026-02-28&category=dairy")
+	if got := rows(body); len(got) != 3 {
+		t.Errorf("dairy sales = %d rows; want 3", len(got))
+	}
+	_, body, _, _ = get(fmt.Sprintf("/api/v1/export/sales?from=2026-01-01&to=2026-01-31&product_id=%d", bread.ID))
+	if got := rows(body); len(got) != 1 || got[0][2] != "Bread" {
+		t.Errorf("bread sales = %v", got)
+	}
+
+	status, body, contentType, disposition = get("/api/v1/export/sales?from=2026-01-01&to=2026-01-31&format=xlsx")
+	if status != fiber.StatusOK || !strings.HasPrefix(body, "PK") ||
+		contentType != "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet" || !strings.HasSuffix(disposition, ".xlsx") {
+		t.Errorf("xlsx export = %d %s %s", status, contentType, disposition)
+	}
+
+	// A report for one product, added up from its sales
+	status, body, _, _ = get(fmt.Sprintf("/api/v1/export/report?from=2026-01-01&to=2026-01-31&format=json&product_id=%d", milk.ID))
+	var report struct {
+		Date             string  `json:"date"`
+		TotalSales       float64 `json:"total_sales"`
+		TransactionCount int     `json:"transaction_count"`
+	}
+	json.Unmarshal([]byte(body), &report)
+	if status != fiber.StatusOK || report.TotalSales != 240 || report.TransactionCount != 2 || report.Date != "2026-01-01 to 2026-01-31" {
+		t.Errorf("milk report = %d %+v; want KSh 240 over 2 sales", status, report)
+	}
+
+	status, body, _, _ = get("/api/v1/export/inventory?from=2026-01-01&to=2026-01-31&format=json&category=Dairy")
+	var inventory struct {
+		Inventory []struct {
+			Name      string `json:"name"`
+			UnitsSold int    `json:"units_sold"`
+		} `json:"inventory"`
+		TotalStockValue float64 `json:"total_stock_value"`
+	}
+	json.Unmarshal([]byte(body), &inventory)
+	if status != fiber.StatusOK || len(inventory.Inventory) != 1 || inventory.Inventory[0].UnitsSold != 4 || inventory.TotalStockValue != 600 {
+		t.Errorf("dairy inventory = %d %+v; want milk with 4 sold", status, inventory)
+	}
+
+	// A month is valued at the stock and prices it closed with
+	for _, p := range []*models.Product{milk, bread} {
+		db.Create(&models.ClosingStockSnapshot{ShopID: shop.ID, ProductID: p.ID, ProductName: p.Name, ClosingStock: 7,
+			CostPrice: p.CostPrice, SellingPrice: p.SellingPrice - 5, SnapshotDate: time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)})
+	}
+	inventory.Inventory = nil
+	status, body, _, disposition = get("/api/v1/export/inventory?month=2026-01&format=json&category=Dairy")
+	json.Unmarshal([]byte(body), &inventory)
+	if status != fiber.StatusOK || len(inventory.Inventory) != 1 || inventory.Inventory[0].UnitsSold != 4 || inventory.TotalStockValue != 385 {
+		t.Errorf("dairy inventory at January's close = %d %+v; want milk, 7 at KSh 55, with 4 sold", status, inventory)
+	}
+	if disposition != "attachment; filename=mama-mboga_inventory_20260101-20260131.json" {
+		t.Errorf("closing stock Content-Disposition = %q", disposition)
+	}
+	if status, body, _, _ := get("/api/v1/export/inventory?month=2025-12"); status != fiber.StatusNotFound {
+		t.Errorf("inventory for a month with no closing stock = %d %s; want 404", status, body)
+	}
+
+	if status, body, contentType, _ := get("/api/v1/export/products?format=pdf&category=bakery"); status != fiber.StatusOK ||
+		contentType != "application/pdf" || !strings.HasPrefix(body, "%PDF") {
+		t.Errorf("products PDF = %d %s", status, contentType)
+	}
+
+	for _, target := range []string{
+		"/api/v1/export/sales?format=docx",
+		"/api/v1/export/sales?from=2026-02-01&to=2026-01-01",
+		"/api/v1/export/report?from=01/02/2026",
+		"/api/v1/export/inventory?month=2026-01&to=2026-01-31",
+		"/api/v1/export/inventory?month=January",
+		"/api/v1/export/sales?from=2026-01-01&to=2026-06-30", // longer than 60 days
+	} {
+		if status, body, _, _ := get(target); status != fiber.StatusBadRequest {
+			t.Errorf("GET %s = %d %s; want 400", target, status, body)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/handlers"
+	exporthandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/export"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/export"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/jobs"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/storage"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestQueuedExports tests that product exports are queued with a 202,
+// run by a worker, and downloadable only by the shop that asked
+func TestQueuedExports(t *testing.T) {
+	db := openTestDB(t, &models.Product{}, &models.Sale{}, &models.DailySummary{})
+	db.Create(&models.Product{ShopID: 1, Name: "Milk", SellingPrice: 60, CostPrice: 50, CurrentStock: 10, IsActive: true})
+
+	exportHandler := exporthandler.NewExportHandler(repository.NewProductRepository(db),
+		repository.NewSaleRepository(db), repository.NewDailySummaryRepository(db))
+	queue := jobs.NewJobQueue(jobs.NewMemoryStore(), 1)
+	finished := make(chan *jobs.Job, 1)
+	queue.SetNotifier(func(job *jobs.Job) { finished <- job })
+	exportHandler.SetJobQueue(queue)
+	queue.Start()
+	defer queue.Shutdown(context.Background())
+	jobHandler := handlers.NewJobHandler(queue)
+
+	shopID := uint(1)
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("shop_id", shopID)
+		return c.Next()
+	})
+	app.Get("/export/products", exportHandler.ExportProducts)
+	app.Get("/jobs/:id", jobHandler.GetJob)
+	app.Get("/jobs/:id/download", jobHandler.Download)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/export/products?format=csv", nil))
+	if err != nil || resp.StatusCode != fiber.StatusAccepted {
+		t.Fatalf("export status = %v, %v; want 202", resp.StatusCode, err)
+	}
+	var queued struct {
+		JobID string `json:"job_id"`
+	}
+	json.NewDecoder(resp.Body).Decode(&queued)
+
+	select {
+	case job := <-finished:
+		if job.ID != queued.JobID || job.Status != jobs.StatusDone {
+			t.Fatalf("finished job = %+v", job)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("export job didn't finish")
+	}
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/jobs/"+queued.JobID, nil))
+	var status struct {
+		Job         jobs.Job `json:"job"`
+		DownloadURL string   `json:"download_url"`
+	}
+	json.NewDecoder(resp.Body).Decode(&status)
+	if status.Job.Status != jobs.StatusDone || status.DownloadURL == "" {
+		t.Errorf("job status = %+v", status)
+	}
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/jobs/"+queued.JobID+"/download", nil))
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusOK || !strings.Contains(string(body), "Milk") {
+		t.Errorf("download = %d %q; want the products CSV", resp.StatusCode, body)
+	}
+
+	// Another shop can't see the job
+	shopID = 2
+	if resp, _ := app.Test(httptest.NewRequest("GET", "/jobs/"+queued.JobID, nil)); resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("other shop status = %d; want 404", resp.StatusCode)
+	}
+}
+
+// TestBackgroundExportJobs tests that a large sales export is streamed to
+// a file, announced to the shop with a signed link, and deleted once it
+// expires
+func TestBackgroundExportJobs(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.Sale{}, &models.DailySummary{},
+		&models.WeeklySummary{}, &models.MonthlySummary{}, &models.ExportJob{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Email: "mama@example.com", IsActive: true}
+	db.Create(shop)
+	product := &models.Product{ShopID: shop.ID, Name: "Milk", Category: "Dairy", SellingPrice: 60, CostPrice: 50, CurrentStock: 10, IsActive: true}
+	db.Create(product)
+	db.Create(&models.Product{ShopID: shop.ID, Name: "Bread", Category: "Bakery", SellingPrice: 55, CostPrice: 45, CurrentStock: 5, IsActive: true})
+
+	// More sales than one batch, spread over a year
+	start := time.Date(2025, time.January, 1, 9, 0, 0, 0, time.Local)
+	sales := make([]models.Sale, 1200)
+	for i := range sales {
+		sales[i] = models.Sale{ShopID: shop.ID, ProductID: product.ID, Quantity: 1, UnitPrice: 60, TotalAmount: 60,
+			CostAmount: 50, Profit: 10, PaymentMethod: models.PaymentCash, CreatedAt: start.Add(time.Duration(i) * 7 * time.Hour)}
+	}
+	if err := db.CreateInBatches(sales, 200).Error; err != nil {
+		t.Fatalf("failed to create sales: %v", err)
+	}
+
+	root := t.TempDir()
+	queue := jobs.NewJobQueue(jobs.NewMemoryStore(), 1)
+	svc := export.NewJobService(export.JobConfig{
+		Store:   storage.NewLocalStore(root),
+		BaseURL: "https://pos.example.com/",
+		Secret:  "test-secret",
+	}, queue, repository.NewExportJobRepository(db), repository.NewShopRepository(db),
+		repository.NewSaleRepository(db), repository.NewProductRepository(db))
+	whatsapp := make(chan string, 4)
+	var emailed []string
+	svc.SetMessageSenders(func(phone, message string) error {
+		whatsapp <- message
+		return nil
+	}, func(to, subject, body string) error {
+		emailed = append(emailed, to+": "+subject)
+		return nil
+	})
+	queue.Start()
+	defer queue.Shutdown(context.Background())
+
+	exportHandler := exporthandler.NewExportHandler(repository.NewProductRepository(db),
+		repository.NewSaleRepository(db), repository.NewDailySummaryRepository(db))
+	exportHandler.SetShopRepo(repository.NewShopRepository(db))
+	exportHandler.SetExportJobs(svc)
+
+	shopID := shop.ID
+	app := fiber.New()
+	app.Get("/exports/:id", exportHandler.DownloadExport)
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("shop_id", shopID)
+		return c.Next()
+	})
+	app.Post("/export/jobs", exportHandler.CreateExportJob)
+	app.Get("/export/jobs/:id", exportHandler.GetExportJob)
+
+	create := func(body string) (*models.ExportJob, int) {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/export/jobs", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("POST /export/jobs: %v", err)
+		}
+		var out struct {
+			Job models.ExportJob `json:"job"`
+		}
+		json.NewDecoder(resp.Body).Decode(&out)
+		return &out.Job, resp.StatusCode
+	}
+	status := func(id uint) (models.ExportJob, string) {
+		t.Helper()
+		resp, _ := app.Test(httptest.NewRequest("GET", fmt.Sprintf("/export/jobs/%d", id), nil))
+		var out struct {
+			Job         models.ExportJob `json:"job"`
+			DownloadURL string           `json:"download_url"`
+		}
+		json.NewDecoder(resp.Body).Decode(&out)
+		return out.Job, out.DownloadURL
+	}
+	wait := func() string {
+		t.Helper()
+		select {
+		case message := <-whatsapp:
+			return message
+		case <-time.After(10 * time.Second):
+			t.Fatal("export job didn't finish")
+		}
+		return ""
+	}
+
+	if _, code := create(`{"type": "sales", "format": "pdf", "from": "2025-01-01", "to": "2025-12-31"}`); code != fiber.StatusBadRequest {
+		t.Errorf("PDF job status = %d; want 400", code)
+	}
+	if _, code := create(`{"type": "customers", "format": "csv"}`); code != fiber.StatusBadRequest {
+		t.Errorf("unknown type status = %d; want 400", code)
+	}
+
+	// A whole year is more than a request may export, but fine here
+	job, code := create(`{"type": "sales", "format": "csv", "from": "2025-01-01", "to": "2025-12-31"}`)
+	if code != fiber.StatusAccepted || job.ID == 0 || job.Status != models.ExportJobStatusQueued {
+		t.Fatalf("create = %d %+v; want 202 and a queued job", code, job)
+	}
+	message := wait()
+
+	done, link := status(job.ID)
+	if done.Status != models.ExportJobStatusDone || done.Rows != 1200 || done.Filename != "mama-mboga_sales_20250101-20251231.csv" {
+		t.Fatalf("finished job = %+v", done)
+	}
+	if !strings.HasPrefix(link, "https://pos.example.com/exports/") || !strings.Contains(message, link) {
+		t.Errorf("download link %q should be signed under the base URL and sent in %q", link, message)
+	}
+	if len(emailed) != 1 || !strings.HasPrefix(emailed[0], "mama@example.com: ") {
+		t.Errorf("emails = %v; want one to the shop", emailed)
+	}
+
+	path := strings.TrimPrefix(link, "https://pos.example.com")
+	shopID = 0 // the link works without logging in
+	resp, _ := app.Test(httptest.NewRequest("GET", path, nil))
+	body, _ := io.ReadAll(resp.Body)
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	if resp.StatusCode != fiber.StatusOK || len(lines) != 1201 || !strings.HasPrefix(lines[0], "ID,Date,Product") || !strings.Contains(lines[1], "Milk") {
+		t.Errorf("download = %d with %d lines; want the header and 1200 sales", resp.StatusCode, len(lines))
+	}
+	tampered := path[:len(path)-1] + "0"
+	if strings.HasSuffix(path, "0") {
+		tampered = path[:len(path)-1] + "1"
+	}
+	if resp, _ := app.Test(httptest.NewRequest("GET", tampered, nil)); resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("tampered link status = %d; want 403", resp.StatusCode)
+	}
+
+	// Other shops can't see the job
+	shopID = shop.ID + 1
+	if resp, _ := app.Test(httptest.NewRequest("GET", fmt.Sprintf("/export/jobs/%d", job.ID), nil)); resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("other shop status = %d; want 404", resp.StatusCode)
+	}
+
+	// Products stream to a spreadsheet, filtered by category
+	shopID = shop.ID
+	products, code := create(`{"type": "products", "format": "xlsx", "category": "dairy"}`)
+	if code != fiber.StatusAccepted {
+		t.Fatalf("products job status = %d; want 202", code)
+	}
+	wait()
+	if done, _ := status(products.ID); done.Status != models.ExportJobStatusDone || done.Rows != 1 || !strings.HasSuffix(done.Filename, ".xlsx") {
+		t.Errorf("products job = %+v; want 1 row in an xlsx", done)
+	}
+
+	// After 24 hours the link stops working and the file is deleted
+	svc.SetClock(func() time.Time { return time.Now().Add(25 * time.Hour) })
+	if resp, _ := app.Test(httptest.NewRequest("GET", path, nil)); resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("expired link status = %d; want 403", resp.StatusCode)
+	}
+	var key string
+	db.Model(&models.ExportJob{}).Where("id = ?", job.ID).Pluck("file_path", &key)
+	file := filepath.Join(root, filepath.FromSlash(key))
+	if _, err := os.Stat(file); err != nil || !strings.HasPrefix(key, fmt.Sprintf("exports/%d/", shop.ID)) {
+		t.Fatalf("export saved under %q (%v); want it in the store under the shop", key, err)
+	}
+	// A job left running by a server that stopped is given up on, not
+	// run again
+	stuck := &models.ExportJob{ShopID: shop.ID, Type: models.ExportJobProducts, Format: "csv", Status: models.ExportJobStatusRunning}
+	db.Create(stuck)
+	if n, err := svc.Cleanup(); err != nil || n != 2 {
+		t.Errorf("Cleanup() = %d, %v; want both files removed", n, err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("export file %s should be deleted", file)
+	}
+	db.First(stuck, stuck.ID)
+	if stuck.Status != models.ExportJobStatusFailed || stuck.Error == "" {
+		t.Errorf("stuck job = %+v; want failed", stuck)
+	}
+	shopID = shop.ID
+	if expired, link := status(job.ID); expired.Status != models.ExportJobStatusExpired || link != "" {
+		t.Errorf("expired job = %+v, link %q", expired, link)
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	exporthandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/export"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/routes"
+	"github.com/gofiber/fiber/v2"
+	"github.com/xuri/excelize/v2"
+)
+
+// TestRecordExports tests the customer, loyalty, M-Pesa, supplier and
+// purchase order exports: their IDs and timestamps, periods and formats
+func TestRecordExports(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.Sale{}, &models.DailySummary{},
+		&models.Customer{}, &models.LoyaltyTransaction{}, &models.MpesaPayment{}, &models.MpesaTransaction{},
+		&models.Supplier{}, &models.Order{}, &models.OrderItem{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	db.Create(shop)
+	other := &models.Shop{Name: "Duka Jirani", Phone: "+254722000000", IsActive: true}
+	db.Create(other)
+
+	jan := time.Date(2026, 1, 10, 9, 30, 15, 0, time.Local)
+	feb := time.Date(2026, 2, 10, 9, 30, 15, 0, time.Local)
+	milk := &models.Product{ShopID: shop.ID, Name: "Milk", SellingPrice: 60, CostPrice: 50, IsActive: true}
+	db.Create(milk)
+	bread := &models.Product{ShopID: shop.ID, Name: "Bread", SellingPrice: 65, CostPrice: 55, IsActive: true}
+	db.Create(bread)
+
+	wanjiru := &models.Customer{ShopID: shop.ID, Name: "Wanjiru", Phone: "+254733111222", LoyaltyPoints: 120,
+		TotalSpent: 25000, Tier: models.TierSilver, ReferralCode: "WANJ1", CreatedAt: jan}
+	db.Create(wanjiru)
+	db.Create(&models.Customer{ShopID: other.ID, Name: "Someone Else", ReferralCode: "ELSE1"})
+	db.Create(&models.LoyaltyTransaction{CustomerID: wanjiru.ID, ShopID: shop.ID, Type: models.LoyaltyEarned,
+		Points: 100, PointsAfter: 100, Amount: 10000, CreatedAt: jan})
+	db.Create(&models.LoyaltyTransaction{CustomerID: wanjiru.ID, ShopID: shop.ID, Type: models.LoyaltyBonus,
+		Points: 20, PointsBefore: 100, PointsAfter: 120, CreatedAt: feb})
+
+	db.Create(&models.MpesaPayment{ShopID: shop.ID, Amount: 120, Phone: "254733111222", CheckoutRequestID: "ws_CO_1",
+		MpesaReceipt: "SGH12AB34C", Status: models.MpesaPaymentCompleted, CreatedAt: jan, CompletedAt: &jan})
+	db.Create(&models.MpesaPayment{ShopID: shop.ID, Amount: 60, Phone: "254733111222", CheckoutRequestID: "ws_CO_2",
+		Status: models.MpesaPaymentFailed, FailureReason: "Request cancelled by user", CreatedAt: feb})
+	db.Create(&models.MpesaTransaction{ShopID: shop.ID, Type: "c2b", Amount: 500, TransactionID: "SGH99ZZ99Z",
+		Status: "completed", TransactionTime: jan, AccountReference: "milk"})
+
+	supplier := &models.Supplier{ShopID: shop.ID, Name: "Brookside", Phone: "+254700000001", CreatedAt: jan}
+	db.Create(supplier)
+	order := &models.Order{ShopID: shop.ID, SupplierID: supplier.ID, Status: "delivered", TotalAmount: 1550, CreatedAt: jan}
+	db.Create(order)
+	db.Create(&models.OrderItem{OrderID: order.ID, ProductID: milk.ID, Quantity: 20, UnitCost: 50, TotalCost: 1000})
+	db.Create(&models.OrderItem{OrderID: order.ID, ProductID: bread.ID, Quantity: 10, UnitCost: 55, TotalCost: 550})
+	db.Create(&models.Order{ShopID: shop.ID, SupplierID: supplier.ID, Status: "draft", CreatedAt: feb})
+
+	h := exporthandler.NewExportHandler(repository.NewProductRepository(db),
+		repository.NewSaleRepository(db), repository.NewDailySummaryRepository(db))
+	app := serverApp(t, db, routes.RouteConfig{ExportHandler: h}, shop)
+
+	get := func(target string) (int, []byte) {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest("GET", target, nil))
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", target, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, body
+	}
+	rows := func(target string) [][]string {
+		t.Helper()
+		status, body := get(target)
+		if status != fiber.StatusOK {
+			t.Fatalf("GET %s: status %d, %s", target, status, body)
+		}
+		records, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+		if err != nil {
+			t.Fatalf("GET %s: %v", target, err)
+		}
+		return records
+	}
+
+	if status, _ := get("/api/v1/export/customers"); status != fiber.StatusServiceUnavailable {
+		t.Errorf("customers before the repos are set: status %d; want 503", status)
+	}
+	h.SetCustomerRepos(repository.NewCustomerRepository(db), repository.NewLoyaltyTransactionRepository(db))
+	h.SetMpesaRepos(repository.NewMpesaPaymentRepository(db), repository.NewMpesaTransactionRepository(db))
+	h.SetSupplierRepos(repository.NewSupplierRepository(db), repository.NewOrderRepository(db))
+
+	customers := rows("/api/v1/export/customers")
+	if len(customers) != 2 || customers[0][0] != "ID" || customers[1][1] != "Wanjiru" {
+		t.Fatalf("customers = %v; want a header and only this shop's customer", customers)
+	}
+	if customers[1][6] != "silver" || customers[1][7] != "120" || customers[1][10] != "25000.00" ||
+		customers[1][18] != "2026-01-10 09:30:15" || customers[1][14] != "" {
+		t.Errorf("customer row = %v; want tier, points, spend, created time and no referrer", customers[1])
+	}
+
+	loyalty := rows("/api/v1/export/loyalty-transactions")
+	if len(loyalty) != 3 || loyalty[1][5] != "earned" || loyalty[2][5] != "bonus" || loyalty[1][3] != "Wanjiru" {
+		t.Errorf("loyalty = %v; want both transactions oldest first with the customer", loyalty)
+	}
+	if february := rows("/api/v1/export/loyalty-transactions?from=2026-02-01&to=2026-02-28"); len(february) != 2 || february[1][5] != "bonus" {
+		t.Errorf("February loyalty = %v; want only the bonus", february)
+	}
+
+	payments := rows("/api/v1/export/mpesa-payments")
+	if len(payments) != 3 || payments[1][8] != "SGH12AB34C" || payments[2][7] != "failed" || payments[2][14] != "Request cancelled by user" {
+		t.Errorf("payments = %v; want the completed and the failed payment", payments)
+	}
+	transactions := rows("/api/v1/export/mpesa-transactions")
+	if len(transactions) != 2 || transactions[1][7] != "SGH99ZZ99Z" || transactions[1][8] != "milk" {
+		t.Errorf("transactions = %v; want the paybill payment with its receipt", transactions)
+	}
+
+	suppliers := rows("/api/v1/export/suppliers")
+	if len(suppliers) != 2 || suppliers[1][1] != "Brookside" || suppliers[1][5] != "2026-01-10 09:30:15" {
+		t.Errorf("suppliers = %v", suppliers)
+	}
+	orders := rows("/api/v1/export/purchase-orders")
+	if len(orders) != 4 {
+		t.Fatalf("purchase orders = %v; want a row per item and one for the empty order", orders)
+	}
+	if orders[1][3] != "Brookside" || orders[1][11] != "Milk" || orders[2][11] != "Bread" || orders[2][14] != "550.00" {
+		t.Errorf("delivered order rows = %v", orders[1:3])
+	}
+	if orders[3][4] != "draft" || orders[3][9] != "" {
+		t.Errorf("draft order row = %v; want no item", orders[3])
+	}
+
+	status, body := get("/api/v1/export/customers?format=json")
+	var list []map[string]interface{}
+	if err := json.Unmarshal(body, &list); err != nil || status != fiber.StatusOK || len(list) != 1 {
+		t.Fatalf("JSON customers: status %d, %s", status, body)
+	}
+	if list[0]["referral_code"] != "WANJ1" || list[0]["referred_by"] != nil {
+		t.Errorf("JSON customer = %v; want the referral code and a null referrer", list[0])
+	}
+
+	status, body = get("/api/v1/export/purchase-orders?format=xlsx")
+	if status != fiber.StatusOK {
+		t.Fatalf("Excel purchase orders: status %d", status)
+	}
+	f, err := excelize.OpenReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Excel purchase orders do not open: %v", err)
+	}
+	defer f.Close()
+	if product, _ := f.GetCellValue("Sheet1", "L2"); product != "Milk" {
+		t.Errorf("Excel L2 = %q; want Milk", product)
+	}
+
+	if status, _ := get("/api/v1/export/suppliers?format=pdf"); status != fiber.StatusBadRequest {
+		t.Errorf("PDF suppliers: status %d; want 400", status)
+	}
+}
+
+// TestRecordExportBatches tests that a record export longer than a batch
+// carries on after the last row of each batch, even between rows made in
+// the same second
+func TestRecordExportBatches(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.Sale{}, &models.DailySummary{},
+		&models.MpesaPayment{}, &models.MpesaTransaction{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	db.Create(shop)
+
+	at := time.Date(2026, 3, 2, 8, 0, 0, 0, time.Local)
+	transactions := make([]models.MpesaTransaction, 1203)
+	for i := range transactions {
+		transactions[i] = models.MpesaTransaction{ShopID: shop.ID, Type: "c2b", Amount: 100, Status: "completed",
+			TransactionID: fmt.Sprintf("SGH%07d", i), TransactionTime: at.Add(time.Duration(i/3) * time.Second)}
+	}
+	db.CreateInBatches(transactions, 200)
+
+	h := exporthandler.NewExportHandler(repository.NewProductRepository(db),
+		repository.NewSaleRepository(db), repository.NewDailySummaryRepository(db))
+	h.SetMpesaRepos(repository.NewMpesaPaymentRepository(db), repository.NewMpesaTransactionRepository(db))
+	app := serverApp(t, db, routes.RouteConfig{ExportHandler: h}, shop)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/export/mpesa-transactions?format=jsonl", nil), -1)
+	if err != nil || resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("export failed: %v, %v", resp, err)
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	seen := make(map[string]bool)
+	for scanner.Scan() {
+		var row map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		id := row["transaction_id"].(string)
+		if seen[id] {
+			t.Errorf("transaction %s exported twice", id)
+		}
+		seen[id] = true
+	}
+	if len(seen) != len(transactions) {
+		t.Errorf("exported %d transactions; want %d", len(seen), len(transactions))
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"testing"
+	"time"
+
+	exporthandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/export"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/routes"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/export"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// seedStreamSales gives a shop n sales of one product a minute apart from
+// start, and returns the shop
+func seedStreamSales(tb testing.TB, db *gorm.DB, n int, start time.Time) *models.Shop {
+	tb.Helper()
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	db.Create(shop)
+	milk := &models.Product{ShopID: shop.ID, Name: "Milk", SellingPrice: 60, CostPrice: 50, IsActive: true}
+	db.Create(milk)
+
+	const batch = 1000
+	sales := make([]models.Sale, 0, batch)
+	for i := 0; i < n; i++ {
+		sales = append(sales, models.Sale{ShopID: shop.ID, ProductID: milk.ID, Quantity: 1, UnitPrice: 60,
+			TotalAmount: 60, CostAmount: 50, Profit: 10, PaymentMethod: models.PaymentCash,
+			CreatedAt: start.Add(time.Duration(i) * time.Minute)})
+		if len(sales) == batch || i == n-1 {
+			if err := db.Create(&sales).Error; err != nil {
+				tb.Fatalf("failed to seed sales: %v", err)
+			}
+			sales = sales[:0]
+		}
+	}
+	return shop
+}
+
+// heapWatcher counts what is written through it and samples the heap as
+// it goes, keeping the most seen in use
+type heapWatcher struct {
+	written, sampled int
+	peak             uint64
+}
+
+func (w *heapWatcher) Write(p []byte) (int, error) {
+	w.written += len(p)
+	if w.written-w.sampled >= 256<<10 {
+		w.sample()
+		w.sampled = w.written
+	}
+	return len(p), nil
+}
+
+func (w *heapWatcher) sample() {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	w.peak = max(w.peak, stats.HeapAlloc)
+}
+
+// TestStreamedSalesExport tests that CSV and JSON lines sales exports are
+// streamed newest first across database batches, and that JSON lines is
+// refused for exports that are not a row per record
+func TestStreamedSalesExport(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.Sale{}, &models.DailySummary{}, &models.WeeklySummary{}, &models.MonthlySummary{})
+	start := time.Date(2026, 1, 5, 8, 0, 0, 0, time.Local)
+	shop := seedStreamSales(t, db, 1200, start)
+
+	h := exporthandler.NewExportHandler(repository.NewProductRepository(db),
+		repository.NewSaleRepository(db), repository.NewDailySummaryRepository(db))
+	app := serverApp(t, db, routes.RouteConfig{ExportHandler: h}, shop)
+
+	get := func(target string) (int, string, string) {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest("GET", target, nil), 30000)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", target, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body), resp.Header.Get("Content-Type")
+	}
+
+	status, body, contentType := get("/api/v1/export/sales?format=jsonl&from=2026-01-05&to=2026-01-06")
+	if status != fiber.StatusOK || contentType != "application/x-ndjson" {
+		t.Fatalf("jsonl export: status %d, %s", status, contentType)
+	}
+	lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+	if len(lines) != 1200 {
+		t.Fatalf("jsonl export has %d lines; want 1200", len(lines))
+	}
+	seen := map[float64]bool{}
+	var previous string
+	for i, line := range lines {
+		var row map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &row); err != nil {
+			t.Fatalf("line %d = %q: %v", i+1, line, err)
+		}
+		id := row["id"].(float64)
+		if seen[id] {
+			t.Fatalf("sale %v exported twice", id)
+		}
+		seen[id] = true
+		if date := row["date"].(string); previous != "" && date > previous {
+			t.Fatalf("line %d is dated %s after %s; want newest first", i+1, date, previous)
+		}
+		previous = row["date"].(string)
+	}
+	if newest := start.Add(1199 * time.Minute).Format("2006-01-02 15:04"); !strings.Contains(lines[0], newest) {
+		t.Errorf("first line = %s; want the sale at %s", lines[0], newest)
+	}
+
+	status, body, contentType = get("/api/v1/export/sales?format=csv&from=2026-01-05&to=2026-01-06")
+	if status != fiber.StatusOK || contentType != "text/csv" {
+		t.Fatalf("csv export: status %d, %s", status, contentType)
+	}
+	if rows := strings.Count(body, "\n"); rows != 1201 || !strings.HasPrefix(body, "ID,Date,Product") {
+		t.Errorf("csv export has %d lines; want a header and 1200 sales", rows)
+	}
+
+	if status, _, _ := get("/api/v1/export/report?format=jsonl"); status != fiber.StatusBadRequest {
+		t.Errorf("jsonl report: status %d; want 400", status)
+	}
+}
+
+// TestStreamedSalesExportMemory tests that streaming 100k sales needs no
+// more memory than streaming 10k: only a batch is held at a time. Heap
+// samples include garbage the collector has yet to reach, so the margin
+// is a fixed 16MB; holding every sale of 100k would take several times that
+func TestStreamedSalesExportMemory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("seeds 100k sales")
+	}
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.Sale{}, &models.DailySummary{}, &models.WeeklySummary{}, &models.MonthlySummary{})
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local)
+	shop := seedStreamSales(t, db, 100_000, start)
+	saleRepo := repository.NewSaleRepository(db)
+
+	// Collect often so the heap tracks what is live rather than garbage
+	defer debug.SetGCPercent(debug.SetGCPercent(10))
+
+	// stream exports the first n sales, returning the most the heap grew
+	// by and how much was written
+	stream := func(format export.Format, n int) (int64, int) {
+		t.Helper()
+		runtime.GC()
+		var before runtime.MemStats
+		runtime.ReadMemStats(&before)
+
+		w := &heapWatcher{}
+		rows, err := export.StreamSales(w, format, func(fn func([]models.Sale) error) error {
+			end := start.Add(time.Duration(n) * time.Minute)
+			return saleRepo.EachFilteredNewest(shop.ID, start, end, repository.SaleFilter{}, 500, fn)
+		})
+		if err != nil || rows != n {
+			t.Fatalf("%s: StreamSales() = %d, %v; want %d rows", format, rows, err, n)
+		}
+		w.sample()
+		return int64(w.peak) - int64(before.HeapAlloc), w.written
+	}
+
+	for _, format := range []export.Format{export.FormatCSV, export.FormatJSONLines} {
+		small, _ := stream(format, 10_000)
+		large, written := stream(format, 100_000)
+		t.Logf("%s: heap grew %d bytes for 10k sales, %d for 100k (%d bytes written)", format, small, large, written)
+		if large > small+(16<<20) {
+			t.Errorf("%s: heap grew %d bytes for 100k sales against %d for 10k; want it flat", format, large, small)
+		}
+	}
+}
+
+// BenchmarkStreamSalesCSV measures streaming 10k sales as CSV; allocations
+// per op stay proportional to batches, not to a buffered file
+func BenchmarkStreamSalesCSV(b *testing.B) {
+	db := openTestDB(b, &models.Shop{}, &models.Product{}, &models.Sale{})
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local)
+	shop := seedStreamSales(b, db, 10_000, start)
+	saleRepo := repository.NewSaleRepository(db)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := &heapWatcher{}
+		if _, err := export.StreamSales(w, export.FormatCSV, func(fn func([]models.Sale) error) error {
+			return saleRepo.EachFilteredNewest(shop.ID, start, start.AddDate(1, 0, 0), repository.SaleFilter{}, 500, fn)
+		}); err != nil {
+			b.Fatal(err)
+		}
+		b.SetBytes(int64(w.written))
+	}
+}
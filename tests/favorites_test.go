@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/handlers"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/routes"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestQuickSellFavorites tests pinning favorites over WhatsApp and the
+// API, selling them by number, and the best sellers shown until any are
+// pinned
+func TestQuickSellFavorites(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.ProductAlias{}, &models.FavoriteProduct{},
+		&models.StockMovement{}, &models.Sale{}, &models.DailySummary{}, &models.AuditLog{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	db.Create(shop)
+	other := &models.Shop{Name: "Other", Phone: "+254700000001", IsActive: true}
+	db.Create(other)
+
+	productRepo := repository.NewProductRepository(db)
+	milk := &models.Product{ShopID: shop.ID, Name: "Milk", SellingPrice: 60, CurrentStock: 30, Unit: "packet", IsActive: true}
+	productRepo.Create(milk)
+	bread := &models.Product{ShopID: shop.ID, Name: "Bread", SellingPrice: 65, CurrentStock: 20, Unit: "loaf", IsActive: true}
+	productRepo.Create(bread)
+	sugar := &models.Product{ShopID: shop.ID, Name: "Sugar", SellingPrice: 180, CurrentStock: 10, Unit: "kg", IsActive: true}
+	productRepo.Create(sugar)
+	theirs := &models.Product{ShopID: other.ID, Name: "Soda", SellingPrice: 50, CurrentStock: 10, IsActive: true}
+	productRepo.Create(theirs)
+
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		productRepo,
+		repository.NewSaleRepository(db),
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	parser := services.NewCommandParser(nil, nil)
+	run := func(text string) string {
+		t.Helper()
+		reply, err := cmdHandler.Handle(shop.Phone, parser.Parse(text))
+		if err != nil {
+			t.Fatalf("%q error: %v", text, err)
+		}
+		return reply
+	}
+
+	if reply := run("fav"); !strings.Contains(reply, "No favorites yet") {
+		t.Errorf("fav with no favorites or sales = %q", reply)
+	}
+	if reply := run("sell 1 2"); !strings.Contains(reply, "no favorite #1") {
+		t.Errorf("sell 1 2 with no favorites = %q", reply)
+	}
+
+	// Until favorites are pinned, the week's best sellers are listed but
+	// not sold by number, since they reorder with every sale
+	run("sell bread 5")
+	run("sell milk 2")
+	reply := run("fav")
+	if !strings.Contains(reply, "best sellers") || !strings.Contains(reply, "• Bread") || !strings.Contains(reply, "• Milk") ||
+		strings.Index(reply, "Bread") > strings.Index(reply, "Milk") {
+		t.Errorf("fav from best sellers = %q; want Bread then Milk", reply)
+	}
+	if reply := run("sell 1 1"); !strings.Contains(reply, "no favorite #1") {
+		t.Errorf("sell 1 1 from best sellers = %q; want no favorite #1", reply)
+	}
+
+	if reply := run("fav add sugar"); !strings.Contains(reply, "Sugar is favorite #1") {
+		t.Errorf("fav add sugar = %q", reply)
+	}
+	if reply := run("fav add milk"); !strings.Contains(reply, "Milk is favorite #2") {
+		t.Errorf("fav add milk = %q", reply)
+	}
+	if reply := run("fav add milk"); !strings.Contains(reply, "Milk is favorite #2") {
+		t.Errorf("adding milk again = %q; want it left at #2", reply)
+	}
+	if reply := run("fav add tea"); !strings.Contains(reply, "Tea") {
+		t.Errorf("fav add tea = %q; want product not found", reply)
+	}
+	reply = run("fav")
+	if !strings.Contains(reply, "FAVORITES") || !strings.Contains(reply, "1. Sugar - KSh 180") || !strings.Contains(reply, "2. Milk") {
+		t.Errorf("fav = %q; want Sugar then Milk", reply)
+	}
+
+	if reply := run("sell 2 3"); !strings.Contains(reply, "Milk") {
+		t.Errorf("sell 2 3 = %q; want a milk sale", reply)
+	}
+	db.First(milk, milk.ID)
+	if milk.CurrentStock != 25 {
+		t.Errorf("milk stock = %d; want 25 after selling 2 then 3", milk.CurrentStock)
+	}
+	if reply := run("sell 3 1"); !strings.Contains(reply, "no favorite #3") {
+		t.Errorf("sell 3 1 = %q; want no favorite #3", reply)
+	}
+
+	// A favorite that sells out keeps its number
+	if reply := run("fav add bread"); !strings.Contains(reply, "Bread is favorite #3") {
+		t.Errorf("fav add bread = %q", reply)
+	}
+	run("sell milk 25")
+	// Hidden, as selling out does for shops that hide sold out products
+	db.Model(milk).Updates(map[string]interface{}{"is_active": false, "auto_deactivated": true})
+	if reply := run("fav"); !strings.Contains(reply, "2. Milk - OUT OF STOCK") || !strings.Contains(reply, "3. Bread") {
+		t.Errorf("fav with milk sold out = %q; want milk at #2 marked out of stock", reply)
+	}
+	if reply := run("sell 2 1"); !strings.Contains(reply, "Milk is OUT OF STOCK") {
+		t.Errorf("sell 2 1 with milk sold out = %q; want out of stock", reply)
+	}
+	db.First(bread, bread.ID)
+	if bread.CurrentStock != 15 {
+		t.Errorf("bread stock = %d; want 15, not sold in milk's place", bread.CurrentStock)
+	}
+	run("add milk 60 25")
+	run("fav remove bread")
+
+	if reply := run("fav remove 1"); !strings.Contains(reply, "Sugar is no longer a favorite") {
+		t.Errorf("fav remove 1 = %q", reply)
+	}
+	if reply := run("fav remove bread"); !strings.Contains(reply, "not a favorite") {
+		t.Errorf("fav remove bread = %q", reply)
+	}
+	run("sell 1 1")
+	db.First(milk, milk.ID)
+	if milk.CurrentStock != 24 {
+		t.Errorf("milk stock = %d; want 24 once milk is favorite #1", milk.CurrentStock)
+	}
+
+	for i := 0; i < repository.MaxFavorites+1; i++ {
+		p := &models.Product{ShopID: shop.ID, Name: fmt.Sprintf("Item%d", i), SellingPrice: 10, CurrentStock: 5, IsActive: true}
+		productRepo.Create(p)
+		if err := productRepo.AddFavorite(p); err != nil {
+			if i < repository.MaxFavorites-1 {
+				t.Fatalf("favorite %d: %v", i+2, err)
+			}
+			break
+		}
+	}
+	if reply := run("fav add bread"); !strings.Contains(reply, fmt.Sprintf("already have %d favorites", repository.MaxFavorites)) {
+		t.Errorf("fav add past the limit = %q", reply)
+	}
+	db.Where("shop_id = ? AND product_id <> ?", shop.ID, milk.ID).Delete(&models.FavoriteProduct{})
+
+	// The API lists and changes the same favorites
+	app := serverApp(t, db, routes.RouteConfig{ProductHandler: handlers.NewProductHandler(productRepo)}, shop)
+
+	type favorites struct {
+		Saved     bool             `json:"saved"`
+		Favorites []models.Product `json:"favorites"`
+	}
+	call := func(method, target, body string) (int, favorites) {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, target, err)
+		}
+		out, _ := io.ReadAll(resp.Body)
+		var list favorites
+		json.Unmarshal(out, &list)
+		return resp.StatusCode, list
+	}
+
+	status, list := call("POST", "/api/v1/products/favorites", fmt.Sprintf(`{"product_id": %d}`, bread.ID))
+	if status != fiber.StatusCreated || !list.Saved || len(list.Favorites) != 2 || list.Favorites[1].Name != "Bread" {
+		t.Errorf("POST bread: status %d, %+v; want Milk then Bread", status, list)
+	}
+	if status, _ := call("POST", "/api/v1/products/favorites", fmt.Sprintf(`{"product_id": %d}`, theirs.ID)); status != fiber.StatusNotFound {
+		t.Errorf("POST another shop's product: status %d; want 404", status)
+	}
+	if status, _ := call("POST", "/api/v1/products/favorites", `{}`); status != fiber.StatusBadRequest {
+		t.Errorf("POST without product_id: status %d; want 400", status)
+	}
+	if status, list := call("GET", "/api/v1/products/favorites", ""); status != fiber.StatusOK || len(list.Favorites) != 2 || list.Favorites[0].Name != "Milk" {
+		t.Errorf("GET: status %d, %+v", status, list)
+	}
+	if status, _ := call("DELETE", fmt.Sprintf("/api/v1/products/favorites/%d", sugar.ID), ""); status != fiber.StatusNotFound {
+		t.Errorf("DELETE a product that is not a favorite: status %d; want 404", status)
+	}
+
+	call("DELETE", fmt.Sprintf("/api/v1/products/favorites/%d", milk.ID), "")
+	call("DELETE", fmt.Sprintf("/api/v1/products/favorites/%d", bread.ID), "")
+	db.Model(&models.Sale{}).Where("product_id = ?", bread.ID).Update("created_at", time.Now().AddDate(0, 0, -10))
+	status, list = call("GET", "/api/v1/products/favorites", "")
+	if status != fiber.StatusOK || list.Saved || len(list.Favorites) != 1 || list.Favorites[0].Name != "Milk" {
+		t.Errorf("GET with none pinned: status %d, %+v; want this week's best seller, Milk", status, list)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/handlers"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/middleware"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/features"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestFeatureFlags tests switching features off and on at runtime through
+// the admin endpoints, the middleware and WhatsApp, and the flag cache
+func TestFeatureFlags(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.Sale{}, &models.DailySummary{},
+		&models.AuditLog{}, &models.FeatureFlag{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanPro, IsActive: true}
+	db.Create(shop)
+
+	now := time.Now()
+	flags := features.New(repository.NewFeatureFlagRepository(db), map[string]bool{
+		models.FeatureFlagMpesa:         true,
+		models.FeatureFlagAnalytics:     false,
+		models.FeatureFlagWebDashboard:  true,
+		models.FeatureFlagMultipleShops: true,
+		models.FeatureFlagStaffAccounts: true,
+	})
+	flags.SetClock(func() time.Time { return now })
+	middleware.SetFeatureFlags(flags)
+	defer middleware.SetFeatureFlags(nil)
+
+	admin := handlers.NewAdminHandler()
+	admin.SetFeatureFlags(flags)
+
+	isAdmin := true
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("account", &models.Account{Plan: models.PlanPro, IsAdmin: isAdmin})
+		c.Locals("shop", shop)
+		return c.Next()
+	})
+	app.Get("/admin/features", middleware.RequireAdmin(), admin.GetFeatures)
+	app.Put("/admin/features/:name", middleware.RequireAdmin(), admin.UpdateFeature)
+	app.Get("/mpesa", middleware.RequireFeature(middleware.FeatureMpesa), func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	call := func(method, target, body string) (int, []byte) {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, target, err)
+		}
+		out, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, out
+	}
+
+	// Nothing stored yet: every flag is listed with its default
+	status, out := call("GET", "/admin/features", "")
+	var list struct {
+		Features []models.FeatureFlag `json:"features"`
+	}
+	if err := json.Unmarshal(out, &list); err != nil || status != fiber.StatusOK {
+		t.Fatalf("GET features: status %d, %s", status, out)
+	}
+	if len(list.Features) != 5 || list.Features[0].Name != models.FeatureFlagAnalytics || list.Features[0].Enabled {
+		t.Errorf("features = %+v; want all five, analytics first and off", list.Features)
+	}
+
+	if status, _ := call("GET", "/mpesa", ""); status != fiber.StatusOK {
+		t.Errorf("M-Pesa while on: status %d; want 200", status)
+	}
+
+	// Switching a flag off takes effect at once on this instance
+	if status, _ := call("PUT", "/admin/features/mpesa", `{"enabled": false}`); status != fiber.StatusOK {
+		t.Fatalf("PUT mpesa: status %d; want 200", status)
+	}
+	status, out = call("GET", "/mpesa", "")
+	if status != fiber.StatusServiceUnavailable || !strings.Contains(string(out), "FEATURE_DISABLED") {
+		t.Errorf("M-Pesa while off: status %d, %s; want 503 FEATURE_DISABLED", status, out)
+	}
+
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		repository.NewProductRepository(db),
+		repository.NewSaleRepository(db),
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	cmdHandler.SetFeatureFlags(flags)
+	reply, err := cmdHandler.Handle(shop.Phone, services.NewCommandParser(nil, nil).Parse("mpesa pay 0712345678 100"))
+	if err != nil {
+		t.Fatalf("mpesa command error: %v", err)
+	}
+	if !strings.Contains(reply, "switched off") {
+		t.Errorf("mpesa command while off = %q; want the feature switched off", reply)
+	}
+
+	if status, _ := call("PUT", "/admin/features/mpesa", `{}`); status != fiber.StatusBadRequest {
+		t.Errorf("PUT without enabled: status %d; want 400", status)
+	}
+	if status, _ := call("PUT", "/admin/features/teleport", `{"enabled": true}`); status != fiber.StatusNotFound {
+		t.Errorf("PUT unknown flag: status %d; want 404", status)
+	}
+
+	// A change made elsewhere shows once the cache expires
+	db.Model(&models.FeatureFlag{}).Where("name = ?", models.FeatureFlagMpesa).Update("enabled", true)
+	if flags.Enabled(models.FeatureFlagMpesa) {
+		t.Error("mpesa on before the cache expired; want the cached off")
+	}
+	now = now.Add(features.CacheTTL)
+	if !flags.Enabled(models.FeatureFlagMpesa) {
+		t.Error("mpesa off after the cache expired; want the stored on")
+	}
+
+	isAdmin = false
+	if status, _ := call("PUT", "/admin/features/mpesa", `{"enabled": false}`); status != fiber.StatusForbidden {
+		t.Errorf("PUT as a shop owner: status %d; want 403", status)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/i18n"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+)
+
+// TestI18nCatalogs tests that every English message has a Swahili translation with the same verbs
+func TestI18nCatalogs(t *testing.T) {
+	messages := []i18n.Message{
+		i18n.MsgWelcome, i18n.MsgHelp, i18n.MsgUnknownCommand, i18n.MsgAddCreated, i18n.MsgAddUpdated,
+		i18n.MsgSold, i18n.MsgSellNotEnoughStock, i18n.MsgStockProduct, i18n.MsgDailyReport,
+		i18n.MsgWeeklyReport, i18n.MsgMonthlyReport, i18n.MsgProfit, i18n.MsgLowStockItem,
+		i18n.MsgBundleShort, i18n.MsgOrderNotDraft, i18n.MsgMpesaNotCompleted, i18n.MsgStaffAddUsage,
+		i18n.MsgStaffLimitReached, i18n.MsgZReportUsage, i18n.MsgExpenseUsage, i18n.MsgLoyaltyUnknown,
+	}
+	for _, msg := range messages {
+		en := i18n.T(i18n.English, msg)
+		sw := i18n.T(i18n.Swahili, msg)
+		if en == "" || sw == "" || en == sw {
+			t.Errorf("%s: missing translation", msg)
+		}
+		if strings.Count(en, "%") != strings.Count(sw, "%") {
+			t.Errorf("%s: format verbs differ between English and Swahili", msg)
+		}
+	}
+
+	if lang := i18n.Of("fr"); lang != i18n.English {
+		t.Errorf("Of(fr) = %s; want en", lang)
+	}
+	if _, ok := i18n.Parse("SW"); !ok {
+		t.Error("Parse(SW) should accept upper case codes")
+	}
+}
+
+// TestWhatsAppSetLanguage tests switching a shop to Swahili over WhatsApp
+func TestWhatsAppSetLanguage(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.StockMovement{}, &models.Sale{}, &models.DailySummary{}, &models.AuditLog{})
+
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanFree, IsActive: true}
+	if err := db.Create(shop).Error; err != nil {
+		t.Fatalf("failed to create shop: %v", err)
+	}
+	db.Create(&models.Product{ShopID: shop.ID, Name: "Bread", SellingPrice: 60, CostPrice: 50, CurrentStock: 10, LowStockThreshold: 2, IsActive: true})
+
+	shopRepo := repository.NewShopRepository(db)
+	cmdHandler := services.NewCommandHandler(db,
+		shopRepo,
+		repository.NewProductRepository(db),
+		repository.NewSaleRepository(db),
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	parser := services.NewCommandParser(nil, nil)
+	send := func(message string) string {
+		reply, err := cmdHandler.Handle(shop.Phone, parser.Parse(message))
+		if err != nil {
+			t.Fatalf("Handle(%q) error: %v", message, err)
+		}
+		return reply
+	}
+
+	if created, _ := shopRepo.GetByID(shop.ID); created.Language != "en" {
+		t.Errorf("new shops should default to en, got %q", created.Language)
+	}
+
+	help := send("help")
+	if !strings.Contains(help, "sw - Kiswahili") || !strings.Contains(help, "set language [code]") {
+		t.Errorf("help should list languages, got:\n%s", help)
+	}
+
+	if reply := send("set language fr"); !strings.Contains(reply, "en, sw") {
+		t.Errorf("unsupported language should show usage, got %q", reply)
+	}
+
+	if reply := send("SET LANGUAGE sw"); !strings.Contains(reply, "Kiswahili") {
+		t.Errorf("unexpected reply: %q", reply)
+	}
+	updated, _ := shopRepo.GetByID(shop.ID)
+	if updated.Language != "sw" {
+		t.Errorf("Language = %q; want sw", updated.Language)
+	}
+
+	if reply := send("sell bread 2"); !strings.Contains(reply, "IMEUZWA") || !strings.Contains(reply, "Zimebaki: 8") {
+		t.Errorf("sell reply should be in Swahili, got %q", reply)
+	}
+	if reply := send("xyz"); !strings.Contains(reply, "Amri haijulikani") {
+		t.Errorf("unknown command reply should be in Swahili, got %q", reply)
+	}
+	if reply := send("search sukari"); !strings.Contains(reply, "Hakuna bidhaa") {
+		t.Errorf("search reply should be in Swahili, got %q", reply)
+	}
+
+	// lang is a shortcut for set language
+	if reply := send("lang en"); !strings.Contains(reply, "English") {
+		t.Errorf("unexpected reply: %q", reply)
+	}
+	if updated, _ := shopRepo.GetByID(shop.ID); updated.Language != "en" {
+		t.Errorf("Language = %q; want en", updated.Language)
+	}
+	if reply := send("lang"); !strings.Contains(reply, "lang [code]") {
+		t.Errorf("lang without a code should show usage, got %q", reply)
+	}
+}
+
+// TestWhatsAppErrorsInSwahili tests that usage and error replies follow the
+// shop's language
+func TestWhatsAppErrorsInSwahili(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.AuditLog{})
+
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanPro, Language: "sw", IsActive: true}
+	if err := db.Create(shop).Error; err != nil {
+		t.Fatalf("failed to create shop: %v", err)
+	}
+
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		repository.NewProductRepository(db),
+		repository.NewSaleRepository(db),
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	parser := services.NewCommandParser(nil, nil)
+
+	tests := map[string]string{
+		"mpesa pay":      "Tumia: mpesa pay [kiasi] [simu]",
+		"mpesa pay sh50": "Kiasi si sahihi",
+		"mpesa refund":   "Amri ya M-Pesa haijulikani",
+		"shop switch x":  "Namba ya duka si sahihi",
+	}
+	for message, want := range tests {
+		reply, err := cmdHandler.Handle(shop.Phone, parser.Parse(message))
+		if err != nil {
+			t.Fatalf("Handle(%q) error: %v", message, err)
+		}
+		if !strings.Contains(reply, want) {
+			t.Errorf("Handle(%q) = %q; want it to contain %q", message, reply, want)
+		}
+	}
+}
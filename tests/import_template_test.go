@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/handlers"
+	exporthandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/export"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/routes"
+	"github.com/gofiber/fiber/v2"
+	"github.com/xuri/excelize/v2"
+)
+
+// TestProductImportTemplate tests the import template's columns, example
+// rows and column notes in each format
+func TestProductImportTemplate(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.Sale{}, &models.DailySummary{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	db.Create(shop)
+	h := exporthandler.NewExportHandler(repository.NewProductRepository(db),
+		repository.NewSaleRepository(db), repository.NewDailySummaryRepository(db))
+	app := serverApp(t, db, routes.RouteConfig{ExportHandler: h}, shop)
+
+	get := func(target string) (int, []byte, string) {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest("GET", target, nil))
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", target, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, body, resp.Header.Get("Content-Disposition")
+	}
+
+	status, body, disposition := get("/api/v1/import/products/template")
+	if status != fiber.StatusOK || !strings.Contains(disposition, "products_import_template.csv") {
+		t.Fatalf("CSV template: status %d, %s", status, disposition)
+	}
+	if !strings.HasPrefix(string(body), "# ID:") || !strings.Contains(string(body), "Low Stock Threshold: alert") {
+		t.Errorf("CSV template should start with the column notes:\n%s", body)
+	}
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.Comment = '#'
+	rows, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("CSV template does not parse: %v", err)
+	}
+	if len(rows) != 4 || rows[0][1] != "Name" || rows[1][1] != "Milk" || rows[2][1] != "Bread" || rows[3][1] != "Sugar" {
+		t.Errorf("CSV template rows = %v; want the header then Milk, Bread and Sugar", rows)
+	}
+	if rows[1][5] != "60.00" {
+		t.Errorf("Milk selling price = %s; want 60.00", rows[1][5])
+	}
+
+	status, body, disposition = get("/api/v1/import/products/template?format=excel")
+	if status != fiber.StatusOK || !strings.Contains(disposition, ".xlsx") {
+		t.Fatalf("Excel template: status %d, %s", status, disposition)
+	}
+	f, err := excelize.OpenReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Excel template does not open: %v", err)
+	}
+	defer f.Close()
+	if header, _ := f.GetCellValue("Sheet1", "F1"); header != "Selling Price" {
+		t.Errorf("Excel F1 = %q; want Selling Price", header)
+	}
+	if name, _ := f.GetCellValue("Sheet1", "B4"); name != "Sugar" {
+		t.Errorf("Excel B4 = %q; want Sugar", name)
+	}
+
+	if status, _, _ := get("/api/v1/import/products/template?format=pdf"); status != fiber.StatusBadRequest {
+		t.Errorf("PDF template: status %d; want 400", status)
+	}
+}
+
+// TestProductImport tests that the import template's rows are added, that
+// rows with a product's ID update it, and that a file the endpoint can't
+// read is answered with the template's address
+func TestProductImport(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.StockMovement{}, &models.PriceHistory{}, &models.Sale{}, &models.DailySummary{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanPro, IsActive: true}
+	db.Create(shop)
+	productRepo := repository.NewProductRepository(db)
+	exports := exporthandler.NewExportHandler(productRepo, repository.NewSaleRepository(db), repository.NewDailySummaryRepository(db))
+	app := serverApp(t, db, routes.RouteConfig{ExportHandler: exports, ProductHandler: handlers.NewProductHandler(productRepo)}, shop)
+
+	template := func(format string) []byte {
+		resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/import/products/template?format="+format, nil))
+		if err != nil || resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("template %s: %v %v", format, err, resp)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		return data
+	}
+	type result struct {
+		Created  int      `json:"created"`
+		Updated  int      `json:"updated"`
+		Errors   []string `json:"errors"`
+		Error    string   `json:"error"`
+		Template string   `json:"template"`
+	}
+	upload := func(filename string, data []byte) (int, result) {
+		t.Helper()
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		if filename != "" {
+			part, _ := form.CreateFormFile("file", filename)
+			part.Write(data)
+		}
+		form.Close()
+
+		req := httptest.NewRequest("POST", "/api/v1/import/products", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("import error: %v", err)
+		}
+		var out result
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	status, out := upload("products.csv", template("csv"))
+	if status != fiber.StatusOK || out.Created != 3 || len(out.Errors) != 0 {
+		t.Fatalf("CSV template import = %d %+v; want 3 products added", status, out)
+	}
+	milk, err := productRepo.GetByShopAndName(shop.ID, "Milk")
+	if err != nil || milk.SellingPrice != 60 || milk.CurrentStock != 24 || milk.Category != "Dairy" || milk.Unit != "packet" {
+		t.Fatalf("imported milk = %+v, %v; want the template's row", milk, err)
+	}
+
+	edited := fmt.Sprintf("ID,Name,Category,Unit,Cost Price,Selling Price,Stock,Low Stock Threshold,Barcode\n"+
+		"%d,Milk,Dairy,packet,50,65,30,6,\n"+
+		"0,Eggs,Dairy,tray,300,360,,,\n"+
+		"0,Salt,,,,free,,,\n"+
+		"99999,Ghost,,,,10,,,\n", milk.ID)
+	status, out = upload("products.csv", []byte(edited))
+	if status != fiber.StatusOK || out.Created != 1 || out.Updated != 1 || len(out.Errors) != 2 {
+		t.Fatalf("edited import = %d %+v; want 1 added, 1 updated and 2 rows refused", status, out)
+	}
+	if !strings.HasPrefix(out.Errors[0], "Row 4:") || !strings.HasPrefix(out.Errors[1], "Row 5: product 99999 not found") {
+		t.Errorf("import errors = %q; want rows 4 and 5", out.Errors)
+	}
+	milk, _ = productRepo.GetByID(milk.ID)
+	if milk.SellingPrice != 65 || milk.CurrentStock != 30 {
+		t.Errorf("updated milk = KSh %.0f, %d in stock; want KSh 65, 30", milk.SellingPrice, milk.CurrentStock)
+	}
+	if eggs, err := productRepo.GetByShopAndName(shop.ID, "Eggs"); err != nil || eggs.LowStockThreshold != 10 {
+		t.Errorf("imported eggs = %+v, %v; want the default low stock alert", eggs, err)
+	}
+
+	db.Where("shop_id = ?", shop.ID).Delete(&models.Product{})
+	if status, out = upload("products.xlsx", template("excel")); status != fiber.StatusOK || out.Created != 3 {
+		t.Errorf("Excel template import = %d %+v; want 3 products added", status, out)
+	}
+
+	for name, data := range map[string][]byte{
+		"":             nil,
+		"products.csv": []byte("name,price\nMilk,60\n"),
+	} {
+		status, out = upload(name, data)
+		if status != fiber.StatusBadRequest || out.Template != "/api/v1/import/products/template" {
+			t.Errorf("import of %q = %d %+v; want 400 linking the template", name, status, out)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/handlers"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/routes"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestInventorySnapshot tests that snapshots value stock at selling and cost
+// price and that a second run on the same day replaces the first
+func TestInventorySnapshot(t *testing.T) {
+	db := openTestDB(t, &models.Product{}, &models.InventorySnapshot{})
+	repo := repository.NewInventorySnapshotRepository(db)
+
+	db.Create(&models.Product{ShopID: 1, Name: "Bread", SellingPrice: 60, CostPrice: 50, CurrentStock: 10, IsActive: true})
+	db.Create(&models.Product{ShopID: 1, Name: "Milk", SellingPrice: 55, CostPrice: 45, CurrentStock: 4, IsActive: true})
+	db.Create(&models.Product{ShopID: 1, Name: "Breakfast", SellingPrice: 100, IsBundle: true, IsActive: true})
+	db.Create(&models.Product{ShopID: 2, Name: "Sugar", SellingPrice: 200, CurrentStock: 5, IsActive: true})
+
+	now := time.Now()
+	snapshot, err := repo.Snapshot(1, now)
+	if err != nil {
+		t.Fatalf("Snapshot() error: %v", err)
+	}
+	if snapshot.Value != 820 || snapshot.CostValue != 680 || snapshot.Units != 14 || snapshot.Products != 2 {
+		t.Fatalf("snapshot = %+v; want value 820, cost 680, 14 units, 2 products", snapshot)
+	}
+
+	db.Model(&models.Product{}).Where("name = ?", "Bread").Update("current_stock", 0)
+	if _, err := repo.Snapshot(1, now); err != nil {
+		t.Fatalf("second Snapshot() error: %v", err)
+	}
+
+	snapshots, _ := repo.GetRange(1, now.AddDate(0, 0, -1), now)
+	if len(snapshots) != 1 {
+		t.Fatalf("got %d snapshots for today; want 1", len(snapshots))
+	}
+	if snapshots[0].Value != 220 {
+		t.Errorf("value after resnapshot = %v; want 220", snapshots[0].Value)
+	}
+}
+
+// TestInventoryValueTrend tests the inventory value series endpoint
+func TestInventoryValueTrend(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.InventorySnapshot{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	db.Create(shop)
+	snapshotRepo := repository.NewInventorySnapshotRepository(db)
+
+	db.Create(&models.Product{ShopID: shop.ID, Name: "Bread", SellingPrice: 60, CurrentStock: 10, IsActive: true})
+
+	now := time.Now()
+	twoDaysAgo := now.AddDate(0, 0, -2)
+	db.Create(&models.InventorySnapshot{
+		ShopID: shop.ID,
+		Date:   time.Date(twoDaysAgo.Year(), twoDaysAgo.Month(), twoDaysAgo.Day(), 0, 0, 0, 0, time.UTC),
+		Value:  1500,
+		Units:  25,
+	})
+
+	handler := handlers.NewReportHandler(nil, nil, nil)
+	handler.SetSnapshotRepo(snapshotRepo)
+
+	app := serverApp(t, db, routes.RouteConfig{ReportHandler: handler}, shop)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/api/v1/reports/inventory-value?days=7", nil))
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d; want 200", resp.StatusCode)
+	}
+
+	var body struct {
+		CurrentValue float64 `json:"current_value"`
+		Series       []struct {
+			Date  string   `json:"date"`
+			Value *float64 `json:"value"`
+		} `json:"series"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+
+	if len(body.Series) != 7 {
+		t.Fatalf("series has %d points; want 7", len(body.Series))
+	}
+	if body.CurrentValue != 600 {
+		t.Errorf("current_value = %v; want 600", body.CurrentValue)
+	}
+	last := body.Series[6]
+	if last.Date != now.Format("2006-01-02") || last.Value == nil || *last.Value != 600 {
+		t.Errorf("today's point = %+v; want the live value 600", last)
+	}
+	if p := body.Series[4]; p.Date != twoDaysAgo.Format("2006-01-02") || p.Value == nil || *p.Value != 1500 {
+		t.Errorf("snapshot point = %+v; want 1500 two days ago", p)
+	}
+	if body.Series[5].Value != nil {
+		t.Errorf("missing day has value %v; want null", *body.Series[5].Value)
+	}
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/api/v1/reports/inventory-value?days=400", nil))
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("days=400 status = %d; want 400", resp.StatusCode)
+	}
+}
+
+// TestMonthEndSnapshot tests that a month's closing stock is worked back
+// from the stock ledger however late it is taken, and the endpoint that
+// returns it
+func TestMonthEndSnapshot(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.StockMovement{}, &models.ClosingStockSnapshot{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true} // shop 1
+	db.Create(shop)
+	repo := repository.NewClosingStockRepository(db)
+
+	november := time.Date(2024, 11, 1, 0, 0, 0, 0, time.Local)
+	closing := repository.MonthClose(november)
+	if want := time.Date(2024, 12, 1, 0, 0, 0, 0, time.Local); !closing.Equal(want) {
+		t.Fatalf("MonthClose(November) = %v; want %v", closing, want)
+	}
+
+	milk := &models.Product{ShopID: 1, Name: "Milk", SellingPrice: 55, CostPrice: 45, CurrentStock: 4, IsActive: true, CreatedAt: november}
+	bread := &models.Product{ShopID: 1, Name: "Bread", SellingPrice: 60, CostPrice: 50, CurrentStock: 10, IsActive: true, CreatedAt: november}
+	db.Create(milk)
+	db.Create(bread)
+	db.Create(&models.Product{ShopID: 1, Name: "Breakfast", SellingPrice: 100, IsBundle: true, IsActive: true, CreatedAt: november})
+	db.Create(&models.Product{ShopID: 1, Name: "Eggs", SellingPrice: 15, CurrentStock: 30, IsActive: true, CreatedAt: closing.AddDate(0, 0, 3)})
+	db.Create(&models.Product{ShopID: 2, Name: "Sugar", SellingPrice: 200, CurrentStock: 5, IsActive: true, CreatedAt: november})
+
+	// Bread sold 2 before the close and was restocked with 4 after it;
+	// milk sold 1 after it
+	db.Create(&models.StockMovement{ShopID: 1, ProductID: bread.ID, Type: models.StockMovementSale, Quantity: -2, CreatedAt: closing.Add(-time.Hour)})
+	db.Create(&models.StockMovement{ShopID: 1, ProductID: bread.ID, Type: models.StockMovementRestock, Quantity: 4, CreatedAt: closing.AddDate(0, 0, 5)})
+	db.Create(&models.StockMovement{ShopID: 1, ProductID: milk.ID, Type: models.StockMovementSale, Quantity: -1, CreatedAt: closing.Add(time.Minute)})
+
+	if n, err := repo.Close(1, november); err != nil || n != 2 {
+		t.Fatalf("Close() = %d, %v; want 2 products", n, err)
+	}
+	// Taking it again replaces it
+	if _, err := repo.Close(1, november.AddDate(0, 0, 20)); err != nil {
+		t.Fatalf("second Close() error: %v", err)
+	}
+
+	handler := handlers.NewReportHandler(nil, nil, nil)
+	handler.SetClosingStockRepo(repo)
+	app := serverApp(t, db, routes.RouteConfig{ReportHandler: handler}, shop)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/api/v1/reports/snapshots?month=2024-11", nil))
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d; want 200", resp.StatusCode)
+	}
+	var body struct {
+		SnapshotDate string                        `json:"snapshot_date"`
+		Products     []models.ClosingStockSnapshot `json:"products"`
+		CostValue    float64                       `json:"cost_value"`
+		Value        float64                       `json:"value"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	if body.SnapshotDate != "2024-11-30" || len(body.Products) != 2 {
+		t.Fatalf("snapshot = %+v; want 2 products on 2024-11-30", body)
+	}
+	if p := body.Products[0]; p.ProductName != "Bread" || p.ClosingStock != 6 || p.CostPrice != 50 {
+		t.Errorf("first row = %+v; want Bread with 6 at cost 50", p)
+	}
+	if p := body.Products[1]; p.ProductName != "Milk" || p.ClosingStock != 5 {
+		t.Errorf("second row = %+v; want Milk with 5", p)
+	}
+	if body.CostValue != 525 || body.Value != 635 {
+		t.Errorf("cost_value = %v, value = %v; want 525 and 635", body.CostValue, body.Value)
+	}
+
+	for query, want := range map[string]int{"month=2024-10": fiber.StatusNotFound, "month=Nov": fiber.StatusBadRequest} {
+		resp, _ := app.Test(httptest.NewRequest("GET", "/api/v1/reports/snapshots?"+query, nil))
+		if resp.StatusCode != want {
+			t.Errorf("%s status = %d; want %d", query, resp.StatusCode, want)
+		}
+	}
+}
+
+// TestCloseDue tests that month-end closing stock is taken a few shops a
+// run, catching up on the last months and skipping shops with nothing to
+// close
+func TestCloseDue(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.StockMovement{}, &models.ClosingStockSnapshot{})
+	repo := repository.NewClosingStockRepository(db)
+
+	september := time.Date(2024, 9, 1, 0, 0, 0, 0, time.Local)
+	shops := make([]*models.Shop, 4)
+	for i := range shops {
+		shops[i] = &models.Shop{Name: fmt.Sprintf("Shop %d", i+1), Phone: fmt.Sprintf("+25470000000%d", i+1), IsActive: true}
+		db.Create(shops[i])
+		created := september
+		if i == 2 {
+			created = time.Date(2024, 11, 15, 0, 0, 0, 0, time.Local) // opened in November
+		}
+		db.Create(&models.Product{ShopID: shops[i].ID, Name: "Milk", SellingPrice: 55, CurrentStock: 4, IsActive: true, CreatedAt: created})
+	}
+	db.Model(shops[3]).Update("is_active", false)
+
+	// Ten days into January, October to December are due
+	now := time.Date(2025, 1, 10, 9, 0, 0, 0, time.Local)
+	closed := func(month time.Month, year int) []uint {
+		t.Helper()
+		var ids []uint
+		db.Model(&models.ClosingStockSnapshot{}).
+			Where("snapshot_date = ?", time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)).
+			Order("shop_id").Pluck("shop_id", &ids)
+		return ids
+	}
+
+	if n, err := repo.CloseDue(now, 3); err != nil || n != 3 {
+		t.Fatalf("first CloseDue() = %d, %v; want 3", n, err)
+	}
+	if got := closed(time.October, 2024); len(got) != 2 || got[0] != shops[0].ID || got[1] != shops[1].ID {
+		t.Errorf("October closed for shops %v; want the first two", got)
+	}
+	if got := closed(time.November, 2024); len(got) != 1 || got[0] != shops[0].ID {
+		t.Errorf("November closed for shops %v; want the first", got)
+	}
+
+	if n, err := repo.CloseDue(now, 10); err != nil || n != 5 {
+		t.Fatalf("second CloseDue() = %d, %v; want the other 5", n, err)
+	}
+	if got := closed(time.December, 2024); len(got) != 3 {
+		t.Errorf("December closed for shops %v; want the three active ones", got)
+	}
+	if n, err := repo.CloseDue(now, 10); err != nil || n != 0 {
+		t.Errorf("third CloseDue() = %d, %v; want nothing left", n, err)
+	}
+	if got := closed(time.September, 2024); len(got) != 0 {
+		t.Errorf("September closed for shops %v; want it past the catch-up", got)
+	}
+}
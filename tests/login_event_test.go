@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/config"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/handlers"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/email"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/otp"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestLoginEvents tests that password and OTP logins are recorded with
+// where they came from, listed newest first, and that a login from a new
+// country is emailed to the owner
+func TestLoginEvents(t *testing.T) {
+	mock := &mockSendGrid{}
+	server := mock.server(t)
+	defer server.Close()
+
+	db := openTestDB(t, &models.OtpCode{}, &models.Shop{}, &models.LoginEvent{})
+	cfg := &config.Config{JWTSecret: "test-secret", JWTExpiryHrs: 24}
+	shopRepo := repository.NewShopRepository(db)
+	auth := services.NewAuthService(shopRepo, cfg)
+	otpSvc := otp.NewOTPService(db, cfg)
+	sms := sentCodes{}
+	otpSvc.SetSMSSender(sms.send)
+	auth.SetOTPService(otpSvc)
+
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Email: "mama@example.com"}
+	if err := auth.Register(shop, "secret123"); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	other := &models.Shop{Name: "Kibanda", Phone: "+254700000002"}
+	if err := auth.Register(other, "secret123"); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	loginEvents := repository.NewLoginEventRepository(db)
+	h := handlers.NewAuthHandler(auth)
+	h.SetLoginEvents(loginEvents, "CF-IPCountry",
+		email.New(&email.Config{APIKey: "sg-key", FromEmail: "alerts@dukapos.io", BaseURL: server.URL}))
+
+	// app.Test requests come from 0.0.0.0, standing in for the proxy
+	app := fiber.New(fiber.Config{EnableTrustedProxyCheck: true, TrustedProxies: []string{"0.0.0.0"}})
+	app.Post("/auth/login", h.Login)
+	app.Post("/auth/otp/verify", h.VerifyOTP)
+	app.Get("/logins", func(c *fiber.Ctx) error {
+		c.Locals("shop", shop)
+		return c.Next()
+	}, h.GetLoginEvents)
+	post := func(path, body, country string) int {
+		t.Helper()
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "DukaPOS-Android/2.1")
+		if country != "" {
+			req.Header.Set("CF-IPCountry", country)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		return resp.StatusCode
+	}
+	login := fmt.Sprintf(`{"phone": %q, "password": "secret123"}`, shop.Phone)
+
+	if status := post("/auth/login", fmt.Sprintf(`{"phone": %q, "password": "wrong"}`, shop.Phone), "KE"); status != fiber.StatusUnauthorized {
+		t.Fatalf("wrong password: status %d", status)
+	}
+	if status := post("/auth/login", login, "KE"); status != fiber.StatusOK {
+		t.Fatalf("login: status %d", status)
+	}
+	post("/auth/login", login, "ke")
+	post("/auth/login", login, "")
+	post("/auth/login", fmt.Sprintf(`{"phone": %q, "password": "secret123"}`, other.Phone), "UG")
+	if len(mock.sent) != 0 {
+		t.Fatalf("emails sent = %d; want none for the first country or an unknown one", len(mock.sent))
+	}
+
+	// A wrong code is recorded as a failed OTP login, and a new country alerts the owner
+	if err := auth.SendOTP(shop.Phone, otp.PurposeLogin); err != nil {
+		t.Fatalf("SendOTP: %v", err)
+	}
+	if status := post("/auth/otp/verify", fmt.Sprintf(`{"phone": %q, "code": "000000"}`, shop.Phone), "NG"); status == fiber.StatusOK {
+		t.Fatal("wrong code logged in")
+	}
+	if len(mock.sent) != 0 {
+		t.Fatalf("emails sent = %d; want none for a failed login", len(mock.sent))
+	}
+	if status := post("/auth/otp/verify", fmt.Sprintf(`{"phone": %q, "code": %q}`, shop.Phone, sms[shop.Phone]), "NG"); status != fiber.StatusOK {
+		t.Fatalf("OTP login: status %d", status)
+	}
+	// The alert is sent in the background
+	for deadline := time.Now().Add(2 * time.Second); len(mock.recipients()) == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if recipients := mock.recipients(); len(recipients) != 1 || recipients[0] != shop.Email {
+		t.Fatalf("login alerts sent to %v; want %s", recipients, shop.Email)
+	}
+	if subject := mock.sent[0]["subject"]; subject != "New login to Mama Mboga from NG" {
+		t.Errorf("subject = %q", subject)
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/logins?limit=4", nil))
+	if err != nil {
+		t.Fatalf("GET logins: %v", err)
+	}
+	out, _ := io.ReadAll(resp.Body)
+	var list struct {
+		Data  []models.LoginEvent `json:"data"`
+		Count int                 `json:"count"`
+	}
+	json.Unmarshal(out, &list)
+	if resp.StatusCode != fiber.StatusOK || list.Count != 4 {
+		t.Fatalf("GET logins: status %d %s", resp.StatusCode, out)
+	}
+	want := []struct {
+		method, country string
+		success         bool
+	}{
+		{models.LoginMethodOTP, "NG", true},
+		{models.LoginMethodOTP, "NG", false},
+		{models.LoginMethodPassword, "", true},
+		{models.LoginMethodPassword, "KE", true},
+	}
+	for i, w := range want {
+		e := list.Data[i]
+		if e.ShopID != shop.ID || e.Method != w.method || e.Country != w.country || e.Success != w.success || e.UserAgent != "DukaPOS-Android/2.1" {
+			t.Errorf("login %d = %+v; want %s from %q, success %v", i, e, w.method, w.country, w.success)
+		}
+	}
+
+	all, _ := loginEvents.Recent(0, shop.ID, 100)
+	if len(all) != 6 || all[5].Success {
+		t.Errorf("shop logins = %d, first success %v; want 6 starting with the wrong password", len(all), len(all) > 5 && all[5].Success)
+	}
+
+	// A client talking to the server directly can't set its own country
+	direct := fiber.New(fiber.Config{EnableTrustedProxyCheck: true})
+	direct.Post("/auth/login", h.Login)
+	req := httptest.NewRequest("POST", "/auth/login", strings.NewReader(login))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("CF-IPCountry", "KE")
+	if resp, err := direct.Test(req); err != nil || resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("direct login: %v", err)
+	}
+	if latest, _ := loginEvents.Recent(0, shop.ID, 1); len(latest) != 1 || latest[0].Country != "" {
+		t.Errorf("direct login = %+v; want no country", latest)
+	}
+}
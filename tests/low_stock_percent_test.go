@@ -0,0 +1,118 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+)
+
+// TestLowStockLevel tests that a percentage of max stock takes precedence
+// over the absolute threshold, rounded up, and only with a max stock
+func TestLowStockLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		product models.Product
+		want    int
+	}{
+		{"absolute", models.Product{LowStockThreshold: 5}, 5},
+		{"percentage", models.Product{LowStockThreshold: 5, LowStockPercent: 20, MaxStock: 200}, 40},
+		{"rounded up", models.Product{LowStockThreshold: 5, LowStockPercent: 15, MaxStock: 10}, 2},
+		{"percentage without max stock", models.Product{LowStockThreshold: 5, LowStockPercent: 20}, 5},
+		{"max stock without percentage", models.Product{LowStockThreshold: 5, MaxStock: 200}, 5},
+	}
+	for _, tt := range tests {
+		if got := tt.product.LowStockLevel(); got != tt.want {
+			t.Errorf("%s: LowStockLevel() = %d; want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestLowStockPercentThreshold tests setting a percentage threshold with
+// the threshold command and that GetLowStock finds products by it
+func TestLowStockPercentThreshold(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.Sale{}, &models.AuditLog{}, &models.ProductAlias{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	db.Create(shop)
+	// Milk is low below 10 by number, but not by 20% of the 200 it's stocked to
+	milk := &models.Product{ShopID: shop.ID, Name: "Milk", SellingPrice: 60, CurrentStock: 30, LowStockThreshold: 10, IsActive: true}
+	bread := &models.Product{ShopID: shop.ID, Name: "Bread", SellingPrice: 50, CurrentStock: 4, LowStockThreshold: 5, IsActive: true}
+	sugar := &models.Product{ShopID: shop.ID, Name: "Sugar", SellingPrice: 130, CurrentStock: 8, LowStockThreshold: 5, IsActive: true}
+	db.Create(milk)
+	db.Create(bread)
+	db.Create(sugar)
+
+	productRepo := repository.NewProductRepository(db)
+	lowStock := func() []string {
+		t.Helper()
+		products, err := productRepo.GetLowStock(shop.ID)
+		if err != nil {
+			t.Fatalf("GetLowStock: %v", err)
+		}
+		var names []string
+		for _, p := range products {
+			names = append(names, p.Name)
+		}
+		sort.Strings(names)
+		return names
+	}
+	if got := strings.Join(lowStock(), ","); got != "Bread" {
+		t.Fatalf("low stock = %q; want bread", got)
+	}
+
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		productRepo,
+		repository.NewSaleRepository(db),
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	parser := services.NewCommandParser(nil, nil)
+	run := func(text string) string {
+		t.Helper()
+		reply, err := cmdHandler.Handle(shop.Phone, parser.Parse(text))
+		if err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+		return reply
+	}
+
+	if reply := run("threshold milk 20%"); !strings.Contains(reply, "no max stock") {
+		t.Errorf("percentage without a max stock = %q; want to be asked for one", reply)
+	}
+	for _, text := range []string{"threshold milk 0%", "threshold milk 120%", "threshold milk 20% lots"} {
+		if reply := run(text); !strings.Contains(reply, "Invalid percentage") {
+			t.Errorf("%s = %q; want invalid", text, reply)
+		}
+	}
+	if reply := run("threshold milk 20% 200"); !strings.Contains(reply, "20% of max stock 200: 40") {
+		t.Fatalf("threshold milk 20%% 200 = %q", reply)
+	}
+	if reply := run("threshold sugar 50% 10"); !strings.Contains(reply, ": 5") {
+		t.Fatalf("threshold sugar 50%% 10 = %q", reply)
+	}
+	if got := strings.Join(lowStock(), ","); got != "Bread,Milk" {
+		t.Errorf("low stock = %q; want milk by percentage and bread by number", got)
+	}
+	if reply := run("threshold milk"); !strings.Contains(reply, "40 (20% of 200)") || !strings.Contains(reply, "LOW") {
+		t.Errorf("threshold milk = %q", reply)
+	}
+
+	// Keeps the max stock, so a new percentage needs only the percentage
+	if reply := run("threshold milk 10%"); !strings.Contains(reply, "10% of max stock 200: 20") {
+		t.Errorf("threshold milk 10%% = %q", reply)
+	}
+	// A number goes back to the absolute threshold
+	run("threshold milk 10")
+	stored, _ := productRepo.GetByID(milk.ID)
+	if stored.LowStockPercent != 0 || stored.MaxStock != 200 || stored.LowStockLevel() != 10 {
+		t.Errorf("after threshold milk 10: percent %d, max %d, level %d; want 0, 200, 10",
+			stored.LowStockPercent, stored.MaxStock, stored.LowStockLevel())
+	}
+	if got := strings.Join(lowStock(), ","); got != "Bread" {
+		t.Errorf("low stock = %q; want bread", got)
+	}
+}
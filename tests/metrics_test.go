@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/metrics"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/middleware"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// TestMetricsSalesAndStoreGauges tests the GORM sales counter and the shop gauges
+func TestMetricsSalesAndStoreGauges(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.Sale{})
+	if err := metrics.RegisterGORMCallbacks(db); err != nil {
+		t.Fatalf("RegisterGORMCallbacks() error: %v", err)
+	}
+
+	db.Create(&models.Shop{ID: 4242, Name: "Mama Mboga", Phone: "+254712345678", IsActive: true})
+	closed := &models.Shop{ID: 4243, Name: "Closed Duka", Phone: "+254712345679"}
+	db.Create(closed)
+	db.Model(closed).Update("is_active", false)
+	db.Create(&models.Product{ShopID: 4242, Name: "Bread", SellingPrice: 60, CurrentStock: 2, LowStockThreshold: 5, IsActive: true})
+	db.Create(&models.Product{ShopID: 4242, Name: "Milk", SellingPrice: 60, CurrentStock: 20, LowStockThreshold: 5, IsActive: true})
+
+	sales := metrics.SalesTotal.WithLabelValues("4242")
+	before := testutil.ToFloat64(sales)
+	db.Create(&models.Sale{ShopID: 4242, ProductID: 1, Quantity: 1, UnitPrice: 60, TotalAmount: 60})
+	db.Create(&[]models.Sale{
+		{ShopID: 4242, ProductID: 1, Quantity: 1, UnitPrice: 60, TotalAmount: 60},
+		{ShopID: 4242, ProductID: 2, Quantity: 2, UnitPrice: 60, TotalAmount: 120},
+	})
+	if got := testutil.ToFloat64(sales) - before; got != 3 {
+		t.Errorf("dukapos_sales_total increased by %v; want 3", got)
+	}
+
+	expected := `
+# HELP dukapos_active_shops Number of active shops.
+# TYPE dukapos_active_shops gauge
+dukapos_active_shops 1
+# HELP dukapos_low_stock_products Active products at or below their low stock threshold.
+# TYPE dukapos_low_stock_products gauge
+dukapos_low_stock_products{shop_id="4242"} 1
+`
+	if err := testutil.CollectAndCompare(metrics.NewStoreCollector(db), strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestMetricsEndpointAllowlist tests that /metrics is only served to allowed networks
+func TestMetricsEndpointAllowlist(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		status  int
+	}{
+		{"allowed network", []string{"10.0.0.0/8", "0.0.0.0/32"}, fiber.StatusOK},
+		{"other network", []string{"10.0.0.0/8"}, fiber.StatusForbidden},
+		{"empty allowlist", nil, fiber.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Use(metrics.Middleware())
+			app.Get("/metrics", middleware.IPAllowlist(tt.allowed), metrics.Handler())
+
+			resp, err := app.Test(httptest.NewRequest("GET", "/metrics", nil))
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, resp.StatusCode)
+			}
+		})
+	}
+}
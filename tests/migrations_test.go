@@ -0,0 +1,425 @@
+package main
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/database"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/database/migrations"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	pg_query "github.com/pganalyze/pg_query_go/v5"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// openEmptyDB opens a database with no tables as database.DB
+func openEmptyDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "dukapos.db")+"?_foreign_keys=on"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = previous })
+	return db
+}
+
+// TestMigrationsUpAndDown tests that a new database is built by the SQL
+// migrations, works with the models, and rolls back one step at a time
+func TestMigrationsUpAndDown(t *testing.T) {
+	db := openEmptyDB(t)
+
+	if err := database.Migrate(); err != nil {
+		t.Fatalf("Migrate() error: %v", err)
+	}
+	version, latest, dirty, err := database.MigrationVersion()
+	if err != nil || version != latest || dirty {
+		t.Fatalf("version = %d of %d, dirty %v, err %v; want all applied", version, latest, dirty, err)
+	}
+
+	account := &models.Account{Email: "mama@example.com", PasswordHash: "x", Name: "Mama", Phone: "+254712345678"}
+	if err := db.Create(account).Error; err != nil {
+		t.Fatalf("create account: %v", err)
+	}
+	shop := &models.Shop{AccountID: account.ID, Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	if err := db.Create(shop).Error; err != nil {
+		t.Fatalf("create shop: %v", err)
+	}
+	product := &models.Product{ShopID: shop.ID, Name: "Bread", Category: "Bakery", SellingPrice: 55, CurrentStock: 10, IsActive: true}
+	if err := db.Create(product).Error; err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+	if err := db.Create(&models.Sale{ShopID: shop.ID, ProductID: product.ID, Quantity: 1, UnitPrice: 55, TotalAmount: 55}).Error; err != nil {
+		t.Fatalf("create sale: %v", err)
+	}
+
+	// Running again applies nothing
+	if err := database.Migrate(); err != nil {
+		t.Fatalf("second Migrate() error: %v", err)
+	}
+
+	for v := latest; v > 0; v-- {
+		if err := database.MigrateDown(); err != nil {
+			t.Fatalf("MigrateDown() from %d error: %v", v, err)
+		}
+	}
+	if version, _, _, _ := database.MigrationVersion(); version != 0 {
+		t.Errorf("version after rolling everything back = %d; want 0", version)
+	}
+	if db.Migrator().HasTable(&models.Shop{}) {
+		t.Error("shops table still exists after rolling back the initial schema")
+	}
+	if err := database.MigrateDown(); err != migrations.ErrNoMigrations {
+		t.Errorf("MigrateDown() with nothing applied = %v; want ErrNoMigrations", err)
+	}
+}
+
+// TestMigrationsRefuseUnversionedSchema tests that a database created by
+// AutoMigrate is left alone until its version is recorded, then migrated
+// from there keeping its data
+func TestMigrationsRefuseUnversionedSchema(t *testing.T) {
+	db := openEmptyDB(t)
+	if err := database.Migrate(); err != nil {
+		t.Fatalf("Migrate() error: %v", err)
+	}
+	account := &models.Account{Email: "mama@example.com", PasswordHash: "x", Name: "Mama", Phone: "+254712345678"}
+	db.Create(account)
+	shop := &models.Shop{AccountID: account.ID, Name: "Mama Mboga", Phone: "+254712345678"}
+	db.Create(shop)
+
+	// Forget the history, as a database from before versioned migrations
+	if err := db.Exec("DELETE FROM schema_migrations").Error; err != nil {
+		t.Fatalf("clear history: %v", err)
+	}
+	if err := database.Migrate(); !errors.Is(err, database.ErrUnversionedSchema) {
+		t.Fatalf("Migrate() on an unversioned schema = %v; want ErrUnversionedSchema", err)
+	}
+
+	_, latest, _, _ := database.MigrationVersion()
+	if err := database.ForceMigrationVersion(latest); err != nil {
+		t.Fatalf("ForceMigrationVersion() error: %v", err)
+	}
+	if err := database.Migrate(); err != nil {
+		t.Fatalf("Migrate() after forcing the version: %v", err)
+	}
+	var count int64
+	db.Model(&models.Shop{}).Count(&count)
+	if count != 1 {
+		t.Errorf("shops = %d; want the existing shop kept", count)
+	}
+}
+
+// TestMigrationsBackfillCategories tests category rows are made for the
+// category names products already use
+func TestMigrationsBackfillCategories(t *testing.T) {
+	db := openEmptyDB(t)
+	if err := database.Migrate(); err != nil {
+		t.Fatalf("Migrate() error: %v", err)
+	}
+	account := &models.Account{Email: "mama@example.com", PasswordHash: "x", Name: "Mama", Phone: "+254712345678"}
+	db.Create(account)
+	shop := &models.Shop{AccountID: account.ID, Name: "Mama Mboga", Phone: "+254712345678"}
+	db.Create(shop)
+	db.Create(&models.Product{ShopID: shop.ID, Name: "Bread", Category: "Bakery", SellingPrice: 55, IsActive: true})
+	db.Create(&models.Product{ShopID: shop.ID, Name: "Cake", Category: "Bakery", SellingPrice: 300, IsActive: true})
+
+	loaded, _ := migrations.Load("sqlite")
+	if err := db.Exec(loaded[1].Up).Error; err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+	var categories []models.Category
+	db.Where("shop_id = ?", shop.ID).Find(&categories)
+	if len(categories) != 1 || categories[0].Name != "Bakery" {
+		t.Errorf("categories = %+v; want one Bakery row", categories)
+	}
+}
+
+// TestMigrationFilesPaired tests both dialects have the same versions
+func TestMigrationFilesPaired(t *testing.T) {
+	pg, err := migrations.Load("postgres")
+	if err != nil {
+		t.Fatalf("Load(postgres) error: %v", err)
+	}
+	lite, err := migrations.Load("sqlite")
+	if err != nil {
+		t.Fatalf("Load(sqlite) error: %v", err)
+	}
+	if len(pg) != len(lite) {
+		t.Fatalf("postgres has %d migrations, sqlite %d; want the same", len(pg), len(lite))
+	}
+	for i := range pg {
+		if pg[i].Version != lite[i].Version || pg[i].Name != lite[i].Name {
+			t.Errorf("migration %d: postgres %04d_%s, sqlite %04d_%s", i, pg[i].Version, pg[i].Name, lite[i].Version, lite[i].Name)
+		}
+	}
+}
+
+// TestMigrationsMatchModels tests that the migrations create a table for
+// every model in internal/models with each of its columns, so a model or
+// field added without a migration is caught. The models are read from the
+// source rather than a list that would need updating too.
+func TestMigrationsMatchModels(t *testing.T) {
+	db := openEmptyDB(t)
+	if err := database.Migrate(); err != nil {
+		t.Fatalf("Migrate() error: %v", err)
+	}
+
+	tables := modelTables(t, filepath.Join("..", "internal", "models"))
+	if len(tables) == 0 {
+		t.Fatal("no models found")
+	}
+	for _, table := range tables {
+		if !db.Migrator().HasTable(table.name) {
+			t.Errorf("table %s missing; add a migration for models.%s", table.name, table.model)
+			continue
+		}
+		for _, column := range table.columns {
+			if !db.Migrator().HasColumn(table.name, column) {
+				t.Errorf("column %s.%s missing; add a migration for models.%s", table.name, column, table.model)
+			}
+		}
+	}
+}
+
+// TestPostgresMigrationsParse tests every postgres migration file is
+// valid PostgreSQL, as the tests only run the sqlite ones
+func TestPostgresMigrationsParse(t *testing.T) {
+	loaded, err := migrations.Load("postgres")
+	if err != nil {
+		t.Fatalf("Load(postgres) error: %v", err)
+	}
+	for _, m := range loaded {
+		if _, err := pg_query.Parse(m.Up); err != nil {
+			t.Errorf("%04d_%s.up.sql: %v", m.Version, m.Name, err)
+		}
+		if _, err := pg_query.Parse(m.Down); err != nil {
+			t.Errorf("%04d_%s.down.sql: %v", m.Version, m.Name, err)
+		}
+	}
+}
+
+// TestPostgresMigrationsUpAndDown runs the postgres migrations both ways
+// against the empty database in TEST_POSTGRES_DSN
+func TestPostgresMigrationsUpAndDown(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("set TEST_POSTGRES_DSN to an empty PostgreSQL database")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = previous })
+
+	if err := database.Migrate(); err != nil {
+		t.Fatalf("Migrate() error: %v", err)
+	}
+	version, latest, dirty, err := database.MigrationVersion()
+	if err != nil || version != latest || dirty {
+		t.Fatalf("version = %d of %d, dirty %v, err %v; want all applied", version, latest, dirty, err)
+	}
+	for v := latest; v > 0; v-- {
+		if err := database.MigrateDown(); err != nil {
+			t.Fatalf("MigrateDown() from %d error: %v", v, err)
+		}
+	}
+}
+
+// modelTable is a table a model in internal/models needs
+type modelTable struct {
+	model   string
+	name    string
+	columns []string
+}
+
+// modelTables reads the structs in dir with a primary key and works out
+// their table and column names the way GORM does
+func modelTables(t *testing.T, dir string) []modelTable {
+	t.Helper()
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatalf("parse models: %v", err)
+	}
+
+	structs := make(map[string]*ast.StructType)
+	tableNames := make(map[string]string)
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				switch d := decl.(type) {
+				case *ast.GenDecl:
+					for _, spec := range d.Specs {
+						if ts, ok := spec.(*ast.TypeSpec); ok {
+							if st, ok := ts.Type.(*ast.StructType); ok {
+								structs[ts.Name.Name] = st
+							}
+						}
+					}
+				case *ast.FuncDecl:
+					if name, table, ok := tableNameMethod(d); ok {
+						tableNames[name] = table
+					}
+				}
+			}
+		}
+	}
+
+	naming := schema.NamingStrategy{}
+	var tables []modelTable
+	for name, st := range structs {
+		columns := structColumns(st, structs)
+		if !hasPrimaryKey(st) {
+			continue
+		}
+		table := tableNames[name]
+		if table == "" {
+			table = naming.TableName(name)
+		}
+		tables = append(tables, modelTable{model: name, name: table, columns: columns})
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].model < tables[j].model })
+	return tables
+}
+
+// tableNameMethod returns the table a "func (X) TableName() string" names
+func tableNameMethod(fn *ast.FuncDecl) (string, string, bool) {
+	if fn.Name.Name != "TableName" || fn.Recv == nil || len(fn.Recv.List) != 1 || fn.Body == nil || len(fn.Body.List) != 1 {
+		return "", "", false
+	}
+	recv := fn.Recv.List[0].Type
+	if star, ok := recv.(*ast.StarExpr); ok {
+		recv = star.X
+	}
+	ident, ok := recv.(*ast.Ident)
+	if !ok {
+		return "", "", false
+	}
+	ret, ok := fn.Body.List[0].(*ast.ReturnStmt)
+	if !ok || len(ret.Results) != 1 {
+		return "", "", false
+	}
+	lit, ok := ret.Results[0].(*ast.BasicLit)
+	if !ok {
+		return "", "", false
+	}
+	table, err := strconv.Unquote(lit.Value)
+	return ident.Name, table, err == nil
+}
+
+// hasPrimaryKey reports whether the struct is stored, from an ID field
+// tagged primaryKey or an embedded gorm.Model
+func hasPrimaryKey(st *ast.StructType) bool {
+	for _, field := range st.Fields.List {
+		if len(field.Names) == 0 && exprString(field.Type) == "gorm.Model" {
+			return true
+		}
+		for _, name := range field.Names {
+			if name.Name == "ID" && strings.Contains(gormTag(field), "primaryKey") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// structColumns returns the column names GORM gives the struct's fields,
+// skipping associations and ignored fields
+func structColumns(st *ast.StructType, structs map[string]*ast.StructType) []string {
+	naming := schema.NamingStrategy{}
+	var columns []string
+	for _, field := range st.Fields.List {
+		tag := gormTag(field)
+		if tag == "-" || strings.HasPrefix(tag, "-:") {
+			continue
+		}
+		if len(field.Names) == 0 {
+			switch typ := exprString(field.Type); {
+			case typ == "gorm.Model":
+				columns = append(columns, "id", "created_at", "updated_at", "deleted_at")
+			case structs[typ] != nil:
+				columns = append(columns, structColumns(structs[typ], structs)...)
+			}
+			continue
+		}
+		if isAssociation(field, tag, structs) {
+			continue
+		}
+		for _, name := range field.Names {
+			if !name.IsExported() {
+				continue
+			}
+			column := naming.ColumnName("", name.Name)
+			for _, option := range strings.Split(tag, ";") {
+				if strings.HasPrefix(option, "column:") {
+					column = strings.TrimPrefix(option, "column:")
+				}
+			}
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// isAssociation reports whether the field is a relation to another model
+// rather than a column
+func isAssociation(field *ast.Field, tag string, structs map[string]*ast.StructType) bool {
+	if strings.Contains(tag, "type:") || strings.Contains(tag, "serializer:") {
+		return false
+	}
+	if strings.Contains(tag, "foreignKey:") || strings.Contains(tag, "many2many:") {
+		return true
+	}
+	typ := field.Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	if arr, ok := typ.(*ast.ArrayType); ok {
+		return exprString(arr.Elt) != "byte"
+	}
+	ident, ok := typ.(*ast.Ident)
+	return ok && structs[ident.Name] != nil
+}
+
+// gormTag returns the field's gorm struct tag
+func gormTag(field *ast.Field) string {
+	if field.Tag == nil {
+		return ""
+	}
+	raw, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return ""
+	}
+	return reflect.StructTag(raw).Get("gorm")
+}
+
+func exprString(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		return exprString(e.X) + "." + e.Sel.Name
+	case *ast.StarExpr:
+		return "*" + exprString(e.X)
+	}
+	return ""
+}
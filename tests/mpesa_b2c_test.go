@@ -0,0 +1,401 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/encryption"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB opens a throwaway sqlite database with the given models migrated
+func openTestDB(t testing.TB, dst ...interface{}) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "dukapos.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(dst...); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return db
+}
+
+// mockDarajaB2C serves OAuth and B2C requests, recording every B2C body
+type mockDarajaB2C struct {
+	mu       sync.Mutex
+	requests []map[string]interface{}
+}
+
+func (m *mockDarajaB2C) server(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/v1/generate", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "test-token", "expires_in": "3599"})
+	})
+	mux.HandleFunc("/mpesa/b2c/v1/paymentrequest", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			t.Errorf("b2c Authorization = %q; want Bearer test-token", r.Header.Get("Authorization"))
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode B2C request: %v", err)
+		}
+
+		m.mu.Lock()
+		m.requests = append(m.requests, body)
+		n := len(m.requests)
+		m.mu.Unlock()
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"ConversationID":           fmt.Sprintf("AG_20240215_%d", n),
+			"OriginatorConversationID": fmt.Sprintf("10571-7910404-%d", n),
+			"ResponseCode":             "0",
+			"ResponseDescription":      "Accept the service request successfully.",
+		})
+	})
+	return httptest.NewServer(mux)
+}
+
+func (m *mockDarajaB2C) received() []map[string]interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]map[string]interface{}(nil), m.requests...)
+}
+
+// addShopInitiators creates shops 1 and 2, with their own shortcodes 600997
+// and 600998 and an initiator for B2C, status queries and reversals
+func addShopInitiators(t *testing.T, svc *mpesa.Service, db *gorm.DB) {
+	t.Helper()
+	encryptor, err := encryption.NewEncryptionServiceWithKey(bytes.Repeat([]byte("k"), encryption.KeySize))
+	if err != nil {
+		t.Fatalf("NewEncryptionServiceWithKey() error: %v", err)
+	}
+	svc.SetCredentialStore(repository.NewIntegrationCredentialRepository(db), encryptor)
+	for i, phone := range []string{"+254712345678", "+254733000111"} {
+		shop := &models.Shop{Name: fmt.Sprintf("Shop %d", i+1), Phone: phone, Plan: models.PlanPro, IsActive: true}
+		db.Create(shop)
+		err := svc.SaveShopCredentials(shop.ID, &mpesa.ShopCredentials{
+			ConsumerKey:        "key",
+			ConsumerSecret:     "secret",
+			Shortcode:          fmt.Sprintf("60099%d", 7+i),
+			Passkey:            testPasskey,
+			InitiatorName:      "testapi",
+			SecurityCredential: "ENCRYPTED==",
+		})
+		if err != nil {
+			t.Fatalf("SaveShopCredentials() error: %v", err)
+		}
+	}
+}
+
+// newB2CTestService sets up payouts for shops 1 and 2
+func newB2CTestService(t *testing.T, baseURL string, dailyLimit float64) (*mpesa.Service, *gorm.DB) {
+	db := openTestDB(t, &models.B2CPayout{}, &models.MpesaTransaction{}, &models.AuditLog{},
+		&models.Shop{}, &models.IntegrationCredential{})
+
+	svc := mpesa.New(&mpesa.Config{
+		ConsumerKey:    "key",
+		ConsumerSecret: "secret",
+		Shortcode:      "174379",
+		Passkey:        testPasskey,
+		CallbackToken:  "s3cret",
+		BaseURL:        baseURL,
+		B2CResultURL:   "https://pos.example.com/webhook/mpesa/b2c",
+		B2CTimeoutURL:  "https://pos.example.com/webhook/mpesa/b2c/timeout",
+		B2CDailyLimit:  dailyLimit,
+	}, nil, repository.NewMpesaTransactionRepository(db))
+	svc.SetB2CRepos(repository.NewB2CPayoutRepository(db), repository.NewAuditLogRepository(db))
+
+	addShopInitiators(t, svc, db)
+
+	return svc, db
+}
+
+func b2cResultBody(conversationID string, resultCode int, desc string) []byte {
+	body, _ := json.Marshal(map[string]interface{}{
+		"Result": map[string]interface{}{
+			"ResultType":               0,
+			"ResultCode":               resultCode,
+			"ResultDesc":               desc,
+			"OriginatorConversationID": "",
+			"ConversationID":           conversationID,
+			"TransactionID":            "NLJ41HAY6Q",
+			"ResultParameters": map[string]interface{}{
+				"ResultParameter": []map[string]interface{}{
+					{"Key": "TransactionAmount", "Value": 500},
+					{"Key": "TransactionReceipt", "Value": "NLJ41HAY6Q"},
+					{"Key": "ReceiverPartyPublicName", "Value": "254712345678 - Jane Wanjiku"},
+				},
+			},
+		},
+	})
+	return body
+}
+
+// TestMpesaB2CAgainstMockDaraja tests the B2C request sent to Daraja and settling it from the result callback
+func TestMpesaB2CAgainstMockDaraja(t *testing.T) {
+	daraja := &mockDarajaB2C{}
+	server := daraja.server(t)
+	defer server.Close()
+
+	svc, db := newB2CTestService(t, server.URL, 0)
+
+	payout, err := svc.InitiateB2C(context.Background(), &mpesa.B2CRequest{
+		ShopID:   1,
+		Phone:    "0712345678",
+		Amount:   500,
+		Remarks:  "Refund",
+		Occasion: "Order 42",
+	})
+	if err != nil {
+		t.Fatalf("InitiateB2C() error: %v", err)
+	}
+	if payout.Status != models.B2CPayoutSubmitted || payout.ConversationID != "AG_20240215_1" {
+		t.Errorf("payout not submitted: %+v", payout)
+	}
+
+	expected := map[string]interface{}{
+		"InitiatorName":      "testapi",
+		"SecurityCredential": "ENCRYPTED==",
+		"CommandID":          "BusinessPayment",
+		"Amount":             float64(500),
+		"PartyA":             "600997",
+		"PartyB":             "254712345678",
+		"Remarks":            "Refund",
+		"QueueTimeOutURL":    "https://pos.example.com/webhook/mpesa/b2c/timeout?token=s3cret",
+		"ResultURL":          "https://pos.example.com/webhook/mpesa/b2c?token=s3cret",
+		"Occasion":           "Order 42",
+	}
+	body := daraja.received()[0]
+	for field, want := range expected {
+		if body[field] != want {
+			t.Errorf("%s = %v; want %v", field, body[field], want)
+		}
+	}
+
+	settled, err := svc.ProcessB2CResult(b2cResultBody(payout.ConversationID, 0, "The service request is processed successfully."))
+	if err != nil {
+		t.Fatalf("ProcessB2CResult() error: %v", err)
+	}
+	if settled.Status != models.B2CPayoutCompleted || settled.ReceiptNumber != "NLJ41HAY6Q" || settled.CompletedAt == nil {
+		t.Errorf("payout not completed: %+v", settled)
+	}
+	if settled.ReceiverName != "254712345678 - Jane Wanjiku" {
+		t.Errorf("ReceiverName = %q", settled.ReceiverName)
+	}
+
+	if _, err := svc.ProcessB2CResult(b2cResultBody(payout.ConversationID, 0, "")); !errors.Is(err, mpesa.ErrDuplicateCallback) {
+		t.Errorf("replayed result should be a duplicate, got %v", err)
+	}
+
+	var transactions int64
+	db.Model(&models.MpesaTransaction{}).Where("type = ? AND receipt_number = ?", "b2c", "NLJ41HAY6Q").Count(&transactions)
+	if transactions != 1 {
+		t.Errorf("expected 1 b2c transaction, got %d", transactions)
+	}
+
+	var actions []string
+	db.Model(&models.AuditLog{}).Where("entity_type = ?", "b2c_payout").Order("id").Pluck("action", &actions)
+	if len(actions) != 2 || actions[0] != "b2c_initiated" || actions[1] != "b2c_completed" {
+		t.Errorf("audit trail = %v; want [b2c_initiated b2c_completed]", actions)
+	}
+}
+
+// TestMpesaB2CDailyLimit tests the per-shop daily cap, which failed payouts don't count towards
+func TestMpesaB2CDailyLimit(t *testing.T) {
+	daraja := &mockDarajaB2C{}
+	server := daraja.server(t)
+	defer server.Close()
+
+	svc, _ := newB2CTestService(t, server.URL, 1000)
+	ctx := context.Background()
+
+	first, err := svc.InitiateB2C(ctx, &mpesa.B2CRequest{ShopID: 1, Phone: "0712345678", Amount: 600})
+	if err != nil {
+		t.Fatalf("first payout failed: %v", err)
+	}
+
+	if _, err := svc.InitiateB2C(ctx, &mpesa.B2CRequest{ShopID: 1, Phone: "0712345678", Amount: 500}); !errors.Is(err, mpesa.ErrB2CDailyLimit) {
+		t.Errorf("expected ErrB2CDailyLimit, got %v", err)
+	}
+	if n := len(daraja.received()); n != 1 {
+		t.Errorf("capped payout should not reach Daraja, got %d requests", n)
+	}
+
+	if _, err := svc.InitiateB2C(ctx, &mpesa.B2CRequest{ShopID: 2, Phone: "0712345678", Amount: 500}); err != nil {
+		t.Errorf("the cap is per shop, got %v", err)
+	}
+
+	failed, err := svc.ProcessB2CResult(b2cResultBody(first.ConversationID, 2001, "The initiator information is invalid."))
+	if err != nil {
+		t.Fatalf("ProcessB2CResult() error: %v", err)
+	}
+	if failed.Status != models.B2CPayoutFailed || failed.ResultDesc != "The initiator information is invalid." {
+		t.Errorf("payout not failed: %+v", failed)
+	}
+
+	if _, err := svc.InitiateB2C(ctx, &mpesa.B2CRequest{ShopID: 1, Phone: "0712345678", Amount: 500}); err != nil {
+		t.Errorf("failed payouts should not count towards the cap, got %v", err)
+	}
+}
+
+// TestMpesaB2CValidation tests amount and command checks before anything is recorded
+func TestMpesaB2CValidation(t *testing.T) {
+	svc, db := newB2CTestService(t, "http://127.0.0.1:0", 0)
+
+	tests := []struct {
+		req  mpesa.B2CRequest
+		want error
+	}{
+		{mpesa.B2CRequest{ShopID: 1, Phone: "0712345678", Amount: 5}, mpesa.ErrB2CInvalidAmount},
+		{mpesa.B2CRequest{ShopID: 1, Phone: "0712345678", Amount: 150001}, mpesa.ErrB2CInvalidAmount},
+		{mpesa.B2CRequest{ShopID: 1, Phone: "0712345678", Amount: 99.5}, mpesa.ErrB2CInvalidAmount},
+		{mpesa.B2CRequest{ShopID: 1, Phone: "0712345678", Amount: 100, CommandID: "TransferFromBankToCustomer"}, mpesa.ErrB2CInvalidCommand},
+		{mpesa.B2CRequest{ShopID: 1, Phone: "12345", Amount: 100}, mpesa.ErrInvalidPhone},
+	}
+
+	for _, tt := range tests {
+		req := tt.req
+		if _, err := svc.InitiateB2C(context.Background(), &req); !errors.Is(err, tt.want) {
+			t.Errorf("InitiateB2C(%+v) error = %v; want %v", tt.req, err, tt.want)
+		}
+	}
+
+	var count int64
+	db.Model(&models.B2CPayout{}).Count(&count)
+	if count != 0 {
+		t.Errorf("invalid requests should not be recorded, got %d payouts", count)
+	}
+}
+
+// TestEncryptSecurityCredential tests that the credential decrypts with the certificate's private key
+func TestEncryptSecurityCredential(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sandbox.safaricom.co.ke"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	credential, err := mpesa.EncryptSecurityCredential("Safaricom999!*!", certPEM)
+	if err != nil {
+		t.Fatalf("EncryptSecurityCredential() error: %v", err)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(credential)
+	if err != nil {
+		t.Fatalf("credential is not base64: %v", err)
+	}
+	plaintext, err := rsa.DecryptPKCS1v15(rand.Reader, key, ciphertext)
+	if err != nil || string(plaintext) != "Safaricom999!*!" {
+		t.Errorf("decrypted credential = %q, %v", plaintext, err)
+	}
+
+	if _, err := mpesa.EncryptSecurityCredential("pw", []byte("not a certificate")); err == nil {
+		t.Error("expected an error for invalid PEM")
+	}
+}
+
+// fixedRates converts at fixed KES rates per unit
+type fixedRates map[string]float64
+
+func (r fixedRates) Convert(amount float64, from, to string) (float64, error) {
+	rate, ok := r[from]
+	if !ok || to != "KES" {
+		return 0, fmt.Errorf("unknown currency: %s", from)
+	}
+	return amount * rate, nil
+}
+
+// TestMpesaB2CForexPayout tests sending a foreign currency amount as KES
+func TestMpesaB2CForexPayout(t *testing.T) {
+	daraja := &mockDarajaB2C{}
+	server := daraja.server(t)
+	defer server.Close()
+
+	svc, db := newB2CTestService(t, server.URL, 0)
+	forex := func(amount float64, currency string, rate float64) (*models.B2CPayout, error) {
+		return svc.InitiateB2C(context.Background(), &mpesa.B2CRequest{
+			ShopID: 1,
+			Phone:  "0712345678",
+			Amount: amount,
+			Forex:  mpesa.B2CForexRequest{Currency: currency, ExchangeRate: rate},
+		})
+	}
+
+	if _, err := forex(100, "USD", 0); err == nil {
+		t.Error("USD payout without a converter or rate should fail")
+	}
+
+	svc.SetCurrencyConverter(fixedRates{"USD": 129.25, "EUR": 140.1})
+
+	payout, err := forex(100, "usd", 0)
+	if err != nil {
+		t.Fatalf("InitiateB2C() error: %v", err)
+	}
+	if payout.Amount != 12925 || payout.Currency != "USD" || payout.ForeignAmount != 100 || payout.ExchangeRate != 129.25 {
+		t.Errorf("payout = %+v; want KES 12925 for USD 100 at 129.25", payout)
+	}
+	if got := daraja.received()[0]["Amount"]; got != float64(12925) {
+		t.Errorf("Daraja Amount = %v; want 12925", got)
+	}
+
+	payout, err = forex(50.5, "EUR", 139.99)
+	if err != nil {
+		t.Fatalf("InitiateB2C() error: %v", err)
+	}
+	if payout.Amount != 7069 || payout.ExchangeRate != 139.99 {
+		t.Errorf("payout = %+v; want KES 7069 at the given rate 139.99", payout)
+	}
+
+	if _, err := forex(100, "USD", -1); !errors.Is(err, mpesa.ErrB2CInvalidRate) {
+		t.Errorf("negative rate error = %v; want ErrB2CInvalidRate", err)
+	}
+	if _, err := forex(2000, "USD", 0); !errors.Is(err, mpesa.ErrB2CInvalidAmount) {
+		t.Errorf("USD 2000 error = %v; want ErrB2CInvalidAmount over the KES cap", err)
+	}
+
+	var details string
+	db.Model(&models.AuditLog{}).Where("action = ?", "b2c_initiated").Order("id").Limit(1).Pluck("details", &details)
+	if !strings.Contains(details, "100.00 USD at 129.2500 KES") {
+		t.Errorf("audit details = %q; want the exchange rate", details)
+	}
+
+	payouts, _, _ := svc.GetB2CPayoutsByShop(1, 10, 0)
+	if len(payouts) != 2 {
+		t.Errorf("got %d payouts; want 2", len(payouts))
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mpesahandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/mpesa"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/middleware"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestMpesaBulkSTKPush tests that a bulk request prompts every valid phone,
+// at most five at a time, records a payment for each and reports the
+// invalid entries as failed without stopping the rest
+func TestMpesaBulkSTKPush(t *testing.T) {
+	var mu sync.Mutex
+	var prompts, inFlight, maxInFlight int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/v1/generate", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "test-token", "expires_in": "3599"})
+	})
+	mux.HandleFunc("/mpesa/stkpush/v1/processrequest", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		prompts++
+		n := prompts
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]string{
+			"MerchantRequestID": fmt.Sprintf("mr_%d", n),
+			"CheckoutRequestID": fmt.Sprintf("ws_CO_%d", n),
+			"ResponseCode":      "0",
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	db := openTestDB(t, &models.Shop{}, &models.MpesaPayment{}, &models.MpesaPaymentAttempt{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanBusiness, IsActive: true}
+	db.Create(shop)
+
+	paymentRepo := repository.NewMpesaPaymentRepository(db)
+	svc := mpesa.New(&mpesa.Config{
+		ConsumerKey:    "key",
+		ConsumerSecret: "secret",
+		Shortcode:      testShortcode,
+		Passkey:        testPasskey,
+		BaseURL:        server.URL,
+	}, paymentRepo, repository.NewMpesaTransactionRepository(db))
+	h := mpesahandler.New(svc, repository.NewShopRepository(db), nil, nil, paymentRepo, nil)
+
+	app := fiber.New()
+	app.Post("/bulk-stk", func(c *fiber.Ctx) error {
+		c.Locals("shop_id", shop.ID)
+		return c.Next()
+	}, middleware.RateLimiter(2, 60), h.BulkSTKPush)
+
+	post := func(body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest("POST", "/bulk-stk", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, 30000)
+		if err != nil {
+			t.Fatalf("POST /bulk-stk failed: %v", err)
+		}
+		var out map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	var tooMany []string
+	for i := 0; i < mpesahandler.MaxBulkSTKEntries+1; i++ {
+		tooMany = append(tooMany, `{"phone":"254712345678","amount":10}`)
+	}
+	if status, _ := post("[" + strings.Join(tooMany, ",") + "]"); status != 400 {
+		t.Errorf("51 entries: status %d; want 400", status)
+	}
+
+	var entries []string
+	for i := 0; i < 12; i++ {
+		entries = append(entries, fmt.Sprintf(`{"phone":"2547123456%02d","amount":%d,"reference":"payroll_jan"}`, i, 100+i))
+	}
+	entries[3] = `{"phone":"","amount":500,"reference":"payroll_jan"}`
+	entries[7] = `{"phone":"254712345607","amount":0,"reference":"payroll_jan"}`
+	status, out := post("[" + strings.Join(entries, ",") + "]")
+	if status != 200 {
+		t.Fatalf("bulk push: status %d, body %v; want 200", status, out)
+	}
+	if out["success"] != float64(10) || out["failed"] != float64(2) {
+		t.Errorf("success %v, failed %v; want 10 and 2", out["success"], out["failed"])
+	}
+
+	results, _ := out["results"].([]interface{})
+	if len(results) != 12 {
+		t.Fatalf("got %d results; want 12", len(results))
+	}
+	for i, r := range results {
+		result := r.(map[string]interface{})
+		if result["index"] != float64(i) {
+			t.Errorf("results[%d].index = %v; want the request order", i, result["index"])
+		}
+		wantStatus := "success"
+		if i == 3 || i == 7 {
+			wantStatus = "failed"
+		}
+		if result["status"] != wantStatus {
+			t.Errorf("results[%d] = %v; want %s", i, result, wantStatus)
+		}
+	}
+	if msg, _ := results[3].(map[string]interface{})["error"].(string); !strings.Contains(msg, "phone") {
+		t.Errorf("results[3].error = %q; want the missing phone", msg)
+	}
+
+	if prompts != 10 {
+		t.Errorf("STK requests = %d; want 10, none for the invalid entries", prompts)
+	}
+	if maxInFlight > 5 {
+		t.Errorf("%d pushes ran at once; want at most 5", maxInFlight)
+	}
+
+	var payments []models.MpesaPayment
+	db.Where("shop_id = ?", shop.ID).Find(&payments)
+	if len(payments) != 10 {
+		t.Errorf("recorded %d payments; want one per push", len(payments))
+	}
+	checkouts := map[string]bool{}
+	for _, p := range payments {
+		checkouts[p.CheckoutRequestID] = true
+		if p.AccountReference != "payroll_jan" {
+			t.Errorf("payment %d account reference = %q; want the entry's reference", p.ID, p.AccountReference)
+		}
+	}
+	if len(checkouts) != 10 {
+		t.Errorf("payments share checkout IDs: %v", checkouts)
+	}
+
+	// The shop has used its two bulk requests this minute
+	if status, _ := post(`[{"phone":"254712345678","amount":10}]`); status != fiber.StatusTooManyRequests {
+		t.Errorf("third bulk request: status %d; want 429", status)
+	}
+}
+
+// TestMpesaBulkSTKDeadline tests that a bulk request answers by its
+// deadline however slow Daraja is, reporting the entries it didn't send
+func TestMpesaBulkSTKDeadline(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/v1/generate", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "test-token", "expires_in": "3599"})
+	})
+	mux.HandleFunc("/mpesa/stkpush/v1/processrequest", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(300 * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"MerchantRequestID": "mr_slow",
+			"CheckoutRequestID": fmt.Sprintf("ws_CO_%d", time.Now().UnixNano()),
+			"ResponseCode":      "0",
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	db := openTestDB(t, &models.Shop{}, &models.MpesaPayment{}, &models.MpesaPaymentAttempt{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanBusiness, IsActive: true}
+	db.Create(shop)
+
+	paymentRepo := repository.NewMpesaPaymentRepository(db)
+	svc := mpesa.New(&mpesa.Config{
+		ConsumerKey:    "key",
+		ConsumerSecret: "secret",
+		Shortcode:      testShortcode,
+		Passkey:        testPasskey,
+		BaseURL:        server.URL,
+	}, paymentRepo, repository.NewMpesaTransactionRepository(db))
+	h := mpesahandler.New(svc, repository.NewShopRepository(db), nil, nil, paymentRepo, nil)
+	h.SetBulkSTKDeadline(500 * time.Millisecond)
+
+	app := fiber.New()
+	app.Post("/bulk-stk", func(c *fiber.Ctx) error {
+		c.Locals("shop_id", shop.ID)
+		return c.Next()
+	}, h.BulkSTKPush)
+
+	var entries []string
+	for i := 0; i < 15; i++ {
+		entries = append(entries, fmt.Sprintf(`{"phone":"2547123456%02d","amount":100}`, i))
+	}
+	req := httptest.NewRequest("POST", "/bulk-stk", strings.NewReader("["+strings.Join(entries, ",")+"]"))
+	req.Header.Set("Content-Type", "application/json")
+	started := time.Now()
+	resp, err := app.Test(req, 10000)
+	if err != nil {
+		t.Fatalf("POST /bulk-stk failed: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("bulk request took %v; want it to answer by its deadline", elapsed)
+	}
+	var out map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&out)
+	if resp.StatusCode != 200 {
+		t.Fatalf("status %d, body %v; want 200", resp.StatusCode, out)
+	}
+	if out["success"] != float64(5) || out["not_sent"] != float64(5) || out["failed"] != float64(5) {
+		t.Errorf("success %v, failed %v, not sent %v; want 5 sent, 5 cut off and 5 not sent",
+			out["success"], out["failed"], out["not_sent"])
+	}
+	results, _ := out["results"].([]interface{})
+	if len(results) != 15 {
+		t.Fatalf("got %d results; want 15", len(results))
+	}
+	if last := results[14].(map[string]interface{}); last["status"] != "not_sent" {
+		t.Errorf("last result = %v; want not_sent", last)
+	}
+}
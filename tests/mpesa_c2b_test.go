@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
+	"gorm.io/gorm"
+)
+
+func newC2BTestService(t *testing.T, baseURL string) (*mpesa.Service, *gorm.DB) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.StockMovement{}, &models.Sale{}, &models.MpesaTransaction{})
+
+	svc := mpesa.New(&mpesa.Config{
+		ConsumerKey:        "key",
+		ConsumerSecret:     "secret",
+		Shortcode:          "600998",
+		Passkey:            testPasskey,
+		CallbackToken:      "s3cret",
+		BaseURL:            baseURL,
+		C2BValidationURL:   "https://pos.example.com/webhook/mpesa/c2b/validation",
+		C2BConfirmationURL: "https://pos.example.com/webhook/mpesa/c2b/confirmation",
+	}, nil, repository.NewMpesaTransactionRepository(db))
+	svc.SetBusinessRepos(repository.NewSaleRepository(db), repository.NewProductRepository(db), repository.NewShopRepository(db))
+
+	return svc, db
+}
+
+// TestMpesaRegisterC2BURLs tests registering the paybill callbacks with Daraja
+func TestMpesaRegisterC2BURLs(t *testing.T) {
+	var registered map[string]interface{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/v1/generate", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "test-token", "expires_in": "3599"})
+	})
+	mux.HandleFunc("/"+mpesa.RegisterURLEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&registered)
+		json.NewEncoder(w).Encode(map[string]string{"ResponseCode": "0", "ResponseDescription": "success"})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	svc, _ := newC2BTestService(t, server.URL)
+
+	if err := svc.RegisterC2BURLs(context.Background(), 0); err != nil {
+		t.Fatalf("RegisterC2BURLs() error: %v", err)
+	}
+	expected := map[string]interface{}{
+		"ShortCode":       "600998",
+		"ResponseType":    "Completed",
+		"ValidationURL":   "https://pos.example.com/webhook/mpesa/c2b/validation?token=s3cret",
+		"ConfirmationURL": "https://pos.example.com/webhook/mpesa/c2b/confirmation?token=s3cret",
+	}
+	for field, want := range expected {
+		if registered[field] != want {
+			t.Errorf("%s = %v; want %v", field, registered[field], want)
+		}
+	}
+
+	if err := svc.RegisterC2BURLs(context.Background(), 1); !errors.Is(err, mpesa.ErrShopCredentialsRequired) {
+		t.Errorf("shops without their own paybill: expected ErrShopCredentialsRequired, got %v", err)
+	}
+}
+
+// TestMpesaC2BValidation tests accepting and rejecting paybill payments by account number
+func TestMpesaC2BValidation(t *testing.T) {
+	svc, db := newC2BTestService(t, "")
+
+	active := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	other := &models.Shop{Name: "Kiosk", Phone: "+254712345670", IsActive: true}
+	closed := &models.Shop{Name: "Closed Duka", Phone: "+254712345679"}
+	db.Create(active)
+	db.Create(other)
+	db.Create(closed)
+	db.Model(closed).Update("is_active", false)
+	bread := &models.Product{ShopID: active.ID, Name: "Bread", SellingPrice: 60, CurrentStock: 10, IsActive: true}
+	db.Create(bread)
+	soldOut := &models.Product{ShopID: active.ID, Name: "Milk", SellingPrice: 55, IsActive: true}
+	db.Create(soldOut)
+
+	tests := []struct {
+		name   string
+		ref    string
+		amount string
+		code   string
+	}{
+		{"shop reference", mpesa.ShopAccountReference(active.ID, ""), "100", mpesa.C2BAccepted},
+		{"lower case reference", "duka1", "100", mpesa.C2BAccepted},
+		{"product reference", mpesa.ProductAccountReference(active.ID, bread.ID), "60", mpesa.C2BAccepted},
+		{"unknown format", "INV-2041", "100", mpesa.C2BInvalidAccount},
+		{"unknown shop", "DUKA999", "100", mpesa.C2BInvalidAccount},
+		{"inactive shop", mpesa.ShopAccountReference(closed.ID, ""), "100", mpesa.C2BInvalidAccount},
+		{"unknown product", mpesa.ProductAccountReference(active.ID, 999), "100", mpesa.C2BInvalidAccount},
+		{"another shop's product", mpesa.ProductAccountReference(other.ID, bread.ID), "100", mpesa.C2BInvalidAccount},
+		{"zero amount", mpesa.ShopAccountReference(active.ID, ""), "0", mpesa.C2BInvalidAmount},
+		{"less than the product price", mpesa.ProductAccountReference(active.ID, bread.ID), "50", mpesa.C2BInvalidAmount},
+		{"more than the product price", mpesa.ProductAccountReference(active.ID, bread.ID), "120", mpesa.C2BInvalidAmount},
+		{"product out of stock", mpesa.ProductAccountReference(active.ID, soldOut.ID), "55", mpesa.C2BOtherError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := svc.ValidateC2B(&mpesa.C2BNotification{
+				TransactionID:       "QJK4ABC123",
+				Amount:              tt.amount,
+				BusinessShortCode:   "600998",
+				BillReferenceNumber: tt.ref,
+			})
+			if result.ResultCode != tt.code {
+				t.Errorf("ValidateC2B(%q) = %s; want %s", tt.ref, result.ResultCode, tt.code)
+			}
+		})
+	}
+}
+
+// TestMpesaC2BConfirmation tests crediting paybill payments and auto-creating sales
+func TestMpesaC2BConfirmation(t *testing.T) {
+	svc, db := newC2BTestService(t, "")
+
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	db.Create(shop)
+	bread := &models.Product{ShopID: shop.ID, Name: "Bread", SellingPrice: 60, CostPrice: 50, CurrentStock: 10, IsActive: true}
+	db.Create(bread)
+
+	confirm := func(receipt, ref, amount string) *models.MpesaTransaction {
+		tx, err := svc.HandleC2BNotification(&mpesa.C2BNotification{
+			TransactionType:     "Pay Bill",
+			TransactionID:       receipt,
+			TransactionTime:     "20261016101530",
+			Amount:              amount,
+			BusinessShortCode:   "600998",
+			BillReferenceNumber: ref,
+			PhoneNumber:         "254708374149",
+			Name:                "JOHN",
+		})
+		if err != nil {
+			t.Fatalf("HandleC2BNotification(%s) error: %v", receipt, err)
+		}
+		return tx
+	}
+
+	// A product reference records a sale for one unit, keyed by the receipt
+	tx := confirm("QJK4ABC123", mpesa.ProductAccountReference(shop.ID, bread.ID), "60")
+	if tx.ShopID != shop.ID || tx.SaleID == nil {
+		t.Fatalf("payment should be credited to the shop with a sale: %+v", tx)
+	}
+	if tx.ReceiptNumber != "QJK4ABC123" {
+		t.Errorf("receipt number = %q; want the transaction ID", tx.ReceiptNumber)
+	}
+	var sale models.Sale
+	db.First(&sale, *tx.SaleID)
+	if sale.Quantity != 1 || sale.MpesaReceipt != "QJK4ABC123" || sale.PaymentMethod != models.PaymentMpesa {
+		t.Errorf("unexpected sale: %+v", sale)
+	}
+	var product models.Product
+	db.First(&product, bread.ID)
+	if product.CurrentStock != 9 {
+		t.Errorf("stock = %d; want 9", product.CurrentStock)
+	}
+
+	// Daraja retries do not record the sale twice
+	if again := confirm("QJK4ABC123", mpesa.ProductAccountReference(shop.ID, bread.ID), "60"); again.ID != tx.ID {
+		t.Errorf("retried confirmation created a new transaction")
+	}
+	var sales int64
+	db.Model(&models.Sale{}).Count(&sales)
+	if sales != 1 {
+		t.Errorf("sales = %d; want 1", sales)
+	}
+
+	// Plain shop references are credited without a sale, and repeat payments
+	// to the same account number are each recorded
+	first := confirm("QJK4ABC124", "DUKA1", "500")
+	second := confirm("QJK4ABC125", "DUKA1", "300")
+	if first.ID == second.ID || first.SaleID != nil || first.ShopID != shop.ID {
+		t.Errorf("unexpected transactions: %+v, %+v", first, second)
+	}
+
+	// Any other amount for a product is credited but left unattributed
+	over := confirm("QJK4ABC126", mpesa.ProductAccountReference(shop.ID, bread.ID), "150")
+	if over.ShopID != shop.ID || over.SaleID != nil {
+		t.Errorf("overpayment should be credited without a sale: %+v", over)
+	}
+	db.Model(&models.Sale{}).Count(&sales)
+	if sales != 1 {
+		t.Errorf("sales = %d; want 1", sales)
+	}
+
+	txs, total, _ := svc.GetTransactionsByShop(shop.ID, 10, 0)
+	if total != 4 || len(txs) != 4 {
+		t.Errorf("shop transactions = %d; want 4", total)
+	}
+
+	// A receipt another shop queried by hand goes to the shop it was paid to
+	other := &models.Shop{Name: "Kiosk", Phone: "+254712345670", IsActive: true}
+	db.Create(other)
+	db.Create(&models.MpesaTransaction{ShopID: other.ID, Type: "c2b", TransactionID: "QJK4ABC127", ReceiptNumber: "QJK4ABC127", Status: "unverified"})
+	claimed := confirm("QJK4ABC127", mpesa.ProductAccountReference(shop.ID, bread.ID), "60")
+	if claimed.ShopID != shop.ID || claimed.Status != "completed" || claimed.SaleID == nil {
+		t.Errorf("queried receipt should be credited to the paying shop: %+v", claimed)
+	}
+}
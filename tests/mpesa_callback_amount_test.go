@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
+)
+
+// safaricomCallback is a successful STK callback as Safaricom sends it,
+// with the amount, phone and date as JSON numbers
+func safaricomCallback(checkoutID string, amount float64, receipt string) []byte {
+	return []byte(fmt.Sprintf(`{
+  "Body": {
+    "stkCallback": {
+      "MerchantRequestID": "29115-34620561-1",
+      "CheckoutRequestID": %q,
+      "ResultCode": 0,
+      "ResultDesc": "The service request is processed successfully.",
+      "CallbackMetadata": {
+        "Item": [
+          {"Name": "Amount", "Value": %.2f},
+          {"Name": "MpesaReceiptNumber", "Value": %q},
+          {"Name": "Balance"},
+          {"Name": "TransactionDate", "Value": 20191219102115},
+          {"Name": "PhoneNumber", "Value": 254708374149}
+        ]
+      }
+    }
+  }
+}`, checkoutID, amount, receipt))
+}
+
+// TestMpesaCallbackAmount tests that the amount paid is read from the
+// callback and under- and overpayments are flagged
+func TestMpesaCallbackAmount(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.StockMovement{}, &models.Sale{}, &models.PendingSale{}, &models.PendingSaleItem{},
+		&models.MpesaPayment{}, &models.MpesaPaymentAttempt{}, &models.MpesaTransaction{})
+
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanPro, IsActive: true}
+	db.Create(shop)
+	bread := &models.Product{ShopID: shop.ID, Name: "Bread", SellingPrice: 60, CostPrice: 45, CurrentStock: 10, IsActive: true}
+	db.Create(bread)
+
+	svc := mpesa.New(&mpesa.Config{Shortcode: testShortcode, Passkey: testPasskey},
+		repository.NewMpesaPaymentRepository(db), repository.NewMpesaTransactionRepository(db))
+	svc.SetBusinessRepos(repository.NewSaleRepository(db), repository.NewProductRepository(db), repository.NewShopRepository(db))
+	svc.SetPendingSaleRepo(repository.NewPendingSaleRepository(db))
+
+	basket, err := svc.CreatePendingSale(shop.ID, []mpesa.PendingSaleItemRequest{{ProductID: bread.ID, Quantity: 2}}, "")
+	if err != nil {
+		t.Fatalf("CreatePendingSale() error: %v", err)
+	}
+	newPayment := func(checkoutID string, pendingSaleID *uint) {
+		db.Create(&models.MpesaPayment{
+			ShopID: shop.ID, Amount: 120, Phone: "254708374149", CheckoutRequestID: checkoutID,
+			Status: models.MpesaPaymentPending, PendingSaleID: pendingSaleID, ExpiresAt: time.Now().Add(time.Hour),
+		})
+	}
+
+	tests := []struct {
+		checkoutID string
+		paid       float64
+		mismatch   string
+	}{
+		{"ws_CO_under", 100, models.MpesaUnderpaid},
+		{"ws_CO_exact", 120, ""},
+		{"ws_CO_over", 150, models.MpesaOverpaid},
+	}
+	for _, tt := range tests {
+		var pendingSaleID *uint
+		if tt.checkoutID == "ws_CO_under" {
+			pendingSaleID = &basket.ID
+		}
+		newPayment(tt.checkoutID, pendingSaleID)
+
+		payment, err := svc.ProcessSTKCallback(safaricomCallback(tt.checkoutID, tt.paid, "NLJ7RT61SV"))
+		if err != nil {
+			t.Fatalf("%s: ProcessSTKCallback() error: %v", tt.checkoutID, err)
+		}
+		if payment.Status != models.MpesaPaymentCompleted || payment.MpesaReceipt != "NLJ7RT61SV" || payment.PayerPhone != "254708374149" {
+			t.Errorf("%s: payment = %s, receipt %q, payer %q", tt.checkoutID, payment.Status, payment.MpesaReceipt, payment.PayerPhone)
+		}
+		if payment.AmountPaid != tt.paid || payment.AmountMismatch != tt.mismatch {
+			t.Errorf("%s: paid %.2f, mismatch %q; want %.2f, %q", tt.checkoutID, payment.AmountPaid, payment.AmountMismatch, tt.paid, tt.mismatch)
+		}
+	}
+
+	// An underpaid basket isn't turned into sales
+	var sales int64
+	db.Model(&models.Sale{}).Count(&sales)
+	if sales != 0 {
+		t.Errorf("sales = %d; want 0 for an underpaid basket", sales)
+	}
+	var stored models.MpesaPayment
+	db.Where("checkout_request_id = ?", "ws_CO_under").First(&stored)
+	if stored.AmountPaid != 100 || stored.AmountMismatch != models.MpesaUnderpaid {
+		t.Errorf("stored payment = %.2f, %q; want the underpayment saved", stored.AmountPaid, stored.AmountMismatch)
+	}
+}
+
+// TestMpesaCallbackSettlesOnce tests that a callback delivered several times
+// at once records its sale once
+func TestMpesaCallbackSettlesOnce(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.StockMovement{}, &models.Sale{}, &models.PendingSale{}, &models.PendingSaleItem{},
+		&models.MpesaPayment{}, &models.MpesaPaymentAttempt{}, &models.MpesaTransaction{})
+
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanPro, IsActive: true}
+	db.Create(shop)
+	bread := &models.Product{ShopID: shop.ID, Name: "Bread", SellingPrice: 60, CostPrice: 45, CurrentStock: 10, IsActive: true}
+	db.Create(bread)
+
+	svc := mpesa.New(&mpesa.Config{Shortcode: testShortcode, Passkey: testPasskey},
+		repository.NewMpesaPaymentRepository(db), repository.NewMpesaTransactionRepository(db))
+	svc.SetBusinessRepos(repository.NewSaleRepository(db), repository.NewProductRepository(db), repository.NewShopRepository(db))
+	svc.SetPendingSaleRepo(repository.NewPendingSaleRepository(db))
+
+	basket, err := svc.CreatePendingSale(shop.ID, []mpesa.PendingSaleItemRequest{{ProductID: bread.ID, Quantity: 2}}, "")
+	if err != nil {
+		t.Fatalf("CreatePendingSale() error: %v", err)
+	}
+	db.Create(&models.MpesaPayment{
+		ShopID: shop.ID, Amount: 120, Phone: "254708374149", CheckoutRequestID: "ws_CO_twice",
+		Status: models.MpesaPaymentPending, PendingSaleID: &basket.ID, ExpiresAt: time.Now().Add(time.Hour),
+	})
+
+	var wg sync.WaitGroup
+	var settled int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := svc.ProcessSTKCallback(safaricomCallback("ws_CO_twice", 120, "NLJ7RT61SV")); err == nil {
+				atomic.AddInt32(&settled, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var sales int64
+	db.Model(&models.Sale{}).Count(&sales)
+	db.First(bread, bread.ID)
+	if settled != 1 || sales != 1 || bread.CurrentStock != 8 {
+		t.Errorf("settled %d times, %d sales, stock %d; want the basket sold once", settled, sales, bread.CurrentStock)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
+)
+
+// memoryLockStore stands in for Redis in STK push dedup tests
+type memoryLockStore struct {
+	mu    sync.Mutex
+	locks map[string]string
+	err   error
+}
+
+func (m *memoryLockStore) AcquireLock(key, value string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return false, m.err
+	}
+	if _, held := m.locks[key]; held {
+		return false, nil
+	}
+	m.locks[key] = value
+	return true, nil
+}
+
+func (m *memoryLockStore) GetLock(key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.locks[key], m.err
+}
+
+func (m *memoryLockStore) UpdateLock(key, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, held := m.locks[key]; held {
+		m.locks[key] = value
+	}
+	return m.err
+}
+
+func (m *memoryLockStore) ReleaseLock(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.locks, key)
+	return m.err
+}
+
+// TestMpesaDuplicatePush tests that a repeat STK push within the dedup window
+// returns the first payment without prompting again, and that pushes still go
+// out when the lock store fails
+func TestMpesaDuplicatePush(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.StockMovement{}, &models.Sale{}, &models.PendingSale{}, &models.PendingSaleItem{},
+		&models.MpesaPayment{}, &models.MpesaPaymentAttempt{}, &models.MpesaTransaction{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanPro, IsActive: true}
+	db.Create(shop)
+
+	paymentRepo := repository.NewMpesaPaymentRepository(db)
+	svc := mpesa.New(&mpesa.Config{
+		Environment:       mpesa.EnvironmentMock,
+		MockCallbackDelay: time.Hour, // the prompts stay pending
+	}, paymentRepo, repository.NewMpesaTransactionRepository(db))
+	svc.SetBusinessRepos(repository.NewSaleRepository(db), repository.NewProductRepository(db), repository.NewShopRepository(db))
+	store := &memoryLockStore{locks: make(map[string]string)}
+	svc.SetCache(store)
+
+	push := func() (*models.MpesaPayment, *mpesa.STKPushResponse) {
+		payment, resp, err := svc.InitiateSTKPush(context.Background(), &mpesa.PaymentRequest{
+			Phone: "0712345678", ShopID: shop.ID, Amount: 100,
+		})
+		if err != nil {
+			t.Fatalf("InitiateSTKPush() error: %v", err)
+		}
+		return payment, resp
+	}
+	countPayments := func() int64 {
+		var n int64
+		db.Model(&models.MpesaPayment{}).Count(&n)
+		return n
+	}
+
+	first, resp := push()
+	if resp == nil || first.ID == 0 {
+		t.Fatalf("first push = %+v, %+v; want a sent prompt", first, resp)
+	}
+	again, resp := push()
+	if resp != nil || again == nil || again.ID != first.ID {
+		t.Errorf("repeat push = %+v, %+v; want payment %d without a new prompt", again, resp, first.ID)
+	}
+	if n := countPayments(); n != 1 {
+		t.Errorf("payments = %d; want 1 after a repeat push", n)
+	}
+
+	store.err = errors.New("redis down")
+	if _, resp := push(); resp == nil {
+		t.Error("push with the lock store down should still be sent")
+	}
+	if n := countPayments(); n != 2 {
+		t.Errorf("payments = %d; want 2 after a push without dedup", n)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/webhook"
+)
+
+// TestMpesaProcessExpiredPayments tests that only stale pending payments expire
+// and that a late callback still settles an expired payment
+func TestMpesaProcessExpiredPayments(t *testing.T) {
+	db := openTestDB(t, &models.MpesaPayment{}, &models.MpesaTransaction{})
+	paymentRepo := repository.NewMpesaPaymentRepository(db)
+	svc := mpesa.New(&mpesa.Config{Shortcode: "174379", Passkey: testPasskey},
+		paymentRepo, repository.NewMpesaTransactionRepository(db))
+
+	now := time.Now()
+	payments := []*models.MpesaPayment{
+		{ShopID: 1, Amount: 100, Phone: "254712345678", CheckoutRequestID: "ws_CO_stale", Status: models.MpesaPaymentPending, ExpiresAt: now.Add(-time.Minute)},
+		{ShopID: 1, Amount: 200, Phone: "254712345678", CheckoutRequestID: "ws_CO_fresh", Status: models.MpesaPaymentPending, ExpiresAt: now.Add(time.Minute)},
+		{ShopID: 2, Amount: 300, Phone: "254712345679", CheckoutRequestID: "ws_CO_paid", Status: models.MpesaPaymentCompleted, ExpiresAt: now.Add(-time.Minute)},
+		{ShopID: 2, Amount: 400, Phone: "254712345679", CheckoutRequestID: "ws_CO_stale2", Status: models.MpesaPaymentPending, ExpiresAt: now.Add(-time.Hour)},
+	}
+	for _, p := range payments {
+		if err := paymentRepo.Create(p); err != nil {
+			t.Fatalf("failed to create payment: %v", err)
+		}
+	}
+
+	if err := svc.ProcessExpiredPayments(); err != nil {
+		t.Fatalf("ProcessExpiredPayments() error: %v", err)
+	}
+
+	want := map[string]models.MpesaPaymentStatus{
+		"ws_CO_stale":  models.MpesaPaymentTimeout,
+		"ws_CO_fresh":  models.MpesaPaymentPending,
+		"ws_CO_paid":   models.MpesaPaymentCompleted,
+		"ws_CO_stale2": models.MpesaPaymentTimeout,
+	}
+	for checkoutID, status := range want {
+		payment, _ := paymentRepo.GetByCheckoutRequestID(checkoutID)
+		if payment.Status != status {
+			t.Errorf("%s: status = %s; want %s", checkoutID, payment.Status, status)
+		}
+	}
+
+	callback := func(checkoutID string, resultCode int) []byte {
+		return []byte(fmt.Sprintf(`{"Body":{"stkCallback":{"CheckoutRequestID":%q,"ResultCode":%d,"ResultDesc":"done",
+			"CallbackMetadata":{"Item":[{"Name":"MpesaReceiptNumber","Value":"QKL1234567"}]}}}}`, checkoutID, resultCode))
+	}
+
+	// A cancellation arriving after expiry changes nothing
+	if _, err := svc.ProcessSTKCallback(callback("ws_CO_stale2", 1032)); !errors.Is(err, mpesa.ErrDuplicateCallback) {
+		t.Errorf("late failure callback: expected ErrDuplicateCallback, got %v", err)
+	}
+
+	// The customer did pay: the late success callback wins
+	payment, err := svc.ProcessSTKCallback(callback("ws_CO_stale", 0))
+	if err != nil {
+		t.Fatalf("late success callback error: %v", err)
+	}
+	if payment.Status != models.MpesaPaymentCompleted || payment.MpesaReceipt != "QKL1234567" {
+		t.Errorf("late success callback: got status %s receipt %q", payment.Status, payment.MpesaReceipt)
+	}
+
+	// Nothing left to expire
+	if err := svc.ProcessExpiredPayments(); err != nil {
+		t.Fatalf("second ProcessExpiredPayments() error: %v", err)
+	}
+	if payment, _ := paymentRepo.GetByCheckoutRequestID("ws_CO_stale"); payment.Status != models.MpesaPaymentCompleted {
+		t.Errorf("completed payment was expired again: %s", payment.Status)
+	}
+}
+
+// TestWebhookShopEventScoping tests that payment events only reach the paying
+// shop's subscribed webhooks
+func TestWebhookShopEventScoping(t *testing.T) {
+	db := openTestDB(t, &models.Webhook{})
+	deliverySvc := webhook.NewDeliveryService(db, 1, 0)
+
+	hooks := []*models.Webhook{
+		{ShopID: 1, Name: "payments", URL: "https://a.example.com", Events: "sale.created, payment.completed", IsActive: true},
+		{ShopID: 1, Name: "sales", URL: "https://b.example.com", Events: "sale.created", IsActive: true},
+		{ShopID: 1, Name: "everything", URL: "https://c.example.com", Events: "all", IsActive: true},
+		{ShopID: 2, Name: "other shop", URL: "https://d.example.com", Events: "all", IsActive: true},
+	}
+	for _, h := range hooks {
+		if err := db.Create(h).Error; err != nil {
+			t.Fatalf("failed to create webhook: %v", err)
+		}
+	}
+
+	payment := &models.MpesaPayment{ID: 7, ShopID: 1, Amount: 100, Status: models.MpesaPaymentCompleted}
+	if err := deliverySvc.TriggerShopEvent(1, webhook.EventPaymentCompleted, payment); err != nil {
+		t.Fatalf("TriggerShopEvent() error: %v", err)
+	}
+
+	var events []webhook.WebhookEvent
+	db.Order("webhook_id ASC").Find(&events)
+	if len(events) != 2 {
+		t.Fatalf("expected 2 queued events, got %d", len(events))
+	}
+	if events[0].WebhookID != hooks[0].ID || events[1].WebhookID != hooks[2].ID {
+		t.Errorf("event sent to webhooks %d and %d; want %d and %d",
+			events[0].WebhookID, events[1].WebhookID, hooks[0].ID, hooks[2].ID)
+	}
+	for _, e := range events {
+		if e.Event != webhook.EventPaymentCompleted {
+			t.Errorf("event type = %s; want %s", e.Event, webhook.EventPaymentCompleted)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/config"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
+)
+
+// TestMpesaMockMode tests that a mock STK push for a basket is paid by a
+// synthesized callback and recorded as sales, without Daraja credentials
+func TestMpesaMockMode(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.StockMovement{}, &models.Sale{}, &models.PendingSale{}, &models.PendingSaleItem{},
+		&models.MpesaPayment{}, &models.MpesaPaymentAttempt{}, &models.MpesaTransaction{})
+
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanPro, IsActive: true}
+	db.Create(shop)
+	bread := &models.Product{ShopID: shop.ID, Name: "Bread", SellingPrice: 55, CostPrice: 45, CurrentStock: 10, IsActive: true}
+	db.Create(bread)
+
+	paymentRepo := repository.NewMpesaPaymentRepository(db)
+	svc := mpesa.New(&mpesa.Config{
+		Environment:       mpesa.EnvironmentMock,
+		MockCallbackDelay: 50 * time.Millisecond,
+	}, paymentRepo, repository.NewMpesaTransactionRepository(db))
+	svc.SetBusinessRepos(repository.NewSaleRepository(db), repository.NewProductRepository(db), repository.NewShopRepository(db))
+	svc.SetPendingSaleRepo(repository.NewPendingSaleRepository(db))
+
+	if !svc.IsConfigured() || !svc.IsMock() {
+		t.Fatal("mock mode should count as configured without credentials")
+	}
+
+	basket, err := svc.CreatePendingSale(shop.ID, []mpesa.PendingSaleItemRequest{{ProductID: bread.ID, Quantity: 2}}, "")
+	if err != nil {
+		t.Fatalf("CreatePendingSale() error: %v", err)
+	}
+	payment, resp, err := svc.InitiateSTKPush(context.Background(), &mpesa.PaymentRequest{
+		Phone: "0712345678", ShopID: shop.ID, PendingSaleID: &basket.ID,
+	})
+	if err != nil {
+		t.Fatalf("InitiateSTKPush() error: %v", err)
+	}
+	if resp.ResponseCode != "0" || !strings.HasPrefix(payment.CheckoutRequestID, "ws_CO_MOCK_") {
+		t.Fatalf("response = %+v, checkout %q; want an accepted mock prompt", resp, payment.CheckoutRequestID)
+	}
+
+	// The payment is marked completed before its sale is linked, so wait
+	// for both
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		got, _ := paymentRepo.GetByID(payment.ID)
+		if got.Status == models.MpesaPaymentCompleted && got.SaleID != nil {
+			if !strings.HasPrefix(got.MpesaReceipt, "MOCK") || got.AmountPaid != 110 {
+				t.Errorf("payment = receipt %q, paid %.2f; want a mock receipt for KSh 110",
+					got.MpesaReceipt, got.AmountPaid)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("payment status = %s, sale %v; want it completed with a sale by the mock callback", got.Status, got.SaleID)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	var product models.Product
+	db.First(&product, bread.ID)
+	if product.CurrentStock != 8 {
+		t.Errorf("bread stock = %d; want 8 after the paid basket", product.CurrentStock)
+	}
+}
+
+// TestMpesaMockRefusedInProduction tests that mock payments can only be
+// turned on when ENVIRONMENT says development or test
+func TestMpesaMockRefusedInProduction(t *testing.T) {
+	t.Setenv("MPESA_ENVIRONMENT", "mock")
+	t.Setenv("ENVIRONMENT", "production")
+	if _, err := config.Load(); err == nil {
+		t.Error("config.Load() accepted mock M-Pesa in production")
+	}
+
+	// Left unset, ENVIRONMENT defaults to development, which isn't enough
+	os.Unsetenv("ENVIRONMENT")
+	if _, err := config.Load(); err == nil {
+		t.Error("config.Load() accepted mock M-Pesa without ENVIRONMENT set")
+	}
+	t.Setenv("ENVIRONMENT", "staging")
+	if _, err := config.Load(); err == nil {
+		t.Error("config.Load() accepted mock M-Pesa in staging")
+	}
+
+	for _, env := range []string{"development", "test"} {
+		t.Setenv("ENVIRONMENT", env)
+		if _, err := config.Load(); err != nil {
+			t.Errorf("config.Load() in %s: %v", env, err)
+		}
+	}
+}
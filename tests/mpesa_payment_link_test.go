@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/handlers"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestMpesaPaymentLink tests that a payment link sends its STK push to the
+// phone the customer enters, is marked paid by the first completed payment
+// and stops taking payments afterwards
+func TestMpesaPaymentLink(t *testing.T) {
+	var prompts []map[string]interface{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/v1/generate", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "test-token", "expires_in": "3599"})
+	})
+	mux.HandleFunc("/mpesa/stkpush/v1/processrequest", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		prompts = append(prompts, body)
+		json.NewEncoder(w).Encode(map[string]string{
+			"MerchantRequestID": fmt.Sprintf("mr_%d", len(prompts)),
+			"CheckoutRequestID": fmt.Sprintf("ws_CO_%d", len(prompts)),
+			"ResponseCode":      "0",
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.StockMovement{}, &models.Sale{}, &models.PaymentLink{},
+		&models.MpesaPayment{}, &models.MpesaPaymentAttempt{}, &models.MpesaTransaction{})
+
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanPro, IsActive: true}
+	db.Create(shop)
+
+	shopRepo := repository.NewShopRepository(db)
+	paymentRepo := repository.NewMpesaPaymentRepository(db)
+	svc := mpesa.New(&mpesa.Config{
+		ConsumerKey:    "key",
+		ConsumerSecret: "secret",
+		Shortcode:      testShortcode,
+		Passkey:        testPasskey,
+		BaseURL:        server.URL,
+	}, paymentRepo, repository.NewMpesaTransactionRepository(db))
+	svc.SetBusinessRepos(repository.NewSaleRepository(db), repository.NewProductRepository(db), shopRepo)
+	svc.SetPaymentLinkRepo(repository.NewPaymentLinkRepository(db), "https://pay.example.com/")
+	ctx := context.Background()
+
+	link, err := svc.CreatePaymentLink(shop.ID, 350, "Delivery", 0)
+	if err != nil {
+		t.Fatalf("CreatePaymentLink() error: %v", err)
+	}
+	if got := svc.PaymentLinkURL(link); got != "https://pay.example.com/pay/"+link.Token {
+		t.Errorf("PaymentLinkURL() = %q", got)
+	}
+	if link.ExpiresAt == nil || time.Until(*link.ExpiresAt) < 6*24*time.Hour {
+		t.Errorf("expires_at = %v; want the default of 7 days", link.ExpiresAt)
+	}
+
+	// The public page sends the prompt to the number the customer types in
+	web := handlers.NewWebHandler(shopRepo, nil, nil)
+	web.SetPaymentLinks(svc)
+	app := fiber.New()
+	app.Get("/pay/:token", web.PaymentLinkPage)
+	app.Post("/pay/:token", web.PayPaymentLink)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/pay/"+link.Token, nil))
+	page, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusOK || !strings.Contains(string(page), "KSh 350") {
+		t.Fatalf("page status = %d, body %q; want the link amount", resp.StatusCode, page)
+	}
+
+	form := url.Values{"phone": {"0722000111"}, "amount": {"5"}}
+	req := httptest.NewRequest("POST", "/pay/"+link.Token, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, _ = app.Test(req)
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("pay status = %d; want 200", resp.StatusCode)
+	}
+	if len(prompts) != 1 || prompts[0]["PhoneNumber"] != "254722000111" || prompts[0]["Amount"] != float64(350) {
+		t.Fatalf("prompts = %v; want one KSh 350 prompt to 254722000111", prompts)
+	}
+
+	var payment models.MpesaPayment
+	db.Where("payment_link_id = ?", link.ID).First(&payment)
+
+	callback := func(checkoutID, receipt string) []byte {
+		return []byte(fmt.Sprintf(`{"Body":{"stkCallback":{"CheckoutRequestID":%q,"ResultCode":0,"ResultDesc":"done",
+			"CallbackMetadata":{"Item":[{"Name":"MpesaReceiptNumber","Value":%q}]}}}}`, checkoutID, receipt))
+	}
+
+	// A second customer opens the link before the first has paid
+	other, err := svc.PayPaymentLink(ctx, link.Token, "0733000222", 0)
+	if err != nil {
+		t.Fatalf("PayPaymentLink() error: %v", err)
+	}
+
+	if _, err := svc.ProcessSTKCallback(callback(payment.CheckoutRequestID, "QPL1111111")); err != nil {
+		t.Fatalf("ProcessSTKCallback() error: %v", err)
+	}
+	paid, _ := svc.GetPaymentLink(shop.ID, link.ID)
+	if paid.Status != models.PaymentLinkPaid || paid.MpesaReceipt != "QPL1111111" || paid.Phone != "254722000111" {
+		t.Fatalf("link after payment = %+v; want paid by 254722000111", paid)
+	}
+
+	// The later payment does not take over the link
+	svc.ProcessSTKCallback(callback(other.CheckoutRequestID, "QPL2222222"))
+	if again, _ := svc.GetPaymentLink(shop.ID, link.ID); again.MpesaReceipt != "QPL1111111" {
+		t.Errorf("link receipt = %s; want the first payment's", again.MpesaReceipt)
+	}
+	var sales int64
+	db.Model(&models.Sale{}).Count(&sales)
+	if sales != 0 {
+		t.Errorf("sales = %d; a payment link records no sale", sales)
+	}
+
+	if _, err := svc.PayPaymentLink(ctx, link.Token, "0722000111", 0); !errors.Is(err, mpesa.ErrPaymentLinkUnavailable) {
+		t.Errorf("paying a paid link: expected ErrPaymentLinkUnavailable, got %v", err)
+	}
+
+	// Open amount links take the customer's amount; expired ones take nothing
+	open, _ := svc.CreatePaymentLink(shop.ID, 0, "", 0)
+	if _, err := svc.PayPaymentLink(ctx, open.Token, "0722000111", 0); !errors.Is(err, mpesa.ErrPaymentLinkAmount) {
+		t.Errorf("open link without amount: expected ErrPaymentLinkAmount, got %v", err)
+	}
+	if p, err := svc.PayPaymentLink(ctx, open.Token, "0722000111", 99.6); err != nil || p.Amount != 100 {
+		t.Errorf("open link payment = %v, %v; want KSh 100", p, err)
+	}
+
+	expired, _ := svc.CreatePaymentLink(shop.ID, 100, "", time.Hour)
+	db.Model(&models.PaymentLink{}).Where("id = ?", expired.ID).Update("expires_at", time.Now().Add(-time.Minute))
+	if _, err := svc.PayPaymentLink(ctx, expired.Token, "0722000111", 0); !errors.Is(err, mpesa.ErrPaymentLinkUnavailable) {
+		t.Errorf("expired link: expected ErrPaymentLinkUnavailable, got %v", err)
+	}
+	if l, _ := svc.GetPaymentLink(shop.ID, expired.ID); l.Status != models.PaymentLinkExpired {
+		t.Errorf("expired link status = %s; want expired", l.Status)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
+)
+
+// TestMpesaPendingSale tests that a paid basket is recorded item by item at
+// the prices it was made with, and that a payment without one makes no sale
+// until the shop attributes it
+func TestMpesaPendingSale(t *testing.T) {
+	var prompts int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/v1/generate", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "test-token", "expires_in": "3599"})
+	})
+	mux.HandleFunc("/mpesa/stkpush/v1/processrequest", func(w http.ResponseWriter, r *http.Request) {
+		prompts++
+		json.NewEncoder(w).Encode(map[string]string{
+			"MerchantRequestID": fmt.Sprintf("mr_%d", prompts),
+			"CheckoutRequestID": fmt.Sprintf("ws_CO_%d", prompts),
+			"ResponseCode":      "0",
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.StockMovement{}, &models.Sale{}, &models.PendingSale{}, &models.PendingSaleItem{},
+		&models.MpesaPayment{}, &models.MpesaPaymentAttempt{}, &models.MpesaTransaction{})
+
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanPro, IsActive: true}
+	db.Create(shop)
+	bread := &models.Product{ShopID: shop.ID, Name: "Bread", SellingPrice: 55.5, CostPrice: 45, CurrentStock: 10, IsActive: true}
+	milk := &models.Product{ShopID: shop.ID, Name: "Milk", SellingPrice: 60, CostPrice: 50, CurrentStock: 5, IsActive: true}
+	db.Create(bread)
+	db.Create(milk)
+
+	paymentRepo := repository.NewMpesaPaymentRepository(db)
+	productRepo := repository.NewProductRepository(db)
+	svc := mpesa.New(&mpesa.Config{
+		ConsumerKey:    "key",
+		ConsumerSecret: "secret",
+		Shortcode:      testShortcode,
+		Passkey:        testPasskey,
+		BaseURL:        server.URL,
+	}, paymentRepo, repository.NewMpesaTransactionRepository(db))
+	svc.SetBusinessRepos(repository.NewSaleRepository(db), productRepo, repository.NewShopRepository(db))
+	svc.SetPendingSaleRepo(repository.NewPendingSaleRepository(db))
+	ctx := context.Background()
+
+	basket, err := svc.CreatePendingSale(shop.ID, []mpesa.PendingSaleItemRequest{
+		{ProductID: bread.ID, Quantity: 2},
+		{ProductID: milk.ID, Quantity: 1},
+	}, "")
+	if err != nil {
+		t.Fatalf("CreatePendingSale() error: %v", err)
+	}
+	if basket.TotalAmount != 171 {
+		t.Errorf("basket total = %.2f; want 171 (171.00 rounded)", basket.TotalAmount)
+	}
+
+	// Prices change after the basket was made; the sale keeps the old ones
+	db.Model(bread).Update("selling_price", 80)
+
+	if _, _, err := svc.InitiateSTKPush(ctx, &mpesa.PaymentRequest{
+		Phone: "0712345678", Amount: 100, ShopID: shop.ID, PendingSaleID: &basket.ID,
+	}); !errors.Is(err, mpesa.ErrPendingSaleAmount) {
+		t.Errorf("wrong amount: expected ErrPendingSaleAmount, got %v", err)
+	}
+
+	payment, _, err := svc.InitiateSTKPush(ctx, &mpesa.PaymentRequest{
+		Phone: "0712345678", ShopID: shop.ID, PendingSaleID: &basket.ID,
+	})
+	if err != nil || payment.Amount != 171 {
+		t.Fatalf("InitiateSTKPush() = %v, %v; want a KSh 171 payment", payment, err)
+	}
+
+	callback := func(checkoutID, receipt string) []byte {
+		return []byte(fmt.Sprintf(`{"Body":{"stkCallback":{"CheckoutRequestID":%q,"ResultCode":0,"ResultDesc":"done",
+			"CallbackMetadata":{"Item":[{"Name":"MpesaReceiptNumber","Value":%q}]}}}}`, checkoutID, receipt))
+	}
+	if _, err := svc.ProcessSTKCallback(callback(payment.CheckoutRequestID, "QKL1111111")); err != nil {
+		t.Fatalf("ProcessSTKCallback() error: %v", err)
+	}
+
+	var sales []models.Sale
+	db.Where("pending_sale_id = ?", basket.ID).Order("id").Find(&sales)
+	if len(sales) != 2 {
+		t.Fatalf("sales = %d; want 2", len(sales))
+	}
+	if sales[0].ProductID != bread.ID || sales[0].Quantity != 2 || sales[0].UnitPrice != 55.5 {
+		t.Errorf("bread sale = %+v; want 2 @ 55.50", sales[0])
+	}
+	if total := sales[0].TotalAmount + sales[1].TotalAmount; total != 171 {
+		t.Errorf("sales total = %.2f; want 171", total)
+	}
+	if b, _ := productRepo.GetByID(bread.ID); b.CurrentStock != 8 {
+		t.Errorf("bread stock = %d; want 8", b.CurrentStock)
+	}
+	if m, _ := productRepo.GetByID(milk.ID); m.CurrentStock != 4 {
+		t.Errorf("milk stock = %d; want 4", m.CurrentStock)
+	}
+	if p, _ := paymentRepo.GetByID(payment.ID); p.SaleID == nil {
+		t.Error("payment should be linked to its sale")
+	}
+
+	// A product payment without a basket records no sale
+	stray, _, err := svc.InitiateSTKPush(ctx, &mpesa.PaymentRequest{
+		Phone: "0722222222", Amount: 120, ShopID: shop.ID, ProductID: &milk.ID,
+	})
+	if err != nil {
+		t.Fatalf("InitiateSTKPush() error: %v", err)
+	}
+	svc.ProcessSTKCallback(callback(stray.CheckoutRequestID, "QKL2222222"))
+
+	var count int64
+	db.Model(&models.Sale{}).Count(&count)
+	if count != 2 {
+		t.Errorf("sales after unattributed payment = %d; want 2", count)
+	}
+
+	// The shop attributes it to a basket afterwards
+	second, err := svc.CreatePendingSale(shop.ID, []mpesa.PendingSaleItemRequest{{ProductID: milk.ID, Quantity: 2}}, "")
+	if err != nil {
+		t.Fatalf("CreatePendingSale() error: %v", err)
+	}
+	attributed, err := svc.AttributePayment(shop.ID, stray.ID, second.ID)
+	if err != nil || len(attributed) != 1 || attributed[0].Quantity != 2 {
+		t.Fatalf("AttributePayment() = %v, %v; want one sale of 2", attributed, err)
+	}
+	if _, err := svc.AttributePayment(shop.ID, stray.ID, second.ID); !errors.Is(err, mpesa.ErrPaymentAlreadyLinked) {
+		t.Errorf("second attribution: expected ErrPaymentAlreadyLinked, got %v", err)
+	}
+
+	// A payment for a basket that closed before it arrived can still be
+	// attributed to another one
+	third, _ := svc.CreatePendingSale(shop.ID, []mpesa.PendingSaleItemRequest{{ProductID: bread.ID, Quantity: 1}}, "")
+	late, _, err := svc.InitiateSTKPush(ctx, &mpesa.PaymentRequest{
+		Phone: "0733333333", ShopID: shop.ID, PendingSaleID: &third.ID,
+	})
+	if err != nil {
+		t.Fatalf("InitiateSTKPush() error: %v", err)
+	}
+	svc.CancelPendingSale(shop.ID, third.ID)
+	svc.ProcessSTKCallback(callback(late.CheckoutRequestID, "QKL3333333"))
+	if p, _ := paymentRepo.GetByID(late.ID); p.SaleID != nil || p.PendingSaleID != nil {
+		t.Errorf("payment for a cancelled basket should be unlinked: %+v", p)
+	}
+	fourth, _ := svc.CreatePendingSale(shop.ID, []mpesa.PendingSaleItemRequest{{ProductID: bread.ID, Quantity: 1}}, "")
+	if _, err := svc.AttributePayment(shop.ID, late.ID, fourth.ID); err != nil {
+		t.Errorf("attributing the payment for a cancelled basket: %v", err)
+	}
+
+	if err := svc.CancelPendingSale(shop.ID, basket.ID); !errors.Is(err, repository.ErrPendingSaleClosed) {
+		t.Errorf("cancelling a paid basket: expected ErrPendingSaleClosed, got %v", err)
+	}
+}
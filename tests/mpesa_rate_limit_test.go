@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mpesahandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/mpesa"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/outbox"
+	"github.com/gofiber/fiber/v2"
+)
+
+// rateLimitedDaraja answers the first limited calls to each path with 429
+// and Retry-After, then like Daraja
+type rateLimitedDaraja struct {
+	mu         sync.Mutex
+	limited    map[string]int // path to how many calls get 429
+	retryAfter string
+	calls      map[string]int
+}
+
+func (d *rateLimitedDaraja) server(t *testing.T) *httptest.Server {
+	t.Helper()
+	d.calls = map[string]int{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.mu.Lock()
+		d.calls[r.URL.Path]++
+		n := d.calls[r.URL.Path]
+		d.mu.Unlock()
+
+		if n <= d.limited[r.URL.Path] {
+			if d.retryAfter != "" {
+				w.Header().Set("Retry-After", d.retryAfter)
+			}
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if r.URL.Path == "/oauth/v1/generate" {
+			json.NewEncoder(w).Encode(map[string]string{"access_token": "test-token", "expires_in": "3599"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"MerchantRequestID": "mr_1",
+			"CheckoutRequestID": "ws_CO_1",
+			"ResponseCode":      "0",
+		})
+	}))
+}
+
+func (d *rateLimitedDaraja) count(path string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.calls[path]
+}
+
+func newRateLimitTestService(t *testing.T, baseURL string) (*mpesa.Service, *repository.MpesaPaymentRepository) {
+	t.Helper()
+	db := openTestDB(t, &models.MpesaPayment{}, &models.MpesaPaymentAttempt{}, &models.MpesaTransaction{})
+	paymentRepo := repository.NewMpesaPaymentRepository(db)
+	svc := mpesa.New(&mpesa.Config{
+		ConsumerKey:    "key",
+		ConsumerSecret: "secret",
+		Shortcode:      testShortcode,
+		Passkey:        testPasskey,
+		BaseURL:        baseURL,
+	}, paymentRepo, repository.NewMpesaTransactionRepository(db))
+	return svc, paymentRepo
+}
+
+// TestMpesaRateLimitBackoff tests that calls Daraja answers with 429 are
+// retried after a backoff, and that one still rate limited after every
+// retry fails with ErrRateLimited
+func TestMpesaRateLimitBackoff(t *testing.T) {
+	daraja := &rateLimitedDaraja{limited: map[string]int{
+		"/oauth/v1/generate":               1,
+		"/mpesa/stkpush/v1/processrequest": 1,
+	}}
+	server := daraja.server(t)
+	defer server.Close()
+
+	svc, _ := newRateLimitTestService(t, server.URL)
+	payment, _, err := svc.InitiateSTKPush(context.Background(), &mpesa.PaymentRequest{
+		Phone: "0712345678", Amount: 100, AccountReference: "TEST",
+	})
+	if err != nil {
+		t.Fatalf("InitiateSTKPush() after 429s error: %v", err)
+	}
+	if payment.Status != models.MpesaPaymentPending || payment.CheckoutRequestID != "ws_CO_1" {
+		t.Errorf("payment = %+v; want pending with the checkout ID", payment)
+	}
+	if got := daraja.count("/oauth/v1/generate"); got != 2 {
+		t.Errorf("token requests = %d; want 2", got)
+	}
+	if got := daraja.count("/mpesa/stkpush/v1/processrequest"); got != 2 {
+		t.Errorf("STK requests = %d; want 2", got)
+	}
+
+	// Rate limited every time: MaxRetries retries, then the error
+	daraja = &rateLimitedDaraja{limited: map[string]int{"/mpesa/stkpush/v1/processrequest": 100}, retryAfter: "0"}
+	server = daraja.server(t)
+	defer server.Close()
+
+	svc, _ = newRateLimitTestService(t, server.URL)
+	payment, _, err = svc.InitiateSTKPush(context.Background(), &mpesa.PaymentRequest{
+		Phone: "0712345678", Amount: 200, AccountReference: "TEST",
+	})
+	if !errors.Is(err, mpesa.ErrRateLimited) {
+		t.Fatalf("InitiateSTKPush() error = %v; want ErrRateLimited", err)
+	}
+	if got := daraja.count("/mpesa/stkpush/v1/processrequest"); got != mpesa.MaxRetries+1 {
+		t.Errorf("STK requests = %d; want %d", got, mpesa.MaxRetries+1)
+	}
+	if payment.Status != models.MpesaPaymentFailed || payment.FailureReason != mpesa.RateLimitMessage {
+		t.Errorf("payment = %s (%q); want failed as rate limited", payment.Status, payment.FailureReason)
+	}
+	if got := mpesa.RetryAfterSeconds(err); got != 1 {
+		t.Errorf("RetryAfterSeconds() = %d; want 1, the least", got)
+	}
+}
+
+// TestMpesaRateLimitCancel tests that waiting out a Retry-After stops when
+// the request's context is done
+func TestMpesaRateLimitCancel(t *testing.T) {
+	daraja := &rateLimitedDaraja{limited: map[string]int{"/oauth/v1/generate": 100}, retryAfter: "5"}
+	server := daraja.server(t)
+	defer server.Close()
+
+	svc, _ := newRateLimitTestService(t, server.URL)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, _, err := svc.InitiateSTKPush(ctx, &mpesa.PaymentRequest{
+		Phone: "0712345678", Amount: 100, AccountReference: "TEST",
+	})
+	if err == nil || errors.Is(err, mpesa.ErrRateLimited) {
+		t.Errorf("InitiateSTKPush() error = %v; want the context's error", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("cancelled request took %s; want it to stop waiting", elapsed)
+	}
+	if got := daraja.count("/oauth/v1/generate"); got != 1 {
+		t.Errorf("token requests = %d; want 1", got)
+	}
+}
+
+// TestMpesaRateLimitResponse tests that the STK push endpoint answers a
+// rate-limited payment with 429 and Retry-After
+func TestMpesaRateLimitResponse(t *testing.T) {
+	daraja := &rateLimitedDaraja{limited: map[string]int{"/mpesa/stkpush/v1/processrequest": 100}, retryAfter: "0"}
+	server := daraja.server(t)
+	defer server.Close()
+
+	svc, paymentRepo := newRateLimitTestService(t, server.URL)
+	db := openTestDB(t, &models.Shop{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	db.Create(shop)
+	h := mpesahandler.New(svc, repository.NewShopRepository(db), nil, nil, paymentRepo, nil)
+
+	app := fiber.New()
+	app.Post("/stk", func(c *fiber.Ctx) error {
+		c.Locals("shop_id", shop.ID)
+		return h.STKPush(c)
+	})
+	req := httptest.NewRequest("POST", "/stk", strings.NewReader(`{"phone": "0712345678", "amount": 100}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, 30000)
+	if err != nil {
+		t.Fatalf("POST /stk failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusTooManyRequests || resp.Header.Get("Retry-After") != "1" {
+		t.Errorf("status %d, Retry-After %q; want 429 and 1", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+	if !strings.Contains(string(body), "try again shortly") {
+		t.Errorf("body = %s; want the try again message", body)
+	}
+}
+
+// TestMpesaRateLimitWhatsApp tests that a rate-limited "mpesa pay" answers
+// the WhatsApp webhook at once and is sent again by the outbox workers,
+// which tell the shop when it goes through
+func TestMpesaRateLimitWhatsApp(t *testing.T) {
+	daraja := &rateLimitedDaraja{limited: map[string]int{"/mpesa/stkpush/v1/processrequest": 1}, retryAfter: "5"}
+	server := daraja.server(t)
+	defer server.Close()
+
+	svc, _ := newRateLimitTestService(t, server.URL)
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.Sale{}, &models.DailySummary{},
+		&models.AuditLog{}, &models.OutboxMessage{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanPro, IsActive: true}
+	db.Create(shop)
+
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		repository.NewProductRepository(db),
+		repository.NewSaleRepository(db),
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	cmdHandler.SetMpesaService(svc)
+	queue := outbox.New(repository.NewOutboxRepository(db), 1)
+	var mu sync.Mutex
+	var told []string
+	cmdHandler.SetSTKPushQueue(queue.QueueSTKPush, func(phone, message string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		told = append(told, message)
+		return nil
+	})
+	queue.SetSender(models.OutboxChannelMpesa, cmdHandler.SendQueuedSTKPush)
+
+	start := time.Now()
+	reply, err := cmdHandler.Handle(shop.Phone, services.NewCommandParser(nil, nil).Parse("mpesa pay 500 0722000111"))
+	if err != nil {
+		t.Fatalf("mpesa pay: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("rate-limited mpesa pay took %s; want it not to wait out Retry-After", elapsed)
+	}
+	if !strings.Contains(reply, "busy") || !strings.Contains(reply, "as soon as") {
+		t.Errorf("reply = %q; want the push queued", reply)
+	}
+	if got := daraja.count("/mpesa/stkpush/v1/processrequest"); got != 1 {
+		t.Errorf("STK requests = %d; want 1, no retries in the webhook", got)
+	}
+
+	sent, err := queue.ProcessDue()
+	if err != nil || sent != 1 {
+		t.Fatalf("ProcessDue() = %d, %v; want the queued push sent", sent, err)
+	}
+	if got := daraja.count("/mpesa/stkpush/v1/processrequest"); got != 2 {
+		t.Errorf("STK requests = %d; want the outbox to send it again", got)
+	}
+	if len(told) != 1 || !strings.Contains(told[0], "STK Push Sent") || !strings.Contains(told[0], "ws_CO_1") {
+		t.Errorf("shop was told %q; want the sent prompt", told)
+	}
+}
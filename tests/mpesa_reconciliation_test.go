@@ -0,0 +1,152 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/export"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
+)
+
+// TestMpesaReconciliation tests matching payments to sales by receipt
+func TestMpesaReconciliation(t *testing.T) {
+	db := openTestDB(t, &models.MpesaPayment{}, &models.MpesaTransaction{}, &models.Sale{})
+
+	const shopID = 1
+	now := time.Now()
+	received := func(txID, kind string, amount float64, at time.Time) *models.MpesaTransaction {
+		tx := &models.MpesaTransaction{ShopID: shopID, Type: kind, Amount: amount, Phone: "254712345678",
+			TransactionID: txID, ReceiptNumber: txID, TransactionTime: at, Status: "completed"}
+		if err := db.Create(tx).Error; err != nil {
+			t.Fatalf("failed to create transaction: %v", err)
+		}
+		return tx
+	}
+	sold := func(receipt string, amount float64) {
+		if err := db.Create(&models.Sale{ShopID: shopID, ProductID: 1, Quantity: 1, UnitPrice: amount,
+			TotalAmount: amount, PaymentMethod: models.PaymentMpesa, MpesaReceipt: receipt}).Error; err != nil {
+			t.Fatalf("failed to create sale: %v", err)
+		}
+	}
+
+	received("QAA0000001", "stk_push", 100, now)
+	sold("QAA0000001", 100)
+
+	received("QAA0000002", "c2b", 200, now)
+
+	sold("QAA0000003", 80)
+	sold("", 50)
+
+	received("QAA0000004", "stk_push", 150, now)
+	sold("QAA0000004", 120)
+
+	completedAt := now
+	db.Create(&models.MpesaPayment{ShopID: shopID, Amount: 60, Phone: "254712345678", CheckoutRequestID: "ws_CO_5",
+		MpesaReceipt: "QAA0000005", Status: models.MpesaPaymentCompleted, CompletedAt: &completedAt})
+	sold("QAA0000005", 60)
+
+	reversed := received("QAA0000006", "c2b", 70, now)
+	db.Model(reversed).Update("reversal_status", models.ReversalCompleted)
+	sold("QAA0000006", 70)
+
+	received("QAA0000007", "b2c", 500, now)
+
+	received("QAA0000008", "c2b", 90, now.AddDate(0, 0, -2))
+	sold("QAA0000008", 90)
+
+	from, to, err := mpesa.ParseReconciliationRange(now.Format("2006-01-02"), "")
+	if err != nil {
+		t.Fatalf("ParseReconciliationRange() error: %v", err)
+	}
+	reconciler := mpesa.NewReconciliationService(
+		repository.NewMpesaPaymentRepository(db),
+		repository.NewMpesaTransactionRepository(db),
+		repository.NewSaleRepository(db),
+	)
+	report, err := reconciler.Reconcile(shopID, from, to)
+	if err != nil {
+		t.Fatalf("Reconcile() error: %v", err)
+	}
+
+	statuses := make(map[string]mpesa.ReconciliationStatus)
+	for _, row := range report.Rows {
+		statuses[row.Receipt] = row.Status
+	}
+	want := map[string]mpesa.ReconciliationStatus{
+		"QAA0000001": mpesa.ReconciliationMatched,
+		"QAA0000002": mpesa.ReconciliationUnmatchedPayment,
+		"QAA0000003": mpesa.ReconciliationUnmatchedSale,
+		"":           mpesa.ReconciliationUnmatchedSale,
+		"QAA0000004": mpesa.ReconciliationAmountMismatch,
+		"QAA0000005": mpesa.ReconciliationMatched,
+		"QAA0000006": mpesa.ReconciliationUnmatchedSale,
+		"QAA0000008": mpesa.ReconciliationMatched,
+	}
+	if len(statuses) != len(want) {
+		t.Errorf("got %d rows %v; want %d", len(report.Rows), statuses, len(want))
+	}
+	for receipt, status := range want {
+		if statuses[receipt] != status {
+			t.Errorf("receipt %q: status %q; want %q", receipt, statuses[receipt], status)
+		}
+	}
+
+	s := report.Summary
+	if s.PaymentCount != 4 || s.PaymentTotal != 510 {
+		t.Errorf("payments = %d, %.2f; want 4, 510.00", s.PaymentCount, s.PaymentTotal)
+	}
+	if s.SaleCount != 7 || s.SaleTotal != 570 {
+		t.Errorf("sales = %d, %.2f; want 7, 570.00", s.SaleCount, s.SaleTotal)
+	}
+	if s.MatchedCount != 3 || s.MismatchCount != 1 || s.UnmatchedPaymentTotal != 200 || s.UnmatchedSaleTotal != 200 {
+		t.Errorf("unexpected summary: %+v", s)
+	}
+	if s.Difference != -60 {
+		t.Errorf("difference = %.2f; want -60.00", s.Difference)
+	}
+
+	discrepancies := *report
+	discrepancies.Rows = append([]mpesa.ReconciliationRow(nil), report.Rows...)
+	discrepancies.Discrepancies()
+	if len(discrepancies.Rows) != 5 {
+		t.Errorf("got %d discrepancies; want 5", len(discrepancies.Rows))
+	}
+	for _, row := range discrepancies.Rows {
+		if row.Status == mpesa.ReconciliationMatched {
+			t.Errorf("discrepancies include matched receipt %q", row.Receipt)
+		}
+	}
+
+	data, err := (&export.ReconciliationExporter{}).Export(report, export.FormatCSV)
+	if err != nil {
+		t.Fatalf("Export() error: %v", err)
+	}
+	csv := string(data)
+	if !strings.HasPrefix(csv, "Status,Receipt,Source") || !strings.Contains(csv, "unmatched_payment,QAA0000002,c2b") {
+		t.Errorf("unexpected CSV:\n%s", csv)
+	}
+}
+
+// TestParseReconciliationRange tests date range validation
+func TestParseReconciliationRange(t *testing.T) {
+	from, to, err := mpesa.ParseReconciliationRange("2025-03-01", "2025-03-31")
+	if err != nil {
+		t.Fatalf("ParseReconciliationRange() error: %v", err)
+	}
+	if from.Format("2006-01-02") != "2025-03-01" || to.Format("2006-01-02") != "2025-04-01" {
+		t.Errorf("range = %s to %s; want 2025-03-01 to 2025-04-01", from, to)
+	}
+
+	for _, tt := range [][2]string{
+		{"2025-03-31", "2025-03-01"},
+		{"2025-01-01", "2025-12-31"},
+		{"01/03/2025", "2025-03-31"},
+	} {
+		if _, _, err := mpesa.ParseReconciliationRange(tt[0], tt[1]); err == nil {
+			t.Errorf("ParseReconciliationRange(%q, %q) should fail", tt[0], tt[1])
+		}
+	}
+}
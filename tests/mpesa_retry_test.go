@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
+)
+
+// TestMpesaRetryBackoff tests the delay before each retry
+func TestMpesaRetryBackoff(t *testing.T) {
+	want := []time.Duration{15 * time.Second, 30 * time.Second, 60 * time.Second}
+	for retries, delay := range want {
+		if got := mpesa.RetryBackoff(retries); got != delay {
+			t.Errorf("RetryBackoff(%d) = %s; want %s", retries, got, delay)
+		}
+	}
+}
+
+// TestMpesaRetryPayment tests that retries reuse the payment row and its
+// AccountReference, honour the backoff, and that callbacks for any prompt
+// settle it correctly
+func TestMpesaRetryPayment(t *testing.T) {
+	var prompts []map[string]interface{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/v1/generate", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "test-token", "expires_in": "3599"})
+	})
+	mux.HandleFunc("/mpesa/stkpush/v1/processrequest", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		prompts = append(prompts, body)
+		json.NewEncoder(w).Encode(map[string]string{
+			"MerchantRequestID": fmt.Sprintf("mr_%d", len(prompts)),
+			"CheckoutRequestID": fmt.Sprintf("ws_CO_%d", len(prompts)),
+			"ResponseCode":      "0",
+		})
+	})
+	// The first prompt timed out on the customer's phone
+	mux.HandleFunc("/mpesa/stkpushquery/v1/query", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"ResponseCode": "0", "ResultCode": "1037", "ResultDesc": "No response from user"})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	db := openTestDB(t, &models.MpesaPayment{}, &models.MpesaPaymentAttempt{}, &models.MpesaTransaction{})
+	paymentRepo := repository.NewMpesaPaymentRepository(db)
+	svc := mpesa.New(&mpesa.Config{
+		ConsumerKey:    "key",
+		ConsumerSecret: "secret",
+		Shortcode:      testShortcode,
+		Passkey:        testPasskey,
+		BaseURL:        server.URL,
+	}, paymentRepo, repository.NewMpesaTransactionRepository(db))
+	ctx := context.Background()
+
+	payment, _, err := svc.InitiateSTKPush(ctx, &mpesa.PaymentRequest{
+		Phone: "0712345678", Amount: 150, AccountReference: "INV7", ShopID: 1,
+	})
+	if err != nil {
+		t.Fatalf("InitiateSTKPush() error: %v", err)
+	}
+
+	// Too soon after the first prompt
+	var wait *mpesa.RetryWaitError
+	if _, err := svc.RetryPayment(ctx, 1, payment.ID); !errors.As(err, &wait) || wait.Wait <= 0 {
+		t.Fatalf("immediate retry: expected RetryWaitError, got %v", err)
+	}
+
+	if _, err := svc.RetryPayment(ctx, 2, payment.ID); !errors.Is(err, mpesa.ErrPaymentNotFound) {
+		t.Errorf("retry from another shop: expected ErrPaymentNotFound, got %v", err)
+	}
+
+	backdate := func(d time.Duration) {
+		db.Model(&models.MpesaPayment{}).Where("id = ?", payment.ID).Update("last_attempt_at", time.Now().Add(-d))
+	}
+	backdate(mpesa.RetryBackoff(0))
+
+	retried, err := svc.RetryPayment(ctx, 1, payment.ID)
+	if err != nil {
+		t.Fatalf("RetryPayment() error: %v", err)
+	}
+	if retried.ID != payment.ID || retried.RetryCount != 1 || retried.CheckoutRequestID != "ws_CO_2" {
+		t.Errorf("retried payment = id %d, retries %d, checkout %s; want %d, 1, ws_CO_2",
+			retried.ID, retried.RetryCount, retried.CheckoutRequestID, payment.ID)
+	}
+	if len(prompts) != 2 || prompts[1]["AccountReference"] != prompts[0]["AccountReference"] {
+		t.Errorf("retry prompt AccountReference = %v; want %v", prompts[1]["AccountReference"], prompts[0]["AccountReference"])
+	}
+
+	var count int64
+	db.Model(&models.MpesaPayment{}).Count(&count)
+	if count != 1 {
+		t.Errorf("retry created a new payment row: %d rows", count)
+	}
+
+	// The customer cancels the replaced prompt: the payment stays pending
+	callback := func(checkoutID string, resultCode int) []byte {
+		return []byte(fmt.Sprintf(`{"Body":{"stkCallback":{"CheckoutRequestID":%q,"ResultCode":%d,"ResultDesc":"done",
+			"CallbackMetadata":{"Item":[{"Name":"MpesaReceiptNumber","Value":"QKL7654321"}]}}}}`, checkoutID, resultCode))
+	}
+	if _, err := svc.ProcessSTKCallback(callback("ws_CO_1", 1032)); !errors.Is(err, mpesa.ErrDuplicateCallback) {
+		t.Errorf("cancelled old prompt: expected ErrDuplicateCallback, got %v", err)
+	}
+	if p, _ := paymentRepo.GetByID(payment.ID); p.Status != models.MpesaPaymentPending {
+		t.Errorf("old prompt cancellation changed status to %s", p.Status)
+	}
+
+	// Polling by the first checkout ID still finds the payment
+	if p, err := svc.GetPaymentByCheckoutID("ws_CO_1"); err != nil || p.ID != payment.ID {
+		t.Errorf("GetPaymentByCheckoutID(first prompt) = %v, %v", p, err)
+	}
+
+	paid, err := svc.ProcessSTKCallback(callback("ws_CO_2", 0))
+	if err != nil || paid.Status != models.MpesaPaymentCompleted {
+		t.Fatalf("success callback = %v, %v; want completed", paid, err)
+	}
+
+	payments, _, _ := paymentRepo.GetByShopID(1, 10, 0)
+	if len(payments) != 1 || len(payments[0].Attempts) != 2 {
+		t.Fatalf("payments list = %+v; want one payment with 2 attempts", payments)
+	}
+	attempts := payments[0].Attempts
+	if attempts[0].Status != models.MpesaPaymentFailed || attempts[1].Status != models.MpesaPaymentCompleted {
+		t.Errorf("attempt statuses = %s, %s; want failed, completed", attempts[0].Status, attempts[1].Status)
+	}
+
+	backdate(time.Hour)
+	if _, err := svc.RetryPayment(ctx, 1, payment.ID); !errors.Is(err, mpesa.ErrPaymentCompleted) {
+		t.Errorf("retry after payment: expected ErrPaymentCompleted, got %v", err)
+	}
+
+	expired := &models.MpesaPayment{ShopID: 1, Amount: 50, Phone: "254712345678", CheckoutRequestID: "ws_CO_old",
+		Status: models.MpesaPaymentFailed, ExpiresAt: time.Now().Add(-time.Minute)}
+	exhausted := &models.MpesaPayment{ShopID: 1, Amount: 60, Phone: "254712345678", CheckoutRequestID: "ws_CO_many",
+		Status: models.MpesaPaymentFailed, RetryCount: mpesa.MaxRetries, ExpiresAt: time.Now().Add(time.Minute)}
+	paymentRepo.Create(expired)
+	paymentRepo.Create(exhausted)
+
+	if _, err := svc.RetryPayment(ctx, 1, expired.ID); !errors.Is(err, mpesa.ErrPaymentExpired) {
+		t.Errorf("retry of expired payment: expected ErrPaymentExpired, got %v", err)
+	}
+	if _, err := svc.RetryPayment(ctx, 1, exhausted.ID); !errors.Is(err, mpesa.ErrMaxRetriesExceeded) {
+		t.Errorf("retry past the limit: expected ErrMaxRetriesExceeded, got %v", err)
+	}
+	if len(prompts) != 2 {
+		t.Errorf("rejected retries sent prompts: %d sent", len(prompts))
+	}
+}
+
+// TestMpesaRetryQueriesLastPrompt tests that a retry asks Daraja how the
+// last prompt went first, and doesn't prompt a customer who paid it or
+// still has it
+func TestMpesaRetryQueriesLastPrompt(t *testing.T) {
+	var prompts int
+	query := map[string]string{"ResponseCode": "0"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/v1/generate", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "test-token", "expires_in": "3599"})
+	})
+	mux.HandleFunc("/mpesa/stkpush/v1/processrequest", func(w http.ResponseWriter, r *http.Request) {
+		prompts++
+		json.NewEncoder(w).Encode(map[string]string{
+			"MerchantRequestID": fmt.Sprintf("mr_%d", prompts),
+			"CheckoutRequestID": fmt.Sprintf("ws_CO_%d", prompts),
+			"ResponseCode":      "0",
+		})
+	})
+	mux.HandleFunc("/mpesa/stkpushquery/v1/query", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(query)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	db := openTestDB(t, &models.MpesaPayment{}, &models.MpesaPaymentAttempt{}, &models.MpesaTransaction{})
+	paymentRepo := repository.NewMpesaPaymentRepository(db)
+	svc := mpesa.New(&mpesa.Config{
+		ConsumerKey:    "key",
+		ConsumerSecret: "secret",
+		Shortcode:      testShortcode,
+		Passkey:        testPasskey,
+		BaseURL:        server.URL,
+	}, paymentRepo, repository.NewMpesaTransactionRepository(db))
+	ctx := context.Background()
+
+	payment, _, err := svc.InitiateSTKPush(ctx, &mpesa.PaymentRequest{
+		Phone: "0712345678", Amount: 150, AccountReference: "INV8", ShopID: 1,
+	})
+	if err != nil {
+		t.Fatalf("InitiateSTKPush() error: %v", err)
+	}
+	db.Model(&models.MpesaPayment{}).Where("id = ?", payment.ID).Update("last_attempt_at", time.Now().Add(-time.Hour))
+
+	// The customer still has the first prompt on their phone
+	if _, err := svc.RetryPayment(ctx, 1, payment.ID); !errors.Is(err, mpesa.ErrPromptPending) {
+		t.Errorf("retry while the prompt is open: expected ErrPromptPending, got %v", err)
+	}
+
+	// They paid it before the callback came in
+	query = map[string]string{"ResponseCode": "0", "ResultCode": "0", "ResultDesc": "The service request is processed successfully."}
+	if p, err := svc.RetryPayment(ctx, 1, payment.ID); !errors.Is(err, mpesa.ErrPaymentCompleted) || p.Status != models.MpesaPaymentCompleted {
+		t.Errorf("retry after the customer paid: expected ErrPaymentCompleted, got %v", err)
+	}
+	if prompts != 1 {
+		t.Errorf("retries sent %d more prompts; want none", prompts-1)
+	}
+
+	// Of two retries of the same attempt, only one is claimed
+	failed := &models.MpesaPayment{ShopID: 1, Amount: 60, Phone: "254712345678", CheckoutRequestID: "ws_CO_failed",
+		Status: models.MpesaPaymentFailed, ExpiresAt: time.Now().Add(time.Minute)}
+	paymentRepo.Create(failed)
+	if ok, err := paymentRepo.ClaimRetry(failed.ID, 0, time.Now()); !ok || err != nil {
+		t.Fatalf("first ClaimRetry() = %v, %v; want claimed", ok, err)
+	}
+	if ok, _ := paymentRepo.ClaimRetry(failed.ID, 0, time.Now()); ok {
+		t.Error("second ClaimRetry() of the same attempt claimed it again")
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
+	"gorm.io/gorm"
+)
+
+// mockDarajaInitiator serves OAuth, transaction status and reversal requests,
+// recording each body by endpoint
+type mockDarajaInitiator struct {
+	mu       sync.Mutex
+	requests map[string][]map[string]interface{}
+}
+
+func (m *mockDarajaInitiator) server(t *testing.T) *httptest.Server {
+	m.requests = make(map[string][]map[string]interface{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/v1/generate", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "test-token", "expires_in": "3599"})
+	})
+	for _, endpoint := range []string{"/" + mpesa.StatusEndpoint, "/" + mpesa.ReversalEndpoint} {
+		endpoint := endpoint
+		mux.HandleFunc(endpoint, func(w http.ResponseWriter, r *http.Request) {
+			var body map[string]interface{}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("failed to decode request: %v", err)
+			}
+
+			m.mu.Lock()
+			m.requests[endpoint] = append(m.requests[endpoint], body)
+			n := len(m.requests[endpoint])
+			m.mu.Unlock()
+
+			json.NewEncoder(w).Encode(map[string]string{
+				"ConversationID":           fmt.Sprintf("AG_%s_%d", body["CommandID"], n),
+				"OriginatorConversationID": fmt.Sprintf("10571-%d", n),
+				"ResponseCode":             "0",
+				"ResponseDescription":      "Accept the service request successfully.",
+			})
+		})
+	}
+	return httptest.NewServer(mux)
+}
+
+func (m *mockDarajaInitiator) received(endpoint string) []map[string]interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]map[string]interface{}(nil), m.requests["/"+endpoint]...)
+}
+
+// newInitiatorTestService sets up status queries and reversals for shops 1
+// and 2 on their own shortcodes
+func newInitiatorTestService(t *testing.T, baseURL string) (*mpesa.Service, *gorm.DB) {
+	db := openTestDB(t, &models.MpesaTransaction{}, &models.B2CPayout{}, &models.AuditLog{},
+		&models.Shop{}, &models.IntegrationCredential{})
+
+	svc := mpesa.New(&mpesa.Config{
+		ConsumerKey:       "key",
+		ConsumerSecret:    "secret",
+		Shortcode:         "174379",
+		Passkey:           testPasskey,
+		CallbackToken:     "s3cret",
+		BaseURL:           baseURL,
+		StatusResultURL:   "https://pos.example.com/webhook/mpesa/status",
+		ReversalResultURL: "https://pos.example.com/webhook/mpesa/reversal",
+	}, nil, repository.NewMpesaTransactionRepository(db))
+	svc.SetB2CRepos(repository.NewB2CPayoutRepository(db), repository.NewAuditLogRepository(db))
+	addShopInitiators(t, svc, db)
+
+	return svc, db
+}
+
+func initiatorResultBody(conversationID string, resultCode int, desc string, params map[string]interface{}) []byte {
+	var parameters []map[string]interface{}
+	for key, value := range params {
+		parameters = append(parameters, map[string]interface{}{"Key": key, "Value": value})
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"Result": map[string]interface{}{
+			"ResultType":       0,
+			"ResultCode":       resultCode,
+			"ResultDesc":       desc,
+			"ConversationID":   conversationID,
+			"TransactionID":    "RVS81HAY6Q",
+			"ResultParameters": map[string]interface{}{"ResultParameter": parameters},
+		},
+	})
+	return body
+}
+
+// TestMpesaTransactionStatusByReceipt tests querying a receipt the shop has no callback for
+func TestMpesaTransactionStatusByReceipt(t *testing.T) {
+	daraja := &mockDarajaInitiator{}
+	server := daraja.server(t)
+	defer server.Close()
+
+	svc, _ := newInitiatorTestService(t, server.URL)
+	ctx := context.Background()
+
+	if _, err := svc.TransactionStatusByReceipt(ctx, 1, "not-a-code"); !errors.Is(err, mpesa.ErrInvalidReceipt) {
+		t.Errorf("expected ErrInvalidReceipt, got %v", err)
+	}
+
+	tx, err := svc.TransactionStatusByReceipt(ctx, 1, "qjk4abc123")
+	if err != nil {
+		t.Fatalf("TransactionStatusByReceipt() error: %v", err)
+	}
+	if tx.Status != "unverified" || tx.StatusConversationID != "AG_TransactionStatusQuery_1" {
+		t.Errorf("unexpected transaction: %+v", tx)
+	}
+
+	body := daraja.received(mpesa.StatusEndpoint)[0]
+	expected := map[string]interface{}{
+		"Initiator":      "testapi",
+		"CommandID":      "TransactionStatusQuery",
+		"TransactionID":  "QJK4ABC123",
+		"PartyA":         "600997",
+		"IdentifierType": "4",
+		"ResultURL":      "https://pos.example.com/webhook/mpesa/status?token=s3cret",
+	}
+	for field, want := range expected {
+		if body[field] != want {
+			t.Errorf("%s = %v; want %v", field, body[field], want)
+		}
+	}
+
+	// A second check inside the query interval waits for the pending result
+	if _, err := svc.TransactionStatusByReceipt(ctx, 1, "QJK4ABC123"); err != nil {
+		t.Fatalf("second query failed: %v", err)
+	}
+	if n := len(daraja.received(mpesa.StatusEndpoint)); n != 1 {
+		t.Errorf("expected 1 status request while the first is pending, got %d", n)
+	}
+
+	settled, err := svc.ProcessStatusResult(initiatorResultBody(tx.StatusConversationID, 0, "The service request is processed successfully.", map[string]interface{}{
+		"TransactionStatus": "Completed",
+		"Amount":            250,
+		"DebitPartyName":    "254712345678 - Jane Wanjiku",
+		"CreditPartyName":   "600997 - Shop 1",
+		"FinalisedTime":     "20240215143000",
+		"ReceiptNo":         "QJK4ABC123",
+	}))
+	if err != nil {
+		t.Fatalf("ProcessStatusResult() error: %v", err)
+	}
+	if settled.Status != "completed" || settled.StatusResult != "Completed" || settled.StatusCheckedAt == nil {
+		t.Errorf("transaction not completed: %+v", settled)
+	}
+	if settled.Amount != 250 || settled.Phone != "254712345678" {
+		t.Errorf("amount/phone = %v/%q; want 250/254712345678", settled.Amount, settled.Phone)
+	}
+	if settled.TransactionTime.Format("20060102150405") != "20240215143000" {
+		t.Errorf("TransactionTime = %v", settled.TransactionTime)
+	}
+}
+
+// TestMpesaTransactionStatusOtherShops tests that a shop can't look up or
+// claim payments made to another shop's shortcode, nor query from the
+// platform's without its own initiator
+func TestMpesaTransactionStatusOtherShops(t *testing.T) {
+	daraja := &mockDarajaInitiator{}
+	server := daraja.server(t)
+	defer server.Close()
+
+	svc, db := newInitiatorTestService(t, server.URL)
+	ctx := context.Background()
+
+	db.Create(&models.MpesaTransaction{ShopID: 1, Type: "c2b", Amount: 500, TransactionID: "QJK4ABC123",
+		ReceiptNumber: "QJK4ABC123", TransactionTime: time.Now(), Status: "completed"})
+	if _, err := svc.TransactionStatusByReceipt(ctx, 2, "QJK4ABC123"); !errors.Is(err, mpesa.ErrTransactionNotFound) {
+		t.Errorf("another shop's receipt error = %v; want ErrTransactionNotFound", err)
+	}
+	if _, err := svc.TransactionStatusByReceipt(ctx, 3, "SBX1ABC999"); !errors.Is(err, mpesa.ErrStatusNotConfigured) {
+		t.Errorf("shop without an initiator error = %v; want ErrStatusNotConfigured", err)
+	}
+
+	// Shop 2 claims a receipt M-Pesa says was paid to shop 1
+	tx, err := svc.TransactionStatusByReceipt(ctx, 2, "SBX1ABC999")
+	if err != nil {
+		t.Fatalf("TransactionStatusByReceipt() error: %v", err)
+	}
+	rejected, err := svc.ProcessStatusResult(initiatorResultBody(tx.StatusConversationID, 0, "The service request is processed successfully.", map[string]interface{}{
+		"TransactionStatus": "Completed",
+		"Amount":            9000,
+		"CreditPartyName":   "600997 - Shop 1",
+	}))
+	if !errors.Is(err, mpesa.ErrReceiptNotForShop) {
+		t.Fatalf("ProcessStatusResult() error = %v; want ErrReceiptNotForShop", err)
+	}
+	if rejected.Status != "rejected" || rejected.Amount != 0 || rejected.StatusResult != "" {
+		t.Errorf("transaction = %+v; want rejected without the amount", rejected)
+	}
+}
+
+// TestMpesaReversal tests reversing a received payment and settling it from the result callback
+func TestMpesaReversal(t *testing.T) {
+	daraja := &mockDarajaInitiator{}
+	server := daraja.server(t)
+	defer server.Close()
+
+	svc, db := newInitiatorTestService(t, server.URL)
+	ctx := context.Background()
+
+	received := &models.MpesaTransaction{ShopID: 1, Type: "stk_push", Amount: 1200, Phone: "254712345678",
+		TransactionID: "TX1", ReceiptNumber: "SBK7XYZ901", TransactionTime: time.Now(), Status: "completed"}
+	payout := &models.MpesaTransaction{ShopID: 1, Type: "b2c", Amount: 300, TransactionID: "NLJ41HAY6Q",
+		ReceiptNumber: "NLJ41HAY6Q", TransactionTime: time.Now(), Status: "completed"}
+	db.Create(received)
+	db.Create(payout)
+
+	tests := []struct {
+		req  mpesa.ReversalRequest
+		want error
+	}{
+		{mpesa.ReversalRequest{ShopID: 2, TransactionID: received.ID, Reason: "Wrong till"}, mpesa.ErrTransactionNotFound},
+		{mpesa.ReversalRequest{ShopID: 1, TransactionID: payout.ID, Reason: "Wrong till"}, mpesa.ErrReversalNotAllowed},
+	}
+	for _, tt := range tests {
+		req := tt.req
+		if _, err := svc.Reversal(ctx, &req); !errors.Is(err, tt.want) {
+			t.Errorf("Reversal(%+v) error = %v; want %v", tt.req, err, tt.want)
+		}
+	}
+
+	tx, err := svc.Reversal(ctx, &mpesa.ReversalRequest{ShopID: 1, TransactionID: received.ID, Reason: "Customer paid twice"})
+	if err != nil {
+		t.Fatalf("Reversal() error: %v", err)
+	}
+	if tx.ReversalStatus != models.ReversalPending || tx.ReversalConversationID != "AG_TransactionReversal_1" {
+		t.Errorf("reversal not pending: %+v", tx)
+	}
+
+	body := daraja.received(mpesa.ReversalEndpoint)[0]
+	expected := map[string]interface{}{
+		"Initiator":              "testapi",
+		"CommandID":              "TransactionReversal",
+		"TransactionID":          "SBK7XYZ901",
+		"Amount":                 float64(1200),
+		"ReceiverParty":          "600997",
+		"RecieverIdentifierType": "11",
+		"Remarks":                "Customer paid twice",
+		"ResultURL":              "https://pos.example.com/webhook/mpesa/reversal?token=s3cret",
+	}
+	for field, want := range expected {
+		if body[field] != want {
+			t.Errorf("%s = %v; want %v", field, body[field], want)
+		}
+	}
+
+	if _, err := svc.Reversal(ctx, &mpesa.ReversalRequest{ShopID: 1, TransactionID: received.ID, Reason: "Again"}); !errors.Is(err, mpesa.ErrReversalInProgress) {
+		t.Errorf("expected ErrReversalInProgress, got %v", err)
+	}
+
+	reversed, err := svc.ProcessReversalResult(initiatorResultBody(tx.ReversalConversationID, 0, "The service request is processed successfully.", nil))
+	if err != nil {
+		t.Fatalf("ProcessReversalResult() error: %v", err)
+	}
+	if reversed.Status != "reversed" || reversed.ReversalStatus != models.ReversalCompleted || reversed.ReversalReceipt != "RVS81HAY6Q" {
+		t.Errorf("transaction not reversed: %+v", reversed)
+	}
+
+	if _, err := svc.ProcessReversalResult(initiatorResultBody(tx.ReversalConversationID, 0, "", nil)); !errors.Is(err, mpesa.ErrDuplicateCallback) {
+		t.Errorf("replayed result should be a duplicate, got %v", err)
+	}
+
+	var actions []string
+	db.Model(&models.AuditLog{}).Where("entity_type = ?", "mpesa_transaction").Order("id").Pluck("action", &actions)
+	if len(actions) != 2 || actions[0] != "mpesa_reversal_requested" || actions[1] != "mpesa_reversal_completed" {
+		t.Errorf("audit trail = %v; want [mpesa_reversal_requested mpesa_reversal_completed]", actions)
+	}
+}
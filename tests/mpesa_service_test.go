@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
+)
+
+const (
+	testShortcode = "174379"
+	testPasskey   = "bfb279f9aa9bdbcf158e97dd71a467cd2e0c893059b10f78e6b72e1f3"
+	testTimestamp = "20240215120000"
+)
+
+// TestMpesaServicePassword tests the exact STK password for a known shortcode, passkey and timestamp
+func TestMpesaServicePassword(t *testing.T) {
+	svc := mpesa.New(&mpesa.Config{Shortcode: testShortcode, Passkey: testPasskey}, nil, nil)
+
+	expected := "MTc0Mzc5YmZiMjc5ZjlhYTliZGJjZjE1OGU5N2RkNzFhNDY3Y2QyZTBjODkzMDU5YjEwZjc4ZTZiNzJlMWYzMjAyNDAyMTUxMjAwMDA="
+	if got := svc.GeneratePassword(testTimestamp); got != expected {
+		t.Errorf("GeneratePassword() = %s; want %s", got, expected)
+	}
+}
+
+// TestMpesaServiceBasicAuth tests the OAuth Basic header built from consumer key and secret
+func TestMpesaServiceBasicAuth(t *testing.T) {
+	svc := mpesa.New(&mpesa.Config{ConsumerKey: "key", ConsumerSecret: "secret"}, nil, nil)
+
+	if got := svc.BasicAuthHeader(); got != "Basic a2V5OnNlY3JldA==" {
+		t.Errorf("BasicAuthHeader() = %s; want Basic a2V5OnNlY3JldA==", got)
+	}
+}
+
+// TestMpesaAccessToken tests the token request's Basic header for known
+// inputs, and that blank credentials fail before Daraja is called
+func TestMpesaAccessToken(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		// base64("consumer-key:consumer-secret")
+		if got := r.Header.Get("Authorization"); got != "Basic Y29uc3VtZXIta2V5OmNvbnN1bWVyLXNlY3JldA==" {
+			t.Errorf("oauth Authorization = %q", got)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "test-token", "expires_in": "3599"})
+	}))
+	defer server.Close()
+
+	for _, cfg := range []mpesa.Config{{ConsumerKey: "consumer-key"}, {ConsumerSecret: "consumer-secret"}} {
+		cfg.BaseURL = server.URL
+		if _, err := mpesa.New(&cfg, nil, nil).AccessToken(); err != mpesa.ErrMissingCredentials {
+			t.Errorf("AccessToken() with key %q, secret %q = %v; want ErrMissingCredentials", cfg.ConsumerKey, cfg.ConsumerSecret, err)
+		}
+	}
+	if requests != 0 {
+		t.Fatalf("Daraja called %d times with blank credentials; want 0", requests)
+	}
+
+	svc := mpesa.New(&mpesa.Config{ConsumerKey: "consumer-key", ConsumerSecret: "consumer-secret", BaseURL: server.URL}, nil, nil)
+	for i := 0; i < 2; i++ {
+		token, err := svc.AccessToken()
+		if err != nil || token != "test-token" {
+			t.Fatalf("AccessToken() = %q, %v; want test-token", token, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("token requests = %d; want 1, then cached", requests)
+	}
+}
+
+// TestMpesaSTKPushAgainstMockDaraja tests the OAuth and STK push requests sent to Daraja
+func TestMpesaSTKPushAgainstMockDaraja(t *testing.T) {
+	var stkBody map[string]interface{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/v1/generate", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("oauth method = %s; want GET", r.Method)
+		}
+		if r.URL.Query().Get("grant_type") != "client_credentials" {
+			t.Errorf("oauth grant_type = %q; want client_credentials", r.URL.Query().Get("grant_type"))
+		}
+		if r.Header.Get("Authorization") != "Basic a2V5OnNlY3JldA==" {
+			t.Errorf("oauth Authorization = %q", r.Header.Get("Authorization"))
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "test-token", "expires_in": "3599"})
+	})
+	mux.HandleFunc("/mpesa/stkpush/v1/processrequest", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("stk method = %s; want POST", r.Method)
+		}
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			t.Errorf("stk Authorization = %q; want Bearer test-token", r.Header.Get("Authorization"))
+		}
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("stk Content-Type = %q", r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&stkBody); err != nil {
+			t.Fatalf("failed to decode STK request: %v", err)
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"MerchantRequestID":   "29115-34620561-1",
+			"CheckoutRequestID":   "ws_CO_191220191020363925",
+			"ResponseCode":        "0",
+			"ResponseDescription": "Success. Request accepted for processing",
+			"CustomerMessage":     "Success. Request accepted for processing",
+		})
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	svc := mpesa.New(&mpesa.Config{
+		ConsumerKey:    "key",
+		ConsumerSecret: "secret",
+		Shortcode:      testShortcode,
+		Passkey:        testPasskey,
+		CallbackURL:    "https://pos.example.com/webhook/mpesa/stk",
+		CallbackToken:  "s3cret",
+		BaseURL:        server.URL,
+	}, nil, nil)
+
+	payment, resp, err := svc.InitiateSTKPush(context.Background(), &mpesa.PaymentRequest{
+		Phone:            "0712345678",
+		Amount:           150,
+		AccountReference: "DukaPOS",
+		Description:      "Sugar 2kg",
+		ShopID:           1,
+	})
+	if err != nil {
+		t.Fatalf("InitiateSTKPush() error: %v", err)
+	}
+	if resp.CheckoutRequestID != "ws_CO_191220191020363925" || payment.CheckoutRequestID != resp.CheckoutRequestID {
+		t.Errorf("checkout request ID not recorded: %+v", payment)
+	}
+
+	timestamp, _ := stkBody["Timestamp"].(string)
+	if len(timestamp) != 14 {
+		t.Fatalf("Timestamp = %q; want YYYYMMDDHHMMSS", timestamp)
+	}
+
+	expected := map[string]interface{}{
+		"BusinessShortCode": testShortcode,
+		"Password":          svc.GeneratePassword(timestamp),
+		"TransactionType":   "CustomerPayBillOnline",
+		"Amount":            float64(150),
+		"PartyA":            "254712345678",
+		"PartyB":            testShortcode,
+		"PhoneNumber":       "254712345678",
+		"CallBackURL":       "https://pos.example.com/webhook/mpesa/stk?token=s3cret",
+		"AccountReference":  "DukaPOS",
+		"TransactionDesc":   "Sugar 2kg",
+	}
+	for field, want := range expected {
+		if stkBody[field] != want {
+			t.Errorf("%s = %v; want %v", field, stkBody[field], want)
+		}
+	}
+	if len(stkBody) != len(expected)+1 {
+		t.Errorf("STK request has %d fields; want %d", len(stkBody), len(expected)+1)
+	}
+}
+
+// TestMpesaShopAccountReference tests that the shop ID survives the round trip through AccountReference
+func TestMpesaShopAccountReference(t *testing.T) {
+	tests := []struct {
+		shopID   uint
+		ref      string
+		expected string
+	}{
+		{42, "", "DUKA42"},
+		{42, "INV7", "DUKA42-INV7"},
+		{42, "254712345678", "DUKA42-254712345678"},
+		{42, "DUKA42-INV7", "DUKA42-INV7"},
+		{7, "DUKA42", "DUKA7-DUKA42"},
+	}
+
+	for _, tt := range tests {
+		got := mpesa.ShopAccountReference(tt.shopID, tt.ref)
+		if got != tt.expected {
+			t.Errorf("ShopAccountReference(%d, %q) = %q; want %q", tt.shopID, tt.ref, got, tt.expected)
+		}
+
+		shopID, ok := mpesa.ParseShopAccountReference(got)
+		if !ok || shopID != tt.shopID {
+			t.Errorf("ParseShopAccountReference(%q) = %d, %v; want %d", got, shopID, ok, tt.shopID)
+		}
+	}
+
+	if _, ok := mpesa.ParseShopAccountReference("INV-2024"); ok {
+		t.Error("references without the shop prefix should not resolve to a shop")
+	}
+}
+
+// TestMpesaSTKAccountReference tests that pushes to the platform shortcode
+// carry the shop prefix while its paybill confirmations come to us, and
+// that a reference too long for Daraja with the prefix is refused, not cut
+func TestMpesaSTKAccountReference(t *testing.T) {
+	daraja := &rateLimitedDaraja{}
+	server := daraja.server(t)
+	defer server.Close()
+
+	db := openTestDB(t, &models.MpesaPayment{}, &models.MpesaPaymentAttempt{}, &models.MpesaTransaction{})
+	paymentRepo := repository.NewMpesaPaymentRepository(db)
+	svc := mpesa.New(&mpesa.Config{
+		ConsumerKey:        "key",
+		ConsumerSecret:     "secret",
+		Shortcode:          testShortcode,
+		Passkey:            testPasskey,
+		BaseURL:            server.URL,
+		C2BConfirmationURL: "https://pos.example.com/webhook/mpesa/c2b/confirmation",
+	}, paymentRepo, repository.NewMpesaTransactionRepository(db))
+
+	payment, _, err := svc.InitiateSTKPush(context.Background(), &mpesa.PaymentRequest{
+		Phone: "0712345678", Amount: 100, AccountReference: "INV7", ShopID: 42,
+	})
+	if err != nil {
+		t.Fatalf("InitiateSTKPush() error: %v", err)
+	}
+	if payment.AccountReference != "DUKA42-INV7" {
+		t.Errorf("AccountReference = %q; want DUKA42-INV7", payment.AccountReference)
+	}
+
+	_, _, err = svc.InitiateSTKPush(context.Background(), &mpesa.PaymentRequest{
+		Phone: "0712345678", Amount: 200, AccountReference: "payroll_jan", ShopID: 42,
+	})
+	if !errors.Is(err, mpesa.ErrAccountReferenceTooLong) || !strings.Contains(err.Error(), "room for 5 characters") {
+		t.Errorf("InitiateSTKPush() with a long reference error = %v; want ErrAccountReferenceTooLong with the room left", err)
+	}
+	var payments int64
+	db.Model(&models.MpesaPayment{}).Count(&payments)
+	if payments != 1 {
+		t.Errorf("recorded %d payments; want none for the refused push", payments-1)
+	}
+	if got := daraja.count("/mpesa/stkpush/v1/processrequest"); got != 1 {
+		t.Errorf("STK requests = %d; want none for the refused push", got-1)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	mpesahandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/mpesa"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/routes"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/encryption"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestMpesaShopCredentials tests that a shop's own credentials are stored
+// encrypted, used in place of the platform shortcode, and can be removed
+func TestMpesaShopCredentials(t *testing.T) {
+	db := openTestDB(t, &models.IntegrationCredential{})
+
+	encryptor, err := encryption.NewEncryptionServiceWithKey(bytes.Repeat([]byte("k"), encryption.KeySize))
+	if err != nil {
+		t.Fatalf("NewEncryptionServiceWithKey() error: %v", err)
+	}
+	svc := mpesa.New(&mpesa.Config{
+		ConsumerKey:    "platform-key",
+		ConsumerSecret: "platform-secret",
+		Shortcode:      testShortcode,
+		Passkey:        testPasskey,
+	}, nil, nil)
+	svc.SetCredentialStore(repository.NewIntegrationCredentialRepository(db), encryptor)
+
+	if shortcode, own := svc.ShopShortcode(1); shortcode != testShortcode || own {
+		t.Errorf("ShopShortcode() before saving = %q, %v; want the platform shortcode", shortcode, own)
+	}
+
+	if err := svc.SaveShopCredentials(1, &mpesa.ShopCredentials{ConsumerKey: "key"}); err == nil {
+		t.Error("SaveShopCredentials() accepted incomplete credentials")
+	}
+	err = svc.SaveShopCredentials(1, &mpesa.ShopCredentials{
+		ConsumerKey:    "shop-key",
+		ConsumerSecret: "shop-secret",
+		Shortcode:      "600111",
+		Passkey:        "shop-passkey",
+	})
+	if err != nil {
+		t.Fatalf("SaveShopCredentials() error: %v", err)
+	}
+
+	var stored models.IntegrationCredential
+	db.First(&stored)
+	if bytes.Contains([]byte(stored.Secrets), []byte("shop-secret")) {
+		t.Error("credentials stored in plain text")
+	}
+	if shortcode, own := svc.ShopShortcode(1); shortcode != "600111" || !own {
+		t.Errorf("ShopShortcode() = %q, %v; want the shop's own 600111", shortcode, own)
+	}
+	if shortcode, own := svc.ShopShortcode(2); shortcode != testShortcode || own {
+		t.Errorf("other shop ShopShortcode() = %q, %v; want the platform shortcode", shortcode, own)
+	}
+
+	if err := svc.DeleteShopCredentials(1); err != nil {
+		t.Fatalf("DeleteShopCredentials() error: %v", err)
+	}
+	if shortcode, own := svc.ShopShortcode(1); shortcode != testShortcode || own {
+		t.Errorf("ShopShortcode() after delete = %q, %v; want the platform shortcode", shortcode, own)
+	}
+}
+
+// TestMpesaShopCredentialsOwnerOnly tests that only the shop owner can save
+// or remove the shop's credentials, not requests signed in with an API key
+func TestMpesaShopCredentialsOwnerOnly(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.IntegrationCredential{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanPro, IsActive: true}
+	db.Create(shop)
+
+	encryptor, err := encryption.NewEncryptionServiceWithKey(bytes.Repeat([]byte("k"), encryption.KeySize))
+	if err != nil {
+		t.Fatalf("NewEncryptionServiceWithKey() error: %v", err)
+	}
+	svc := mpesa.New(&mpesa.Config{
+		ConsumerKey:    "platform-key",
+		ConsumerSecret: "platform-secret",
+		Shortcode:      testShortcode,
+		Passkey:        testPasskey,
+	}, nil, nil)
+	svc.SetCredentialStore(repository.NewIntegrationCredentialRepository(db), encryptor)
+	cfg := routes.RouteConfig{
+		MpesaHandler: mpesahandler.New(svc, repository.NewShopRepository(db), nil, nil, nil, nil),
+	}
+
+	send := func(app *fiber.App, method string) int {
+		req := httptest.NewRequest(method, "/api/v1/mpesa/credentials", strings.NewReader(
+			`{"consumer_key":"shop-key","consumer_secret":"shop-secret","shortcode":"600111","passkey":"shop-passkey"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s /api/v1/mpesa/credentials error: %v", method, err)
+		}
+		return resp.StatusCode
+	}
+
+	withKey := serverApp(t, db, cfg, shop, func(c *fiber.Ctx) error {
+		c.Locals("api_key", &models.APIKey{ShopID: shop.ID})
+		return c.Next()
+	})
+	if status := send(withKey, "PUT"); status != fiber.StatusForbidden {
+		t.Errorf("PUT with an API key status = %d; want 403", status)
+	}
+	if shortcode, own := svc.ShopShortcode(shop.ID); own {
+		t.Errorf("PUT with an API key saved shortcode %q", shortcode)
+	}
+
+	owner := serverApp(t, db, cfg, shop)
+	if status := send(owner, "PUT"); status != fiber.StatusOK {
+		t.Fatalf("owner PUT status = %d; want 200", status)
+	}
+	if status := send(withKey, "DELETE"); status != fiber.StatusForbidden {
+		t.Errorf("DELETE with an API key status = %d; want 403", status)
+	}
+	if shortcode, own := svc.ShopShortcode(shop.ID); shortcode != "600111" || !own {
+		t.Errorf("ShopShortcode() after the API key DELETE = %q, %v; want the shop's own 600111", shortcode, own)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
+)
+
+// TestMpesaPollPendingPayments tests that STK pushes whose callback never
+// arrives are settled by status queries, within the check limit, and that
+// a late callback only fills in the receipt
+func TestMpesaPollPendingPayments(t *testing.T) {
+	var mu sync.Mutex
+	prompts := 0
+	queries := map[string]int{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/v1/generate", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "test-token", "expires_in": "3599"})
+	})
+	mux.HandleFunc("/mpesa/stkpush/v1/processrequest", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		prompts++
+		n := prompts
+		mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]string{
+			"MerchantRequestID": fmt.Sprintf("mr_%d", n),
+			"CheckoutRequestID": fmt.Sprintf("ws_CO_%d", n),
+			"ResponseCode":      "0",
+		})
+	})
+	mux.HandleFunc("/mpesa/stkpushquery/v1/query", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		checkoutID := body["CheckoutRequestID"]
+
+		mu.Lock()
+		queries[checkoutID]++
+		n := queries[checkoutID]
+		mu.Unlock()
+
+		switch {
+		case checkoutID == "ws_CO_1" && n > 1:
+			json.NewEncoder(w).Encode(map[string]string{
+				"ResponseCode": "0", "ResultCode": "0", "ResultDesc": "The service request is processed successfully.",
+			})
+		case checkoutID == "ws_CO_2":
+			json.NewEncoder(w).Encode(map[string]string{
+				"ResponseCode": "0", "ResultCode": "1032", "ResultDesc": "Request cancelled by user",
+			})
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"errorCode": "500.001.1001", "errorMessage": "The transaction is being processed",
+			})
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	db := openTestDB(t, &models.MpesaPayment{}, &models.MpesaPaymentAttempt{}, &models.MpesaTransaction{})
+	paymentRepo := repository.NewMpesaPaymentRepository(db)
+	svc := mpesa.New(&mpesa.Config{
+		ConsumerKey:    "key",
+		ConsumerSecret: "secret",
+		Shortcode:      testShortcode,
+		Passkey:        testPasskey,
+		BaseURL:        server.URL,
+	}, paymentRepo, repository.NewMpesaTransactionRepository(db))
+
+	var payments []*models.MpesaPayment
+	for i, phone := range []string{"0712345678", "0723456789", "0734567890"} {
+		payment, _, err := svc.InitiateSTKPush(context.Background(), &mpesa.PaymentRequest{
+			Phone: phone, Amount: float64(100 * (i + 1)), AccountReference: fmt.Sprintf("INV%d", i+1), ShopID: 1,
+		})
+		if err != nil {
+			t.Fatalf("InitiateSTKPush(%s) error: %v", phone, err)
+		}
+		payments = append(payments, payment)
+	}
+
+	queried := func(checkoutID string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return queries[checkoutID]
+	}
+	backdate := func(d time.Duration) {
+		past := time.Now().Add(-d)
+		db.Model(&models.MpesaPayment{}).Where("1 = 1").Updates(map[string]interface{}{
+			"created_at": past, "last_attempt_at": past,
+		})
+		db.Model(&models.MpesaPayment{}).Where("last_status_check_at IS NOT NULL").Update("last_status_check_at", past)
+	}
+
+	// Callbacks usually arrive within seconds, so fresh prompts are left alone
+	if err := svc.PollPendingPayments(); err != nil {
+		t.Fatalf("PollPendingPayments() error: %v", err)
+	}
+	if queried("ws_CO_1") != 0 {
+		t.Fatalf("fresh prompt queried %d times; want 0", queried("ws_CO_1"))
+	}
+
+	// The third payment has used up its checks
+	db.Model(&models.MpesaPayment{}).Where("id = ?", payments[2].ID).Update("status_checks", mpesa.StatusCheckLimit)
+
+	backdate(time.Minute)
+	if err := svc.PollPendingPayments(); err != nil {
+		t.Fatalf("PollPendingPayments() error: %v", err)
+	}
+
+	first, _ := paymentRepo.GetByID(payments[0].ID)
+	if first.Status != models.MpesaPaymentPending || first.StatusChecks != 1 {
+		t.Errorf("still processing: status = %s, checks = %d; want pending after 1 check", first.Status, first.StatusChecks)
+	}
+	second, _ := paymentRepo.GetByID(payments[1].ID)
+	if second.Status != models.MpesaPaymentFailed || second.FailureReason != "Request cancelled by user" {
+		t.Errorf("cancelled: status = %s (%q); want failed", second.Status, second.FailureReason)
+	}
+	if queried("ws_CO_3") != 0 {
+		t.Errorf("payment past the check limit queried %d times; want 0", queried("ws_CO_3"))
+	}
+
+	// Not due again until the delay has passed since the last check
+	if err := svc.PollPendingPayments(); err != nil {
+		t.Fatalf("PollPendingPayments() error: %v", err)
+	}
+	if queried("ws_CO_1") != 1 {
+		t.Errorf("queried %d times right after a check; want 1", queried("ws_CO_1"))
+	}
+
+	backdate(time.Minute)
+	if err := svc.PollPendingPayments(); err != nil {
+		t.Fatalf("PollPendingPayments() error: %v", err)
+	}
+	first, _ = paymentRepo.GetByID(payments[0].ID)
+	if first.Status != models.MpesaPaymentCompleted || first.CompletedAt == nil {
+		t.Fatalf("paid: status = %s; want completed", first.Status)
+	}
+	if queried("ws_CO_2") != 1 {
+		t.Errorf("failed payment queried %d times; want 1", queried("ws_CO_2"))
+	}
+
+	var transactions int64
+	db.Model(&models.MpesaTransaction{}).Where("type = ?", "stk_push").Count(&transactions)
+	if transactions != 1 {
+		t.Errorf("stk_push transactions = %d; want 1", transactions)
+	}
+
+	// The callback arrives late; it can't settle the payment twice but
+	// supplies the receipt the query couldn't
+	late := []byte(`{"Body":{"stkCallback":{"MerchantRequestID":"mr_1","CheckoutRequestID":"ws_CO_1","ResultCode":0,
+		"ResultDesc":"The service request is processed successfully.","CallbackMetadata":{"Item":[
+		{"Name":"Amount","Value":"100"},{"Name":"MpesaReceiptNumber","Value":"QGH7XYZ123"}]}}}}`)
+	if _, err := svc.ProcessSTKCallback(late); !errors.Is(err, mpesa.ErrDuplicateCallback) {
+		t.Errorf("late callback: expected ErrDuplicateCallback, got %v", err)
+	}
+	first, _ = paymentRepo.GetByID(payments[0].ID)
+	if first.MpesaReceipt != "QGH7XYZ123" {
+		t.Errorf("receipt = %q; want QGH7XYZ123 from the late callback", first.MpesaReceipt)
+	}
+	db.Model(&models.MpesaTransaction{}).Where("type = ?", "stk_push").Count(&transactions)
+	if transactions != 1 {
+		t.Errorf("stk_push transactions after late callback = %d; want 1", transactions)
+	}
+
+	// Expired payments are left to ProcessExpiredPayments
+	db.Model(&models.MpesaPayment{}).Where("id = ?", payments[2].ID).
+		Updates(map[string]interface{}{"status_checks": 0, "expires_at": time.Now().Add(-time.Second)})
+	if err := svc.PollPendingPayments(); err != nil {
+		t.Fatalf("PollPendingPayments() error: %v", err)
+	}
+	if queried("ws_CO_3") != 0 {
+		t.Errorf("expired payment queried %d times; want 0", queried("ws_CO_3"))
+	}
+}
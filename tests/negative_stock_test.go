@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/handlers"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/export"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestNegativeStockMode tests that sales past the stock on record are
+// refused by default, and recorded with a warning once the shop allows
+// negative stock
+func TestNegativeStockMode(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.ProductAlias{}, &models.FavoriteProduct{},
+		&models.StockMovement{}, &models.Sale{}, &models.DailySummary{}, &models.AuditLog{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	db.Create(shop)
+
+	productRepo := repository.NewProductRepository(db)
+	milk := &models.Product{ShopID: shop.ID, Name: "Milk", SellingPrice: 60, CostPrice: 45, CurrentStock: 3, Unit: "packet", IsActive: true}
+	productRepo.Create(milk)
+	bread := &models.Product{ShopID: shop.ID, Name: "Bread", SellingPrice: 65, CostPrice: 50, CurrentStock: 4, Unit: "loaf", IsActive: true}
+	productRepo.Create(bread)
+
+	shopRepo := repository.NewShopRepository(db)
+	saleRepo := repository.NewSaleRepository(db)
+	cmdHandler := services.NewCommandHandler(db, shopRepo, productRepo, saleRepo,
+		repository.NewDailySummaryRepository(db), repository.NewAuditLogRepository(db))
+	parser := services.NewCommandParser(nil, nil)
+	run := func(text string) string {
+		t.Helper()
+		reply, err := cmdHandler.Handle(shop.Phone, parser.Parse(text))
+		if err != nil {
+			t.Fatalf("%q error: %v", text, err)
+		}
+		return reply
+	}
+	stockOf := func(p *models.Product) int {
+		t.Helper()
+		got, _ := productRepo.GetByID(p.ID)
+		return got.CurrentStock
+	}
+
+	saleHandler := handlers.NewSaleHandler(saleRepo, productRepo)
+	saleHandler.SetShopRepo(shopRepo)
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("shop_id", shop.ID)
+		return c.Next()
+	})
+	app.Post("/sales", saleHandler.CreateSale)
+	createSale := func(productID uint, qty int) (int, map[string]interface{}) {
+		t.Helper()
+		r := httptest.NewRequest("POST", "/sales", strings.NewReader(fmt.Sprintf(`{"product_id": %d, "quantity": %d}`, productID, qty)))
+		r.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(r)
+		if err != nil {
+			t.Fatalf("POST /sales: %v", err)
+		}
+		var out map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	// Strict by default
+	if reply := run("sell milk 5"); !strings.Contains(reply, "Not enough stock") {
+		t.Errorf("sell past stock in strict mode = %q", reply)
+	}
+	if status, _ := createSale(bread.ID, 6); status != fiber.StatusBadRequest {
+		t.Errorf("API sale past stock in strict mode: status %d; want 400", status)
+	}
+	if stockOf(milk) != 3 || stockOf(bread) != 4 {
+		t.Errorf("strict mode changed stock: milk %d, bread %d", stockOf(milk), stockOf(bread))
+	}
+
+	if reply := run("set negative maybe"); !strings.Contains(reply, "set negative [on|off]") {
+		t.Errorf("set negative maybe = %q; want usage", reply)
+	}
+	if reply := run("set negative on"); !strings.Contains(reply, "now recorded") {
+		t.Errorf("set negative on = %q", reply)
+	}
+
+	reply := run("sell milk 5")
+	if !strings.Contains(reply, "SOLD") || !strings.Contains(reply, "Milk is now at -2") || strings.Contains(reply, "LOW STOCK") {
+		t.Errorf("sell past stock with negative stock allowed = %q; want the sale with a warning", reply)
+	}
+	if got := stockOf(milk); got != -2 {
+		t.Errorf("milk stock %d; want -2", got)
+	}
+	var movement models.StockMovement
+	db.Where("product_id = ? AND type = ?", milk.ID, models.StockMovementSale).Last(&movement)
+	if movement.Quantity != -5 || movement.BalanceAfter != -2 {
+		t.Errorf("sale movement %d to %d; want -5 to -2", movement.Quantity, movement.BalanceAfter)
+	}
+
+	status, out := createSale(bread.ID, 6)
+	if status != fiber.StatusCreated || !strings.Contains(fmt.Sprint(out["warning"]), "Bread is now at -2") || out["id"] == nil {
+		t.Errorf("API sale past stock = %d %v; want the sale with a warning", status, out)
+	}
+	if status, out := createSale(bread.ID, 1); status != fiber.StatusCreated || !strings.Contains(fmt.Sprint(out["warning"]), "Bread is now at -3") {
+		t.Errorf("API sale with stock already negative = %d %v; want another warning", status, out["warning"])
+	}
+
+	// Negative stock is flagged for restocking but not valued
+	if reply := run("low"); !strings.Contains(reply, "Milk: -2 packet (sold past zero, add stock)") {
+		t.Errorf("low = %q; want milk flagged as sold past zero", reply)
+	}
+	if reply := run("stock"); !strings.Contains(reply, "Total Value: KSh 0") {
+		t.Errorf("stock = %q; want negative stock valued at nothing", reply)
+	}
+	products, _ := productRepo.GetByShopID(shop.ID)
+	if data := export.NewInventoryData(products, nil, "today"); data.TotalStockValue != 0 || data.TotalCostValue != 0 {
+		t.Errorf("inventory value %.0f at cost %.0f; want 0", data.TotalStockValue, data.TotalCostValue)
+	}
+
+	// Restocking brings it back up from below zero
+	run("add milk 60 10")
+	if got := stockOf(milk); got != 8 {
+		t.Errorf("milk stock after adding 10 = %d; want 8", got)
+	}
+
+	run("set negative off")
+	if reply := run("sell bread 1"); !strings.Contains(reply, "OUT OF STOCK") {
+		t.Errorf("sell with negative stock after turning it off = %q; want out of stock", reply)
+	}
+	if status, _ := createSale(bread.ID, 1); status != fiber.StatusBadRequest {
+		t.Errorf("API sale after turning negative stock off: status %d; want 400", status)
+	}
+}
@@ -1,7 +1,25 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/config"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/handlers"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/otp"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 // TestOTPCodeGeneration tests OTP code generation
@@ -177,3 +195,240 @@ func TestPhoneMasking(t *testing.T) {
 		})
 	}
 }
+
+var otpCodePattern = regexp.MustCompile(`\b\d{6}\b`)
+
+// sentCodes records the codes an OTP service delivers, by destination
+type sentCodes map[string]string
+
+func (s sentCodes) send(to, message string) error {
+	s[to] = otpCodePattern.FindString(message)
+	return nil
+}
+
+// allowResend backdates sent codes past the resend cooldown
+func allowResend(db *gorm.DB) {
+	db.Model(&models.OtpCode{}).Where("1 = 1").Update("created_at", time.Now().Add(-2*time.Minute))
+}
+
+// TestOTPServiceStorage tests stored, hashed, single-use codes with attempt limits and resend throttling
+func TestOTPServiceStorage(t *testing.T) {
+	db := openTestDB(t, &models.OtpCode{}, &models.Shop{})
+	svc := otp.NewOTPService(db, &config.Config{JWTSecret: "test-secret"})
+
+	if _, err := svc.Send("254712345678", otp.PurposeLogin); !errors.Is(err, otp.ErrOTPNoChannel) {
+		t.Fatalf("Send() without senders error = %v; want ErrOTPNoChannel", err)
+	}
+
+	sent := sentCodes{}
+	svc.SetSMSSender(sent.send)
+
+	record, err := svc.Send("254712345678", otp.PurposeLogin)
+	if err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+	code := sent["254712345678"]
+	if len(code) != 6 || record.Channel != "sms" {
+		t.Fatalf("sent code %q by %s; want 6 digits by sms", code, record.Channel)
+	}
+	var stored models.OtpCode
+	db.First(&stored, record.ID)
+	if stored.CodeHash == "" || stored.CodeHash == code {
+		t.Errorf("stored hash = %q; want a hash, not the code", stored.CodeHash)
+	}
+
+	if _, err := svc.Send("254712345678", otp.PurposeLogin); !errors.Is(err, otp.ErrOTPThrottled) {
+		t.Errorf("immediate resend error = %v; want ErrOTPThrottled", err)
+	}
+
+	if err := svc.Check("254712345678", otp.PurposePasswordReset, code); !errors.Is(err, otp.ErrOTPNotFound) {
+		t.Errorf("Check() for another purpose error = %v; want ErrOTPNotFound", err)
+	}
+	if err := svc.Check("254712345678", otp.PurposeLogin, "000000x"); !errors.Is(err, otp.ErrOTPInvalid) {
+		t.Errorf("Check() wrong code error = %v; want ErrOTPInvalid", err)
+	}
+	if err := svc.Check("254712345678", otp.PurposeLogin, code); err != nil {
+		t.Fatalf("Check() error: %v", err)
+	}
+	if err := svc.Check("254712345678", otp.PurposeLogin, code); !errors.Is(err, otp.ErrOTPNotFound) {
+		t.Errorf("reused code error = %v; want ErrOTPNotFound", err)
+	}
+
+	allowResend(db)
+	if _, err := svc.Send("254712345678", otp.PurposeLogin); err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+	code = sent["254712345678"]
+	for i := 1; i <= otp.MaxAttempts; i++ {
+		err := svc.Check("254712345678", otp.PurposeLogin, "wrong")
+		if i < otp.MaxAttempts && !errors.Is(err, otp.ErrOTPInvalid) {
+			t.Errorf("attempt %d error = %v; want ErrOTPInvalid", i, err)
+		}
+		if i == otp.MaxAttempts && !errors.Is(err, otp.ErrOTPTooManyAttempts) {
+			t.Errorf("attempt %d error = %v; want ErrOTPTooManyAttempts", i, err)
+		}
+	}
+	if err := svc.Check("254712345678", otp.PurposeLogin, code); !errors.Is(err, otp.ErrOTPNotFound) {
+		t.Errorf("code after too many attempts error = %v; want ErrOTPNotFound", err)
+	}
+
+	allowResend(db)
+	if _, err := svc.Send("254712345678", otp.PurposeLogin); err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+	db.Model(&models.OtpCode{}).Where("used_at IS NULL").Update("expires_at", time.Now().Add(-time.Second))
+	if err := svc.Check("254712345678", otp.PurposeLogin, sent["254712345678"]); !errors.Is(err, otp.ErrOTPExpired) {
+		t.Errorf("expired code error = %v; want ErrOTPExpired", err)
+	}
+
+	for i := 0; i < otp.MaxRateLimit; i++ {
+		allowResend(db)
+		svc.Send("254700000001", otp.PurposeLogin)
+	}
+	db.Model(&models.OtpCode{}).Where("destination = ?", "254700000001").Update("created_at", time.Now().Add(-5*time.Minute))
+	if _, err := svc.Send("254700000001", otp.PurposeLogin); !errors.Is(err, otp.ErrOTPThrottled) {
+		t.Errorf("send over the window limit error = %v; want ErrOTPThrottled", err)
+	}
+}
+
+// TestOTPConcurrentLimits tests that parallel guesses can't get past the
+// attempt limit and parallel requests can't get past the resend throttle
+func TestOTPConcurrentLimits(t *testing.T) {
+	db := openTestDB(t, &models.OtpCode{}, &models.Shop{})
+	// One connection interleaves the requests' statements without sqlite's
+	// locking errors getting in the way
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	svc := otp.NewOTPService(db, &config.Config{JWTSecret: "test-secret"})
+	var sent int32
+	svc.SetSMSSender(func(to, message string) error {
+		atomic.AddInt32(&sent, 1)
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			svc.Send("254712345678", otp.PurposeLogin)
+		}()
+	}
+	wg.Wait()
+	var stored int64
+	db.Model(&models.OtpCode{}).Count(&stored)
+	if sent != 1 || stored != 1 {
+		t.Fatalf("parallel sends delivered %d codes and stored %d; want 1", sent, stored)
+	}
+
+	var invalid int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := svc.Check("254712345678", otp.PurposeLogin, "wrong"); errors.Is(err, otp.ErrOTPInvalid) {
+				atomic.AddInt32(&invalid, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	var code models.OtpCode
+	db.First(&code)
+	if code.Attempts > otp.MaxAttempts || invalid > otp.MaxAttempts-1 {
+		t.Errorf("attempts = %d with %d guesses allowed to retry; want at most %d and %d", code.Attempts, invalid, otp.MaxAttempts, otp.MaxAttempts-1)
+	}
+	if code.Attempts == otp.MaxAttempts && code.UsedAt == nil {
+		t.Error("code out of attempts was not spent")
+	}
+}
+
+// TestPasswordResetWithOTP tests the login and password reset flows sharing one-time codes
+func TestPasswordResetWithOTP(t *testing.T) {
+	db := openTestDB(t, &models.OtpCode{}, &models.Shop{})
+	cfg := &config.Config{JWTSecret: "test-secret", JWTExpiryHrs: 24}
+	auth := services.NewAuthService(repository.NewShopRepository(db), cfg)
+
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Email: "mama@example.com"}
+	if err := auth.Register(shop, "old-password"); err != nil {
+		t.Fatalf("Register() error: %v", err)
+	}
+
+	if err := auth.SendOTP(shop.Phone, otp.PurposePasswordReset); !errors.Is(err, services.ErrOTPUnavailable) {
+		t.Errorf("SendOTP() without OTP service error = %v; want ErrOTPUnavailable", err)
+	}
+
+	svc := otp.NewOTPService(db, cfg)
+	sms, mail := sentCodes{}, sentCodes{}
+	svc.SetSMSSender(sms.send)
+	svc.SetEmailSender(func(to, subject, body string) error { return mail.send(to, body) })
+	auth.SetOTPService(svc)
+
+	if err := auth.SendOTP("+254799999999", otp.PurposePasswordReset); !errors.Is(err, services.ErrInvalidCredentials) {
+		t.Errorf("SendOTP() unknown phone error = %v; want ErrInvalidCredentials", err)
+	}
+	if err := auth.SendOTP(shop.Phone, otp.PurposePayment); !errors.Is(err, services.ErrInvalidOTPPurpose) {
+		t.Errorf("SendOTP() payment purpose error = %v; want ErrInvalidOTPPurpose", err)
+	}
+
+	if err := auth.SendOTP(shop.Email, otp.PurposePasswordReset); err != nil {
+		t.Fatalf("SendOTP() by email error: %v", err)
+	}
+	if sms[shop.Phone] != "" || mail[shop.Email] == "" {
+		t.Fatalf("email reset sent sms %v, email %v; want email only", sms, mail)
+	}
+	if _, _, err := auth.LoginWithOTP(shop.Email, mail[shop.Email]); !errors.Is(err, otp.ErrOTPNotFound) {
+		t.Errorf("LoginWithOTP() with a reset code error = %v; want ErrOTPNotFound", err)
+	}
+	if err := auth.ResetPasswordWithOTP(shop.Email, mail[shop.Email], "new-password"); err != nil {
+		t.Fatalf("ResetPasswordWithOTP() error: %v", err)
+	}
+	if _, _, _, err := auth.Login(shop.Phone, "new-password"); err != nil {
+		t.Errorf("Login() with new password error: %v", err)
+	}
+	if err := auth.ResetPasswordWithOTP(shop.Email, mail[shop.Email], "another-password"); !errors.Is(err, otp.ErrOTPNotFound) {
+		t.Errorf("reused reset code error = %v; want ErrOTPNotFound", err)
+	}
+
+	if err := auth.SendOTP(shop.Phone, otp.PurposeLogin); err != nil {
+		t.Fatalf("SendOTP() login error: %v", err)
+	}
+	loggedIn, token, err := auth.LoginWithOTP(shop.Phone, sms[shop.Phone])
+	if err != nil || token == "" || loggedIn.ID != shop.ID {
+		t.Errorf("LoginWithOTP() = %v, %q, %v; want shop %d with a token", loggedIn, token, err, shop.ID)
+	}
+}
+
+// TestSendOTPHidesRegisteredNumbers tests that a throttled code request for a
+// registered number gets the same reply as one for an unknown number
+func TestSendOTPHidesRegisteredNumbers(t *testing.T) {
+	db := openTestDB(t, &models.OtpCode{}, &models.Shop{})
+	cfg := &config.Config{JWTSecret: "test-secret", JWTExpiryHrs: 24}
+	auth := services.NewAuthService(repository.NewShopRepository(db), cfg)
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678"}
+	if err := auth.Register(shop, "secret123"); err != nil {
+		t.Fatalf("Register() error: %v", err)
+	}
+	svc := otp.NewOTPService(db, cfg)
+	svc.SetSMSSender(sentCodes{}.send)
+	auth.SetOTPService(svc)
+
+	app := fiber.New()
+	app.Post("/auth/otp/send", handlers.NewAuthHandler(auth).SendOTP)
+	send := func(phone string) (int, string) {
+		req := httptest.NewRequest("POST", "/auth/otp/send", strings.NewReader(fmt.Sprintf(`{"phone": %q}`, phone)))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("POST: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	unknownStatus, unknownBody := send("+254799999999")
+	for i := 0; i < 2; i++ {
+		if status, body := send(shop.Phone); status != unknownStatus || body != unknownBody {
+			t.Errorf("request %d for a registered number = %d %s; want %d %s like an unknown one", i+1, status, body, unknownStatus, unknownBody)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/outbox"
+)
+
+// TestOutbox tests that queued messages are sent by the workers, failures
+// are retried with backoff and a message that keeps failing goes dead
+func TestOutbox(t *testing.T) {
+	db := openTestDB(t, &models.OutboxMessage{})
+	repo := repository.NewOutboxRepository(db)
+	svc := outbox.New(repo, 2)
+
+	var mu sync.Mutex
+	sent := map[string]int{}
+	svc.SetSender(models.OutboxChannelWhatsApp, func(m *models.OutboxMessage) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if m.Recipient == "+254700000000" {
+			return errors.New("twilio: 503 service unavailable")
+		}
+		sent[m.Recipient]++
+		return nil
+	})
+
+	for _, phone := range []string{"+254712345678", "+254723456789", "+254700000000"} {
+		if err := svc.SendWhatsApp(phone, "📊 DAILY REPORT"); err != nil {
+			t.Fatalf("SendWhatsApp(%s) error: %v", phone, err)
+		}
+	}
+	if err := svc.SendEmail("owner@example.com", "Report", "body"); err != nil {
+		t.Fatalf("SendEmail() error: %v", err)
+	}
+	if err := svc.Enqueue("pigeon", "roof", "", "coo"); err == nil {
+		t.Error("Enqueue accepted an unknown channel")
+	}
+
+	n, err := svc.ProcessDue()
+	if err != nil || n != 2 {
+		t.Fatalf("ProcessDue() = %d, %v; want 2 sent", n, err)
+	}
+	if sent["+254712345678"] != 1 || sent["+254723456789"] != 1 {
+		t.Errorf("sent = %v; want each shop once", sent)
+	}
+
+	var failing models.OutboxMessage
+	db.Where("recipient = ?", "+254700000000").First(&failing)
+	if failing.Status != models.OutboxStatusPending || failing.Attempts != 1 || failing.LastError == "" {
+		t.Fatalf("after a failure: %+v; want pending with the error", failing)
+	}
+	if wait := time.Until(failing.NextAttemptAt); wait < 25*time.Second || wait > outbox.BaseBackoff {
+		t.Errorf("retry in %s; want about %s", wait, outbox.BaseBackoff)
+	}
+
+	// Nothing is due until the backoff has passed, and sent messages stay sent
+	if n, _ := svc.ProcessDue(); n != 0 || sent["+254712345678"] != 1 {
+		t.Errorf("ProcessDue() before backoff sent %d (%v); want nothing", n, sent)
+	}
+
+	for i := 2; i <= outbox.DefaultMaxAttempts; i++ {
+		db.Model(&models.OutboxMessage{}).Where("status = ?", models.OutboxStatusPending).
+			Update("next_attempt_at", time.Now().Add(-time.Second))
+		svc.ProcessDue()
+	}
+	db.First(&failing, failing.ID)
+	if failing.Status != models.OutboxStatusDead || failing.Attempts != outbox.DefaultMaxAttempts {
+		t.Errorf("after %d failures: status = %s, attempts = %d; want dead", outbox.DefaultMaxAttempts, failing.Status, failing.Attempts)
+	}
+
+	// Email has no sender here, so it dies rather than retrying forever
+	var mail models.OutboxMessage
+	db.Where("channel = ?", models.OutboxChannelEmail).First(&mail)
+	if mail.Status != models.OutboxStatusDead {
+		t.Errorf("email without a sender: status = %s; want dead", mail.Status)
+	}
+
+	counts, err := repo.CountByStatus()
+	if err != nil || counts[models.OutboxStatusSent] != 2 || counts[models.OutboxStatusDead] != 2 {
+		t.Errorf("CountByStatus() = %v, %v; want 2 sent and 2 dead", counts, err)
+	}
+	dead, total, _ := repo.List(models.OutboxStatusDead, models.OutboxChannelWhatsApp, 10, 0)
+	if total != 1 || len(dead) != 1 || dead[0].Recipient != "+254700000000" {
+		t.Errorf("List(dead, whatsapp) = %d messages; want the failing one", total)
+	}
+}
+
+// TestOutboxLease tests that a message another worker is sending is left
+// to it, and one a stopped worker was sending is claimed again once its
+// lease is up
+func TestOutboxLease(t *testing.T) {
+	db := openTestDB(t, &models.OutboxMessage{})
+	repo := repository.NewOutboxRepository(db)
+	svc := outbox.New(repo, 1)
+
+	var smsFor []uint
+	svc.SetSender(models.OutboxChannelSMS, func(m *models.OutboxMessage) error {
+		if m.Subject != string(models.SmsPurposeReceipt) {
+			t.Errorf("SMS purpose = %q; want receipt", m.Subject)
+		}
+		smsFor = append(smsFor, m.ShopID)
+		return nil
+	})
+	if err := svc.SendShopSMS(7, models.SmsPurposeReceipt, "+254712345678", "Thank you!"); err != nil {
+		t.Fatalf("SendShopSMS() error: %v", err)
+	}
+
+	// Another replica claimed it and is still sending
+	now := time.Now()
+	claimed, err := repo.ClaimDue(now, now.Add(-outbox.SendLease), 10)
+	if err != nil || len(claimed) != 1 {
+		t.Fatalf("ClaimDue() = %d, %v; want the message", len(claimed), err)
+	}
+	if n, _ := svc.ProcessDue(); n != 0 || len(smsFor) != 0 {
+		t.Errorf("ProcessDue() sent %d while another worker holds it; want none", n)
+	}
+
+	// That replica stopped; once the lease is up the message is sent
+	db.Model(&models.OutboxMessage{}).Where("id = ?", claimed[0].ID).
+		Update("claimed_at", now.Add(-outbox.SendLease-time.Minute))
+	if n, _ := svc.ProcessDue(); n != 1 || len(smsFor) != 1 || smsFor[0] != 7 {
+		t.Errorf("ProcessDue() after the lease = %d, sent for shops %v; want shop 7's SMS", n, smsFor)
+	}
+	if n, _ := svc.ProcessDue(); n != 0 {
+		t.Errorf("ProcessDue() sent %d again; want it sent once", n)
+	}
+}
+
+// TestOutboxBackoff tests the wait doubles per attempt up to the cap
+func TestOutboxBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{4, 4 * time.Minute},
+		{20, outbox.MaxBackoff},
+	}
+	for _, tt := range tests {
+		if got := outbox.Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %s; want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
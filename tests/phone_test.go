@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/database"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
+)
+
+// TestNormalizePhone tests the formats shop phone numbers arrive in
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"+254712345678", "+254712345678"},
+		{"254712345678", "+254712345678"},
+		{"0712345678", "+254712345678"},
+		{"712345678", "+254712345678"},
+		{"0110 123 456", "+254110123456"},
+		{" +254 (712) 345-678 ", "+254712345678"},
+		{"00255712345678", "+255712345678"},
+		{"+14155238886", "+14155238886"},
+		{"12345", "12345"},
+		{"owner@duka.co.ke", "owner@duka.co.ke"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := utils.NormalizePhone(tt.in); got != tt.want {
+			t.Errorf("NormalizePhone(%q) = %q; want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestShopPhoneFormats tests that one shop is found and created whatever
+// format its number comes in
+func TestShopPhoneFormats(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.AuditLog{})
+	shopRepo := repository.NewShopRepository(db)
+
+	legacy := &models.Shop{Name: "Legacy", Phone: "0722000000", IsActive: true}
+	db.Create(legacy)
+	if shop, err := shopRepo.GetByPhone("0722000000"); err != nil || shop.ID != legacy.ID {
+		t.Errorf("a shop saved before normalisation should still be found: %v", err)
+	}
+
+	cmdHandler := services.NewCommandHandler(db, shopRepo,
+		repository.NewProductRepository(db),
+		repository.NewSaleRepository(db),
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	parser := services.NewCommandParser(nil, nil)
+	for _, phone := range []string{"254712345678", "+254712345678", "0712345678"} {
+		if _, err := cmdHandler.Handle(phone, parser.Parse("help")); err != nil {
+			t.Fatalf("Handle(%s) error: %v", phone, err)
+		}
+	}
+	var shops []models.Shop
+	db.Where("phone LIKE ?", "%712345678").Find(&shops)
+	if len(shops) != 1 || shops[0].Phone != "+254712345678" {
+		t.Fatalf("shops = %+v; want one stored as +254712345678", shops)
+	}
+
+	authService := services.NewAuthService(shopRepo, nil)
+	if err := authService.Register(&models.Shop{Name: "Again", Phone: "0712 345 678"}, "secret123"); err != services.ErrShopExists {
+		t.Errorf("Register() with the same number in another format = %v; want ErrShopExists", err)
+	}
+}
+
+// TestDedupeShopPhones tests merging shops created under one number in
+// different formats
+func TestDedupeShopPhones(t *testing.T) {
+	db := openEmptyDB(t)
+	if err := database.Migrate(); err != nil {
+		t.Fatalf("Migrate() error: %v", err)
+	}
+
+	account := &models.Account{Email: "owner@duka.co.ke", Name: "Owner"}
+	db.Create(account)
+	shop := func(name, phone string) *models.Shop {
+		s := &models.Shop{AccountID: account.ID, Name: name, Phone: phone, IsActive: true}
+		if err := db.Create(s).Error; err != nil {
+			t.Fatalf("create shop %s: %v", name, err)
+		}
+		db.Create(&models.Product{ShopID: s.ID, Name: name + " bread", SellingPrice: 50, IsActive: true})
+		return s
+	}
+	first := shop("First", "+254712345678")
+	second := shop("Second", "0712345678")
+	third := shop("Third", "254712345678")
+	single := shop("Single", "0722000000")
+	shop("Other", "+254733000000")
+
+	merges, err := database.DedupeShopPhones(true)
+	if err != nil {
+		t.Fatalf("DedupeShopPhones(dry run) error: %v", err)
+	}
+	var count int64
+	db.Model(&models.Shop{}).Count(&count)
+	if len(merges) != 2 || count != 5 {
+		t.Fatalf("dry run: %d merges, %d shops; want 2 merges and nothing changed", len(merges), count)
+	}
+
+	merges, err = database.DedupeShopPhones(false)
+	if err != nil {
+		t.Fatalf("DedupeShopPhones() error: %v", err)
+	}
+	got := fmt.Sprintf("%+v", merges)
+	want := fmt.Sprintf("%+v", []database.PhoneMerge{
+		{Phone: "+254712345678", KeptID: first.ID, Merged: []uint{second.ID, third.ID}},
+		{Phone: "+254722000000", KeptID: single.ID},
+	})
+	if got != want {
+		t.Errorf("merges = %s; want %s", got, want)
+	}
+
+	db.Model(&models.Shop{}).Count(&count)
+	if count != 3 {
+		t.Errorf("%d shops left; want 3", count)
+	}
+	db.Model(&models.Product{}).Where("shop_id = ?", first.ID).Count(&count)
+	if count != 3 {
+		t.Errorf("kept shop has %d products; want all 3 moved to it", count)
+	}
+	var kept models.Shop
+	db.First(&kept, single.ID)
+	if kept.Phone != "+254722000000" {
+		t.Errorf("single shop phone = %q; want it normalised", kept.Phone)
+	}
+
+	if merges, _ := database.DedupeShopPhones(false); len(merges) != 0 {
+		t.Errorf("second run = %+v; want nothing left to do", merges)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/handlers"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestPlanLimitConfig tests the central plan limits
+func TestPlanLimitConfig(t *testing.T) {
+	free := models.LimitsFor(models.PlanFree)
+	if !free.CanAddProducts(49, 1) {
+		t.Error("Free plan should allow the 50th product")
+	}
+	if free.CanAddProducts(50, 1) {
+		t.Error("Free plan should reject the 51st product")
+	}
+	if free.CanAddStaff(0, 1) {
+		t.Error("Free plan should not allow staff")
+	}
+
+	pro := models.LimitsFor(models.PlanPro)
+	if !pro.CanAddProducts(100000, 1) {
+		t.Error("Pro plan should allow unlimited products")
+	}
+	if !pro.CanAddStaff(2, 1) || pro.CanAddStaff(3, 1) {
+		t.Errorf("Pro plan should allow exactly %d staff", pro.MaxStaff)
+	}
+
+	if got := models.LimitsFor("unknown"); got != free {
+		t.Errorf("unknown plans should fall back to Free, got %+v", got)
+	}
+}
+
+// TestWhatsAppProductLimit tests the Free plan product boundary over WhatsApp
+func TestWhatsAppProductLimit(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.ProductAlias{}, &models.StockMovement{}, &models.Sale{}, &models.DailySummary{}, &models.AuditLog{})
+
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanFree, IsActive: true}
+	if err := db.Create(shop).Error; err != nil {
+		t.Fatalf("failed to create shop: %v", err)
+	}
+	for i := 1; i <= 49; i++ {
+		db.Create(&models.Product{ShopID: shop.ID, Name: fmt.Sprintf("Item %d", i), SellingPrice: 10, CurrentStock: 1, IsActive: true})
+	}
+
+	productRepo := repository.NewProductRepository(db)
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		productRepo,
+		repository.NewSaleRepository(db),
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	parser := services.NewCommandParser(nil, nil)
+	send := func(message string) string {
+		reply, err := cmdHandler.Handle(shop.Phone, parser.Parse(message))
+		if err != nil {
+			t.Fatalf("Handle(%q) error: %v", message, err)
+		}
+		return reply
+	}
+
+	if reply := send("add bread 50 30"); !strings.Contains(reply, "Added NEW") {
+		t.Fatalf("50th product should be created, got %q", reply)
+	}
+	if reply := send("add milk 60 20"); !strings.Contains(reply, "Product limit reached") || !strings.Contains(reply, "Upgrade to Pro") {
+		t.Errorf("51st product should be rejected with an upgrade prompt, got %q", reply)
+	}
+	if reply := send("add bread 50 10"); !strings.Contains(reply, "Updated") {
+		t.Errorf("restocking an existing product should not count against the limit, got %q", reply)
+	}
+
+	if count, _ := productRepo.CountByShop(shop.ID); count != 50 {
+		t.Errorf("product count = %d; want 50", count)
+	}
+
+	// Deactivated products still count against the limit
+	db.Model(&models.Product{}).Where("shop_id = ? AND name = ?", shop.ID, "Item 1").Update("is_active", false)
+	if reply := send("add milk 60 20"); !strings.Contains(reply, "Product limit reached") {
+		t.Errorf("deactivating a product should not make room for another, got %q", reply)
+	}
+}
+
+// TestWhatsAppStaffLimit tests the Pro plan staff boundary over WhatsApp,
+// in the shop's language
+func TestWhatsAppStaffLimit(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.Staff{}, &models.AuditLog{})
+
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanPro, Language: "sw", IsActive: true}
+	if err := db.Create(shop).Error; err != nil {
+		t.Fatalf("failed to create shop: %v", err)
+	}
+	for i := 1; i <= 2; i++ {
+		db.Create(&models.Staff{ShopID: shop.ID, Name: fmt.Sprintf("Staff %d", i), Phone: fmt.Sprintf("+25470000000%d", i), Role: "cashier", IsActive: true})
+	}
+
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		repository.NewProductRepository(db),
+		repository.NewSaleRepository(db),
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	cmdHandler.SetStaffRepo(repository.NewStaffRepository(db))
+	parser := services.NewCommandParser(nil, nil)
+	send := func(message string) string {
+		reply, err := cmdHandler.Handle(shop.Phone, parser.Parse(message))
+		if err != nil {
+			t.Fatalf("Handle(%q) error: %v", message, err)
+		}
+		return reply
+	}
+
+	if reply := send("staff add Wanjiku +254700000003 cashier"); strings.Contains(reply, "kikomo") {
+		t.Fatalf("third staff member should be added on Pro, got %q", reply)
+	}
+	if reply := send("staff add Otieno +254700000004 cashier"); !strings.Contains(reply, "Umefikia kikomo cha wafanyakazi") || !strings.Contains(reply, "wafanyakazi 3") {
+		t.Errorf("fourth staff member should be rejected in Swahili, got %q", reply)
+	}
+	var count int64
+	db.Model(&models.Staff{}).Where("shop_id = ?", shop.ID).Count(&count)
+	if count != 3 {
+		t.Errorf("staff count = %d; want 3", count)
+	}
+}
+
+// TestAPIProductLimit tests the Free plan product boundary over the REST API
+func TestAPIProductLimit(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.StockMovement{})
+
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanFree, IsActive: true}
+	if err := db.Create(shop).Error; err != nil {
+		t.Fatalf("failed to create shop: %v", err)
+	}
+	for i := 1; i <= 49; i++ {
+		db.Create(&models.Product{ShopID: shop.ID, Name: fmt.Sprintf("Item %d", i), SellingPrice: 10, CurrentStock: 1, IsActive: true})
+	}
+
+	productHandler := handlers.NewProductHandler(repository.NewProductRepository(db))
+	app := fiber.New(fiber.Config{ErrorHandler: utils.ErrorHandler})
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("shop_id", shop.ID)
+		c.Locals("shop", shop)
+		return c.Next()
+	})
+	app.Post("/products", productHandler.CreateProduct)
+	app.Post("/products/bulk", productHandler.BulkCreateProducts)
+
+	create := func(name string) (int, map[string]interface{}) {
+		req := httptest.NewRequest("POST", "/products", strings.NewReader(fmt.Sprintf(`{"name":%q,"selling_price":50}`, name)))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		var body map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body
+	}
+
+	if status, _ := create("Bread"); status != fiber.StatusCreated {
+		t.Fatalf("50th product: expected status 201, got %d", status)
+	}
+	status, body := create("Milk")
+	if status != fiber.StatusForbidden {
+		t.Fatalf("51st product: expected status 403, got %d", status)
+	}
+	if body["code"] != string(utils.CodePlanLimitReached) {
+		t.Errorf("expected code %s, got %v", utils.CodePlanLimitReached, body["code"])
+	}
+
+	req := httptest.NewRequest("POST", "/products/bulk", strings.NewReader(`[{"name":"Sugar","selling_price":120}]`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	var bulk struct {
+		Created int      `json:"created"`
+		Errors  []string `json:"errors"`
+	}
+	json.NewDecoder(resp.Body).Decode(&bulk)
+	if bulk.Created != 0 || len(bulk.Errors) != 1 || !strings.Contains(bulk.Errors[0], "product limit reached") {
+		t.Errorf("bulk create past the limit should be rejected, got %+v", bulk)
+	}
+}
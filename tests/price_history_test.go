@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/handlers"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/ai"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestPriceHistoryRecorded tests that price changes are logged with their source
+func TestPriceHistoryRecorded(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.ProductAlias{}, &models.StockMovement{}, &models.PriceHistory{}, &models.Sale{}, &models.DailySummary{}, &models.AuditLog{})
+
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanPro, IsActive: true}
+	if err := db.Create(shop).Error; err != nil {
+		t.Fatalf("failed to create shop: %v", err)
+	}
+	productRepo := repository.NewProductRepository(db)
+	priceRepo := repository.NewPriceHistoryRepository(db)
+
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		productRepo,
+		repository.NewSaleRepository(db),
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	cmdHandler.SetPriceHistoryRepo(priceRepo)
+	parser := services.NewCommandParser(nil, nil)
+	send := func(message string) string {
+		reply, err := cmdHandler.Handle(shop.Phone, parser.Parse(message))
+		if err != nil {
+			t.Fatalf("Handle(%q) error: %v", message, err)
+		}
+		return reply
+	}
+
+	send("add bread 50 10")
+	if reply := send("price history bread"); !strings.Contains(reply, "No price changes") {
+		t.Errorf("new product should have no history, got %q", reply)
+	}
+
+	send("add bread 55 10") // restock at a new price
+	send("price bread 60")  // price change
+	send("price bread 60")  // unchanged: not recorded
+	product, _ := productRepo.GetByShopAndName(shop.ID, "Bread")
+	product.Category = "Bakery" // non-price update: not recorded
+	if err := productRepo.Update(product); err != nil {
+		t.Fatalf("Update() error: %v", err)
+	}
+
+	history, err := priceRepo.GetByProduct(product.ID, 10)
+	if err != nil {
+		t.Fatalf("GetByProduct() error: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 price changes, got %d", len(history))
+	}
+	if history[0].OldPrice != 55 || history[0].NewPrice != 60 || history[0].Source != models.PriceSourceWhatsApp {
+		t.Errorf("latest change = %+v; want 55 -> 60 from whatsapp", history[0])
+	}
+	if history[1].OldPrice != 50 || history[1].NewPrice != 55 {
+		t.Errorf("first change = %+v; want 50 -> 55", history[1])
+	}
+
+	reply := send("price history bread")
+	if !strings.Contains(reply, "PRICE HISTORY: Bread") || !strings.Contains(reply, "KSh 55 → KSh 60") {
+		t.Errorf("unexpected price history reply: %q", reply)
+	}
+	if reply := send("price history"); !strings.Contains(reply, "Usage: price history") {
+		t.Errorf("expected usage message, got %q", reply)
+	}
+}
+
+// TestPriceHistoryRollback tests that a failed history write rolls back the price change
+func TestPriceHistoryRollback(t *testing.T) {
+	// No price_history table, so recording the change fails
+	db := openTestDB(t, &models.Product{})
+	productRepo := repository.NewProductRepository(db)
+
+	product := &models.Product{ShopID: 1, Name: "Bread", SellingPrice: 50, IsActive: true}
+	if err := productRepo.Create(product); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+
+	product.SellingPrice = 60
+	if err := productRepo.UpdateWithSource(product, models.PriceSourceAPI); err == nil {
+		t.Fatal("expected error when price history can't be written")
+	}
+
+	stored, _ := productRepo.GetByID(product.ID)
+	if stored.SellingPrice != 50 {
+		t.Errorf("price = %.0f; want 50 after rollback", stored.SellingPrice)
+	}
+}
+
+// TestPriceHistoryAPI tests GET /products/:id/price-history
+func TestPriceHistoryAPI(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.PriceHistory{})
+
+	productRepo := repository.NewProductRepository(db)
+	productHandler := handlers.NewProductHandler(productRepo)
+	productHandler.SetPriceHistoryRepo(repository.NewPriceHistoryRepository(db))
+
+	app := fiber.New(fiber.Config{ErrorHandler: utils.ErrorHandler})
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("shop_id", uint(1))
+		return c.Next()
+	})
+	app.Put("/products/:id", productHandler.UpdateProduct)
+	app.Get("/products/:id/price-history", productHandler.GetPriceHistory)
+
+	mine := &models.Product{ShopID: 1, Name: "Bread", SellingPrice: 50, IsActive: true}
+	theirs := &models.Product{ShopID: 2, Name: "Milk", SellingPrice: 60, IsActive: true}
+	db.Create(mine)
+	db.Create(theirs)
+
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/products/%d", mine.ID), strings.NewReader(`{"selling_price":65}`))
+	req.Header.Set("Content-Type", "application/json")
+	if resp, err := app.Test(req); err != nil || resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("update failed: %v", err)
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", fmt.Sprintf("/products/%d/price-history", mine.ID), nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+	var body struct {
+		CurrentPrice float64               `json:"current_price"`
+		History      []models.PriceHistory `json:"history"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	if body.CurrentPrice != 65 || len(body.History) != 1 {
+		t.Fatalf("unexpected response: %+v", body)
+	}
+	if h := body.History[0]; h.OldPrice != 50 || h.NewPrice != 65 || h.Source != models.PriceSourceAPI {
+		t.Errorf("change = %+v; want 50 -> 65 from api", h)
+	}
+
+	resp, _ = app.Test(httptest.NewRequest("GET", fmt.Sprintf("/products/%d/price-history", theirs.ID), nil))
+	if resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("another shop's product: expected status 403, got %d", resp.StatusCode)
+	}
+}
+
+// TestPriceHistoryCostAndActor tests that cost price changes are logged with
+// who made them, filtered by date and reported with their margins
+func TestPriceHistoryCostAndActor(t *testing.T) {
+	db := openTestDB(t, &models.Product{}, &models.PriceHistory{}, &models.Sale{})
+
+	productRepo := repository.NewProductRepository(db)
+	priceRepo := repository.NewPriceHistoryRepository(db)
+	productHandler := handlers.NewProductHandler(productRepo)
+	productHandler.SetPriceHistoryRepo(priceRepo)
+
+	app := fiber.New(fiber.Config{ErrorHandler: utils.ErrorHandler})
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("shop_id", uint(1))
+		c.Locals("api_key", &models.APIKey{ID: 7, ShopID: 1})
+		return c.Next()
+	})
+	app.Put("/products/:id", productHandler.UpdateProduct)
+	app.Get("/products/:id/price-history", productHandler.GetPriceHistory)
+
+	bread := &models.Product{ShopID: 1, Name: "Bread", SellingPrice: 60, CostPrice: 45, IsActive: true}
+	db.Create(bread)
+
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/products/%d", bread.ID), strings.NewReader(`{"cost_price":48}`))
+	req.Header.Set("Content-Type", "application/json")
+	if resp, err := app.Test(req); err != nil || resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("update failed: %v", err)
+	}
+
+	history, _ := priceRepo.GetByProduct(bread.ID, 10)
+	if len(history) != 1 {
+		t.Fatalf("expected 1 change, got %d", len(history))
+	}
+	h := history[0]
+	if h.OldCostPrice != 45 || h.NewCostPrice != 48 || h.OldPrice != 60 || h.NewPrice != 60 {
+		t.Errorf("change = %+v; want cost 45 -> 48 at a selling price of 60", h)
+	}
+	if h.ChangedByType != "api_key" || h.ChangedByID != 7 {
+		t.Errorf("changed by = %s %d; want api_key 7", h.ChangedByType, h.ChangedByID)
+	}
+
+	// Changes made without an actor are the system's
+	bread.SellingPrice = 64
+	productRepo.Update(bread)
+	history, _ = priceRepo.GetByProduct(bread.ID, 10)
+	if history[0].ChangedByType != "system" {
+		t.Errorf("changed by = %q; want system", history[0].ChangedByType)
+	}
+
+	count := func(query string) int {
+		resp, _ := app.Test(httptest.NewRequest("GET", fmt.Sprintf("/products/%d/price-history?%s", bread.ID, query), nil))
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("price-history?%s: status %d", query, resp.StatusCode)
+		}
+		var body struct {
+			History []models.PriceHistory `json:"history"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return len(body.History)
+	}
+	today := time.Now().Format("2006-01-02")
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	if n := count("from=" + today + "&to=" + today); n != 2 {
+		t.Errorf("today's changes = %d; want 2", n)
+	}
+	if n := count("from=" + tomorrow); n != 0 {
+		t.Errorf("changes from tomorrow = %d; want 0", n)
+	}
+	resp, _ := app.Test(httptest.NewRequest("GET", fmt.Sprintf("/products/%d/price-history?from=last-week", bread.ID), nil))
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("bad from: expected 400, got %d", resp.StatusCode)
+	}
+
+	predictor := ai.NewPredictionService(productRepo, repository.NewSaleRepository(db), nil)
+	predictor.SetPriceHistoryRepo(priceRepo)
+	changes, err := predictor.GetPriceChanges(1, 30)
+	if err != nil {
+		t.Fatalf("GetPriceChanges() error: %v", err)
+	}
+	if len(changes) != 2 || changes[0].ProductName != "Bread" || changes[0].OldMargin != 25 || changes[0].NewMargin != 20 {
+		t.Errorf("changes = %+v; want the cost change first, margin 25%% -> 20%%", changes)
+	}
+}
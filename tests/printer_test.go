@@ -1,9 +1,30 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
+
+	printerhandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/printer"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/printer"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/storage"
+	"github.com/gofiber/fiber/v2"
 )
 
 // TestReceiptGeneration tests receipt creation
@@ -248,3 +269,612 @@ func TestReceiptIDGeneration(t *testing.T) {
 		t.Error("ID format incorrect")
 	}
 }
+
+// testLogo is a 400x100 PNG, white with a black square in the top-left
+// corner
+func testLogo() []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 400; x++ {
+			if x < 50 && y < 50 {
+				img.Set(x, y, color.Black)
+			} else {
+				img.Set(x, y, color.White)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+// TestRasterLogo tests converting a logo to an ESC/POS raster image
+func TestRasterLogo(t *testing.T) {
+	cmd, err := printer.RasterLogo(testLogo())
+	if err != nil {
+		t.Fatalf("RasterLogo() error: %v", err)
+	}
+	// Scaled from 400x100 to 256x64: 32 bytes a row
+	want := []byte{0x1D, 0x76, 0x30, 0x00, 32, 0, 64, 0}
+	if !bytes.HasPrefix(cmd, want) {
+		t.Fatalf("header = % x; want % x", cmd[:8], want)
+	}
+	if len(cmd) != 8+32*64 {
+		t.Errorf("length = %d; want %d", len(cmd), 8+32*64)
+	}
+	// The black square is 32 dots wide after scaling
+	if row := cmd[8:40]; row[0] != 0xff || row[3] != 0xff || row[4] != 0x00 {
+		t.Errorf("first row = % x; want 4 black bytes then white", row[:6])
+	}
+	if _, err := printer.RasterLogo([]byte("not an image")); err == nil {
+		t.Error("RasterLogo() should fail on data that is not an image")
+	}
+}
+
+// TestReceiptBranding tests shop branding on every receipt format and that
+// receipts without it are unchanged
+func TestReceiptBranding(t *testing.T) {
+	svc := printer.New(&printer.PrinterConfig{Type: "pdf", Width: 32})
+	receipt := svc.GenerateReceipt(1, "Mama Mboga", "+254712345678", []printer.ReceiptItem{
+		{Name: "Milk", Quantity: 2, UnitPrice: 60, Total: 120},
+	}, "cash", 200)
+
+	text := svc.FormatText(receipt)
+	if !strings.Contains(text, "Please come again") || strings.Contains(text, "VAT") {
+		t.Errorf("unbranded text receipt:\n%s", text)
+	}
+	if bytes.Contains(svc.FormatThermal(receipt), []byte{0x1D, 0x76, 0x30}) {
+		t.Error("unbranded thermal receipt should have no logo")
+	}
+	if html := svc.FormatHTML(receipt); strings.Contains(html, "<img") || !strings.Contains(html, "Please come again") {
+		t.Error("unbranded HTML receipt should have no logo and the default footer")
+	}
+	if pdf, err := svc.GeneratePDF(receipt); err != nil || !bytes.HasPrefix(pdf, []byte("%PDF")) {
+		t.Fatalf("GeneratePDF() = %.10q, %v; want a PDF", pdf, err)
+	}
+
+	receipt.Branding = printer.Branding{
+		Logo:      testLogo(),
+		Header:    "Fresh vegetables every morning from our farm",
+		Footer:    "Goods once sold are not returnable",
+		Contact:   "mamamboga.co.ke",
+		VATNumber: "P051234567X",
+	}
+	text = svc.FormatText(receipt)
+	for _, want := range []string{
+		"Fresh vegetables every morning\n",
+		"from our farm\n",
+		"mamamboga.co.ke",
+		"VAT No: P051234567X",
+		"Goods once sold are not\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("branded text receipt missing %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "Please come again") {
+		t.Error("the shop footer should replace the default one")
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if len(line) > 32 && !strings.Contains(line, "🏪") {
+			t.Errorf("line wider than the paper: %q", line)
+		}
+	}
+
+	thermal := svc.FormatThermal(receipt)
+	if !bytes.Contains(thermal, []byte{0x1D, 0x76, 0x30, 0x00, 32, 0, 64, 0}) {
+		t.Error("branded thermal receipt should print the logo")
+	}
+	if !bytes.Contains(thermal, []byte("VAT No: P051234567X")) {
+		t.Error("branded thermal receipt should show the VAT number")
+	}
+	if html := svc.FormatHTML(receipt); !strings.Contains(html, `src="data:image/png;base64,`) || !strings.Contains(html, "not returnable") {
+		t.Error("branded HTML receipt should embed the logo and show the footer")
+	}
+	pdf, err := svc.GeneratePDF(receipt)
+	if err != nil || !bytes.Contains(pdf, []byte("/Subtype /Image")) {
+		t.Errorf("branded PDF receipt should embed the logo (err %v)", err)
+	}
+
+	receipt.Branding.Logo = []byte("not an image")
+	if _, err := svc.GeneratePDF(receipt); err != nil {
+		t.Errorf("a bad logo should be left off, got %v", err)
+	}
+	if bytes.Contains(svc.FormatThermal(receipt), []byte{0x1D, 0x76, 0x30}) {
+		t.Error("a bad logo should be left off thermal receipts")
+	}
+}
+
+// TestReceiptBrandingEndpoints tests setting a shop's receipt branding and
+// its use on receipts
+func TestReceiptBrandingEndpoints(t *testing.T) {
+	db := openTestDB(t, &models.Shop{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	db.Create(shop)
+
+	h := printerhandler.New(printer.New(nil))
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("shop_id", shop.ID)
+		return c.Next()
+	})
+	app.Get("/print/branding", h.GetBranding)
+	app.Put("/print/branding", h.UpdateBranding)
+	app.Post("/print/text", h.GetTextReceipt)
+
+	do := func(method, body, contentType string) (int, map[string]interface{}) {
+		t.Helper()
+		r := httptest.NewRequest(method, "/print/branding", strings.NewReader(body))
+		if contentType != "" {
+			r.Header.Set("Content-Type", contentType)
+		}
+		resp, err := app.Test(r)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		var out map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+	if status, _ := do("GET", "", ""); status != fiber.StatusServiceUnavailable {
+		t.Errorf("branding without a shop repository: status %d; want 503", status)
+	}
+
+	dir := t.TempDir()
+	h.SetShopRepo(repository.NewShopRepository(db))
+	h.SetImageStore(storage.NewLocalStore(dir), "/static/")
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("header", "Fresh every morning")
+	form.WriteField("footer", "Karibu tena!")
+	form.WriteField("vat_number", "P051234567X")
+	part, _ := form.CreateFormFile("logo", "logo.png")
+	part.Write(testLogo())
+	form.Close()
+	status, out := do("PUT", body.String(), form.FormDataContentType())
+	if status != fiber.StatusOK {
+		t.Fatalf("PUT branding: status %d: %v", status, out)
+	}
+	logoURL, _ := out["logo_url"].(string)
+	if !strings.HasPrefix(logoURL, fmt.Sprintf("/static/receipts/%d/logo-", shop.ID)) || out["vat_number"] != "P051234567X" {
+		t.Fatalf("PUT branding = %v", out)
+	}
+	logoPath := filepath.Join(dir, strings.TrimPrefix(logoURL, "/static/"))
+	if _, err := os.Stat(logoPath); err != nil {
+		t.Fatalf("logo not saved: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/print/text", strings.NewReader(
+		`{"shop_name": "Mama Mboga", "items": [{"name": "Milk", "quantity": 1, "unit_price": 60}], "payment_method": "cash"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	receipt, _ := io.ReadAll(resp.Body)
+	if !bytes.Contains(receipt, []byte("Fresh every morning")) || !bytes.Contains(receipt, []byte("Karibu tena!")) {
+		t.Errorf("text receipt should carry the shop branding: %s", receipt)
+	}
+
+	status, out = do("PUT", `{"footer": "Asante", "remove_logo": true}`, "application/json")
+	if status != fiber.StatusOK || out["logo_url"] != "" || out["header"] != "" || out["footer"] != "Asante" {
+		t.Errorf("PUT branding without a logo = %d %v", status, out)
+	}
+	if _, err := os.Stat(logoPath); !os.IsNotExist(err) {
+		t.Error("the removed logo should be deleted")
+	}
+
+	status, _ = do("PUT", `{"vat_number": "`+strings.Repeat("X", 31)+`"}`, "application/json")
+	if status != fiber.StatusBadRequest {
+		t.Errorf("VAT number too long: status %d; want 400", status)
+	}
+	if status, out = do("GET", "", ""); status != fiber.StatusOK || out["footer"] != "Asante" {
+		t.Errorf("GET branding = %d %v", status, out)
+	}
+}
+
+// fakePrinter listens like a network thermal printer and returns what each
+// connection sent
+func fakePrinter(t *testing.T) (host string, port int, jobs <-chan []byte) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	ch := make(chan []byte, 4)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			data, _ := io.ReadAll(conn)
+			conn.Close()
+			ch <- data
+		}
+	}()
+	host, portStr, _ := net.SplitHostPort(ln.Addr().String())
+	port, _ = strconv.Atoi(portStr)
+	return host, port, ch
+}
+
+func nextJob(t *testing.T, jobs <-chan []byte) []byte {
+	t.Helper()
+	select {
+	case data := <-jobs:
+		return data
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing reached the printer")
+		return nil
+	}
+}
+
+// TestNetworkPrinter tests ESC/POS receipts and test pages sent over TCP
+func TestNetworkPrinter(t *testing.T) {
+	host, port, jobs := fakePrinter(t)
+	svc := printer.New(&printer.PrinterConfig{Type: "thermal", Host: host, Port: port, OpenDrawer: true})
+	receipt := svc.GenerateReceipt(7, "Mama Mboga", "+254712345678", []printer.ReceiptItem{
+		{Name: "Unga Jogoo 2kg", Quantity: 2, UnitPrice: 180, Total: 360},
+	}, "cash", 500)
+
+	if err := svc.Print(receipt); err != nil {
+		t.Fatalf("Print() error: %v", err)
+	}
+	data := nextJob(t, jobs)
+	if !bytes.HasPrefix(data, []byte{0x1B, 0x40}) {
+		t.Error("receipt should start by resetting the printer")
+	}
+	if !bytes.Contains(data, append([]byte{0x1B, 0x45, 0x01, 0x1B, 0x21, 0x10}, "Mama Mboga"...)) {
+		t.Error("the shop name should be bold")
+	}
+	cut := bytes.Index(data, []byte{0x1D, 0x56, 0x42, 0x00})
+	kick := bytes.Index(data, []byte{0x1B, 0x70, 0x00, 0x19, 0xFA})
+	if cut < 0 || kick < cut {
+		t.Errorf("receipt should be cut, then the drawer opened (cut at %d, kick at %d)", cut, kick)
+	}
+	for _, line := range strings.Split(string(data[:cut]), "\n") {
+		if !bytes.ContainsAny([]byte(line), "\x1b\x1d") && len(line) > 32 {
+			t.Errorf("line wider than the paper: %q", line)
+		}
+	}
+
+	receipt.PaymentMethod = "mpesa"
+	svc.Print(receipt)
+	if bytes.Contains(nextJob(t, jobs), []byte{0x1B, 0x70}) {
+		t.Error("the drawer should stay shut for M-Pesa sales")
+	}
+
+	// The test page goes through the handler
+	h := printerhandler.New(svc)
+	app := fiber.New()
+	app.Post("/print/test", h.TestPrinter)
+	app.Get("/print/printers", h.GetPrinters)
+	resp, err := app.Test(httptest.NewRequest("POST", "/print/test", nil))
+	if err != nil || resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("POST /print/test: %v %v", err, resp)
+	}
+	page := nextJob(t, jobs)
+	if !bytes.Contains(page, []byte("TEST PAGE")) || !bytes.Contains(page, []byte("12345678901234567890123456789012\n")) {
+		t.Errorf("test page = %q", page)
+	}
+	resp, _ = app.Test(httptest.NewRequest("GET", "/print/printers", nil))
+	var listed struct {
+		Printers []map[string]interface{} `json:"printers"`
+	}
+	json.NewDecoder(resp.Body).Decode(&listed)
+	if len(listed.Printers) != 1 || listed.Printers[0]["status"] != "online" {
+		t.Errorf("printers = %v; want the network printer online", listed.Printers)
+	}
+	<-jobs // the status check connects without sending anything
+
+	// No printer configured
+	unset := printerhandler.New(printer.New(nil))
+	app = fiber.New()
+	app.Post("/print/test", unset.TestPrinter)
+	resp, _ = app.Test(httptest.NewRequest("POST", "/print/test", nil))
+	if resp.StatusCode != fiber.StatusServiceUnavailable {
+		t.Errorf("test page without a printer: status %d; want 503", resp.StatusCode)
+	}
+
+	// Nothing listening on the port
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	closed := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	down := printer.New(&printer.PrinterConfig{Host: "127.0.0.1", Port: closed, Timeout: time.Second})
+	if err := down.TestPrint(); !errors.Is(err, printer.ErrPrinterUnreachable) || !strings.Contains(err.Error(), down.Address()) {
+		t.Errorf("TestPrint() to a closed port = %v; want ErrPrinterUnreachable naming the address", err)
+	}
+}
+
+// TestShopPrinterSettings tests saving a shop's own printer, printing a
+// recorded sale on it and printing to a USB device
+func TestShopPrinterSettings(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.Sale{}, &models.Staff{}, &models.Customer{},
+		&models.PrinterSetting{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	db.Create(shop)
+	other := &models.Shop{Name: "Other", Phone: "+254700000001", IsActive: true}
+	db.Create(other)
+
+	serverHost, serverPort, serverJobs := fakePrinter(t)
+	shopHost, shopPort, shopJobs := fakePrinter(t)
+	h := printerhandler.New(printer.New(&printer.PrinterConfig{Type: "thermal", Host: serverHost, Port: serverPort}))
+	h.SetShopRepo(repository.NewShopRepository(db))
+	h.SetSaleRepo(repository.NewSaleRepository(db))
+	h.SetPrinterSettingRepo(repository.NewPrinterSettingRepository(db))
+	h.SetLocalPrinters(true) // the fake printers listen on loopback
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("shop_id", shop.ID)
+		return c.Next()
+	})
+	app.Get("/print/config", h.GetConfig)
+	app.Put("/print/config", h.Configure)
+	app.Post("/print/test", h.TestPrinter)
+	app.Post("/print/receipt", h.PrintReceipt)
+
+	do := func(method, path, body string) (int, map[string]interface{}) {
+		t.Helper()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(r)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		var out map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	// Until the shop saves a printer it uses the server's
+	if _, out := do("GET", "/print/config", ""); out["saved"] != false {
+		t.Errorf("config before saving = %v; want saved false", out)
+	}
+	do("POST", "/print/test", "")
+	nextJob(t, serverJobs)
+
+	for _, body := range []string{
+		`{"connection": "network"}`,
+		`{"connection": "usb", "device": "/etc/passwd"}`,
+		`{"connection": "serial", "device": "/dev/usb/lp0"}`,
+		`{"connection": "bluetooth"}`,
+		`{"host": "10.0.0.5", "paper_width": 70}`,
+		`{"type": "laser", "host": "10.0.0.5"}`,
+	} {
+		if status, _ := do("PUT", "/print/config", body); status != fiber.StatusBadRequest {
+			t.Errorf("PUT /print/config %s: status %d; want 400", body, status)
+		}
+	}
+
+	body := fmt.Sprintf(`{"host": %q, "port": %d, "paper_width": 80, "open_drawer": true}`, shopHost, shopPort)
+	if status, out := do("PUT", "/print/config", body); status != fiber.StatusOK {
+		t.Fatalf("PUT /print/config: status %d %v", status, out)
+	}
+	status, out := do("GET", "/print/config", "")
+	config, _ := out["config"].(map[string]interface{})
+	if status != fiber.StatusOK || out["saved"] != true || config["connection"] != "network" || config["width"] != float64(48) {
+		t.Errorf("config after saving = %v; want the shop's network printer 48 characters wide", out)
+	}
+
+	do("POST", "/print/test", "")
+	page := nextJob(t, shopJobs)
+	ruler := "123456789012345678901234567890123456789012345678\n"
+	if !bytes.Contains(page, []byte(ruler)) || !bytes.Contains(page, []byte("|<- LEFT")) ||
+		!bytes.Contains(page, append([]byte{0x1B, 0x61, 0x02}, "RIGHT ->|"...)) {
+		t.Errorf("test page on 80mm paper = %q; want a 48 character ruler and alignment marks", page)
+	}
+
+	// A recorded sale prints with the rest of its basket
+	milk := &models.Product{ShopID: shop.ID, Name: "Milk", SellingPrice: 60, IsActive: true}
+	db.Create(milk)
+	bread := &models.Product{ShopID: shop.ID, Name: "Bread", SellingPrice: 65, IsActive: true}
+	db.Create(bread)
+	staff := &models.Staff{ShopID: shop.ID, Name: "Akinyi", Phone: "+254733000111", IsActive: true}
+	db.Create(staff)
+	basket := uint(9)
+	first := &models.Sale{ShopID: shop.ID, ProductID: milk.ID, Quantity: 2, UnitPrice: 60, TotalAmount: 120,
+		PaymentMethod: models.PaymentCash, PendingSaleID: &basket, StaffID: &staff.ID}
+	db.Create(first)
+	db.Create(&models.Sale{ShopID: shop.ID, ProductID: bread.ID, Quantity: 1, UnitPrice: 65, TotalAmount: 65,
+		PaymentMethod: models.PaymentCash, PendingSaleID: &basket, StaffID: &staff.ID})
+	theirs := &models.Sale{ShopID: other.ID, ProductID: milk.ID, Quantity: 1, UnitPrice: 60, TotalAmount: 60}
+	db.Create(theirs)
+
+	if status, out := do("POST", "/print/receipt", fmt.Sprintf(`{"sale_id": %d, "cash_given": 200}`, first.ID)); status != fiber.StatusOK {
+		t.Fatalf("printing sale %d: status %d %v", first.ID, status, out)
+	}
+	receipt := string(nextJob(t, shopJobs))
+	for _, want := range []string{"Mama Mboga", "Milk", "Bread", "Cashier: Akinyi", "KSh 185", "Change:", "KSh 15"} {
+		if !strings.Contains(receipt, want) {
+			t.Errorf("sale receipt missing %q:\n%s", want, receipt)
+		}
+	}
+	if !strings.Contains(receipt, "\x1b\x70\x00") {
+		t.Error("the shop's printer should open the drawer for a cash sale")
+	}
+	if status, _ := do("POST", "/print/receipt", fmt.Sprintf(`{"sale_id": %d}`, theirs.ID)); status != fiber.StatusNotFound {
+		t.Errorf("printing another shop's sale: status %d; want 404", status)
+	}
+
+	// USB and serial printers are written to as a device
+	device := filepath.Join(t.TempDir(), "lp0")
+	os.WriteFile(device, nil, 0o600)
+	usb := printer.New(&printer.PrinterConfig{Connection: printer.ConnectionUSB, Device: device})
+	if usb.Address() != device || !usb.Configured() {
+		t.Errorf("USB printer address %q; want %q", usb.Address(), device)
+	}
+	if err := usb.TestPrint(); err != nil {
+		t.Fatalf("TestPrint() to a device: %v", err)
+	}
+	if data, _ := os.ReadFile(device); !bytes.HasPrefix(data, []byte{0x1B, 0x40}) || !bytes.Contains(data, []byte("TEST PAGE")) {
+		t.Errorf("device got %q; want the test page", data)
+	}
+	missing := printer.New(&printer.PrinterConfig{Connection: printer.ConnectionSerial, Device: filepath.Join(t.TempDir(), "ttyUSB0")})
+	if err := missing.Ping(); !errors.Is(err, printer.ErrPrinterUnreachable) {
+		t.Errorf("Ping() a missing device = %v; want ErrPrinterUnreachable", err)
+	}
+}
+
+// TestShopPrinterPublicOnly tests that on a shared server shops can't point
+// their printer at the server's own network or devices
+func TestShopPrinterPublicOnly(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.PrinterSetting{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	db.Create(shop)
+
+	h := printerhandler.New(printer.New(nil))
+	settings := repository.NewPrinterSettingRepository(db)
+	h.SetPrinterSettingRepo(settings)
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("shop_id", shop.ID)
+		return c.Next()
+	})
+	app.Put("/print/config", h.Configure)
+	app.Post("/print/test", h.TestPrinter)
+
+	for _, body := range []string{
+		`{"host": "127.0.0.1"}`,
+		`{"host": "localhost"}`,
+		`{"host": "10.0.0.5"}`,
+		`{"host": "192.168.1.20", "port": 9100}`,
+		`{"host": "169.254.169.254", "port": 80}`,
+		`{"host": "::1"}`,
+		`{"connection": "usb", "device": "/dev/usb/lp0"}`,
+		`{"connection": "serial", "device": "/dev/ttyUSB0"}`,
+	} {
+		r := httptest.NewRequest("PUT", "/print/config", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(r)
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("PUT /print/config %s: status %d; want 400", body, resp.StatusCode)
+		}
+	}
+
+	// A setting saved before, or a name re-pointed since, is refused when
+	// connecting
+	host, port, jobs := fakePrinter(t)
+	settings.Save(&models.PrinterSetting{ShopID: shop.ID, Type: "thermal", Connection: printer.ConnectionNetwork,
+		Host: host, Port: port, PaperWidth: 58})
+	resp, _ := app.Test(httptest.NewRequest("POST", "/print/test", nil))
+	if resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("test page on a loopback printer: status %d; want 403", resp.StatusCode)
+	}
+	select {
+	case <-jobs:
+		t.Error("the loopback printer was reached")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	for ip, public := range map[string]bool{
+		"41.90.64.10": true, "2c0f:fe38::1": true, "100.64.0.1": false, "172.16.0.1": false, "fe80::1": false, "0.0.0.0": false,
+	} {
+		if got := printer.PublicIP(net.ParseIP(ip)); got != public {
+			t.Errorf("PublicIP(%s) = %v; want %v", ip, got, public)
+		}
+	}
+}
+
+// TestReceiptSettings tests the header lines, KRA PIN, currency symbol and
+// QR code branding and that every receipt format uses them
+func TestReceiptSettings(t *testing.T) {
+	db := openTestDB(t, &models.Shop{})
+	shop := &models.Shop{Name: "Mama <b>Mboga</b>", Phone: "+254712345678", ReceiptContact: "mamamboga.co.ke", IsActive: true}
+	db.Create(shop)
+
+	h := printerhandler.New(printer.New(nil))
+	h.SetShopRepo(repository.NewShopRepository(db))
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("shop_id", shop.ID)
+		return c.Next()
+	})
+	app.Put("/print/branding", h.UpdateBranding)
+	app.Get("/print/branding/preview", h.PreviewReceipt)
+	app.Post("/print/text", h.GetTextReceipt)
+
+	do := func(method, path, body string) (int, []byte) {
+		t.Helper()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(r)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		out, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, out
+	}
+
+	for _, body := range []string{
+		`{"vat_number": "12345"}`,
+		`{"header": "1\n2\n3\n4\n5\n6"}`,
+		`{"currency_symbol": "` + strings.Repeat("$", 11) + `"}`,
+	} {
+		if status, out := do("PUT", "/print/branding", body); status != fiber.StatusBadRequest {
+			t.Errorf("PUT %s: status %d %s; want 400", body, status, out)
+		}
+	}
+
+	status, out := do("PUT", "/print/branding", `{"header": "Fresh every morning\n \nOpen 7 days",
+		"footer": "Asante", "contact": "mamamboga.co.ke", "vat_number": "p051234567x", "show_qr": true, "currency_symbol": "USh"}`)
+	if status != fiber.StatusOK {
+		t.Fatalf("PUT branding: status %d %s", status, out)
+	}
+	var branding map[string]interface{}
+	json.Unmarshal(out, &branding)
+	if branding["vat_number"] != "P051234567X" || branding["show_qr"] != true || branding["currency_symbol"] != "USh" {
+		t.Errorf("PUT branding = %v", branding)
+	}
+	var saved models.Shop
+	db.First(&saved, shop.ID)
+	if saved.ReceiptHeader != "Fresh every morning\nOpen 7 days" || !saved.ReceiptShowQR {
+		t.Errorf("saved shop = header %q qr %v; want the two lines with text and a QR code", saved.ReceiptHeader, saved.ReceiptShowQR)
+	}
+
+	_, text := do("POST", "/print/text", `{"shop_name": "Mama Mboga", "items": [{"name": "Milk", "quantity": 1, "unit_price": 60}]}`)
+	for _, want := range []string{"Open 7 days", "VAT No: P051234567X", "USh 60", "Asante"} {
+		if !bytes.Contains(text, []byte(want)) {
+			t.Errorf("text receipt missing %q: %s", want, text)
+		}
+	}
+	if bytes.Contains(text, []byte("KSh")) {
+		t.Errorf("text receipt should use the shop's currency: %s", text)
+	}
+
+	status, preview := do("GET", "/print/branding/preview", "")
+	if status != fiber.StatusOK {
+		t.Fatalf("preview: status %d", status)
+	}
+	for _, want := range []string{"Fresh every morning<br>Open 7 days", "VAT No: P051234567X", "TOTAL: USh 380", `<div class="qr"><img src="data:image/png;base64,`, "Mama &lt;b&gt;Mboga"} {
+		if !bytes.Contains(preview, []byte(want)) {
+			t.Errorf("preview missing %q:\n%s", want, preview)
+		}
+	}
+
+	svc := printer.New(&printer.PrinterConfig{Width: 32})
+	receipt := svc.GenerateReceipt(7, "Mama Mboga", "+254712345678", []printer.ReceiptItem{
+		{Name: "Milk", Quantity: 1, UnitPrice: 60, Total: 60},
+	}, "cash", 0)
+	receipt.VerifyURL = "https://duka.example/r/7?s=abc"
+	if bytes.Contains(svc.FormatThermal(receipt), []byte{0x1D, 0x28, 0x6B}) {
+		t.Error("receipts without show_qr should have no QR code")
+	}
+	receipt.Branding = printer.Branding{VATNumber: "P051234567X", ShowQR: true}
+	thermal := svc.FormatThermal(receipt)
+	store := append([]byte{0x1D, 0x28, 0x6B, byte(len(receipt.VerifyURL) + 3), 0x00, 0x31, 0x50, 0x30}, receipt.VerifyURL...)
+	if !bytes.Contains(thermal, store) || !bytes.Contains(thermal, []byte("VAT No: P051234567X")) {
+		t.Error("thermal receipt should print the VAT number and a QR code of the verification link")
+	}
+	if text := svc.FormatText(receipt); !strings.Contains(text, "Verify this receipt at") {
+		t.Errorf("text receipt should give the verification link:\n%s", text)
+	}
+	receipt.VerifyURL = ""
+	if receipt.QRContent() != receipt.ID {
+		t.Errorf("QRContent() = %q; want the receipt number %q", receipt.QRContent(), receipt.ID)
+	}
+	if pdf, err := svc.GeneratePDF(receipt); err != nil || !bytes.Contains(pdf, []byte("/Subtype /Image")) {
+		t.Errorf("PDF receipt should embed the QR code (err %v)", err)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/handlers"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestProductAliases tests selling products by the short names a shop gives them
+func TestProductAliases(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.ProductAlias{}, &models.StockMovement{},
+		&models.Sale{}, &models.DailySummary{}, &models.AuditLog{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	db.Create(shop)
+	other := &models.Shop{Name: "Other", Phone: "+254700000001", IsActive: true}
+	db.Create(other)
+
+	productRepo := repository.NewProductRepository(db)
+	coke := &models.Product{ShopID: shop.ID, Name: "Coca-Cola 500ml", SellingPrice: 80, CurrentStock: 20, Unit: "bottle", IsActive: true}
+	productRepo.Create(coke)
+	bread := &models.Product{ShopID: shop.ID, Name: "Bread", SellingPrice: 50, CurrentStock: 10, Unit: "pcs", IsActive: true}
+	productRepo.Create(bread)
+	theirs := &models.Product{ShopID: other.ID, Name: "Coca-Cola 500ml", SellingPrice: 80, CurrentStock: 5, Unit: "bottle", IsActive: true}
+	productRepo.Create(theirs)
+
+	if _, err := productRepo.AddAlias(coke, " CocaCola "); err != nil {
+		t.Fatalf("AddAlias() error: %v", err)
+	}
+	if _, err := productRepo.AddAlias(coke, "cocacola"); err != nil {
+		t.Errorf("adding an alias the product already has: %v", err)
+	}
+	if _, err := productRepo.AddAlias(bread, "cocacola"); !errors.Is(err, repository.ErrAliasTaken) {
+		t.Errorf("alias of another product: err = %v; want ErrAliasTaken", err)
+	}
+	if _, err := productRepo.AddAlias(coke, "bread"); !errors.Is(err, repository.ErrAliasTaken) {
+		t.Errorf("alias matching another product's name: err = %v; want ErrAliasTaken", err)
+	}
+	if _, err := productRepo.AddAlias(theirs, "cocacola"); err != nil {
+		t.Errorf("the same alias in another shop: %v", err)
+	}
+
+	found, err := productRepo.GetByShopAndName(shop.ID, "Cocacola")
+	if err != nil || found.ID != coke.ID {
+		t.Fatalf("GetByShopAndName(alias) = %v, %v; want Coca-Cola 500ml", found, err)
+	}
+
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		productRepo,
+		repository.NewSaleRepository(db),
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	parser := services.NewCommandParser(nil, nil)
+	run := func(text string) string {
+		t.Helper()
+		reply, err := cmdHandler.Handle(shop.Phone, parser.Parse(text))
+		if err != nil {
+			t.Fatalf("%q error: %v", text, err)
+		}
+		return reply
+	}
+
+	run("sell cocacola 2")
+	db.First(coke, coke.ID)
+	if coke.CurrentStock != 18 {
+		t.Errorf("stock after selling by alias = %d; want 18", coke.CurrentStock)
+	}
+
+	if reply := run("alias add coca-cola 500ml coke"); !strings.Contains(reply, "'coke' now means Coca-Cola 500ml") {
+		t.Errorf("alias add reply = %q", reply)
+	}
+	if reply := run("alias add bread coke"); !strings.Contains(reply, "already names another product") {
+		t.Errorf("taken alias reply = %q", reply)
+	}
+	if reply := run("alias coke"); !strings.Contains(reply, "• cocacola") || !strings.Contains(reply, "• coke") {
+		t.Errorf("alias list reply = %q", reply)
+	}
+	if reply := run("alias remove coke"); !strings.Contains(reply, "no longer means Coca-Cola 500ml") {
+		t.Errorf("alias remove reply = %q", reply)
+	}
+	if reply := run("alias remove coke"); !strings.Contains(reply, "No product goes by 'coke'") {
+		t.Errorf("removing a missing alias reply = %q", reply)
+	}
+
+	// An inactive product is not found by its alias
+	db.Model(coke).Update("is_active", false)
+	if _, err := productRepo.GetByShopAndName(shop.ID, "cocacola"); err == nil {
+		t.Error("an inactive product should not be found by alias")
+	}
+	db.Model(coke).Update("is_active", true)
+
+	h := handlers.NewProductHandler(productRepo)
+	app := fiber.New(fiber.Config{ErrorHandler: utils.ErrorHandler})
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("shop_id", shop.ID)
+		return c.Next()
+	})
+	app.Post("/products/:id/aliases", h.AddAlias)
+	app.Delete("/products/:id/aliases/:alias", h.DeleteAlias)
+
+	post := func(id uint, body string) (int, []string) {
+		t.Helper()
+		req := httptest.NewRequest("POST", fmt.Sprintf("/products/%d/aliases", id), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("POST aliases failed: %v", err)
+		}
+		var out struct {
+			Aliases []string `json:"aliases"`
+		}
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out.Aliases
+	}
+
+	if status, aliases := post(bread.ID, `{"alias": "Mkate"}`); status != fiber.StatusCreated || fmt.Sprint(aliases) != "[mkate]" {
+		t.Errorf("POST alias = %d %v; want 201 [mkate]", status, aliases)
+	}
+	if status, _ := post(bread.ID, `{"alias": "cocacola"}`); status != fiber.StatusConflict {
+		t.Errorf("POST taken alias: status %d; want 409", status)
+	}
+	if status, _ := post(bread.ID, `{"alias": "white bread"}`); status != fiber.StatusBadRequest {
+		t.Errorf("POST two-word alias: status %d; want 400", status)
+	}
+	if status, _ := post(theirs.ID, `{"alias": "soda"}`); status == fiber.StatusCreated {
+		t.Error("another shop's product should be refused")
+	}
+
+	resp, _ := app.Test(httptest.NewRequest("DELETE", fmt.Sprintf("/products/%d/aliases/mkate", bread.ID), nil))
+	if resp.StatusCode != fiber.StatusOK {
+		t.Errorf("DELETE alias: status %d; want 200", resp.StatusCode)
+	}
+	resp, _ = app.Test(httptest.NewRequest("DELETE", fmt.Sprintf("/products/%d/aliases/mkate", bread.ID), nil))
+	if resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("DELETE missing alias: status %d; want 404", resp.StatusCode)
+	}
+}
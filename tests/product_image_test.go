@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/handlers"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/images"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/storage"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// testPNG returns a w x h PNG
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode() error: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// hugePNG returns a small PNG whose header claims w x h pixels
+func hugePNG(t *testing.T, w, h uint32) []byte {
+	t.Helper()
+	data := testPNG(t, 1, 1)
+	// The IHDR chunk follows the 8-byte signature: length, type, then
+	// width and height, and its CRC covers the type and 13 data bytes
+	binary.BigEndian.PutUint32(data[16:], w)
+	binary.BigEndian.PutUint32(data[20:], h)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+// TestProductImageUpload tests uploading, replacing and rejecting product images
+func TestProductImageUpload(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{})
+	db.Create(&models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true})
+	productRepo := repository.NewProductRepository(db)
+	bread := &models.Product{ShopID: 1, Name: "Bread", SellingPrice: 60, IsActive: true}
+	productRepo.Create(bread)
+
+	root := t.TempDir()
+	h := handlers.NewProductHandler(productRepo)
+	h.SetImageStore(storage.NewLocalStore(root), "/static/")
+
+	app := fiber.New(fiber.Config{ErrorHandler: utils.ErrorHandler})
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("shop_id", uint(1))
+		return c.Next()
+	})
+	app.Post("/products/:id/image", h.UploadImage)
+
+	upload := func(data []byte) (int, models.Product) {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, _ := form.CreateFormFile("image", "photo.png")
+		part.Write(data)
+		form.Close()
+
+		req := httptest.NewRequest("POST", fmt.Sprintf("/products/%d/image", bread.ID), &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("upload error: %v", err)
+		}
+		var product models.Product
+		json.NewDecoder(resp.Body).Decode(&product)
+		return resp.StatusCode, product
+	}
+	file := func(url string) string {
+		return filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(url, "/static/")))
+	}
+
+	status, first := upload(testPNG(t, 800, 400))
+	if status != fiber.StatusOK {
+		t.Fatalf("status = %d; want 200", status)
+	}
+	if !strings.HasPrefix(first.ImageURL, "/static/products/1/") || !strings.HasSuffix(first.ImageURL, ".png") {
+		t.Errorf("image_url = %q", first.ImageURL)
+	}
+	thumbFile, err := os.Open(file(first.ThumbnailURL))
+	if err != nil {
+		t.Fatalf("thumbnail not saved: %v", err)
+	}
+	thumb, err := jpeg.DecodeConfig(thumbFile)
+	thumbFile.Close()
+	if err != nil || thumb.Width != images.ThumbnailSize || thumb.Height != images.ThumbnailSize/2 {
+		t.Errorf("thumbnail = %dx%d (%v); want %dx%d", thumb.Width, thumb.Height, err, images.ThumbnailSize, images.ThumbnailSize/2)
+	}
+
+	// Replacing removes the old files
+	status, second := upload(testPNG(t, 50, 50))
+	if status != fiber.StatusOK || second.ImageURL == first.ImageURL {
+		t.Fatalf("replace: status %d, image_url %q", status, second.ImageURL)
+	}
+	for _, url := range []string{first.ImageURL, first.ThumbnailURL} {
+		if _, err := os.Stat(file(url)); !os.IsNotExist(err) {
+			t.Errorf("old image %s not removed", url)
+		}
+	}
+	saved, _ := productRepo.GetByID(bread.ID)
+	if saved.ImageURL != second.ImageURL || saved.ThumbnailURL != second.ThumbnailURL {
+		t.Errorf("product not updated: %q %q", saved.ImageURL, saved.ThumbnailURL)
+	}
+
+	// The content is checked, not the file name
+	if status, _ := upload([]byte("<html>not an image</html>")); status != fiber.StatusUnsupportedMediaType {
+		t.Errorf("non-image status = %d; want 415", status)
+	}
+	if status, _ := upload(bytes.Repeat([]byte{0}, images.MaxUploadSize+1)); status != fiber.StatusRequestEntityTooLarge {
+		t.Errorf("oversized status = %d; want 413", status)
+	}
+	// Refused from the header, before 10 GB of pixels are allocated
+	if status, _ := upload(hugePNG(t, 50000, 50000)); status != fiber.StatusRequestEntityTooLarge {
+		t.Errorf("50000x50000 status = %d; want 413", status)
+	}
+	if _, err := images.Thumbnail(hugePNG(t, 50000, 50000), images.ThumbnailSize); err != images.ErrTooManyPixels {
+		t.Errorf("Thumbnail() of 50000x50000 error = %v; want ErrTooManyPixels", err)
+	}
+}
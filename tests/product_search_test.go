@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/handlers"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/routes"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestProductSearchVelocity tests that search puts the products that sell
+// most and most recently first
+func TestProductSearchVelocity(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.Sale{}, &models.DailySummary{},
+		&models.WeeklySummary{}, &models.MonthlySummary{}, &models.AuditLog{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	db.Create(shop)
+	other := &models.Shop{Name: "Other", Phone: "+254700000009", IsActive: true}
+	db.Create(other)
+
+	products := map[string]*models.Product{}
+	for _, name := range []string{"Cheese", "Chapati", "Chai", "Mchele", "Bread"} {
+		p := &models.Product{ShopID: shop.ID, Name: name, SellingPrice: 50, CurrentStock: 10, IsActive: true}
+		db.Create(p)
+		products[name] = p
+	}
+	hidden := &models.Product{ShopID: shop.ID, Name: "Chips", SellingPrice: 50, IsActive: true}
+	db.Create(hidden)
+	db.Model(hidden).Update("is_active", false)
+
+	now := time.Now()
+	sell := func(shopID, productID uint, at time.Time) {
+		db.Create(&models.Sale{ShopID: shopID, ProductID: productID, Quantity: 1, TotalAmount: 50, CreatedAt: at})
+	}
+	// Chapati: 15 sales this month, the last today
+	for i := 0; i < 15; i++ {
+		sell(shop.ID, products["Chapati"].ID, now.Add(-time.Duration(i)*36*time.Hour))
+	}
+	// Chai: 3 sales, the last ten days ago
+	for i := 0; i < 3; i++ {
+		sell(shop.ID, products["Chai"].ID, now.AddDate(0, 0, -10-i))
+	}
+	// Cheese sold a lot, but two months ago, and at another shop
+	for i := 0; i < 20; i++ {
+		sell(shop.ID, products["Cheese"].ID, now.AddDate(0, 0, -60))
+		sell(other.ID, products["Cheese"].ID, now)
+	}
+
+	repo := repository.NewProductRepository(db)
+	results, err := repo.Search(shop.ID, "CH", repository.SearchSortVelocity, 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	var names []string
+	for _, r := range results {
+		names = append(names, r.Name)
+	}
+	if got := strings.Join(names, ","); got != "Chapati,Chai,Cheese,Mchele" {
+		t.Fatalf("velocity order = %s; want Chapati,Chai,Cheese,Mchele", got)
+	}
+	// 15 sales in 30 days, last sold today
+	if math.Abs(results[0].AvgDailySales-0.5) > 0.001 || results[0].RecencyScore != 1 ||
+		math.Abs(results[0].SearchRank-(0.5*0.7+0.3)) > 0.001 {
+		t.Errorf("Chapati = avg %.3f recency %.2f rank %.3f", results[0].AvgDailySales, results[0].RecencyScore, results[0].SearchRank)
+	}
+	if results[1].RecencyScore != 0.5 || results[2].SearchRank != 0 {
+		t.Errorf("Chai recency %.2f, Cheese rank %.3f; want 0.5, 0", results[1].RecencyScore, results[2].SearchRank)
+	}
+
+	results, err = repo.Search(shop.ID, "ch", repository.SearchSortName, 2)
+	if err != nil || len(results) != 2 || results[0].Name != "Chai" || results[1].Name != "Chapati" {
+		t.Errorf("name order = %+v, %v; want Chai, Chapati", results, err)
+	}
+
+	app := serverApp(t, db, routes.RouteConfig{ProductHandler: handlers.NewProductHandler(repo)}, shop)
+	get := func(path string) (int, []byte) {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		out, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, out
+	}
+
+	status, out := get("/api/v1/products/search?q=ch&sort=velocity&limit=2")
+	var body struct {
+		Data []struct {
+			Name       string  `json:"name"`
+			SearchRank float64 `json:"search_rank"`
+		} `json:"data"`
+		Count int `json:"count"`
+	}
+	json.Unmarshal(out, &body)
+	if status != fiber.StatusOK || body.Count != 2 || body.Data[0].Name != "Chapati" || body.Data[0].SearchRank <= body.Data[1].SearchRank {
+		t.Errorf("GET search: status %d %s", status, out)
+	}
+	for _, path := range []string{"/api/v1/products/search", "/api/v1/products/search?q=ch&sort=price"} {
+		if status, out := get(path); status != fiber.StatusBadRequest {
+			t.Errorf("GET %s: status %d %s; want 400", path, status, out)
+		}
+	}
+
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		repo,
+		repository.NewSaleRepository(db),
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	reply, err := cmdHandler.Handle(shop.Phone, services.NewCommandParser(nil, nil).Parse("find ch"))
+	if err != nil {
+		t.Fatalf("find ch: %v", err)
+	}
+	chapati, chai, cheese := strings.Index(reply, "Chapati"), strings.Index(reply, "Chai"), strings.Index(reply, "Cheese")
+	if chapati < 0 || chapati > chai || chai > cheese || strings.Contains(reply, "Chips") {
+		t.Errorf("find ch = %q; want Chapati, Chai then Cheese", reply)
+	}
+}
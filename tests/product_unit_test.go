@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+)
+
+// TestProductUnitConversion tests converting bulk units to base units and
+// describing stock with its bulk equivalent
+func TestProductUnitConversion(t *testing.T) {
+	soda := &models.Product{Name: "Soda", Unit: "bottle"}
+	if err := soda.SetPurchaseUnit("Crates", 24); err != nil {
+		t.Fatalf("SetPurchaseUnit() error: %v", err)
+	}
+	if soda.PurchaseUnit != "crate" {
+		t.Errorf("purchase unit = %q; want crate", soda.PurchaseUnit)
+	}
+
+	tests := []struct {
+		qty  int
+		unit string
+		want int
+		ok   bool
+	}{
+		{2, "crate", 48, true},
+		{2, "crates", 48, true},
+		{5, "bottles", 5, true},
+		{5, "", 5, true},
+		{1, "box", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := soda.ToBaseUnits(tt.qty, tt.unit)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ToBaseUnits(%d, %q) = %d, %v; want %d, %v", tt.qty, tt.unit, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	labels := map[int]string{
+		53: "53 bottles (= 2 crates + 5 bottles)",
+		48: "48 bottles (= 2 crates)",
+		25: "25 bottles (= 1 crate + 1 bottle)",
+		7:  "7 bottles",
+	}
+	for qty, want := range labels {
+		if got := soda.StockLabel(qty); got != want {
+			t.Errorf("StockLabel(%d) = %q; want %q", qty, got, want)
+		}
+	}
+
+	for _, factor := range []int{0, -24} {
+		if err := soda.SetPurchaseUnit("crate", factor); !errors.Is(err, models.ErrInvalidUnitFactor) {
+			t.Errorf("SetPurchaseUnit(crate, %d) error = %v; want ErrInvalidUnitFactor", factor, err)
+		}
+	}
+}
+
+// TestWhatsAppBulkUnits tests restocking and selling by the crate over WhatsApp
+func TestWhatsAppBulkUnits(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.ProductAlias{}, &models.StockMovement{}, &models.Sale{},
+		&models.DailySummary{}, &models.AuditLog{}, &models.PriceHistory{})
+
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanPro, IsActive: true}
+	db.Create(shop)
+
+	productRepo := repository.NewProductRepository(db)
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		productRepo,
+		repository.NewSaleRepository(db),
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	parser := services.NewCommandParser(nil, nil)
+	send := func(message string) string {
+		reply, err := cmdHandler.Handle(shop.Phone, parser.Parse(message))
+		if err != nil {
+			t.Fatalf("Handle(%q) error: %v", message, err)
+		}
+		return reply
+	}
+	stock := func() int {
+		product, _ := productRepo.GetByShopAndName(shop.ID, "Soda")
+		return product.CurrentStock
+	}
+
+	send("add soda 50 10")
+	if reply := send("add soda 50 1 crate"); !strings.Contains(reply, "not counted in 'crate'") {
+		t.Errorf("crate before a bulk unit is set: %q", reply)
+	}
+	if reply := send("unit soda crate 0"); !strings.Contains(reply, "whole number") {
+		t.Errorf("zero factor: %q", reply)
+	}
+
+	if reply := send("unit soda crate 24 bottle"); !strings.Contains(reply, "1 crate = 24 bottle") {
+		t.Errorf("unit reply = %q", reply)
+	}
+	if reply := send("add soda 50 2 crates"); !strings.Contains(reply, "(+48)") || stock() != 58 {
+		t.Errorf("add 2 crates: stock %d, reply %q; want +48 to 58", stock(), reply)
+	}
+	if reply := send("stock soda"); !strings.Contains(reply, "58 bottles (= 2 crates + 10 bottles)") {
+		t.Errorf("stock reply = %q", reply)
+	}
+	if reply := send("sell soda 1 crate"); !strings.Contains(reply, "Soda x24") || stock() != 34 {
+		t.Errorf("sell 1 crate: stock %d, reply %q; want 24 sold leaving 34", stock(), reply)
+	}
+	send("sell soda 4")
+	send("remove soda 1 crate")
+	if got := stock(); got != 6 {
+		t.Errorf("stock = %d; want 6", got)
+	}
+
+	send("unit soda off")
+	if product, _ := productRepo.GetByShopAndName(shop.ID, "Soda"); product.PurchaseUnit != "" || product.Unit != "bottle" {
+		t.Errorf("after clearing: purchase unit %q, unit %q; want none and bottle", product.PurchaseUnit, product.Unit)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	supplierhandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/supplier"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/email"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestPurchaseOrderPDFAndSend tests the purchase order PDF and sending it
+// to the supplier by email and by WhatsApp, which marks the order sent
+func TestPurchaseOrderPDFAndSend(t *testing.T) {
+	mock := &mockSendGrid{}
+	server := mock.server(t)
+	defer server.Close()
+
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.AuditLog{},
+		&models.Supplier{}, &models.Order{}, &models.OrderItem{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Address: "Kawangware", Plan: models.PlanPro, IsActive: true}
+	db.Create(shop)
+	milk := &models.Product{ShopID: shop.ID, Name: "Milk", Unit: "packet", CostPrice: 45, IsActive: true}
+	db.Create(milk)
+	brookside := &models.Supplier{ShopID: shop.ID, Name: "Brookside", Phone: "+254700000001", Email: "orders@brookside.example"}
+	bidco := &models.Supplier{ShopID: shop.ID, Name: "Bidco", Phone: "+254700000002"}
+	db.Create(brookside)
+	db.Create(bidco)
+
+	orderRepo := repository.NewOrderRepository(db)
+	auditRepo := repository.NewAuditLogRepository(db)
+	h := supplierhandler.New(repository.NewSupplierRepository(db), orderRepo, repository.NewProductRepository(db))
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("shop_id", shop.ID)
+		c.Locals("shop", shop)
+		return c.Next()
+	})
+	app.Post("/orders", h.CreateOrder)
+	app.Get("/orders/:id/pdf", h.GetOrderPDF)
+	app.Post("/orders/:id/send", h.SendOrder)
+	call := func(method, target, body string) (int, []byte) {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, target, err)
+		}
+		out, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, out
+	}
+	create := func(supplier *models.Supplier, delivery string) *models.Order {
+		t.Helper()
+		status, out := call("POST", "/orders", fmt.Sprintf(
+			`{"supplier_id": %d, "status": "pending", "expected_delivery": %q, "items": [{"product_id": %d, "quantity": 24, "unit_cost": 45}]}`,
+			supplier.ID, delivery, milk.ID))
+		if status != fiber.StatusCreated {
+			t.Fatalf("create order: status %d %s", status, out)
+		}
+		var order models.Order
+		json.Unmarshal(out, &order)
+		return &order
+	}
+
+	if status, out := call("POST", "/orders", fmt.Sprintf(`{"supplier_id": %d, "expected_delivery": "next week"}`, brookside.ID)); status != fiber.StatusBadRequest {
+		t.Errorf("bad expected_delivery: status %d %s; want 400", status, out)
+	}
+	order := create(brookside, "2024-12-02")
+	if order.ExpectedDelivery == nil || order.ExpectedDelivery.Format("2006-01-02") != "2024-12-02" {
+		t.Fatalf("expected_delivery = %v; want 2024-12-02", order.ExpectedDelivery)
+	}
+
+	status, out := call("GET", fmt.Sprintf("/orders/%d/pdf", order.ID), "")
+	if status != fiber.StatusOK || !bytes.HasPrefix(out, []byte("%PDF")) {
+		t.Fatalf("GET pdf: status %d, %d bytes", status, len(out))
+	}
+
+	// Nothing is set up to send with yet
+	if status, out := call("POST", fmt.Sprintf("/orders/%d/send", order.ID), ""); status != fiber.StatusServiceUnavailable {
+		t.Errorf("send without senders: status %d %s; want 503", status, out)
+	}
+
+	var whatsapp []string
+	emailSvc := email.New(&email.Config{APIKey: "sg-key", FromEmail: "orders@dukapos.io", BaseURL: server.URL})
+	h.SetOrderSenders(func(phone, message string) error {
+		whatsapp = append(whatsapp, phone+": "+message)
+		return nil
+	}, emailSvc)
+	h.SetAuditRepo(auditRepo)
+
+	// Brookside has an email address, so it gets the PDF by email
+	status, out = call("POST", fmt.Sprintf("/orders/%d/send", order.ID), "")
+	var sent struct {
+		Order   models.Order `json:"order"`
+		Channel string       `json:"channel"`
+		SentTo  string       `json:"sent_to"`
+	}
+	json.Unmarshal(out, &sent)
+	if status != fiber.StatusOK || sent.Channel != "email" || sent.SentTo != brookside.Email ||
+		sent.Order.Status != models.OrderStatusSent || sent.Order.SentAt == nil {
+		t.Fatalf("send by email: status %d %s", status, out)
+	}
+	if len(mock.sent) != 1 {
+		t.Fatalf("emails sent = %d; want 1", len(mock.sent))
+	}
+	if subject := mock.sent[0]["subject"]; subject != fmt.Sprintf("Purchase order #%d from Mama Mboga", order.ID) {
+		t.Errorf("subject = %q", subject)
+	}
+	attachment := mock.sent[0]["attachments"].([]interface{})[0].(map[string]interface{})
+	pdf, _ := base64.StdEncoding.DecodeString(attachment["content"].(string))
+	if attachment["filename"] != fmt.Sprintf("purchase_order_%d.pdf", order.ID) || !bytes.HasPrefix(pdf, []byte("%PDF")) {
+		t.Errorf("attachment = %v (%d bytes)", attachment["filename"], len(pdf))
+	}
+	text := mock.sent[0]["content"].([]interface{})[1].(map[string]interface{})["value"].(string)
+	if !strings.Contains(text, "Milk x24") || !strings.Contains(text, "02 Dec 2024") {
+		t.Errorf("email text = %q; want the items and delivery date", text)
+	}
+
+	// Bidco has only a phone, so it gets the items on WhatsApp
+	other := create(bidco, "")
+	if status, out := call("POST", fmt.Sprintf("/orders/%d/send", other.ID), `{"channel": "email"}`); status != fiber.StatusBadRequest {
+		t.Errorf("email to a supplier without one: status %d %s; want 400", status, out)
+	}
+	status, out = call("POST", fmt.Sprintf("/orders/%d/send", other.ID), "")
+	if status != fiber.StatusOK || !strings.Contains(string(out), `"channel":"whatsapp"`) {
+		t.Fatalf("send by WhatsApp: status %d %s", status, out)
+	}
+	if len(whatsapp) != 1 || !strings.HasPrefix(whatsapp[0], bidco.Phone+": ") || !strings.Contains(whatsapp[0], "• Milk x24") ||
+		!strings.Contains(whatsapp[0], "Total: KSh 1080") {
+		t.Errorf("WhatsApp messages = %q", whatsapp)
+	}
+
+	logs, _ := auditRepo.GetByAction(shop.ID, "order_sent", 10)
+	if len(logs) != 2 || logs[0].EntityType != "order" {
+		t.Fatalf("audit logs = %+v; want one per order sent", logs)
+	}
+	if !strings.Contains(logs[1].Details+logs[0].Details, "pending -> sent") {
+		t.Errorf("audit details = %q, %q", logs[0].Details, logs[1].Details)
+	}
+
+	db.Model(&models.Order{}).Where("id = ?", other.ID).Update("status", models.OrderStatusDelivered)
+	if status, out := call("POST", fmt.Sprintf("/orders/%d/send", other.ID), ""); status != fiber.StatusConflict {
+		t.Errorf("sending a delivered order: status %d %s; want 409", status, out)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/handlers"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/export"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/qr"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestReceiptLinker tests that receipt links are signed per sale
+func TestReceiptLinker(t *testing.T) {
+	linker := qr.NewReceiptLinker("https://receipt.dukapos.io/", "test-secret")
+
+	url := linker.URL(42)
+	if !strings.HasPrefix(url, "https://receipt.dukapos.io/r/42?s=") {
+		t.Fatalf("unexpected receipt URL %q", url)
+	}
+	sig := url[strings.Index(url, "?s=")+3:]
+	if !linker.Verify(42, sig) {
+		t.Error("signature should verify for its own sale")
+	}
+	if linker.Verify(43, sig) {
+		t.Error("signature should not verify for another sale")
+	}
+	if qr.NewReceiptLinker("https://receipt.dukapos.io", "other-secret").Verify(42, sig) {
+		t.Error("signature should not verify under another secret")
+	}
+}
+
+// TestReceiptPDFAndPublicPage tests the PDF receipt and the page its QR code links to
+func TestReceiptPDFAndPublicPage(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.Sale{}, &models.Customer{})
+
+	shop := &models.Shop{Name: "Mama <b>Mboga</b>", Phone: "+254712345678", IsActive: true}
+	db.Create(shop)
+	product := &models.Product{ShopID: shop.ID, Name: "Bread", SellingPrice: 60, IsActive: true}
+	db.Create(product)
+	sale := &models.Sale{ShopID: shop.ID, ProductID: product.ID, Quantity: 2, UnitPrice: 60, TotalAmount: 120,
+		PaymentMethod: models.PaymentMpesa, MpesaReceipt: "QAB1234567"}
+	db.Create(sale)
+
+	linker := qr.NewReceiptLinker("https://receipt.dukapos.io", "test-secret")
+	h := handlers.NewReceiptHandler(repository.NewSaleRepository(db), repository.NewShopRepository(db), linker)
+
+	app := fiber.New()
+	app.Get("/r/:saleID", h.PublicReceipt)
+	app.Get("/sales/:id/receipt.pdf", func(c *fiber.Ctx) error {
+		c.Locals("shop_id", shop.ID)
+		return c.Next()
+	}, h.GetReceiptPDF)
+
+	resp, err := app.Test(httptest.NewRequest("GET", fmt.Sprintf("/sales/%d/receipt.pdf", sale.ID), nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusOK || !bytes.HasPrefix(body, []byte("%PDF")) {
+		t.Fatalf("expected a PDF, got status %d: %.40q", resp.StatusCode, body)
+	}
+	if !bytes.Contains(body, []byte("/Subtype /Image")) {
+		t.Error("PDF receipt should embed the QR code image")
+	}
+
+	path := strings.TrimPrefix(linker.URL(sale.ID), "https://receipt.dukapos.io")
+	resp, err = app.Test(httptest.NewRequest("GET", path, nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	page := string(body)
+	if resp.StatusCode != fiber.StatusOK || !strings.Contains(page, "QAB1234567") || !strings.Contains(page, "Bread") {
+		t.Errorf("unexpected receipt page (status %d):\n%s", resp.StatusCode, page)
+	}
+	if strings.Contains(page, "<b>Mboga</b>") {
+		t.Error("shop name should be HTML escaped")
+	}
+
+	for _, bad := range []string{fmt.Sprintf("/r/%d", sale.ID), fmt.Sprintf("/r/%d?s=0000000000000000", sale.ID)} {
+		resp, err = app.Test(httptest.NewRequest("GET", bad, nil))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		if resp.StatusCode != fiber.StatusNotFound {
+			t.Errorf("GET %s: expected 404, got %d", bad, resp.StatusCode)
+		}
+	}
+}
+
+// TestReceiptPDFWithoutQRCode tests that a receipt still renders without a QR image
+func TestReceiptPDFWithoutQRCode(t *testing.T) {
+	data, err := (&export.ReceiptExporter{}).ExportPDF(export.ReceiptData{
+		Shop: models.Shop{Name: "Duka"},
+		Sale: models.Sale{ID: 1, Quantity: 1, UnitPrice: 47.5, TotalAmount: 50, RoundingAdjustment: 2.5},
+	})
+	if err != nil {
+		t.Fatalf("ExportPDF() error: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF")) {
+		t.Error("expected PDF output")
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/handlers"
+	exporthandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/export"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/routes"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/export"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestPercentChange tests percentage changes, including from nothing and
+// from a loss
+func TestPercentChange(t *testing.T) {
+	tests := []struct {
+		current, previous float64
+		want              string
+	}{
+		{1120, 1000, "↑12%"},
+		{950, 1000, "↓5%"},
+		{1000, 1000, "→0%"},
+		{50, -100, "↑150%"}, // a loss turned into a profit
+		{-200, -100, "↓100%"},
+	}
+	for _, tt := range tests {
+		change := export.PercentChange(tt.current, tt.previous)
+		if change == nil {
+			t.Errorf("PercentChange(%v, %v) = nil", tt.current, tt.previous)
+			continue
+		}
+		if got := export.FormatChange(*change); got != tt.want {
+			t.Errorf("PercentChange(%v, %v) = %.1f, %s; want %s", tt.current, tt.previous, *change, got, tt.want)
+		}
+	}
+	if change := export.PercentChange(500, 0); change != nil {
+		t.Errorf("PercentChange(500, 0) = %v; want nil for a zero baseline", *change)
+	}
+}
+
+// TestReportsVsLastPeriod tests that weekly and monthly reports compare
+// sales, profit and transactions with the period before, on WhatsApp, the
+// API and in exports
+func TestReportsVsLastPeriod(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.Sale{}, &models.DailySummary{},
+		&models.WeeklySummary{}, &models.MonthlySummary{}, &models.AuditLog{}, &models.ProductAlias{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanFree, IsActive: true}
+	db.Create(shop)
+	bread := &models.Product{ShopID: shop.ID, Name: "Bread", SellingPrice: 60, CostPrice: 50, CurrentStock: 100, IsActive: true}
+	db.Create(bread)
+	sell := func(qty int, at time.Time) {
+		db.Create(&models.Sale{ShopID: shop.ID, ProductID: bread.ID, Quantity: qty, UnitPrice: 60,
+			TotalAmount: 60 * float64(qty), CostAmount: 50 * float64(qty), Profit: 10 * float64(qty), CreatedAt: at})
+	}
+	now := time.Now()
+	sell(4, now)                   // this week: KSh 240
+	sell(2, now.AddDate(0, 0, -1)) // this week: KSh 120
+	sell(5, now.AddDate(0, 0, -10))
+
+	saleRepo := repository.NewSaleRepository(db)
+	summaryRepo := repository.NewDailySummaryRepository(db)
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		repository.NewProductRepository(db),
+		saleRepo,
+		summaryRepo,
+		repository.NewAuditLogRepository(db),
+	)
+	parser := services.NewCommandParser(nil, nil)
+	run := func(text string) string {
+		t.Helper()
+		reply, err := cmdHandler.Handle(shop.Phone, parser.Parse(text))
+		if err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+		return reply
+	}
+
+	// KSh 360 over 2 sales against KSh 300 over 1
+	reply := run("weekly")
+	for _, want := range []string{"Total Sales: KSh 360 (↑20% vs last week)", "Transactions: 2 (↑100% vs last week)", "Profit: KSh 60 (↑20% vs last week)"} {
+		if !strings.Contains(reply, want) {
+			t.Errorf("weekly report missing %q:\n%s", want, reply)
+		}
+	}
+	if reply := run("monthly"); !strings.Contains(reply, "Total Sales: KSh 660 (none last month)") {
+		t.Errorf("monthly report should have nothing to compare with:\n%s", reply)
+	}
+
+	// The API report
+	reports := handlers.NewReportHandler(saleRepo, repository.NewProductRepository(db), summaryRepo)
+	exports := exporthandler.NewExportHandler(repository.NewProductRepository(db), saleRepo, summaryRepo)
+	app := serverApp(t, db, routes.RouteConfig{ReportHandler: reports, ExportHandler: exports}, shop)
+	get := func(target string) string {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest("GET", target, nil))
+		if err != nil {
+			t.Fatalf("GET %s: %v", target, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("GET %s: status %d %s", target, resp.StatusCode, body)
+		}
+		return string(body)
+	}
+
+	var weekly struct {
+		Comparison export.Comparison `json:"comparison"`
+	}
+	json.Unmarshal([]byte(get("/api/v1/reports/weekly")), &weekly)
+	c := weekly.Comparison
+	if c.Period != "last week" || c.PreviousSales != 300 || c.PreviousTransactions != 1 ||
+		c.SalesChange == nil || *c.SalesChange != 20 || c.TransactionsChange == nil || *c.TransactionsChange != 100 {
+		t.Errorf("weekly comparison = %+v; want up 20%% on KSh 300", c)
+	}
+
+	// The exported report compares the 7 days to yesterday with the 7 before
+	yesterday := now.AddDate(0, 0, -1)
+	query := fmt.Sprintf("/api/v1/export/report?from=%s&to=%s&product_id=%d",
+		yesterday.AddDate(0, 0, -6).Format("2006-01-02"), yesterday.Format("2006-01-02"), bread.ID)
+	var report struct {
+		Comparison *export.Comparison `json:"comparison"`
+	}
+	json.Unmarshal([]byte(get(query+"&format=json")), &report)
+	if c := report.Comparison; c == nil || c.PreviousSales != 300 || c.SalesChange == nil || *c.SalesChange != -60 ||
+		c.Period != yesterday.AddDate(0, 0, -13).Format("2006-01-02")+" to "+yesterday.AddDate(0, 0, -7).Format("2006-01-02") {
+		t.Errorf("exported comparison = %+v; want KSh 120 against 300 the week before", c)
+	}
+	if csv := get(query); !strings.Contains(csv, "Compared With") || !strings.Contains(csv, "Total Sales,KSh 300.00,-60.0%") {
+		t.Errorf("CSV report missing the comparison:\n%s", csv)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	emailhandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/email"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/email"
+	"github.com/gofiber/fiber/v2"
+)
+
+// mockSendGrid records every mail sent through it
+type mockSendGrid struct {
+	mu   sync.Mutex
+	sent []map[string]interface{}
+}
+
+func (m *mockSendGrid) server(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/mail/send" || r.Header.Get("Authorization") != "Bearer sg-key" {
+			t.Errorf("unexpected request %s with %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		m.mu.Lock()
+		m.sent = append(m.sent, body)
+		m.mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+}
+
+func (m *mockSendGrid) recipients() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var to []string
+	for _, mail := range m.sent {
+		p := mail["personalizations"].([]interface{})[0].(map[string]interface{})
+		to = append(to, p["to"].([]interface{})[0].(map[string]interface{})["email"].(string))
+	}
+	return to
+}
+
+// TestReportMailer tests scheduled and on-demand report emails with a PDF
+// attached, and that a bounce turns them off and tells the owner
+func TestReportMailer(t *testing.T) {
+	mock := &mockSendGrid{}
+	server := mock.server(t)
+	defer server.Close()
+
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.Sale{}, &models.ReportEmailPreference{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Email: "Owner@example.com", Plan: models.PlanPro, IsActive: true}
+	db.Create(shop)
+	bread := &models.Product{ShopID: shop.ID, Name: "Bread", SellingPrice: 60, CurrentStock: 10}
+	db.Create(bread)
+	yesterday := time.Now().AddDate(0, 0, -1)
+	db.Create(&models.Sale{ShopID: shop.ID, ProductID: bread.ID, Quantity: 2, UnitPrice: 60, TotalAmount: 120, CreatedAt: yesterday})
+
+	prefRepo := repository.NewReportEmailPreferenceRepository(db)
+	mailer := email.NewReportMailer(
+		email.New(&email.Config{APIKey: "sg-key", FromEmail: "noreply@dukapos.com", BaseURL: server.URL}),
+		prefRepo, repository.NewShopRepository(db), repository.NewSaleRepository(db))
+	var whatsapp []string
+	mailer.SetWhatsAppSender(func(phone, message string) error {
+		whatsapp = append(whatsapp, phone+": "+message)
+		return nil
+	})
+
+	// Nothing is sent until the shop turns reports on
+	if err := mailer.SendScheduled(); err != nil {
+		t.Fatalf("SendScheduled() error: %v", err)
+	}
+	if len(mock.sent) != 0 {
+		t.Fatalf("sent %d emails with reports off; want 0", len(mock.sent))
+	}
+
+	pref, _ := mailer.GetPreference(shop.ID)
+	pref.Enabled = true
+	pref.Frequency = "hourly"
+	if err := mailer.SavePreference(pref); err != email.ErrInvalidFrequency {
+		t.Errorf("SavePreference(hourly) = %v; want ErrInvalidFrequency", err)
+	}
+	pref.Frequency = models.ReportFrequencyDaily
+	if err := mailer.SavePreference(pref); err != nil {
+		t.Fatalf("SavePreference() error: %v", err)
+	}
+
+	// The daily report for yesterday goes to the shop's email once
+	for i := 0; i < 2; i++ {
+		if err := mailer.SendScheduled(); err != nil {
+			t.Fatalf("SendScheduled() error: %v", err)
+		}
+	}
+	if got := mock.recipients(); len(got) != 1 || got[0] != "owner@example.com" {
+		t.Fatalf("scheduled recipients = %v; want owner@example.com once", got)
+	}
+	attachments, _ := mock.sent[0]["attachments"].([]interface{})
+	if len(attachments) != 1 {
+		t.Fatalf("attachments = %v; want the report PDF", mock.sent[0]["attachments"])
+	}
+	pdf := attachments[0].(map[string]interface{})
+	content, _ := base64.StdEncoding.DecodeString(pdf["content"].(string))
+	if pdf["type"] != "application/pdf" || !bytes.HasPrefix(content, []byte("%PDF")) {
+		t.Errorf("attachment %v is not a PDF", pdf["filename"])
+	}
+	if subject := mock.sent[0]["subject"].(string); !strings.Contains(subject, "Daily Sales Report") {
+		t.Errorf("subject = %q; want the daily report", subject)
+	}
+
+	// "Send me this month now" goes to the listed recipients
+	pref.Recipients = "books@example.com, Owner@example.com"
+	if err := mailer.SavePreference(pref); err != nil {
+		t.Fatalf("SavePreference() error: %v", err)
+	}
+	sentTo, err := mailer.SendNow(shop.ID, email.ReportPeriodMonth)
+	if err != nil {
+		t.Fatalf("SendNow() error: %v", err)
+	}
+	if len(sentTo) != 2 || len(mock.sent) != 3 {
+		t.Errorf("sent to %v (%d emails); want both recipients", sentTo, len(mock.sent))
+	}
+	if _, err := mailer.SendNow(shop.ID, "year"); err != email.ErrInvalidPeriod {
+		t.Errorf("SendNow(year) = %v; want ErrInvalidPeriod", err)
+	}
+
+	// An unrelated bounce changes nothing
+	if n, _ := mailer.HandleBounce("ks@example.com", "mailbox full"); n != 0 {
+		t.Errorf("unrelated bounce disabled %d shops; want 0", n)
+	}
+
+	// SendGrid events are refused until a token is configured
+	handler := emailhandler.New(nil)
+	handler.SetReportMailer(mailer)
+	app := fiber.New()
+	app.Post("/webhook/sendgrid/events", handler.EventWebhook)
+	bounce := func(token string) int {
+		body := `[{"email":"books@example.com","event":"bounce","reason":"550 mailbox does not exist"}]`
+		req := httptest.NewRequest("POST", "/webhook/sendgrid/events?token="+token, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+		return resp.StatusCode
+	}
+	if code := bounce(""); code != fiber.StatusForbidden {
+		t.Errorf("no token configured: status %d; want 403", code)
+	}
+	handler.SetEventWebhookToken("sg-events")
+	if code := bounce("wrong"); code != fiber.StatusForbidden {
+		t.Errorf("bad token: status %d; want 403", code)
+	}
+	if pref, _ = prefRepo.GetByShop(shop.ID); !pref.Enabled {
+		t.Fatal("a refused event disabled reports")
+	}
+	if code := bounce("sg-events"); code != fiber.StatusOK {
+		t.Fatalf("bounce event: status %d; want 200", code)
+	}
+	pref, _ = prefRepo.GetByShop(shop.ID)
+	if pref.Enabled || !strings.Contains(pref.DisabledReason, "books@example.com") {
+		t.Errorf("preference after bounce = %+v; want disabled with the reason", pref)
+	}
+	if len(whatsapp) != 1 || !strings.HasPrefix(whatsapp[0], shop.Phone) || !strings.Contains(whatsapp[0], "books@example.com") {
+		t.Errorf("WhatsApp notices = %v; want the owner told about the bounce", whatsapp)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+)
+
+// TestRoundingPolicyBoundaries tests rounding at and around the half-way points
+func TestRoundingPolicyBoundaries(t *testing.T) {
+	tests := []struct {
+		policy     models.RoundingPolicy
+		amount     float64
+		rounded    float64
+		adjustment float64
+	}{
+		{models.RoundingNone, 49.60, 49.60, 0},
+		{models.RoundingNone, 49.604, 49.60, 0},
+		{models.RoundingNearest1, 49.60, 50, 0.40},
+		{models.RoundingNearest1, 49.50, 50, 0.50},
+		{models.RoundingNearest1, 49.49, 49, -0.49},
+		{models.RoundingNearest1, 3 * 16.50, 50, 0.50},
+		{models.RoundingNearest1, 0.49, 0, -0.49},
+		{models.RoundingNearest5, 47.50, 50, 2.50},
+		{models.RoundingNearest5, 47.49, 45, -2.49},
+		{models.RoundingNearest5, 52.49, 50, -2.49},
+		{models.RoundingNearest5, 52.50, 55, 2.50},
+		{models.RoundingNearest5, 45, 45, 0},
+		{models.RoundingPolicy("unknown"), 47.50, 47.50, 0},
+	}
+
+	for _, tt := range tests {
+		rounded, adjustment := tt.policy.Round(tt.amount)
+		if rounded != tt.rounded || adjustment != tt.adjustment {
+			t.Errorf("%s.Round(%v) = %v, %v; want %v, %v", tt.policy, tt.amount, rounded, adjustment, tt.rounded, tt.adjustment)
+		}
+	}
+}
+
+// TestRoundingForPaymentMethod tests that M-Pesa always rounds to whole shillings
+func TestRoundingForPaymentMethod(t *testing.T) {
+	shop := &models.Shop{Rounding: models.RoundingNearest5}
+	if got := shop.RoundingFor(models.PaymentCash); got != models.RoundingNearest5 {
+		t.Errorf("cash rounding = %s; want %s", got, models.RoundingNearest5)
+	}
+	if got := shop.RoundingFor(models.PaymentMpesa); got != models.RoundingNearest1 {
+		t.Errorf("M-Pesa rounding = %s; want %s", got, models.RoundingNearest1)
+	}
+
+	var missing *models.Shop
+	if got := missing.RoundingFor(models.PaymentCash); got != models.RoundingNone {
+		t.Errorf("rounding without a shop = %s; want %s", got, models.RoundingNone)
+	}
+}
+
+// TestSellAppliesCashRounding tests that WhatsApp sales store the rounded
+// total and the adjustment
+func TestSellAppliesCashRounding(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.StockMovement{}, &models.Sale{}, &models.DailySummary{}, &models.AuditLog{})
+
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanFree, IsActive: true}
+	if err := db.Create(shop).Error; err != nil {
+		t.Fatalf("failed to create shop: %v", err)
+	}
+	db.Create(&models.Product{ShopID: shop.ID, Name: "Sweets", SellingPrice: 2.50, CostPrice: 1.50, CurrentStock: 100, LowStockThreshold: 2, IsActive: true})
+
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		repository.NewProductRepository(db),
+		repository.NewSaleRepository(db),
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	parser := services.NewCommandParser(nil, nil)
+	send := func(message string) string {
+		reply, err := cmdHandler.Handle(shop.Phone, parser.Parse(message))
+		if err != nil {
+			t.Fatalf("Handle(%q) error: %v", message, err)
+		}
+		return reply
+	}
+
+	if reply := send("set rounding 7"); !strings.Contains(reply, "Usage") {
+		t.Errorf("invalid rounding should show usage, got %q", reply)
+	}
+	if reply := send("set rounding 5"); !strings.Contains(reply, "nearest KSh 5") {
+		t.Fatalf("unexpected reply to set rounding 5: %q", reply)
+	}
+
+	// 19 x 2.50 = 47.50 rounds up to 50
+	send("sell sweets 19")
+
+	var sale models.Sale
+	if err := db.Last(&sale).Error; err != nil {
+		t.Fatalf("sale not recorded: %v", err)
+	}
+	if sale.TotalAmount != 50 || sale.RoundingAdjustment != 2.50 {
+		t.Errorf("sale total = %v, adjustment = %v; want 50, 2.50", sale.TotalAmount, sale.RoundingAdjustment)
+	}
+	if sale.Profit != 50-19*1.50 {
+		t.Errorf("sale profit = %v; want %v", sale.Profit, 50-19*1.50)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/config"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/routes"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// serverApp registers the server's routes, the same table main serves, with
+// the handlers in cfg and signs every request in as shop, so tests reach
+// handlers the way clients do.
+// The before handlers run ahead of the routes, e.g. to sign in with an API key.
+func serverApp(t *testing.T, db *gorm.DB, cfg routes.RouteConfig, shop *models.Shop, before ...fiber.Handler) *fiber.App {
+	t.Helper()
+	auth := services.NewAuthService(repository.NewShopRepository(db), &config.Config{JWTSecret: "test-secret", JWTExpiryHrs: 24})
+	if err := auth.ResetPassword(shop.ID, "secret123"); err != nil {
+		t.Fatalf("ResetPassword() error: %v", err)
+	}
+	_, token, _, err := auth.Login(shop.Phone, "secret123")
+	if err != nil {
+		t.Fatalf("Login() error: %v", err)
+	}
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Request().Header.Set("Authorization", "Bearer "+token)
+		return c.Next()
+	})
+	for _, handler := range before {
+		app.Use(handler)
+	}
+	cfg.App = app
+	cfg.AuthService = auth
+	routes.RegisterServerRoutes(cfg)
+	return app
+}
+
+// TestServerRoutesNotShadowed tests that no fixed path the server serves,
+// like /products/search, is caught by a parameter route registered before
+// it, like /products/:id
+func TestServerRoutesNotShadowed(t *testing.T) {
+	db := openTestDB(t, &models.Shop{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	db.Create(shop)
+	app := serverApp(t, db, routes.RouteConfig{}, shop)
+
+	seen := map[string][]string{}
+	for _, route := range app.GetRoutes(true) {
+		if route.Method == fiber.MethodHead {
+			continue
+		}
+		path := strings.TrimSuffix(route.Path, "/")
+		for _, earlier := range seen[route.Method] {
+			if earlier == path {
+				path = "" // served by the route registered first
+				break
+			}
+		}
+		if path == "" {
+			continue
+		}
+		if len(route.Params) == 0 {
+			for _, earlier := range seen[route.Method] {
+				if routePathMatches(earlier, path) {
+					t.Errorf("%s %s is caught by %s registered before it", route.Method, path, earlier)
+				}
+			}
+		}
+		seen[route.Method] = append(seen[route.Method], path)
+	}
+}
+
+// routePathMatches reports whether a route pattern with :params and *
+// wildcards matches path
+func routePathMatches(pattern, path string) bool {
+	patternSegments := strings.Split(pattern, "/")
+	pathSegments := strings.Split(path, "/")
+	for i, segment := range patternSegments {
+		if strings.HasPrefix(segment, "*") || strings.HasPrefix(segment, "+") {
+			return true
+		}
+		if i >= len(pathSegments) {
+			return false
+		}
+		if strings.HasPrefix(segment, ":") && pathSegments[i] != "" {
+			continue
+		}
+		if segment != pathSegments[i] {
+			return false
+		}
+	}
+	return len(patternSegments) == len(pathSegments)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/job"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/scheduler"
+)
+
+// TestJobSchedulerPanicIsolation tests that a panicking job is recorded as failed
+// and does not stop other jobs or its own schedule
+func TestJobSchedulerPanicIsolation(t *testing.T) {
+	s := job.NewScheduler(1)
+	s.Start()
+	defer s.Stop()
+
+	var panics, healthy int32
+	if err := s.AddPeriodicJob("broken", 10*time.Millisecond, func() error {
+		atomic.AddInt32(&panics, 1)
+		var m map[string]int
+		m["boom"]++
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to add job: %v", err)
+	}
+	if err := s.AddPeriodicJob("healthy", 10*time.Millisecond, func() error {
+		atomic.AddInt32(&healthy, 1)
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to add job: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if n := atomic.LoadInt32(&panics); n < 2 {
+		t.Errorf("panicking job should keep being rescheduled, ran %d times", n)
+	}
+	if n := atomic.LoadInt32(&healthy); n < 2 {
+		t.Errorf("healthy job should keep running, ran %d times", n)
+	}
+
+	err := s.RunJob("broken")
+	if err == nil {
+		t.Fatal("expected panic to be returned as an error")
+	}
+
+	broken, _ := s.GetJob("broken")
+	if broken.IsRunning {
+		t.Error("panicked job should not be left marked as running")
+	}
+
+	for _, info := range s.GetStatus()["jobs"].([]map[string]interface{}) {
+		if info["name"] == "broken" && info["last_error"] == "" {
+			t.Error("status should report the panic as last_error")
+		}
+		if info["name"] == "healthy" && info["last_error"] != "" {
+			t.Errorf("healthy job reported error: %v", info["last_error"])
+		}
+	}
+}
+
+// TestJobSchedulerStop tests that Stop cancels periodic jobs and is idempotent
+func TestJobSchedulerStop(t *testing.T) {
+	s := job.NewScheduler(1)
+	s.Start()
+
+	var runs int32
+	s.AddPeriodicJob("tick", 5*time.Millisecond, func() error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	})
+
+	time.Sleep(30 * time.Millisecond)
+	s.Stop()
+	s.Stop()
+
+	stopped := atomic.LoadInt32(&runs)
+	time.Sleep(30 * time.Millisecond)
+	if atomic.LoadInt32(&runs) != stopped {
+		t.Error("jobs kept running after Stop")
+	}
+}
+
+// TestTaskSchedulerStop tests that Stop cancels the task scheduler context and
+// can be called twice
+func TestTaskSchedulerStop(t *testing.T) {
+	s := scheduler.New()
+	s.AddTask("noop", time.Hour, func() error { return nil })
+	s.Start()
+
+	if s.Context().Err() != nil {
+		t.Fatal("context should be live while the scheduler runs")
+	}
+
+	s.Stop()
+	s.Stop()
+
+	if s.Context().Err() == nil {
+		t.Error("context should be cancelled after Stop")
+	}
+}
+
+// TestJobSchedulerRunJobOnDemand tests manual runs and that a task can't run twice at once
+func TestJobSchedulerRunJobOnDemand(t *testing.T) {
+	s := job.NewScheduler(1)
+	defer s.Stop()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s.AddPeriodicJob("daily_reports", time.Hour, func() error {
+		close(started)
+		<-release
+		return nil
+	})
+
+	if err := s.RunJob("missing"); !errors.Is(err, job.ErrJobNotFound) {
+		t.Errorf("expected ErrJobNotFound, got %v", err)
+	}
+
+	done := make(chan error)
+	go func() { done <- s.RunJob("daily_reports") }()
+	<-started
+
+	if err := s.RunJob("daily_reports"); !errors.Is(err, job.ErrJobAlreadyRunning) {
+		t.Errorf("expected ErrJobAlreadyRunning, got %v", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("manual run failed: %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	smshandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/sms"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/middleware"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/sms"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestSMSCampaign tests texting a filtered customer list, skipping opt-outs,
+// and summarising the outcome
+func TestSMSCampaign(t *testing.T) {
+	server, sent := mockAfricasTalking(t)
+	db := openTestDB(t, &models.Customer{}, &models.SmsMessage{}, &models.SmsCampaign{})
+
+	lastMonth := time.Now().AddDate(0, -1, 0)
+	customers := []models.Customer{
+		{Name: "Wanjiku", Phone: "0712345678", Tier: models.TierGold, LoyaltyPoints: 500, ReferralCode: "WAN1"},
+		{Name: "Kamau", Phone: "0711000000", Tier: models.TierGold, LoyaltyPoints: 300, ReferralCode: "KAM1"},
+		{Name: "Otieno", Phone: "0722000111", Tier: models.TierGold, LoyaltyPoints: 400, ReferralCode: "OTI1", SMSOptOut: true},
+		{Name: "Achieng", Phone: "0733000222", Tier: models.TierSilver, LoyaltyPoints: 900, ReferralCode: "ACH1"},
+		{Name: "Mutua", Phone: "0744000333", Tier: models.TierGold, LoyaltyPoints: 20, ReferralCode: "MUT1"},
+	}
+	for i := range customers {
+		customers[i].ShopID = 1
+		customers[i].IsActive = true
+		customers[i].LastPurchaseAt = &lastMonth
+		db.Create(&customers[i])
+	}
+
+	campaignRepo := repository.NewSmsCampaignRepository(db)
+	svc := sms.New(&sms.Config{APIKey: "at-key", Username: "duka", BaseURL: server.URL})
+	svc.SetMessageRepo(repository.NewSmsMessageRepository(db))
+	svc.SetCampaignRepo(campaignRepo)
+	svc.SetCampaignRate(1, 0)
+
+	handler := smshandler.New(svc)
+	handler.SetCampaignRepos(campaignRepo, repository.NewCustomerRepository(db))
+
+	plan := models.PlanBusiness
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("shop_id", uint(1))
+		c.Locals("shop", &models.Shop{ID: 1, Plan: plan})
+		return c.Next()
+	})
+	app.Post("/sms/campaigns", middleware.RequireBusiness(), handler.CreateCampaign)
+	app.Get("/sms/campaigns/:id", middleware.RequireBusiness(), handler.GetCampaign)
+
+	create := func(body string) (*models.SmsCampaign, int) {
+		req := httptest.NewRequest("POST", "/sms/campaigns", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+		var campaign models.SmsCampaign
+		json.NewDecoder(resp.Body).Decode(&campaign)
+		return &campaign, resp.StatusCode
+	}
+
+	if _, code := create(`{"message":"Hi {{nmae}}"}`); code != fiber.StatusBadRequest {
+		t.Errorf("unknown variable: status %d; want 400", code)
+	}
+	if _, code := create(`{"message":"Hi","filter":{"tier":"diamond"}}`); code != fiber.StatusBadRequest {
+		t.Errorf("unknown tier: status %d; want 400", code)
+	}
+
+	before := time.Now().AddDate(0, 0, -7).Format("2006-01-02")
+	campaign, code := create(fmt.Sprintf(`{"message":"Hi {{name}}, 20%% off this weekend! You have {{points}} points.",
+		"filter":{"tier":"gold","min_points":100,"last_purchase_before":"%s"}}`, before))
+	if code != fiber.StatusAccepted {
+		t.Fatalf("create campaign: status %d; want 202", code)
+	}
+	if campaign.Recipients != 2 || campaign.OptedOut != 1 {
+		t.Errorf("campaign = %d recipients, %d opted out; want 2 and 1", campaign.Recipients, campaign.OptedOut)
+	}
+
+	var summary models.SmsCampaignSummary
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		resp, _ := app.Test(httptest.NewRequest("GET", fmt.Sprintf("/sms/campaigns/%d", campaign.ID), nil))
+		json.NewDecoder(resp.Body).Decode(&summary)
+		if summary.Campaign != nil && summary.Campaign.Status == models.SmsCampaignCompleted {
+			break
+		}
+	}
+	if summary.Campaign == nil || summary.Campaign.Status != models.SmsCampaignCompleted {
+		t.Fatalf("campaign did not complete: %+v", summary)
+	}
+	if summary.Sent != 1 || summary.Failed != 1 || summary.Cost != 0.8 || summary.Currency != "KES" {
+		t.Errorf("summary = %+v; want 1 sent, 1 failed, KES 0.8", summary)
+	}
+
+	if len(*sent) != 2 {
+		t.Fatalf("messages sent = %d; want 2", len(*sent))
+	}
+	if got := (*sent)[0].Get("message"); got != "Hi Wanjiku, 20% off this weekend! You have 500 points." {
+		t.Errorf("message = %q", got)
+	}
+	for _, form := range *sent {
+		if form.Get("to") == "+254722000111" {
+			t.Error("opted out customer was texted")
+		}
+	}
+
+	svc.HandleDeliveryReport("ATPid_1", "Success", "")
+	stored, _ := campaignRepo.GetByID(campaign.ID)
+	if after, err := campaignRepo.Summary(stored); err != nil || after.Delivered != 1 || after.Sent != 0 {
+		t.Errorf("after delivery report: %+v, %v; want 1 delivered", after, err)
+	}
+
+	plan = models.PlanPro
+	if _, code := create(`{"message":"Hi"}`); code != fiber.StatusForbidden {
+		t.Errorf("pro plan: status %d; want 403", code)
+	}
+}
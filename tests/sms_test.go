@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	smshandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/sms"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/qr"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/sms"
+	"github.com/gofiber/fiber/v2"
+)
+
+// mockAfricasTalking accepts every SMS except to numbers ending in 000
+func mockAfricasTalking(t *testing.T) (*httptest.Server, *[]url.Values) {
+	var sent []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/version1/messaging" || r.Header.Get("apiKey") != "at-key" {
+			t.Errorf("unexpected request %s with apiKey %q", r.URL.Path, r.Header.Get("apiKey"))
+		}
+		r.ParseForm()
+		sent = append(sent, r.PostForm)
+
+		to := r.PostForm.Get("to")
+		status, cost, id := "Success", "KES 0.8000", fmt.Sprintf("ATPid_%d", len(sent))
+		if strings.HasSuffix(to, "000") {
+			status, cost, id = "InvalidPhoneNumber", "0", "None"
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"SMSMessageData": map[string]interface{}{
+				"Message": "Sent to 1/1",
+				"Recipients": []map[string]interface{}{
+					{"statusCode": 101, "number": to, "status": status, "cost": cost, "messageId": id},
+				},
+			},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server, &sent
+}
+
+// TestSMSMessageLog tests that sends are logged with their cost, delivery
+// reports update them and the log can be filtered
+func TestSMSMessageLog(t *testing.T) {
+	server, sent := mockAfricasTalking(t)
+	db := openTestDB(t, &models.SmsMessage{})
+
+	repo := repository.NewSmsMessageRepository(db)
+	svc := sms.New(&sms.Config{APIKey: "at-key", Username: "duka", BaseURL: server.URL, From: "DUKAPOS"})
+	svc.SetMessageRepo(repo)
+
+	msg, err := svc.Send(1, models.SmsPurposeManual, "0712345678", "Karibu tena")
+	if err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+	if msg.Recipient != "+254712345678" || msg.ProviderID != "ATPid_1" || msg.Cost != 0.8 || msg.Currency != "KES" {
+		t.Errorf("message = %+v; want +254712345678, ATPid_1, KES 0.8", msg)
+	}
+	if got := (*sent)[0].Get("from"); got != "DUKAPOS" {
+		t.Errorf("from = %q; want the configured sender ID", got)
+	}
+
+	if _, err := svc.Send(1, models.SmsPurposeManual, "0711000000", "Karibu"); err == nil {
+		t.Error("expected an error for a rejected number")
+	}
+	svc.Send(2, models.SmsPurposeManual, "0722000111", "Another shop")
+
+	handler := smshandler.New(svc)
+	handler.SetMessageRepo(repo)
+	app := fiber.New()
+	app.Post("/webhook/sms/delivery", handler.DeliveryReport)
+	app.Get("/sms/messages", func(c *fiber.Ctx) error {
+		c.Locals("shop_id", uint(1))
+		return c.Next()
+	}, handler.ListMessages)
+
+	report := func(token, id, status string) int {
+		form := url.Values{"id": {id}, "status": {status}, "phoneNumber": {"+254712345678"}}
+		req := httptest.NewRequest("POST", "/webhook/sms/delivery?token="+token, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, _ := app.Test(req)
+		return resp.StatusCode
+	}
+	if code := report("", "ATPid_1", "Success"); code != fiber.StatusForbidden {
+		t.Errorf("no token configured: status %d; want 403", code)
+	}
+	handler.SetDeliveryReportToken("dlr-secret")
+	if code := report("", "ATPid_1", "Success"); code != fiber.StatusForbidden {
+		t.Errorf("missing token: status %d; want 403", code)
+	}
+	if code := report("wrong", "ATPid_1", "Success"); code != fiber.StatusForbidden {
+		t.Errorf("bad token: status %d; want 403", code)
+	}
+	if code := report("dlr-secret", "ATPid_1", "Success"); code != fiber.StatusOK {
+		t.Errorf("delivery report: status %d; want 200", code)
+	}
+	if code := report("dlr-secret", "ATPid_unknown", "Success"); code != fiber.StatusOK {
+		t.Errorf("unknown message: status %d; want 200 so it is not retried", code)
+	}
+
+	list := func(query string) (messages []models.SmsMessage, total int64) {
+		resp, _ := app.Test(httptest.NewRequest("GET", "/sms/messages?"+query, nil))
+		var body struct {
+			Data  []models.SmsMessage `json:"data"`
+			Total int64               `json:"total"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return body.Data, body.Total
+	}
+	if _, total := list(""); total != 2 {
+		t.Errorf("shop 1 messages = %d; want 2", total)
+	}
+	delivered, _ := list("status=delivered")
+	if len(delivered) != 1 || delivered[0].ProviderID != "ATPid_1" || delivered[0].DeliveredAt == nil {
+		t.Errorf("delivered = %+v; want ATPid_1 with a delivery time", delivered)
+	}
+	failed, _ := list("status=failed&phone=254711000000")
+	if len(failed) != 1 || failed[0].FailureReason == "" {
+		t.Errorf("failed = %+v; want the rejected send with its reason", failed)
+	}
+}
+
+// TestSMSReceiptsAndLowStock tests that receipts follow the shop toggle and
+// low stock alerts go to the owner
+func TestSMSReceiptsAndLowStock(t *testing.T) {
+	server, sent := mockAfricasTalking(t)
+	db := openTestDB(t, &models.SmsMessage{})
+
+	svc := sms.New(&sms.Config{APIKey: "at-key", Username: "duka", BaseURL: server.URL})
+	svc.SetMessageRepo(repository.NewSmsMessageRepository(db))
+	svc.SetReceiptLinker(qr.NewReceiptLinker("https://duka.example.com", "secret"))
+
+	shop := &models.Shop{ID: 1, Name: "Mama Mboga", Phone: "+254712345678"}
+	sales := []models.Sale{
+		{ID: 41, ShopID: 1, TotalAmount: 111, PaymentMethod: models.PaymentMpesa, MpesaReceipt: "QRC1234567"},
+		{ID: 42, ShopID: 1, TotalAmount: 60, PaymentMethod: models.PaymentMpesa, MpesaReceipt: "QRC1234567"},
+	}
+
+	if msg, err := svc.SendReceipt(shop, "254722000111", sales); msg != nil || err != nil || len(*sent) != 0 {
+		t.Fatalf("receipts off: got %v, %v; want nothing sent", msg, err)
+	}
+
+	shop.SMSReceipts = true
+	msg, err := svc.SendReceipt(shop, "254722000111", sales)
+	if err != nil {
+		t.Fatalf("SendReceipt() error: %v", err)
+	}
+	if !strings.Contains(msg.Body, "KSh 171") || !strings.Contains(msg.Body, "QRC1234567") ||
+		!strings.Contains(msg.Body, "https://duka.example.com/r/41") {
+		t.Errorf("receipt = %q; want the total, M-Pesa code and receipt link", msg.Body)
+	}
+	if msg.Purpose != models.SmsPurposeReceipt || msg.Recipient != "+254722000111" {
+		t.Errorf("receipt message = %+v", msg)
+	}
+
+	err = svc.SendLowStockAlert(shop, []models.Product{{Name: "Bread", CurrentStock: 2, LowStockThreshold: 10}})
+	if err != nil {
+		t.Fatalf("SendLowStockAlert() error: %v", err)
+	}
+	alert := (*sent)[len(*sent)-1]
+	if alert.Get("to") != "+254712345678" || !strings.Contains(alert.Get("message"), "Bread: 2 (min 10)") {
+		t.Errorf("alert = %v; want Bread to the owner", alert)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/handlers"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestStockMovementLedger tests that every stock change made through
+// WhatsApp and the product repository is recorded with its resulting balance
+func TestStockMovementLedger(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.ProductAlias{}, &models.StockMovement{}, &models.Sale{},
+		&models.DailySummary{}, &models.AuditLog{}, &models.PriceHistory{})
+
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanPro, IsActive: true}
+	db.Create(shop)
+
+	productRepo := repository.NewProductRepository(db)
+	movementRepo := repository.NewStockMovementRepository(db)
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		productRepo,
+		repository.NewSaleRepository(db),
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	parser := services.NewCommandParser(nil, nil)
+	send := func(message string) {
+		if _, err := cmdHandler.Handle(shop.Phone, parser.Parse(message)); err != nil {
+			t.Fatalf("Handle(%q) error: %v", message, err)
+		}
+	}
+
+	send("add bread 50 10")
+	send("add bread 50 5")
+	send("sell bread 3")
+	send("remove bread 2")
+
+	product, _ := productRepo.GetByShopAndName(shop.ID, "Bread")
+	product.CurrentStock = 4 // counted on the shelf
+	if err := productRepo.Update(product); err != nil {
+		t.Fatalf("Update() error: %v", err)
+	}
+
+	movements, total, err := movementRepo.GetByProduct(product.ID, 50, 0)
+	if err != nil {
+		t.Fatalf("GetByProduct() error: %v", err)
+	}
+	want := []struct {
+		kind     models.StockMovementType
+		quantity int
+		balance  int
+	}{
+		{models.StockMovementAdjustment, -6, 4},
+		{models.StockMovementAdjustment, -2, 10},
+		{models.StockMovementSale, -3, 12},
+		{models.StockMovementRestock, 5, 15},
+		{models.StockMovementRestock, 10, 10},
+	}
+	if total != int64(len(want)) || len(movements) != len(want) {
+		t.Fatalf("movements = %d (total %d); want %d", len(movements), total, len(want))
+	}
+	for i, w := range want {
+		m := movements[i]
+		if m.Type != w.kind || m.Quantity != w.quantity || m.BalanceAfter != w.balance || m.ShopID != shop.ID {
+			t.Errorf("movement %d = %s %+d -> %d; want %s %+d -> %d", i, m.Type, m.Quantity, m.BalanceAfter, w.kind, w.quantity, w.balance)
+		}
+	}
+
+	var sale models.Sale
+	db.Where("product_id = ?", product.ID).First(&sale)
+	if ref := movements[2].ReferenceID; ref == nil || *ref != sale.ID {
+		t.Errorf("sale movement reference = %v; want sale %d", ref, sale.ID)
+	}
+
+	// The ledger adds up to the stock on hand
+	sum := 0
+	for _, m := range movements {
+		sum += m.Quantity
+	}
+	if stored, _ := productRepo.GetByID(product.ID); sum != stored.CurrentStock {
+		t.Errorf("ledger sums to %d; stock is %d", sum, stored.CurrentStock)
+	}
+}
+
+// TestStockMovementBundleSale tests that a bundle sale records a movement
+// per component and records nothing when a component is short
+func TestStockMovementBundleSale(t *testing.T) {
+	db := openTestDB(t, &models.Product{}, &models.ProductBundle{}, &models.StockMovement{}, &models.Sale{})
+
+	bread := &models.Product{ShopID: 1, Name: "Bread", SellingPrice: 60, CurrentStock: 5, IsActive: true}
+	milk := &models.Product{ShopID: 1, Name: "Milk", SellingPrice: 55, CurrentStock: 1, IsActive: true}
+	db.Create(bread)
+	db.Create(milk)
+
+	bundleRepo := repository.NewBundleRepository(db)
+	sale := func(productID uint, qty int) *models.Sale {
+		return &models.Sale{ShopID: 1, ProductID: productID, Quantity: qty, PaymentMethod: models.PaymentCash}
+	}
+
+	if err := bundleRepo.RecordSales([]*models.Sale{sale(bread.ID, 2), sale(milk.ID, 2)}); !errors.Is(err, repository.ErrInsufficientStock) {
+		t.Fatalf("short component: expected ErrInsufficientStock, got %v", err)
+	}
+	var count int64
+	db.Model(&models.StockMovement{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("movements after failed sale = %d; want 0", count)
+	}
+
+	if err := bundleRepo.RecordSales([]*models.Sale{sale(bread.ID, 2), sale(milk.ID, 1)}); err != nil {
+		t.Fatalf("RecordSales() error: %v", err)
+	}
+	movements, _, _ := repository.NewStockMovementRepository(db).GetByProduct(milk.ID, 10, 0)
+	if len(movements) != 1 || movements[0].Type != models.StockMovementSale || movements[0].BalanceAfter != 0 {
+		t.Errorf("milk movements = %+v; want one sale leaving 0", movements)
+	}
+}
+
+// TestStockMovementAPI tests GET /products/:id/movements
+func TestStockMovementAPI(t *testing.T) {
+	db := openTestDB(t, &models.Product{}, &models.StockMovement{})
+
+	productRepo := repository.NewProductRepository(db)
+	productHandler := handlers.NewProductHandler(productRepo)
+	productHandler.SetStockMovementRepo(repository.NewStockMovementRepository(db))
+
+	app := fiber.New(fiber.Config{ErrorHandler: utils.ErrorHandler})
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("shop_id", uint(1))
+		return c.Next()
+	})
+	app.Get("/products/:id/movements", productHandler.GetStockMovements)
+
+	mine := &models.Product{ShopID: 1, Name: "Bread", SellingPrice: 50, CurrentStock: 10, IsActive: true}
+	theirs := &models.Product{ShopID: 2, Name: "Milk", SellingPrice: 60, CurrentStock: 3, IsActive: true}
+	productRepo.Create(mine)
+	productRepo.Create(theirs)
+	productRepo.MoveStock(mine.ID, 4, models.StockMovementRestock, nil, "supplier delivery")
+
+	resp, err := app.Test(httptest.NewRequest("GET", fmt.Sprintf("/products/%d/movements?limit=1", mine.ID), nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+	var body struct {
+		CurrentStock int                    `json:"current_stock"`
+		Movements    []models.StockMovement `json:"movements"`
+		Total        int64                  `json:"total"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	if body.CurrentStock != 14 || body.Total != 2 || len(body.Movements) != 1 {
+		t.Fatalf("body = %+v; want stock 14, 2 movements, 1 returned", body)
+	}
+	if m := body.Movements[0]; m.Quantity != 4 || m.BalanceAfter != 14 || m.Note != "supplier delivery" {
+		t.Errorf("latest movement = %+v; want the +4 restock", m)
+	}
+
+	resp, _ = app.Test(httptest.NewRequest("GET", fmt.Sprintf("/products/%d/movements", theirs.ID), nil))
+	if resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("other shop's product: expected 403, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/stripe"
+)
+
+const testWebhookSecret = "whsec_test"
+
+// stripeEvent builds a signed PaymentIntent event
+func stripeEvent(t *testing.T, eventType, intentID string, amountReceived int64, at time.Time) ([]byte, string) {
+	t.Helper()
+	payload, _ := json.Marshal(map[string]interface{}{
+		"id":   "evt_" + intentID,
+		"type": eventType,
+		"data": map[string]interface{}{"object": map[string]interface{}{
+			"id": intentID, "amount_received": amountReceived, "currency": "kes",
+			"last_payment_error": map[string]string{"message": "Your card was declined."},
+		}},
+	})
+	ts := at.Unix()
+	return payload, fmt.Sprintf("t=%d,v1=%s", ts, stripe.Sign(payload, testWebhookSecret, ts))
+}
+
+// TestStripeCheckout tests that a checkout creates a PaymentIntent and a
+// signed succeeded event records the sale once
+func TestStripeCheckout(t *testing.T) {
+	var form map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _, _ := r.BasicAuth()
+		if r.URL.Path != "/v1/payment_intents" || user != "sk_test" {
+			t.Errorf("unexpected request %s as %q", r.URL.Path, user)
+		}
+		r.ParseForm()
+		form = map[string]string{}
+		for k := range r.PostForm {
+			form[k] = r.PostForm.Get(k)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id": "pi_123", "client_secret": "pi_123_secret_abc", "amount": 24000, "currency": "kes", "status": "requires_payment_method",
+		})
+	}))
+	defer server.Close()
+
+	db := openTestDB(t, &models.Product{}, &models.Sale{}, &models.StockMovement{}, &models.StripePayment{})
+	bread := &models.Product{ShopID: 1, Name: "Bread", SellingPrice: 60, CostPrice: 50, CurrentStock: 10, IsActive: true}
+	db.Create(bread)
+
+	paymentRepo := repository.NewStripePaymentRepository(db)
+	svc := stripe.New(&stripe.Config{SecretKey: "sk_test", WebhookSecret: testWebhookSecret, BaseURL: server.URL},
+		paymentRepo, repository.NewProductRepository(db))
+
+	if _, _, err := svc.Checkout(2, bread.ID, 1, ""); !errors.Is(err, stripe.ErrProductUnavailable) {
+		t.Errorf("checkout for another shop's product: %v; want ErrProductUnavailable", err)
+	}
+
+	// Prices are in KES, so another currency isn't charged the same number
+	if _, _, err := svc.Checkout(1, bread.ID, 1, "usd"); !errors.Is(err, stripe.ErrUnsupportedCurrency) {
+		t.Errorf("checkout in usd: %v; want ErrUnsupportedCurrency", err)
+	}
+	if form != nil {
+		t.Fatalf("PaymentIntent form = %v; want no request for usd", form)
+	}
+
+	payment, intent, err := svc.Checkout(1, bread.ID, 4, "")
+	if err != nil {
+		t.Fatalf("Checkout() error: %v", err)
+	}
+	if form["amount"] != "24000" || form["currency"] != "kes" || form["metadata[shop_id]"] != "1" {
+		t.Errorf("PaymentIntent form = %v; want 24000 kes cents for shop 1", form)
+	}
+	if intent.ClientSecret != "pi_123_secret_abc" || payment.Status != models.StripePaymentPending || payment.Amount != 240 {
+		t.Errorf("checkout = %+v, %+v", payment, intent)
+	}
+
+	// Unsigned, stale and short-paid events don't record a sale
+	payload, _ := stripeEvent(t, "payment_intent.succeeded", "pi_123", 24000, time.Now())
+	if _, err := svc.HandleWebhook(payload, "t=1,v1=bad"); !errors.Is(err, stripe.ErrInvalidSignature) {
+		t.Errorf("bad signature: %v; want ErrInvalidSignature", err)
+	}
+	payload, sig := stripeEvent(t, "payment_intent.succeeded", "pi_123", 24000, time.Now().Add(-10*time.Minute))
+	if _, err := svc.HandleWebhook(payload, sig); !errors.Is(err, stripe.ErrInvalidSignature) {
+		t.Errorf("stale signature: %v; want ErrInvalidSignature", err)
+	}
+	payload, sig = stripeEvent(t, "payment_intent.succeeded", "pi_123", 100, time.Now())
+	if _, err := svc.HandleWebhook(payload, sig); !errors.Is(err, stripe.ErrAmountMismatch) {
+		t.Errorf("short payment: %v; want ErrAmountMismatch", err)
+	}
+	payload, _ = stripeEvent(t, "payment_intent.succeeded", "pi_123", 24000, time.Now())
+	payload = bytes.Replace(payload, []byte(`"currency":"kes"`), []byte(`"currency":"usd"`), 1)
+	ts := time.Now().Unix()
+	sig = fmt.Sprintf("t=%d,v1=%s", ts, stripe.Sign(payload, testWebhookSecret, ts))
+	if _, err := svc.HandleWebhook(payload, sig); !errors.Is(err, stripe.ErrAmountMismatch) {
+		t.Errorf("payment in usd: %v; want ErrAmountMismatch", err)
+	}
+
+	payload, sig = stripeEvent(t, "payment_intent.succeeded", "pi_123", 24000, time.Now())
+	paid, err := svc.HandleWebhook(payload, sig)
+	if err != nil {
+		t.Fatalf("HandleWebhook() error: %v", err)
+	}
+	if paid.Status != models.StripePaymentCompleted || paid.SaleID == nil {
+		t.Fatalf("payment = %+v; want completed with a sale", paid)
+	}
+
+	var sale models.Sale
+	db.First(&sale, *paid.SaleID)
+	if sale.Quantity != 4 || sale.TotalAmount != 240 || sale.Profit != 40 || sale.PaymentMethod != models.PaymentCard {
+		t.Errorf("sale = %+v; want 4 bread paid by card", sale)
+	}
+	db.First(bread, bread.ID)
+	if bread.CurrentStock != 6 {
+		t.Errorf("stock = %d; want 6", bread.CurrentStock)
+	}
+
+	// Stripe redelivers events; the second one changes nothing
+	if _, err := svc.HandleWebhook(payload, sig); !errors.Is(err, repository.ErrStripePaymentSettled) {
+		t.Errorf("redelivered event: %v; want ErrStripePaymentSettled", err)
+	}
+	var sales int64
+	db.Model(&models.Sale{}).Count(&sales)
+	if sales != 1 {
+		t.Errorf("sales = %d; want 1", sales)
+	}
+
+	// Events for PaymentIntents made elsewhere are not ours
+	payload, sig = stripeEvent(t, "payment_intent.payment_failed", "pi_other", 0, time.Now())
+	if _, err := svc.HandleWebhook(payload, sig); !errors.Is(err, stripe.ErrPaymentNotFound) {
+		t.Errorf("unknown intent: %v; want ErrPaymentNotFound", err)
+	}
+}
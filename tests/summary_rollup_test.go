@@ -0,0 +1,206 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+)
+
+// TestSummaryRollups tests rolling daily summaries up into weeks and
+// months, and adding up a period from rollups, summaries and sales
+func TestSummaryRollups(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.Sale{}, &models.DailySummary{},
+		&models.WeeklySummary{}, &models.MonthlySummary{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	db.Create(shop)
+	product := &models.Product{ShopID: shop.ID, Name: "Bread", SellingPrice: 50, CostPrice: 40, CurrentStock: 100, IsActive: true}
+	db.Create(product)
+	sell := func(at time.Time, amount float64) {
+		t.Helper()
+		sale := &models.Sale{ShopID: shop.ID, ProductID: product.ID, Quantity: 1, UnitPrice: amount, TotalAmount: amount,
+			CostAmount: amount * 0.8, Profit: amount * 0.2, CreatedAt: at}
+		if err := db.Create(sale).Error; err != nil {
+			t.Fatalf("failed to create sale: %v", err)
+		}
+	}
+	day := func(d int) time.Time { return time.Date(2026, time.March, d, 0, 0, 0, 0, time.UTC) }
+
+	// Sunday 8 March is in the week starting Monday 2 March
+	if got := models.WeekStart(day(8).Add(15 * time.Hour)); !got.Equal(day(2)) {
+		t.Errorf("WeekStart(Sun 8 Mar) = %v; want Mon 2 Mar", got)
+	}
+	if got := models.WeekStart(day(2)); !got.Equal(day(2)) {
+		t.Errorf("WeekStart(Mon 2 Mar) = %v; want itself", got)
+	}
+	if got := models.MonthStart(day(31).Add(23 * time.Hour)); !got.Equal(day(1)) {
+		t.Errorf("MonthStart(31 Mar) = %v; want 1 Mar", got)
+	}
+
+	sell(day(3).Add(10*time.Hour), 100)
+	sell(day(5).Add(18*time.Hour), 50)
+	repo := repository.NewDailySummaryRepository(db)
+
+	week, err := repo.RollupWeek(shop.ID, day(8).Add(12*time.Hour))
+	if err != nil {
+		t.Fatalf("RollupWeek() error: %v", err)
+	}
+	if week.TotalSales != 150 || week.TotalTransactions != 2 || week.TotalProfit != 30 || !week.WeekStart.Equal(day(2)) {
+		t.Errorf("RollupWeek() = %+v; want 150 from 2 sales in the week of 2 Mar", week)
+	}
+	// Rolling up again updates the same row
+	if _, err := repo.RollupWeek(shop.ID, day(4)); err != nil {
+		t.Fatalf("RollupWeek() again error: %v", err)
+	}
+	var weeks int64
+	db.Model(&models.WeeklySummary{}).Count(&weeks)
+	if weeks != 1 {
+		t.Errorf("weekly summaries = %d; want 1", weeks)
+	}
+
+	// The rolled-up week is read instead of its days or sales
+	db.Model(&models.WeeklySummary{}).Where("id = ?", week.ID).Update("total_sales", 1000)
+	if totals, err := repo.Totals(shop.ID, day(2), day(9)); err != nil || totals.Sales != 1000 || totals.Transactions != 2 {
+		t.Errorf("Totals(week) = %+v, %v; want the weekly summary's 1000", totals, err)
+	}
+	// Part of a week uses the daily summaries
+	if totals, _ := repo.Totals(shop.ID, day(3), day(9)); totals.Sales != 150 {
+		t.Errorf("Totals(Tue-Sun) = %+v; want 150 from the daily summaries", totals)
+	}
+
+	// A day after the week with no summary comes from its sales, and one
+	// with a summary from the summary
+	sell(day(10).Add(9*time.Hour), 30)
+	db.Create(&models.DailySummary{ShopID: shop.ID, Date: day(11), TotalSales: 7, TotalTransactions: 1})
+	totals, err := repo.Totals(shop.ID, day(2), day(11).Add(16*time.Hour))
+	if err != nil {
+		t.Fatalf("Totals() error: %v", err)
+	}
+	if totals.Sales != 1037 || totals.Transactions != 4 {
+		t.Errorf("Totals(2-11 Mar) = %+v; want 1000 + 30 + 7 from 4 sales", totals)
+	}
+
+	month, err := repo.RollupMonth(shop.ID, day(20))
+	if err != nil {
+		t.Fatalf("RollupMonth() error: %v", err)
+	}
+	if month.TotalSales != 180 || month.TotalTransactions != 3 || !month.Month.Equal(day(1)) {
+		t.Errorf("RollupMonth() = %+v; want 180 from 3 sales in March", month)
+	}
+	if totals, _ := repo.Totals(shop.ID, day(1), time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)); totals.Sales != 180 {
+		t.Errorf("Totals(March) = %+v; want the monthly summary's 180", totals)
+	}
+}
+
+// TestWeeklyReportFromSales tests the weekly report adding up recent sales
+// that no summary covers yet
+func TestWeeklyReportFromSales(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.Sale{}, &models.DailySummary{},
+		&models.WeeklySummary{}, &models.MonthlySummary{}, &models.AuditLog{}, &models.ProductAlias{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanFree, IsActive: true}
+	db.Create(shop)
+	product := &models.Product{ShopID: shop.ID, Name: "Bread", SellingPrice: 60, CostPrice: 50, CurrentStock: 10, IsActive: true}
+	db.Create(product)
+	db.Create(&models.Sale{ShopID: shop.ID, ProductID: product.ID, Quantity: 4, UnitPrice: 60, TotalAmount: 240, CostAmount: 200, Profit: 40})
+	db.Create(&models.Sale{ShopID: shop.ID, ProductID: product.ID, Quantity: 1, UnitPrice: 60, TotalAmount: 60, CostAmount: 50, Profit: 10,
+		CreatedAt: time.Now().AddDate(0, 0, -10)})
+
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		repository.NewProductRepository(db),
+		repository.NewSaleRepository(db),
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	parser := services.NewCommandParser(nil, nil)
+
+	reply, err := cmdHandler.Handle(shop.Phone, parser.Parse("weekly"))
+	if err != nil {
+		t.Fatalf("weekly error: %v", err)
+	}
+	if !strings.Contains(reply, "Total Sales: KSh 240") || !strings.Contains(reply, "Transactions: 1") {
+		t.Errorf("weekly report should count only this week's sale, got:\n%s", reply)
+	}
+	reply, err = cmdHandler.Handle(shop.Phone, parser.Parse("monthly"))
+	if err != nil {
+		t.Fatalf("monthly error: %v", err)
+	}
+	if !strings.Contains(reply, "Total Sales: KSh 300") {
+		t.Errorf("monthly report should count both sales, got:\n%s", reply)
+	}
+}
+
+// TestRollupDue tests rolling up the weeks and months that ended a few at
+// a time, catching up on those missed and taking a period again once one
+// of its sales changes
+func TestRollupDue(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.Sale{}, &models.DailySummary{},
+		&models.WeeklySummary{}, &models.MonthlySummary{})
+	day := func(d int) time.Time { return time.Date(2026, time.March, d, 0, 0, 0, 0, time.UTC) }
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true, CreatedAt: day(1)}
+	closed := &models.Shop{Name: "Closed Duka", Phone: "+254712345679", CreatedAt: day(1)}
+	db.Create(shop)
+	db.Create(closed)
+	db.Model(closed).Update("is_active", false)
+	product := &models.Product{ShopID: shop.ID, Name: "Bread", SellingPrice: 50, CostPrice: 40, CurrentStock: 100, IsActive: true}
+	db.Create(product)
+	sell := func(at time.Time, amount float64) {
+		t.Helper()
+		sale := &models.Sale{ShopID: shop.ID, ProductID: product.ID, Quantity: 1, UnitPrice: amount, TotalAmount: amount,
+			CostAmount: amount * 0.8, CreatedAt: at}
+		if err := db.Create(sale).Error; err != nil {
+			t.Fatalf("failed to create sale: %v", err)
+		}
+	}
+	sell(day(3).Add(10*time.Hour), 100)
+	repo := repository.NewDailySummaryRepository(db)
+
+	// On Wednesday 18 March the weeks of 23 Feb, 2 and 9 March have ended
+	// since the shop opened; February ended as it opened
+	now := day(18).Add(12 * time.Hour)
+	if n, err := repo.RollupDue(now, 2); err != nil || n != 2 {
+		t.Fatalf("RollupDue(limit 2) = %d, %v; want 2", n, err)
+	}
+	if n, _ := repo.RollupDue(now, 2); n != 1 {
+		t.Errorf("second RollupDue() = %d; want the 1 week left", n)
+	}
+	if n, _ := repo.RollupDue(now, 2); n != 0 {
+		t.Errorf("third RollupDue() = %d; want nothing left", n)
+	}
+	var weeks []models.WeeklySummary
+	db.Order("week_start").Find(&weeks)
+	if len(weeks) != 3 || weeks[0].ShopID != shop.ID || weeks[1].TotalSales != 100 {
+		t.Fatalf("weekly summaries = %+v; want 3 for the open shop, 100 in the week of 2 Mar", weeks)
+	}
+
+	// A sale recorded late for 4 March drops that week until it is taken
+	// again, and its day's summary is worked out again at once
+	sell(day(4).Add(9*time.Hour), 30)
+	var summary models.DailySummary
+	db.Where("shop_id = ? AND date = ?", shop.ID, day(4)).First(&summary)
+	if summary.TotalSales != 30 || summary.TotalTransactions != 1 {
+		t.Errorf("daily summary of 4 Mar = %+v; want the late sale's 30", summary)
+	}
+	if totals, _ := repo.Totals(shop.ID, day(2), day(9)); totals.Sales != 130 {
+		t.Errorf("Totals(week of 2 Mar) = %+v; want 130 with the late sale", totals)
+	}
+	if n, _ := repo.RollupDue(now, 10); n != 1 {
+		t.Errorf("RollupDue() after a late sale = %d; want its week again", n)
+	}
+	week, _ := repo.RollupWeek(shop.ID, day(2))
+	if week.TotalSales != 130 {
+		t.Errorf("week of 2 Mar = %+v; want 130", week)
+	}
+
+	// The month is rolled up once it ends
+	if n, _ := repo.RollupDue(time.Date(2026, time.April, 1, 0, 0, 30, 0, time.UTC), 10); n != 3 {
+		t.Errorf("RollupDue(1 Apr) = %d; want the weeks of 16 and 23 March and March itself", n)
+	}
+	var month models.MonthlySummary
+	if err := db.Where("shop_id = ?", shop.ID).First(&month).Error; err != nil || month.TotalSales != 130 {
+		t.Errorf("March = %+v, %v; want 130", month, err)
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/mpesa"
+	"gorm.io/gorm"
+)
+
+// newSupplierPayTest sets up a shop that pays supplier Brookside over a mock
+// Daraja, returning the command handler and the shop
+func newSupplierPayTest(t *testing.T, daraja *mockDarajaB2C) (*services.CommandHandler, *mpesa.Service, *gorm.DB, *models.Shop, *models.Supplier) {
+	t.Helper()
+	server := daraja.server(t)
+	t.Cleanup(server.Close)
+
+	svc, db := newB2CTestService(t, server.URL, 0)
+	if err := db.AutoMigrate(&models.Supplier{}, &models.Order{}, &models.OrderItem{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	shop := &models.Shop{}
+	db.First(shop, 1)
+	supplier := &models.Supplier{ShopID: shop.ID, Name: "Brookside", Phone: "0722000111"}
+	db.Create(supplier)
+
+	orderRepo := repository.NewOrderRepository(db)
+	svc.SetOrderRepo(orderRepo)
+
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		repository.NewProductRepository(db),
+		repository.NewSaleRepository(db),
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	cmdHandler.SetSupplierRepo(repository.NewSupplierRepository(db), orderRepo)
+	cmdHandler.SetMpesaService(svc)
+	return cmdHandler, svc, db, shop, supplier
+}
+
+// TestSupplierPayB2C tests paying a supplier's order over B2C from WhatsApp
+// and settling it from the result callback
+func TestSupplierPayB2C(t *testing.T) {
+	daraja := &mockDarajaB2C{}
+	cmdHandler, svc, db, shop, supplier := newSupplierPayTest(t, daraja)
+	orderRepo := repository.NewOrderRepository(db)
+	parser := services.NewCommandParser(nil, nil)
+	send := func(message string) string {
+		t.Helper()
+		reply, err := cmdHandler.Handle(shop.Phone, parser.Parse(message))
+		if err != nil {
+			t.Fatalf("%s: %v", message, err)
+		}
+		return reply
+	}
+
+	draft := &models.Order{ShopID: shop.ID, SupplierID: supplier.ID, Status: models.OrderStatusDraft, TotalAmount: 900}
+	order := &models.Order{ShopID: shop.ID, SupplierID: supplier.ID, Status: models.OrderStatusPending, TotalAmount: 5000}
+	db.Create(draft)
+	db.Create(order)
+
+	// An amount that matches no order isn't sent against the oldest one
+	reply := send("supplier pay brookside 1")
+	if !strings.Contains(reply, "doesn't match") || !strings.Contains(reply, "#2: KSh 5000") {
+		t.Errorf("reply = %q; want the unpaid orders listed", reply)
+	}
+
+	reply = send("supplier pay brookside 5000")
+	if !strings.Contains(reply, "yes supplier pay brookside 5000 #2") {
+		t.Fatalf("reply = %q; want a confirmation for order #2", reply)
+	}
+	if requests := daraja.received(); len(requests) != 0 {
+		t.Fatalf("B2C requests = %v; want none before confirming", requests)
+	}
+
+	reply = send("yes supplier pay brookside 5000 #2")
+	if !strings.Contains(reply, "Paying Brookside KSh 5000") || !strings.Contains(reply, "Order #2") {
+		t.Errorf("reply = %q; want payout for order #2", reply)
+	}
+
+	requests := daraja.received()
+	if len(requests) != 1 || requests[0]["PartyB"] != "254722000111" || requests[0]["Amount"] != float64(5000) {
+		t.Fatalf("B2C requests = %v; want 5000 to the supplier", requests)
+	}
+
+	got, _ := orderRepo.GetByID(order.ID)
+	if got.PaymentStatus != models.OrderPaymentProcessing {
+		t.Errorf("payment status = %q; want processing until the result", got.PaymentStatus)
+	}
+	var tx models.MpesaTransaction
+	if err := db.Where("type = ?", "b2c").First(&tx).Error; err != nil || tx.Status != "pending" || tx.TransactionID != "AG_20240215_1" {
+		t.Errorf("b2c transaction = %+v, %v; want it pending under its conversation", tx, err)
+	}
+
+	payout, err := svc.ProcessB2CResult(b2cResultBody("AG_20240215_1", 0, "The service request is processed successfully."))
+	if err != nil {
+		t.Fatalf("ProcessB2CResult() error: %v", err)
+	}
+
+	got, _ = orderRepo.GetByID(order.ID)
+	if got.PaymentStatus != models.OrderPaymentPaid || got.AmountPaid != 5000 || got.B2CPayoutID == nil || *got.B2CPayoutID != payout.ID {
+		t.Errorf("order = %+v; want paid by payout %d", got, payout.ID)
+	}
+	if got, _ := orderRepo.GetByID(draft.ID); got.PaymentStatus != models.OrderPaymentUnpaid {
+		t.Errorf("draft payment status = %q; want unpaid", got.PaymentStatus)
+	}
+
+	var txs []models.MpesaTransaction
+	db.Where("type = ?", "b2c").Find(&txs)
+	if len(txs) != 1 || txs[0].Amount != 5000 || txs[0].Status != "completed" || txs[0].ReceiptNumber != "NLJ41HAY6Q" {
+		t.Errorf("b2c transactions = %+v; want one completed for 5000", txs)
+	}
+
+	// Nothing unpaid is left, so the next payout isn't tied to an order
+	reply = send("supplier pay brookside 200")
+	if !strings.Contains(reply, "yes supplier pay brookside 200`") {
+		t.Fatalf("reply = %q; want a confirmation with no order", reply)
+	}
+	reply = send("yes supplier pay brookside 200")
+	if !strings.Contains(reply, "Paying Brookside KSh 200") || strings.Contains(reply, "Order #") {
+		t.Errorf("reply = %q; want no order once it is paid", reply)
+	}
+}
+
+// TestSupplierPayPartial tests paying part of a named order, which leaves it
+// partly paid until the rest is sent
+func TestSupplierPayPartial(t *testing.T) {
+	daraja := &mockDarajaB2C{}
+	cmdHandler, svc, db, shop, supplier := newSupplierPayTest(t, daraja)
+	orderRepo := repository.NewOrderRepository(db)
+	parser := services.NewCommandParser(nil, nil)
+	send := func(message string) string {
+		t.Helper()
+		reply, err := cmdHandler.Handle(shop.Phone, parser.Parse(message))
+		if err != nil {
+			t.Fatalf("%s: %v", message, err)
+		}
+		return reply
+	}
+
+	order := &models.Order{ShopID: shop.ID, SupplierID: supplier.ID, Status: models.OrderStatusSent, TotalAmount: 5000}
+	db.Create(order)
+
+	if reply := send("supplier pay brookside 6000 #1"); !strings.Contains(reply, "only KSh 5000 left") {
+		t.Errorf("reply = %q; want overpaying refused", reply)
+	}
+	if reply := send("supplier pay brookside 100 #7"); !strings.Contains(reply, "#7 is not an unpaid order") {
+		t.Errorf("reply = %q; want an unknown order refused", reply)
+	}
+
+	send("supplier pay brookside 2000 #1")
+	send("yes supplier pay brookside 2000 #1")
+
+	// A second payout can't start while the first is in flight
+	if reply := send("supplier pay brookside 3000 #1"); !strings.Contains(reply, "#1 is not an unpaid order") {
+		t.Errorf("reply = %q; want the order busy", reply)
+	}
+
+	if _, err := svc.ProcessB2CResult(b2cResultBody("AG_20240215_1", 0, "The service request is processed successfully.")); err != nil {
+		t.Fatalf("ProcessB2CResult() error: %v", err)
+	}
+	got, _ := orderRepo.GetByID(order.ID)
+	if got.PaymentStatus != models.OrderPaymentPartial || got.AmountPaid != 2000 {
+		t.Fatalf("order = %+v; want partly paid with 2000", got)
+	}
+
+	// The balance matches without naming the order
+	if reply := send("supplier pay brookside 3000"); !strings.Contains(reply, "#1? KSh 3000 is left") {
+		t.Fatalf("reply = %q; want the balance of order #1 confirmed", reply)
+	}
+	send("yes supplier pay brookside 3000 #1")
+
+	// A failed payout keeps what was already paid
+	if _, err := svc.ProcessB2CResult(b2cResultBody("AG_20240215_2", 2001, "The initiator information is invalid.")); err != nil {
+		t.Fatalf("ProcessB2CResult() error: %v", err)
+	}
+	got, _ = orderRepo.GetByID(order.ID)
+	if got.PaymentStatus != models.OrderPaymentPartial || got.AmountPaid != 2000 {
+		t.Fatalf("order = %+v; want still partly paid with 2000", got)
+	}
+
+	send("supplier pay brookside 3000 #1")
+	send("yes supplier pay brookside 3000 #1")
+	body := bytes.ReplaceAll(b2cResultBody("AG_20240215_3", 0, "The service request is processed successfully."),
+		[]byte("NLJ41HAY6Q"), []byte("NLJ41HAY7R"))
+	if _, err := svc.ProcessB2CResult(body); err != nil {
+		t.Fatalf("ProcessB2CResult() error: %v", err)
+	}
+	got, _ = orderRepo.GetByID(order.ID)
+	if got.PaymentStatus != models.OrderPaymentPaid || got.AmountPaid != 5000 {
+		t.Errorf("order = %+v; want paid with 5000", got)
+	}
+
+	var statuses []string
+	db.Model(&models.MpesaTransaction{}).Where("type = ?", "b2c").Order("id").Pluck("status", &statuses)
+	if strings.Join(statuses, ",") != "completed,failed,completed" {
+		t.Errorf("b2c transaction statuses = %v; want completed, failed, completed", statuses)
+	}
+}
+
+// TestSupplierPayRequiresInitiator tests that a shop without its own
+// initiator can't pay out from the platform's, and the error naming what is
+// missing
+func TestSupplierPayRequiresInitiator(t *testing.T) {
+	db := openTestDB(t, &models.B2CPayout{}, &models.Shop{}, &models.Supplier{}, &models.Order{})
+
+	svc := mpesa.New(&mpesa.Config{
+		ConsumerKey:        "key",
+		ConsumerSecret:     "secret",
+		Shortcode:          "600998",
+		Passkey:            testPasskey,
+		InitiatorName:      "platform",
+		SecurityCredential: "PLATFORM==",
+		B2CResultURL:       "https://pos.example.com/webhook/mpesa/b2c",
+	}, nil, nil)
+	svc.SetB2CRepos(repository.NewB2CPayoutRepository(db), nil)
+
+	err := svc.B2CConfigError(1)
+	if !errors.Is(err, mpesa.ErrB2CNotConfigured) {
+		t.Fatalf("B2CConfigError() = %v; want ErrB2CNotConfigured", err)
+	}
+	if !strings.Contains(err.Error(), "initiator_name") || !strings.Contains(err.Error(), "security_credential") {
+		t.Errorf("B2CConfigError() = %v; want the missing settings named", err)
+	}
+
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanPro, IsActive: true}
+	db.Create(shop)
+	db.Create(&models.Supplier{ShopID: shop.ID, Name: "Brookside", Phone: "0722000111"})
+
+	cmdHandler := services.NewCommandHandler(db, repository.NewShopRepository(db), nil, nil, nil, nil)
+	cmdHandler.SetSupplierRepo(repository.NewSupplierRepository(db), repository.NewOrderRepository(db))
+	cmdHandler.SetMpesaService(svc)
+
+	reply, _ := cmdHandler.Handle(shop.Phone, services.NewCommandParser(nil, nil).Parse("supplier pay brookside 500"))
+	if !strings.Contains(reply, "initiator_name") {
+		t.Errorf("reply = %q; want the missing settings named", reply)
+	}
+}
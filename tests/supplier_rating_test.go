@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	supplierhandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/supplier"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestSupplierRatings tests rating suppliers on delivered orders and the
+// average showing on the supplier, in the list order and on WhatsApp
+func TestSupplierRatings(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.Sale{}, &models.DailySummary{},
+		&models.AuditLog{}, &models.Supplier{}, &models.Order{}, &models.OrderItem{}, &models.SupplierRating{})
+
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanPro, IsActive: true}
+	other := &models.Shop{Name: "Duka Jingine", Phone: "+254700000000", IsActive: true}
+	db.Create(shop)
+	db.Create(other)
+	brookside := &models.Supplier{ShopID: shop.ID, Name: "Brookside"}
+	bidco := &models.Supplier{ShopID: shop.ID, Name: "Bidco"}
+	db.Create(brookside)
+	db.Create(bidco)
+	pending := &models.Order{ShopID: shop.ID, SupplierID: brookside.ID, Status: models.OrderStatusPending}
+	first := &models.Order{ShopID: shop.ID, SupplierID: brookside.ID, Status: models.OrderStatusDelivered}
+	second := &models.Order{ShopID: shop.ID, SupplierID: brookside.ID, Status: models.OrderStatusDelivered}
+	third := &models.Order{ShopID: shop.ID, SupplierID: bidco.ID, Status: models.OrderStatusDelivered}
+	foreign := &models.Order{ShopID: other.ID, SupplierID: bidco.ID, Status: models.OrderStatusDelivered}
+	for _, o := range []*models.Order{pending, first, second, third, foreign} {
+		db.Create(o)
+	}
+
+	supplierRepo := repository.NewSupplierRepository(db)
+	orderRepo := repository.NewOrderRepository(db)
+	h := supplierhandler.New(supplierRepo, orderRepo, repository.NewProductRepository(db))
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("shop_id", shop.ID)
+		return c.Next()
+	})
+	app.Get("/suppliers", h.ListSuppliers)
+	app.Get("/suppliers/:id", h.GetSupplier)
+	app.Get("/suppliers/:id/ratings", h.ListRatings)
+	app.Post("/orders/:id/rating", h.RateOrder)
+
+	call := func(method, target, body string) (int, []byte) {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, target, err)
+		}
+		out, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, out
+	}
+	rate := func(order *models.Order, rating int, notes string) int {
+		status, _ := call("POST", fmt.Sprintf("/orders/%d/rating", order.ID),
+			fmt.Sprintf(`{"rating": %d, "notes": %q}`, rating, notes))
+		return status
+	}
+
+	if status := rate(pending, 4, ""); status != fiber.StatusConflict {
+		t.Errorf("rating a pending order: status %d; want 409", status)
+	}
+	if status := rate(first, 6, ""); status != fiber.StatusBadRequest {
+		t.Errorf("rating of 6: status %d; want 400", status)
+	}
+	if status := rate(foreign, 5, ""); status != fiber.StatusForbidden {
+		t.Errorf("rating another shop's order: status %d; want 403", status)
+	}
+	if status := rate(first, 2, "late"); status != fiber.StatusCreated {
+		t.Errorf("rating a delivered order: status %d; want 201", status)
+	}
+	// Rating the same order again replaces its rating
+	if status := rate(first, 4, "on time after all"); status != fiber.StatusCreated {
+		t.Errorf("re-rating: status %d; want 201", status)
+	}
+	rate(second, 5, "fresh stock")
+	rate(third, 5, "")
+
+	if ratings := supplierRepo.GetRatings(brookside.ID); len(ratings) != 2 {
+		t.Fatalf("Brookside ratings = %d; want 2 after re-rating", len(ratings))
+	}
+
+	status, out := call("GET", fmt.Sprintf("/suppliers/%d", brookside.ID), "")
+	var got supplierhandler.SupplierResponse
+	if err := json.Unmarshal(out, &got); err != nil || status != fiber.StatusOK {
+		t.Fatalf("GET supplier: status %d, %s", status, out)
+	}
+	if got.Average != 4.5 || got.Count != 2 {
+		t.Errorf("Brookside rating = %.2f from %d; want 4.5 from 2", got.Average, got.Count)
+	}
+
+	// Bidco's single 5 beats Brookside's 4.5
+	_, out = call("GET", "/suppliers?sort=rating", "")
+	var list []supplierhandler.SupplierResponse
+	if err := json.Unmarshal(out, &list); err != nil {
+		t.Fatalf("GET suppliers: %s", out)
+	}
+	if len(list) != 2 || list[0].Name != "Bidco" || list[1].Name != "Brookside" {
+		t.Errorf("suppliers by rating = %+v; want Bidco then Brookside", list)
+	}
+
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		repository.NewProductRepository(db),
+		repository.NewSaleRepository(db),
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	cmdHandler.SetSupplierRepo(supplierRepo, orderRepo)
+	reply, err := cmdHandler.Handle(shop.Phone, services.NewCommandParser(nil, nil).Parse("supplier view brookside"))
+	if err != nil {
+		t.Fatalf("supplier view error: %v", err)
+	}
+	if !strings.Contains(reply, "4.5/5 (2 ratings)") || !strings.Contains(reply, "fresh stock") {
+		t.Errorf("supplier view = %q; want the average and latest notes", reply)
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/ussd"
+)
+
+// newUSSDTestService returns a USSD service backed by a test database with
+// one shop registered on 0712345678 with PIN 1234
+func newUSSDTestService(t *testing.T) (*ussd.Service, *repository.ProductRepository, *models.Shop, *repository.ShopRepository) {
+	t.Helper()
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.StockMovement{}, &models.Sale{}, &models.DailySummary{})
+
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	shop.SetUSSDPin("1234")
+	db.Create(shop)
+
+	shopRepo := repository.NewShopRepository(db)
+	productRepo := repository.NewProductRepository(db)
+	svc := ussd.New()
+	svc.SetSessionStore(newMemorySessionStore())
+	svc.SetRepositories(shopRepo, productRepo,
+		repository.NewSaleRepository(db), repository.NewDailySummaryRepository(db))
+	return svc, productRepo, shop, shopRepo
+}
+
+// TestUSSDSellFlow tests selling through the menus using Africa's Talking
+// cumulative text
+func TestUSSDSellFlow(t *testing.T) {
+	svc, productRepo, shop, _ := newUSSDTestService(t)
+	for i := 1; i <= 7; i++ {
+		productRepo.Create(&models.Product{ShopID: shop.ID, Name: fmt.Sprintf("Item %d", i),
+			SellingPrice: 50, CostPrice: 30, CurrentStock: 10, Unit: "pcs", IsActive: true})
+	}
+
+	steps := []struct {
+		text string
+		want string
+	}{
+		{"", "Enter your PIN"},
+		{"1234", "DUKAPOS"},
+		{"1234*2", "Item 5"},
+		{"1234*2*98", "Item 7"},
+		{"1234*2*98*2", "Enter quantity"},
+		{"1234*2*98*2*3", "Total: KSh 150"},
+	}
+	for _, step := range steps {
+		resp := svc.Process("0712345678", "sell-1", step.text)
+		if resp.End || !strings.Contains(resp.Message, step.want) {
+			t.Fatalf("%q: got %q (end=%v); want it to continue with %q", step.text, resp.Message, resp.End, step.want)
+		}
+	}
+
+	resp := svc.Process("0712345678", "sell-1", "1234*2*98*2*3*1")
+	if !resp.End || !strings.Contains(resp.Message, "Sold 3 x Item 7") {
+		t.Fatalf("confirm: got %q; want the sale to end the session", resp.Message)
+	}
+
+	products, _ := productRepo.GetByShopID(shop.ID)
+	for _, p := range products {
+		if p.Name == "Item 7" && p.CurrentStock != 7 {
+			t.Errorf("Item 7 stock = %d; want 7 after selling 3", p.CurrentStock)
+		}
+	}
+}
+
+// TestUSSDSellRejectsTooMany tests that a quantity above stock is asked again
+func TestUSSDSellRejectsTooMany(t *testing.T) {
+	svc, productRepo, shop, _ := newUSSDTestService(t)
+	productRepo.Create(&models.Product{ShopID: shop.ID, Name: "Milk", SellingPrice: 60, CurrentStock: 2, Unit: "pcs", IsActive: true})
+
+	for _, text := range []string{"", "1234", "1234*2", "1234*2*1"} {
+		svc.Process("0712345678", "sell-2", text)
+	}
+	resp := svc.Process("0712345678", "sell-2", "1234*2*1*5")
+	if resp.End || !strings.Contains(resp.Message, "Only 2 pcs in stock") {
+		t.Errorf("got %q; want the quantity asked again", resp.Message)
+	}
+}
+
+// TestUSSDRestockFlow tests adding stock to an existing product
+func TestUSSDRestockFlow(t *testing.T) {
+	svc, productRepo, shop, _ := newUSSDTestService(t)
+	milk := &models.Product{ShopID: shop.ID, Name: "Milk", SellingPrice: 60, CurrentStock: 0, Unit: "pcs", IsActive: true}
+	productRepo.Create(milk)
+
+	for _, text := range []string{"", "1234", "1234*3", "1234*3*2", "1234*3*2*1", "1234*3*2*1*12"} {
+		svc.Process("0712345678", "restock-1", text)
+	}
+	resp := svc.Process("0712345678", "restock-1", "1234*3*2*1*12*1")
+	if !resp.End || !strings.Contains(resp.Message, "Added 12 pcs to Milk") {
+		t.Fatalf("confirm: got %q; want the restock to end the session", resp.Message)
+	}
+
+	got, _ := productRepo.GetByID(milk.ID)
+	if got.CurrentStock != 12 {
+		t.Errorf("stock = %d; want 12", got.CurrentStock)
+	}
+}
+
+// TestUSSDRegistration tests a new number registering a shop with a PIN
+func TestUSSDRegistration(t *testing.T) {
+	svc, _, _, shopRepo := newUSSDTestService(t)
+
+	steps := []struct {
+		text string
+		want string
+	}{
+		{"", "Enter shop name"},
+		{"Kibanda Stores", "owner's name"},
+		{"Kibanda Stores*Wanjiku", "Choose a 4-digit PIN"},
+		{"Kibanda Stores*Wanjiku*12", "must be 4 digits"},
+		{"Kibanda Stores*Wanjiku*12*4321", "confirm"},
+		{"Kibanda Stores*Wanjiku*12*4321*4321", "Shop registered"},
+	}
+	for _, step := range steps {
+		resp := svc.Process("0799999999", "reg-1", step.text)
+		if resp.End || !strings.Contains(resp.Message, step.want) {
+			t.Fatalf("%q: got %q (end=%v); want %q", step.text, resp.Message, resp.End, step.want)
+		}
+	}
+
+	shop, err := shopRepo.GetByPhone("+254799999999")
+	if err != nil {
+		t.Fatalf("shop not created: %v", err)
+	}
+	if shop.Name != "Kibanda Stores" || shop.OwnerName != "Wanjiku" || shop.USSDPinHash == "4321" || !shop.CheckUSSDPin("4321", time.Now()) {
+		t.Errorf("shop = %q by %q; want it registered with PIN 4321 stored hashed", shop.Name, shop.OwnerName)
+	}
+}
+
+// TestUSSDPinLockout tests wrong PINs locking the shop out of USSD
+func TestUSSDPinLockout(t *testing.T) {
+	svc, _, shop, shopRepo := newUSSDTestService(t)
+
+	svc.Process("0712345678", "pin-1", "")
+	for i, text := range []string{"1111", "1111*2222"} {
+		resp := svc.Process("0712345678", "pin-1", text)
+		if resp.End || !strings.Contains(resp.Message, fmt.Sprintf("%d attempt(s) left", 2-i)) {
+			t.Fatalf("wrong PIN %d: got %q", i+1, resp.Message)
+		}
+	}
+	resp := svc.Process("0712345678", "pin-1", "1111*2222*3333")
+	if !resp.End || !strings.Contains(resp.Message, "Too many wrong PINs") {
+		t.Fatalf("third wrong PIN: got %q; want the session ended", resp.Message)
+	}
+
+	// Even the right PIN is refused while locked
+	resp = svc.Process("0712345678", "pin-2", "")
+	if !resp.End || !strings.Contains(resp.Message, "Too many wrong PINs") {
+		t.Errorf("new session while locked: got %q", resp.Message)
+	}
+
+	got, _ := shopRepo.GetByID(shop.ID)
+	if !got.USSDPinLocked(time.Now()) || got.USSDPinLocked(time.Now().Add(models.USSDPinLockout)) {
+		t.Errorf("locked until %v; want a %s lockout", got.USSDPinLockedUntil, models.USSDPinLockout)
+	}
+}
+
+// TestUSSDChangePin tests changing the PIN from the settings menu
+func TestUSSDChangePin(t *testing.T) {
+	svc, _, shop, shopRepo := newUSSDTestService(t)
+
+	for _, text := range []string{"", "1234", "1234*8", "1234*8*1", "1234*8*1*1234", "1234*8*1*1234*5678"} {
+		if resp := svc.Process("0712345678", "chg-1", text); resp.End {
+			t.Fatalf("%q ended the session: %q", text, resp.Message)
+		}
+	}
+	resp := svc.Process("0712345678", "chg-1", "1234*8*1*1234*5678*5678")
+	if !resp.End || !strings.Contains(resp.Message, "PIN changed") {
+		t.Fatalf("confirm: got %q", resp.Message)
+	}
+
+	got, _ := shopRepo.GetByID(shop.ID)
+	if !got.CheckUSSDPin("5678", time.Now()) {
+		t.Error("new PIN not saved")
+	}
+}
+
+// TestUSSDRequiresDashboardPin tests that a shop without a PIN cannot set
+// one over USSD and is sent to the dashboard instead
+func TestUSSDRequiresDashboardPin(t *testing.T) {
+	svc, _, shop, shopRepo := newUSSDTestService(t)
+	shop.USSDPinHash = ""
+	if err := shopRepo.Update(shop); err != nil {
+		t.Fatalf("clear PIN: %v", err)
+	}
+
+	resp := svc.Process("0712345678", "nopin-1", "")
+	if !resp.End || !strings.Contains(resp.Message, "dashboard") {
+		t.Fatalf("start without PIN: got %q; want the session ended", resp.Message)
+	}
+
+	resp = svc.Process("0712345678", "nopin-1", "5678")
+	got, _ := shopRepo.GetByID(shop.ID)
+	if got.HasUSSDPin() {
+		t.Errorf("PIN set over USSD after %q", resp.Message)
+	}
+}
+
+// TestUSSDPickerSearchAndPages tests paging and searching a long product
+// list with every screen kept within the carrier limit
+func TestUSSDPickerSearchAndPages(t *testing.T) {
+	svc, productRepo, shop, _ := newUSSDTestService(t)
+	for i := 1; i <= 40; i++ {
+		productRepo.Create(&models.Product{ShopID: shop.ID, Name: fmt.Sprintf("Extra Long Product Name Number %02d", i),
+			SellingPrice: 1500, CurrentStock: 1000, Unit: "pcs", IsActive: true})
+	}
+	productRepo.Create(&models.Product{ShopID: shop.ID, Name: "Blue Band", SellingPrice: 250, CurrentStock: 5, Unit: "pcs", IsActive: true})
+	productRepo.Create(&models.Product{ShopID: shop.ID, Name: "Bread", SellingPrice: 60, CurrentStock: 5, Unit: "pcs", IsActive: true})
+
+	text := ""
+	step := func(input string) *ussd.Response {
+		t.Helper()
+		if text == "" && input != "" {
+			text = input
+		} else if input != "" {
+			text += "*" + input
+		}
+		resp := svc.Process("0712345678", "page-1", text)
+		if n := len([]rune(resp.Message)); n > ussd.MaxMessageLength {
+			t.Fatalf("%q: %d characters; want at most %d:\n%s", text, n, ussd.MaxMessageLength, resp.Message)
+		}
+		return resp
+	}
+
+	step("")
+	step("1234")
+	first := step("2")
+	if !strings.Contains(first.Message, "98. More") || !strings.Contains(first.Message, "99. Search") {
+		t.Fatalf("picker missing navigation: %q", first.Message)
+	}
+	for i := 0; i < 20; i++ {
+		step("98")
+	}
+
+	if resp := step("99"); !strings.Contains(resp.Message, "first letters") {
+		t.Fatalf("search prompt: got %q", resp.Message)
+	}
+	resp := step("ba")
+	if !strings.Contains(resp.Message, "Blue Band") || strings.Contains(resp.Message, "Bread") || strings.Contains(resp.Message, "98. More") {
+		t.Fatalf("search for ba: got %q; want only Blue Band", resp.Message)
+	}
+	if resp := step("1"); !strings.Contains(resp.Message, "Blue Band") || !strings.Contains(resp.Message, "Enter quantity") {
+		t.Fatalf("choosing a search result: got %q", resp.Message)
+	}
+
+	// Back from the quantity keeps the search; back again clears it
+	step("0")
+	if resp := step("0"); !strings.Contains(resp.Message, "Extra Long") {
+		t.Errorf("back out of search: got %q; want the full list", resp.Message)
+	}
+	if resp := step("0"); !strings.Contains(resp.Message, "DUKAPOS") {
+		t.Errorf("back out of picker: got %q; want the main menu", resp.Message)
+	}
+
+	// Stock list pages and searches the same way
+	step("1")
+	resp = step("1")
+	if !strings.Contains(resp.Message, "STOCK") || !strings.Contains(resp.Message, "98. More") {
+		t.Fatalf("stock list: got %q", resp.Message)
+	}
+	step("99")
+	if resp := step("bre"); !strings.Contains(resp.Message, "Bread") || strings.Contains(resp.Message, "Blue Band") {
+		t.Errorf("stock search: got %q; want Bread", resp.Message)
+	}
+	if resp := step("99"); !strings.Contains(resp.Message, "first letters") {
+		t.Errorf("search again: got %q", resp.Message)
+	}
+	if resp := step("zz"); !strings.Contains(resp.Message, "No products starting with") {
+		t.Errorf("search with no match: got %q", resp.Message)
+	}
+}
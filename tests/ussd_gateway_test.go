@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	ussdhandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/ussd"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/ussd"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestUSSDGatewayAdapters tests the same menus served through each
+// gateway's request and reply format
+func TestUSSDGatewayAdapters(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.Sale{}, &models.DailySummary{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	shop.SetUSSDPin("1234")
+	db.Create(shop)
+
+	svc := ussd.New()
+	svc.SetSessionStore(newMemorySessionStore())
+	svc.SetRepositories(repository.NewShopRepository(db), repository.NewProductRepository(db),
+		repository.NewSaleRepository(db), repository.NewDailySummaryRepository(db))
+	h := ussdhandler.New(svc)
+
+	comviva, err := ussdhandler.NewGenericAdapter(ussdhandler.GatewayConfig{
+		Format:       ussdhandler.FormatForm,
+		Fields:       map[string]string{"phone": "MSISDN", "session": "SESSIONID", "input": "INPUT"},
+		Continue:     "FC",
+		End:          "FB",
+		ActionHeader: "Freeflow",
+	})
+	if err != nil {
+		t.Fatalf("NewGenericAdapter(form) error: %v", err)
+	}
+	xmlGateway, err := ussdhandler.NewGenericAdapter(ussdhandler.GatewayConfig{
+		Format:     ussdhandler.FormatXML,
+		Fields:     map[string]string{"phone": "msisdn", "session": "sessionid", "input": "ussdstring", "message": "text", "action": "type"},
+		Cumulative: true,
+		Continue:   "1",
+		End:        "2",
+	})
+	if err != nil {
+		t.Fatalf("NewGenericAdapter(xml) error: %v", err)
+	}
+	jsonGateway, err := ussdhandler.NewGenericAdapter(ussdhandler.GatewayConfig{Format: ussdhandler.FormatJSON})
+	if err != nil {
+		t.Fatalf("NewGenericAdapter(json) error: %v", err)
+	}
+
+	app := fiber.New()
+	app.Post("/africa", h.HandleAfricaTalking)
+	app.Get("/comviva", h.Serve(comviva))
+	app.Post("/xml", h.Serve(xmlGateway))
+	app.Post("/json", h.Serve(jsonGateway))
+
+	call := func(method, target, body, contentType string) (string, string) {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, target, err)
+		}
+		out, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("%s %s: status %d: %s", method, target, resp.StatusCode, out)
+		}
+		return string(out), resp.Header.Get("Freeflow")
+	}
+
+	// Africa's Talking: cumulative text, CON/END prefixes
+	at := func(text string) string {
+		form := url.Values{"sessionId": {"at-1"}, "phoneNumber": {"+254712345678"}, "text": {text}}
+		out, _ := call("POST", "/africa", form.Encode(), "application/x-www-form-urlencoded")
+		return out
+	}
+	if out := at(""); !strings.HasPrefix(out, "CON ") || !strings.Contains(out, "Enter your PIN") {
+		t.Errorf("AT start = %q", out)
+	}
+	if out := at("1234"); !strings.HasPrefix(out, "CON ") || !strings.Contains(out, "DUKAPOS") {
+		t.Errorf("AT PIN = %q", out)
+	}
+	if out := at("1234*0"); !strings.HasPrefix(out, "END ") {
+		t.Errorf("AT exit = %q; want END", out)
+	}
+
+	// Comviva style: query string with only the latest input, Freeflow header
+	comvivaStep := func(input string) (string, string) {
+		q := url.Values{"MSISDN": {"254712345678"}, "SESSIONID": {"cv-1"}, "INPUT": {input}}
+		return call("GET", "/comviva?"+q.Encode(), "", "")
+	}
+	if out, flow := comvivaStep("*384#"); flow != "FC" || strings.HasPrefix(out, "FC") || !strings.Contains(out, "Enter your PIN") {
+		t.Errorf("Comviva start = %q (Freeflow %q)", out, flow)
+	}
+	if out, flow := comvivaStep("1234"); flow != "FC" || !strings.Contains(out, "DUKAPOS") {
+		t.Errorf("Comviva PIN = %q (Freeflow %q)", out, flow)
+	}
+	if _, flow := comvivaStep("0"); flow != "FB" {
+		t.Errorf("Comviva exit: Freeflow %q; want FB", flow)
+	}
+
+	// XML with the marker in its own element
+	xmlStep := func(text string) string {
+		out, _ := call("POST", "/xml", `<?xml version="1.0"?><ussd><msisdn>254712345678</msisdn><sessionid>x-1</sessionid>`+
+			`<ussdstring>`+text+`</ussdstring></ussd>`, "application/xml")
+		return out
+	}
+	xmlStep("")
+	out := xmlStep("1234")
+	if !strings.Contains(out, "<type>1</type>") || !strings.Contains(out, "DUKAPOS") || !strings.Contains(out, "<sessionid>x-1</sessionid>") {
+		t.Errorf("XML PIN = %q", out)
+	}
+	if out := xmlStep("1234*0"); !strings.Contains(out, "<type>2</type>") {
+		t.Errorf("XML exit = %q; want type 2", out)
+	}
+
+	// JSON with default field names and a numeric phone number; no action
+	// field, so the marker leads the message
+	jsonStep := func(input string) map[string]string {
+		out, _ := call("POST", "/json", `{"sessionId": "j-1", "msisdn": 254712345678, "input": "`+input+`"}`, "application/json")
+		var body map[string]string
+		if err := json.Unmarshal([]byte(out), &body); err != nil {
+			t.Fatalf("JSON reply %q: %v", out, err)
+		}
+		return body
+	}
+	jsonStep("")
+	if body := jsonStep("1234"); !strings.HasPrefix(body["message"], "CON ") || body["sessionId"] != "j-1" {
+		t.Errorf("JSON PIN = %v", body)
+	}
+
+	req := httptest.NewRequest("POST", "/json", strings.NewReader(`{"input": "1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	if resp, _ := app.Test(req); resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("request without a phone number: status %d; want 400", resp.StatusCode)
+	}
+}
+
+// TestUSSDGatewayConfig tests rejecting gateway settings that cannot work
+func TestUSSDGatewayConfig(t *testing.T) {
+	fields, err := ussdhandler.ParseGatewayFields(" phone=MSISDN , session=SESSIONID,")
+	if err != nil || fields["phone"] != "MSISDN" || fields["session"] != "SESSIONID" || len(fields) != 2 {
+		t.Errorf("ParseGatewayFields() = %v, %v", fields, err)
+	}
+	if _, err := ussdhandler.ParseGatewayFields("phone"); err == nil {
+		t.Error("ParseGatewayFields() should reject a pair without a name")
+	}
+	if _, err := ussdhandler.NewGenericAdapter(ussdhandler.GatewayConfig{Format: "soap"}); err == nil {
+		t.Error("NewGenericAdapter() should reject an unknown format")
+	}
+	if _, err := ussdhandler.NewGenericAdapter(ussdhandler.GatewayConfig{Format: "form", Fields: map[string]string{"pin": "PIN"}}); err == nil {
+		t.Error("NewGenericAdapter() should reject an unknown field")
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/ussd"
+)
+
+// TestUSSDReports tests the report screens read from daily summaries and
+// the full versions sent by SMS
+func TestUSSDReports(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.StockMovement{}, &models.Sale{}, &models.DailySummary{},
+		&models.WeeklySummary{}, &models.MonthlySummary{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	shop.SetUSSDPin("1234")
+	db.Create(shop)
+
+	productRepo := repository.NewProductRepository(db)
+	summaryRepo := repository.NewDailySummaryRepository(db)
+	svc := ussd.New()
+	svc.SetSessionStore(newMemorySessionStore())
+	svc.SetRepositories(repository.NewShopRepository(db), productRepo, repository.NewSaleRepository(db), summaryRepo)
+
+	type sms struct {
+		shopID         uint
+		phone, message string
+	}
+	var sent []sms
+	svc.SetSMSSender(func(shopID uint, phone, message string) error {
+		sent = append(sent, sms{shopID, phone, message})
+		return nil
+	})
+
+	session := ""
+	text := ""
+	step := func(input string) *ussd.Response {
+		t.Helper()
+		if text == "" && input != "" {
+			text = input
+		} else if input != "" {
+			text += "*" + input
+		}
+		resp := svc.Process("0712345678", session, text)
+		if n := len([]rune(resp.Message)); n > ussd.MaxMessageLength {
+			t.Fatalf("%q: %d characters; want at most %d:\n%s", text, n, ussd.MaxMessageLength, resp.Message)
+		}
+		return resp
+	}
+	start := func(id string) {
+		session, text = id, ""
+		step("")
+		step("1234")
+	}
+
+	// A shop with no sales
+	start("report-empty")
+	step("4")
+	if resp := step("2"); !strings.Contains(resp.Message, "No sales yet") || strings.Contains(resp.Message, "SMS") {
+		t.Errorf("week with no sales: got %q", resp.Message)
+	}
+	if resp := step("0"); !strings.Contains(resp.Message, "REPORTS") {
+		t.Errorf("back from a report: got %q; want the reports menu", resp.Message)
+	}
+
+	bread := &models.Product{ShopID: shop.ID, Name: "Bread", SellingPrice: 60, CostPrice: 45, CurrentStock: 3,
+		LowStockThreshold: 10, Unit: "loaf", IsActive: true}
+	milk := &models.Product{ShopID: shop.ID, Name: "Milk", SellingPrice: 50, CostPrice: 40, CurrentStock: 40, Unit: "pkt", IsActive: true}
+	productRepo.Create(bread)
+	productRepo.Create(milk)
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	sell := func(p *models.Product, qty int, day time.Time) {
+		db.Create(&models.Sale{ShopID: shop.ID, ProductID: p.ID, Quantity: qty, UnitPrice: p.SellingPrice,
+			TotalAmount: p.SellingPrice * float64(qty), CostAmount: p.CostPrice * float64(qty),
+			PaymentMethod: models.PaymentCash, CreatedAt: day.Add(time.Minute)})
+	}
+	sell(bread, 2, today)
+	sell(milk, 1, today)
+	sell(milk, 10, today.AddDate(0, 0, -3))
+	summaryRepo.Recalculate(shop.ID, today.AddDate(0, 0, -3))
+	// Today's summary is not refreshed by these sales; the report does it
+
+	start("report-today")
+	step("4")
+	resp := step("1")
+	for _, want := range []string{"Sales: KSh 170", "Txns: 2", "Profit: KSh 40", "Top: Bread x2", "1. SMS full report"} {
+		if !strings.Contains(resp.Message, want) {
+			t.Errorf("today's report: got %q; want %q", resp.Message, want)
+		}
+	}
+
+	start("report-week")
+	step("4")
+	resp = step("2")
+	for _, want := range []string{"LAST 7 DAYS", "Sales: KSh 670", "Txns: 3", "Avg/day: KSh 96", "Best: " + today.AddDate(0, 0, -3).Format("Mon 2") + " KSh 500"} {
+		if !strings.Contains(resp.Message, want) {
+			t.Errorf("week report: got %q; want %q", resp.Message, want)
+		}
+	}
+	if resp := step("1"); !resp.End || !strings.Contains(resp.Message, "sent by SMS") {
+		t.Fatalf("SMS report: got %q (end=%v)", resp.Message, resp.End)
+	}
+	if len(sent) != 1 || sent[0].shopID != shop.ID || sent[0].phone != "+254712345678" {
+		t.Fatalf("sent = %+v; want one SMS to the shop", sent)
+	}
+	for _, want := range []string{"Mama Mboga", "Sales: KSh 670 (3 txns)", "By day:", "Top products:\nMilk x11 - KSh 550\nBread x2 - KSh 120"} {
+		if !strings.Contains(sent[0].message, want) {
+			t.Errorf("SMS report = %q; want %q", sent[0].message, want)
+		}
+	}
+
+	// Low stock from the reports menu, back to the reports menu
+	start("report-low")
+	step("4")
+	resp = step("4")
+	if !strings.Contains(resp.Message, "Bread 3/10") || !strings.Contains(resp.Message, "1. SMS full list") {
+		t.Fatalf("low stock: got %q", resp.Message)
+	}
+	if resp := step("1"); !resp.End || len(sent) != 2 || !strings.Contains(sent[1].message, "Bread: 3 loaf (min 10)") {
+		t.Errorf("SMS low stock: got %q, sent %+v", resp.Message, sent)
+	}
+	start("report-low-back")
+	step("4")
+	step("4")
+	if resp := step("0"); !strings.Contains(resp.Message, "REPORTS") {
+		t.Errorf("back from low stock: got %q; want the reports menu", resp.Message)
+	}
+}
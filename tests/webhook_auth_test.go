@@ -0,0 +1,191 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/middleware"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestTwilioRequestSignature checks the signature against Twilio's documented example
+func TestTwilioRequestSignature(t *testing.T) {
+	params := map[string]string{
+		"CallSid": "CA1234567890ABCDE",
+		"Caller":  "+12349013030",
+		"Digits":  "1234",
+		"From":    "+12349013030",
+		"To":      "+18005551212",
+	}
+
+	got := middleware.TwilioRequestSignature("12345", "https://mycompany.com/myapp.php?foo=1&bar=2", params)
+	if got != "0/KCTR6DLpKmkAf8muzZqo1nDgQ=" {
+		t.Errorf("unexpected signature: %s", got)
+	}
+}
+
+// TestTwilioSignatureMiddleware tests that unsigned and forged webhooks are rejected
+func TestTwilioSignatureMiddleware(t *testing.T) {
+	app := fiber.New()
+	app.Post("/webhook/twilio", middleware.TwilioSignature("secret", "https://pos.example.com", true), func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	form := url.Values{"From": {"whatsapp:+254712345678"}, "Body": {"stock"}}
+	valid := middleware.TwilioRequestSignature("secret", "https://pos.example.com/webhook/twilio", map[string]string{
+		"From": "whatsapp:+254712345678",
+		"Body": "stock",
+	})
+
+	tests := []struct {
+		name      string
+		signature string
+		expected  int
+	}{
+		{"valid signature", valid, 200},
+		{"missing signature", "", 403},
+		{"forged signature", "bm90LWEtc2lnbmF0dXJl", 403},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/webhook/twilio", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.signature != "" {
+				req.Header.Set("X-Twilio-Signature", tt.signature)
+			}
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if resp.StatusCode != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, resp.StatusCode)
+			}
+		})
+	}
+}
+
+// TestTwilioSignatureRequired tests that webhooks are rejected without an
+// auth token when verification is required, and let through when it isn't
+func TestTwilioSignatureRequired(t *testing.T) {
+	handler := func(c *fiber.Ctx) error { return c.SendString("ok") }
+	for _, tt := range []struct {
+		required bool
+		expected int
+	}{{true, 403}, {false, 200}} {
+		app := fiber.New()
+		app.Post("/webhook/twilio", middleware.TwilioSignature("", "", tt.required), handler)
+
+		req := httptest.NewRequest("POST", "/webhook/twilio", strings.NewReader("Body=stock"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		if resp.StatusCode != tt.expected {
+			t.Errorf("required=%v: expected %d, got %d", tt.required, tt.expected, resp.StatusCode)
+		}
+	}
+}
+
+// TestMpesaCallbackAuth tests token and IP allowlist checks on M-Pesa callbacks
+func TestMpesaCallbackAuth(t *testing.T) {
+	handler := func(c *fiber.Ctx) error { return c.SendString("ok") }
+
+	tokenApp := fiber.New()
+	tokenApp.Post("/webhook/mpesa/stk", middleware.MpesaCallbackAuth(nil, "s3cret"), handler)
+
+	ipApp := fiber.New()
+	ipApp.Post("/webhook/mpesa/stk", middleware.MpesaCallbackAuth([]string{"10.0.0.0/8"}, ""), handler)
+
+	safaricomApp := fiber.New()
+	safaricomApp.Post("/webhook/mpesa/stk", middleware.MpesaCallbackAuth([]string{"safaricom"}, ""), handler)
+
+	// Behind a trusted proxy the allowlist sees the address it forwards,
+	// and only then
+	proxied := func(trusted []string) *fiber.App {
+		app := fiber.New(fiber.Config{
+			ProxyHeader:             "X-Real-IP",
+			EnableTrustedProxyCheck: true,
+			TrustedProxies:          trusted,
+		})
+		app.Post("/webhook/mpesa/stk", middleware.MpesaCallbackAuth([]string{"safaricom"}, ""), handler)
+		return app
+	}
+
+	tests := []struct {
+		name     string
+		app      *fiber.App
+		target   string
+		expected int
+	}{
+		{"valid token", tokenApp, "/webhook/mpesa/stk?token=s3cret", 200},
+		{"wrong token", tokenApp, "/webhook/mpesa/stk?token=guess", 403},
+		{"missing token", tokenApp, "/webhook/mpesa/stk", 403},
+		{"source outside allowlist", ipApp, "/webhook/mpesa/stk", 403},
+		{"source not safaricom", safaricomApp, "/webhook/mpesa/stk", 403},
+		{"safaricom behind trusted proxy", proxied([]string{"0.0.0.0"}), "/webhook/mpesa/stk", 200},
+		{"forged header without trusted proxy", proxied(nil), "/webhook/mpesa/stk", 403},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.target, strings.NewReader("{}"))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Real-IP", middleware.SafaricomCallbackIPs[0])
+
+			resp, err := tt.app.Test(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if resp.StatusCode != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, resp.StatusCode)
+			}
+		})
+	}
+}
+
+// TestUSSDGatewayAuth tests that USSD requests need the gateway token or an
+// allowed source, and that an unconfigured gateway rejects everything
+func TestUSSDGatewayAuth(t *testing.T) {
+	handler := func(c *fiber.Ctx) error { return c.SendString("ok") }
+
+	tokenApp := fiber.New()
+	tokenApp.Post("/ussd", middleware.USSDGatewayAuth(nil, "s3cret"), handler)
+
+	ipApp := fiber.New()
+	ipApp.Post("/ussd", middleware.USSDGatewayAuth([]string{"10.0.0.0/8"}, ""), handler)
+
+	openApp := fiber.New()
+	openApp.Post("/ussd", middleware.USSDGatewayAuth(nil, ""), handler)
+
+	tests := []struct {
+		name     string
+		app      *fiber.App
+		target   string
+		expected int
+	}{
+		{"valid token", tokenApp, "/ussd?token=s3cret", 200},
+		{"wrong token", tokenApp, "/ussd?token=guess", 403},
+		{"missing token", tokenApp, "/ussd", 403},
+		{"source outside allowlist", ipApp, "/ussd", 403},
+		{"not configured", openApp, "/ussd?token=anything", 403},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.target, strings.NewReader("phoneNumber=%2B254712345678"))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			resp, err := tt.app.Test(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if resp.StatusCode != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, resp.StatusCode)
+			}
+		})
+	}
+}
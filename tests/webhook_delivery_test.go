@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	webhookhandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/webhook"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/webhook"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestWebhookDeliveryLogAndRetry tests that test sends are logged per attempt
+// and that a failed delivery can be retried by hand
+func TestWebhookDeliveryLogAndRetry(t *testing.T) {
+	var calls int32
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("boom"))
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer endpoint.Close()
+
+	db := openTestDB(t, &models.Webhook{})
+	deliverySvc := webhook.NewDeliveryService(db, 1, 0)
+	deliverySvc.Start(context.Background())
+	defer deliverySvc.Shutdown(context.Background())
+
+	hook := &models.Webhook{ShopID: 1, Name: "erp", URL: endpoint.URL, Events: "all", IsActive: true}
+	if err := db.Create(hook).Error; err != nil {
+		t.Fatalf("failed to create webhook: %v", err)
+	}
+
+	deliveryRepo := repository.NewWebhookDeliveryRepository(db)
+	handler := webhookhandler.New(repository.NewWebhookRepository(db))
+	handler.SetDeliveries(deliveryRepo, deliverySvc)
+
+	newApp := func(shopID uint) *fiber.App {
+		app := fiber.New()
+		app.Use(func(c *fiber.Ctx) error {
+			c.Locals("shop_id", shopID)
+			return c.Next()
+		})
+		app.Post("/webhooks/:id/test", handler.Test)
+		app.Get("/webhooks/:id/deliveries", handler.ListDeliveries)
+		app.Post("/webhooks/:id/deliveries/:deliveryId/retry", handler.RetryDelivery)
+		return app
+	}
+	app := newApp(1)
+
+	waitForDeliveries := func(n int) []models.WebhookDelivery {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			deliveries, _ := deliveryRepo.GetByWebhook(hook.ID, 10)
+			if len(deliveries) >= n {
+				return deliveries
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %d deliveries, got %d", n, len(deliveries))
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	resp, _ := app.Test(httptest.NewRequest("POST", fmt.Sprintf("/webhooks/%d/test", hook.ID), nil))
+	if resp.StatusCode != fiber.StatusAccepted {
+		t.Fatalf("test send status = %d; want 202", resp.StatusCode)
+	}
+
+	failed := waitForDeliveries(1)[0]
+	if failed.Status != "failed" || failed.HTTPStatus != 500 || failed.ResponseBody != "boom" || failed.Attempt != 1 {
+		t.Errorf("unexpected failed delivery: %+v", failed)
+	}
+	if failed.Event != string(webhook.EventTest) {
+		t.Errorf("delivery event = %q; want %q", failed.Event, webhook.EventTest)
+	}
+
+	resp, _ = app.Test(httptest.NewRequest("GET", fmt.Sprintf("/webhooks/%d/deliveries", hook.ID), nil))
+	if resp.StatusCode != fiber.StatusOK {
+		t.Errorf("list deliveries status = %d; want 200", resp.StatusCode)
+	}
+
+	retryPath := fmt.Sprintf("/webhooks/%d/deliveries/%d/retry", hook.ID, failed.ID)
+
+	// Another shop can neither see nor retry the delivery
+	other := newApp(2)
+	resp, _ = other.Test(httptest.NewRequest("GET", fmt.Sprintf("/webhooks/%d/deliveries", hook.ID), nil))
+	if resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("other shop list status = %d; want 404", resp.StatusCode)
+	}
+	resp, _ = other.Test(httptest.NewRequest("POST", retryPath, nil))
+	if resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("other shop retry status = %d; want 404", resp.StatusCode)
+	}
+
+	resp, _ = app.Test(httptest.NewRequest("POST", retryPath, nil))
+	if resp.StatusCode != fiber.StatusAccepted {
+		t.Fatalf("retry status = %d; want 202", resp.StatusCode)
+	}
+
+	retried := waitForDeliveries(2)[0]
+	if retried.Status != "success" || retried.HTTPStatus != 200 || retried.Attempt != 2 || retried.EventID != failed.EventID {
+		t.Errorf("unexpected retried delivery: %+v", retried)
+	}
+}
+
+// TestWebhookShutdownWithRetryPending tests that shutting down with a retry
+// scheduled returns promptly and leaves the retry in the database rather
+// than sending it to the stopped workers
+func TestWebhookShutdownWithRetryPending(t *testing.T) {
+	var calls int32
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer endpoint.Close()
+
+	db := openTestDB(t, &models.Webhook{})
+	deliverySvc := webhook.NewDeliveryService(db, 1, 5)
+	deliverySvc.SetRetryDelay(200 * time.Millisecond)
+	deliverySvc.Start(context.Background())
+
+	hook := &models.Webhook{ShopID: 1, Name: "erp", URL: endpoint.URL, Events: "all", IsActive: true}
+	db.Create(hook)
+	if err := deliverySvc.TriggerShopEvent(1, webhook.EventSaleCreated, map[string]interface{}{"sale_id": 1}); err != nil {
+		t.Fatalf("TriggerShopEvent() error: %v", err)
+	}
+
+	var event webhook.WebhookEvent
+	deadline := time.Now().Add(5 * time.Second)
+	for db.Where("webhook_id = ? AND status = ?", hook.ID, "retry_scheduled").First(&event).Error != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("failed delivery was not scheduled for a retry")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := deliverySvc.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error: %v", err)
+	}
+
+	time.Sleep(400 * time.Millisecond) // past the retry delay
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("endpoint called %d times; want no retry after shutdown", n)
+	}
+	db.First(&event, event.ID)
+	if event.Status != "retry_scheduled" || event.Attempts != 1 {
+		t.Errorf("event = %s after %d attempts; want retry_scheduled after 1", event.Status, event.Attempts)
+	}
+}
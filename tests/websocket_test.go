@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/websocket"
+	wsclient "github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestWebSocketDashboardEvents tests that dashboard connections are
+// authenticated by token and get only their own shop's sales and stock
+// alerts
+func TestWebSocketDashboardEvents(t *testing.T) {
+	websocket.Init()
+	websocket.SetAuthenticator(func(token string) (uint, error) {
+		switch token {
+		case "shop-1-token":
+			return 1, nil
+		case "shop-2-token":
+			return 2, nil
+		}
+		return 0, errors.New("invalid token")
+	})
+
+	app := fiber.New()
+	app.Get("/ws", websocket.HandleWebSocket)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go app.Listener(ln)
+	defer app.Shutdown()
+
+	dial := func(query string) (*wsclient.Conn, int) {
+		t.Helper()
+		conn, resp, err := wsclient.DefaultDialer.Dial(fmt.Sprintf("ws://%s/ws?%s", ln.Addr(), query), nil)
+		if err != nil {
+			if resp == nil {
+				t.Fatalf("dial %s: %v", query, err)
+			}
+			return nil, resp.StatusCode
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		return conn, http.StatusSwitchingProtocols
+	}
+	read := func(conn *wsclient.Conn) map[string]interface{} {
+		t.Helper()
+		var msg map[string]interface{}
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return msg
+	}
+
+	for query, want := range map[string]int{
+		"":                             http.StatusUnauthorized,
+		"token=forged":                 http.StatusUnauthorized,
+		"token=shop-1-token&shop_id=2": http.StatusForbidden,
+	} {
+		if _, status := dial(query); status != want {
+			t.Errorf("dial ?%s: status %d; want %d", query, status, want)
+		}
+	}
+
+	cashier, _ := dial("token=shop-1-token")
+	defer cashier.Close()
+	if msg := read(cashier); msg["type"] != "connected" {
+		t.Fatalf("first message = %v; want connected", msg)
+	}
+	other, _ := dial("token=shop-2-token&shop_id=2")
+	defer other.Close()
+	read(other)
+
+	// A token can't be used to listen in on another shop
+	cashier.WriteJSON(map[string]interface{}{"type": "subscribe", "payload": map[string]uint{"shop_id": 2}})
+	if msg := read(cashier); msg["type"] != "error" {
+		t.Errorf("subscribe to another shop = %v; want an error", msg)
+	}
+
+	milk := &models.Product{ID: 7, ShopID: 1, Name: "Milk", Unit: "packet", LowStockThreshold: 5}
+	websocket.NotifySaleCreated(&models.Sale{ID: 1, ShopID: 1, ProductID: milk.ID, Quantity: 2, TotalAmount: 120}, milk, 10)
+	websocket.NotifySaleCreated(&models.Sale{ID: 2, ShopID: 1, ProductID: milk.ID, Quantity: 5, TotalAmount: 300}, milk, 5)
+	websocket.NotifySaleCreated(&models.Sale{ID: 3, ShopID: 2, ProductID: 9, Quantity: 1, TotalAmount: 50}, nil, 0)
+
+	msg := read(cashier)
+	sale, _ := msg["sale"].(map[string]interface{})
+	if msg["event"] != "sale_created" || sale["id"] != float64(1) || sale["total_amount"] != float64(120) {
+		t.Fatalf("first event = %v; want sale 1", msg)
+	}
+	if msg := read(cashier); msg["event"] != "sale_created" {
+		t.Fatalf("second event = %v; want sale 2", msg)
+	}
+	msg = read(cashier)
+	product, _ := msg["product"].(map[string]interface{})
+	if msg["event"] != "stock_alert" || product["name"] != "Milk" || product["current_stock"] != float64(5) || product["threshold"] != float64(5) {
+		t.Errorf("third event = %v; want a stock alert for Milk at 5", msg)
+	}
+
+	// Shop 2 sees only its own sale
+	msg = read(other)
+	sale, _ = msg["sale"].(map[string]interface{})
+	if msg["event"] != "sale_created" || sale["id"] != float64(3) {
+		t.Errorf("shop 2 event = %v; want only sale 3", msg)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/config"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/handlers"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestWhatsAppWebhookDedup tests that a redelivered Twilio webhook records a single sale
+func TestWhatsAppWebhookDedup(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.StockMovement{}, &models.Sale{}, &models.DailySummary{}, &models.AuditLog{})
+
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanFree, IsActive: true}
+	if err := db.Create(shop).Error; err != nil {
+		t.Fatalf("failed to create shop: %v", err)
+	}
+	product := &models.Product{ShopID: shop.ID, Name: "Bread", SellingPrice: 60, CostPrice: 50, CurrentStock: 10, IsActive: true}
+	if err := db.Create(product).Error; err != nil {
+		t.Fatalf("failed to create product: %v", err)
+	}
+
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		repository.NewProductRepository(db),
+		repository.NewSaleRepository(db),
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	h := handlers.NewWhatsAppHandler(cmdHandler, &config.Config{})
+
+	app := fiber.New()
+	app.Post("/webhook/twilio", h.HandleWebhook)
+
+	form := url.Values{
+		"MessageSid": {"SM1234567890abcdef1234567890abcdef"},
+		"From":       {"whatsapp:+254712345678"},
+		"Body":       {"sell bread 2"},
+	}
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/webhook/twilio", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("delivery %d failed: %v", i+1, err)
+		}
+		if resp.StatusCode != fiber.StatusOK {
+			t.Errorf("delivery %d: status %d; want 200", i+1, resp.StatusCode)
+		}
+	}
+
+	var sales int64
+	db.Model(&models.Sale{}).Where("shop_id = ?", shop.ID).Count(&sales)
+	if sales != 1 {
+		t.Errorf("expected 1 sale, got %d", sales)
+	}
+
+	var stock models.Product
+	db.First(&stock, product.ID)
+	if stock.CurrentStock != 8 {
+		t.Errorf("stock = %d; want 8", stock.CurrentStock)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/config"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/handlers"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestWhatsAppDeliveryTracking tests that sent messages are recorded, that
+// status callbacks update them without going backwards, and that admins
+// are alerted once a shop's messages keep failing
+func TestWhatsAppDeliveryTracking(t *testing.T) {
+	var sent int
+	var callbackURL string
+	twilio := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		callbackURL = r.PostForm.Get("StatusCallback")
+		sent++
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"sid": fmt.Sprintf("SM%032d", sent), "status": "queued"})
+	}))
+	defer twilio.Close()
+
+	db := openTestDB(t, &models.Shop{}, &models.OutboundMessage{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", IsActive: true}
+	db.Create(shop)
+
+	h := handlers.NewWhatsAppHandler(nil, &config.Config{
+		TwilioAccountSID:     "AC123",
+		TwilioAuthToken:      "token",
+		TwilioWhatsAppNumber: "whatsapp:+14155238886",
+		TwilioBaseURL:        twilio.URL,
+		WebhookBaseURL:       "https://pos.example.com",
+	})
+	messageRepo := repository.NewOutboundMessageRepository(db)
+	var alerts []int
+	h.SetDeliveryTracking(messageRepo, repository.NewShopRepository(db), func(s *models.Shop, failures int) {
+		alerts = append(alerts, failures)
+	})
+
+	app := fiber.New()
+	app.Post("/webhook/twilio/status", h.HandleStatusCallback)
+	status := func(sid, status, errorCode string) {
+		form := url.Values{"MessageSid": {sid}, "MessageStatus": {status}, "ErrorCode": {errorCode}}
+		req := httptest.NewRequest("POST", "/webhook/twilio/status", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if resp, err := app.Test(req); err != nil || resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("status callback failed: %v", err)
+		}
+	}
+
+	if err := h.SendWhatsAppMessageAs(shop.Phone, models.MessageTypeDailyReport, "📊 DAILY REPORT"); err != nil {
+		t.Fatalf("SendWhatsAppMessageAs() error: %v", err)
+	}
+	if callbackURL != "https://pos.example.com/webhook/twilio/status" {
+		t.Errorf("StatusCallback = %q", callbackURL)
+	}
+	report, err := messageRepo.GetBySID(fmt.Sprintf("SM%032d", 1))
+	if err != nil {
+		t.Fatalf("sent message not recorded: %v", err)
+	}
+	if report.ShopID != shop.ID || report.Type != models.MessageTypeDailyReport || report.Status != models.MessageStatusQueued {
+		t.Errorf("recorded message = %+v", report)
+	}
+
+	// Callbacks can arrive out of order; a late "sent" doesn't undo "delivered"
+	status(report.SID, models.MessageStatusDelivered, "")
+	status(report.SID, models.MessageStatusSent, "")
+	report, _ = messageRepo.GetBySID(report.SID)
+	if report.Status != models.MessageStatusDelivered || report.DeliveredAt == nil {
+		t.Errorf("status = %s, delivered at %v; want delivered", report.Status, report.DeliveredAt)
+	}
+
+	// Five failures in a row alert admins once
+	for i := 0; i < handlers.FailureAlertThreshold+1; i++ {
+		h.SendWhatsAppMessage(shop.Phone, "⚠️ LOW STOCK ALERT")
+		status(fmt.Sprintf("SM%032d", sent), models.MessageStatusUndelivered, "63024")
+	}
+	if len(alerts) != 1 || alerts[0] != handlers.FailureAlertThreshold {
+		t.Errorf("alerts = %v; want one after %d failures", alerts, handlers.FailureAlertThreshold)
+	}
+
+	failed, total, err := messageRepo.List(shop.ID, models.MessageStatusFailed, "", 50, 0)
+	if err != nil || total != int64(handlers.FailureAlertThreshold+1) {
+		t.Fatalf("failed messages = %d, %v; want %d", total, err, handlers.FailureAlertThreshold+1)
+	}
+	if failed[0].ErrorCode != "63024" {
+		t.Errorf("error code = %q; want 63024", failed[0].ErrorCode)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/media"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/qr"
+)
+
+// TestMediaHost tests that hosted files expire and are cleaned up
+func TestMediaHost(t *testing.T) {
+	now := time.Now()
+	host := media.NewHost(t.TempDir(), "https://pos.example.com/media/", time.Hour)
+	host.SetClock(func() time.Time { return now })
+
+	if _, err := host.Put([]byte("x"), "exe"); !errors.Is(err, media.ErrUnsupported) {
+		t.Errorf("Put(exe) error = %v; want ErrUnsupported", err)
+	}
+
+	url, err := host.Put([]byte("png data"), "png")
+	if err != nil {
+		t.Fatalf("Put() error: %v", err)
+	}
+	if !strings.HasPrefix(url, "https://pos.example.com/media/") || !strings.HasSuffix(url, ".png") {
+		t.Errorf("url = %q", url)
+	}
+	name := path.Base(url)
+	if _, err := host.Path(name); err != nil {
+		t.Errorf("Path(%q) error: %v", name, err)
+	}
+	if _, err := host.Path("../dukapos.db"); !errors.Is(err, media.ErrNotFound) {
+		t.Errorf("Path(traversal) error = %v; want ErrNotFound", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, err := host.Path(name); !errors.Is(err, media.ErrNotFound) {
+		t.Errorf("expired Path() error = %v; want ErrNotFound", err)
+	}
+	if n, err := host.Cleanup(); err != nil || n != 1 {
+		t.Errorf("Cleanup() = %d, %v; want 1", n, err)
+	}
+}
+
+// TestWhatsAppMediaCommands tests that QR codes and receipts are sent as
+// media attachments
+func TestWhatsAppMediaCommands(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.Sale{}, &models.AuditLog{})
+
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanBusiness, IsActive: true}
+	db.Create(shop)
+	milk := &models.Product{ShopID: shop.ID, Name: "Milk", SellingPrice: 60, CostPrice: 45, CurrentStock: 10, IsActive: true}
+	db.Create(milk)
+	sale := &models.Sale{ShopID: shop.ID, ProductID: milk.ID, Quantity: 2, UnitPrice: 60, TotalAmount: 120, PaymentMethod: models.PaymentCash}
+	db.Create(sale)
+
+	shopRepo := repository.NewShopRepository(db)
+	saleRepo := repository.NewSaleRepository(db)
+	productRepo := repository.NewProductRepository(db)
+	cmdHandler := services.NewCommandHandler(db, shopRepo, productRepo, saleRepo,
+		repository.NewDailySummaryRepository(db), repository.NewAuditLogRepository(db))
+	cmdHandler.SetQRService(qr.NewQRPaymentService(db, nil, shopRepo, saleRepo, productRepo))
+
+	parser := services.NewCommandParser(nil, nil)
+	send := func(message string) string {
+		reply, err := cmdHandler.Handle(shop.Phone, parser.Parse(message))
+		if err != nil {
+			t.Fatalf("Handle(%q) error: %v", message, err)
+		}
+		return reply
+	}
+
+	// Without a media sender the QR payload falls back to text
+	if reply := send("qr static"); !strings.Contains(reply, "QR Code: ") {
+		t.Errorf("qr static without media = %q; want the payload as text", reply)
+	}
+
+	dir := t.TempDir()
+	type attachment struct{ to, caption, url string }
+	var sent []attachment
+	cmdHandler.SetMediaSender(media.NewHost(dir, "https://pos.example.com/media", 0),
+		func(to, caption, url string) error {
+			sent = append(sent, attachment{to, caption, url})
+			return nil
+		})
+	cmdHandler.SetReceiptLinker(qr.NewReceiptLinker("https://receipt.example.com", "secret"))
+
+	if reply := send("qr static"); !strings.Contains(reply, "sent as an image") {
+		t.Errorf("qr static = %q; want the image notice", reply)
+	}
+	if len(sent) != 1 || sent[0].to != shop.Phone || !strings.HasSuffix(sent[0].url, ".png") {
+		t.Fatalf("sent = %+v; want one PNG to the shop", sent)
+	}
+	png, _ := os.ReadFile(dir + "/" + path.Base(sent[0].url))
+	if !bytes.HasPrefix(png, []byte("\x89PNG")) {
+		t.Errorf("hosted QR is not a PNG")
+	}
+
+	if reply := send("receipt"); !strings.Contains(reply, "Receipt #1 sent") {
+		t.Errorf("receipt = %q", reply)
+	}
+	if len(sent) != 2 || !strings.HasSuffix(sent[1].url, ".pdf") {
+		t.Fatalf("sent = %+v; want the receipt PDF", sent)
+	}
+	pdf, _ := os.ReadFile(dir + "/" + path.Base(sent[1].url))
+	if !bytes.HasPrefix(pdf, []byte("%PDF")) {
+		t.Errorf("hosted receipt is not a PDF")
+	}
+
+	if reply := send("risiti 99"); !strings.Contains(reply, "not found") {
+		t.Errorf("receipt for a missing sale = %q", reply)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+)
+
+// fakeMenuStore is an in-memory MenuStore standing in for Redis
+type fakeMenuStore struct {
+	data map[string][]byte
+}
+
+func (f *fakeMenuStore) GetWhatsAppMenu(phone string) ([]byte, error) {
+	return f.data[phone], nil
+}
+
+func (f *fakeMenuStore) SetWhatsAppMenu(phone string, data []byte, ttl time.Duration) error {
+	f.data[phone] = data
+	return nil
+}
+
+// TestMenuSessionResolveOption tests translating numbered replies into commands
+func TestMenuSessionResolveOption(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		store services.MenuStore
+	}{
+		{"memory", nil},
+		{"store", &fakeMenuStore{data: make(map[string][]byte)}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			menus := services.NewMenuSessionService()
+			if tt.store != nil {
+				menus.SetStore(tt.store)
+			}
+
+			if got := menus.ResolveOption("+254712345678", "1"); got != "1" {
+				t.Errorf("without a menu: got %q; want input unchanged", got)
+			}
+
+			if err := menus.SetMenu("+254712345678", []string{"sell", "stock", "report"}); err != nil {
+				t.Fatalf("SetMenu() error: %v", err)
+			}
+
+			cases := map[string]string{
+				"1":         "sell",
+				"1 bread 2": "sell bread 2",
+				" 3 ":       "report",
+				"4":         "4",
+				"0":         "0",
+				"sell milk": "sell milk",
+				"2nd":       "2nd",
+			}
+			for input, want := range cases {
+				if got := menus.ResolveOption("+254712345678", input); got != want {
+					t.Errorf("ResolveOption(%q) = %q; want %q", input, got, want)
+				}
+			}
+
+			if got := menus.ResolveOption("+254700000000", "1"); got != "1" {
+				t.Errorf("another phone's menu was used: got %q", got)
+			}
+		})
+	}
+}
+
+// TestWhatsAppNumberedMenu tests the feature phone profile end to end
+func TestWhatsAppNumberedMenu(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Product{}, &models.StockMovement{}, &models.Sale{}, &models.DailySummary{}, &models.AuditLog{})
+
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", Plan: models.PlanFree, IsActive: true}
+	if err := db.Create(shop).Error; err != nil {
+		t.Fatalf("failed to create shop: %v", err)
+	}
+	db.Create(&models.Product{ShopID: shop.ID, Name: "Bread", SellingPrice: 60, CostPrice: 50, CurrentStock: 10, LowStockThreshold: 2, IsActive: true})
+
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		repository.NewProductRepository(db),
+		repository.NewSaleRepository(db),
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	cmdHandler.SetMenuSessions(services.NewMenuSessionService())
+	parser := services.NewCommandParser(nil, nil)
+	send := func(message string) string {
+		reply, err := cmdHandler.Handle(shop.Phone, parser.Parse(message))
+		if err != nil {
+			t.Fatalf("Handle(%q) error: %v", message, err)
+		}
+		return reply
+	}
+
+	// Smartphone profile: numbers are not commands
+	send("help")
+	if reply := send("1"); !strings.Contains(reply, "Unknown command") {
+		t.Errorf("numbers should not resolve without the basic profile, got %q", reply)
+	}
+
+	if reply := send("set phone basic"); !strings.Contains(reply, "Numbered menus on") {
+		t.Fatalf("unexpected reply to set phone basic: %q", reply)
+	}
+	reply := send("help")
+	if !strings.Contains(reply, "1. Sell") || !strings.Contains(reply, "3. Stock") {
+		t.Fatalf("help should be a numbered menu, got %q", reply)
+	}
+
+	if reply := send("1 bread 2"); !strings.Contains(reply, "SOLD") {
+		t.Errorf("1 bread 2 should sell bread, got %q", reply)
+	}
+	if reply := send("3"); !strings.Contains(reply, "INVENTORY") {
+		t.Errorf("3 should show stock, got %q", reply)
+	}
+	if reply := send("42"); !strings.Contains(reply, "MENU") {
+		t.Errorf("an unknown option should resend the menu, got %q", reply)
+	}
+
+	if reply := send("set phone smart"); !strings.Contains(reply, "Numbered menus off") {
+		t.Fatalf("unexpected reply to set phone smart: %q", reply)
+	}
+	if reply := send("help"); !strings.Contains(reply, "COMMANDS") {
+		t.Errorf("help should be the full list again, got %q", reply)
+	}
+}
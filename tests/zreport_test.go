@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/C9b3rD3vi1/DukaPOS/internal/handlers"
+	printerhandler "github.com/C9b3rD3vi1/DukaPOS/internal/handlers/printer"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/models"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/repository"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/routes"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/printer"
+	"github.com/C9b3rD3vi1/DukaPOS/internal/services/zreport"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestZReportBuildAndClose tests the Z-report totals, the staff breakdown
+// and that sales after a close go into the next day's report
+func TestZReportBuildAndClose(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Staff{}, &models.Product{}, &models.Sale{}, &models.MpesaTransaction{},
+		&models.Customer{}, &models.LoyaltyTransaction{}, &models.DayClose{}, &models.Expense{},
+		&models.DailySummary{}, &models.WeeklySummary{}, &models.MonthlySummary{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", OwnerName: "Wanjiru", IsActive: true}
+	db.Create(shop)
+	staff := &models.Staff{ShopID: shop.ID, Name: "Otieno", Phone: "+254700000001", IsActive: true}
+	db.Create(staff)
+	db.Create(&models.Product{ID: 1, ShopID: shop.ID, Name: "Soda", Category: "Drinks", SellingPrice: 60, IsActive: true})
+	db.Create(&models.Product{ID: 2, ShopID: shop.ID, Name: "Mandazi", SellingPrice: 20, IsActive: true})
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	at := func(hour int) time.Time { return today.Add(time.Duration(hour) * time.Hour) }
+	basket := uint(7)
+	for _, sale := range []models.Sale{
+		// Sold yesterday, before the day began
+		{ShopID: shop.ID, ProductID: 1, Quantity: 1, TotalAmount: 999, PaymentMethod: models.PaymentCash, CreatedAt: at(-2)},
+		{ShopID: shop.ID, ProductID: 1, Quantity: 2, TotalAmount: 120, PaymentMethod: models.PaymentCash, CreatedAt: at(8)},
+		// Two items of one basket are one transaction
+		{ShopID: shop.ID, ProductID: 1, Quantity: 1, TotalAmount: 60, PaymentMethod: models.PaymentMpesa, PendingSaleID: &basket, StaffID: &staff.ID, CreatedAt: at(9)},
+		{ShopID: shop.ID, ProductID: 2, Quantity: 2, TotalAmount: 40, PaymentMethod: models.PaymentMpesa, PendingSaleID: &basket, StaffID: &staff.ID, CreatedAt: at(9)},
+		{ShopID: shop.ID, ProductID: 2, Quantity: 5, TotalAmount: 100, PaymentMethod: models.PaymentCash, StaffID: &staff.ID, CreatedAt: at(10)},
+		{ShopID: shop.ID, ProductID: 2, Quantity: 1, TotalAmount: 30, PaymentMethod: models.PaymentCard, CreatedAt: at(11)},
+	} {
+		sale := sale
+		db.Create(&sale)
+	}
+	db.Create(&models.LoyaltyTransaction{CustomerID: 1, ShopID: shop.ID, Type: models.LoyaltyRedeemed, Points: -50, Amount: 25, CreatedAt: at(9)})
+	db.Create(&models.LoyaltyTransaction{CustomerID: 1, ShopID: shop.ID, Type: models.LoyaltyEarned, Points: 10, Amount: 100, CreatedAt: at(9)})
+	reversedAt := at(10)
+	db.Create(&models.MpesaTransaction{ShopID: shop.ID, Type: "stk_push", Amount: 40, TransactionID: "QWE1", Status: "completed",
+		ReversalStatus: models.ReversalCompleted, ReversedAt: &reversedAt})
+	db.Create(&models.MpesaTransaction{ShopID: shop.ID, Type: "stk_push", Amount: 500, TransactionID: "QWE2", Status: "completed",
+		ReversalStatus: "pending", ReversedAt: &reversedAt})
+	// Airtime paid out of the drawer, stock paid for by M-Pesa and
+	// yesterday's transport
+	db.Create(&models.Expense{ShopID: shop.ID, Amount: 150, Category: "airtime", SpentAt: at(9)})
+	db.Create(&models.Expense{ShopID: shop.ID, Amount: 100, Category: "stock", PaymentMethod: models.PaymentMpesa, SpentAt: at(10)})
+	db.Create(&models.Expense{ShopID: shop.ID, Amount: 77, Category: "transport", SpentAt: at(-3)})
+
+	svc := zreport.New(repository.NewDayCloseRepository(db))
+	counted := 1100.0
+	report, err := svc.Build(shop, at(12), 1000, &counted)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if report.CashSales != 220 || report.MpesaSales != 100 || report.OtherSales != 30 || report.GrossSales != 350 {
+		t.Errorf("sales cash %.2f mpesa %.2f other %.2f gross %.2f; want 220, 100, 30, 350",
+			report.CashSales, report.MpesaSales, report.OtherSales, report.GrossSales)
+	}
+	if report.Transactions != 4 {
+		t.Errorf("transactions = %d; want 4 with the basket counted once", report.Transactions)
+	}
+	if report.Refunds != 40 || report.RefundCount != 1 || report.NetSales != 310 {
+		t.Errorf("refunds %.2f (%d), net %.2f; want 40 (1), 310", report.Refunds, report.RefundCount, report.NetSales)
+	}
+	if report.Expenses != 250 || report.CashExpenses != 150 || report.Net != 60 {
+		t.Errorf("expenses %.2f (cash %.2f), net %.2f; want 250 (150), 60", report.Expenses, report.CashExpenses, report.Net)
+	}
+	if report.ExpectedCash != 1070 || report.Variance == nil || *report.Variance != 30 {
+		t.Errorf("expected cash %.2f variance %v; want 1070 with the cash expense paid out, 30", report.ExpectedCash, report.Variance)
+	}
+	if len(report.ByStaff) != 2 || report.ByStaff[0].Name != "Otieno" || report.ByStaff[0].Total != 200 ||
+		report.ByStaff[0].Transactions != 2 || report.ByStaff[1].Name != "Owner" || report.ByStaff[1].Total != 150 {
+		t.Errorf("by staff = %+v; want Otieno 200 in 2, then Owner 150", report.ByStaff)
+	}
+	if report.Discounts != 25 {
+		t.Errorf("discounts = %.2f; want the 25 of points redeemed", report.Discounts)
+	}
+	if len(report.ByCategory) != 2 || report.ByCategory[0].Category != "Drinks" || report.ByCategory[0].Quantity != 3 ||
+		report.ByCategory[0].Total != 180 || report.ByCategory[1].Category != "Uncategorised" || report.ByCategory[1].Total != 170 {
+		t.Errorf("by category = %+v; want Drinks 3 for 180, then Uncategorised 170", report.ByCategory)
+	}
+	if report.Closed || report.Number != 1 {
+		t.Errorf("closed %v number %d; want the open day #1", report.Closed, report.Number)
+	}
+
+	if err := svc.Close(report, shop.OwnerName); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := db.Create(&models.DayClose{ShopID: shop.ID, Number: 1, BusinessDate: today, PeriodStart: today, PeriodEnd: at(12)}).Error; err == nil {
+		t.Error("a second close #1 was saved; want Z numbers unique per shop")
+	}
+	db.Create(&models.Sale{ShopID: shop.ID, ProductID: 1, Quantity: 1, TotalAmount: 75, PaymentMethod: models.PaymentCash, CreatedAt: at(13)})
+
+	next, err := svc.Build(shop, at(14), 0, nil)
+	if err != nil {
+		t.Fatalf("Build after close: %v", err)
+	}
+	if next.Number != 2 || next.GrossSales != 75 || next.Transactions != 1 || next.Refunds != 0 || !next.PeriodStart.Equal(at(12)) {
+		t.Errorf("after close: #%d gross %.2f in %d, refunds %.2f, from %v; want #2 with only the 75 sale from noon",
+			next.Number, next.GrossSales, next.Transactions, next.Refunds, next.PeriodStart)
+	}
+
+	closed, err := svc.Get(shop, 1)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !closed.Closed || closed.GrossSales != 350 || closed.ClosedBy != "Wanjiru" || len(closed.ByStaff) != 2 || closed.Variance == nil {
+		t.Errorf("close #1 = %+v", closed)
+	}
+
+	// A closed day's report by date is its close; a day never closed has
+	// all of its sales
+	closed, err = svc.ForDate(shop, today, at(14))
+	if err != nil || !closed.Closed || closed.Number != 1 || len(closed.ByCategory) != 2 {
+		t.Errorf("ForDate(today) = %+v, %v; want close #1", closed, err)
+	}
+	yesterday, err := svc.ForDate(shop, today.AddDate(0, 0, -1), at(14))
+	if err != nil || yesterday.Closed || yesterday.GrossSales != 999 || yesterday.Transactions != 1 {
+		t.Errorf("ForDate(yesterday) = %+v, %v; want its 999 sale, not closed", yesterday, err)
+	}
+}
+
+// TestZReportEndpointsAndCommand tests the Z-report endpoints in each
+// format, closing the day and the zreport command
+func TestZReportEndpointsAndCommand(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Staff{}, &models.Product{}, &models.Sale{}, &models.MpesaTransaction{},
+		&models.Customer{}, &models.LoyaltyTransaction{}, &models.DayClose{}, &models.Expense{}, &models.AuditLog{}, &models.PrinterSetting{},
+		&models.DailySummary{}, &models.WeeklySummary{}, &models.MonthlySummary{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", OwnerName: "Wanjiru", IsActive: true}
+	db.Create(shop)
+	db.Create(&models.Sale{ShopID: shop.ID, ProductID: 1, Quantity: 2, TotalAmount: 120, PaymentMethod: models.PaymentCash,
+		CreatedAt: time.Now().Add(-time.Second)})
+
+	zreports := zreport.New(repository.NewDayCloseRepository(db))
+	h := handlers.NewReportHandler(repository.NewSaleRepository(db), repository.NewProductRepository(db),
+		repository.NewDailySummaryRepository(db))
+	h.SetZReportService(zreports)
+	app := serverApp(t, db, routes.RouteConfig{ReportHandler: h}, shop)
+
+	do := func(method, path, body string) (int, string, []byte) {
+		t.Helper()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(r)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		out, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, resp.Header.Get("Content-Type"), out
+	}
+
+	status, _, out := do("GET", "/api/v1/reports/zreport?opening_float=500&counted=650", "")
+	var report map[string]interface{}
+	json.Unmarshal(out, &report)
+	if status != fiber.StatusOK || report["cash_sales"] != 120.0 || report["expected_cash"] != 620.0 ||
+		report["variance"] != 30.0 || report["closed"] != false {
+		t.Errorf("GET zreport: status %d %s", status, out)
+	}
+	if status, _, out := do("GET", "/api/v1/reports/zreport?opening_float=-1", ""); status != fiber.StatusBadRequest {
+		t.Errorf("negative float: status %d %s; want 400", status, out)
+	}
+	status, contentType, out := do("GET", "/api/v1/reports/zreport?format=text", "")
+	if status != fiber.StatusOK || !strings.HasPrefix(contentType, "text/plain") ||
+		!strings.Contains(string(out), "Z-REPORT (NOT CLOSED)") || !strings.Contains(string(out), "KSh 120") {
+		t.Errorf("text zreport: status %d %s\n%s", status, contentType, out)
+	}
+	status, contentType, out = do("GET", "/api/v1/reports/zreport?format=pdf", "")
+	if status != fiber.StatusOK || contentType != "application/pdf" || !bytes.HasPrefix(out, []byte("%PDF")) {
+		t.Errorf("pdf zreport: status %d %s", status, contentType)
+	}
+
+	// A bad format is refused before the day is closed
+	if status, _, out := do("POST", "/api/v1/reports/zreport/close?format=xml", `{}`); status != fiber.StatusBadRequest {
+		t.Errorf("close with format=xml: status %d %s; want 400", status, out)
+	}
+	status, _, out = do("POST", "/api/v1/reports/zreport/close", `{"opening_float": 500, "counted_cash": 600}`)
+	json.Unmarshal(out, &report)
+	if status != fiber.StatusOK || report["closed"] != true || report["number"] != 1.0 || report["closed_by"] != "Wanjiru" ||
+		math.Abs(report["variance"].(float64)+20) > 0.001 {
+		t.Errorf("close: status %d %s", status, out)
+	}
+	if status, _, out := do("GET", "/api/v1/reports/zreport?number=2", ""); status != fiber.StatusNotFound {
+		t.Errorf("GET #2: status %d %s; want 404", status, out)
+	}
+	status, _, out = do("GET", "/api/v1/reports/zreport/history", "")
+	var history struct {
+		Reports []map[string]interface{} `json:"reports"`
+		Count   int                      `json:"count"`
+	}
+	json.Unmarshal(out, &history)
+	if status != fiber.StatusOK || history.Count != 1 || history.Reports[0]["gross_sales"] != 120.0 {
+		t.Errorf("history: status %d %s", status, out)
+	}
+
+	cmdHandler := services.NewCommandHandler(db,
+		repository.NewShopRepository(db),
+		repository.NewProductRepository(db),
+		repository.NewSaleRepository(db),
+		repository.NewDailySummaryRepository(db),
+		repository.NewAuditLogRepository(db),
+	)
+	cmdHandler.SetZReportService(zreports)
+	send := func(text string) string {
+		t.Helper()
+		reply, err := cmdHandler.Handle(shop.Phone, services.NewCommandParser(nil, nil).Parse(text))
+		if err != nil {
+			t.Fatalf("%q error: %v", text, err)
+		}
+		return reply
+	}
+
+	// The day was closed, so the open day has nothing yet
+	if reply := send("zreport"); !strings.Contains(reply, "NOT CLOSED") || !strings.Contains(reply, "zreport close") ||
+		strings.Contains(reply, "KSh 120") {
+		t.Errorf("zreport = %q; want an empty open day", reply)
+	}
+	if reply := send("zreport 1"); !strings.Contains(reply, "Z-REPORT #1") || !strings.Contains(reply, "KSh 120") {
+		t.Errorf("zreport 1 = %q; want close #1", reply)
+	}
+	if reply := send("zreport close abc"); !strings.Contains(reply, "Usage") {
+		t.Errorf("zreport close abc = %q; want usage", reply)
+	}
+	if reply := send("zreport close 0"); !strings.Contains(reply, "Z-REPORT #2") || !strings.Contains(reply, "Day closed by Wanjiru") {
+		t.Errorf("zreport close 0 = %q; want close #2", reply)
+	}
+	if reply := send("zreport yesterday"); !strings.Contains(reply, "NOT CLOSED") || strings.Contains(reply, "zreport close") {
+		t.Errorf("zreport yesterday = %q; want yesterday's sales without offering to close it", reply)
+	}
+	if reply := send("zreport 2099-01-01"); !strings.Contains(reply, "Usage") {
+		t.Errorf("zreport in the future = %q; want usage", reply)
+	}
+}
+
+// TestPrintZReport tests printing a day's Z-report on the shop's thermal
+// printer, for a closed day and one that never was
+func TestPrintZReport(t *testing.T) {
+	db := openTestDB(t, &models.Shop{}, &models.Staff{}, &models.Product{}, &models.Sale{}, &models.MpesaTransaction{},
+		&models.Customer{}, &models.LoyaltyTransaction{}, &models.DayClose{}, &models.Expense{}, &models.PrinterSetting{},
+		&models.DailySummary{}, &models.WeeklySummary{}, &models.MonthlySummary{})
+	shop := &models.Shop{Name: "Mama Mboga", Phone: "+254712345678", OwnerName: "Wanjiru", IsActive: true}
+	db.Create(shop)
+	db.Create(&models.Product{ID: 1, ShopID: shop.ID, Name: "Soda", Category: "Drinks", SellingPrice: 60, IsActive: true})
+	yesterday := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+	db.Create(&models.Sale{ShopID: shop.ID, ProductID: 1, Quantity: 3, TotalAmount: 180, PaymentMethod: models.PaymentMpesa,
+		CreatedAt: yesterday.Add(10 * time.Hour)})
+
+	host, port, jobs := fakePrinter(t)
+	h := printerhandler.New(printer.New(&printer.PrinterConfig{Type: "thermal", Host: host, Port: port, PaperWidth: 80}))
+	h.SetShopRepo(repository.NewShopRepository(db))
+	zreports := zreport.New(repository.NewDayCloseRepository(db))
+	h.SetZReportService(zreports)
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("shop_id", shop.ID)
+		return c.Next()
+	})
+	app.Post("/print/zreport", h.PrintZReport)
+	do := func(body string) (int, map[string]interface{}) {
+		t.Helper()
+		r := httptest.NewRequest("POST", "/print/zreport", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(r)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		var out map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	for _, body := range []string{`{"date": "30/11/2024"}`, `{"date": "2099-01-01"}`, `{"format": "pdf"}`} {
+		if status, out := do(body); status != fiber.StatusBadRequest {
+			t.Errorf("%s: status %d %v; want 400", body, status, out)
+		}
+	}
+	if status, out := do(`{"number": 3}`); status != fiber.StatusNotFound {
+		t.Errorf("unknown close: status %d %v; want 404", status, out)
+	}
+
+	// The shop never closes its days: yesterday's report is its sales
+	status, out := do(`{"date": "` + yesterday.Format("2006-01-02") + `"}`)
+	if status != fiber.StatusOK || out["printed"] != true || out["closed"] != false {
+		t.Fatalf("print yesterday: status %d %v", status, out)
+	}
+	job := string(nextJob(t, jobs))
+	for _, want := range []string{"Z-REPORT (NOT CLOSED)", "M-Pesa sales:", "KSh 180", "By category:", "Drinks (3)", "\x1dVB\x00"} {
+		if !strings.Contains(job, want) {
+			t.Errorf("printed Z-report is missing %q:\n%s", want, job)
+		}
+	}
+	if text, _ := out["text"].(string); !strings.Contains(text, "Drinks (3)") || strings.Contains(text, "\x1b") {
+		t.Errorf("text = %q; want the report without printer commands", text)
+	}
+	if strings.Contains(job, "\x1bp") {
+		t.Error("printing a Z-report opened the cash drawer")
+	}
+
+	// Today's report once the day is closed, as text only
+	report, _ := zreports.Build(shop, time.Now(), 0, nil)
+	zreports.Close(report, "Wanjiru")
+	status, out = do(`{"format": "text"}`)
+	if status != fiber.StatusOK || out["printed"] != false || out["closed"] != true || out["number"] != 1.0 {
+		t.Errorf("text of today's close: status %d %v", status, out)
+	}
+	select {
+	case <-jobs:
+		t.Error("format text sent the report to the printer")
+	case <-time.After(100 * time.Millisecond):
+	}
+}